@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+var walletAccountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Manage the HD accounts of a wallet",
+	Long: `Every wallet starts with BIP-86 account 0, m/86'/coin'/0'. Further
+accounts, m/86'/coin'/N', keep funds apart under the same prophecy: each
+has its own receive and change branches, scanned with the gap limit and
+signed for by send and consolidate.`,
+}
+
+var walletAccountAddCmd = &cobra.Command{
+	Use:   "add [wallet-name] [account]",
+	Short: "Track another HD account of the wallet",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		index, err := strconv.ParseUint(args[1], 10, 31)
+		if err != nil {
+			return fmt.Errorf("invalid account %q", args[1])
+		}
+
+		f, err := wallet.OpenWalletFile(walletFilePath(cmd, walletName))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("wallet %s has no keys to derive accounts from", walletName)
+			}
+			return err
+		}
+		passphrase, err := walletPassphrase(cmd, false)
+		if err != nil {
+			return err
+		}
+		hd, err := f.Unlock(passphrase, networkParams(cmd))
+		if err != nil {
+			return err
+		}
+		account, err := hd.Account(uint32(index))
+		if err != nil {
+			return err
+		}
+		desc, err := account.Descriptor()
+		if err != nil {
+			return err
+		}
+
+		store, err := openDescriptorStore(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		if _, err := store.Import(desc, fmt.Sprintf("hd account %d", index), 0, 1000, true); err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Account %d added to %s\n", index, walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Descriptor: %s\n", desc)
+		fmt.Printf("\nShow an address with: exs-node wallet address %s --account %d\n", walletName, index)
+		return nil
+	},
+}
+
+var walletAccountListCmd = &cobra.Command{
+	Use:   "list [wallet-name]",
+	Short: "List the HD accounts a wallet tracks",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		net := networkParams(cmd)
+		store, err := openDescriptorStore(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		fmt.Printf("Accounts of %s:\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		found := false
+		for _, entry := range store.List() {
+			desc, err := wallet.ParseDescriptor(entry.Descriptor)
+			if err != nil {
+				return err
+			}
+			index, ok := wallet.AccountIndex(desc, net)
+			if !ok {
+				continue
+			}
+			found = true
+			_, path, _ := strings.Cut(desc.Origin, "/")
+			var change uint32
+			if len(entry.NextIndex) > wallet.ChangeBranch {
+				change = entry.NextIndex[wallet.ChangeBranch]
+			}
+			fmt.Printf("  %-4d m/%s  next receive %d, change %d  %s\n", index, path, entry.NextIndex[wallet.ReceiveBranch], change, entry.Label)
+		}
+		if !found {
+			fmt.Println("  none (use wallet create or wallet account add)")
+		}
+		return nil
+	},
+}
+
+func init() {
+	walletAccountAddCmd.Flags().StringP("passphrase", "p", "", "wallet passphrase (prompted if omitted)")
+
+	walletAccountCmd.AddCommand(walletAccountAddCmd, walletAccountListCmd)
+	walletCmd.AddCommand(walletAccountCmd)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/spf13/cobra"
+)
+
+var analyticsCmd = &cobra.Command{
+	Use:   "analytics",
+	Short: "Supply and distribution statistics",
+	Long: `Scan the local chain for supply issuance over time, the distribution of
+unspent coins across holders, the treasury's share of issuance and average
+fees per block. --json prints the full report for dashboards.
+
+The chain database is read directly, so the node must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		interval, _ := cmd.Flags().GetInt32("interval")
+		treasury, _ := cmd.Flags().GetString("treasury")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		opts := chain.AnalyticsOptions{Interval: interval}
+		if treasury != "" {
+			net := networkParams(cmd)
+			addr, err := btcutil.DecodeAddress(treasury, net)
+			if err != nil || !addr.IsForNet(net) {
+				return fmt.Errorf("%s is not a %s address", treasury, net.Name)
+			}
+			if opts.TreasuryScript, err = txscript.PayToAddrScript(addr); err != nil {
+				return err
+			}
+		}
+
+		if status, err := readStatus(cmd); err != nil {
+			return err
+		} else if status != nil {
+			return errors.New("the node is running; stop it to read the chain database")
+		}
+		if _, err := os.Stat(chainPath(cmd)); err != nil {
+			return errors.New("no chain database (the chain is created by node start)")
+		}
+		store, err := openChain(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		report, err := store.Analyze(opts)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		printAnalytics(report, treasury != "")
+		return nil
+	},
+}
+
+// printAnalytics prints the human-readable report
+func printAnalytics(a *chain.Analytics, treasury bool) {
+	exs := func(sats int64) float64 { return btcutil.Amount(sats).ToBTC() }
+
+	fmt.Println("📈 Chain Analytics")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Best Block:      %d (%s)\n", a.Height, a.Hash)
+	if a.FromHeight > 0 {
+		fmt.Printf("Scanned:         blocks %d-%d (below %d pruned)\n", a.FromHeight, a.Height, a.FromHeight)
+	}
+	fmt.Printf("Transactions:    %d\n", a.Transactions)
+	fmt.Printf("Issued:          %.8f EXS\n", exs(a.Issued))
+	fmt.Printf("Unspent:         %.8f EXS\n", exs(a.Unspent))
+	fmt.Printf("Fees:            %.8f EXS (%.8f EXS per block)\n", exs(a.Fees), a.AverageFee/btcutil.SatoshiPerBitcoin)
+	if treasury {
+		fmt.Printf("Treasury:        %.8f EXS (%.2f%% of issuance)\n", exs(a.Treasury), a.TreasuryShare*100)
+	}
+
+	fmt.Println()
+	fmt.Println("Issuance")
+	for _, p := range a.Issuance {
+		fmt.Printf("  %7d-%-7d %s  %16.8f EXS  fees %.8f  supply %.8f\n", p.StartHeight, p.EndHeight,
+			p.EndTime.Format("2006-01-02"), exs(p.Issued), exs(p.Fees), exs(p.Supply))
+	}
+
+	fmt.Println()
+	fmt.Printf("Holders          %d\n", a.Holders)
+	for _, b := range a.Buckets {
+		label := fmt.Sprintf("%g-%g EXS", exs(b.Min), exs(b.Max))
+		if b.Max == 0 {
+			label = fmt.Sprintf("%g+ EXS", exs(b.Min))
+		}
+		fmt.Printf("  %-14s %7d holders  %16.8f EXS  %6.2f%%\n", label, b.Holders, exs(b.Amount), a.Share(b)*100)
+	}
+}
+
+func init() {
+	analyticsCmd.Flags().Int32("interval", chain.DefaultAnalyticsInterval, "blocks per issuance period")
+	analyticsCmd.Flags().String("treasury", "", "treasury address whose coinbase share to report")
+	analyticsCmd.Flags().Bool("json", false, "print the report as JSON")
+
+	rootCmd.AddCommand(analyticsCmd)
+}
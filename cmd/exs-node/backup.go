@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/backup"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up and restore persistent state",
+	Long: `Back up wallets, the address book, the epoch schedule and chain metadata,
+plus the treasury ledger and Guardian store when backup.treasury_dir and
+backup.guardian_store point at them. The chain itself is not archived; a
+restored node resyncs it, and the archived metadata records the tip it had.
+
+Backups are written to backup.target, a directory (default <datadir>/backups)
+or an S3 bucket as s3://bucket/prefix with credentials from the standard
+AWS_* variables. With backup.enabled, node start backs up every
+backup.interval minutes, verifies each backup and keeps the newest
+backup.keep.`,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Take a backup now",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, err := backupTarget(cmd)
+		if err != nil {
+			return err
+		}
+		result := backupSchedule().Backup(cmd.Context(), target, backupSources(cmd, nil))
+		if result.Err != nil {
+			return result.Err
+		}
+		printBackupResult(result)
+		return nil
+	},
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backups",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, err := backupTarget(cmd)
+		if err != nil {
+			return err
+		}
+		backups, err := backup.List(cmd.Context(), target)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("🗄️  Backups in %s\n", target)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		if len(backups) == 0 {
+			fmt.Println("No backups")
+			return nil
+		}
+		for _, b := range backups {
+			fmt.Printf("%s  %s  %s\n", b.Name, b.CreatedAt.Local().Format(time.DateTime), formatBytes(uint64(b.Size)))
+		}
+		return nil
+	},
+}
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify [name]",
+	Short: "Check a backup against its manifest (default the latest)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, name, err := backupByName(cmd, args)
+		if err != nil {
+			return err
+		}
+		manifest, err := backup.Verify(cmd.Context(), target, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s: %d files match the manifest\n", name, len(manifest.Files))
+		return nil
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore [name]",
+	Short: "Verify a backup and extract it (default the latest)",
+	Long: `Verify a backup and extract it into --dir, which must be empty or not
+exist. Nothing is extracted unless every file matches the manifest. Stop the
+node and move the restored files into the data directory yourself, so a
+restore never overwrites live state.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+		if dir == "" {
+			return errors.New("--dir is required")
+		}
+		target, name, err := backupByName(cmd, args)
+		if err != nil {
+			return err
+		}
+		manifest, err := backup.Restore(cmd.Context(), target, name, dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Restored %d files from %s into %s\n", len(manifest.Files), name, dir)
+		fmt.Printf("  Taken: %s\n", manifest.CreatedAt.Local().Format(time.DateTime))
+		fmt.Println("\nStop the node before moving the restored files into place.")
+		return nil
+	},
+}
+
+func init() {
+	backupRestoreCmd.Flags().String("dir", "", "directory to restore into")
+
+	backupCmd.AddCommand(
+		backupCreateCmd,
+		backupListCmd,
+		backupVerifyCmd,
+		backupRestoreCmd,
+	)
+
+	rootCmd.AddCommand(backupCmd)
+}
+
+// backupTarget opens backup.target, or <datadir>/backups when it is empty
+func backupTarget(cmd *cobra.Command) (backup.Target, error) {
+	location := config.Backup.Target
+	if location == "" {
+		location = filepath.Join(dataDir(cmd), "backups")
+	}
+	return backup.OpenTarget(location)
+}
+
+// backupByName opens the target and returns the backup named in args, or
+// the latest
+func backupByName(cmd *cobra.Command, args []string) (backup.Target, string, error) {
+	target, err := backupTarget(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(args) > 0 {
+		return target, args[0], nil
+	}
+	latest, err := backup.Latest(cmd.Context(), target)
+	if err != nil {
+		return nil, "", err
+	}
+	return target, latest.Name, nil
+}
+
+// backupSchedule returns the configured backup schedule
+func backupSchedule() backup.Schedule {
+	return backup.Schedule{
+		Interval: time.Duration(config.Backup.Interval) * time.Minute,
+		Keep:     config.Backup.Keep,
+		Verify:   config.Backup.Verify,
+	}
+}
+
+// chainMetadata records the chain a backup was taken with. Blocks are not
+// archived, so a restored node knows what tip to expect after resyncing.
+type chainMetadata struct {
+	Network     string `json:"network"`
+	Backend     string `json:"backend,omitempty"`
+	Height      int32  `json:"height"`
+	Hash        string `json:"hash,omitempty"`
+	ChainWork   string `json:"chain_work,omitempty"`
+	PrunedBelow int32  `json:"pruned_below,omitempty"`
+	TxIndex     bool   `json:"txindex"`
+}
+
+// backupSources lists the state to back up. store is the running node's
+// chain; without one the chain is read from the status file of a running
+// node, or opened if the node is stopped.
+func backupSources(cmd *cobra.Command, store *chain.Chain) []backup.Source {
+	dir := dataDir(cmd)
+	sources := []backup.Source{
+		{Name: "wallets", Path: filepath.Join(dir, "wallets")},
+		{Name: "addressbook.json", Path: addressBookPath(cmd)},
+		{Name: "epochs.json", Path: epochSchedulePath(cmd)},
+		{Name: "chain/metadata.json", Data: func() ([]byte, error) {
+			meta, err := readChainMetadata(cmd, store)
+			if err != nil {
+				return nil, err
+			}
+			return json.MarshalIndent(meta, "", "  ")
+		}},
+	}
+	if config.Backup.TreasuryDir != "" {
+		sources = append(sources, backup.Source{Name: "treasury", Path: config.Backup.TreasuryDir})
+	}
+	if path := config.Backup.GuardianStore; path != "" {
+		sources = append(sources, backup.Source{Name: "guardian/" + filepath.Base(path), Path: path})
+	}
+	return sources
+}
+
+func readChainMetadata(cmd *cobra.Command, store *chain.Chain) (*chainMetadata, error) {
+	meta := &chainMetadata{
+		Network: networkParams(cmd).Name,
+		Backend: config.Storage.Backend,
+		Height:  -1,
+		TxIndex: config.Storage.TxIndex,
+	}
+	if store == nil {
+		status, err := readStatus(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if status != nil {
+			meta.Height, meta.Hash = status.BestHeight, status.BestHash
+			return meta, nil
+		}
+		if _, err := os.Stat(chainPath(cmd)); err != nil {
+			// No chain yet
+			return meta, nil
+		}
+		if store, err = openChain(cmd); err != nil {
+			return nil, err
+		}
+		defer store.Close()
+	}
+
+	tip := store.Tip()
+	meta.Height, meta.Hash = tip.Height, tip.Hash.String()
+	meta.ChainWork = tip.ChainWork.Text(16)
+	meta.PrunedBelow = store.PruneHeight()
+	return meta, nil
+}
+
+// runBackups backs up on the configured schedule until ctx is done
+func runBackups(ctx context.Context, cmd *cobra.Command, store *chain.Chain) error {
+	target, err := backupTarget(cmd)
+	if err != nil {
+		return err
+	}
+	backupSchedule().Run(ctx, target, backupSources(cmd, store), logBackupResult)
+	return nil
+}
+
+func printBackupResult(result backup.Result) {
+	if result.Err != nil {
+		fmt.Printf("✗ Backup failed: %v\n", result.Err)
+		return
+	}
+	verified := ""
+	if result.Verified {
+		verified = ", verified"
+	}
+	fmt.Printf("🗄️  Backup %s (%s%s)\n", result.Info.Name, formatBytes(uint64(result.Info.Size)), verified)
+	for _, name := range result.Pruned {
+		fmt.Printf("   pruned %s\n", name)
+	}
+}
+
+// logBackupResult logs a scheduled backup of the running node
+func logBackupResult(result backup.Result) {
+	if result.Err != nil {
+		slog.Error("Backup failed", "err", result.Err)
+		return
+	}
+	slog.Info("Backup taken",
+		"name", result.Info.Name,
+		"size", formatBytes(uint64(result.Info.Size)),
+		"verified", result.Verified,
+		"pruned", result.Pruned)
+}
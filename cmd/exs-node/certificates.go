@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/inscription"
+)
+
+var forgeCertificatesCmd = &cobra.Command{
+	Use:   "certificates",
+	Short: "List forge certificates inscribed on chain",
+	Long: `Index the forge certificates inscribed on the configured network and list
+them by forge number. A certificate is an Ordinals inscription of a forge's
+number, block height and Tetra-PoW proof hash, inscribed by the miner when
+the treasury records the forge; the first one inscribed for a forge counts.
+
+The index is kept in the data directory and brought up to the chain tip on
+each run. The first run needs --from, the height to start scanning at;
+--from again rebuilds the index, e.g. after a reorganization. Blocks come
+from an Esplora API (--backend, default blockstream.info for mainnet and
+testnet).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		net := networkParams(cmd)
+		path := certificateIndexPath(cmd)
+		owner, _ := cmd.Flags().GetString("owner")
+		offline, _ := cmd.Flags().GetBool("offline")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		index, err := inscription.LoadIndex(path)
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("from") {
+			from, _ := cmd.Flags().GetInt32("from")
+			index = inscription.NewIndex(from)
+		}
+		if index == nil {
+			return fmt.Errorf("no certificate index for %s yet (use --from with the height to start at)", net.Name)
+		}
+
+		if !offline {
+			source, err := chainSource(cmd)
+			if err != nil {
+				return err
+			}
+			blocks, ok := source.(inscription.BlockSource)
+			if !ok {
+				return errors.New("the chain backend does not serve blocks")
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			fmt.Fprintf(os.Stderr, "Indexing %s from block %d...\n", net.Name, index.Height+1)
+			// Saved either way, so an interrupted scan resumes where it stopped
+			syncErr := index.Sync(ctx, blocks, net)
+			if err := index.Save(path); err != nil {
+				return err
+			}
+			if errors.Is(syncErr, inscription.ErrReorg) {
+				return fmt.Errorf("%w (rebuild with --from)", syncErr)
+			}
+			if syncErr != nil && !errors.Is(syncErr, context.Canceled) {
+				return syncErr
+			}
+		}
+
+		records := index.List(owner)
+		if asJSON {
+			out, err := json.MarshalIndent(records, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+		fmt.Println("📜 Forge Certificates")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for _, r := range records {
+			fmt.Printf("Forge #%d (height %d)\n", r.Forge, r.Height)
+			fmt.Printf("  Proof:        %s\n", r.Proof)
+			fmt.Printf("  Inscription:  %s (block %d)\n", r.InscriptionID, r.BlockHeight)
+			if r.Owner != "" {
+				fmt.Printf("  Owner:        %s\n", r.Owner)
+			}
+		}
+		fmt.Printf("%d certificate(s), indexed to block %d\n", len(records), index.Height)
+		return nil
+	},
+}
+
+// certificateIndexPath returns the forge certificate index of the
+// configured network
+func certificateIndexPath(cmd *cobra.Command) string {
+	return filepath.Join(dataDir(cmd), "certificates", networkParams(cmd).Name+".json")
+}
+
+func init() {
+	forgeCertificatesCmd.Flags().Int32("from", 0, "block height to (re)build the index from")
+	forgeCertificatesCmd.Flags().String("owner", "", "only list certificates sent to this address")
+	forgeCertificatesCmd.Flags().Bool("offline", false, "list the indexed certificates without contacting the chain")
+	forgeCertificatesCmd.Flags().Bool("json", false, "print the certificates as JSON")
+	forgeCertificatesCmd.Flags().String("backend", "", "Esplora API URL (default: wallet.backend or the network's public API)")
+	bindConfigFlags(forgeCertificatesCmd, map[string]string{"backend": "wallet.backend"})
+
+	forgeCmd.AddCommand(forgeCertificatesCmd)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/clock"
+)
+
+var nodeClockCmd = &cobra.Command{
+	Use:   "clock",
+	Short: "Check the local clock",
+	Long: `Compare the local clock with the clock.ntp_servers and, when the node is
+running, with the median clock of its peers.
+
+Block timestamps come from the local clock. The node warns when it is more
+than 5 minutes off and refuses to mine when it is more than 1 hour off,
+half the 2 hours a block may be ahead of its validators' clocks.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := clockConfig(nil)
+		status := clock.Status{}
+
+		fmt.Println("🕰️  Clock Check")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Local Time:      %s\n", time.Now().Format(time.RFC3339))
+		if len(cfg.NTPServers) == 0 {
+			fmt.Println("NTP:             disabled (clock.ntp_servers is empty)")
+		}
+		var offsets []time.Duration
+		for _, server := range cfg.NTPServers {
+			ctx, cancel := context.WithTimeout(cmd.Context(), cfg.Timeout)
+			sample, err := clock.QueryNTP(ctx, server)
+			cancel()
+			if err != nil {
+				fmt.Printf("  ✗ %-22s %v\n", server, err)
+				continue
+			}
+			offsets = append(offsets, sample.Offset)
+			fmt.Printf("  ✓ %-22s offset %s, round trip %s\n", server, clock.FormatOffset(sample.Offset), sample.RTT.Round(time.Millisecond))
+		}
+		if len(offsets) > 0 {
+			status.NTPOffset, status.NTPServers = clock.MedianOffset(offsets), len(offsets)
+		} else if len(cfg.NTPServers) > 0 {
+			status.NTPError = clock.ErrNTPUnavailable.Error()
+		}
+
+		running, err := readStatus(cmd)
+		if err != nil {
+			return err
+		}
+		if running != nil && running.Clock != nil {
+			status.PeerOffset, status.PeerSamples = running.Clock.PeerOffset, running.Clock.PeerSamples
+		}
+
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Clock:           %s\n", status.String())
+		if running == nil {
+			fmt.Println("Peers:           node is not running")
+		} else if status.PeerSamples < clock.MinPeerSamples {
+			fmt.Printf("Peers:           %d connected, %d needed to compare\n", status.PeerSamples, clock.MinPeerSamples)
+		}
+		printClockStatus(status)
+		return nil
+	},
+}
+
+// clockConfig returns the clock monitor settings of the configuration,
+// comparing with peerOffset when it is not nil
+func clockConfig(peerOffset func() (time.Duration, int)) *clock.Config {
+	cfg := clock.DefaultConfig()
+	cfg.NTPServers = config.Clock.NTPServers
+	cfg.Interval = time.Duration(config.Clock.CheckInterval) * time.Minute
+	cfg.PeerOffset = peerOffset
+	return cfg
+}
+
+// logClockStatus logs a clock check of the running node at the level its
+// skew calls for
+func logClockStatus(status clock.Status) {
+	switch status.Level() {
+	case clock.LevelRefuse:
+		slog.Error("Clock skew refuses mining until the clock is corrected", "clock", status.String(), "err", status.Err())
+	case clock.LevelWarn:
+		for _, warning := range status.Warnings() {
+			slog.Warn("Clock skew; check the system time", "clock", status.String(), "warning", warning)
+		}
+	default:
+		slog.Info("Clock checked", "clock", status.String())
+	}
+}
+
+// printClockStatus prints whether the node may mine with the clock as it is
+func printClockStatus(status clock.Status) {
+	switch status.Level() {
+	case clock.LevelRefuse:
+		fmt.Printf("⛔ %v\n", status.Err())
+		fmt.Println("   Mining is refused until the clock is corrected")
+	case clock.LevelWarn:
+		for _, warning := range status.Warnings() {
+			fmt.Printf("⚠️  %s; check the system time\n", warning)
+		}
+	default:
+		if _, source, ok := status.Offset(); ok {
+			fmt.Printf("✓ Clock within %s of %s time\n", clock.WarnSkew, source)
+		}
+	}
+}
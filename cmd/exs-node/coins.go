@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/spf13/cobra"
+)
+
+var walletCoinsCmd = &cobra.Command{
+	Use:   "coins",
+	Short: "List, freeze and unfreeze the wallet's coins",
+	Long: `Coin control for the outputs found by the last wallet balance. A
+frozen coin is left out when send and consolidate select inputs, for
+example to keep a tainted or earmarked output apart; send --coin-control
+picks coins by hand instead.
+
+Frozen coins are kept in the wallet's BIP-329 labels as output records
+with "spendable": false, so they travel with wallet labels export.`,
+}
+
+var walletCoinsListCmd = &cobra.Command{
+	Use:   "list [wallet-name]",
+	Short: "List the wallet's coins and which are frozen",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		utxoFile, _ := cmd.Flags().GetString("utxos")
+		utxos, err := knownUTXOs(cmd, walletName, utxoFile)
+		if err != nil {
+			return err
+		}
+		labels, err := wallet.OpenLabelStore(labelsPath(cmd, walletName))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Coins of %s:\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		var spendable, frozen int64
+		for _, u := range utxos {
+			state := "  "
+			if labels.Frozen(u.Outpoint()) {
+				state = "❄ "
+				frozen += u.Value
+			} else {
+				spendable += u.Value
+			}
+			fmt.Printf("%s%s  %.8f EXS  %s", state, u.Outpoint(), btcutil.Amount(u.Value).ToBTC(), u.Address)
+			if label := firstNonEmpty(labels.Get(wallet.LabelOutput, u.Outpoint()), labels.Get(wallet.LabelAddress, u.Address)); label != "" {
+				fmt.Printf("  %s", label)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("\nSpendable: %.8f EXS\n", btcutil.Amount(spendable).ToBTC())
+		fmt.Printf("Frozen:    %.8f EXS\n", btcutil.Amount(frozen).ToBTC())
+		return nil
+	},
+}
+
+var walletCoinsFreezeCmd = &cobra.Command{
+	Use:   "freeze [wallet-name] [txid:vout...]",
+	Short: "Keep coins out of automatic coin selection",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return freezeCoins(cmd, args[0], args[1:], true)
+	},
+}
+
+var walletCoinsUnfreezeCmd = &cobra.Command{
+	Use:   "unfreeze [wallet-name] [txid:vout...]",
+	Short: "Make frozen coins spendable again",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return freezeCoins(cmd, args[0], args[1:], false)
+	},
+}
+
+// freezeCoins freezes or unfreezes the outputs of a wallet
+func freezeCoins(cmd *cobra.Command, walletName string, outpoints []string, frozen bool) error {
+	labels, err := wallet.OpenLabelStore(labelsPath(cmd, walletName))
+	if err != nil {
+		return err
+	}
+	for _, outpoint := range outpoints {
+		if err := labels.Freeze(outpoint, frozen); err != nil {
+			return err
+		}
+		if frozen {
+			fmt.Printf("✓ Frozen: %s\n", outpoint)
+		} else {
+			fmt.Printf("✓ Unfrozen: %s\n", outpoint)
+		}
+	}
+	return nil
+}
+
+// controlledUTXOs returns the wallet's outputs at outpoints for coin
+// control; unknown, frozen or repeated outpoints are refused
+func controlledUTXOs(cmd *cobra.Command, walletName, path string, outpoints []string) ([]wallet.UTXO, error) {
+	utxos, err := knownUTXOs(cmd, walletName, path)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := wallet.OpenLabelStore(labelsPath(cmd, walletName))
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]wallet.UTXO, len(utxos))
+	for _, u := range utxos {
+		known[u.Outpoint()] = u
+	}
+
+	selected := make([]wallet.UTXO, 0, len(outpoints))
+	seen := make(map[string]bool)
+	for _, arg := range outpoints {
+		outpoint, err := wallet.ParseOutpoint(arg)
+		if err != nil {
+			return nil, err
+		}
+		u, ok := known[outpoint]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("coin %s is not a known output of %s (run wallet balance or use --utxos)", outpoint, walletName)
+		case labels.Frozen(outpoint):
+			return nil, fmt.Errorf("coin %s is frozen (see wallet coins unfreeze)", outpoint)
+		case seen[outpoint]:
+			return nil, fmt.Errorf("coin %s given twice", outpoint)
+		}
+		seen[outpoint] = true
+		selected = append(selected, u)
+	}
+	return selected, nil
+}
+
+func init() {
+	walletCoinsListCmd.Flags().String("utxos", "", "JSON file of outputs (default: outputs from the last balance)")
+
+	walletCoinsCmd.AddCommand(walletCoinsListCmd, walletCoinsFreezeCmd, walletCoinsUnfreezeCmd)
+	walletCmd.AddCommand(walletCoinsCmd)
+}
@@ -1,72 +1,690 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chaos"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/lightning"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/p2p"
 )
 
+// defaultConfig is the file written by config init. It is also loaded
+// beneath every configuration, so it is the single source of defaults.
+const defaultConfig = `# Excalibur-EXS console node configuration
+#
+# Values can be overridden with EXS_* environment variables (EXS_NETWORK,
+# EXS_RPC_PASSWORD, EXS_MINING_ADDRESS, ...) and with command-line flags.
+
+network: mainnet  # mainnet, testnet, regtest
+datadir: ~/.excalibur-exs/data
+
+rpc:
+  enabled: true
+  bind: 127.0.0.1
+  port: 8332
+  user: excalibur
+  password: changeme
+  wallet: ""  # wallet served by getbalance and getnewaddress, empty for none
+  grpc_port: 0  # gRPC API port on rpc.bind, 0 to disable
+
+p2p:
+  bind: 0.0.0.0
+  port: 8333
+  max_peers: 125
+  listen: true
+  connect: []
+  features: compact-filters  # runes, compact-filters, forge-commitments
+  require_features: ""
+  encryption: prefer  # prefer, require, off
+  max_upload_rate: 0  # KB/s, 0 = unlimited
+  max_download_rate: 0  # KB/s, 0 = unlimited
+
+mining:
+  enabled: false
+  address: ""
+  threads: 0  # 0 = auto
+  pool: ""  # stratum+tcp://host:port, empty for solo mining
+  optimization: balanced  # power_save, balanced, performance, extreme
+
+clock:
+  # Block timestamps come from the local clock; the node warns when it is
+  # more than 5 minutes off and refuses to mine past 1 hour
+  ntp_servers: [pool.ntp.org, time.google.com, time.cloudflare.com]  # empty disables NTP checks
+  check_interval: 30  # minutes between NTP checks
+
+wallet:
+  backend: ""  # Esplora API URL, empty for the network's public API
+  gap_limit: 20
+
+fees:
+  estimator: ""  # Esplora API URL for fee estimates, empty for wallet.backend
+  mode: economical  # or conservative
+  target: 6  # blocks
+
+performance:
+  db_cache: 450  # MB
+  max_mempool: 300  # MB
+
+storage:
+  backend: ""  # leveldb, bolt or badger; empty keeps the chain in leveldb and wallets in JSON files
+  prune: false
+  prune_depth: 288  # recent blocks kept when pruning, at least 288
+  txindex: true
+  addrindex: true  # index coins by address for scantxoutset and Rosetta /account/coins
+
+backup:
+  enabled: false  # back up on a schedule while the node runs
+  interval: 360  # minutes between backups
+  target: ""  # directory or s3://bucket/prefix, empty for <datadir>/backups
+  keep: 14  # newest backups kept, 0 keeps every backup
+  verify: true  # download and check each backup after writing it
+  treasury_dir: ""  # treasury TREASURY_DATA_DIR to include, empty for none
+  guardian_store: ""  # Guardian store file to include, empty for none
+
+lightning:
+  # The protocol's Lightning node, read by revenue stats lightning
+  backend: ""  # lnd or cln, empty for none
+  url: ""  # REST endpoint: LND's REST proxy or CLN's clnrest
+  macaroon: ""  # LND macaroon file, e.g. readonly.macaroon
+  rune: ""  # CLN rune allowed listforwards and listpeerchannels
+  tls_cert: ""  # node certificate file, empty to trust the system roots
+  treasury: ""  # treasury API URL to show the fees credited, empty for none
+
+privacy:
+  tor: false
+  i2p: false
+
+# Fault injection for resilience testing, never for production: delays,
+# dropped connections and corrupted frames on P2P peers and the RPC server,
+# e.g. "delay=250ms,drop=0.01,corrupt=0.01" (see pkg/chaos)
+chaos: ""
+`
+
+// Config is the console node configuration
+type Config struct {
+	Network string `yaml:"network"`
+	DataDir string `yaml:"datadir"`
+
+	RPC struct {
+		Enabled  bool   `yaml:"enabled"`
+		Bind     string `yaml:"bind"`
+		Port     int    `yaml:"port"`
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+		Wallet   string `yaml:"wallet"`
+		GRPCPort int    `yaml:"grpc_port"`
+	} `yaml:"rpc"`
+
+	P2P struct {
+		Bind            string   `yaml:"bind"`
+		Port            int      `yaml:"port"`
+		MaxPeers        int      `yaml:"max_peers"`
+		Listen          bool     `yaml:"listen"`
+		Connect         []string `yaml:"connect"`
+		Features        string   `yaml:"features"`
+		RequireFeatures string   `yaml:"require_features"`
+		Encryption      string   `yaml:"encryption"`
+		MaxUploadRate   int64    `yaml:"max_upload_rate"`
+		MaxDownloadRate int64    `yaml:"max_download_rate"`
+	} `yaml:"p2p"`
+
+	Mining struct {
+		Enabled      bool   `yaml:"enabled"`
+		Address      string `yaml:"address"`
+		Threads      int    `yaml:"threads"`
+		Pool         string `yaml:"pool"`
+		Optimization string `yaml:"optimization"`
+	} `yaml:"mining"`
+
+	Clock struct {
+		NTPServers    []string `yaml:"ntp_servers"`
+		CheckInterval int      `yaml:"check_interval"`
+	} `yaml:"clock"`
+
+	Wallet struct {
+		Backend  string `yaml:"backend"`
+		GapLimit uint32 `yaml:"gap_limit"`
+	} `yaml:"wallet"`
+
+	Fees struct {
+		Estimator string `yaml:"estimator"`
+		Mode      string `yaml:"mode"`
+		Target    int    `yaml:"target"`
+	} `yaml:"fees"`
+
+	Performance struct {
+		DBCache    int `yaml:"db_cache"`
+		MaxMempool int `yaml:"max_mempool"`
+	} `yaml:"performance"`
+
+	Storage struct {
+		Backend    string `yaml:"backend"`
+		Prune      bool   `yaml:"prune"`
+		PruneDepth int32  `yaml:"prune_depth"`
+		TxIndex    bool   `yaml:"txindex"`
+		AddrIndex  bool   `yaml:"addrindex"`
+	} `yaml:"storage"`
+
+	Backup struct {
+		Enabled       bool   `yaml:"enabled"`
+		Interval      int    `yaml:"interval"`
+		Target        string `yaml:"target"`
+		Keep          int    `yaml:"keep"`
+		Verify        bool   `yaml:"verify"`
+		TreasuryDir   string `yaml:"treasury_dir"`
+		GuardianStore string `yaml:"guardian_store"`
+	} `yaml:"backup"`
+
+	Lightning struct {
+		Backend  string `yaml:"backend"`
+		URL      string `yaml:"url"`
+		Macaroon string `yaml:"macaroon"`
+		Rune     string `yaml:"rune"`
+		TLSCert  string `yaml:"tls_cert"`
+		Treasury string `yaml:"treasury"`
+	} `yaml:"lightning"`
+
+	Privacy struct {
+		Tor bool `yaml:"tor"`
+		I2P bool `yaml:"i2p"`
+	} `yaml:"privacy"`
+
+	Chaos string `yaml:"chaos"`
+
+	// path is the config file the configuration was read from, empty when
+	// only defaults, environment and flags apply
+	path string
+}
+
+// config is the configuration loaded for the running command
+var config *Config
+
+// configFlags maps each command's flags to the configuration keys they
+// override, registered with bindConfigFlags
+var configFlags = map[*cobra.Command]map[string]string{}
+
+// bindConfigFlags lets cmd's flags override configuration keys when set
+func bindConfigFlags(cmd *cobra.Command, flags map[string]string) {
+	if configFlags[cmd] == nil {
+		configFlags[cmd] = make(map[string]string, len(flags))
+	}
+	for flag, key := range flags {
+		configFlags[cmd][flag] = key
+	}
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Configuration management",
-	Long:  "View and manage node configuration settings",
+	Long: `View and manage node configuration settings.
+
+Settings are read from the config file (default ~/.excalibur-exs/config.yaml),
+then EXS_* environment variables, then command-line flags, each overriding
+the last. EXS_RPC_PORT overrides rpc.port, EXS_MINING_ADDRESS overrides
+mining.address, and so on.`,
+	// The config commands must work while the file is invalid, so they load
+	// it themselves
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
 }
 
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
-	Run: func(cmd *cobra.Command, args []string) {
+	Long:  "Show the effective configuration after environment variables and flags",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, c, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		shown := *c
+		if shown.RPC.Password != "" {
+			shown.RPC.Password = "********"
+		}
+		var out bytes.Buffer
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(&shown); err != nil {
+			return err
+		}
+
 		fmt.Println("⚙️  Configuration")
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		fmt.Println("Data Directory:    ~/.excalibur-exs/data")
-		fmt.Println("Config File:       ~/.excalibur-exs/config.yaml")
-		fmt.Println("Network:           mainnet")
-		fmt.Println("RPC Port:          8332")
-		fmt.Println("P2P Port:          8333")
-		fmt.Println("\nPrivacy Settings:")
-		fmt.Println("  Tor:             disabled")
-		fmt.Println("  I2P:             disabled")
-		fmt.Println("\nPerformance:")
-		fmt.Println("  Max Connections: 125")
-		fmt.Println("  DB Cache:        450 MB")
-		fmt.Println("\nStorage:")
-		fmt.Println("  Prune:           disabled")
-		fmt.Println("  TX Index:        enabled")
-		fmt.Println("✗ Not implemented yet")
+		if c.path != "" {
+			fmt.Printf("Config File: %s\n", c.path)
+		} else {
+			fmt.Printf("Config File: %s (not found, using defaults)\n", configPath(cmd))
+		}
+		fmt.Println()
+		fmt.Print(out.String())
+		return nil
 	},
 }
 
 var configSetCmd = &cobra.Command{
 	Use:   "set [key] [value]",
 	Short: "Set configuration value",
-	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
-		key := args[0]
-		value := args[1]
-		
-		fmt.Printf("Setting %s = %s\n", key, value)
-		fmt.Println("✗ Not implemented yet")
+	Long: `Set a key in the config file, e.g. "exs-node config set p2p.port 18333".
+Lists are comma separated. The file is created from the defaults if missing,
+and the change is refused if it would make the configuration invalid.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configPath(cmd)
+		if err := setConfigValue(path, args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s = %s (%s)\n", args[0], args[1], path)
+		return nil
 	},
 }
 
 var configInitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize configuration",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Initializing configuration...")
-		fmt.Println("Creating data directory...")
-		fmt.Println("Generating default config file...")
-		fmt.Println("✓ Configuration initialized")
-		fmt.Println("✗ Not implemented yet")
+	Long:  "Write the default config file and create the data directory",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configPath(cmd)
+		force, _ := cmd.Flags().GetBool("force")
+		if _, err := os.Stat(path); err == nil && !force {
+			return fmt.Errorf("config file %s already exists (use --force to overwrite)", path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		// The default RPC password is a placeholder, so keep the file private
+		if err := os.WriteFile(path, []byte(defaultConfig), 0600); err != nil {
+			return fmt.Errorf("failed to write config file: %w", err)
+		}
+		fmt.Printf("✓ Wrote %s\n", path)
+
+		_, c, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(c.DataDir, 0700); err != nil {
+			return fmt.Errorf("failed to create data directory: %w", err)
+		}
+		fmt.Printf("✓ Data directory %s\n", c.DataDir)
+		fmt.Println("\nSet your own RPC password: exs-node config set rpc.password <password>")
+		return nil
 	},
 }
 
 func init() {
+	configInitCmd.Flags().Bool("force", false, "overwrite an existing config file")
+
 	configCmd.AddCommand(
 		configShowCmd,
 		configSetCmd,
 		configInitCmd,
 	)
-	
+
 	rootCmd.AddCommand(configCmd)
 }
+
+// initConfig loads and validates the configuration for cmd
+func initConfig(cmd *cobra.Command) error {
+	_, c, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	config = c
+	return nil
+}
+
+// configPath returns the config file selected by --config or the default
+// ~/.excalibur-exs/config.yaml
+func configPath(cmd *cobra.Command) string {
+	if path, _ := cmd.Flags().GetString("config"); path != "" {
+		return path
+	}
+	return filepath.Join(homeDir(), ".excalibur-exs", "config.yaml")
+}
+
+// loadConfig reads the configuration for cmd: defaults, the config file,
+// EXS_* environment variables, then cmd's flags
+func loadConfig(cmd *cobra.Command) (*viper.Viper, *Config, error) {
+	path := configPath(cmd)
+	explicit, _ := cmd.Flags().GetString("config")
+	v, found, err := readConfig(path, explicit != "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bindings := map[string]string{"datadir": "datadir"}
+	for flag, key := range configFlags[cmd] {
+		bindings[flag] = key
+	}
+	for flag, key := range bindings {
+		if f := cmd.Flags().Lookup(flag); f != nil {
+			if err := v.BindPFlag(key, f); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if regtest, _ := cmd.Flags().GetBool("regtest"); regtest {
+		v.Set("network", "regtest")
+	} else if testnet, _ := cmd.Flags().GetBool("testnet"); testnet {
+		v.Set("network", "testnet")
+	}
+
+	c, err := decodeConfig(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	if found {
+		c.path = path
+	}
+	return v, c, nil
+}
+
+// readConfig layers the config file at path, if any, and EXS_* environment
+// variables over the defaults. A missing file is an error only if explicit.
+func readConfig(path string, explicit bool) (v *viper.Viper, found bool, err error) {
+	if v, err = defaultViper(); err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := v.MergeConfig(strings.NewReader(string(data))); err != nil {
+			return nil, false, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		found = true
+	case errors.Is(err, fs.ErrNotExist) && !explicit:
+	default:
+		return nil, false, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	v.SetEnvPrefix("EXS")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	return v, found, nil
+}
+
+// defaultViper returns the default configuration alone
+func defaultViper() (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(defaultConfig)); err != nil {
+		return nil, fmt.Errorf("invalid default config: %w", err)
+	}
+	return v, nil
+}
+
+// decodeConfig decodes and validates the settings of v, rejecting unknown
+// keys so typos in the file are not silently ignored
+func decodeConfig(v *viper.Viper) (*Config, error) {
+	var c Config
+	err := v.UnmarshalExact(&c, func(dc *mapstructure.DecoderConfig) { dc.TagName = "yaml" })
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	c.DataDir = expandHome(c.DataDir)
+	c.Backup.Target = expandHome(c.Backup.Target)
+	c.Backup.TreasuryDir = expandHome(c.Backup.TreasuryDir)
+	c.Backup.GuardianStore = expandHome(c.Backup.GuardianStore)
+	c.Lightning.Macaroon = expandHome(c.Lightning.Macaroon)
+	c.Lightning.TLSCert = expandHome(c.Lightning.TLSCert)
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks the configuration for values the node cannot use
+func (c *Config) Validate() error {
+	net, err := c.Params()
+	if err != nil {
+		return err
+	}
+	if c.DataDir == "" {
+		return errors.New("datadir must be set")
+	}
+	for key, port := range map[string]int{"rpc.port": c.RPC.Port, "p2p.port": c.P2P.Port} {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("%s %d out of range", key, port)
+		}
+	}
+	if port := c.RPC.GRPCPort; port < 0 || port > 65535 {
+		return fmt.Errorf("rpc.grpc_port %d out of range", port)
+	}
+	if c.RPC.GRPCPort != 0 && c.RPC.GRPCPort == c.RPC.Port {
+		return errors.New("rpc.grpc_port and rpc.port must differ")
+	}
+	if c.RPC.Enabled && (c.RPC.User == "" || c.RPC.Password == "") {
+		return errors.New("rpc.user and rpc.password must be set when rpc is enabled")
+	}
+	if c.P2P.MaxPeers < 0 {
+		return fmt.Errorf("p2p.max_peers %d is negative", c.P2P.MaxPeers)
+	}
+	if _, err := p2p.ParseFeatures(c.P2P.Features); err != nil {
+		return fmt.Errorf("p2p.features: %w", err)
+	}
+	if _, err := p2p.ParseFeatures(c.P2P.RequireFeatures); err != nil {
+		return fmt.Errorf("p2p.require_features: %w", err)
+	}
+	switch c.P2P.Encryption {
+	case "prefer", "require", "off":
+	default:
+		return fmt.Errorf("p2p.encryption %q must be prefer, require or off", c.P2P.Encryption)
+	}
+	if c.P2P.MaxUploadRate < 0 || c.P2P.MaxDownloadRate < 0 {
+		return errors.New("p2p rate limits must not be negative")
+	}
+	if c.Mining.Address != "" {
+		addr, err := btcutil.DecodeAddress(c.Mining.Address, net)
+		if err != nil || !addr.IsForNet(net) {
+			return fmt.Errorf("mining.address %s is not a %s address", c.Mining.Address, net.Name)
+		}
+	}
+	if c.Mining.Threads < 0 {
+		return fmt.Errorf("mining.threads %d is negative", c.Mining.Threads)
+	}
+	switch c.Mining.Optimization {
+	case "power_save", "balanced", "performance", "extreme":
+	default:
+		return fmt.Errorf("mining.optimization %q must be power_save, balanced, performance or extreme", c.Mining.Optimization)
+	}
+	if c.Clock.CheckInterval < 1 {
+		return fmt.Errorf("clock.check_interval %d must be at least 1 minute", c.Clock.CheckInterval)
+	}
+	if c.Wallet.GapLimit == 0 {
+		return errors.New("wallet.gap_limit must be at least 1")
+	}
+	if _, err := fees.ParseMode(c.Fees.Mode); err != nil {
+		return fmt.Errorf("fees.mode: %w", err)
+	}
+	if c.Fees.Target < 1 || c.Fees.Target > fees.MaxTarget {
+		return fmt.Errorf("fees.target %d must be between 1 and %d blocks", c.Fees.Target, fees.MaxTarget)
+	}
+	if c.Performance.DBCache < 0 || c.Performance.MaxMempool < 0 {
+		return errors.New("performance sizes must not be negative")
+	}
+	switch c.Storage.Backend {
+	case "", kv.BackendLevelDB, kv.BackendBolt, kv.BackendBadger:
+	default:
+		return fmt.Errorf("storage.backend %q must be leveldb, bolt or badger", c.Storage.Backend)
+	}
+	if c.Storage.Prune {
+		if c.Storage.PruneDepth < chain.MinPruneDepth {
+			return fmt.Errorf("storage.prune_depth %d must be at least %d", c.Storage.PruneDepth, chain.MinPruneDepth)
+		}
+		if c.Storage.TxIndex {
+			return errors.New("storage.txindex must be off when storage.prune is on")
+		}
+	}
+	if c.Backup.Interval < 1 {
+		return fmt.Errorf("backup.interval %d must be at least 1 minute", c.Backup.Interval)
+	}
+	if c.Backup.Keep < 0 {
+		return fmt.Errorf("backup.keep %d is negative", c.Backup.Keep)
+	}
+	if bucket, ok := strings.CutPrefix(c.Backup.Target, "s3://"); ok && (bucket == "" || bucket[0] == '/') {
+		return fmt.Errorf("backup.target %q must name a bucket", c.Backup.Target)
+	}
+	if b := c.Lightning.Backend; b != "" && b != lightning.BackendLND && b != lightning.BackendCLN {
+		return fmt.Errorf("lightning.backend %q must be lnd or cln", b)
+	}
+	if _, err := chaos.Parse(c.Chaos); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Params returns the chain parameters of the configured network
+func (c *Config) Params() (*chaincfg.Params, error) {
+	switch c.Network {
+	case "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet":
+		return &chaincfg.TestNet3Params, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	}
+	return nil, fmt.Errorf("network %q must be mainnet, testnet or regtest", c.Network)
+}
+
+// setConfigValue sets key in the config file at path, keeping the file's
+// comments and layout, after checking the result is a valid configuration
+func setConfigValue(path, key, value string) error {
+	defaults, err := defaultViper()
+	if err != nil {
+		return err
+	}
+	current := defaults.Get(key)
+	if _, section := current.(map[string]interface{}); current == nil || section {
+		return fmt.Errorf("unknown config key %q (see exs-node config show)", key)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = []byte(defaultConfig), nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+
+	node, err := valueNode(current, value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	setNode(doc.Content[0], strings.Split(key, "."), node)
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+
+	// Validate the edited file as it will be loaded, without the
+	// environment so only the file's own values are checked
+	check, err := defaultViper()
+	if err != nil {
+		return err
+	}
+	if err := check.MergeConfig(bytes.NewReader(out.Bytes())); err != nil {
+		return err
+	}
+	if _, err := decodeConfig(check); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return os.WriteFile(path, out.Bytes(), 0600)
+}
+
+// valueNode parses value as the type of the key's default value
+func valueNode(current interface{}, value string) (*yaml.Node, error) {
+	switch current.(type) {
+	case bool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return nil, errors.New("not a boolean")
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: value}, nil
+	case int:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, errors.New("not an integer")
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}, nil
+	case []interface{}:
+		list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+			}
+		}
+		return list, nil
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+}
+
+// setNode sets the value at path in a YAML mapping, creating sections as
+// needed and keeping the existing line comment
+func setNode(mapping *yaml.Node, path []string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			setNode(mapping.Content[i+1], path[1:], value)
+			return
+		}
+		value.LineComment = mapping.Content[i+1].LineComment
+		mapping.Content[i+1] = value
+		return
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) > 1 {
+		section := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setNode(section, path[1:], value)
+		value = section
+	}
+	mapping.Content = append(mapping.Content, key, value)
+}
+
+// homeDir returns the user's home directory, or "." if it is unknown
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return home
+}
+
+// expandHome replaces a leading ~ in path with the home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return filepath.Join(homeDir(), path[1:])
+	}
+	return path
+}
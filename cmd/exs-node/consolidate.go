@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/spf13/cobra"
+)
+
+var walletConsolidateCmd = &cobra.Command{
+	Use:   "consolidate",
+	Short: "Merge dust and fragmented outputs while fees are low",
+	Long: `Find the wallet's small confirmed outputs and merge them into one.
+
+Mining payouts leave a vault holding many small outputs, and each one adds
+an input's fee to every later payment that spends it. Merging them while
+the network is quiet pays that fee once, at the low rate. advise weighs the
+cost, create builds the consolidation, and with --schedule signs it and
+holds it back until run finds the network's fee rate at or below the rate
+it pays.
+
+Examples:
+  exs-node wallet consolidate advise mining-vault
+  exs-node wallet consolidate create mining-vault --fee-rate 2 --sign --broadcast
+  exs-node wallet consolidate create mining-vault --fee-rate 1 --sign --schedule --expires 168h
+  exs-node wallet consolidate run mining-vault --watch 10m`,
+}
+
+var walletConsolidateAdviseCmd = &cobra.Command{
+	Use:   "advise [wallet-name]",
+	Short: "Report fragmented outputs and what merging them costs",
+	Long: `Report the wallet's fragments, the confirmed outputs below --small, and
+compare merging them at --fee-rate with spending them one by one at
+--spend-rate later.
+
+Without --fee-rate the fee estimate (see wallet fees) for --window blocks
+is used, and without --spend-rate its estimate for 6 blocks. Outputs come
+from --utxos or the last wallet balance.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		utxoFile, _ := cmd.Flags().GetString("utxos")
+		utxos, err := walletUTXOs(cmd, walletName, utxoFile)
+		if err != nil {
+			return err
+		}
+		feeRate, spendRate, err := consolidationRates(cmd, true)
+		if err != nil {
+			return err
+		}
+		policy, err := consolidationPolicy(cmd)
+		if err != nil {
+			return err
+		}
+		advice, err := wallet.AdviseConsolidation(utxos, feeRate, spendRate, policy, networkParams(cmd))
+		if err != nil {
+			return err
+		}
+
+		var fragmentValue, uneconomicValue int64
+		for _, utxo := range advice.Fragments {
+			fragmentValue += utxo.Value
+		}
+		for _, utxo := range advice.Uneconomic {
+			uneconomicValue += utxo.Value
+		}
+		fmt.Printf("Consolidation advice: %s\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Outputs:     %d\n", len(utxos))
+		fmt.Printf("Fragments:   %d below %.8f EXS, holding %.8f EXS\n",
+			len(advice.Fragments), btcutil.Amount(policy.SmallValue).ToBTC(), btcutil.Amount(fragmentValue).ToBTC())
+		fmt.Printf("Uneconomic:  %d worth less than their fee at %d sat/vB, holding %d sats\n",
+			len(advice.Uneconomic), spendRate, uneconomicValue)
+		fmt.Printf("Merge:       %d outputs, %.8f EXS\n", len(advice.Inputs), btcutil.Amount(advice.Value).ToBTC())
+		fmt.Printf("Size:        %d vB\n", advice.VSize)
+		fmt.Printf("Fee now:     %d sats (%d sat/vB)\n", advice.Fee, advice.FeeRate)
+		fmt.Printf("Fee later:   %d sats (%d sat/vB)\n", advice.LaterCost, advice.SpendRate)
+		fmt.Printf("Savings:     %d sats\n", advice.Savings)
+		if advice.Recommended {
+			fmt.Printf("\n✓ Consolidate: %s\n", advice.Reason)
+			fmt.Printf("Run: exs-node wallet consolidate create %s --fee-rate %d\n", walletName, feeRate)
+		} else {
+			fmt.Printf("\n✗ Wait: %s\n", advice.Reason)
+		}
+		return nil
+	},
+}
+
+var walletConsolidateCreateCmd = &cobra.Command{
+	Use:   "create [wallet-name]",
+	Short: "Build a transaction merging the wallet's fragments",
+	Long: `Build one transaction spending the wallet's fragments worth spending at
+--fee-rate, at most --max-inputs of them, to --dest, by default the
+wallet's next change address.
+
+Without --fee-rate the fee estimate (see wallet fees) for --window blocks
+is used. Like send, the transaction is written as a signing request unless
+--sign or --keys signs it, and --broadcast relays it. --schedule instead
+keeps the signed transaction until run finds the network's fee rate at or
+below the rate it pays; it is dropped if still waiting after --expires.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		utxoFile, _ := cmd.Flags().GetString("utxos")
+		dest, _ := cmd.Flags().GetString("dest")
+		out, _ := cmd.Flags().GetString("out")
+		description, _ := cmd.Flags().GetString("description")
+		noRBF, _ := cmd.Flags().GetBool("no-rbf")
+		keyFile, _ := cmd.Flags().GetString("keys")
+		sign, _ := cmd.Flags().GetBool("sign")
+		broadcast, _ := cmd.Flags().GetBool("broadcast")
+		schedule, _ := cmd.Flags().GetBool("schedule")
+		expires, _ := cmd.Flags().GetDuration("expires")
+		if (broadcast || schedule) && !sign && keyFile == "" {
+			return fmt.Errorf("--broadcast and --schedule need --sign or --keys")
+		}
+		if broadcast && schedule {
+			return fmt.Errorf("--broadcast and --schedule cannot be combined")
+		}
+
+		net := networkParams(cmd)
+		utxos, err := walletUTXOs(cmd, walletName, utxoFile)
+		if err != nil {
+			return err
+		}
+		feeRate, _, err := consolidationRates(cmd, false)
+		if err != nil {
+			return err
+		}
+		policy, err := consolidationPolicy(cmd)
+		if err != nil {
+			return err
+		}
+		advice, err := wallet.AdviseConsolidation(utxos, feeRate, feeRate, policy, net)
+		if err != nil {
+			return err
+		}
+		if len(advice.Inputs) < 2 {
+			return fmt.Errorf("%d fragment(s) worth spending at %d sat/vB, nothing to merge", len(advice.Inputs), feeRate)
+		}
+		if dest == "" {
+			if dest, err = nextChangeAddress(cmd, walletName); err != nil {
+				return err
+			}
+		}
+		if description == "" {
+			description = fmt.Sprintf("Consolidate %d outputs", len(advice.Inputs))
+		}
+
+		batch, err := wallet.BuildConsolidation(advice.Inputs, dest, feeRate, net)
+		if err != nil {
+			return err
+		}
+		if noRBF {
+			wallet.SetRBF(batch.Packet.UnsignedTx, false)
+		}
+		req, err := wallet.NewSigningRequest(batch.Packet, walletName, description, net)
+		if err != nil {
+			return err
+		}
+
+		var rawTx string
+		var scheduled *wallet.ScheduledTx
+		if sign || keyFile != "" {
+			var keys []*bitcoin.TaprootKey
+			if sign {
+				keys, err = walletKeys(cmd, walletName)
+			} else {
+				keys, err = readTaprootKeys(keyFile, net)
+			}
+			if err != nil {
+				return err
+			}
+			signed, _, err := req.Sign(keys)
+			if err != nil {
+				return err
+			}
+			if rawTx, err = req.Finalize(signed); err != nil {
+				return err
+			}
+			if schedule {
+				if scheduled, err = wallet.NewScheduledTx(rawTx, batch.Fee, time.Now().Add(expires)); err != nil {
+					return err
+				}
+				if err := wallet.SaveScheduledTx(scheduleDir(cmd, walletName), scheduled); err != nil {
+					return err
+				}
+				fmt.Printf("✓ Consolidation scheduled: %s\n", scheduled.TxID)
+			} else {
+				if err := recordTransaction(cmd, walletName, req, rawTx); err != nil {
+					return err
+				}
+				fmt.Printf("✓ Consolidation signed: %s\n", req.ID)
+			}
+		} else {
+			if out, err = saveSigningRequest(cmd, walletName, req, out); err != nil {
+				return err
+			}
+			fmt.Printf("✓ Consolidation built: %s\n", out)
+		}
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Request ID: %s\n", req.ID)
+		fmt.Printf("Merges:     %d outputs, %.8f EXS\n", len(batch.Inputs), btcutil.Amount(advice.Value).ToBTC())
+		fmt.Printf("Pays:       %.8f EXS to %s\n", btcutil.Amount(batch.Payouts[0].Amount).ToBTC(), dest)
+		fmt.Printf("Fee:        %d sats (%d sat/vB)\n", batch.Fee, feeRate)
+		fmt.Printf("RBF:        %s\n", rbfStatus(req.RBF))
+		switch {
+		case scheduled != nil:
+			fmt.Printf("Expires:    %s\n", scheduled.Expires.Local().Format(time.RFC3339))
+			fmt.Printf("\nBroadcast it once fees fall: exs-node wallet consolidate run %s --watch 10m\n", walletName)
+		case rawTx != "":
+			fmt.Printf("\n%s\n", rawTx)
+			if broadcast {
+				return broadcastTransaction(cmd, rawTx)
+			}
+		default:
+			fmt.Println("\nSign the request offline, then run: exs-node wallet import-signed")
+		}
+		return nil
+	},
+}
+
+var walletConsolidateRunCmd = &cobra.Command{
+	Use:   "run [wallet-name]",
+	Short: "Broadcast scheduled consolidations once fees fall",
+	Long: `Broadcast each scheduled consolidation paying at least the fee
+estimate to confirm within --blocks, and drop those past their expiry.
+Transactions the backend rejects stay scheduled for the next run.
+
+With --watch the check repeats at that interval until nothing is left
+scheduled; otherwise it runs once, e.g. from cron.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		blocks, _ := cmd.Flags().GetInt("blocks")
+		watch, _ := cmd.Flags().GetDuration("watch")
+		source, err := chainSource(cmd)
+		if err != nil {
+			return err
+		}
+		estimator, err := feeEstimator(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		for {
+			waiting, err := runConsolidationSchedule(ctx, cmd, walletName, source, estimator, blocks)
+			if err != nil {
+				if watch == 0 {
+					return err
+				}
+				fmt.Printf("✗ %v\n", err)
+			} else if watch == 0 || waiting == 0 {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(watch):
+			}
+		}
+	},
+}
+
+var walletConsolidateListCmd = &cobra.Command{
+	Use:   "list [wallet-name]",
+	Short: "List scheduled consolidations",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scheduled, err := wallet.ListScheduledTxs(scheduleDir(cmd, args[0]))
+		if err != nil {
+			return err
+		}
+		if len(scheduled) == 0 {
+			fmt.Println("No consolidations scheduled")
+			return nil
+		}
+		fmt.Printf("%-64s  %10s  %10s  %s\n", "TXID", "FEE", "SAT/VB", "EXPIRES")
+		for _, s := range scheduled {
+			fmt.Printf("%-64s  %10d  %10.2f  %s\n", s.TxID, s.Fee, s.FeeRate, s.Expires.Local().Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+var walletConsolidateCancelCmd = &cobra.Command{
+	Use:   "cancel [wallet-name] [txid]",
+	Short: "Drop a scheduled consolidation without broadcasting it",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := wallet.RemoveScheduledTx(scheduleDir(cmd, args[0]), args[1]); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("no consolidation %s scheduled for %s", args[1], args[0])
+			}
+			return err
+		}
+		fmt.Printf("✓ Cancelled: %s\n", args[1])
+		return nil
+	},
+}
+
+// runConsolidationSchedule checks the fee rate once, broadcasts the due
+// consolidations and returns how many are still waiting
+func runConsolidationSchedule(ctx context.Context, cmd *cobra.Command, walletName string, source wallet.ChainSource, estimator wallet.FeeEstimator, blocks int) (int, error) {
+	estimates, err := estimator.FeeEstimates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rate, err := estimates.Rate(blocks)
+	if err != nil {
+		return 0, err
+	}
+	run, err := wallet.RunSchedule(ctx, scheduleDir(cmd, walletName), rate, source.Broadcast, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("%s  fee rate for %d blocks: %.2f sat/vB\n", time.Now().Format(time.RFC3339), blocks, rate)
+	for _, s := range run.Broadcast {
+		// Keep it for fee bumping like any other finalized transaction
+		if err := wallet.SaveTxRecord(txRecordDir(cmd, walletName), &s.TxRecord); err != nil {
+			return 0, err
+		}
+		fmt.Printf("✓ Broadcast: %s (%.2f sat/vB)\n", s.TxID, s.FeeRate)
+	}
+	for _, s := range run.Expired {
+		fmt.Printf("✗ Expired:   %s\n", s.TxID)
+	}
+	for txid, err := range run.Failed {
+		fmt.Printf("✗ Rejected:  %s: %v\n", txid, err)
+	}
+	for _, s := range run.Waiting {
+		fmt.Printf("  Waiting:   %s (%.2f sat/vB)\n", s.TxID, s.FeeRate)
+	}
+	return len(run.Waiting) + len(run.Failed), nil
+}
+
+// consolidationRates returns --fee-rate and, when spend is set,
+// --spend-rate, estimating either from the backend when not given
+func consolidationRates(cmd *cobra.Command, spend bool) (int64, int64, error) {
+	feeRate, _ := cmd.Flags().GetInt64("fee-rate")
+	var spendRate int64
+	if spend {
+		spendRate, _ = cmd.Flags().GetInt64("spend-rate")
+	}
+	if feeRate > 0 && (!spend || spendRate > 0) {
+		return feeRate, spendRate, nil
+	}
+
+	estimator, err := feeEstimator(cmd)
+	if err != nil {
+		return 0, 0, err
+	}
+	estimates, err := estimator.FeeEstimates(cmd.Context())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to estimate fees (use --fee-rate): %w", err)
+	}
+	estimate := func(blocks int) (int64, error) {
+		rate, err := estimates.Rate(blocks)
+		if err != nil {
+			return 0, err
+		}
+		return max(int64(math.Ceil(rate)), 1), nil
+	}
+	if feeRate <= 0 {
+		window, _ := cmd.Flags().GetInt("window")
+		if feeRate, err = estimate(window); err != nil {
+			return 0, 0, err
+		}
+	}
+	if spend && spendRate <= 0 {
+		if spendRate, err = estimate(6); err != nil {
+			return 0, 0, err
+		}
+	}
+	return feeRate, spendRate, nil
+}
+
+// consolidationPolicy reads the policy flags over the defaults
+func consolidationPolicy(cmd *cobra.Command) (wallet.ConsolidationPolicy, error) {
+	policy := wallet.DefaultConsolidationPolicy()
+	small, _ := cmd.Flags().GetString("small")
+	value, err := wallet.ParseAmount(small)
+	if err != nil {
+		return policy, fmt.Errorf("invalid --small: %w", err)
+	}
+	policy.SmallValue = value
+	policy.MaxInputs, _ = cmd.Flags().GetInt("max-inputs")
+	// Only advise decides whether merging is worth it
+	if cmd.Flags().Lookup("min-inputs") != nil {
+		policy.MinInputs, _ = cmd.Flags().GetInt("min-inputs")
+		policy.MaxFeeRate, _ = cmd.Flags().GetInt64("max-fee-rate")
+	}
+	return policy, nil
+}
+
+// scheduleDir holds signed consolidations waiting for low fees
+func scheduleDir(cmd *cobra.Command, walletName string) string {
+	return filepath.Join(dataDir(cmd), "wallets", walletName, "scheduled")
+}
+
+func init() {
+	defaults := wallet.DefaultConsolidationPolicy()
+	for _, c := range []*cobra.Command{walletConsolidateAdviseCmd, walletConsolidateCreateCmd} {
+		c.Flags().String("utxos", "", "JSON file of spendable outputs (default: outputs from the last balance)")
+		c.Flags().Int64("fee-rate", 0, "consolidation fee rate in sat/vB (default: the fee estimate for --window)")
+		c.Flags().Int("window", 144, "confirmation target in blocks for the estimated fee rate")
+		c.Flags().String("small", strconv.FormatFloat(btcutil.Amount(defaults.SmallValue).ToBTC(), 'f', -1, 64), "EXS value below which an output is a fragment")
+		c.Flags().Int("max-inputs", defaults.MaxInputs, "most outputs one consolidation spends")
+	}
+	walletConsolidateAdviseCmd.Flags().Int64("spend-rate", 0, "fee rate in sat/vB assumed for later payments (default: the fee estimate for 6 blocks)")
+	walletConsolidateAdviseCmd.Flags().Int("min-inputs", defaults.MinInputs, "fragments worth merging before consolidation is advised")
+	walletConsolidateAdviseCmd.Flags().Int64("max-fee-rate", defaults.MaxFeeRate, "highest fee rate in sat/vB at which consolidation is advised")
+
+	walletConsolidateCreateCmd.Flags().String("dest", "", "address receiving the merged output (default: the wallet's next change address)")
+	walletConsolidateCreateCmd.Flags().String("out", "", "signing request output file (default <id>.json)")
+	walletConsolidateCreateCmd.Flags().String("description", "", "note shown to the offline signer")
+	walletConsolidateCreateCmd.Flags().Bool("no-rbf", false, "do not signal replace-by-fee")
+	walletConsolidateCreateCmd.Flags().String("keys", "", "sign with the Taproot keys in this file instead of exporting a signing request")
+	walletConsolidateCreateCmd.Flags().Bool("sign", false, "sign with the wallet's own keys instead of exporting a signing request")
+	walletConsolidateCreateCmd.Flags().StringP("passphrase", "p", "", "wallet passphrase for --sign (prompted if omitted)")
+	walletConsolidateCreateCmd.Flags().Bool("broadcast", false, "relay the signed transaction now")
+	walletConsolidateCreateCmd.Flags().Bool("schedule", false, "keep the signed transaction until fees fall to its rate")
+	walletConsolidateCreateCmd.Flags().Duration("expires", 72*time.Hour, "drop a scheduled transaction still waiting after this long")
+
+	walletConsolidateRunCmd.Flags().Int("blocks", 6, "confirmation target whose fee estimate a transaction must meet")
+	walletConsolidateRunCmd.Flags().Duration("watch", 0, "repeat the check at this interval until nothing is scheduled")
+
+	for _, c := range []*cobra.Command{walletConsolidateAdviseCmd, walletConsolidateCreateCmd, walletConsolidateRunCmd} {
+		c.Flags().String("backend", "", "Esplora API URL (default: wallet.backend or the network's public API)")
+		bindConfigFlags(c, map[string]string{"backend": "wallet.backend"})
+	}
+
+	walletConsolidateCmd.AddCommand(
+		walletConsolidateAdviseCmd,
+		walletConsolidateCreateCmd,
+		walletConsolidateRunCmd,
+		walletConsolidateListCmd,
+		walletConsolidateCancelCmd,
+	)
+	walletCmd.AddCommand(walletConsolidateCmd)
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/consensus"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/hardware"
 	"github.com/spf13/cobra"
 )
 
@@ -11,8 +14,12 @@ var dashboardCmd = &cobra.Command{
 	Use:   "dashboard",
 	Short: "Node dashboard",
 	Long:  "Real-time dashboard showing node status, mining stats, and network info",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		refresh, _ := cmd.Flags().GetInt("refresh")
+		status, err := readStatus(cmd)
+		if err != nil {
+			return err
+		}
 		
 		fmt.Println("╔═══════════════════════════════════════════════════════════╗")
 		fmt.Println("║           EXCALIBUR-EXS NODE DASHBOARD                   ║")
@@ -23,32 +30,57 @@ var dashboardCmd = &cobra.Command{
 		fmt.Println()
 		
 		// Display dashboard
-		displayDashboard()
+		displayDashboard(status)
 		
 		// TODO: Implement real-time updates
 		fmt.Println("\n✗ Dashboard not fully implemented yet")
+		return nil
 	},
 }
 
-func displayDashboard() {
+// displayDashboard prints the dashboard; status is nil when no node is running
+func displayDashboard(status *nodeStatus) {
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("NODE STATUS")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println("Status:          ● Stopped")
-	fmt.Println("Uptime:          0h 0m 0s")
-	fmt.Println("Network:         mainnet")
-	fmt.Println("Best Block:      0")
+	if status != nil {
+		uptime := time.Since(status.StartedAt).Truncate(time.Second)
+		fmt.Println("Status:          ● Running")
+		fmt.Printf("Uptime:          %dh %dm %ds\n", int(uptime.Hours()), int(uptime.Minutes())%60, int(uptime.Seconds())%60)
+		fmt.Printf("Network:         %s\n", status.Network)
+		fmt.Printf("Best Block:      %d\n", status.BestHeight)
+	} else {
+		fmt.Println("Status:          ● Stopped")
+		fmt.Println("Uptime:          0h 0m 0s")
+		fmt.Println("Network:         mainnet")
+		fmt.Println("Best Block:      0")
+	}
 	fmt.Println("Sync Progress:   0.00%")
-	fmt.Println("Connections:     0 peers")
+	if status != nil {
+		fmt.Printf("Connections:     %d peers\n", len(status.Peers))
+		if status.Clock != nil {
+			fmt.Printf("Clock:           %s\n", status.Clock.String())
+		}
+	} else {
+		fmt.Println("Connections:     0 peers")
+	}
 	fmt.Println()
 	
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("MINING STATUS")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("Miner:           ○ Stopped")
-	fmt.Println("Hash Rate:       0.00 H/s")
+	if acc := calibratedAccelerator(); acc != nil {
+		c := acc.Calibration()
+		fmt.Printf("Hash Rate:       %.2f H/s (calibrated %s, %d cores)\n", acc.EstimateHashRate(), c.MeasuredAt.Local().Format("2006-01-02"), c.Cores)
+		fmt.Printf("Time to Block:   %s at the pow limit\n", hardware.FormatBlockTime(acc.EstimateBlockTime(consensus.PowLimit)))
+		fmt.Printf("Efficiency:      %.4f H/s/W\n", acc.GetEfficiency())
+	} else {
+		fmt.Println("Hash Rate:       not calibrated (run miner calibrate)")
+		fmt.Println("Efficiency:      0.00 H/s/W")
+	}
+	fmt.Printf("Temperature:     %s\n", temperature(context.Background()))
 	fmt.Println("Blocks Found:    0")
-	fmt.Println("Efficiency:      0.00 H/s/W")
 	fmt.Println("Last Block:      Never")
 	fmt.Println()
 	
@@ -63,16 +95,62 @@ func displayDashboard() {
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("NETWORK")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println("In:              0.00 KB/s")
-	fmt.Println("Out:             0.00 KB/s")
+	if status != nil {
+		fmt.Printf("In:              %.2f KB/s (limit %s)\n", status.InRate/1024, formatRate(status.MaxDownloadRate))
+		fmt.Printf("Out:             %.2f KB/s (limit %s)\n", status.OutRate/1024, formatRate(status.MaxUploadRate))
+		fmt.Printf("Received:        %s\n", formatBytes(status.Bandwidth.BytesIn))
+		fmt.Printf("Sent:            %s\n", formatBytes(status.Bandwidth.BytesOut))
+		for _, p := range status.Peers {
+			fmt.Printf("  %-22s ↓ %-11s ↑ %s\n", p.Addr, formatBytes(p.Traffic.BytesIn), formatBytes(p.Traffic.BytesOut))
+		}
+	} else {
+		fmt.Println("In:              0.00 KB/s")
+		fmt.Println("Out:             0.00 KB/s")
+	}
 	fmt.Println("Mempool Size:    0 txs")
 	fmt.Println()
 	
 	fmt.Printf("Last updated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
 }
 
+// calibratedAccelerator returns this machine's accelerator with the
+// miner's stored calibration, or nil if the miner has not calibrated
+func calibratedAccelerator() *hardware.Accelerator {
+	path, err := hardware.DefaultCalibrationPath()
+	if err != nil {
+		return nil
+	}
+	c, err := hardware.LoadCalibration(path)
+	if err != nil {
+		return nil
+	}
+	acc := hardware.NewAccelerator()
+	if acc.SetCalibration(c) != nil {
+		return nil
+	}
+	return acc
+}
+
+// temperature reads this machine's CPU temperatures and clock for the
+// dashboard
+func temperature(ctx context.Context) string {
+	sensor, err := hardware.DetectSensor(ctx)
+	if err != nil {
+		return "no sensor"
+	}
+	r, err := sensor.Read(ctx)
+	if err != nil {
+		return fmt.Sprintf("%s failed: %v", sensor.Name(), err)
+	}
+	warning := ""
+	if r.Hottest() >= hardware.DefaultThermalLimit {
+		warning = fmt.Sprintf(" ⚠️  at or above the miner's default %.0f °C limit", hardware.DefaultThermalLimit)
+	}
+	return fmt.Sprintf("%s (%s)%s", r, sensor.Name(), warning)
+}
+
 func init() {
-	dashboardCmd.Flags().IntP("refresh", "r", 5, "refresh interval in seconds")
+	dashboardCmd.Flags().Int("refresh", 5, "refresh interval in seconds")
 	
 	rootCmd.AddCommand(dashboardCmd)
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/consensus"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+	"github.com/spf13/cobra"
+)
+
+var epochCmd = &cobra.Command{
+	Use:   "epoch",
+	Short: "Schedule prophecy axiom rotations",
+	Long: `The prophecy axiom rotates per epoch. Block seeds must carry the hashed
+axiom of the epoch their height falls in; seeds with an earlier or a future
+epoch's hash are rejected.
+
+The schedule is kept in epochs.json in the data directory and holds only axiom
+hashes. Rotations must be scheduled at least 2016 blocks ahead of the tip so
+miners and nodes can pick up the new schedule:
+  exs-node epoch hash sword legend ...        # share only the hash
+  exs-node epoch schedule --height 900000 --tip 880000 --axiom-hash <hash>
+  exs-node epoch list`,
+}
+
+var epochListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the epoch schedule",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schedule, err := consensus.LoadSchedule(epochSchedulePath(cmd))
+		if err != nil {
+			return err
+		}
+		active := -1
+		if cmd.Flags().Changed("height") {
+			height, _ := cmd.Flags().GetUint32("height")
+			active, _ = schedule.EpochAt(height)
+		}
+
+		fmt.Println("📜 Prophecy Epochs")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for i, epoch := range schedule {
+			heights := fmt.Sprintf("%d-", epoch.Start)
+			if end, ok := schedule.End(i); ok {
+				heights += fmt.Sprint(end)
+			}
+			marker := " "
+			if i == active {
+				marker = "*"
+			}
+			fmt.Printf("%s Epoch %-3d %-16s %s\n", marker, i, heights, epoch.AxiomHash)
+		}
+		return nil
+	},
+}
+
+var epochScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Schedule a rotation to a new axiom",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		height, _ := cmd.Flags().GetUint32("height")
+		tip, _ := cmd.Flags().GetUint32("tip")
+		hash, err := rotationHash(cmd)
+		if err != nil {
+			return err
+		}
+
+		path := epochSchedulePath(cmd)
+		schedule, err := consensus.LoadSchedule(path)
+		if err != nil {
+			return err
+		}
+		schedule, err = schedule.Rotate(height, hash, tip)
+		if err != nil {
+			return err
+		}
+		if err := schedule.Save(path); err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Epoch %d scheduled from height %d\n", len(schedule)-1, height)
+		fmt.Printf("  Axiom hash: %s\n", hash)
+		fmt.Printf("  Schedule:   %s\n", path)
+		fmt.Println("\nDistribute the schedule to every miner and node before the rotation height.")
+		return nil
+	},
+}
+
+var epochHashCmd = &cobra.Command{
+	Use:   "hash [axiom]",
+	Short: "Hash an axiom for scheduling without revealing it",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hash, err := hashProphecyAxiom(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		fmt.Println(hash)
+		return nil
+	},
+}
+
+var epochVerifyCmd = &cobra.Command{
+	Use:   "verify [seed]",
+	Short: "Check a hex block seed against the epoch of its height",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		height, _ := cmd.Flags().GetUint32("height")
+		seed, err := hex.DecodeString(args[0])
+		if err != nil {
+			return fmt.Errorf("seed is not hex: %w", err)
+		}
+		schedule, err := consensus.LoadSchedule(epochSchedulePath(cmd))
+		if err != nil {
+			return err
+		}
+		if err := schedule.ValidateSeed(height, seed); err != nil {
+			return err
+		}
+		epoch, _ := schedule.EpochAt(height)
+		fmt.Printf("✓ Seed valid for height %d (epoch %d)\n", height, epoch)
+		return nil
+	},
+}
+
+// epochSchedulePath returns the epoch schedule file in the data directory
+func epochSchedulePath(cmd *cobra.Command) string {
+	return filepath.Join(dataDir(cmd), "epochs.json")
+}
+
+// hashProphecyAxiom hashes a 13-word axiom
+func hashProphecyAxiom(axiom string) (consensus.AxiomHash, error) {
+	if n := len(strings.Fields(axiom)); n != crypto.ProphecyWordCount {
+		return consensus.AxiomHash{}, fmt.Errorf("axiom has %d words, want %d", n, crypto.ProphecyWordCount)
+	}
+	return consensus.HashAxiom(axiom), nil
+}
+
+// rotationHash returns the axiom hash given by --axiom or --axiom-hash
+func rotationHash(cmd *cobra.Command) (consensus.AxiomHash, error) {
+	axiom, _ := cmd.Flags().GetString("axiom")
+	hash, _ := cmd.Flags().GetString("axiom-hash")
+	switch {
+	case axiom != "" && hash != "":
+		return consensus.AxiomHash{}, errors.New("use either --axiom or --axiom-hash")
+	case axiom != "":
+		return hashProphecyAxiom(axiom)
+	case hash != "":
+		return consensus.ParseAxiomHash(hash)
+	default:
+		return consensus.AxiomHash{}, errors.New("--axiom or --axiom-hash is required")
+	}
+}
+
+func init() {
+	epochListCmd.Flags().Uint32("height", 0, "mark the epoch in force at this height")
+
+	epochScheduleCmd.Flags().Uint32("height", 0, "first height of the new epoch (required)")
+	epochScheduleCmd.Flags().Uint32("tip", 0, "current chain height (required)")
+	epochScheduleCmd.Flags().String("axiom", "", "13-word axiom of the new epoch, hashed before it is saved")
+	epochScheduleCmd.Flags().String("axiom-hash", "", "hex hash of the new epoch's axiom, from epoch hash")
+	epochScheduleCmd.MarkFlagRequired("height")
+	epochScheduleCmd.MarkFlagRequired("tip")
+
+	epochVerifyCmd.Flags().Uint32("height", 0, "height of the block the seed belongs to (required)")
+	epochVerifyCmd.MarkFlagRequired("height")
+
+	epochCmd.AddCommand(epochListCmd, epochScheduleCmd, epochHashCmd, epochVerifyCmd)
+	rootCmd.AddCommand(epochCmd)
+}
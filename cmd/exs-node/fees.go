@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+var walletFeesCmd = &cobra.Command{
+	Use:   "fees",
+	Short: "Show fee rate estimates by confirmation target",
+	Long: `Show the fee rate, in sat/vB, estimated to confirm within each target
+number of blocks, conservative and economical.
+
+Estimates come from the Esplora API at fees.estimator, by default the
+wallet backend, checked against its mempool: a conservative estimate pays
+at least what the mempool needs to clear within the target, an economical
+one trusts the recent blocks. The node's own RPC server estimates from the
+blocks and mempool it sees (estimatesmartfee).
+
+send, sendmany and consolidate use these estimates unless --fee-rate is
+given, for --target blocks (default fees.target) in --fee-mode (default
+fees.mode).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		estimator, err := feeEstimator(cmd)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-7s  %12s  %12s  %s\n", "BLOCKS", "CONSERVATIVE", "ECONOMICAL", "SOURCE")
+		for _, target := range fees.Targets {
+			conservative, err := estimator.Estimate(cmd.Context(), target, fees.Conservative)
+			if err != nil {
+				return fmt.Errorf("failed to estimate fees: %w", err)
+			}
+			economical, err := estimator.Estimate(cmd.Context(), target, fees.Economical)
+			if err != nil {
+				return fmt.Errorf("failed to estimate fees: %w", err)
+			}
+			fmt.Printf("%-7d  %12.2f  %12.2f  %s\n", target, conservative.Rate, economical.Rate, conservative.Source)
+		}
+		return nil
+	},
+}
+
+// feeEstimator returns an estimator over the Esplora API at fees.estimator,
+// or else the wallet backend, in fees.mode
+func feeEstimator(cmd *cobra.Command) (*fees.Estimator, error) {
+	var source *wallet.EsploraSource
+	if config.Fees.Estimator != "" {
+		source = wallet.NewEsploraSource(config.Fees.Estimator, networkParams(cmd))
+	} else {
+		backend, err := chainSource(cmd)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if source, ok = backend.(*wallet.EsploraSource); !ok {
+			return nil, fmt.Errorf("backend does not estimate fees (use --fee-rate)")
+		}
+	}
+	mode, err := fees.ParseMode(config.Fees.Mode)
+	if err != nil {
+		return nil, err
+	}
+	return fees.New(fees.Config{External: source, Mempool: source, Mode: mode}), nil
+}
+
+// paymentFeeRate returns --fee-rate, or when it is 0 the estimate to confirm
+// within --target blocks in --fee-mode, printing where it came from
+func paymentFeeRate(cmd *cobra.Command) (int64, error) {
+	if feeRate, _ := cmd.Flags().GetInt64("fee-rate"); feeRate > 0 {
+		return feeRate, nil
+	}
+	mode, err := fees.ParseMode(config.Fees.Mode)
+	if err != nil {
+		return 0, err
+	}
+	estimator, err := feeEstimator(cmd)
+	if err != nil {
+		return 0, err
+	}
+	estimate, err := estimator.Estimate(cmd.Context(), config.Fees.Target, mode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate fees (use --fee-rate): %w", err)
+	}
+	fmt.Printf("Fee rate:   %d sat/vB (%s estimate for %d blocks from %s)\n", estimate.SatsPerVByte(), estimate.Mode, estimate.Target, estimate.Source)
+	return estimate.SatsPerVByte(), nil
+}
+
+// addFeeFlags adds the fee rate flags of a payment command
+func addFeeFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64("fee-rate", 0, "fee rate in sat/vB (default: the estimate for --target)")
+	cmd.Flags().Int("target", 0, "confirmation target in blocks for the estimated fee rate (default: fees.target)")
+	cmd.Flags().String("fee-mode", "", "fee estimate mode: conservative or economical (default: fees.mode)")
+	bindConfigFlags(cmd, map[string]string{"target": "fees.target", "fee-mode": "fees.mode"})
+}
+
+func init() {
+	walletFeesCmd.Flags().String("backend", "", "Esplora API URL (default: wallet.backend or the network's public API)")
+	bindConfigFlags(walletFeesCmd, map[string]string{"backend": "wallet.backend"})
+
+	walletCmd.AddCommand(walletFeesCmd)
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
 )
 
 var forgeCmd = &cobra.Command{
@@ -46,8 +48,8 @@ var forgeStartCmd = &cobra.Command{
 		fmt.Println()
 		fmt.Printf("Mining Address: %s\n", address)
 		fmt.Printf("Difficulty:     0x%016x\n", difficulty)
-		fmt.Printf("Forge Reward:   50 EXS\n")
-		fmt.Printf("Treasury Share: 7.5 EXS (15%%)\n")
+		fmt.Printf("Forge Reward:   %s EXS\n", economy.ForgeReward)
+		fmt.Printf("Treasury Share: %s EXS (%d%%)\n", economy.TreasuryAllocation, economy.TreasuryPercent)
 		fmt.Println()
 		
 		if visualize {
@@ -61,8 +63,8 @@ var forgeStartCmd = &cobra.Command{
 		
 		fmt.Println("\n✓ Forge completed successfully!")
 		fmt.Println("\nRewards:")
-		fmt.Println("  Miner:    42.5 EXS")
-		fmt.Println("  Treasury: 7.5 EXS")
+		fmt.Printf("  Miner:    %s EXS\n", economy.MinerReward)
+		fmt.Printf("  Treasury: %s EXS\n", economy.TreasuryAllocation)
 		fmt.Println("\nP2TR Vault Address:")
 		fmt.Printf("  bc1p%s\n", generateMockHash(32))
 		fmt.Println("\n⚠️  Not fully implemented yet - Integration pending")
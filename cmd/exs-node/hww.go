@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin/hww"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/spf13/cobra"
+)
+
+var hwwCmd = &cobra.Command{
+	Use:   "hww",
+	Short: "Use hardware wallets (Ledger, Trezor) through HWI",
+	Long: `Find hardware wallets and the Taproot keys they hold. Devices are reached
+through HWI (https://github.com/bitcoin-core/HWI), which must be installed;
+set EXS_HWI to run it from somewhere other than $PATH.
+
+A key on a device is referenced as hww:FINGERPRINT/PATH[:script-root],
+for example hww:d34db33f/86h/0h/0h/0/0. Sign PSBTs with such keys using
+exs-node psbt sign --device.`,
+}
+
+var hwwListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List connected hardware wallets",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		devices, err := hww.New(networkParams(cmd)).Enumerate(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if len(devices) == 0 {
+			fmt.Println("No hardware wallets found")
+			return nil
+		}
+		for _, d := range devices {
+			status := "ready"
+			switch {
+			case d.Error != "":
+				status = d.Error
+			case d.NeedsPinSent:
+				status = "locked: unlock with hwi promptpin and sendpin"
+			case d.NeedsPassphraseSent:
+				status = "needs passphrase"
+			}
+			fmt.Printf("%-8s  %-16s  %-10s  %s  (%s)\n", d.Fingerprint, d.Model, d.Type, d.Path, status)
+		}
+		return nil
+	},
+}
+
+var hwwKeyCmd = &cobra.Command{
+	Use:   "key [hww:FINGERPRINT/PATH[:script-root]]",
+	Short: "Show the Taproot key and address of a key on a hardware wallet",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := hww.ParseKey(args[0])
+		if err != nil {
+			return err
+		}
+		net := networkParams(cmd)
+		h := hww.New(net)
+		internal, err := h.PublicKey(cmd.Context(), key)
+		if err != nil {
+			return err
+		}
+		outputKey, err := h.OutputKey(cmd.Context(), key)
+		if err != nil {
+			return err
+		}
+		address, err := bitcoin.EncodeBech32m(schnorr.SerializePubKey(outputKey), net)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Key:          %s\n", key)
+		fmt.Printf("Internal key: %x\n", schnorr.SerializePubKey(internal))
+		fmt.Printf("Output key:   %x\n", schnorr.SerializePubKey(outputKey))
+		fmt.Printf("Address:      %s\n", address)
+		return nil
+	},
+}
+
+func init() {
+	hwwCmd.AddCommand(hwwListCmd, hwwKeyCmd)
+	rootCmd.AddCommand(hwwCmd)
+}
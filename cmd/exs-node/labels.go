@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+var walletReceiveCmd = &cobra.Command{
+	Use:   "receive [wallet-name] [amount]",
+	Short: "Create a payment request for a fresh address",
+	Long: `Hand out a new receiving address and print a complete payment request
+for it: the address, an exs: URI with the amount, --label and --message, and
+with --qr or --png a QR code of the URI.
+
+Unlike wallet address, receive reserves the address, so every request gets
+its own and payments can be told apart. The address is labelled with
+--label (or --message) in the wallet's labels; see wallet labels.
+
+Example:
+  exs-node wallet receive mining-vault 0.5 --label "Invoice 42" --qr`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		label, _ := cmd.Flags().GetString("label")
+		message, _ := cmd.Flags().GetString("message")
+		account, _ := cmd.Flags().GetUint32("account")
+		net := networkParams(cmd)
+
+		req := &wallet.PaymentRequest{Label: label, Message: message}
+		if len(args) == 2 {
+			amount, err := wallet.ParseAmount(args[1])
+			if err != nil {
+				return err
+			}
+			req.Amount = amount
+		}
+
+		store, err := openDescriptorStore(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		var derived *wallet.DerivedAddress
+		for _, entry := range store.List() {
+			desc, err := wallet.ParseDescriptor(entry.Descriptor)
+			if err != nil {
+				return err
+			}
+			if desc.Type != wallet.DescriptorTR || !desc.Ranged {
+				continue
+			}
+			if n, ok := wallet.AccountIndex(desc, net); cmd.Flags().Changed("account") && (!ok || n != account) {
+				continue
+			}
+			index, err := store.Reserve(entry.Descriptor, wallet.ReceiveBranch)
+			if err != nil {
+				return err
+			}
+			if derived, err = desc.Derive(wallet.ReceiveBranch, index, net); err != nil {
+				return err
+			}
+			break
+		}
+		if derived == nil {
+			return fmt.Errorf("wallet %s has no ranged p2tr descriptor to receive with", walletName)
+		}
+		req.Address = derived.Address
+
+		if note := firstNonEmpty(label, message); note != "" {
+			labels, err := wallet.OpenLabelStore(labelsPath(cmd, walletName))
+			if err != nil {
+				return err
+			}
+			if err := labels.Set(wallet.Label{Type: wallet.LabelAddress, Ref: derived.Address, Label: note}); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Payment request for wallet: %s\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Address: %s\n", req.Address)
+		fmt.Printf("Index:   %d\n", derived.Index)
+		if req.Amount > 0 {
+			fmt.Printf("Amount:  %s EXS\n", wallet.FormatAmount(req.Amount))
+		}
+		if req.Label != "" {
+			fmt.Printf("Label:   %s\n", req.Label)
+		}
+		if req.Message != "" {
+			fmt.Printf("Message: %s\n", req.Message)
+		}
+		fmt.Printf("URI:     %s\n", req.URI())
+		return showQR(cmd, req.QRContent())
+	},
+}
+
+var walletLabelsCmd = &cobra.Command{
+	Use:   "labels",
+	Short: "Label the wallet's addresses and transactions",
+	Long: `Labels name a wallet's own addresses and transactions; contacts name
+other people's addresses. They are kept per wallet in labels.jsonl, a
+BIP-329 file that export and import exchange with other wallets such as
+Sparrow. Output records also carry frozen coins (see wallet coins), and
+records of types exs-node does not set (xpub, input, pubkey) are kept as
+they are.`,
+}
+
+var walletLabelsSetCmd = &cobra.Command{
+	Use:   "set [wallet-name] [address|txid|txid:vout] [label]",
+	Short: "Label an address, transaction or output; an empty label removes it",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		labelType, err := wallet.LabelRef(args[1], networkParams(cmd))
+		if err != nil {
+			return err
+		}
+		labels, err := wallet.OpenLabelStore(labelsPath(cmd, args[0]))
+		if err != nil {
+			return err
+		}
+		if err := labels.Set(wallet.Label{Type: labelType, Ref: args[1], Label: args[2]}); err != nil {
+			return err
+		}
+		if args[2] == "" {
+			fmt.Printf("✓ Label removed: %s\n", args[1])
+		} else {
+			fmt.Printf("✓ Labelled %s %s: %s\n", labelType, args[1], args[2])
+		}
+		return nil
+	},
+}
+
+var walletLabelsListCmd = &cobra.Command{
+	Use:   "list [wallet-name]",
+	Short: "List labels",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		labels, err := wallet.OpenLabelStore(labelsPath(cmd, args[0]))
+		if err != nil {
+			return err
+		}
+		list := labels.List()
+		if len(list) == 0 {
+			fmt.Println("No labels")
+			return nil
+		}
+		for _, l := range list {
+			fmt.Printf("%-6s  %s  %s\n", l.Type, l.Ref, l.Label)
+		}
+		return nil
+	},
+}
+
+var walletLabelsExportCmd = &cobra.Command{
+	Use:   "export [wallet-name] [file]",
+	Short: "Export labels as BIP-329 JSON Lines (stdout if no file)",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		labels, err := wallet.OpenLabelStore(labelsPath(cmd, args[0]))
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			return labels.Export(os.Stdout)
+		}
+		f, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		if err := labels.Export(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %d label(s) exported to %s\n", len(labels.List()), args[1])
+		return nil
+	},
+}
+
+var walletLabelsImportCmd = &cobra.Command{
+	Use:   "import [wallet-name] [file]",
+	Short: "Import BIP-329 labels, replacing labels of the same items",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		labels, err := wallet.OpenLabelStore(labelsPath(cmd, args[0]))
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, err := labels.Import(f)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ %d label(s) imported\n", n)
+		return nil
+	},
+}
+
+// labelsPath returns the BIP-329 labels file of a wallet
+func labelsPath(cmd *cobra.Command, walletName string) string {
+	return filepath.Join(dataDir(cmd), "wallets", walletName, "labels.jsonl")
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func init() {
+	walletReceiveCmd.Flags().String("label", "", "label for the request and the address")
+	walletReceiveCmd.Flags().String("message", "", "message for the payment URI")
+	walletReceiveCmd.Flags().Uint32("account", 0, "HD account to receive with")
+	addQRFlags(walletReceiveCmd)
+
+	walletLabelsCmd.AddCommand(walletLabelsSetCmd, walletLabelsListCmd, walletLabelsExportCmd, walletLabelsImportCmd)
+	walletCmd.AddCommand(walletReceiveCmd, walletLabelsCmd)
+}
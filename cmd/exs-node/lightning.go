@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/lightning"
+)
+
+var revenueStatsLightningCmd = &cobra.Command{
+	Use:   "lightning",
+	Short: "Show Lightning channel and routing statistics",
+	Long: `Show the channels and routing fees of the protocol's Lightning node and the
+fees the treasury has been credited with.
+
+The node is LND or Core Lightning, reached through its REST gateway
+(lightning.backend, lightning.url and lightning.macaroon or lightning.rune in
+the config file). The credited fees come from the treasury API at --treasury,
+which collects them when it runs with LIGHTNING_BACKEND set.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		c := config.Lightning
+		if c.Backend == "" && c.Treasury == "" {
+			return errors.New("no Lightning node or treasury configured (set lightning.backend or --treasury)")
+		}
+
+		var out struct {
+			Node     *lightning.Stats       `json:"node,omitempty"`
+			Credited *economy.RevenueStream `json:"credited,omitempty"`
+		}
+		if c.Backend != "" {
+			node, err := lightning.Open(lightning.Config{
+				Backend:      c.Backend,
+				URL:          c.URL,
+				MacaroonFile: c.Macaroon,
+				Rune:         c.Rune,
+				TLSCert:      c.TLSCert,
+			})
+			if err != nil {
+				return err
+			}
+			if out.Node, err = lightning.ReadStats(cmd.Context(), node, time.Now()); err != nil {
+				return err
+			}
+		}
+		if c.Treasury != "" {
+			streams, err := client.NewTreasury(c.Treasury, client.Options{}).Revenue(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("treasury revenue: %w", err)
+			}
+			out.Credited = &economy.RevenueStream{Name: economy.StreamLightning}
+			for i := range streams {
+				if streams[i].Name == economy.StreamLightning {
+					out.Credited = &streams[i]
+				}
+			}
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		fmt.Println("⚡ Revenue Statistics: Lightning Fee Routing")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		if s := out.Node; s != nil {
+			fmt.Printf("Node:                %s at %s\n", c.Backend, c.URL)
+			fmt.Printf("Channels:            %d (%d active)\n", s.Channels, s.ActiveChannels)
+			fmt.Printf("Capacity:            %d sats\n", s.Capacity)
+			fmt.Printf("Local / Remote:      %d / %d sats\n", s.LocalBalance, s.RemoteBalance)
+			fmt.Printf("Forwards:            %d (%s sats routed)\n", s.Forwards, msatString(s.VolumeMsat))
+			fmt.Printf("Fees (24h):          %s sats\n", msatString(s.Fees24hMsat))
+			fmt.Printf("Fees (7d):           %s sats\n", msatString(s.Fees7dMsat))
+			fmt.Printf("Fees (30d):          %s sats\n", msatString(s.Fees30dMsat))
+			fmt.Printf("Fees (total):        %s sats\n", msatString(s.FeesMsat))
+		}
+		if r := out.Credited; r != nil {
+			fmt.Printf("Treasury Credited:   %s EXS from %d forwards (%s sats)\n", r.Amount, r.Earnings, msatString(r.Units))
+			if !r.LastAt.IsZero() {
+				fmt.Printf("Last Credit:         forward %d at %s\n", r.LastIndex, r.LastAt.Local().Format(time.RFC3339))
+			}
+		}
+		return nil
+	},
+}
+
+// msatString formats millisatoshis as satoshis, e.g. 1234.567
+func msatString(msat int64) string {
+	if msat%1000 == 0 {
+		return fmt.Sprint(msat / 1000)
+	}
+	sign := ""
+	if msat < 0 {
+		sign, msat = "-", -msat
+	}
+	return fmt.Sprintf("%s%d.%03d", sign, msat/1000, msat%1000)
+}
+
+func init() {
+	revenueStatsLightningCmd.Flags().String("treasury", "", "treasury API URL to show the fees credited (default: lightning.treasury)")
+	revenueStatsLightningCmd.Flags().Bool("json", false, "print the statistics as JSON")
+	bindConfigFlags(revenueStatsLightningCmd, map[string]string{"treasury": "lightning.treasury"})
+
+	revenueStatsCmd.AddCommand(revenueStatsLightningCmd)
+}
@@ -4,12 +4,20 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/spf13/cobra"
 )
 
+// logOptions are set by --log-level and --log-format
+var logOptions logging.Options
+
+// Version is the release version, set at build time through pkg/buildinfo
+var Version = buildinfo.Version
+
 const (
-	Version = "1.0.0"
-	Banner  = `
+	Banner = `
 ╔═══════════════════════════════════════════════════════════╗
 ║           EXCALIBUR-EXS CONSOLE NODE                     ║
 ║     Quantum-Hardened Bitcoin Protocol Implementation      ║
@@ -34,8 +42,7 @@ for the Excalibur Anomaly Protocol with:
 Use "exs-node <command> --help" for more information about a command.`,
 	Version: Version,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		// Initialize configuration
-		if err := initConfig(); err != nil {
+		if err := initConfig(cmd); err != nil {
 			fmt.Fprintf(os.Stderr, "Error initializing config: %v\n", err)
 			os.Exit(1)
 		}
@@ -49,11 +56,27 @@ func init() {
 	rootCmd.PersistentFlags().BoolP("testnet", "t", false, "use testnet")
 	rootCmd.PersistentFlags().BoolP("regtest", "r", false, "use regtest mode")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
+	logOptions.Register(rootCmd.PersistentFlags())
+
+	// Subcommands that replace PersistentPreRun still log as configured
+	cobra.OnInitialize(func() {
+		if err := logOptions.Setup(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	})
+}
+
+// dataDir returns the node data directory from the configuration
+func dataDir(cmd *cobra.Command) string {
+	return config.DataDir
 }
 
-func initConfig() error {
-	// TODO: Load configuration from file or environment
-	return nil
+// networkParams returns the chain parameters of the configured network,
+// selected by the network setting or --testnet/--regtest
+func networkParams(cmd *cobra.Command) *chaincfg.Params {
+	net, _ := config.Params()
+	return net
 }
 
 func main() {
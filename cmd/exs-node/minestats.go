@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/spf13/cobra"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/hardware"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/mining/stats"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/rpc"
+)
+
+// miningStatsPath returns the database miner statistics are recorded in
+func miningStatsPath(cmd *cobra.Command) string {
+	return filepath.Join(dataDir(cmd), "mining.db")
+}
+
+// openMiningStats opens the miner statistics database
+func openMiningStats(cmd *cobra.Command) (*stats.Store, error) {
+	if err := os.MkdirAll(dataDir(cmd), 0700); err != nil {
+		return nil, err
+	}
+	return stats.Open(miningStatsPath(cmd))
+}
+
+// miningRecorder records a mining session in the statistics database. A nil
+// recorder records nothing.
+type miningRecorder struct {
+	store   *stats.Store
+	session *stats.Session
+	// watts is the power draw estimated for the session's threads
+	watts float64
+}
+
+// startMiningRecorder opens the statistics database and starts a session of
+// threads workers mining for address with work from source
+func startMiningRecorder(cmd *cobra.Command, source, address string, threads int) (*miningRecorder, error) {
+	store, err := openMiningStats(cmd)
+	if err != nil {
+		return nil, err
+	}
+	session, err := store.StartSession(source, address, threads, time.Now())
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	acc := hardware.NewAccelerator()
+	if config.Mining.Optimization != "" {
+		acc.SetOptimization(config.Mining.Optimization)
+	}
+	acc.SetWorkerCount(threads)
+	return &miningRecorder{store: store, session: session, watts: acc.EstimatePowerConsumption()}, nil
+}
+
+// sample records the current hash rate with the session's power estimate
+func (r *miningRecorder) sample(hashRate float64) {
+	if r == nil {
+		return
+	}
+	if err := r.session.Sample(time.Now(), hashRate, r.watts); err != nil {
+		slog.Error("Failed to record hash rate", "err", err)
+	}
+}
+
+// share records a submitted share
+func (r *miningRecorder) share(accepted bool, difficulty float64) {
+	if r == nil {
+		return
+	}
+	if err := r.session.Share(time.Now(), accepted, difficulty); err != nil {
+		slog.Error("Failed to record share", "err", err)
+	}
+}
+
+// close ends the session after hashes hashes and closes the database
+func (r *miningRecorder) close(hashes uint64) {
+	if r == nil {
+		return
+	}
+	if err := r.session.End(time.Now(), hashes); err != nil {
+		slog.Error("Failed to end mining session", "err", err)
+	}
+	r.store.Close()
+}
+
+// printMiningStats reports the statistics recorded over the last history
+func printMiningStats(cmd *cobra.Command, history time.Duration) error {
+	if _, err := os.Stat(miningStatsPath(cmd)); os.IsNotExist(err) {
+		return fmt.Errorf("no mining statistics at %s; start mining first", miningStatsPath(cmd))
+	}
+	store, err := openMiningStats(cmd)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	report, err := store.Report(time.Now().Add(-history))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("⚡ Mining Statistics")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Since:        %s\n", report.Since.Format(time.DateTime))
+	status := "Stopped"
+	if report.Running > 0 {
+		status = "Running"
+	}
+	fmt.Printf("Status:       %s\n", status)
+	fmt.Printf("Sessions:     %d\n", report.Sessions)
+	fmt.Printf("Uptime:       %s\n", report.Uptime.Round(time.Second))
+	fmt.Printf("Hash Rate:    %.2f H/s average, %.2f H/s peak (%d samples)\n", report.AvgHashRate, report.PeakHashRate, report.Samples)
+	fmt.Printf("Power:        %.1f W estimated\n", report.AvgWatts)
+	fmt.Printf("Efficiency:   %.2f H/s/W\n", report.Efficiency())
+	fmt.Printf("Shares:       %d accepted, %d rejected\n", report.Accepted, report.Rejected)
+	fmt.Printf("Blocks Found: %d\n", len(report.Blocks))
+	for _, block := range report.Blocks {
+		fmt.Printf("  %s  height %d  %s\n", block.Time.Format(time.DateTime), block.Height, block.Hash)
+	}
+	return nil
+}
+
+// statsChain records the blocks generated by or submitted to the RPC
+// server that join the main chain
+type statsChain struct {
+	rpc.Chain
+	store *stats.Store
+}
+
+// Generate mines n blocks and records them
+func (c *statsChain) Generate(n int, pkScript []byte) ([]chainhash.Hash, error) {
+	hashes, err := c.Chain.Generate(n, pkScript)
+	for _, hash := range hashes {
+		c.record(hash)
+	}
+	return hashes, err
+}
+
+// SubmitBlock stores a solved block and records it when it joins the main
+// chain
+func (c *statsChain) SubmitBlock(block *wire.MsgBlock) (bool, error) {
+	main, err := c.Chain.SubmitBlock(block)
+	if err == nil && main {
+		c.record(block.BlockHash())
+	}
+	return main, err
+}
+
+func (c *statsChain) record(hash chainhash.Hash) {
+	_, height, err := c.Chain.Block(hash)
+	if err == nil {
+		err = c.store.Block(time.Now(), height, hash.String())
+	}
+	if err != nil {
+		slog.Error("Failed to record block", "hash", hash, "err", err)
+	}
+}
@@ -1,8 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
 
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/mining/stats"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/mining/stratum"
 	"github.com/spf13/cobra"
 )
 
@@ -24,26 +34,103 @@ var mineStartCmd = &cobra.Command{
 	Short: "Start mining",
 	Long:  "Start Tetra-PoW mining with the configured parameters",
 	Run: func(cmd *cobra.Command, args []string) {
-		address, _ := cmd.Flags().GetString("address")
-		threads, _ := cmd.Flags().GetInt("threads")
-		pool, _ := cmd.Flags().GetString("pool")
+		address := config.Mining.Address
+		threads := config.Mining.Threads
+		pool := config.Mining.Pool
+		if address == "" {
+			fmt.Fprintln(os.Stderr, "✗ No mining address: use --address or set mining.address")
+			os.Exit(1)
+		}
+		if threads <= 0 {
+			threads = runtime.NumCPU()
+		}
 		
 		fmt.Println("⚔️ Starting Excalibur-EXS Tetra-PoW Miner")
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 		fmt.Printf("Mining address: %s\n", address)
 		fmt.Printf("Threads: %d\n", threads)
 		
-		if pool != "" {
-			fmt.Printf("Pool: %s\n", pool)
-		} else {
+		if pool == "" {
 			fmt.Println("Mode: Solo mining")
+			fmt.Println("\nMining started. Press Ctrl+C to stop.")
+			fmt.Println("✗ Not implemented yet")
+			return
 		}
 		
-		fmt.Println("\nMining started. Press Ctrl+C to stop.")
-		fmt.Println("✗ Not implemented yet")
+		fmt.Printf("Pool: %s\n", pool)
+		
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		
+		recorder, err := startMiningRecorder(cmd, pool, address, threads)
+		if err != nil {
+			slog.Warn("Mining statistics disabled", "err", err)
+		}
+		
+		if err := mineOnPool(ctx, pool, address, threads, recorder); err != nil {
+			logging.Fatal("Pool mining failed", "err", err)
+		}
 	},
 }
 
+// mineOnPool connects to a Stratum pool and mines shares for address until
+// ctx is cancelled, recording the session with recorder
+func mineOnPool(ctx context.Context, pool, address string, threads int, recorder *miningRecorder) error {
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	
+	client, err := stratum.Dial(dialCtx, pool)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	
+	if err := client.Subscribe(dialCtx, "exs-node/"+Version); err != nil {
+		return fmt.Errorf("subscribe failed: %w", err)
+	}
+	if err := client.Authorize(dialCtx, address, "x"); err != nil {
+		return fmt.Errorf("authorization failed: %w", err)
+	}
+	
+	extranonce1, _ := client.Extranonce()
+	fmt.Printf("Extranonce1: %x\n", extranonce1)
+	fmt.Println("\nMining started. Press Ctrl+C to stop.")
+	
+	miner := stratum.NewMiner(client, address, threads)
+	miner.OnShare = func(jobID string, nonce uint64, err error) {
+		recorder.share(err == nil, client.Difficulty())
+		if err != nil {
+			slog.Warn("Share rejected", "job", jobID, "nonce", nonce, "err", err)
+			return
+		}
+		slog.Info("Share accepted", "job", jobID, "nonce", nonce, "difficulty", client.Difficulty())
+	}
+	
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				stats := miner.Stats()
+				recorder.sample(stats.HashRate)
+				slog.Info("Hash rate", "hash_rate", stats.HashRate, "accepted", stats.Accepted, "rejected", stats.Rejected)
+			}
+		}
+	}()
+	
+	err = miner.Run(ctx)
+	stats := miner.Stats()
+	recorder.sample(stats.HashRate)
+	recorder.close(stats.Hashes)
+	slog.Info("Mining stopped", "hashes", stats.Hashes, "accepted", stats.Accepted, "rejected", stats.Rejected)
+	return err
+}
+
 var mineStopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop mining",
@@ -56,15 +143,16 @@ var mineStopCmd = &cobra.Command{
 var mineStatsCmd = &cobra.Command{
 	Use:   "stats",
 	Short: "Show mining statistics",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("⚡ Mining Statistics")
-		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		fmt.Println("Status:       Running")
-		fmt.Println("Hash Rate:    0.00 H/s")
-		fmt.Println("Blocks Found: 0")
-		fmt.Println("Uptime:       0h 0m 0s")
-		fmt.Println("Efficiency:   0.00 H/s/W")
-		fmt.Println("✗ Not implemented yet")
+	Long: `Report the mining statistics recorded in the data directory: hash rate
+samples, shares, blocks found and power estimates, over the --history window
+(e.g. 24h, 7d, 2w).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		window, _ := cmd.Flags().GetString("history")
+		history, err := stats.ParseHistory(window)
+		if err != nil {
+			return err
+		}
+		return printMiningStats(cmd, history)
 	},
 }
 
@@ -83,11 +171,19 @@ var mineBenchmarkCmd = &cobra.Command{
 
 func init() {
 	// Mine start flags
-	mineStartCmd.Flags().StringP("address", "a", "", "mining address (required)")
-	mineStartCmd.Flags().IntP("threads", "t", 0, "number of threads (0 = auto)")
-	mineStartCmd.Flags().StringP("pool", "p", "", "mining pool URL")
+	mineStartCmd.Flags().StringP("address", "a", "", "mining address (default mining.address)")
+	mineStartCmd.Flags().Int("threads", 0, "number of threads (0 = auto)")
+	mineStartCmd.Flags().StringP("pool", "p", "", "mining pool address (stratum+tcp://host:port)")
 	mineStartCmd.Flags().String("optimization", "balanced", "optimization mode: power_save, balanced, performance, extreme")
-	mineStartCmd.MarkFlagRequired("address")
+	bindConfigFlags(mineStartCmd, map[string]string{
+		"address":      "mining.address",
+		"threads":      "mining.threads",
+		"pool":         "mining.pool",
+		"optimization": "mining.optimization",
+	})
+	
+	// Stats flags
+	mineStatsCmd.Flags().String("history", "24h", "report window, e.g. 24h, 7d, 2w")
 	
 	// Benchmark flags
 	mineBenchmarkCmd.Flags().IntP("rounds", "r", 1000, "number of benchmark rounds")
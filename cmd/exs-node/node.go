@@ -1,8 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chaos"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/clock"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/p2p"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/rpc"
 	"github.com/spf13/cobra"
 )
 
@@ -22,18 +37,199 @@ Features:
 var nodeStartCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start blockchain node",
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Start the node and accept EXS peers.
+
+Peers exchange EXS service bits and an exsfeatures message during the
+handshake. Peers running another Tetra-PoW version, or lacking a feature
+listed in --require-features, are disconnected before sync begins.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		mode, _ := cmd.Flags().GetString("mode")
-		port, _ := cmd.Flags().GetInt("port")
-		rpcPort, _ := cmd.Flags().GetInt("rpc-port")
+		port := config.P2P.Port
+		connect := config.P2P.Connect
+		listen := config.P2P.Listen
+		maxUpload := config.P2P.MaxUploadRate
+		maxDownload := config.P2P.MaxDownloadRate
+		noEncryption := config.P2P.Encryption == "off"
+		requireEncryption := config.P2P.Encryption == "require"
+		if cmd.Flags().Changed("no-encryption") {
+			noEncryption, _ = cmd.Flags().GetBool("no-encryption")
+		}
+		if cmd.Flags().Changed("require-encryption") {
+			requireEncryption, _ = cmd.Flags().GetBool("require-encryption")
+		}
+
+		features, err := p2p.ParseFeatures(config.P2P.Features)
+		if err != nil {
+			return err
+		}
+		required, err := p2p.ParseFeatures(config.P2P.RequireFeatures)
+		if err != nil {
+			return err
+		}
+		store, err := openChain(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		local := rpc.NewLocalChain(store)
+		faults := chaosInjector()
+
+		node, err := p2p.NewNode(p2p.Config{
+			Network:           networkParams(cmd),
+			StartHeight:       func() int32 { return store.Tip().Height },
+			UserAgent:         "exs-node:" + Version,
+			Features:          features,
+			RequiredFeatures:  required,
+			DisableEncryption: noEncryption,
+			RequireEncryption: requireEncryption,
+			MaxUploadRate:     maxUpload * 1024,
+			MaxDownloadRate:   maxDownload * 1024,
+			Chaos:             faults,
+		})
+		if err != nil {
+			return err
+		}
+		defer node.Close()
+		monitor := clock.NewMonitor(clockConfig(node.TimeOffset))
 		
 		fmt.Println("🌐 Starting Excalibur-EXS Node")
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 		fmt.Printf("Mode: %s\n", mode)
+		fmt.Printf("Network: %s\n", networkParams(cmd).Name)
 		fmt.Printf("P2P Port: %d\n", port)
-		fmt.Printf("RPC Port: %d\n", rpcPort)
-		fmt.Println("\nNode starting...")
-		fmt.Println("✗ Not implemented yet")
+		tip := store.Tip()
+		fmt.Printf("Best Block: %d (%s)\n", tip.Height, tip.Hash)
+		if height := store.PruneHeight(); height > 0 {
+			fmt.Printf("Pruned Below: %d\n", height)
+		}
+		if config.RPC.Enabled {
+			fmt.Printf("RPC: %s\n", rpcAddr())
+			if config.RPC.GRPCPort != 0 {
+				fmt.Printf("gRPC: %s\n", grpcAddr())
+			}
+			if config.RPC.Wallet != "" {
+				fmt.Printf("RPC Wallet: %s\n", config.RPC.Wallet)
+			}
+		} else {
+			fmt.Println("RPC: disabled")
+		}
+		fmt.Printf("Tetra-PoW: v%d\n", p2p.TetraPoWVersion)
+		fmt.Printf("Features: %s\n", features)
+		switch {
+		case noEncryption:
+			fmt.Println("Transport: plaintext")
+		case requireEncryption:
+			fmt.Println("Transport: encrypted (required)")
+		default:
+			fmt.Println("Transport: encrypted (plaintext fallback)")
+		}
+		fmt.Printf("Upload Limit: %s\n", formatRate(maxUpload*1024))
+		fmt.Printf("Download Limit: %s\n", formatRate(maxDownload*1024))
+		if servers := len(config.Clock.NTPServers); servers > 0 {
+			fmt.Printf("Clock: checked against %d NTP servers every %dm and peers\n", servers, config.Clock.CheckInterval)
+		} else {
+			fmt.Println("Clock: checked against peers (NTP disabled)")
+		}
+		if config.Backup.Enabled {
+			fmt.Printf("Backups: every %dm, keeping %d\n", config.Backup.Interval, config.Backup.Keep)
+		}
+		if faults != nil {
+			fmt.Printf("⚠️  Chaos: injecting %s into peers and RPC\n", faults.Config())
+		}
+		
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		
+		go monitor.Run(ctx, func(status clock.Status) {
+			logClockStatus(status)
+		})
+
+		statusDone := make(chan struct{})
+		go func() {
+			defer close(statusDone)
+			publishStatus(ctx, statusPath(cmd), node, store, monitor, nodeStatus{
+				Network:         networkParams(cmd).Name,
+				StartedAt:       time.Now().Add(-node.Uptime()),
+				MaxUploadRate:   maxUpload * 1024,
+				MaxDownloadRate: maxDownload * 1024,
+			})
+		}()
+
+		backupDone := make(chan struct{})
+		go func() {
+			defer close(backupDone)
+			if !config.Backup.Enabled {
+				return
+			}
+			if err := runBackups(ctx, cmd, store); err != nil {
+				slog.Error("Backups disabled", "err", err)
+			}
+		}()
+		
+		rescanDone := make(chan struct{})
+		go func() {
+			defer close(rescanDone)
+			runRescans(ctx, cmd)
+		}()
+
+		errc := make(chan error, 3)
+		if config.RPC.Enabled {
+			if config.RPC.Password == "changeme" {
+				slog.Warn("RPC password is the default; set rpc.password before exposing the RPC port")
+			}
+			go func() {
+				errc <- serveRPC(ctx, cmd, local, monitor.CheckMining, faults)
+			}()
+			if config.RPC.GRPCPort != 0 {
+				go func() {
+					errc <- serveGRPC(ctx, cmd, local)
+				}()
+			}
+		}
+		if listen {
+			go func() {
+				errc <- node.Listen(ctx, net.JoinHostPort(config.P2P.Bind, strconv.Itoa(port)))
+			}()
+		}
+		for _, addr := range connect {
+			dialCtx, cancel := context.WithTimeout(ctx, p2p.DefaultHandshakeTimeout)
+			info, err := node.Connect(dialCtx, addr)
+			cancel()
+			if err != nil {
+				slog.Warn("Failed to connect to peer", "peer", addr, "err", err)
+				continue
+			}
+			transport := "plaintext"
+			if info.Encrypted {
+				transport = fmt.Sprintf("encrypted, session %x", info.SessionID[:8])
+			}
+			slog.Info("Connected to peer",
+				"peer", addr,
+				"user_agent", info.UserAgent,
+				"tetra_pow", info.TetraPoWVersion,
+				"features", info.Features.String(),
+				"uptime", info.Uptime().Truncate(time.Second),
+				"transport", transport)
+		}
+		
+		fmt.Println("\nNode running. Press Ctrl+C to stop.")
+		select {
+		case <-ctx.Done():
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		}
+		stop()
+		<-statusDone
+		<-backupDone
+		<-rescanDone
+		bandwidth := node.Bandwidth()
+		slog.Info("Node stopped",
+			"uptime", node.Uptime().Truncate(time.Second),
+			"received", formatBytes(bandwidth.BytesIn),
+			"sent", formatBytes(bandwidth.BytesOut))
+		return nil
 	},
 }
 
@@ -49,15 +245,40 @@ var nodeStopCmd = &cobra.Command{
 var nodeStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show node status",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		status, err := readStatus(cmd)
+		if err != nil {
+			return err
+		}
+
 		fmt.Println("📊 Node Status")
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		if status != nil {
+			fmt.Println("Status:          Running")
+			fmt.Printf("Best Block:      %d (%s)\n", status.BestHeight, status.BestHash)
+			fmt.Printf("Connections:     %d\n", len(status.Peers))
+			fmt.Printf("Network:         %s\n", status.Network)
+			if status.Clock != nil {
+				fmt.Printf("Clock:           %s\n", status.Clock.String())
+				printClockStatus(*status.Clock)
+			}
+			return nil
+		}
+
 		fmt.Println("Status:          Stopped")
-		fmt.Println("Sync Progress:   0.00%")
-		fmt.Println("Best Block:      0")
-		fmt.Println("Connections:     0")
-		fmt.Println("Network:         mainnet")
-		fmt.Println("✗ Not implemented yet")
+		if _, err := os.Stat(chainPath(cmd)); err == nil {
+			store, err := openChain(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			tip := store.Tip()
+			fmt.Printf("Best Block:      %d (%s)\n", tip.Height, tip.Hash)
+		} else {
+			fmt.Println("Best Block:      none (the chain is created by node start)")
+		}
+		fmt.Printf("Network:         %s\n", networkParams(cmd).Name)
+		return nil
 	},
 }
 
@@ -73,21 +294,100 @@ var nodeSyncCmd = &cobra.Command{
 var nodePeersCmd = &cobra.Command{
 	Use:   "peers",
 	Short: "List connected peers",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		status, err := readStatus(cmd)
+		if err != nil {
+			return err
+		}
+		
 		fmt.Println("👥 Connected Peers")
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		fmt.Println("No peers connected")
-		fmt.Println("✗ Not implemented yet")
+		if status == nil {
+			fmt.Println("Node is not running")
+			return nil
+		}
+		if len(status.Peers) == 0 {
+			fmt.Println("No peers connected")
+			return nil
+		}
+		for _, p := range status.Peers {
+			direction := "outbound"
+			if p.Inbound {
+				direction = "inbound"
+			}
+			transport := "plaintext"
+			if p.Encrypted {
+				transport = "encrypted"
+			}
+			fmt.Printf("%s  %s  %s, %s, connected %s\n", p.Addr, p.UserAgent, direction, transport,
+				time.Since(p.ConnectedAt).Truncate(time.Second))
+			fmt.Printf("    received %s, sent %s, clock %s\n", formatBytes(p.Traffic.BytesIn), formatBytes(p.Traffic.BytesOut), clock.FormatOffset(p.TimeOffset))
+		}
+		return nil
 	},
 }
 
+// chainPath returns the chain database of the configured network. Backends
+// other than LevelDB get their own path.
+func chainPath(cmd *cobra.Command) string {
+	path := filepath.Join(dataDir(cmd), "chain", networkParams(cmd).Name)
+	if backend := config.Storage.Backend; backend != "" && backend != kv.BackendLevelDB {
+		path += "." + backend
+	}
+	return path
+}
+
+// openChain opens the chain database with the storage settings. --mode
+// pruned turns pruning on, which rules out the transaction index.
+func openChain(cmd *cobra.Command) (*chain.Chain, error) {
+	mode, _ := cmd.Flags().GetString("mode")
+	opts := chain.Options{
+		TxIndex:   config.Storage.TxIndex,
+		AddrIndex: config.Storage.AddrIndex,
+		CacheSize: config.Performance.DBCache << 20,
+		Backend:   config.Storage.Backend,
+	}
+	if config.Storage.Prune || mode == "pruned" {
+		opts.PruneDepth = config.Storage.PruneDepth
+		opts.TxIndex = false
+	}
+	return chain.Open(chainPath(cmd), networkParams(cmd), opts)
+}
+
+// chaosInjector returns the fault injector of the chaos setting, nil when
+// it is empty. The setting was checked when the configuration was loaded.
+func chaosInjector() *chaos.Injector {
+	cfg, _ := chaos.Parse(config.Chaos)
+	return chaos.New(cfg)
+}
+
 func init() {
 	// Node start flags
 	nodeStartCmd.Flags().String("mode", "full", "node mode: full, spv, pruned")
 	nodeStartCmd.Flags().IntP("port", "p", 8333, "P2P port")
 	nodeStartCmd.Flags().Int("rpc-port", 8332, "RPC port")
+	nodeStartCmd.Flags().String("rpc-wallet", "", "wallet served by getbalance and getnewaddress")
 	nodeStartCmd.Flags().StringSlice("connect", []string{}, "connect to specific peers")
 	nodeStartCmd.Flags().Bool("listen", true, "accept incoming connections")
+	nodeStartCmd.Flags().String("features", "compact-filters", "optional features to offer: runes, compact-filters, forge-commitments")
+	nodeStartCmd.Flags().String("require-features", "", "features peers must offer")
+	nodeStartCmd.Flags().Bool("no-encryption", false, "disable the encrypted peer transport")
+	nodeStartCmd.Flags().Bool("require-encryption", false, "reject peers that do not support the encrypted transport")
+	nodeStartCmd.Flags().Int64("max-upload-rate", 0, "upload limit in KB/s across all peers (0 = unlimited)")
+	nodeStartCmd.Flags().Int64("max-download-rate", 0, "download limit in KB/s across all peers (0 = unlimited)")
+	nodeStartCmd.Flags().String("chaos", "", "inject faults into peers and RPC for resilience testing, e.g. delay=250ms,drop=0.01")
+	bindConfigFlags(nodeStartCmd, map[string]string{
+		"port":              "p2p.port",
+		"rpc-port":          "rpc.port",
+		"rpc-wallet":        "rpc.wallet",
+		"connect":           "p2p.connect",
+		"listen":            "p2p.listen",
+		"features":          "p2p.features",
+		"require-features":  "p2p.require_features",
+		"max-upload-rate":   "p2p.max_upload_rate",
+		"max-download-rate": "p2p.max_download_rate",
+		"chaos":             "chaos",
+	})
 	
 	nodeCmd.AddCommand(
 		nodeStartCmd,
@@ -95,6 +395,7 @@ func init() {
 		nodeStatusCmd,
 		nodeSyncCmd,
 		nodePeersCmd,
+		nodeClockCmd,
 	)
 	
 	rootCmd.AddCommand(nodeCmd)
@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
 )
 
 var oracleCmd = &cobra.Command{
@@ -87,8 +89,8 @@ var oracleQuickCmd = &cobra.Command{
 			fmt.Println("  Command: exs-node mine start --address <addr>")
 		case "forge":
 			fmt.Println("Forge Process:")
-			fmt.Println("  Every successful forge rewards 50 $EXS")
-			fmt.Println("  15% (7.5 $EXS) goes to treasury")
+			fmt.Printf("  Every successful forge rewards %s $EXS\n", economy.ForgeReward)
+			fmt.Printf("  %d%% (%s $EXS) goes to treasury\n", economy.TreasuryPercent, economy.TreasuryAllocation)
 			fmt.Println("  Use: exs-node forge start --address <addr>")
 		case "treasury":
 			fmt.Println("Treasury Information:")
@@ -97,9 +99,9 @@ var oracleQuickCmd = &cobra.Command{
 			fmt.Println("  Use: exs-node revenue show")
 		case "rewards":
 			fmt.Println("Reward System:")
-			fmt.Println("  Forge reward: 50 EXS")
-			fmt.Println("  Smooth exponential halving over 1000 forges")
-			fmt.Println("  Tail emission: 0.1 EXS minimum forever")
+			fmt.Printf("  Forge reward: %s EXS, halving every %d forges\n", economy.ForgeReward, economy.HalvingInterval)
+			fmt.Printf("  Smooth exponential halving over %d forges\n", economy.HalvingTransition)
+			fmt.Printf("  Tail emission: %s EXS minimum until the %s EXS cap\n", economy.TailEmission, economy.TotalSupplyCap)
 		default:
 			fmt.Printf("Unknown topic: %s\n", topic)
 			fmt.Println("Available topics: mining, forge, treasury, rewards")
@@ -134,10 +136,10 @@ func consultOracle(question string) OracleResponse {
 		}
 	} else if strings.Contains(questionLower, "forge") || strings.Contains(questionLower, "forging") {
 		return OracleResponse{
-			Wisdom: "Every successful forge echoes through Camelot, rewarding the worthy with 50 $EXS.",
+			Wisdom: fmt.Sprintf("Every successful forge echoes through Camelot, rewarding the worthy with %s $EXS.", economy.ForgeReward),
 			Details: map[string]string{
-				"Forge Reward": "50 $EXS per successful forge",
-				"Treasury":     "15% (7.5 $EXS) to treasury",
+				"Forge Reward": fmt.Sprintf("%s $EXS per successful forge", economy.ForgeReward),
+				"Treasury":     fmt.Sprintf("%d%% (%s $EXS) to treasury", economy.TreasuryPercent, economy.TreasuryAllocation),
 				"Process":      "Verify axiom → Draw sword → Mine 128 rounds → Receive P2TR vault",
 			},
 		}
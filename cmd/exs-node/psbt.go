@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin/hww"
+	exspsbt "github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/spf13/cobra"
+)
+
+var psbtCmd = &cobra.Command{
+	Use:   "psbt",
+	Short: "Coordinate partially signed transactions (BIP-174)",
+	Long: `Inspect, sign, combine and finalize PSBTs so vault and treasury spends
+can be signed offline by several parties or hardware wallets.
+
+A typical multisig treasury distribution:
+  exs-node psbt sign dist.psbt --keys knight1.txt --out k1.psbt
+  exs-node psbt sign dist.psbt --keys knight2.txt --out k2.psbt
+  exs-node psbt sign dist.psbt --device hww:d34db33f/86h/0h/0h/0/0 --out k3.psbt
+  exs-node psbt combine k1.psbt k2.psbt k3.psbt --out combined.psbt
+  exs-node psbt finalize combined.psbt
+
+PSBTs are read as binary, hex or base64 and written as base64.`,
+}
+
+var psbtDecodeCmd = &cobra.Command{
+	Use:   "decode [psbt]",
+	Short: "Show the inputs, outputs and signatures of a PSBT",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		packet, err := readPSBT(args[0])
+		if err != nil {
+			return err
+		}
+		net := networkParams(cmd)
+
+		fmt.Printf("Transaction: %s\n", packet.UnsignedTx.TxHash())
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		var inputTotal, outputTotal int64
+		known := true
+		for i, in := range packet.Inputs {
+			outPoint := packet.UnsignedTx.TxIn[i].PreviousOutPoint
+			status := "unsigned"
+			switch {
+			case len(in.FinalScriptWitness) > 0 || len(in.FinalScriptSig) > 0:
+				status = "final"
+			case len(in.TaprootKeySpendSig) > 0:
+				status = "signed (taproot key path)"
+			case len(in.PartialSigs) > 0:
+				status = fmt.Sprintf("%d partial signature(s)", len(in.PartialSigs))
+			}
+			if in.WitnessUtxo == nil {
+				known = false
+				fmt.Printf("Input %d:  %s  %s\n", i, outPoint, status)
+				continue
+			}
+			inputTotal += in.WitnessUtxo.Value
+			fmt.Printf("Input %d:  %s  %d sats  %s\n", i, outPoint, in.WitnessUtxo.Value, status)
+		}
+		for i, out := range packet.UnsignedTx.TxOut {
+			outputTotal += out.Value
+			address := "nonstandard"
+			if _, addrs, _, err := txscript.ExtractPkScriptAddrs(out.PkScript, net); err == nil && len(addrs) == 1 {
+				address = addrs[0].EncodeAddress()
+			} else if txscript.GetScriptClass(out.PkScript) == txscript.NullDataTy {
+				address = "OP_RETURN"
+			}
+			fmt.Printf("Output %d: %s  %d sats\n", i, address, out.Value)
+		}
+		if known {
+			fmt.Printf("Fee:      %d sats\n", inputTotal-outputTotal)
+		} else {
+			fmt.Println("Fee:      unknown (inputs lack witness UTXO data)")
+		}
+		return nil
+	},
+}
+
+var psbtSignCmd = &cobra.Command{
+	Use:   "sign [psbt]",
+	Short: "Sign the Taproot inputs of a PSBT",
+	Long: `Sign the P2TR inputs controlled by the keys in --keys by key path, adding
+the BIP-371 internal key and merkle root. The --keys file holds one WIF key
+per line, optionally followed by ":" and a vault script root in hex.
+
+With --device the inputs of a key on a hardware wallet are signed on the
+device, which shows the transaction for confirmation. The PSBT sent to it
+carries the key's BIP-32 derivation and Taproot tweak, and each signature it
+returns is verified before it is added.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyFile, _ := cmd.Flags().GetString("keys")
+		devices, _ := cmd.Flags().GetStringArray("device")
+		out, _ := cmd.Flags().GetString("out")
+		if keyFile == "" && len(devices) == 0 {
+			return fmt.Errorf("--keys or --device is required")
+		}
+		deviceKeys := make([]*hww.Key, len(devices))
+		for i, device := range devices {
+			key, err := hww.ParseKey(device)
+			if err != nil {
+				return fmt.Errorf("--device %s: %w", device, err)
+			}
+			deviceKeys[i] = key
+		}
+
+		packet, err := readPSBT(args[0])
+		if err != nil {
+			return err
+		}
+		var count int
+		if keyFile != "" {
+			keys, err := readTaprootKeys(keyFile, networkParams(cmd))
+			if err != nil {
+				return err
+			}
+			if count, err = exspsbt.Sign(packet, keys); err != nil {
+				return err
+			}
+		}
+		if len(deviceKeys) > 0 {
+			fmt.Fprintln(os.Stderr, "Confirm the transaction on the hardware wallet...")
+			signed, err := hww.New(networkParams(cmd)).Sign(cmd.Context(), packet, deviceKeys)
+			if err != nil {
+				return err
+			}
+			count += signed
+		}
+		if count == 0 {
+			return fmt.Errorf("no inputs can be signed with the given keys")
+		}
+		if err := writePSBT(packet, out); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "✓ Signed %d input(s)\n", count)
+		return nil
+	},
+}
+
+var psbtCombineCmd = &cobra.Command{
+	Use:   "combine [psbt...]",
+	Short: "Merge signatures from several copies of a PSBT",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+
+		packets := make([]*exspsbt.Packet, len(args))
+		for i, arg := range args {
+			packet, err := readPSBT(arg)
+			if err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			packets[i] = packet
+		}
+		combined, err := exspsbt.Combine(packets...)
+		if err != nil {
+			return err
+		}
+		return writePSBT(combined, out)
+	},
+}
+
+var psbtFinalizeCmd = &cobra.Command{
+	Use:   "finalize [psbt]",
+	Short: "Finalize a fully signed PSBT and print the raw transaction",
+	Long: `Finalize every input, check the signatures by executing the scripts and
+print the raw transaction ready for broadcast.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+
+		packet, err := readPSBT(args[0])
+		if err != nil {
+			return err
+		}
+		tx, err := exspsbt.Finalize(packet)
+		if err != nil {
+			return err
+		}
+		rawTx, err := bitcoin.SerializeTx(tx)
+		if err != nil {
+			return err
+		}
+		if out != "" {
+			if err := os.WriteFile(out, []byte(rawTx+"\n"), 0600); err != nil {
+				return fmt.Errorf("failed to write transaction: %w", err)
+			}
+		}
+		fmt.Println(rawTx)
+		return nil
+	},
+}
+
+// writePSBT writes a packet as base64 to out, or stdout when out is empty
+func writePSBT(packet *exspsbt.Packet, out string) error {
+	encoded, err := exspsbt.Encode(packet)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		fmt.Println(encoded)
+		return nil
+	}
+	if err := os.WriteFile(out, []byte(encoded+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write psbt: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	psbtSignCmd.Flags().String("keys", "", "file of WIF keys or keystore:NAME references, one per line, optionally followed by :script-root")
+	psbtSignCmd.Flags().StringArray("device", nil, "key on a hardware wallet to sign with, hww:FINGERPRINT/PATH[:script-root] (repeatable)")
+	psbtSignCmd.Flags().String("out", "", "write the signed PSBT to a file instead of stdout")
+	psbtCombineCmd.Flags().String("out", "", "write the combined PSBT to a file instead of stdout")
+	psbtFinalizeCmd.Flags().String("out", "", "also write the raw transaction hex to a file")
+
+	psbtCmd.AddCommand(psbtDecodeCmd, psbtSignCmd, psbtCombineCmd, psbtFinalizeCmd)
+	rootCmd.AddCommand(psbtCmd)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+// rescanInterval is how often node start looks for descriptors imported
+// with a rescan pending
+const rescanInterval = time.Minute
+
+// runRescans scans the descriptors every wallet has pending a rescan, in the
+// background of a running node, until ctx is cancelled
+func runRescans(ctx context.Context, cmd *cobra.Command) {
+	ticker := time.NewTicker(rescanInterval)
+	defer ticker.Stop()
+	for {
+		entries, err := os.ReadDir(filepath.Join(dataDir(cmd), "wallets"))
+		if err != nil && !os.IsNotExist(err) {
+			slog.Error("Rescan failed", "err", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				rescanWallet(ctx, cmd, entry.Name())
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rescanWallet scans walletName's pending descriptors and records the
+// results. The store is only held open to read and record, so wallet
+// commands keep working while the scan runs.
+func rescanWallet(ctx context.Context, cmd *cobra.Command, walletName string) {
+	path := descriptorStorePath(cmd, walletName)
+	if config.Storage.Backend != "" {
+		path = strings.TrimSuffix(path, ".json") + "." + config.Storage.Backend
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return
+	}
+	store, err := openDescriptorStore(cmd, walletName)
+	if err != nil {
+		slog.Error("Rescan failed", "wallet", walletName, "err", err)
+		return
+	}
+	var pending []wallet.ImportedDescriptor
+	for _, entry := range store.List() {
+		if entry.NeedsScan {
+			pending = append(pending, entry)
+		}
+	}
+	store.Close()
+	if len(pending) == 0 {
+		return
+	}
+
+	source, err := chainSource(cmd)
+	if err != nil {
+		slog.Error("Rescan failed", "wallet", walletName, "err", err)
+		return
+	}
+	slog.Info("Rescanning descriptors", "wallet", walletName, "descriptors", len(pending))
+	job := wallet.NewScanner(source, networkParams(cmd), config.Wallet.GapLimit).StartRescan(ctx, pending)
+	results, scanErr := job.Wait()
+
+	if len(results) > 0 {
+		if store, err = openDescriptorStore(cmd, walletName); err != nil {
+			slog.Error("Rescan failed", "wallet", walletName, "err", err)
+			return
+		}
+		defer store.Close()
+		for _, result := range results {
+			if err := store.RecordScan(result); err != nil {
+				slog.Error("Rescan failed", "wallet", walletName, "err", err)
+				return
+			}
+		}
+	}
+	progress := job.Progress()
+	if scanErr != nil {
+		if ctx.Err() == nil {
+			slog.Error("Rescan failed", "wallet", walletName, "completed", progress.Completed, "err", scanErr)
+		}
+		return
+	}
+	slog.Info("Rescan complete",
+		"wallet", walletName,
+		"descriptors", progress.Completed,
+		"scanned", progress.Scanned,
+		"used", progress.Found)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/spf13/cobra"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chaos"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/health"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/rpc"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+)
+
+// serveRPC serves the JSON-RPC API for local on rpc.bind and rpc.port until
+// ctx is done. generatetoaddress is refused while clockCheck fails, and
+// faults, when not nil, are injected into every request. In-flight requests
+// are drained on shutdown.
+func serveRPC(ctx context.Context, cmd *cobra.Command, local *rpc.LocalChain, clockCheck func() error, faults *chaos.Injector) error {
+	net := networkParams(cmd)
+	chain, err := rpcChain(cmd, local)
+	if err != nil {
+		return err
+	}
+
+	// Blocks the node mines or accepts from external miners are recorded
+	// with the miner statistics
+	if store, err := openMiningStats(cmd); err != nil {
+		slog.Warn("Mining statistics disabled", "err", err)
+	} else {
+		defer store.Close()
+		chain = &statsChain{Chain: chain, store: store}
+	}
+
+	cfg := rpc.Config{Chain: chain, User: config.RPC.User, Password: config.RPC.Password, ClockCheck: clockCheck, MiningAddress: config.Mining.Address}
+	cfg.Fees = rpcFeeEstimator(cmd)
+	go rpc.TrackFees(ctx, chain, cfg.Fees, 30*time.Second)
+	if config.RPC.Wallet != "" {
+		cfg.Wallet = &rpcWallet{
+			openStore: func() (*wallet.DescriptorStore, error) {
+				return openDescriptorStore(cmd, config.RPC.Wallet)
+			},
+			snapshotPath: snapshotPath(cmd, config.RPC.Wallet),
+			net:          net,
+			chain:        local,
+			gapLimit:     config.Wallet.GapLimit,
+		}
+	}
+
+	// /healthz and /readyz answer without credentials; the node is not
+	// ready while its clock cannot timestamp blocks
+	probe := health.NewProbe()
+	if clockCheck != nil {
+		probe.Add("clock", func(ctx context.Context) error { return clockCheck() })
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", probe.Liveness())
+	mux.Handle("GET /readyz", probe.Readiness())
+	mux.Handle("/", rpc.NewServer(cfg))
+
+	server := &http.Server{
+		Addr:              rpcAddr(),
+		Handler:           faults.Middleware(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := health.Serve(ctx, server, probe, server.ListenAndServe); err != nil {
+		return fmt.Errorf("rpc server: %w", err)
+	}
+	return nil
+}
+
+// serveGRPC serves NodeService for local on rpc.bind and rpc.grpc_port
+// until ctx is done, with the JSON-RPC credentials
+func serveGRPC(ctx context.Context, cmd *cobra.Command, local *rpc.LocalChain) error {
+	chain, err := rpcChain(cmd, local)
+	if err != nil {
+		return err
+	}
+	server := api.NewServer(api.BasicAuth(config.RPC.User, config.RPC.Password))
+	exsv1.RegisterNodeServiceServer(server, api.NewNodeServer(chain))
+	return api.Serve(ctx, server, grpcAddr())
+}
+
+// rpcChain returns the chain the RPC servers serve: local on regtest,
+// relaying transactions through the wallet's Esplora API elsewhere
+func rpcChain(cmd *cobra.Command, local *rpc.LocalChain) (rpc.Chain, error) {
+	if networkParams(cmd).Net == chaincfg.RegressionNetParams.Net {
+		return local, nil
+	}
+	source, err := chainSource(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &relayChain{LocalChain: local, source: source}, nil
+}
+
+// rpcFeeEstimator returns the estimator behind estimatesmartfee, fed the
+// node's blocks and mempool. Off regtest it falls back to the fee estimates
+// of fees.estimator or the wallet backend until it has seen enough blocks.
+func rpcFeeEstimator(cmd *cobra.Command) *fees.Estimator {
+	mode, _ := fees.ParseMode(config.Fees.Mode)
+	if networkParams(cmd).Net == chaincfg.RegressionNetParams.Net {
+		return fees.New(fees.Config{Mode: mode})
+	}
+	external, err := feeEstimator(cmd)
+	if err != nil {
+		slog.Warn("External fee estimates disabled", "err", err)
+		return fees.New(fees.Config{Mode: mode})
+	}
+	return fees.New(fees.Config{External: external, Mode: mode})
+}
+
+// rpcAddr returns the address the RPC server listens on
+func rpcAddr() string {
+	return net.JoinHostPort(config.RPC.Bind, strconv.Itoa(config.RPC.Port))
+}
+
+// grpcAddr returns the address the gRPC server listens on
+func grpcAddr() string {
+	return net.JoinHostPort(config.RPC.Bind, strconv.Itoa(config.RPC.GRPCPort))
+}
+
+// relayChain serves chain queries from the node's chain and relays
+// transactions through the wallet's Esplora API, for networks the node
+// cannot mine on
+type relayChain struct {
+	*rpc.LocalChain
+	source wallet.ChainSource
+}
+
+// SendTransaction broadcasts tx through the Esplora API
+func (c *relayChain) SendTransaction(ctx context.Context, tx *wire.MsgTx) error {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return err
+	}
+	if _, err := c.source.Broadcast(ctx, hex.EncodeToString(buf.Bytes())); err != nil {
+		return fmt.Errorf("%w: %v", rpc.ErrTxRejected, err)
+	}
+	return nil
+}
+
+// rpcWallet serves the wallet methods from a wallet's descriptor store. On
+// regtest its balance comes from the node's own chain, elsewhere from the
+// outputs cached by the last wallet balance scan.
+type rpcWallet struct {
+	openStore    func() (*wallet.DescriptorStore, error)
+	snapshotPath string
+	net          *chaincfg.Params
+	chain        *rpc.LocalChain
+	gapLimit     uint32
+}
+
+// NewAddress reserves the next receiving address of the wallet's first tr()
+// descriptor for bech32m, or wpkh() descriptor for bech32
+func (w *rpcWallet) NewAddress(ctx context.Context, addressType string) (string, error) {
+	descType := wallet.DescriptorTR
+	if addressType == "bech32" {
+		descType = wallet.DescriptorWPKH
+	}
+	store, err := w.openStore()
+	if err != nil {
+		return "", err
+	}
+	defer store.Close()
+	for _, entry := range store.List() {
+		desc, err := wallet.ParseDescriptor(entry.Descriptor)
+		if err != nil {
+			return "", err
+		}
+		if desc.Type != descType || !desc.Ranged {
+			continue
+		}
+		index, err := store.Reserve(entry.Descriptor, 0)
+		if err != nil {
+			return "", err
+		}
+		derived, err := desc.Derive(0, index, w.net)
+		if err != nil {
+			return "", err
+		}
+		return derived.Address, nil
+	}
+	return "", fmt.Errorf("wallet has no ranged %s descriptor", descType)
+}
+
+// Balance totals the wallet's mature outputs confirmed at least minConf times
+func (w *rpcWallet) Balance(ctx context.Context, minConf int) (btcutil.Amount, error) {
+	if w.net.Net != chaincfg.RegressionNetParams.Net {
+		snapshot, err := wallet.LoadSnapshot(w.snapshotPath)
+		if err != nil {
+			return 0, err
+		}
+		// The snapshot does not record the tip, so any minconf above zero
+		// counts every confirmed output
+		balance := snapshot.Confirmed()
+		if minConf == 0 {
+			balance += snapshot.Unconfirmed()
+		}
+		return btcutil.Amount(balance), nil
+	}
+
+	scripts, err := w.scripts()
+	if err != nil {
+		return 0, err
+	}
+	coins, err := w.chain.Unspent(func(pkScript []byte) bool { return scripts[string(pkScript)] })
+	if err != nil {
+		return 0, err
+	}
+	tip := w.chain.Tip().Height
+	var balance btcutil.Amount
+	for _, coin := range coins {
+		confirmations := int(tip - coin.Height + 1)
+		if confirmations < minConf || coin.Coinbase && confirmations <= int(w.net.CoinbaseMaturity) {
+			continue
+		}
+		balance += btcutil.Amount(coin.TxOut.Value)
+	}
+	return balance, nil
+}
+
+// scripts returns the output scripts of the wallet's addresses up to the gap
+// limit past the next unused index of every branch
+func (w *rpcWallet) scripts() (map[string]bool, error) {
+	store, err := w.openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	gapLimit := w.gapLimit
+	if gapLimit == 0 {
+		gapLimit = wallet.DefaultGapLimit
+	}
+	scripts := make(map[string]bool)
+	for _, entry := range store.List() {
+		desc, err := wallet.ParseDescriptor(entry.Descriptor)
+		if err != nil {
+			return nil, err
+		}
+		for branch, next := range entry.NextIndex {
+			var derived []*wallet.DerivedAddress
+			if desc.Ranged {
+				derived, err = desc.DeriveRange(branch, entry.RangeStart, next+gapLimit, w.net)
+			} else {
+				var addr *wallet.DerivedAddress
+				addr, err = desc.Derive(branch, 0, w.net)
+				derived = []*wallet.DerivedAddress{addr}
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, addr := range derived {
+				scripts[string(addr.PkScript)] = true
+			}
+		}
+	}
+	return scripts, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/clock"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/p2p"
+	"github.com/spf13/cobra"
+)
+
+// statusInterval is how often a running node refreshes its status file
+const statusInterval = 5 * time.Second
+
+// nodeStatus is what a running node publishes for the dashboard and other
+// commands
+type nodeStatus struct {
+	Network         string             `json:"network"`
+	BestHeight      int32              `json:"best_height"`
+	BestHash        string             `json:"best_hash"`
+	StartedAt       time.Time          `json:"started_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	Bandwidth       p2p.BandwidthStats `json:"bandwidth"`
+	InRate          float64            `json:"in_rate"`  // bytes/s over the last interval
+	OutRate         float64            `json:"out_rate"` // bytes/s over the last interval
+	MaxUploadRate   int64              `json:"max_upload_rate,omitempty"`
+	MaxDownloadRate int64              `json:"max_download_rate,omitempty"`
+	Peers           []peerStatus       `json:"peers"`
+	Clock           *clock.Status      `json:"clock,omitempty"`
+}
+
+// peerStatus is a connected peer in the status file
+type peerStatus struct {
+	Addr        string             `json:"addr"`
+	UserAgent   string             `json:"user_agent"`
+	Inbound     bool               `json:"inbound"`
+	Encrypted   bool               `json:"encrypted"`
+	ConnectedAt time.Time          `json:"connected_at"`
+	TimeOffset  time.Duration      `json:"time_offset"`
+	Traffic     p2p.BandwidthStats `json:"traffic"`
+}
+
+func statusPath(cmd *cobra.Command) string {
+	return filepath.Join(dataDir(cmd), "node-status.json")
+}
+
+// publishStatus writes the node status every statusInterval until ctx is
+// cancelled, then removes the file
+func publishStatus(ctx context.Context, path string, node *p2p.Node, store *chain.Chain, monitor *clock.Monitor, status nodeStatus) {
+	defer os.Remove(path)
+
+	prev, prevAt := node.Bandwidth(), time.Now()
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		total := node.Bandwidth()
+		if elapsed := now.Sub(prevAt).Seconds(); elapsed > 0 {
+			delta := total.Sub(prev)
+			status.InRate = float64(delta.BytesIn) / elapsed
+			status.OutRate = float64(delta.BytesOut) / elapsed
+		}
+		prev, prevAt = total, now
+
+		status.UpdatedAt = now
+		tip := store.Tip()
+		status.BestHeight, status.BestHash = tip.Height, tip.Hash.String()
+		status.Bandwidth = total
+		status.Peers = status.Peers[:0]
+		for _, info := range node.Peers() {
+			status.Peers = append(status.Peers, peerStatus{
+				Addr:        info.Addr,
+				UserAgent:   info.UserAgent,
+				Inbound:     info.Inbound,
+				Encrypted:   info.Encrypted,
+				ConnectedAt: info.ConnectedAt,
+				TimeOffset:  info.TimeOffset,
+				Traffic:     info.Traffic,
+			})
+		}
+		clockStatus := monitor.Status()
+		status.Clock = &clockStatus
+		if err := writeStatus(path, &status); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeStatus atomically replaces the status file
+func writeStatus(path string, status *nodeStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode node status: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write node status: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace node status: %w", err)
+	}
+	return nil
+}
+
+// readStatus loads the status of a running node. It returns nil when no node
+// is running or the file is stale because the node died without cleaning up.
+func readStatus(cmd *cobra.Command) (*nodeStatus, error) {
+	data, err := os.ReadFile(statusPath(cmd))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node status: %w", err)
+	}
+	var status nodeStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse node status: %w", err)
+	}
+	if time.Since(status.UpdatedAt) > 3*statusInterval {
+		return nil, nil
+	}
+	return &status, nil
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatRate formats a rate limit in bytes per second, or "unlimited" for zero
+func formatRate(bytesPerSecond int64) string {
+	if bytesPerSecond <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%.0f KB/s", float64(bytesPerSecond)/1024)
+}
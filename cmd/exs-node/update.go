@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/update"
+	"github.com/spf13/cobra"
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Check for new releases",
+	Long: `Check for new Excalibur-EXS releases. Each release publishes a manifest
+signed by the maintainers; it is trusted only when signed by a key built into
+this binary. Nothing is ever downloaded or installed automatically.
+
+The rosetta, treasury and tetra_pow servers check daily and report the result
+under "update" in /health. EXS_UPDATE_URL sets the manifest URL and
+EXS_UPDATE_INTERVAL the check interval ("0" disables checking).`,
+	// Update checks do not need the node configuration
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+}
+
+var updateCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check whether a newer release is available",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := update.ConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		if url, _ := cmd.Flags().GetString("url"); url != "" {
+			config.URL = url
+		}
+		checker, err := update.NewChecker(config)
+		if errors.Is(err, update.ErrNoMaintainerKeys) {
+			return fmt.Errorf("this build has no maintainer keys, so releases cannot be verified")
+		}
+		if err != nil {
+			return err
+		}
+
+		release, err := checker.Check(context.Background())
+		if err != nil {
+			return err
+		}
+		status := checker.Status()
+
+		fmt.Println("⬆️  Release Check")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Running:  %s\n", status.Current)
+		fmt.Printf("Latest:   %s (released %s)\n", release.Version, release.ReleasedAt.Format("2006-01-02"))
+		if !status.Available {
+			fmt.Println("\n✓ Up to date")
+			return nil
+		}
+		if release.Critical {
+			fmt.Println("\n⚠️  Security release: upgrade as soon as possible")
+		} else {
+			fmt.Println("\n✨ A newer release is available")
+		}
+		if release.URL != "" {
+			fmt.Printf("Release:  %s\n", release.URL)
+		}
+		if release.Notes != "" {
+			fmt.Printf("\n%s\n", release.Notes)
+		}
+		if len(release.Assets) > 0 {
+			fmt.Println("\nDownloads (check the SHA-256 before installing):")
+			for _, asset := range release.Assets {
+				fmt.Printf("  %-32s %s\n", asset.Name, asset.SHA256)
+			}
+		}
+		return nil
+	},
+}
+
+var updateKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a maintainer signing key",
+	Long: `Generate an Ed25519 key for signing release manifests. The private key is
+written to --out; the printed public key line goes into
+pkg/update/maintainers.keys so future builds trust the key.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+		name, _ := cmd.Flags().GetString("name")
+
+		pub, key, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create key file: %w", err)
+		}
+		if _, err := fmt.Fprintln(f, hex.EncodeToString(key.Seed())); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write key file: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write key file: %w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "✓ Private key written to %s; keep it offline\n", out)
+		fmt.Fprintln(os.Stderr, "Add this line to pkg/update/maintainers.keys:")
+		fmt.Println(strings.TrimSpace(hex.EncodeToString(pub) + " " + name))
+		return nil
+	},
+}
+
+var updateSignCmd = &cobra.Command{
+	Use:   "sign [manifest]",
+	Short: "Sign a release manifest",
+	Long: `Add a maintainer signature to a release manifest. With --release, the
+manifest is created from a release JSON file such as
+  {"version": "1.1.0", "released_at": "2026-10-01T00:00:00Z",
+   "url": "https://github.com/Holedozer1229/Excalibur-EXS/releases/tag/v1.1.0",
+   "assets": [{"name": "exs-node-linux-amd64", "sha256": "..."}]}
+Otherwise the signature is added to the existing manifest, so several
+maintainers can sign the same release.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyFile, _ := cmd.Flags().GetString("key")
+		releaseFile, _ := cmd.Flags().GetString("release")
+		if keyFile == "" {
+			return fmt.Errorf("--key is required")
+		}
+		key, err := readSigningKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		var m *update.Manifest
+		if releaseFile != "" {
+			data, err := os.ReadFile(releaseFile)
+			if err != nil {
+				return err
+			}
+			var release update.Release
+			if err := json.Unmarshal(data, &release); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", releaseFile, err)
+			}
+			if m, err = update.NewManifest(&release); err != nil {
+				return err
+			}
+		} else {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			if m, err = update.ParseManifest(data); err != nil {
+				return err
+			}
+		}
+
+		if err := m.Sign(key); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(args[0], append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Signed %s (%d signature(s))\n", args[0], len(m.Signatures))
+		return nil
+	},
+}
+
+// readSigningKey reads a private key written by update keygen
+func readSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not a maintainer signing key", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func init() {
+	updateCheckCmd.Flags().String("url", "", "manifest URL (default $EXS_UPDATE_URL or the GitHub release)")
+	updateKeygenCmd.Flags().String("out", "maintainer.key", "private key file")
+	updateKeygenCmd.Flags().String("name", "", "maintainer name for the public key line")
+	updateSignCmd.Flags().String("key", "", "private key file from update keygen")
+	updateSignCmd.Flags().String("release", "", "create the manifest from this release JSON file")
+
+	updateCmd.AddCommand(updateCheckCmd, updateKeygenCmd, updateSignCmd)
+	rootCmd.AddCommand(updateCmd)
+}
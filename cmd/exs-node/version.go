@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show version and build information",
+	Long: `Show the exs-node version. With --verbose, also show the commit, build
+date, Go version, platform and reproducibility hash. Two binaries built from
+the same commit with the same toolchain, dependencies and flags share a
+build hash; servers report the same fields in their /health responses.`,
+	Args: cobra.NoArgs,
+	// The version is shown even when the configuration is invalid
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		info := buildinfo.Get()
+		if verbose, _ := cmd.Flags().GetBool("verbose"); !verbose {
+			fmt.Printf("exs-node %s\n", info)
+			return
+		}
+
+		commit := info.Commit
+		if commit == "" {
+			commit = "unknown"
+		} else if info.Modified {
+			commit += " (modified)"
+		}
+		buildDate := info.BuildDate
+		if buildDate == "" {
+			buildDate = "unknown"
+		}
+
+		fmt.Println("Excalibur-EXS Console Node")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Version:    %s\n", info.Version)
+		fmt.Printf("Commit:     %s\n", commit)
+		fmt.Printf("Build Date: %s\n", buildDate)
+		fmt.Printf("Go Version: %s\n", info.GoVersion)
+		fmt.Printf("Platform:   %s\n", info.Platform)
+		fmt.Printf("Build Hash: %s\n", info.BuildHash)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
@@ -1,9 +1,31 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	exspsbt "github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin/psbt"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/keystore"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var walletCmd = &cobra.Command{
@@ -21,103 +43,765 @@ Features:
 var walletCreateCmd = &cobra.Command{
 	Use:   "create [wallet-name]",
 	Short: "Create a new HD wallet",
-	Long: `Create a new HD wallet using the 13-word prophecy axiom.
+	Long: `Create a new HD wallet from a freshly generated 13-word prophecy.
 
-The wallet will be encrypted using HPP-1 (600,000 PBKDF2 rounds)
-and stored in the data directory.`,
+The prophecy is encrypted with AES-256-GCM under a key stretched from the
+passphrase with HPP-1 (600,000 PBKDF2 rounds) and stored in the data
+directory. The wallet's BIP-86 Taproot account descriptor is imported so
+addresses and balances can be tracked without unlocking it.
+
+The prophecy is drawn from the BIP-39 wordlist chosen with --language
+(english by default; also japanese, korean, spanish, chinese_simplified,
+chinese_traditional, french, italian and czech).
+
+Keys are seeded from the prophecy's 12-word BIP-39 mnemonic by default, so
+the wallet can be restored in other BIP-86 wallets. --seed forge seeds them
+with the tempered Proof-of-Forge output of the prophecy instead; such a
+wallet can only be restored by exs-node, with --seed forge.
+
+The prophecy is shown once. Write it down: it is the only way to recover
+the wallet without the passphrase.`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		walletName := args[0]
-		useProphecy, _ := cmd.Flags().GetBool("prophecy")
-		passphrase, _ := cmd.Flags().GetString("passphrase")
-		
-		fmt.Printf("Creating wallet: %s\n", walletName)
-		if useProphecy {
-			fmt.Println("Using 13-word prophecy axiom...")
+		language, _ := cmd.Flags().GetString("language")
+		lang, err := crypto.LanguageByName(language)
+		if err != nil {
+			return err
 		}
-		
-		// TODO: Implement wallet creation
-		fmt.Println("✓ Wallet created successfully")
-		fmt.Println("\nIMPORTANT: Back up your seed phrase securely!")
-		
-		if passphrase == "" {
-			fmt.Println("\nWarning: No passphrase set. Use --passphrase to encrypt your wallet.")
+		words, err := lang.NewProphecy()
+		if err != nil {
+			return err
 		}
+		passphrase, err := walletPassphrase(cmd, true)
+		if err != nil {
+			return err
+		}
+		f, err := createWallet(cmd, walletName, words, passphrase, false)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Wallet created: %s\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Network:    %s\n", f.Network)
+		fmt.Printf("Language:   %s\n", lang.Name)
+		fmt.Printf("Seed:       %s\n", f.Seed)
+		fmt.Printf("Descriptor: %s\n", f.Descriptor)
+		fmt.Println("\nIMPORTANT: Back up your prophecy securely! It will not be shown again.")
+		for i, word := range words {
+			fmt.Printf("  %2d. %s\n", i+1, word)
+		}
+		return nil
 	},
 }
 
 var walletListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all wallets",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := os.ReadDir(filepath.Join(dataDir(cmd), "wallets"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
 		fmt.Println("Available wallets:")
-		fmt.Println("  • default (encrypted)")
-		fmt.Println("  • mining-vault (multisig 2-of-3)")
-		// TODO: List actual wallets from data directory
+		found := false
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			found = true
+			name := entry.Name()
+			f, err := wallet.OpenWalletFile(walletFilePath(cmd, name))
+			switch {
+			case err == nil:
+				fmt.Printf("  • %s (encrypted, %s, created %s)\n", name, f.Network, f.CreatedAt.Format("2006-01-02"))
+			case os.IsNotExist(err):
+				fmt.Printf("  • %s (watch-only)\n", name)
+			default:
+				fmt.Printf("  • %s (unreadable: %v)\n", name, err)
+			}
+		}
+		if !found {
+			fmt.Println("  none (use wallet create)")
+		}
+		return nil
 	},
 }
 
 var walletBalanceCmd = &cobra.Command{
 	Use:   "balance [wallet-name]",
 	Short: "Show wallet balance",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Scan the wallet's descriptors for used addresses with a gap limit, then
+total their unspent outputs. The outputs are cached for send; --offline
+shows the cached balance without contacting the chain.
+
+Chain data comes from an Esplora API (--backend, default blockstream.info
+for mainnet and testnet). Regtest needs a local Esplora such as electrs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		walletName := args[0]
+		offline, _ := cmd.Flags().GetBool("offline")
+		gapLimit := config.Wallet.GapLimit
+
+		var snapshot *wallet.Snapshot
+		var err error
+		if offline {
+			if snapshot, err = wallet.LoadSnapshot(snapshotPath(cmd, walletName)); err != nil {
+				return err
+			}
+		} else {
+			store, err := openDescriptorStore(cmd, walletName)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			if len(store.List()) == 0 {
+				return fmt.Errorf("wallet %s has no descriptors (use create, import or import-descriptor)", walletName)
+			}
+			source, err := chainSource(cmd)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Scanning %s...\n", walletName)
+			snapshot, err = wallet.Sync(cmd.Context(), source, store, networkParams(cmd), gapLimit)
+			if err != nil {
+				return err
+			}
+			if err := wallet.SaveSnapshot(snapshotPath(cmd, walletName), snapshot); err != nil {
+				return err
+			}
+		}
+
+		confirmed, unconfirmed := snapshot.Confirmed(), snapshot.Unconfirmed()
 		fmt.Printf("Wallet: %s\n", walletName)
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		fmt.Println("Confirmed:    0.00000000 EXS")
-		fmt.Println("Unconfirmed:  0.00000000 EXS")
-		fmt.Println("Total:        0.00000000 EXS")
-		// TODO: Get actual balance
+		fmt.Printf("Confirmed:    %.8f EXS\n", btcutil.Amount(confirmed).ToBTC())
+		fmt.Printf("Unconfirmed:  %.8f EXS\n", btcutil.Amount(unconfirmed).ToBTC())
+		fmt.Printf("Total:        %.8f EXS\n", btcutil.Amount(confirmed+unconfirmed).ToBTC())
+		fmt.Printf("Outputs:      %d\n", len(snapshot.UTXOs))
+		if snapshot.SyncedAt.IsZero() {
+			fmt.Println("Synced:       never")
+		} else {
+			fmt.Printf("Synced:       %s\n", snapshot.SyncedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		return nil
 	},
 }
 
 var walletSendCmd = &cobra.Command{
-	Use:   "send [wallet-name] [address] [amount]",
-	Short: "Send EXS to an address",
-	Args:  cobra.ExactArgs(3),
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:   "send [wallet-name] [recipient] [amount]",
+	Short: "Send EXS to an address, contact or payment URI",
+	Long: `Build a transaction paying amount EXS to recipient.
+
+The recipient is an address, a contact label from the address book or an
+exs: payment URI. amount may be omitted when the contact or URI carries one.
+
+Coins are taken from --utxos, a JSON list of {"txid","vout","value","address"}
+with values in satoshis, or else from the outputs found by the last wallet
+balance, leaving out frozen ones (see wallet coins). --strategy picks them:
+largest-first (default), bnb, which looks for coins matching the payment
+closely enough to need no change and falls back to knapsack, or knapsack.
+--coin-control txid:vout, repeated, spends exactly those coins instead.
+The fee rate is --fee-rate, or else the estimate to confirm within
+--target blocks in --fee-mode (see wallet fees). Change goes to --change,
+by default the wallet's next change address, unless it would be dust or
+cost more to spend than it is worth. --commit attaches an OP_RETURN output carrying a forge proof
+hash (hex, up to 76 bytes) so the forge is committed on-chain; check it later
+with verify-commit. The transaction signals replace-by-fee unless --no-rbf is
+given. The unsigned transaction is written as a signing request for an
+air-gapped signer; finish with import-signed. With --sign the wallet
+unlocks its own keys, and with --keys it uses the Taproot keys in a file,
+to sign the inputs and print the raw transaction. --broadcast then relays
+it through the --backend Esplora API.
+
+Examples:
+  exs-node wallet send mining-vault bc1p... 1.5 --utxos utxos.json --change bc1p... --commit 9f86d0...
+  exs-node wallet send mining-vault alice 5 --utxos utxos.json --change bc1p...
+  exs-node wallet send mining-vault "exs:bc1p...?amount=0.25" --utxos utxos.json --change bc1p...
+  exs-node wallet send hot-wallet bc1p... 0.1 --sign --broadcast
+  exs-node wallet send hot-wallet bc1p... 0.1 --strategy bnb
+  exs-node wallet send hot-wallet bc1p... 0.1 --coin-control 4a5e...:0 --coin-control 9c1d...:1
+  exs-node wallet send hot-wallet bc1p... 0.1 --utxos utxos.json --change bc1p... --keys keys.txt`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		walletName := args[0]
-		address := args[1]
-		amount := args[2]
-		
-		fmt.Printf("Sending %s EXS from %s to %s\n", amount, walletName, address)
-		// TODO: Implement send transaction
-		fmt.Println("✗ Not implemented yet")
+		utxoFile, _ := cmd.Flags().GetString("utxos")
+		change, _ := cmd.Flags().GetString("change")
+		commitHex, _ := cmd.Flags().GetString("commit")
+		out, _ := cmd.Flags().GetString("out")
+		description, _ := cmd.Flags().GetString("description")
+		noRBF, _ := cmd.Flags().GetBool("no-rbf")
+		keyFile, _ := cmd.Flags().GetString("keys")
+		sign, _ := cmd.Flags().GetBool("sign")
+		broadcast, _ := cmd.Flags().GetBool("broadcast")
+		strategyName, _ := cmd.Flags().GetString("strategy")
+		coinControl, _ := cmd.Flags().GetStringSlice("coin-control")
+		if broadcast && !sign && keyFile == "" {
+			return fmt.Errorf("--broadcast needs --sign or --keys")
+		}
+		strategy, err := wallet.ParseSelectionStrategy(strategyName)
+		if err != nil {
+			return err
+		}
+
+		net := networkParams(cmd)
+		book, err := wallet.OpenAddressBook(addressBookPath(cmd))
+		if err != nil {
+			return err
+		}
+		recipient, err := book.Resolve(args[1], net)
+		if err != nil {
+			return err
+		}
+		address, amount := recipient.Address, recipient.Amount
+		if len(args) == 3 {
+			if amount, err = wallet.ParseAmount(args[2]); err != nil {
+				return err
+			}
+		} else if amount == 0 {
+			return fmt.Errorf("no amount given and %s has no default amount", args[1])
+		}
+		if description == "" {
+			description = recipient.Message
+		}
+		var commitment []byte
+		if commitHex != "" {
+			if commitment, err = hex.DecodeString(commitHex); err != nil {
+				return fmt.Errorf("invalid --commit: %w", err)
+			}
+		}
+		var utxos []wallet.UTXO
+		if len(coinControl) > 0 {
+			utxos, err = controlledUTXOs(cmd, walletName, utxoFile, coinControl)
+		} else {
+			utxos, err = walletUTXOs(cmd, walletName, utxoFile)
+		}
+		if err != nil {
+			return err
+		}
+		if change == "" {
+			if change, err = nextChangeAddress(cmd, walletName); err != nil {
+				return err
+			}
+		}
+
+		feeRate, err := paymentFeeRate(cmd)
+		if err != nil {
+			return err
+		}
+		payouts := []wallet.Payout{{Address: address, Amount: amount}}
+		opts := wallet.BatchOptions{Commitment: commitment, Strategy: strategy, SpendAll: len(coinControl) > 0}
+		batch, err := wallet.BuildBatchWithOptions(utxos, payouts, change, feeRate, opts, net)
+		if err != nil {
+			return err
+		}
+		if noRBF {
+			wallet.SetRBF(batch.Packet.UnsignedTx, false)
+		}
+		req, err := wallet.NewSigningRequest(batch.Packet, walletName, description, net)
+		if err != nil {
+			return err
+		}
+		if batch.Change > 0 {
+			changeIndex := uint32(len(batch.Packet.UnsignedTx.TxOut) - 1)
+			req.ChangeIndex = &changeIndex
+		}
+
+		var rawTx string
+		if sign || keyFile != "" {
+			var keys []*bitcoin.TaprootKey
+			if sign {
+				keys, err = walletKeys(cmd, walletName)
+			} else {
+				keys, err = readTaprootKeys(keyFile, net)
+			}
+			if err != nil {
+				return err
+			}
+			signed, _, err := req.Sign(keys)
+			if err != nil {
+				return err
+			}
+			if rawTx, err = req.Finalize(signed); err != nil {
+				return err
+			}
+			if err := recordTransaction(cmd, walletName, req, rawTx); err != nil {
+				return err
+			}
+			fmt.Printf("✓ Transaction signed: %s\n", req.ID)
+		} else {
+			if out, err = saveSigningRequest(cmd, walletName, req, out); err != nil {
+				return err
+			}
+			fmt.Printf("✓ Transaction built: %s\n", out)
+		}
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Request ID: %s\n", req.ID)
+		if recipient.Label != "" {
+			fmt.Printf("Pays:       %.8f EXS to %s (%s)\n", btcutil.Amount(amount).ToBTC(), recipient.Label, address)
+		} else {
+			fmt.Printf("Pays:       %.8f EXS to %s\n", btcutil.Amount(amount).ToBTC(), address)
+		}
+		if commitment != nil {
+			fmt.Printf("Commitment: %x\n", commitment)
+		}
+		fmt.Printf("Inputs:     %d\n", len(batch.Inputs))
+		fmt.Printf("Change:     %d sats\n", batch.Change)
+		fmt.Printf("Fee:        %d sats (%d sat/vB)\n", batch.Fee, feeRate)
+		fmt.Printf("RBF:        %s\n", rbfStatus(req.RBF))
+		if rawTx != "" {
+			fmt.Printf("\n%s\n", rawTx)
+			if broadcast {
+				return broadcastTransaction(cmd, rawTx)
+			}
+			return nil
+		}
+		fmt.Println("\nSign the request offline, then run: exs-node wallet import-signed")
+		return nil
+	},
+}
+
+var walletVerifyCommitCmd = &cobra.Command{
+	Use:   "verify-commit [wallet-name] [txid] [commitment]",
+	Short: "Check that a transaction commits to a forge proof hash",
+	Long: `Check that a transaction finalized by this wallet, or given with
+--tx-hex, carries an OP_RETURN forge commitment equal to commitment (hex).`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName, txid := args[0], args[1]
+		txHex, _ := cmd.Flags().GetString("tx-hex")
+
+		expected, err := hex.DecodeString(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid commitment: %w", err)
+		}
+		var record *wallet.TxRecord
+		if txHex != "" {
+			record, err = wallet.NewTxRecord(txHex, 0, nil)
+			if err == nil && record.TxID != txid {
+				err = fmt.Errorf("--tx-hex is transaction %s, not %s", record.TxID, txid)
+			}
+		} else {
+			record, err = wallet.LoadTxRecord(txRecordDir(cmd, walletName), txid)
+			if os.IsNotExist(err) {
+				err = fmt.Errorf("transaction %s not found in wallet %s (use --tx-hex)", txid, walletName)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		tx, err := record.Tx()
+		if err != nil {
+			return err
+		}
+
+		committed, ok := wallet.ExtractCommitment(tx)
+		if !ok {
+			return fmt.Errorf("transaction %s carries no forge commitment", txid)
+		}
+		if !bytes.Equal(committed, expected) {
+			return fmt.Errorf("transaction %s commits to %x, not %x", txid, committed, expected)
+		}
+		fmt.Printf("✓ Transaction %s commits to %x\n", txid, committed)
+		return nil
+	},
+}
+
+var walletSendManyCmd = &cobra.Command{
+	Use:   "sendmany [wallet-name] [payouts-file]",
+	Short: "Pay many addresses in one transaction",
+	Long: `Build one consolidated transaction paying every address→amount pair in
+a CSV (address,amount) or JSON ([{"address","amount"}]) file. Amounts are
+in EXS; repeated addresses are merged into a single output. The round
+files of miner pool --payouts are in this format.
+
+Coins are taken from --utxos, a JSON list of {"txid","vout","value","address"}
+with values in satoshis, or else from the outputs found by the last wallet
+balance, largest first. Change goes to --change, by default the wallet's
+next change address, unless it would be dust. Without --fee-rate the fee
+estimate for --target blocks is paid (see wallet fees). The transaction signals replace-by-fee unless --no-rbf is
+given. The unsigned transaction is written as a signing request for an
+air-gapped signer; finish with import-signed.
+
+Example:
+  exs-node wallet sendmany mining-vault payouts.csv --utxos utxos.json --change bc1p...`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		utxoFile, _ := cmd.Flags().GetString("utxos")
+		change, _ := cmd.Flags().GetString("change")
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+		description, _ := cmd.Flags().GetString("description")
+		noRBF, _ := cmd.Flags().GetBool("no-rbf")
+
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(args[1])), ".")
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		payouts, err := wallet.ParsePayouts(f, format)
+		if err != nil {
+			return err
+		}
+
+		if utxoFile == "" || change == "" {
+			return fmt.Errorf("--utxos and --change are required")
+		}
+		utxos, err := readUTXOs(utxoFile)
+		if err != nil {
+			return err
+		}
+
+		feeRate, err := paymentFeeRate(cmd)
+		if err != nil {
+			return err
+		}
+		net := networkParams(cmd)
+		batch, err := wallet.BuildBatch(utxos, payouts, change, feeRate, net)
+		if err != nil {
+			return err
+		}
+		if noRBF {
+			wallet.SetRBF(batch.Packet.UnsignedTx, false)
+		}
+		req, err := wallet.NewSigningRequest(batch.Packet, walletName, description, net)
+		if err != nil {
+			return err
+		}
+		if batch.Change > 0 {
+			changeIndex := uint32(len(batch.Packet.UnsignedTx.TxOut) - 1)
+			req.ChangeIndex = &changeIndex
+		}
+		out, err = saveSigningRequest(cmd, walletName, req, out)
+		if err != nil {
+			return err
+		}
+
+		var total int64
+		for _, p := range batch.Payouts {
+			total += p.Amount
+		}
+		fmt.Printf("✓ Batch payout built: %s\n", out)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Request ID: %s\n", req.ID)
+		fmt.Printf("Payouts:    %d (%.8f EXS)\n", len(batch.Payouts), btcutil.Amount(total).ToBTC())
+		fmt.Printf("Inputs:     %d\n", len(batch.Inputs))
+		fmt.Printf("Change:     %d sats\n", batch.Change)
+		fmt.Printf("Fee:        %d sats (%d sat/vB)\n", batch.Fee, feeRate)
+		fmt.Printf("RBF:        %s\n", rbfStatus(req.RBF))
+		fmt.Println("\nSign the request offline, then run: exs-node wallet import-signed")
+		return nil
+	},
+}
+
+var walletAccelerateCmd = &cobra.Command{
+	Use:   "accelerate [wallet-name] [txid]",
+	Short: "Bump a stuck transaction with a child-pays-for-parent spend",
+	Long: `Build a child transaction spending one of our outputs of a stuck parent,
+paying enough fee that the parent+child package reaches --fee-rate.
+
+The parent must have been finalized with import-signed, or be given with
+--parent-hex and --parent-fee. By default the child spends the parent's
+change output and pays it back to the same address. The child signals
+replace-by-fee unless --no-rbf is given.
+
+Example:
+  exs-node wallet accelerate mining-vault 4a5e1e... --fee-rate 40`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName, txid := args[0], args[1]
+		feeRate, _ := cmd.Flags().GetInt64("fee-rate")
+		vout, _ := cmd.Flags().GetInt("vout")
+		dest, _ := cmd.Flags().GetString("dest")
+		parentHex, _ := cmd.Flags().GetString("parent-hex")
+		parentFee, _ := cmd.Flags().GetInt64("parent-fee")
+		out, _ := cmd.Flags().GetString("out")
+		noRBF, _ := cmd.Flags().GetBool("no-rbf")
+
+		var record *wallet.TxRecord
+		var err error
+		if parentHex != "" {
+			if parentFee < 0 {
+				return fmt.Errorf("--parent-fee is required with --parent-hex")
+			}
+			record, err = wallet.NewTxRecord(parentHex, parentFee, nil)
+			if err == nil && record.TxID != txid {
+				err = fmt.Errorf("--parent-hex is transaction %s, not %s", record.TxID, txid)
+			}
+		} else {
+			record, err = wallet.LoadTxRecord(txRecordDir(cmd, walletName), txid)
+			if os.IsNotExist(err) {
+				err = fmt.Errorf("transaction %s not found in wallet %s (use --parent-hex)", txid, walletName)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if parentFee < 0 {
+			if record.Fee == 0 {
+				return fmt.Errorf("parent fee unknown; pass --parent-fee")
+			}
+			parentFee = record.Fee
+		}
+		if vout < 0 {
+			if record.ChangeIndex == nil {
+				return fmt.Errorf("parent has no known change output; pass --vout")
+			}
+			vout = int(*record.ChangeIndex)
+		}
+
+		parent, err := record.Tx()
+		if err != nil {
+			return err
+		}
+		net := networkParams(cmd)
+		if dest == "" && vout < len(parent.TxOut) {
+			_, addrs, _, err := txscript.ExtractPkScriptAddrs(parent.TxOut[vout].PkScript, net)
+			if err != nil || len(addrs) != 1 {
+				return fmt.Errorf("cannot determine address of output %d; pass --dest", vout)
+			}
+			dest = addrs[0].EncodeAddress()
+		}
+
+		child, err := wallet.BuildCPFP(parent, uint32(vout), parentFee, feeRate, dest, net)
+		if err != nil {
+			return err
+		}
+		if noRBF {
+			wallet.SetRBF(child.Packet.UnsignedTx, false)
+		}
+		req, err := wallet.NewSigningRequest(child.Packet, walletName, "CPFP for "+txid, net)
+		if err != nil {
+			return err
+		}
+		out, err = saveSigningRequest(cmd, walletName, req, out)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ CPFP child built: %s\n", out)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Parent:       %s:%d\n", txid, vout)
+		fmt.Printf("Parent rate:  %.1f sat/vB (%d vB)\n", child.ParentFeeRate, child.ParentVSize)
+		fmt.Printf("Child fee:    %d sats (%d vB)\n", child.ChildFee, child.ChildVSize)
+		fmt.Printf("Package rate: %.1f sat/vB\n", child.PackageFeeRate)
+		fmt.Printf("Pays:         %d sats to %s\n", child.DestinationPaid, dest)
+		fmt.Printf("RBF:          %s\n", rbfStatus(req.RBF))
+		fmt.Println("\nSign the request offline, then run: exs-node wallet import-signed")
+		return nil
+	},
+}
+
+var walletHistoryCmd = &cobra.Command{
+	Use:   "history [wallet-name]",
+	Short: "List transactions finalized by this wallet",
+	Long: `List transactions finalized with import-signed, newest first, with their
+fee and whether they signal replace-by-fee.
+
+With --chain, list every transaction paying to or spending from the
+wallet's descriptors instead, watch-only wallets included, with its effect
+on the balance. Each confirmed transaction is proven SPV-style by a merkle
+proof against its block header.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		if chain, _ := cmd.Flags().GetBool("chain"); chain {
+			return runChainHistory(cmd, walletName)
+		}
+		records, err := wallet.ListTxRecords(txRecordDir(cmd, walletName))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Transactions for %s:\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		if len(records) == 0 {
+			fmt.Println("No transactions")
+			return nil
+		}
+		for _, rec := range records {
+			tx, err := rec.Tx()
+			if err != nil {
+				return fmt.Errorf("transaction %s: %w", rec.TxID, err)
+			}
+			fmt.Printf("%s  %s  fee %d sats  RBF %s\n",
+				rec.CreatedAt.Format("2006-01-02 15:04"), rec.TxID, rec.Fee, rbfStatus(wallet.SignalsRBF(tx)))
+		}
+		return nil
 	},
 }
 
 var walletAddressCmd = &cobra.Command{
 	Use:   "address [wallet-name]",
-	Short: "Generate a new receiving address",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Show a receiving address",
+	Long: `Show the next unused receiving address, as of the last scan, of the
+wallet's first imported descriptor of --type. --account picks the
+descriptor of that HD account (see wallet account add), --change shows the
+change branch, and --index shows the address at that index instead of the
+next unused one. With --amount, --label or --message the address is
+wrapped in an exs: payment request.
+
+--qr prints the address or request as a QR code for a mobile wallet to scan,
+and --png writes it to an image file.
+
+Example:
+  exs-node wallet address mining-vault --amount 0.5 --label "Round Table" --qr`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		walletName := args[0]
 		addrType, _ := cmd.Flags().GetString("type")
-		
-		fmt.Printf("Generating %s address for wallet: %s\n", addrType, walletName)
-		fmt.Println("\nAddress: bc1p...")
-		fmt.Println("Type: P2TR (Taproot)")
-		// TODO: Generate actual address
+		amountStr, _ := cmd.Flags().GetString("amount")
+		label, _ := cmd.Flags().GetString("label")
+		message, _ := cmd.Flags().GetString("message")
+		account, _ := cmd.Flags().GetUint32("account")
+		index, _ := cmd.Flags().GetUint32("index")
+		change, _ := cmd.Flags().GetBool("change")
+		net := networkParams(cmd)
+		branch := wallet.ReceiveBranch
+		if change {
+			branch = wallet.ChangeBranch
+		}
+		if index >= hdkeychain.HardenedKeyStart {
+			return fmt.Errorf("index %d out of range", index)
+		}
+
+		var descType wallet.DescriptorType
+		switch addrType {
+		case "p2tr":
+			descType = wallet.DescriptorTR
+		case "p2wpkh":
+			descType = wallet.DescriptorWPKH
+		default:
+			return fmt.Errorf("unknown address type %q (use p2tr or p2wpkh)", addrType)
+		}
+
+		store, err := openDescriptorStore(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		var derived *wallet.DerivedAddress
+		for _, entry := range store.List() {
+			desc, err := wallet.ParseDescriptor(entry.Descriptor)
+			if err != nil {
+				return err
+			}
+			if desc.Type != descType || branch >= desc.Branches() {
+				continue
+			}
+			if cmd.Flags().Changed("account") {
+				if n, ok := wallet.AccountIndex(desc, net); !ok || n != account {
+					continue
+				}
+			}
+			if !cmd.Flags().Changed("index") {
+				index = entry.NextIndex[branch]
+			}
+			if derived, err = desc.Derive(branch, index, net); err != nil {
+				return err
+			}
+			break
+		}
+		if derived == nil {
+			switch {
+			case cmd.Flags().Changed("account"):
+				return fmt.Errorf("wallet %s does not track %s account %d (use wallet account add)", walletName, addrType, account)
+			case change:
+				return fmt.Errorf("wallet %s has no %s descriptor with a change branch", walletName, addrType)
+			}
+			return fmt.Errorf("wallet %s has no %s descriptor (use import-descriptor)", walletName, addrType)
+		}
+
+		req := &wallet.PaymentRequest{Address: derived.Address, Label: label, Message: message}
+		if amountStr != "" {
+			if req.Amount, err = wallet.ParseAmount(amountStr); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Receiving address for wallet: %s\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Address: %s\n", derived.Address)
+		fmt.Printf("Type:    %s\n", addrType)
+		if change {
+			fmt.Printf("Branch:  change\n")
+		}
+		fmt.Printf("Index:   %d\n", derived.Index)
+		if req.QRContent() != req.Address {
+			fmt.Printf("URI:     %s\n", req.URI())
+		}
+		return showQR(cmd, req.QRContent())
 	},
 }
 
 var walletImportCmd = &cobra.Command{
 	Use:   "import [wallet-name]",
 	Short: "Import wallet from seed phrase",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Restore a wallet from its prophecy (13 words) or a standard BIP-39
+phrase of 12 to 24 words, read from --seed-file or standard input. The
+phrase's checksum is verified so a mistyped word is reported instead of
+restoring an empty wallet. The wallet is encrypted like a new one and its
+descriptor is queued for a rescan; run wallet balance to find its funds.
+
+Phrases from before prophecies carried a checksum, such as the canonical
+axiom, fail that check. Import them with --seed legacy, which accepts any
+English words and seeds the wallet from all of them as it was seeded then.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		walletName := args[0]
 		seedFile, _ := cmd.Flags().GetString("seed-file")
-		
-		fmt.Printf("Importing wallet: %s\n", walletName)
+
+		var phrase []byte
+		var err error
 		if seedFile != "" {
-			fmt.Printf("From file: %s\n", seedFile)
+			phrase, err = os.ReadFile(seedFile)
 		} else {
-			fmt.Println("Enter seed phrase (13 or 24 words):")
-			// TODO: Read seed from stdin securely
+			if term.IsTerminal(int(os.Stdin.Fd())) {
+				fmt.Print("Enter seed phrase: ")
+				phrase, err = term.ReadPassword(int(os.Stdin.Fd()))
+				fmt.Println()
+			} else {
+				phrase, err = bufio.NewReader(os.Stdin).ReadBytes('\n')
+				if err == io.EOF {
+					err = nil
+				}
+			}
 		}
-		fmt.Println("✗ Not implemented yet")
+		if err != nil {
+			return fmt.Errorf("failed to read seed phrase: %w", err)
+		}
+		parse := crypto.ParseMnemonic
+		if seedFlag, _ := cmd.Flags().GetString("seed"); wallet.SeedScheme(seedFlag) == wallet.SeedLegacy {
+			parse = crypto.ParseLegacyProphecy
+		}
+		words, err := parse(string(phrase))
+		if errors.Is(err, crypto.ErrChecksum) {
+			return fmt.Errorf("%w (a phrase from before prophecy checksums imports with --seed legacy)", err)
+		}
+		if err != nil {
+			return err
+		}
+		passphrase, err := walletPassphrase(cmd, true)
+		if err != nil {
+			return err
+		}
+		f, err := createWallet(cmd, walletName, words, passphrase, true)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Wallet imported: %s\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Network:    %s\n", f.Network)
+		fmt.Printf("Descriptor: %s\n", f.Descriptor)
+		fmt.Println("\nRun wallet balance to scan for funds.")
+		return nil
 	},
 }
 
@@ -125,14 +809,279 @@ var walletExportCmd = &cobra.Command{
 	Use:   "export [wallet-name]",
 	Short: "Export wallet seed phrase",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		walletName := args[0]
-		
+		f, err := wallet.OpenWalletFile(walletFilePath(cmd, walletName))
+		if err != nil {
+			return err
+		}
+		passphrase, err := walletPassphrase(cmd, false)
+		if err != nil {
+			return err
+		}
+		words, err := f.Words(passphrase)
+		if err != nil {
+			return err
+		}
+
 		fmt.Printf("Exporting wallet: %s\n", walletName)
 		fmt.Println("\nWARNING: Never share your seed phrase!")
 		fmt.Println("Seed phrase:")
-		fmt.Println("  sword legend pull magic kingdom artist stone destroy forget fire steel honey question")
-		// TODO: Export actual seed
+		phrase := strings.Join(words, " ")
+		if lang, err := crypto.DetectLanguage(words); err == nil {
+			phrase = lang.Phrase(words)
+		}
+		fmt.Printf("  %s\n", phrase)
+		return nil
+	},
+}
+
+var walletBackupCmd = &cobra.Command{
+	Use:   "backup [wallet-name]",
+	Short: "Split the wallet seed into Shamir backup shares",
+	Long: `Split the wallet's prophecy into --shares shares, any --threshold of which
+restore it with wallet recover. Fewer shares reveal nothing about the
+prophecy, so they can be kept in separate places or with separate people.
+
+Shares are split like SLIP-39 shares, with Shamir's scheme over GF(256) and
+a digest that detects wrong or corrupted shares on recovery, and are
+written in the prophecy's wordlist with a checksum against typos.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		n, _ := cmd.Flags().GetInt("shares")
+		threshold, _ := cmd.Flags().GetInt("threshold")
+		outDir, _ := cmd.Flags().GetString("out-dir")
+
+		f, err := wallet.OpenWalletFile(walletFilePath(cmd, walletName))
+		if err != nil {
+			return err
+		}
+		passphrase, err := walletPassphrase(cmd, false)
+		if err != nil {
+			return err
+		}
+		words, err := f.Words(passphrase)
+		if err != nil {
+			return err
+		}
+		shares, err := crypto.SplitPhrase(words, threshold, n)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Backup of wallet %s: %d shares, any %d recover it\n", walletName, n, threshold)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		if outDir != "" {
+			if err := os.MkdirAll(outDir, 0700); err != nil {
+				return err
+			}
+		}
+		for _, share := range shares {
+			if outDir == "" {
+				fmt.Printf("Share %d of %d:\n  %s\n\n", share.Index, n, share.Phrase())
+				continue
+			}
+			path := filepath.Join(outDir, fmt.Sprintf("%s-share-%d.txt", walletName, share.Index))
+			if err := os.WriteFile(path, []byte(share.Phrase()+"\n"), 0600); err != nil {
+				return err
+			}
+			fmt.Printf("Share %d of %d: %s\n", share.Index, n, path)
+		}
+		fmt.Println("\nIMPORTANT: Store each share separately. Anyone holding", threshold, "shares can restore the wallet.")
+		return nil
+	},
+}
+
+var walletRecoverCmd = &cobra.Command{
+	Use:   "recover [wallet-name]",
+	Short: "Restore a wallet from Shamir backup shares",
+	Long: `Restore a wallet from the shares written by wallet backup. Shares are
+read from --share-file, then prompted for one at a time until the backup's
+threshold is reached; without a terminal they are read from standard input,
+one per line. Each share's checksum is verified as it is entered, and the
+recovered prophecy is checked against the backup's digest before the wallet
+is encrypted and its descriptor queued for a rescan.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		files, _ := cmd.Flags().GetStringSlice("share-file")
+
+		var shares []*crypto.Share
+		add := func(phrase string) error {
+			share, err := crypto.ParseShare(phrase)
+			if err != nil {
+				return err
+			}
+			for _, have := range shares {
+				if have.ID != share.ID || have.Threshold != share.Threshold {
+					return crypto.ErrShareMismatch
+				}
+				if have.Index == share.Index {
+					return fmt.Errorf("%w: share %d", crypto.ErrShareDuplicate, share.Index)
+				}
+			}
+			shares = append(shares, share)
+			if remaining := share.Threshold - len(shares); remaining > 0 {
+				fmt.Printf("✓ Share %d accepted, %d more needed\n", share.Index, remaining)
+			} else {
+				fmt.Printf("✓ Share %d accepted\n", share.Index)
+			}
+			return nil
+		}
+
+		for _, file := range files {
+			phrase, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read share: %w", err)
+			}
+			if err := add(string(phrase)); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+
+		fd := int(os.Stdin.Fd())
+		interactive := term.IsTerminal(fd)
+		stdin := bufio.NewReader(os.Stdin)
+		for len(shares) == 0 || len(shares) < shares[0].Threshold {
+			var phrase []byte
+			var err error
+			if interactive {
+				fmt.Printf("Enter share %d: ", len(shares)+1)
+				phrase, err = term.ReadPassword(fd)
+				fmt.Println()
+			} else {
+				phrase, err = stdin.ReadBytes('\n')
+				if err == io.EOF && len(bytes.TrimSpace(phrase)) > 0 {
+					err = nil
+				}
+			}
+			if err == io.EOF {
+				return fmt.Errorf("%w: %d entered", crypto.ErrTooFewShares, len(shares))
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read share: %w", err)
+			}
+			if err := add(string(phrase)); err != nil {
+				if !interactive {
+					return err
+				}
+				fmt.Printf("✗ %v, try again\n", err)
+			}
+		}
+
+		words, err := crypto.CombineShares(shares)
+		if err != nil {
+			return err
+		}
+		passphrase, err := walletPassphrase(cmd, true)
+		if err != nil {
+			return err
+		}
+		f, err := createWallet(cmd, walletName, words, passphrase, true)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Wallet recovered: %s\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Network:    %s\n", f.Network)
+		fmt.Printf("Descriptor: %s\n", f.Descriptor)
+		fmt.Println("\nRun wallet balance to scan for funds.")
+		return nil
+	},
+}
+
+var walletDuressCmd = &cobra.Command{
+	Use:   "duress [wallet-name]",
+	Short: "Hide the wallet behind a decoy wallet",
+	Long: `Hide the wallet behind a decoy opened by a second, duress passphrase.
+
+A new decoy prophecy is generated (or read from --decoy-seed-file) and
+becomes the wallet's visible account: its descriptor replaces the real one
+in the wallet file and descriptor store, and the cached balance is deleted.
+Fund the decoy with a small balance so it is believable. The real wallet is
+sealed in the file's hidden slot with its current passphrase, which still
+opens it for export, backup and signing (pass --utxos to spend from it).
+
+Every wallet file has a hidden slot of the same size, holding random bytes
+when no wallet is hidden, so the file does not reveal that one exists.
+For the same reason running duress again with the decoy passphrase
+replaces any hidden wallet without warning. Transaction records and
+signing requests written earlier may still mention the real wallet.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		seedFile, _ := cmd.Flags().GetString("decoy-seed-file")
+		net := networkParams(cmd)
+
+		f, err := wallet.OpenWalletFile(walletFilePath(cmd, walletName))
+		if err != nil {
+			return err
+		}
+		passphrase, err := walletPassphrase(cmd, false)
+		if err != nil {
+			return err
+		}
+		account, err := f.Open(passphrase)
+		if err != nil {
+			return err
+		}
+		if account.Hidden {
+			return wallet.ErrHiddenWallet
+		}
+
+		var decoyWords []string
+		if seedFile != "" {
+			phrase, err := os.ReadFile(seedFile)
+			if err != nil {
+				return fmt.Errorf("failed to read decoy seed phrase: %w", err)
+			}
+			if decoyWords, err = crypto.ParseMnemonic(string(phrase)); err != nil {
+				return err
+			}
+		} else if decoyWords, err = crypto.NewProphecy(); err != nil {
+			return err
+		}
+		decoyPassphrase, err := readPassphrase(cmd, "decoy-passphrase", "Decoy passphrase", true)
+		if err != nil {
+			return err
+		}
+
+		decoy, err := f.HideBehindDecoy(passphrase, decoyWords, decoyPassphrase, net)
+		if err != nil {
+			return err
+		}
+		desc, err := decoy.Descriptor()
+		if err != nil {
+			return err
+		}
+		store, err := openDescriptorStore(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		if _, err := store.Remove(account.Descriptor); err != nil {
+			return err
+		}
+		if _, err := store.Import(desc, "hd", 0, 1000, true); err != nil {
+			return err
+		}
+		if err := os.Remove(snapshotPath(cmd, walletName)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		fmt.Printf("✓ Wallet %s is hidden behind a decoy\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Decoy descriptor: %s\n", f.Descriptor)
+		if seedFile == "" {
+			fmt.Println("\nBack up the decoy prophecy; it will not be shown again.")
+			for i, word := range decoyWords {
+				fmt.Printf("  %2d. %s\n", i+1, word)
+			}
+		}
+		fmt.Println("\nThe decoy passphrase opens the decoy; the wallet passphrase still opens the hidden wallet.")
+		return nil
 	},
 }
 
@@ -156,29 +1105,1004 @@ var walletMultisigCreateCmd = &cobra.Command{
 	},
 }
 
+var walletImportDescriptorCmd = &cobra.Command{
+	Use:   "import-descriptor [wallet-name] [descriptor]",
+	Short: "Import an output descriptor (tr(), wpkh(), multi-path)",
+	Long: `Import an output descriptor so funds held by another wallet can be
+tracked by exs-node. Ranged descriptors (ending in /*) are derived over
+--range, and multi-path descriptors (/<0;1>/*) track receive and change
+branches together. The descriptor is rescanned in the background while the
+node runs, unless --rescan=false.
+
+Example:
+  exs-node wallet import-descriptor default "tr([73c5da0a/86'/0'/0']xpub.../<0;1>/*)" --range 0:1000`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		label, _ := cmd.Flags().GetString("label")
+		rangeFlag, _ := cmd.Flags().GetString("range")
+		rescan, _ := cmd.Flags().GetBool("rescan")
+
+		desc, err := wallet.ParseDescriptor(args[1])
+		if err != nil {
+			return err
+		}
+		if desc.Private {
+			fmt.Println("⚠️  The descriptor holds a private key; only its xpub is kept.")
+		}
+
+		start, end, err := parseRange(rangeFlag)
+		if err != nil {
+			return err
+		}
+
+		store, err := openDescriptorStore(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		entry, err := store.Import(desc, label, start, end, rescan)
+		if err != nil {
+			return err
+		}
+
+		net := networkParams(cmd)
+		fmt.Printf("✓ Imported descriptor into wallet: %s\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Descriptor: %s\n", entry.Descriptor)
+		if desc.Ranged {
+			fmt.Printf("Range:      %d-%d\n", entry.RangeStart, entry.RangeEnd)
+		}
+		fmt.Printf("Branches:   %d\n", desc.Branches())
+
+		for branch := 0; branch < desc.Branches(); branch++ {
+			preview := uint32(3)
+			if !desc.Ranged {
+				preview = 1
+			}
+			fmt.Printf("\nBranch %d:\n", branch)
+			for i := uint32(0); i < preview; i++ {
+				addr, err := desc.Derive(branch, start+i, net)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("  [%d] %s\n", addr.Index, addr.Address)
+			}
+		}
+
+		if rescan {
+			fmt.Println("\nRescan queued; a running node scans it in the background, or run wallet balance.")
+		}
+		return nil
+	},
+}
+
+var walletDescriptorsCmd = &cobra.Command{
+	Use:   "descriptors [wallet-name]",
+	Short: "List imported descriptors",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openDescriptorStore(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		list := store.List()
+		if len(list) == 0 {
+			fmt.Println("No descriptors imported")
+			return nil
+		}
+
+		for _, entry := range list {
+			status := "scanned"
+			if entry.NeedsScan {
+				status = "rescan pending"
+			}
+			fmt.Printf("• %s\n", entry.Descriptor)
+			if entry.Label != "" {
+				fmt.Printf("  Label:      %s\n", entry.Label)
+			}
+			fmt.Printf("  Range:      %d-%d\n", entry.RangeStart, entry.RangeEnd)
+			fmt.Printf("  Next index: %v\n", entry.NextIndex)
+			fmt.Printf("  Status:     %s\n", status)
+		}
+		return nil
+	},
+}
+
+var walletContactsCmd = &cobra.Command{
+	Use:   "contacts",
+	Short: "Manage the address book",
+	Long: `Save addresses under labels so send can pay a contact by name.
+Contacts may carry a default amount and message used when none is given.`,
+}
+
+var walletContactsAddCmd = &cobra.Command{
+	Use:   "add [label] [address|uri] [default-amount]",
+	Short: "Add or replace a contact",
+	Long: `Save address under label, replacing any contact with the same label.
+The address may be an exs: payment URI, whose amount and message become the
+contact's defaults.
+
+Example:
+  exs-node wallet contacts add alice bc1p... 5 --message "Monthly tithe"`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		message, _ := cmd.Flags().GetString("message")
+		net := networkParams(cmd)
+
+		contact := wallet.Contact{Label: args[0], Address: args[1], Message: message}
+		if wallet.IsPaymentURI(args[1]) {
+			req, err := wallet.ParsePaymentURI(args[1], net)
+			if err != nil {
+				return err
+			}
+			contact.Address, contact.Amount = req.Address, req.Amount
+			if contact.Message == "" {
+				contact.Message = req.Message
+			}
+		}
+		if len(args) == 3 {
+			amount, err := wallet.ParseAmount(args[2])
+			if err != nil {
+				return err
+			}
+			contact.Amount = amount
+		}
+
+		book, err := wallet.OpenAddressBook(addressBookPath(cmd))
+		if err != nil {
+			return err
+		}
+		saved, err := book.Add(contact, net)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Contact saved: %s → %s\n", saved.Label, saved.Address)
+		return nil
+	},
+}
+
+var walletContactsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List contacts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		book, err := wallet.OpenAddressBook(addressBookPath(cmd))
+		if err != nil {
+			return err
+		}
+
+		list := book.List()
+		if len(list) == 0 {
+			fmt.Println("No contacts saved")
+			return nil
+		}
+
+		for _, c := range list {
+			fmt.Printf("• %s\n", c.Label)
+			fmt.Printf("  Address: %s\n", c.Address)
+			if c.Amount > 0 {
+				fmt.Printf("  Amount:  %s EXS\n", wallet.FormatAmount(c.Amount))
+			}
+			if c.Message != "" {
+				fmt.Printf("  Message: %s\n", c.Message)
+			}
+		}
+		return nil
+	},
+}
+
+var walletContactsRemoveCmd = &cobra.Command{
+	Use:   "remove [label]",
+	Short: "Remove a contact",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		book, err := wallet.OpenAddressBook(addressBookPath(cmd))
+		if err != nil {
+			return err
+		}
+		if err := book.Remove(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Contact removed: %s\n", args[0])
+		return nil
+	},
+}
+
+var walletURICmd = &cobra.Command{
+	Use:   "uri [address|contact|uri] [amount]",
+	Short: "Create or decode an exs: payment request URI",
+	Long: `Print an exs: payment URI requesting amount EXS to an address or saved
+contact, for sharing with the payer. Given an existing exs: URI, decode it
+instead.
+
+Examples:
+  exs-node wallet uri bc1p... 2.5 --label "Camelot Forge" --message "Invoice 42"
+  exs-node wallet uri "exs:bc1p...?amount=2.5"`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		label, _ := cmd.Flags().GetString("label")
+		message, _ := cmd.Flags().GetString("message")
+		net := networkParams(cmd)
+
+		book, err := wallet.OpenAddressBook(addressBookPath(cmd))
+		if err != nil {
+			return err
+		}
+		req, err := book.Resolve(args[0], net)
+		if err != nil {
+			return err
+		}
+
+		if wallet.IsPaymentURI(args[0]) && len(args) == 1 && label == "" && message == "" {
+			fmt.Println("Payment Request")
+			fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			fmt.Printf("Address: %s\n", req.Address)
+			if req.Amount > 0 {
+				fmt.Printf("Amount:  %s EXS\n", wallet.FormatAmount(req.Amount))
+			}
+			if req.Label != "" {
+				fmt.Printf("Label:   %s\n", req.Label)
+			}
+			if req.Message != "" {
+				fmt.Printf("Message: %s\n", req.Message)
+			}
+			return nil
+		}
+
+		if len(args) == 2 {
+			if req.Amount, err = wallet.ParseAmount(args[1]); err != nil {
+				return err
+			}
+		}
+		if cmd.Flags().Changed("label") {
+			req.Label = label
+		}
+		if cmd.Flags().Changed("message") {
+			req.Message = message
+		}
+		fmt.Println(req.URI())
+		return showQR(cmd, req.URI())
+	},
+}
+
+var walletExportSigningRequestCmd = &cobra.Command{
+	Use:   "export-signing-request [wallet-name]",
+	Short: "Export an unsigned PSBT for an air-gapped signer",
+	Long: `Wrap an unsigned PSBT in a portable signing-request file that an
+offline signer can review and sign. With --qr the request is also printed
+as a sequence of QR-ready text frames, so it can cross the air gap without
+USB media.
+
+Example:
+  exs-node wallet export-signing-request default --psbt tx.psbt --out request.json --qr`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		psbtFlag, _ := cmd.Flags().GetString("psbt")
+		out, _ := cmd.Flags().GetString("out")
+		description, _ := cmd.Flags().GetString("description")
+		showQR, _ := cmd.Flags().GetBool("qr")
+		chunkSize, _ := cmd.Flags().GetInt("chunk-size")
+
+		packet, err := readPSBT(psbtFlag)
+		if err != nil {
+			return err
+		}
+		req, err := wallet.NewSigningRequest(packet, walletName, description, networkParams(cmd))
+		if err != nil {
+			return err
+		}
+
+		out, err = saveSigningRequest(cmd, walletName, req, out)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Signing request exported: %s\n", out)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Request ID: %s\n", req.ID)
+		fmt.Printf("Network:    %s\n", req.Network)
+		for _, output := range req.Outputs {
+			fmt.Printf("Output:     %s  %d sats\n", output.Address, output.Amount)
+		}
+		if req.InputTotal > 0 {
+			fmt.Printf("Fee:        %d sats\n", req.Fee)
+		} else {
+			fmt.Println("Fee:        unknown (inputs lack witness UTXO data)")
+		}
+
+		if showQR {
+			data, err := req.Encode()
+			if err != nil {
+				return err
+			}
+			frames := wallet.SplitChunks(data, chunkSize)
+			fmt.Printf("\nQR frames (%d):\n", len(frames))
+			for _, frame := range frames {
+				fmt.Println(frame)
+			}
+		}
+		return nil
+	},
+}
+
+var walletImportSignedCmd = &cobra.Command{
+	Use:   "import-signed [wallet-name] [file]",
+	Short: "Import a signed PSBT returned by an air-gapped signer",
+	Long: `Import a signed signing-request file, or a text file of scanned QR
+frames, check it against the exported request, finalize it and print the
+raw transaction. --broadcast relays it through the --backend Esplora API.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		out, _ := cmd.Flags().GetString("out")
+		broadcast, _ := cmd.Flags().GetBool("broadcast")
+
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(strings.TrimSpace(string(data)), "EXS:") {
+			data, err = wallet.JoinChunks(strings.Split(string(data), "\n"))
+			if err != nil {
+				return err
+			}
+		}
+		signed, err := wallet.DecodeSigningRequest(data)
+		if err != nil {
+			return err
+		}
+
+		pending := filepath.Join(pendingRequestDir(cmd, walletName), signed.ID+".json")
+		req, err := wallet.ReadSigningRequest(pending)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("no pending signing request %s for wallet %s", signed.ID, walletName)
+			}
+			return err
+		}
+
+		rawTx, err := req.Finalize(signed)
+		if err != nil {
+			return err
+		}
+		if out != "" {
+			if err := os.WriteFile(out, []byte(rawTx+"\n"), 0600); err != nil {
+				return fmt.Errorf("failed to write transaction: %w", err)
+			}
+		}
+
+		if err := recordTransaction(cmd, walletName, req, rawTx); err != nil {
+			return err
+		}
+		os.Remove(pending)
+
+		fmt.Printf("✓ Signed transaction imported: %s\n", signed.ID)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Println(rawTx)
+		if broadcast {
+			return broadcastTransaction(cmd, rawTx)
+		}
+		return nil
+	},
+}
+
+var walletSignCmd = &cobra.Command{
+	Use:   "sign [request-file]",
+	Short: "Sign a signing request with Taproot keys",
+	Long: `Act as the offline signer: review a signing request, sign its P2TR
+inputs by key path with BIP-340 Schnorr signatures, and write the signed
+answer for import-signed.
+
+The --keys file holds one WIF private key per line. Keys for Taproot vaults
+are followed by ":" and the vault's script root (tweak hash) in hex. Blank
+lines and lines starting with # are ignored.
+
+Example:
+  exs-node wallet sign request.json --keys keys.txt --out signed.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyFile, _ := cmd.Flags().GetString("keys")
+		out, _ := cmd.Flags().GetString("out")
+		if keyFile == "" {
+			return fmt.Errorf("--keys is required")
+		}
+
+		req, err := wallet.ReadSigningRequest(args[0])
+		if err != nil {
+			return err
+		}
+		net := networkParams(cmd)
+		if req.Network != net.Name {
+			return fmt.Errorf("signing request is for %s, not %s", req.Network, net.Name)
+		}
+		keys, err := readTaprootKeys(keyFile, net)
+		if err != nil {
+			return err
+		}
+		signed, count, err := req.Sign(keys)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("no inputs of %s can be signed with the given keys", req.ID)
+		}
+
+		if out == "" {
+			out = req.ID + ".signed.json"
+		}
+		if err := wallet.WriteSigningRequest(out, signed); err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Signing request signed: %s\n", out)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Request ID: %s\n", req.ID)
+		if req.Description != "" {
+			fmt.Printf("Note:       %s\n", req.Description)
+		}
+		for _, output := range req.Outputs {
+			fmt.Printf("Output:     %s  %d sats\n", output.Address, output.Amount)
+		}
+		fmt.Printf("Fee:        %d sats\n", req.Fee)
+		fmt.Printf("Signed:     %d input(s)\n", count)
+		fmt.Println("\nReturn the signed file and run: exs-node wallet import-signed")
+		return nil
+	},
+}
+
+// createWallet writes an encrypted wallet file and tracks its account
+// descriptor, queueing a rescan for restored wallets
+func createWallet(cmd *cobra.Command, walletName string, words []string, passphrase string, rescan bool) (*wallet.WalletFile, error) {
+	if walletName == "" || filepath.Base(walletName) != walletName || strings.HasPrefix(walletName, ".") {
+		return nil, fmt.Errorf("invalid wallet name %q", walletName)
+	}
+	seedFlag, _ := cmd.Flags().GetString("seed")
+	scheme, err := wallet.ParseSeedScheme(seedFlag)
+	if err != nil {
+		return nil, err
+	}
+	f, hd, err := wallet.CreateWalletFile(walletFilePath(cmd, walletName), walletName, words, scheme, passphrase, networkParams(cmd))
+	if err != nil {
+		return nil, err
+	}
+	desc, err := hd.Descriptor()
+	if err != nil {
+		return nil, err
+	}
+	store, err := openDescriptorStore(cmd, walletName)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	if _, err := store.Import(desc, "hd", 0, 1000, rescan); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// walletPassphrase returns --passphrase or prompts for it, twice when a new
+// wallet is being encrypted
+func walletPassphrase(cmd *cobra.Command, confirm bool) (string, error) {
+	return readPassphrase(cmd, "passphrase", "Wallet passphrase", confirm)
+}
+
+// readPassphrase returns the passphrase flag or prompts for it with label,
+// twice when confirm is set
+func readPassphrase(cmd *cobra.Command, flag, label string, confirm bool) (string, error) {
+	if cmd.Flags().Changed(flag) {
+		passphrase, _ := cmd.Flags().GetString(flag)
+		if passphrase == "" && confirm {
+			return "", fmt.Errorf("an empty passphrase would leave the wallet unprotected")
+		}
+		return passphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("--%s is required when not running in a terminal", flag)
+	}
+
+	fmt.Printf("%s: ", label)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if !confirm {
+		return string(passphrase), nil
+	}
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("an empty passphrase would leave the wallet unprotected")
+	}
+	fmt.Print("Repeat passphrase: ")
+	repeat, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if !bytes.Equal(passphrase, repeat) {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return string(passphrase), nil
+}
+
+// walletKeys unlocks a wallet file and derives the signing keys of every
+// tracked account, for each address up to a gap limit past the last one
+// found in use
+func walletKeys(cmd *cobra.Command, walletName string) ([]*bitcoin.TaprootKey, error) {
+	f, err := wallet.OpenWalletFile(walletFilePath(cmd, walletName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("wallet %s has no keys (use --keys)", walletName)
+		}
+		return nil, err
+	}
+	passphrase, err := walletPassphrase(cmd, false)
+	if err != nil {
+		return nil, err
+	}
+	hd, err := f.Unlock(passphrase, networkParams(cmd))
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := openDescriptorStore(cmd, walletName)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	// Accounts are matched by key origin, since a hidden wallet's
+	// descriptors are not the file's visible one
+	ends := map[uint32]uint32{0: 0}
+	for _, entry := range store.List() {
+		desc, err := wallet.ParseDescriptor(entry.Descriptor)
+		if err != nil {
+			return nil, err
+		}
+		index, ok := hd.AccountOf(desc)
+		if !ok {
+			continue
+		}
+		for _, next := range entry.NextIndex {
+			if next > ends[index] {
+				ends[index] = next
+			}
+		}
+	}
+	var keys []*bitcoin.TaprootKey
+	for index, end := range ends {
+		account, err := hd.Account(index)
+		if err != nil {
+			return nil, err
+		}
+		accountKeys, err := account.TaprootKeys(end + wallet.DefaultGapLimit)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, accountKeys...)
+	}
+	return keys, nil
+}
+
+// walletUTXOs reads spendable outputs from path, or from the wallet's last
+// balance scan, leaving out frozen ones
+func walletUTXOs(cmd *cobra.Command, walletName, path string) ([]wallet.UTXO, error) {
+	utxos, err := knownUTXOs(cmd, walletName, path)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := wallet.OpenLabelStore(labelsPath(cmd, walletName))
+	if err != nil {
+		return nil, err
+	}
+	spendable := labels.Spendable(utxos)
+	if len(spendable) == 0 {
+		return nil, fmt.Errorf("all %d outputs of %s are frozen (see wallet coins unfreeze)", len(utxos), walletName)
+	}
+	return spendable, nil
+}
+
+// knownUTXOs reads outputs from path, or from the wallet's last balance
+// scan, frozen ones included
+func knownUTXOs(cmd *cobra.Command, walletName, path string) ([]wallet.UTXO, error) {
+	if path != "" {
+		return readUTXOs(path)
+	}
+	snapshot, err := wallet.LoadSnapshot(snapshotPath(cmd, walletName))
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshot.UTXOs) == 0 {
+		return nil, fmt.Errorf("no spendable outputs known for %s (run wallet balance or use --utxos)", walletName)
+	}
+	return snapshot.UTXOs, nil
+}
+
+// nextChangeAddress reserves the next unused change address of the
+// wallet's first multi-path descriptor, so transactions built before a
+// rescan do not share one
+func nextChangeAddress(cmd *cobra.Command, walletName string) (string, error) {
+	store, err := openDescriptorStore(cmd, walletName)
+	if err != nil {
+		return "", err
+	}
+	defer store.Close()
+	for _, entry := range store.List() {
+		desc, err := wallet.ParseDescriptor(entry.Descriptor)
+		if err != nil {
+			return "", err
+		}
+		if !desc.IsMultiPath() {
+			continue
+		}
+		index, err := store.Reserve(entry.Descriptor, wallet.ChangeBranch)
+		if err != nil {
+			return "", err
+		}
+		addr, err := desc.Derive(wallet.ChangeBranch, index, networkParams(cmd))
+		if err != nil {
+			return "", err
+		}
+		return addr.Address, nil
+	}
+	return "", fmt.Errorf("wallet %s has no change branch (use --change)", walletName)
+}
+
+// chainSource returns the Esplora API selected by --backend or
+// wallet.backend, or the default public one for the network
+func chainSource(cmd *cobra.Command) (wallet.ChainSource, error) {
+	net := networkParams(cmd)
+	backend := config.Wallet.Backend
+	if backend == "" {
+		backend = wallet.DefaultEsploraURL(net)
+	}
+	if backend == "" {
+		return nil, fmt.Errorf("no public Esplora API for %s (use --backend)", net.Name)
+	}
+	return wallet.NewEsploraSource(backend, net), nil
+}
+
+// broadcastTransaction relays a raw transaction and prints its txid
+func broadcastTransaction(cmd *cobra.Command, rawTx string) error {
+	source, err := chainSource(cmd)
+	if err != nil {
+		return err
+	}
+	txid, err := source.Broadcast(cmd.Context(), rawTx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n✓ Broadcast: %s\n", txid)
+	return nil
+}
+
+// walletFilePath returns the encrypted key file of a wallet
+func walletFilePath(cmd *cobra.Command, walletName string) string {
+	return filepath.Join(dataDir(cmd), "wallets", walletName, "wallet.json")
+}
+
+// snapshotPath returns the cached unspent outputs of a wallet
+func snapshotPath(cmd *cobra.Command, walletName string) string {
+	return filepath.Join(dataDir(cmd), "wallets", walletName, "utxos.json")
+}
+
+// descriptorStorePath returns the descriptor store file of a wallet
+func descriptorStorePath(cmd *cobra.Command, walletName string) string {
+	return filepath.Join(dataDir(cmd), "wallets", walletName, "descriptors.json")
+}
+
+// openDescriptorStore opens a wallet's descriptor store: the JSON file, or
+// with storage.backend set a kv store next to it, which takes over the
+// descriptors of the JSON file on first use
+func openDescriptorStore(cmd *cobra.Command, walletName string) (*wallet.DescriptorStore, error) {
+	path := descriptorStorePath(cmd, walletName)
+	if config.Storage.Backend == "" {
+		return wallet.OpenDescriptorStore(path)
+	}
+	db, err := kv.Open(config.Storage.Backend, strings.TrimSuffix(path, ".json")+"."+config.Storage.Backend)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.OpenDescriptorStoreKV(db, path)
+}
+
+// saveSigningRequest writes req to out (default <id>.json) and keeps a
+// pending copy so import-signed can check the signer's answer
+func saveSigningRequest(cmd *cobra.Command, walletName string, req *wallet.SigningRequest, out string) (string, error) {
+	pending := filepath.Join(pendingRequestDir(cmd, walletName), req.ID+".json")
+	if err := os.MkdirAll(filepath.Dir(pending), 0700); err != nil {
+		return "", fmt.Errorf("failed to create pending directory: %w", err)
+	}
+	if err := wallet.WriteSigningRequest(pending, req); err != nil {
+		return "", err
+	}
+	if out == "" {
+		out = req.ID + ".json"
+	}
+	if err := wallet.WriteSigningRequest(out, req); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// recordTransaction keeps a finalized transaction so it can be fee-bumped later
+func recordTransaction(cmd *cobra.Command, walletName string, req *wallet.SigningRequest, rawTx string) error {
+	var fee int64
+	if req.InputTotal > 0 {
+		fee = req.Fee
+	}
+	record, err := wallet.NewTxRecord(rawTx, fee, req.ChangeIndex)
+	if err != nil {
+		return err
+	}
+	return wallet.SaveTxRecord(txRecordDir(cmd, walletName), record)
+}
+
+// readTaprootKeys reads a file of Taproot signing keys, one "WIF[:root]" or
+// "keystore:NAME[:root]" per line, the latter held in the EXS_KEYSTORE
+// keystore
+func readTaprootKeys(path string, net *chaincfg.Params) ([]*bitcoin.TaprootKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*bitcoin.TaprootKey
+	var ks keystore.Keystore
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, keystore.TaprootKeyPrefix) && ks == nil {
+			if ks, err = keystore.FromEnv(); err != nil {
+				return nil, err
+			}
+		}
+		key, err := keystore.ParseTaprootKey(line, ks, net)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys in %s", path)
+	}
+	return keys, nil
+}
+
+// readUTXOs reads a JSON list of spendable outputs
+func readUTXOs(path string) ([]wallet.UTXO, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var utxos []wallet.UTXO
+	if err := json.Unmarshal(data, &utxos); err != nil {
+		return nil, fmt.Errorf("invalid utxo file: %w", err)
+	}
+	return utxos, nil
+}
+
+// rbfStatus describes replace-by-fee signaling for listings
+func rbfStatus(enabled bool) string {
+	if enabled {
+		return "yes"
+	}
+	return "no"
+}
+
+// addQRFlags adds the --qr and --png flags read by showQR
+func addQRFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("qr", false, "print a QR code for scanning with a mobile wallet")
+	cmd.Flags().String("png", "", "write a QR code image to this PNG file")
+	cmd.Flags().Int("png-size", wallet.DefaultQRSize, "width and height of the PNG in pixels")
+}
+
+// showQR renders content as requested by the --qr and --png flags
+func showQR(cmd *cobra.Command, content string) error {
+	printQR, _ := cmd.Flags().GetBool("qr")
+	pngPath, _ := cmd.Flags().GetString("png")
+	pngSize, _ := cmd.Flags().GetInt("png-size")
+
+	if printQR {
+		code, err := wallet.QRTerminal(content)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n%s\n", code)
+	}
+	if pngPath != "" {
+		png, err := wallet.QRPNG(content, pngSize)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(pngPath, png, 0644); err != nil {
+			return fmt.Errorf("failed to write QR code: %w", err)
+		}
+		fmt.Printf("✓ QR code written: %s\n", pngPath)
+	}
+	return nil
+}
+
+// addressBookPath is shared by all wallets in the data directory
+func addressBookPath(cmd *cobra.Command) string {
+	return filepath.Join(dataDir(cmd), "addressbook.json")
+}
+
+// txRecordDir holds finalized wallet transactions
+func txRecordDir(cmd *cobra.Command, walletName string) string {
+	return filepath.Join(dataDir(cmd), "wallets", walletName, "transactions")
+}
+
+// pendingRequestDir holds signing requests awaiting an offline signature
+func pendingRequestDir(cmd *cobra.Command, walletName string) string {
+	return filepath.Join(dataDir(cmd), "wallets", walletName, "pending")
+}
+
+// readPSBT loads a PSBT from a file (binary, hex or base64) or a base64 argument
+func readPSBT(source string) (*psbt.Packet, error) {
+	if source == "" {
+		return nil, fmt.Errorf("--psbt is required")
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		data = []byte(source)
+	}
+	return exspsbt.Decode(string(data))
+}
+
+// parseRange parses a "start:end" derivation range
+func parseRange(s string) (uint32, uint32, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q (expected start:end)", s)
+	}
+	start, err := strconv.ParseUint(parts[0], 10, 31)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range start: %w", err)
+	}
+	end, err := strconv.ParseUint(parts[1], 10, 31)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range end: %w", err)
+	}
+	return uint32(start), uint32(end), nil
+}
+
 func init() {
 	// Wallet create flags
-	walletCreateCmd.Flags().Bool("prophecy", true, "use 13-word prophecy axiom")
-	walletCreateCmd.Flags().StringP("passphrase", "p", "", "encryption passphrase")
-	walletCreateCmd.Flags().String("type", "hd", "wallet type (hd, multisig)")
+	walletCreateCmd.Flags().StringP("passphrase", "p", "", "encryption passphrase (prompted if omitted)")
+	walletCreateCmd.Flags().String("language", crypto.English.Name, "BIP-39 wordlist for the prophecy")
+	walletCreateCmd.Flags().String("seed", string(wallet.SeedBIP39), "how the prophecy seeds the keys (bip39, forge)")
+	
+	// Balance flags
+	walletBalanceCmd.Flags().String("backend", "", "Esplora API URL (default: wallet.backend or the network's public API)")
+	walletBalanceCmd.Flags().Uint32("gap-limit", wallet.DefaultGapLimit, "consecutive unused addresses that end a scan")
+	walletBalanceCmd.Flags().Bool("offline", false, "show the balance from the last scan")
+	walletHistoryCmd.Flags().Bool("chain", false, "list the wallet's transactions on chain")
+	walletHistoryCmd.Flags().Bool("offline", false, "with --chain, show the history from the last scan")
+	walletHistoryCmd.Flags().String("backend", "", "Esplora API URL (default: wallet.backend or the network's public API)")
+	walletHistoryCmd.Flags().Uint32("gap-limit", wallet.DefaultGapLimit, "consecutive unused addresses that end a scan")
 	
 	// Wallet address flags
 	walletAddressCmd.Flags().String("type", "p2tr", "address type (p2tr, p2wpkh)")
+	walletAddressCmd.Flags().String("amount", "", "request this amount of EXS in a payment URI")
+	walletAddressCmd.Flags().String("label", "", "label for the payment URI")
+	walletAddressCmd.Flags().String("message", "", "message for the payment URI")
+	walletAddressCmd.Flags().Uint32("account", 0, "HD account whose address to show")
+	walletAddressCmd.Flags().Uint32("index", 0, "show the address at this index instead of the next unused one")
+	walletAddressCmd.Flags().Bool("change", false, "show a change address")
+	addQRFlags(walletAddressCmd)
 	
 	// Wallet import flags
 	walletImportCmd.Flags().String("seed-file", "", "file containing seed phrase")
+	walletImportCmd.Flags().String("seed", string(wallet.SeedBIP39), "how the phrase seeds the keys (bip39, forge, legacy)")
+	walletImportCmd.Flags().StringP("passphrase", "p", "", "encryption passphrase (prompted if omitted)")
+	walletExportCmd.Flags().StringP("passphrase", "p", "", "wallet passphrase (prompted if omitted)")
+	
+	// Shamir backup flags
+	walletBackupCmd.Flags().Int("shares", 5, "number of shares to split the seed into")
+	walletBackupCmd.Flags().Int("threshold", 3, "number of shares that restore the wallet")
+	walletBackupCmd.Flags().String("out-dir", "", "write each share to its own file in this directory")
+	walletBackupCmd.Flags().StringP("passphrase", "p", "", "wallet passphrase (prompted if omitted)")
+	walletRecoverCmd.Flags().StringSlice("share-file", nil, "file containing a share (repeatable)")
+	walletRecoverCmd.Flags().StringP("passphrase", "p", "", "encryption passphrase (prompted if omitted)")
+	walletRecoverCmd.Flags().String("seed", string(wallet.SeedBIP39), "how the phrase seeds the keys (bip39, forge)")
+	
+	// Duress flags
+	walletDuressCmd.Flags().StringP("passphrase", "p", "", "wallet passphrase (prompted if omitted)")
+	walletDuressCmd.Flags().String("decoy-passphrase", "", "passphrase that opens the decoy (prompted if omitted)")
+	walletDuressCmd.Flags().String("decoy-seed-file", "", "file containing the decoy seed phrase (default: a new prophecy)")
+	
+	// Descriptor import flags
+	walletImportDescriptorCmd.Flags().String("label", "", "label for the imported descriptor")
+	walletImportDescriptorCmd.Flags().String("range", "0:1000", "derivation range start:end for ranged descriptors")
+	walletImportDescriptorCmd.Flags().Bool("rescan", true, "rescan the chain for the descriptor's addresses")
+	
+	// Offline signing flags
+	walletExportSigningRequestCmd.Flags().String("psbt", "", "unsigned PSBT file or base64 string")
+	walletExportSigningRequestCmd.Flags().String("out", "", "signing request output file (default <id>.json)")
+	walletExportSigningRequestCmd.Flags().String("description", "", "note shown to the offline signer")
+	walletExportSigningRequestCmd.Flags().Bool("qr", false, "print the request as QR-ready text frames")
+	walletExportSigningRequestCmd.Flags().Int("chunk-size", wallet.DefaultChunkSize, "characters of data per QR frame")
+	walletImportSignedCmd.Flags().String("out", "", "write the raw transaction hex to a file")
+	walletImportSignedCmd.Flags().Bool("broadcast", false, "relay the transaction after finalizing it")
+	walletImportSignedCmd.Flags().String("backend", "", "Esplora API URL (default: wallet.backend or the network's public API)")
+	walletSignCmd.Flags().String("keys", "", "file of WIF keys or keystore:NAME references, one per line, optionally followed by :script-root")
+	walletSignCmd.Flags().String("out", "", "signed request output file (default <id>.signed.json)")
+	
+	// Send flags
+	walletSendCmd.Flags().String("utxos", "", "JSON file of spendable outputs (default: outputs from the last balance)")
+	walletSendCmd.Flags().String("change", "", "change address (default: the wallet's next change address)")
+	addFeeFlags(walletSendCmd)
+	walletSendCmd.Flags().String("commit", "", "forge proof hash to commit in an OP_RETURN output (hex)")
+	walletSendCmd.Flags().String("strategy", string(wallet.SelectLargestFirst), "coin selection: largest-first, bnb or knapsack")
+	walletSendCmd.Flags().StringSlice("coin-control", nil, "spend exactly this output (txid:vout); repeatable")
+	walletSendCmd.Flags().String("out", "", "signing request output file (default <id>.json)")
+	walletSendCmd.Flags().String("description", "", "note shown to the offline signer")
+	walletSendCmd.Flags().Bool("no-rbf", false, "do not signal replace-by-fee")
+	walletSendCmd.Flags().String("keys", "", "sign with the Taproot keys in this file instead of exporting a signing request")
+	walletSendCmd.Flags().Bool("sign", false, "sign with the wallet's own keys instead of exporting a signing request")
+	walletSendCmd.Flags().StringP("passphrase", "p", "", "wallet passphrase for --sign (prompted if omitted)")
+	walletSendCmd.Flags().Bool("broadcast", false, "relay the signed transaction")
+	walletSendCmd.Flags().String("backend", "", "Esplora API URL (default: wallet.backend or the network's public API)")
+	walletVerifyCommitCmd.Flags().String("tx-hex", "", "raw transaction, if not finalized by this wallet")
+	for _, c := range []*cobra.Command{walletBalanceCmd, walletHistoryCmd, walletImportSignedCmd, walletSendCmd} {
+		bindConfigFlags(c, map[string]string{"backend": "wallet.backend", "gap-limit": "wallet.gap_limit"})
+	}
+	
+	// Address book and payment URI flags
+	walletContactsAddCmd.Flags().String("message", "", "default message for payments to the contact")
+	walletURICmd.Flags().String("label", "", "label for the recipient")
+	walletURICmd.Flags().String("message", "", "message describing the payment")
+	addQRFlags(walletURICmd)
+	
+	// Batch payout flags
+	walletSendManyCmd.Flags().String("utxos", "", "JSON file of spendable outputs")
+	walletSendManyCmd.Flags().String("change", "", "change address")
+	addFeeFlags(walletSendManyCmd)
+	walletSendManyCmd.Flags().String("format", "", "payout file format: csv or json (default from extension)")
+	walletSendManyCmd.Flags().String("out", "", "signing request output file (default <id>.json)")
+	walletSendManyCmd.Flags().String("description", "", "note shown to the offline signer")
+	walletSendManyCmd.Flags().Bool("no-rbf", false, "do not signal replace-by-fee")
+	
+	// CPFP flags
+	walletAccelerateCmd.Flags().Int64("fee-rate", 20, "target package fee rate in sat/vB")
+	walletAccelerateCmd.Flags().Int("vout", -1, "parent output to spend (default: change output)")
+	walletAccelerateCmd.Flags().String("dest", "", "child destination address (default: spent output's address)")
+	walletAccelerateCmd.Flags().String("parent-hex", "", "raw parent transaction, if not finalized by this wallet")
+	walletAccelerateCmd.Flags().Int64("parent-fee", -1, "fee paid by the parent in satoshis")
+	walletAccelerateCmd.Flags().String("out", "", "signing request output file (default <id>.json)")
+	walletAccelerateCmd.Flags().Bool("no-rbf", false, "do not signal replace-by-fee")
 	
 	// Add subcommands
 	walletMultisigCmd.AddCommand(walletMultisigCreateCmd)
+	walletContactsCmd.AddCommand(walletContactsAddCmd, walletContactsListCmd, walletContactsRemoveCmd)
 	
 	walletCmd.AddCommand(
 		walletCreateCmd,
 		walletListCmd,
 		walletBalanceCmd,
 		walletSendCmd,
+		walletSendManyCmd,
+		walletAccelerateCmd,
+		walletHistoryCmd,
+		walletVerifyCommitCmd,
 		walletAddressCmd,
 		walletImportCmd,
+		walletImportDescriptorCmd,
+		walletDescriptorsCmd,
+		walletContactsCmd,
+		walletURICmd,
+		walletExportSigningRequestCmd,
+		walletSignCmd,
+		walletImportSignedCmd,
 		walletExportCmd,
+		walletBackupCmd,
+		walletRecoverCmd,
+		walletDuressCmd,
 		walletMultisigCmd,
 	)
 	
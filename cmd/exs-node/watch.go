@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/spf13/cobra"
+)
+
+var walletWatchCmd = &cobra.Command{
+	Use:   "watch [wallet-name] [descriptor...]",
+	Short: "Create a watch-only wallet from output descriptors",
+	Long: `Create a wallet that tracks the given descriptors without any private
+key, for auditors and monitoring. Descriptors take public keys only, such
+as tr([d34db33f/86'/0'/0']xpub.../<0;1>/*); one holding an xprv is refused.
+
+A watch-only wallet supports balance, history --chain, address and
+statement, and can export signing requests for an offline signer.
+
+Example:
+  exs-node wallet watch treasury-audit "tr([73c5da0a/86'/0'/0']xpub.../<0;1>/*)"
+  exs-node wallet statement treasury-audit --out statement.json`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		label, _ := cmd.Flags().GetString("label")
+		rangeFlag, _ := cmd.Flags().GetString("range")
+
+		if _, err := os.Stat(walletFilePath(cmd, walletName)); err == nil {
+			return fmt.Errorf("wallet %s holds keys; pick another name for a watch-only wallet", walletName)
+		}
+		start, end, err := parseRange(rangeFlag)
+		if err != nil {
+			return err
+		}
+		descs := make([]*wallet.Descriptor, len(args)-1)
+		for i, arg := range args[1:] {
+			desc, err := wallet.ParseDescriptor(arg)
+			if err != nil {
+				return err
+			}
+			if desc.Private {
+				return fmt.Errorf("descriptor %d holds a private key; export its xpub instead", i+1)
+			}
+			descs[i] = desc
+		}
+
+		store, err := openDescriptorStore(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		for _, desc := range descs {
+			if _, err := store.Import(desc, label, start, end, true); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("✓ Watch-only wallet: %s\n", walletName)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for _, entry := range store.List() {
+			fmt.Printf("• %s\n", entry.Descriptor)
+		}
+		fmt.Printf("\nSync with: exs-node wallet balance %s\n", walletName)
+		return nil
+	},
+}
+
+var walletStatementCmd = &cobra.Command{
+	Use:   "statement [wallet-name]",
+	Short: "Export a Rosetta-compatible account statement",
+	Long: `Scan the wallet's descriptors, prove each confirmed transaction's
+inclusion with a merkle proof against its block header, and write an
+account statement shaped after the Rosetta Data API: the balance and coins
+as /account/balance and /account/coins return them, and the transactions
+as /search/transactions does, with INPUT and OUTPUT operations for the
+wallet's coins. Mempool transactions are left out.
+
+Works the same for watch-only wallets; no key is needed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName := args[0]
+		out, _ := cmd.Flags().GetString("out")
+		net := networkParams(cmd)
+
+		store, source, err := openHistorySource(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		fmt.Fprintf(os.Stderr, "Scanning %s...\n", walletName)
+		height, err := source.TipHeight(cmd.Context())
+		if err != nil {
+			return err
+		}
+		tipHash, err := source.BlockHash(cmd.Context(), height)
+		if err != nil {
+			return err
+		}
+		snapshot, err := wallet.Sync(cmd.Context(), source, store, net, config.Wallet.GapLimit)
+		if err != nil {
+			return err
+		}
+		history, err := wallet.SyncHistory(cmd.Context(), source, store, net, config.Wallet.GapLimit)
+		if err != nil {
+			return err
+		}
+		if err := saveSync(cmd, walletName, snapshot, history); err != nil {
+			return err
+		}
+
+		tip := client.BlockIdentifier{Index: int64(height), Hash: tipHash.String()}
+		statement := wallet.NewStatement(store, history, snapshot, tip, net)
+		data, err := json.MarshalIndent(statement, "", "  ")
+		if err != nil {
+			return err
+		}
+		if out == "" {
+			fmt.Println(string(data))
+		} else if err := os.WriteFile(out, append(data, '\n'), 0600); err != nil {
+			return fmt.Errorf("failed to write statement: %w", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "✓ Statement of %d transaction(s) at block %d written to %s\n", statement.TotalCount, height, out)
+		}
+		if n := len(statement.Unverified); n > 0 {
+			fmt.Fprintf(os.Stderr, "⚠️  %d transaction(s) could not be proven; they are listed under unverified\n", n)
+		}
+		return nil
+	},
+}
+
+// openHistorySource opens the wallet's descriptors and the chain source
+// that proves their history
+func openHistorySource(cmd *cobra.Command, walletName string) (*wallet.DescriptorStore, *wallet.EsploraSource, error) {
+	source, err := chainSource(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	esplora, ok := source.(*wallet.EsploraSource)
+	if !ok {
+		return nil, nil, fmt.Errorf("chain backend cannot list address history")
+	}
+	store, err := openDescriptorStore(cmd, walletName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(store.List()) == 0 {
+		store.Close()
+		return nil, nil, fmt.Errorf("wallet %s has no descriptors (use create, watch or import-descriptor)", walletName)
+	}
+	return store, esplora, nil
+}
+
+// saveSync keeps the outputs and history of a sync for --offline use
+func saveSync(cmd *cobra.Command, walletName string, snapshot *wallet.Snapshot, history *wallet.History) error {
+	if err := wallet.SaveSnapshot(snapshotPath(cmd, walletName), snapshot); err != nil {
+		return err
+	}
+	return wallet.SaveHistory(historyPath(cmd, walletName), history)
+}
+
+// historyPath returns the cached chain history of a wallet
+func historyPath(cmd *cobra.Command, walletName string) string {
+	return filepath.Join(dataDir(cmd), "wallets", walletName, "history.json")
+}
+
+// runChainHistory prints the wallet's transactions on chain, proven by
+// merkle proofs, or as of the last sync when offline
+func runChainHistory(cmd *cobra.Command, walletName string) error {
+	offline, _ := cmd.Flags().GetBool("offline")
+	var history *wallet.History
+	var err error
+	if offline {
+		if history, err = wallet.LoadHistory(historyPath(cmd, walletName)); err != nil {
+			return err
+		}
+	} else {
+		store, source, err := openHistorySource(cmd, walletName)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		fmt.Printf("Scanning %s...\n", walletName)
+		if history, err = wallet.SyncHistory(cmd.Context(), source, store, networkParams(cmd), config.Wallet.GapLimit); err != nil {
+			return err
+		}
+		if err := wallet.SaveHistory(historyPath(cmd, walletName), history); err != nil {
+			return err
+		}
+	}
+
+	labels, err := wallet.OpenLabelStore(labelsPath(cmd, walletName))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Chain history for %s:\n", walletName)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	if len(history.Entries) == 0 {
+		fmt.Println("No transactions")
+		return nil
+	}
+	for _, entry := range history.Entries {
+		when, proof := "unconfirmed     ", "mempool"
+		if entry.Height > 0 {
+			when = entry.BlockTime.Local().Format("2006-01-02 15:04")
+			proof = fmt.Sprintf("block %d, unproven", entry.Height)
+			if entry.Verified {
+				proof = fmt.Sprintf("block %d, proven", entry.Height)
+			}
+		}
+		fmt.Printf("%s  %s  %+.8f EXS  (%s)", when, entry.TxID, btcutil.Amount(entry.Net()).ToBTC(), proof)
+		if label := entryLabel(labels, &entry); label != "" {
+			fmt.Printf("  %s", label)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// entryLabel returns the label of a transaction, or else of the first
+// labelled address of the wallet it pays to or spends from
+func entryLabel(labels *wallet.LabelStore, entry *wallet.HistoryEntry) string {
+	if label := labels.Get(wallet.LabelTx, entry.TxID); label != "" {
+		return label
+	}
+	for _, coin := range append(append([]wallet.TxIO{}, entry.Outputs...), entry.Inputs...) {
+		if label := labels.Get(wallet.LabelAddress, coin.Address); label != "" {
+			return label
+		}
+	}
+	return ""
+}
+
+func init() {
+	walletWatchCmd.Flags().String("label", "", "label for the descriptors")
+	walletWatchCmd.Flags().String("range", "0:1000", "derivation range start:end for ranged descriptors")
+	walletStatementCmd.Flags().String("out", "", "write the statement to a file instead of stdout")
+	walletStatementCmd.Flags().String("backend", "", "Esplora API URL (default: wallet.backend or the network's public API)")
+	walletStatementCmd.Flags().Uint32("gap-limit", wallet.DefaultGapLimit, "consecutive unused addresses that end a scan")
+	bindConfigFlags(walletStatementCmd, map[string]string{"backend": "wallet.backend", "gap-limit": "wallet.gap_limit"})
+
+	walletCmd.AddCommand(walletWatchCmd, walletStatementCmd)
+}
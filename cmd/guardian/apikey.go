@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/spf13/cobra"
+)
+
+func runAPIKeyCreate(cmd *cobra.Command, args []string) error {
+	spec := guardian.APIKey{Name: args[0], Role: guardian.Role(args[1])}
+	scopes, _ := cmd.Flags().GetStringSlice("scope")
+	for _, scope := range scopes {
+		spec.Scopes = append(spec.Scopes, guardian.Permission(scope))
+	}
+	rules, _ := cmd.Flags().GetStringSlice("ip")
+	for _, rule := range rules {
+		prefix, err := guardian.ParseIPRule(rule)
+		if err != nil {
+			return err
+		}
+		spec.AllowedIPs = append(spec.AllowedIPs, prefix)
+	}
+	spec.RateLimit, _ = cmd.Flags().GetInt("rate-limit")
+	if ttl, _ := cmd.Flags().GetDuration("expires-in"); ttl > 0 {
+		spec.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	key, record, err := g.CreateAPIKey(spec)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	fmt.Printf("✅ API key '%s' created with role %s (ID %s)\n\n", record.Name, record.Role, record.ID)
+	fmt.Printf("API Key: %s\n\n", key)
+	fmt.Println("⚠️  Store the key now; it cannot be shown again.")
+	fmt.Printf("Send it as \"Authorization: Bearer <key>\" or \"%s: <key>\".\n", guardian.APIKeyHeader)
+	return nil
+}
+
+func runAPIKeyList(cmd *cobra.Command, args []string) {
+	fmt.Println("🔑 API Keys")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	keys := g.ListAPIKeys()
+	if len(keys) == 0 {
+		fmt.Println("No API keys found")
+		return
+	}
+
+	fmt.Printf("%-16s %-16s %-12s %-20s %-20s %s\n", "ID", "NAME", "ROLE", "LAST USED", "EXPIRES", "LIMITS")
+	for _, key := range keys {
+		lastUsed := "never"
+		if !key.LastUsedAt.IsZero() {
+			lastUsed = key.LastUsedAt.Format("2006-01-02 15:04:05")
+		}
+		expires := "never"
+		if !key.ExpiresAt.IsZero() {
+			expires = key.ExpiresAt.Format("2006-01-02 15:04:05")
+		}
+		var limits []string
+		for _, scope := range key.Scopes {
+			limits = append(limits, string(scope))
+		}
+		for _, prefix := range key.AllowedIPs {
+			limits = append(limits, prefix.String())
+		}
+		if key.RateLimit > 0 {
+			limits = append(limits, fmt.Sprintf("%d req", key.RateLimit))
+		}
+		if len(limits) == 0 {
+			limits = []string{"-"}
+		}
+		fmt.Printf("%-16s %-16s %-12s %-20s %-20s %s\n", key.ID, key.Name, key.Role, lastUsed, expires, strings.Join(limits, ", "))
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Total: %d key(s)\n", len(keys))
+}
+
+func runAPIKeyRotate(cmd *cobra.Command, args []string) error {
+	key, err := g.RotateAPIKey(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to rotate API key: %w", err)
+	}
+	fmt.Printf("🔄 API key %s rotated; the old key no longer works\n\n", args[0])
+	fmt.Printf("API Key: %s\n", key)
+	return nil
+}
+
+func runAPIKeyRevoke(cmd *cobra.Command, args []string) error {
+	if err := g.RevokeAPIKey(args[0]); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	fmt.Printf("✅ API key %s revoked\n", args[0])
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/keystore"
+	"github.com/spf13/cobra"
+)
+
+// openKeystore opens the --keystore keystore, EXS_KEYSTORE by default,
+// asking for the passphrase of a file keystore unless
+// EXS_KEYSTORE_PASSPHRASE is set. It returns nil when neither is set.
+func openKeystore(cmd *cobra.Command) (keystore.Keystore, error) {
+	spec, _ := cmd.Flags().GetString("keystore")
+	if spec == "" {
+		return nil, nil
+	}
+	passphrase := os.Getenv("EXS_KEYSTORE_PASSPHRASE")
+	if strings.HasPrefix(spec, "file:") && passphrase == "" {
+		fmt.Print("Keystore passphrase: ")
+		var err error
+		if passphrase, err = readPassword(); err != nil {
+			return nil, fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		fmt.Println()
+	}
+	return keystore.Open(spec, passphrase)
+}
+
+// signerKey returns the signer for the --key in the keystore, or else
+// reads a private key from the terminal
+func signerKey(cmd *cobra.Command) (keystore.Signer, error) {
+	name, _ := cmd.Flags().GetString("key")
+	if name == "" {
+		key, err := readSignerKey()
+		if err != nil {
+			return nil, err
+		}
+		return keystore.PrivateKeySigner(key), nil
+	}
+	ks, err := openKeystore(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if ks == nil {
+		return nil, errors.New("--key needs --keystore or EXS_KEYSTORE")
+	}
+	return ks.Signer(name)
+}
+
+// generateInKeystore generates the --key signer key in the keystore,
+// reporting false when no --key was given
+func generateInKeystore(cmd *cobra.Command, use string) (bool, error) {
+	name, _ := cmd.Flags().GetString("key")
+	if name == "" {
+		return false, nil
+	}
+	ks, err := openKeystore(cmd)
+	if err != nil {
+		return true, err
+	}
+	if ks == nil {
+		return true, errors.New("--key needs --keystore or EXS_KEYSTORE")
+	}
+	pub, err := ks.Generate(name)
+	if err != nil {
+		return true, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	fmt.Printf("🔑 %s Signer Key\n", use)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Public key:  %s\n", guardian.SignerKey(pub))
+	fmt.Printf("Keystore:    %s (key %s)\n", keystoreSpec(cmd), name)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Sign with --key %s; the private key never leaves the keystore.\n", name)
+	return true, nil
+}
+
+func keystoreSpec(cmd *cobra.Command) string {
+	spec, _ := cmd.Flags().GetString("keystore")
+	return spec
+}
+
+func runKeystoreList(cmd *cobra.Command, args []string) error {
+	ks, err := openKeystore(cmd)
+	if err != nil {
+		return err
+	}
+	if ks == nil {
+		return errors.New("set --keystore or EXS_KEYSTORE")
+	}
+	names, err := ks.List()
+	if err != nil {
+		return fmt.Errorf("failed to list keys: %w", err)
+	}
+
+	fmt.Printf("🗝️  Keys in %s\n", keystoreSpec(cmd))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	if len(names) == 0 {
+		fmt.Println("No keys found")
+		return nil
+	}
+	fmt.Printf("%-24s %s\n", "NAME", "PUBLIC KEY (X-ONLY)")
+	for _, name := range names {
+		pub, err := ks.PublicKey(name)
+		if err != nil {
+			fmt.Printf("%-24s ⚠️  %v\n", name, err)
+			continue
+		}
+		fmt.Printf("%-24s %s\n", name, guardian.SignerKey(pub))
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Total: %d key(s)\n", len(names))
+	return nil
+}
+
+func runKeystoreImport(cmd *cobra.Command, args []string) error {
+	ks, err := openKeystore(cmd)
+	if err != nil {
+		return err
+	}
+	if ks == nil {
+		return errors.New("set --keystore or EXS_KEYSTORE")
+	}
+	key, err := readSignerKey()
+	if err != nil {
+		return err
+	}
+	defer key.Zero()
+	if err := ks.Import(args[0], key); err != nil {
+		return fmt.Errorf("failed to import key: %w", err)
+	}
+	fmt.Printf("✅ Key %s imported (%s)\n", args[0], guardian.SignerKey(key.PubKey()))
+	fmt.Println("Destroy other copies of the private key once the import is checked.")
+	return nil
+}
+
+func runKeystoreDelete(cmd *cobra.Command, args []string) error {
+	ks, err := openKeystore(cmd)
+	if err != nil {
+		return err
+	}
+	if ks == nil {
+		return errors.New("set --keystore or EXS_KEYSTORE")
+	}
+	if err := ks.Delete(args[0]); err != nil {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+	fmt.Printf("✅ Key %s deleted\n", args[0])
+	return nil
+}
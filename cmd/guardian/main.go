@@ -2,25 +2,35 @@ package main
 
 import (
 	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/netip"
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
 	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
 var (
 	g *guardian.Guardian
-	// Note: This CLI uses in-memory storage for demonstration.
-	// For production, implement persistent storage (PostgreSQL, Redis, etc.)
+
+	storeBackend string
+	storePath    string
+	auditSpec    string
+	logOptions   logging.Options
 )
 
 func main() {
-	g = guardian.NewGuardian(nil)
-
 	rootCmd := &cobra.Command{
 		Use:   "guardian",
 		Short: "⚔️ Lancelot Guardian Protocol CLI",
@@ -29,8 +39,25 @@ func main() {
 This CLI tool manages authentication, authorization, and security for the
 Excalibur $EXS blockchain protocol. Named after Sir Lancelot, the most
 trusted knight of King Arthur's Round Table.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return openGuardian()
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			return g.Close()
+		},
 	}
 
+	rootCmd.PersistentFlags().StringVar(&storeBackend, "store", "bolt", "user/session store backend: bolt, badger, sqlite, memory")
+	rootCmd.PersistentFlags().StringVar(&storePath, "db", "", "store database path (default is $HOME/.excalibur-exs/guardian/guardian.db)")
+	logOptions.Register(rootCmd.PersistentFlags())
+	cobra.OnInitialize(func() {
+		if err := logOptions.Setup(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	})
+	rootCmd.PersistentFlags().StringVar(&auditSpec, "audit", envOr("GUARDIAN_AUDIT", "file"), "audit sinks: file[:path], syslog[:tag], webhook:url, comma separated, or none")
+
 	// User management commands
 	userCmd := &cobra.Command{
 		Use:   "user",
@@ -46,11 +73,39 @@ trusted knight of King Arthur's Round Table.`,
 
 	listUsersCmd := &cobra.Command{
 		Use:   "list",
-		Short: "List all users (requires data persistence)",
+		Short: "List all users",
 		Run:   runListUsers,
 	}
 
-	userCmd.AddCommand(createUserCmd, listUsersCmd)
+	passwdCmd := &cobra.Command{
+		Use:   "passwd [username]",
+		Short: "Change a user's password and revoke their sessions",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runPasswd,
+	}
+
+	deleteUserCmd := &cobra.Command{
+		Use:   "delete [username]",
+		Short: "Delete a user and revoke their sessions",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runDeleteUser,
+	}
+
+	roleCmd := &cobra.Command{
+		Use:   "role [username] [king_arthur|knight|squire]",
+		Short: "Change a user's role",
+		Args:  cobra.ExactArgs(2),
+		RunE:  runSetRole,
+	}
+
+	unlockCmd := &cobra.Command{
+		Use:   "unlock [username]",
+		Short: "Lift a lockout after failed logins",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runUnlock,
+	}
+
+	userCmd.AddCommand(createUserCmd, listUsersCmd, passwdCmd, deleteUserCmd, roleCmd, unlockCmd)
 
 	// Session management commands
 	sessionCmd := &cobra.Command{
@@ -79,7 +134,50 @@ trusted knight of King Arthur's Round Table.`,
 		RunE:  runRevoke,
 	}
 
-	sessionCmd.AddCommand(loginCmd, validateCmd, revokeCmd)
+	refreshCmd := &cobra.Command{
+		Use:   "refresh [refresh-token]",
+		Short: "Exchange a refresh token for a new session",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runRefresh,
+	}
+
+	revokeAllCmd := &cobra.Command{
+		Use:   "revoke-all [username]",
+		Short: "Revoke every session of a user",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runRevokeAll,
+	}
+
+	sessionCmd.AddCommand(loginCmd, validateCmd, refreshCmd, revokeCmd, revokeAllCmd)
+
+	// Two-factor authentication commands
+	totpCmd := &cobra.Command{
+		Use:   "totp",
+		Short: "Manage two-factor authentication",
+	}
+
+	enrollCmd := &cobra.Command{
+		Use:   "enroll [username]",
+		Short: "Enroll a user in TOTP two-factor authentication",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runTOTPEnroll,
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify [username] [code]",
+		Short: "Verify a TOTP code, completing enrollment if pending",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE:  runTOTPVerify,
+	}
+
+	disableCmd := &cobra.Command{
+		Use:   "disable [username]",
+		Short: "Disable two-factor authentication for a user",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runTOTPDisable,
+	}
+
+	totpCmd.AddCommand(enrollCmd, verifyCmd, disableCmd)
 
 	// Security commands
 	securityCmd := &cobra.Command{
@@ -88,12 +186,34 @@ trusted knight of King Arthur's Round Table.`,
 	}
 
 	whitelistCmd := &cobra.Command{
-		Use:   "whitelist [add|remove] [ip]",
+		Use:   "whitelist [add|remove] [ip|cidr]",
 		Short: "Manage IP whitelist",
 		Args:  cobra.ExactArgs(2),
 		RunE:  runWhitelist,
 	}
 
+	denylistCmd := &cobra.Command{
+		Use:   "denylist [add|remove] [ip|cidr]",
+		Short: "Manage IP denylist, which takes precedence over the whitelist",
+		Args:  cobra.ExactArgs(2),
+		RunE:  runDenylist,
+	}
+
+	policyCmd := &cobra.Command{
+		Use:   "policy [file] [ip]",
+		Short: "Check an IP policy file, and an address against it",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE:  runPolicy,
+	}
+	policyCmd.Flags().String("role", "", "check the address for this role")
+
+	jwtCmd := &cobra.Command{
+		Use:   "jwt [rotate|jwks]",
+		Short: "Rotate the JWT signing key or print the public keys (GUARDIAN_SESSION_MODE=jwt)",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runJWT,
+	}
+
 	cleanupCmd := &cobra.Command{
 		Use:   "cleanup",
 		Short: "Clean up expired sessions",
@@ -106,7 +226,7 @@ trusted knight of King Arthur's Round Table.`,
 		Run:   runStatus,
 	}
 
-	securityCmd.AddCommand(whitelistCmd, cleanupCmd, statusCmd)
+	securityCmd.AddCommand(whitelistCmd, denylistCmd, policyCmd, jwtCmd, cleanupCmd, statusCmd)
 
 	// Info command
 	infoCmd := &cobra.Command{
@@ -115,7 +235,166 @@ trusted knight of King Arthur's Round Table.`,
 		Run:   runInfo,
 	}
 
-	rootCmd.AddCommand(userCmd, sessionCmd, securityCmd, infoCmd)
+	// Emergency signer commands work offline, without the user store
+	emergencyCmd := &cobra.Command{
+		Use:                "emergency",
+		Short:              "Emergency halt signer keys and resume signatures",
+		PersistentPreRunE:  func(cmd *cobra.Command, args []string) error { return nil },
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+
+	emergencyKeygenCmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate an emergency signer key",
+		RunE:  runEmergencyKeygen,
+	}
+
+	emergencySignCmd := &cobra.Command{
+		Use:   "sign [halt-id]",
+		Short: "Sign the resume of an emergency halt",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runEmergencySign,
+	}
+
+	emergencyCmd.AddCommand(emergencyKeygenCmd, emergencySignCmd)
+
+	// Distribution signer commands also work offline
+	distributionCmd := &cobra.Command{
+		Use:                "distribution",
+		Short:              "Distribution signer keys and proposal approvals",
+		PersistentPreRunE:  func(cmd *cobra.Command, args []string) error { return nil },
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+
+	distributionKeygenCmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate a distribution signer key",
+		RunE:  runDistributionKeygen,
+	}
+
+	distributionSignCmd := &cobra.Command{
+		Use:   "sign [proposal.json]",
+		Short: "Sign the approval of a distribution proposal",
+		Long: `Sign the approval of a distribution proposal, as returned by the
+treasury's GET /proposals/{id}. The proposal is shown for review first.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runDistributionSign,
+	}
+
+	distributionCmd.AddCommand(distributionKeygenCmd, distributionSignCmd)
+
+	// Signer keys can be kept in a keystore instead of offline: keygen
+	// --key generates one there, sign --key signs with it
+	for _, c := range []*cobra.Command{emergencyCmd, distributionCmd} {
+		c.PersistentFlags().String("keystore", os.Getenv("EXS_KEYSTORE"), "keystore: file:DIR, keychain[:SERVICE] or transit:DIR")
+		c.PersistentFlags().String("key", "", "name of the signer key in the keystore")
+	}
+
+	// Keystore commands manage signer keys, also offline
+	keystoreCmd := &cobra.Command{
+		Use:                "keystore",
+		Short:              "Manage signer keys in a keystore",
+		PersistentPreRunE:  func(cmd *cobra.Command, args []string) error { return nil },
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+	keystoreCmd.PersistentFlags().String("keystore", os.Getenv("EXS_KEYSTORE"), "keystore: file:DIR, keychain[:SERVICE] or transit:DIR")
+
+	keystoreListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List keys and their public keys",
+		Args:  cobra.NoArgs,
+		RunE:  runKeystoreList,
+	}
+
+	keystoreImportCmd := &cobra.Command{
+		Use:   "import [name]",
+		Short: "Import a signer private key read from the terminal",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runKeystoreImport,
+	}
+
+	keystoreDeleteCmd := &cobra.Command{
+		Use:   "delete [name]",
+		Short: "Delete a key",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runKeystoreDelete,
+	}
+
+	keystoreCmd.AddCommand(keystoreListCmd, keystoreImportCmd, keystoreDeleteCmd)
+
+	// Audit log commands read the log file without opening the store
+	auditCmd := &cobra.Command{
+		Use:                "audit",
+		Short:              "Inspect the security audit log",
+		PersistentPreRunE:  func(cmd *cobra.Command, args []string) error { return nil },
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+
+	auditQueryCmd := &cobra.Command{
+		Use:   "query",
+		Short: "List audit events, newest last",
+		Args:  cobra.NoArgs,
+		RunE:  runAuditQuery,
+	}
+	auditQueryCmd.Flags().String("type", "", "event type, e.g. login_failure")
+	auditQueryCmd.Flags().String("user", "", "username")
+	auditQueryCmd.Flags().String("ip", "", "IP address")
+	auditQueryCmd.Flags().String("since", "", "earliest time, RFC 3339, YYYY-MM-DD or a duration such as 24h")
+	auditQueryCmd.Flags().String("until", "", "latest time, in the same formats as --since")
+	auditQueryCmd.Flags().Int("limit", 50, "show at most this many of the latest matches (0 for all)")
+	auditQueryCmd.Flags().Bool("json", false, "print events as JSON lines")
+
+	auditVerifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the audit log hash chain for tampering",
+		Args:  cobra.NoArgs,
+		RunE:  runAuditVerify,
+	}
+
+	auditCmd.PersistentFlags().String("file", "", "audit log path (default is $HOME/.excalibur-exs/guardian/audit.log)")
+	auditCmd.AddCommand(auditQueryCmd, auditVerifyCmd)
+
+	// API key commands
+	apiKeyCmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage API keys for machine callers",
+	}
+
+	apiKeyCreateCmd := &cobra.Command{
+		Use:   "create [name] [king_arthur|knight|squire]",
+		Short: "Create an API key and print it once",
+		Args:  cobra.ExactArgs(2),
+		RunE:  runAPIKeyCreate,
+	}
+	apiKeyCreateCmd.Flags().StringSlice("scope", nil, "limit the key to these permissions, e.g. forge.submit")
+	apiKeyCreateCmd.Flags().StringSlice("ip", nil, "bind the key to these addresses or networks")
+	apiKeyCreateCmd.Flags().Int("rate-limit", 0, "requests per rate limit window (default is the Guardian's)")
+	apiKeyCreateCmd.Flags().Duration("expires-in", 0, "expire the key after this long (default never)")
+
+	apiKeyListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List API keys",
+		Args:  cobra.NoArgs,
+		Run:   runAPIKeyList,
+	}
+
+	apiKeyRotateCmd := &cobra.Command{
+		Use:   "rotate [id]",
+		Short: "Replace an API key, printing the new key",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runAPIKeyRotate,
+	}
+
+	apiKeyRevokeCmd := &cobra.Command{
+		Use:   "revoke [id]",
+		Short: "Revoke an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runAPIKeyRevoke,
+	}
+
+	apiKeyCmd.AddCommand(apiKeyCreateCmd, apiKeyListCmd, apiKeyRotateCmd, apiKeyRevokeCmd)
+
+	rootCmd.AddCommand(userCmd, sessionCmd, totpCmd, apiKeyCmd, securityCmd, emergencyCmd, distributionCmd, keystoreCmd, auditCmd, infoCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -178,9 +457,35 @@ func runCreateUser(cmd *cobra.Command, args []string) error {
 func runListUsers(cmd *cobra.Command, args []string) {
 	fmt.Println("📋 User Management")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println("\nNote: User listing requires persistent storage.")
-	fmt.Println("In-memory users are not persisted across restarts.")
-	fmt.Println("\nFor production use, integrate with a database backend.")
+
+	users := g.ListUsers()
+	if len(users) == 0 {
+		fmt.Println("No users found")
+		return
+	}
+
+	fmt.Printf("%-20s %-12s %-8s %-8s %-20s %s\n", "USERNAME", "ROLE", "ENABLED", "2FA", "LAST LOGIN", "LOCKOUT")
+	for _, user := range users {
+		lastLogin := "never"
+		if !user.LastLoginAt.IsZero() {
+			lastLogin = user.LastLoginAt.Format("2006-01-02 15:04:05")
+		}
+		twoFactor := "off"
+		if user.TOTPEnabled {
+			twoFactor = "on"
+		} else if len(user.TOTPSecret) > 0 {
+			twoFactor = "pending"
+		}
+		lockout := "-"
+		if until := g.LockedUntil(user.Username); !until.IsZero() {
+			lockout = "until " + until.Format("2006-01-02 15:04:05")
+		} else if user.FailedLogins > 0 {
+			lockout = fmt.Sprintf("%d failed", user.FailedLogins)
+		}
+		fmt.Printf("%-20s %-12s %-8t %-8s %-20s %s\n", user.Username, user.Role, user.Enabled, twoFactor, lastLogin, lockout)
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Total: %d user(s)\n", len(users))
 }
 
 func runLogin(cmd *cobra.Command, args []string) error {
@@ -201,16 +506,50 @@ func runLogin(cmd *cobra.Command, args []string) error {
 		ipAddress = "127.0.0.1"
 	}
 
-	token, err := g.Authenticate(username, password, ipAddress)
+	creds := guardian.Credentials{Username: username, Password: password}
+	session, err := g.LoginSession(creds, ipAddress)
+	if errors.Is(err, guardian.ErrTOTPRequired) {
+		fmt.Print("Authentication code (or backup code): ")
+		code, _ := reader.ReadString('\n')
+		creds.TOTPCode = strings.TrimSpace(code)
+		session, err = g.LoginSession(creds, ipAddress)
+	}
+	if errors.Is(err, guardian.ErrAccountLocked) {
+		fmt.Println("💡 A King Arthur can lift the lockout with: guardian user unlock " + username)
+	}
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
 
 	fmt.Println("\n✅ Authentication successful!")
+	printSession(session)
+	fmt.Println("\n💡 Use the session token for API authentication.")
+	if session.RefreshToken != "" {
+		fmt.Println("   Renew it before it expires with: guardian session refresh <refresh-token>")
+	}
+	return nil
+}
+
+// printSession shows the tokens of a new session and when they expire
+func printSession(session *guardian.Session) {
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Printf("Session Token: %s\n", token)
+	fmt.Printf("Session Token: %s\n", session.Token)
+	fmt.Printf("Expires:       %s\n", session.ExpiresAt.Format("2006-01-02 15:04:05"))
+	if session.RefreshToken != "" {
+		fmt.Printf("Refresh Token: %s\n", session.RefreshToken)
+		fmt.Printf("Refreshable:   until %s\n", g.RefreshExpiresAt(session).Format("2006-01-02 15:04:05"))
+	}
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println("\n💡 Use this token for API authentication.")
+}
+
+func runRefresh(cmd *cobra.Command, args []string) error {
+	session, err := g.RefreshSession(args[0], "127.0.0.1")
+	if err != nil {
+		return fmt.Errorf("refresh failed: %w", err)
+	}
+
+	fmt.Println("✅ Session refreshed; the previous tokens no longer work")
+	printSession(session)
 	return nil
 }
 
@@ -229,6 +568,9 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	fmt.Printf("IP Address: %s\n", session.IPAddress)
 	fmt.Printf("Created:    %s\n", session.CreatedAt.Format("2006-01-02 15:04:05"))
 	fmt.Printf("Expires:    %s\n", session.ExpiresAt.Format("2006-01-02 15:04:05"))
+	if limit := g.RefreshExpiresAt(session); !limit.IsZero() {
+		fmt.Printf("Refreshable: until %s\n", limit.Format("2006-01-02 15:04:05"))
+	}
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	return nil
 }
@@ -244,17 +586,205 @@ func runRevoke(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runRevokeAll(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	if _, err := g.GetUserInfo(username); err != nil {
+		return err
+	}
+
+	revoked, err := g.RevokeUserSessions(username)
+	if err != nil {
+		return fmt.Errorf("revocation failed: %w", err)
+	}
+
+	fmt.Printf("✅ Revoked %d session(s) for '%s'\n", revoked, username)
+	return nil
+}
+
+func runPasswd(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	if _, err := g.GetUserInfo(username); err != nil {
+		return err
+	}
+
+	fmt.Printf("Changing password for: %s\n", username)
+	fmt.Print("New password: ")
+	password, err := readPassword()
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+
+	fmt.Print("\nConfirm password: ")
+	confirmPassword, err := readPassword()
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	fmt.Println()
+
+	if password != confirmPassword {
+		return fmt.Errorf("passwords do not match")
+	}
+
+	revoked, err := g.SetPassword(username, password)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	fmt.Printf("\n✅ Password changed for '%s'; %d session(s) revoked\n", username, revoked)
+	return nil
+}
+
+func runDeleteUser(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	if err := g.DeleteUser(username); err != nil {
+		return err
+	}
+	fmt.Printf("✅ User '%s' deleted\n", username)
+	return nil
+}
+
+func runUnlock(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	user, err := g.GetUserInfo(username)
+	if err != nil {
+		return err
+	}
+	if err := g.UnlockUser(username); err != nil {
+		return err
+	}
+	if user.FailedLogins == 0 {
+		fmt.Printf("✅ '%s' is not locked\n", username)
+		return nil
+	}
+	fmt.Printf("✅ '%s' unlocked (%d failed login(s) cleared)\n", username, user.FailedLogins)
+	return nil
+}
+
+func runSetRole(cmd *cobra.Command, args []string) error {
+	username, role := args[0], guardian.Role(args[1])
+	user, err := g.GetUserInfo(username)
+	if err != nil {
+		return err
+	}
+	revoked, err := g.SetRole(username, role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ '%s' changed from %s to %s\n", username, user.Role, role)
+	if revoked > 0 {
+		fmt.Printf("   %d session(s) revoked\n", revoked)
+	}
+	return nil
+}
+
+func runTOTPEnroll(cmd *cobra.Command, args []string) error {
+	username := args[0]
+
+	enrollment, err := g.EnableTOTP(username)
+	if err != nil {
+		return fmt.Errorf("enrollment failed: %w", err)
+	}
+
+	fmt.Printf("🔑 Two-factor enrollment for %s\n", username)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println("Add this account to your authenticator app:")
+	fmt.Printf("\nSecret: %s\n", enrollment.Secret)
+	fmt.Printf("URI:    %s\n", enrollment.URI)
+	fmt.Println("\nBackup codes (each works once, store them offline):")
+	for _, code := range enrollment.BackupCodes {
+		fmt.Printf("  %s\n", code)
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	fmt.Print("\nEnter the code from your authenticator to finish (or press Enter to verify later): ")
+	reader := bufio.NewReader(os.Stdin)
+	code, _ := reader.ReadString('\n')
+	code = strings.TrimSpace(code)
+	if code == "" {
+		fmt.Printf("\n💡 Finish enrollment with: guardian totp verify %s <code>\n", username)
+		return nil
+	}
+	if err := g.VerifyTOTP(username, code); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	fmt.Printf("\n✅ Two-factor authentication enabled for '%s'\n", username)
+	return nil
+}
+
+func runTOTPVerify(cmd *cobra.Command, args []string) error {
+	username := args[0]
+
+	var code string
+	if len(args) == 2 {
+		code = args[1]
+	} else {
+		fmt.Print("Authentication code: ")
+		reader := bufio.NewReader(os.Stdin)
+		code, _ = reader.ReadString('\n')
+	}
+
+	user, err := g.GetUserInfo(username)
+	if err != nil {
+		return err
+	}
+	if err := g.VerifyTOTP(username, strings.TrimSpace(code)); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	if !user.TOTPEnabled {
+		fmt.Printf("✅ Two-factor authentication enabled for '%s'\n", username)
+	} else {
+		fmt.Println("✅ Code is valid")
+	}
+	return nil
+}
+
+func runTOTPDisable(cmd *cobra.Command, args []string) error {
+	username := args[0]
+
+	if err := g.DisableTOTP(username); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	fmt.Printf("✅ Two-factor authentication disabled for '%s'\n", username)
+	return nil
+}
+
 func runWhitelist(cmd *cobra.Command, args []string) error {
-	action := args[0]
-	ip := args[1]
+	action, rule := args[0], args[1]
+
+	switch action {
+	case "add":
+		if err := g.AddToWhitelist(rule); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Added %s to IP whitelist\n", rule)
+	case "remove":
+		if err := g.RemoveFromWhitelist(rule); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Removed %s from IP whitelist\n", rule)
+	default:
+		return fmt.Errorf("invalid action: %s (use 'add' or 'remove')", action)
+	}
+
+	return nil
+}
+
+func runDenylist(cmd *cobra.Command, args []string) error {
+	action, rule := args[0], args[1]
 
 	switch action {
 	case "add":
-		g.AddToWhitelist(ip)
-		fmt.Printf("✅ Added %s to IP whitelist\n", ip)
+		if err := g.AddToDenylist(rule); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Added %s to IP denylist\n", rule)
 	case "remove":
-		g.RemoveFromWhitelist(ip)
-		fmt.Printf("✅ Removed %s from IP whitelist\n", ip)
+		if err := g.RemoveFromDenylist(rule); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Removed %s from IP denylist\n", rule)
 	default:
 		return fmt.Errorf("invalid action: %s (use 'add' or 'remove')", action)
 	}
@@ -262,6 +792,91 @@ func runWhitelist(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runJWT(cmd *cobra.Command, args []string) error {
+	keys := g.JWTKeys()
+	if keys == nil {
+		return errors.New("JWT sessions are not enabled: set GUARDIAN_SESSION_MODE=jwt and GUARDIAN_JWT_KEYS")
+	}
+
+	switch args[0] {
+	case "rotate":
+		retired := keys.KeyID()
+		kid, err := g.RotateJWTKey()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Signing with key %s; %s stays published until its tokens expire\n", kid, retired)
+		if os.Getenv("GUARDIAN_JWT_KEYS") == "" {
+			slog.Warn("GUARDIAN_JWT_KEYS is not set, so the new key was not saved")
+		}
+	case "jwks":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(keys.JWKS())
+	default:
+		return fmt.Errorf("invalid action: %s (use 'rotate' or 'jwks')", args[0])
+	}
+	return nil
+}
+
+func runPolicy(cmd *cobra.Command, args []string) error {
+	policy, err := guardian.LoadIPPolicy(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🌐 IP Policy: %s\n", args[0])
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Whitelist required: %v\n", policy.Require)
+	printIPRules("", policy.IPRules)
+	for _, role := range []guardian.Role{guardian.RoleKingArthur, guardian.RoleKnight, guardian.RoleSquire} {
+		if rules, ok := policy.Roles[role]; ok {
+			fmt.Printf("\n%s:\n", role)
+			printIPRules("  ", rules)
+		}
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	if len(args) < 2 {
+		return nil
+	}
+	ip := args[1]
+	roleName, _ := cmd.Flags().GetString("role")
+	role := guardian.Role(roleName)
+	switch role {
+	case "", guardian.RoleKingArthur, guardian.RoleKnight, guardian.RoleSquire:
+	default:
+		return fmt.Errorf("unknown role: %s", role)
+	}
+	if policy.Allows(ip, role) {
+		fmt.Printf("✅ %s is allowed", ip)
+	} else {
+		fmt.Printf("⛔ %s is denied", ip)
+	}
+	if role != "" {
+		fmt.Printf(" for %s", role)
+	}
+	fmt.Println()
+	return nil
+}
+
+// printIPRules lists allow and deny rules, or "-" for an empty list
+func printIPRules(indent string, rules guardian.IPRules) {
+	for _, list := range []struct {
+		name     string
+		prefixes []netip.Prefix
+	}{{"Allow", rules.Allow}, {"Deny", rules.Deny}} {
+		names := make([]string, len(list.prefixes))
+		for i, prefix := range list.prefixes {
+			names[i] = prefix.String()
+		}
+		if len(names) == 0 {
+			names = []string{"-"}
+		}
+		fmt.Printf("%s%-6s %s\n", indent, list.name+":", strings.Join(names, ", "))
+	}
+}
+
 func runCleanup(cmd *cobra.Command, args []string) {
 	removed := g.CleanupExpiredSessions()
 	fmt.Printf("🧹 Cleaned up %d expired session(s)\n", removed)
@@ -273,13 +888,23 @@ func runStatus(cmd *cobra.Command, args []string) {
 	fmt.Println("\n🛡️  Security Status: Active")
 	fmt.Println("🔐 Authentication: Argon2id (OWASP compliant)")
 	fmt.Println("⏱️  Rate Limiting: Enabled")
-	fmt.Println("🌐 IP Whitelisting: Configurable")
+	policy := g.IPPolicy()
+	fmt.Printf("🌐 IP Policy: %d allow, %d deny, %d role rule set(s)", len(policy.Allow), len(policy.Deny), len(policy.Roles))
+	if file := os.Getenv("GUARDIAN_IP_POLICY"); file != "" {
+		fmt.Printf(" from %s", file)
+	}
+	fmt.Println()
+	if keys := g.JWTKeys(); keys != nil {
+		fmt.Printf("🎫 Sessions: JWT (EdDSA), signing key %s\n", keys.KeyID())
+	} else {
+		fmt.Println("🎫 Sessions: opaque tokens")
+	}
 	fmt.Println("\n💡 For detailed metrics, integrate with monitoring system.")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 }
 
 func runInfo(cmd *cobra.Command, args []string) {
-	fmt.Println(`
+	fmt.Print(`
 ⚔️ ═══════════════════════════════════════════════════════════════ ⚔️
 
             THE LANCELOT GUARDIAN PROTOCOL
@@ -313,9 +938,13 @@ worthy may access the sacred halls of Excalibur.
 ║                                                               ║
 ║  🌐 IP Whitelisting                                          ║
 ║     • Optional IP-based access control                       ║
-║     • Dynamic whitelist management                           ║
+║     • CIDR allow and deny rules, IPv4 and IPv6               ║
 ║     • Enhanced security for Merlin's Portal                  ║
 ║                                                               ║
+║  🔑 TOTP Two-Factor Authentication                           ║
+║     • RFC 6238 codes from any authenticator app              ║
+║     • One-time backup codes                                  ║
+║                                                               ║
 ║  🎫 Session Management                                       ║
 ║     • Cryptographically secure tokens                        ║
 ║     • Configurable expiration (24h default)                  ║
@@ -342,6 +971,151 @@ For more information, see: docs/guardian.md
 `)
 }
 
+func runEmergencyKeygen(cmd *cobra.Command, args []string) error {
+	if stored, err := generateInKeystore(cmd, "Emergency"); stored {
+		return err
+	}
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	fmt.Println("🔑 Emergency Signer Key")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Public key:  %s\n", guardian.SignerKey(key.PubKey()))
+	fmt.Printf("Private key: %s\n", hex.EncodeToString(key.Serialize()))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println("Add the public key to the treasury's EMERGENCY_SIGNERS and keep the")
+	fmt.Println("private key offline; it is needed to resume after an emergency halt.")
+	return nil
+}
+
+func runEmergencySign(cmd *cobra.Command, args []string) error {
+	haltID := args[0]
+
+	key, err := signerKey(cmd)
+	if err != nil {
+		return err
+	}
+	sig, err := guardian.SignResume(key, haltID)
+	if err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+
+	fmt.Printf("\n✅ Resume approval for halt %s\n", haltID)
+	fmt.Printf("Signer:    %s\n", guardian.SignerKey(key.PubKey()))
+	fmt.Printf("Signature: %s\n", hex.EncodeToString(sig))
+	fmt.Println("\nPOST both as {\"signer\", \"signature\"} to the treasury's /emergency/resume")
+	return nil
+}
+
+func runDistributionKeygen(cmd *cobra.Command, args []string) error {
+	if stored, err := generateInKeystore(cmd, "Distribution"); stored {
+		return err
+	}
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	fmt.Println("🔑 Distribution Signer Key")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Public key:  %s\n", guardian.SignerKey(key.PubKey()))
+	fmt.Printf("Private key: %s\n", hex.EncodeToString(key.Serialize()))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println("Add the public key to the treasury's DISTRIBUTION_SIGNERS and keep the")
+	fmt.Println("private key offline; it is needed to approve treasury distributions.")
+	return nil
+}
+
+func runDistributionSign(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read proposal: %w", err)
+	}
+	var proposal economy.Proposal
+	if err := json.Unmarshal(data, &proposal); err != nil || proposal.ID == "" {
+		return fmt.Errorf("%s is not a distribution proposal", args[0])
+	}
+
+	fmt.Println("📜 Distribution Proposal")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("ID:        %s\n", proposal.ID)
+	fmt.Printf("Amount:    %s EXS\n", proposal.Amount)
+	fmt.Printf("Recipient: %s\n", proposal.Recipient)
+	fmt.Printf("Purpose:   %s\n", proposal.Purpose)
+	fmt.Printf("Proposer:  %s\n", proposal.ProposedBy)
+	fmt.Printf("Expires:   %s\n", proposal.ExpiresAt.Format(time.RFC3339))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	key, err := signerKey(cmd)
+	if err != nil {
+		return err
+	}
+	sig, err := economy.SignProposal(key, &proposal)
+	if err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+
+	fmt.Printf("\n✅ Approval of proposal %s\n", proposal.ID)
+	fmt.Printf("Signer:    %s\n", guardian.SignerKey(key.PubKey()))
+	fmt.Printf("Signature: %s\n", hex.EncodeToString(sig))
+	fmt.Printf("\nPOST both as {\"signer\", \"signature\"} to the treasury's /proposals/%s/approve\n", proposal.ID)
+	return nil
+}
+
+// readSignerKey reads a signer's private key from the terminal
+func readSignerKey() (*btcec.PrivateKey, error) {
+	fmt.Print("Signer private key: ")
+	keyHex, err := readPassword()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	fmt.Println()
+
+	raw, err := hex.DecodeString(strings.TrimSpace(keyHex))
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("the signer key must be 32 bytes of hex")
+	}
+	key, _ := btcec.PrivKeyFromBytes(raw)
+	return key, nil
+}
+
+// openGuardian opens the configured store and initializes the Guardian
+func openGuardian() error {
+	store, err := guardian.OpenStore(storeBackend, storePath)
+	if err != nil {
+		return err
+	}
+	config, err := guardian.ConfigFromEnv()
+	if err != nil {
+		store.Close()
+		return err
+	}
+	g, err = guardian.NewGuardianWithStore(config, store)
+	if err != nil {
+		store.Close()
+		return err
+	}
+	if auditSpec != "none" {
+		audit, err := guardian.OpenAuditLog(auditSpec)
+		if err != nil {
+			g.Close()
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		g.SetAuditLog(audit)
+	}
+	return nil
+}
+
+// envOr returns the environment variable name, or fallback when it is unset
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func readPassword() (string, error) {
 	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
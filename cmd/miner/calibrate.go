@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/hardware"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recalibrate     bool
+	calibrationTime time.Duration
+)
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Measure Tetra-PoW hash rate",
+	Long: `Hash with full Tetra-PoW rounds on every core and store the measured
+per-core rate in ~/.excalibur-exs/calibration.json. The miner and the node
+dashboard use it for hash rate, time-to-block and efficiency figures instead
+of a fixed guess. mine calibrates on first use; run this after hardware or
+clock changes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		acc := hardware.NewAccelerator()
+		c, err := calibrate(ctx, acc, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Calibration failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("\n⏱️  Calibration")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Hashes: %d in %v on %d cores\n", c.Hashes, c.Duration.Round(time.Millisecond), c.Cores)
+		fmt.Printf("Per Core: %.2f H/s\n", c.PerCoreHashRate)
+		fmt.Printf("All Cores: %.2f H/s\n", c.HashRate())
+		fmt.Printf("Time to Block: %s (difficulty 0x%016x)\n", hardware.FormatBlockTime(acc.EstimateBlockTime(difficulty)), difficulty)
+	},
+}
+
+// calibrate applies the stored calibration to acc, measuring and storing a
+// new one when run is set or none matches this hardware
+func calibrate(ctx context.Context, acc *hardware.Accelerator, run bool) (*hardware.Calibration, error) {
+	path, err := hardware.DefaultCalibrationPath()
+	if err != nil {
+		return nil, err
+	}
+	if !run {
+		c, err := hardware.LoadCalibration(path)
+		if err == nil {
+			if err = acc.SetCalibration(c); err == nil {
+				return c, nil
+			}
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Saved calibration unusable, recalibrating", "err", err)
+		}
+	}
+
+	fmt.Printf("⏱️  Calibrating hash rate for %v...\n", calibrationTime)
+	c, err := hardware.Calibrate(ctx, calibrationTime)
+	if err != nil {
+		return nil, err
+	}
+	if err := acc.SetCalibration(c); err != nil {
+		return nil, err
+	}
+	if err := c.Save(path); err != nil {
+		slog.Warn("Calibration not saved", "err", err)
+	}
+	return c, nil
+}
+
+// loadCalibration applies the stored calibration to acc without measuring,
+// reporting whether one was applied
+func loadCalibration(acc *hardware.Accelerator) bool {
+	path, err := hardware.DefaultCalibrationPath()
+	if err != nil {
+		return false
+	}
+	c, err := hardware.LoadCalibration(path)
+	return err == nil && acc.SetCalibration(c) == nil
+}
+
+// hashRateSource labels a hash rate as measured or guessed
+func hashRateSource(acc *hardware.Accelerator) string {
+	if c := acc.Calibration(); c != nil {
+		return "calibrated " + c.MeasuredAt.Local().Format("2006-01-02")
+	}
+	return "estimated, run miner calibrate"
+}
+
+func init() {
+	calibrateCmd.Flags().DurationVar(&calibrationTime, "duration", hardware.DefaultCalibrationTime, "How long to hash while calibrating")
+	calibrateCmd.Flags().Uint64VarP(&difficulty, "difficulty", "d", 0x00FFFFFFFFFFFFFF, "Difficulty target for the time-to-block estimate")
+	mineCmd.Flags().BoolVar(&recalibrate, "calibrate", false, "Measure the hash rate again before mining")
+	mineCmd.Flags().DurationVar(&calibrationTime, "calibration-time", hardware.DefaultCalibrationTime, "How long to hash while calibrating")
+
+	rootCmd.AddCommand(calibrateCmd)
+}
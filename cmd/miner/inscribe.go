@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/inscription"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inscribeNetwork string
+	inscribeEsplora string
+	inscribeTo      string
+
+	// inscriber inscribes the certificates of recorded forges when
+	// MINER_INSCRIPTION_KEY is set
+	inscriber *inscription.Inscriber
+)
+
+// addInscribeFlags registers the flags that inscribe a forge certificate
+// once the treasury records a found block, see setupInscriber
+func addInscribeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&inscribeNetwork, "network", "mainnet", "Bitcoin network certificates are inscribed on and pool payout addresses belong to: mainnet, testnet or regtest")
+	cmd.Flags().StringVar(&inscribeEsplora, "esplora", "", "Esplora API URL certificates are inscribed through (default: the public API of --network)")
+	cmd.Flags().StringVar(&inscribeTo, "inscribe-to", "", "Address to send forge certificates to (default: the address credited with the miner reward)")
+}
+
+// bitcoinParams returns the chain parameters of --network
+func bitcoinParams() (*chaincfg.Params, error) {
+	switch inscribeNetwork {
+	case "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet":
+		return &chaincfg.TestNet3Params, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	}
+	return nil, fmt.Errorf("network %q must be mainnet, testnet or regtest", inscribeNetwork)
+}
+
+// setupInscriber enables forge certificates when MINER_INSCRIPTION_KEY, the
+// WIF key of a funded Taproot address, is set. Forges are numbered by the
+// treasury, so certificates need --treasury.
+func setupInscriber() error {
+	v := os.Getenv("MINER_INSCRIPTION_KEY")
+	if v == "" {
+		return nil
+	}
+	if treasuryURL == "" {
+		return fmt.Errorf("MINER_INSCRIPTION_KEY needs --treasury to number forges")
+	}
+	net, err := bitcoinParams()
+	if err != nil {
+		return err
+	}
+	key, err := bitcoin.ParseTaprootKey(v, net)
+	if err != nil {
+		return fmt.Errorf("MINER_INSCRIPTION_KEY: %w", err)
+	}
+	url := inscribeEsplora
+	if url == "" {
+		url = wallet.DefaultEsploraURL(net)
+	}
+	if url == "" {
+		return fmt.Errorf("MINER_INSCRIPTION_KEY needs --esplora on %s", net.Name)
+	}
+	if inscriber, err = inscription.NewInscriber(key, net, wallet.NewEsploraSource(url, net), 0); err != nil {
+		return err
+	}
+	fmt.Printf("Forge certificates: paid from %s\n", inscriber.Address())
+	return nil
+}
+
+// inscribeForge inscribes the certificate of a recorded forge, proven by
+// the Tetra-PoW hash proof, and sends it to --inscribe-to or wherever the
+// treasury credited the miner reward
+func inscribeForge(ctx context.Context, result *economy.ForgeResult, proof []byte) {
+	owner := inscribeTo
+	if owner == "" {
+		owner = result.MinerAddress
+	}
+	certificate, err := inscription.NewCertificate(result.ForgeID, result.BlockHeight, proof)
+	if err != nil {
+		slog.Warn("⚠️  Forge certificate not inscribed", "forge", result.ForgeID, "err", err)
+		return
+	}
+	receipt, err := inscriber.Inscribe(ctx, certificate, owner)
+	if err != nil {
+		args := []any{"forge", result.ForgeID, "err", err}
+		if receipt != nil {
+			args = append(args, "reveal_tx", receipt.RevealTx)
+		}
+		slog.Warn("⚠️  Forge certificate not inscribed", args...)
+		return
+	}
+	slog.Info("📜 Forge certificate inscribed", "forge", result.ForgeID, "inscription", receipt.InscriptionID,
+		"owner", receipt.Owner, "commit", receipt.CommitTxID, "fee", receipt.Fee)
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
 	"github.com/Holedozer1229/Excalibur-EXS/pkg/hardware"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tracing"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -17,6 +26,13 @@ var (
 	rounds       int
 	workers      int
 	optimization string
+	backend      string
+
+	mineCheckpoint     string
+	checkpointInterval time.Duration
+	progressInterval   time.Duration
+
+	logOptions logging.Options
 )
 
 var rootCmd = &cobra.Command{
@@ -36,19 +52,49 @@ var mineCmd = &cobra.Command{
 	Short: "Mine a block using Tetra-PoW",
 	Long:  "Perform Tetra-PoW mining on the provided data with specified difficulty",
 	Run: func(cmd *cobra.Command, args []string) {
+		split, err := rewardSplit()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		if treasuryURL != "" && split == nil {
+			fmt.Fprintln(os.Stderr, "❌ --treasury needs --payout to know whom to credit")
+			os.Exit(1)
+		}
+		if err := setupInscriber(); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+
 		// Initialize hardware accelerator
 		acc := hardware.NewAccelerator()
 		
 		if workers > 0 {
 			if err := acc.SetWorkerCount(workers); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				slog.Warn("Invalid worker count", "err", err)
 			}
 		}
 		
 		if optimization != "" {
 			if err := acc.SetOptimization(optimization); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				slog.Warn("Invalid optimization mode", "err", err)
+			}
+		}
+		if err := acc.SetBackend(backend); err != nil {
+			slog.Warn("Backend unavailable, mining on the CPU", "err", err)
+		}
+		defer acc.Close()
+		
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		flushTraces := startTracing(ctx)
+		defer flushTraces()
+		
+		if _, err := calibrate(ctx, acc, recalibrate); err != nil {
+			if ctx.Err() != nil {
+				logging.Fatal("Mining stopped", "err", err)
 			}
+			slog.Warn("Calibration failed, using estimates", "err", err)
 		}
 		
 		fmt.Println("⚔️ Excalibur-EXS Ω′ Δ18 Miner")
@@ -60,25 +106,115 @@ var mineCmd = &cobra.Command{
 		hwInfo := acc.GetHardwareInfo()
 		fmt.Printf("Hardware: %s (%s)\n", hwInfo.Type.String(), hwInfo.Name)
 		fmt.Printf("Cores: %d\n", hwInfo.Cores)
+		fmt.Printf("Backend: %s\n", acc.Backend())
+		for _, d := range acc.Devices() {
+			fmt.Printf("Device %d: %s (%d compute units)\n", d.Index, d.Name, d.ComputeUnits)
+		}
 		fmt.Printf("Workers: %d\n", acc.GetWorkerCount())
 		fmt.Printf("Optimization: %s\n", acc.GetOptimization())
-		fmt.Printf("Estimated Hash Rate: %.2f H/s\n", acc.EstimateHashRate())
+		fmt.Printf("Hash Rate: %.2f H/s (%s)\n", acc.EstimateHashRate(), hashRateSource(acc))
+		fmt.Printf("Time to Block: %s\n", hardware.FormatBlockTime(acc.EstimateBlockTime(difficulty)))
 		fmt.Printf("Estimated Power: %.2f W\n", acc.EstimatePowerConsumption())
+		startThermal(ctx, acc)
+		printSplit(split)
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 		
-		startTime := time.Now()
-		nonce, hash := crypto.TetraPoW([]byte(data), difficulty)
-		elapsed := time.Since(startTime)
+		started := acc.Backend()
+		ctx, span := tracing.Start(ctx, "mine",
+			attribute.String("difficulty", fmt.Sprintf("0x%016x", difficulty)),
+			attribute.String("backend", started),
+			attribute.Int("workers", acc.GetWorkerCount()))
+		defer span.End()
+		job, err := crypto.NewMiningJob(crypto.JobConfig{
+			Data:               []byte(data),
+			Difficulty:         difficulty,
+			Batch:              acc.BatchSize(),
+			Search:             acc.MineRange,
+			Checkpoint:         mineCheckpoint,
+			CheckpointInterval: checkpointInterval,
+			ProgressInterval:   progressInterval,
+			OnProgress:         printProgress,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		if next := job.Progress().Next; next > 0 {
+			fmt.Printf("Resuming from nonce %d (%s)\n", next, mineCheckpoint)
+		}
+		job.Start(ctx)
+		result, err := job.Wait()
+		progress := job.Progress()
+		span.SetAttributes(
+			attribute.String("next_nonce", strconv.FormatUint(progress.Next, 10)),
+			attribute.String("hashes", strconv.FormatUint(progress.Hashes, 10)),
+			attribute.Float64("hash_rate", progress.HashRate))
+		if err != nil {
+			tracing.End(span, err)
+			flushTraces()
+			if mineCheckpoint != "" {
+				logging.Fatal("Mining stopped; rerun with the same --checkpoint to resume", "nonce", progress.Next, "checkpoint", mineCheckpoint, "err", err)
+			}
+			logging.Fatal("Mining stopped", "nonce", progress.Next, "err", err)
+		}
 		
+		hashRate := progress.HashRate
+		if started != acc.Backend() {
+			slog.Warn("Backend failed, mined on the CPU", "backend", started, "reason", acc.FallbackReason())
+		}
 		fmt.Println("\n✅ Block mined successfully!")
-		fmt.Printf("Nonce: %d\n", nonce)
-		fmt.Printf("Hash: %s\n", hex.EncodeToString(hash))
-		fmt.Printf("Time elapsed: %v\n", elapsed)
-		fmt.Printf("Hash rate: %.2f H/s\n", float64(nonce)/elapsed.Seconds())
-		fmt.Printf("Efficiency: %.4f H/s/W\n", (float64(nonce)/elapsed.Seconds())/acc.EstimatePowerConsumption())
+		fmt.Printf("Nonce: %d (worker %d)\n", result.Nonce, result.Worker)
+		fmt.Printf("Hash: %s\n", hex.EncodeToString(result.Hash))
+		fmt.Printf("Time elapsed: %v\n", progress.Elapsed)
+		fmt.Printf("Hashes: %d\n", progress.Hashes)
+		fmt.Printf("Hash rate: %.2f H/s\n", hashRate)
+		fmt.Printf("Efficiency: %.4f H/s/W\n", hashRate/acc.EstimatePowerConsumption())
+		
+		fmt.Println("\n👷 Workers (last batch)")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for _, w := range result.Workers {
+			fmt.Printf("Worker %-3d: %d hashes @ %.2f H/s\n", w.Worker, w.Hashes, w.HashRate)
+		}
+		if mineResult != "" {
+			if err := writeMinedBlock(mineResult, result.Nonce, result.Hash); err != nil {
+				slog.Error("Result not saved", "err", err)
+			} else {
+				fmt.Printf("\nResult saved to %s (check with: miner replay --block %s)\n", mineResult, mineResult)
+			}
+		}
+
+		if treasuryURL != "" {
+			fmt.Println("\n🏛️  Treasury")
+			fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			reportForge(context.WithoutCancel(ctx), "", split, result.Hash)
+		}
 	},
 }
 
+// startTracing exports the spans of this run when OTEL_EXPORTER_OTLP_ENDPOINT
+// is set, returning the function that flushes them before the miner exits
+func startTracing(ctx context.Context) func() {
+	shutdown, err := tracing.FromEnv().Setup(ctx, "exs-miner")
+	if err != nil {
+		slog.Warn("Tracing disabled", "err", err)
+		return func() {}
+	}
+	return func() {
+		if err := shutdown(context.Background()); err != nil {
+			slog.Warn("Failed to flush traces", "err", err)
+		}
+	}
+}
+
+// printProgress logs a mining job's nonce, hash count and best hash
+func printProgress(p crypto.JobProgress) {
+	args := []any{"nonce", p.Next, "hashes", p.Hashes, "hash_rate", p.HashRate}
+	if p.Best != nil {
+		args = append(args, "best", hex.EncodeToString(p.Best[:8]), "best_nonce", p.BestNonce)
+	}
+	slog.Info("⛏️  Mining", args...)
+}
+
 var hpp1Cmd = &cobra.Command{
 	Use:   "hpp1",
 	Short: "Run HPP-1 key derivation",
@@ -131,6 +267,9 @@ var hwInfoCmd = &cobra.Command{
 	Long:  "Display detailed information about available mining hardware",
 	Run: func(cmd *cobra.Command, args []string) {
 		acc := hardware.NewAccelerator()
+		acc.SetBackend(hardware.BackendAuto)
+		defer acc.Close()
+		loadCalibration(acc)
 		
 		fmt.Println("🖥️  Hardware Information")
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -148,9 +287,27 @@ var hwInfoCmd = &cobra.Command{
 			fmt.Println("Disabled ❌")
 		}
 		
+		fmt.Println("\n🎮 Accelerator Devices")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		compiled := hardware.Backends()
+		if len(compiled) == 0 {
+			fmt.Println("Backends: none (build with -tags opencl for GPU mining)")
+		} else {
+			fmt.Printf("Backends: %s\n", strings.Join(compiled, ", "))
+		}
+		fmt.Printf("Mining Backend: %v\n", stats["backend"])
+		for _, d := range acc.Devices() {
+			fmt.Printf("Device %d: %s %s (%s)\n", d.Index, d.Vendor, d.Name, d.Type)
+			fmt.Printf("  Compute Units: %d @ %d MHz, Memory: %d MiB\n", d.ComputeUnits, d.ClockMHz, d.Memory>>20)
+		}
+		if reason := acc.FallbackReason(); reason != nil {
+			fmt.Printf("CPU fallback: %v\n", reason)
+		}
+		printThermal(cmd.Context())
+		
 		fmt.Println("\n📊 Performance Estimates")
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		fmt.Printf("Hash Rate: %.2f H/s\n", stats["estimated_hashrate"].(float64))
+		fmt.Printf("Hash Rate: %.2f H/s (%s)\n", stats["estimated_hashrate"].(float64), hashRateSource(acc))
 		fmt.Printf("Power Consumption: %.2f W\n", stats["estimated_power_w"].(float64))
 		fmt.Printf("Efficiency: %.4f H/s/W\n", stats["efficiency_h_per_w"].(float64))
 		
@@ -174,12 +331,27 @@ func init() {
 	mineCmd.Flags().Uint64VarP(&difficulty, "difficulty", "d", 0x00FFFFFFFFFFFFFF, "Mining difficulty target")
 	mineCmd.Flags().StringVarP(&data, "data", "i", "Excalibur-EXS", "Data to mine")
 	mineCmd.Flags().IntVarP(&workers, "workers", "w", 0, "Number of worker threads (0 = auto)")
-	mineCmd.Flags().StringVarP(&optimization, "optimization", "o", "balanced", "Optimization mode: power_save, balanced, performance, extreme")
+	mineCmd.Flags().StringVarP(&optimization, "optimization", "o", "balanced", "Optimization mode: power_save, balanced, performance, extreme (also sets the GPU batch size)")
+	mineCmd.Flags().StringVar(&backend, "backend", hardware.BackendAuto, "Mining backend: auto, cpu, or a compiled-in device backend such as opencl")
+	mineCmd.Flags().StringVar(&mineResult, "result", "", "Write the mined block to this file as JSON for miner replay")
+	mineCmd.Flags().StringVar(&mineCheckpoint, "checkpoint", "", "Save the next nonce to this file and resume from it after an interrupt")
+	mineCmd.Flags().DurationVar(&checkpointInterval, "checkpoint-interval", 30*time.Second, "Least time between checkpoint writes")
+	mineCmd.Flags().DurationVar(&progressInterval, "progress", 10*time.Second, "Interval between progress lines (0 = after every batch)")
+	addTreasuryFlags(mineCmd)
+	addInscribeFlags(mineCmd)
 	
 	hpp1Cmd.Flags().StringVarP(&data, "data", "i", "Excalibur-EXS", "Input data for key derivation")
 	
 	benchmarkCmd.Flags().IntVarP(&rounds, "rounds", "r", 1000, "Number of benchmark rounds")
 	
+	logOptions.Register(rootCmd.PersistentFlags())
+	cobra.OnInitialize(func() {
+		if err := logOptions.Setup(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	})
+
 	rootCmd.AddCommand(mineCmd)
 	rootCmd.AddCommand(hpp1Cmd)
 	rootCmd.AddCommand(benchmarkCmd)
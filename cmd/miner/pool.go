@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/mining/stratum"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tracing"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	poolListen      string
+	poolDifficulty  float64
+	poolJobDiff     float64
+	poolShareRate   float64
+	poolShareTime   time.Duration
+	poolRetarget    time.Duration
+	poolJobInterval time.Duration
+	poolPayouts     string
+	poolReward      string
+
+	// poolRewardSats and poolNet are --block-reward and --network once
+	// --payouts is set
+	poolRewardSats int64
+	poolNet        *chaincfg.Params
+)
+
+var poolCmd = &cobra.Command{
+	Use:   "pool",
+	Short: "Run a Stratum mining pool server",
+	Long: `Run an EXS mining pool that hands Tetra-PoW jobs to miners over the
+Stratum protocol and validates their shares. Each connection gets its own
+share difficulty, retargeted toward --shares-per-minute so fast miners send
+fewer, harder shares. --job-difficulty pins the share difficulty of every
+job instead.
+
+With --payouts, every found block's --block-reward is split in proportion
+to the work each worker contributed since the previous block, and written
+to a CSV file in that directory for exs-node wallet sendmany to pay in one
+transaction. Workers are named after their payout address, optionally
+followed by .rig; work of workers not named after an address of --network
+is not paid, nor are shares below the dust limit.
+
+Miners connect with: exs-node mine start --pool stratum+tcp://host:port`,
+	Run: func(cmd *cobra.Command, args []string) {
+		split, err := rewardSplit()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		if err := setupInscriber(); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		if err := setupPoolPayouts(); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+
+		cfg := stratum.DefaultConfig()
+		cfg.Difficulty = poolDifficulty
+		cfg.TargetShareTime = poolShareTime
+		if poolShareRate > 0 {
+			cfg.TargetShareTime = time.Duration(float64(time.Minute) / poolShareRate)
+		}
+		cfg.RetargetInterval = poolRetarget
+
+		blocks := make(chan stratum.Share, 1)
+		cfg.OnShare = func(s stratum.Share) {
+			slog.Info("✓ Share", "worker", s.Worker, "job", s.JobID, "difficulty", s.Difficulty)
+		}
+		cfg.OnBlock = func(s stratum.Share) {
+			select {
+			case blocks <- s:
+			default:
+			}
+		}
+		server := stratum.NewServer(cfg)
+
+		fmt.Println("⛏️  Excalibur-EXS Stratum Pool")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Listen: %s\n", poolListen)
+		fmt.Printf("Block target: 0x%016x\n", difficulty)
+		if poolJobDiff > 0 {
+			fmt.Printf("Share difficulty: %.2f (fixed per job)\n", poolJobDiff)
+		} else {
+			fmt.Printf("Share difficulty: %.2f (vardiff, one share per %s)\n", cfg.Difficulty, cfg.TargetShareTime)
+		}
+		printSplit(split)
+		if treasuryURL != "" {
+			fmt.Printf("Treasury: %s\n", treasuryURL)
+		}
+		if poolPayouts != "" {
+			fmt.Printf("Payouts: %s EXS per block to %s\n", poolReward, poolPayouts)
+		}
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		flushTraces := startTracing(ctx)
+		defer flushTraces()
+
+		if treasuryURL != "" {
+			go followEmergency(ctx)
+		}
+		go runPoolJobs(ctx, server, blocks, split)
+
+		if err := server.ListenAndServe(ctx, poolListen); err != nil {
+			logging.Fatal("Pool stopped", "err", err)
+		}
+
+		stats := server.Stats()
+		fmt.Printf("\nPool stopped: %d blocks found\n", stats.Blocks)
+		for _, w := range stats.Workers {
+			fmt.Printf("%-20s: %d accepted, %d rejected, %d stale, difficulty %.2f\n", w.Worker, w.Accepted, w.Rejected, w.Stale, w.Difficulty)
+		}
+	},
+}
+
+// runPoolJobs announces a fresh job every poolJobInterval and a clean job
+// whenever a block is found. Found blocks end the payout round and are
+// reported to the treasury, crediting split or, without one, the finding
+// worker.
+func runPoolJobs(ctx context.Context, server *stratum.Server, blocks <-chan stratum.Share, split economy.RewardSplit) {
+	ticker := time.NewTicker(poolJobInterval)
+	defer ticker.Stop()
+
+	prevHash := make([]byte, 32)
+	var height uint64
+	next := func(clean bool) {
+		height++
+		server.SetJob(newPoolJob(height, prevHash, clean))
+	}
+	next(true)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-blocks:
+			slog.Info("🏆 Block found", "worker", s.Worker, "job", s.JobID, "hash", hex.EncodeToString(s.Hash))
+			round := server.EndRound()
+			prevHash = s.Hash
+			next(true)
+			if poolPayouts != "" {
+				if path, err := writeRoundPayouts(s, round); err != nil {
+					slog.Error("Failed to write round payouts", "job", s.JobID, "err", err)
+				} else if path != "" {
+					slog.Info("Round payouts written", "file", path, "pay", "exs-node wallet sendmany <wallet> "+path)
+				}
+			}
+			go func() {
+				ctx, span := tracing.Start(ctx, "pool block",
+					attribute.String("worker", s.Worker),
+					attribute.String("job", s.JobID),
+					attribute.String("block.hash", hex.EncodeToString(s.Hash)))
+				defer span.End()
+				reportForge(ctx, s.Worker, split, s.Hash)
+			}()
+		case <-ticker.C:
+			next(false)
+		}
+	}
+}
+
+// setupPoolPayouts checks --block-reward and --network when --payouts is
+// set and creates the payouts directory
+func setupPoolPayouts() error {
+	if poolPayouts == "" {
+		return nil
+	}
+	reward, err := wallet.ParseAmount(poolReward)
+	if err != nil || reward <= 0 {
+		return fmt.Errorf("--payouts needs a positive --block-reward, got %q", poolReward)
+	}
+	if poolNet, err = bitcoinParams(); err != nil {
+		return err
+	}
+	poolRewardSats = reward
+	return os.MkdirAll(poolPayouts, 0o700)
+}
+
+// writeRoundPayouts splits the block reward over the work of the round
+// block ended and writes it to the payouts directory as a sendmany CSV. It
+// returns the file written, or "" when no worker is paid.
+func writeRoundPayouts(block stratum.Share, round map[string]float64) (string, error) {
+	work := make(map[string]float64, len(round))
+	for worker, w := range round {
+		address, _, _ := strings.Cut(worker, ".")
+		addr, err := btcutil.DecodeAddress(address, poolNet)
+		if err != nil || !addr.IsForNet(poolNet) {
+			slog.Warn("Worker not paid, not named after a payout address", "worker", worker, "network", poolNet.Name)
+			continue
+		}
+		work[addr.EncodeAddress()] += w
+	}
+	payouts := wallet.SplitReward(poolRewardSats, work)
+	if len(payouts) == 0 {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := wallet.WritePayouts(&buf, payouts); err != nil {
+		return "", err
+	}
+	path := filepath.Join(poolPayouts, fmt.Sprintf("round-%s-%x.csv", block.JobID, block.Hash[:4]))
+	return path, os.WriteFile(path, buf.Bytes(), 0o600)
+}
+
+// newPoolJob builds a job committing to the mined data, the previous block
+// hash, the job height and a random tag so every job has unique work
+func newPoolJob(height uint64, prevHash []byte, clean bool) *stratum.Job {
+	tag := make([]byte, 8)
+	rand.Read(tag)
+
+	work := append([]byte(data), prevHash...)
+	work = binary.BigEndian.AppendUint64(work, height)
+	work = append(work, tag...)
+
+	return &stratum.Job{
+		ID:         fmt.Sprintf("%x", height),
+		PrevHash:   hex.EncodeToString(prevHash),
+		Data:       work,
+		Target:     difficulty,
+		Difficulty: poolJobDiff,
+		Clean:      clean,
+	}
+}
+
+func init() {
+	defaults := stratum.DefaultConfig()
+	poolCmd.Flags().StringVarP(&poolListen, "listen", "l", ":3333", "Address to accept miners on")
+	poolCmd.Flags().Uint64VarP(&difficulty, "difficulty", "d", 0x00FFFFFFFFFFFFFF, "Block difficulty target")
+	poolCmd.Flags().StringVarP(&data, "data", "i", "Excalibur-EXS", "Data committed to by every job")
+	poolCmd.Flags().Float64Var(&poolDifficulty, "share-difficulty", defaults.Difficulty, "Initial share difficulty")
+	poolCmd.Flags().Float64Var(&poolJobDiff, "job-difficulty", 0, "Fixed share difficulty for every job (0 = vardiff)")
+	poolCmd.Flags().DurationVar(&poolShareTime, "share-time", defaults.TargetShareTime, "Target interval between shares per miner")
+	poolCmd.Flags().Float64Var(&poolShareRate, "shares-per-minute", 0, "Target shares per minute per miner (overrides --share-time)")
+	poolCmd.Flags().DurationVar(&poolRetarget, "retarget", defaults.RetargetInterval, "Share difficulty retarget interval (0 = off)")
+	poolCmd.Flags().DurationVar(&poolJobInterval, "job-interval", 30*time.Second, "Interval between new jobs")
+	poolCmd.Flags().StringVar(&poolPayouts, "payouts", "", "Directory to write each found block's reward split to, as a CSV for exs-node wallet sendmany")
+	poolCmd.Flags().StringVar(&poolReward, "block-reward", "", "EXS split between the round's workers for every found block (with --payouts)")
+	addTreasuryFlags(poolCmd)
+	addInscribeFlags(poolCmd)
+
+	rootCmd.AddCommand(poolCmd)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/mining/proxy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	proxyChains   string
+	proxyAPI      string
+	proxyState    string
+	proxyInterval time.Duration
+)
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Relay Bitcoin, Litecoin and Dogecoin miners to external pools",
+	Long: `Relay miners of other chains to external Stratum pools for the cross-chain
+mining revenue stream. --chains names a JSON file listing one relay per chain:
+
+  [{"chain": "ltc", "listen": ":3334", "pool": "stratum+tcp://pool:3333",
+    "user": "exs-treasury", "password": "x", "multiplier": 0.25}]
+
+Miners connect to the listen address as they would to the pool; their
+workers are authorized under the pool account. Shares the pool accepts and
+rejects are counted per worker.
+
+Pool payouts are recorded through the API at --api with
+POST /payouts {"chain": "ltc", "txid": "...", "units": 150000000}, converted
+to EXS at the chain's multiplier (EXS per coin) and credited to the treasury
+at --treasury with a Knight token. GET /stats and GET /payouts report the
+relays and payouts.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configs, err := readProxyChains(proxyChains)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		payouts, err := proxy.OpenPayouts(proxyState)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		proxies := make(map[string]*proxy.Proxy, len(configs))
+		for _, c := range configs {
+			if _, ok := proxies[c.Chain]; ok {
+				fmt.Fprintf(os.Stderr, "❌ %s is relayed twice\n", c.Chain)
+				os.Exit(1)
+			}
+			if proxies[c.Chain], err = proxy.New(c); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Println("⛏️  Excalibur-EXS Cross-Chain Mining Proxy")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for _, c := range configs {
+			fmt.Printf("%-5s %s → %s (%s EXS per coin)\n", c.Chain, c.Listen, c.Pool, c.Multiplier)
+		}
+		fmt.Printf("API: %s\n", proxyAPI)
+		if treasuryURL != "" {
+			fmt.Printf("Treasury: %s\n", treasuryURL)
+		}
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		for _, p := range proxies {
+			go func() {
+				if err := p.ListenAndServe(ctx); err != nil {
+					logging.Fatal("Proxy stopped", "chain", p.Config().Chain, "err", err)
+				}
+			}()
+		}
+		if treasuryURL != "" {
+			go creditPayouts(ctx, payouts)
+		}
+
+		server := &http.Server{Addr: proxyAPI, Handler: proxyHandler(proxies, payouts), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("Proxy API stopped", "err", err)
+		}
+	},
+}
+
+// readProxyChains reads the relays listed in the JSON file at path
+func readProxyChains(path string) ([]proxy.ChainConfig, error) {
+	if path == "" {
+		return nil, errors.New("--chains is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []proxy.ChainConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("%s lists no chains", path)
+	}
+	return configs, nil
+}
+
+// proxyHandler serves the relays' statistics and records payouts
+func proxyHandler(proxies map[string]*proxy.Proxy, payouts *proxy.Payouts) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		type chainStats struct {
+			proxy.ChainStats
+			Payouts proxy.PayoutTotals `json:"payouts"`
+		}
+		chains := make([]string, 0, len(proxies))
+		for c := range proxies {
+			chains = append(chains, c)
+		}
+		sort.Strings(chains)
+		stats := make([]chainStats, 0, len(proxies))
+		for _, c := range chains {
+			stats = append(stats, chainStats{ChainStats: proxies[c].Stats(), Payouts: payouts.Totals(c)})
+		}
+		writeProxyJSON(w, http.StatusOK, stats)
+	})
+	mux.HandleFunc("GET /payouts", func(w http.ResponseWriter, r *http.Request) {
+		writeProxyJSON(w, http.StatusOK, payouts.List(r.URL.Query().Get("chain")))
+	})
+	mux.HandleFunc("POST /payouts", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Chain string    `json:"chain"`
+			TxID  string    `json:"txid"`
+			Units int64     `json:"units"`
+			Time  time.Time `json:"time"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid payout: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		p, ok := proxies[req.Chain]
+		if !ok {
+			http.Error(w, fmt.Sprintf("chain %q is not relayed", req.Chain), http.StatusBadRequest)
+			return
+		}
+		if req.Time.IsZero() {
+			req.Time = time.Now()
+		}
+		payout, err := payouts.Record(p.Config(), req.TxID, req.Units, req.Time)
+		switch {
+		case errors.Is(err, proxy.ErrDuplicatePayout):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("💰 Payout recorded", "chain", payout.Chain, "txid", payout.TxID, "units", payout.Units, "exs", payout.Amount)
+		writeProxyJSON(w, http.StatusCreated, payout)
+	})
+	return mux
+}
+
+func writeProxyJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// treasuryCrediter credits payouts through the treasury API
+type treasuryCrediter struct {
+	treasury *client.Treasury
+}
+
+func (c treasuryCrediter) CreditPayout(ctx context.Context, p proxy.Payout) error {
+	_, err := c.treasury.CreditCrossChain(ctx, client.CrossChainReward{
+		Chain:     p.Chain,
+		Index:     p.Index,
+		Units:     p.Units,
+		Amount:    p.Amount,
+		TxID:      p.TxID,
+		Timestamp: p.Time,
+	})
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		return proxy.ErrAlreadyCredited
+	}
+	return err
+}
+
+// creditPayouts credits recorded payouts to the treasury every
+// proxyInterval until ctx is done
+func creditPayouts(ctx context.Context, payouts *proxy.Payouts) {
+	crediter := treasuryCrediter{client.NewTreasury(treasuryURL, client.Options{
+		HTTPClient: treasuryClient,
+		Token:      treasuryToken,
+	})}
+	ticker := time.NewTicker(proxyInterval)
+	defer ticker.Stop()
+	for {
+		n, err := payouts.Credit(ctx, crediter)
+		if n > 0 {
+			slog.Info("✓ Payouts credited to the treasury", "payouts", n)
+		}
+		if err != nil {
+			slog.Warn("Crediting payouts failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func init() {
+	home, _ := os.UserHomeDir()
+	proxyCmd.Flags().StringVar(&proxyChains, "chains", "", "JSON file listing the chains to relay")
+	proxyCmd.Flags().StringVar(&proxyAPI, "api", "127.0.0.1:8091", "Address of the stats and payouts API")
+	proxyCmd.Flags().StringVar(&proxyState, "state", filepath.Join(home, ".excalibur-exs", "proxy-payouts.json"), "File recording pool payouts")
+	proxyCmd.Flags().DurationVar(&proxyInterval, "credit-interval", 30*time.Second, "Interval between crediting payouts to the treasury")
+	proxyCmd.Flags().StringVar(&treasuryURL, "treasury", "", "Treasury API URL to credit payouts to")
+	proxyCmd.Flags().StringVar(&treasuryToken, "treasury-token", "", "Knight bearer token or API key for the treasury's /revenue/cross-chain endpoint")
+
+	rootCmd.AddCommand(proxyCmd)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/mining/stratum"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayBlock     string
+	replayJSON      bool
+	replayAllRounds bool
+	mineResult      string
+)
+
+// replayRoundStep is how often replay prints a round digest without
+// --all-rounds
+const replayRoundStep = 16
+
+// target is a uint64 target written as "0x" hex in JSON, read from hex,
+// decimal or a JSON number
+type target uint64
+
+func (t target) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("0x%016x", uint64(t)))
+}
+
+func (t *target) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return fmt.Errorf("invalid target %s", b)
+	}
+	*t = target(v)
+	return nil
+}
+
+// minedBlock is a mining result as mine --result writes it and replay reads
+// it. Work data is Data as text or DataHex, followed for pool shares by the
+// extranonces.
+type minedBlock struct {
+	Data        string `json:"data,omitempty"`
+	DataHex     string `json:"data_hex,omitempty"`
+	Extranonce1 string `json:"extranonce1,omitempty"`
+	Extranonce2 string `json:"extranonce2,omitempty"`
+	Nonce       uint64 `json:"nonce"`
+	Hash        string `json:"hash,omitempty"`
+	Target      target `json:"target"`
+	// ShareDifficulty, when set, replays the result as a pool share checked
+	// against the share target instead of Target
+	ShareDifficulty float64 `json:"share_difficulty,omitempty"`
+}
+
+// workData returns the data the block's hash was computed over
+func (b *minedBlock) workData() ([]byte, error) {
+	if b.Data != "" && b.DataHex != "" {
+		return nil, errors.New("set data or data_hex, not both")
+	}
+	work := []byte(b.Data)
+	if b.DataHex != "" {
+		var err error
+		if work, err = hex.DecodeString(b.DataHex); err != nil {
+			return nil, fmt.Errorf("invalid data_hex: %w", err)
+		}
+	}
+	for _, en := range []struct{ name, value string }{{"extranonce1", b.Extranonce1}, {"extranonce2", b.Extranonce2}} {
+		raw, err := hex.DecodeString(en.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", en.name, err)
+		}
+		work = append(work, raw...)
+	}
+	return work, nil
+}
+
+// readBlock parses --block as inline JSON, a file path or - for stdin
+func readBlock(arg string) (*minedBlock, error) {
+	raw := []byte(arg)
+	var err error
+	switch {
+	case arg == "-":
+		raw, err = io.ReadAll(os.Stdin)
+	case !strings.HasPrefix(strings.TrimSpace(arg), "{"):
+		raw, err = os.ReadFile(arg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b minedBlock
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, fmt.Errorf("invalid block JSON: %w", err)
+	}
+	return &b, nil
+}
+
+// replayReport is the evidence replay --json prints: the submitted block,
+// every stage of the recomputation, the verdict and the build that replayed
+// it
+type replayReport struct {
+	Verdict     string         `json:"verdict"`
+	Reasons     []string       `json:"reasons,omitempty"`
+	Block       minedBlock     `json:"block"`
+	WorkData    string         `json:"work_data"`
+	Target      target         `json:"target"`
+	SolvesBlock bool           `json:"solves_block"`
+	Input       string         `json:"input"`
+	Seed        string         `json:"hpp1_seed"`
+	Rounds      []string       `json:"rounds"`
+	Hash        string         `json:"hash"`
+	Value       target         `json:"value"`
+	ReplayedAt  time.Time      `json:"replayed_at"`
+	Replayer    buildinfo.Info `json:"replayer"`
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Re-execute Tetra-PoW for a submitted result",
+	Long: `Recompute the Tetra-PoW hash of a submitted result from its work data and
+nonce, record the HPP-1 seed and the digest after every round, and decide
+whether the claimed hash matches and falls below the target. When a pool or
+treasury rejects a result, the --json report is evidence either side can
+reproduce: the first stage that differs shows where the computations part.
+
+--block takes the result JSON inline, as a file path or - for stdin:
+
+  {"data": "Excalibur-EXS", "nonce": 4242, "hash": "…", "target": "0x00ffffffffffffff"}
+
+mine --result writes this form. Pool shares give the job data as data_hex,
+the extranonce1 and extranonce2 and the share_difficulty they were sent at.
+
+Exits with status 1 when the result is invalid.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		block, err := readBlock(replayBlock)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		work, err := block.workData()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		claimed, err := hex.DecodeString(block.Hash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Invalid hash: %v\n", err)
+			os.Exit(1)
+		}
+		if block.Hash == "" {
+			claimed = nil
+		}
+		checked := uint64(block.Target)
+		if block.ShareDifficulty > 0 {
+			checked = stratum.TargetForDifficulty(block.ShareDifficulty)
+		}
+		if checked == 0 {
+			fmt.Fprintln(os.Stderr, "❌ The block needs a target or share_difficulty")
+			os.Exit(1)
+		}
+
+		r := crypto.ReplayResult(work, block.Nonce, checked, claimed)
+		report := newReplayReport(block, r)
+		if replayJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+		} else {
+			printReplay(block, r, report)
+		}
+		if r.Err() != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+// newReplayReport collects the evidence of a replay
+func newReplayReport(block *minedBlock, r *crypto.Replay) *replayReport {
+	report := &replayReport{
+		Verdict:     "valid",
+		Block:       *block,
+		WorkData:    hex.EncodeToString(r.Data),
+		Target:      target(r.Target),
+		SolvesBlock: r.Value < uint64(block.Target),
+		Input:       hex.EncodeToString(r.Trace.Input),
+		Seed:        hex.EncodeToString(r.Trace.Seed),
+		Hash:        hex.EncodeToString(r.Trace.Hash()),
+		Value:       target(r.Value),
+		ReplayedAt:  time.Now().UTC(),
+		Replayer:    buildinfo.Get(),
+	}
+	for _, digest := range r.Trace.Rounds {
+		report.Rounds = append(report.Rounds, hex.EncodeToString(digest))
+	}
+	if err := r.Err(); err != nil {
+		report.Verdict = "invalid"
+		report.Reasons = strings.Split(err.Error(), "\n")
+	}
+	return report
+}
+
+// printReplay shows a replay's stages and verdict
+func printReplay(block *minedBlock, r *crypto.Replay, report *replayReport) {
+	fmt.Println("🔁 Tetra-PoW Replay")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Work data: %s (%d bytes)\n", report.WorkData, len(r.Data))
+	fmt.Printf("Nonce: %d\n", r.Nonce)
+	if block.ShareDifficulty > 0 {
+		fmt.Printf("Share target: 0x%016x (difficulty %.2f)\n", r.Target, block.ShareDifficulty)
+		if block.Target > 0 {
+			fmt.Printf("Block target: 0x%016x\n", uint64(block.Target))
+		}
+	} else {
+		fmt.Printf("Target: 0x%016x\n", r.Target)
+	}
+	if r.Claimed != nil {
+		fmt.Printf("Claimed hash: %s\n", block.Hash)
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Input:      %s\n", report.Input)
+	fmt.Printf("HPP-1 seed: %s\n", report.Seed)
+	for i, digest := range report.Rounds {
+		if replayAllRounds || (i+1)%replayRoundStep == 0 || i == 0 {
+			fmt.Printf("Round %3d:  %s\n", i+1, digest)
+		}
+	}
+	fmt.Printf("Hash:       %s\n", report.Hash)
+	fmt.Printf("Value:      0x%016x\n", r.Value)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	if err := r.Err(); err != nil {
+		fmt.Println("❌ INVALID")
+		for _, reason := range report.Reasons {
+			fmt.Printf("   %s\n", reason)
+		}
+		return
+	}
+	if r.Claimed != nil {
+		fmt.Println("✅ VALID: the claimed hash matches and meets the target")
+	} else {
+		fmt.Println("✅ VALID: the nonce meets the target")
+	}
+	if block.ShareDifficulty > 0 && report.SolvesBlock {
+		fmt.Println("🏆 The share also solves the block target")
+	}
+}
+
+// writeMinedBlock saves a mine result in the form replay reads
+func writeMinedBlock(path string, nonce uint64, hash []byte) error {
+	raw, err := json.MarshalIndent(minedBlock{
+		Data:   data,
+		Nonce:  nonce,
+		Hash:   hex.EncodeToString(hash),
+		Target: target(difficulty),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(raw, '\n'), 0o644)
+}
+
+func init() {
+	replayCmd.Flags().StringVar(&replayBlock, "block", "", "Result JSON to replay: inline, a file path or - for stdin")
+	replayCmd.Flags().BoolVar(&replayJSON, "json", false, "Print the full replay report as JSON evidence")
+	replayCmd.Flags().BoolVar(&replayAllRounds, "all-rounds", false, "Print the digest after every round, not every 16th")
+	replayCmd.MarkFlagRequired("block")
+
+	rootCmd.AddCommand(replayCmd)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/hardware"
+)
+
+var thermalLimit float64
+
+// startThermal throttles acc's CPU workers at thermalLimit, logging
+// whenever the throttled worker count changes. Mining goes on unthrottled
+// when no sensor can be read.
+func startThermal(ctx context.Context, acc *hardware.Accelerator) {
+	if thermalLimit <= 0 {
+		fmt.Println("Thermal Limit: off")
+		return
+	}
+	sensor, err := hardware.DetectSensor(ctx)
+	if err != nil {
+		slog.Warn("Not throttling: no temperature sensor", "limit_celsius", thermalLimit, "err", err)
+		return
+	}
+	last := acc.ActiveWorkers()
+	err = acc.StartThermalMonitor(ctx, hardware.ThermalConfig{
+		Sensor: sensor,
+		Limit:  thermalLimit,
+		OnReading: func(r hardware.ThermalReading, workers int) {
+			if workers == last {
+				return
+			}
+			last = workers
+			slog.Warn("🌡️  Thermal throttling", "reading", r.String(), "limit_celsius", thermalLimit, "workers", workers)
+		},
+	})
+	if err != nil {
+		slog.Warn("Not throttling", "limit_celsius", thermalLimit, "err", err)
+		return
+	}
+	status := acc.Thermal()
+	fmt.Printf("Thermal Limit: %.0f °C (%s, now %s)\n", thermalLimit, status.Sensor, status.Reading)
+}
+
+// printThermal prints every temperature and the CPU clock
+func printThermal(ctx context.Context) {
+	fmt.Println("\n🌡️  Thermal")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	sensor, err := hardware.DetectSensor(ctx)
+	if err != nil {
+		fmt.Printf("Sensors: %v\n", err)
+		return
+	}
+	r, err := sensor.Read(ctx)
+	if err != nil {
+		fmt.Printf("Sensors: %s failed: %v\n", sensor.Name(), err)
+		return
+	}
+	fmt.Printf("Source: %s\n", sensor.Name())
+	for _, t := range r.Temperatures {
+		fmt.Printf("%-28s %.1f °C\n", t.Label+":", t.Celsius)
+	}
+	if r.FrequencyMHz > 0 {
+		fmt.Printf("CPU Clock: %.0f MHz", r.FrequencyMHz)
+		if r.MaxFrequencyMHz > 0 {
+			fmt.Printf(" of %.0f MHz (%.0f%%)", r.MaxFrequencyMHz, 100*r.FrequencyMHz/r.MaxFrequencyMHz)
+		}
+		fmt.Println()
+	}
+}
+
+func init() {
+	mineCmd.Flags().Float64Var(&thermalLimit, "thermal-limit", hardware.DefaultThermalLimit, "Halve CPU workers at this temperature in °C until it falls 5 °C below (0 = off)")
+}
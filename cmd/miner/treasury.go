@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/events"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tracing"
+	"github.com/spf13/cobra"
+)
+
+var (
+	payoutSplit   string
+	treasuryURL   string
+	treasuryToken string
+
+	// emergency mirrors the treasury's emergency breaker while
+	// followEmergency runs
+	emergency, _ = guardian.NewBreaker("", nil, 0)
+
+	// treasuryClient carries the mining trace over to the treasury
+	treasuryClient = &http.Client{Transport: tracing.Transport(nil)}
+)
+
+// addTreasuryFlags registers the flags that report found blocks to the
+// treasury and split their reward between payout addresses
+func addTreasuryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&payoutSplit, "payout", "", "Reward split as address:percent pairs, e.g. bc1p...:90,bc1p...:10")
+	cmd.Flags().StringVar(&treasuryURL, "treasury", "", "Treasury API URL to submit found blocks to")
+	cmd.Flags().StringVar(&treasuryToken, "treasury-token", "", "Bearer token or API key for the treasury's /forge endpoint")
+}
+
+// rewardSplit parses --payout, returning nil when no split is configured
+func rewardSplit() (economy.RewardSplit, error) {
+	if payoutSplit == "" {
+		return nil, nil
+	}
+	return economy.ParseRewardSplit(payoutSplit)
+}
+
+// printSplit shows how a block's miner reward will be divided
+func printSplit(split economy.RewardSplit) {
+	for _, p := range split.Apply(economy.MinerReward) {
+		fmt.Printf("Payout: %s %.2f%% (%s EXS per block)\n", p.Address, p.Percent, p.Amount)
+	}
+}
+
+// submitForge reports a found block to the treasury, crediting the miner
+// reward to split or, without a split, to minerAddress
+func submitForge(ctx context.Context, minerAddress string, split economy.RewardSplit) (*economy.ForgeResult, error) {
+	req := struct {
+		MinerAddress  string              `json:"miner_address"`
+		Beneficiaries economy.RewardSplit `json:"beneficiaries,omitempty"`
+	}{MinerAddress: minerAddress, Beneficiaries: split}
+	if len(split) > 0 {
+		req.MinerAddress = split[0].Address
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(treasuryURL, "/")+"/forge", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if treasuryToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+treasuryToken)
+	}
+
+	resp, err := treasuryClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("treasury unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("treasury rejected forge: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result economy.ForgeResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid treasury response: %w", err)
+	}
+	return &result, nil
+}
+
+// followEmergency mirrors the treasury's emergency halt from its event
+// stream until ctx is cancelled
+func followEmergency(ctx context.Context) {
+	follower := &events.Follower{
+		URL:   strings.TrimSuffix(treasuryURL, "/") + "/events",
+		Token: treasuryToken,
+		OnError: func(err error) {
+			slog.Warn("Treasury events", "err", err)
+		},
+	}
+	follower.Run(ctx, func(e events.Event) {
+		if e.Type != guardian.EventEmergency {
+			return
+		}
+		var state guardian.HaltState
+		if err := e.Decode(&state); err != nil {
+			slog.Warn("Invalid emergency event", "err", err)
+			return
+		}
+		wasHalted := emergency.Check() != nil
+		emergency.Apply(state)
+		if state.Halted == wasHalted {
+			return
+		}
+		if state.Halted {
+			slog.Warn("🛑 EMERGENCY HALT: found blocks are held until the treasury resumes", "halt", state.ID, "by", state.HaltedBy, "reason", state.Reason)
+		} else {
+			slog.Info("✅ Emergency halt lifted", "halt", state.ID)
+		}
+	})
+}
+
+// reportForge submits a found block, with Tetra-PoW hash proof, when
+// --treasury is set and logs the credited payouts, then inscribes the
+// forge's certificate when an inscriber is set up. Nothing is submitted
+// during an emergency halt. The submission is traced as a child of the span
+// in ctx.
+func reportForge(ctx context.Context, minerAddress string, split economy.RewardSplit, proof []byte) {
+	if treasuryURL == "" {
+		return
+	}
+	if err := emergency.Check(); err != nil {
+		slog.Warn("⚠️  Forge not recorded", "err", err)
+		return
+	}
+	result, err := submitForge(ctx, minerAddress, split)
+	if err != nil {
+		slog.Warn("⚠️  Forge not recorded", "err", err)
+		return
+	}
+	var payouts []string
+	for _, p := range result.Payouts {
+		payouts = append(payouts, fmt.Sprintf("%s: %s EXS (%.2f%%)", p.Address, p.Amount, p.Percent))
+	}
+	if len(result.Payouts) == 0 {
+		payouts = append(payouts, fmt.Sprintf("%s: %s EXS", result.MinerAddress, result.MinerReward))
+	}
+	slog.Info("📜 Forge recorded", "forge", result.ForgeID, "height", result.BlockHeight, "payouts", payouts)
+	if inscriber != nil {
+		inscribeForge(ctx, result, proof)
+	}
+}
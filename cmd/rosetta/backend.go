@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Operation types for forge rewards recorded by the treasury ledger
+const (
+	OpTypeForgeReward      = "FORGE_REWARD"
+	OpTypeTreasuryAllocate = "TREASURY_ALLOCATION"
+)
+
+var (
+	// ErrBlockNotFound indicates the backend has no block for an identifier
+	ErrBlockNotFound = errors.New("block not found")
+
+	errBlockNotFound      = APIError{Code: 3, Message: "Block not found", Retriable: true}
+	errBackendUnavailable = APIError{Code: 6, Message: "Chain backend unavailable", Retriable: true}
+)
+
+// PartialBlockIdentifier selects a block by index or hash; neither selects the tip
+type PartialBlockIdentifier struct {
+	Index *int64  `json:"index,omitempty"`
+	Hash  *string `json:"hash,omitempty"`
+}
+
+// BlockRequest is used to get a block
+type BlockRequest struct {
+	NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+	BlockIdentifier   PartialBlockIdentifier `json:"block_identifier"`
+}
+
+// Transaction is a Rosetta transaction
+type Transaction struct {
+	TransactionIdentifier TransactionIdentifier `json:"transaction_identifier"`
+	Operations            []Operation           `json:"operations"`
+}
+
+// Peer is a Rosetta peer
+type Peer struct {
+	PeerID string `json:"peer_id"`
+}
+
+// ChainBackend supplies the chain state served by the Data API
+type ChainBackend interface {
+	// Tip returns the best block and its timestamp in milliseconds
+	Tip(ctx context.Context) (BlockIdentifier, int64, error)
+	// Genesis returns the genesis block
+	Genesis(ctx context.Context) (BlockIdentifier, error)
+	// Block returns the block selected by id, or ErrBlockNotFound
+	Block(ctx context.Context, id PartialBlockIdentifier) (*Block, error)
+	// Balance returns the balance of an address in satoshis at the tip
+	Balance(ctx context.Context, address string) (BlockIdentifier, int64, error)
+	// Peers returns the connected peers
+	Peers(ctx context.Context) ([]Peer, error)
+}
+
+// backend is the chain state used by the Data API handlers
+var backend ChainBackend
+
+// spvBackend serves headers from the SPV client and balances and forge
+// rewards from the treasury ledger
+type spvBackend struct {
+	spv      *bitcoin.SPVClient
+	treasury *economy.Treasury
+}
+
+// NewSPVBackend creates a ChainBackend over an SPV client and treasury ledger
+func NewSPVBackend(spv *bitcoin.SPVClient, treasury *economy.Treasury) ChainBackend {
+	return &spvBackend{spv: spv, treasury: treasury}
+}
+
+func (b *spvBackend) Tip(ctx context.Context) (BlockIdentifier, int64, error) {
+	hash, height := b.spv.GetBestBlock()
+	header, err := b.spv.GetBlockHeader(hash)
+	if err != nil {
+		return BlockIdentifier{}, 0, err
+	}
+	return BlockIdentifier{Index: int64(height), Hash: hash.String()}, header.Timestamp.UnixMilli(), nil
+}
+
+func (b *spvBackend) Genesis(ctx context.Context) (BlockIdentifier, error) {
+	header, err := b.spv.GetBlockHeaderByHeight(0)
+	if err != nil {
+		return BlockIdentifier{}, err
+	}
+	return BlockIdentifier{Index: 0, Hash: header.Hash.String()}, nil
+}
+
+func (b *spvBackend) Block(ctx context.Context, id PartialBlockIdentifier) (*Block, error) {
+	var header *bitcoin.BlockHeaderInfo
+	var err error
+	switch {
+	case id.Hash != nil:
+		hash, parseErr := chainhash.NewHashFromStr(*id.Hash)
+		if parseErr != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBlockNotFound, parseErr)
+		}
+		header, err = b.spv.GetBlockHeader(*hash)
+	case id.Index != nil:
+		if *id.Index < 0 || *id.Index > math.MaxInt32 {
+			return nil, ErrBlockNotFound
+		}
+		header, err = b.spv.GetBlockHeaderByHeight(int32(*id.Index))
+	default:
+		hash, _ := b.spv.GetBestBlock()
+		header, err = b.spv.GetBlockHeader(hash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBlockNotFound, err)
+	}
+	if id.Index != nil && int64(header.Height) != *id.Index {
+		return nil, ErrBlockNotFound
+	}
+
+	// The genesis block is its own parent
+	parent := BlockIdentifier{Index: int64(header.Height), Hash: header.Hash.String()}
+	if header.Height > 0 {
+		parent = BlockIdentifier{Index: int64(header.Height) - 1, Hash: header.PrevBlock.String()}
+	}
+
+	transactions := make([]Transaction, 0)
+	for _, forge := range b.treasury.GetForgesAtHeight(uint32(header.Height)) {
+		transactions = append(transactions, forgeTransaction(forge))
+	}
+
+	return &Block{
+		BlockIdentifier:       BlockIdentifier{Index: int64(header.Height), Hash: header.Hash.String()},
+		ParentBlockIdentifier: parent,
+		Timestamp:             header.Timestamp.UnixMilli(),
+		Transactions:          transactions,
+	}, nil
+}
+
+func (b *spvBackend) Balance(ctx context.Context, address string) (BlockIdentifier, int64, error) {
+	tip, _, err := b.Tip(ctx)
+	if err != nil {
+		return BlockIdentifier{}, 0, err
+	}
+	return tip, int64(b.treasury.GetAddressBalance(address)), nil
+}
+
+func (b *spvBackend) Peers(ctx context.Context) ([]Peer, error) {
+	peers := make([]Peer, 0)
+	for _, peer := range b.spv.GetPeers() {
+		if peer.Connected {
+			peers = append(peers, Peer{PeerID: peer.Address})
+		}
+	}
+	return peers, nil
+}
+
+// forgeTransaction describes a forge reward as a Rosetta transaction. Forge
+// records carry no transaction hash, so the identifier is derived from the
+// forge ID.
+func forgeTransaction(forge economy.ForgeResult) Transaction {
+	id := chainhash.HashH([]byte(fmt.Sprintf("exs-forge/%d", forge.ForgeID)))
+	return Transaction{
+		TransactionIdentifier: TransactionIdentifier{Hash: id.String()},
+		Operations: []Operation{
+			{
+				OperationIdentifier: OperationIdentifier{Index: 0},
+				Type:                OpTypeForgeReward,
+				Status:              "SUCCESS",
+				Account:             &AccountIdentifier{Address: forge.MinerAddress},
+				Amount:              exsAmount(int64(forge.MinerReward)),
+			},
+			{
+				OperationIdentifier: OperationIdentifier{Index: 1},
+				Type:                OpTypeTreasuryAllocate,
+				Status:              "SUCCESS",
+				Account:             &AccountIdentifier{Address: "treasury"},
+				Amount:              exsAmount(int64(forge.TreasuryAllocation)),
+			},
+		},
+	}
+}
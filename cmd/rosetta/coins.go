@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var errInvalidAddress = APIError{Code: 5, Message: "Invalid address", Retriable: false}
+
+// CoinSource supplies the unspent outputs served by /account/coins
+type CoinSource interface {
+	// Coins returns the confirmed unspent outputs paying address and the
+	// block they are current at
+	Coins(ctx context.Context, address string) (BlockIdentifier, []UnspentCoin, error)
+	// MempoolTransactions returns the unconfirmed transactions
+	MempoolTransactions(ctx context.Context) ([]*wire.MsgTx, error)
+}
+
+// coinSource is nil until the server is connected to a node
+var coinSource CoinSource
+
+// UnspentCoin is a confirmed unspent output
+type UnspentCoin struct {
+	OutPoint wire.OutPoint
+	// Value is in satoshis
+	Value    int64
+	Height   int64
+	Coinbase bool
+}
+
+// AccountCoinsRequest selects the coins of an address
+type AccountCoinsRequest struct {
+	NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+	AccountIdentifier AccountIdentifier `json:"account_identifier"`
+	IncludeMempool    bool              `json:"include_mempool"`
+	Currencies        []Currency        `json:"currencies,omitempty"`
+}
+
+// Coin is an unspent output of an account
+type Coin struct {
+	CoinIdentifier CoinIdentifier         `json:"coin_identifier"`
+	Amount         Amount                 `json:"amount"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// AccountCoinsResponse lists the coins of an address
+type AccountCoinsResponse struct {
+	BlockIdentifier BlockIdentifier `json:"block_identifier"`
+	Coins           []Coin          `json:"coins"`
+}
+
+// handleAccountCoins lists the unspent outputs paying an address. With
+// include_mempool, outputs of unconfirmed transactions are added and coins
+// spent by them carry the spending transaction as spent_by.
+func handleAccountCoins(w http.ResponseWriter, r *http.Request) {
+	var req AccountCoinsRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	params := networkParams()
+	addr, err := btcutil.DecodeAddress(req.AccountIdentifier.Address, params)
+	if err == nil && !addr.IsForNet(params) {
+		err = fmt.Errorf("address is not for %s", params.Name)
+	}
+	var pkScript []byte
+	if err == nil {
+		pkScript, err = txscript.PayToAddrScript(addr)
+	}
+	if err != nil {
+		writeError(w, errInvalidAddress, err)
+		return
+	}
+	if coinSource == nil {
+		writeError(w, errBackendUnavailable, fmt.Errorf("no node configured"))
+		return
+	}
+
+	block, confirmed, err := coinSource.Coins(r.Context(), addr.EncodeAddress())
+	if err != nil {
+		writeError(w, errBackendUnavailable, err)
+		return
+	}
+	var txs []*wire.MsgTx
+	if req.IncludeMempool {
+		if txs, err = coinSource.MempoolTransactions(r.Context()); err != nil {
+			writeError(w, errBackendUnavailable, err)
+			return
+		}
+	}
+
+	coins := []Coin{}
+	if wantsEXS(req.Currencies) {
+		coins = accountCoins(confirmed, txs, pkScript)
+	}
+	writeJSON(w, AccountCoinsResponse{BlockIdentifier: block, Coins: coins})
+}
+
+// wantsEXS reports whether a currencies filter admits EXS; an empty filter
+// admits every currency
+func wantsEXS(currencies []Currency) bool {
+	if len(currencies) == 0 {
+		return true
+	}
+	for _, c := range currencies {
+		if c.Symbol == "EXS" {
+			return true
+		}
+	}
+	return false
+}
+
+// accountCoins merges confirmed coins with the outputs to pkScript of the
+// unconfirmed transactions txs, marking each coin an unconfirmed
+// transaction spends
+func accountCoins(confirmed []UnspentCoin, txs []*wire.MsgTx, pkScript []byte) []Coin {
+	spentBy := make(map[wire.OutPoint]chainhash.Hash)
+	for _, tx := range txs {
+		for _, in := range tx.TxIn {
+			spentBy[in.PreviousOutPoint] = tx.TxHash()
+		}
+	}
+
+	coins := make([]Coin, 0, len(confirmed))
+	add := func(op wire.OutPoint, value int64, metadata map[string]interface{}) {
+		if spender, ok := spentBy[op]; ok {
+			metadata["spent_by"] = spender.String()
+		}
+		coins = append(coins, Coin{
+			CoinIdentifier: CoinIdentifier{Identifier: op.String()},
+			Amount:         *exsAmount(value),
+			Metadata:       metadata,
+		})
+	}
+	for _, coin := range confirmed {
+		add(coin.OutPoint, coin.Value, map[string]interface{}{"height": coin.Height, "coinbase": coin.Coinbase})
+	}
+	for _, tx := range txs {
+		txid := tx.TxHash()
+		for i, out := range tx.TxOut {
+			if string(out.PkScript) == string(pkScript) {
+				add(wire.OutPoint{Hash: txid, Index: uint32(i)}, out.Value, map[string]interface{}{"mempool": true})
+			}
+		}
+	}
+	return coins
+}
+
+// Coins scans the node's UTXO set for the coins paying address
+func (n *nodeRPC) Coins(ctx context.Context, address string) (BlockIdentifier, []UnspentCoin, error) {
+	var result struct {
+		Height    int64  `json:"height"`
+		BestBlock string `json:"bestblock"`
+		Unspents  []struct {
+			TxID     string  `json:"txid"`
+			Vout     uint32  `json:"vout"`
+			Amount   float64 `json:"amount"`
+			Coinbase bool    `json:"coinbase"`
+			Height   int64   `json:"height"`
+		} `json:"unspents"`
+	}
+	if err := n.call(ctx, "scantxoutset", &result, "start", []string{"addr(" + address + ")"}); err != nil {
+		return BlockIdentifier{}, nil, err
+	}
+	coins := make([]UnspentCoin, 0, len(result.Unspents))
+	for _, u := range result.Unspents {
+		hash, err := chainhash.NewHashFromStr(u.TxID)
+		if err != nil {
+			return BlockIdentifier{}, nil, fmt.Errorf("node scantxoutset: %v", err)
+		}
+		value, err := btcutil.NewAmount(u.Amount)
+		if err != nil {
+			return BlockIdentifier{}, nil, fmt.Errorf("node scantxoutset: %v", err)
+		}
+		coins = append(coins, UnspentCoin{
+			OutPoint: wire.OutPoint{Hash: *hash, Index: u.Vout},
+			Value:    int64(value),
+			Height:   u.Height,
+			Coinbase: u.Coinbase,
+		})
+	}
+	return BlockIdentifier{Index: result.Height, Hash: result.BestBlock}, coins, nil
+}
+
+// MempoolTransactions returns the node's mempool. Transactions are cached
+// until they leave it, so each is fetched once.
+func (n *nodeRPC) MempoolTransactions(ctx context.Context) ([]*wire.MsgTx, error) {
+	hashes, err := n.Mempool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n.mu.Lock()
+	cached := n.txs
+	n.mu.Unlock()
+
+	current := make(map[chainhash.Hash]*wire.MsgTx, len(hashes))
+	txs := make([]*wire.MsgTx, 0, len(hashes))
+	for _, hash := range hashes {
+		tx, ok := cached[hash]
+		if !ok {
+			var raw string
+			if err := n.call(ctx, "getrawtransaction", &raw, hash.String(), false); err != nil {
+				var rpcErr *btcjson.RPCError
+				if errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCNoTxInfo {
+					// Mined or evicted since the mempool was listed
+					continue
+				}
+				return nil, err
+			}
+			if tx, err = decodeTx(raw); err != nil {
+				return nil, fmt.Errorf("node getrawtransaction %s: %v", hash, err)
+			}
+		}
+		current[hash] = tx
+		txs = append(txs, tx)
+	}
+
+	n.mu.Lock()
+	n.txs = current
+	n.mu.Unlock()
+	return txs, nil
+}
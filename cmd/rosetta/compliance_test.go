@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/rpc"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/compliance")
+
+// complianceBlocks is the height of the deterministic header chain, with
+// forges at complianceForges
+const complianceBlocks = 5
+
+var complianceForges = []uint32{2, 4}
+
+// complianceEnv is a Rosetta server on regtest. Its Data API serves a
+// deterministic header chain with treasury forges, so responses can be
+// compared with golden files, and its node is an in-memory chain for the
+// mempool, coins and submission.
+type complianceEnv struct {
+	t       *testing.T
+	url     string
+	node    *rpc.LocalChain
+	rosetta *client.Rosetta
+	miner   string
+}
+
+func newComplianceEnv(t *testing.T) *complianceEnv {
+	t.Helper()
+	params := &chaincfg.RegressionNetParams
+	network = "regtest"
+
+	spv := bitcoin.NewSPVClient(params)
+	if err := spv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { spv.Stop() })
+	prev := *params.GenesisHash
+	for height := 1; height <= complianceBlocks; height++ {
+		header := complianceHeader(prev, height, params)
+		if err := spv.AddBlockHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		prev = header.BlockHash()
+	}
+
+	env := &complianceEnv{t: t, miner: complianceAddress(t, "miner", params)}
+	treasury := economy.NewTreasury()
+	for _, height := range complianceForges {
+		treasury.SetBlockHeight(height)
+		if treasury.ProcessForge(env.miner) == nil {
+			t.Fatalf("forge at %d failed", height)
+		}
+	}
+	backend = NewSPVBackend(spv, treasury)
+	chainIndex = newBlockIndex(backend)
+
+	store, err := chain.OpenMemory(params, chain.Options{TxIndex: true, AddrIndex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	env.node = rpc.NewLocalChain(store)
+	nodeServer := httptest.NewServer(rpc.NewServer(rpc.Config{Chain: env.node, User: "exs", Password: "secret"}))
+	t.Cleanup(nodeServer.Close)
+	node, err := newNodeRPC(strings.Replace(nodeServer.URL, "http://", "http://exs:secret@", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mempool, submitter, coinSource = node, node, node
+
+	mux := http.NewServeMux()
+	routes(mux.Handle, func(h http.HandlerFunc) http.Handler { return h })
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	env.url = server.URL
+	env.rosetta = client.NewRosetta(server.URL, "regtest", client.Options{})
+	return env
+}
+
+// complianceHeader mines the regtest header at height on prev. Its fields
+// depend on height alone, so the chain is the same on every run.
+func complianceHeader(prev chainhash.Hash, height int, params *chaincfg.Params) *wire.BlockHeader {
+	header := &wire.BlockHeader{
+		Version:    4,
+		PrevBlock:  prev,
+		MerkleRoot: chainhash.HashH([]byte(fmt.Sprintf("exs-compliance/%d", height))),
+		Timestamp:  params.GenesisBlock.Header.Timestamp.Add(time.Duration(height) * 10 * time.Minute),
+		Bits:       params.PowLimitBits,
+	}
+	target := blockchain.CompactToBig(header.Bits)
+	for {
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return header
+		}
+		header.Nonce++
+	}
+}
+
+// complianceKey is a private key derived from name
+func complianceKey(name string) *btcec.PrivateKey {
+	seed := sha256.Sum256([]byte("exs-compliance/" + name))
+	key, _ := btcec.PrivKeyFromBytes(seed[:])
+	return key
+}
+
+func complianceAddress(t *testing.T, name string, params *chaincfg.Params) string {
+	t.Helper()
+	address, err := bitcoin.DeriveTaprootAddress(complianceKey(name).PubKey().SerializeCompressed(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+// post sends body to path and returns the response body
+func (env *complianceEnv) post(path, body string) []byte {
+	env.t.Helper()
+	resp, err := http.Post(env.url+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	return raw
+}
+
+// golden compares a JSON response with testdata/compliance/name.json, or
+// rewrites the file with -update
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, got, "", "  "); err != nil {
+		t.Fatalf("%s: response is not JSON: %s", name, got)
+	}
+	path := filepath.Join("testdata", "compliance", name+".json")
+	if *updateGolden {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s: %v (run go test -update to create it)", name, err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(want), bytes.TrimSpace(buf.Bytes())) {
+		t.Errorf("%s differs from %s:\n%s", name, path, buf.Bytes())
+	}
+}
+
+const regtestNetwork = `"network_identifier": {"blockchain": "Excalibur-ESX", "network": "regtest"}`
+
+func TestComplianceDataAPI(t *testing.T) {
+	env := newComplianceEnv(t)
+	cases := []struct {
+		name, path, body string
+	}{
+		{"network_list", "/network/list", `{}`},
+		{"network_options", "/network/options", `{` + regtestNetwork + `}`},
+		{"network_status", "/network/status", `{` + regtestNetwork + `}`},
+		{"block_genesis", "/block", `{` + regtestNetwork + `, "block_identifier": {"index": 0}}`},
+		{"block_forge", "/block", `{` + regtestNetwork + `, "block_identifier": {"index": 2}}`},
+		{"block_not_found", "/block", `{` + regtestNetwork + `, "block_identifier": {"index": 99}}`},
+		{"account_balance", "/account/balance", `{` + regtestNetwork + `, "account_identifier": {"address": "` + env.miner + `"}}`},
+		{"account_balance_invalid", "/account/balance", `{` + regtestNetwork + `, "account_identifier": {"address": "treasury"}}`},
+		{"account_coins_invalid", "/account/coins", `{` + regtestNetwork + `, "account_identifier": {"address": "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"}}`},
+		{"events_blocks", "/events/blocks", `{` + regtestNetwork + `}`},
+		{"events_blocks_page", "/events/blocks", `{` + regtestNetwork + `, "offset": 4, "limit": 1}`},
+		{"search_forges", "/search/transactions", `{` + regtestNetwork + `, "address": "` + env.miner + `", "type": "FORGE_REWARD", "limit": 1}`},
+		{"search_invalid", "/search/transactions", `{` + regtestNetwork + `, "operator": "xor"}`},
+		{"mempool_transaction_not_found", "/mempool/transaction", `{` + regtestNetwork + `, "transaction_identifier": {"hash": "` + strings.Repeat("00", 32) + `"}}`},
+	}
+	for _, c := range cases {
+		golden(t, c.name, env.post(c.path, c.body))
+	}
+}
+
+func TestComplianceConstructionAPI(t *testing.T) {
+	env := newComplianceEnv(t)
+	key := complianceKey("sender")
+	pubKey := hex.EncodeToString(key.PubKey().SerializeCompressed())
+
+	derive := env.post("/construction/derive", `{`+regtestNetwork+`, "public_key": {"hex_bytes": "`+pubKey+`", "curve_type": "secp256k1"}}`)
+	golden(t, "construction_derive", derive)
+	var derived ConstructionDeriveResponse
+	json.Unmarshal(derive, &derived)
+	sender := derived.AccountIdentifier.Address
+
+	ops := fmt.Sprintf(`[
+		{"operation_identifier": {"index": 0}, "type": "INPUT", "account": {"address": %q},
+		 "amount": {"value": "-100000000", "currency": {"symbol": "EXS", "decimals": 8}},
+		 "coin_change": {"coin_identifier": {"identifier": "%s:0"}, "coin_action": "coin_spent"}},
+		{"operation_identifier": {"index": 1}, "type": "OUTPUT", "account": {"address": %q},
+		 "amount": {"value": "60000000", "currency": {"symbol": "EXS", "decimals": 8}}},
+		{"operation_identifier": {"index": 2}, "type": "OUTPUT", "account": {"address": %q},
+		 "amount": {"value": "39998890", "currency": {"symbol": "EXS", "decimals": 8}}}
+	]`, sender, strings.Repeat("11", 32), complianceAddress(t, "recipient", &chaincfg.RegressionNetParams), sender)
+
+	preprocess := env.post("/construction/preprocess", `{`+regtestNetwork+`, "operations": `+ops+`}`)
+	golden(t, "construction_preprocess", preprocess)
+	var options ConstructionPreprocessResponse
+	json.Unmarshal(preprocess, &options)
+	optionsJSON, _ := json.Marshal(options.Options)
+
+	metadata := env.post("/construction/metadata", `{`+regtestNetwork+`, "options": `+string(optionsJSON)+`}`)
+	golden(t, "construction_metadata", metadata)
+
+	payloadsRaw := env.post("/construction/payloads", `{`+regtestNetwork+`, "operations": `+ops+`, "metadata": {"fee_rate": 10}}`)
+	golden(t, "construction_payloads", payloadsRaw)
+	var payloads client.Payloads
+	json.Unmarshal(payloadsRaw, &payloads)
+
+	golden(t, "construction_parse_unsigned", env.post("/construction/parse",
+		fmt.Sprintf(`{%s, "signed": false, "transaction": %q}`, regtestNetwork, payloads.UnsignedTransaction)))
+
+	sigs, err := client.SignPayloads(key, payloads.Payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigsJSON, _ := json.Marshal(sigs)
+	combine := env.post("/construction/combine",
+		fmt.Sprintf(`{%s, "unsigned_transaction": %q, "signatures": %s}`, regtestNetwork, payloads.UnsignedTransaction, sigsJSON))
+	golden(t, "construction_combine", combine)
+	var combined ConstructionCombineResponse
+	json.Unmarshal(combine, &combined)
+
+	golden(t, "construction_parse_signed", env.post("/construction/parse",
+		fmt.Sprintf(`{%s, "signed": true, "transaction": %q}`, regtestNetwork, combined.SignedTransaction)))
+	golden(t, "construction_hash", env.post("/construction/hash",
+		fmt.Sprintf(`{%s, "signed_transaction": %q}`, regtestNetwork, combined.SignedTransaction)))
+
+	// A forged signature is refused
+	sigs[0].HexBytes = strings.Repeat("00", 64)
+	sigsJSON, _ = json.Marshal(sigs)
+	golden(t, "construction_combine_invalid", env.post("/construction/combine",
+		fmt.Sprintf(`{%s, "unsigned_transaction": %q, "signatures": %s}`, regtestNetwork, payloads.UnsignedTransaction, sigsJSON)))
+}
+
+// TestComplianceTransfer funds an account on the node, then spends a coin
+// through the Construction API and follows it through the mempool
+// endpoints and /account/coins into a block
+func TestComplianceTransfer(t *testing.T) {
+	env := newComplianceEnv(t)
+	ctx := context.Background()
+	params := &chaincfg.RegressionNetParams
+	key := complianceKey("sender")
+
+	sender, err := env.rosetta.Derive(ctx, client.PublicKey{HexBytes: hex.EncodeToString(key.PubKey().SerializeCompressed()), CurveType: client.CurveSecp256k1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, _ := btcutil.DecodeAddress(sender, params)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	if _, err := env.node.Generate(101, pkScript); err != nil {
+		t.Fatal(err)
+	}
+
+	coins, err := env.rosetta.AccountCoins(ctx, sender, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coins.Coins) != 101 || coins.BlockIdentifier.Index != 101 {
+		t.Fatalf("AccountCoins() = %d coins at %d, want 101 at 101", len(coins.Coins), coins.BlockIdentifier.Index)
+	}
+	var coin client.Coin
+	for _, c := range coins.Coins {
+		if c.Metadata["height"] == float64(1) {
+			coin = c
+		}
+	}
+	value, err := coin.Amount.Sats()
+	if err != nil || value != 50*btcutil.SatoshiPerBitcoin {
+		t.Fatalf("mature coin %+v", coin)
+	}
+
+	recipient := complianceAddress(t, "recipient", params)
+	ops := func(change int64) []client.Operation {
+		return []client.Operation{
+			{OperationIdentifier: client.OperationIdentifier{Index: 0}, Type: client.OpTypeInput,
+				Account: &client.AccountIdentifier{Address: sender}, Amount: client.NewAmount(-value),
+				CoinChange: &client.CoinChange{CoinIdentifier: coin.CoinIdentifier, CoinAction: client.CoinSpent}},
+			{OperationIdentifier: client.OperationIdentifier{Index: 1}, Type: client.OpTypeOutput,
+				Account: &client.AccountIdentifier{Address: recipient}, Amount: client.NewAmount(value / 2)},
+			{OperationIdentifier: client.OperationIdentifier{Index: 2}, Type: client.OpTypeOutput,
+				Account: &client.AccountIdentifier{Address: sender}, Amount: client.NewAmount(change)},
+		}
+	}
+	options, err := env.rosetta.Preprocess(ctx, ops(value/2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := env.rosetta.Metadata(ctx, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, _ := metadata.SuggestedFee[0].Sats()
+	change := value/2 - fee
+	payloads, err := env.rosetta.Payloads(ctx, ops(change), metadata.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigs, err := client.SignPayloads(key, payloads.Payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := env.rosetta.Combine(ctx, payloads.UnsignedTransaction, sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := env.rosetta.Hash(ctx, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitted, err := env.rosetta.Submit(ctx, signed)
+	if err != nil || submitted != hash {
+		t.Fatalf("Submit() = %s, %v, want %s", submitted, err, hash)
+	}
+
+	pending, err := env.rosetta.Mempool(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Hash != hash {
+		t.Fatalf("Mempool() = %+v, %v, want %s", pending, err, hash)
+	}
+	tx, err := env.rosetta.MempoolTransaction(ctx, hash)
+	if err != nil || len(tx.Operations) != 3 || tx.Operations[0].CoinChange.CoinIdentifier != coin.CoinIdentifier {
+		t.Fatalf("MempoolTransaction() = %+v, %v", tx, err)
+	}
+	coins, err = env.rosetta.AccountCoins(ctx, sender, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent, unconfirmed := 0, 0
+	for _, c := range coins.Coins {
+		if c.Metadata["spent_by"] == hash && c.CoinIdentifier == coin.CoinIdentifier {
+			spent++
+		}
+		if c.Metadata["mempool"] == true && c.CoinIdentifier.Identifier == hash+":1" {
+			unconfirmed++
+		}
+	}
+	if spent != 1 || unconfirmed != 1 || len(coins.Coins) != 102 {
+		t.Errorf("AccountCoins() with the mempool = %+v", coins.Coins)
+	}
+
+	if _, err := env.node.Generate(1, pkScript); err != nil {
+		t.Fatal(err)
+	}
+	coins, err = env.rosetta.AccountCoins(ctx, recipient, true)
+	if err != nil || len(coins.Coins) != 1 || coins.Coins[0].CoinIdentifier.Identifier != hash+":0" || coins.Coins[0].Metadata["height"] != float64(102) {
+		t.Errorf("recipient coins after mining = %+v, %v", coins, err)
+	}
+}
+
+// TestRosettaCLI runs the official rosetta-cli data check against the
+// compliance server when rosetta-cli is on the PATH. The construction check
+// also runs when EXS_ROSETTA_CLI_CONSTRUCTION is set.
+func TestRosettaCLI(t *testing.T) {
+	cli, err := exec.LookPath("rosetta-cli")
+	if err != nil {
+		t.Skip("rosetta-cli is not installed")
+	}
+	env := newComplianceEnv(t)
+	dir := t.TempDir()
+
+	tmpl, err := template.ParseFiles(filepath.Join("testdata", "rosetta-cli", "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, _ := filepath.Abs(filepath.Join("testdata", "rosetta-cli"))
+	var config bytes.Buffer
+	err = tmpl.Execute(&config, map[string]string{
+		"URL":      env.url,
+		"DataDir":  filepath.Join(dir, "data"),
+		"Fixtures": fixtures,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, config.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []string{"check:data"}
+	if os.Getenv("EXS_ROSETTA_CLI_CONSTRUCTION") != "" {
+		checks = append(checks, "check:construction")
+	}
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		out, err := exec.CommandContext(ctx, cli, check, "--configuration-file", configPath).CombinedOutput()
+		cancel()
+		if err != nil {
+			t.Errorf("rosetta-cli %s failed: %v\n%s", check, err, out)
+		}
+	}
+}
@@ -0,0 +1,812 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Operation types used by the Construction API. EXS follows the UTXO model,
+// so a transfer is a set of INPUT operations spending coins and OUTPUT
+// operations creating them.
+const (
+	OpTypeInput  = "INPUT"
+	OpTypeOutput = "OUTPUT"
+
+	CoinSpent   = "coin_spent"
+	CoinCreated = "coin_created"
+
+	CurveSecp256k1      = "secp256k1"
+	SignatureSchnorr340 = "schnorr_bip340"
+)
+
+// Size estimates in virtual bytes for fee calculation
+const (
+	txOverheadVSize   = 11 // version, locktime, counts and segwit marker
+	taprootInputVSize = 58 // outpoint, sequence and a 64-byte key-path witness
+	outputBaseVSize   = 9  // value and script length
+)
+
+// Default fee rate in satoshis per virtual byte
+var feeRate int64 = 10
+
+// Default confirmation target in blocks of estimated fee rates
+const defaultConfirmationTarget = 6
+
+// Construction API errors
+var (
+	ErrInvalidPublicKey   = APIError{Code: 10, Message: "Invalid public key", Retriable: false}
+	ErrInvalidOperations  = APIError{Code: 11, Message: "Invalid operations", Retriable: false}
+	ErrInvalidTransaction = APIError{Code: 12, Message: "Invalid transaction", Retriable: false}
+	ErrInvalidSignature   = APIError{Code: 13, Message: "Invalid signature", Retriable: false}
+	ErrBroadcastFailed    = APIError{Code: 14, Message: "Unable to broadcast transaction", Retriable: true}
+)
+
+// constructionErrors lists the errors advertised by /network/options
+var constructionErrors = []APIError{
+	ErrInvalidPublicKey,
+	ErrInvalidOperations,
+	ErrInvalidTransaction,
+	ErrInvalidSignature,
+	ErrBroadcastFailed,
+}
+
+// TransactionSubmitter relays signed transactions to the network
+type TransactionSubmitter interface {
+	SubmitTransaction(ctx context.Context, tx *wire.MsgTx) error
+}
+
+// submitter is nil until the server is connected to a chain backend
+var submitter TransactionSubmitter
+
+// FeeEstimator estimates the fee rate to confirm within target blocks
+type FeeEstimator interface {
+	EstimateSmartFee(ctx context.Context, target int, mode fees.Mode) (fees.Estimate, error)
+}
+
+// feeEstimator is nil until the server is connected to a node;
+// /construction/metadata then suggests --fee-rate
+var feeEstimator FeeEstimator
+
+// PublicKey is a Rosetta public key
+type PublicKey struct {
+	HexBytes  string `json:"hex_bytes"`
+	CurveType string `json:"curve_type"`
+}
+
+// OperationIdentifier identifies an operation within a transaction
+type OperationIdentifier struct {
+	Index int64 `json:"index"`
+}
+
+// CoinIdentifier identifies a UTXO as "txid:vout"
+type CoinIdentifier struct {
+	Identifier string `json:"identifier"`
+}
+
+// CoinChange records a coin created or spent by an operation
+type CoinChange struct {
+	CoinIdentifier CoinIdentifier `json:"coin_identifier"`
+	CoinAction     string         `json:"coin_action"`
+}
+
+// Operation is a single balance-changing step of a transaction
+type Operation struct {
+	OperationIdentifier OperationIdentifier `json:"operation_identifier"`
+	Type                string              `json:"type"`
+	Status              string              `json:"status,omitempty"`
+	Account             *AccountIdentifier  `json:"account,omitempty"`
+	Amount              *Amount             `json:"amount,omitempty"`
+	CoinChange          *CoinChange         `json:"coin_change,omitempty"`
+	// Metadata describes the script: script_type, and for a signed Taproot
+	// input spend_path, "key" or "script"
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// SigningPayload is a message that must be signed by an account
+type SigningPayload struct {
+	AccountIdentifier *AccountIdentifier `json:"account_identifier,omitempty"`
+	HexBytes          string             `json:"hex_bytes"`
+	SignatureType     string             `json:"signature_type"`
+}
+
+// Signature is a signed SigningPayload
+type Signature struct {
+	SigningPayload SigningPayload `json:"signing_payload"`
+	PublicKey      PublicKey      `json:"public_key"`
+	SignatureType  string         `json:"signature_type"`
+	HexBytes       string         `json:"hex_bytes"`
+}
+
+// TransactionIdentifier identifies a transaction by hash
+type TransactionIdentifier struct {
+	Hash string `json:"hash"`
+}
+
+// ConstructionDeriveRequest derives an address from a public key
+type ConstructionDeriveRequest struct {
+	NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+	PublicKey         PublicKey         `json:"public_key"`
+}
+
+// ConstructionDeriveResponse contains the derived Taproot account
+type ConstructionDeriveResponse struct {
+	AccountIdentifier AccountIdentifier `json:"account_identifier"`
+}
+
+// ConstructionPreprocessRequest carries the intended operations
+type ConstructionPreprocessRequest struct {
+	NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+	Operations        []Operation       `json:"operations"`
+	// Metadata may set confirmation_target and fee_mode for the fee
+	// estimate
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ConstructionPreprocessResponse contains options for /construction/metadata
+type ConstructionPreprocessResponse struct {
+	Options map[string]interface{} `json:"options"`
+}
+
+// ConstructionMetadataRequest carries options from /construction/preprocess
+type ConstructionMetadataRequest struct {
+	NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+	Options           map[string]interface{} `json:"options"`
+}
+
+// ConstructionMetadataResponse contains the fee rate and suggested fee
+type ConstructionMetadataResponse struct {
+	Metadata     map[string]interface{} `json:"metadata"`
+	SuggestedFee []Amount               `json:"suggested_fee"`
+}
+
+// ConstructionPayloadsRequest carries the operations to build
+type ConstructionPayloadsRequest struct {
+	NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+	Operations        []Operation            `json:"operations"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ConstructionPayloadsResponse contains the unsigned transaction and sighashes
+type ConstructionPayloadsResponse struct {
+	UnsignedTransaction string           `json:"unsigned_transaction"`
+	Payloads            []SigningPayload `json:"payloads"`
+}
+
+// ConstructionParseRequest carries a transaction to decode
+type ConstructionParseRequest struct {
+	NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+	Signed            bool              `json:"signed"`
+	Transaction       string            `json:"transaction"`
+}
+
+// ConstructionParseResponse contains the decoded operations
+type ConstructionParseResponse struct {
+	Operations               []Operation         `json:"operations"`
+	AccountIdentifierSigners []AccountIdentifier `json:"account_identifier_signers,omitempty"`
+}
+
+// ConstructionCombineRequest carries an unsigned transaction and signatures
+type ConstructionCombineRequest struct {
+	NetworkIdentifier   NetworkIdentifier `json:"network_identifier"`
+	UnsignedTransaction string            `json:"unsigned_transaction"`
+	Signatures          []Signature       `json:"signatures"`
+}
+
+// ConstructionCombineResponse contains the signed transaction
+type ConstructionCombineResponse struct {
+	SignedTransaction string `json:"signed_transaction"`
+}
+
+// ConstructionHashRequest carries a signed transaction to hash
+type ConstructionHashRequest struct {
+	NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+	SignedTransaction string            `json:"signed_transaction"`
+}
+
+// ConstructionSubmitRequest carries a signed transaction to broadcast
+type ConstructionSubmitRequest = ConstructionHashRequest
+
+// TransactionIdentifierResponse identifies a hashed or submitted transaction
+type TransactionIdentifierResponse struct {
+	TransactionIdentifier TransactionIdentifier `json:"transaction_identifier"`
+}
+
+// constructionTx is the opaque transaction blob passed between construction
+// endpoints. Taproot sighashes commit to every prevout, so the spent amounts
+// and addresses travel with the transaction.
+type constructionTx struct {
+	Transaction    string   `json:"transaction"`
+	InputAmounts   []int64  `json:"input_amounts"`
+	InputAddresses []string `json:"input_addresses"`
+}
+
+func networkParams() *chaincfg.Params {
+	switch network {
+	case "testnet":
+		return &chaincfg.TestNet3Params
+	case "regtest":
+		return &chaincfg.RegressionNetParams
+	default:
+		return &chaincfg.MainNetParams
+	}
+}
+
+func exsAmount(value int64) *Amount {
+	return &Amount{
+		Value:    strconv.FormatInt(value, 10),
+		Currency: Currency{Symbol: "EXS", Decimals: 8},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Failed to encode response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, apiErr APIError, err error) {
+	if err != nil {
+		apiErr.Description = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(apiErr)
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIError{
+			Code:        400,
+			Message:     "Invalid request format",
+			Retriable:   false,
+			Description: err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+func handleConstructionDerive(w http.ResponseWriter, r *http.Request) {
+	var req ConstructionDeriveRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if req.PublicKey.CurveType != CurveSecp256k1 {
+		writeError(w, ErrInvalidPublicKey, fmt.Errorf("unsupported curve type %q", req.PublicKey.CurveType))
+		return
+	}
+	pubKey, err := hex.DecodeString(req.PublicKey.HexBytes)
+	if err != nil {
+		writeError(w, ErrInvalidPublicKey, err)
+		return
+	}
+
+	address, err := bitcoin.DeriveTaprootAddress(pubKey, networkParams())
+	if err != nil {
+		writeError(w, ErrInvalidPublicKey, err)
+		return
+	}
+	writeJSON(w, ConstructionDeriveResponse{
+		AccountIdentifier: AccountIdentifier{Address: address},
+	})
+}
+
+func handleConstructionPreprocess(w http.ResponseWriter, r *http.Request) {
+	var req ConstructionPreprocessRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	tx, _, _, err := buildTransaction(req.Operations, networkParams())
+	if err != nil {
+		writeError(w, ErrInvalidOperations, err)
+		return
+	}
+	options := map[string]interface{}{
+		"estimated_size": estimateVSize(tx),
+	}
+	for _, key := range []string{"confirmation_target", "fee_mode"} {
+		if value, ok := req.Metadata[key]; ok {
+			options[key] = value
+		}
+	}
+	writeJSON(w, ConstructionPreprocessResponse{Options: options})
+}
+
+func handleConstructionMetadata(w http.ResponseWriter, r *http.Request) {
+	var req ConstructionMetadataRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	size, ok := req.Options["estimated_size"].(float64)
+	if !ok || size <= 0 {
+		writeError(w, ErrInvalidOperations, fmt.Errorf("missing estimated_size option"))
+		return
+	}
+	rate, metadata, err := constructionFeeRate(r.Context(), req.Options)
+	if err != nil {
+		writeError(w, ErrInvalidOperations, err)
+		return
+	}
+	writeJSON(w, ConstructionMetadataResponse{
+		Metadata:     metadata,
+		SuggestedFee: []Amount{*exsAmount(int64(size) * rate)},
+	})
+}
+
+// constructionFeeRate returns the fee rate for the confirmation_target and
+// fee_mode options and the metadata describing it: the node's estimate
+// when connected to one, else --fee-rate
+func constructionFeeRate(ctx context.Context, options map[string]interface{}) (int64, map[string]interface{}, error) {
+	target := defaultConfirmationTarget
+	if value, ok := options["confirmation_target"]; ok {
+		blocks, ok := value.(float64)
+		if !ok || blocks < 1 || blocks != float64(int(blocks)) {
+			return 0, nil, fmt.Errorf("confirmation_target must be a whole number of blocks")
+		}
+		target = int(blocks)
+	}
+	modeName, _ := options["fee_mode"].(string)
+	mode, err := fees.ParseMode(modeName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	fallback := map[string]interface{}{"fee_rate": feeRate}
+	if feeEstimator == nil {
+		return feeRate, fallback, nil
+	}
+	estimate, err := feeEstimator.EstimateSmartFee(ctx, target, mode)
+	if err != nil {
+		slog.Warn("Fee estimation failed, suggesting --fee-rate", "err", err)
+		return feeRate, fallback, nil
+	}
+	rate := estimate.SatsPerVByte()
+	return rate, map[string]interface{}{
+		"fee_rate":            rate,
+		"confirmation_target": estimate.Target,
+		"fee_mode":            string(estimate.Mode),
+		"fee_source":          estimate.Source,
+	}, nil
+}
+
+func handleConstructionPayloads(w http.ResponseWriter, r *http.Request) {
+	var req ConstructionPayloadsRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	params := networkParams()
+	tx, amounts, addresses, err := buildTransaction(req.Operations, params)
+	if err != nil {
+		writeError(w, ErrInvalidOperations, err)
+		return
+	}
+
+	fetcher, err := prevOutFetcher(tx, amounts, addresses, params)
+	if err != nil {
+		writeError(w, ErrInvalidOperations, err)
+		return
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+
+	payloads := make([]SigningPayload, len(tx.TxIn))
+	for i := range tx.TxIn {
+		hash, err := txscript.CalcTaprootSignatureHash(sigHashes, txscript.SigHashDefault, tx, i, fetcher)
+		if err != nil {
+			writeError(w, ErrInvalidOperations, err)
+			return
+		}
+		payloads[i] = SigningPayload{
+			AccountIdentifier: &AccountIdentifier{Address: addresses[i]},
+			HexBytes:          hex.EncodeToString(hash),
+			SignatureType:     SignatureSchnorr340,
+		}
+	}
+
+	unsigned, err := encodeConstructionTx(tx, amounts, addresses)
+	if err != nil {
+		writeError(w, ErrInvalidTransaction, err)
+		return
+	}
+	writeJSON(w, ConstructionPayloadsResponse{
+		UnsignedTransaction: unsigned,
+		Payloads:            payloads,
+	})
+}
+
+func handleConstructionParse(w http.ResponseWriter, r *http.Request) {
+	var req ConstructionParseRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	tx, blob, err := decodeConstructionTx(req.Transaction)
+	if err != nil {
+		writeError(w, ErrInvalidTransaction, err)
+		return
+	}
+
+	params := networkParams()
+	fetcher, err := prevOutFetcher(tx, blob.InputAmounts, blob.InputAddresses, params)
+	if err != nil {
+		writeError(w, ErrInvalidTransaction, err)
+		return
+	}
+	ops := transactionOperations(tx, fetcher, params)
+
+	response := ConstructionParseResponse{Operations: ops}
+	if req.Signed {
+		seen := make(map[string]bool)
+		for _, address := range blob.InputAddresses {
+			if !seen[address] {
+				seen[address] = true
+				response.AccountIdentifierSigners = append(response.AccountIdentifierSigners, AccountIdentifier{Address: address})
+			}
+		}
+	}
+	writeJSON(w, response)
+}
+
+func handleConstructionCombine(w http.ResponseWriter, r *http.Request) {
+	var req ConstructionCombineRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	tx, blob, err := decodeConstructionTx(req.UnsignedTransaction)
+	if err != nil {
+		writeError(w, ErrInvalidTransaction, err)
+		return
+	}
+	if len(req.Signatures) != len(tx.TxIn) {
+		writeError(w, ErrInvalidSignature, fmt.Errorf("expected %d signatures, got %d", len(tx.TxIn), len(req.Signatures)))
+		return
+	}
+
+	params := networkParams()
+	fetcher, err := prevOutFetcher(tx, blob.InputAmounts, blob.InputAddresses, params)
+	if err != nil {
+		writeError(w, ErrInvalidTransaction, err)
+		return
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+
+	for i, sig := range req.Signatures {
+		if err := verifyInputSignature(tx, i, sigHashes, fetcher, sig); err != nil {
+			writeError(w, ErrInvalidSignature, fmt.Errorf("input %d: %w", i, err))
+			return
+		}
+		raw, _ := hex.DecodeString(sig.HexBytes)
+		tx.TxIn[i].Witness = wire.TxWitness{raw}
+	}
+
+	signed, err := encodeConstructionTx(tx, blob.InputAmounts, blob.InputAddresses)
+	if err != nil {
+		writeError(w, ErrInvalidTransaction, err)
+		return
+	}
+	writeJSON(w, ConstructionCombineResponse{SignedTransaction: signed})
+}
+
+func handleConstructionHash(w http.ResponseWriter, r *http.Request) {
+	var req ConstructionHashRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	tx, _, err := decodeConstructionTx(req.SignedTransaction)
+	if err != nil {
+		writeError(w, ErrInvalidTransaction, err)
+		return
+	}
+	writeJSON(w, TransactionIdentifierResponse{
+		TransactionIdentifier: TransactionIdentifier{Hash: tx.TxHash().String()},
+	})
+}
+
+func handleConstructionSubmit(w http.ResponseWriter, r *http.Request) {
+	var req ConstructionSubmitRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	tx, _, err := decodeConstructionTx(req.SignedTransaction)
+	if err != nil {
+		writeError(w, ErrInvalidTransaction, err)
+		return
+	}
+	for i, in := range tx.TxIn {
+		if len(in.Witness) == 0 {
+			writeError(w, ErrInvalidTransaction, fmt.Errorf("input %d is not signed", i))
+			return
+		}
+	}
+
+	if submitter == nil {
+		writeError(w, ErrBroadcastFailed, fmt.Errorf("no chain backend configured"))
+		return
+	}
+	if err := submitter.SubmitTransaction(r.Context(), tx); err != nil {
+		writeError(w, ErrBroadcastFailed, err)
+		return
+	}
+	writeJSON(w, TransactionIdentifierResponse{
+		TransactionIdentifier: TransactionIdentifier{Hash: tx.TxHash().String()},
+	})
+}
+
+// transactionOperations decomposes tx into an INPUT operation per coin
+// spent and an OUTPUT operation per coin created. fetcher supplies the
+// outputs the inputs spend.
+func transactionOperations(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher, params *chaincfg.Params) []Operation {
+	var ops []Operation
+	for _, in := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(in.PreviousOutPoint)
+		if prevOut == nil {
+			prevOut = &wire.TxOut{}
+		}
+		metadata := scriptMetadata(prevOut.PkScript)
+		if metadata["script_type"] == txscript.WitnessV1TaprootTy.String() && len(in.Witness) > 0 {
+			metadata["spend_path"] = taprootSpendPath(in.Witness)
+		}
+		ops = append(ops, Operation{
+			OperationIdentifier: OperationIdentifier{Index: int64(len(ops))},
+			Type:                OpTypeInput,
+			Account:             &AccountIdentifier{Address: scriptAddress(prevOut.PkScript, params)},
+			Amount:              exsAmount(-prevOut.Value),
+			CoinChange: &CoinChange{
+				CoinIdentifier: CoinIdentifier{Identifier: in.PreviousOutPoint.String()},
+				CoinAction:     CoinSpent,
+			},
+			Metadata: metadata,
+		})
+	}
+	txHash := tx.TxHash()
+	for i, out := range tx.TxOut {
+		ops = append(ops, Operation{
+			OperationIdentifier: OperationIdentifier{Index: int64(len(ops))},
+			Type:                OpTypeOutput,
+			Account:             &AccountIdentifier{Address: scriptAddress(out.PkScript, params)},
+			Amount:              exsAmount(out.Value),
+			CoinChange: &CoinChange{
+				CoinIdentifier: CoinIdentifier{Identifier: wire.NewOutPoint(&txHash, uint32(i)).String()},
+				CoinAction:     CoinCreated,
+			},
+			Metadata: scriptMetadata(out.PkScript),
+		})
+	}
+	return ops
+}
+
+// scriptAddress returns the address pkScript pays, or "" for scripts
+// without one
+func scriptAddress(pkScript []byte, params *chaincfg.Params) string {
+	if _, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, params); err == nil && len(addrs) == 1 {
+		return addrs[0].EncodeAddress()
+	}
+	return ""
+}
+
+// scriptMetadata describes the type of pkScript
+func scriptMetadata(pkScript []byte) map[string]interface{} {
+	return map[string]interface{}{"script_type": txscript.GetScriptClass(pkScript).String()}
+}
+
+// taprootSpendPath returns "key" for a Taproot key-path witness, a single
+// signature, and "script" for a script-path one, which ends in a script
+// and its control block. An annex (BIP 341) is not counted.
+func taprootSpendPath(witness wire.TxWitness) string {
+	n := len(witness)
+	if n >= 2 && len(witness[n-1]) > 0 && witness[n-1][0] == txscript.TaprootAnnexTag {
+		n--
+	}
+	if n == 1 {
+		return "key"
+	}
+	return "script"
+}
+
+// buildTransaction converts INPUT and OUTPUT operations into an unsigned
+// transaction, returning the spent amounts and addresses in input order
+func buildTransaction(ops []Operation, params *chaincfg.Params) (*wire.MsgTx, []int64, []string, error) {
+	tx := wire.NewMsgTx(2)
+	var amounts []int64
+	var addresses []string
+	var inputTotal, outputTotal int64
+
+	for _, op := range ops {
+		if op.Account == nil || op.Amount == nil {
+			return nil, nil, nil, fmt.Errorf("operation %d: account and amount are required", op.OperationIdentifier.Index)
+		}
+		if op.Amount.Currency.Symbol != "EXS" || op.Amount.Currency.Decimals != 8 {
+			return nil, nil, nil, fmt.Errorf("operation %d: unsupported currency %s", op.OperationIdentifier.Index, op.Amount.Currency.Symbol)
+		}
+		value, err := strconv.ParseInt(op.Amount.Value, 10, 64)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("operation %d: invalid amount: %w", op.OperationIdentifier.Index, err)
+		}
+
+		switch op.Type {
+		case OpTypeInput:
+			if value >= 0 {
+				return nil, nil, nil, fmt.Errorf("operation %d: input amount must be negative", op.OperationIdentifier.Index)
+			}
+			if op.CoinChange == nil || op.CoinChange.CoinAction != CoinSpent {
+				return nil, nil, nil, fmt.Errorf("operation %d: input must spend a coin", op.OperationIdentifier.Index)
+			}
+			if !bitcoin.VerifyTaprootAddress(op.Account.Address) {
+				return nil, nil, nil, fmt.Errorf("operation %d: only Taproot inputs can be signed", op.OperationIdentifier.Index)
+			}
+			outpoint, err := parseCoinIdentifier(op.CoinChange.CoinIdentifier.Identifier)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("operation %d: %w", op.OperationIdentifier.Index, err)
+			}
+			tx.AddTxIn(wire.NewTxIn(outpoint, nil, nil))
+			amounts = append(amounts, -value)
+			addresses = append(addresses, op.Account.Address)
+			inputTotal += -value
+
+		case OpTypeOutput:
+			if value <= 0 {
+				return nil, nil, nil, fmt.Errorf("operation %d: output amount must be positive", op.OperationIdentifier.Index)
+			}
+			addr, err := btcutil.DecodeAddress(op.Account.Address, params)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("operation %d: invalid address: %w", op.OperationIdentifier.Index, err)
+			}
+			pkScript, err := txscript.PayToAddrScript(addr)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("operation %d: %w", op.OperationIdentifier.Index, err)
+			}
+			tx.AddTxOut(wire.NewTxOut(value, pkScript))
+			outputTotal += value
+
+		default:
+			return nil, nil, nil, fmt.Errorf("operation %d: unsupported type %q", op.OperationIdentifier.Index, op.Type)
+		}
+	}
+
+	if len(tx.TxIn) == 0 || len(tx.TxOut) == 0 {
+		return nil, nil, nil, fmt.Errorf("at least one input and one output are required")
+	}
+	if inputTotal < outputTotal {
+		return nil, nil, nil, fmt.Errorf("inputs %d do not cover outputs %d", inputTotal, outputTotal)
+	}
+	return tx, amounts, addresses, nil
+}
+
+// parseCoinIdentifier parses a "txid:vout" coin identifier
+func parseCoinIdentifier(id string) (*wire.OutPoint, error) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid coin identifier %q", id)
+	}
+	hash, err := chainhash.NewHashFromStr(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid coin identifier %q: %w", id, err)
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coin identifier %q: %w", id, err)
+	}
+	return wire.NewOutPoint(hash, uint32(index)), nil
+}
+
+// estimateVSize estimates the signed size of a key-path-only transaction
+func estimateVSize(tx *wire.MsgTx) int64 {
+	size := int64(txOverheadVSize + taprootInputVSize*len(tx.TxIn))
+	for _, out := range tx.TxOut {
+		size += int64(outputBaseVSize + len(out.PkScript))
+	}
+	return size
+}
+
+func prevOutFetcher(tx *wire.MsgTx, amounts []int64, addresses []string, params *chaincfg.Params) (*txscript.MultiPrevOutFetcher, error) {
+	if len(amounts) != len(tx.TxIn) || len(addresses) != len(tx.TxIn) {
+		return nil, fmt.Errorf("prevout data does not match inputs")
+	}
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, in := range tx.TxIn {
+		addr, err := btcutil.DecodeAddress(addresses[i], params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input address: %w", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		fetcher.AddPrevOut(in.PreviousOutPoint, wire.NewTxOut(amounts[i], pkScript))
+	}
+	return fetcher, nil
+}
+
+// verifyInputSignature checks a BIP-340 signature against the input's
+// Taproot output key. Signers must sign with the BIP-86 tweaked private key.
+func verifyInputSignature(tx *wire.MsgTx, idx int, sigHashes *txscript.TxSigHashes, fetcher *txscript.MultiPrevOutFetcher, sig Signature) error {
+	if sig.SignatureType != SignatureSchnorr340 {
+		return fmt.Errorf("unsupported signature type %q", sig.SignatureType)
+	}
+	raw, err := hex.DecodeString(sig.HexBytes)
+	if err != nil || len(raw) != schnorr.SignatureSize {
+		return fmt.Errorf("signature must be %d bytes of hex", schnorr.SignatureSize)
+	}
+	parsed, err := schnorr.ParseSignature(raw)
+	if err != nil {
+		return err
+	}
+
+	prevOut := fetcher.FetchPrevOutput(tx.TxIn[idx].PreviousOutPoint)
+	if !txscript.IsPayToTaproot(prevOut.PkScript) {
+		return fmt.Errorf("input is not a Taproot output")
+	}
+	outputKey, err := schnorr.ParsePubKey(prevOut.PkScript[2:])
+	if err != nil {
+		return err
+	}
+	hash, err := txscript.CalcTaprootSignatureHash(sigHashes, txscript.SigHashDefault, tx, idx, fetcher)
+	if err != nil {
+		return err
+	}
+	if !parsed.Verify(hash, outputKey) {
+		return fmt.Errorf("signature does not verify")
+	}
+	return nil
+}
+
+func encodeConstructionTx(tx *wire.MsgTx, amounts []int64, addresses []string) (string, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(constructionTx{
+		Transaction:    hex.EncodeToString(buf.Bytes()),
+		InputAmounts:   amounts,
+		InputAddresses: addresses,
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+func decodeConstructionTx(encoded string) (*wire.MsgTx, *constructionTx, error) {
+	data, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("transaction is not hex: %w", err)
+	}
+	var blob constructionTx
+	if err := json.Unmarshal(data, &blob); err != nil {
+		return nil, nil, fmt.Errorf("malformed transaction: %w", err)
+	}
+	raw, err := hex.DecodeString(blob.Transaction)
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed transaction: %w", err)
+	}
+	tx := wire.NewMsgTx(2)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, nil, fmt.Errorf("malformed transaction: %w", err)
+	}
+	if len(blob.InputAmounts) != len(tx.TxIn) || len(blob.InputAddresses) != len(tx.TxIn) {
+		return nil, nil, fmt.Errorf("prevout data does not match inputs")
+	}
+	for _, amount := range blob.InputAmounts {
+		if amount <= 0 || amount > btcutil.MaxSatoshi {
+			return nil, nil, fmt.Errorf("invalid input amount %d", amount)
+		}
+	}
+	return tx, &blob, nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Block event types
+const (
+	BlockAdded   = "block_added"
+	BlockRemoved = "block_removed"
+)
+
+const (
+	// defaultIndexerLimit is the page size of the indexer endpoints when a
+	// request has no limit
+	defaultIndexerLimit = 100
+	// maxIndexerLimit bounds the page size of the indexer endpoints
+	maxIndexerLimit = 1000
+	// indexInterval is how often the block index follows the chain between
+	// indexer requests, which sync it themselves
+	indexInterval = 5 * time.Second
+)
+
+var errInvalidSearch = APIError{Code: 8, Message: "Invalid indexer request", Retriable: false}
+
+// BlockEvent records a block joining or leaving the main chain
+type BlockEvent struct {
+	Sequence        int64           `json:"sequence"`
+	BlockIdentifier BlockIdentifier `json:"block_identifier"`
+	Type            string          `json:"type"`
+}
+
+// EventsBlocksRequest pages through the block events
+type EventsBlocksRequest struct {
+	NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+	Offset            *int64            `json:"offset,omitempty"`
+	Limit             *int64            `json:"limit,omitempty"`
+}
+
+// EventsBlocksResponse lists block events in sequence order
+type EventsBlocksResponse struct {
+	MaxSequence int64        `json:"max_sequence"`
+	Events      []BlockEvent `json:"events"`
+}
+
+// SearchTransactionsRequest selects transactions of the main chain. Set
+// conditions must all match with the "and" operator, the default, and at
+// least one with "or".
+type SearchTransactionsRequest struct {
+	NetworkIdentifier     NetworkIdentifier      `json:"network_identifier"`
+	Operator              string                 `json:"operator,omitempty"`
+	MaxBlock              *int64                 `json:"max_block,omitempty"`
+	Offset                *int64                 `json:"offset,omitempty"`
+	Limit                 *int64                 `json:"limit,omitempty"`
+	TransactionIdentifier *TransactionIdentifier `json:"transaction_identifier,omitempty"`
+	AccountIdentifier     *AccountIdentifier     `json:"account_identifier,omitempty"`
+	CoinIdentifier        *CoinIdentifier        `json:"coin_identifier,omitempty"`
+	Currency              *Currency              `json:"currency,omitempty"`
+	Status                *string                `json:"status,omitempty"`
+	Type                  *string                `json:"type,omitempty"`
+	Address               *string                `json:"address,omitempty"`
+	Success               *bool                  `json:"success,omitempty"`
+}
+
+// BlockTransaction is a transaction and the block containing it
+type BlockTransaction struct {
+	BlockIdentifier BlockIdentifier `json:"block_identifier"`
+	Transaction     Transaction     `json:"transaction"`
+}
+
+// SearchTransactionsResponse lists matching transactions, newest first
+type SearchTransactionsResponse struct {
+	Transactions []BlockTransaction `json:"transactions"`
+	TotalCount   int64              `json:"total_count"`
+	NextOffset   *int64             `json:"next_offset,omitempty"`
+}
+
+// indexedBlock is a main chain block and its transactions
+type indexedBlock struct {
+	id           BlockIdentifier
+	transactions []Transaction
+}
+
+// blockIndex follows the backend's main chain. Every block it connects or
+// disconnects is recorded as a numbered event, and the transactions of the
+// connected blocks are kept for search. Block contents are read once, when
+// the block is connected. The index lives in memory: after a restart it
+// replays the chain from genesis, which numbers an unchanged chain's
+// events the same way.
+type blockIndex struct {
+	backend ChainBackend
+
+	// syncMu serializes syncs, which alone change chain
+	syncMu sync.Mutex
+
+	mu     sync.RWMutex
+	chain  []indexedBlock
+	events []BlockEvent
+}
+
+// chainIndex is the block index of the indexer endpoints
+var chainIndex *blockIndex
+
+func newBlockIndex(backend ChainBackend) *blockIndex {
+	return &blockIndex{backend: backend}
+}
+
+// run syncs the index every interval until ctx is done
+func (x *blockIndex) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := x.sync(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Block index sync failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync moves the index to the backend's tip, disconnecting the blocks of
+// an abandoned branch and connecting those of the new one
+func (x *blockIndex) sync(ctx context.Context) error {
+	x.syncMu.Lock()
+	defer x.syncMu.Unlock()
+
+	tip, _, err := x.backend.Tip(ctx)
+	if err != nil {
+		return err
+	}
+	// chain only changes under syncMu, so it is read without mu here
+	if n := len(x.chain); n > 0 && x.chain[n-1].id == tip {
+		return nil
+	}
+
+	// Walk back from the tip to the last block the index has; fork is the
+	// height of the first block that differs
+	var added []indexedBlock
+	var fork int64
+	hash := tip.Hash
+	for {
+		block, err := x.backend.Block(ctx, PartialBlockIdentifier{Hash: &hash})
+		if err != nil {
+			return fmt.Errorf("block %s: %w", hash, err)
+		}
+		i := block.BlockIdentifier.Index
+		if i < int64(len(x.chain)) && x.chain[i].id == block.BlockIdentifier {
+			fork = i + 1
+			break
+		}
+		added = append(added, indexedBlock{id: block.BlockIdentifier, transactions: block.Transactions})
+		if i == 0 {
+			break
+		}
+		if block.ParentBlockIdentifier.Index != i-1 {
+			return fmt.Errorf("block %s at %d has parent at %d", hash, i, block.ParentBlockIdentifier.Index)
+		}
+		hash = block.ParentBlockIdentifier.Hash
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for i := int64(len(x.chain)) - 1; i >= fork; i-- {
+		x.record(BlockRemoved, x.chain[i].id)
+	}
+	x.chain = x.chain[:fork]
+	for i := len(added) - 1; i >= 0; i-- {
+		x.chain = append(x.chain, added[i])
+		x.record(BlockAdded, added[i].id)
+	}
+	return nil
+}
+
+// record appends an event with the next sequence number
+func (x *blockIndex) record(eventType string, id BlockIdentifier) {
+	x.events = append(x.events, BlockEvent{Sequence: int64(len(x.events)), BlockIdentifier: id, Type: eventType})
+}
+
+// Events returns up to limit events from sequence offset, and the highest
+// sequence, -1 before the first event
+func (x *blockIndex) Events(offset, limit int64) ([]BlockEvent, int64) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	max := int64(len(x.events)) - 1
+	if offset > max {
+		return []BlockEvent{}, max
+	}
+	end := offset + limit
+	if end > max+1 {
+		end = max + 1
+	}
+	events := make([]BlockEvent, end-offset)
+	copy(events, x.events[offset:end])
+	return events, max
+}
+
+// Search returns the main chain transactions at or below maxBlock that
+// match, newest first
+func (x *blockIndex) Search(maxBlock int64, match func(*Transaction) bool) []BlockTransaction {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if maxBlock >= int64(len(x.chain)) {
+		maxBlock = int64(len(x.chain)) - 1
+	}
+	var found []BlockTransaction
+	for i := maxBlock; i >= 0; i-- {
+		block := x.chain[i]
+		for j := len(block.transactions) - 1; j >= 0; j-- {
+			if match(&block.transactions[j]) {
+				found = append(found, BlockTransaction{BlockIdentifier: block.id, Transaction: block.transactions[j]})
+			}
+		}
+	}
+	return found
+}
+
+// page checks a request's offset and limit and applies their defaults
+func page(offset, limit *int64) (int64, int64, error) {
+	o, l := int64(0), int64(defaultIndexerLimit)
+	if offset != nil {
+		if o = *offset; o < 0 {
+			return 0, 0, fmt.Errorf("offset %d is negative", o)
+		}
+	}
+	if limit != nil {
+		if l = *limit; l <= 0 || l > maxIndexerLimit {
+			return 0, 0, fmt.Errorf("limit %d is not between 1 and %d", l, maxIndexerLimit)
+		}
+	}
+	return o, l, nil
+}
+
+func handleEventsBlocks(w http.ResponseWriter, r *http.Request) {
+	var req EventsBlocksRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	offset, limit, err := page(req.Offset, req.Limit)
+	if err != nil {
+		writeError(w, errInvalidSearch, err)
+		return
+	}
+	if err := chainIndex.sync(r.Context()); err != nil {
+		writeError(w, errBackendUnavailable, err)
+		return
+	}
+	events, max := chainIndex.Events(offset, limit)
+	writeJSON(w, EventsBlocksResponse{MaxSequence: max, Events: events})
+}
+
+func handleSearchTransactions(w http.ResponseWriter, r *http.Request) {
+	var req SearchTransactionsRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	offset, limit, err := page(req.Offset, req.Limit)
+	if err == nil {
+		err = checkSearch(&req)
+	}
+	if err != nil {
+		writeError(w, errInvalidSearch, err)
+		return
+	}
+	if err := chainIndex.sync(r.Context()); err != nil {
+		writeError(w, errBackendUnavailable, err)
+		return
+	}
+
+	maxBlock := int64(1<<63 - 1)
+	if req.MaxBlock != nil {
+		maxBlock = *req.MaxBlock
+	}
+	found := chainIndex.Search(maxBlock, func(tx *Transaction) bool { return searchMatch(&req, tx) })
+	response := SearchTransactionsResponse{Transactions: []BlockTransaction{}, TotalCount: int64(len(found))}
+	if offset < int64(len(found)) {
+		end := offset + limit
+		if end < int64(len(found)) {
+			response.NextOffset = &end
+		} else {
+			end = int64(len(found))
+		}
+		response.Transactions = found[offset:end]
+	}
+	writeJSON(w, response)
+}
+
+// checkSearch validates the operator and max_block of a search
+func checkSearch(req *SearchTransactionsRequest) error {
+	switch req.Operator {
+	case "":
+		req.Operator = "and"
+	case "and", "or":
+	default:
+		return fmt.Errorf("operator %q is not and or or", req.Operator)
+	}
+	if req.MaxBlock != nil && *req.MaxBlock < 0 {
+		return errors.New("max_block is negative")
+	}
+	return nil
+}
+
+// searchMatch reports whether tx meets the conditions of req. A search
+// without conditions matches every transaction.
+func searchMatch(req *SearchTransactionsRequest, tx *Transaction) bool {
+	var conditions []bool
+	if req.TransactionIdentifier != nil {
+		conditions = append(conditions, tx.TransactionIdentifier.Hash == req.TransactionIdentifier.Hash)
+	}
+	anyOp := func(f func(op *Operation) bool) bool {
+		for i := range tx.Operations {
+			if f(&tx.Operations[i]) {
+				return true
+			}
+		}
+		return false
+	}
+	address := func(address string) bool {
+		return anyOp(func(op *Operation) bool { return op.Account != nil && op.Account.Address == address })
+	}
+	if req.AccountIdentifier != nil {
+		conditions = append(conditions, address(req.AccountIdentifier.Address))
+	}
+	if req.Address != nil {
+		conditions = append(conditions, address(*req.Address))
+	}
+	if req.CoinIdentifier != nil {
+		conditions = append(conditions, anyOp(func(op *Operation) bool {
+			return op.CoinChange != nil && op.CoinChange.CoinIdentifier == *req.CoinIdentifier
+		}))
+	}
+	if req.Currency != nil {
+		conditions = append(conditions, anyOp(func(op *Operation) bool {
+			return op.Amount != nil && op.Amount.Currency == *req.Currency
+		}))
+	}
+	if req.Status != nil {
+		conditions = append(conditions, anyOp(func(op *Operation) bool { return op.Status == *req.Status }))
+	}
+	if req.Type != nil {
+		conditions = append(conditions, anyOp(func(op *Operation) bool { return op.Type == *req.Type }))
+	}
+	if req.Success != nil {
+		// A transaction succeeds when its operations do
+		succeeded := !anyOp(func(op *Operation) bool { return op.Status != "SUCCESS" })
+		conditions = append(conditions, succeeded == *req.Success)
+	}
+
+	if len(conditions) == 0 {
+		return true
+	}
+	for _, ok := range conditions {
+		if req.Operator == "or" && ok {
+			return true
+		}
+		if req.Operator == "and" && !ok {
+			return false
+		}
+	}
+	return req.Operator == "and"
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/health"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+)
+
+// Health states reported by /health, from best to worst
+const (
+	healthHealthy   = "healthy"
+	healthDegraded  = "degraded"
+	healthUnhealthy = "unhealthy"
+)
+
+// Machine-readable reasons a dependency is not healthy
+const (
+	reasonTimeout           = "timeout"
+	reasonTipUnavailable    = "tip_unavailable"
+	reasonTipStale          = "tip_stale"
+	reasonNoPeers           = "no_peers"
+	reasonLedgerWriteFailed = "ledger_write_failed"
+	reasonStoreUnavailable  = "store_unavailable"
+)
+
+var (
+	// healthTimeout bounds each dependency check
+	healthTimeout = 2 * time.Second
+	// maxTipAge is how old the chain tip may be before the chain is degraded
+	maxTipAge = 2 * time.Hour
+	// healthChecks are the dependencies /health probes, set up by serve
+	healthChecks []healthCheck
+)
+
+// healthCheck probes one dependency. A critical dependency that is unhealthy
+// makes the server unhealthy; any other only degrades it.
+type healthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) DependencyHealth
+}
+
+// DependencyHealth is the state of one dependency in /health
+type DependencyHealth struct {
+	Status    string   `json:"status"`
+	Critical  bool     `json:"critical"`
+	LatencyMS float64  `json:"latency_ms"`
+	Reasons   []string `json:"reasons,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// degrade records reason and lowers the status to at least status
+func (d *DependencyHealth) degrade(status, reason string) {
+	d.Reasons = append(d.Reasons, reason)
+	if healthRank(status) > healthRank(d.Status) {
+		d.Status = status
+	}
+}
+
+func healthRank(status string) int {
+	switch status {
+	case healthDegraded:
+		return 1
+	case healthUnhealthy:
+		return 2
+	}
+	return 0
+}
+
+// chainCheck reports the backend unhealthy without a tip, and degraded
+// without peers or with a tip older than maxTipAge
+func chainCheck(chain ChainBackend) healthCheck {
+	return healthCheck{Name: "chain", Critical: true, Check: func(ctx context.Context) DependencyHealth {
+		health := DependencyHealth{Status: healthHealthy}
+		_, timestamp, err := chain.Tip(ctx)
+		if err != nil {
+			health.degrade(healthUnhealthy, reasonTipUnavailable)
+			health.Error = err.Error()
+			return health
+		}
+		if age := time.Since(time.UnixMilli(timestamp)); age > maxTipAge {
+			health.degrade(healthDegraded, reasonTipStale)
+		}
+		if peers, err := chain.Peers(ctx); err != nil || len(peers) == 0 {
+			health.degrade(healthDegraded, reasonNoPeers)
+		}
+		return health
+	}}
+}
+
+// treasuryCheck reports the treasury unhealthy once its ledger fails a write
+func treasuryCheck(treasury *economy.Treasury) healthCheck {
+	return healthCheck{Name: "treasury", Critical: true, Check: func(ctx context.Context) DependencyHealth {
+		if err := treasury.LedgerErr(); err != nil {
+			return DependencyHealth{Status: healthUnhealthy, Reasons: []string{reasonLedgerWriteFailed}, Error: err.Error()}
+		}
+		return DependencyHealth{Status: healthHealthy}
+	}}
+}
+
+// datastoreCheck reports whether the Guardian store answers. Only the
+// construction endpoints need it, so it is not critical.
+func datastoreCheck(guard *guardian.Guardian) healthCheck {
+	return healthCheck{Name: "datastore", Check: func(ctx context.Context) DependencyHealth {
+		if err := guard.CheckStore(); err != nil {
+			return DependencyHealth{Status: healthUnhealthy, Reasons: []string{reasonStoreUnavailable}, Error: err.Error()}
+		}
+		return DependencyHealth{Status: healthHealthy}
+	}}
+}
+
+// readinessCheck adapts check to /readyz: the dependency is not ready when
+// it is unhealthy, or for the chain while its tip is stale during sync
+func readinessCheck(check healthCheck) health.Check {
+	return func(ctx context.Context) error {
+		dependency := runCheck(ctx, check)
+		if dependency.Status == healthUnhealthy {
+			if dependency.Error != "" {
+				return errors.New(dependency.Error)
+			}
+			return errors.New(strings.Join(dependency.Reasons, ", "))
+		}
+		if slices.Contains(dependency.Reasons, reasonTipStale) {
+			return errors.New("chain tip is stale, still syncing")
+		}
+		return nil
+	}
+}
+
+// runCheck runs check with healthTimeout, timing it. A check still running
+// at the deadline is reported unhealthy and left to finish in the background.
+func runCheck(ctx context.Context, check healthCheck) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan DependencyHealth, 1)
+	go func() { done <- check.Check(ctx) }()
+
+	var health DependencyHealth
+	select {
+	case health = <-done:
+	case <-ctx.Done():
+		health = DependencyHealth{Status: healthUnhealthy, Reasons: []string{reasonTimeout}, Error: ctx.Err().Error()}
+	}
+	health.Critical = check.Critical
+	health.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	return health
+}
+
+// handleHealth reports each dependency and the overall status: unhealthy,
+// with 503, when a critical dependency is, degraded when any dependency is
+// not healthy
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	dependencies := make(map[string]DependencyHealth, len(healthChecks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			health := runCheck(r.Context(), check)
+			mu.Lock()
+			dependencies[check.Name] = health
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status := healthHealthy
+	reasons := make([]string, 0)
+	for _, check := range healthChecks {
+		health := dependencies[check.Name]
+		for _, reason := range health.Reasons {
+			reasons = append(reasons, check.Name+":"+reason)
+		}
+		effect := health.Status
+		if effect == healthUnhealthy && !check.Critical {
+			effect = healthDegraded
+		}
+		if healthRank(effect) > healthRank(status) {
+			status = effect
+		}
+	}
+
+	response := map[string]interface{}{
+		"status":       status,
+		"reasons":      reasons,
+		"dependencies": dependencies,
+		"version":      "0.1.0",
+		"network":      network,
+		"tetra_pow":    "active",
+		"hpp1_rounds":  crypto.HPP1Rounds,
+		"build":        buildinfo.Get(),
+	}
+	if updates != nil {
+		response["update"] = updates.Status()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status == healthUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "err", err)
+	}
+}
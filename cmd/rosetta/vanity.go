@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+var (
+	vanityPrefix     string
+	vanityWorkers    int
+	vanityCheckpoint string
+)
+
+var vanityCmd = &cobra.Command{
+	Use:   "vanity",
+	Short: "Find a vault address with a chosen prefix",
+	Long: `Grind the receive addresses of a seed's BIP-86 wallet, m/86'/coin'/0'/0/i,
+for one starting with --prefix. The address is derived from the seed, not
+from a random key, so the seed and the printed index recover it.
+
+Each prefix character multiplies the expected work by 32. Progress is saved
+to a checkpoint that holds no key material; interrupt with Ctrl-C and run
+the same command again to resume.
+
+Examples:
+  # Find bc1pexs... for a new random seed
+  rosetta vanity --prefix exs --seed new
+
+  # Resume, or search an existing seed
+  rosetta vanity --prefix exs --seed "your 13 words here" --key-out vault.key`,
+	Run: func(cmd *cobra.Command, args []string) {
+		params := networkParams()
+		prefix, err := wallet.VanityPrefix(vanityPrefix, params)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		words, err := vanitySeed()
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		scheme := wallet.SeedBIP39
+		if legacySeed {
+			scheme = wallet.SeedLegacy
+		}
+		seed, err := wallet.Seed(words, "", scheme)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		hd, err := wallet.NewHDWalletFromSeed(seed, params)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		search := &wallet.VanitySearch{Wallet: hd, Branch: wallet.ReceiveBranch, Prefix: prefix, Workers: vanityWorkers}
+		path := vanityCheckpoint
+		if path == "" {
+			if path, err = defaultVanityCheckpoint(hd, prefix); err != nil {
+				fmt.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		var earlier uint64
+		if c, err := wallet.LoadVanityCheckpoint(path); err == nil {
+			if err := search.Resume(c); err != nil {
+				fmt.Printf("❌ Error: %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			earlier = c.Tried
+		} else if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		expected := wallet.VanityTries(prefix, params)
+		fmt.Println("🔎 Vanity Vault Search")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Prefix:     %s\n", prefix)
+		fmt.Printf("Network:    %s\n", network)
+		fmt.Printf("Wallet:     %08x\n", hd.Fingerprint())
+		fmt.Printf("Expected:   %.0f addresses\n", expected)
+		fmt.Printf("Checkpoint: %s\n", path)
+		if search.Start > 0 {
+			fmt.Printf("Resuming:   index %d (%d tried before)\n", search.Start, earlier)
+		}
+		if customSeed == "new" {
+			fmt.Printf("Seed:       %s\n", strings.Join(words, " "))
+			fmt.Println("            Pass this seed with --seed to resume")
+		}
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+		var saveErr error
+		search.Progress = func(p wallet.VanityProgress) {
+			saveErr = search.Checkpoint(p, earlier).Save(path)
+			rate := float64(p.Tried) / p.Elapsed.Seconds()
+			eta := "-"
+			if remaining := expected - float64(earlier+p.Tried); rate > 0 && remaining > 0 {
+				eta = (time.Duration(remaining/rate) * time.Second).Round(time.Second).String()
+			}
+			fmt.Printf("\r⛏️  %d tried, %.0f addr/s, next index %d, expected in %s   ",
+				earlier+p.Tried, rate, p.Next, eta)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		match, err := search.Run(ctx)
+		fmt.Println()
+		if saveErr != nil {
+			fmt.Printf("⚠️  Checkpoint not saved: %v\n", saveErr)
+		}
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("⏸️  Interrupted; run the same command to resume")
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Remove(path)
+
+		fmt.Println("\n🔱 Vanity Vault Found")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("Address:  %s\n", match.Address)
+		fmt.Printf("Index:    %d (receive branch)\n", match.Index)
+		if customSeed == "new" {
+			fmt.Printf("Seed:     %s\n", strings.Join(words, " "))
+		}
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Println("\n⚠️  IMPORTANT: The seed and index recover this address. A restored")
+		fmt.Println("   wallet only scans --gap-limit addresses past its last used one,")
+		fmt.Println("   so keep the index or the spend key.")
+
+		if vaultKeyOut != "" {
+			key, err := hd.TaprootKey(wallet.ReceiveBranch, match.Index)
+			if err != nil {
+				fmt.Printf("❌ Error deriving spend key: %v\n", err)
+				os.Exit(1)
+			}
+			spendKey, err := key.Encode(params)
+			if err != nil {
+				fmt.Printf("❌ Error encoding spend key: %v\n", err)
+				os.Exit(1)
+			}
+			keyFile := fmt.Sprintf("# Spend key for vault %s (index %d)\n%s\n", match.Address, match.Index, spendKey)
+			if err := os.WriteFile(vaultKeyOut, []byte(keyFile), 0600); err != nil {
+				fmt.Printf("❌ Error writing spend key: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("\n🔐 Spend key written to %s\n", vaultKeyOut)
+			fmt.Println("   Use it with: exs-node wallet sign --keys " + vaultKeyOut)
+		}
+	},
+}
+
+// vanitySeed returns the seed to search, a new one for --seed new
+func vanitySeed() ([]string, error) {
+	switch customSeed {
+	case "":
+		return nil, errors.New("--seed is required: the canonical prophecy axiom is public")
+	case "new":
+		lang, err := crypto.LanguageByName(seedLanguage)
+		if err != nil {
+			return nil, err
+		}
+		return lang.NewProphecy()
+	default:
+		words, err := parseSeed(customSeed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed: %w", err)
+		}
+		return words, nil
+	}
+}
+
+// parseSeed parses a --seed prophecy, without its checksum for --legacy-seed
+func parseSeed(phrase string) ([]string, error) {
+	if legacySeed {
+		return crypto.ParseLegacyProphecy(phrase)
+	}
+	words, err := crypto.ParseProphecy(phrase)
+	if errors.Is(err, crypto.ErrChecksum) {
+		return nil, fmt.Errorf("%w (use --legacy-seed for a seed from before prophecy checksums)", err)
+	}
+	return words, err
+}
+
+// defaultVanityCheckpoint returns the checkpoint path for a search, unique
+// to the wallet, network and prefix
+func defaultVanityCheckpoint(hd *wallet.HDWallet, prefix string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%08x-%s-%s.json", hd.Fingerprint(), network, prefix)
+	return filepath.Join(home, ".excalibur-exs", "vanity", name), nil
+}
+
+func init() {
+	vanityCmd.Flags().StringVar(&vanityPrefix, "prefix", "", "Address prefix to find, with or without bc1p")
+	vanityCmd.Flags().StringVarP(&network, "network", "n", "mainnet", "Network (mainnet/testnet/regtest)")
+	vanityCmd.Flags().StringVarP(&customSeed, "seed", "s", "", "13-word seed to search, or \"new\" for a random one")
+	vanityCmd.Flags().BoolVar(&legacySeed, "legacy-seed", false, "Accept a --seed from before prophecy checksums and derive its keys as then")
+	vanityCmd.Flags().StringVar(&seedLanguage, "language", crypto.English.Name, "BIP-39 wordlist for a new seed")
+	vanityCmd.Flags().IntVarP(&vanityWorkers, "workers", "w", 0, "Number of worker threads (0 = all cores)")
+	vanityCmd.Flags().StringVar(&vanityCheckpoint, "checkpoint", "", "Checkpoint file (default is $HOME/.excalibur-exs/vanity/<wallet>-<network>-<prefix>.json)")
+	vanityCmd.Flags().StringVar(&vaultKeyOut, "key-out", "", "Write the spend key (WIF) of the found address to this file")
+	vanityCmd.MarkFlagRequired("prefix")
+
+	rootCmd.AddCommand(vanityCmd)
+}
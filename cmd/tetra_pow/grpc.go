@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+)
+
+// mineProgressInterval is how often Mine streams progress
+var mineProgressInterval = time.Second
+
+// minerService serves MinerService from the miner engine
+type minerService struct {
+	exsv1.UnimplementedMinerServiceServer
+	engine *MinerEngine
+}
+
+// Mine runs mining rounds over successive nonces, streaming progress every
+// mineProgressInterval and the result once a nonce meets the difficulty or
+// the request's attempts run out
+func (s *minerService) Mine(req *exsv1.MineRequest, stream exsv1.MinerService_MineServer) error {
+	timestamp := req.Timestamp
+	if timestamp == 0 {
+		timestamp = time.Now().Unix()
+	}
+	start := time.Now()
+	lastReport := start
+	for nonce, attempts := req.StartNonce, uint64(1); ; nonce, attempts = nonce+1, attempts+1 {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		result, err := s.engine.Mine(stream.Context(), req.Height, nonce, timestamp)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		done := result.Success || req.MaxAttempts > 0 && attempts >= req.MaxAttempts
+		if !done && time.Since(lastReport) < mineProgressInterval {
+			continue
+		}
+
+		progress := &exsv1.MineProgress{
+			Attempts: attempts,
+			Nonce:    nonce,
+			Hashrate: float64(attempts) / time.Since(start).Seconds(),
+		}
+		if done {
+			progress.Result = &exsv1.MineResult{
+				Success:       result.Success,
+				Height:        result.Height,
+				Epoch:         int32(result.Epoch),
+				BlockHash:     result.BlockHash,
+				Nonce:         result.Nonce,
+				Difficulty:    int32(result.Difficulty),
+				Timestamp:     result.Timestamp,
+				Attempts:      attempts,
+				VaultAddress:  result.VaultAddress,
+				TreasuryAlloc: result.TreasuryAlloc,
+			}
+		}
+		if err := stream.Send(progress); err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		lastReport = time.Now()
+	}
+}
+
+// GetStats returns the engine's statistics
+func (s *minerService) GetStats(ctx context.Context, req *exsv1.GetMinerStatsRequest) (*exsv1.MinerStats, error) {
+	stats := s.engine.GetStats()
+	resp := &exsv1.MinerStats{
+		TotalAttempts: stats.TotalAttempts,
+		ValidBlocks:   stats.ValidBlocks,
+		Hashrate:      stats.Hashrate,
+		StartTime:     timestamppb.New(stats.StartTime),
+	}
+	if !stats.LastBlockTime.IsZero() {
+		resp.LastBlockTime = timestamppb.New(stats.LastBlockTime)
+	}
+	return resp, nil
+}
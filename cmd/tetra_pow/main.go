@@ -6,15 +6,29 @@
 package main
 
 import (
-	"crypto/sha256"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chaos"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/consensus"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/health"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/metrics"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tlsconfig"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tracing"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/update"
 	"github.com/gorilla/mux"
 )
 
@@ -32,17 +46,29 @@ type MinerConfig struct {
 }
 
 type MinerServer struct {
-	config *MinerConfig
-	engine *MinerEngine
+	config  *MinerConfig
+	engine  *MinerEngine
+	updates *update.Checker // nil when update checks are disabled
 }
 
 func main() {
 	axiom := flag.String("axiom", DefaultAxiom, "13-word Arthurian prophecy axiom")
+	epochs := flag.String("epochs", "", "Epoch schedule of axiom hashes by height (overrides -axiom)")
 	difficulty := flag.Int("difficulty", 4, "Mining difficulty target")
 	treasuryURL := flag.String("treasury", "http://localhost:8080", "Treasury API URL")
 	rosettaURL := flag.String("rosetta", "http://localhost:8081", "Rosetta API URL")
 	port := flag.String("port", "8082", "HTTP API port")
+	grpcPort := flag.String("grpc-port", "", "gRPC API port, empty to disable")
+	var logOptions logging.Options
+	logOptions.Register(flag.CommandLine)
 	flag.Parse()
+	if err := logOptions.Setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	config := &MinerConfig{
 		Axiom:         *axiom,
@@ -54,45 +80,154 @@ func main() {
 		PBKDF2Iters:   PBKDF2Iterations,
 	}
 
-	// Hash axiom for entropy (never store raw axiom on-chain)
-	axiomHash := hashAxiom(config.Axiom)
-	log.Printf("🗡️  EXS Tetra-PoW Miner Starting...")
-	log.Printf("📊 Difficulty: %d", config.Difficulty)
-	log.Printf("🔐 Quantum Rounds: %d", config.QuantumRounds)
-	log.Printf("🔑 Axiom Hash: %x", axiomHash[:8])
-	log.Printf("🏛️  Treasury: %s", config.TreasuryURL)
-	log.Printf("🌹 Rosetta: %s", config.RosettaURL)
+	// Hash axiom for entropy (never store raw axiom on-chain). A schedule
+	// rotates the axiom hash per epoch; without one the axiom never rotates.
+	schedule := consensus.Schedule{{Start: 0, AxiomHash: consensus.HashAxiom(config.Axiom)}}
+	if *epochs != "" {
+		var err error
+		if schedule, err = consensus.LoadSchedule(*epochs); err != nil {
+			logging.Fatal("Failed to load epoch schedule", "err", err)
+		}
+	}
+	slog.Info("🗡️  EXS Tetra-PoW Miner Starting",
+		"difficulty", config.Difficulty,
+		"quantum_rounds", config.QuantumRounds,
+		"treasury", config.TreasuryURL,
+		"rosetta", config.RosettaURL)
+	for i, epoch := range schedule {
+		slog.Info("🔑 Epoch", "epoch", i, "start_height", epoch.Start, "axiom_hash", fmt.Sprintf("%x", epoch.AxiomHash[:8]))
+	}
 
 	// Initialize miner engine
-	engine := NewMinerEngine(config, axiomHash)
+	engine := NewMinerEngine(config, schedule)
 	
+	updates, err := update.StartFromEnv(ctx, func(r *update.Release) {
+		slog.Info("⬆️  Excalibur-EXS update available", "version", r.Version, "running", buildinfo.Version, "url", r.URL)
+	})
+	if err != nil {
+		slog.Warn("Update checks disabled", "err", err)
+	}
 	server := &MinerServer{
-		config: config,
-		engine: engine,
+		config:  config,
+		engine:  engine,
+		updates: updates,
+	}
+
+	tlsConfig, err := tlsconfig.ServerFromEnv()
+	if err != nil {
+		logging.Fatal("Failed to configure TLS", "err", err)
 	}
 
 	// Setup HTTP API
+	guard := mineGuardian(tlsConfig)
 	router := mux.NewRouter()
+	probe := health.NewProbe()
 	router.HandleFunc("/health", server.handleHealth).Methods("GET")
-	router.HandleFunc("/mine", server.handleMine).Methods("POST")
+	router.Handle("/healthz", probe.Liveness()).Methods("GET")
+	router.Handle("/readyz", probe.Readiness()).Methods("GET")
+	router.Handle("/mine", mineHandler(http.HandlerFunc(server.handleMine), guard)).Methods("POST")
 	router.HandleFunc("/stats", server.handleStats).Methods("GET")
 	router.HandleFunc("/config", server.handleConfig).Methods("GET")
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	router.Use(metrics.MuxMiddleware)
+	router.Use(tracing.MuxMiddleware)
+	if err := metrics.WatchMiner(func() float64 { return engine.GetStats().Hashrate }); err != nil {
+		logging.Fatal("Failed to register miner metrics", "err", err)
+	}
+
+	if *grpcPort != "" {
+		var auth api.Authorizer
+		if guard != nil {
+			auth = api.GuardianAuth(guard, map[string]guardian.Permission{exsv1.MinerService_Mine_FullMethodName: guardian.PermissionForgeSubmit})
+		}
+		tlsOpts, err := api.TLSOptions(tlsConfig)
+		if err != nil {
+			logging.Fatal("Failed to configure gRPC TLS", "err", err)
+		}
+		grpcServer := api.NewServer(auth, tlsOpts...)
+		exsv1.RegisterMinerServiceServer(grpcServer, &minerService{engine: engine})
+		go func() {
+			if err := api.Serve(ctx, grpcServer, ":"+*grpcPort); err != nil {
+				logging.Fatal("Tetra-PoW gRPC API failed", "err", err)
+			}
+		}()
+		slog.Info("🚀 Tetra-PoW gRPC API listening", "port", *grpcPort)
+	}
+
+	// EXS_CHAOS injects faults into every request for resilience testing
+	faults, err := chaos.FromEnv()
+	if err != nil {
+		logging.Fatal("Failed to configure fault injection", "err", err)
+	}
+	if faults != nil {
+		slog.Warn("⚠️  Injecting faults into every request", "chaos", faults.Config())
+	}
+	traceConfig := tracing.FromEnv()
+	shutdownTracing, err := traceConfig.Setup(ctx, "exs-tetra-pow")
+	if err != nil {
+		logging.Fatal("Failed to configure tracing", "err", err)
+	}
+	defer shutdownTracing(context.Background())
 
-	log.Printf("🚀 Tetra-PoW Miner listening on %s", config.ListenAddr)
-	log.Fatal(http.ListenAndServe(config.ListenAddr, router))
+	slog.Info("🚀 Tetra-PoW Miner listening", "addr", config.ListenAddr, "transport", tlsConfig.Describe(), "tracing", traceConfig.Describe())
+	httpServer := &http.Server{Addr: config.ListenAddr, Handler: tracing.Middleware(logging.Middleware(faults.Middleware(router)))}
+	if err := health.Serve(ctx, httpServer, probe, func() error {
+		return tlsconfig.ListenAndServe(httpServer, tlsConfig)
+	}); err != nil {
+		logging.Fatal("Tetra-PoW Miner failed", "err", err)
+	}
+	slog.Info("🛑 Tetra-PoW Miner stopped")
+}
+
+// mineGuardian returns the Guardian requiring a Knight JWT for mining when
+// GUARDIAN_JWKS_URL names the key set of the Guardian that issues them, or
+// nil when mining is open. The miner keeps no users or sessions; tokens are
+// checked by signature alone, fetching the key set with tlsConfig.
+func mineGuardian(tlsConfig tlsconfig.Config) *guardian.Guardian {
+	if os.Getenv("GUARDIAN_JWKS_URL") == "" {
+		slog.Warn("🔓 Mining is open: set GUARDIAN_JWKS_URL to require a JWT", "role", guardian.RoleKnight)
+		return nil
+	}
+	config, err := guardian.ConfigFromEnv()
+	if err != nil {
+		logging.Fatal("Failed to configure guardian", "err", err)
+	}
+	if config.JWKSClient, err = tlsConfig.HTTPClient(30 * time.Second); err != nil {
+		logging.Fatal("Failed to configure guardian", "err", err)
+	}
+	guard, err := guardian.NewGuardianWithStore(config, guardian.NewMemoryStore())
+	if err != nil {
+		logging.Fatal("Failed to start guardian", "err", err)
+	}
+	slog.Info("🛡️  Mining requires a JWT", "role", guardian.RoleKnight, "jwks_url", config.JWKSURL)
+	return guard
+}
+
+// mineHandler protects /mine with guard, when not nil
+func mineHandler(h http.Handler, guard *guardian.Guardian) http.Handler {
+	if guard == nil {
+		return h
+	}
+	return guard.Middleware(h, guardian.RoleKnight)
 }
 
 func (s *MinerServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"status":  "healthy",
 		"miner":   "tetra-pow",
-		"version": "1.0.0",
-	})
+		"version": buildinfo.Version,
+		"build":   buildinfo.Get(),
+	}
+	if s.updates != nil {
+		response["update"] = s.updates.Status()
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func (s *MinerServer) handleMine(w http.ResponseWriter, r *http.Request) {
 	var req struct {
+		Height    uint32 `json:"height"`
 		Nonce     uint64 `json:"nonce"`
 		Timestamp int64  `json:"timestamp"`
 	}
@@ -102,10 +237,10 @@ func (s *MinerServer) handleMine(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("⛏️  Starting mining round (nonce: %d)", req.Nonce)
+	logging.FromContext(r.Context()).Info("⛏️  Starting mining round", "height", req.Height, "nonce", req.Nonce)
 	
 	// Run mining round
-	result, err := s.engine.Mine(req.Nonce, req.Timestamp)
+	result, err := s.engine.Mine(r.Context(), req.Height, req.Nonce, req.Timestamp)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -129,15 +264,6 @@ func (s *MinerServer) handleConfig(w http.ResponseWriter, r *http.Request) {
 		"pbkdf2_iters":    s.config.PBKDF2Iters,
 		"treasury_url":    s.config.TreasuryURL,
 		"rosetta_url":     s.config.RosettaURL,
+		"epochs":          s.engine.schedule,
 	})
 }
-
-// hashAxiom creates SHA-256 hash of the axiom for use as entropy seed
-// The raw axiom is NEVER stored on-chain
-func hashAxiom(axiom string) [32]byte {
-	// Normalize axiom (lowercase, single spaces)
-	normalized := strings.ToLower(strings.TrimSpace(axiom))
-	normalized = strings.Join(strings.Fields(normalized), " ")
-	
-	return sha256.Sum256([]byte(normalized))
-}
@@ -5,18 +5,25 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
-	"encoding/binary"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
 	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/consensus"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/metrics"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type MinerEngine struct {
 	config    *MinerConfig
-	axiomHash [32]byte
+	schedule  consensus.Schedule
 	stats     *MiningStats
 	mu        sync.RWMutex
 }
@@ -31,6 +38,8 @@ type MiningStats struct {
 
 type MiningResult struct {
 	Success       bool      `json:"success"`
+	Height        uint32    `json:"height"`
+	Epoch         int       `json:"epoch"`
 	BlockHash     string    `json:"block_hash,omitempty"`
 	Nonce         uint64    `json:"nonce"`
 	Difficulty    int       `json:"difficulty"`
@@ -40,37 +49,48 @@ type MiningResult struct {
 	TreasuryAlloc float64   `json:"treasury_alloc,omitempty"`
 }
 
-func NewMinerEngine(config *MinerConfig, axiomHash [32]byte) *MinerEngine {
+func NewMinerEngine(config *MinerConfig, schedule consensus.Schedule) *MinerEngine {
 	return &MinerEngine{
 		config:    config,
-		axiomHash: axiomHash,
+		schedule:  schedule,
 		stats: &MiningStats{
 			StartTime: time.Now(),
 		},
 	}
 }
 
-// Mine executes one mining round with 128 nonlinear transformations
-func (m *MinerEngine) Mine(startNonce uint64, timestamp int64) (*MiningResult, error) {
+// Mine executes one mining round with 128 nonlinear transformations, seeded
+// with the axiom hash of the epoch height falls in. The round is traced as a
+// child of the span in ctx.
+func (m *MinerEngine) Mine(ctx context.Context, height uint32, startNonce uint64, timestamp int64) (*MiningResult, error) {
+	_, span := tracing.Start(ctx, "mining round",
+		attribute.Int("block.height", int(height)),
+		attribute.String("nonce", strconv.FormatUint(startNonce, 10)))
+	defer span.End()
+
 	m.mu.Lock()
 	m.stats.TotalAttempts++
 	m.mu.Unlock()
+	metrics.MiningAttempts.Inc()
 
 	if timestamp == 0 {
 		timestamp = time.Now().Unix()
 	}
 
 	// Create block header seed from axiom hash + nonce + timestamp
-	seed := m.createBlockSeed(startNonce, timestamp)
+	epoch, current := m.schedule.EpochAt(height)
+	seed := m.schedule.BlockSeed(height, startNonce, timestamp)
 
 	// Apply 128 nonlinear rounds (Tetra-PoW algorithm)
-	hash := m.tetraPoW(seed)
+	hash := m.tetraPoW(seed, current.AxiomHash)
 
 	// Check if hash meets difficulty target
 	success := m.checkDifficulty(hash, m.config.Difficulty)
 
 	result := &MiningResult{
 		Success:    success,
+		Height:     height,
+		Epoch:      epoch,
 		Nonce:      startNonce,
 		Difficulty: m.config.Difficulty,
 		Timestamp:  timestamp,
@@ -79,20 +99,22 @@ func (m *MinerEngine) Mine(startNonce uint64, timestamp int64) (*MiningResult, e
 
 	if success {
 		result.BlockHash = fmt.Sprintf("%x", hash)
-		result.VaultAddress = m.generateVaultAddress(hash)
-		result.TreasuryAlloc = TreasuryAllocation // 7.5 EXS per block
+		result.VaultAddress = m.generateVaultAddress(hash, current.AxiomHash)
+		result.TreasuryAlloc = economy.TreasuryShare(economy.RewardAtForge(uint64(height))).EXS()
 		
 		m.mu.Lock()
 		m.stats.ValidBlocks++
 		m.stats.LastBlockTime = time.Now()
 		m.mu.Unlock()
+		metrics.BlocksFound.Inc()
 	}
+	span.SetAttributes(attribute.Int("epoch", epoch), attribute.Bool("success", success))
 
 	return result, nil
 }
 
 // tetraPoW implements 128-round nonlinear mining algorithm
-func (m *MinerEngine) tetraPoW(seed []byte) []byte {
+func (m *MinerEngine) tetraPoW(seed []byte, axiomHash consensus.AxiomHash) []byte {
 	state := make([]byte, 64)
 	copy(state, seed)
 
@@ -104,13 +126,13 @@ func (m *MinerEngine) tetraPoW(seed []byte) []byte {
 		// XOR with axiom hash (different offset each round)
 		offset := round % 32
 		for i := 0; i < 32; i++ {
-			roundHash[i] ^= m.axiomHash[(i+offset)%32]
+			roundHash[i] ^= axiomHash[(i+offset)%32]
 		}
 
 		// Apply HPP-1 quantum hardening (PBKDF2 with 600,000 iterations)
 		// Only apply every 16 rounds to balance security vs performance
 		if round%16 == 0 {
-			hardened := pbkdf2.Key(roundHash[:], m.axiomHash[:], m.config.PBKDF2Iters, 32, sha256.New)
+			hardened := pbkdf2.Key(roundHash[:], axiomHash[:], m.config.PBKDF2Iters, 32, sha256.New)
 			copy(roundHash[:], hardened)
 		}
 
@@ -126,15 +148,6 @@ func (m *MinerEngine) tetraPoW(seed []byte) []byte {
 	return finalHash[:]
 }
 
-// createBlockSeed combines axiom hash, nonce, and timestamp
-func (m *MinerEngine) createBlockSeed(nonce uint64, timestamp int64) []byte {
-	seed := make([]byte, 48)
-	copy(seed[0:32], m.axiomHash[:])
-	binary.LittleEndian.PutUint64(seed[32:40], nonce)
-	binary.LittleEndian.PutUint64(seed[40:48], uint64(timestamp))
-	return seed
-}
-
 // checkDifficulty verifies if hash meets difficulty target (leading zeros)
 func (m *MinerEngine) checkDifficulty(hash []byte, difficulty int) bool {
 	if difficulty <= 0 || difficulty > 8 {
@@ -152,9 +165,9 @@ func (m *MinerEngine) checkDifficulty(hash []byte, difficulty int) bool {
 
 // generateVaultAddress creates P2TR vault address from block hash
 // Uses axiom hash as additional entropy for deterministic vault generation
-func (m *MinerEngine) generateVaultAddress(blockHash []byte) string {
+func (m *MinerEngine) generateVaultAddress(blockHash []byte, axiomHash consensus.AxiomHash) string {
 	// Combine block hash with axiom hash for vault seed
-	vaultSeed := sha256.Sum256(append(blockHash, m.axiomHash[:]...))
+	vaultSeed := sha256.Sum256(append(blockHash, axiomHash[:]...))
 	
 	// Mock P2TR address generation (in production, use btcutil)
 	// Format: bc1p + 58 chars (Bech32m encoding)
@@ -26,9 +26,9 @@ func main() {
 		result := treasury.ProcessForge(fmt.Sprintf("bc1p_miner_%d", i))
 		fmt.Printf("Forge #%d (Block %d):\n", result.ForgeID, result.BlockHeight)
 		fmt.Printf("  Miner:       %s\n", result.MinerAddress)
-		fmt.Printf("  Reward:      %.2f $EXS\n", result.MinerReward)
+		fmt.Printf("  Reward:      %.2f $EXS\n", result.MinerReward.EXS())
 		fmt.Printf("  Treasury:    %.2f $EXS (split into %d mini-outputs)\n", 
-			result.TreasuryAllocation, len(result.TreasuryMiniOutputs))
+			result.TreasuryAllocation.EXS(), len(result.TreasuryMiniOutputs))
 		fmt.Printf("  Mini-Outputs:\n")
 		for j, output := range result.TreasuryMiniOutputs {
 			status := "🔓 Unlocked"
@@ -36,7 +36,7 @@ func main() {
 				status = "🔒 Locked"
 			}
 			fmt.Printf("    %d) %.1f $EXS - Unlock at block %d %s\n", 
-				j+1, output.Amount, output.UnlockHeight, status)
+				j+1, output.Amount.EXS(), output.UnlockHeight, status)
 		}
 		fmt.Println()
 	}
@@ -47,18 +47,18 @@ func main() {
 	// Test distribution
 	fmt.Println()
 	fmt.Println("Testing treasury distribution...")
-	dist, err := treasury.Distribute(0.5, "bc1p_dev_wallet", "Development grant")
+	dist, err := treasury.Distribute(economy.Coin/2, "bc1p_dev_wallet", "Development grant")
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 	} else {
 		fmt.Printf("✅ Distribution #%d successful\n", dist.ID)
-		fmt.Printf("   Amount: %.2f $EXS\n", dist.Amount)
+		fmt.Printf("   Amount: %.2f $EXS\n", dist.Amount.EXS())
 		fmt.Printf("   Recipient: %s\n", dist.Recipient)
 		fmt.Printf("   Purpose: %s\n", dist.Purpose)
 	}
 	
 	fmt.Println()
-	fmt.Printf("Final Treasury Balance: %.2f $EXS\n", treasury.GetBalance())
-	fmt.Printf("  Spendable: %.2f $EXS\n", treasury.GetSpendableBalance())
-	fmt.Printf("  Locked:    %.2f $EXS\n", treasury.GetLockedBalance())
+	fmt.Printf("Final Treasury Balance: %.2f $EXS\n", treasury.GetBalance().EXS())
+	fmt.Printf("  Spendable: %.2f $EXS\n", treasury.GetSpendableBalance().EXS())
+	fmt.Printf("  Locked:    %.2f $EXS\n", treasury.GetLockedBalance().EXS())
 }
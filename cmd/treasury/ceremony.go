@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const ceremonyUsage = `Usage: treasury keygen-ceremony <step> [flags]
+
+Sets up the treasury multisig vault with every signer on their own offline
+machine. No private key ever leaves the machine that generated it.
+
+Steps:
+  keygen  -name NAME            generate a signer key (NAME.key, NAME.pub)
+  vault   -threshold M NAME.pub...  build the M-of-N vault from every public key
+  verify  -vault vault.json     re-derive the vault and compare addresses
+
+Run "treasury keygen-ceremony <step> -h" for the flags of a step.
+`
+
+// signerShare is the public half of a signer's key, exchanged between
+// signers as NAME.pub
+type signerShare struct {
+	Name   string `json:"name"`
+	PubKey string `json:"pubkey"`
+}
+
+// vaultFile records a ceremony's outcome; it holds public keys only
+type vaultFile struct {
+	Network     string        `json:"network"`
+	Threshold   int           `json:"threshold"`
+	Signers     []signerShare `json:"signers"`
+	InternalKey string        `json:"internal_key"`
+	LeafScript  string        `json:"leaf_script"`
+	Address     string        `json:"address"`
+	Fingerprint string        `json:"fingerprint"`
+}
+
+// runCeremony runs one step of the key ceremony and returns the exit code
+func runCeremony(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, ceremonyUsage)
+		return 2
+	}
+	steps := map[string]func([]string) error{
+		"keygen": ceremonyKeygen,
+		"vault":  ceremonyVault,
+		"verify": ceremonyVerify,
+	}
+	step, ok := steps[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown ceremony step %q\n\n%s", args[0], ceremonyUsage)
+		return 2
+	}
+	if err := step(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// ceremonyParams maps a network name to its parameters
+func ceremonyParams(name string) (*chaincfg.Params, error) {
+	switch name {
+	case "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet":
+		return &chaincfg.TestNet3Params, nil
+	case "signet":
+		return &chaincfg.SigNetParams, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	}
+	return nil, fmt.Errorf("unknown network %q (use mainnet, testnet, signet or regtest)", name)
+}
+
+func ceremonyKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	name := fs.String("name", "", "signer name, used for the key files")
+	network := fs.String("network", "mainnet", "network the key is for")
+	dir := fs.String("dir", ".", "directory to write NAME.key and NAME.pub to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" || strings.ContainsAny(*name, `/\ `) {
+		return errors.New("-name is required and may not contain spaces or slashes")
+	}
+	net, err := ceremonyParams(*network)
+	if err != nil {
+		return err
+	}
+
+	keyPath := filepath.Join(*dir, *name+".key")
+	pubPath := filepath.Join(*dir, *name+".pub")
+	for _, path := range []string{keyPath, pubPath} {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists; refusing to overwrite a signer key", path)
+		}
+	}
+
+	priv, err := btcec.NewPrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	wif, err := btcutil.NewWIF(priv, net, true)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, []byte(wif.String()+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	share := signerShare{Name: *name, PubKey: hex.EncodeToString(priv.PubKey().SerializeCompressed())}
+	if err := writeJSON(pubPath, share); err != nil {
+		return err
+	}
+
+	fmt.Println("🔑 Signer Key Generated")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Signer:      %s\n", share.Name)
+	fmt.Printf("Public key:  %s\n", share.PubKey)
+	fmt.Printf("Private key: %s (keep offline, back it up)\n", keyPath)
+	fmt.Printf("Share:       %s\n", pubPath)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println("Next:")
+	fmt.Printf("  1. Give %s (never %s) to every other signer.\n", filepath.Base(pubPath), filepath.Base(keyPath))
+	fmt.Println("  2. Read your public key aloud so they can check the file they received.")
+	fmt.Println("  3. With every signer's .pub file, each signer runs:")
+	fmt.Printf("     treasury keygen-ceremony vault -network %s -threshold M -key %s *.pub\n", *network, keyPath)
+	return nil
+}
+
+func ceremonyVault(args []string) error {
+	fs := flag.NewFlagSet("vault", flag.ContinueOnError)
+	threshold := fs.Int("threshold", 0, "signatures needed to spend (M of N)")
+	network := fs.String("network", "mainnet", "network of the vault address")
+	keyPath := fs.String("key", "", "your NAME.key, to confirm your key is in the vault")
+	out := fs.String("out", "vault.json", "file to write the vault description to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	net, err := ceremonyParams(*network)
+	if err != nil {
+		return err
+	}
+
+	shares := make([]signerShare, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		var share signerShare
+		if err := readJSON(path, &share); err != nil {
+			return err
+		}
+		shares = append(shares, share)
+	}
+	vault, shares, err := deriveVault(shares, *threshold, net)
+	if err != nil {
+		return err
+	}
+	mine, err := ownShare(*keyPath, net, shares)
+	if err != nil {
+		return err
+	}
+
+	file := vaultFile{
+		Network:     *network,
+		Threshold:   vault.Threshold,
+		Signers:     shares,
+		InternalKey: hex.EncodeToString(vault.InternalKey.SerializeCompressed()),
+		LeafScript:  hex.EncodeToString(vault.LeafScript),
+		Address:     vault.Address,
+		Fingerprint: vault.Fingerprint(),
+	}
+	if err := writeJSON(*out, file); err != nil {
+		return err
+	}
+
+	printVault(file, mine)
+	fmt.Println("Next:")
+	fmt.Println("  1. Every signer reads the fingerprint and address aloud; they must all match.")
+	fmt.Printf("  2. Exchange %s files and confirm each one with:\n", filepath.Base(*out))
+	fmt.Printf("     treasury keygen-ceremony verify -vault %s -address <their address>\n", *out)
+	fmt.Println("  3. Only fund the address once every signer has confirmed it.")
+	return nil
+}
+
+func ceremonyVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	path := fs.String("vault", "vault.json", "vault description from the vault step")
+	keyPath := fs.String("key", "", "your NAME.key, to confirm your key is in the vault")
+	var addresses stringList
+	fs.Var(&addresses, "address", "address another signer derived (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var file vaultFile
+	if err := readJSON(*path, &file); err != nil {
+		return err
+	}
+	net, err := ceremonyParams(file.Network)
+	if err != nil {
+		return err
+	}
+	vault, shares, err := deriveVault(file.Signers, file.Threshold, net)
+	if err != nil {
+		return err
+	}
+	if vault.Address != file.Address {
+		return fmt.Errorf("%s records address %s but its keys derive %s", *path, file.Address, vault.Address)
+	}
+	if vault.Fingerprint() != file.Fingerprint {
+		return fmt.Errorf("%s records fingerprint %s but its keys derive %s", *path, file.Fingerprint, vault.Fingerprint())
+	}
+	for _, address := range addresses {
+		if address != vault.Address {
+			return fmt.Errorf("address mismatch: %s derived here, %s by another signer; stop and compare public keys", vault.Address, address)
+		}
+	}
+	mine, err := ownShare(*keyPath, net, shares)
+	if err != nil {
+		return err
+	}
+
+	file.Signers = shares
+	printVault(file, mine)
+	fmt.Print("✅ Vault verified")
+	if len(addresses) > 0 {
+		fmt.Printf(" against %d other signer address(es)", len(addresses))
+	}
+	fmt.Println()
+	return nil
+}
+
+// deriveVault builds the vault from signer shares, returning the shares in
+// the vault's key order. Names must be unique so signers can be told apart.
+func deriveVault(shares []signerShare, threshold int, net *chaincfg.Params) (*bitcoin.MultisigVault, []signerShare, error) {
+	names := make(map[string]bool)
+	byKey := make(map[string]signerShare)
+	keys := make([]*btcec.PublicKey, 0, len(shares))
+	for _, share := range shares {
+		if names[share.Name] {
+			return nil, nil, fmt.Errorf("signer %q appears twice", share.Name)
+		}
+		names[share.Name] = true
+		raw, err := hex.DecodeString(share.PubKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("signer %q: invalid public key: %w", share.Name, err)
+		}
+		key, err := btcec.ParsePubKey(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("signer %q: invalid public key: %w", share.Name, err)
+		}
+		keys = append(keys, key)
+		byKey[string(key.SerializeCompressed())] = share
+	}
+
+	vault, err := bitcoin.NewMultisigVault(keys, threshold, net)
+	if err != nil {
+		return nil, nil, err
+	}
+	ordered := make([]signerShare, len(vault.Keys))
+	for i, key := range vault.Keys {
+		ordered[i] = byKey[string(key.SerializeCompressed())]
+	}
+	return vault, ordered, nil
+}
+
+// ownShare checks that the key in keyPath, if given, belongs to one of the
+// signers and returns that signer's name
+func ownShare(keyPath string, net *chaincfg.Params, shares []signerShare) (string, error) {
+	if keyPath == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key: %w", err)
+	}
+	wif, err := btcutil.DecodeWIF(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "", fmt.Errorf("invalid key in %s: %w", keyPath, err)
+	}
+	if !wif.IsForNet(net) {
+		return "", fmt.Errorf("%s is not a %s key", keyPath, net.Name)
+	}
+	pub := hex.EncodeToString(wif.PrivKey.PubKey().SerializeCompressed())
+	for _, share := range shares {
+		if share.PubKey == pub {
+			return share.Name, nil
+		}
+	}
+	return "", fmt.Errorf("your key %s is not among the vault's signers", pub)
+}
+
+// printVault shows the vault for signers to compare
+func printVault(file vaultFile, mine string) {
+	fmt.Printf("🏛️  Treasury Vault (%d-of-%d, %s)\n", file.Threshold, len(file.Signers), file.Network)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for i, share := range file.Signers {
+		marker := ""
+		if share.Name == mine {
+			marker = "  ← you"
+		}
+		fmt.Printf("Signer %d: %-12s %s%s\n", i+1, share.Name, share.PubKey, marker)
+	}
+	fmt.Printf("Internal key: %s (MuSig2 of all signers)\n", file.InternalKey)
+	fmt.Printf("Fingerprint:  %s\n", file.Fingerprint)
+	fmt.Printf("Address:      %s\n", file.Address)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+}
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tlsconfig"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// tipInterval is how often the SPV chain tip is checked
+const tipInterval = 30 * time.Second
+
+// treasuryKeyFromEnv sets the key mini-outputs pay from TREASURY_PUBKEY, a
+// compressed public key in hex, with addresses for net. It returns false
+// when the key is unset.
+func treasuryKeyFromEnv(treasury *economy.Treasury, net *chaincfg.Params) (bool, error) {
+	v := os.Getenv("TREASURY_PUBKEY")
+	if v == "" {
+		return false, nil
+	}
+	raw, err := hex.DecodeString(v)
+	if err != nil {
+		return false, fmt.Errorf("TREASURY_PUBKEY %q is not hex", v)
+	}
+	return true, treasury.SetTreasuryKey(raw, net)
+}
+
+// startChainTip follows the chain tip of an SPV client on net connected to
+// TREASURY_SPV_PEERS, comma-separated host:port addresses, unlocking
+// mini-outputs as their heights pass. It returns nil without peers.
+func startChainTip(ctx context.Context, treasury *economy.Treasury, net *chaincfg.Params) (*bitcoin.SPVClient, error) {
+	var peers []string
+	for _, peer := range strings.Split(os.Getenv("TREASURY_SPV_PEERS"), ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	if len(peers) == 0 {
+		return nil, nil
+	}
+
+	spv := bitcoin.NewSPVClient(net)
+	if err := spv.Start(); err != nil {
+		return nil, err
+	}
+	for _, peer := range peers {
+		if err := spv.AddPeer(peer); err != nil {
+			slog.Warn("Failed to add SPV peer", "peer", peer, "err", err)
+		}
+	}
+	go followChainTip(ctx, spv, treasury, tipInterval)
+	return spv, nil
+}
+
+// followChainTip moves the treasury to the SPV best height whenever it
+// advances, until ctx is done. The ledger records each new height, so
+// heights are never moved backwards by a client still syncing.
+func followChainTip(ctx context.Context, spv *bitcoin.SPVClient, treasury *economy.Treasury, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, height := spv.GetBestBlock(); height > 0 && uint32(height) > treasury.GetBlockHeight() {
+			unlockable := len(treasury.GetUnlockableOutputs())
+			treasury.SetBlockHeight(uint32(height))
+			if n := len(treasury.GetUnlockableOutputs()) - unlockable; n > 0 {
+				slog.Info("Chain tip unlocked treasury mini-outputs", "height", height, "unlocked", n)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleUnlockable lists the spendable mini-outputs with their scripts
+func (s *Server) handleUnlockable() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		outputs := s.treasury.GetUnlockableOutputs()
+		var total economy.Amount
+		for _, output := range outputs {
+			total += output.Amount
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(client.Unlockable{
+			BlockHeight: s.treasury.GetBlockHeight(),
+			Outputs:     outputs,
+			Total:       total,
+		})
+	}
+}
+
+// runUnlockable queries a running treasury for its spendable mini-outputs
+// and returns the exit code
+func runUnlockable(args []string) int {
+	fs := flag.NewFlagSet("unlockable", flag.ContinueOnError)
+	url := fs.String("url", "http://localhost:8080", "treasury API URL")
+	asJSON := fs.Bool("json", false, "print the outputs as JSON")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	// A treasury requiring client certificates is called with the same
+	// TLS_* settings it is served with
+	tlsConfig, err := tlsconfig.FromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	httpClient, err := tlsConfig.HTTPClient(30 * time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	unlockable, err := client.NewTreasury(*url, client.Options{HTTPClient: httpClient}).Unlockable(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(unlockable)
+		return 0
+	}
+
+	fmt.Printf("🔓 Unlockable Treasury Outputs at height %d\n", unlockable.BlockHeight)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, output := range unlockable.Outputs {
+		fmt.Printf("Output %d: %s EXS (forged at %d, unlocked at %d)\n",
+			output.OutputID, output.Amount, output.BlockHeight, output.UnlockHeight)
+		fmt.Printf("  Address:        %s\n", output.Address)
+		fmt.Printf("  Script:         %s\n", output.PkScript)
+		if output.WitnessScript != "" {
+			fmt.Printf("  Witness script: %s\n", output.WitnessScript)
+		}
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("%d outputs, %s EXS\n", len(unlockable.Outputs), unlockable.Total)
+	return 0
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+)
+
+const (
+	// maxClaimVerifications bounds the proofs verified at once; each one
+	// runs the full HPP-1 tempering
+	maxClaimVerifications = 2
+	// maxClaimBody bounds a /claim request body
+	maxClaimBody = 16 << 10
+)
+
+// claimPolicyFromEnv reads the claim policy: TREASURY_NETWORK (mainnet,
+// testnet, signet or regtest) for claimant and proof addresses,
+// CLAIM_REWARD in EXS, CLAIM_MAX_PER_ADDRESS claims and
+// CLAIM_MAX_AMOUNT EXS per address, 0 for no cap
+func claimPolicyFromEnv() (economy.ClaimPolicy, error) {
+	policy := economy.DefaultClaimPolicy()
+	if v := os.Getenv("TREASURY_NETWORK"); v != "" {
+		net, err := ceremonyParams(v)
+		if err != nil {
+			return policy, fmt.Errorf("TREASURY_NETWORK: %w", err)
+		}
+		policy.Network = net
+	}
+	if v := os.Getenv("CLAIM_REWARD"); v != "" {
+		reward, err := economy.ParseAmount(v)
+		if err != nil || reward <= 0 {
+			return policy, fmt.Errorf("CLAIM_REWARD %q is not a positive amount", v)
+		}
+		policy.Reward = reward
+	}
+	if v := os.Getenv("CLAIM_MAX_PER_ADDRESS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return policy, fmt.Errorf("CLAIM_MAX_PER_ADDRESS %q is not a count", v)
+		}
+		policy.MaxClaims = n
+	}
+	if v := os.Getenv("CLAIM_MAX_AMOUNT"); v != "" {
+		amount, err := economy.ParseAmount(v)
+		if err != nil {
+			return policy, fmt.Errorf("CLAIM_MAX_AMOUNT %q is not an amount", v)
+		}
+		policy.MaxAmount = amount
+	}
+	return policy, nil
+}
+
+// handleClaim pays a signed claim for a proof of forge. It is public: the
+// claim is signed by the claimant's Taproot key and carries its own proof.
+// Proofs are slow to verify, so only maxClaimVerifications run at once and
+// the rest are turned away with 429.
+func (s *Server) handleClaim() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.emergency.Check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		var req economy.ClaimRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxClaimBody)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case s.claimSlots <- struct{}{}:
+			defer func() { <-s.claimSlots }()
+		default:
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Too many claims being verified, retry later", http.StatusTooManyRequests)
+			return
+		}
+
+		claim, err := s.treasury.ClaimReward(&req)
+		switch {
+		case errors.Is(err, economy.ErrInvalidClaim):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, economy.ErrAlreadyClaimed):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, economy.ErrClaimCap):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, economy.ErrPaymentRequired):
+			http.Error(w, err.Error(), http.StatusPaymentRequired)
+			return
+		case err != nil:
+			// Halted since the check above, out of funds or a ledger failure
+			logging.FromContext(r.Context()).Error("Claim processing failed", "err", err)
+			http.Error(w, "Claim processing failed", http.StatusServiceUnavailable)
+			return
+		}
+
+		logging.FromContext(r.Context()).Info("Claim paid", "claim", claim.ID, "amount_exs", claim.Amount, "address", claim.Address, "proof_address", claim.ProofAddress)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(claim)
+	}
+}
+
+func (s *Server) handleClaims() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.treasury.GetClaims())
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/lightning"
+)
+
+// defaultLightningInterval is how often new forwards are credited
+const defaultLightningInterval = time.Minute
+
+// lightningFromEnv reads the collector crediting the routing fees of the
+// protocol's Lightning node: LIGHTNING_BACKEND is lnd or cln, LIGHTNING_URL
+// its REST endpoint, LIGHTNING_MACAROON the LND macaroon file or
+// LIGHTNING_RUNE the CLN rune, and LIGHTNING_TLS_CERT the node's
+// certificate. LIGHTNING_SATS_PER_EXS converts fees, one exs-satoshi per
+// satoshi by default, and LIGHTNING_POLL_INTERVAL sets how often forwards
+// are read. It returns nil without a backend.
+func lightningFromEnv(treasury *economy.Treasury) (*lightning.Collector, time.Duration, error) {
+	backend := os.Getenv("LIGHTNING_BACKEND")
+	if backend == "" {
+		return nil, 0, nil
+	}
+	node, err := lightning.Open(lightning.Config{
+		Backend:      backend,
+		URL:          os.Getenv("LIGHTNING_URL"),
+		MacaroonFile: os.Getenv("LIGHTNING_MACAROON"),
+		Rune:         os.Getenv("LIGHTNING_RUNE"),
+		TLSCert:      os.Getenv("LIGHTNING_TLS_CERT"),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("LIGHTNING_BACKEND: %w", err)
+	}
+	var satsPerCoin int64
+	if v := os.Getenv("LIGHTNING_SATS_PER_EXS"); v != "" {
+		if satsPerCoin, err = strconv.ParseInt(v, 10, 64); err != nil || satsPerCoin <= 0 {
+			return nil, 0, fmt.Errorf("LIGHTNING_SATS_PER_EXS %q is not a positive number", v)
+		}
+	}
+	interval := defaultLightningInterval
+	if v := os.Getenv("LIGHTNING_POLL_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+			return nil, 0, fmt.Errorf("LIGHTNING_POLL_INTERVAL %q is not a positive duration", v)
+		}
+	}
+	return lightning.NewCollector(treasury, node, satsPerCoin), interval, nil
+}
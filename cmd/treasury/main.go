@@ -1,25 +1,81 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chaos"
 	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/events"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/health"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/metrics"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tlsconfig"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tracing"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/update"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/webhook"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Server struct {
-	treasury *economy.Treasury
-	router   *mux.Router
+	treasury  *economy.Treasury
+	guard     *guardian.Guardian
+	emergency *guardian.Breaker
+	bus       *events.Bus
+	updates   *update.Checker
+	router    *mux.Router
+	// claimSlots bounds the claims verified at once
+	claimSlots chan struct{}
+	// payments holds forge payment proofs waiting for confirmations
+	payments *paymentWatcher
+	// origins are the browser origins allowed to open /ws, "*" for any
+	origins []string
+	// webhooks, when not nil, posts events to external endpoints
+	webhooks *webhook.Dispatcher
+	// probe answers /healthz and /readyz
+	probe *health.Probe
 }
 
-func NewServer() *Server {
+// NewServer creates the API server. When guard is nil the protected routes
+// are served without authentication, and the emergency halt and event
+// stream are not served at all. updates, when not nil, is reported by
+// /health.
+func NewServer(treasury *economy.Treasury, guard *guardian.Guardian, emergency *guardian.Breaker, bus *events.Bus, updates *update.Checker) *Server {
 	s := &Server{
-		treasury: economy.NewTreasury(),
-		router:   mux.NewRouter(),
+		treasury:   treasury,
+		guard:      guard,
+		emergency:  emergency,
+		bus:        bus,
+		updates:    updates,
+		router:     mux.NewRouter(),
+		claimSlots: make(chan struct{}, maxClaimVerifications),
+		payments:   newPaymentWatcher(treasury),
+		probe:      health.NewProbe(),
+	}
+	s.probe.Require("ledger", func(ctx context.Context) error { return treasury.LedgerErr() })
+	if guard != nil {
+		s.probe.Require("datastore", func(ctx context.Context) error { return guard.CheckStore() })
 	}
 	s.routes()
 	return s
@@ -27,20 +83,84 @@ func NewServer() *Server {
 
 func (s *Server) routes() {
 	s.router.HandleFunc("/health", s.handleHealth()).Methods("GET")
+	s.router.Handle("/healthz", s.probe.Liveness()).Methods("GET")
+	s.router.Handle("/readyz", s.probe.Readiness()).Methods("GET")
 	s.router.HandleFunc("/stats", s.handleStats()).Methods("GET")
-	s.router.HandleFunc("/forge", s.handleForge()).Methods("POST")
+	s.router.HandleFunc("/leaderboard", s.handleLeaderboard()).Methods("GET")
+	s.router.HandleFunc("/revenue", s.handleRevenue()).Methods("GET")
+	s.router.Handle("/revenue/cross-chain", s.permit(s.handleCrossChainReward(), guardian.PermissionForgeSubmit)).Methods("POST")
+	s.router.Handle("/forge", s.permit(s.handleForge(), guardian.PermissionForgeSubmit)).Methods("POST")
 	s.router.HandleFunc("/balance", s.handleBalance()).Methods("GET")
-	s.router.HandleFunc("/distributions", s.handleDistributions()).Methods("GET")
+	s.router.Handle("/distributions", s.protect(s.handleDistributions(), guardian.RoleKingArthur)).Methods("GET")
+	s.router.Handle("/distributions/{id}/retry", s.permit(s.handleRetrySettlement(), guardian.PermissionTreasuryDistribute)).Methods("POST")
 	s.router.HandleFunc("/mini-outputs", s.handleMiniOutputs()).Methods("GET")
+	s.router.HandleFunc("/unlockable", s.handleUnlockable()).Methods("GET")
+	s.router.HandleFunc("/claim", s.handleClaim()).Methods("POST")
+	s.router.Handle("/claims", s.protect(s.handleClaims(), guardian.RoleKingArthur)).Methods("GET")
+	s.router.Handle("/proposals", s.protect(s.handleProposals(), guardian.RoleKingArthur)).Methods("GET")
+	s.router.Handle("/proposals", s.permit(s.handlePropose(), guardian.PermissionTreasuryDistribute)).Methods("POST")
+	s.router.Handle("/proposals/{id}", s.protect(s.handleProposal(), guardian.RoleKingArthur)).Methods("GET")
+	s.router.Handle("/proposals/{id}/approve", s.permit(s.handleApprove(), guardian.PermissionTreasuryDistribute)).Methods("POST")
+	s.router.Handle("/payments", s.protect(s.handlePayments(), guardian.RoleKingArthur)).Methods("GET")
+	s.router.Handle("/webhooks/deliveries", s.protect(s.handleWebhookDeliveries(), guardian.RoleKingArthur)).Methods("GET")
+	s.router.HandleFunc("/payments", s.handleRequestPayment()).Methods("POST")
+	s.router.HandleFunc("/payments/{id}", s.handlePayment()).Methods("GET")
+	s.router.HandleFunc("/payments/{id}/verify", s.handleVerifyPayment()).Methods("POST")
+	s.router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	s.router.HandleFunc("/emergency", s.handleEmergency()).Methods("GET")
+	s.router.HandleFunc("/ws", s.handleWS()).Methods("GET")
+	if s.guard != nil {
+		s.router.Handle("/auth/login", s.guard.LoginHandler()).Methods("POST")
+		s.router.Handle("/auth/refresh", s.guard.RefreshHandler()).Methods("POST")
+		s.router.Handle("/auth/jwks", s.guard.JWKSHandler()).Methods("GET")
+		s.router.Handle("/emergency/halt", s.permit(s.handleHalt(), guardian.PermissionNodeAdmin)).Methods("POST")
+		s.router.Handle("/emergency/resume", s.permit(s.handleResume(), guardian.PermissionNodeAdmin)).Methods("POST")
+		s.router.Handle("/events", s.protect(s.bus.Handler(), guardian.RoleKnight)).Methods("GET")
+	}
+	s.router.Use(metrics.MuxMiddleware)
+	s.router.Use(tracing.MuxMiddleware)
+}
+
+// protect requires a Guardian session with role, if the Guardian is enabled
+func (s *Server) protect(h http.Handler, role guardian.Role) http.Handler {
+	if s.guard == nil {
+		return h
+	}
+	return s.guard.Middleware(h, role)
+}
+
+// permit requires a Guardian session whose role holds permission, if the
+// Guardian is enabled
+func (s *Server) permit(h http.Handler, permission guardian.Permission) http.Handler {
+	if s.guard == nil {
+		return h
+	}
+	return s.guard.PermissionMiddleware(h, permission)
 }
 
 func (s *Server) handleHealth() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
+		if err := s.treasury.LedgerErr(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "unhealthy",
+				"service": "excalibur-treasury",
+				"error": err.Error(),
+				"build": buildinfo.Get(),
+			})
+			return
+		}
+		response := map[string]interface{}{
 			"status": "healthy",
 			"service": "excalibur-treasury",
-		})
+			"halted": s.emergency.Check() != nil,
+			"build": buildinfo.Get(),
+		}
+		if s.updates != nil {
+			response["update"] = s.updates.Status()
+		}
+		json.NewEncoder(w).Encode(response)
 	}
 }
 
@@ -52,24 +172,85 @@ func (s *Server) handleStats() http.HandlerFunc {
 	}
 }
 
+// handleLeaderboard ranks miners by forges for the Round Table. It is public,
+// so addresses are truncated; ?limit= sets the number of miners returned.
+func (s *Server) handleLeaderboard() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > economy.MaxLeaderboardSize {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", economy.MaxLeaderboardSize), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.treasury.Leaderboard(limit))
+	}
+}
+
 func (s *Server) handleForge() http.HandlerFunc {
 	type forgeRequest struct {
-		MinerAddress string `json:"miner_address"`
+		MinerAddress  string              `json:"miner_address"`
+		Beneficiaries economy.RewardSplit `json:"beneficiaries,omitempty"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.emergency.Check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		var req forgeRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid request format", http.StatusBadRequest)
 			return
 		}
 
-		result := s.treasury.ProcessForge(req.MinerAddress)
+		// A split forge credits the miner reward to every beneficiary; the
+		// first one is the miner
+		var result *economy.ForgeResult
+		var err error
+		_, span := tracing.Start(r.Context(), "forge",
+			attribute.String("miner_address", req.MinerAddress),
+			attribute.Int("beneficiaries", len(req.Beneficiaries)))
+		defer func() { tracing.End(span, err) }()
+		if len(req.Beneficiaries) > 0 {
+			if req.MinerAddress != "" && req.MinerAddress != req.Beneficiaries[0].Address {
+				http.Error(w, "miner_address must be the first beneficiary", http.StatusBadRequest)
+				return
+			}
+			result, err = s.treasury.ProcessForgeSplit(req.Beneficiaries)
+			if errors.Is(err, economy.ErrInvalidSplit) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, economy.ErrPaymentRequired) {
+				http.Error(w, err.Error(), http.StatusPaymentRequired)
+				return
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).Error("Forge processing failed", "err", err)
+			}
+		} else {
+			result, _, err = s.treasury.ProcessForgeWithFee(req.MinerAddress, false)
+			if errors.Is(err, economy.ErrPaymentRequired) {
+				http.Error(w, err.Error(), http.StatusPaymentRequired)
+				return
+			}
+			if result == nil {
+				logging.FromContext(r.Context()).Error("Forge processing failed", "err", err)
+			}
+		}
+		if result == nil && s.treasury.GetTotalMinted() >= economy.TotalSupplyCap {
+			http.Error(w, economy.ErrSupplyCap.Error(), http.StatusConflict)
+			return
+		}
 		if result == nil {
-			log.Printf("Forge processing error: result is nil")
 			http.Error(w, "Forge processing failed", http.StatusInternalServerError)
 			return
 		}
+		span.SetAttributes(attribute.Int("forge.id", result.ForgeID), attribute.Int("block.height", int(result.BlockHeight)))
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
@@ -87,7 +268,7 @@ func (s *Server) handleBalance() http.HandlerFunc {
 			"total_balance":     totalBalance,
 			"spendable_balance": spendableBalance,
 			"locked_balance":    lockedBalance,
-			"forge_fee_pool":    s.treasury.GetForgeFeePool(),
+			"forge_fee_pool":    s.treasury.GetForgeFeePool().ToBTC(),
 		})
 	}
 }
@@ -113,8 +294,327 @@ func (s *Server) handleMiniOutputs() http.HandlerFunc {
 	}
 }
 
+func (s *Server) handleEmergency() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.emergency.State())
+	}
+}
+
+// handleHalt trips the emergency breaker, freezing forges and distributions
+// here and on every service following /events
+func (s *Server) handleHalt() http.HandlerFunc {
+	type haltRequest struct {
+		Reason string `json:"reason"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req haltRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+			http.Error(w, "a reason is required", http.StatusBadRequest)
+			return
+		}
+		by := "unknown"
+		if session, ok := guardian.SessionFromContext(r.Context()); ok {
+			by = session.Username
+		}
+
+		logger := logging.FromContext(r.Context())
+		state, err := s.emergency.Halt(req.Reason, by)
+		logger.Warn("EMERGENCY HALT", "halt", state.ID, "by", state.HaltedBy, "reason", state.Reason)
+		if err != nil {
+			// The halt is in effect but will not survive a restart
+			logger.Error("Emergency halt not saved", "halt", state.ID, "err", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	}
+}
+
+// handleResume records one emergency signer's approval to resume; the halt
+// lifts once enough signers have approved
+func (s *Server) handleResume() http.HandlerFunc {
+	type resumeRequest struct {
+		Signer    string `json:"signer"`
+		Signature string `json:"signature"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req resumeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		sig, err := hex.DecodeString(req.Signature)
+		if err != nil {
+			http.Error(w, "signature must be hex", http.StatusBadRequest)
+			return
+		}
+
+		state, err := s.emergency.Approve(req.Signer, sig)
+		switch {
+		case errors.Is(err, guardian.ErrNotHalted):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, guardian.ErrUnknownSigner), errors.Is(err, guardian.ErrInvalidApproval):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("Emergency resume failed", "err", err)
+			http.Error(w, "resume could not be saved", http.StatusInternalServerError)
+			return
+		}
+		if state.Halted {
+			logging.FromContext(r.Context()).Info("Emergency resume approved", "halt", state.ID, "signer", req.Signer, "approvals", len(state.Approvals), "threshold", state.Threshold)
+		} else {
+			logging.FromContext(r.Context()).Warn("Emergency halt lifted", "halt", state.ID)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	}
+}
+
+// openEmergency creates the treasury's emergency breaker from
+// EMERGENCY_SIGNERS, comma-separated x-only public keys in hex, and
+// EMERGENCY_THRESHOLD, by default a majority of the signers. Its state is
+// kept in dataDir and every change is published on bus.
+func openEmergency(dataDir string, bus *events.Bus) (*guardian.Breaker, error) {
+	var signers []string
+	for _, signer := range strings.Split(os.Getenv("EMERGENCY_SIGNERS"), ",") {
+		if signer = strings.TrimSpace(signer); signer != "" {
+			signers = append(signers, signer)
+		}
+	}
+	threshold := len(signers)/2 + 1
+	if v := os.Getenv("EMERGENCY_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("EMERGENCY_THRESHOLD %q is not a number", v)
+		}
+		threshold = n
+	}
+
+	breaker, err := guardian.NewBreaker(filepath.Join(dataDir, "emergency.json"), signers, threshold)
+	if err != nil {
+		return nil, err
+	}
+	publish := func(state guardian.HaltState) {
+		if _, err := bus.Publish(guardian.EventEmergency, state); err != nil {
+			slog.Error("Failed to publish emergency state", "err", err)
+		}
+	}
+	publish(breaker.State())
+	breaker.OnChange(publish)
+	return breaker, nil
+}
+
+// openTreasuryStore opens the ledger store of backend in dataDir, or returns
+// nil for the file ledger when backend is empty
+func openTreasuryStore(backend, dataDir string) (kv.Store, error) {
+	if backend == "" {
+		return nil, nil
+	}
+	path := filepath.Join(dataDir, "treasury."+backend)
+	if backend == kv.BackendBolt {
+		path = filepath.Join(dataDir, "treasury.db")
+	}
+	return kv.Open(backend, path)
+}
+
 func main() {
-	server := NewServer()
+	if len(os.Args) > 1 && os.Args[1] == "keygen-ceremony" {
+		os.Exit(runCeremony(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "unlockable" {
+		os.Exit(runUnlockable(os.Args[2:]))
+	}
+	var logOptions logging.Options
+	logOptions.Register(flag.CommandLine)
+	flag.Parse()
+	if err := logOptions.Setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	dataDir := os.Getenv("TREASURY_DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	// TREASURY_STORE (bolt, badger, leveldb or memory) keeps the ledger in a
+	// key/value store under the data directory instead of snapshot and log
+	// files
+	storeBackend := os.Getenv("TREASURY_STORE")
+	store, err := openTreasuryStore(storeBackend, dataDir)
+	if err != nil {
+		logging.Fatal("Failed to open treasury store", "err", err)
+	}
+	var treasury *economy.Treasury
+	location := dataDir
+	if store != nil {
+		location = storeBackend + " store in " + dataDir
+		treasury, err = economy.OpenTreasuryStore(store, 0)
+	} else {
+		treasury, err = economy.OpenTreasury(dataDir, 0)
+	}
+	if err != nil {
+		logging.Fatal("Failed to open treasury ledger", "err", err)
+	}
+	info := treasury.LedgerInfo()
+	slog.Info("Treasury ledger recovered", "location", location, "entry", info.Seq, "snapshot", info.SnapshotSeq, "replayed", info.Replayed)
+	if info.TruncatedBytes > 0 {
+		slog.Warn("Discarded incomplete ledger entry", "bytes", info.TruncatedBytes)
+	}
+
+	// Guardian authentication is enabled by GUARDIAN_STORE (bolt, badger,
+	// sqlite or memory), reading users from GUARDIAN_DB or the default store path, or
+	// by GUARDIAN_JWKS_URL alone to accept JWTs another service issues
+	tlsConfig, err := tlsconfig.ServerFromEnv()
+	if err != nil {
+		logging.Fatal("Failed to configure TLS", "err", err)
+	}
+	
+	var guard *guardian.Guardian
+	backend := os.Getenv("GUARDIAN_STORE")
+	if backend == "" && os.Getenv("GUARDIAN_JWKS_URL") != "" {
+		backend = "memory"
+	}
+	if backend != "" {
+		store, err := guardian.OpenStore(backend, os.Getenv("GUARDIAN_DB"))
+		if err != nil {
+			logging.Fatal("Failed to open guardian store", "err", err)
+		}
+		config, err := guardian.ConfigFromEnv()
+		if err != nil {
+			logging.Fatal("Failed to configure guardian", "err", err)
+		}
+		if config.JWKSClient, err = tlsConfig.HTTPClient(30 * time.Second); err != nil {
+			logging.Fatal("Failed to configure guardian", "err", err)
+		}
+		guard, err = guardian.NewGuardianWithStore(config, store)
+		if err != nil {
+			logging.Fatal("Failed to start guardian", "err", err)
+		}
+		defer guard.Close()
+		if spec := os.Getenv("GUARDIAN_AUDIT"); spec != "" {
+			audit, err := guardian.OpenAuditLog(spec)
+			if err != nil {
+				logging.Fatal("Failed to open guardian audit log", "err", err)
+			}
+			guard.SetAuditLog(audit)
+			slog.Info("Guardian audit log enabled")
+		}
+		slog.Info("Guardian enabled", "forge_role", guardian.RoleKnight, "distributions_role", guardian.RoleKingArthur)
+		if keys := guard.JWTKeys(); keys != nil {
+			slog.Info("Guardian issues JWTs, published at /auth/jwks", "key", keys.KeyID())
+		}
+		if url := config.JWKSURL; url != "" {
+			slog.Info("Guardian accepts JWTs signed with a remote key set", "jwks_url", url)
+		}
+	} else {
+		slog.Warn("Guardian disabled: set GUARDIAN_STORE or GUARDIAN_JWKS_URL to protect /forge and /distributions")
+	}
+
+	// With RATE_LIMIT_REDIS_URL, replicas share their rate limit buckets
+	rateBuckets, err := guardian.RateLimitBucketsFromEnv("treasury")
+	if err != nil {
+		logging.Fatal("Failed to configure rate limits", "err", err)
+	}
+	if rateBuckets != nil {
+		defer rateBuckets.Close()
+		pingCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := rateBuckets.Ping(pingCtx); err != nil {
+			slog.Warn("Rate limit Redis unreachable, limiting per replica until it answers", "addr", rateBuckets.Addr(), "err", err)
+		} else {
+			slog.Info("Rate limits shared through Redis", "addr", rateBuckets.Addr())
+		}
+		cancel()
+		if guard != nil {
+			guard.SetRateLimitBuckets(rateBuckets)
+		}
+	}
+
+	bus := events.NewBus()
+	emergency, err := openEmergency(dataDir, bus)
+	if err != nil {
+		logging.Fatal("Failed to open emergency breaker", "err", err)
+	}
+	treasury.SetHaltCheck(emergency.Check)
+	policy, err := claimPolicyFromEnv()
+	if err != nil {
+		logging.Fatal("Failed to configure claims", "err", err)
+	}
+	treasury.SetClaimPolicy(policy)
+	slog.Info("Claims enabled", "reward_exs", policy.Reward, "network", policy.Network.Name)
+	hasKey, err := treasuryKeyFromEnv(treasury, policy.Network)
+	if err != nil {
+		logging.Fatal("Failed to configure treasury key", "err", err)
+	} else if !hasKey {
+		slog.Warn("TREASURY_PUBKEY unset: mini-outputs pay a placeholder key hash nobody can spend")
+	}
+	multisig, err := multisigPolicyFromEnv()
+	if err != nil {
+		logging.Fatal("Failed to configure distribution signers", "err", err)
+	}
+	if err := treasury.SetMultisigPolicy(multisig); err != nil {
+		logging.Fatal("Failed to configure distribution signers", "err", err)
+	}
+	if len(multisig.Signers) == 0 {
+		slog.Info("Distribution proposals disabled: set DISTRIBUTION_SIGNERS to approve payouts")
+	} else {
+		slog.Info("Distribution proposals enabled", "threshold", multisig.Threshold, "signers", len(multisig.Signers))
+	}
+	hooks, err := webhooksFromEnv(dataDir)
+	if err != nil {
+		logging.Fatal("Failed to configure webhooks", "err", err)
+	}
+	if hooks != nil {
+		slog.Info("Posting treasury events to webhooks", "urls", os.Getenv("WEBHOOK_URLS"))
+	}
+	// Forges, distributions, claims and balance changes go out on the bus
+	// for /ws, /events and the gRPC event stream, and to the webhooks
+	treasury.OnEvent(func(typ string, data any) {
+		event, err := bus.Publish(typ, data)
+		if err != nil {
+			slog.Error("Failed to publish event", "type", typ, "err", err)
+			return
+		}
+		if hooks != nil {
+			if err := hooks.Send(event); err != nil {
+				slog.Warn("Webhook delivery dropped", "type", typ, "seq", event.Seq, "err", err)
+			}
+		}
+	})
+	if state := emergency.State(); state.Halted {
+		slog.Warn("EMERGENCY HALT in effect", "halt", state.ID, "since", state.HaltedAt, "reason", state.Reason)
+	}
+	if guard == nil {
+		slog.Info("Emergency halt disabled: it needs GUARDIAN_STORE or GUARDIAN_JWKS_URL")
+	} else if state := emergency.State(); state.Threshold == 0 {
+		slog.Warn("Emergency halt enabled without EMERGENCY_SIGNERS: a halt cannot be resumed")
+	} else {
+		slog.Info("Emergency halt enabled", "threshold", state.Threshold)
+	}
+
+	if err := metrics.WatchTreasury(treasury); err != nil {
+		logging.Fatal("Failed to register treasury metrics", "err", err)
+	}
+	updates, err := update.StartFromEnv(context.Background(), func(r *update.Release) {
+		slog.Info("Excalibur-EXS update available", "version", r.Version, "running", buildinfo.Version, "url", r.URL)
+	})
+	if err != nil {
+		slog.Warn("Update checks disabled", "err", err)
+	}
+	server := NewServer(treasury, guard, emergency, bus, updates)
+	server.webhooks = hooks
+	if guard != nil {
+		// The admin API manages users and sessions remotely, so it also
+		// needs a client certificate
+		if tlsConfig.Mutual() {
+			server.router.PathPrefix("/admin/guardian/").Handler(http.StripPrefix("/admin/guardian", guard.AdminHandler(true)))
+		} else {
+			slog.Info("Guardian admin API disabled: it needs TLS_CLIENT_CA_FILE for mutual TLS")
+		}
+	}
 
 	// CORS configuration
 	allowedOrigins := []string{
@@ -128,19 +628,160 @@ func main() {
 		allowedOrigins = []string{"*"}
 	}
 	
+	server.origins = allowedOrigins
+
 	c := cors.New(cors.Options{
 		AllowedOrigins: allowedOrigins,
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", guardian.APIKeyHeader},
+		ExposedHeaders: []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining"},
 	})
 
-	handler := c.Handler(server.router)
+	// EXS_CHAOS injects faults into every request for resilience testing
+	faults, err := chaos.FromEnv()
+	if err != nil {
+		logging.Fatal("Failed to configure fault injection", "err", err)
+	}
+	if faults != nil {
+		slog.Warn("Injecting faults into every request", "chaos", faults.Config())
+	}
+	traceConfig := tracing.FromEnv()
+	shutdownTracing, err := traceConfig.Setup(context.Background(), "exs-treasury")
+	if err != nil {
+		logging.Fatal("Failed to configure tracing", "err", err)
+	}
+	// Every endpoint is rate limited per caller by RATE_LIMIT_POLICY, with
+	// callers told apart by their Guardian session or API key when enabled
+	var routes http.Handler = server.router
+	rateLimits, err := guardian.RateLimitPolicyFromEnv()
+	if err != nil {
+		logging.Fatal("Failed to configure rate limits", "err", err)
+	}
+	if rateLimits != nil {
+		caller := guardian.CallerFunc(guardian.ClientCaller)
+		if guard != nil {
+			caller = guard.Caller
+		}
+		limiter := guardian.NewPolicyLimiter(rateLimits, caller)
+		if rateBuckets != nil {
+			limiter.SetBuckets(rateBuckets)
+		}
+		routes = limiter.Middleware(routes)
+	} else {
+		slog.Warn("Rate limiting disabled by RATE_LIMIT_POLICY=off")
+	}
+	handler := tracing.Middleware(logging.Middleware(faults.Middleware(c.Handler(routes))))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	log.Printf("Treasury API server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	// Snapshot the ledger on shutdown so the next start replays nothing.
+	// Requests share ctx so open event streams end with the server.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	spv, err := startChainTip(ctx, treasury, policy.Network)
+	if err != nil {
+		logging.Fatal("Failed to start SPV client", "err", err)
+	}
+	if spv != nil {
+		defer spv.Stop()
+		slog.Info("Following the chain tip to unlock mini-outputs", "network", policy.Network.Name, "peers", len(spv.GetPeers()))
+	} else {
+		slog.Info("TREASURY_SPV_PEERS unset: mini-outputs unlock only at heights set on the ledger")
+	}
+	payments, err := paymentPolicyFromEnv(spv, hasKey)
+	if err != nil {
+		logging.Fatal("Failed to configure forge payments", "err", err)
+	}
+	treasury.SetPaymentPolicy(payments)
+	if spv != nil {
+		go server.payments.run(ctx, tipInterval)
+	}
+	if payments.Required {
+		slog.Info("Forges and claims need a forge fee payment", "confirmations", payments.MinConfirmations)
+	} else {
+		slog.Info("Forge payments not required: set FORGE_PAYMENT_REQUIRED to gate forges and claims on a BTC fee")
+	}
+	settler, err := settlementFromEnv(treasury, policy.Network)
+	if err != nil {
+		logging.Fatal("Failed to configure settlement", "err", err)
+	}
+	if settler != nil {
+		treasury.EnableSettlement()
+		go settler.Run(ctx, tipInterval)
+		slog.Info("Settling distributions on Bitcoin", "network", policy.Network.Name, "from", settler.Address())
+	} else {
+		slog.Info("Distribution settlement disabled: set TREASURY_SETTLEMENT_KEY to pay distributions on Bitcoin")
+	}
+	collector, lightningInterval, err := lightningFromEnv(treasury)
+	if err != nil {
+		logging.Fatal("Failed to configure the Lightning node", "err", err)
+	}
+	if collector != nil {
+		go collector.Run(ctx, lightningInterval)
+		slog.Info("Crediting Lightning routing fees", "backend", os.Getenv("LIGHTNING_BACKEND"), "interval", lightningInterval)
+	} else {
+		slog.Info("Lightning routing fees not collected: set LIGHTNING_BACKEND to credit a node's fees")
+	}
+	httpServer := &http.Server{
+		Addr:        ":" + port,
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	// GRPC_PORT serves the treasury over gRPC as well, with the same roles
+	// and, like /events, the event stream only when the Guardian is enabled
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		var auth api.Authorizer
+		var grpcBus *events.Bus
+		if guard != nil {
+			auth = api.GuardianAuth(guard, api.TreasuryPermissions)
+			grpcBus = bus
+		}
+		tlsOpts, err := api.TLSOptions(tlsConfig)
+		if err != nil {
+			logging.Fatal("Failed to configure gRPC TLS", "err", err)
+		}
+		grpcServer := api.NewServer(auth, tlsOpts...)
+		exsv1.RegisterTreasuryServiceServer(grpcServer, api.NewTreasuryServer(treasury, emergency, grpcBus))
+		go func() {
+			slog.Info("Treasury gRPC server starting", "port", grpcPort)
+			if err := api.Serve(ctx, grpcServer, ":"+grpcPort); err != nil {
+				logging.Fatal("Treasury gRPC server failed", "err", err)
+			}
+		}()
+	}
+	
+	// The ledger and Guardian store must answer before requests are taken;
+	// /readyz also waits for SPV peers
+	if spv != nil {
+		server.probe.Add("spv", func(ctx context.Context) error {
+			if spv.GetPeerCount() == 0 {
+				return errors.New("no SPV peers connected")
+			}
+			return nil
+		})
+	}
+	if err := server.probe.Startup(ctx, health.StartupTimeout); err != nil {
+		logging.Fatal("Startup checks failed", "err", err)
+	}
+	slog.Info("Treasury API server starting", "port", port, "transport", tlsConfig.Describe(), "tracing", traceConfig.Describe())
+	serveErr := health.Serve(ctx, httpServer, server.probe, func() error {
+		return tlsconfig.ListenAndServe(httpServer, tlsConfig)
+	})
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Warn("Failed to flush traces", "err", err)
+	}
+	
+	if err := treasury.Close(); err != nil {
+		logging.Fatal("Failed to close treasury ledger", "err", err)
+	}
+	slog.Info("Treasury ledger saved")
+	if hooks != nil {
+		hooks.Close()
+	}
+	if serveErr != nil {
+		logging.Fatal("Treasury API server failed", "err", serveErr)
+	}
 }
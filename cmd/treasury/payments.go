@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gorilla/mux"
+)
+
+// maxPaymentBody bounds a payment verification request: a transaction and
+// its merkle proof
+const maxPaymentBody = 1 << 20
+
+// paymentPolicyFromEnv reads the forge payment policy:
+// FORGE_PAYMENT_REQUIRED gates forges and claims on a verified payment,
+// FORGE_PAYMENT_CONFIRMATIONS is the depth it needs and FORGE_FEE_SATS
+// overrides the fee. Payments are verified against spv, so requiring them
+// needs TREASURY_SPV_PEERS and, for the addresses paid, TREASURY_PUBKEY.
+func paymentPolicyFromEnv(spv *bitcoin.SPVClient, hasKey bool) (economy.PaymentPolicy, error) {
+	policy := economy.PaymentPolicy{MinConfirmations: economy.DefaultPaymentConfirmations}
+	if spv != nil {
+		policy.Chain = spv
+	}
+	if v := os.Getenv("FORGE_PAYMENT_REQUIRED"); v != "" {
+		required, err := strconv.ParseBool(v)
+		if err != nil {
+			return policy, fmt.Errorf("FORGE_PAYMENT_REQUIRED %q is not a boolean", v)
+		}
+		policy.Required = required
+	}
+	if v := os.Getenv("FORGE_PAYMENT_CONFIRMATIONS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n <= 0 {
+			return policy, fmt.Errorf("FORGE_PAYMENT_CONFIRMATIONS %q is not a positive number", v)
+		}
+		policy.MinConfirmations = int32(n)
+	}
+	if v := os.Getenv("FORGE_FEE_SATS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return policy, fmt.Errorf("FORGE_FEE_SATS %q is not a positive number", v)
+		}
+		policy.FeeSats = n
+	}
+	if policy.Required && spv == nil {
+		return policy, errors.New("FORGE_PAYMENT_REQUIRED needs TREASURY_SPV_PEERS to verify payments")
+	}
+	if policy.Required && !hasKey {
+		return policy, errors.New("FORGE_PAYMENT_REQUIRED needs TREASURY_PUBKEY for the addresses paid")
+	}
+	return policy, nil
+}
+
+// paymentProof is a payment transaction waiting for confirmations
+type paymentProof struct {
+	tx    *wire.MsgTx
+	proof bitcoin.TransactionProof
+}
+
+// paymentWatcher verifies submitted payments again as the chain grows
+// until they have enough confirmations
+type paymentWatcher struct {
+	treasury *economy.Treasury
+	mu       sync.Mutex
+	waiting  map[string]paymentProof
+}
+
+func newPaymentWatcher(treasury *economy.Treasury) *paymentWatcher {
+	return &paymentWatcher{treasury: treasury, waiting: make(map[string]paymentProof)}
+}
+
+// verify verifies payment id, and when it lacks confirmations keeps its
+// proof to verify again later
+func (pw *paymentWatcher) verify(id string, tx *wire.MsgTx, proof bitcoin.TransactionProof) (*economy.ForgePayment, error) {
+	payment, err := pw.treasury.VerifyForgePayment(id, tx, proof)
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if errors.Is(err, economy.ErrPaymentUnconfirmed) {
+		pw.waiting[id] = paymentProof{tx: tx, proof: proof}
+	} else {
+		delete(pw.waiting, id)
+	}
+	return payment, err
+}
+
+// run verifies the waiting payments every interval until ctx is done
+func (pw *paymentWatcher) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pw.mu.Lock()
+		waiting := make(map[string]paymentProof, len(pw.waiting))
+		for id, p := range pw.waiting {
+			waiting[id] = p
+		}
+		pw.mu.Unlock()
+		for id, p := range waiting {
+			payment, err := pw.verify(id, p.tx, p.proof)
+			switch {
+			case err == nil:
+				slog.Info("Forge payment verified", "payment", id, "sats", payment.PaidSats, "tx", payment.TxHash)
+			case !errors.Is(err, economy.ErrPaymentUnconfirmed):
+				slog.Warn("Forge payment dropped", "payment", id, "err", err)
+			}
+		}
+	}
+}
+
+func (s *Server) handlePayments() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.treasury.GetForgePayments())
+	}
+}
+
+func (s *Server) handlePayment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payment, err := s.treasury.GetForgePayment(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payment)
+	}
+}
+
+// handleRequestPayment creates a forge fee payment for a miner or claimant
+// address. It is public: a payment only names an address to pay.
+func (s *Server) handleRequestPayment() http.HandlerFunc {
+	type paymentRequest struct {
+		Address string `json:"address"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req paymentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		payment, err := s.treasury.RequestForgePayment(req.Address)
+		switch {
+		case errors.Is(err, economy.ErrInvalidPayment):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, economy.ErrTooManyPayments):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		case errors.Is(err, economy.ErrNoTreasuryKey):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("Forge payment failed", "err", err)
+			http.Error(w, "Forge payment failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(payment)
+	}
+}
+
+// handleVerifyPayment verifies the transaction paying a forge payment
+// against its merkle proof. A payment with too few confirmations is
+// accepted with 202 and verified again as blocks arrive.
+func (s *Server) handleVerifyPayment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req client.PaymentProof
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPaymentBody)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		tx, proof, err := decodePaymentProof(req.RawTx, req.BlockHash, req.MerkleProof, req.Position)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		payment, err := s.payments.verify(id, tx, proof)
+		switch {
+		case errors.Is(err, economy.ErrUnknownPayment):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, economy.ErrInvalidPayment):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, economy.ErrPaymentUnconfirmed):
+			payment, _ = s.treasury.GetForgePayment(id)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(payment)
+			return
+		case errors.Is(err, economy.ErrNoPaymentChain):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("Forge payment verification failed", "err", err)
+			http.Error(w, "Forge payment verification failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payment)
+	}
+}
+
+// decodePaymentProof decodes a raw transaction and the merkle proof of its
+// block, hashes in the byte-reversed hex Bitcoin displays them in
+func decodePaymentProof(rawTx, blockHash string, merkleProof []string, position int) (*wire.MsgTx, bitcoin.TransactionProof, error) {
+	var proof bitcoin.TransactionProof
+	raw, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, proof, fmt.Errorf("raw_tx is not hex")
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, proof, fmt.Errorf("raw_tx is not a transaction: %v", err)
+	}
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		return nil, proof, fmt.Errorf("block_hash %q is not a block hash", blockHash)
+	}
+	proof.BlockHash = *hash
+	for _, h := range merkleProof {
+		sibling, err := chainhash.NewHashFromStr(h)
+		if err != nil {
+			return nil, proof, fmt.Errorf("merkle_proof hash %q is not a hash", h)
+		}
+		proof.MerkleProof = append(proof.MerkleProof, *sibling)
+	}
+	if position < 0 {
+		return nil, proof, fmt.Errorf("position %d is negative", position)
+	}
+	proof.Position = position
+	return tx, proof, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/gorilla/mux"
+)
+
+// multisigPolicyFromEnv reads the distribution signers:
+// DISTRIBUTION_SIGNERS, comma-separated x-only public keys in hex,
+// DISTRIBUTION_THRESHOLD, by default a majority of the signers, and
+// DISTRIBUTION_TTL, how long a proposal collects approvals
+func multisigPolicyFromEnv() (economy.MultisigPolicy, error) {
+	var policy economy.MultisigPolicy
+	for _, signer := range strings.Split(os.Getenv("DISTRIBUTION_SIGNERS"), ",") {
+		if signer = strings.TrimSpace(signer); signer != "" {
+			policy.Signers = append(policy.Signers, signer)
+		}
+	}
+	policy.Threshold = len(policy.Signers)/2 + 1
+	if v := os.Getenv("DISTRIBUTION_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return policy, fmt.Errorf("DISTRIBUTION_THRESHOLD %q is not a number", v)
+		}
+		policy.Threshold = n
+	}
+	if v := os.Getenv("DISTRIBUTION_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			return policy, fmt.Errorf("DISTRIBUTION_TTL %q is not a positive duration", v)
+		}
+		policy.TTL = ttl
+	}
+	return policy, nil
+}
+
+func (s *Server) handleProposals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.treasury.GetProposals())
+	}
+}
+
+func (s *Server) handleProposal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proposal, err := s.treasury.GetProposal(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proposal)
+	}
+}
+
+// handlePropose creates a distribution proposal for the signers to approve
+func (s *Server) handlePropose() http.HandlerFunc {
+	type proposeRequest struct {
+		Amount    economy.Amount `json:"amount"`
+		Recipient string         `json:"recipient"`
+		Purpose   string         `json:"purpose"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req proposeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		by := "unknown"
+		if session, ok := guardian.SessionFromContext(r.Context()); ok {
+			by = session.Username
+		}
+
+		proposal, err := s.treasury.Propose(req.Amount, req.Recipient, req.Purpose, by)
+		if err != nil {
+			proposalError(w, r, err)
+			return
+		}
+		logging.FromContext(r.Context()).Info("Distribution proposed", "proposal", proposal.ID, "by", by, "amount_exs", proposal.Amount, "recipient", proposal.Recipient, "threshold", proposal.Threshold)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(proposal)
+	}
+}
+
+// handleApprove records one distribution signer's approval; the proposal
+// is paid once enough signers have approved
+func (s *Server) handleApprove() http.HandlerFunc {
+	type approveRequest struct {
+		Signer    string `json:"signer"`
+		Signature string `json:"signature"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.emergency.Check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		var req approveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		sig, err := hex.DecodeString(req.Signature)
+		if err != nil {
+			http.Error(w, "signature must be hex", http.StatusBadRequest)
+			return
+		}
+
+		proposal, err := s.treasury.Approve(mux.Vars(r)["id"], req.Signer, sig)
+		if err != nil {
+			proposalError(w, r, err)
+			return
+		}
+		if proposal.Status == economy.ProposalExecuted {
+			logging.FromContext(r.Context()).Info("Distribution proposal executed", "proposal", proposal.ID, "distribution", proposal.DistributionID)
+		} else {
+			logging.FromContext(r.Context()).Info("Distribution proposal approved", "proposal", proposal.ID, "signer", req.Signer, "approvals", len(proposal.Approvals), "threshold", proposal.Threshold)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proposal)
+	}
+}
+
+// proposalError answers a failed proposal or approval
+func proposalError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, economy.ErrInvalidProposal):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, economy.ErrNotSigner), errors.Is(err, economy.ErrInvalidSignature):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, economy.ErrUnknownProposal):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, economy.ErrProposalClosed), errors.Is(err, economy.ErrInsufficientFunds):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, economy.ErrNoSigners):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		// Halted or a ledger failure
+		logging.FromContext(r.Context()).Error("Distribution proposal failed", "err", err)
+		http.Error(w, "Proposal processing failed", http.StatusServiceUnavailable)
+	}
+}
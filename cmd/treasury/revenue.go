@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+)
+
+// handleRevenue lists the revenue credited per stream
+func (s *Server) handleRevenue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.treasury.RevenueStreams())
+	}
+}
+
+// handleCrossChainReward credits a payout of coins mined on another chain
+// through an external pool, as reported by the mining proxy. A payout
+// already credited is a conflict, so the proxy can resend after a failure.
+func (s *Server) handleCrossChainReward() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.emergency.Check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		var req client.CrossChainReward
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		if req.Timestamp.IsZero() {
+			req.Timestamp = time.Now().UTC()
+		}
+		err := s.treasury.ProcessCrossChainReward(req.Chain, req.Index, req.Units, req.Amount, req.Timestamp)
+		switch {
+		case errors.Is(err, economy.ErrInvalidRevenue):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, economy.ErrDuplicateRevenue):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("Cross-chain reward failed", "chain", req.Chain, "payout", req.Index, "err", err)
+			http.Error(w, "Cross-chain reward failed", http.StatusInternalServerError)
+			return
+		}
+		logging.FromContext(r.Context()).Info("Cross-chain reward credited", "chain", req.Chain, "payout", req.Index, "units", req.Units, "amount_exs", req.Amount)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.treasury.RevenueStream(economy.CrossChainStream(req.Chain)))
+	}
+}
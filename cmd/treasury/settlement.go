@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/keystore"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/settlement"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/gorilla/mux"
+)
+
+// settlementFromEnv reads the settlement engine that pays distributions on
+// net: TREASURY_SETTLEMENT_KEY is the WIF key of the address paid from, or
+// "keystore:NAME" for key NAME in the EXS_KEYSTORE keystore, optionally
+// followed by ":" and a hex script root, TREASURY_ESPLORA_URL the
+// Esplora API it is paid through, by default the public one for net,
+// TREASURY_FEE_ESTIMATOR another Esplora API to estimate fees with, and
+// SETTLEMENT_CONFIRMATIONS, SETTLEMENT_MAX_ATTEMPTS, SETTLEMENT_FEE_TARGET,
+// SETTLEMENT_FEE_MODE and SETTLEMENT_SATS_PER_EXS tune it. It returns nil
+// without a key, leaving distributions with mock transaction hashes.
+func settlementFromEnv(treasury *economy.Treasury, net *chaincfg.Params) (*settlement.Engine, error) {
+	v := os.Getenv("TREASURY_SETTLEMENT_KEY")
+	if v == "" {
+		return nil, nil
+	}
+	ks, err := keystore.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.ParseTaprootKey(v, ks, net)
+	if err != nil {
+		return nil, fmt.Errorf("TREASURY_SETTLEMENT_KEY: %w", err)
+	}
+	url := os.Getenv("TREASURY_ESPLORA_URL")
+	if url == "" {
+		url = wallet.DefaultEsploraURL(net)
+	}
+	if url == "" {
+		return nil, fmt.Errorf("TREASURY_SETTLEMENT_KEY needs TREASURY_ESPLORA_URL on %s", net.Name)
+	}
+	source := wallet.NewEsploraSource(url, net)
+	if v := os.Getenv("TREASURY_FEE_ESTIMATOR"); v != "" {
+		source = wallet.NewEsploraSource(v, net)
+	}
+	mode := settlement.DefaultFeeMode
+	if v := os.Getenv("SETTLEMENT_FEE_MODE"); v != "" {
+		if mode, err = fees.ParseMode(v); err != nil {
+			return nil, fmt.Errorf("SETTLEMENT_FEE_MODE: %w", err)
+		}
+	}
+	config := settlement.Config{
+		Key:     key,
+		Net:     net,
+		Chain:   wallet.NewEsploraSource(url, net),
+		Fees:    fees.New(fees.Config{External: source, Mempool: source}),
+		FeeMode: mode,
+	}
+
+	for _, setting := range []struct {
+		name string
+		set  func(n int64)
+	}{
+		{"SETTLEMENT_CONFIRMATIONS", func(n int64) { config.Confirmations = int32(n) }},
+		{"SETTLEMENT_MAX_ATTEMPTS", func(n int64) { config.MaxAttempts = int(n) }},
+		{"SETTLEMENT_FEE_TARGET", func(n int64) { config.FeeTarget = int(n) }},
+		{"SETTLEMENT_SATS_PER_EXS", func(n int64) { config.SatsPerCoin = n }},
+	} {
+		if v := os.Getenv(setting.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s %q is not a positive number", setting.name, v)
+			}
+			setting.set(n)
+		}
+	}
+	return settlement.New(treasury, config)
+}
+
+// handleRetrySettlement returns a failed distribution to the settlement
+// engine, which pays it with a transaction conflicting with the old one
+func (s *Server) handleRetrySettlement() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid distribution ID", http.StatusBadRequest)
+			return
+		}
+		dist, err := s.treasury.RetrySettlement(id)
+		switch {
+		case errors.Is(err, economy.ErrUnknownDistribution):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, economy.ErrSettlementState):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("Settlement retry failed", "distribution", id, "err", err)
+			http.Error(w, "Settlement retry failed", http.StatusServiceUnavailable)
+			return
+		}
+		logging.FromContext(r.Context()).Info("Settlement retried", "distribution", id, "recipient", dist.Recipient, "amount_exs", dist.Amount)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dist)
+	}
+}
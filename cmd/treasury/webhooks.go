@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/webhook"
+)
+
+// defaultWebhookEvents are the events posted without WEBHOOK_EVENTS
+var defaultWebhookEvents = []string{economy.EventForge, economy.EventDistribution, economy.EventClaim}
+
+// webhooksFromEnv reads the endpoints treasury events are posted to:
+// WEBHOOK_URLS is a comma-separated list of URLs, each signed with
+// WEBHOOK_SECRET, and WEBHOOK_EVENTS the event types posted, forges,
+// distributions and claims by default. Attempts are logged to WEBHOOK_LOG,
+// webhooks.log in dataDir by default. It returns nil without URLs.
+func webhooksFromEnv(dataDir string) (*webhook.Dispatcher, error) {
+	urls := splitList(os.Getenv("WEBHOOK_URLS"))
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, errors.New("WEBHOOK_URLS needs a WEBHOOK_SECRET to sign deliveries")
+	}
+	types := splitList(os.Getenv("WEBHOOK_EVENTS"))
+	if len(types) == 0 {
+		types = defaultWebhookEvents
+	}
+	logPath := os.Getenv("WEBHOOK_LOG")
+	if logPath == "" {
+		logPath = filepath.Join(dataDir, "webhooks.log")
+	}
+	config := webhook.Config{LogPath: logPath}
+	for _, url := range urls {
+		config.Endpoints = append(config.Endpoints, webhook.Endpoint{URL: url, Secret: secret, Events: types})
+	}
+	return webhook.New(config)
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// handleWebhookDeliveries lists the latest webhook delivery attempts
+func (s *Server) handleWebhookDeliveries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attempts := []webhook.Attempt{}
+		if s.webhooks != nil {
+			attempts = s.webhooks.Recent()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(attempts)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/events"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+)
+
+const (
+	// wsPingInterval is how often an idle socket is pinged; a client that
+	// does not answer within wsPongTimeout is disconnected
+	wsPingInterval = 30 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	// wsMaxMessage bounds the filter messages a client sends
+	wsMaxMessage = 4096
+)
+
+// wsEventTypes are the events /ws streams: the treasury's own and the
+// public emergency state
+var wsEventTypes = []string{economy.EventForge, economy.EventDistribution, economy.EventBalance, guardian.EventEmergency}
+
+// wsFilter selects the events a /ws client receives. A client may send a
+// filter as a JSON message at any time to replace the current one.
+type wsFilter struct {
+	// Types lists the event types to receive, empty for all
+	Types []string `json:"types"`
+	// Addresses lists the miner and recipient addresses to follow, empty
+	// for all
+	Addresses []string `json:"addresses"`
+}
+
+// wsQueryFilter reads ?types= and ?address= (repeated or comma-separated)
+func wsQueryFilter(r *http.Request) wsFilter {
+	split := func(values []string) []string {
+		var out []string
+		for _, v := range values {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					out = append(out, item)
+				}
+			}
+		}
+		return out
+	}
+	query := r.URL.Query()
+	return wsFilter{Types: split(query["types"]), Addresses: split(query["address"])}
+}
+
+// apply returns the event as the client should see it, or false to skip
+// it. Without an address filter every address is truncated, as on the
+// public leaderboard; with one, only events touching those addresses pass.
+func (f wsFilter) apply(event events.Event) (events.Event, bool) {
+	if !slices.Contains(wsEventTypes, event.Type) || len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return event, false
+	}
+	follows := func(address string) bool { return slices.Contains(f.Addresses, address) }
+	show := func(address string) string {
+		if len(f.Addresses) == 0 {
+			return economy.TruncateAddress(address)
+		}
+		return address
+	}
+
+	var data any
+	switch event.Type {
+	case economy.EventForge:
+		var forge economy.ForgeResult
+		if event.Decode(&forge) != nil {
+			return event, false
+		}
+		match := follows(forge.MinerAddress)
+		for i := range forge.Payouts {
+			match = match || follows(forge.Payouts[i].Address)
+			forge.Payouts[i].Address = show(forge.Payouts[i].Address)
+		}
+		if len(f.Addresses) > 0 && !match {
+			return event, false
+		}
+		forge.MinerAddress = show(forge.MinerAddress)
+		data = forge
+	case economy.EventDistribution:
+		var dist economy.Distribution
+		if event.Decode(&dist) != nil {
+			return event, false
+		}
+		if len(f.Addresses) > 0 && !follows(dist.Recipient) {
+			return event, false
+		}
+		dist.Recipient = show(dist.Recipient)
+		data = dist
+	case economy.EventBalance:
+		var change economy.BalanceChange
+		if event.Decode(&change) != nil {
+			return event, false
+		}
+		addresses := make(map[string]economy.Amount)
+		for address, balance := range change.Addresses {
+			if len(f.Addresses) == 0 || follows(address) {
+				addresses[show(address)] = balance
+			}
+		}
+		if len(f.Addresses) > 0 && len(addresses) == 0 {
+			return event, false
+		}
+		change.Addresses = addresses
+		data = change
+	default:
+		return event, true
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return event, false
+	}
+	event.Data = raw
+	return event, true
+}
+
+// handleWS streams forge, distribution, balance and emergency events over a
+// WebSocket, starting with the latest event of each type. It is public like
+// /stats, so full addresses are only sent to clients that filter on them.
+func (s *Server) handleWS() http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || slices.Contains(s.origins, "*") || slices.Contains(s.origins, origin)
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already answered the request
+			return
+		}
+		defer conn.Close()
+
+		// The reader owns the read side: it takes filter updates and
+		// notices the client going away
+		filters := make(chan wsFilter, 1)
+		done := make(chan struct{})
+		conn.SetReadLimit(wsMaxMessage)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		go func() {
+			defer close(done)
+			for {
+				_, message, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				var filter wsFilter
+				if json.Unmarshal(message, &filter) != nil {
+					continue
+				}
+				select {
+				case <-filters:
+				default:
+				}
+				filters <- filter
+			}
+		}()
+
+		filter := wsQueryFilter(r)
+		stream, cancel := s.bus.Subscribe()
+		defer cancel()
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+
+		send := func(messageType int, data []byte) error {
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			return conn.WriteMessage(messageType, data)
+		}
+		for {
+			select {
+			case <-r.Context().Done():
+				send(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+				return
+			case <-done:
+				return
+			case filter = <-filters:
+			case event, ok := <-stream:
+				if !ok {
+					// Dropped for falling behind; the client reconnects
+					// and catches up from the latest events
+					send(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client fell behind"))
+					return
+				}
+				event, ok = filter.apply(event)
+				if !ok {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					slog.Error("Failed to encode event", "type", event.Type, "err", err)
+					continue
+				}
+				if err := send(websocket.TextMessage, data); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := send(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
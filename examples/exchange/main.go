@@ -0,0 +1,380 @@
+// Command exchange walks through an exchange integration on regtest with the
+// pkg/client SDK: it derives a deposit address and waits for a deposit,
+// mines a round and records the forge to the deposit address, claims the
+// treasury's reward for a proof of forge and withdraws a deposited coin.
+// Run it against a regtest Rosetta server, treasury and Tetra-PoW miner;
+// see README.md.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+)
+
+// network is the only network the walkthrough runs on; it mines and spends
+var network = &chaincfg.RegressionNetParams
+
+// walkthrough holds the clients and the exchange's hot wallet key
+type walkthrough struct {
+	rosetta  *client.Rosetta
+	treasury *client.Treasury
+	miner    *client.Miner
+
+	key     *btcec.PrivateKey
+	deposit string
+}
+
+func main() {
+	rosettaURL := flag.String("rosetta", "http://localhost:8081", "Rosetta API URL")
+	treasuryURL := flag.String("treasury", "http://localhost:8080", "Treasury API URL")
+	minerURL := flag.String("miner", "http://localhost:8082", "Tetra-PoW miner API URL")
+	keyHex := flag.String("key", "", "Hot wallet private key in hex; a new key when empty")
+	user := flag.String("user", "", "Guardian user to log in to the treasury as (a Knight, for /forge)")
+	depositWait := flag.Duration("deposit-wait", 0, "How long to wait for a deposit to the hot wallet")
+	coin := flag.String("coin", "", "Deposited coin to withdraw, as txid:vout:sats; the first listed by /account/coins when empty")
+	withdrawTo := flag.String("withdraw-to", "", "Address to withdraw the coin to")
+	rounds := flag.Int("rounds", 1024, "Mining rounds to try for a block, one nonce each")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	opts := client.Options{UserAgent: "exs-exchange-example"}
+	w := &walkthrough{
+		rosetta:  client.NewRosetta(*rosettaURL, "regtest", opts),
+		treasury: client.NewTreasury(*treasuryURL, opts),
+		miner:    client.NewMiner(*minerURL, opts),
+	}
+	if err := w.loadKey(*keyHex); err != nil {
+		log.Fatalf("Invalid -key: %v", err)
+	}
+	if *user != "" {
+		session, err := w.treasury.Login(ctx, guardian.Credentials{Username: *user, Password: os.Getenv("EXS_PASSWORD")})
+		if err != nil {
+			log.Fatalf("Treasury login failed: %v", err)
+		}
+		fmt.Printf("🔑 Logged in to the treasury as %s (%s) until %s\n", *user, session.Role, session.ExpiresAt.Format(time.RFC3339))
+	}
+
+	steps := []struct {
+		name string
+		run  func(context.Context) error
+	}{
+		{"Check services", w.checkServices},
+		{"Deposit", func(ctx context.Context) error { return w.awaitDeposit(ctx, *depositWait) }},
+		{"Mine", func(ctx context.Context) error { return w.mine(ctx, *rounds) }},
+		{"Claim", w.claim},
+		{"Withdraw", func(ctx context.Context) error { return w.withdraw(ctx, *coin, *withdrawTo) }},
+	}
+	for i, step := range steps {
+		fmt.Printf("\n━━ %d. %s ━━\n", i+1, step.name)
+		if err := step.run(ctx); err != nil {
+			log.Fatalf("%s failed: %v", step.name, err)
+		}
+	}
+	fmt.Println("\n✅ Walkthrough complete")
+}
+
+// loadKey parses the hot wallet key or creates one
+func (w *walkthrough) loadKey(keyHex string) error {
+	if keyHex == "" {
+		key, err := btcec.NewPrivateKey()
+		if err != nil {
+			return err
+		}
+		w.key = key
+		fmt.Printf("🗝️  New hot wallet key %x (pass it as -key to reuse the wallet)\n", key.Serialize())
+	} else {
+		raw, err := hex.DecodeString(keyHex)
+		if err != nil || len(raw) != 32 {
+			return errors.New("must be 32 bytes of hex")
+		}
+		w.key, _ = btcec.PrivKeyFromBytes(raw)
+	}
+	return nil
+}
+
+// depositCoin returns the first confirmed coin of the deposit address that
+// no mempool transaction spends, as txid:vout:sats
+func (w *walkthrough) depositCoin(ctx context.Context) (string, error) {
+	coins, err := w.rosetta.AccountCoins(ctx, w.deposit, true)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range coins.Coins {
+		if c.Metadata["mempool"] != nil || c.Metadata["spent_by"] != nil {
+			continue
+		}
+		sats, err := c.Amount.Sats()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s:%d", c.CoinIdentifier.Identifier, sats), nil
+	}
+	return "", nil
+}
+
+func (w *walkthrough) publicKey() client.PublicKey {
+	return client.PublicKey{
+		HexBytes:  hex.EncodeToString(w.key.PubKey().SerializeCompressed()),
+		CurveType: client.CurveSecp256k1,
+	}
+}
+
+// checkServices reaches every service and derives the deposit address
+func (w *walkthrough) checkServices(ctx context.Context) error {
+	status, err := w.rosetta.NetworkStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("rosetta: %w", err)
+	}
+	fmt.Printf("🌹 Rosetta tip %d (%d peers)\n", status.CurrentBlockIdentifier.Index, len(status.Peers))
+
+	health, err := w.treasury.Health(ctx)
+	if err != nil {
+		return fmt.Errorf("treasury: %w", err)
+	}
+	if health.Halted {
+		return errors.New("treasury is under an emergency halt")
+	}
+	fmt.Printf("🏛️  Treasury %s\n", health.Status)
+
+	if _, err := w.miner.Health(ctx); err != nil {
+		return fmt.Errorf("miner: %w", err)
+	}
+	config, err := w.miner.Config(ctx)
+	if err != nil {
+		return fmt.Errorf("miner: %w", err)
+	}
+	fmt.Printf("⛏️  Miner at difficulty %d with %d epochs\n", config.Difficulty, len(config.Epochs))
+
+	if w.deposit, err = w.rosetta.Derive(ctx, w.publicKey()); err != nil {
+		return fmt.Errorf("deriving the deposit address: %w", err)
+	}
+	fmt.Printf("📬 Deposit address %s\n", w.deposit)
+	return nil
+}
+
+// awaitDeposit polls the deposit address's balance until it is funded or
+// wait runs out
+func (w *walkthrough) awaitDeposit(ctx context.Context, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		balance, err := w.rosetta.AccountBalance(ctx, w.deposit)
+		if err != nil {
+			return err
+		}
+		sats, err := balance.Balances[0].Sats()
+		if err != nil {
+			return err
+		}
+		if sats > 0 || !time.Now().Before(deadline) {
+			fmt.Printf("💰 %s holds %s EXS at block %d\n", w.deposit, economy.Amount(sats), balance.BlockIdentifier.Index)
+			if sats == 0 {
+				fmt.Println("   Nothing deposited yet: fund it, e.g. with exs-node's generatetoaddress, and pass -deposit-wait")
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// mine runs mining rounds at the treasury's next height until one finds a
+// block, and records it to the deposit address
+func (w *walkthrough) mine(ctx context.Context, rounds int) error {
+	stats, err := w.treasury.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	height := stats.CurrentBlockHeight + 1
+	req := client.MineRequest{Height: height, Timestamp: time.Now().Unix()}
+	var result *client.MiningResult
+	for ; req.Nonce < uint64(rounds); req.Nonce++ {
+		if result, err = w.miner.Mine(ctx, req); err != nil {
+			return err
+		}
+		if result.Success {
+			break
+		}
+	}
+	if result == nil || !result.Success {
+		fmt.Printf("🔨 No block at height %d in %d rounds, raise -rounds or lower the miner's -difficulty\n", height, rounds)
+		return nil
+	}
+	fmt.Printf("🔨 Found block %s at height %d with nonce %d\n", result.BlockHash, height, result.Nonce)
+
+	forge, err := w.treasury.Forge(ctx, w.deposit, nil)
+	if errors.Is(err, client.ErrUnauthorized) {
+		fmt.Println("   Not recorded: /forge needs a Knight, pass -user")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📜 Forge #%d credited %s EXS to the deposit address, %s EXS to the treasury\n",
+		forge.ForgeID, forge.MinerReward, forge.TreasuryAllocation)
+	return nil
+}
+
+// claim proves a forge with a random salt and claims the treasury's reward
+// for it, signed by the hot wallet's Taproot output key
+func (w *walkthrough) claim(ctx context.Context) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	fmt.Println("🔮 Proving a forge (HPP-1 takes a while)...")
+	proof, err := crypto.ProofOfForge(crypto.Canonical13WordProphecy, salt, network)
+	if err != nil {
+		return err
+	}
+
+	req := &economy.ClaimRequest{
+		Address:       w.deposit,
+		ProphecyWords: crypto.Canonical13WordProphecy,
+		Salt:          hex.EncodeToString(salt),
+		ProofAddress:  proof.TaprootAddress,
+	}
+	// The deposit address commits to the tweaked key, which signs the claim
+	if err := req.Sign(txscript.TweakTaprootPrivKey(*w.key, nil), network); err != nil {
+		return err
+	}
+	claim, err := w.treasury.Claim(ctx, req)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusConflict || apiErr.StatusCode == http.StatusForbidden) {
+		fmt.Printf("   Not paid: %s\n", apiErr.Message)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🏆 Claim %d paid %s EXS for %s\n", claim.ID, claim.Amount, claim.ProofAddress)
+	return nil
+}
+
+// withdraw spends a deposited coin: half to the withdrawal address and the
+// rest, less the fee, back to the deposit address. Without -coin the first
+// confirmed coin Rosetta lists for the deposit address is spent.
+func (w *walkthrough) withdraw(ctx context.Context, coin, to string) error {
+	if to == "" {
+		fmt.Println("   Skipped: pass -withdraw-to to withdraw a deposit")
+		return nil
+	}
+	if coin == "" {
+		found, err := w.depositCoin(ctx)
+		if err != nil {
+			fmt.Printf("   Skipped: listing the deposit's coins failed (%v); pass -coin txid:vout:sats\n", err)
+			return nil
+		}
+		if found == "" {
+			fmt.Println("   Skipped: the deposit address has no unspent confirmed coin")
+			return nil
+		}
+		coin = found
+		fmt.Printf("🪙 Withdrawing coin %s\n", coin)
+	}
+	i := strings.LastIndex(coin, ":")
+	if i < 0 {
+		return fmt.Errorf("-coin %q is not txid:vout:sats", coin)
+	}
+	value, err := strconv.ParseInt(coin[i+1:], 10, 64)
+	if err != nil || value <= 0 {
+		return fmt.Errorf("-coin %q is not txid:vout:sats", coin)
+	}
+	outpoint := coin[:i]
+	amount := value / 2
+
+	ops := func(change int64) []client.Operation {
+		return []client.Operation{
+			{
+				OperationIdentifier: client.OperationIdentifier{Index: 0},
+				Type:                client.OpTypeInput,
+				Account:             &client.AccountIdentifier{Address: w.deposit},
+				Amount:              client.NewAmount(-value),
+				CoinChange: &client.CoinChange{
+					CoinIdentifier: client.CoinIdentifier{Identifier: outpoint},
+					CoinAction:     client.CoinSpent,
+				},
+			},
+			{
+				OperationIdentifier: client.OperationIdentifier{Index: 1},
+				Type:                client.OpTypeOutput,
+				Account:             &client.AccountIdentifier{Address: to},
+				Amount:              client.NewAmount(amount),
+			},
+			{
+				OperationIdentifier: client.OperationIdentifier{Index: 2},
+				Type:                client.OpTypeOutput,
+				Account:             &client.AccountIdentifier{Address: w.deposit},
+				Amount:              client.NewAmount(change),
+			},
+		}
+	}
+
+	// The fee depends on the size, which the operations give before the
+	// change is known
+	options, err := w.rosetta.Preprocess(ctx, ops(value-amount))
+	if err != nil {
+		return err
+	}
+	metadata, err := w.rosetta.Metadata(ctx, options)
+	if err != nil {
+		return err
+	}
+	fee, err := metadata.SuggestedFee[0].Sats()
+	if err != nil {
+		return err
+	}
+	change := value - amount - fee
+	if change <= 0 {
+		return fmt.Errorf("coin of %s EXS cannot pay a %s EXS fee", economy.Amount(value), economy.Amount(fee))
+	}
+
+	payloads, err := w.rosetta.Payloads(ctx, ops(change), metadata.Metadata)
+	if err != nil {
+		return err
+	}
+	sigs, err := client.SignPayloads(w.key, payloads.Payloads)
+	if err != nil {
+		return err
+	}
+	signed, err := w.rosetta.Combine(ctx, payloads.UnsignedTransaction, sigs)
+	if err != nil {
+		return err
+	}
+	parsed, err := w.rosetta.Parse(ctx, signed, true)
+	if err != nil {
+		return err
+	}
+	if len(parsed.AccountIdentifierSigners) != 1 || parsed.AccountIdentifierSigners[0].Address != w.deposit {
+		return fmt.Errorf("signed transaction has unexpected signers %+v", parsed.AccountIdentifierSigners)
+	}
+	hash, err := w.rosetta.Submit(ctx, signed)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📤 Withdrew %s EXS to %s in %s (fee %s EXS)\n", economy.Amount(amount), to, hash, economy.Amount(fee))
+	return nil
+}
@@ -0,0 +1,178 @@
+// Package api serves the gRPC API of the EXS services, defined by the
+// protobuf files in proto/exs/v1 and generated into package exsv1. It offers
+// integrators typed clients and streams for what the HTTP and JSON-RPC APIs
+// serve as JSON: NodeService for exs-node, MinerService for the tetra_pow
+// miner and TreasuryService for the treasury API.
+//
+// Calls are authorized by an Authorizer run by the server's interceptors:
+// BasicAuth checks the node's RPC credentials, GuardianAuth a Guardian
+// bearer token with a permission per method. Services that carry Guardian
+// tokens serve over the TLS setup of their HTTP API, via TLSOptions.
+package api
+
+//go:generate protoc -I ../../proto --go_out=exsv1 --go_opt=paths=source_relative --go-grpc_out=exsv1 --go-grpc_opt=paths=source_relative exs/v1/node.proto exs/v1/miner.proto exs/v1/treasury.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tlsconfig"
+)
+
+// Authorizer authorizes a call to fullMethod, e.g.
+// "/exs.v1.TreasuryService/Forge", returning the context the call runs with
+// or a status error
+type Authorizer func(ctx context.Context, fullMethod string) (context.Context, error)
+
+// NewServer creates a gRPC server with opts whose calls are authorized by
+// auth, or not at all when auth is nil
+func NewServer(auth Authorizer, opts ...grpc.ServerOption) *grpc.Server {
+	if auth == nil {
+		return grpc.NewServer(opts...)
+	}
+	return grpc.NewServer(append(opts,
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := auth(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := auth(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+		}),
+	)...)
+}
+
+// TLSOptions returns the server options that serve over c's TLS setup,
+// none when TLS is not enabled, which is tlsconfig.ErrPlaintext in
+// HTTPS-only mode
+func TLSOptions(c tlsconfig.Config) ([]grpc.ServerOption, error) {
+	config, err := c.Server()
+	if err != nil || config == nil {
+		return nil, err
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(config))}, nil
+}
+
+// authorizedStream is a server stream running with its authorized context
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+// shutdownTimeout bounds how long Serve waits for calls to finish, since
+// streams run until the client ends them
+const shutdownTimeout = 5 * time.Second
+
+// Serve serves server on addr until ctx is done, then stops it gracefully
+func Serve(ctx context.Context, server *grpc.Server, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("grpc server: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		timer := time.AfterFunc(shutdownTimeout, server.Stop)
+		defer timer.Stop()
+		server.GracefulStop()
+	}()
+	if err := server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("grpc server: %w", err)
+	}
+	return nil
+}
+
+// authorization returns the scheme and credentials of the call's
+// "authorization" metadata
+func authorization(ctx context.Context) (scheme, credentials string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", ""
+	}
+	scheme, credentials, _ = strings.Cut(values[0], " ")
+	return scheme, strings.TrimSpace(credentials)
+}
+
+// BasicAuth requires every call to carry "authorization: Basic ..."
+// metadata with user and password, like the node's JSON-RPC API
+func BasicAuth(user, password string) Authorizer {
+	return func(ctx context.Context, fullMethod string) (context.Context, error) {
+		scheme, credentials := authorization(ctx)
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if !strings.EqualFold(scheme, "Basic") || err != nil {
+			return nil, status.Error(codes.Unauthenticated, "basic authentication required")
+		}
+		gotUser, gotPassword, _ := strings.Cut(string(decoded), ":")
+		userOK := subtle.ConstantTimeCompare([]byte(gotUser), []byte(user)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(gotPassword), []byte(password)) == 1
+		if !userOK || !passwordOK {
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		return ctx, nil
+	}
+}
+
+// GuardianAuth requires calls to the methods in permissions to carry
+// "authorization: Bearer <token>" metadata for a Guardian session holding
+// the method's permission, within any API key scopes, checked like
+// guardian.PermissionMiddleware; other methods are open. The session is
+// available to the call via guardian.SessionFromContext.
+func GuardianAuth(guard *guardian.Guardian, permissions map[string]guardian.Permission) Authorizer {
+	return func(ctx context.Context, fullMethod string) (context.Context, error) {
+		permission, ok := permissions[fullMethod]
+		if !ok {
+			return ctx, nil
+		}
+		var token string
+		if scheme, credentials := authorization(ctx); strings.EqualFold(scheme, "Bearer") {
+			token = credentials
+		}
+		session, err := guard.AuthorizePermission(token, peerIP(ctx), permission)
+		switch {
+		case errors.Is(err, guardian.ErrRateLimitExceeded):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		case errors.Is(err, guardian.ErrUnauthorized):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case err != nil:
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return guardian.ContextWithSession(ctx, session), nil
+	}
+}
+
+// peerIP returns the IP address of the client making the call
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
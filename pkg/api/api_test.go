@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/tlsconfig"
+)
+
+// dial serves the services registered by register on an in-memory
+// listener and returns a client connection to them
+func dial(t *testing.T, auth Authorizer, register func(*grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := NewServer(auth)
+	register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withAuth(ctx context.Context, value string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", value)
+}
+
+func TestBasicAuth(t *testing.T) {
+	node := newTestChain(1)
+	client := exsv1.NewNodeServiceClient(dial(t, BasicAuth("exs", "secret"), func(s *grpc.Server) {
+		exsv1.RegisterNodeServiceServer(s, NewNodeServer(node))
+	}))
+
+	ctx := context.Background()
+	for name, value := range map[string]string{
+		"missing":        "",
+		"wrong password": "Basic " + base64.StdEncoding.EncodeToString([]byte("exs:wrong")),
+		"not base64":     "Basic !!!",
+	} {
+		if _, err := client.GetTip(withAuth(ctx, value), &exsv1.GetTipRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: expected Unauthenticated, got %v", name, err)
+		}
+	}
+	auth := withAuth(ctx, "Basic "+base64.StdEncoding.EncodeToString([]byte("exs:secret")))
+	if _, err := client.GetTip(auth, &exsv1.GetTipRequest{}); err != nil {
+		t.Errorf("GetTip() error = %v", err)
+	}
+}
+
+func TestGuardianAuth(t *testing.T) {
+	guard := guardian.NewGuardian(nil)
+	guard.CreateUser("galahad", "grail1234", guardian.RoleKnight)
+	guard.CreateUser("percival", "grail1234", guardian.RoleSquire)
+	knight, err := guard.Authenticate("galahad", "grail1234", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	squire, err := guard.Authenticate("percival", "grail1234", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := exsv1.NewTreasuryServiceClient(dial(t, GuardianAuth(guard, TreasuryPermissions), func(s *grpc.Server) {
+		exsv1.RegisterTreasuryServiceServer(s, NewTreasuryServer(economy.NewTreasury(), nil, nil))
+	}))
+	ctx := context.Background()
+	if _, err := client.GetBalance(ctx, &exsv1.GetBalanceRequest{}); err != nil {
+		t.Errorf("Expected GetBalance open, got %v", err)
+	}
+	forge := &exsv1.ForgeRequest{MinerAddress: "bc1pknight"}
+	if _, err := client.Forge(ctx, forge); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token, got %v", err)
+	}
+	if _, err := client.Forge(withAuth(ctx, "Bearer invalid"), forge); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated with an invalid token, got %v", err)
+	}
+	if _, err := client.Forge(withAuth(ctx, "Bearer "+squire), forge); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for a squire, got %v", err)
+	}
+	if _, err := client.Forge(withAuth(ctx, "Bearer "+knight), forge); err != nil {
+		t.Errorf("Forge() error = %v", err)
+	}
+	// An API key scoped without forge.submit cannot forge, whatever its role
+	readOnly, _, err := guard.CreateAPIKey(guardian.APIKey{
+		Name:   "auditor",
+		Role:   guardian.RoleKingArthur,
+		Scopes: []guardian.Permission{guardian.PermissionTreasuryRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Forge(withAuth(ctx, "Bearer "+readOnly), forge); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for a key scoped to treasury.read, got %v", err)
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	if opts, err := TLSOptions(tlsconfig.Config{}); err != nil || len(opts) != 0 {
+		t.Errorf("TLSOptions() without TLS = %d options, %v; want none", len(opts), err)
+	}
+	if _, err := TLSOptions(tlsconfig.Config{HTTPSOnly: true}); !errors.Is(err, tlsconfig.ErrPlaintext) {
+		t.Errorf("TLSOptions() of HTTPS-only without a certificate = %v, want ErrPlaintext", err)
+	}
+}
@@ -0,0 +1,498 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v5.29.3
+// source: exs/v1/miner.proto
+
+package exsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MineRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Height     uint32                 `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	StartNonce uint64                 `protobuf:"varint,2,opt,name=start_nonce,json=startNonce,proto3" json:"start_nonce,omitempty"`
+	// Unix time in seconds, zero for now
+	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Nonces to try, zero for no limit
+	MaxAttempts   uint64 `protobuf:"varint,4,opt,name=max_attempts,json=maxAttempts,proto3" json:"max_attempts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MineRequest) Reset() {
+	*x = MineRequest{}
+	mi := &file_exs_v1_miner_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MineRequest) ProtoMessage() {}
+
+func (x *MineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_miner_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MineRequest.ProtoReflect.Descriptor instead.
+func (*MineRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_miner_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MineRequest) GetHeight() uint32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *MineRequest) GetStartNonce() uint64 {
+	if x != nil {
+		return x.StartNonce
+	}
+	return 0
+}
+
+func (x *MineRequest) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *MineRequest) GetMaxAttempts() uint64 {
+	if x != nil {
+		return x.MaxAttempts
+	}
+	return 0
+}
+
+type MineProgress struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Nonces tried by this call so far
+	Attempts uint64 `protobuf:"varint,1,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	// Last nonce tried
+	Nonce uint64 `protobuf:"varint,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	// Nonces per second tried by this call
+	Hashrate float64 `protobuf:"fixed64,3,opt,name=hashrate,proto3" json:"hashrate,omitempty"`
+	// Outcome of the call, set on the last message only
+	Result        *MineResult `protobuf:"bytes,4,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MineProgress) Reset() {
+	*x = MineProgress{}
+	mi := &file_exs_v1_miner_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MineProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MineProgress) ProtoMessage() {}
+
+func (x *MineProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_miner_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MineProgress.ProtoReflect.Descriptor instead.
+func (*MineProgress) Descriptor() ([]byte, []int) {
+	return file_exs_v1_miner_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MineProgress) GetAttempts() uint64 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *MineProgress) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *MineProgress) GetHashrate() float64 {
+	if x != nil {
+		return x.Hashrate
+	}
+	return 0
+}
+
+func (x *MineProgress) GetResult() *MineResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+type MineResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Height        uint32                 `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Epoch         int32                  `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	BlockHash     string                 `protobuf:"bytes,4,opt,name=block_hash,json=blockHash,proto3" json:"block_hash,omitempty"`
+	Nonce         uint64                 `protobuf:"varint,5,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Difficulty    int32                  `protobuf:"varint,6,opt,name=difficulty,proto3" json:"difficulty,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Attempts      uint64                 `protobuf:"varint,8,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	VaultAddress  string                 `protobuf:"bytes,9,opt,name=vault_address,json=vaultAddress,proto3" json:"vault_address,omitempty"`
+	TreasuryAlloc float64                `protobuf:"fixed64,10,opt,name=treasury_alloc,json=treasuryAlloc,proto3" json:"treasury_alloc,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MineResult) Reset() {
+	*x = MineResult{}
+	mi := &file_exs_v1_miner_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MineResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MineResult) ProtoMessage() {}
+
+func (x *MineResult) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_miner_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MineResult.ProtoReflect.Descriptor instead.
+func (*MineResult) Descriptor() ([]byte, []int) {
+	return file_exs_v1_miner_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MineResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *MineResult) GetHeight() uint32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *MineResult) GetEpoch() int32 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *MineResult) GetBlockHash() string {
+	if x != nil {
+		return x.BlockHash
+	}
+	return ""
+}
+
+func (x *MineResult) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *MineResult) GetDifficulty() int32 {
+	if x != nil {
+		return x.Difficulty
+	}
+	return 0
+}
+
+func (x *MineResult) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *MineResult) GetAttempts() uint64 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *MineResult) GetVaultAddress() string {
+	if x != nil {
+		return x.VaultAddress
+	}
+	return ""
+}
+
+func (x *MineResult) GetTreasuryAlloc() float64 {
+	if x != nil {
+		return x.TreasuryAlloc
+	}
+	return 0
+}
+
+type GetMinerStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMinerStatsRequest) Reset() {
+	*x = GetMinerStatsRequest{}
+	mi := &file_exs_v1_miner_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMinerStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMinerStatsRequest) ProtoMessage() {}
+
+func (x *GetMinerStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_miner_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMinerStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetMinerStatsRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_miner_proto_rawDescGZIP(), []int{3}
+}
+
+type MinerStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TotalAttempts uint64                 `protobuf:"varint,1,opt,name=total_attempts,json=totalAttempts,proto3" json:"total_attempts,omitempty"`
+	ValidBlocks   uint64                 `protobuf:"varint,2,opt,name=valid_blocks,json=validBlocks,proto3" json:"valid_blocks,omitempty"`
+	Hashrate      float64                `protobuf:"fixed64,3,opt,name=hashrate,proto3" json:"hashrate,omitempty"`
+	LastBlockTime *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_block_time,json=lastBlockTime,proto3" json:"last_block_time,omitempty"`
+	StartTime     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MinerStats) Reset() {
+	*x = MinerStats{}
+	mi := &file_exs_v1_miner_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MinerStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MinerStats) ProtoMessage() {}
+
+func (x *MinerStats) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_miner_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MinerStats.ProtoReflect.Descriptor instead.
+func (*MinerStats) Descriptor() ([]byte, []int) {
+	return file_exs_v1_miner_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MinerStats) GetTotalAttempts() uint64 {
+	if x != nil {
+		return x.TotalAttempts
+	}
+	return 0
+}
+
+func (x *MinerStats) GetValidBlocks() uint64 {
+	if x != nil {
+		return x.ValidBlocks
+	}
+	return 0
+}
+
+func (x *MinerStats) GetHashrate() float64 {
+	if x != nil {
+		return x.Hashrate
+	}
+	return 0
+}
+
+func (x *MinerStats) GetLastBlockTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastBlockTime
+	}
+	return nil
+}
+
+func (x *MinerStats) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+var File_exs_v1_miner_proto protoreflect.FileDescriptor
+
+const file_exs_v1_miner_proto_rawDesc = "" +
+	"\n" +
+	"\x12exs/v1/miner.proto\x12\x06exs.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x87\x01\n" +
+	"\vMineRequest\x12\x16\n" +
+	"\x06height\x18\x01 \x01(\rR\x06height\x12\x1f\n" +
+	"\vstart_nonce\x18\x02 \x01(\x04R\n" +
+	"startNonce\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12!\n" +
+	"\fmax_attempts\x18\x04 \x01(\x04R\vmaxAttempts\"\x88\x01\n" +
+	"\fMineProgress\x12\x1a\n" +
+	"\battempts\x18\x01 \x01(\x04R\battempts\x12\x14\n" +
+	"\x05nonce\x18\x02 \x01(\x04R\x05nonce\x12\x1a\n" +
+	"\bhashrate\x18\x03 \x01(\x01R\bhashrate\x12*\n" +
+	"\x06result\x18\x04 \x01(\v2\x12.exs.v1.MineResultR\x06result\"\xaf\x02\n" +
+	"\n" +
+	"MineResult\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\rR\x06height\x12\x14\n" +
+	"\x05epoch\x18\x03 \x01(\x05R\x05epoch\x12\x1d\n" +
+	"\n" +
+	"block_hash\x18\x04 \x01(\tR\tblockHash\x12\x14\n" +
+	"\x05nonce\x18\x05 \x01(\x04R\x05nonce\x12\x1e\n" +
+	"\n" +
+	"difficulty\x18\x06 \x01(\x05R\n" +
+	"difficulty\x12\x1c\n" +
+	"\ttimestamp\x18\a \x01(\x03R\ttimestamp\x12\x1a\n" +
+	"\battempts\x18\b \x01(\x04R\battempts\x12#\n" +
+	"\rvault_address\x18\t \x01(\tR\fvaultAddress\x12%\n" +
+	"\x0etreasury_alloc\x18\n" +
+	" \x01(\x01R\rtreasuryAlloc\"\x16\n" +
+	"\x14GetMinerStatsRequest\"\xf1\x01\n" +
+	"\n" +
+	"MinerStats\x12%\n" +
+	"\x0etotal_attempts\x18\x01 \x01(\x04R\rtotalAttempts\x12!\n" +
+	"\fvalid_blocks\x18\x02 \x01(\x04R\vvalidBlocks\x12\x1a\n" +
+	"\bhashrate\x18\x03 \x01(\x01R\bhashrate\x12B\n" +
+	"\x0flast_block_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\rlastBlockTime\x129\n" +
+	"\n" +
+	"start_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime2\x81\x01\n" +
+	"\fMinerService\x123\n" +
+	"\x04Mine\x12\x13.exs.v1.MineRequest\x1a\x14.exs.v1.MineProgress0\x01\x12<\n" +
+	"\bGetStats\x12\x1c.exs.v1.GetMinerStatsRequest\x1a\x12.exs.v1.MinerStatsB<Z:github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1;exsv1b\x06proto3"
+
+var (
+	file_exs_v1_miner_proto_rawDescOnce sync.Once
+	file_exs_v1_miner_proto_rawDescData []byte
+)
+
+func file_exs_v1_miner_proto_rawDescGZIP() []byte {
+	file_exs_v1_miner_proto_rawDescOnce.Do(func() {
+		file_exs_v1_miner_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_exs_v1_miner_proto_rawDesc), len(file_exs_v1_miner_proto_rawDesc)))
+	})
+	return file_exs_v1_miner_proto_rawDescData
+}
+
+var file_exs_v1_miner_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_exs_v1_miner_proto_goTypes = []any{
+	(*MineRequest)(nil),           // 0: exs.v1.MineRequest
+	(*MineProgress)(nil),          // 1: exs.v1.MineProgress
+	(*MineResult)(nil),            // 2: exs.v1.MineResult
+	(*GetMinerStatsRequest)(nil),  // 3: exs.v1.GetMinerStatsRequest
+	(*MinerStats)(nil),            // 4: exs.v1.MinerStats
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+}
+var file_exs_v1_miner_proto_depIdxs = []int32{
+	2, // 0: exs.v1.MineProgress.result:type_name -> exs.v1.MineResult
+	5, // 1: exs.v1.MinerStats.last_block_time:type_name -> google.protobuf.Timestamp
+	5, // 2: exs.v1.MinerStats.start_time:type_name -> google.protobuf.Timestamp
+	0, // 3: exs.v1.MinerService.Mine:input_type -> exs.v1.MineRequest
+	3, // 4: exs.v1.MinerService.GetStats:input_type -> exs.v1.GetMinerStatsRequest
+	1, // 5: exs.v1.MinerService.Mine:output_type -> exs.v1.MineProgress
+	4, // 6: exs.v1.MinerService.GetStats:output_type -> exs.v1.MinerStats
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_exs_v1_miner_proto_init() }
+func file_exs_v1_miner_proto_init() {
+	if File_exs_v1_miner_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_exs_v1_miner_proto_rawDesc), len(file_exs_v1_miner_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_exs_v1_miner_proto_goTypes,
+		DependencyIndexes: file_exs_v1_miner_proto_depIdxs,
+		MessageInfos:      file_exs_v1_miner_proto_msgTypes,
+	}.Build()
+	File_exs_v1_miner_proto = out.File
+	file_exs_v1_miner_proto_goTypes = nil
+	file_exs_v1_miner_proto_depIdxs = nil
+}
@@ -0,0 +1,175 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: exs/v1/miner.proto
+
+package exsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MinerService_Mine_FullMethodName     = "/exs.v1.MinerService/Mine"
+	MinerService_GetStats_FullMethodName = "/exs.v1.MinerService/GetStats"
+)
+
+// MinerServiceClient is the client API for MinerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MinerService runs Tetra-PoW mining rounds on a tetra_pow miner
+type MinerServiceClient interface {
+	// Mine tries nonces from start_nonce until one meets the difficulty, max
+	// attempts run out or the call is cancelled, streaming progress. The last
+	// message carries the result.
+	Mine(ctx context.Context, in *MineRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MineProgress], error)
+	// GetStats returns the miner's statistics since it started
+	GetStats(ctx context.Context, in *GetMinerStatsRequest, opts ...grpc.CallOption) (*MinerStats, error)
+}
+
+type minerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMinerServiceClient(cc grpc.ClientConnInterface) MinerServiceClient {
+	return &minerServiceClient{cc}
+}
+
+func (c *minerServiceClient) Mine(ctx context.Context, in *MineRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MineProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MinerService_ServiceDesc.Streams[0], MinerService_Mine_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[MineRequest, MineProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MinerService_MineClient = grpc.ServerStreamingClient[MineProgress]
+
+func (c *minerServiceClient) GetStats(ctx context.Context, in *GetMinerStatsRequest, opts ...grpc.CallOption) (*MinerStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MinerStats)
+	err := c.cc.Invoke(ctx, MinerService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MinerServiceServer is the server API for MinerService service.
+// All implementations must embed UnimplementedMinerServiceServer
+// for forward compatibility.
+//
+// MinerService runs Tetra-PoW mining rounds on a tetra_pow miner
+type MinerServiceServer interface {
+	// Mine tries nonces from start_nonce until one meets the difficulty, max
+	// attempts run out or the call is cancelled, streaming progress. The last
+	// message carries the result.
+	Mine(*MineRequest, grpc.ServerStreamingServer[MineProgress]) error
+	// GetStats returns the miner's statistics since it started
+	GetStats(context.Context, *GetMinerStatsRequest) (*MinerStats, error)
+	mustEmbedUnimplementedMinerServiceServer()
+}
+
+// UnimplementedMinerServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMinerServiceServer struct{}
+
+func (UnimplementedMinerServiceServer) Mine(*MineRequest, grpc.ServerStreamingServer[MineProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method Mine not implemented")
+}
+func (UnimplementedMinerServiceServer) GetStats(context.Context, *GetMinerStatsRequest) (*MinerStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedMinerServiceServer) mustEmbedUnimplementedMinerServiceServer() {}
+func (UnimplementedMinerServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeMinerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MinerServiceServer will
+// result in compilation errors.
+type UnsafeMinerServiceServer interface {
+	mustEmbedUnimplementedMinerServiceServer()
+}
+
+func RegisterMinerServiceServer(s grpc.ServiceRegistrar, srv MinerServiceServer) {
+	// If the following call pancis, it indicates UnimplementedMinerServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MinerService_ServiceDesc, srv)
+}
+
+func _MinerService_Mine_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MineRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MinerServiceServer).Mine(m, &grpc.GenericServerStream[MineRequest, MineProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MinerService_MineServer = grpc.ServerStreamingServer[MineProgress]
+
+func _MinerService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMinerStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MinerServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MinerService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MinerServiceServer).GetStats(ctx, req.(*GetMinerStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MinerService_ServiceDesc is the grpc.ServiceDesc for MinerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MinerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "exs.v1.MinerService",
+	HandlerType: (*MinerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStats",
+			Handler:    _MinerService_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Mine",
+			Handler:       _MinerService_Mine_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "exs/v1/miner.proto",
+}
@@ -0,0 +1,784 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v5.29.3
+// source: exs/v1/node.proto
+
+package exsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type BlockEvent_Type int32
+
+const (
+	BlockEvent_TYPE_UNSPECIFIED  BlockEvent_Type = 0
+	BlockEvent_TYPE_CONNECTED    BlockEvent_Type = 1
+	BlockEvent_TYPE_DISCONNECTED BlockEvent_Type = 2
+)
+
+// Enum value maps for BlockEvent_Type.
+var (
+	BlockEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_CONNECTED",
+		2: "TYPE_DISCONNECTED",
+	}
+	BlockEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED":  0,
+		"TYPE_CONNECTED":    1,
+		"TYPE_DISCONNECTED": 2,
+	}
+)
+
+func (x BlockEvent_Type) Enum() *BlockEvent_Type {
+	p := new(BlockEvent_Type)
+	*p = x
+	return p
+}
+
+func (x BlockEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BlockEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_exs_v1_node_proto_enumTypes[0].Descriptor()
+}
+
+func (BlockEvent_Type) Type() protoreflect.EnumType {
+	return &file_exs_v1_node_proto_enumTypes[0]
+}
+
+func (x BlockEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BlockEvent_Type.Descriptor instead.
+func (BlockEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{9, 0}
+}
+
+type GetTipRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTipRequest) Reset() {
+	*x = GetTipRequest{}
+	mi := &file_exs_v1_node_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTipRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTipRequest) ProtoMessage() {}
+
+func (x *GetTipRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTipRequest.ProtoReflect.Descriptor instead.
+func (*GetTipRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{0}
+}
+
+type BlockHeader struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Hash         string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Height       int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Version      int32                  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	PreviousHash string                 `protobuf:"bytes,4,opt,name=previous_hash,json=previousHash,proto3" json:"previous_hash,omitempty"`
+	MerkleRoot   string                 `protobuf:"bytes,5,opt,name=merkle_root,json=merkleRoot,proto3" json:"merkle_root,omitempty"`
+	// Unix time in seconds
+	Time          int64  `protobuf:"varint,6,opt,name=time,proto3" json:"time,omitempty"`
+	Bits          uint32 `protobuf:"varint,7,opt,name=bits,proto3" json:"bits,omitempty"`
+	Nonce         uint32 `protobuf:"varint,8,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockHeader) Reset() {
+	*x = BlockHeader{}
+	mi := &file_exs_v1_node_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockHeader) ProtoMessage() {}
+
+func (x *BlockHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockHeader.ProtoReflect.Descriptor instead.
+func (*BlockHeader) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BlockHeader) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *BlockHeader) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *BlockHeader) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *BlockHeader) GetPreviousHash() string {
+	if x != nil {
+		return x.PreviousHash
+	}
+	return ""
+}
+
+func (x *BlockHeader) GetMerkleRoot() string {
+	if x != nil {
+		return x.MerkleRoot
+	}
+	return ""
+}
+
+func (x *BlockHeader) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *BlockHeader) GetBits() uint32 {
+	if x != nil {
+		return x.Bits
+	}
+	return 0
+}
+
+func (x *BlockHeader) GetNonce() uint32 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+type GetBlockRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Block:
+	//
+	//	*GetBlockRequest_Hash
+	//	*GetBlockRequest_Height
+	Block         isGetBlockRequest_Block `protobuf_oneof:"block"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBlockRequest) Reset() {
+	*x = GetBlockRequest{}
+	mi := &file_exs_v1_node_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBlockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBlockRequest) ProtoMessage() {}
+
+func (x *GetBlockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBlockRequest.ProtoReflect.Descriptor instead.
+func (*GetBlockRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetBlockRequest) GetBlock() isGetBlockRequest_Block {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+func (x *GetBlockRequest) GetHash() string {
+	if x != nil {
+		if x, ok := x.Block.(*GetBlockRequest_Hash); ok {
+			return x.Hash
+		}
+	}
+	return ""
+}
+
+func (x *GetBlockRequest) GetHeight() int32 {
+	if x != nil {
+		if x, ok := x.Block.(*GetBlockRequest_Height); ok {
+			return x.Height
+		}
+	}
+	return 0
+}
+
+type isGetBlockRequest_Block interface {
+	isGetBlockRequest_Block()
+}
+
+type GetBlockRequest_Hash struct {
+	Hash string `protobuf:"bytes,1,opt,name=hash,proto3,oneof"`
+}
+
+type GetBlockRequest_Height struct {
+	Height int32 `protobuf:"varint,2,opt,name=height,proto3,oneof"`
+}
+
+func (*GetBlockRequest_Hash) isGetBlockRequest_Block() {}
+
+func (*GetBlockRequest_Height) isGetBlockRequest_Block() {}
+
+type Block struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Header *BlockHeader           `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Txids  []string               `protobuf:"bytes,2,rep,name=txids,proto3" json:"txids,omitempty"`
+	// Serialized block, set when requested
+	Raw           []byte `protobuf:"bytes,3,opt,name=raw,proto3" json:"raw,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Block) Reset() {
+	*x = Block{}
+	mi := &file_exs_v1_node_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Block) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Block) ProtoMessage() {}
+
+func (x *Block) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Block.ProtoReflect.Descriptor instead.
+func (*Block) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Block) GetHeader() *BlockHeader {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *Block) GetTxids() []string {
+	if x != nil {
+		return x.Txids
+	}
+	return nil
+}
+
+func (x *Block) GetRaw() []byte {
+	if x != nil {
+		return x.Raw
+	}
+	return nil
+}
+
+type GetTransactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Txid          string                 `protobuf:"bytes,1,opt,name=txid,proto3" json:"txid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionRequest) Reset() {
+	*x = GetTransactionRequest{}
+	mi := &file_exs_v1_node_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionRequest) ProtoMessage() {}
+
+func (x *GetTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionRequest.ProtoReflect.Descriptor instead.
+func (*GetTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetTransactionRequest) GetTxid() string {
+	if x != nil {
+		return x.Txid
+	}
+	return ""
+}
+
+type Transaction struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Txid  string                 `protobuf:"bytes,1,opt,name=txid,proto3" json:"txid,omitempty"`
+	Raw   []byte                 `protobuf:"bytes,2,opt,name=raw,proto3" json:"raw,omitempty"`
+	// Hash of the confirming block, empty while in the mempool
+	BlockHash     string `protobuf:"bytes,3,opt,name=block_hash,json=blockHash,proto3" json:"block_hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_exs_v1_node_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Transaction) GetTxid() string {
+	if x != nil {
+		return x.Txid
+	}
+	return ""
+}
+
+func (x *Transaction) GetRaw() []byte {
+	if x != nil {
+		return x.Raw
+	}
+	return nil
+}
+
+func (x *Transaction) GetBlockHash() string {
+	if x != nil {
+		return x.BlockHash
+	}
+	return ""
+}
+
+type SendTransactionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Serialized transaction
+	Raw           []byte `protobuf:"bytes,1,opt,name=raw,proto3" json:"raw,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendTransactionRequest) Reset() {
+	*x = SendTransactionRequest{}
+	mi := &file_exs_v1_node_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendTransactionRequest) ProtoMessage() {}
+
+func (x *SendTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendTransactionRequest.ProtoReflect.Descriptor instead.
+func (*SendTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SendTransactionRequest) GetRaw() []byte {
+	if x != nil {
+		return x.Raw
+	}
+	return nil
+}
+
+type SendTransactionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Txid          string                 `protobuf:"bytes,1,opt,name=txid,proto3" json:"txid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendTransactionResponse) Reset() {
+	*x = SendTransactionResponse{}
+	mi := &file_exs_v1_node_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendTransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendTransactionResponse) ProtoMessage() {}
+
+func (x *SendTransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendTransactionResponse.ProtoReflect.Descriptor instead.
+func (*SendTransactionResponse) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SendTransactionResponse) GetTxid() string {
+	if x != nil {
+		return x.Txid
+	}
+	return ""
+}
+
+type SubscribeBlocksRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// First block to stream; unset to start with the next block
+	StartHeight *int32 `protobuf:"varint,1,opt,name=start_height,json=startHeight,proto3,oneof" json:"start_height,omitempty"`
+	// Whether connected blocks carry their serialized form
+	IncludeRaw    bool `protobuf:"varint,2,opt,name=include_raw,json=includeRaw,proto3" json:"include_raw,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeBlocksRequest) Reset() {
+	*x = SubscribeBlocksRequest{}
+	mi := &file_exs_v1_node_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeBlocksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeBlocksRequest) ProtoMessage() {}
+
+func (x *SubscribeBlocksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeBlocksRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeBlocksRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SubscribeBlocksRequest) GetStartHeight() int32 {
+	if x != nil && x.StartHeight != nil {
+		return *x.StartHeight
+	}
+	return 0
+}
+
+func (x *SubscribeBlocksRequest) GetIncludeRaw() bool {
+	if x != nil {
+		return x.IncludeRaw
+	}
+	return false
+}
+
+type BlockEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          BlockEvent_Type        `protobuf:"varint,1,opt,name=type,proto3,enum=exs.v1.BlockEvent_Type" json:"type,omitempty"`
+	Block         *Block                 `protobuf:"bytes,2,opt,name=block,proto3" json:"block,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockEvent) Reset() {
+	*x = BlockEvent{}
+	mi := &file_exs_v1_node_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockEvent) ProtoMessage() {}
+
+func (x *BlockEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_node_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockEvent.ProtoReflect.Descriptor instead.
+func (*BlockEvent) Descriptor() ([]byte, []int) {
+	return file_exs_v1_node_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BlockEvent) GetType() BlockEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return BlockEvent_TYPE_UNSPECIFIED
+}
+
+func (x *BlockEvent) GetBlock() *Block {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+var File_exs_v1_node_proto protoreflect.FileDescriptor
+
+const file_exs_v1_node_proto_rawDesc = "" +
+	"\n" +
+	"\x11exs/v1/node.proto\x12\x06exs.v1\"\x0f\n" +
+	"\rGetTipRequest\"\xd7\x01\n" +
+	"\vBlockHeader\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x05R\aversion\x12#\n" +
+	"\rprevious_hash\x18\x04 \x01(\tR\fpreviousHash\x12\x1f\n" +
+	"\vmerkle_root\x18\x05 \x01(\tR\n" +
+	"merkleRoot\x12\x12\n" +
+	"\x04time\x18\x06 \x01(\x03R\x04time\x12\x12\n" +
+	"\x04bits\x18\a \x01(\rR\x04bits\x12\x14\n" +
+	"\x05nonce\x18\b \x01(\rR\x05nonce\"J\n" +
+	"\x0fGetBlockRequest\x12\x14\n" +
+	"\x04hash\x18\x01 \x01(\tH\x00R\x04hash\x12\x18\n" +
+	"\x06height\x18\x02 \x01(\x05H\x00R\x06heightB\a\n" +
+	"\x05block\"\\\n" +
+	"\x05Block\x12+\n" +
+	"\x06header\x18\x01 \x01(\v2\x13.exs.v1.BlockHeaderR\x06header\x12\x14\n" +
+	"\x05txids\x18\x02 \x03(\tR\x05txids\x12\x10\n" +
+	"\x03raw\x18\x03 \x01(\fR\x03raw\"+\n" +
+	"\x15GetTransactionRequest\x12\x12\n" +
+	"\x04txid\x18\x01 \x01(\tR\x04txid\"R\n" +
+	"\vTransaction\x12\x12\n" +
+	"\x04txid\x18\x01 \x01(\tR\x04txid\x12\x10\n" +
+	"\x03raw\x18\x02 \x01(\fR\x03raw\x12\x1d\n" +
+	"\n" +
+	"block_hash\x18\x03 \x01(\tR\tblockHash\"*\n" +
+	"\x16SendTransactionRequest\x12\x10\n" +
+	"\x03raw\x18\x01 \x01(\fR\x03raw\"-\n" +
+	"\x17SendTransactionResponse\x12\x12\n" +
+	"\x04txid\x18\x01 \x01(\tR\x04txid\"r\n" +
+	"\x16SubscribeBlocksRequest\x12&\n" +
+	"\fstart_height\x18\x01 \x01(\x05H\x00R\vstartHeight\x88\x01\x01\x12\x1f\n" +
+	"\vinclude_raw\x18\x02 \x01(\bR\n" +
+	"includeRawB\x0f\n" +
+	"\r_start_height\"\xa7\x01\n" +
+	"\n" +
+	"BlockEvent\x12+\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x17.exs.v1.BlockEvent.TypeR\x04type\x12#\n" +
+	"\x05block\x18\x02 \x01(\v2\r.exs.v1.BlockR\x05block\"G\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eTYPE_CONNECTED\x10\x01\x12\x15\n" +
+	"\x11TYPE_DISCONNECTED\x10\x022\xdc\x02\n" +
+	"\vNodeService\x124\n" +
+	"\x06GetTip\x12\x15.exs.v1.GetTipRequest\x1a\x13.exs.v1.BlockHeader\x122\n" +
+	"\bGetBlock\x12\x17.exs.v1.GetBlockRequest\x1a\r.exs.v1.Block\x12D\n" +
+	"\x0eGetTransaction\x12\x1d.exs.v1.GetTransactionRequest\x1a\x13.exs.v1.Transaction\x12R\n" +
+	"\x0fSendTransaction\x12\x1e.exs.v1.SendTransactionRequest\x1a\x1f.exs.v1.SendTransactionResponse\x12I\n" +
+	"\x0fSubscribeBlocks\x12\x1e.exs.v1.SubscribeBlocksRequest\x1a\x12.exs.v1.BlockEvent(\x010\x01B<Z:github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1;exsv1b\x06proto3"
+
+var (
+	file_exs_v1_node_proto_rawDescOnce sync.Once
+	file_exs_v1_node_proto_rawDescData []byte
+)
+
+func file_exs_v1_node_proto_rawDescGZIP() []byte {
+	file_exs_v1_node_proto_rawDescOnce.Do(func() {
+		file_exs_v1_node_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_exs_v1_node_proto_rawDesc), len(file_exs_v1_node_proto_rawDesc)))
+	})
+	return file_exs_v1_node_proto_rawDescData
+}
+
+var file_exs_v1_node_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_exs_v1_node_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_exs_v1_node_proto_goTypes = []any{
+	(BlockEvent_Type)(0),            // 0: exs.v1.BlockEvent.Type
+	(*GetTipRequest)(nil),           // 1: exs.v1.GetTipRequest
+	(*BlockHeader)(nil),             // 2: exs.v1.BlockHeader
+	(*GetBlockRequest)(nil),         // 3: exs.v1.GetBlockRequest
+	(*Block)(nil),                   // 4: exs.v1.Block
+	(*GetTransactionRequest)(nil),   // 5: exs.v1.GetTransactionRequest
+	(*Transaction)(nil),             // 6: exs.v1.Transaction
+	(*SendTransactionRequest)(nil),  // 7: exs.v1.SendTransactionRequest
+	(*SendTransactionResponse)(nil), // 8: exs.v1.SendTransactionResponse
+	(*SubscribeBlocksRequest)(nil),  // 9: exs.v1.SubscribeBlocksRequest
+	(*BlockEvent)(nil),              // 10: exs.v1.BlockEvent
+}
+var file_exs_v1_node_proto_depIdxs = []int32{
+	2,  // 0: exs.v1.Block.header:type_name -> exs.v1.BlockHeader
+	0,  // 1: exs.v1.BlockEvent.type:type_name -> exs.v1.BlockEvent.Type
+	4,  // 2: exs.v1.BlockEvent.block:type_name -> exs.v1.Block
+	1,  // 3: exs.v1.NodeService.GetTip:input_type -> exs.v1.GetTipRequest
+	3,  // 4: exs.v1.NodeService.GetBlock:input_type -> exs.v1.GetBlockRequest
+	5,  // 5: exs.v1.NodeService.GetTransaction:input_type -> exs.v1.GetTransactionRequest
+	7,  // 6: exs.v1.NodeService.SendTransaction:input_type -> exs.v1.SendTransactionRequest
+	9,  // 7: exs.v1.NodeService.SubscribeBlocks:input_type -> exs.v1.SubscribeBlocksRequest
+	2,  // 8: exs.v1.NodeService.GetTip:output_type -> exs.v1.BlockHeader
+	4,  // 9: exs.v1.NodeService.GetBlock:output_type -> exs.v1.Block
+	6,  // 10: exs.v1.NodeService.GetTransaction:output_type -> exs.v1.Transaction
+	8,  // 11: exs.v1.NodeService.SendTransaction:output_type -> exs.v1.SendTransactionResponse
+	10, // 12: exs.v1.NodeService.SubscribeBlocks:output_type -> exs.v1.BlockEvent
+	8,  // [8:13] is the sub-list for method output_type
+	3,  // [3:8] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_exs_v1_node_proto_init() }
+func file_exs_v1_node_proto_init() {
+	if File_exs_v1_node_proto != nil {
+		return
+	}
+	file_exs_v1_node_proto_msgTypes[2].OneofWrappers = []any{
+		(*GetBlockRequest_Hash)(nil),
+		(*GetBlockRequest_Height)(nil),
+	}
+	file_exs_v1_node_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_exs_v1_node_proto_rawDesc), len(file_exs_v1_node_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_exs_v1_node_proto_goTypes,
+		DependencyIndexes: file_exs_v1_node_proto_depIdxs,
+		EnumInfos:         file_exs_v1_node_proto_enumTypes,
+		MessageInfos:      file_exs_v1_node_proto_msgTypes,
+	}.Build()
+	File_exs_v1_node_proto = out.File
+	file_exs_v1_node_proto_goTypes = nil
+	file_exs_v1_node_proto_depIdxs = nil
+}
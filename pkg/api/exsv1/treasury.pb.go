@@ -0,0 +1,1104 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v5.29.3
+// source: exs/v1/treasury.proto
+
+package exsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListMiniOutputsRequest_Filter int32
+
+const (
+	ListMiniOutputsRequest_FILTER_UNSPECIFIED ListMiniOutputsRequest_Filter = 0
+	ListMiniOutputsRequest_FILTER_SPENDABLE   ListMiniOutputsRequest_Filter = 1
+	ListMiniOutputsRequest_FILTER_LOCKED      ListMiniOutputsRequest_Filter = 2
+)
+
+// Enum value maps for ListMiniOutputsRequest_Filter.
+var (
+	ListMiniOutputsRequest_Filter_name = map[int32]string{
+		0: "FILTER_UNSPECIFIED",
+		1: "FILTER_SPENDABLE",
+		2: "FILTER_LOCKED",
+	}
+	ListMiniOutputsRequest_Filter_value = map[string]int32{
+		"FILTER_UNSPECIFIED": 0,
+		"FILTER_SPENDABLE":   1,
+		"FILTER_LOCKED":      2,
+	}
+)
+
+func (x ListMiniOutputsRequest_Filter) Enum() *ListMiniOutputsRequest_Filter {
+	p := new(ListMiniOutputsRequest_Filter)
+	*p = x
+	return p
+}
+
+func (x ListMiniOutputsRequest_Filter) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ListMiniOutputsRequest_Filter) Descriptor() protoreflect.EnumDescriptor {
+	return file_exs_v1_treasury_proto_enumTypes[0].Descriptor()
+}
+
+func (ListMiniOutputsRequest_Filter) Type() protoreflect.EnumType {
+	return &file_exs_v1_treasury_proto_enumTypes[0]
+}
+
+func (x ListMiniOutputsRequest_Filter) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ListMiniOutputsRequest_Filter.Descriptor instead.
+func (ListMiniOutputsRequest_Filter) EnumDescriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{4, 0}
+}
+
+type GetTreasuryStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTreasuryStatsRequest) Reset() {
+	*x = GetTreasuryStatsRequest{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTreasuryStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTreasuryStatsRequest) ProtoMessage() {}
+
+func (x *GetTreasuryStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTreasuryStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetTreasuryStatsRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{0}
+}
+
+type TreasuryStats struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	TreasuryBalance    float64                `protobuf:"fixed64,1,opt,name=treasury_balance,json=treasuryBalance,proto3" json:"treasury_balance,omitempty"`
+	SpendableBalance   float64                `protobuf:"fixed64,2,opt,name=spendable_balance,json=spendableBalance,proto3" json:"spendable_balance,omitempty"`
+	LockedBalance      float64                `protobuf:"fixed64,3,opt,name=locked_balance,json=lockedBalance,proto3" json:"locked_balance,omitempty"`
+	TotalFeesCollected float64                `protobuf:"fixed64,4,opt,name=total_fees_collected,json=totalFeesCollected,proto3" json:"total_fees_collected,omitempty"`
+	TotalForges        int64                  `protobuf:"varint,5,opt,name=total_forges,json=totalForges,proto3" json:"total_forges,omitempty"`
+	CurrentBlockHeight uint32                 `protobuf:"varint,6,opt,name=current_block_height,json=currentBlockHeight,proto3" json:"current_block_height,omitempty"`
+	ForgeFeePoolBtc    float64                `protobuf:"fixed64,7,opt,name=forge_fee_pool_btc,json=forgeFeePoolBtc,proto3" json:"forge_fee_pool_btc,omitempty"`
+	TotalMinted        float64                `protobuf:"fixed64,8,opt,name=total_minted,json=totalMinted,proto3" json:"total_minted,omitempty"`
+	SupplyCap          float64                `protobuf:"fixed64,9,opt,name=supply_cap,json=supplyCap,proto3" json:"supply_cap,omitempty"`
+	Halted             bool                   `protobuf:"varint,10,opt,name=halted,proto3" json:"halted,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *TreasuryStats) Reset() {
+	*x = TreasuryStats{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TreasuryStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TreasuryStats) ProtoMessage() {}
+
+func (x *TreasuryStats) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TreasuryStats.ProtoReflect.Descriptor instead.
+func (*TreasuryStats) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TreasuryStats) GetTreasuryBalance() float64 {
+	if x != nil {
+		return x.TreasuryBalance
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetSpendableBalance() float64 {
+	if x != nil {
+		return x.SpendableBalance
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetLockedBalance() float64 {
+	if x != nil {
+		return x.LockedBalance
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetTotalFeesCollected() float64 {
+	if x != nil {
+		return x.TotalFeesCollected
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetTotalForges() int64 {
+	if x != nil {
+		return x.TotalForges
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetCurrentBlockHeight() uint32 {
+	if x != nil {
+		return x.CurrentBlockHeight
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetForgeFeePoolBtc() float64 {
+	if x != nil {
+		return x.ForgeFeePoolBtc
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetTotalMinted() float64 {
+	if x != nil {
+		return x.TotalMinted
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetSupplyCap() float64 {
+	if x != nil {
+		return x.SupplyCap
+	}
+	return 0
+}
+
+func (x *TreasuryStats) GetHalted() bool {
+	if x != nil {
+		return x.Halted
+	}
+	return false
+}
+
+type GetBalanceRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Address whose credited balance to return, empty for none
+	Address       string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBalanceRequest) Reset() {
+	*x = GetBalanceRequest{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBalanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBalanceRequest) ProtoMessage() {}
+
+func (x *GetBalanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBalanceRequest.ProtoReflect.Descriptor instead.
+func (*GetBalanceRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetBalanceRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type Balance struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	TotalBalance     float64                `protobuf:"fixed64,1,opt,name=total_balance,json=totalBalance,proto3" json:"total_balance,omitempty"`
+	SpendableBalance float64                `protobuf:"fixed64,2,opt,name=spendable_balance,json=spendableBalance,proto3" json:"spendable_balance,omitempty"`
+	LockedBalance    float64                `protobuf:"fixed64,3,opt,name=locked_balance,json=lockedBalance,proto3" json:"locked_balance,omitempty"`
+	ForgeFeePool     float64                `protobuf:"fixed64,4,opt,name=forge_fee_pool,json=forgeFeePool,proto3" json:"forge_fee_pool,omitempty"`
+	AddressBalance   float64                `protobuf:"fixed64,5,opt,name=address_balance,json=addressBalance,proto3" json:"address_balance,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Balance) Reset() {
+	*x = Balance{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Balance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Balance) ProtoMessage() {}
+
+func (x *Balance) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Balance.ProtoReflect.Descriptor instead.
+func (*Balance) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Balance) GetTotalBalance() float64 {
+	if x != nil {
+		return x.TotalBalance
+	}
+	return 0
+}
+
+func (x *Balance) GetSpendableBalance() float64 {
+	if x != nil {
+		return x.SpendableBalance
+	}
+	return 0
+}
+
+func (x *Balance) GetLockedBalance() float64 {
+	if x != nil {
+		return x.LockedBalance
+	}
+	return 0
+}
+
+func (x *Balance) GetForgeFeePool() float64 {
+	if x != nil {
+		return x.ForgeFeePool
+	}
+	return 0
+}
+
+func (x *Balance) GetAddressBalance() float64 {
+	if x != nil {
+		return x.AddressBalance
+	}
+	return 0
+}
+
+type ListMiniOutputsRequest struct {
+	state         protoimpl.MessageState        `protogen:"open.v1"`
+	Filter        ListMiniOutputsRequest_Filter `protobuf:"varint,1,opt,name=filter,proto3,enum=exs.v1.ListMiniOutputsRequest_Filter" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMiniOutputsRequest) Reset() {
+	*x = ListMiniOutputsRequest{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMiniOutputsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMiniOutputsRequest) ProtoMessage() {}
+
+func (x *ListMiniOutputsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMiniOutputsRequest.ProtoReflect.Descriptor instead.
+func (*ListMiniOutputsRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListMiniOutputsRequest) GetFilter() ListMiniOutputsRequest_Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return ListMiniOutputsRequest_FILTER_UNSPECIFIED
+}
+
+type ListMiniOutputsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MiniOutputs   []*MiniOutput          `protobuf:"bytes,1,rep,name=mini_outputs,json=miniOutputs,proto3" json:"mini_outputs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMiniOutputsResponse) Reset() {
+	*x = ListMiniOutputsResponse{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMiniOutputsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMiniOutputsResponse) ProtoMessage() {}
+
+func (x *ListMiniOutputsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMiniOutputsResponse.ProtoReflect.Descriptor instead.
+func (*ListMiniOutputsResponse) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListMiniOutputsResponse) GetMiniOutputs() []*MiniOutput {
+	if x != nil {
+		return x.MiniOutputs
+	}
+	return nil
+}
+
+type MiniOutput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OutputId      int64                  `protobuf:"varint,1,opt,name=output_id,json=outputId,proto3" json:"output_id,omitempty"`
+	BlockHeight   uint32                 `protobuf:"varint,2,opt,name=block_height,json=blockHeight,proto3" json:"block_height,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	LockHeight    uint32                 `protobuf:"varint,4,opt,name=lock_height,json=lockHeight,proto3" json:"lock_height,omitempty"`
+	Spendable     bool                   `protobuf:"varint,5,opt,name=spendable,proto3" json:"spendable,omitempty"`
+	Spent         bool                   `protobuf:"varint,6,opt,name=spent,proto3" json:"spent,omitempty"`
+	CltvScript    []byte                 `protobuf:"bytes,7,opt,name=cltv_script,json=cltvScript,proto3" json:"cltv_script,omitempty"`
+	ScriptAddress string                 `protobuf:"bytes,8,opt,name=script_address,json=scriptAddress,proto3" json:"script_address,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MiniOutput) Reset() {
+	*x = MiniOutput{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MiniOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MiniOutput) ProtoMessage() {}
+
+func (x *MiniOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MiniOutput.ProtoReflect.Descriptor instead.
+func (*MiniOutput) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *MiniOutput) GetOutputId() int64 {
+	if x != nil {
+		return x.OutputId
+	}
+	return 0
+}
+
+func (x *MiniOutput) GetBlockHeight() uint32 {
+	if x != nil {
+		return x.BlockHeight
+	}
+	return 0
+}
+
+func (x *MiniOutput) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *MiniOutput) GetLockHeight() uint32 {
+	if x != nil {
+		return x.LockHeight
+	}
+	return 0
+}
+
+func (x *MiniOutput) GetSpendable() bool {
+	if x != nil {
+		return x.Spendable
+	}
+	return false
+}
+
+func (x *MiniOutput) GetSpent() bool {
+	if x != nil {
+		return x.Spent
+	}
+	return false
+}
+
+func (x *MiniOutput) GetCltvScript() []byte {
+	if x != nil {
+		return x.CltvScript
+	}
+	return nil
+}
+
+func (x *MiniOutput) GetScriptAddress() string {
+	if x != nil {
+		return x.ScriptAddress
+	}
+	return ""
+}
+
+func (x *MiniOutput) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type Beneficiary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Percent       float64                `protobuf:"fixed64,2,opt,name=percent,proto3" json:"percent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Beneficiary) Reset() {
+	*x = Beneficiary{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Beneficiary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Beneficiary) ProtoMessage() {}
+
+func (x *Beneficiary) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Beneficiary.ProtoReflect.Descriptor instead.
+func (*Beneficiary) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Beneficiary) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Beneficiary) GetPercent() float64 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+type ForgeRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	MinerAddress string                 `protobuf:"bytes,1,opt,name=miner_address,json=minerAddress,proto3" json:"miner_address,omitempty"`
+	// Beneficiaries sharing the miner reward, the miner first
+	Beneficiaries []*Beneficiary `protobuf:"bytes,2,rep,name=beneficiaries,proto3" json:"beneficiaries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForgeRequest) Reset() {
+	*x = ForgeRequest{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForgeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForgeRequest) ProtoMessage() {}
+
+func (x *ForgeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForgeRequest.ProtoReflect.Descriptor instead.
+func (*ForgeRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ForgeRequest) GetMinerAddress() string {
+	if x != nil {
+		return x.MinerAddress
+	}
+	return ""
+}
+
+func (x *ForgeRequest) GetBeneficiaries() []*Beneficiary {
+	if x != nil {
+		return x.Beneficiaries
+	}
+	return nil
+}
+
+type Payout struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Percent       float64                `protobuf:"fixed64,2,opt,name=percent,proto3" json:"percent,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Payout) Reset() {
+	*x = Payout{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Payout) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payout) ProtoMessage() {}
+
+func (x *Payout) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payout.ProtoReflect.Descriptor instead.
+func (*Payout) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Payout) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Payout) GetPercent() float64 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+func (x *Payout) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+type ForgeResult struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ForgeId            int64                  `protobuf:"varint,1,opt,name=forge_id,json=forgeId,proto3" json:"forge_id,omitempty"`
+	BlockHeight        uint32                 `protobuf:"varint,2,opt,name=block_height,json=blockHeight,proto3" json:"block_height,omitempty"`
+	MinerAddress       string                 `protobuf:"bytes,3,opt,name=miner_address,json=minerAddress,proto3" json:"miner_address,omitempty"`
+	TotalReward        float64                `protobuf:"fixed64,4,opt,name=total_reward,json=totalReward,proto3" json:"total_reward,omitempty"`
+	MinerReward        float64                `protobuf:"fixed64,5,opt,name=miner_reward,json=minerReward,proto3" json:"miner_reward,omitempty"`
+	TreasuryAllocation float64                `protobuf:"fixed64,6,opt,name=treasury_allocation,json=treasuryAllocation,proto3" json:"treasury_allocation,omitempty"`
+	MiniOutputs        []*MiniOutput          `protobuf:"bytes,7,rep,name=mini_outputs,json=miniOutputs,proto3" json:"mini_outputs,omitempty"`
+	ForgeFeeBtc        float64                `protobuf:"fixed64,8,opt,name=forge_fee_btc,json=forgeFeeBtc,proto3" json:"forge_fee_btc,omitempty"`
+	Payouts            []*Payout              `protobuf:"bytes,9,rep,name=payouts,proto3" json:"payouts,omitempty"`
+	Timestamp          *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *ForgeResult) Reset() {
+	*x = ForgeResult{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForgeResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForgeResult) ProtoMessage() {}
+
+func (x *ForgeResult) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForgeResult.ProtoReflect.Descriptor instead.
+func (*ForgeResult) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ForgeResult) GetForgeId() int64 {
+	if x != nil {
+		return x.ForgeId
+	}
+	return 0
+}
+
+func (x *ForgeResult) GetBlockHeight() uint32 {
+	if x != nil {
+		return x.BlockHeight
+	}
+	return 0
+}
+
+func (x *ForgeResult) GetMinerAddress() string {
+	if x != nil {
+		return x.MinerAddress
+	}
+	return ""
+}
+
+func (x *ForgeResult) GetTotalReward() float64 {
+	if x != nil {
+		return x.TotalReward
+	}
+	return 0
+}
+
+func (x *ForgeResult) GetMinerReward() float64 {
+	if x != nil {
+		return x.MinerReward
+	}
+	return 0
+}
+
+func (x *ForgeResult) GetTreasuryAllocation() float64 {
+	if x != nil {
+		return x.TreasuryAllocation
+	}
+	return 0
+}
+
+func (x *ForgeResult) GetMiniOutputs() []*MiniOutput {
+	if x != nil {
+		return x.MiniOutputs
+	}
+	return nil
+}
+
+func (x *ForgeResult) GetForgeFeeBtc() float64 {
+	if x != nil {
+		return x.ForgeFeeBtc
+	}
+	return 0
+}
+
+func (x *ForgeResult) GetPayouts() []*Payout {
+	if x != nil {
+		return x.Payouts
+	}
+	return nil
+}
+
+func (x *ForgeResult) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type SubscribeEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Event types to stream, empty for every type
+	Types         []string `protobuf:"bytes,1,rep,name=types,proto3" json:"types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeEventsRequest) Reset() {
+	*x = SubscribeEventsRequest{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeEventsRequest) ProtoMessage() {}
+
+func (x *SubscribeEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeEventsRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeEventsRequest) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SubscribeEventsRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Seq   uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Type  string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Time  *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	// Event data as JSON
+	Data          []byte `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_exs_v1_treasury_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_exs_v1_treasury_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_exs_v1_treasury_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Event) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Event) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_exs_v1_treasury_proto protoreflect.FileDescriptor
+
+const file_exs_v1_treasury_proto_rawDesc = "" +
+	"\n" +
+	"\x15exs/v1/treasury.proto\x12\x06exs.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x19\n" +
+	"\x17GetTreasuryStatsRequest\"\x9c\x03\n" +
+	"\rTreasuryStats\x12)\n" +
+	"\x10treasury_balance\x18\x01 \x01(\x01R\x0ftreasuryBalance\x12+\n" +
+	"\x11spendable_balance\x18\x02 \x01(\x01R\x10spendableBalance\x12%\n" +
+	"\x0elocked_balance\x18\x03 \x01(\x01R\rlockedBalance\x120\n" +
+	"\x14total_fees_collected\x18\x04 \x01(\x01R\x12totalFeesCollected\x12!\n" +
+	"\ftotal_forges\x18\x05 \x01(\x03R\vtotalForges\x120\n" +
+	"\x14current_block_height\x18\x06 \x01(\rR\x12currentBlockHeight\x12+\n" +
+	"\x12forge_fee_pool_btc\x18\a \x01(\x01R\x0fforgeFeePoolBtc\x12!\n" +
+	"\ftotal_minted\x18\b \x01(\x01R\vtotalMinted\x12\x1d\n" +
+	"\n" +
+	"supply_cap\x18\t \x01(\x01R\tsupplyCap\x12\x16\n" +
+	"\x06halted\x18\n" +
+	" \x01(\bR\x06halted\"-\n" +
+	"\x11GetBalanceRequest\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\"\xd1\x01\n" +
+	"\aBalance\x12#\n" +
+	"\rtotal_balance\x18\x01 \x01(\x01R\ftotalBalance\x12+\n" +
+	"\x11spendable_balance\x18\x02 \x01(\x01R\x10spendableBalance\x12%\n" +
+	"\x0elocked_balance\x18\x03 \x01(\x01R\rlockedBalance\x12$\n" +
+	"\x0eforge_fee_pool\x18\x04 \x01(\x01R\fforgeFeePool\x12'\n" +
+	"\x0faddress_balance\x18\x05 \x01(\x01R\x0eaddressBalance\"\xa2\x01\n" +
+	"\x16ListMiniOutputsRequest\x12=\n" +
+	"\x06filter\x18\x01 \x01(\x0e2%.exs.v1.ListMiniOutputsRequest.FilterR\x06filter\"I\n" +
+	"\x06Filter\x12\x16\n" +
+	"\x12FILTER_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10FILTER_SPENDABLE\x10\x01\x12\x11\n" +
+	"\rFILTER_LOCKED\x10\x02\"P\n" +
+	"\x17ListMiniOutputsResponse\x125\n" +
+	"\fmini_outputs\x18\x01 \x03(\v2\x12.exs.v1.MiniOutputR\vminiOutputs\"\xbc\x02\n" +
+	"\n" +
+	"MiniOutput\x12\x1b\n" +
+	"\toutput_id\x18\x01 \x01(\x03R\boutputId\x12!\n" +
+	"\fblock_height\x18\x02 \x01(\rR\vblockHeight\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x12\x1f\n" +
+	"\vlock_height\x18\x04 \x01(\rR\n" +
+	"lockHeight\x12\x1c\n" +
+	"\tspendable\x18\x05 \x01(\bR\tspendable\x12\x14\n" +
+	"\x05spent\x18\x06 \x01(\bR\x05spent\x12\x1f\n" +
+	"\vcltv_script\x18\a \x01(\fR\n" +
+	"cltvScript\x12%\n" +
+	"\x0escript_address\x18\b \x01(\tR\rscriptAddress\x129\n" +
+	"\n" +
+	"created_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"A\n" +
+	"\vBeneficiary\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x18\n" +
+	"\apercent\x18\x02 \x01(\x01R\apercent\"n\n" +
+	"\fForgeRequest\x12#\n" +
+	"\rminer_address\x18\x01 \x01(\tR\fminerAddress\x129\n" +
+	"\rbeneficiaries\x18\x02 \x03(\v2\x13.exs.v1.BeneficiaryR\rbeneficiaries\"T\n" +
+	"\x06Payout\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x18\n" +
+	"\apercent\x18\x02 \x01(\x01R\apercent\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\"\xa6\x03\n" +
+	"\vForgeResult\x12\x19\n" +
+	"\bforge_id\x18\x01 \x01(\x03R\aforgeId\x12!\n" +
+	"\fblock_height\x18\x02 \x01(\rR\vblockHeight\x12#\n" +
+	"\rminer_address\x18\x03 \x01(\tR\fminerAddress\x12!\n" +
+	"\ftotal_reward\x18\x04 \x01(\x01R\vtotalReward\x12!\n" +
+	"\fminer_reward\x18\x05 \x01(\x01R\vminerReward\x12/\n" +
+	"\x13treasury_allocation\x18\x06 \x01(\x01R\x12treasuryAllocation\x125\n" +
+	"\fmini_outputs\x18\a \x03(\v2\x12.exs.v1.MiniOutputR\vminiOutputs\x12\"\n" +
+	"\rforge_fee_btc\x18\b \x01(\x01R\vforgeFeeBtc\x12(\n" +
+	"\apayouts\x18\t \x03(\v2\x0e.exs.v1.PayoutR\apayouts\x128\n" +
+	"\ttimestamp\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\".\n" +
+	"\x16SubscribeEventsRequest\x12\x14\n" +
+	"\x05types\x18\x01 \x03(\tR\x05types\"q\n" +
+	"\x05Event\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12.\n" +
+	"\x04time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\fR\x04data2\xdb\x02\n" +
+	"\x0fTreasuryService\x12B\n" +
+	"\bGetStats\x12\x1f.exs.v1.GetTreasuryStatsRequest\x1a\x15.exs.v1.TreasuryStats\x128\n" +
+	"\n" +
+	"GetBalance\x12\x19.exs.v1.GetBalanceRequest\x1a\x0f.exs.v1.Balance\x12R\n" +
+	"\x0fListMiniOutputs\x12\x1e.exs.v1.ListMiniOutputsRequest\x1a\x1f.exs.v1.ListMiniOutputsResponse\x122\n" +
+	"\x05Forge\x12\x14.exs.v1.ForgeRequest\x1a\x13.exs.v1.ForgeResult\x12B\n" +
+	"\x0fSubscribeEvents\x12\x1e.exs.v1.SubscribeEventsRequest\x1a\r.exs.v1.Event0\x01B<Z:github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1;exsv1b\x06proto3"
+
+var (
+	file_exs_v1_treasury_proto_rawDescOnce sync.Once
+	file_exs_v1_treasury_proto_rawDescData []byte
+)
+
+func file_exs_v1_treasury_proto_rawDescGZIP() []byte {
+	file_exs_v1_treasury_proto_rawDescOnce.Do(func() {
+		file_exs_v1_treasury_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_exs_v1_treasury_proto_rawDesc), len(file_exs_v1_treasury_proto_rawDesc)))
+	})
+	return file_exs_v1_treasury_proto_rawDescData
+}
+
+var file_exs_v1_treasury_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_exs_v1_treasury_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_exs_v1_treasury_proto_goTypes = []any{
+	(ListMiniOutputsRequest_Filter)(0), // 0: exs.v1.ListMiniOutputsRequest.Filter
+	(*GetTreasuryStatsRequest)(nil),    // 1: exs.v1.GetTreasuryStatsRequest
+	(*TreasuryStats)(nil),              // 2: exs.v1.TreasuryStats
+	(*GetBalanceRequest)(nil),          // 3: exs.v1.GetBalanceRequest
+	(*Balance)(nil),                    // 4: exs.v1.Balance
+	(*ListMiniOutputsRequest)(nil),     // 5: exs.v1.ListMiniOutputsRequest
+	(*ListMiniOutputsResponse)(nil),    // 6: exs.v1.ListMiniOutputsResponse
+	(*MiniOutput)(nil),                 // 7: exs.v1.MiniOutput
+	(*Beneficiary)(nil),                // 8: exs.v1.Beneficiary
+	(*ForgeRequest)(nil),               // 9: exs.v1.ForgeRequest
+	(*Payout)(nil),                     // 10: exs.v1.Payout
+	(*ForgeResult)(nil),                // 11: exs.v1.ForgeResult
+	(*SubscribeEventsRequest)(nil),     // 12: exs.v1.SubscribeEventsRequest
+	(*Event)(nil),                      // 13: exs.v1.Event
+	(*timestamppb.Timestamp)(nil),      // 14: google.protobuf.Timestamp
+}
+var file_exs_v1_treasury_proto_depIdxs = []int32{
+	0,  // 0: exs.v1.ListMiniOutputsRequest.filter:type_name -> exs.v1.ListMiniOutputsRequest.Filter
+	7,  // 1: exs.v1.ListMiniOutputsResponse.mini_outputs:type_name -> exs.v1.MiniOutput
+	14, // 2: exs.v1.MiniOutput.created_at:type_name -> google.protobuf.Timestamp
+	8,  // 3: exs.v1.ForgeRequest.beneficiaries:type_name -> exs.v1.Beneficiary
+	7,  // 4: exs.v1.ForgeResult.mini_outputs:type_name -> exs.v1.MiniOutput
+	10, // 5: exs.v1.ForgeResult.payouts:type_name -> exs.v1.Payout
+	14, // 6: exs.v1.ForgeResult.timestamp:type_name -> google.protobuf.Timestamp
+	14, // 7: exs.v1.Event.time:type_name -> google.protobuf.Timestamp
+	1,  // 8: exs.v1.TreasuryService.GetStats:input_type -> exs.v1.GetTreasuryStatsRequest
+	3,  // 9: exs.v1.TreasuryService.GetBalance:input_type -> exs.v1.GetBalanceRequest
+	5,  // 10: exs.v1.TreasuryService.ListMiniOutputs:input_type -> exs.v1.ListMiniOutputsRequest
+	9,  // 11: exs.v1.TreasuryService.Forge:input_type -> exs.v1.ForgeRequest
+	12, // 12: exs.v1.TreasuryService.SubscribeEvents:input_type -> exs.v1.SubscribeEventsRequest
+	2,  // 13: exs.v1.TreasuryService.GetStats:output_type -> exs.v1.TreasuryStats
+	4,  // 14: exs.v1.TreasuryService.GetBalance:output_type -> exs.v1.Balance
+	6,  // 15: exs.v1.TreasuryService.ListMiniOutputs:output_type -> exs.v1.ListMiniOutputsResponse
+	11, // 16: exs.v1.TreasuryService.Forge:output_type -> exs.v1.ForgeResult
+	13, // 17: exs.v1.TreasuryService.SubscribeEvents:output_type -> exs.v1.Event
+	13, // [13:18] is the sub-list for method output_type
+	8,  // [8:13] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_exs_v1_treasury_proto_init() }
+func file_exs_v1_treasury_proto_init() {
+	if File_exs_v1_treasury_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_exs_v1_treasury_proto_rawDesc), len(file_exs_v1_treasury_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_exs_v1_treasury_proto_goTypes,
+		DependencyIndexes: file_exs_v1_treasury_proto_depIdxs,
+		EnumInfos:         file_exs_v1_treasury_proto_enumTypes,
+		MessageInfos:      file_exs_v1_treasury_proto_msgTypes,
+	}.Build()
+	File_exs_v1_treasury_proto = out.File
+	file_exs_v1_treasury_proto_goTypes = nil
+	file_exs_v1_treasury_proto_depIdxs = nil
+}
@@ -0,0 +1,299 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: exs/v1/treasury.proto
+
+package exsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TreasuryService_GetStats_FullMethodName        = "/exs.v1.TreasuryService/GetStats"
+	TreasuryService_GetBalance_FullMethodName      = "/exs.v1.TreasuryService/GetBalance"
+	TreasuryService_ListMiniOutputs_FullMethodName = "/exs.v1.TreasuryService/ListMiniOutputs"
+	TreasuryService_Forge_FullMethodName           = "/exs.v1.TreasuryService/Forge"
+	TreasuryService_SubscribeEvents_FullMethodName = "/exs.v1.TreasuryService/SubscribeEvents"
+)
+
+// TreasuryServiceClient is the client API for TreasuryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TreasuryService serves the protocol treasury, like the treasury HTTP API
+type TreasuryServiceClient interface {
+	// GetStats returns treasury totals
+	GetStats(ctx context.Context, in *GetTreasuryStatsRequest, opts ...grpc.CallOption) (*TreasuryStats, error)
+	// GetBalance returns the treasury balances, and an address's balance
+	// when one is given
+	GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	// ListMiniOutputs returns the treasury's time-locked mini-outputs
+	ListMiniOutputs(ctx context.Context, in *ListMiniOutputsRequest, opts ...grpc.CallOption) (*ListMiniOutputsResponse, error)
+	// Forge processes a forge, optionally splitting the miner reward. It
+	// needs a knight session and fails with UNAVAILABLE during an emergency
+	// halt.
+	Forge(ctx context.Context, in *ForgeRequest, opts ...grpc.CallOption) (*ForgeResult, error)
+	// SubscribeEvents streams fleet events, starting with the latest event of
+	// every type. It needs a knight session.
+	SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type treasuryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTreasuryServiceClient(cc grpc.ClientConnInterface) TreasuryServiceClient {
+	return &treasuryServiceClient{cc}
+}
+
+func (c *treasuryServiceClient) GetStats(ctx context.Context, in *GetTreasuryStatsRequest, opts ...grpc.CallOption) (*TreasuryStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TreasuryStats)
+	err := c.cc.Invoke(ctx, TreasuryService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *treasuryServiceClient) GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, TreasuryService_GetBalance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *treasuryServiceClient) ListMiniOutputs(ctx context.Context, in *ListMiniOutputsRequest, opts ...grpc.CallOption) (*ListMiniOutputsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMiniOutputsResponse)
+	err := c.cc.Invoke(ctx, TreasuryService_ListMiniOutputs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *treasuryServiceClient) Forge(ctx context.Context, in *ForgeRequest, opts ...grpc.CallOption) (*ForgeResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ForgeResult)
+	err := c.cc.Invoke(ctx, TreasuryService_Forge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *treasuryServiceClient) SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TreasuryService_ServiceDesc.Streams[0], TreasuryService_SubscribeEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TreasuryService_SubscribeEventsClient = grpc.ServerStreamingClient[Event]
+
+// TreasuryServiceServer is the server API for TreasuryService service.
+// All implementations must embed UnimplementedTreasuryServiceServer
+// for forward compatibility.
+//
+// TreasuryService serves the protocol treasury, like the treasury HTTP API
+type TreasuryServiceServer interface {
+	// GetStats returns treasury totals
+	GetStats(context.Context, *GetTreasuryStatsRequest) (*TreasuryStats, error)
+	// GetBalance returns the treasury balances, and an address's balance
+	// when one is given
+	GetBalance(context.Context, *GetBalanceRequest) (*Balance, error)
+	// ListMiniOutputs returns the treasury's time-locked mini-outputs
+	ListMiniOutputs(context.Context, *ListMiniOutputsRequest) (*ListMiniOutputsResponse, error)
+	// Forge processes a forge, optionally splitting the miner reward. It
+	// needs a knight session and fails with UNAVAILABLE during an emergency
+	// halt.
+	Forge(context.Context, *ForgeRequest) (*ForgeResult, error)
+	// SubscribeEvents streams fleet events, starting with the latest event of
+	// every type. It needs a knight session.
+	SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedTreasuryServiceServer()
+}
+
+// UnimplementedTreasuryServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTreasuryServiceServer struct{}
+
+func (UnimplementedTreasuryServiceServer) GetStats(context.Context, *GetTreasuryStatsRequest) (*TreasuryStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedTreasuryServiceServer) GetBalance(context.Context, *GetBalanceRequest) (*Balance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedTreasuryServiceServer) ListMiniOutputs(context.Context, *ListMiniOutputsRequest) (*ListMiniOutputsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMiniOutputs not implemented")
+}
+func (UnimplementedTreasuryServiceServer) Forge(context.Context, *ForgeRequest) (*ForgeResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Forge not implemented")
+}
+func (UnimplementedTreasuryServiceServer) SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeEvents not implemented")
+}
+func (UnimplementedTreasuryServiceServer) mustEmbedUnimplementedTreasuryServiceServer() {}
+func (UnimplementedTreasuryServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeTreasuryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TreasuryServiceServer will
+// result in compilation errors.
+type UnsafeTreasuryServiceServer interface {
+	mustEmbedUnimplementedTreasuryServiceServer()
+}
+
+func RegisterTreasuryServiceServer(s grpc.ServiceRegistrar, srv TreasuryServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTreasuryServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TreasuryService_ServiceDesc, srv)
+}
+
+func _TreasuryService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreasuryStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TreasuryServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TreasuryService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TreasuryServiceServer).GetStats(ctx, req.(*GetTreasuryStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TreasuryService_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TreasuryServiceServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TreasuryService_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TreasuryServiceServer).GetBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TreasuryService_ListMiniOutputs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMiniOutputsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TreasuryServiceServer).ListMiniOutputs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TreasuryService_ListMiniOutputs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TreasuryServiceServer).ListMiniOutputs(ctx, req.(*ListMiniOutputsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TreasuryService_Forge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TreasuryServiceServer).Forge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TreasuryService_Forge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TreasuryServiceServer).Forge(ctx, req.(*ForgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TreasuryService_SubscribeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TreasuryServiceServer).SubscribeEvents(m, &grpc.GenericServerStream[SubscribeEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TreasuryService_SubscribeEventsServer = grpc.ServerStreamingServer[Event]
+
+// TreasuryService_ServiceDesc is the grpc.ServiceDesc for TreasuryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TreasuryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "exs.v1.TreasuryService",
+	HandlerType: (*TreasuryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStats",
+			Handler:    _TreasuryService_GetStats_Handler,
+		},
+		{
+			MethodName: "GetBalance",
+			Handler:    _TreasuryService_GetBalance_Handler,
+		},
+		{
+			MethodName: "ListMiniOutputs",
+			Handler:    _TreasuryService_ListMiniOutputs_Handler,
+		},
+		{
+			MethodName: "Forge",
+			Handler:    _TreasuryService_Forge_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeEvents",
+			Handler:       _TreasuryService_SubscribeEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "exs/v1/treasury.proto",
+}
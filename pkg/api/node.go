@@ -0,0 +1,250 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/rpc"
+)
+
+// reorgWindow is the number of streamed blocks SubscribeBlocks remembers to
+// detect a reorganization
+const reorgWindow = 100
+
+// blockPollInterval is how often SubscribeBlocks checks the tip
+var blockPollInterval = time.Second
+
+// NodeServer serves NodeService from a node's chain
+type NodeServer struct {
+	exsv1.UnimplementedNodeServiceServer
+	chain rpc.Chain
+}
+
+// NewNodeServer creates a NodeService server for chain
+func NewNodeServer(chain rpc.Chain) *NodeServer {
+	return &NodeServer{chain: chain}
+}
+
+// GetTip returns the header of the best block
+func (s *NodeServer) GetTip(ctx context.Context, req *exsv1.GetTipRequest) (*exsv1.BlockHeader, error) {
+	tip := s.chain.Tip()
+	return blockHeader(tip.Hash, tip.Height, &tip.Header), nil
+}
+
+// GetBlock returns a block, with its serialized form, by hash or height
+func (s *NodeServer) GetBlock(ctx context.Context, req *exsv1.GetBlockRequest) (*exsv1.Block, error) {
+	var hash chainhash.Hash
+	switch block := req.Block.(type) {
+	case *exsv1.GetBlockRequest_Hash:
+		parsed, err := chainhash.NewHashFromStr(block.Hash)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid block hash: %v", err)
+		}
+		hash = *parsed
+	case *exsv1.GetBlockRequest_Height:
+		var err error
+		if hash, err = s.chain.BlockHash(block.Height); err != nil {
+			return nil, nodeError(err)
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "block hash or height required")
+	}
+	return s.block(hash, true)
+}
+
+// GetTransaction returns a confirmed or mempool transaction
+func (s *NodeServer) GetTransaction(ctx context.Context, req *exsv1.GetTransactionRequest) (*exsv1.Transaction, error) {
+	txid, err := chainhash.NewHashFromStr(req.Txid)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid txid: %v", err)
+	}
+	tx, blockHash, err := s.chain.Transaction(*txid)
+	if err != nil {
+		return nil, nodeError(err)
+	}
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, nodeError(err)
+	}
+	resp := &exsv1.Transaction{Txid: txid.String(), Raw: buf.Bytes()}
+	if blockHash != nil {
+		resp.BlockHash = blockHash.String()
+	}
+	return resp, nil
+}
+
+// SendTransaction validates a serialized transaction and relays it
+func (s *NodeServer) SendTransaction(ctx context.Context, req *exsv1.SendTransactionRequest) (*exsv1.SendTransactionResponse, error) {
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(req.Raw)); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid transaction: %v", err)
+	}
+	if err := s.chain.SendTransaction(ctx, &tx); err != nil {
+		return nil, nodeError(err)
+	}
+	return &exsv1.SendTransactionResponse{Txid: tx.TxHash().String()}, nil
+}
+
+// SubscribeBlocks streams best chain changes, polling the tip every
+// blockPollInterval. Each request restarts the stream; after the client
+// closes its side the stream continues until the call ends.
+func (s *NodeServer) SubscribeBlocks(stream exsv1.NodeService_SubscribeBlocksServer) error {
+	ctx := stream.Context()
+	requests := make(chan *exsv1.SubscribeBlocksRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(blockPollInterval)
+	defer ticker.Stop()
+	var sub *blockSubscription
+	for {
+		select {
+		case req := <-requests:
+			sub = &blockSubscription{next: s.chain.Tip().Height + 1, includeRaw: req.IncludeRaw}
+			if req.StartHeight != nil {
+				sub.next = max(*req.StartHeight, 0)
+			}
+		case err := <-recvErr:
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			if sub == nil {
+				return nil
+			}
+			recvErr = nil
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if sub != nil {
+			if err := s.sync(sub, stream.Send); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// blockSubscription is the position of a SubscribeBlocks stream
+type blockSubscription struct {
+	// next is the height of the next block to connect
+	next       int32
+	includeRaw bool
+	// sent holds the last blocks connected, oldest first
+	sent []*exsv1.Block
+}
+
+// sync sends the events bringing sub to the best chain: first the streamed
+// blocks it no longer contains, newest first, then the blocks from
+// sub.next to the tip
+func (s *NodeServer) sync(sub *blockSubscription, send func(*exsv1.BlockEvent) error) error {
+	for len(sub.sent) > 0 {
+		last := sub.sent[len(sub.sent)-1]
+		hash, err := s.chain.BlockHash(last.Header.Height)
+		if err == nil && hash.String() == last.Header.Hash {
+			break
+		}
+		if err != nil && !errors.Is(err, rpc.ErrBlockNotFound) {
+			return nodeError(err)
+		}
+		if err := send(&exsv1.BlockEvent{Type: exsv1.BlockEvent_TYPE_DISCONNECTED, Block: last}); err != nil {
+			return err
+		}
+		sub.sent = sub.sent[:len(sub.sent)-1]
+		sub.next = last.Header.Height
+	}
+
+	for tip := s.chain.Tip(); sub.next <= tip.Height; sub.next++ {
+		hash, err := s.chain.BlockHash(sub.next)
+		if errors.Is(err, rpc.ErrBlockNotFound) {
+			// The tip moved back since it was read
+			break
+		}
+		if err != nil {
+			return nodeError(err)
+		}
+		block, err := s.block(hash, sub.includeRaw)
+		if err != nil {
+			return err
+		}
+		if err := send(&exsv1.BlockEvent{Type: exsv1.BlockEvent_TYPE_CONNECTED, Block: block}); err != nil {
+			return err
+		}
+		// Disconnected blocks are sent without their serialized form
+		sub.sent = append(sub.sent, &exsv1.Block{Header: block.Header, Txids: block.Txids})
+		if len(sub.sent) > reorgWindow {
+			sub.sent = sub.sent[1:]
+		}
+	}
+	return nil
+}
+
+// block returns the block with hash, serialized when raw is set
+func (s *NodeServer) block(hash chainhash.Hash, raw bool) (*exsv1.Block, error) {
+	msg, height, err := s.chain.Block(hash)
+	if err != nil {
+		return nil, nodeError(err)
+	}
+	block := &exsv1.Block{
+		Header: blockHeader(hash, height, &msg.Header),
+		Txids:  make([]string, len(msg.Transactions)),
+	}
+	for i, tx := range msg.Transactions {
+		block.Txids[i] = tx.TxHash().String()
+	}
+	if raw {
+		var buf bytes.Buffer
+		if err := msg.Serialize(&buf); err != nil {
+			return nil, nodeError(err)
+		}
+		block.Raw = buf.Bytes()
+	}
+	return block, nil
+}
+
+func blockHeader(hash chainhash.Hash, height int32, header *wire.BlockHeader) *exsv1.BlockHeader {
+	return &exsv1.BlockHeader{
+		Hash:         hash.String(),
+		Height:       height,
+		Version:      header.Version,
+		PreviousHash: header.PrevBlock.String(),
+		MerkleRoot:   header.MerkleRoot.String(),
+		Time:         header.Timestamp.Unix(),
+		Bits:         header.Bits,
+		Nonce:        header.Nonce,
+	}
+}
+
+// nodeError converts a chain error to a status error
+func nodeError(err error) error {
+	switch {
+	case errors.Is(err, rpc.ErrBlockNotFound), errors.Is(err, rpc.ErrTxNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, rpc.ErrTxRejected):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, rpc.ErrTxInChain):
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/rpc"
+)
+
+// testChain is an rpc.Chain whose best chain the test rewrites
+type testChain struct {
+	mu     sync.Mutex
+	best   []chainhash.Hash
+	blocks map[chainhash.Hash]*wire.MsgBlock
+	sent   []*wire.MsgTx
+}
+
+func newTestChain(n int) *testChain {
+	c := &testChain{blocks: make(map[chainhash.Hash]*wire.MsgBlock)}
+	c.extend(n, 0)
+	return c
+}
+
+// extend mines n blocks on the tip, varied by salt
+func (c *testChain) extend(n int, salt uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < n; i++ {
+		block := wire.NewMsgBlock(&wire.BlockHeader{Nonce: salt<<16 | uint32(len(c.best)), Timestamp: time.Unix(1700000000, 0)})
+		if len(c.best) > 0 {
+			block.Header.PrevBlock = c.best[len(c.best)-1]
+		}
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(50, []byte{0x51}))
+		tx.LockTime = block.Header.Nonce
+		block.AddTransaction(tx)
+		hash := block.BlockHash()
+		c.blocks[hash] = block
+		c.best = append(c.best, hash)
+	}
+}
+
+// reorg replaces the blocks from height with n blocks varied by salt
+func (c *testChain) reorg(height, n int, salt uint32) {
+	c.mu.Lock()
+	c.best = c.best[:height]
+	c.mu.Unlock()
+	c.extend(n, salt)
+}
+
+func (c *testChain) Params() *chaincfg.Params { return &chaincfg.RegressionNetParams }
+
+func (c *testChain) Tip() chain.Tip {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hash := c.best[len(c.best)-1]
+	return chain.Tip{Hash: hash, Height: int32(len(c.best) - 1), Header: c.blocks[hash].Header}
+}
+
+func (c *testChain) BlockHash(height int32) (chainhash.Hash, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if height < 0 || int(height) >= len(c.best) {
+		return chainhash.Hash{}, rpc.ErrBlockNotFound
+	}
+	return c.best[height], nil
+}
+
+func (c *testChain) Block(hash chainhash.Hash) (*wire.MsgBlock, int32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	block, ok := c.blocks[hash]
+	if !ok {
+		return nil, 0, rpc.ErrBlockNotFound
+	}
+	return block, int32(block.Header.Nonce & 0xffff), nil
+}
+
+func (c *testChain) Transaction(txid chainhash.Hash) (*wire.MsgTx, *chainhash.Hash, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, hash := range c.best {
+		if tx := c.blocks[hash].Transactions[0]; tx.TxHash() == txid {
+			return tx, &hash, nil
+		}
+	}
+	return nil, nil, rpc.ErrTxNotFound
+}
+
+func (c *testChain) SendTransaction(ctx context.Context, tx *wire.MsgTx) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, tx)
+	return nil
+}
+
+func (c *testChain) Mempool() []rpc.MempoolEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]rpc.MempoolEntry, len(c.sent))
+	for i, tx := range c.sent {
+		entries[i] = rpc.MempoolEntry{Tx: tx}
+	}
+	return entries
+}
+
+func (c *testChain) Coins(pkScript []byte) ([]chain.Coin, error) {
+	return nil, nil
+}
+
+func (c *testChain) Generate(n int, pkScript []byte) ([]chainhash.Hash, error) {
+	return nil, rpc.ErrNotRegtest
+}
+
+func (c *testChain) BlockTemplate(pkScript []byte) (*rpc.BlockTemplate, error) {
+	return nil, rpc.ErrNotRegtest
+}
+
+func (c *testChain) SubmitBlock(block *wire.MsgBlock) (bool, error) {
+	return false, rpc.ErrNotRegtest
+}
+
+func nodeClient(t *testing.T, c *testChain) exsv1.NodeServiceClient {
+	return exsv1.NewNodeServiceClient(dial(t, nil, func(s *grpc.Server) {
+		exsv1.RegisterNodeServiceServer(s, NewNodeServer(c))
+	}))
+}
+
+func TestNodeQueries(t *testing.T) {
+	c := newTestChain(5)
+	client := nodeClient(t, c)
+	ctx := context.Background()
+
+	tip, err := client.GetTip(ctx, &exsv1.GetTipRequest{})
+	if err != nil {
+		t.Fatalf("GetTip() error = %v", err)
+	}
+	if tip.Height != 4 || tip.Hash != c.best[4].String() || tip.PreviousHash != c.best[3].String() {
+		t.Errorf("Unexpected tip %+v", tip)
+	}
+
+	byHeight, err := client.GetBlock(ctx, &exsv1.GetBlockRequest{Block: &exsv1.GetBlockRequest_Height{Height: 2}})
+	if err != nil {
+		t.Fatalf("GetBlock() error = %v", err)
+	}
+	byHash, err := client.GetBlock(ctx, &exsv1.GetBlockRequest{Block: &exsv1.GetBlockRequest_Hash{Hash: c.best[2].String()}})
+	if err != nil {
+		t.Fatalf("GetBlock() error = %v", err)
+	}
+	if byHeight.Header.Hash != byHash.Header.Hash || len(byHash.Txids) != 1 || len(byHash.Raw) == 0 {
+		t.Errorf("Unexpected block %+v", byHash)
+	}
+	if _, err := client.GetBlock(ctx, &exsv1.GetBlockRequest{Block: &exsv1.GetBlockRequest_Height{Height: 9}}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+	if _, err := client.GetBlock(ctx, &exsv1.GetBlockRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+
+	tx, err := client.GetTransaction(ctx, &exsv1.GetTransactionRequest{Txid: byHash.Txids[0]})
+	if err != nil {
+		t.Fatalf("GetTransaction() error = %v", err)
+	}
+	if tx.BlockHash != c.best[2].String() {
+		t.Errorf("Expected the transaction in block 2, got %s", tx.BlockHash)
+	}
+	if _, err := client.GetTransaction(ctx, &exsv1.GetTransactionRequest{Txid: "zz"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+
+	sent, err := client.SendTransaction(ctx, &exsv1.SendTransactionRequest{Raw: tx.Raw})
+	if err != nil {
+		t.Fatalf("SendTransaction() error = %v", err)
+	}
+	if sent.Txid != tx.Txid || len(c.sent) != 1 {
+		t.Errorf("Expected %s relayed, got %s", tx.Txid, sent.Txid)
+	}
+	if _, err := client.SendTransaction(ctx, &exsv1.SendTransactionRequest{Raw: []byte{1}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestSubscribeBlocks(t *testing.T) {
+	interval := blockPollInterval
+	blockPollInterval = 10 * time.Millisecond
+	defer func() { blockPollInterval = interval }()
+
+	c := newTestChain(3)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := nodeClient(t, c).SubscribeBlocks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := int32(1)
+	if err := stream.Send(&exsv1.SubscribeBlocksRequest{StartHeight: &start, IncludeRaw: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(typ exsv1.BlockEvent_Type, hash chainhash.Hash, raw bool) {
+		t.Helper()
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if event.Type != typ || event.Block.Header.Hash != hash.String() || (len(event.Block.Raw) > 0) != raw {
+			t.Fatalf("Expected %v %s, got %v %s", typ, hash, event.Type, event.Block.Header.Hash)
+		}
+	}
+	expect(exsv1.BlockEvent_TYPE_CONNECTED, c.best[1], true)
+	expect(exsv1.BlockEvent_TYPE_CONNECTED, c.best[2], true)
+
+	c.extend(1, 0)
+	expect(exsv1.BlockEvent_TYPE_CONNECTED, c.best[3], true)
+
+	// Replace blocks 2 and 3 with a longer branch
+	old := append([]chainhash.Hash(nil), c.best...)
+	c.reorg(2, 3, 1)
+	expect(exsv1.BlockEvent_TYPE_DISCONNECTED, old[3], false)
+	expect(exsv1.BlockEvent_TYPE_DISCONNECTED, old[2], false)
+	for height := 2; height <= 4; height++ {
+		expect(exsv1.BlockEvent_TYPE_CONNECTED, c.best[height], true)
+	}
+
+	// A new request restarts the stream, and closing the send side keeps it
+	start = 4
+	if err := stream.Send(&exsv1.SubscribeBlocksRequest{StartHeight: &start}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	expect(exsv1.BlockEvent_TYPE_CONNECTED, c.best[4], false)
+	c.extend(1, 1)
+	expect(exsv1.BlockEvent_TYPE_CONNECTED, c.best[5], false)
+
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("Expected the stream cancelled, got %v", err)
+	}
+}
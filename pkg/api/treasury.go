@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/events"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+)
+
+// TreasuryPermissions are the Guardian permissions TreasuryService methods
+// require, matching the treasury HTTP API. Events need forge.submit, which
+// Knights hold, as GET /events needs a Knight.
+var TreasuryPermissions = map[string]guardian.Permission{
+	exsv1.TreasuryService_Forge_FullMethodName:           guardian.PermissionForgeSubmit,
+	exsv1.TreasuryService_SubscribeEvents_FullMethodName: guardian.PermissionForgeSubmit,
+}
+
+// TreasuryServer serves TreasuryService from a treasury
+type TreasuryServer struct {
+	exsv1.UnimplementedTreasuryServiceServer
+	treasury  *economy.Treasury
+	emergency *guardian.Breaker
+	bus       *events.Bus
+}
+
+// NewTreasuryServer creates a TreasuryService server. Forges are refused
+// while emergency, when not nil, is halted; events are streamed from bus,
+// or not served when bus is nil.
+func NewTreasuryServer(treasury *economy.Treasury, emergency *guardian.Breaker, bus *events.Bus) *TreasuryServer {
+	return &TreasuryServer{treasury: treasury, emergency: emergency, bus: bus}
+}
+
+// GetStats returns treasury totals
+func (s *TreasuryServer) GetStats(ctx context.Context, req *exsv1.GetTreasuryStatsRequest) (*exsv1.TreasuryStats, error) {
+	forges := s.treasury.GetTotalForges()
+	return &exsv1.TreasuryStats{
+		TreasuryBalance:    s.treasury.GetBalance().EXS(),
+		SpendableBalance:   s.treasury.GetSpendableBalance().EXS(),
+		LockedBalance:      s.treasury.GetLockedBalance().EXS(),
+		TotalFeesCollected: s.treasury.GetTotalFeesCollected().EXS(),
+		TotalForges:        int64(forges),
+		CurrentBlockHeight: s.treasury.GetBlockHeight(),
+		ForgeFeePoolBtc:    s.treasury.GetForgeFeePool().ToBTC(),
+		TotalMinted:        s.treasury.GetTotalMinted().EXS(),
+		SupplyCap:          economy.TotalSupplyCap.EXS(),
+		Halted:             s.emergency != nil && s.emergency.Check() != nil,
+	}, nil
+}
+
+// GetBalance returns the treasury balances and the requested address's
+func (s *TreasuryServer) GetBalance(ctx context.Context, req *exsv1.GetBalanceRequest) (*exsv1.Balance, error) {
+	balance := &exsv1.Balance{
+		TotalBalance:     s.treasury.GetBalance().EXS(),
+		SpendableBalance: s.treasury.GetSpendableBalance().EXS(),
+		LockedBalance:    s.treasury.GetLockedBalance().EXS(),
+		ForgeFeePool:     s.treasury.GetForgeFeePool().ToBTC(),
+	}
+	if req.Address != "" {
+		balance.AddressBalance = s.treasury.GetAddressBalance(req.Address).EXS()
+	}
+	return balance, nil
+}
+
+// ListMiniOutputs returns the mini-outputs passing the request's filter
+func (s *TreasuryServer) ListMiniOutputs(ctx context.Context, req *exsv1.ListMiniOutputsRequest) (*exsv1.ListMiniOutputsResponse, error) {
+	var outputs []economy.TreasuryMiniOutput
+	switch req.Filter {
+	case exsv1.ListMiniOutputsRequest_FILTER_SPENDABLE:
+		outputs = s.treasury.GetSpendableMiniOutputs()
+	case exsv1.ListMiniOutputsRequest_FILTER_LOCKED:
+		outputs = s.treasury.GetLockedMiniOutputs()
+	default:
+		outputs = s.treasury.GetMiniOutputs()
+	}
+	return &exsv1.ListMiniOutputsResponse{MiniOutputs: miniOutputs(outputs)}, nil
+}
+
+// Forge processes a forge like the treasury's POST /forge
+func (s *TreasuryServer) Forge(ctx context.Context, req *exsv1.ForgeRequest) (*exsv1.ForgeResult, error) {
+	if s.emergency != nil {
+		if err := s.emergency.Check(); err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+	}
+
+	var result *economy.ForgeResult
+	if len(req.Beneficiaries) > 0 {
+		split := make(economy.RewardSplit, len(req.Beneficiaries))
+		for i, b := range req.Beneficiaries {
+			split[i] = economy.Beneficiary{Address: b.Address, Percent: b.Percent}
+		}
+		if req.MinerAddress != "" && req.MinerAddress != split[0].Address {
+			return nil, status.Error(codes.InvalidArgument, "miner_address must be the first beneficiary")
+		}
+		var err error
+		result, err = s.treasury.ProcessForgeSplit(split)
+		if errors.Is(err, economy.ErrInvalidSplit) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, economy.ErrSupplyCap) || errors.Is(err, economy.ErrPaymentRequired) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "forge processing failed: %v", err)
+		}
+	} else {
+		var err error
+		result, _, err = s.treasury.ProcessForgeWithFee(req.MinerAddress, false)
+		if errors.Is(err, economy.ErrSupplyCap) || errors.Is(err, economy.ErrPaymentRequired) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if result == nil {
+			return nil, status.Errorf(codes.Internal, "forge processing failed: %v", err)
+		}
+	}
+
+	resp := &exsv1.ForgeResult{
+		ForgeId:            int64(result.ForgeID),
+		BlockHeight:        result.BlockHeight,
+		MinerAddress:       result.MinerAddress,
+		TotalReward:        result.TotalReward.EXS(),
+		MinerReward:        result.MinerReward.EXS(),
+		TreasuryAllocation: result.TreasuryAllocation.EXS(),
+		MiniOutputs:        miniOutputs(result.TreasuryMiniOutputs),
+		ForgeFeeBtc:        result.ForgeFeeInBTC,
+		Timestamp:          timestamppb.New(result.Timestamp),
+	}
+	for _, payout := range result.Payouts {
+		resp.Payouts = append(resp.Payouts, &exsv1.Payout{Address: payout.Address, Percent: payout.Percent, Amount: payout.Amount.EXS()})
+	}
+	return resp, nil
+}
+
+// SubscribeEvents streams the bus's events of the requested types. A
+// subscriber too far behind is dropped with UNAVAILABLE, and resynchronizes
+// from the latest events when it subscribes again.
+func (s *TreasuryServer) SubscribeEvents(req *exsv1.SubscribeEventsRequest, stream exsv1.TreasuryService_SubscribeEventsServer) error {
+	if s.bus == nil {
+		return status.Error(codes.Unimplemented, "event stream is not served")
+	}
+	ch, cancel := s.bus.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "subscriber fell behind")
+			}
+			if len(req.Types) > 0 && !slices.Contains(req.Types, event.Type) {
+				continue
+			}
+			err := stream.Send(&exsv1.Event{
+				Seq:  event.Seq,
+				Type: event.Type,
+				Time: timestamppb.New(event.Time),
+				Data: event.Data,
+			})
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func miniOutputs(outputs []economy.TreasuryMiniOutput) []*exsv1.MiniOutput {
+	resp := make([]*exsv1.MiniOutput, len(outputs))
+	for i, output := range outputs {
+		resp[i] = &exsv1.MiniOutput{
+			OutputId:      int64(output.OutputID),
+			BlockHeight:   output.BlockHeight,
+			Amount:        output.Amount.EXS(),
+			LockHeight:    output.LockHeight,
+			Spendable:     output.IsSpendable,
+			Spent:         output.IsSpent,
+			CltvScript:    output.CLTVScript,
+			ScriptAddress: output.ScriptAddress,
+			CreatedAt:     timestamppb.New(output.CreatedAt),
+		}
+	}
+	return resp
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/api/exsv1"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/events"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+)
+
+func TestTreasuryForge(t *testing.T) {
+	treasury := economy.NewTreasury()
+	emergency, err := guardian.NewBreaker("", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := exsv1.NewTreasuryServiceClient(dial(t, nil, func(s *grpc.Server) {
+		exsv1.RegisterTreasuryServiceServer(s, NewTreasuryServer(treasury, emergency, nil))
+	}))
+	ctx := context.Background()
+
+	result, err := client.Forge(ctx, &exsv1.ForgeRequest{Beneficiaries: []*exsv1.Beneficiary{
+		{Address: "bc1pop", Percent: 90},
+		{Address: "bc1phost", Percent: 10},
+	}})
+	if err != nil {
+		t.Fatalf("Forge() error = %v", err)
+	}
+	if result.MinerAddress != "bc1pop" || len(result.Payouts) != 2 || len(result.MiniOutputs) != economy.MiniOutputCount {
+		t.Errorf("Unexpected forge %+v", result)
+	}
+	_, err = client.Forge(ctx, &exsv1.ForgeRequest{Beneficiaries: []*exsv1.Beneficiary{{Address: "bc1pop", Percent: 50}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a bad split, got %v", err)
+	}
+
+	balance, err := client.GetBalance(ctx, &exsv1.GetBalanceRequest{Address: "bc1phost"})
+	if err != nil {
+		t.Fatalf("GetBalance() error = %v", err)
+	}
+	if balance.TotalBalance != treasury.GetBalance().EXS() || balance.AddressBalance != result.Payouts[1].Amount {
+		t.Errorf("Unexpected balance %+v", balance)
+	}
+	locked, err := client.ListMiniOutputs(ctx, &exsv1.ListMiniOutputsRequest{Filter: exsv1.ListMiniOutputsRequest_FILTER_LOCKED})
+	if err != nil {
+		t.Fatalf("ListMiniOutputs() error = %v", err)
+	}
+	if len(locked.MiniOutputs) != len(treasury.GetLockedMiniOutputs()) {
+		t.Errorf("Expected %d locked mini-outputs, got %d", len(treasury.GetLockedMiniOutputs()), len(locked.MiniOutputs))
+	}
+
+	if _, err := emergency.Halt("incident", "arthur"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Forge(ctx, &exsv1.ForgeRequest{MinerAddress: "bc1pop"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable while halted, got %v", err)
+	}
+	stats, err := client.GetStats(ctx, &exsv1.GetTreasuryStatsRequest{})
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if !stats.Halted || stats.TotalForges != 1 || stats.TotalMinted != economy.ForgeReward.EXS() {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestTreasurySubscribeEvents(t *testing.T) {
+	// A new subscriber receives the latest event of every type first
+	bus := events.NewBus()
+	bus.Publish("emergency", map[string]bool{"halted": false})
+	bus.Publish("update", map[string]string{"version": "v1.2.0"})
+	client := exsv1.NewTreasuryServiceClient(dial(t, nil, func(s *grpc.Server) {
+		exsv1.RegisterTreasuryServiceServer(s, NewTreasuryServer(economy.NewTreasury(), nil, bus))
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.SubscribeEvents(ctx, &exsv1.SubscribeEventsRequest{Types: []string{"update"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	if event.Type != "update" || string(event.Data) != `{"version":"v1.2.0"}` {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	unserved := exsv1.NewTreasuryServiceClient(dial(t, nil, func(s *grpc.Server) {
+		exsv1.RegisterTreasuryServiceServer(s, NewTreasuryServer(economy.NewTreasury(), nil, nil))
+	}))
+	stream, err = unserved.SubscribeEvents(ctx, &exsv1.SubscribeEventsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected Unimplemented without a bus, got %v", err)
+	}
+}
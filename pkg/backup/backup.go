@@ -0,0 +1,414 @@
+// Package backup snapshots persistent state into compressed archives kept
+// on a Target, a local directory or an S3 bucket. Each archive ends with a
+// manifest of the SHA-256 of every file it holds, so a backup can be
+// verified without restoring it, and is verified again before a restore
+// writes anything. A Schedule takes backups periodically and prunes old ones.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	archivePrefix = "exs-backup-"
+	archiveSuffix = ".tar.gz"
+	// timeLayout names archives so they sort by creation time
+	timeLayout = "20060102T150405Z"
+	// manifestName is the last entry of every archive
+	manifestName = "MANIFEST.json"
+	// manifestVersion is the archive format written by Create
+	manifestVersion = 1
+	// maxManifestSize bounds how much of a manifest entry is read
+	maxManifestSize = 16 << 20
+)
+
+var (
+	// ErrNotFound indicates a backup the target does not have
+	ErrNotFound = errors.New("backup not found")
+	// ErrCorrupt indicates a backup whose contents do not match its manifest
+	ErrCorrupt = errors.New("backup failed verification")
+	// ErrInvalidTarget indicates a target location that cannot be used
+	ErrInvalidTarget = errors.New("invalid backup target")
+	// ErrRestoreDir indicates a restore into a directory that is not empty
+	ErrRestoreDir = errors.New("restore directory is not empty")
+)
+
+// Source is state to back up, stored under Name in the archive
+type Source struct {
+	Name string
+	// Path is a file or directory copied as is. A missing path is skipped,
+	// so sources that do not exist yet do not fail the backup.
+	Path string
+	// Data, when set, produces the contents of a single file instead of Path
+	Data func() ([]byte, error)
+}
+
+// File is an archived file, as listed in the manifest
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists every file of a backup
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+}
+
+// Info describes a backup on a target
+type Info struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+// archiveName returns the name of a backup created at t
+func archiveName(t time.Time) string {
+	return archivePrefix + t.UTC().Format(timeLayout) + archiveSuffix
+}
+
+// parseName returns the creation time of a backup name, or false if name
+// is not a backup
+func parseName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix))
+	return t, err == nil
+}
+
+// Create archives sources and stores the archive on target, named after now
+func Create(ctx context.Context, target Target, sources []Source, now time.Time) (Info, error) {
+	tmp, err := os.CreateTemp("", archivePrefix+"*"+archiveSuffix)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to create backup archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	manifest := Manifest{Version: manifestVersion, CreatedAt: now.UTC().Truncate(time.Second)}
+	if err := writeArchive(ctx, tmp, sources, &manifest); err != nil {
+		return Info{}, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Info{}, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+
+	info := Info{Name: archiveName(now), CreatedAt: manifest.CreatedAt, Size: size}
+	if err := target.Put(ctx, info.Name, tmp, size); err != nil {
+		return Info{}, fmt.Errorf("failed to store backup %s: %w", info.Name, err)
+	}
+	return info, nil
+}
+
+// writeArchive writes every source to w as a gzipped tar, ending with the
+// manifest, which it fills in
+func writeArchive(ctx context.Context, w io.Writer, sources []Source, manifest *Manifest) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, mode fs.FileMode, modTime time.Time, size int64, r io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(mode.Perm()),
+			Size:    size,
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		// A file appended to meanwhile is archived as it was when stat'ed
+		hash := sha256.New()
+		n, err := io.Copy(io.MultiWriter(tw, hash), io.LimitReader(r, size))
+		if err != nil {
+			return err
+		}
+		if n != size {
+			return fmt.Errorf("%s shrank while being backed up", name)
+		}
+		manifest.Files = append(manifest.Files, File{Path: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+		return nil
+	}
+
+	for _, source := range sources {
+		if err := addSource(source, add); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", source.Name, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: manifestName, Mode: 0600, Size: int64(len(data)), ModTime: manifest.CreatedAt}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addSource passes every regular file of source to add
+func addSource(source Source, add func(name string, mode fs.FileMode, modTime time.Time, size int64, r io.Reader) error) error {
+	if source.Name == "" || source.Name == manifestName || !filepath.IsLocal(source.Name) {
+		return fmt.Errorf("invalid source name %q", source.Name)
+	}
+	if source.Data != nil {
+		data, err := source.Data()
+		if err != nil {
+			return err
+		}
+		return add(source.Name, 0600, time.Now(), int64(len(data)), bytes.NewReader(data))
+	}
+
+	if _, err := os.Stat(source.Path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(source.Path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			// Directories are implied by their files; links, sockets and
+			// devices are not state
+			return nil
+		}
+		rel, err := filepath.Rel(source.Path, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return add(path.Join(source.Name, filepath.ToSlash(rel)), info.Mode(), info.ModTime(), info.Size(), f)
+	})
+}
+
+// readArchive checks an archive against its manifest, passing each file to
+// extract, if set, as it is read. extract sees files before they are
+// verified, so callers must discard its output when readArchive fails.
+func readArchive(r io.Reader, extract func(name string, mode fs.FileMode, r io.Reader) error) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	hashes := make(map[string]File)
+	var manifest *Manifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		if manifest != nil {
+			return nil, fmt.Errorf("%w: %s after the manifest", ErrCorrupt, hdr.Name)
+		}
+		if hdr.Typeflag != tar.TypeReg || !filepath.IsLocal(hdr.Name) {
+			return nil, fmt.Errorf("%w: unexpected entry %s", ErrCorrupt, hdr.Name)
+		}
+
+		if hdr.Name == manifestName {
+			data, err := io.ReadAll(io.LimitReader(tr, maxManifestSize))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+			}
+			manifest = new(Manifest)
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, fmt.Errorf("%w: invalid manifest: %v", ErrCorrupt, err)
+			}
+			continue
+		}
+		if _, ok := hashes[hdr.Name]; ok {
+			return nil, fmt.Errorf("%w: %s archived twice", ErrCorrupt, hdr.Name)
+		}
+
+		hash := sha256.New()
+		var content io.Reader = io.TeeReader(tr, hash)
+		if extract != nil {
+			if err := extract(hdr.Name, fs.FileMode(hdr.Mode).Perm(), content); err != nil {
+				return nil, err
+			}
+		}
+		// Hash whatever extract left unread
+		if _, err := io.Copy(io.Discard, content); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		hashes[hdr.Name] = File{Path: hdr.Name, Size: hdr.Size, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: no manifest", ErrCorrupt)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("%w: unsupported manifest version %d", ErrCorrupt, manifest.Version)
+	}
+	if len(manifest.Files) != len(hashes) {
+		return nil, fmt.Errorf("%w: manifest lists %d files, archive holds %d", ErrCorrupt, len(manifest.Files), len(hashes))
+	}
+	for _, want := range manifest.Files {
+		if got, ok := hashes[want.Path]; !ok || got != want {
+			return nil, fmt.Errorf("%w: %s does not match the manifest", ErrCorrupt, want.Path)
+		}
+	}
+	return manifest, nil
+}
+
+// Verify downloads a backup and checks every file against its manifest
+func Verify(ctx context.Context, target Target, name string) (*Manifest, error) {
+	r, err := target.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	manifest, err := readArchive(r, nil)
+	if err != nil {
+		return nil, fmt.Errorf("backup %s: %w", name, err)
+	}
+	return manifest, nil
+}
+
+// Restore verifies a backup and extracts it into dir, which must be empty
+// or not exist. Nothing is left in dir if verification fails.
+func Restore(ctx context.Context, target Target, name, dir string) (*Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRestoreDir, dir)
+	}
+
+	r, err := target.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// Extract into a staging directory beside dir, then move it into place
+	// once every file has matched the manifest
+	parent := filepath.Dir(filepath.Clean(dir))
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(parent, ".restore-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := readArchive(r, func(name string, mode fs.FileMode, r io.Reader) error {
+		p := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode|0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backup %s: %w", name, err)
+	}
+
+	if err := os.Remove(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// List returns the backups on target, oldest first
+func List(ctx context.Context, target Target) ([]Info, error) {
+	objects, err := target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var backups []Info
+	for _, obj := range objects {
+		if t, ok := parseName(obj.Name); ok {
+			backups = append(backups, Info{Name: obj.Name, CreatedAt: t, Size: obj.Size})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.Before(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// Latest returns the newest backup on target
+func Latest(ctx context.Context, target Target) (Info, error) {
+	backups, err := List(ctx, target)
+	if err != nil {
+		return Info{}, err
+	}
+	if len(backups) == 0 {
+		return Info{}, ErrNotFound
+	}
+	return backups[len(backups)-1], nil
+}
+
+// Prune deletes all but the newest keep backups and returns the deleted
+// names. A keep of zero or less keeps everything.
+func Prune(ctx context.Context, target Target, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	backups, err := List(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for len(backups) > keep {
+		if err := target.Delete(ctx, backups[0].Name); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %w", backups[0].Name, err)
+		}
+		deleted = append(deleted, backups[0].Name)
+		backups = backups[1:]
+	}
+	return deleted, nil
+}
@@ -0,0 +1,172 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFiles creates files under dir from a map of relative paths
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testSources(t *testing.T) []Source {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"wallets/main/wallet.json":    `{"name":"main"}`,
+		"wallets/main/utxos.json":     `[]`,
+		"treasury/treasury.wal":       "entry\n",
+		"guardian.db":                 "guardian",
+		"wallets/main/pending/a.json": "{}",
+	})
+	return []Source{
+		{Name: "wallets", Path: filepath.Join(src, "wallets")},
+		{Name: "treasury", Path: filepath.Join(src, "treasury")},
+		{Name: "guardian/guardian.db", Path: filepath.Join(src, "guardian.db")},
+		{Name: "missing", Path: filepath.Join(src, "missing")},
+		{Name: "chain.json", Data: func() ([]byte, error) { return []byte(`{"height":7}`), nil }},
+	}
+}
+
+func TestCreateVerifyRestore(t *testing.T) {
+	ctx := context.Background()
+	target := Dir(t.TempDir())
+
+	info, err := Create(ctx, target, testSources(t), time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if info.Name != "exs-backup-20260102T030405Z.tar.gz" || info.Size == 0 {
+		t.Errorf("Create() = %+v", info)
+	}
+
+	manifest, err := Verify(ctx, target, info.Name)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(manifest.Files) != 6 {
+		t.Errorf("Manifest lists %d files, expected 6: %+v", len(manifest.Files), manifest.Files)
+	}
+
+	dir := filepath.Join(t.TempDir(), "restored")
+	if _, err := Restore(ctx, target, info.Name, dir); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	for name, want := range map[string]string{
+		"wallets/main/wallet.json": `{"name":"main"}`,
+		"treasury/treasury.wal":    "entry\n",
+		"guardian/guardian.db":     "guardian",
+		"chain.json":               `{"height":7}`,
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Errorf("Restored %s = %q, %v, expected %q", name, got, err, want)
+		}
+	}
+
+	if _, err := Restore(ctx, target, info.Name, dir); !errors.Is(err, ErrRestoreDir) {
+		t.Errorf("Expected ErrRestoreDir restoring over files, got %v", err)
+	}
+	if _, err := Verify(ctx, target, "exs-backup-20250101T000000Z.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	target := Dir(dir)
+	info, err := Create(ctx, target, testSources(t), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncating the archive loses the manifest
+	p := filepath.Join(dir, info.Name)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, data[:len(data)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(ctx, target, info.Name); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt, got %v", err)
+	}
+
+	restored := filepath.Join(t.TempDir(), "restored")
+	if _, err := Restore(ctx, target, info.Name, restored); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected Restore to fail with ErrCorrupt, got %v", err)
+	}
+	if _, err := os.Stat(restored); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected nothing restored from a corrupt backup, got %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	target := Dir(t.TempDir())
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if _, err := Create(ctx, target, nil, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Other files in the target are left alone
+	writeFiles(t, string(target), map[string]string{"notes.txt": "keep"})
+
+	deleted, err := Prune(ctx, target, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0] != archiveName(start) {
+		t.Errorf("Prune() deleted %v, expected the two oldest", deleted)
+	}
+	backups, err := List(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || !backups[1].CreatedAt.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("List() = %+v after pruning", backups)
+	}
+	latest, err := Latest(ctx, target)
+	if err != nil || latest.Name != backups[1].Name {
+		t.Errorf("Latest() = %+v, %v", latest, err)
+	}
+	if _, err := os.Stat(filepath.Join(string(target), "notes.txt")); err != nil {
+		t.Errorf("Prune() removed a file that is not a backup: %v", err)
+	}
+}
+
+func TestScheduleBackup(t *testing.T) {
+	ctx := context.Background()
+	target := Dir(t.TempDir())
+	schedule := Schedule{Interval: time.Hour, Keep: 1, Verify: true}
+
+	result := schedule.Backup(ctx, target, testSources(t))
+	if result.Err != nil || !result.Verified {
+		t.Fatalf("Backup() = %+v", result)
+	}
+
+	failing := []Source{{Name: "bad", Data: func() ([]byte, error) { return nil, errors.New("unreadable") }}}
+	if result := schedule.Backup(ctx, target, failing); result.Err == nil {
+		t.Error("Expected a failing source to fail the backup")
+	}
+	backups, err := List(ctx, target)
+	if err != nil || len(backups) != 1 {
+		t.Errorf("Expected the good backup kept, got %+v, %v", backups, err)
+	}
+}
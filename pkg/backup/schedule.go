@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"context"
+	"time"
+)
+
+// Schedule configures Run
+type Schedule struct {
+	Interval time.Duration // between backups
+	// Keep is the number of newest backups kept; zero keeps every backup
+	Keep int
+	// Verify downloads each backup after storing it and checks it against
+	// its manifest
+	Verify bool
+}
+
+// Result is the outcome of one scheduled backup
+type Result struct {
+	Info     Info
+	Verified bool
+	// Pruned lists the backups deleted to honour Keep
+	Pruned []string
+	Err    error
+}
+
+// Backup takes one backup of sources, then verifies and prunes as the
+// schedule says. A backup that fails verification is deleted and nothing
+// is pruned, so the last good backups are kept.
+func (s Schedule) Backup(ctx context.Context, target Target, sources []Source) Result {
+	var result Result
+	result.Info, result.Err = Create(ctx, target, sources, time.Now())
+	if result.Err != nil {
+		return result
+	}
+	if s.Verify {
+		if _, err := Verify(ctx, target, result.Info.Name); err != nil {
+			target.Delete(ctx, result.Info.Name)
+			result.Err = err
+			return result
+		}
+		result.Verified = true
+	}
+	result.Pruned, result.Err = Prune(ctx, target, s.Keep)
+	return result
+}
+
+// Run backs up sources now and then every Interval until ctx is done,
+// calling report with each result
+func (s Schedule) Run(ctx context.Context, target Target, sources []Source, report func(Result)) {
+	if s.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		result := s.Backup(ctx, target, sources)
+		if ctx.Err() != nil {
+			return
+		}
+		if report != nil {
+			report(result)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
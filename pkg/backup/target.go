@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Target stores backup archives by name
+type Target interface {
+	// Put stores size bytes read from r under name, replacing any
+	// existing object
+	Put(ctx context.Context, name string, r io.Reader, size int64) error
+	// Get opens the object name, failing with ErrNotFound if it is missing
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns every object on the target
+	List(ctx context.Context) ([]Object, error)
+	// Delete removes the object name; deleting a missing object succeeds
+	Delete(ctx context.Context, name string) error
+	// String describes the target for logs
+	String() string
+}
+
+// Object is a stored archive
+type Object struct {
+	Name string
+	Size int64
+}
+
+// OpenTarget returns the target at location: s3://bucket/prefix for an S3
+// bucket, with credentials from the environment (see S3ConfigFromEnv), or
+// otherwise a local directory
+func OpenTarget(location string) (Target, error) {
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		config, err := S3ConfigFromEnv(bucket, prefix)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3(config)
+	}
+	if location == "" {
+		return nil, fmt.Errorf("%w: no location", ErrInvalidTarget)
+	}
+	return Dir(location), nil
+}
+
+// Dir is a target that keeps archives in a local directory, created on the
+// first Put
+type Dir string
+
+func (d Dir) path(name string) (string, error) {
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid backup name %q", name)
+	}
+	return filepath.Join(string(d), name), nil
+}
+
+// Put writes the archive to a temporary file and renames it into place, so
+// a failed write never leaves a partial archive under name
+func (d Dir) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	p, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(string(d), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(string(d), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if n, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	} else if n != size {
+		tmp.Close()
+		return fmt.Errorf("wrote %d bytes, expected %d", n, size)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (d Dir) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return f, err
+}
+
+func (d Dir) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(string(d))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, Object{Name: entry.Name(), Size: info.Size()})
+	}
+	return objects, nil
+}
+
+func (d Dir) Delete(ctx context.Context, name string) error {
+	p, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (d Dir) String() string {
+	return string(d)
+}
@@ -0,0 +1,342 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/gcs"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// filterMatchBuffer is the capacity of the filter match channel
+const filterMatchBuffer = 64
+
+var (
+	// ErrUnsupportedFilterType indicates a filter type other than BIP-158 basic
+	ErrUnsupportedFilterType = errors.New("unsupported filter type")
+	// ErrFilterHeaderMismatch indicates filter headers or a filter that do not
+	// extend the verified filter header chain
+	ErrFilterHeaderMismatch = errors.New("filter header mismatch")
+	// ErrMissingFilterHeader indicates a filter for a block whose filter header
+	// has not been verified yet
+	ErrMissingFilterHeader = errors.New("missing filter header")
+)
+
+// FilterMatch reports a block whose compact filter matches watched scripts.
+// The block may still be a false positive and must be fetched to confirm.
+type FilterMatch struct {
+	BlockHash chainhash.Hash
+	Height    int32
+	Addresses []btcutil.Address
+	Scripts   [][]byte
+}
+
+// watchedScript is a script being watched, with the address it pays if known
+type watchedScript struct {
+	script  []byte
+	address btcutil.Address
+}
+
+// WatchAddress watches for outputs paying address using BIP-157/158 compact
+// block filters. Matches are delivered on FilterMatches.
+//
+// Filters are only as trustworthy as their filter header chain, and the
+// client accepts the first chain a peer serves that is consistent with the
+// block headers. A peer that serves that chain first can hide payments by
+// omitting them from its filters. Pin known filter headers with
+// AddFilterCheckpoint, and treat ErrFilterHeaderMismatch from a second peer
+// as a sign that one of the peers is lying.
+func (s *SPVClient) WatchAddress(address btcutil.Address) error {
+	if address == nil || address.String() == "" {
+		return errors.New("invalid address")
+	}
+	if !address.IsForNet(s.network) {
+		return fmt.Errorf("address %s is not for %s", address, s.network.Name)
+	}
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", address, err)
+	}
+
+	s.watchMu.Lock()
+	s.watched[string(script)] = watchedScript{script: script, address: address}
+	s.watchMu.Unlock()
+	return nil
+}
+
+// WatchScript watches for outputs with the given public key script
+func (s *SPVClient) WatchScript(script []byte) error {
+	if len(script) == 0 {
+		return errors.New("empty script")
+	}
+	watched := watchedScript{script: append([]byte(nil), script...)}
+	if _, addrs, _, err := txscript.ExtractPkScriptAddrs(script, s.network); err == nil && len(addrs) == 1 {
+		watched.address = addrs[0]
+	}
+
+	s.watchMu.Lock()
+	s.watched[string(script)] = watched
+	s.watchMu.Unlock()
+	return nil
+}
+
+// WatchVault watches for payments to a prophecy-derived Taproot vault
+func (s *SPVClient) WatchVault(vault *TaprootVault) error {
+	if vault == nil {
+		return errors.New("nil vault")
+	}
+	address, err := btcutil.DecodeAddress(vault.Address, s.network)
+	if err != nil {
+		return fmt.Errorf("invalid vault address %s: %w", vault.Address, err)
+	}
+	return s.WatchAddress(address)
+}
+
+// UnwatchAddress stops watching address
+func (s *SPVClient) UnwatchAddress(address btcutil.Address) {
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return
+	}
+	s.watchMu.Lock()
+	delete(s.watched, string(script))
+	s.watchMu.Unlock()
+}
+
+// FilterMatches returns the channel on which filter matches are delivered.
+// Filter handling blocks until matches are received, so callers watching
+// addresses must drain it.
+func (s *SPVClient) FilterMatches() <-chan FilterMatch {
+	return s.filterMatches
+}
+
+// GetFilterHeader returns the verified BIP-157 filter header of a block
+func (s *SPVClient) GetFilterHeader(blockHash chainhash.Hash) (chainhash.Hash, bool) {
+	s.headersMu.RLock()
+	defer s.headersMu.RUnlock()
+
+	header, ok := s.filterHeaders[blockHash]
+	return header, ok
+}
+
+// AddFilterCheckpoint pins the basic filter header at height, e.g. from a
+// trusted full node. Filter headers that disagree with a checkpoint are
+// rejected.
+func (s *SPVClient) AddFilterCheckpoint(height int32, header chainhash.Hash) error {
+	if height < 0 {
+		return fmt.Errorf("invalid checkpoint height %d", height)
+	}
+	s.headersMu.Lock()
+	defer s.headersMu.Unlock()
+
+	if int(height) < len(s.mainChain) {
+		if known, ok := s.filterHeaders[s.mainChain[height]]; ok && known != header {
+			return fmt.Errorf("%w: checkpoint at height %d conflicts with the verified filter header", ErrFilterHeaderMismatch, height)
+		}
+	}
+	s.checkpoints[height] = header
+	return nil
+}
+
+// NewGetCFHeaders builds a getcfheaders request for basic filter headers of
+// best-chain blocks from startHeight to stopHeight
+func (s *SPVClient) NewGetCFHeaders(startHeight, stopHeight int32) (*wire.MsgGetCFHeaders, error) {
+	stopHash, err := s.filterRange(startHeight, stopHeight, wire.MaxCFHeadersPerMsg)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewMsgGetCFHeaders(wire.GCSFilterRegular, uint32(startHeight), &stopHash), nil
+}
+
+// NewGetCFilters builds a getcfilters request for basic filters of
+// best-chain blocks from startHeight to stopHeight
+func (s *SPVClient) NewGetCFilters(startHeight, stopHeight int32) (*wire.MsgGetCFilters, error) {
+	stopHash, err := s.filterRange(startHeight, stopHeight, wire.MaxGetCFiltersReqRange)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewMsgGetCFilters(wire.GCSFilterRegular, uint32(startHeight), &stopHash), nil
+}
+
+// HandleFilterMessage processes a cfheaders or cfilter message from a peer
+func (s *SPVClient) HandleFilterMessage(msg wire.Message) error {
+	switch m := msg.(type) {
+	case *wire.MsgCFHeaders:
+		return s.HandleCFHeaders(m)
+	case *wire.MsgCFilter:
+		return s.HandleCFilter(m)
+	default:
+		return fmt.Errorf("unexpected message %s", msg.Command())
+	}
+}
+
+// HandleCFHeaders verifies a cfheaders response against the filter header
+// chain and stores the new filter headers. The batch must start at the
+// genesis block or directly after an already verified filter header, and
+// must agree with any filter checkpoints it covers.
+func (s *SPVClient) HandleCFHeaders(msg *wire.MsgCFHeaders) error {
+	if msg.FilterType != wire.GCSFilterRegular {
+		return fmt.Errorf("%w: %d", ErrUnsupportedFilterType, msg.FilterType)
+	}
+	if len(msg.FilterHashes) == 0 {
+		return errors.New("empty cfheaders")
+	}
+
+	s.headersMu.Lock()
+	defer s.headersMu.Unlock()
+
+	stopHeight, ok := s.heights[msg.StopHash]
+	if !ok || int(stopHeight) >= len(s.mainChain) || s.mainChain[stopHeight] != msg.StopHash {
+		return fmt.Errorf("cfheaders stop block %s is not on the best chain", msg.StopHash)
+	}
+	startHeight := stopHeight - int32(len(msg.FilterHashes)) + 1
+	if startHeight < 0 {
+		return fmt.Errorf("cfheaders has %d hashes for stop height %d", len(msg.FilterHashes), stopHeight)
+	}
+
+	expectedPrev, err := s.prevFilterHeader(startHeight)
+	if err != nil {
+		return err
+	}
+	if msg.PrevFilterHeader != expectedPrev {
+		return fmt.Errorf("%w: cfheaders at height %d does not extend the filter header chain", ErrFilterHeaderMismatch, startHeight)
+	}
+
+	// Compute the whole batch before storing so a bad batch changes nothing
+	headers := make([]chainhash.Hash, len(msg.FilterHashes))
+	prev := msg.PrevFilterHeader
+	for i, filterHash := range msg.FilterHashes {
+		headers[i] = chainhash.DoubleHashH(append(filterHash[:], prev[:]...))
+		prev = headers[i]
+
+		height := startHeight + int32(i)
+		if checkpoint, ok := s.checkpoints[height]; ok && checkpoint != headers[i] {
+			return fmt.Errorf("%w: cfheaders at height %d does not match the checkpoint", ErrFilterHeaderMismatch, height)
+		}
+		blockHash := s.mainChain[height]
+		if known, ok := s.filterHeaders[blockHash]; ok && known != headers[i] {
+			return fmt.Errorf("%w: conflicting filter header for block %s", ErrFilterHeaderMismatch, blockHash)
+		}
+	}
+	for i, header := range headers {
+		s.filterHeaders[s.mainChain[startHeight+int32(i)]] = header
+	}
+	return nil
+}
+
+// HandleCFilter verifies a cfilter against its filter header and matches it
+// against watched scripts, delivering a FilterMatch on a hit
+func (s *SPVClient) HandleCFilter(msg *wire.MsgCFilter) error {
+	if msg.FilterType != wire.GCSFilterRegular {
+		return fmt.Errorf("%w: %d", ErrUnsupportedFilterType, msg.FilterType)
+	}
+
+	s.headersMu.RLock()
+	height, known := s.heights[msg.BlockHash]
+	header, verified := s.filterHeaders[msg.BlockHash]
+	var prev chainhash.Hash
+	var err error
+	if known && verified {
+		prev, err = s.prevFilterHeader(height)
+	}
+	s.headersMu.RUnlock()
+
+	if !known || !verified {
+		return fmt.Errorf("%w: block %s", ErrMissingFilterHeader, msg.BlockHash)
+	}
+	if err != nil {
+		return err
+	}
+
+	filter, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM, msg.Data)
+	if err != nil {
+		return fmt.Errorf("invalid filter for block %s: %w", msg.BlockHash, err)
+	}
+	computed, err := builder.MakeHeaderForFilter(filter, prev)
+	if err != nil {
+		return err
+	}
+	if computed != header {
+		return fmt.Errorf("%w: filter for block %s", ErrFilterHeaderMismatch, msg.BlockHash)
+	}
+
+	match, err := s.matchFilter(filter, msg.BlockHash)
+	if err != nil || match == nil {
+		return err
+	}
+	match.Height = height
+
+	select {
+	case s.filterMatches <- *match:
+	case <-s.ctx.Done():
+	}
+	return nil
+}
+
+// matchFilter tests every watched script against filter and returns the
+// matches, or nil if none match
+func (s *SPVClient) matchFilter(filter *gcs.Filter, blockHash chainhash.Hash) (*FilterMatch, error) {
+	s.watchMu.RLock()
+	watched := make([]watchedScript, 0, len(s.watched))
+	for _, w := range s.watched {
+		watched = append(watched, w)
+	}
+	s.watchMu.RUnlock()
+
+	if len(watched) == 0 || filter.N() == 0 {
+		return nil, nil
+	}
+
+	key := builder.DeriveKey(&blockHash)
+	var match *FilterMatch
+	for _, w := range watched {
+		ok, err := filter.Match(key, w.script)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match filter for block %s: %w", blockHash, err)
+		}
+		if !ok {
+			continue
+		}
+		if match == nil {
+			match = &FilterMatch{BlockHash: blockHash}
+		}
+		match.Scripts = append(match.Scripts, w.script)
+		if w.address != nil {
+			match.Addresses = append(match.Addresses, w.address)
+		}
+	}
+	return match, nil
+}
+
+// prevFilterHeader returns the filter header preceding height, which is all
+// zeros for the genesis block; callers must hold headersMu
+func (s *SPVClient) prevFilterHeader(height int32) (chainhash.Hash, error) {
+	if height == 0 {
+		return chainhash.Hash{}, nil
+	}
+	prevBlock := s.mainChain[height-1]
+	header, ok := s.filterHeaders[prevBlock]
+	if !ok {
+		return chainhash.Hash{}, fmt.Errorf("%w: block %s at height %d", ErrMissingFilterHeader, prevBlock, height-1)
+	}
+	return header, nil
+}
+
+// filterRange validates a best-chain height range of at most maxBlocks
+// blocks and returns the stop block hash
+func (s *SPVClient) filterRange(startHeight, stopHeight int32, maxBlocks int) (chainhash.Hash, error) {
+	s.headersMu.RLock()
+	defer s.headersMu.RUnlock()
+
+	if startHeight < 0 || stopHeight < startHeight || int(stopHeight) >= len(s.mainChain) {
+		return chainhash.Hash{}, fmt.Errorf("invalid filter range %d-%d", startHeight, stopHeight)
+	}
+	if int(stopHeight-startHeight) >= maxBlocks {
+		return chainhash.Hash{}, fmt.Errorf("filter range %d-%d exceeds %d blocks", startHeight, stopHeight, maxBlocks)
+	}
+	return s.mainChain[stopHeight], nil
+}
@@ -0,0 +1,235 @@
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// filterChain is a test chain of blocks with their serialized basic filters
+type filterChain struct {
+	blocks  []*wire.MsgBlock
+	filters [][]byte
+}
+
+// newFilterChain builds blocks on the network's genesis block, each paying
+// the matching script of payTo (nil pays an anyone-can-spend script)
+func newFilterChain(t *testing.T, client *SPVClient, payTo [][]byte) *filterChain {
+	t.Helper()
+	chain := &filterChain{}
+	add := func(block *wire.MsgBlock) {
+		filter, err := builder.BuildBasicFilter(block, nil)
+		if err != nil {
+			t.Fatalf("BuildBasicFilter() error = %v", err)
+		}
+		data, err := filter.NBytes()
+		if err != nil {
+			t.Fatalf("NBytes() error = %v", err)
+		}
+		chain.blocks = append(chain.blocks, block)
+		chain.filters = append(chain.filters, data)
+	}
+	add(client.network.GenesisBlock)
+
+	for i, script := range payTo {
+		if script == nil {
+			script = []byte{txscript.OP_TRUE}
+		}
+		coinbase := wire.NewMsgTx(1)
+		coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), []byte{byte(i + 1), 0}, nil))
+		coinbase.AddTxOut(wire.NewTxOut(50_0000_0000, script))
+
+		block := wire.NewMsgBlock(&wire.BlockHeader{
+			Version:    1,
+			PrevBlock:  chain.blocks[len(chain.blocks)-1].BlockHash(),
+			MerkleRoot: coinbase.TxHash(),
+			Timestamp:  time.Unix(int64(1700000000+i), 0),
+			Bits:       0x207fffff,
+		})
+		block.AddTransaction(coinbase)
+		if err := client.AddBlockHeader(&block.Header); err != nil {
+			t.Fatalf("AddBlockHeader() error = %v", err)
+		}
+		add(block)
+	}
+	return chain
+}
+
+// cfheaders returns the cfheaders message for blocks start..stop
+func (c *filterChain) cfheaders(t *testing.T, start, stop int, prev chainhash.Hash) *wire.MsgCFHeaders {
+	t.Helper()
+	msg := wire.NewMsgCFHeaders()
+	msg.FilterType = wire.GCSFilterRegular
+	msg.StopHash = c.blocks[stop].BlockHash()
+	msg.PrevFilterHeader = prev
+	for i := start; i <= stop; i++ {
+		hash := chainhash.DoubleHashH(c.filters[i])
+		msg.AddCFHash(&hash)
+	}
+	return msg
+}
+
+func (c *filterChain) cfilter(height int) *wire.MsgCFilter {
+	hash := c.blocks[height].BlockHash()
+	return wire.NewMsgCFilter(wire.GCSFilterRegular, &hash, c.filters[height])
+}
+
+func TestSPVClientCompactFilterMatch(t *testing.T) {
+	client := NewSPVClient(&chaincfg.RegressionNetParams)
+	client.Start()
+	defer client.Stop()
+
+	prophecyWords := []string{
+		"sword", "legend", "pull", "magic", "kingdom", "artist",
+		"stone", "destroy", "forget", "fire", "steel", "honey", "question",
+	}
+	vault, err := GenerateTaprootVault(prophecyWords, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("GenerateTaprootVault() error = %v", err)
+	}
+	if err := client.WatchVault(vault); err != nil {
+		t.Fatalf("WatchVault() error = %v", err)
+	}
+	address, _ := btcutil.DecodeAddress(vault.Address, &chaincfg.RegressionNetParams)
+	vaultScript, _ := txscript.PayToAddrScript(address)
+
+	chain := newFilterChain(t, client, [][]byte{nil, vaultScript, nil})
+
+	req, err := client.NewGetCFHeaders(0, 3)
+	if err != nil {
+		t.Fatalf("NewGetCFHeaders() error = %v", err)
+	}
+	if req.StopHash != chain.blocks[3].BlockHash() || req.StartHeight != 0 {
+		t.Errorf("Unexpected getcfheaders %+v", req)
+	}
+	if err := client.HandleFilterMessage(chain.cfheaders(t, 0, 3, chainhash.Hash{})); err != nil {
+		t.Fatalf("HandleCFHeaders() error = %v", err)
+	}
+
+	for height := 0; height <= 3; height++ {
+		if err := client.HandleFilterMessage(chain.cfilter(height)); err != nil {
+			t.Fatalf("HandleCFilter(%d) error = %v", height, err)
+		}
+	}
+
+	select {
+	case match := <-client.FilterMatches():
+		if match.BlockHash != chain.blocks[2].BlockHash() || match.Height != 2 {
+			t.Errorf("Expected match in block 2, got height %d", match.Height)
+		}
+		if len(match.Addresses) != 1 || match.Addresses[0].EncodeAddress() != vault.Address {
+			t.Errorf("Unexpected matched addresses %v", match.Addresses)
+		}
+	default:
+		t.Fatal("Expected a filter match for the vault payment")
+	}
+	select {
+	case match := <-client.FilterMatches():
+		t.Errorf("Unexpected extra match at height %d", match.Height)
+	default:
+	}
+}
+
+func TestSPVClientFilterHeaderVerification(t *testing.T) {
+	client := NewSPVClient(&chaincfg.RegressionNetParams)
+	client.Start()
+	defer client.Stop()
+	chain := newFilterChain(t, client, [][]byte{nil, nil})
+
+	// A batch that does not start at genesis needs its predecessor verified
+	err := client.HandleCFHeaders(chain.cfheaders(t, 1, 2, chainhash.Hash{}))
+	if !errors.Is(err, ErrMissingFilterHeader) {
+		t.Errorf("Expected ErrMissingFilterHeader, got %v", err)
+	}
+
+	// Filters are rejected before their header is verified
+	if err := client.HandleCFilter(chain.cfilter(0)); !errors.Is(err, ErrMissingFilterHeader) {
+		t.Errorf("Expected ErrMissingFilterHeader, got %v", err)
+	}
+
+	if err := client.HandleCFHeaders(chain.cfheaders(t, 0, 0, chainhash.Hash{})); err != nil {
+		t.Fatalf("HandleCFHeaders() error = %v", err)
+	}
+	genesisHeader, ok := client.GetFilterHeader(chain.blocks[0].BlockHash())
+	if !ok {
+		t.Fatal("Expected genesis filter header")
+	}
+
+	// Wrong previous header
+	if err := client.HandleCFHeaders(chain.cfheaders(t, 1, 2, chainhash.Hash{1})); !errors.Is(err, ErrFilterHeaderMismatch) {
+		t.Errorf("Expected ErrFilterHeaderMismatch, got %v", err)
+	}
+	if err := client.HandleCFHeaders(chain.cfheaders(t, 1, 2, genesisHeader)); err != nil {
+		t.Fatalf("HandleCFHeaders() error = %v", err)
+	}
+
+	// A filter that does not hash to its header is rejected
+	bad := chain.cfilter(1)
+	bad.Data = chain.filters[2]
+	if err := client.HandleCFilter(bad); !errors.Is(err, ErrFilterHeaderMismatch) {
+		t.Errorf("Expected ErrFilterHeaderMismatch, got %v", err)
+	}
+	if err := client.HandleCFilter(chain.cfilter(1)); err != nil {
+		t.Errorf("HandleCFilter() error = %v", err)
+	}
+
+	if _, err := client.NewGetCFilters(0, 5); err == nil {
+		t.Error("Expected error for range beyond the best chain")
+	}
+}
+
+func TestSPVClientFilterCheckpoint(t *testing.T) {
+	client := NewSPVClient(&chaincfg.RegressionNetParams)
+	client.Start()
+	defer client.Stop()
+	chain := newFilterChain(t, client, [][]byte{nil, nil})
+
+	// The genuine header at height 2, computed by a trusted node
+	var header chainhash.Hash
+	for _, filter := range chain.filters {
+		filterHash := chainhash.DoubleHashH(filter)
+		header = chainhash.DoubleHashH(append(filterHash[:], header[:]...))
+	}
+	if err := client.AddFilterCheckpoint(2, header); err != nil {
+		t.Fatalf("AddFilterCheckpoint() error = %v", err)
+	}
+
+	// A peer serving a filter that hides a payment is caught at the
+	// checkpoint, and nothing from its batch is stored
+	lying := chain.cfheaders(t, 0, 2, chainhash.Hash{})
+	lying.FilterHashes[1] = &chainhash.Hash{1}
+	if err := client.HandleCFHeaders(lying); !errors.Is(err, ErrFilterHeaderMismatch) {
+		t.Errorf("Expected ErrFilterHeaderMismatch, got %v", err)
+	}
+	if _, ok := client.GetFilterHeader(chain.blocks[0].BlockHash()); ok {
+		t.Error("Expected the rejected batch to store nothing")
+	}
+
+	if err := client.HandleCFHeaders(chain.cfheaders(t, 0, 2, chainhash.Hash{})); err != nil {
+		t.Fatalf("HandleCFHeaders() error = %v", err)
+	}
+	if got, _ := client.GetFilterHeader(chain.blocks[2].BlockHash()); got != header {
+		t.Errorf("Filter header at height 2 = %s, expected %s", got, header)
+	}
+	if err := client.AddFilterCheckpoint(1, chainhash.Hash{1}); !errors.Is(err, ErrFilterHeaderMismatch) {
+		t.Errorf("Expected a checkpoint conflicting with a verified header to be refused, got %v", err)
+	}
+}
+
+func TestSPVClientWatchAddressNetwork(t *testing.T) {
+	client := NewSPVClient(&chaincfg.MainNetParams)
+	testnet, err := btcutil.DecodeAddress("tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("DecodeAddress() error = %v", err)
+	}
+	if err := client.WatchAddress(testnet); err == nil {
+		t.Error("Expected error watching a testnet address on mainnet")
+	}
+}
@@ -0,0 +1,386 @@
+// Package hww signs PSBTs with hardware wallets such as Ledger and Trezor,
+// so treasury and vault keys never touch the host. It drives HWI
+// (https://github.com/bitcoin-core/HWI), which speaks each vendor's HID
+// protocol: the host exports an unsigned PSBT carrying the BIP-371 Taproot
+// fields the device needs to derive and tweak its key, and imports only the
+// signatures it returns, each verified before it is kept.
+package hww
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin/psbt"
+)
+
+var (
+	// ErrNoDevice indicates no connected device has the fingerprint asked for
+	ErrNoDevice = errors.New("hardware wallet not found")
+	// ErrDevice indicates an error reported by HWI or the device, such as a
+	// refused confirmation
+	ErrDevice = errors.New("hardware wallet error")
+	// ErrBadSignature indicates a device signature that does not verify
+	ErrBadSignature = errors.New("hardware wallet returned an invalid signature")
+)
+
+// KeyPrefix marks a key held by a hardware wallet
+const KeyPrefix = "hww:"
+
+// Device is a hardware wallet found by Enumerate
+type Device struct {
+	Type        string `json:"type"`
+	Model       string `json:"model"`
+	Path        string `json:"path"`
+	Fingerprint string `json:"fingerprint"`
+	// NeedsPinSent is set for a locked Trezor One, which must be unlocked
+	// with hwi promptpin and sendpin first
+	NeedsPinSent        bool   `json:"needs_pin_sent"`
+	NeedsPassphraseSent bool   `json:"needs_passphrase_sent"`
+	Error               string `json:"error,omitempty"`
+}
+
+// Key is a Taproot key on a hardware wallet: the key at Path under the
+// master key with Fingerprint, tweaked to commit to ScriptRoot (nil for a
+// BIP-86 key)
+type Key struct {
+	Fingerprint [4]byte
+	Path        []uint32
+	ScriptRoot  []byte
+}
+
+// ParseKey parses a key reference "hww:FINGERPRINT/PATH[:ROOT]", such as
+// "hww:d34db33f/86h/0h/0h/0/0" or, for a vault, the same followed by ":" and
+// its hex script root. Hardened steps end in h or '.
+func ParseKey(s string) (*Key, error) {
+	ref, ok := strings.CutPrefix(strings.TrimSpace(s), KeyPrefix)
+	if !ok {
+		return nil, fmt.Errorf("hardware wallet key must start with %q", KeyPrefix)
+	}
+	ref, rootHex, hasRoot := strings.Cut(ref, ":")
+	steps := strings.Split(ref, "/")
+
+	key := &Key{}
+	fingerprint, err := hex.DecodeString(steps[0])
+	if err != nil || len(fingerprint) != 4 {
+		return nil, fmt.Errorf("invalid fingerprint %q", steps[0])
+	}
+	copy(key.Fingerprint[:], fingerprint)
+	if len(steps) < 2 {
+		return nil, errors.New("hardware wallet key needs a derivation path")
+	}
+	for _, step := range steps[1:] {
+		n, err := parseStep(step)
+		if err != nil {
+			return nil, err
+		}
+		key.Path = append(key.Path, n)
+	}
+	if hasRoot {
+		if key.ScriptRoot, err = hex.DecodeString(rootHex); err != nil || len(key.ScriptRoot) != 32 {
+			return nil, fmt.Errorf("invalid script root %q", rootHex)
+		}
+	}
+	return key, nil
+}
+
+// parseStep parses a BIP-32 path step, hardened when it ends in h or '
+func parseStep(step string) (uint32, error) {
+	trimmed := strings.TrimRight(step, "h'")
+	hardened := len(trimmed) == len(step)-1
+	n, err := strconv.ParseUint(trimmed, 10, 32)
+	if err != nil || n >= hdkeychain.HardenedKeyStart || len(trimmed) < len(step)-1 {
+		return 0, fmt.Errorf("invalid path step %q", step)
+	}
+	if hardened {
+		n += hdkeychain.HardenedKeyStart
+	}
+	return uint32(n), nil
+}
+
+// String returns the key in the form ParseKey reads
+func (k *Key) String() string {
+	s := KeyPrefix + hex.EncodeToString(k.Fingerprint[:]) + strings.TrimPrefix(k.PathString(), "m")
+	if k.ScriptRoot != nil {
+		s += ":" + hex.EncodeToString(k.ScriptRoot)
+	}
+	return s
+}
+
+// PathString returns the derivation path as HWI takes it, e.g. m/86h/0h/0h/0/0
+func (k *Key) PathString() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, step := range k.Path {
+		if step >= hdkeychain.HardenedKeyStart {
+			fmt.Fprintf(&b, "/%dh", step-hdkeychain.HardenedKeyStart)
+		} else {
+			fmt.Fprintf(&b, "/%d", step)
+		}
+	}
+	return b.String()
+}
+
+// psbtFingerprint returns the fingerprint as btcd's psbt package stores it
+func (k *Key) psbtFingerprint() uint32 {
+	return binary.LittleEndian.Uint32(k.Fingerprint[:])
+}
+
+// HWI runs the hwi command
+type HWI struct {
+	// Command is the HWI executable, EXS_HWI or "hwi" by default
+	Command string
+	// Chain is the HWI chain name of the network: main, test, signet or regtest
+	Chain string
+	// run runs HWI with args and returns its standard output
+	run func(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// New returns an HWI for net
+func New(net *chaincfg.Params) *HWI {
+	h := &HWI{Command: os.Getenv("EXS_HWI"), Chain: chainName(net)}
+	if h.Command == "" {
+		h.Command = "hwi"
+	}
+	h.run = h.exec
+	return h
+}
+
+// chainName maps a network onto HWI's --chain values
+func chainName(net *chaincfg.Params) string {
+	switch net.Net {
+	case chaincfg.MainNetParams.Net:
+		return "main"
+	case chaincfg.SigNetParams.Net:
+		return "signet"
+	case chaincfg.RegressionNetParams.Net:
+		return "regtest"
+	}
+	return "test"
+}
+
+func (h *HWI) exec(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, h.Command, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// HWI reports most failures as JSON on stdout with a non-zero exit
+		if stdout.Len() > 0 {
+			return stdout.Bytes(), nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", h.Command, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", h.Command, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// call runs an HWI command on the device with fingerprint, or on no device
+// when it is empty, decoding its JSON result into v
+func (h *HWI) call(ctx context.Context, fingerprint string, v any, args ...string) error {
+	full := []string{"--chain", h.Chain}
+	if fingerprint != "" {
+		full = append(full, "--fingerprint", fingerprint)
+	}
+	out, err := h.run(ctx, append(full, args...)...)
+	if err != nil {
+		return err
+	}
+	var failure struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+	}
+	if json.Unmarshal(out, &failure) == nil && failure.Error != "" {
+		// -3 is HWI's DEVICE_CONN_ERROR, also given when no device has the
+		// fingerprint
+		if failure.Code == -3 {
+			return fmt.Errorf("%w: %s", ErrNoDevice, fingerprint)
+		}
+		return fmt.Errorf("%w: %s", ErrDevice, failure.Error)
+	}
+	if err := json.Unmarshal(out, v); err != nil {
+		return fmt.Errorf("invalid hwi output: %w", err)
+	}
+	return nil
+}
+
+// Enumerate lists the connected hardware wallets
+func (h *HWI) Enumerate(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	if err := h.call(ctx, "", &devices, "enumerate"); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// PublicKey returns the untweaked internal key of key from its device
+func (h *HWI) PublicKey(ctx context.Context, key *Key) (*btcec.PublicKey, error) {
+	var result struct {
+		XPub string `json:"xpub"`
+	}
+	fingerprint := hex.EncodeToString(key.Fingerprint[:])
+	if err := h.call(ctx, fingerprint, &result, "getxpub", key.PathString()); err != nil {
+		return nil, err
+	}
+	ext, err := hdkeychain.NewKeyFromString(result.XPub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpub from device: %w", err)
+	}
+	return ext.ECPubKey()
+}
+
+// OutputKey returns the Taproot output key of key from its device
+func (h *HWI) OutputKey(ctx context.Context, key *Key) (*btcec.PublicKey, error) {
+	internal, err := h.PublicKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return (&bitcoin.TaprootKey{Signer: pubKeyOnly{internal}, ScriptRoot: key.ScriptRoot}).OutputKey(), nil
+}
+
+// pubKeyOnly lets a TaprootKey compute scripts for a key held elsewhere
+type pubKeyOnly struct {
+	pub *btcec.PublicKey
+}
+
+func (p pubKeyOnly) PubKey() *btcec.PublicKey {
+	return p.pub
+}
+
+func (p pubKeyOnly) SignTaproot(digest, scriptRoot []byte) (*schnorr.Signature, error) {
+	return nil, errors.New("key is on a hardware wallet")
+}
+
+// Sign has the devices holding keys sign the unsigned P2TR inputs they
+// control by key path and returns how many were signed. For each input it
+// first records the internal key, merkle root and BIP-32 derivation
+// (BIP-371), then sends the PSBT to the device, which shows the outputs for
+// confirmation. Only the returned key-path signatures are taken back, and
+// only once they verify against the input.
+func (h *HWI) Sign(ctx context.Context, packet *psbt.Packet, keys []*Key) (int, error) {
+	prevOuts := make([]*wire.TxOut, len(packet.Inputs))
+	for i, in := range packet.Inputs {
+		if in.WitnessUtxo == nil {
+			return 0, fmt.Errorf("%w: input %d", psbt.ErrMissingUtxo, i)
+		}
+		prevOuts[i] = in.WitnessUtxo
+	}
+
+	// Inputs to sign by device fingerprint, with their output keys
+	byDevice := make(map[string][]int)
+	var order []string
+	outputKeys := make(map[int]*btcec.PublicKey)
+	for _, key := range keys {
+		internal, err := h.PublicKey(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		taprootKey := &bitcoin.TaprootKey{Signer: pubKeyOnly{internal}, ScriptRoot: key.ScriptRoot}
+		pkScript, err := taprootKey.PkScript()
+		if err != nil {
+			return 0, err
+		}
+		fingerprint := hex.EncodeToString(key.Fingerprint[:])
+		for i := range packet.Inputs {
+			in := &packet.Inputs[i]
+			if outputKeys[i] != nil || len(in.TaprootKeySpendSig) > 0 || len(in.FinalScriptWitness) > 0 ||
+				!bytes.Equal(in.WitnessUtxo.PkScript, pkScript) {
+				continue
+			}
+			if err := psbt.AddTaprootInput(packet, i, internal, key.ScriptRoot); err != nil {
+				return 0, err
+			}
+			if err := psbt.AddTaprootDerivation(packet, i, internal, key.psbtFingerprint(), key.Path); err != nil {
+				return 0, err
+			}
+			if _, ok := byDevice[fingerprint]; !ok {
+				order = append(order, fingerprint)
+			}
+			byDevice[fingerprint] = append(byDevice[fingerprint], i)
+			outputKeys[i] = taprootKey.OutputKey()
+		}
+	}
+
+	signed := 0
+	for _, fingerprint := range order {
+		encoded, err := psbt.Encode(packet)
+		if err != nil {
+			return signed, err
+		}
+		var result struct {
+			PSBT   string `json:"psbt"`
+			Signed bool   `json:"signed"`
+		}
+		if err := h.call(ctx, fingerprint, &result, "signtx", encoded); err != nil {
+			return signed, err
+		}
+		returned, err := psbt.Decode(result.PSBT)
+		if err != nil {
+			return signed, fmt.Errorf("device %s: %w", fingerprint, err)
+		}
+		if returned.UnsignedTx.TxHash() != packet.UnsignedTx.TxHash() {
+			return signed, fmt.Errorf("%w: device %s returned a different transaction", ErrDevice, fingerprint)
+		}
+
+		for _, i := range byDevice[fingerprint] {
+			sig := returned.Inputs[i].TaprootKeySpendSig
+			if len(sig) == 0 {
+				return signed, fmt.Errorf("%w: device %s did not sign input %d", ErrDevice, fingerprint, i)
+			}
+			if err := verifySignature(packet, i, prevOuts, sig, outputKeys[i]); err != nil {
+				return signed, fmt.Errorf("device %s, input %d: %w", fingerprint, i, err)
+			}
+			packet.Inputs[i].TaprootKeySpendSig = sig
+			signed++
+		}
+	}
+	return signed, nil
+}
+
+// verifySignature checks a key-path signature for input idx against its
+// output key and the sighash type the input asks for
+func verifySignature(packet *psbt.Packet, idx int, prevOuts []*wire.TxOut, sig []byte, outputKey *btcec.PublicKey) error {
+	hashType := txscript.SigHashDefault
+	switch len(sig) {
+	case schnorr.SignatureSize:
+	case schnorr.SignatureSize + 1:
+		hashType = txscript.SigHashType(sig[schnorr.SignatureSize])
+	default:
+		return fmt.Errorf("%w: length %d", ErrBadSignature, len(sig))
+	}
+	if want := packet.Inputs[idx].SighashType; want != 0 && hashType != want {
+		return fmt.Errorf("%w: sighash type %#x, want %#x", ErrBadSignature, hashType, want)
+	}
+
+	parsed, err := schnorr.ParseSignature(sig[:schnorr.SignatureSize])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	sighash, err := bitcoin.TaprootSighash(packet.UnsignedTx, idx, prevOuts, hashType)
+	if err != nil {
+		return err
+	}
+	if !parsed.Verify(sighash, outputKey) {
+		// Typically a device that ignored the merkle root and signed for the
+		// untweaked BIP-86 output key
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package hww
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin/psbt"
+)
+
+// fakeDevice answers HWI commands for a wallet with a fixed seed. A device
+// that ignoresRoot signs every input as a BIP-86 key.
+type fakeDevice struct {
+	t           *testing.T
+	master      *hdkeychain.ExtendedKey
+	fingerprint string
+	ignoresRoot bool
+}
+
+func newFakeDevice(t *testing.T) *fakeDevice {
+	t.Helper()
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{0x42}, 32), &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := master.ECPubKey()
+	return &fakeDevice{t: t, master: master, fingerprint: hex.EncodeToString(btcutil.Hash160(pub.SerializeCompressed())[:4])}
+}
+
+func (d *fakeDevice) derive(path []uint32) *hdkeychain.ExtendedKey {
+	key := d.master
+	for _, step := range path {
+		var err error
+		if key, err = key.Derive(step); err != nil {
+			d.t.Fatal(err)
+		}
+	}
+	return key
+}
+
+func (d *fakeDevice) run(ctx context.Context, args ...string) ([]byte, error) {
+	if args[0] != "--chain" || args[1] != "regtest" {
+		d.t.Fatalf("Unexpected arguments %v", args)
+	}
+	args = args[2:]
+	if args[0] == "--fingerprint" {
+		if args[1] != d.fingerprint {
+			return []byte(`{"error": "Could not find device with specified fingerprint", "code": -3}`), nil
+		}
+		args = args[2:]
+	}
+	switch args[0] {
+	case "enumerate":
+		return json.Marshal([]Device{{Type: "trezor", Model: "trezor_t", Path: "webusb:000:1", Fingerprint: d.fingerprint}})
+	case "getxpub":
+		key, err := ParseKey(KeyPrefix + d.fingerprint + args[1][1:])
+		if err != nil {
+			d.t.Fatal(err)
+		}
+		xpub, _ := d.derive(key.Path).Neuter()
+		return json.Marshal(map[string]string{"xpub": xpub.String()})
+	case "signtx":
+		packet, err := psbt.Decode(args[1])
+		if err != nil {
+			d.t.Fatal(err)
+		}
+		var keys []*bitcoin.TaprootKey
+		for _, in := range packet.Inputs {
+			for _, derivation := range in.TaprootBip32Derivation {
+				var fingerprint [4]byte
+				binary.LittleEndian.PutUint32(fingerprint[:], derivation.MasterKeyFingerprint)
+				if hex.EncodeToString(fingerprint[:]) != d.fingerprint {
+					continue
+				}
+				priv, _ := d.derive(derivation.Bip32Path).ECPrivKey()
+				key := &bitcoin.TaprootKey{PrivKey: priv, ScriptRoot: in.TaprootMerkleRoot}
+				if d.ignoresRoot {
+					// Sign as the BIP-86 key, which the host must reject
+					key.ScriptRoot = nil
+					pkScript, _ := key.PkScript()
+					saved := in.WitnessUtxo.PkScript
+					in.WitnessUtxo.PkScript = pkScript
+					psbt.Sign(packet, []*bitcoin.TaprootKey{key})
+					in.WitnessUtxo.PkScript = saved
+					continue
+				}
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) > 0 {
+			if _, err := psbt.Sign(packet, keys); err != nil {
+				d.t.Fatal(err)
+			}
+		}
+		encoded, _ := psbt.Encode(packet)
+		return json.Marshal(map[string]any{"psbt": encoded, "signed": true})
+	}
+	return []byte(`{"error": "Unknown command", "code": -13}`), nil
+}
+
+// testPacket spends an output of each key
+func testPacket(t *testing.T, h *HWI, keys ...*Key) *psbt.Packet {
+	t.Helper()
+	var utxos []bitcoin.UTXO
+	for i, key := range keys {
+		outputKey, err := h.OutputKey(context.Background(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkScript, _ := txscript.PayToTaprootScript(outputKey)
+		hash := chainhash.DoubleHashH([]byte{byte(i)})
+		utxos = append(utxos, bitcoin.UTXO{OutPoint: *wire.NewOutPoint(&hash, 0), Value: 50_000, PkScript: pkScript})
+	}
+	packet, err := psbt.Create(utxos, []*wire.TxOut{wire.NewTxOut(int64(len(keys))*50_000-1_000, utxos[0].PkScript)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey("hww:d34db33f/86h/1'/0h/0/7:" + hex.EncodeToString(bytes.Repeat([]byte{0xaa}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{86 + hdkeychain.HardenedKeyStart, 1 + hdkeychain.HardenedKeyStart, hdkeychain.HardenedKeyStart, 0, 7}
+	if hex.EncodeToString(key.Fingerprint[:]) != "d34db33f" || len(key.Path) != len(want) || len(key.ScriptRoot) != 32 {
+		t.Fatalf("ParseKey() = %+v", key)
+	}
+	for i := range want {
+		if key.Path[i] != want[i] {
+			t.Errorf("Path[%d] = %d, want %d", i, key.Path[i], want[i])
+		}
+	}
+	if key.PathString() != "m/86h/1h/0h/0/7" {
+		t.Errorf("PathString() = %s", key.PathString())
+	}
+	if again, err := ParseKey(key.String()); err != nil || again.String() != key.String() {
+		t.Errorf("ParseKey(String()) = %v, %v", again, err)
+	}
+
+	for _, bad := range []string{"d34db33f/86h", "hww:d34db3/86h", "hww:d34db33f", "hww:d34db33f/86hh", "hww:d34db33f/x", "hww:d34db33f/0:abcd"} {
+		if _, err := ParseKey(bad); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSignVaultAndBIP86Inputs(t *testing.T) {
+	device := newFakeDevice(t)
+	h := New(&chaincfg.RegressionNetParams)
+	h.run = device.run
+
+	devices, err := h.Enumerate(context.Background())
+	if err != nil || len(devices) != 1 || devices[0].Fingerprint != device.fingerprint {
+		t.Fatalf("Enumerate() = %v, %v", devices, err)
+	}
+
+	hot, _ := ParseKey(KeyPrefix + device.fingerprint + "/86h/1h/0h/0/0")
+	vault, _ := ParseKey(KeyPrefix + device.fingerprint + "/86h/1h/0h/0/1:" + hex.EncodeToString(bytes.Repeat([]byte{0xbb}, 32)))
+	packet := testPacket(t, h, hot, vault)
+
+	signed, err := h.Sign(context.Background(), packet, []*Key{hot, vault})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed != 2 {
+		t.Fatalf("Sign() signed %d inputs, want 2", signed)
+	}
+	if len(packet.Inputs[1].TaprootMerkleRoot) != 32 || len(packet.Inputs[1].TaprootBip32Derivation) != 1 {
+		t.Error("Vault input lacks its BIP-371 fields")
+	}
+	if _, err := psbt.Finalize(packet); err != nil {
+		t.Errorf("Finalize() = %v", err)
+	}
+}
+
+func TestSignRejectsUntweakedSignature(t *testing.T) {
+	device := newFakeDevice(t)
+	device.ignoresRoot = true
+	h := New(&chaincfg.RegressionNetParams)
+	h.run = device.run
+
+	vault, _ := ParseKey(KeyPrefix + device.fingerprint + "/86h/1h/0h/0/1:" + hex.EncodeToString(bytes.Repeat([]byte{0xbb}, 32)))
+	packet := testPacket(t, h, vault)
+	if _, err := h.Sign(context.Background(), packet, []*Key{vault}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Sign() = %v, want ErrBadSignature", err)
+	}
+	if len(packet.Inputs[0].TaprootKeySpendSig) != 0 {
+		t.Error("Rejected signature was kept")
+	}
+}
+
+func TestUnknownDevice(t *testing.T) {
+	device := newFakeDevice(t)
+	h := New(&chaincfg.RegressionNetParams)
+	h.run = device.run
+
+	key, _ := ParseKey("hww:00000000/86h/1h/0h/0/0")
+	if _, err := h.PublicKey(context.Background(), key); !errors.Is(err, ErrNoDevice) {
+		t.Errorf("PublicKey() = %v, want ErrNoDevice", err)
+	}
+}
@@ -0,0 +1,222 @@
+package bitcoin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// InscriptionPostage is the value, in satoshis, of the output an inscription
+// is revealed to, the default of the ord wallet
+const InscriptionPostage = 10_000
+
+// inscriptionProtocol marks an ord envelope
+var inscriptionProtocol = []byte("ord")
+
+// inscriptionContentTypeTag is the envelope field holding the content type
+const inscriptionContentTypeTag = 1
+
+// ErrInvalidInscription indicates an inscription that cannot be revealed
+var ErrInvalidInscription = errors.New("invalid inscription")
+
+// Inscription is content inscribed on a satoshi, Ordinals style, in the
+// witness of a Taproot script-path spend
+type Inscription struct {
+	ContentType string
+	Body        []byte
+}
+
+// InscriptionScript returns the tapscript that reveals ins: a CHECKSIG by key
+// followed by the envelope OP_FALSE OP_IF "ord" 1 <content type> 0 <body>
+// OP_ENDIF, the body split into pushes of at most 520 bytes
+func InscriptionScript(key *btcec.PublicKey, ins Inscription) ([]byte, error) {
+	if ins.ContentType == "" {
+		return nil, fmt.Errorf("%w: no content type", ErrInvalidInscription)
+	}
+	builder := txscript.NewScriptBuilder().
+		AddData(schnorr.SerializePubKey(key)).
+		AddOp(txscript.OP_CHECKSIG).
+		AddOp(txscript.OP_FALSE).
+		AddOp(txscript.OP_IF).
+		AddData(inscriptionProtocol).
+		AddData([]byte{inscriptionContentTypeTag}).
+		AddData([]byte(ins.ContentType)).
+		AddOp(txscript.OP_0)
+	for body := ins.Body; len(body) > 0; {
+		n := min(len(body), txscript.MaxScriptElementSize)
+		builder.AddFullData(body[:n])
+		body = body[n:]
+	}
+	script, err := builder.AddOp(txscript.OP_ENDIF).Script()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInscription, err)
+	}
+	return script, nil
+}
+
+// ParseInscriptions returns the inscriptions in the envelopes of the
+// tapscript a script-path witness reveals, none for other witnesses
+func ParseInscriptions(witness wire.TxWitness) []Inscription {
+	if len(witness) >= 2 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == txscript.TaprootAnnexTag {
+		witness = witness[:len(witness)-1]
+	}
+	if len(witness) < 2 {
+		return nil
+	}
+	script := witness[len(witness)-2]
+
+	var inscriptions []Inscription
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	// The last three opcodes, to spot OP_FALSE OP_IF "ord"
+	var window [3][]byte
+	opcodes := [3]byte{txscript.OP_INVALIDOPCODE, txscript.OP_INVALIDOPCODE, txscript.OP_INVALIDOPCODE}
+	for tokenizer.Next() {
+		copy(opcodes[:], opcodes[1:])
+		copy(window[:], window[1:])
+		opcodes[2], window[2] = tokenizer.Opcode(), tokenizer.Data()
+		if opcodes[0] == txscript.OP_FALSE && opcodes[1] == txscript.OP_IF && bytes.Equal(window[2], inscriptionProtocol) {
+			if ins, ok := parseEnvelope(&tokenizer); ok {
+				inscriptions = append(inscriptions, ins)
+			}
+			opcodes = [3]byte{txscript.OP_INVALIDOPCODE, txscript.OP_INVALIDOPCODE, txscript.OP_INVALIDOPCODE}
+		}
+	}
+	return inscriptions
+}
+
+// parseEnvelope reads the fields and body of an envelope up to its
+// OP_ENDIF; envelopes with other opcodes than pushes are not inscriptions
+func parseEnvelope(tokenizer *txscript.ScriptTokenizer) (Inscription, bool) {
+	var ins Inscription
+	var pushes [][]byte
+	body := -1
+	for tokenizer.Next() {
+		data, ok := pushData(tokenizer)
+		if !ok {
+			if tokenizer.Opcode() != txscript.OP_ENDIF {
+				return Inscription{}, false
+			}
+			if body < 0 {
+				body = len(pushes)
+			}
+			fields := pushes[:body]
+			for i := 0; i+1 < len(fields); i += 2 {
+				if bytes.Equal(fields[i], []byte{inscriptionContentTypeTag}) && ins.ContentType == "" {
+					ins.ContentType = string(fields[i+1])
+				}
+			}
+			for _, chunk := range pushes[body:] {
+				ins.Body = append(ins.Body, chunk...)
+			}
+			return ins, true
+		}
+		// An empty push in a tag position starts the body
+		if body < 0 && len(data) == 0 && len(pushes)%2 == 0 {
+			body = len(pushes)
+			continue
+		}
+		pushes = append(pushes, data)
+	}
+	return Inscription{}, false
+}
+
+// pushData returns the data the current opcode pushes, with OP_1 to OP_16
+// and OP_1NEGATE pushing their number
+func pushData(tokenizer *txscript.ScriptTokenizer) ([]byte, bool) {
+	op := tokenizer.Opcode()
+	switch {
+	case op <= txscript.OP_PUSHDATA4:
+		return tokenizer.Data(), true
+	case op == txscript.OP_1NEGATE:
+		return []byte{0x81}, true
+	case op >= txscript.OP_1 && op <= txscript.OP_16:
+		return []byte{op - txscript.OP_1 + 1}, true
+	}
+	return nil, false
+}
+
+// InscriptionCommit is the P2TR output an inscription is committed to: its
+// single script leaf is the InscriptionScript, so the reveal transaction
+// spending it by script path publishes the envelope in its witness
+type InscriptionCommit struct {
+	Key          *btcec.PrivateKey
+	Script       []byte
+	ControlBlock []byte
+	PkScript     []byte
+	Address      string
+}
+
+// NewInscriptionCommit builds the commit output of ins for key on net
+func NewInscriptionCommit(key *btcec.PrivateKey, ins Inscription, net *chaincfg.Params) (*InscriptionCommit, error) {
+	script, err := InscriptionScript(key.PubKey(), ins)
+	if err != nil {
+		return nil, err
+	}
+	tree := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(script))
+	control := tree.LeafMerkleProofs[0].ToControlBlock(key.PubKey())
+	controlBlock, err := control.ToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build control block: %w", err)
+	}
+	root := tree.RootNode.TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(key.PubKey(), root[:])
+	pkScript, err := txscript.PayToTaprootScript(outputKey)
+	if err != nil {
+		return nil, err
+	}
+	address, err := EncodeBech32m(schnorr.SerializePubKey(outputKey), net)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bech32m address: %w", err)
+	}
+	return &InscriptionCommit{
+		Key:          key,
+		Script:       script,
+		ControlBlock: controlBlock,
+		PkScript:     pkScript,
+		Address:      address,
+	}, nil
+}
+
+// RevealFee returns the fee at feeRate sat/vB of the reveal transaction
+// paying the inscription to pkScript
+func (c *InscriptionCommit) RevealFee(pkScript []byte, feeRate int64) int64 {
+	// The witness counts a quarter: item count, signature, script and
+	// control block, each with its length
+	witness := 1 + 1 + schnorr.SignatureSize +
+		wire.VarIntSerializeSize(uint64(len(c.Script))) + len(c.Script) +
+		1 + len(c.ControlBlock)
+	base := txOverheadVSize*4 + (32+4+1+4)*4 + (outputBaseVSize+len(pkScript))*4
+	return int64((base+witness+3)/4) * feeRate
+}
+
+// Reveal builds and signs the transaction spending the commit output at
+// outPoint, worth value, to pkScript at feeRate sat/vB. The inscription is
+// on the first satoshi of its only output.
+func (c *InscriptionCommit) Reveal(outPoint wire.OutPoint, value int64, pkScript []byte, feeRate int64) (*Spend, error) {
+	fee := c.RevealFee(pkScript, feeRate)
+	if value-fee < DustLimit {
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientFunds, value, fee+DustLimit)
+	}
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&outPoint, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(value-fee, pkScript))
+	spend := &Spend{Tx: tx, PrevOuts: []*wire.TxOut{wire.NewTxOut(value, c.PkScript)}, Fee: fee}
+
+	fetcher, err := prevOutFetcher(tx, spend.PrevOuts)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := txscript.RawTxInTapscriptSignature(tx, txscript.NewTxSigHashes(tx, fetcher), 0, value,
+		c.PkScript, txscript.NewBaseTapLeaf(c.Script), txscript.SigHashDefault, c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign reveal: %w", err)
+	}
+	tx.TxIn[0].Witness = wire.TxWitness{sig, c.Script, c.ControlBlock}
+	return spend, nil
+}
@@ -0,0 +1,108 @@
+package bitcoin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestInscriptionCommitReveal(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := Inscription{ContentType: "text/plain;charset=utf-8", Body: bytes.Repeat([]byte("excalibur "), 120)}
+	commit, err := NewInscriptionCommit(key, ins, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Address[:6] != "bcrt1p" {
+		t.Errorf("Expected a regtest Taproot commit address, got %s", commit.Address)
+	}
+
+	dest, _ := (&TaprootKey{PrivKey: key}).PkScript()
+	reveal, err := commit.Reveal(wire.OutPoint{Index: 1}, 20_000, dest, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reveal.Verify(); err != nil {
+		t.Fatalf("Reveal does not verify: %v", err)
+	}
+	if got := reveal.Tx.TxOut[0].Value + reveal.Fee; got != 20_000 {
+		t.Errorf("Expected output and fee to spend the commit, got %d", got)
+	}
+	if vsize := (reveal.Tx.SerializeSizeStripped()*3 + reveal.Tx.SerializeSize() + 3) / 4; int64(vsize)*2 > reveal.Fee {
+		t.Errorf("Reveal fee %d is below 2 sat/vB for %d vB", reveal.Fee, vsize)
+	}
+
+	got := ParseInscriptions(reveal.Tx.TxIn[0].Witness)
+	if len(got) != 1 || got[0].ContentType != ins.ContentType || !bytes.Equal(got[0].Body, ins.Body) {
+		t.Errorf("ParseInscriptions() = %+v", got)
+	}
+
+	if _, err := commit.Reveal(wire.OutPoint{}, 600, dest, 2); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+	if _, err := NewInscriptionCommit(key, Inscription{Body: []byte("x")}, &chaincfg.RegressionNetParams); !errors.Is(err, ErrInvalidInscription) {
+		t.Errorf("Expected ErrInvalidInscription without a content type, got %v", err)
+	}
+}
+
+func TestParseInscriptions(t *testing.T) {
+	envelope := func(ops ...func(*txscript.ScriptBuilder)) []byte {
+		b := txscript.NewScriptBuilder().AddOp(txscript.OP_FALSE).AddOp(txscript.OP_IF).AddData([]byte("ord"))
+		for _, op := range ops {
+			op(b)
+		}
+		script, _ := b.AddOp(txscript.OP_ENDIF).Script()
+		return script
+	}
+	data := func(d string) func(*txscript.ScriptBuilder) {
+		return func(b *txscript.ScriptBuilder) { b.AddFullData([]byte(d)) }
+	}
+	op := func(o byte) func(*txscript.ScriptBuilder) {
+		return func(b *txscript.ScriptBuilder) { b.AddOp(o) }
+	}
+
+	two := append(envelope(op(txscript.OP_1), data("text/plain"), op(txscript.OP_0), data("a"), data("b")),
+		envelope(op(txscript.OP_0), data("c"))...)
+	for name, tc := range map[string]struct {
+		witness wire.TxWitness
+		want    []Inscription
+	}{
+		"two envelopes": {
+			witness: wire.TxWitness{{}, two, {0xc0}},
+			want:    []Inscription{{ContentType: "text/plain", Body: []byte("ab")}, {Body: []byte("c")}},
+		},
+		"annex": {
+			witness: wire.TxWitness{two, {0xc0}, {txscript.TaprootAnnexTag}},
+			want:    []Inscription{{ContentType: "text/plain", Body: []byte("ab")}, {Body: []byte("c")}},
+		},
+		"no body": {
+			witness: wire.TxWitness{envelope(data("\x01"), data("text/plain")), {0xc0}},
+			want:    []Inscription{{ContentType: "text/plain"}},
+		},
+		"opcode in envelope": {
+			witness: wire.TxWitness{envelope(op(txscript.OP_0), op(txscript.OP_DUP), data("a")), {0xc0}},
+		},
+		"key path": {
+			witness: wire.TxWitness{bytes.Repeat([]byte{1}, 64)},
+		},
+	} {
+		got := ParseInscriptions(tc.witness)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: ParseInscriptions() = %+v, want %+v", name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i].ContentType != tc.want[i].ContentType || !bytes.Equal(got[i].Body, tc.want[i].Body) {
+				t.Errorf("%s: inscription %d = %+v, want %+v", name, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
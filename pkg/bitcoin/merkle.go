@@ -0,0 +1,57 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+var (
+	// ErrInvalidMerkleProof indicates a merkle proof that does not lead to
+	// its block's merkle root
+	ErrInvalidMerkleProof = errors.New("invalid merkle proof")
+	// ErrNotInBestChain indicates a block that is not on the best chain
+	ErrNotInBestChain = errors.New("block not in best chain")
+)
+
+// MerkleRootFromProof returns the merkle root reached from txHash by the
+// sibling hashes of proof, position being the transaction's index in its
+// block
+func MerkleRootFromProof(txHash chainhash.Hash, proof []chainhash.Hash, position int) chainhash.Hash {
+	current := txHash
+	for _, sibling := range proof {
+		var pair [2 * chainhash.HashSize]byte
+		if position&1 == 0 {
+			copy(pair[:], current[:])
+			copy(pair[chainhash.HashSize:], sibling[:])
+		} else {
+			copy(pair[:], sibling[:])
+			copy(pair[chainhash.HashSize:], current[:])
+		}
+		current = chainhash.DoubleHashH(pair[:])
+		position >>= 1
+	}
+	return current
+}
+
+// Confirmations verifies that proof places its transaction in a best-chain
+// block, sets proof.BlockHeight and returns the number of confirmations, 1
+// for the tip
+func (s *SPVClient) Confirmations(proof *TransactionProof) (int32, error) {
+	header, err := s.GetBlockHeader(proof.BlockHash)
+	if err != nil {
+		return 0, err
+	}
+	if MerkleRootFromProof(proof.TxHash, proof.MerkleProof, proof.Position) != header.MerkleRoot {
+		return 0, fmt.Errorf("%w: %s in block %s", ErrInvalidMerkleProof, proof.TxHash, proof.BlockHash)
+	}
+
+	s.headersMu.RLock()
+	defer s.headersMu.RUnlock()
+	if int(header.Height) >= len(s.mainChain) || s.mainChain[header.Height] != proof.BlockHash {
+		return 0, fmt.Errorf("%w: %s", ErrNotInBestChain, proof.BlockHash)
+	}
+	proof.BlockHeight = header.Height
+	return s.bestHeight - header.Height + 1, nil
+}
@@ -0,0 +1,54 @@
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func hashPair(a, b chainhash.Hash) chainhash.Hash {
+	return chainhash.DoubleHashH(append(a[:], b[:]...))
+}
+
+func TestConfirmations(t *testing.T) {
+	// A block of three transactions; the last is paired with itself
+	txs := []chainhash.Hash{chainhash.HashH([]byte("a")), chainhash.HashH([]byte("b")), chainhash.HashH([]byte("c"))}
+	left, right := hashPair(txs[0], txs[1]), hashPair(txs[2], txs[2])
+	root := hashPair(left, right)
+
+	spv := NewSPVClient(&chaincfg.RegressionNetParams)
+	spv.Start()
+	defer spv.Stop()
+	block := &wire.BlockHeader{PrevBlock: *chaincfg.RegressionNetParams.GenesisHash, MerkleRoot: root, Timestamp: time.Unix(1, 0)}
+	if err := spv.AddBlockHeader(block); err != nil {
+		t.Fatal(err)
+	}
+	blockHash := block.BlockHash()
+
+	proof := &TransactionProof{BlockHash: blockHash, TxHash: txs[2], MerkleProof: []chainhash.Hash{txs[2], left}, Position: 2}
+	if n, err := spv.Confirmations(proof); err != nil || n != 1 {
+		t.Errorf("Confirmations() at the tip = %d, %v, want 1", n, err)
+	}
+	first := &TransactionProof{BlockHash: blockHash, TxHash: txs[0], MerkleProof: []chainhash.Hash{txs[1], right}, Position: 0}
+	if n, err := spv.Confirmations(first); err != nil || n != 1 {
+		t.Errorf("Confirmations() of the first transaction = %d, %v, want 1", n, err)
+	}
+
+	next := &wire.BlockHeader{PrevBlock: blockHash, Timestamp: time.Unix(2, 0)}
+	if err := spv.AddBlockHeader(next); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := spv.Confirmations(proof); err != nil || n != 2 {
+		t.Errorf("Confirmations() a block deep = %d, %v, want 2", n, err)
+	}
+
+	wrong := *proof
+	wrong.Position = 1
+	if _, err := spv.Confirmations(&wrong); !errors.Is(err, ErrInvalidMerkleProof) {
+		t.Errorf("Expected ErrInvalidMerkleProof for the wrong position, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+var (
+	// ErrInvalidThreshold indicates a threshold outside 1..signers
+	ErrInvalidThreshold = errors.New("invalid multisig threshold")
+	// ErrDuplicateKey indicates the same signer key given twice
+	ErrDuplicateKey = errors.New("duplicate multisig key")
+)
+
+// MultisigVault is a P2TR vault held by several signers. Its internal key is
+// the MuSig2 (BIP-327) aggregate of every signer's key, so all of them
+// together spend by key path, and its single script leaf lets any Threshold
+// of them spend with OP_CHECKSIGADD. Keys are sorted first, so every signer
+// derives the same vault from the same keys in any order.
+type MultisigVault struct {
+	Threshold   int
+	Keys        []*btcec.PublicKey // sorted by compressed encoding
+	InternalKey *btcec.PublicKey
+	LeafScript  []byte
+	MerkleRoot  []byte
+	OutputKey   *btcec.PublicKey
+	Address     string
+}
+
+// NewMultisigVault builds the threshold-of-len(keys) vault for net
+func NewMultisigVault(keys []*btcec.PublicKey, threshold int, net *chaincfg.Params) (*MultisigVault, error) {
+	if len(keys) < 2 {
+		return nil, fmt.Errorf("%w: a multisig needs at least 2 keys, got %d", ErrInvalidThreshold, len(keys))
+	}
+	if threshold < 1 || threshold > len(keys) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrInvalidThreshold, threshold, len(keys))
+	}
+
+	sorted := append([]*btcec.PublicKey(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].SerializeCompressed(), sorted[j].SerializeCompressed()) < 0
+	})
+	seen := make(map[string]bool)
+	for _, key := range sorted {
+		xOnly := string(schnorr.SerializePubKey(key))
+		if seen[xOnly] {
+			return nil, fmt.Errorf("%w: %x", ErrDuplicateKey, xOnly)
+		}
+		seen[xOnly] = true
+	}
+
+	aggregate, _, _, err := musig2.AggregateKeys(sorted, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate keys: %w", err)
+	}
+	leafScript, err := thresholdScript(sorted, threshold)
+	if err != nil {
+		return nil, err
+	}
+	root := txscript.NewBaseTapLeaf(leafScript).TapHash()
+
+	v := &MultisigVault{
+		Threshold:   threshold,
+		Keys:        sorted,
+		InternalKey: aggregate.PreTweakedKey,
+		LeafScript:  leafScript,
+		MerkleRoot:  root[:],
+	}
+	v.OutputKey = txscript.ComputeTaprootOutputKey(v.InternalKey, v.MerkleRoot)
+	if v.Address, err = EncodeBech32m(schnorr.SerializePubKey(v.OutputKey), net); err != nil {
+		return nil, fmt.Errorf("failed to encode bech32m address: %w", err)
+	}
+	return v, nil
+}
+
+// thresholdScript is the tapscript <k1> CHECKSIG <k2> CHECKSIGADD ...
+// <threshold> NUMEQUAL
+func thresholdScript(keys []*btcec.PublicKey, threshold int) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	for i, key := range keys {
+		builder.AddData(schnorr.SerializePubKey(key))
+		if i == 0 {
+			builder.AddOp(txscript.OP_CHECKSIG)
+		} else {
+			builder.AddOp(txscript.OP_CHECKSIGADD)
+		}
+	}
+	builder.AddInt64(int64(threshold)).AddOp(txscript.OP_NUMEQUAL)
+	script, err := builder.Script()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build threshold script: %w", err)
+	}
+	return script, nil
+}
+
+// PkScript returns the vault's P2TR output script
+func (v *MultisigVault) PkScript() ([]byte, error) {
+	return txscript.PayToTaprootScript(v.OutputKey)
+}
+
+// ControlBlock returns the control block that reveals the threshold leaf in
+// a script-path spend
+func (v *MultisigVault) ControlBlock() ([]byte, error) {
+	tree := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(v.LeafScript))
+	control := tree.LeafMerkleProofs[0].ToControlBlock(v.InternalKey)
+	return control.ToBytes()
+}
+
+// Fingerprint is a short digest of the threshold and keys for signers to
+// read to each other: the first 4 bytes of their SHA-256, as hex in two
+// groups
+func (v *MultisigVault) Fingerprint() string {
+	h := sha256.New()
+	h.Write([]byte{byte(v.Threshold)})
+	for _, key := range v.Keys {
+		h.Write(key.SerializeCompressed())
+	}
+	sum := hex.EncodeToString(h.Sum(nil)[:4])
+	return sum[:4] + "-" + sum[4:]
+}
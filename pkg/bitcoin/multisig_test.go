@@ -0,0 +1,184 @@
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testSigners generates n signer keys
+func testSigners(t *testing.T, n int) ([]*btcec.PrivateKey, []*btcec.PublicKey) {
+	t.Helper()
+	privs := make([]*btcec.PrivateKey, n)
+	pubs := make([]*btcec.PublicKey, n)
+	for i := range privs {
+		priv, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs[i], pubs[i] = priv, priv.PubKey()
+	}
+	return privs, pubs
+}
+
+// testVaultSpend builds an unsigned spend of a vault output
+func testVaultSpend(t *testing.T, v *MultisigVault) *Spend {
+	t.Helper()
+	script, err := v.PkScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend, err := BuildTaprootSpend([]UTXO{testUTXO(t, 1, 100000, script)}, []*wire.TxOut{wire.NewTxOut(50000, script)}, script, 1)
+	if err != nil {
+		t.Fatalf("BuildTaprootSpend() error = %v", err)
+	}
+	return spend
+}
+
+func TestMultisigVaultDeterministic(t *testing.T) {
+	_, pubs := testSigners(t, 3)
+	a, err := NewMultisigVault(pubs, 2, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewMultisigVault() error = %v", err)
+	}
+	b, err := NewMultisigVault([]*btcec.PublicKey{pubs[2], pubs[0], pubs[1]}, 2, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Address != b.Address || a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("Key order changed the vault: %s vs %s", a.Address, b.Address)
+	}
+	if a.Address[:4] != "bc1p" || !VerifyTaprootAddress(a.Address) {
+		t.Errorf("Expected a mainnet taproot address, got %s", a.Address)
+	}
+
+	c, err := NewMultisigVault(pubs, 3, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Address == a.Address || c.Fingerprint() == a.Fingerprint() {
+		t.Error("Expected the threshold to change the vault")
+	}
+}
+
+func TestMultisigVaultErrors(t *testing.T) {
+	_, pubs := testSigners(t, 3)
+	for _, threshold := range []int{0, 4} {
+		if _, err := NewMultisigVault(pubs, threshold, &chaincfg.MainNetParams); !errors.Is(err, ErrInvalidThreshold) {
+			t.Errorf("threshold %d: expected ErrInvalidThreshold, got %v", threshold, err)
+		}
+	}
+	if _, err := NewMultisigVault(pubs[:1], 1, &chaincfg.MainNetParams); !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("single key: expected ErrInvalidThreshold, got %v", err)
+	}
+	if _, err := NewMultisigVault([]*btcec.PublicKey{pubs[0], pubs[1], pubs[0]}, 2, &chaincfg.MainNetParams); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("duplicate key: expected ErrDuplicateKey, got %v", err)
+	}
+}
+
+func TestMultisigVaultScriptSpend(t *testing.T) {
+	privs, pubs := testSigners(t, 3)
+	v, err := NewMultisigVault(pubs, 2, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control, err := v.ControlBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sign returns the script-path witness with signatures from signers;
+	// keys are consumed from the top of the stack, so the witness lists
+	// them in reverse
+	sign := func(spend *Spend, signers map[int]bool) wire.TxWitness {
+		fetcher, err := prevOutFetcher(spend.Tx, spend.PrevOuts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigHashes := txscript.NewTxSigHashes(spend.Tx, fetcher)
+		leaf := txscript.NewBaseTapLeaf(v.LeafScript)
+		var witness wire.TxWitness
+		for i := len(v.Keys) - 1; i >= 0; i-- {
+			var sig []byte
+			for j, priv := range privs {
+				if signers[j] && priv.PubKey().IsEqual(v.Keys[i]) {
+					sig, err = txscript.RawTxInTapscriptSignature(spend.Tx, sigHashes, 0,
+						spend.PrevOuts[0].Value, spend.PrevOuts[0].PkScript, leaf, txscript.SigHashDefault, priv)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			witness = append(witness, sig)
+		}
+		return append(witness, v.LeafScript, control)
+	}
+
+	spend := testVaultSpend(t, v)
+	spend.Tx.TxIn[0].Witness = sign(spend, map[int]bool{0: true, 2: true})
+	if err := spend.Verify(); err != nil {
+		t.Errorf("2-of-3 script spend: %v", err)
+	}
+
+	spend.Tx.TxIn[0].Witness = sign(spend, map[int]bool{1: true})
+	if err := spend.Verify(); err == nil {
+		t.Error("Expected a 1-of-3 script spend to fail")
+	}
+}
+
+func TestMultisigVaultKeySpend(t *testing.T) {
+	privs, pubs := testSigners(t, 3)
+	v, err := NewMultisigVault(pubs, 2, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend := testVaultSpend(t, v)
+	sighash, err := TaprootSighash(spend.Tx, 0, spend.PrevOuts, txscript.SigHashDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [32]byte
+	copy(msg[:], sighash)
+
+	// Every signer runs a MuSig2 session over the vault's sorted keys
+	sessions := make([]*musig2.Session, len(privs))
+	for i, priv := range privs {
+		ctx, err := musig2.NewContext(priv, false, musig2.WithKnownSigners(v.Keys), musig2.WithTaprootTweakCtx(v.MerkleRoot))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sessions[i], err = ctx.NewSession(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, s := range sessions {
+		for j, other := range sessions {
+			if i != j {
+				if _, err := s.RegisterPubNonce(other.PublicNonce()); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	partials := make([]*musig2.PartialSignature, len(sessions))
+	for i, s := range sessions {
+		if partials[i], err = s.Sign(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, partial := range partials[1:] {
+		if _, err := sessions[0].CombineSig(partial); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spend.Tx.TxIn[0].Witness = wire.TxWitness{sessions[0].FinalSig().Serialize()}
+	if err := spend.Verify(); err != nil {
+		t.Errorf("MuSig2 key spend: %v", err)
+	}
+}
@@ -0,0 +1,443 @@
+// Package psbt coordinates offline signing of vault and treasury spends with
+// BIP-174 partially signed transactions. It builds on the btcd psbt types and
+// adds the Taproot fields of BIP-371, a key-path signer for TaprootKeys and a
+// combiner for collecting signatures from several signers.
+//
+// The roles map onto functions as follows:
+//
+//	Creator:    Create, FromSpend
+//	Updater:    AddTaprootInput, AddTaprootOutput, AddTaprootDerivation
+//	Signer:     Sign
+//	Combiner:   Combine
+//	Finalizer:  Finalize
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+)
+
+// Packet is a BIP-174 partially signed transaction
+type Packet = psbt.Packet
+
+var (
+	// ErrTxMismatch indicates packets that spend different transactions
+	ErrTxMismatch = errors.New("psbts are for different transactions")
+	// ErrIncomplete indicates inputs still missing signatures
+	ErrIncomplete = errors.New("psbt is missing signatures")
+	// ErrMissingUtxo indicates an input without the output it spends
+	ErrMissingUtxo = errors.New("input has no witness utxo")
+)
+
+// Create returns a version 2 PSBT spending utxos into outputs. Inputs signal
+// replace-by-fee and carry their witness UTXO, which Taproot signers need.
+func Create(utxos []bitcoin.UTXO, outputs []*wire.TxOut) (*Packet, error) {
+	if len(utxos) == 0 {
+		return nil, errors.New("no inputs")
+	}
+	outPoints := make([]*wire.OutPoint, len(utxos))
+	sequences := make([]uint32, len(utxos))
+	for i := range utxos {
+		outPoints[i] = &utxos[i].OutPoint
+		sequences[i] = bitcoin.RBFSequence
+	}
+
+	packet, err := psbt.New(outPoints, outputs, 2, 0, sequences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create psbt: %w", err)
+	}
+	for i, utxo := range utxos {
+		packet.Inputs[i].WitnessUtxo = wire.NewTxOut(utxo.Value, utxo.PkScript)
+	}
+	return packet, nil
+}
+
+// FromSpend wraps an unsigned transaction from bitcoin.BuildTaprootSpend
+func FromSpend(spend *bitcoin.Spend) (*Packet, error) {
+	if len(spend.PrevOuts) != len(spend.Tx.TxIn) {
+		return nil, fmt.Errorf("have %d spent outputs for %d inputs", len(spend.PrevOuts), len(spend.Tx.TxIn))
+	}
+	unsigned := spend.Tx.Copy()
+	for _, in := range unsigned.TxIn {
+		in.SignatureScript = nil
+		in.Witness = nil
+	}
+
+	packet, err := psbt.NewFromUnsignedTx(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create psbt: %w", err)
+	}
+	for i, prevOut := range spend.PrevOuts {
+		packet.Inputs[i].WitnessUtxo = wire.NewTxOut(prevOut.Value, prevOut.PkScript)
+	}
+	return packet, nil
+}
+
+// AddTaprootInput records the internal key and script root behind a P2TR
+// input (PSBT_IN_TAP_INTERNAL_KEY and PSBT_IN_TAP_MERKLE_ROOT), which hardware
+// signers need to tweak their key. A nil merkleRoot means a BIP-86 key.
+func AddTaprootInput(packet *Packet, idx int, internalKey *btcec.PublicKey, merkleRoot []byte) error {
+	if idx < 0 || idx >= len(packet.Inputs) {
+		return fmt.Errorf("input %d out of range", idx)
+	}
+	if merkleRoot != nil && len(merkleRoot) != 32 {
+		return fmt.Errorf("invalid merkle root length %d", len(merkleRoot))
+	}
+	in := &packet.Inputs[idx]
+	if in.WitnessUtxo == nil {
+		return fmt.Errorf("%w: input %d", ErrMissingUtxo, idx)
+	}
+	if !bytes.Equal(taprootScript(internalKey, merkleRoot), in.WitnessUtxo.PkScript) {
+		return fmt.Errorf("internal key does not match input %d", idx)
+	}
+	in.TaprootInternalKey = schnorr.SerializePubKey(internalKey)
+	in.TaprootMerkleRoot = merkleRoot
+	return nil
+}
+
+// AddTaprootOutput records the internal key of a P2TR output, such as change,
+// so signers can recognize it (PSBT_OUT_TAP_INTERNAL_KEY)
+func AddTaprootOutput(packet *Packet, idx int, internalKey *btcec.PublicKey, merkleRoot []byte) error {
+	if idx < 0 || idx >= len(packet.Outputs) {
+		return fmt.Errorf("output %d out of range", idx)
+	}
+	if !bytes.Equal(taprootScript(internalKey, merkleRoot), packet.UnsignedTx.TxOut[idx].PkScript) {
+		return fmt.Errorf("internal key does not match output %d", idx)
+	}
+	packet.Outputs[idx].TaprootInternalKey = schnorr.SerializePubKey(internalKey)
+	return nil
+}
+
+// AddTaprootDerivation records where a hardware wallet with the master key
+// fingerprint finds the internal key of input idx (PSBT_IN_TAP_BIP32_DERIVATION)
+func AddTaprootDerivation(packet *Packet, idx int, internalKey *btcec.PublicKey, fingerprint uint32, path []uint32) error {
+	if idx < 0 || idx >= len(packet.Inputs) {
+		return fmt.Errorf("input %d out of range", idx)
+	}
+	xOnly := schnorr.SerializePubKey(internalKey)
+	in := &packet.Inputs[idx]
+	for _, derivation := range in.TaprootBip32Derivation {
+		if bytes.Equal(derivation.XOnlyPubKey, xOnly) {
+			derivation.MasterKeyFingerprint = fingerprint
+			derivation.Bip32Path = path
+			return nil
+		}
+	}
+	in.TaprootBip32Derivation = append(in.TaprootBip32Derivation, &psbt.TaprootBip32Derivation{
+		XOnlyPubKey:          xOnly,
+		MasterKeyFingerprint: fingerprint,
+		Bip32Path:            path,
+	})
+	return nil
+}
+
+// taprootScript returns the P2TR script committing to internalKey and
+// merkleRoot, or nil if it cannot be built
+func taprootScript(internalKey *btcec.PublicKey, merkleRoot []byte) []byte {
+	var outputKey *btcec.PublicKey
+	if merkleRoot == nil {
+		outputKey = txscript.ComputeTaprootKeyNoScript(internalKey)
+	} else {
+		outputKey = txscript.ComputeTaprootOutputKey(internalKey, merkleRoot)
+	}
+	script, err := txscript.PayToTaprootScript(outputKey)
+	if err != nil {
+		return nil
+	}
+	return script
+}
+
+// Sign adds BIP-340 key-path signatures to the unsigned P2TR inputs that keys
+// control, along with their BIP-371 internal key and merkle root, and returns
+// how many it signed. Every input needs its witness UTXO, since Taproot
+// signatures commit to all spent outputs.
+func Sign(packet *Packet, keys []*bitcoin.TaprootKey) (int, error) {
+	prevOuts := make([]*wire.TxOut, len(packet.Inputs))
+	for i, in := range packet.Inputs {
+		if in.WitnessUtxo == nil {
+			return 0, fmt.Errorf("%w: input %d", ErrMissingUtxo, i)
+		}
+		prevOuts[i] = in.WitnessUtxo
+	}
+
+	signed := 0
+	for i := range packet.Inputs {
+		in := &packet.Inputs[i]
+		if len(in.TaprootKeySpendSig) > 0 || len(in.FinalScriptWitness) > 0 ||
+			!txscript.IsPayToTaproot(in.WitnessUtxo.PkScript) {
+			continue
+		}
+		key := bitcoin.FindTaprootKey(in.WitnessUtxo.PkScript, keys)
+		if key == nil {
+			continue
+		}
+
+		sig, err := bitcoin.SignTaprootInput(packet.UnsignedTx, i, prevOuts, key, in.SighashType)
+		if err != nil {
+			return signed, err
+		}
+		in.TaprootKeySpendSig = sig
+		in.TaprootInternalKey = schnorr.SerializePubKey(key.InternalKey())
+		in.TaprootMerkleRoot = key.ScriptRoot
+		signed++
+	}
+	return signed, nil
+}
+
+// Combine merges the signatures and metadata of packets for the same
+// transaction into a new packet. Where packets disagree on a field the first
+// packet's value wins.
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, errors.New("no psbts to combine")
+	}
+	combined, err := clone(packets[0])
+	if err != nil {
+		return nil, err
+	}
+	txid := combined.UnsignedTx.TxHash()
+
+	for _, packet := range packets[1:] {
+		if packet.UnsignedTx.TxHash() != txid {
+			return nil, ErrTxMismatch
+		}
+		for i := range packet.Inputs {
+			combineInput(&combined.Inputs[i], &packet.Inputs[i])
+		}
+		for i := range packet.Outputs {
+			combineOutput(&combined.Outputs[i], &packet.Outputs[i])
+		}
+		combined.Unknowns = combineUnknowns(combined.Unknowns, packet.Unknowns)
+	}
+	return combined, nil
+}
+
+// combineInput copies the fields of src that dst lacks
+func combineInput(dst, src *psbt.PInput) {
+	if dst.NonWitnessUtxo == nil {
+		dst.NonWitnessUtxo = src.NonWitnessUtxo
+	}
+	if dst.WitnessUtxo == nil {
+		dst.WitnessUtxo = src.WitnessUtxo
+	}
+	if dst.SighashType == 0 {
+		dst.SighashType = src.SighashType
+	}
+	if dst.RedeemScript == nil {
+		dst.RedeemScript = src.RedeemScript
+	}
+	if dst.WitnessScript == nil {
+		dst.WitnessScript = src.WitnessScript
+	}
+	if dst.FinalScriptSig == nil {
+		dst.FinalScriptSig = src.FinalScriptSig
+	}
+	if dst.FinalScriptWitness == nil {
+		dst.FinalScriptWitness = src.FinalScriptWitness
+	}
+	if dst.TaprootKeySpendSig == nil {
+		dst.TaprootKeySpendSig = src.TaprootKeySpendSig
+	}
+	if dst.TaprootInternalKey == nil {
+		dst.TaprootInternalKey = src.TaprootInternalKey
+	}
+	if dst.TaprootMerkleRoot == nil {
+		dst.TaprootMerkleRoot = src.TaprootMerkleRoot
+	}
+
+	for _, sig := range src.PartialSigs {
+		if !containsPartialSig(dst.PartialSigs, sig.PubKey) {
+			dst.PartialSigs = append(dst.PartialSigs, sig)
+		}
+	}
+	for _, derivation := range src.Bip32Derivation {
+		if !containsBip32(dst.Bip32Derivation, derivation.PubKey) {
+			dst.Bip32Derivation = append(dst.Bip32Derivation, derivation)
+		}
+	}
+	for _, sig := range src.TaprootScriptSpendSig {
+		if !containsScriptSpendSig(dst.TaprootScriptSpendSig, sig) {
+			dst.TaprootScriptSpendSig = append(dst.TaprootScriptSpendSig, sig)
+		}
+	}
+	for _, leaf := range src.TaprootLeafScript {
+		if !containsLeafScript(dst.TaprootLeafScript, leaf.ControlBlock) {
+			dst.TaprootLeafScript = append(dst.TaprootLeafScript, leaf)
+		}
+	}
+	for _, derivation := range src.TaprootBip32Derivation {
+		if !containsTaprootBip32(dst.TaprootBip32Derivation, derivation.XOnlyPubKey) {
+			dst.TaprootBip32Derivation = append(dst.TaprootBip32Derivation, derivation)
+		}
+	}
+	dst.Unknowns = combineUnknowns(dst.Unknowns, src.Unknowns)
+}
+
+// combineOutput copies the fields of src that dst lacks
+func combineOutput(dst, src *psbt.POutput) {
+	if dst.RedeemScript == nil {
+		dst.RedeemScript = src.RedeemScript
+	}
+	if dst.WitnessScript == nil {
+		dst.WitnessScript = src.WitnessScript
+	}
+	if dst.TaprootInternalKey == nil {
+		dst.TaprootInternalKey = src.TaprootInternalKey
+	}
+	if dst.TaprootTapTree == nil {
+		dst.TaprootTapTree = src.TaprootTapTree
+	}
+	for _, derivation := range src.Bip32Derivation {
+		if !containsBip32(dst.Bip32Derivation, derivation.PubKey) {
+			dst.Bip32Derivation = append(dst.Bip32Derivation, derivation)
+		}
+	}
+	for _, derivation := range src.TaprootBip32Derivation {
+		if !containsTaprootBip32(dst.TaprootBip32Derivation, derivation.XOnlyPubKey) {
+			dst.TaprootBip32Derivation = append(dst.TaprootBip32Derivation, derivation)
+		}
+	}
+	dst.Unknowns = combineUnknowns(dst.Unknowns, src.Unknowns)
+}
+
+func containsPartialSig(sigs []*psbt.PartialSig, pubKey []byte) bool {
+	for _, sig := range sigs {
+		if bytes.Equal(sig.PubKey, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsBip32(derivations []*psbt.Bip32Derivation, pubKey []byte) bool {
+	for _, derivation := range derivations {
+		if bytes.Equal(derivation.PubKey, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTaprootBip32(derivations []*psbt.TaprootBip32Derivation, xOnly []byte) bool {
+	for _, derivation := range derivations {
+		if bytes.Equal(derivation.XOnlyPubKey, xOnly) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsScriptSpendSig(sigs []*psbt.TaprootScriptSpendSig, sig *psbt.TaprootScriptSpendSig) bool {
+	for _, existing := range sigs {
+		if bytes.Equal(existing.XOnlyPubKey, sig.XOnlyPubKey) && bytes.Equal(existing.LeafHash, sig.LeafHash) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsLeafScript(leaves []*psbt.TaprootTapLeafScript, controlBlock []byte) bool {
+	for _, leaf := range leaves {
+		if bytes.Equal(leaf.ControlBlock, controlBlock) {
+			return true
+		}
+	}
+	return false
+}
+
+func combineUnknowns(dst, src []*psbt.Unknown) []*psbt.Unknown {
+	for _, unknown := range src {
+		found := false
+		for _, existing := range dst {
+			if bytes.Equal(existing.Key, unknown.Key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, unknown)
+		}
+	}
+	return dst
+}
+
+// clone deep-copies a packet through its serialization
+func clone(packet *Packet) (*Packet, error) {
+	encoded, err := Encode(packet)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(encoded)
+}
+
+// Finalize completes every input, extracts the network transaction and, when
+// all inputs carry witness UTXOs, runs their scripts so a bad signature is
+// caught before broadcast. packet is finalized in place.
+func Finalize(packet *Packet) (*wire.MsgTx, error) {
+	if err := psbt.MaybeFinalizeAll(packet); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIncomplete, err)
+	}
+	tx, err := psbt.Extract(packet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract transaction: %w", err)
+	}
+
+	spend := &bitcoin.Spend{Tx: tx}
+	for _, in := range packet.Inputs {
+		if in.WitnessUtxo == nil {
+			return tx, nil
+		}
+		spend.PrevOuts = append(spend.PrevOuts, in.WitnessUtxo)
+	}
+	if err := spend.Verify(); err != nil {
+		return nil, fmt.Errorf("finalized transaction is invalid: %w", err)
+	}
+	return tx, nil
+}
+
+// Encode serializes a packet as base64, the usual text form of a PSBT
+func Encode(packet *Packet) (string, error) {
+	var buf bytes.Buffer
+	if err := packet.Serialize(&buf); err != nil {
+		return "", fmt.Errorf("failed to serialize psbt: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// Decode parses a PSBT given as base64, hex or raw binary
+func Decode(s string) (*Packet, error) {
+	trimmed := strings.TrimSpace(s)
+	var raw []byte
+	switch {
+	case strings.HasPrefix(s, "psbt\xff"):
+		raw = []byte(s)
+	case strings.HasPrefix(trimmed, "70736274ff"):
+		var err error
+		if raw, err = hex.DecodeString(trimmed); err != nil {
+			return nil, fmt.Errorf("invalid psbt hex: %w", err)
+		}
+	default:
+		var err error
+		if raw, err = base64.StdEncoding.DecodeString(trimmed); err != nil {
+			return nil, fmt.Errorf("invalid psbt encoding: %w", err)
+		}
+	}
+
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader(raw), false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid psbt: %w", err)
+	}
+	return packet, nil
+}
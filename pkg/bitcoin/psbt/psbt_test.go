@@ -0,0 +1,217 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+)
+
+func testKey(t *testing.T, seed byte, root []byte) *bitcoin.TaprootKey {
+	t.Helper()
+	priv, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{seed}, 32))
+	return &bitcoin.TaprootKey{PrivKey: priv, ScriptRoot: root}
+}
+
+func testUTXO(t *testing.T, n byte, value int64, key *bitcoin.TaprootKey) bitcoin.UTXO {
+	t.Helper()
+	script, err := key.PkScript()
+	if err != nil {
+		t.Fatalf("PkScript() error = %v", err)
+	}
+	hash := chainhash.DoubleHashH([]byte{n})
+	return bitcoin.UTXO{OutPoint: *wire.NewOutPoint(&hash, uint32(n)), Value: value, PkScript: script}
+}
+
+// testTreasuryPacket spends a BIP-86 output and a vault output, each held by
+// a different signer
+func testTreasuryPacket(t *testing.T) (*Packet, *bitcoin.TaprootKey, *bitcoin.TaprootKey) {
+	t.Helper()
+	hot := testKey(t, 0x01, nil)
+	vault := testKey(t, 0x02, bytes.Repeat([]byte{0xaa}, 32))
+	dest, _ := testKey(t, 0x03, nil).PkScript()
+
+	packet, err := Create(
+		[]bitcoin.UTXO{testUTXO(t, 1, 40000, hot), testUTXO(t, 2, 60000, vault)},
+		[]*wire.TxOut{wire.NewTxOut(99000, dest)},
+	)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return packet, hot, vault
+}
+
+func TestSignCombineFinalize(t *testing.T) {
+	packet, hot, vault := testTreasuryPacket(t)
+	if packet.UnsignedTx.TxIn[0].Sequence != bitcoin.RBFSequence {
+		t.Error("Expected inputs to signal replace-by-fee")
+	}
+
+	// Each signer works on its own copy
+	encoded, err := Encode(packet)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	first, _ := Decode(encoded)
+	second, _ := Decode(encoded)
+
+	if n, err := Sign(first, []*bitcoin.TaprootKey{hot}); err != nil || n != 1 {
+		t.Fatalf("Sign() = %d, %v, expected 1 input", n, err)
+	}
+	if n, err := Sign(second, []*bitcoin.TaprootKey{vault}); err != nil || n != 1 {
+		t.Fatalf("Sign() = %d, %v, expected 1 input", n, err)
+	}
+	if !bytes.Equal(second.Inputs[1].TaprootMerkleRoot, vault.ScriptRoot) ||
+		!bytes.Equal(second.Inputs[1].TaprootInternalKey, schnorr.SerializePubKey(vault.PrivKey.PubKey())) {
+		t.Error("Expected BIP-371 internal key and merkle root on the signed input")
+	}
+
+	partial, _ := Decode(encoded)
+	Sign(partial, []*bitcoin.TaprootKey{hot})
+	if _, err := Finalize(partial); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected ErrIncomplete, got %v", err)
+	}
+
+	combined, err := Combine(first, second)
+	if err != nil {
+		t.Fatalf("Combine() error = %v", err)
+	}
+	if first.Inputs[1].TaprootKeySpendSig != nil {
+		t.Error("Expected Combine to leave its arguments unmodified")
+	}
+	tx, err := Finalize(combined)
+	if err != nil {
+		t.Fatalf("Finalize() error = %v", err)
+	}
+	if len(tx.TxIn[0].Witness) != 1 || len(tx.TxIn[1].Witness) != 1 {
+		t.Error("Expected key-path witnesses on both inputs")
+	}
+
+	// A corrupted signature is caught before broadcast
+	bad, _ := Combine(first, second)
+	bad.Inputs[0].TaprootKeySpendSig[10] ^= 0xff
+	if _, err := Finalize(bad); err == nil {
+		t.Error("Expected Finalize to reject an invalid signature")
+	}
+}
+
+func TestCombineMismatch(t *testing.T) {
+	packet, hot, _ := testTreasuryPacket(t)
+	other, err := Create([]bitcoin.UTXO{testUTXO(t, 9, 1000, hot)}, packet.UnsignedTx.TxOut)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := Combine(packet, other); !errors.Is(err, ErrTxMismatch) {
+		t.Errorf("Expected ErrTxMismatch, got %v", err)
+	}
+	if _, err := Combine(); err == nil {
+		t.Error("Expected Combine() with no packets to fail")
+	}
+}
+
+func TestTaprootUpdater(t *testing.T) {
+	packet, hot, vault := testTreasuryPacket(t)
+	vaultPub := vault.PrivKey.PubKey()
+
+	if err := AddTaprootInput(packet, 1, vaultPub, vault.ScriptRoot); err != nil {
+		t.Fatalf("AddTaprootInput() error = %v", err)
+	}
+	if err := AddTaprootInput(packet, 0, vaultPub, nil); err == nil {
+		t.Error("Expected a key that does not match the input to be rejected")
+	}
+	if err := AddTaprootInput(packet, 5, vaultPub, nil); err == nil {
+		t.Error("Expected an out of range input to be rejected")
+	}
+	path := []uint32{86 + 0x80000000, 0x80000000, 0x80000000, 0, 0}
+	if err := AddTaprootDerivation(packet, 0, hot.PrivKey.PubKey(), 0xdeadbeef, path); err != nil {
+		t.Fatalf("AddTaprootDerivation() error = %v", err)
+	}
+
+	dest := testKey(t, 0x03, nil)
+	if err := AddTaprootOutput(packet, 0, dest.PrivKey.PubKey(), nil); err != nil {
+		t.Fatalf("AddTaprootOutput() error = %v", err)
+	}
+	if err := AddTaprootOutput(packet, 0, vaultPub, nil); err == nil {
+		t.Error("Expected a key that does not match the output to be rejected")
+	}
+
+	// The BIP-371 fields survive serialization
+	encoded, err := Encode(packet)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !bytes.Equal(decoded.Inputs[1].TaprootMerkleRoot, vault.ScriptRoot) {
+		t.Error("Merkle root lost in serialization")
+	}
+	derivations := decoded.Inputs[0].TaprootBip32Derivation
+	if len(derivations) != 1 || derivations[0].MasterKeyFingerprint != 0xdeadbeef || len(derivations[0].Bip32Path) != 5 {
+		t.Errorf("Unexpected derivations: %+v", derivations)
+	}
+	if len(decoded.Outputs[0].TaprootInternalKey) != 32 {
+		t.Error("Output internal key lost in serialization")
+	}
+}
+
+func TestFromSpend(t *testing.T) {
+	key := testKey(t, 0x04, nil)
+	script, _ := key.PkScript()
+	spend, err := bitcoin.BuildTaprootSpend([]bitcoin.UTXO{testUTXO(t, 1, 50000, key)},
+		[]*wire.TxOut{wire.NewTxOut(20000, script)}, script, 2)
+	if err != nil {
+		t.Fatalf("BuildTaprootSpend() error = %v", err)
+	}
+
+	packet, err := FromSpend(spend)
+	if err != nil {
+		t.Fatalf("FromSpend() error = %v", err)
+	}
+	if packet.UnsignedTx.TxHash() != spend.Tx.TxHash() || packet.Inputs[0].WitnessUtxo.Value != 50000 {
+		t.Fatal("Packet does not match the spend")
+	}
+	Sign(packet, []*bitcoin.TaprootKey{key})
+	tx, err := Finalize(packet)
+	if err != nil {
+		t.Fatalf("Finalize() error = %v", err)
+	}
+	if tx.TxHash() != spend.Tx.TxHash() {
+		t.Error("Finalized transaction differs from the spend")
+	}
+}
+
+func TestDecodeFormats(t *testing.T) {
+	packet, _, _ := testTreasuryPacket(t)
+	var raw bytes.Buffer
+	if err := packet.Serialize(&raw); err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	encoded, _ := Encode(packet)
+
+	for name, input := range map[string]string{
+		"base64": encoded + "\n",
+		"hex":    hex.EncodeToString(raw.Bytes()),
+		"raw":    raw.String(),
+	} {
+		decoded, err := Decode(input)
+		if err != nil {
+			t.Errorf("Decode(%s) error = %v", name, err)
+			continue
+		}
+		if decoded.UnsignedTx.TxHash() != packet.UnsignedTx.TxHash() {
+			t.Errorf("Decode(%s) returned a different transaction", name)
+		}
+	}
+	if _, err := Decode("not a psbt"); err == nil {
+		t.Error("Expected invalid input to fail")
+	}
+}
@@ -44,6 +44,10 @@ func BuildCLTVScript(lockHeight uint32, pubKeyHash []byte) (*CLTVScript, error)
 	if lockHeightBytes[3] == 0 || lockHeightBytes[2] == 0 || lockHeightBytes[1] == 0 {
 		trimmedHeight = trimTrailingZeros(lockHeightBytes)
 	}
+	// Script numbers are signed: a set top bit needs a zero sign byte
+	if trimmedHeight[len(trimmedHeight)-1]&0x80 != 0 {
+		trimmedHeight = append(trimmedHeight, 0)
+	}
 	
 	builder.AddData(trimmedHeight)
 	builder.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
@@ -99,6 +103,10 @@ func ValidateCLTVScript(script []byte) (lockHeight uint32, err error) {
 	}
 	
 	heightData := tokenizer.Data()
+	if txscript.IsSmallInt(tokenizer.Opcode()) {
+		// Heights 1 to 16 are pushed as OP_1 to OP_16
+		heightData = []byte{byte(txscript.AsSmallInt(tokenizer.Opcode()))}
+	}
 	if len(heightData) == 0 || len(heightData) > 4 {
 		return 0, fmt.Errorf("invalid lock height data length: %d", len(heightData))
 	}
@@ -92,6 +92,21 @@ func TestValidateCLTVScript(t *testing.T) {
 		t.Errorf("ValidateCLTVScript() lockHeight = %d, want 4320", lockHeight)
 	}
 
+	// Heights whose top bit is set need a sign byte to stay positive
+	for _, height := range []uint32{1, 16, 17, 128, 32768, 0x800000, 499_999_999} {
+		cltv, err := BuildCLTVScript(height, pubKeyHash)
+		if err != nil {
+			t.Fatalf("BuildCLTVScript(%d) error = %v", height, err)
+		}
+		if got, err := ValidateCLTVScript(cltv.Script); err != nil || got != height {
+			t.Errorf("ValidateCLTVScript() of height %d = %d, %v", height, got, err)
+		}
+	}
+	cltv, _ = BuildCLTVScript(32768, pubKeyHash)
+	if !bytes.HasPrefix(cltv.Script, []byte{txscript.OP_DATA_3, 0x00, 0x80, 0x00}) {
+		t.Errorf("Height 32768 encoded as %x", cltv.Script[:4])
+	}
+
 	// Test invalid script
 	invalidScript := []byte{txscript.OP_TRUE}
 	_, err = ValidateCLTVScript(invalidScript)
@@ -7,7 +7,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
@@ -17,10 +16,16 @@ import (
 type SPVClient struct {
 	network       *chaincfg.Params
 	headers       map[chainhash.Hash]*wire.BlockHeader
+	heights       map[chainhash.Hash]int32
+	mainChain     []chainhash.Hash
 	headersMu     sync.RWMutex
 	bestHeight    int32
 	bestHash      *chainhash.Hash
-	filterHeaders map[chainhash.Hash][]byte
+	filterHeaders map[chainhash.Hash]chainhash.Hash
+	checkpoints   map[int32]chainhash.Hash
+	watched       map[string]watchedScript
+	watchMu       sync.RWMutex
+	filterMatches chan FilterMatch
 	peers         []*Peer
 	peersMu       sync.RWMutex
 	ctx           context.Context
@@ -63,7 +68,11 @@ func NewSPVClient(network *chaincfg.Params) *SPVClient {
 	return &SPVClient{
 		network:       network,
 		headers:       make(map[chainhash.Hash]*wire.BlockHeader),
-		filterHeaders: make(map[chainhash.Hash][]byte),
+		heights:       make(map[chainhash.Hash]int32),
+		filterHeaders: make(map[chainhash.Hash]chainhash.Hash),
+		checkpoints:   make(map[int32]chainhash.Hash),
+		watched:       make(map[string]watchedScript),
+		filterMatches: make(chan FilterMatch, filterMatchBuffer),
 		peers:         make([]*Peer, 0),
 		ctx:           ctx,
 		cancel:        cancel,
@@ -78,6 +87,8 @@ func (s *SPVClient) Start() error {
 	
 	s.headersMu.Lock()
 	s.headers[*genesisHash] = genesisHeader
+	s.heights[*genesisHash] = 0
+	s.mainChain = []chainhash.Hash{*genesisHash}
 	s.bestHash = genesisHash
 	s.bestHeight = 0
 	s.headersMu.Unlock()
@@ -152,6 +163,7 @@ func (s *SPVClient) GetBlockHeader(hash chainhash.Hash) (*BlockHeaderInfo, error
 
 	info := &BlockHeaderInfo{
 		Hash:       hash,
+		Height:     s.heights[hash],
 		PrevBlock:  header.PrevBlock,
 		MerkleRoot: header.MerkleRoot,
 		Timestamp:  header.Timestamp,
@@ -162,6 +174,19 @@ func (s *SPVClient) GetBlockHeader(hash chainhash.Hash) (*BlockHeaderInfo, error
 	return info, nil
 }
 
+// GetBlockHeaderByHeight retrieves a best-chain block header by height
+func (s *SPVClient) GetBlockHeaderByHeight(height int32) (*BlockHeaderInfo, error) {
+	s.headersMu.RLock()
+	if height < 0 || int(height) >= len(s.mainChain) {
+		s.headersMu.RUnlock()
+		return nil, fmt.Errorf("block header not found at height %d", height)
+	}
+	hash := s.mainChain[height]
+	s.headersMu.RUnlock()
+
+	return s.GetBlockHeader(hash)
+}
+
 // VerifyTransaction verifies if a transaction is included in a block
 func (s *SPVClient) VerifyTransaction(txHash chainhash.Hash, blockHash chainhash.Hash, merkleProof []chainhash.Hash) (bool, error) {
 	// Get block header
@@ -197,19 +222,6 @@ func (s *SPVClient) GetTransactionProof(txHash chainhash.Hash, blockHash chainha
 	return proof, nil
 }
 
-// WatchAddress watches for transactions to/from an address
-func (s *SPVClient) WatchAddress(address btcutil.Address) error {
-	// This would set up a BIP 157/158 compact block filter
-	// to watch for transactions involving this address
-	
-	// For now, just validate the address
-	if address.String() == "" {
-		return errors.New("invalid address")
-	}
-
-	return nil
-}
-
 // GetPeerCount returns the number of connected peers
 func (s *SPVClient) GetPeerCount() int {
 	s.peersMu.RLock()
@@ -310,16 +322,32 @@ func (s *SPVClient) AddBlockHeader(header *wire.BlockHeader) error {
 
 	// Store the header
 	s.headers[blockHash] = header
+	if parentHeight, ok := s.heights[header.PrevBlock]; ok {
+		s.heights[blockHash] = parentHeight + 1
+	}
 
 	// Update best block if this extends the chain
 	if header.PrevBlock == *s.bestHash {
 		s.bestHash = &blockHash
 		s.bestHeight++
+		s.mainChain = append(s.mainChain, blockHash)
 	}
 
 	return nil
 }
 
+// GetPeers returns a copy of the known peers
+func (s *SPVClient) GetPeers() []Peer {
+	s.peersMu.RLock()
+	defer s.peersMu.RUnlock()
+
+	peers := make([]Peer, len(s.peers))
+	for i, peer := range s.peers {
+		peers[i] = *peer
+	}
+	return peers
+}
+
 // GetNetworkName returns the network name
 func (s *SPVClient) GetNetworkName() string {
 	return s.network.Name
@@ -193,3 +193,40 @@ func TestSPVClientStop(t *testing.T) {
 		t.Fatalf("Failed to stop SPV client: %v", err)
 	}
 }
+
+func TestSPVClientGetBlockHeaderByHeight(t *testing.T) {
+	client := NewSPVClient(&chaincfg.RegressionNetParams)
+	client.Start()
+	defer client.Stop()
+
+	prev := *chaincfg.RegressionNetParams.GenesisHash
+	for i := 0; i < 3; i++ {
+		header := &wire.BlockHeader{
+			Version:   1,
+			PrevBlock: prev,
+			Timestamp: time.Unix(int64(1700000000+i), 0),
+			Bits:      0x207fffff,
+		}
+		if err := client.AddBlockHeader(header); err != nil {
+			t.Fatalf("Failed to add block header: %v", err)
+		}
+		prev = header.BlockHash()
+	}
+
+	info, err := client.GetBlockHeaderByHeight(3)
+	if err != nil {
+		t.Fatalf("GetBlockHeaderByHeight failed: %v", err)
+	}
+	if info.Hash != prev || info.Height != 3 {
+		t.Errorf("Expected tip %s at height 3, got %s at %d", prev, info.Hash, info.Height)
+	}
+
+	genesis, err := client.GetBlockHeaderByHeight(0)
+	if err != nil || genesis.Hash != *chaincfg.RegressionNetParams.GenesisHash {
+		t.Errorf("Expected genesis at height 0, got %v (%v)", genesis, err)
+	}
+
+	if _, err := client.GetBlockHeaderByHeight(4); err == nil {
+		t.Error("Expected error beyond best height")
+	}
+}
@@ -10,15 +10,17 @@ import (
 	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
+	"golang.org/x/text/unicode/norm"
 )
 
 // TaprootVault represents a Taproot (P2TR) vault with unique, un-linkable properties
 type TaprootVault struct {
-	InternalKey  *btcec.PublicKey
-	OutputKey    *btcec.PublicKey
-	TweakHash    []byte
-	Address      string
-	ProphecyHash []byte // 13-word prophecy axiom hash
+	InternalPrivKey *btcec.PrivateKey // spends the vault by key path with TweakHash
+	InternalKey     *btcec.PublicKey
+	OutputKey       *btcec.PublicKey
+	TweakHash       []byte
+	Address         string
+	ProphecyHash    []byte // 13-word prophecy axiom hash
 }
 
 // GenerateTaprootVault creates a unique Taproot vault using the 13-word prophecy axiom
@@ -27,19 +29,38 @@ func GenerateTaprootVault(prophecyWords []string, network *chaincfg.Params) (*Ta
 		return nil, errors.New("prophecy axiom must contain exactly 13 words")
 	}
 
-	// Create prophecy hash from 13 words
-	prophecyData := ""
-	for _, word := range prophecyWords {
-		prophecyData += word
-	}
-	prophecyHash := sha256.Sum256([]byte(prophecyData))
-
 	// Generate internal key from prophecy
 	privKey, err := btcec.NewPrivateKey()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate private key: %w", err)
 	}
-	internalKey := privKey.PubKey()
+	return NewTaprootVault(privKey, prophecyWords, network)
+}
+
+// NewTaprootVault builds the vault of the 13-word prophecy axiom on a given
+// internal key, so the same key and prophecy always give the same address
+func NewTaprootVault(privKey *btcec.PrivateKey, prophecyWords []string, network *chaincfg.Params) (*TaprootVault, error) {
+	vault, err := NewTaprootVaultForKey(privKey.PubKey(), prophecyWords, network)
+	if err != nil {
+		return nil, err
+	}
+	vault.InternalPrivKey = privKey
+	return vault, nil
+}
+
+// NewTaprootVaultForKey builds the vault on an internal key held elsewhere,
+// such as on a hardware wallet. The vault has no InternalPrivKey.
+func NewTaprootVaultForKey(internalKey *btcec.PublicKey, prophecyWords []string, network *chaincfg.Params) (*TaprootVault, error) {
+	if len(prophecyWords) != 13 {
+		return nil, errors.New("prophecy axiom must contain exactly 13 words")
+	}
+
+	// Create prophecy hash from 13 NFKD-normalized words
+	prophecyData := ""
+	for _, word := range prophecyWords {
+		prophecyData += norm.NFKD.String(word)
+	}
+	prophecyHash := sha256.Sum256([]byte(prophecyData))
 
 	// Create taproot tweak using prophecy hash
 	tweak := sha256.Sum256(append(schnorr.SerializePubKey(internalKey), prophecyHash[:]...))
@@ -62,6 +83,11 @@ func GenerateTaprootVault(prophecyWords []string, network *chaincfg.Params) (*Ta
 	}, nil
 }
 
+// SpendKey returns the key that spends the vault's outputs by key path
+func (v *TaprootVault) SpendKey() *TaprootKey {
+	return &TaprootKey{PrivKey: v.InternalPrivKey, ScriptRoot: v.TweakHash}
+}
+
 // EncodeBech32m encodes a Taproot output key as a Bech32m address
 func EncodeBech32m(pubkey []byte, network *chaincfg.Params) (string, error) {
 	// Taproot uses witness version 1
@@ -134,3 +160,24 @@ func VerifyTaprootAddress(address string) bool {
 	// Taproot addresses should have witness version 1 and 32-byte program
 	return witnessVersion == 1 && len(program) == 32
 }
+
+// DeriveTaprootAddress returns the BIP-86 key-path-only Taproot address for a
+// 33-byte compressed or 32-byte x-only internal public key
+func DeriveTaprootAddress(pubKey []byte, network *chaincfg.Params) (string, error) {
+	var internalKey *btcec.PublicKey
+	var err error
+	switch len(pubKey) {
+	case 32:
+		internalKey, err = schnorr.ParsePubKey(pubKey)
+	case 33:
+		internalKey, err = btcec.ParsePubKey(pubKey)
+	default:
+		return "", fmt.Errorf("invalid public key length %d", len(pubKey))
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+
+	outputKey := txscript.ComputeTaprootKeyNoScript(internalKey)
+	return EncodeBech32m(schnorr.SerializePubKey(outputKey), network)
+}
@@ -1,6 +1,8 @@
 package bitcoin
 
 import (
+	"encoding/hex"
+	"strings"
 	"testing"
 
 	"github.com/btcsuite/btcd/chaincfg"
@@ -161,3 +163,40 @@ func TestEncodeBech32m(t *testing.T) {
 		t.Error("Generated address should be valid")
 	}
 }
+
+func TestDeriveTaprootAddress(t *testing.T) {
+	// BIP-86 test vector m/86'/0'/0'/0/0
+	internalKey := "cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115"
+	expected := "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"
+
+	tests := []struct {
+		name    string
+		pubKey  string
+		wantErr bool
+	}{
+		{"x-only", internalKey, false},
+		{"compressed even", "02" + internalKey, false},
+		{"compressed odd", "03" + internalKey, false},
+		{"wrong length", internalKey[:62], true},
+		{"not on curve", "02" + strings.Repeat("ff", 32), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pubKey, _ := hex.DecodeString(tt.pubKey)
+			address, err := DeriveTaprootAddress(pubKey, &chaincfg.MainNetParams)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if address != expected {
+				t.Errorf("Expected %s, got %s", expected, address)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Size estimates in virtual bytes for Taproot spends
+const (
+	txOverheadVSize = 11 // version, locktime, counts and segwit marker
+	outputBaseVSize = 9  // value and script length
+
+	// TaprootKeySpendInputVSize is the size of a key-path input: outpoint,
+	// sequence and a 64-byte signature witness
+	TaprootKeySpendInputVSize = 58
+)
+
+// DustLimit is the smallest output value, in satoshis, BuildTaprootSpend will
+// create. Smaller change is left to the fee.
+const DustLimit = 546
+
+// RBFSequence signals BIP-125 replace-by-fee
+const RBFSequence = wire.MaxTxInSequenceNum - 2
+
+var (
+	// ErrInsufficientFunds indicates the UTXOs cannot cover outputs and fee
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrNotTaproot indicates a UTXO that cannot be spent by Taproot key path
+	ErrNotTaproot = errors.New("not a taproot output")
+	// ErrNoSigningKey indicates no key controls an input
+	ErrNoSigningKey = errors.New("no key for input")
+)
+
+// UTXO is a spendable output
+type UTXO struct {
+	OutPoint wire.OutPoint
+	Value    int64
+	PkScript []byte
+}
+
+// TaprootKey is a private key that spends P2TR outputs by key path. The
+// output key commits to ScriptRoot, which is nil for BIP-86 keys and the
+// vault tweak for TaprootVault keys. A key held in a keystore has a Signer
+// in place of PrivKey.
+type TaprootKey struct {
+	PrivKey    *btcec.PrivateKey
+	Signer     TaprootSigner
+	ScriptRoot []byte
+}
+
+// TaprootSigner signs with a private key the process does not hold, such
+// as a keystore.Signer
+type TaprootSigner interface {
+	PubKey() *btcec.PublicKey
+	// SignTaproot signs sighash with the key tweaked by BIP-341 to commit
+	// to scriptRoot, nil for a BIP-86 key
+	SignTaproot(sighash, scriptRoot []byte) (*schnorr.Signature, error)
+}
+
+// ParseTaprootKey parses a WIF private key for net, optionally followed by
+// ":" and a hex script root, e.g. the TweakHash of a vault
+func ParseTaprootKey(s string, net *chaincfg.Params) (*TaprootKey, error) {
+	encoded, rootHex, hasRoot := strings.Cut(strings.TrimSpace(s), ":")
+	wif, err := btcutil.DecodeWIF(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	if !wif.IsForNet(net) {
+		return nil, fmt.Errorf("private key is not for %s", net.Name)
+	}
+
+	key := &TaprootKey{PrivKey: wif.PrivKey}
+	if hasRoot {
+		if key.ScriptRoot, err = hex.DecodeString(rootHex); err != nil || len(key.ScriptRoot) != 32 {
+			return nil, fmt.Errorf("invalid script root %q", rootHex)
+		}
+	}
+	return key, nil
+}
+
+// Encode returns the key in the form ParseTaprootKey reads
+func (k *TaprootKey) Encode(net *chaincfg.Params) (string, error) {
+	if k.PrivKey == nil {
+		return "", errors.New("the private key is held by a signer")
+	}
+	wif, err := btcutil.NewWIF(k.PrivKey, net, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+	if k.ScriptRoot == nil {
+		return wif.String(), nil
+	}
+	return wif.String() + ":" + hex.EncodeToString(k.ScriptRoot), nil
+}
+
+// InternalKey returns the untweaked public key
+func (k *TaprootKey) InternalKey() *btcec.PublicKey {
+	if k.Signer != nil {
+		return k.Signer.PubKey()
+	}
+	return k.PrivKey.PubKey()
+}
+
+// OutputKey returns the tweaked key the output commits to
+func (k *TaprootKey) OutputKey() *btcec.PublicKey {
+	if k.ScriptRoot == nil {
+		return txscript.ComputeTaprootKeyNoScript(k.InternalKey())
+	}
+	return txscript.ComputeTaprootOutputKey(k.InternalKey(), k.ScriptRoot)
+}
+
+// PkScript returns the P2TR output script the key spends
+func (k *TaprootKey) PkScript() ([]byte, error) {
+	return txscript.PayToTaprootScript(k.OutputKey())
+}
+
+// sign signs sighash with the private key tweaked to match OutputKey
+func (k *TaprootKey) sign(sighash []byte) (*schnorr.Signature, error) {
+	if k.Signer != nil {
+		return k.Signer.SignTaproot(sighash, k.ScriptRoot)
+	}
+	root := k.ScriptRoot
+	if root == nil {
+		root = []byte{}
+	}
+	return schnorr.Sign(txscript.TweakTaprootPrivKey(*k.PrivKey, root), sighash)
+}
+
+// Spend is an unsigned or signed Taproot transaction
+type Spend struct {
+	Tx       *wire.MsgTx
+	PrevOuts []*wire.TxOut // spent outputs, in input order
+	Fee      int64
+	Change   int64 // zero when change was dust and left to the fee
+}
+
+// EstimateTaprootVSize returns the virtual size of a transaction spending
+// inputs key-path P2TR outputs into outputs
+func EstimateTaprootVSize(inputs int, outputs []*wire.TxOut) int64 {
+	vsize := int64(txOverheadVSize + inputs*TaprootKeySpendInputVSize)
+	for _, out := range outputs {
+		vsize += int64(outputBaseVSize + len(out.PkScript))
+	}
+	return vsize
+}
+
+// BuildTaprootSpend selects P2TR UTXOs, largest first, to pay outputs at
+// feeRate sat/vB and adds change to changeScript unless it would be dust.
+// Inputs signal replace-by-fee.
+func BuildTaprootSpend(utxos []UTXO, outputs []*wire.TxOut, changeScript []byte, feeRate int64) (*Spend, error) {
+	return BuildTaprootSpendWith(nil, utxos, outputs, changeScript, feeRate)
+}
+
+// BuildTaprootSpendWith is BuildTaprootSpend spending every required UTXO
+// before it selects from utxos, such as the inputs of a transaction the
+// spend must conflict with
+func BuildTaprootSpendWith(required, utxos []UTXO, outputs []*wire.TxOut, changeScript []byte, feeRate int64) (*Spend, error) {
+	if len(outputs) == 0 {
+		return nil, errors.New("no outputs")
+	}
+	if feeRate <= 0 {
+		return nil, fmt.Errorf("invalid fee rate %d", feeRate)
+	}
+
+	tx := wire.NewMsgTx(2)
+	var outputTotal int64
+	for _, out := range outputs {
+		tx.AddTxOut(out)
+		outputTotal += out.Value
+	}
+
+	candidates := make([]UTXO, len(utxos))
+	copy(candidates, utxos)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Value > candidates[j].Value
+	})
+	candidates = append(required[:len(required):len(required)], candidates...)
+
+	spend := &Spend{Tx: tx}
+	var inputTotal int64
+	for _, utxo := range candidates {
+		if !txscript.IsPayToTaproot(utxo.PkScript) {
+			return nil, fmt.Errorf("%w: %s", ErrNotTaproot, utxo.OutPoint)
+		}
+		in := wire.NewTxIn(&utxo.OutPoint, nil, nil)
+		in.Sequence = RBFSequence
+		tx.AddTxIn(in)
+		spend.PrevOuts = append(spend.PrevOuts, wire.NewTxOut(utxo.Value, utxo.PkScript))
+		inputTotal += utxo.Value
+
+		if len(tx.TxIn) >= len(required) && inputTotal >= outputTotal+EstimateTaprootVSize(len(tx.TxIn), outputs)*feeRate {
+			break
+		}
+	}
+
+	fee := EstimateTaprootVSize(len(tx.TxIn), outputs) * feeRate
+	if inputTotal < outputTotal+fee {
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientFunds, inputTotal, outputTotal+fee)
+	}
+
+	change := wire.NewTxOut(0, changeScript)
+	withChange := EstimateTaprootVSize(len(tx.TxIn), append(outputs[:len(outputs):len(outputs)], change)) * feeRate
+	if change.Value = inputTotal - outputTotal - withChange; change.Value >= DustLimit {
+		tx.AddTxOut(change)
+		spend.Change = change.Value
+		spend.Fee = withChange
+	} else {
+		spend.Fee = inputTotal - outputTotal
+	}
+	return spend, nil
+}
+
+// prevOutFetcher indexes the spent outputs of tx for sighash computation
+func prevOutFetcher(tx *wire.MsgTx, prevOuts []*wire.TxOut) (*txscript.MultiPrevOutFetcher, error) {
+	if len(prevOuts) != len(tx.TxIn) {
+		return nil, fmt.Errorf("have %d spent outputs for %d inputs", len(prevOuts), len(tx.TxIn))
+	}
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, in := range tx.TxIn {
+		if prevOuts[i] == nil {
+			return nil, fmt.Errorf("missing spent output for input %d", i)
+		}
+		fetcher.AddPrevOut(in.PreviousOutPoint, prevOuts[i])
+	}
+	return fetcher, nil
+}
+
+// TaprootSighash computes the BIP-341 signature hash of input idx.
+// prevOuts holds the output spent by every input, in input order.
+func TaprootSighash(tx *wire.MsgTx, idx int, prevOuts []*wire.TxOut, hashType txscript.SigHashType) ([]byte, error) {
+	fetcher, err := prevOutFetcher(tx, prevOuts)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.CalcTaprootSignatureHash(txscript.NewTxSigHashes(tx, fetcher), hashType, tx, idx, fetcher)
+}
+
+// SignTaprootInput returns a BIP-340 Schnorr signature spending input idx by
+// key path. The sighash type is appended unless it is SigHashDefault.
+func SignTaprootInput(tx *wire.MsgTx, idx int, prevOuts []*wire.TxOut, key *TaprootKey, hashType txscript.SigHashType) ([]byte, error) {
+	if idx < 0 || idx >= len(tx.TxIn) {
+		return nil, fmt.Errorf("input %d out of range", idx)
+	}
+	pkScript, err := key.PkScript()
+	if err != nil {
+		return nil, err
+	}
+	if len(prevOuts) > idx && prevOuts[idx] != nil && !bytes.Equal(prevOuts[idx].PkScript, pkScript) {
+		return nil, fmt.Errorf("%w %d", ErrNoSigningKey, idx)
+	}
+
+	sighash, err := TaprootSighash(tx, idx, prevOuts, hashType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute sighash: %w", err)
+	}
+	sig, err := key.sign(sighash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign input %d: %w", idx, err)
+	}
+
+	serialized := sig.Serialize()
+	if hashType != txscript.SigHashDefault {
+		serialized = append(serialized, byte(hashType))
+	}
+	return serialized, nil
+}
+
+// FindTaprootKey returns the key among keys that spends pkScript
+func FindTaprootKey(pkScript []byte, keys []*TaprootKey) *TaprootKey {
+	for _, key := range keys {
+		if script, err := key.PkScript(); err == nil && bytes.Equal(script, pkScript) {
+			return key
+		}
+	}
+	return nil
+}
+
+// Sign signs every input by key path with the matching key, setting the
+// witnesses
+func (s *Spend) Sign(keys []*TaprootKey) error {
+	for i, prevOut := range s.PrevOuts {
+		key := FindTaprootKey(prevOut.PkScript, keys)
+		if key == nil {
+			return fmt.Errorf("%w %d (%s)", ErrNoSigningKey, i, s.Tx.TxIn[i].PreviousOutPoint)
+		}
+		sig, err := SignTaprootInput(s.Tx, i, s.PrevOuts, key, txscript.SigHashDefault)
+		if err != nil {
+			return err
+		}
+		s.Tx.TxIn[i].Witness = wire.TxWitness{sig}
+	}
+	return nil
+}
+
+// Verify executes the scripts of every input, catching bad signatures before
+// broadcast
+func (s *Spend) Verify() error {
+	fetcher, err := prevOutFetcher(s.Tx, s.PrevOuts)
+	if err != nil {
+		return err
+	}
+	sigHashes := txscript.NewTxSigHashes(s.Tx, fetcher)
+	for i, prevOut := range s.PrevOuts {
+		engine, err := txscript.NewEngine(prevOut.PkScript, s.Tx, i, txscript.StandardVerifyFlags,
+			nil, sigHashes, prevOut.Value, fetcher)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+		if err := engine.Execute(); err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Hex serializes the transaction, with witnesses, as raw hex
+func (s *Spend) Hex() (string, error) {
+	return SerializeTx(s.Tx)
+}
+
+// SerializeTx serializes a transaction, with witnesses, as raw hex
+func SerializeTx(tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", fmt.Errorf("failed to serialize transaction: %w", err)
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
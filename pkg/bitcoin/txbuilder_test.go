@@ -0,0 +1,192 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var testProphecy = []string{
+	"sword", "legend", "pull", "magic", "kingdom", "artist",
+	"stone", "destroy", "forget", "fire", "steel", "honey", "question",
+}
+
+func testUTXO(t *testing.T, n byte, value int64, pkScript []byte) UTXO {
+	t.Helper()
+	var hash chainhash.Hash
+	hash[0] = n
+	return UTXO{OutPoint: *wire.NewOutPoint(&hash, uint32(n)), Value: value, PkScript: pkScript}
+}
+
+func TestTaprootKey(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	priv, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() error = %v", err)
+	}
+	wif, err := btcutil.NewWIF(priv, net, true)
+	if err != nil {
+		t.Fatalf("NewWIF() error = %v", err)
+	}
+
+	// A bare WIF is a BIP-86 key
+	key, err := ParseTaprootKey(wif.String(), net)
+	if err != nil {
+		t.Fatalf("ParseTaprootKey() error = %v", err)
+	}
+	address, err := DeriveTaprootAddress(priv.PubKey().SerializeCompressed(), net)
+	if err != nil {
+		t.Fatalf("DeriveTaprootAddress() error = %v", err)
+	}
+	decoded, _ := btcutil.DecodeAddress(address, net)
+	expected, _ := txscript.PayToAddrScript(decoded)
+	if script, _ := key.PkScript(); hex.EncodeToString(script) != hex.EncodeToString(expected) {
+		t.Errorf("BIP-86 key script %x does not match address %s", script, address)
+	}
+
+	// A vault key carries the vault tweak as its script root
+	vault, err := GenerateTaprootVault(testProphecy, net)
+	if err != nil {
+		t.Fatalf("GenerateTaprootVault() error = %v", err)
+	}
+	vaultWIF, _ := btcutil.NewWIF(vault.InternalPrivKey, net, true)
+	vaultKey, err := ParseTaprootKey(vaultWIF.String()+":"+hex.EncodeToString(vault.TweakHash), net)
+	if err != nil {
+		t.Fatalf("ParseTaprootKey() error = %v", err)
+	}
+	if !vaultKey.OutputKey().IsEqual(vault.OutputKey) {
+		t.Error("Vault key does not match the vault output key")
+	}
+	if encoded, err := vault.SpendKey().Encode(net); err != nil || encoded != vaultWIF.String()+":"+hex.EncodeToString(vault.TweakHash) {
+		t.Errorf("Encode() = %q, %v", encoded, err)
+	}
+
+	for _, bad := range []string{"notakey", wif.String() + ":zz", wif.String() + ":abcd"} {
+		if _, err := ParseTaprootKey(bad, net); err == nil {
+			t.Errorf("Expected ParseTaprootKey(%q) to fail", bad)
+		}
+	}
+	if _, err := ParseTaprootKey(wif.String(), &chaincfg.MainNetParams); err == nil {
+		t.Error("Expected a regtest key to be rejected on mainnet")
+	}
+}
+
+func TestBuildTaprootSpend(t *testing.T) {
+	vault, err := GenerateTaprootVault(testProphecy, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("GenerateTaprootVault() error = %v", err)
+	}
+	key := vault.SpendKey()
+	vaultScript, _ := key.PkScript()
+
+	dest, _ := btcec.NewPrivateKey()
+	destScript, _ := (&TaprootKey{PrivKey: dest}).PkScript()
+	utxos := []UTXO{
+		testUTXO(t, 1, 30000, vaultScript),
+		testUTXO(t, 2, 80000, vaultScript),
+		testUTXO(t, 3, 50000, vaultScript),
+	}
+	outputs := []*wire.TxOut{wire.NewTxOut(100000, destScript)}
+
+	spend, err := BuildTaprootSpend(utxos, outputs, vaultScript, 5)
+	if err != nil {
+		t.Fatalf("BuildTaprootSpend() error = %v", err)
+	}
+	// Largest first: 80000 + 50000 covers 100000 plus fee
+	if len(spend.Tx.TxIn) != 2 || spend.PrevOuts[0].Value != 80000 {
+		t.Fatalf("Unexpected selection: %d inputs, first %d", len(spend.Tx.TxIn), spend.PrevOuts[0].Value)
+	}
+	if spend.Tx.TxIn[0].Sequence != RBFSequence {
+		t.Error("Expected inputs to signal replace-by-fee")
+	}
+	// Required inputs are spent before the largest
+	with, err := BuildTaprootSpendWith(utxos[:1], utxos[1:], outputs, vaultScript, 5)
+	if err != nil {
+		t.Fatalf("BuildTaprootSpendWith() error = %v", err)
+	}
+	if len(with.Tx.TxIn) != 2 || with.Tx.TxIn[0].PreviousOutPoint != utxos[0].OutPoint || with.PrevOuts[1].Value != 80000 {
+		t.Errorf("Unexpected selection with a required input: %d inputs, first %v", len(with.Tx.TxIn), with.Tx.TxIn[0].PreviousOutPoint)
+	}
+	if got := 130000 - 100000 - spend.Change; got != spend.Fee {
+		t.Errorf("Fee %d does not balance, expected %d", spend.Fee, got)
+	}
+
+	if err := spend.Sign([]*TaprootKey{key}); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := spend.Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	// The estimate covers the real size, and is exact for key-path witnesses
+	if vsize := (spend.Tx.SerializeSizeStripped()*3 + spend.Tx.SerializeSize() + 3) / 4; int64(vsize) > EstimateTaprootVSize(2, spend.Tx.TxOut) {
+		t.Errorf("Actual vsize %d exceeds estimate %d", vsize, EstimateTaprootVSize(2, spend.Tx.TxOut))
+	}
+	if rawHex, err := spend.Hex(); err != nil || len(rawHex) != spend.Tx.SerializeSize()*2 {
+		t.Errorf("Hex() = %d chars, %v", len(rawHex), err)
+	}
+
+	// Changing the transaction after signing invalidates the signatures
+	spend.Tx.TxOut[0].Value--
+	if err := spend.Verify(); err == nil {
+		t.Error("Expected tampered transaction to fail verification")
+	}
+}
+
+func TestBuildTaprootSpendErrors(t *testing.T) {
+	key := &TaprootKey{}
+	key.PrivKey, _ = btcec.NewPrivateKey()
+	script, _ := key.PkScript()
+	outputs := []*wire.TxOut{wire.NewTxOut(10000, script)}
+
+	if _, err := BuildTaprootSpend([]UTXO{testUTXO(t, 1, 10500, script)}, outputs, script, 10); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+	wpkh := []byte{txscript.OP_0, txscript.OP_DATA_20, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+	if _, err := BuildTaprootSpend([]UTXO{testUTXO(t, 1, 50000, wpkh)}, outputs, script, 1); !errors.Is(err, ErrNotTaproot) {
+		t.Errorf("Expected ErrNotTaproot, got %v", err)
+	}
+
+	// Change below the dust limit goes to the fee
+	spend, err := BuildTaprootSpend([]UTXO{testUTXO(t, 1, 10500, script)}, outputs, script, 1)
+	if err != nil {
+		t.Fatalf("BuildTaprootSpend() error = %v", err)
+	}
+	if spend.Change != 0 || spend.Fee != 500 || len(spend.Tx.TxOut) != 1 {
+		t.Errorf("Expected dust change in the fee, got change %d fee %d", spend.Change, spend.Fee)
+	}
+
+	other := &TaprootKey{}
+	other.PrivKey, _ = btcec.NewPrivateKey()
+	if err := spend.Sign([]*TaprootKey{other}); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Expected ErrNoSigningKey, got %v", err)
+	}
+}
+
+func TestSignTaprootInputSighashType(t *testing.T) {
+	key := &TaprootKey{}
+	key.PrivKey, _ = btcec.NewPrivateKey()
+	script, _ := key.PkScript()
+	spend, err := BuildTaprootSpend([]UTXO{testUTXO(t, 1, 50000, script)}, []*wire.TxOut{wire.NewTxOut(20000, script)}, script, 1)
+	if err != nil {
+		t.Fatalf("BuildTaprootSpend() error = %v", err)
+	}
+
+	sig, err := SignTaprootInput(spend.Tx, 0, spend.PrevOuts, key, txscript.SigHashAll)
+	if err != nil {
+		t.Fatalf("SignTaprootInput() error = %v", err)
+	}
+	if len(sig) != 65 || sig[64] != byte(txscript.SigHashAll) {
+		t.Fatalf("Expected a 65-byte signature ending in SIGHASH_ALL, got %d bytes", len(sig))
+	}
+	spend.Tx.TxIn[0].Witness = wire.TxWitness{sig}
+	if err := spend.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package buildinfo reports the version and provenance of Excalibur-EXS
+// binaries. Release builds set Version, Commit and BuildDate with -ldflags,
+// e.g.
+//
+//	go build -trimpath -ldflags "\
+//	  -X github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo.BuildDate=$(git log -1 --format=%cI)"
+//
+// scripts/build.sh does this for every binary. Unset values fall back to the
+// VCS stamp the Go toolchain embeds.
+package buildinfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Set with -ldflags "-X github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo.<Name>=<value>"
+var (
+	Version   = "1.0.0"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes a binary. BuildHash identifies its inputs: builds of the same
+// commit with the same toolchain, dependencies and flags share a hash, so
+// differing hashes across a fleet flag non-reproducible or tampered builds.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	Modified  bool   `json:"modified,omitempty"`
+	BuildHash string `json:"build_hash"`
+}
+
+var get = sync.OnceValue(func() Info {
+	bi, _ := debug.ReadBuildInfo()
+	return newInfo(bi)
+})
+
+// Get returns the running binary's build information
+func Get() Info {
+	return get()
+}
+
+// newInfo combines the -ldflags variables with the toolchain's build
+// information, which is nil when the binary was built without module support
+func newInfo(bi *debug.BuildInfo) Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi == nil {
+		info.BuildHash = buildHash(nil, info.Version, info.Commit)
+		return info
+	}
+
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	if bi.GoVersion != "" {
+		info.GoVersion = bi.GoVersion
+	}
+	info.BuildHash = buildHash(bi, info.Version, info.Commit)
+	return info
+}
+
+// buildHash hashes everything that determines the binary's contents. The
+// -ldflags setting is left out because it carries BuildDate; the version and
+// commit it sets are hashed directly.
+func buildHash(bi *debug.BuildInfo, version, commit string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %s\ncommit %s\n", version, commit)
+	if bi != nil {
+		fmt.Fprintf(h, "go %s\npath %s\nmod %s %s %s\n", bi.GoVersion, bi.Path, bi.Main.Path, bi.Main.Version, bi.Main.Sum)
+		for _, dep := range bi.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			fmt.Fprintf(h, "dep %s %s %s\n", dep.Path, dep.Version, dep.Sum)
+		}
+
+		settings := make([]string, 0, len(bi.Settings))
+		for _, setting := range bi.Settings {
+			if setting.Key == "-ldflags" || setting.Key == "vcs.time" {
+				continue
+			}
+			settings = append(settings, setting.Key+"="+setting.Value)
+		}
+		sort.Strings(settings)
+		fmt.Fprintf(h, "settings %s\n", strings.Join(settings, "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// String returns a one-line summary such as "1.0.0 (a1b2c3d4e5f6, 2026-01-02T15:04:05Z)"
+func (i Info) String() string {
+	commit := i.Commit
+	if commit == "" {
+		commit = "unknown commit"
+	} else if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if i.Modified {
+		commit += "-dirty"
+	}
+	if i.BuildDate == "" {
+		return fmt.Sprintf("%s (%s)", i.Version, commit)
+	}
+	return fmt.Sprintf("%s (%s, %s)", i.Version, commit, i.BuildDate)
+}
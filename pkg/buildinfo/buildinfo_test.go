@@ -0,0 +1,87 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func testBuildInfo() *debug.BuildInfo {
+	return &debug.BuildInfo{
+		GoVersion: "go1.23.4",
+		Path:      "github.com/Holedozer1229/Excalibur-EXS/cmd/exs-node",
+		Main:      debug.Module{Path: "github.com/Holedozer1229/Excalibur-EXS", Version: "(devel)"},
+		Deps: []*debug.Module{
+			{Path: "github.com/spf13/cobra", Version: "v1.8.0", Sum: "h1:abc"},
+		},
+		Settings: []debug.BuildSetting{
+			{Key: "-ldflags", Value: "-X main.date=2026-01-01"},
+			{Key: "-trimpath", Value: "true"},
+			{Key: "vcs.revision", Value: "0123456789abcdef0123456789abcdef01234567"},
+			{Key: "vcs.time", Value: "2026-01-01T00:00:00Z"},
+			{Key: "vcs.modified", Value: "false"},
+		},
+	}
+}
+
+func TestNewInfoFallsBackToVCS(t *testing.T) {
+	info := newInfo(testBuildInfo())
+	if info.Commit != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("Expected commit from vcs.revision, got %q", info.Commit)
+	}
+	if info.BuildDate != "2026-01-01T00:00:00Z" {
+		t.Errorf("Expected build date from vcs.time, got %q", info.BuildDate)
+	}
+	if info.GoVersion != "go1.23.4" || info.Modified {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	if got := info.String(); got != "1.0.0 (0123456789ab, 2026-01-01T00:00:00Z)" {
+		t.Errorf("String() = %q", got)
+	}
+
+	Commit, BuildDate = "feedface", "2026-02-02T00:00:00Z"
+	defer func() { Commit, BuildDate = "", "" }()
+	info = newInfo(testBuildInfo())
+	if info.Commit != "feedface" || info.BuildDate != "2026-02-02T00:00:00Z" {
+		t.Errorf("Expected -ldflags values to win, got %+v", info)
+	}
+}
+
+func TestBuildHash(t *testing.T) {
+	base := newInfo(testBuildInfo()).BuildHash
+	if len(base) != 64 {
+		t.Fatalf("Expected a hex SHA-256, got %q", base)
+	}
+
+	// Rebuilding the same commit on another day gives the same hash
+	rebuilt := testBuildInfo()
+	rebuilt.Settings[0].Value = "-X main.date=2026-03-03"
+	if got := newInfo(rebuilt).BuildHash; got != base {
+		t.Error("Expected build date to be excluded from the hash")
+	}
+
+	changes := map[string]func(*debug.BuildInfo){
+		"toolchain":  func(bi *debug.BuildInfo) { bi.GoVersion = "go1.23.5" },
+		"dependency": func(bi *debug.BuildInfo) { bi.Deps[0].Sum = "h1:def" },
+		"commit":     func(bi *debug.BuildInfo) { bi.Settings[2].Value = strings.Repeat("f", 40) },
+		"dirty tree": func(bi *debug.BuildInfo) { bi.Settings[4].Value = "true" },
+		"flags":      func(bi *debug.BuildInfo) { bi.Settings[1].Value = "false" },
+	}
+	for name, change := range changes {
+		bi := testBuildInfo()
+		change(bi)
+		if newInfo(bi).BuildHash == base {
+			t.Errorf("Expected %s change to change the hash", name)
+		}
+	}
+}
+
+func TestInfoStringModified(t *testing.T) {
+	info := Info{Version: "1.0.0", Commit: "abc", Modified: true}
+	if got := info.String(); got != "1.0.0 (abc-dirty)" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (Info{Version: "dev"}).String(); got != "dev (unknown commit)" {
+		t.Errorf("String() = %q", got)
+	}
+}
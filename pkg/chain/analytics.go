@@ -0,0 +1,215 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DefaultAnalyticsInterval is the number of blocks per issuance period,
+// about a day
+const DefaultAnalyticsInterval = 144
+
+// holderBounds are the lower bounds of the holder distribution buckets, in
+// whole coins
+var holderBounds = []int64{0, 1, 10, 100, 1000, 10000}
+
+// AnalyticsOptions configures a chain scan
+type AnalyticsOptions struct {
+	// Interval is the number of blocks per issuance period,
+	// DefaultAnalyticsInterval if zero
+	Interval int32
+	// TreasuryScript is the treasury's output script; nil skips treasury
+	// accounting
+	TreasuryScript []byte
+}
+
+// IssuancePeriod is the coin issued by a run of main chain blocks
+type IssuancePeriod struct {
+	StartHeight int32     `json:"start_height"`
+	EndHeight   int32     `json:"end_height"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	Blocks      int       `json:"blocks"`
+	// Issued is the new coin created: coinbase outputs less fees
+	Issued int64 `json:"issued"`
+	Fees   int64 `json:"fees"`
+	// Treasury is the coinbase value paid to the treasury script
+	Treasury int64 `json:"treasury"`
+	// Supply is the coin issued by every scanned block up to EndHeight
+	Supply int64 `json:"supply"`
+}
+
+// HolderBucket counts the scripts whose unspent balance falls in
+// [Min, Max), in satoshis; Max is zero for the open top bucket
+type HolderBucket struct {
+	Min     int64 `json:"min"`
+	Max     int64 `json:"max,omitempty"`
+	Holders int   `json:"holders"`
+	Amount  int64 `json:"amount"`
+}
+
+// Analytics summarizes issuance, fees and coin distribution of the chain
+type Analytics struct {
+	Height int32          `json:"height"`
+	Hash   chainhash.Hash `json:"hash"`
+	// FromHeight is the first block scanned; blocks below it are pruned
+	FromHeight   int32 `json:"from_height"`
+	Blocks       int   `json:"blocks"`
+	Transactions int   `json:"transactions"`
+	Issued       int64 `json:"issued"`
+	Fees         int64 `json:"fees"`
+	// AverageFee is the mean fee total per scanned block
+	AverageFee float64 `json:"average_fee"`
+	// Treasury and TreasuryShare are omitted without a treasury script
+	Treasury      int64   `json:"treasury,omitempty"`
+	TreasuryShare float64 `json:"treasury_share,omitempty"`
+	// Unspent is the value of the UTXO set
+	Unspent  int64            `json:"unspent"`
+	Holders  int              `json:"holders"`
+	Buckets  []HolderBucket   `json:"buckets"`
+	Issuance []IssuancePeriod `json:"issuance"`
+}
+
+// Analyze scans the main chain and UTXO set. Fees are read from undo data,
+// so blocks whose data was pruned are left out of the issuance figures.
+func (c *Chain) Analyze(opts AnalyticsOptions) (*Analytics, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultAnalyticsInterval
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tip := c.main[len(c.main)-1]
+	a := &Analytics{Height: tip.height, Hash: tip.hash}
+	if c.pruned > 1 {
+		a.FromHeight = c.pruned
+	}
+
+	var period *IssuancePeriod
+	for height := a.FromHeight; height <= tip.height; height++ {
+		n := c.main[height]
+		block, err := c.readBlock(n)
+		if err != nil {
+			return nil, err
+		}
+		fees, err := c.blockFees(n, block)
+		if err != nil {
+			return nil, err
+		}
+		var issued, treasury int64
+		for _, out := range block.Transactions[0].TxOut {
+			issued += out.Value
+			if opts.TreasuryScript != nil && bytes.Equal(out.PkScript, opts.TreasuryScript) {
+				treasury += out.Value
+			}
+		}
+		issued -= fees
+
+		a.Blocks++
+		a.Transactions += len(block.Transactions)
+		a.Issued += issued
+		a.Fees += fees
+		a.Treasury += treasury
+
+		if period == nil || height/opts.Interval != period.StartHeight/opts.Interval {
+			a.Issuance = append(a.Issuance, IssuancePeriod{StartHeight: height, StartTime: n.header.Timestamp})
+			period = &a.Issuance[len(a.Issuance)-1]
+		}
+		period.EndHeight, period.EndTime = height, n.header.Timestamp
+		period.Blocks++
+		period.Issued += issued
+		period.Fees += fees
+		period.Treasury += treasury
+		period.Supply = a.Issued
+	}
+	if a.Blocks > 0 {
+		a.AverageFee = float64(a.Fees) / float64(a.Blocks)
+	}
+	if opts.TreasuryScript != nil && a.Issued > 0 {
+		a.TreasuryShare = float64(a.Treasury) / float64(a.Issued)
+	}
+
+	if err := c.analyzeHolders(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// blockFees returns the fees a connected block paid, from the value of the
+// coins its undo data records as spent. Genesis spends nothing.
+func (c *Chain) blockFees(n *node, block *wire.MsgBlock) (int64, error) {
+	if n.height == 0 {
+		return 0, nil
+	}
+	data, err := c.get(hashKey(prefixUndo, n.hash))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read undo data of %s: %w", n.hash, err)
+	}
+	spent, err := decodeUndo(data)
+	if err != nil {
+		return 0, err
+	}
+	var fees int64
+	for _, coin := range spent {
+		fees += coin.TxOut.Value
+	}
+	for _, tx := range block.Transactions[1:] {
+		for _, out := range tx.TxOut {
+			fees -= out.Value
+		}
+	}
+	return fees, nil
+}
+
+// analyzeHolders totals the UTXO set by output script into the holder
+// buckets
+func (c *Chain) analyzeHolders(a *Analytics) error {
+	balances := make(map[string]int64)
+	err := c.iterate([]byte{prefixCoin}, func(key, value []byte) error {
+		var op wire.OutPoint
+		copy(op.Hash[:], key[1:])
+		op.Index = binary.BigEndian.Uint32(key[1+chainhash.HashSize:])
+		coin, err := readCoin(bytes.NewReader(value), op)
+		if err != nil {
+			return err
+		}
+		balances[string(coin.TxOut.PkScript)] += coin.TxOut.Value
+		a.Unspent += coin.TxOut.Value
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read UTXO set: %w", err)
+	}
+
+	a.Buckets = make([]HolderBucket, len(holderBounds))
+	for i, bound := range holderBounds {
+		a.Buckets[i].Min = bound * btcutil.SatoshiPerBitcoin
+		if i+1 < len(holderBounds) {
+			a.Buckets[i].Max = holderBounds[i+1] * btcutil.SatoshiPerBitcoin
+		}
+	}
+	for _, balance := range balances {
+		i := len(holderBounds) - 1
+		for i > 0 && balance < a.Buckets[i].Min {
+			i--
+		}
+		a.Buckets[i].Holders++
+		a.Buckets[i].Amount += balance
+	}
+	a.Holders = len(balances)
+	return nil
+}
+
+// Share returns the fraction of the unspent value a bucket holds
+func (a *Analytics) Share(b HolderBucket) float64 {
+	if a.Unspent == 0 {
+		return 0
+	}
+	return float64(b.Amount) / float64(a.Unspent)
+}
@@ -0,0 +1,532 @@
+// Package chain stores the block chain and its state in a kv.Store, LevelDB
+// by default: a block index of every known header, block and undo data, the UTXO set and an
+// optional transaction index.
+//
+// Blocks are validated against the chain state before they are connected,
+// with Bitcoin's rules: headers are mined with double SHA-256 (SHA-256d),
+// not the Tetra-PoW of pkg/consensus.
+// A side chain with more work replaces the main chain by disconnecting
+// blocks with their undo data, and the whole reorganization is committed in
+// a single write. Pruning deletes block and undo data below a depth while
+// keeping the index and UTXO set intact.
+package chain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// MinPruneDepth is the fewest recent blocks a pruned node keeps, matching
+// Bitcoin Core
+const MinPruneDepth = 288
+
+// medianTimeBlocks is the number of blocks whose timestamps give the median
+// time past
+const medianTimeBlocks = 11
+
+var (
+	// ErrBlockNotFound indicates a block hash or height the chain does not have
+	ErrBlockNotFound = errors.New("block not found")
+	// ErrBlockPruned indicates a known block whose data was pruned
+	ErrBlockPruned = errors.New("block data pruned")
+	// ErrTxNotFound indicates a transaction that is not in the transaction
+	// index, or any transaction when the index is disabled
+	ErrTxNotFound = errors.New("transaction not found")
+	// ErrDuplicateBlock indicates a block that is already stored
+	ErrDuplicateBlock = errors.New("block already known")
+	// ErrOrphanBlock indicates a block whose parent is unknown
+	ErrOrphanBlock = errors.New("block parent unknown")
+	// ErrInvalidBlock indicates a block that breaks the consensus rules or
+	// descends from one that does
+	ErrInvalidBlock = errors.New("invalid block")
+	// ErrReorgTooDeep indicates a reorganization past pruned undo data
+	ErrReorgTooDeep = errors.New("reorganization past pruned blocks")
+	// ErrInvalidOptions indicates options the store cannot run with
+	ErrInvalidOptions = errors.New("invalid chain options")
+	// ErrCorrupt indicates a database record that cannot be decoded
+	ErrCorrupt = errors.New("chain database corrupt")
+)
+
+// Options configures a Chain
+type Options struct {
+	// PruneDepth is the number of recent blocks whose data is kept; zero
+	// keeps every block, otherwise it must be at least MinPruneDepth
+	PruneDepth int32
+	// TxIndex maps every confirmed transaction to its block. It needs the
+	// block data, so it cannot be combined with pruning.
+	TxIndex bool
+	// AddrIndex maps output scripts to their unspent coins, so Coins does
+	// not walk the UTXO set. It is built from the UTXO set when first
+	// enabled.
+	AddrIndex bool
+	// CacheSize is the database block cache in bytes; zero uses the
+	// LevelDB default. Other backends ignore it.
+	CacheSize int
+	// Backend is the kv storage backend (bolt, badger or leveldb); empty
+	// means leveldb
+	Backend string
+}
+
+// Coin is an unspent transaction output
+type Coin struct {
+	OutPoint wire.OutPoint
+	TxOut    *wire.TxOut
+	// Height is the height of the block that created the output
+	Height   int32
+	Coinbase bool
+}
+
+// Tip describes the best block of a chain
+type Tip struct {
+	Hash   chainhash.Hash
+	Height int32
+	Header wire.BlockHeader
+	// ChainWork is the total work of the chain up to and including the tip
+	ChainWork *big.Int
+	// MedianTime is the median timestamp of the last 11 blocks
+	MedianTime time.Time
+}
+
+// node is a block index entry
+type node struct {
+	hash   chainhash.Hash
+	parent *node
+	height int32
+	header wire.BlockHeader
+	status byte
+	// work is the total work of the chain ending at this block
+	work *big.Int
+}
+
+// ancestor returns the node's ancestor at height
+func (n *node) ancestor(height int32) *node {
+	for n != nil && n.height > height {
+		n = n.parent
+	}
+	return n
+}
+
+// medianTime returns the median timestamp of the node and its ten parents
+func (n *node) medianTime() time.Time {
+	times := make([]int64, 0, medianTimeBlocks)
+	for ; n != nil && len(times) < medianTimeBlocks; n = n.parent {
+		times = append(times, n.header.Timestamp.Unix())
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return time.Unix(times[len(times)/2], 0)
+}
+
+// Chain is a block chain stored in a kv.Store
+type Chain struct {
+	mu     sync.RWMutex
+	db     kv.Store
+	params *chaincfg.Params
+	opts   Options
+	index  map[chainhash.Hash]*node
+	// main lists the main chain by height
+	main []*node
+	// pruned is the lowest main chain height above genesis that still has
+	// its block data
+	pruned int32
+}
+
+// Open opens or creates the chain database at path. A new database starts
+// with the network's genesis block, whose coinbase is not spendable.
+func Open(path string, params *chaincfg.Params, opts Options) (*Chain, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	db, err := openStore(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chain database: %w", err)
+	}
+	return newChain(db, params, opts)
+}
+
+// OpenMemory creates a chain held in memory, for tests and throwaway
+// regtest nodes
+func OpenMemory(params *chaincfg.Params, opts Options) (*Chain, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return newChain(kv.NewMemory(), params, opts)
+}
+
+// validate checks the options for combinations the store cannot honour
+func (o Options) validate() error {
+	if o.PruneDepth < 0 || o.PruneDepth > 0 && o.PruneDepth < MinPruneDepth {
+		return fmt.Errorf("%w: prune depth %d is below %d blocks", ErrInvalidOptions, o.PruneDepth, MinPruneDepth)
+	}
+	if o.PruneDepth > 0 && o.TxIndex {
+		return fmt.Errorf("%w: the transaction index is incompatible with pruning", ErrInvalidOptions)
+	}
+	switch o.Backend {
+	case "", kv.BackendLevelDB, kv.BackendBolt, kv.BackendBadger:
+	default:
+		return fmt.Errorf("%w: unknown storage backend %q", ErrInvalidOptions, o.Backend)
+	}
+	return nil
+}
+
+// newChain loads the block index, or stores the genesis block in an empty
+// database
+func newChain(db kv.Store, params *chaincfg.Params, opts Options) (*Chain, error) {
+	c := &Chain{db: db, params: params, opts: opts, index: make(map[chainhash.Hash]*node)}
+	tipHash, err := c.get(tipKey)
+	if errors.Is(err, kv.ErrNotFound) {
+		err = c.initGenesis()
+	} else if err == nil {
+		err = c.loadIndex(tipHash)
+	}
+	if err == nil {
+		err = c.syncAddrIndex()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// initGenesis stores the genesis block as the tip
+func (c *Chain) initGenesis() error {
+	genesis := c.params.GenesisBlock
+	n := &node{
+		hash:   *c.params.GenesisHash,
+		header: genesis.Header,
+		status: statusData,
+		work:   blockchain.CalcWork(genesis.Header.Bits),
+	}
+	var buf bytes.Buffer
+	if err := genesis.Serialize(&buf); err != nil {
+		return err
+	}
+	batch := new(kv.Batch)
+	batch.Put(chainBucket, hashKey(prefixIndex, n.hash), encodeIndex(n))
+	batch.Put(chainBucket, hashKey(prefixBlock, n.hash), buf.Bytes())
+	batch.Put(chainBucket, tipKey, n.hash[:])
+	if err := batch.Write(c.db); err != nil {
+		return fmt.Errorf("failed to write genesis block: %w", err)
+	}
+	c.index[n.hash] = n
+	c.main = []*node{n}
+	c.pruned = 1
+	return nil
+}
+
+// loadIndex reads every block index entry, links the entries to their
+// parents and rebuilds the main chain back from the tip
+func (c *Chain) loadIndex(tipHash []byte) error {
+	var nodes []*node
+	err := c.iterate([]byte{prefixIndex}, func(key, value []byte) error {
+		n, err := decodeIndex(value)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, n)
+		c.index[n.hash] = n
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read block index: %w", err)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].height < nodes[j].height })
+	for _, n := range nodes {
+		work := blockchain.CalcWork(n.header.Bits)
+		if n.height == 0 {
+			n.work = work
+			continue
+		}
+		parent, ok := c.index[n.header.PrevBlock]
+		if !ok || parent.height != n.height-1 {
+			return fmt.Errorf("%w: block %s has no parent in the index", ErrCorrupt, n.hash)
+		}
+		n.parent = parent
+		n.work = new(big.Int).Add(parent.work, work)
+	}
+
+	hash, err := chainhash.NewHash(tipHash)
+	if err != nil {
+		return fmt.Errorf("%w: tip %x", ErrCorrupt, tipHash)
+	}
+	tip, ok := c.index[*hash]
+	if !ok {
+		return fmt.Errorf("%w: tip %s not in the index", ErrCorrupt, hash)
+	}
+	c.main = make([]*node, tip.height+1)
+	for n := tip; n != nil; n = n.parent {
+		c.main[n.height] = n
+	}
+	if c.main[0].hash != *c.params.GenesisHash {
+		return fmt.Errorf("%w: database belongs to another network", ErrInvalidOptions)
+	}
+	c.pruned = 1
+	for c.pruned <= tip.height && c.main[c.pruned].status&statusData == 0 {
+		c.pruned++
+	}
+	return nil
+}
+
+// Close closes the database
+func (c *Chain) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.db.Close()
+}
+
+// Params returns the chain's network
+func (c *Chain) Params() *chaincfg.Params {
+	return c.params
+}
+
+// Tip returns the best block
+func (c *Chain) Tip() Tip {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tip := c.main[len(c.main)-1]
+	return Tip{
+		Hash:       tip.hash,
+		Height:     tip.height,
+		Header:     tip.header,
+		ChainWork:  new(big.Int).Set(tip.work),
+		MedianTime: tip.medianTime(),
+	}
+}
+
+// PruneHeight returns the lowest height whose block data is still stored,
+// zero when nothing has been pruned
+func (c *Chain) PruneHeight() int32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.pruned == 1 {
+		return 0
+	}
+	return c.pruned
+}
+
+// BlockHash returns the hash of the main chain block at height
+func (c *Chain) BlockHash(height int32) (chainhash.Hash, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if height < 0 || int(height) >= len(c.main) {
+		return chainhash.Hash{}, fmt.Errorf("%w: height %d", ErrBlockNotFound, height)
+	}
+	return c.main[height].hash, nil
+}
+
+// Block returns a main chain block and its height. Side chain blocks are
+// not returned, as their height does not place them in the chain.
+func (c *Chain) Block(hash chainhash.Hash) (*wire.MsgBlock, int32, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	n, ok := c.index[hash]
+	if !ok || !c.inMain(n) {
+		return nil, 0, fmt.Errorf("%w: %s", ErrBlockNotFound, hash)
+	}
+	block, err := c.readBlock(n)
+	if err != nil {
+		return nil, 0, err
+	}
+	return block, n.height, nil
+}
+
+// inMain reports whether n is on the main chain
+func (c *Chain) inMain(n *node) bool {
+	return int(n.height) < len(c.main) && c.main[n.height] == n
+}
+
+// readBlock loads a block's data
+func (c *Chain) readBlock(n *node) (*wire.MsgBlock, error) {
+	if n.status&statusData == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrBlockPruned, n.hash)
+	}
+	data, err := c.get(hashKey(prefixBlock, n.hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block %s: %w", n.hash, err)
+	}
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%w: block %s: %v", ErrCorrupt, n.hash, err)
+	}
+	return &block, nil
+}
+
+// Transaction returns a confirmed transaction and the hash of its block
+// from the transaction index
+func (c *Chain) Transaction(txid chainhash.Hash) (*wire.MsgTx, chainhash.Hash, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.opts.TxIndex {
+		return nil, chainhash.Hash{}, fmt.Errorf("%w: %s (the transaction index is disabled)", ErrTxNotFound, txid)
+	}
+	value, err := c.get(hashKey(prefixTxIndex, txid))
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil, chainhash.Hash{}, fmt.Errorf("%w: %s", ErrTxNotFound, txid)
+	}
+	if err != nil {
+		return nil, chainhash.Hash{}, fmt.Errorf("failed to read transaction index: %w", err)
+	}
+	hash, err := chainhash.NewHash(value)
+	if err != nil {
+		return nil, chainhash.Hash{}, fmt.Errorf("%w: transaction index entry %s", ErrCorrupt, txid)
+	}
+	n, ok := c.index[*hash]
+	if !ok {
+		return nil, chainhash.Hash{}, fmt.Errorf("%w: transaction index entry %s", ErrCorrupt, txid)
+	}
+	block, err := c.readBlock(n)
+	if err != nil {
+		return nil, chainhash.Hash{}, err
+	}
+	for _, tx := range block.Transactions {
+		if tx.TxHash() == txid {
+			return tx, *hash, nil
+		}
+	}
+	return nil, chainhash.Hash{}, fmt.Errorf("%w: transaction index entry %s", ErrCorrupt, txid)
+}
+
+// Confirmed reports whether a transaction is in the transaction index
+func (c *Chain) Confirmed(txid chainhash.Hash) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, err := c.get(hashKey(prefixTxIndex, txid))
+	return err == nil
+}
+
+// FetchCoin returns an unspent output, or nil when it is spent or unknown
+func (c *Chain) FetchCoin(op wire.OutPoint) (*Coin, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fetchCoin(op)
+}
+
+// fetchCoin reads a coin from the UTXO set
+func (c *Chain) fetchCoin(op wire.OutPoint) (*Coin, error) {
+	data, err := c.get(coinKey(op))
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coin %s: %w", op, err)
+	}
+	return readCoin(bytes.NewReader(data), op)
+}
+
+// Coins returns the unspent outputs paying pkScript, in outpoint order,
+// from the address index, or by walking the UTXO set without it
+func (c *Chain) Coins(pkScript []byte) ([]Coin, error) {
+	if !c.opts.AddrIndex {
+		return c.Unspent(func(script []byte) bool { return bytes.Equal(script, pkScript) })
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	prefix := scriptPrefix(pkScript)
+	var coins []Coin
+	err := c.iterate(prefix, func(key, value []byte) error {
+		var op wire.OutPoint
+		copy(op.Hash[:], key[len(prefix):])
+		op.Index = binary.BigEndian.Uint32(key[len(prefix)+chainhash.HashSize:])
+		coin, err := readCoin(bytes.NewReader(value), op)
+		if err != nil {
+			return err
+		}
+		coins = append(coins, *coin)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read address index: %w", err)
+	}
+	return coins, nil
+}
+
+// syncAddrIndex builds the address index from the UTXO set when it is
+// enabled on a database without an up-to-date one. When it is disabled the
+// mark is removed, since blocks connected meanwhile leave the index stale.
+func (c *Chain) syncAddrIndex() error {
+	_, err := c.get(addrIndexKey)
+	if err != nil && !errors.Is(err, kv.ErrNotFound) {
+		return err
+	}
+	indexed := err == nil
+	if !c.opts.AddrIndex {
+		if !indexed {
+			return nil
+		}
+		batch := new(kv.Batch)
+		batch.Delete(chainBucket, addrIndexKey)
+		return batch.Write(c.db)
+	}
+	if indexed {
+		return nil
+	}
+
+	batch := new(kv.Batch)
+	err = c.iterate([]byte{prefixAddr}, func(key, value []byte) error {
+		batch.Delete(chainBucket, bytes.Clone(key))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear address index: %w", err)
+	}
+	err = c.iterate([]byte{prefixCoin}, func(key, value []byte) error {
+		var op wire.OutPoint
+		copy(op.Hash[:], key[1:])
+		op.Index = binary.BigEndian.Uint32(key[1+chainhash.HashSize:])
+		coin, err := readCoin(bytes.NewReader(value), op)
+		if err != nil {
+			return err
+		}
+		batch.Put(chainBucket, addrKey(coin.TxOut.PkScript, op), bytes.Clone(value))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build address index: %w", err)
+	}
+	batch.Put(chainBucket, addrIndexKey, []byte{1})
+	if err := batch.Write(c.db); err != nil {
+		return fmt.Errorf("failed to build address index: %w", err)
+	}
+	return nil
+}
+
+// Unspent returns the unspent outputs whose script matches, in outpoint
+// order. It walks the whole UTXO set.
+func (c *Chain) Unspent(match func(pkScript []byte) bool) ([]Coin, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var coins []Coin
+	err := c.iterate([]byte{prefixCoin}, func(key, value []byte) error {
+		var op wire.OutPoint
+		copy(op.Hash[:], key[1:])
+		op.Index = binary.BigEndian.Uint32(key[1+chainhash.HashSize:])
+		coin, err := readCoin(bytes.NewReader(value), op)
+		if err != nil {
+			return err
+		}
+		if match(coin.TxOut.PkScript) {
+			coins = append(coins, *coin)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read UTXO set: %w", err)
+	}
+	return coins, nil
+}
@@ -0,0 +1,444 @@
+package chain
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+var regtest = &chaincfg.RegressionNetParams
+
+type testKey struct {
+	priv     *btcec.PrivateKey
+	pkScript []byte
+}
+
+func newTestKey(t *testing.T) *testKey {
+	priv, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(priv.PubKey().SerializeCompressed()), regtest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	return &testKey{priv: priv, pkScript: pkScript}
+}
+
+// spend signs a transaction moving coin to pkScript, less fee
+func (k *testKey) spend(t *testing.T, coin *Coin, pkScript []byte, fee int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&coin.OutPoint, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(coin.TxOut.Value-fee, pkScript))
+	fetcher := txscript.NewCannedPrevOutputFetcher(coin.TxOut.PkScript, coin.TxOut.Value)
+	witness, err := txscript.WitnessSignature(tx, txscript.NewTxSigHashes(tx, fetcher), 0,
+		coin.TxOut.Value, coin.TxOut.PkScript, txscript.SigHashAll, k.priv, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.TxIn[0].Witness = witness
+	return tx
+}
+
+// mine connects n blocks paying pkScript, the first including txs
+func mine(t *testing.T, c *Chain, n int, pkScript []byte, txs []*wire.MsgTx, fees int64) []*wire.MsgBlock {
+	t.Helper()
+	var blocks []*wire.MsgBlock
+	for i := 0; i < n; i++ {
+		block, err := c.NewBlock(pkScript, txs, fees)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if main, err := c.ProcessBlock(block); err != nil || !main {
+			t.Fatalf("ProcessBlock() = %v, %v", main, err)
+		}
+		blocks = append(blocks, block)
+		txs, fees = nil, 0
+	}
+	return blocks
+}
+
+// coinbaseCoin returns the first output of a block's coinbase
+func coinbaseCoin(t *testing.T, c *Chain, block *wire.MsgBlock) *Coin {
+	t.Helper()
+	coin, err := c.FetchCoin(wire.OutPoint{Hash: block.Transactions[0].TxHash()})
+	if err != nil || coin == nil {
+		t.Fatalf("FetchCoin() = %v, %v", coin, err)
+	}
+	return coin
+}
+
+func TestConnectAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	c, err := Open(path, regtest, Options{TxIndex: true})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	key := newTestKey(t)
+	blocks := mine(t, c, 99, key.pkScript, nil, 0)
+
+	coin := coinbaseCoin(t, c, blocks[0])
+	if !coin.Coinbase || coin.Height != 1 {
+		t.Errorf("coinbase coin = %+v", coin)
+	}
+	tx := key.spend(t, coin, key.pkScript, 1000)
+	if _, err := c.ProcessBlock(mustBlock(t, c, key.pkScript, tx)); !errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("immature coinbase spend error = %v, want ErrInvalidBlock", err)
+	}
+
+	mine(t, c, 1, key.pkScript, nil, 0)
+	mined := mine(t, c, 1, key.pkScript, []*wire.MsgTx{tx}, 1000)[0]
+	if spent, _ := c.FetchCoin(coin.OutPoint); spent != nil {
+		t.Error("spent coinbase is still unspent")
+	}
+	if got, hash, err := c.Transaction(tx.TxHash()); err != nil || got.TxHash() != tx.TxHash() || hash != mined.BlockHash() {
+		t.Errorf("Transaction() = %v, %s, %v", got, hash, err)
+	}
+	tip := c.Tip()
+	if tip.Height != 101 || tip.Hash != mined.BlockHash() {
+		t.Errorf("Tip() = %d %s", tip.Height, tip.Hash)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = Open(path, regtest, Options{TxIndex: true})
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if got := c.Tip(); got.Hash != tip.Hash || got.ChainWork.Cmp(tip.ChainWork) != 0 {
+		t.Errorf("reopened tip = %d %s, want %d %s", got.Height, got.Hash, tip.Height, tip.Hash)
+	}
+	if hash, _ := c.BlockHash(1); hash != blocks[0].BlockHash() {
+		t.Errorf("BlockHash(1) = %s", hash)
+	}
+	out, err := c.FetchCoin(wire.OutPoint{Hash: tx.TxHash()})
+	if err != nil || out == nil || out.TxOut.Value != coin.TxOut.Value-1000 || out.Height != 101 {
+		t.Errorf("spending output after reopen = %+v, %v", out, err)
+	}
+	coins, err := c.Unspent(func(pkScript []byte) bool { return true })
+	if err != nil || len(coins) != 101 {
+		t.Errorf("Unspent() = %d coins, %v, want 101", len(coins), err)
+	}
+	c.Close()
+	if _, err := Open(path, &chaincfg.TestNet3Params, Options{}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("opening with another network error = %v, want ErrInvalidOptions", err)
+	}
+}
+
+// mustBlock assembles a block without connecting it
+func mustBlock(t *testing.T, c *Chain, pkScript []byte, txs ...*wire.MsgTx) *wire.MsgBlock {
+	t.Helper()
+	block, err := c.NewBlock(pkScript, txs, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+func TestProcessBlockErrors(t *testing.T) {
+	c, err := OpenMemory(regtest, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := newTestKey(t)
+	blocks := mine(t, c, 2, key.pkScript, nil, 0)
+
+	if _, err := c.ProcessBlock(blocks[1]); !errors.Is(err, ErrDuplicateBlock) {
+		t.Errorf("duplicate error = %v", err)
+	}
+	orphan := mustBlock(t, c, key.pkScript)
+	orphan.Header.PrevBlock[0] ^= 1
+	if _, err := c.ProcessBlock(orphan); !errors.Is(err, ErrOrphanBlock) {
+		t.Errorf("orphan error = %v", err)
+	}
+
+	// A coinbase claiming a fee with no transactions overpays by a satoshi
+	overpay, err := c.NewBlock(key.pkScript, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ProcessBlock(overpay); !errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("overpaying coinbase error = %v", err)
+	}
+	if c.Tip().Hash != blocks[1].BlockHash() {
+		t.Error("tip moved to an invalid block")
+	}
+	child, _ := c.NewBlock(key.pkScript, nil, 0)
+	child.Header.PrevBlock = overpay.BlockHash()
+	if _, err := c.ProcessBlock(child); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("child of invalid block error = %v", err)
+	}
+}
+
+func TestReorganize(t *testing.T) {
+	a, _ := OpenMemory(regtest, Options{TxIndex: true})
+	b, _ := OpenMemory(regtest, Options{TxIndex: true})
+	key, other := newTestKey(t), newTestKey(t)
+
+	for _, block := range mine(t, a, 101, key.pkScript, nil, 0) {
+		if _, err := b.ProcessBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, _ := a.BlockHash(1)
+	firstBlock, _, _ := a.Block(first)
+	coin := coinbaseCoin(t, a, firstBlock)
+	tx := key.spend(t, coin, key.pkScript, 500)
+	mine(t, a, 1, key.pkScript, []*wire.MsgTx{tx}, 500)
+
+	fork := mine(t, b, 2, other.pkScript, nil, 0)
+	if main, err := a.ProcessBlock(fork[0]); err != nil || main {
+		t.Fatalf("side chain block = %v, %v, want stored off the main chain", main, err)
+	}
+	if main, err := a.ProcessBlock(fork[1]); err != nil || !main {
+		t.Fatalf("heavier side chain = %v, %v, want a reorganization", main, err)
+	}
+
+	if tip := a.Tip(); tip.Hash != fork[1].BlockHash() || tip.Height != 103 {
+		t.Errorf("tip after reorganization = %d %s", tip.Height, tip.Hash)
+	}
+	if restored, _ := a.FetchCoin(coin.OutPoint); restored == nil || restored.Height != 1 || !restored.Coinbase {
+		t.Errorf("coin spent on the old branch = %+v, want restored", restored)
+	}
+	if out, _ := a.FetchCoin(wire.OutPoint{Hash: tx.TxHash()}); out != nil {
+		t.Error("output created on the old branch is still unspent")
+	}
+	if _, _, err := a.Transaction(tx.TxHash()); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("transaction on the old branch error = %v", err)
+	}
+
+	// The spend is valid again on the new branch
+	mined := mine(t, a, 1, key.pkScript, []*wire.MsgTx{tx}, 500)[0]
+	if _, hash, err := a.Transaction(tx.TxHash()); err != nil || hash != mined.BlockHash() {
+		t.Errorf("Transaction() after re-mining = %s, %v", hash, err)
+	}
+}
+
+func TestAddrIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	c, err := Open(path, regtest, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, other := newTestKey(t), newTestKey(t)
+	blocks := mine(t, c, 101, key.pkScript, nil, 0)
+	c.Close()
+
+	// Enabling the index builds it from the UTXO set
+	c, err = Open(path, regtest, Options{AddrIndex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if coins, err := c.Coins(key.pkScript); err != nil || len(coins) != 101 {
+		t.Fatalf("Coins() after building = %d coins, %v, want 101", len(coins), err)
+	}
+
+	coin := coinbaseCoin(t, c, blocks[0])
+	tx := key.spend(t, coin, other.pkScript, 1000)
+	mine(t, c, 1, key.pkScript, []*wire.MsgTx{tx}, 1000)
+	coins, err := c.Coins(other.pkScript)
+	if err != nil || len(coins) != 1 || coins[0].OutPoint.Hash != tx.TxHash() || coins[0].TxOut.Value != coin.TxOut.Value-1000 {
+		t.Fatalf("Coins() of the payee = %+v, %v", coins, err)
+	}
+	paid, err := c.Coins(key.pkScript)
+	if err != nil || len(paid) != 101 {
+		t.Errorf("Coins() of the payer = %d coins, %v, want 101", len(paid), err)
+	}
+	for _, got := range paid {
+		if got.OutPoint == coin.OutPoint {
+			t.Error("spent coin is still indexed")
+		}
+	}
+
+	// A reorganization away from the spend restores the spent coin
+	fork, _ := OpenMemory(regtest, Options{})
+	for _, block := range blocks {
+		if _, err := fork.ProcessBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, block := range mine(t, fork, 2, newTestKey(t).pkScript, nil, 0) {
+		if _, err := c.ProcessBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if coins, _ := c.Coins(other.pkScript); len(coins) != 0 {
+		t.Errorf("Coins() after reorganizing = %+v, want none", coins)
+	}
+	if coins, _ := c.Coins(key.pkScript); len(coins) != 101 || c.Tip().Height != 103 {
+		t.Errorf("Coins() of the payer after reorganizing = %d coins, want 101", len(coins))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	if _, err := OpenMemory(regtest, Options{PruneDepth: 100}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("shallow prune depth error = %v", err)
+	}
+	if _, err := OpenMemory(regtest, Options{PruneDepth: MinPruneDepth, TxIndex: true}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("prune with txindex error = %v", err)
+	}
+
+	c, err := OpenMemory(regtest, Options{PruneDepth: MinPruneDepth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := newTestKey(t)
+	blocks := mine(t, c, 300, key.pkScript, nil, 0)
+
+	if got := c.PruneHeight(); got != 13 {
+		t.Errorf("PruneHeight() = %d, want 13", got)
+	}
+	if _, _, err := c.Block(blocks[4].BlockHash()); !errors.Is(err, ErrBlockPruned) {
+		t.Errorf("pruned block error = %v", err)
+	}
+	if _, _, err := c.Block(blocks[299].BlockHash()); err != nil {
+		t.Errorf("recent block error = %v", err)
+	}
+	if _, _, err := c.Block(*regtest.GenesisHash); err != nil {
+		t.Errorf("genesis block error = %v", err)
+	}
+	if coin, _ := c.FetchCoin(wire.OutPoint{Hash: blocks[4].Transactions[0].TxHash()}); coin == nil {
+		t.Error("pruning removed a coin from the UTXO set")
+	}
+	if a, err := c.Analyze(AnalyticsOptions{}); err != nil || a.FromHeight != 13 || a.Blocks != 288 {
+		t.Errorf("Analyze() after pruning = %+v, %v, want blocks 13-300", a, err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	c, err := OpenMemory(regtest, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	miner, treasury := newTestKey(t), newTestKey(t)
+	blocks := mine(t, c, 100, miner.pkScript, nil, 0)
+	tx := miner.spend(t, coinbaseCoin(t, c, blocks[0]), treasury.pkScript, 1000)
+	mine(t, c, 1, treasury.pkScript, []*wire.MsgTx{tx}, 1000)
+
+	a, err := c.Analyze(AnalyticsOptions{Interval: 50, TreasuryScript: treasury.pkScript})
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	const coin = btcutil.SatoshiPerBitcoin
+	if a.Height != 101 || a.Blocks != 102 || a.Transactions != 103 {
+		t.Errorf("Analyze() scanned %d blocks, %d transactions to height %d", a.Blocks, a.Transactions, a.Height)
+	}
+	if a.Issued != 102*50*coin || a.Fees != 1000 || a.AverageFee != 1000.0/102 {
+		t.Errorf("Issued, Fees, AverageFee = %d, %d, %f", a.Issued, a.Fees, a.AverageFee)
+	}
+	if a.Treasury != 50*coin+1000 {
+		t.Errorf("Treasury = %d, want the last coinbase", a.Treasury)
+	}
+
+	if len(a.Issuance) != 3 {
+		t.Fatalf("Expected 3 issuance periods, got %d", len(a.Issuance))
+	}
+	last := a.Issuance[2]
+	if last.StartHeight != 100 || last.EndHeight != 101 || last.Fees != 1000 || last.Supply != a.Issued {
+		t.Errorf("last period = %+v", last)
+	}
+	if a.Issuance[0].Blocks != 50 || a.Issuance[0].Supply != 50*50*coin {
+		t.Errorf("first period = %+v", a.Issuance[0])
+	}
+
+	// The genesis coinbase is unspendable, and the miner's 99 coinbases and
+	// the treasury's 100 coins fall in separate buckets
+	if a.Unspent != 101*50*coin || a.Holders != 2 {
+		t.Errorf("Unspent, Holders = %d, %d", a.Unspent, a.Holders)
+	}
+	for _, b := range a.Buckets {
+		want := 0
+		if b.Min == 100*coin || b.Min == 1000*coin {
+			want = 1
+		}
+		if b.Holders != want {
+			t.Errorf("bucket %d-%d has %d holders, want %d", b.Min, b.Max, b.Holders, want)
+		}
+	}
+	if share := a.Share(a.Buckets[4]); share != 99.0/101 {
+		t.Errorf("Share() = %f, want %f", share, 99.0/101)
+	}
+}
+
+func TestStorageBackends(t *testing.T) {
+	for _, backend := range []string{kv.BackendBolt, kv.BackendBadger} {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chain")
+			c, err := Open(path, regtest, Options{Backend: backend})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			key := newTestKey(t)
+			mine(t, c, 3, key.pkScript, nil, 0)
+			tip := c.Tip()
+			c.Close()
+
+			c, err = Open(path, regtest, Options{Backend: backend})
+			if err != nil {
+				t.Fatalf("reopen error = %v", err)
+			}
+			defer c.Close()
+			if got := c.Tip(); got.Hash != tip.Hash || got.Height != 3 {
+				t.Errorf("reopened tip = %d %s, want 3 %s", got.Height, got.Hash, tip.Hash)
+			}
+		})
+	}
+	if _, err := Open(t.TempDir(), regtest, Options{Backend: "rocksdb"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("unknown backend error = %v, want ErrInvalidOptions", err)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	c, err := Open(path, regtest, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	key := newTestKey(t)
+	mine(t, c, 5, key.pkScript, nil, 0)
+	tip := c.Tip()
+	c.Close()
+
+	// Rewrite the records without the bucket prefix, as older releases did
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := new(leveldb.Batch)
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		batch.Delete(bytes.Clone(iter.Key()))
+		batch.Put(bytes.Clone(iter.Key()[len(chainBucket)+1:]), bytes.Clone(iter.Value()))
+	}
+	iter.Release()
+	if err := db.Write(batch, nil); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	c, err = Open(path, regtest, Options{})
+	if err != nil {
+		t.Fatalf("Open() of a legacy database error = %v", err)
+	}
+	defer c.Close()
+	if got := c.Tip(); got.Hash != tip.Hash || got.Height != 5 {
+		t.Errorf("migrated tip = %d %s, want 5 %s", got.Height, got.Hash, tip.Hash)
+	}
+	coins, err := c.Unspent(func(pkScript []byte) bool { return true })
+	if err != nil || len(coins) != 5 {
+		t.Errorf("Unspent() = %d coins, %v, want 5", len(coins), err)
+	}
+}
@@ -0,0 +1,170 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Key prefixes of the database records
+const (
+	prefixIndex   = 'i' // block hash -> index entry
+	prefixBlock   = 'b' // block hash -> serialized block
+	prefixUndo    = 'u' // block hash -> coins spent by the block
+	prefixCoin    = 'c' // outpoint -> unspent coin
+	prefixTxIndex = 't' // txid -> hash of the confirming block
+	prefixAddr    = 'a' // sha256 of output script, outpoint -> unspent coin
+)
+
+// tipKey holds the hash of the best block
+var tipKey = []byte("tip")
+
+// addrIndexKey marks a database whose address index is up to date
+var addrIndexKey = []byte("addrindex")
+
+// Block index status flags
+const (
+	// statusData marks a block whose data and, once connected, undo data
+	// are stored
+	statusData byte = 1 << iota
+	// statusInvalid marks a block that failed validation
+	statusInvalid
+)
+
+// hashKey returns the key of a per-block record
+func hashKey(prefix byte, hash chainhash.Hash) []byte {
+	key := make([]byte, 1+chainhash.HashSize)
+	key[0] = prefix
+	copy(key[1:], hash[:])
+	return key
+}
+
+// coinKey returns the UTXO set key of an outpoint
+func coinKey(op wire.OutPoint) []byte {
+	key := make([]byte, 1+chainhash.HashSize+4)
+	key[0] = prefixCoin
+	copy(key[1:], op.Hash[:])
+	binary.BigEndian.PutUint32(key[1+chainhash.HashSize:], op.Index)
+	return key
+}
+
+// scriptPrefix returns the address index prefix of an output script
+func scriptPrefix(pkScript []byte) []byte {
+	hash := sha256.Sum256(pkScript)
+	return append([]byte{prefixAddr}, hash[:]...)
+}
+
+// addrKey returns the address index key of a coin paying pkScript
+func addrKey(pkScript []byte, op wire.OutPoint) []byte {
+	key := scriptPrefix(pkScript)
+	key = append(key, op.Hash[:]...)
+	return binary.BigEndian.AppendUint32(key, op.Index)
+}
+
+// encodeIndex serializes a block index entry as height, status and header
+func encodeIndex(n *node) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(n.height))
+	buf.WriteByte(n.status)
+	n.header.Serialize(&buf)
+	return buf.Bytes()
+}
+
+// decodeIndex parses a block index entry
+func decodeIndex(data []byte) (*node, error) {
+	if len(data) != 4+1+wire.MaxBlockHeaderPayload {
+		return nil, fmt.Errorf("%w: index entry of %d bytes", ErrCorrupt, len(data))
+	}
+	n := &node{
+		height: int32(binary.BigEndian.Uint32(data)),
+		status: data[4],
+	}
+	if err := n.header.Deserialize(bytes.NewReader(data[5:])); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	n.hash = n.header.BlockHash()
+	return n, nil
+}
+
+// writeCoin serializes a coin without its outpoint
+func writeCoin(w io.Writer, coin *Coin) {
+	var flags byte
+	if coin.Coinbase {
+		flags = 1
+	}
+	binary.Write(w, binary.BigEndian, uint32(coin.Height))
+	w.Write([]byte{flags})
+	binary.Write(w, binary.BigEndian, coin.TxOut.Value)
+	wire.WriteVarBytes(w, 0, coin.TxOut.PkScript)
+}
+
+// readCoin parses a coin written by writeCoin
+func readCoin(r io.Reader, op wire.OutPoint) (*Coin, error) {
+	var header struct {
+		Height uint32
+		Flags  byte
+		Value  int64
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("%w: coin %s: %v", ErrCorrupt, op, err)
+	}
+	pkScript, err := wire.ReadVarBytes(r, 0, wire.MaxMessagePayload, "pkScript")
+	if err != nil {
+		return nil, fmt.Errorf("%w: coin %s: %v", ErrCorrupt, op, err)
+	}
+	return &Coin{
+		OutPoint: op,
+		TxOut:    wire.NewTxOut(header.Value, pkScript),
+		Height:   int32(header.Height),
+		Coinbase: header.Flags&1 != 0,
+	}, nil
+}
+
+// encodeCoin serializes a coin for the UTXO set
+func encodeCoin(coin *Coin) []byte {
+	var buf bytes.Buffer
+	writeCoin(&buf, coin)
+	return buf.Bytes()
+}
+
+// encodeUndo serializes the coins a block spent, in the order it spent them
+func encodeUndo(spent []*Coin) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarInt(&buf, 0, uint64(len(spent)))
+	for _, coin := range spent {
+		buf.Write(coin.OutPoint.Hash[:])
+		binary.Write(&buf, binary.BigEndian, coin.OutPoint.Index)
+		writeCoin(&buf, coin)
+	}
+	return buf.Bytes()
+}
+
+// decodeUndo parses undo data written by encodeUndo
+func decodeUndo(data []byte) ([]*Coin, error) {
+	r := bytes.NewReader(data)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil || n > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: undo data", ErrCorrupt)
+	}
+	spent := make([]*Coin, 0, n)
+	for i := uint64(0); i < n; i++ {
+		var op wire.OutPoint
+		if _, err := io.ReadFull(r, op.Hash[:]); err != nil {
+			return nil, fmt.Errorf("%w: undo data: %v", ErrCorrupt, err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &op.Index); err != nil {
+			return nil, fmt.Errorf("%w: undo data: %v", ErrCorrupt, err)
+		}
+		coin, err := readCoin(r, op)
+		if err != nil {
+			return nil, err
+		}
+		spent = append(spent, coin)
+	}
+	return spent, nil
+}
@@ -0,0 +1,499 @@
+package chain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// ScriptFlags are the script rules blocks are validated with. Every soft
+// fork up to Taproot is enforced from genesis, as on regtest and any chain
+// started after their activation.
+const ScriptFlags = txscript.ScriptBip16 |
+	txscript.ScriptVerifyDERSignatures |
+	txscript.ScriptVerifyCheckLockTimeVerify |
+	txscript.ScriptVerifyCheckSequenceVerify |
+	txscript.ScriptVerifyWitness |
+	txscript.ScriptVerifyNullFail |
+	txscript.ScriptVerifyTaproot
+
+// ProcessBlock validates a block and stores it. A block extending the best
+// chain is connected; one on a side chain is kept, and connected with its
+// branch once the branch has more work than the main chain. It returns
+// whether the block ended up on the main chain.
+func (c *Chain) ProcessBlock(block *wire.MsgBlock) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hash := block.BlockHash()
+	if _, ok := c.index[hash]; ok {
+		return false, fmt.Errorf("%w: %s", ErrDuplicateBlock, hash)
+	}
+	parent, ok := c.index[block.Header.PrevBlock]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrOrphanBlock, block.Header.PrevBlock)
+	}
+	if parent.status&statusInvalid != 0 {
+		return false, fmt.Errorf("%w: %s descends from invalid block %s", ErrInvalidBlock, hash, parent.hash)
+	}
+
+	if err := blockchain.CheckBlockSanity(btcutil.NewBlock(block), c.params.PowLimit, blockchain.NewMedianTime()); err != nil {
+		return false, fmt.Errorf("%w: %s: %v", ErrInvalidBlock, hash, err)
+	}
+	n := &node{
+		hash:   hash,
+		parent: parent,
+		height: parent.height + 1,
+		header: block.Header,
+		status: statusData,
+		work:   new(big.Int).Add(parent.work, blockchain.CalcWork(block.Header.Bits)),
+	}
+	if err := c.checkHeader(n); err != nil {
+		return false, fmt.Errorf("%w: %s: %v", ErrInvalidBlock, hash, err)
+	}
+
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		return false, err
+	}
+	batch := new(kv.Batch)
+	batch.Put(chainBucket, hashKey(prefixIndex, hash), encodeIndex(n))
+	batch.Put(chainBucket, hashKey(prefixBlock, hash), buf.Bytes())
+	if err := batch.Write(c.db); err != nil {
+		return false, fmt.Errorf("failed to store block %s: %w", hash, err)
+	}
+	c.index[hash] = n
+
+	if n.work.Cmp(c.main[len(c.main)-1].work) <= 0 {
+		return false, nil
+	}
+	if err := c.reorganize(n); err != nil {
+		return false, err
+	}
+	c.prune()
+	return true, nil
+}
+
+// checkHeader applies the header rules that depend on the block's parent
+func (c *Chain) checkHeader(n *node) error {
+	if bits := c.requiredBits(n.parent, n.header.Timestamp); n.header.Bits != bits {
+		return fmt.Errorf("bits %08x, want %08x", n.header.Bits, bits)
+	}
+	if mtp := n.parent.medianTime(); !n.header.Timestamp.After(mtp) {
+		return fmt.Errorf("timestamp %s is not after the median time %s", n.header.Timestamp, mtp)
+	}
+	return nil
+}
+
+// requiredBits returns the difficulty target of the block after parent,
+// retargeting every TargetTimespan like Bitcoin
+func (c *Chain) requiredBits(parent *node, timestamp time.Time) uint32 {
+	params := c.params
+	if params.PoWNoRetargeting {
+		return parent.header.Bits
+	}
+	interval := int32(params.TargetTimespan / params.TargetTimePerBlock)
+	if (parent.height+1)%interval != 0 {
+		if !params.ReduceMinDifficulty {
+			return parent.header.Bits
+		}
+		// Testnet allows a minimum difficulty block after a long gap; other
+		// blocks use the target of the last regular block
+		if timestamp.After(parent.header.Timestamp.Add(params.MinDiffReductionTime)) {
+			return params.PowLimitBits
+		}
+		n := parent
+		for n.parent != nil && n.height%interval != 0 && n.header.Bits == params.PowLimitBits {
+			n = n.parent
+		}
+		return n.header.Bits
+	}
+
+	first := parent.ancestor(parent.height - interval + 1)
+	timespan := int64(parent.header.Timestamp.Sub(first.header.Timestamp) / time.Second)
+	target := int64(params.TargetTimespan / time.Second)
+	minSpan := target / params.RetargetAdjustmentFactor
+	maxSpan := target * params.RetargetAdjustmentFactor
+	if timespan < minSpan {
+		timespan = minSpan
+	} else if timespan > maxSpan {
+		timespan = maxSpan
+	}
+	next := new(big.Int).Mul(blockchain.CompactToBig(parent.header.Bits), big.NewInt(timespan))
+	next.Div(next, big.NewInt(target))
+	if next.Cmp(params.PowLimit) > 0 {
+		next.Set(params.PowLimit)
+	}
+	return blockchain.BigToCompact(next)
+}
+
+// reorganize makes target the tip. It disconnects the main chain back to
+// the fork point and connects target's branch on a UTXO view, then commits
+// the view, undo data, transaction index and tip in one write. If a block
+// on the branch is invalid it and its descendants on the branch are marked
+// invalid and the main chain is left unchanged.
+func (c *Chain) reorganize(target *node) error {
+	tip := c.main[len(c.main)-1]
+	fork := target
+	for !c.inMain(fork) {
+		fork = fork.parent
+	}
+	var attach []*node
+	for n := target; n != fork; n = n.parent {
+		attach = append([]*node{n}, attach...)
+	}
+
+	v := newView(c)
+	batch := new(kv.Batch)
+	for n := tip; n != fork; n = n.parent {
+		if err := c.disconnectBlock(v, batch, n); err != nil {
+			return err
+		}
+	}
+	for i, n := range attach {
+		block, err := c.readBlock(n)
+		if err != nil {
+			return err
+		}
+		spent, err := c.connectBlock(v, n, block)
+		if err != nil {
+			c.markInvalid(attach[i:])
+			return fmt.Errorf("%w: %s: %v", ErrInvalidBlock, n.hash, err)
+		}
+		batch.Put(chainBucket, hashKey(prefixUndo, n.hash), encodeUndo(spent))
+		if c.opts.TxIndex {
+			for _, tx := range block.Transactions {
+				txid := tx.TxHash()
+				batch.Put(chainBucket, hashKey(prefixTxIndex, txid), n.hash[:])
+			}
+		}
+	}
+	v.commit(batch)
+	batch.Put(chainBucket, tipKey, target.hash[:])
+	if err := batch.Write(c.db); err != nil {
+		return fmt.Errorf("failed to connect block %s: %w", target.hash, err)
+	}
+
+	c.main = c.main[:fork.height+1]
+	c.main = append(c.main, attach...)
+	return nil
+}
+
+// markInvalid flags blocks as invalid in the index
+func (c *Chain) markInvalid(nodes []*node) {
+	batch := new(kv.Batch)
+	for _, n := range nodes {
+		n.status |= statusInvalid
+		batch.Put(chainBucket, hashKey(prefixIndex, n.hash), encodeIndex(n))
+	}
+	batch.Write(c.db)
+}
+
+// connectBlock checks a block against the UTXO view and applies it,
+// returning the coins it spent
+func (c *Chain) connectBlock(v *view, n *node, block *wire.MsgBlock) ([]*Coin, error) {
+	ublock := btcutil.NewBlock(block)
+	ublock.SetHeight(n.height)
+	if weight := blockchain.GetBlockWeight(ublock); weight > blockchain.MaxBlockWeight {
+		return nil, fmt.Errorf("block weight %d exceeds %d", weight, blockchain.MaxBlockWeight)
+	}
+	coinbase := ublock.Transactions()[0]
+	if height, err := blockchain.ExtractCoinbaseHeight(coinbase); err != nil || height != n.height {
+		return nil, fmt.Errorf("coinbase does not commit to height %d", n.height)
+	}
+	if err := blockchain.ValidateWitnessCommitment(ublock); err != nil {
+		return nil, err
+	}
+
+	mtp := n.parent.medianTime()
+	var spent []*Coin
+	var fees int64
+	for i, tx := range block.Transactions {
+		txid := tx.TxHash()
+		if !blockchain.IsFinalizedTransaction(ublock.Transactions()[i], n.height, mtp) {
+			return nil, fmt.Errorf("transaction %s is not final", txid)
+		}
+		if i > 0 {
+			fee, txSpent, err := c.spendInputs(v, n.height, tx)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s: %v", txid, err)
+			}
+			fees += fee
+			spent = append(spent, txSpent...)
+		}
+		for index, out := range tx.TxOut {
+			if txscript.IsUnspendable(out.PkScript) {
+				continue
+			}
+			op := wire.OutPoint{Hash: txid, Index: uint32(index)}
+			if existing, err := v.fetch(op); err != nil {
+				return nil, err
+			} else if existing != nil {
+				return nil, fmt.Errorf("transaction %s overwrites an unspent output", txid)
+			}
+			v.add(&Coin{OutPoint: op, TxOut: out, Height: n.height, Coinbase: i == 0})
+		}
+	}
+
+	var out int64
+	for _, txOut := range block.Transactions[0].TxOut {
+		out += txOut.Value
+	}
+	if limit := blockchain.CalcBlockSubsidy(n.height, c.params) + fees; out > limit {
+		return nil, fmt.Errorf("coinbase pays %s, more than subsidy and fees of %s", btcutil.Amount(out), btcutil.Amount(limit))
+	}
+	return spent, nil
+}
+
+// spendInputs checks a transaction's inputs and scripts at height, removes
+// the coins it spends from the view and returns its fee and those coins
+func (c *Chain) spendInputs(v *view, height int32, tx *wire.MsgTx) (int64, []*Coin, error) {
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	coins := make([]*Coin, len(tx.TxIn))
+	var in int64
+	for i, txIn := range tx.TxIn {
+		coin, err := v.fetch(txIn.PreviousOutPoint)
+		if err != nil {
+			return 0, nil, err
+		}
+		if coin == nil {
+			return 0, nil, fmt.Errorf("input %s missing or spent", txIn.PreviousOutPoint)
+		}
+		if coin.Coinbase && height-coin.Height < int32(c.params.CoinbaseMaturity) {
+			return 0, nil, fmt.Errorf("input %s spends a coinbase at depth %d", txIn.PreviousOutPoint, height-coin.Height)
+		}
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, coin.TxOut)
+		coins[i] = coin
+		in += coin.TxOut.Value
+	}
+	var out int64
+	for _, txOut := range tx.TxOut {
+		out += txOut.Value
+	}
+	if in < out {
+		return 0, nil, fmt.Errorf("value in %s is below value out %s", btcutil.Amount(in), btcutil.Amount(out))
+	}
+	if err := VerifyScripts(tx, fetcher); err != nil {
+		return 0, nil, err
+	}
+	for i, txIn := range tx.TxIn {
+		v.spend(txIn.PreviousOutPoint, coins[i].TxOut.PkScript)
+	}
+	return in - out, coins, nil
+}
+
+// VerifyScripts runs every input script of tx with ScriptFlags against the
+// outputs it spends
+func VerifyScripts(tx *wire.MsgTx, prevOuts txscript.PrevOutputFetcher) error {
+	sigHashes := txscript.NewTxSigHashes(tx, prevOuts)
+	for i, txIn := range tx.TxIn {
+		prevOut := prevOuts.FetchPrevOutput(txIn.PreviousOutPoint)
+		if prevOut == nil {
+			return fmt.Errorf("input %d: previous output unknown", i)
+		}
+		engine, err := txscript.NewEngine(prevOut.PkScript, tx, i, ScriptFlags, nil, sigHashes, prevOut.Value, prevOuts)
+		if err == nil {
+			err = engine.Execute()
+		}
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// disconnectBlock undoes a main chain block on the view, deleting its undo
+// data and transaction index entries
+func (c *Chain) disconnectBlock(v *view, batch *kv.Batch, n *node) error {
+	block, err := c.readBlock(n)
+	if errors.Is(err, ErrBlockPruned) {
+		return fmt.Errorf("%w: %s at height %d", ErrReorgTooDeep, n.hash, n.height)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := c.get(hashKey(prefixUndo, n.hash))
+	if err != nil {
+		return fmt.Errorf("failed to read undo data of %s: %w", n.hash, err)
+	}
+	spent, err := decodeUndo(data)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range block.Transactions {
+		txid := tx.TxHash()
+		for index, out := range tx.TxOut {
+			v.spend(wire.OutPoint{Hash: txid, Index: uint32(index)}, out.PkScript)
+		}
+		if c.opts.TxIndex {
+			batch.Delete(chainBucket, hashKey(prefixTxIndex, txid))
+		}
+	}
+	// Outputs created and spent within the block stay spent
+	for _, coin := range spent {
+		if coin.Height != n.height {
+			v.add(coin)
+		}
+	}
+	batch.Delete(chainBucket, hashKey(prefixUndo, n.hash))
+	return nil
+}
+
+// prune deletes the block and undo data of main chain blocks deeper than
+// the prune depth. Genesis is kept.
+func (c *Chain) prune() {
+	if c.opts.PruneDepth == 0 {
+		return
+	}
+	limit := int32(len(c.main)) - c.opts.PruneDepth
+	if c.pruned >= limit {
+		return
+	}
+	batch := new(kv.Batch)
+	for height := c.pruned; height < limit; height++ {
+		n := c.main[height]
+		n.status &^= statusData
+		batch.Put(chainBucket, hashKey(prefixIndex, n.hash), encodeIndex(n))
+		batch.Delete(chainBucket, hashKey(prefixBlock, n.hash))
+		batch.Delete(chainBucket, hashKey(prefixUndo, n.hash))
+	}
+	if err := batch.Write(c.db); err != nil {
+		for height := c.pruned; height < limit; height++ {
+			c.main[height].status |= statusData
+		}
+		return
+	}
+	c.pruned = limit
+}
+
+// view stages UTXO set changes on top of the database
+type view struct {
+	c *Chain
+	// coins holds changed coins; a nil coin is spent
+	coins map[wire.OutPoint]*Coin
+	// scripts holds the output scripts of spent coins, for the address index
+	scripts map[wire.OutPoint][]byte
+}
+
+func newView(c *Chain) *view {
+	return &view{c: c, coins: make(map[wire.OutPoint]*Coin), scripts: make(map[wire.OutPoint][]byte)}
+}
+
+// fetch returns an unspent coin from the view or the database
+func (v *view) fetch(op wire.OutPoint) (*Coin, error) {
+	if coin, ok := v.coins[op]; ok {
+		return coin, nil
+	}
+	return v.c.fetchCoin(op)
+}
+
+func (v *view) add(coin *Coin) {
+	v.coins[coin.OutPoint] = coin
+}
+
+func (v *view) spend(op wire.OutPoint, pkScript []byte) {
+	v.coins[op] = nil
+	v.scripts[op] = pkScript
+}
+
+// commit adds the view's changes to batch, and to the address index when
+// it is enabled
+func (v *view) commit(batch *kv.Batch) {
+	for op, coin := range v.coins {
+		if coin == nil {
+			batch.Delete(chainBucket, coinKey(op))
+			if v.c.opts.AddrIndex {
+				batch.Delete(chainBucket, addrKey(v.scripts[op], op))
+			}
+		} else {
+			batch.Put(chainBucket, coinKey(op), encodeCoin(coin))
+			if v.c.opts.AddrIndex {
+				batch.Put(chainBucket, addrKey(coin.TxOut.PkScript, op), encodeCoin(coin))
+			}
+		}
+	}
+}
+
+// NewBlock assembles and solves a block on the tip paying the subsidy and
+// fees to pkScript. Solving grinds the nonce, which is only practical at
+// regtest difficulty.
+func (c *Chain) NewBlock(pkScript []byte, txs []*wire.MsgTx, fees int64) (*wire.MsgBlock, error) {
+	block, height, err := c.BlockTemplate(pkScript, txs, fees)
+	if err != nil {
+		return nil, err
+	}
+
+	target := blockchain.CompactToBig(block.Header.Bits)
+	for nonce := uint32(0); ; nonce++ {
+		block.Header.Nonce = nonce
+		hash := block.Header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return block, nil
+		}
+		if nonce == ^uint32(0) {
+			return nil, fmt.Errorf("no nonce solves block %d", height)
+		}
+	}
+}
+
+// BlockTemplate assembles an unsolved block on the tip paying the subsidy
+// and fees to pkScript, and returns it with its height. The coinbase
+// commits to the height, and to the witnesses when txs have any; the
+// timestamp is now or just after the median time, and the bits are those
+// the chain requires then.
+func (c *Chain) BlockTemplate(pkScript []byte, txs []*wire.MsgTx, fees int64) (*wire.MsgBlock, int32, error) {
+	c.mu.RLock()
+	parent := c.main[len(c.main)-1]
+	c.mu.RUnlock()
+	height := parent.height + 1
+
+	sigScript, err := txscript.NewScriptBuilder().AddInt64(int64(height)).AddInt64(0).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+		SignatureScript:  sigScript,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(blockchain.CalcBlockSubsidy(height, c.params)+fees, pkScript))
+
+	utxs := []*btcutil.Tx{btcutil.NewTx(coinbase)}
+	witness := false
+	for _, tx := range txs {
+		utxs = append(utxs, btcutil.NewTx(tx))
+		witness = witness || tx.HasWitness()
+	}
+	if witness {
+		mining.AddWitnessCommitment(utxs[0], utxs)
+	}
+
+	timestamp := time.Now().Truncate(time.Second)
+	if mtp := parent.medianTime(); !timestamp.After(mtp) {
+		timestamp = mtp.Add(time.Second)
+	}
+	block := &wire.MsgBlock{Header: wire.BlockHeader{
+		Version:    0x20000000,
+		PrevBlock:  parent.hash,
+		MerkleRoot: blockchain.CalcMerkleRoot(utxs, false),
+		Timestamp:  timestamp,
+		Bits:       c.requiredBits(parent, timestamp),
+	}}
+	for _, tx := range utxs {
+		block.AddTransaction(tx.MsgTx())
+	}
+	return block, height, nil
+}
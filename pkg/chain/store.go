@@ -0,0 +1,89 @@
+package chain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// chainBucket holds every chain record, keyed as described in encoding.go
+const chainBucket = "chain"
+
+// migrateBatch is the number of records moved per write when upgrading a
+// legacy database
+const migrateBatch = 10000
+
+// openStore opens the backend chosen by opts at path
+func openStore(path string, opts Options) (kv.Store, error) {
+	if opts.Backend != "" && opts.Backend != kv.BackendLevelDB {
+		return kv.Open(opts.Backend, path)
+	}
+	db, err := kv.OpenLevelDB(path, opts.CacheSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateLegacy(db.DB()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrateLegacy moves the records of a LevelDB database written before the
+// chain bucket existed into the bucket. Legacy keys are 3, 33 or 37 bytes
+// long and bucket keys longer, and the tip moves last, so an interrupted
+// migration resumes on the next open.
+func migrateLegacy(db *leveldb.DB) error {
+	if ok, err := db.Has(tipKey, nil); err != nil || !ok {
+		return err
+	}
+	prefix := []byte(chainBucket + "\x00")
+	for {
+		batch := new(leveldb.Batch)
+		iter := db.NewIterator(nil, nil)
+		for iter.Next() && batch.Len() < 2*migrateBatch {
+			key := iter.Key()
+			if bytes.HasPrefix(key, prefix) || bytes.Equal(key, tipKey) || len(key) > 1+chainhash.HashSize+4 {
+				continue
+			}
+			batch.Put(append(bytes.Clone(prefix), key...), iter.Value())
+			batch.Delete(bytes.Clone(key))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return fmt.Errorf("failed to migrate chain database: %w", err)
+		}
+		if batch.Len() == 0 {
+			break
+		}
+		if err := db.Write(batch, nil); err != nil {
+			return fmt.Errorf("failed to migrate chain database: %w", err)
+		}
+	}
+
+	tip, err := db.Get(tipKey, nil)
+	if err != nil {
+		return fmt.Errorf("failed to migrate chain database: %w", err)
+	}
+	batch := new(leveldb.Batch)
+	batch.Put(append(prefix, tipKey...), tip)
+	batch.Delete(tipKey)
+	if err := db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to migrate chain database: %w", err)
+	}
+	return nil
+}
+
+// get reads a chain record
+func (c *Chain) get(key []byte) ([]byte, error) {
+	return kv.Get(c.db, chainBucket, key)
+}
+
+// iterate walks the chain records starting with prefix
+func (c *Chain) iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return kv.Iterate(c.db, chainBucket, prefix, fn)
+}
@@ -0,0 +1,313 @@
+// Package chaos injects faults into HTTP servers and peer connections so
+// operators can check how clients cope with slow, dropped and malformed
+// responses before a real incident does it for them. Nothing is injected
+// unless a spec is given, and a nil Injector is a no-op.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EnvVar names the environment variable FromEnv reads the spec from
+const EnvVar = "EXS_CHAOS"
+
+// ErrDropped is returned by reads and writes on a connection the injector
+// dropped
+var ErrDropped = errors.New("chaos: connection dropped")
+
+// Config sets the faults to inject. Rates are probabilities from 0 to 1,
+// drawn per HTTP request or per connection read and write.
+type Config struct {
+	// Delay is the longest delay injected; each delay is uniform up to it
+	Delay time.Duration
+	// DelayRate is how often a delay is injected, 1 when Delay is set and
+	// DelayRate is not
+	DelayRate float64
+	// DropRate is how often the connection is closed without a response
+	DropRate float64
+	// CorruptRate is how often a response or written frame has a byte
+	// flipped
+	CorruptRate float64
+	// Seed makes the faults repeatable, 0 for a random seed
+	Seed uint64
+}
+
+// Parse reads a spec of comma-separated key=value pairs: delay (a
+// duration), delay_rate, drop, corrupt and seed, e.g.
+// "delay=250ms,drop=0.01,corrupt=0.01". An empty spec injects nothing.
+func Parse(spec string) (Config, error) {
+	var cfg Config
+	delayRateSet := false
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("chaos: %q is not key=value", field)
+		}
+		var err error
+		switch strings.TrimSpace(key) {
+		case "delay":
+			cfg.Delay, err = time.ParseDuration(value)
+			if err == nil && cfg.Delay < 0 {
+				err = errors.New("negative")
+			}
+		case "delay_rate":
+			cfg.DelayRate, err = parseRate(value)
+			delayRateSet = true
+		case "drop":
+			cfg.DropRate, err = parseRate(value)
+		case "corrupt":
+			cfg.CorruptRate, err = parseRate(value)
+		case "seed":
+			cfg.Seed, err = strconv.ParseUint(value, 10, 64)
+		default:
+			return Config{}, fmt.Errorf("chaos: unknown key %q (use delay, delay_rate, drop, corrupt or seed)", key)
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("chaos: invalid %s %q: %v", key, value, err)
+		}
+	}
+	if cfg.Delay > 0 && !delayRateSet {
+		cfg.DelayRate = 1
+	}
+	return cfg, nil
+}
+
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, errors.New("must be between 0 and 1")
+	}
+	return rate, nil
+}
+
+// String formats the config as a spec Parse accepts
+func (c Config) String() string {
+	var fields []string
+	if c.Delay > 0 {
+		fields = append(fields, "delay="+c.Delay.String())
+		if c.DelayRate != 1 {
+			fields = append(fields, "delay_rate="+strconv.FormatFloat(c.DelayRate, 'g', -1, 64))
+		}
+	}
+	if c.DropRate > 0 {
+		fields = append(fields, "drop="+strconv.FormatFloat(c.DropRate, 'g', -1, 64))
+	}
+	if c.CorruptRate > 0 {
+		fields = append(fields, "corrupt="+strconv.FormatFloat(c.CorruptRate, 'g', -1, 64))
+	}
+	if c.Seed != 0 {
+		fields = append(fields, "seed="+strconv.FormatUint(c.Seed, 10))
+	}
+	return strings.Join(fields, ",")
+}
+
+// Stats counts the faults injected
+type Stats struct {
+	Delayed   uint64 `json:"delayed"`
+	Dropped   uint64 `json:"dropped"`
+	Corrupted uint64 `json:"corrupted"`
+}
+
+// Injector injects the faults of a Config. It is safe for concurrent use.
+type Injector struct {
+	cfg Config
+
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	delayed   atomic.Uint64
+	dropped   atomic.Uint64
+	corrupted atomic.Uint64
+}
+
+// New creates an injector for cfg. It returns nil, which injects nothing,
+// when cfg has no faults.
+func New(cfg Config) *Injector {
+	if (cfg.Delay <= 0 || cfg.DelayRate <= 0) && cfg.DropRate <= 0 && cfg.CorruptRate <= 0 {
+		return nil
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Injector{cfg: cfg, rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// FromEnv creates an injector from the spec in EXS_CHAOS, nil when it is
+// unset
+func FromEnv() (*Injector, error) {
+	cfg, err := Parse(os.Getenv(EnvVar))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", EnvVar, err)
+	}
+	return New(cfg), nil
+}
+
+// Config returns the injector's configuration
+func (i *Injector) Config() Config {
+	if i == nil {
+		return Config{}
+	}
+	return i.cfg
+}
+
+// Stats returns the faults injected so far
+func (i *Injector) Stats() Stats {
+	if i == nil {
+		return Stats{}
+	}
+	return Stats{Delayed: i.delayed.Load(), Dropped: i.dropped.Load(), Corrupted: i.corrupted.Load()}
+}
+
+// roll reports whether an event of probability rate happens
+func (i *Injector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < rate
+}
+
+// delay returns a delay to inject, or 0
+func (i *Injector) delay() time.Duration {
+	if i.cfg.Delay <= 0 || !i.roll(i.cfg.DelayRate) {
+		return 0
+	}
+	i.delayed.Add(1)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return time.Duration(i.rng.Int64N(int64(i.cfg.Delay)) + 1)
+}
+
+func (i *Injector) drop() bool {
+	if !i.roll(i.cfg.DropRate) {
+		return false
+	}
+	i.dropped.Add(1)
+	return true
+}
+
+// corrupt returns a copy of p with one byte flipped, or p itself
+func (i *Injector) corrupt(p []byte) []byte {
+	if len(p) == 0 || !i.roll(i.cfg.CorruptRate) {
+		return p
+	}
+	i.corrupted.Add(1)
+	i.mu.Lock()
+	at := i.rng.IntN(len(p))
+	i.mu.Unlock()
+	out := make([]byte, len(p))
+	copy(out, p)
+	out[at] ^= 0xff
+	return out
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// Middleware delays requests, drops them by aborting the connection before
+// a response is written, and corrupts response bodies. Upgraded
+// connections such as WebSockets are delayed and dropped but not
+// corrupted. A nil injector returns h unchanged.
+func (i *Injector) Middleware(h http.Handler) http.Handler {
+	if i == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sleep(r.Context(), i.delay())
+		if i.drop() {
+			// The server closes the connection without writing a response
+			panic(http.ErrAbortHandler)
+		}
+		if r.Header.Get("Upgrade") == "" {
+			w = &corruptWriter{ResponseWriter: w, injector: i}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// corruptWriter corrupts response body writes
+type corruptWriter struct {
+	http.ResponseWriter
+	injector *Injector
+}
+
+func (w *corruptWriter) Write(p []byte) (int, error) {
+	// A corrupted copy is the same length, so the count still holds for p
+	return w.ResponseWriter.Write(w.injector.corrupt(p))
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *corruptWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Conn wraps conn so reads and writes are delayed, the connection is
+// dropped at random and written frames are corrupted. A nil injector
+// returns conn unchanged.
+func (i *Injector) Conn(conn net.Conn) net.Conn {
+	if i == nil {
+		return conn
+	}
+	return &faultyConn{Conn: conn, injector: i}
+}
+
+type faultyConn struct {
+	net.Conn
+	injector *Injector
+}
+
+func (c *faultyConn) Read(p []byte) (int, error) {
+	if err := c.fault(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *faultyConn) Write(p []byte) (int, error) {
+	if err := c.fault(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(c.injector.corrupt(p))
+}
+
+// fault delays the next read or write, or drops the connection
+func (c *faultyConn) fault() error {
+	if d := c.injector.delay(); d > 0 {
+		time.Sleep(d)
+	}
+	if c.injector.drop() {
+		c.Conn.Close()
+		return ErrDropped
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package chaos
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse("delay=250ms, drop=0.01,corrupt=0.5,seed=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{Delay: 250 * time.Millisecond, DelayRate: 1, DropRate: 0.01, CorruptRate: 0.5, Seed: 7}
+	if cfg != want {
+		t.Errorf("Parse() = %+v, expected %+v", cfg, want)
+	}
+	if again, err := Parse(cfg.String()); err != nil || again != cfg {
+		t.Errorf("Parse(%q) = %+v, %v", cfg.String(), again, err)
+	}
+
+	for _, spec := range []string{"delay", "drop=2", "corrupt=-0.1", "delay=-1s", "jitter=1s"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+	if cfg, err := Parse(""); err != nil || New(cfg) != nil {
+		t.Errorf("An empty spec should inject nothing, got %+v, %v", cfg, err)
+	}
+}
+
+func TestNilInjector(t *testing.T) {
+	var injector *Injector
+	h := http.NotFoundHandler()
+	if injector.Middleware(h) == nil {
+		t.Error("Middleware() of a nil injector should return the handler")
+	}
+	a, b := net.Pipe()
+	defer b.Close()
+	if injector.Conn(a) != a {
+		t.Error("Conn() of a nil injector should return the connection")
+	}
+	if injector.Stats() != (Stats{}) {
+		t.Error("A nil injector should have no stats")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	body := []byte(`{"status":"healthy"}`)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	})
+
+	dropper := New(Config{DropRate: 1})
+	server := httptest.NewServer(dropper.Middleware(handler))
+	defer server.Close()
+	if resp, err := http.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected a dropped request to fail, got %s", resp.Status)
+	}
+	if dropper.Stats().Dropped == 0 {
+		t.Error("Expected the drop to be counted")
+	}
+
+	corrupter := New(Config{CorruptRate: 1, Seed: 1})
+	server = httptest.NewServer(corrupter.Middleware(handler))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(got) != len(body) || string(got) == string(body) {
+		t.Errorf("Expected a corrupted body of %d bytes, got %q", len(body), got)
+	}
+
+	delayer := New(Config{Delay: 20 * time.Millisecond, DelayRate: 1})
+	server = httptest.NewServer(delayer.Middleware(handler))
+	defer server.Close()
+	resp, err = http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(got) != string(body) || delayer.Stats().Delayed != 1 {
+		t.Errorf("Expected an intact delayed response, got %q and %+v", got, delayer.Stats())
+	}
+}
+
+func TestConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	conn := New(Config{CorruptRate: 1, Seed: 1}).Conn(a)
+	defer conn.Close()
+
+	frame := []byte("version")
+	go conn.Write(frame)
+	got := make([]byte, len(frame))
+	if _, err := io.ReadFull(b, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) == string(frame) {
+		t.Error("Expected the written frame to be corrupted")
+	}
+	if string(frame) != "version" {
+		t.Error("Corruption must not modify the caller's buffer")
+	}
+
+	a, b = net.Pipe()
+	defer b.Close()
+	conn = New(Config{DropRate: 1}).Conn(a)
+	if _, err := conn.Write(frame); !errors.Is(err, ErrDropped) {
+		t.Errorf("Expected ErrDropped, got %v", err)
+	}
+	if _, err := b.Read(got); err != io.EOF {
+		t.Errorf("Expected the peer to see the connection closed, got %v", err)
+	}
+}
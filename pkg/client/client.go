@@ -0,0 +1,252 @@
+// Package client is a Go SDK for the Excalibur-EXS HTTP APIs: the Rosetta
+// API exchanges integrate against, the treasury and the Tetra-PoW miner.
+// Every call takes a context, sends the configured bearer token and
+// retries idempotent requests that fail with a network error, 429 or a
+// 5xx the server marks as temporary.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetries is how often a failed idempotent request is retried
+	// when Options.Retries is 0
+	DefaultRetries = 3
+	// DefaultBackoff is the wait before the first retry when
+	// Options.Backoff is 0; it doubles on each further retry
+	DefaultBackoff = 500 * time.Millisecond
+
+	// maxBackoff caps the wait between retries, including a server's
+	// Retry-After
+	maxBackoff = 10 * time.Second
+	// maxErrorBody bounds the error response read into Error.Message
+	maxErrorBody = 4 << 10
+)
+
+// ErrUnauthorized matches an Error for a missing, expired or insufficient
+// token (401 or 403)
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Options configure a client
+type Options struct {
+	// HTTPClient defaults to a client with a 30 second timeout
+	HTTPClient *http.Client
+	// Token is sent as a bearer token when set
+	Token string
+	// Retries is how often a failed idempotent request is retried,
+	// DefaultRetries when 0 and never when negative
+	Retries int
+	// Backoff is the wait before the first retry, DefaultBackoff when 0
+	Backoff time.Duration
+	// UserAgent is sent when set
+	UserAgent string
+}
+
+// Error is an API's refusal of a request
+type Error struct {
+	// StatusCode is the HTTP status
+	StatusCode int
+	// Message is the server's explanation
+	Message string
+	// Rosetta is the error a Rosetta API answered with, if any
+	Rosetta *RosettaError
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is matches ErrUnauthorized for 401 and 403 responses
+func (e *Error) Is(target error) bool {
+	return target == ErrUnauthorized && (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden)
+}
+
+// Temporary reports whether the same request may succeed later
+func (e *Error) Temporary() bool {
+	if e.Rosetta != nil {
+		return e.Rosetta.Retriable
+	}
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// base carries what every API client shares: the server, the HTTP client,
+// the bearer token and the retry policy
+type base struct {
+	url       string
+	http      *http.Client
+	retries   int
+	backoff   time.Duration
+	userAgent string
+
+	mu    sync.RWMutex
+	token string
+}
+
+func newBase(baseURL string, opts Options) *base {
+	b := &base{
+		url:       strings.TrimSuffix(baseURL, "/"),
+		http:      opts.HTTPClient,
+		retries:   opts.Retries,
+		backoff:   opts.Backoff,
+		userAgent: opts.UserAgent,
+		token:     opts.Token,
+	}
+	if b.http == nil {
+		b.http = &http.Client{Timeout: 30 * time.Second}
+	}
+	if b.retries == 0 {
+		b.retries = DefaultRetries
+	}
+	if b.backoff <= 0 {
+		b.backoff = DefaultBackoff
+	}
+	return b
+}
+
+// SetToken replaces the bearer token sent with each request, "" to send
+// none
+func (b *base) SetToken(token string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.token = token
+}
+
+// Token returns the bearer token sent with each request
+func (b *base) Token() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.token
+}
+
+// call sends in as JSON, or no body when in is nil, and decodes the JSON
+// response into out unless out is nil. Only idempotent calls are retried.
+func (b *base) call(ctx context.Context, method, path string, in, out interface{}, idempotent bool) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	attempts := 1
+	if idempotent && b.retries > 0 {
+		attempts += b.retries
+	}
+	backoff := b.backoff
+	for attempt := 1; ; attempt++ {
+		wait, err := b.do(ctx, method, path, body, out)
+		if err == nil || attempt == attempts || wait < 0 {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(min(wait, maxBackoff)):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// do makes one attempt. It returns how long to wait before retrying, 0 for
+// the client's backoff or -1 when the failure is permanent.
+func (b *base) do(ctx context.Context, method, path string, body []byte, out interface{}) (time.Duration, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.url+path, reader)
+	if err != nil {
+		return -1, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if token := b.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if b.userAgent != "" {
+		req.Header.Set("User-Agent", b.userAgent)
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		return 0, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := decodeError(resp)
+		if !apiErr.Temporary() {
+			return -1, apiErr
+		}
+		return retryAfter(resp), apiErr
+	}
+	if out == nil {
+		return 0, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return -1, fmt.Errorf("invalid response from %s: %w", path, err)
+	}
+	return 0, nil
+}
+
+// decodeError reads a refusal: a Rosetta error, a {"error"} body or plain
+// text
+func decodeError(resp *http.Response) *Error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	var rosetta RosettaError
+	if json.Unmarshal(raw, &rosetta) == nil && rosetta.Message != "" {
+		apiErr.Rosetta = &rosetta
+		apiErr.Message = rosetta.Message
+		if rosetta.Description != "" {
+			apiErr.Message += ": " + rosetta.Description
+		}
+		return apiErr
+	}
+	var guardian struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(raw, &guardian) == nil && guardian.Error != "" {
+		apiErr.Message = guardian.Error
+		return apiErr
+	}
+	apiErr.Message = strings.TrimSpace(string(raw))
+	return apiErr
+}
+
+// retryAfter returns the wait a Retry-After header in seconds asks for, or 0
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
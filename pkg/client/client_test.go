@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+)
+
+var fast = Options{Backoff: time.Millisecond}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "ledger busy", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"treasury_balance": 7.5, "total_forges": 1})
+	}))
+	defer server.Close()
+
+	treasury := NewTreasury(server.URL, fast)
+	stats, err := treasury.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.TreasuryBalance != economy.TreasuryAllocation || stats.TotalForges != 1 || calls.Load() != 3 {
+		t.Errorf("Stats() = %+v after %d calls", stats, calls.Load())
+	}
+
+	// A forge is not idempotent, so a failure is returned at once
+	calls.Store(0)
+	_, err = treasury.Forge(context.Background(), "bc1pminer", nil)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Message != "ledger busy" {
+		t.Errorf("Forge() error = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Forge() was sent %d times", calls.Load())
+	}
+
+	// Nor is a request retried once its context is done
+	calls.Store(-100)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	slow := NewTreasury(server.URL, Options{Retries: 100, Backoff: time.Second})
+	if _, err := slow.Stats(ctx); err == nil || calls.Load() != -99 {
+		t.Errorf("Stats() error = %v after %d calls", err, calls.Load()+100)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/claims":
+			if r.Header.Get("Authorization") != "Bearer arthur" {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "missing token"})
+				return
+			}
+			json.NewEncoder(w).Encode([]economy.Claim{{ID: 1, Amount: 5 * economy.Coin / 2}})
+		case "/construction/submit":
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(RosettaError{Code: 14, Message: "Unable to broadcast transaction", Retriable: true, Description: "no peers"})
+		}
+	}))
+	defer server.Close()
+
+	treasury := NewTreasury(server.URL, fast)
+	if _, err := treasury.Claims(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Claims() error = %v, expected ErrUnauthorized", err)
+	}
+	treasury.SetToken("arthur")
+	claims, err := treasury.Claims(context.Background())
+	if err != nil || len(claims) != 1 || claims[0].Amount != 250_000_000 {
+		t.Errorf("Claims() = %+v, %v", claims, err)
+	}
+
+	rosetta := NewRosetta(server.URL, "regtest", Options{Retries: -1})
+	_, err = rosetta.Submit(context.Background(), "00")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Rosetta == nil || apiErr.Rosetta.Code != 14 || !apiErr.Temporary() {
+		t.Fatalf("Submit() error = %#v", err)
+	}
+	if apiErr.Error() != "500 Internal Server Error: Unable to broadcast transaction: no peers" {
+		t.Errorf("Error() = %q", apiErr.Error())
+	}
+}
+
+func TestRosettaRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+			AccountIdentifier AccountIdentifier `json:"account_identifier"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Method != http.MethodPost || req.NetworkIdentifier != (NetworkIdentifier{Blockchain, "regtest"}) {
+			http.Error(w, "wrong network", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(AccountBalance{
+			BlockIdentifier: BlockIdentifier{Index: 7, Hash: "00ff"},
+			Balances:        []Amount{*NewAmount(4250000000)},
+		})
+	}))
+	defer server.Close()
+
+	balance, err := NewRosetta(server.URL, "regtest", fast).AccountBalance(context.Background(), "bcrt1pdeposit")
+	if err != nil {
+		t.Fatalf("AccountBalance() error = %v", err)
+	}
+	if sats, err := balance.Balances[0].Sats(); err != nil || sats != 4250000000 || balance.BlockIdentifier.Index != 7 {
+		t.Errorf("AccountBalance() = %+v", balance)
+	}
+}
+
+func TestSignPayloads(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := bitcoin.DeriveTaprootAddress(key.PubKey().SerializeCompressed(), &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := btcutil.DecodeAddress(address, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputKey, err := schnorr.ParsePubKey(decoded.ScriptAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := make([]byte, 32)
+	hash[0] = 1
+	payload := SigningPayload{HexBytes: hex.EncodeToString(hash), SignatureType: SignatureSchnorr340}
+	sigs, err := SignPayloads(key, []SigningPayload{payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := hex.DecodeString(sigs[0].HexBytes)
+	sig, err := schnorr.ParseSignature(raw)
+	if err != nil || !sig.Verify(hash, outputKey) {
+		t.Errorf("Signature does not verify against %s's output key", address)
+	}
+
+	payload.SignatureType = "ecdsa"
+	if _, err := SignPayloads(key, []SigningPayload{payload}); err == nil {
+		t.Error("Expected an error for an ECDSA payload")
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/consensus"
+)
+
+// MineRequest starts a mining round
+type MineRequest struct {
+	Height    uint32 `json:"height"`
+	Nonce     uint64 `json:"nonce"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// MiningResult is the outcome of a mining round
+type MiningResult struct {
+	Success      bool   `json:"success"`
+	Height       uint32 `json:"height"`
+	Epoch        int    `json:"epoch"`
+	BlockHash    string `json:"block_hash,omitempty"`
+	Nonce        uint64 `json:"nonce"`
+	Difficulty   int    `json:"difficulty"`
+	Timestamp    int64  `json:"timestamp"`
+	Attempts     uint64 `json:"attempts"`
+	VaultAddress string `json:"vault_address,omitempty"`
+	// TreasuryAlloc is the treasury's share of a found block in EXS
+	TreasuryAlloc float64 `json:"treasury_alloc,omitempty"`
+}
+
+// MinerStats are the miner's counters since it started
+type MinerStats struct {
+	TotalAttempts uint64
+	ValidBlocks   uint64
+	Hashrate      float64
+	LastBlockTime time.Time
+	StartTime     time.Time
+}
+
+// MinerConfig is the miner's configuration
+type MinerConfig struct {
+	Difficulty    int                `json:"difficulty"`
+	QuantumRounds int                `json:"quantum_rounds"`
+	PBKDF2Iters   int                `json:"pbkdf2_iters"`
+	TreasuryURL   string             `json:"treasury_url"`
+	RosettaURL    string             `json:"rosetta_url"`
+	Epochs        consensus.Schedule `json:"epochs"`
+}
+
+// Miner is a client of the Tetra-PoW miner API
+type Miner struct {
+	*base
+}
+
+// NewMiner creates a client of the miner at baseURL, e.g.
+// "http://localhost:8082"
+func NewMiner(baseURL string, opts Options) *Miner {
+	return &Miner{base: newBase(baseURL, opts)}
+}
+
+// Health returns the miner's health
+func (m *Miner) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := m.call(ctx, http.MethodGet, "/health", nil, &health, true); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Mine runs a mining round. It needs a Knight token when the miner
+// requires one, and is retried: a round only searches, so running it again
+// finds the same block.
+func (m *Miner) Mine(ctx context.Context, req MineRequest) (*MiningResult, error) {
+	var result MiningResult
+	if err := m.call(ctx, http.MethodPost, "/mine", req, &result, true); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Stats returns the miner's counters
+func (m *Miner) Stats(ctx context.Context) (*MinerStats, error) {
+	var stats MinerStats
+	if err := m.call(ctx, http.MethodGet, "/stats", nil, &stats, true); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Config returns the miner's configuration
+func (m *Miner) Config(ctx context.Context) (*MinerConfig, error) {
+	var config MinerConfig
+	if err := m.call(ctx, http.MethodGet, "/config", nil, &config, true); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
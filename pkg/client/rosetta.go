@@ -0,0 +1,587 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Rosetta operation types, coin actions and key and signature types
+const (
+	OpTypeInput  = "INPUT"
+	OpTypeOutput = "OUTPUT"
+
+	CoinSpent   = "coin_spent"
+	CoinCreated = "coin_created"
+
+	CurveSecp256k1      = "secp256k1"
+	SignatureSchnorr340 = "schnorr_bip340"
+)
+
+// Blockchain is the blockchain name Rosetta servers identify EXS networks by
+const Blockchain = "Excalibur-ESX"
+
+// EXS is the currency of every Rosetta amount
+var EXS = Currency{Symbol: "EXS", Decimals: 8}
+
+// NetworkIdentifier names a network served by a Rosetta server
+type NetworkIdentifier struct {
+	Blockchain string `json:"blockchain"`
+	Network    string `json:"network"`
+}
+
+// RosettaError is the error body of a Rosetta API
+type RosettaError struct {
+	Code        int32  `json:"code"`
+	Message     string `json:"message"`
+	Retriable   bool   `json:"retriable"`
+	Description string `json:"description,omitempty"`
+}
+
+// Version is the Rosetta and node version of a server
+type Version struct {
+	RosettaVersion    string `json:"rosetta_version"`
+	NodeVersion       string `json:"node_version"`
+	MiddlewareVersion string `json:"middleware_version,omitempty"`
+}
+
+// OperationStatus is a status operations may have
+type OperationStatus struct {
+	Status     string `json:"status"`
+	Successful bool   `json:"successful"`
+}
+
+// NetworkOptions lists what a Rosetta server supports
+type NetworkOptions struct {
+	Version Version `json:"version"`
+	Allow   struct {
+		OperationStatuses []OperationStatus `json:"operation_statuses"`
+		OperationTypes    []string          `json:"operation_types"`
+		Errors            []RosettaError    `json:"errors"`
+		MempoolCoins      bool              `json:"mempool_coins"`
+	} `json:"allow"`
+}
+
+// BlockIdentifier names a block
+type BlockIdentifier struct {
+	Index int64  `json:"index"`
+	Hash  string `json:"hash"`
+}
+
+// PartialBlockIdentifier selects a block by index or hash; neither selects
+// the tip
+type PartialBlockIdentifier struct {
+	Index *int64  `json:"index,omitempty"`
+	Hash  *string `json:"hash,omitempty"`
+}
+
+// Peer is a peer of the Rosetta server's node
+type Peer struct {
+	PeerID string `json:"peer_id"`
+}
+
+// NetworkStatus is the chain state of a Rosetta server
+type NetworkStatus struct {
+	CurrentBlockIdentifier BlockIdentifier `json:"current_block_identifier"`
+	// CurrentBlockTimestamp is in milliseconds
+	CurrentBlockTimestamp  int64           `json:"current_block_timestamp"`
+	GenesisBlockIdentifier BlockIdentifier `json:"genesis_block_identifier"`
+	Peers                  []Peer          `json:"peers"`
+}
+
+// AccountIdentifier names an account by address
+type AccountIdentifier struct {
+	Address string `json:"address"`
+}
+
+// Currency is a Rosetta currency
+type Currency struct {
+	Symbol   string `json:"symbol"`
+	Decimals int32  `json:"decimals"`
+}
+
+// Amount is a Rosetta amount: Value is in the currency's smallest unit,
+// exs-satoshis for EXS
+type Amount struct {
+	Value    string   `json:"value"`
+	Currency Currency `json:"currency"`
+}
+
+// NewAmount returns an EXS amount of sats exs-satoshis
+func NewAmount(sats int64) *Amount {
+	return &Amount{Value: strconv.FormatInt(sats, 10), Currency: EXS}
+}
+
+// Sats returns the amount in its smallest unit
+func (a Amount) Sats() (int64, error) {
+	return strconv.ParseInt(a.Value, 10, 64)
+}
+
+// AccountBalance is an account's balances at a block
+type AccountBalance struct {
+	BlockIdentifier BlockIdentifier `json:"block_identifier"`
+	Balances        []Amount        `json:"balances"`
+}
+
+// OperationIdentifier orders operations within a transaction
+type OperationIdentifier struct {
+	Index int64 `json:"index"`
+}
+
+// CoinIdentifier names a coin as "txid:vout"
+type CoinIdentifier struct {
+	Identifier string `json:"identifier"`
+}
+
+// Coin is an unspent output of an account. Its metadata has height and
+// coinbase for confirmed coins, mempool for unconfirmed ones, and spent_by
+// when an unconfirmed transaction spends it.
+type Coin struct {
+	CoinIdentifier CoinIdentifier         `json:"coin_identifier"`
+	Amount         Amount                 `json:"amount"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// AccountCoins is an account's coins at a block
+type AccountCoins struct {
+	BlockIdentifier BlockIdentifier `json:"block_identifier"`
+	Coins           []Coin          `json:"coins"`
+}
+
+// CoinChange is a coin an operation creates or spends
+type CoinChange struct {
+	CoinIdentifier CoinIdentifier `json:"coin_identifier"`
+	CoinAction     string         `json:"coin_action"`
+}
+
+// Operation is one input or output of a transaction
+type Operation struct {
+	OperationIdentifier OperationIdentifier `json:"operation_identifier"`
+	Type                string              `json:"type"`
+	Status              string              `json:"status,omitempty"`
+	Account             *AccountIdentifier  `json:"account,omitempty"`
+	Amount              *Amount             `json:"amount,omitempty"`
+	CoinChange          *CoinChange         `json:"coin_change,omitempty"`
+	// Metadata has the script_type of the coin, and spend_path, "key" or
+	// "script", of a signed Taproot input
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// TransactionIdentifier names a transaction by hash
+type TransactionIdentifier struct {
+	Hash string `json:"hash"`
+}
+
+// Transaction is a transaction in a block or the mempool
+type Transaction struct {
+	TransactionIdentifier TransactionIdentifier `json:"transaction_identifier"`
+	Operations            []Operation           `json:"operations"`
+}
+
+// Block is a block and its transactions
+type Block struct {
+	BlockIdentifier       BlockIdentifier `json:"block_identifier"`
+	ParentBlockIdentifier BlockIdentifier `json:"parent_block_identifier"`
+	Timestamp             int64           `json:"timestamp"`
+	Transactions          []Transaction   `json:"transactions"`
+}
+
+// Block event types
+const (
+	BlockAdded   = "block_added"
+	BlockRemoved = "block_removed"
+)
+
+// BlockEvent is a block joining or leaving the main chain
+type BlockEvent struct {
+	Sequence        int64           `json:"sequence"`
+	BlockIdentifier BlockIdentifier `json:"block_identifier"`
+	Type            string          `json:"type"`
+}
+
+// BlockEvents is a page of block events
+type BlockEvents struct {
+	// MaxSequence is the newest event's sequence, -1 without events
+	MaxSequence int64        `json:"max_sequence"`
+	Events      []BlockEvent `json:"events"`
+}
+
+// TransactionSearch selects transactions by /search/transactions. Set
+// conditions must all match with the "and" Operator, the default, and at
+// least one with "or".
+type TransactionSearch struct {
+	Operator              string                 `json:"operator,omitempty"`
+	MaxBlock              *int64                 `json:"max_block,omitempty"`
+	Offset                int64                  `json:"offset,omitempty"`
+	Limit                 int64                  `json:"limit,omitempty"`
+	TransactionIdentifier *TransactionIdentifier `json:"transaction_identifier,omitempty"`
+	AccountIdentifier     *AccountIdentifier     `json:"account_identifier,omitempty"`
+	CoinIdentifier        *CoinIdentifier        `json:"coin_identifier,omitempty"`
+	Currency              *Currency              `json:"currency,omitempty"`
+	Status                string                 `json:"status,omitempty"`
+	Type                  string                 `json:"type,omitempty"`
+	Address               string                 `json:"address,omitempty"`
+	Success               *bool                  `json:"success,omitempty"`
+}
+
+// BlockTransaction is a transaction and the block containing it
+type BlockTransaction struct {
+	BlockIdentifier BlockIdentifier `json:"block_identifier"`
+	Transaction     Transaction     `json:"transaction"`
+}
+
+// TransactionSearchResult is a page of matching transactions, newest first
+type TransactionSearchResult struct {
+	Transactions []BlockTransaction `json:"transactions"`
+	TotalCount   int64              `json:"total_count"`
+	// NextOffset is the offset of the next page, nil on the last
+	NextOffset *int64 `json:"next_offset,omitempty"`
+}
+
+// PublicKey is a public key in hex
+type PublicKey struct {
+	HexBytes  string `json:"hex_bytes"`
+	CurveType string `json:"curve_type"`
+}
+
+// SigningPayload is a hash an account must sign
+type SigningPayload struct {
+	AccountIdentifier *AccountIdentifier `json:"account_identifier,omitempty"`
+	HexBytes          string             `json:"hex_bytes"`
+	SignatureType     string             `json:"signature_type"`
+}
+
+// Signature signs a SigningPayload
+type Signature struct {
+	SigningPayload SigningPayload `json:"signing_payload"`
+	PublicKey      PublicKey      `json:"public_key"`
+	SignatureType  string         `json:"signature_type"`
+	HexBytes       string         `json:"hex_bytes"`
+}
+
+// Metadata is the construction metadata a transaction is built with
+type Metadata struct {
+	Metadata     map[string]interface{} `json:"metadata"`
+	SuggestedFee []Amount               `json:"suggested_fee"`
+}
+
+// Payloads is an unsigned transaction and the payloads to sign
+type Payloads struct {
+	UnsignedTransaction string           `json:"unsigned_transaction"`
+	Payloads            []SigningPayload `json:"payloads"`
+}
+
+// ParsedTransaction is the operations of a transaction and, once signed,
+// its signers
+type ParsedTransaction struct {
+	Operations               []Operation         `json:"operations"`
+	AccountIdentifierSigners []AccountIdentifier `json:"account_identifier_signers,omitempty"`
+}
+
+// Rosetta is a client of a Rosetta API server for one network
+type Rosetta struct {
+	*base
+	network NetworkIdentifier
+}
+
+// NewRosetta creates a client of the Rosetta server at baseURL, e.g.
+// "http://localhost:8081", for network (mainnet, testnet or regtest)
+func NewRosetta(baseURL, network string, opts Options) *Rosetta {
+	return &Rosetta{
+		base:    newBase(baseURL, opts),
+		network: NetworkIdentifier{Blockchain: Blockchain, Network: network},
+	}
+}
+
+// Network returns the network the client's requests name
+func (r *Rosetta) Network() NetworkIdentifier {
+	return r.network
+}
+
+// post calls a Rosetta endpoint. Every Rosetta call is idempotent: the
+// Data API only reads, and submitting a signed transaction twice relays
+// the same transaction.
+func (r *Rosetta) post(ctx context.Context, path string, in, out interface{}) error {
+	return r.call(ctx, http.MethodPost, path, in, out, true)
+}
+
+// NetworkList returns the networks the server serves
+func (r *Rosetta) NetworkList(ctx context.Context) ([]NetworkIdentifier, error) {
+	var resp struct {
+		NetworkIdentifiers []NetworkIdentifier `json:"network_identifiers"`
+	}
+	if err := r.post(ctx, "/network/list", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.NetworkIdentifiers, nil
+}
+
+// NetworkOptions returns the server's versions, operation types and errors
+func (r *Rosetta) NetworkOptions(ctx context.Context) (*NetworkOptions, error) {
+	var resp NetworkOptions
+	if err := r.post(ctx, "/network/options", r.networkRequest(), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// NetworkStatus returns the server's tip, genesis and peers
+func (r *Rosetta) NetworkStatus(ctx context.Context) (*NetworkStatus, error) {
+	var resp NetworkStatus
+	if err := r.post(ctx, "/network/status", r.networkRequest(), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AccountBalance returns the balance of a Taproot address at the tip
+func (r *Rosetta) AccountBalance(ctx context.Context, address string) (*AccountBalance, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		AccountIdentifier AccountIdentifier `json:"account_identifier"`
+	}{r.network, AccountIdentifier{Address: address}}
+	var resp AccountBalance
+	if err := r.post(ctx, "/account/balance", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AccountCoins returns the unspent outputs paying address, with those of
+// unconfirmed transactions when includeMempool is set
+func (r *Rosetta) AccountCoins(ctx context.Context, address string, includeMempool bool) (*AccountCoins, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		AccountIdentifier AccountIdentifier `json:"account_identifier"`
+		IncludeMempool    bool              `json:"include_mempool"`
+	}{r.network, AccountIdentifier{Address: address}, includeMempool}
+	var resp AccountCoins
+	if err := r.post(ctx, "/account/coins", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Block returns the block id selects
+func (r *Rosetta) Block(ctx context.Context, id PartialBlockIdentifier) (*Block, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+		BlockIdentifier   PartialBlockIdentifier `json:"block_identifier"`
+	}{r.network, id}
+	var resp struct {
+		Block Block `json:"block"`
+	}
+	if err := r.post(ctx, "/block", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Block, nil
+}
+
+// Mempool returns the identifiers of the node's unconfirmed transactions
+func (r *Rosetta) Mempool(ctx context.Context) ([]TransactionIdentifier, error) {
+	var resp struct {
+		TransactionIdentifiers []TransactionIdentifier `json:"transaction_identifiers"`
+	}
+	if err := r.post(ctx, "/mempool", r.networkRequest(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.TransactionIdentifiers, nil
+}
+
+// MempoolTransaction returns an unconfirmed transaction as operations, to
+// track a deposit before it confirms
+func (r *Rosetta) MempoolTransaction(ctx context.Context, hash string) (*Transaction, error) {
+	req := struct {
+		NetworkIdentifier     NetworkIdentifier     `json:"network_identifier"`
+		TransactionIdentifier TransactionIdentifier `json:"transaction_identifier"`
+	}{r.network, TransactionIdentifier{Hash: hash}}
+	var resp struct {
+		Transaction Transaction `json:"transaction"`
+	}
+	if err := r.post(ctx, "/mempool/transaction", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Transaction, nil
+}
+
+// BlockEvents returns up to limit block events from sequence offset; a
+// limit of 0 uses the server's default
+func (r *Rosetta) BlockEvents(ctx context.Context, offset, limit int64) (*BlockEvents, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		Offset            int64             `json:"offset"`
+		Limit             int64             `json:"limit,omitempty"`
+	}{r.network, offset, limit}
+	var resp BlockEvents
+	if err := r.post(ctx, "/events/blocks", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SearchTransactions returns the main chain transactions matching search
+func (r *Rosetta) SearchTransactions(ctx context.Context, search TransactionSearch) (*TransactionSearchResult, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		TransactionSearch
+	}{r.network, search}
+	var resp TransactionSearchResult
+	if err := r.post(ctx, "/search/transactions", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Derive returns the Taproot address of a public key
+func (r *Rosetta) Derive(ctx context.Context, key PublicKey) (string, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		PublicKey         PublicKey         `json:"public_key"`
+	}{r.network, key}
+	var resp struct {
+		AccountIdentifier AccountIdentifier `json:"account_identifier"`
+	}
+	if err := r.post(ctx, "/construction/derive", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.AccountIdentifier.Address, nil
+}
+
+// Preprocess returns the options to fetch the metadata of ops with
+func (r *Rosetta) Preprocess(ctx context.Context, ops []Operation) (map[string]interface{}, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		Operations        []Operation       `json:"operations"`
+	}{r.network, ops}
+	var resp struct {
+		Options map[string]interface{} `json:"options"`
+	}
+	if err := r.post(ctx, "/construction/preprocess", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Options, nil
+}
+
+// Metadata returns the fee rate and suggested fee for Preprocess's options
+func (r *Rosetta) Metadata(ctx context.Context, options map[string]interface{}) (*Metadata, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+		Options           map[string]interface{} `json:"options"`
+	}{r.network, options}
+	var resp Metadata
+	if err := r.post(ctx, "/construction/metadata", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Payloads builds the unsigned transaction of ops and the payloads to sign
+func (r *Rosetta) Payloads(ctx context.Context, ops []Operation, metadata map[string]interface{}) (*Payloads, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+		Operations        []Operation            `json:"operations"`
+		Metadata          map[string]interface{} `json:"metadata,omitempty"`
+	}{r.network, ops, metadata}
+	var resp Payloads
+	if err := r.post(ctx, "/construction/payloads", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Parse returns the operations of an unsigned or signed transaction
+func (r *Rosetta) Parse(ctx context.Context, tx string, signed bool) (*ParsedTransaction, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		Signed            bool              `json:"signed"`
+		Transaction       string            `json:"transaction"`
+	}{r.network, signed, tx}
+	var resp ParsedTransaction
+	if err := r.post(ctx, "/construction/parse", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Combine attaches signatures, one per payload, to an unsigned transaction
+func (r *Rosetta) Combine(ctx context.Context, unsigned string, sigs []Signature) (string, error) {
+	req := struct {
+		NetworkIdentifier   NetworkIdentifier `json:"network_identifier"`
+		UnsignedTransaction string            `json:"unsigned_transaction"`
+		Signatures          []Signature       `json:"signatures"`
+	}{r.network, unsigned, sigs}
+	var resp struct {
+		SignedTransaction string `json:"signed_transaction"`
+	}
+	if err := r.post(ctx, "/construction/combine", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.SignedTransaction, nil
+}
+
+// Hash returns the hash of a signed transaction
+func (r *Rosetta) Hash(ctx context.Context, signed string) (string, error) {
+	return r.signedTransaction(ctx, "/construction/hash", signed)
+}
+
+// Submit relays a signed transaction and returns its hash
+func (r *Rosetta) Submit(ctx context.Context, signed string) (string, error) {
+	return r.signedTransaction(ctx, "/construction/submit", signed)
+}
+
+func (r *Rosetta) signedTransaction(ctx context.Context, path, signed string) (string, error) {
+	req := struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		SignedTransaction string            `json:"signed_transaction"`
+	}{r.network, signed}
+	var resp struct {
+		TransactionIdentifier TransactionIdentifier `json:"transaction_identifier"`
+	}
+	if err := r.post(ctx, path, req, &resp); err != nil {
+		return "", err
+	}
+	return resp.TransactionIdentifier.Hash, nil
+}
+
+// SignPayloads signs Schnorr payloads for the key-path spend of the Taproot
+// address Derive returns for key's public key
+func SignPayloads(key *btcec.PrivateKey, payloads []SigningPayload) ([]Signature, error) {
+	tweaked := txscript.TweakTaprootPrivKey(*key, nil)
+	pubKey := PublicKey{
+		HexBytes:  hex.EncodeToString(key.PubKey().SerializeCompressed()),
+		CurveType: CurveSecp256k1,
+	}
+	sigs := make([]Signature, len(payloads))
+	for i, payload := range payloads {
+		if payload.SignatureType != SignatureSchnorr340 {
+			return nil, fmt.Errorf("payload %d: unsupported signature type %q", i, payload.SignatureType)
+		}
+		hash, err := hex.DecodeString(payload.HexBytes)
+		if err != nil {
+			return nil, fmt.Errorf("payload %d: %w", i, err)
+		}
+		sig, err := schnorr.Sign(tweaked, hash)
+		if err != nil {
+			return nil, fmt.Errorf("payload %d: %w", i, err)
+		}
+		sigs[i] = Signature{
+			SigningPayload: payload,
+			PublicKey:      pubKey,
+			SignatureType:  SignatureSchnorr340,
+			HexBytes:       hex.EncodeToString(sig.Serialize()),
+		}
+	}
+	return sigs, nil
+}
+
+func (r *Rosetta) networkRequest() interface{} {
+	return struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+	}{r.network}
+}
@@ -0,0 +1,380 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/guardian"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/webhook"
+)
+
+// Health is a service's /health answer
+type Health struct {
+	Status  string `json:"status"`
+	Service string `json:"service,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Halted reports an emergency halt of the treasury
+	Halted bool   `json:"halted,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TreasuryStats are the treasury's totals
+type TreasuryStats struct {
+	TreasuryBalance     economy.Amount `json:"treasury_balance"`
+	SpendableBalance    economy.Amount `json:"spendable_balance"`
+	LockedBalance       economy.Amount `json:"locked_balance"`
+	SpentBalance        economy.Amount `json:"spent_balance"`
+	TotalFeesCollected  economy.Amount `json:"total_fees_collected"`
+	TotalForges         int            `json:"total_forges"`
+	CurrentBlockHeight  uint32         `json:"current_block_height"`
+	ForgeFeePoolBTC     float64        `json:"forge_fee_pool_btc"`
+	TotalMinted         economy.Amount `json:"total_minted"`
+	PercentageMinted    float64        `json:"percentage_minted"`
+	SupplyCap           economy.Amount `json:"supply_cap"`
+	ForgeReward         economy.Amount `json:"forge_reward"`
+	TreasuryAllocation  economy.Amount `json:"treasury_allocation"`
+	DistributionsCount  int            `json:"distributions_count"`
+	MiniOutputsTotal    int            `json:"mini_outputs_total"`
+	MiniOutputAmount    economy.Amount `json:"mini_output_amount"`
+	MiniOutputsPerBlock int            `json:"mini_outputs_per_block"`
+	BlockInterval       int            `json:"block_interval"`
+	HalvingInterval     int            `json:"halving_interval"`
+	TailEmission        economy.Amount `json:"tail_emission"`
+}
+
+// TreasuryBalance is the treasury's own balance
+type TreasuryBalance struct {
+	TotalBalance     economy.Amount `json:"total_balance"`
+	SpendableBalance economy.Amount `json:"spendable_balance"`
+	LockedBalance    economy.Amount `json:"locked_balance"`
+	// ForgeFeePool is in BTC
+	ForgeFeePool float64 `json:"forge_fee_pool"`
+}
+
+// MiniOutputs are the treasury's CLTV-locked mini-outputs
+type MiniOutputs struct {
+	MiniOutputs []economy.TreasuryMiniOutput `json:"mini_outputs"`
+	TotalCount  int                          `json:"total_count"`
+	Spendable   []economy.TreasuryMiniOutput `json:"spendable_mini_outputs"`
+	Locked      []economy.TreasuryMiniOutput `json:"locked_mini_outputs"`
+}
+
+// Unlockable is a treasury's /unlockable answer
+type Unlockable struct {
+	BlockHeight uint32                     `json:"block_height"`
+	Outputs     []economy.UnlockableOutput `json:"outputs"`
+	Total       economy.Amount             `json:"total"`
+}
+
+// PaymentProof is the transaction paying a forge payment and the merkle
+// proof of its block. Hashes are in the byte-reversed hex Bitcoin displays
+// them in.
+type PaymentProof struct {
+	RawTx       string   `json:"raw_tx"`
+	BlockHash   string   `json:"block_hash"`
+	MerkleProof []string `json:"merkle_proof"`
+	Position    int      `json:"position"`
+}
+
+// Session is a Guardian session a login or refresh issues
+type Session struct {
+	Token            string        `json:"token"`
+	Role             guardian.Role `json:"role"`
+	ExpiresAt        time.Time     `json:"expires_at"`
+	RefreshToken     string        `json:"refresh_token,omitempty"`
+	RefreshExpiresAt *time.Time    `json:"refresh_expires_at,omitempty"`
+}
+
+// Treasury is a client of the treasury API
+type Treasury struct {
+	*base
+}
+
+// NewTreasury creates a client of the treasury at baseURL, e.g.
+// "http://localhost:8080"
+func NewTreasury(baseURL string, opts Options) *Treasury {
+	return &Treasury{base: newBase(baseURL, opts)}
+}
+
+func (t *Treasury) get(ctx context.Context, path string, out interface{}) error {
+	return t.call(ctx, http.MethodGet, path, nil, out, true)
+}
+
+// Health returns the treasury's health; an unhealthy ledger is an Error
+// with status 503
+func (t *Treasury) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := t.get(ctx, "/health", &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Stats returns the treasury's totals
+func (t *Treasury) Stats(ctx context.Context) (*TreasuryStats, error) {
+	var stats TreasuryStats
+	if err := t.get(ctx, "/stats", &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Revenue returns the revenue the treasury was credited with per stream
+func (t *Treasury) Revenue(ctx context.Context) ([]economy.RevenueStream, error) {
+	var streams []economy.RevenueStream
+	if err := t.get(ctx, "/revenue", &streams); err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+// CrossChainReward is a payout of coins mined on another chain through an
+// external pool, credited to the treasury as EXS
+type CrossChainReward struct {
+	// Chain is the mined coin, e.g. "ltc"
+	Chain string `json:"chain"`
+	// Index numbers the chain's payouts from 1
+	Index uint64 `json:"index"`
+	// Units is the payout in the coin's smallest unit
+	Units     int64          `json:"units"`
+	Amount    economy.Amount `json:"amount"`
+	TxID      string         `json:"txid,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// CreditCrossChain credits a cross-chain payout and returns the chain's
+// revenue totals. It needs a Knight token; a payout already credited is an
+// Error with status 409.
+func (t *Treasury) CreditCrossChain(ctx context.Context, reward CrossChainReward) (*economy.RevenueStream, error) {
+	var stream economy.RevenueStream
+	if err := t.call(ctx, http.MethodPost, "/revenue/cross-chain", reward, &stream, true); err != nil {
+		return nil, err
+	}
+	return &stream, nil
+}
+
+// Balance returns the treasury's balance
+func (t *Treasury) Balance(ctx context.Context) (*TreasuryBalance, error) {
+	var balance TreasuryBalance
+	if err := t.get(ctx, "/balance", &balance); err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// MiniOutputs returns the treasury's mini-outputs
+func (t *Treasury) MiniOutputs(ctx context.Context) (*MiniOutputs, error) {
+	var outputs MiniOutputs
+	if err := t.get(ctx, "/mini-outputs", &outputs); err != nil {
+		return nil, err
+	}
+	return &outputs, nil
+}
+
+// Unlockable returns the spendable mini-outputs with their scripts
+func (t *Treasury) Unlockable(ctx context.Context) (*Unlockable, error) {
+	var unlockable Unlockable
+	if err := t.get(ctx, "/unlockable", &unlockable); err != nil {
+		return nil, err
+	}
+	return &unlockable, nil
+}
+
+// Leaderboard returns the top limit miners, or the server's default number
+// when limit is 0
+func (t *Treasury) Leaderboard(ctx context.Context, limit int) (*economy.Leaderboard, error) {
+	path := "/leaderboard"
+	if limit > 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+	var board economy.Leaderboard
+	if err := t.get(ctx, path, &board); err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+// Emergency returns the treasury's emergency halt state
+func (t *Treasury) Emergency(ctx context.Context) (*guardian.HaltState, error) {
+	var state guardian.HaltState
+	if err := t.get(ctx, "/emergency", &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Forge records a forge, crediting the miner reward to split or, without a
+// split, to minerAddress. It needs a Knight token and is not retried, since
+// a retry could record the forge twice.
+func (t *Treasury) Forge(ctx context.Context, minerAddress string, split economy.RewardSplit) (*economy.ForgeResult, error) {
+	req := struct {
+		MinerAddress  string              `json:"miner_address"`
+		Beneficiaries economy.RewardSplit `json:"beneficiaries,omitempty"`
+	}{minerAddress, split}
+	if len(split) > 0 {
+		req.MinerAddress = split[0].Address
+	}
+	var result economy.ForgeResult
+	if err := t.call(ctx, http.MethodPost, "/forge", req, &result, false); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Claim submits a signed claim for a proof of forge. A proof is paid once,
+// so a claim is not retried; a 409 Error means it was already paid.
+func (t *Treasury) Claim(ctx context.Context, req *economy.ClaimRequest) (*economy.Claim, error) {
+	var claim economy.Claim
+	if err := t.call(ctx, http.MethodPost, "/claim", req, &claim, false); err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// Claims returns the paid claims. It needs a King Arthur token.
+func (t *Treasury) Claims(ctx context.Context) ([]economy.Claim, error) {
+	var claims []economy.Claim
+	if err := t.get(ctx, "/claims", &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// WebhookDeliveries returns the latest webhook delivery attempts. It needs
+// a King Arthur token.
+func (t *Treasury) WebhookDeliveries(ctx context.Context) ([]webhook.Attempt, error) {
+	var attempts []webhook.Attempt
+	if err := t.get(ctx, "/webhooks/deliveries", &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// Distributions returns the treasury's distributions. It needs a King
+// Arthur token.
+func (t *Treasury) Distributions(ctx context.Context) ([]economy.Distribution, error) {
+	var distributions []economy.Distribution
+	if err := t.get(ctx, "/distributions", &distributions); err != nil {
+		return nil, err
+	}
+	return distributions, nil
+}
+
+// Proposals returns the distribution proposals. It needs a King Arthur
+// token.
+func (t *Treasury) Proposals(ctx context.Context) ([]economy.Proposal, error) {
+	var proposals []economy.Proposal
+	if err := t.get(ctx, "/proposals", &proposals); err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+// Proposal returns distribution proposal id. It needs a King Arthur token.
+func (t *Treasury) Proposal(ctx context.Context, id string) (*economy.Proposal, error) {
+	var proposal economy.Proposal
+	if err := t.get(ctx, "/proposals/"+url.PathEscape(id), &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// Propose creates a distribution proposal for the signers to approve. It
+// needs a King Arthur token and is not retried, since a retry could create
+// a second proposal.
+func (t *Treasury) Propose(ctx context.Context, amount economy.Amount, recipient, purpose string) (*economy.Proposal, error) {
+	req := struct {
+		Amount    economy.Amount `json:"amount"`
+		Recipient string         `json:"recipient"`
+		Purpose   string         `json:"purpose"`
+	}{amount, recipient, purpose}
+	var proposal economy.Proposal
+	if err := t.call(ctx, http.MethodPost, "/proposals", req, &proposal, false); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// ApproveProposal submits a signer's approval of proposal id, signed with
+// economy.SignProposal. The approval that meets the threshold pays the
+// distribution. It needs a King Arthur token and is not retried.
+func (t *Treasury) ApproveProposal(ctx context.Context, id, signer string, signature []byte) (*economy.Proposal, error) {
+	req := struct {
+		Signer    string `json:"signer"`
+		Signature string `json:"signature"`
+	}{signer, hex.EncodeToString(signature)}
+	var proposal economy.Proposal
+	if err := t.call(ctx, http.MethodPost, "/proposals/"+url.PathEscape(id)+"/approve", req, &proposal, false); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// RequestPayment creates a forge fee payment for a miner or claimant
+// address. While the treasury requires payments, each forge or claim by
+// address needs one, paid and verified.
+func (t *Treasury) RequestPayment(ctx context.Context, address string) (*economy.ForgePayment, error) {
+	req := struct {
+		Address string `json:"address"`
+	}{address}
+	var payment economy.ForgePayment
+	if err := t.call(ctx, http.MethodPost, "/payments", req, &payment, false); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Payment returns forge payment id
+func (t *Treasury) Payment(ctx context.Context, id string) (*economy.ForgePayment, error) {
+	var payment economy.ForgePayment
+	if err := t.get(ctx, "/payments/"+url.PathEscape(id), &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// VerifyPayment submits the transaction paying forge payment id. A payment
+// still pending has too few confirmations; the treasury verifies it again
+// as blocks arrive. Verifying is idempotent, so it is retried.
+func (t *Treasury) VerifyPayment(ctx context.Context, id string, proof PaymentProof) (*economy.ForgePayment, error) {
+	var payment economy.ForgePayment
+	if err := t.call(ctx, http.MethodPost, "/payments/"+url.PathEscape(id)+"/verify", proof, &payment, true); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Login exchanges credentials for a session and sends its token from then
+// on
+func (t *Treasury) Login(ctx context.Context, creds guardian.Credentials) (*Session, error) {
+	req := struct {
+		Username  string `json:"username"`
+		Password  string `json:"password"`
+		TOTPCode  string `json:"totp_code,omitempty"`
+		Challenge string `json:"challenge,omitempty"`
+	}{creds.Username, creds.Password, creds.TOTPCode, creds.Challenge}
+	return t.session(ctx, "/auth/login", req)
+}
+
+// Refresh exchanges a refresh token for a new session and sends its token
+// from then on
+func (t *Treasury) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+	req := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{refreshToken}
+	return t.session(ctx, "/auth/refresh", req)
+}
+
+func (t *Treasury) session(ctx context.Context, path string, req interface{}) (*Session, error) {
+	var session Session
+	if err := t.call(ctx, http.MethodPost, path, req, &session, false); err != nil {
+		return nil, err
+	}
+	t.SetToken(session.Token)
+	return &session, nil
+}
@@ -0,0 +1,254 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/consensus"
+)
+
+const (
+	// WarnSkew is the offset from NTP or peer time at which the node warns
+	// that its clock is off
+	WarnSkew = 5 * time.Minute
+	// MaxSkew is the largest offset at which the node still mines: half of
+	// consensus.MaxFutureBlockTime, leaving the other half for the clocks of
+	// the peers that validate its blocks
+	MaxSkew = consensus.MaxFutureBlockTime * time.Second / 2
+	// MinPeerSamples is how many connected peers must report their time
+	// before their median is compared with the local clock
+	MinPeerSamples = 5
+)
+
+// ErrClockSkew indicates a local clock too far from NTP or peer time to
+// produce valid block timestamps
+var ErrClockSkew = errors.New("local clock skew exceeds consensus tolerance")
+
+// peerCheckInterval is how often Run compares the clock with peer time
+const peerCheckInterval = time.Minute
+
+// Config configures a Monitor
+type Config struct {
+	// NTPServers are queried every Interval; empty disables NTP checks
+	NTPServers []string
+	Interval   time.Duration // between NTP checks by Run, at least a minute
+	Timeout    time.Duration // per NTP check
+
+	// PeerOffset returns the median clock offset of connected peers and
+	// how many reported one; nil disables the peer comparison
+	PeerOffset func() (time.Duration, int)
+}
+
+// DefaultConfig checks DefaultNTPServers every 30 minutes
+func DefaultConfig() *Config {
+	return &Config{
+		NTPServers: DefaultNTPServers,
+		Interval:   30 * time.Minute,
+		Timeout:    5 * time.Second,
+	}
+}
+
+// Level is how far the local clock is off
+type Level int
+
+const (
+	// LevelOK is a clock within WarnSkew, or one that cannot be checked
+	LevelOK Level = iota
+	// LevelWarn is a clock more than WarnSkew off
+	LevelWarn
+	// LevelRefuse is a clock more than MaxSkew off, at which mining stops
+	LevelRefuse
+)
+
+// Status is the latest view of the local clock. Offsets are reference time
+// minus local time, so a positive offset means the local clock is behind.
+type Status struct {
+	NTPOffset    time.Duration `json:"ntp_offset"`
+	NTPServers   int           `json:"ntp_servers"` // that answered
+	NTPCheckedAt *time.Time    `json:"ntp_checked_at,omitempty"`
+	NTPError     string        `json:"ntp_error,omitempty"`
+	PeerOffset   time.Duration `json:"peer_offset"`
+	PeerSamples  int           `json:"peer_samples"`
+}
+
+// Offset returns the offset that decides whether the node mines and where
+// it came from: NTP when a server answered, since peers can lie about their
+// time, otherwise the peer median once MinPeerSamples peers reported.
+// ok is false when neither is known.
+func (s *Status) Offset() (offset time.Duration, source string, ok bool) {
+	switch {
+	case s.NTPServers > 0:
+		return s.NTPOffset, "NTP", true
+	case s.PeerSamples >= MinPeerSamples:
+		return s.PeerOffset, "peers", true
+	}
+	return 0, "", false
+}
+
+// Err returns ErrClockSkew when the offset exceeds MaxSkew. A clock that
+// cannot be checked is given the benefit of the doubt.
+func (s *Status) Err() error {
+	offset, source, ok := s.Offset()
+	if !ok || offset.Abs() <= MaxSkew {
+		return nil
+	}
+	return fmt.Errorf("%w: local clock is %s %s %s time (tolerance %s)",
+		ErrClockSkew, offset.Abs().Round(time.Second), direction(offset), source, MaxSkew)
+}
+
+// Warnings describes each source the local clock is more than WarnSkew
+// away from
+func (s *Status) Warnings() []string {
+	var warnings []string
+	if s.NTPServers > 0 && s.NTPOffset.Abs() > WarnSkew {
+		warnings = append(warnings, fmt.Sprintf("local clock is %s %s NTP time",
+			s.NTPOffset.Abs().Round(time.Second), direction(s.NTPOffset)))
+	}
+	if s.PeerSamples >= MinPeerSamples && s.PeerOffset.Abs() > WarnSkew {
+		warnings = append(warnings, fmt.Sprintf("local clock is %s %s the median of %d peers",
+			s.PeerOffset.Abs().Round(time.Second), direction(s.PeerOffset), s.PeerSamples))
+	}
+	return warnings
+}
+
+// Level returns how far the local clock is off
+func (s *Status) Level() Level {
+	switch {
+	case s.Err() != nil:
+		return LevelRefuse
+	case len(s.Warnings()) > 0:
+		return LevelWarn
+	}
+	return LevelOK
+}
+
+// String summarizes the status in one line
+func (s *Status) String() string {
+	var parts []string
+	if s.NTPServers > 0 {
+		parts = append(parts, fmt.Sprintf("NTP %s", FormatOffset(s.NTPOffset)))
+	} else if s.NTPError != "" {
+		parts = append(parts, "NTP unavailable")
+	}
+	if s.PeerSamples > 0 {
+		parts = append(parts, fmt.Sprintf("peers %s (%d)", FormatOffset(s.PeerOffset), s.PeerSamples))
+	}
+	if len(parts) == 0 {
+		return "unchecked"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// direction describes a local clock offset from reference time
+func direction(offset time.Duration) string {
+	if offset > 0 {
+		return "behind"
+	}
+	return "ahead of"
+}
+
+// FormatOffset formats a clock offset with its sign, to the millisecond
+func FormatOffset(offset time.Duration) string {
+	if offset >= 0 {
+		return "+" + offset.Round(time.Millisecond).String()
+	}
+	return offset.Round(time.Millisecond).String()
+}
+
+// Monitor keeps track of the local clock's offset from NTP servers and
+// peers. It is safe for concurrent use.
+type Monitor struct {
+	config Config
+
+	mu  sync.RWMutex
+	ntp Status
+}
+
+// NewMonitor creates a monitor; nil uses DefaultConfig
+func NewMonitor(config *Config) *Monitor {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Monitor{config: *config}
+}
+
+// Status returns the latest NTP check together with the current peer median
+func (m *Monitor) Status() Status {
+	m.mu.RLock()
+	status := m.ntp
+	m.mu.RUnlock()
+	if m.config.PeerOffset != nil {
+		status.PeerOffset, status.PeerSamples = m.config.PeerOffset()
+	}
+	return status
+}
+
+// CheckMining returns ErrClockSkew while the clock is too far off to
+// timestamp blocks
+func (m *Monitor) CheckMining() error {
+	status := m.Status()
+	return status.Err()
+}
+
+// CheckNTP queries the NTP servers and records the median offset. A failed
+// check forgets the previous offset rather than trusting a stale one.
+func (m *Monitor) CheckNTP(ctx context.Context) error {
+	if len(m.config.NTPServers) == 0 {
+		return nil
+	}
+	if m.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.config.Timeout)
+		defer cancel()
+	}
+	samples, err := MeasureNTP(ctx, m.config.NTPServers)
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ntp = Status{NTPCheckedAt: &now}
+	if err != nil {
+		m.ntp.NTPError = err.Error()
+		return err
+	}
+	offsets := make([]time.Duration, len(samples))
+	for i, sample := range samples {
+		offsets[i] = sample.Offset
+	}
+	m.ntp.NTPOffset = MedianOffset(offsets)
+	m.ntp.NTPServers = len(samples)
+	return nil
+}
+
+// Run checks NTP now and every Interval, and the peer median every minute,
+// until ctx is done. notify is called with the status whenever its Level
+// changes, including the first time the clock is found to be off.
+func (m *Monitor) Run(ctx context.Context, notify func(Status)) {
+	ntpTicker := time.NewTicker(max(m.config.Interval, peerCheckInterval))
+	defer ntpTicker.Stop()
+	peerTicker := time.NewTicker(peerCheckInterval)
+	defer peerTicker.Stop()
+
+	m.CheckNTP(ctx)
+	level := LevelOK
+	for {
+		status := m.Status()
+		if next := status.Level(); next != level {
+			level = next
+			if notify != nil {
+				notify(status)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ntpTicker.C:
+			m.CheckNTP(ctx)
+		case <-peerTicker.C:
+		}
+	}
+}
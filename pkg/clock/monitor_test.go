@@ -0,0 +1,93 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatusLevels(t *testing.T) {
+	tests := []struct {
+		name   string
+		status Status
+		want   Level
+	}{
+		{"unchecked", Status{}, LevelOK},
+		{"in sync", Status{NTPOffset: time.Second, NTPServers: 3}, LevelOK},
+		{"NTP warning", Status{NTPOffset: -10 * time.Minute, NTPServers: 3}, LevelWarn},
+		{"NTP refusal", Status{NTPOffset: 2 * time.Hour, NTPServers: 1}, LevelRefuse},
+		{"few peers ignored", Status{PeerOffset: 3 * time.Hour, PeerSamples: MinPeerSamples - 1}, LevelOK},
+		{"peer refusal", Status{PeerOffset: 3 * time.Hour, PeerSamples: MinPeerSamples}, LevelRefuse},
+		// NTP is trusted over peers, whose warning still shows
+		{"NTP overrides peers", Status{NTPServers: 3, PeerOffset: 3 * time.Hour, PeerSamples: 8}, LevelWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.Level(); got != tt.want {
+				t.Errorf("Level() = %d, want %d", got, tt.want)
+			}
+			if err := tt.status.Err(); (tt.want == LevelRefuse) != errors.Is(err, ErrClockSkew) {
+				t.Errorf("Err() = %v", err)
+			}
+		})
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	peers := 0
+	m := NewMonitor(&Config{
+		NTPServers: []string{fakeNTPServer(t, -MaxSkew-time.Minute, 2)},
+		Timeout:    time.Second,
+		PeerOffset: func() (time.Duration, int) { return time.Second, peers },
+	})
+	if err := m.CheckMining(); err != nil {
+		t.Fatalf("Expected an unchecked clock to be allowed to mine, got %v", err)
+	}
+
+	if err := m.CheckNTP(ctx); err != nil {
+		t.Fatalf("CheckNTP() error = %v", err)
+	}
+	if err := m.CheckMining(); !errors.Is(err, ErrClockSkew) {
+		t.Fatalf("Expected ErrClockSkew, got %v", err)
+	}
+
+	// With NTP unreachable the peers decide
+	m.config.NTPServers = []string{fakeNTPServer(t, 0, 0)}
+	if err := m.CheckNTP(ctx); !errors.Is(err, ErrNTPUnavailable) {
+		t.Fatalf("Expected ErrNTPUnavailable, got %v", err)
+	}
+	peers = MinPeerSamples
+	status := m.Status()
+	if status.NTPError == "" || status.PeerSamples != MinPeerSamples {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if err := m.CheckMining(); err != nil {
+		t.Errorf("Expected peers in sync to allow mining, got %v", err)
+	}
+}
+
+func TestMonitorRunNotifies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMonitor(&Config{
+		NTPServers: []string{fakeNTPServer(t, 10*time.Minute, 2)},
+		Interval:   time.Hour,
+		Timeout:    time.Second,
+	})
+	notified := make(chan Status, 1)
+	go m.Run(ctx, func(s Status) { notified <- s })
+
+	select {
+	case s := <-notified:
+		if s.Level() != LevelWarn || len(s.Warnings()) != 1 {
+			t.Errorf("Expected one warning, got %+v", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not report the skewed clock")
+	}
+}
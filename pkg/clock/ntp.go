@@ -0,0 +1,153 @@
+// Package clock checks the local clock against NTP servers and the clocks
+// of connected peers. Block timestamps come from the local clock and blocks
+// more than consensus.MaxFutureBlockTime ahead of a node's clock are
+// rejected, so a node whose clock is far off mines blocks its peers refuse.
+package clock
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// ntpPacketSize is the size of an SNTP request and response
+const ntpPacketSize = 48
+
+// ntpEpochOffset is the number of seconds from the NTP epoch (1900) to the
+// Unix epoch
+const ntpEpochOffset = 2208988800
+
+// DefaultNTPServers are queried when the configuration names none
+var DefaultNTPServers = []string{"pool.ntp.org", "time.google.com", "time.cloudflare.com"}
+
+// ErrNTPUnavailable indicates that no NTP server answered
+var ErrNTPUnavailable = errors.New("no NTP server answered")
+
+// Sample is one NTP server's view of the local clock
+type Sample struct {
+	Server string
+	// Offset is the server's time minus the local time; positive means
+	// the local clock is behind
+	Offset time.Duration
+	// RTT is the network round trip, excluding the server's processing
+	RTT time.Duration
+}
+
+// QueryNTP asks server, a host with an optional port (default 123), for the
+// time using SNTP version 4
+func QueryNTP(ctx context.Context, server string) (*Sample, error) {
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, "123")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", server, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// LI 0, version 4, mode 3 (client). The transmit timestamp is echoed
+	// back as the originate timestamp, tying the response to this request.
+	req := make([]byte, ntpPacketSize)
+	req[0] = 0<<6 | 4<<3 | 3
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTPTime(sent))
+	if _, err := conn.Write(req); err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", server, err)
+	}
+
+	resp := make([]byte, ntpPacketSize)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return nil, fmt.Errorf("no answer from %s: %w", server, err)
+	}
+	received := time.Now()
+	switch {
+	case n < ntpPacketSize:
+		return nil, fmt.Errorf("short response from %s", server)
+	case resp[0]&0x7 != 4:
+		return nil, fmt.Errorf("unexpected NTP mode %d from %s", resp[0]&0x7, server)
+	case resp[1] == 0:
+		return nil, fmt.Errorf("%s refused the request (kiss code %q)", server, resp[12:16])
+	case resp[0]>>6 == 3:
+		return nil, fmt.Errorf("%s is not synchronized", server)
+	case binary.BigEndian.Uint64(resp[24:]) != binary.BigEndian.Uint64(req[40:]):
+		return nil, fmt.Errorf("response from %s does not match the request", server)
+	}
+
+	serverReceived := fromNTPTime(binary.BigEndian.Uint64(resp[32:]))
+	serverSent := fromNTPTime(binary.BigEndian.Uint64(resp[40:]))
+	// The wall clock readings give the offset; the round trip uses the
+	// monotonic clock so a clock step during the query cannot skew it
+	offset := (serverReceived.Sub(sent.Round(0)) + serverSent.Sub(received.Round(0))) / 2
+	rtt := received.Sub(sent) - serverSent.Sub(serverReceived)
+	return &Sample{Server: server, Offset: offset, RTT: max(rtt, 0)}, nil
+}
+
+// MeasureNTP queries servers concurrently and returns the answers, ordered
+// by offset. It fails with ErrNTPUnavailable when none answers.
+func MeasureNTP(ctx context.Context, servers []string) ([]Sample, error) {
+	type result struct {
+		sample *Sample
+		err    error
+	}
+	results := make(chan result, len(servers))
+	for _, server := range servers {
+		go func() {
+			sample, err := QueryNTP(ctx, server)
+			results <- result{sample, err}
+		}()
+	}
+
+	var samples []Sample
+	var errs []error
+	for range servers {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		samples = append(samples, *r.sample)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%w: %w", ErrNTPUnavailable, errors.Join(errs...))
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Offset < samples[j].Offset })
+	return samples, nil
+}
+
+// MedianOffset returns the median of offsets, or 0 when there are none
+func MedianOffset(offsets []time.Duration) time.Duration {
+	if len(offsets) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// toNTPTime converts t to a 64-bit NTP timestamp
+func toNTPTime(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// fromNTPTime converts a 64-bit NTP timestamp to a time
+func fromNTPTime(ts uint64) time.Time {
+	secs := int64(ts>>32) - ntpEpochOffset
+	nanos := (ts & 0xffffffff) * uint64(time.Second) >> 32
+	return time.Unix(secs, int64(nanos))
+}
@@ -0,0 +1,109 @@
+package clock
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeNTPServer answers SNTP requests with its clock set skew ahead of the
+// local one, or with the stratum given
+func fakeNTPServer(t *testing.T, skew time.Duration, stratum byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, ntpPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < ntpPacketSize {
+				continue
+			}
+			resp := make([]byte, ntpPacketSize)
+			resp[0] = 4<<3 | 4
+			resp[1] = stratum
+			copy(resp[24:32], buf[40:48])
+			now := time.Now().Add(skew)
+			binary.BigEndian.PutUint64(resp[32:], toNTPTime(now))
+			binary.BigEndian.PutUint64(resp[40:], toNTPTime(now))
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	now := time.Now()
+	if got := fromNTPTime(toNTPTime(now)); got.Sub(now).Abs() > time.Microsecond {
+		t.Errorf("Round trip of %v gave %v", now, got)
+	}
+	if got := toNTPTime(time.Unix(0, 0)) >> 32; got != ntpEpochOffset {
+		t.Errorf("Unix epoch is %d NTP seconds, want %d", got, ntpEpochOffset)
+	}
+}
+
+func TestQueryNTP(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sample, err := QueryNTP(ctx, fakeNTPServer(t, -90*time.Minute, 2))
+	if err != nil {
+		t.Fatalf("QueryNTP() error = %v", err)
+	}
+	if diff := sample.Offset + 90*time.Minute; diff.Abs() > time.Second {
+		t.Errorf("Expected an offset of -1h30m, got %v", sample.Offset)
+	}
+
+	if _, err := QueryNTP(ctx, fakeNTPServer(t, 0, 0)); err == nil {
+		t.Error("Expected a kiss-of-death response to be rejected")
+	}
+}
+
+func TestMeasureNTP(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	servers := []string{
+		fakeNTPServer(t, 3*time.Minute, 1),
+		fakeNTPServer(t, time.Minute, 1),
+		fakeNTPServer(t, 0, 0),
+	}
+	samples, err := MeasureNTP(ctx, servers)
+	if err != nil {
+		t.Fatalf("MeasureNTP() error = %v", err)
+	}
+	if len(samples) != 2 || samples[0].Offset > samples[1].Offset {
+		t.Errorf("Expected 2 samples ordered by offset, got %+v", samples)
+	}
+
+	if _, err := MeasureNTP(ctx, servers[2:]); !errors.Is(err, ErrNTPUnavailable) {
+		t.Errorf("Expected ErrNTPUnavailable, got %v", err)
+	}
+}
+
+func TestMedianOffset(t *testing.T) {
+	tests := []struct {
+		offsets []time.Duration
+		want    time.Duration
+	}{
+		{nil, 0},
+		{[]time.Duration{time.Second}, time.Second},
+		{[]time.Duration{3 * time.Second, -time.Second, time.Hour}, 3 * time.Second},
+		{[]time.Duration{4 * time.Second, 2 * time.Second}, 3 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := MedianOffset(tt.offsets); got != tt.want {
+			t.Errorf("MedianOffset(%v) = %v, want %v", tt.offsets, got, tt.want)
+		}
+	}
+}
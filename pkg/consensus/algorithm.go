@@ -0,0 +1,76 @@
+package consensus
+
+import (
+	"fmt"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// Proof-of-work algorithms. Every header names the algorithm it was mined
+// with, and the chain switches from HPP-1 Tetra-PoW to the memory-hard
+// Argon2id variant at an activation height, so commodity CPUs and GPUs stay
+// competitive with dedicated hardware. Before activation only PowTetraPoW
+// is valid and from it only PowTetraArgon2, with no overlap.
+
+// PowAlgorithm identifies the proof-of-work hash of a header
+type PowAlgorithm uint8
+
+const (
+	// PowTetraPoW is Tetra-PoW seeded by HPP-1, crypto.TetraPoWHash
+	PowTetraPoW PowAlgorithm = 0
+	// PowTetraArgon2 is Tetra-PoW seeded by Argon2id,
+	// crypto.TetraPoWArgon2Hash
+	PowTetraArgon2 PowAlgorithm = 1
+)
+
+// String names the algorithm
+func (a PowAlgorithm) String() string {
+	switch a {
+	case PowTetraPoW:
+		return "tetrapow"
+	case PowTetraArgon2:
+		return "tetrapow-argon2id"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(a))
+	}
+}
+
+// AlgorithmAt returns the algorithm headers at height must be mined with
+func (v *Validator) AlgorithmAt(height uint32) PowAlgorithm {
+	if v.params.MemoryHardHeight != 0 && height >= v.params.MemoryHardHeight {
+		return PowTetraArgon2
+	}
+	return PowTetraPoW
+}
+
+// PowHash returns the proof-of-work hash of a header under the algorithm
+// it names
+func (v *Validator) PowHash(h *Header) ([]byte, error) {
+	var hash func(data []byte, nonce uint64) []byte
+	switch h.Algorithm {
+	case PowTetraPoW:
+		hash = v.params.PowHash
+	case PowTetraArgon2:
+		hash = v.params.MemoryHardHash
+	default:
+		return nil, fmt.Errorf("%w: unknown algorithm %d", ErrInvalidHeader, h.Algorithm)
+	}
+	return hash(v.params.Schedule.WorkData(h), h.Nonce), nil
+}
+
+// defaultPowHashes fills in the real hashes for params left nil, serving
+// HPP-1 from a cache unless PowCacheSize is negative, and returns the cache
+func (p *Params) defaultPowHashes() *crypto.HPP1Cache {
+	var cache *crypto.HPP1Cache
+	if p.PowHash == nil {
+		p.PowHash = crypto.TetraPoWHash
+		if p.PowCacheSize >= 0 {
+			cache = crypto.NewHPP1Cache(p.PowCacheSize)
+			p.PowHash = cache.TetraPoWHash
+		}
+	}
+	if p.MemoryHardHash == nil {
+		p.MemoryHardHash = crypto.TetraPoWArgon2Hash
+	}
+	return cache
+}
@@ -0,0 +1,118 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// quickMemoryHash stands in for the memory-hard hash, differing from
+// quickHash so tests can tell the algorithms apart
+func quickMemoryHash(data []byte, nonce uint64) []byte {
+	h := sha256.New()
+	h.Write([]byte("argon2id"))
+	h.Write(data)
+	binary.Write(h, binary.LittleEndian, nonce)
+	return h.Sum(nil)
+}
+
+func TestAlgorithmActivation(t *testing.T) {
+	const activation = 5
+	v, err := NewValidator(Params{Schedule: DefaultSchedule(), TreasuryAddress: testTreasury,
+		PowHash: quickHash, MemoryHardHeight: activation, MemoryHardHash: quickMemoryHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return time.Unix(testGenesis+1_000_000, 0) }
+
+	state := &ChainState{}
+	for state.NextHeight() < activation+2 {
+		height := state.NextHeight()
+		want := v.AlgorithmAt(height)
+		if (height >= activation) != (want == PowTetraArgon2) {
+			t.Fatalf("AlgorithmAt(%d) = %s", height, want)
+		}
+
+		// A header naming the other algorithm is refused even when solved
+		other := nextBlock(t, state)
+		other.Header.Algorithm = PowTetraPoW + PowTetraArgon2 - want
+		solve(v, &other.Header)
+		if _, err := v.ConnectBlock(other, state); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("Block %d with %s error = %v, want ErrInvalidHeader", height, other.Header.Algorithm, err)
+		}
+
+		b := nextBlock(t, state)
+		b.Header.Algorithm = want
+		solve(v, &b.Header)
+		if state, err = v.ConnectBlock(b, state); err != nil {
+			t.Fatalf("Block %d with %s error = %v", height, want, err)
+		}
+	}
+
+	// A nonce solving one algorithm does not carry over to the other
+	b := nextBlock(t, state)
+	b.Header.Algorithm = PowTetraArgon2
+	for b.Header.Nonce = 0; ; b.Header.Nonce++ {
+		data := v.params.Schedule.WorkData(&b.Header)
+		if CheckProofOfWork(quickHash(data, b.Header.Nonce), b.Header.Bits) == nil &&
+			CheckProofOfWork(quickMemoryHash(data, b.Header.Nonce), b.Header.Bits) != nil {
+			break
+		}
+	}
+	if err := v.CheckHeader(&b.Header, state); !errors.Is(err, ErrHighHash) {
+		t.Errorf("CheckHeader() with a Tetra-PoW solution error = %v, want ErrHighHash", err)
+	}
+
+	b.Header.Algorithm = 2
+	if _, err := v.PowHash(&b.Header); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("PowHash() of an unknown algorithm error = %v, want ErrInvalidHeader", err)
+	}
+	if err := v.CheckHeader(&b.Header, state); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("CheckHeader() with an unknown algorithm error = %v, want ErrInvalidHeader", err)
+	}
+}
+
+func TestMemoryHardHeader(t *testing.T) {
+	v, err := NewValidator(Params{Schedule: DefaultSchedule(), TreasuryAddress: testTreasury, MemoryHardHeight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return time.Unix(testGenesis+TargetSpacing, 0) }
+
+	state := &ChainState{Recent: []BlockTime{{Height: 0, Timestamp: testGenesis, Bits: PowLimitBits}}}
+	b := nextBlock(t, state)
+	b.Header.Algorithm = PowTetraArgon2
+
+	// Without overrides each algorithm checks its real hash
+	data := DefaultSchedule().WorkData(&b.Header)
+	memoryHard, err := v.PowHash(&b.Header)
+	if err != nil || !bytes.Equal(memoryHard, crypto.TetraPoWArgon2Hash(data, b.Header.Nonce)) {
+		t.Fatalf("PowHash() = %x, %v, want the Argon2id Tetra-PoW hash", memoryHard, err)
+	}
+	legacy := b.Header
+	legacy.Algorithm = PowTetraPoW
+	if hash, _ := v.PowHash(&legacy); !bytes.Equal(hash, crypto.TetraPoWHash(data, b.Header.Nonce)) || bytes.Equal(hash, memoryHard) {
+		t.Errorf("PowHash() of a Tetra-PoW header = %x, want the HPP-1 hash", hash)
+	}
+
+	for CheckProofOfWork(crypto.TetraPoWArgon2Hash(data, b.Header.Nonce), b.Header.Bits) == nil {
+		b.Header.Nonce++
+	}
+	if err := v.CheckHeader(&b.Header, state); !errors.Is(err, ErrHighHash) {
+		t.Errorf("CheckHeader() error = %v, want ErrHighHash", err)
+	}
+}
+
+func TestHeaderCommitsToAlgorithm(t *testing.T) {
+	h := Header{Height: 9, Bits: PowLimitBits}
+	legacy := h.Hash()
+	h.Algorithm = PowTetraArgon2
+	if h.Hash() == legacy || len(h.Bytes()) != HeaderSize {
+		t.Errorf("Expected the block ID to change with the algorithm")
+	}
+}
@@ -0,0 +1,150 @@
+package consensus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// EXS blocks. A header commits to its parent, the merkle root of its
+// transactions, its timestamp, compact target and proof-of-work algorithm;
+// its Tetra-PoW hash is computed over the epoch block seed followed by the
+// rest of the header.
+// Block and transaction IDs are double SHA-256 hashes, as in Bitcoin.
+
+// HeaderSize is the length of a serialized header
+const HeaderSize = 89
+
+// Amount is a quantity of EXS in its smallest unit, 1e-8 EXS
+type Amount int64
+
+// Coin is one EXS
+const Coin Amount = 100_000_000
+
+// Header is an EXS block header
+type Header struct {
+	Height     uint32
+	PrevBlock  chainhash.Hash
+	MerkleRoot chainhash.Hash
+	Timestamp  int64
+	Bits       uint32
+	Nonce      uint64
+	Algorithm  PowAlgorithm
+}
+
+// Bytes serializes the header, all integers little-endian
+func (h *Header) Bytes() []byte {
+	buf := make([]byte, HeaderSize)
+	binary.LittleEndian.PutUint32(buf[0:4], h.Height)
+	copy(buf[4:36], h.PrevBlock[:])
+	copy(buf[36:68], h.MerkleRoot[:])
+	binary.LittleEndian.PutUint64(buf[68:76], uint64(h.Timestamp))
+	binary.LittleEndian.PutUint32(buf[76:80], h.Bits)
+	binary.LittleEndian.PutUint64(buf[80:88], h.Nonce)
+	buf[88] = byte(h.Algorithm)
+	return buf
+}
+
+// Hash returns the block ID, the double SHA-256 of the serialized header
+func (h *Header) Hash() chainhash.Hash {
+	return chainhash.DoubleHashH(h.Bytes())
+}
+
+// WorkData returns the data a header's Tetra-PoW hash is computed over,
+// with the nonce, by crypto.TetraPoWHash: the block seed of the header's
+// epoch, then its parent, merkle root and bits
+func (s Schedule) WorkData(h *Header) []byte {
+	data := s.BlockSeed(h.Height, h.Nonce, h.Timestamp)
+	data = append(data, h.PrevBlock[:]...)
+	data = append(data, h.MerkleRoot[:]...)
+	return binary.LittleEndian.AppendUint32(data, h.Bits)
+}
+
+// OutPoint names an output of an earlier transaction
+type OutPoint struct {
+	Hash  chainhash.Hash
+	Index uint32
+}
+
+// Output pays Amount to Address, spendable from LockHeight on
+type Output struct {
+	Address    string
+	Amount     Amount
+	LockHeight uint32
+}
+
+// Transaction spends Inputs into Outputs. A coinbase has no inputs.
+type Transaction struct {
+	Inputs  []OutPoint
+	Outputs []Output
+}
+
+// IsCoinbase reports whether the transaction mints new EXS
+func (tx *Transaction) IsCoinbase() bool {
+	return len(tx.Inputs) == 0
+}
+
+// Bytes serializes the transaction with Bitcoin varint counts
+func (tx *Transaction) Bytes() []byte {
+	var buf bytes.Buffer
+	tx.serialize(&buf)
+	return buf.Bytes()
+}
+
+func (tx *Transaction) serialize(w io.Writer) {
+	var scratch [8]byte
+	wire.WriteVarInt(w, 0, uint64(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		w.Write(in.Hash[:])
+		binary.LittleEndian.PutUint32(scratch[:4], in.Index)
+		w.Write(scratch[:4])
+	}
+	wire.WriteVarInt(w, 0, uint64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		wire.WriteVarString(w, 0, out.Address)
+		binary.LittleEndian.PutUint64(scratch[:], uint64(out.Amount))
+		w.Write(scratch[:])
+		binary.LittleEndian.PutUint32(scratch[:4], out.LockHeight)
+		w.Write(scratch[:4])
+	}
+}
+
+// Hash returns the transaction ID
+func (tx *Transaction) Hash() chainhash.Hash {
+	return chainhash.DoubleHashH(tx.Bytes())
+}
+
+// Block is a header and its transactions, the coinbase first
+type Block struct {
+	Header       Header
+	Transactions []*Transaction
+}
+
+// MerkleRoot returns the root of the Bitcoin-style merkle tree over the
+// transaction IDs, pairing the last ID with itself on odd levels
+func MerkleRoot(txs []*Transaction) chainhash.Hash {
+	if len(txs) == 0 {
+		return chainhash.Hash{}
+	}
+	level := make([]chainhash.Hash, len(txs))
+	for i, tx := range txs {
+		level[i] = tx.Hash()
+	}
+	var pair [2 * chainhash.HashSize]byte
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		next := level[:0]
+		for i := 0; i < len(level); i += 2 {
+			copy(pair[:chainhash.HashSize], level[i][:])
+			copy(pair[chainhash.HashSize:], level[i+1][:])
+			next = append(next, chainhash.DoubleHashH(pair[:]))
+		}
+		level = next
+	}
+	return level[0]
+}
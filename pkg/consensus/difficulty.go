@@ -0,0 +1,139 @@
+package consensus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/btcsuite/btcd/blockchain"
+)
+
+// Tetra-PoW difficulty. A block hash meets a target when its first eight
+// bytes, read little-endian, fall below it, as in crypto.TetraPoW and the
+// stratum server. Blocks carry their target as compact bits, and every block
+// retargets from a rolling window of the blocks before it.
+
+const (
+	// TargetSpacing is the intended time between blocks in seconds
+	TargetSpacing = 600
+	// RetargetWindow is the number of recent blocks whose targets and
+	// solve times set the next target, about a day
+	RetargetWindow = 144
+	// MaxAdjustment bounds how far the window's timespan may stray from
+	// the expected one, so one retarget moves the target at most this
+	// factor from the window's average
+	MaxAdjustment = 4
+	// PowLimit is the easiest target, 256 in stratum share difficulty,
+	// used from genesis until the first retarget
+	PowLimit uint64 = 0x00FFFF0000000000
+	// PowLimitBits is PowLimit in compact form
+	PowLimitBits uint32 = 0x0800ffff
+)
+
+var (
+	// ErrInvalidBits indicates compact bits that are negative or encode a
+	// target beyond 64 bits or above PowLimit
+	ErrInvalidBits = errors.New("invalid compact bits")
+	// ErrHighHash indicates a block hash above its target
+	ErrHighHash = errors.New("block hash above target")
+)
+
+// BlockTime is the height, timestamp and compact target of a block, all a
+// retarget needs
+type BlockTime struct {
+	Height    uint32
+	Timestamp int64
+	Bits      uint32
+}
+
+// TargetToCompact encodes a target as compact bits: a byte count followed
+// by the three most significant bytes, as Bitcoin encodes targets.
+// Precision below those bytes is dropped.
+func TargetToCompact(target uint64) uint32 {
+	return blockchain.BigToCompact(new(big.Int).SetUint64(target))
+}
+
+// CompactToTarget decodes compact bits, rejecting targets that are
+// negative, zero or easier than PowLimit
+func CompactToTarget(bits uint32) (uint64, error) {
+	if bits&0x00800000 != 0 {
+		return 0, fmt.Errorf("%w: %08x is negative", ErrInvalidBits, bits)
+	}
+	target := blockchain.CompactToBig(bits)
+	if target.Sign() == 0 || !target.IsUint64() || target.Uint64() > PowLimit {
+		return 0, fmt.Errorf("%w: %08x is outside (0, pow limit]", ErrInvalidBits, bits)
+	}
+	return target.Uint64(), nil
+}
+
+// Difficulty returns the stratum share difficulty of compact bits: how
+// many times harder the target is than one every hash meets
+func Difficulty(bits uint32) float64 {
+	target, err := CompactToTarget(bits)
+	if err != nil {
+		return 0
+	}
+	return float64(math.MaxUint64) / float64(target)
+}
+
+// CheckProofOfWork reports whether hash meets the target of bits
+func CheckProofOfWork(hash []byte, bits uint32) error {
+	target, err := CompactToTarget(bits)
+	if err != nil {
+		return err
+	}
+	if len(hash) < 8 {
+		return fmt.Errorf("%w: %d-byte hash", ErrHighHash, len(hash))
+	}
+	if value := binary.LittleEndian.Uint64(hash[:8]); value >= target {
+		return fmt.Errorf("%w: %016x >= %016x", ErrHighHash, value, target)
+	}
+	return nil
+}
+
+// NextBits returns the compact target of the block after the last of
+// recent, the chain's latest blocks in height order. The average target of
+// up to RetargetWindow blocks is scaled by how long they took against
+// TargetSpacing, clamped to MaxAdjustment either way. A chain with fewer
+// than two blocks mines at PowLimit.
+func NextBits(recent []BlockTime) (uint32, error) {
+	if len(recent) > RetargetWindow+1 {
+		recent = recent[len(recent)-RetargetWindow-1:]
+	}
+	if len(recent) < 2 {
+		return PowLimitBits, nil
+	}
+	intervals := int64(len(recent) - 1)
+
+	// The first block only marks the window's start time
+	sum := new(big.Int)
+	for _, b := range recent[1:] {
+		target, err := CompactToTarget(b.Bits)
+		if err != nil {
+			return 0, fmt.Errorf("block %d: %w", b.Height, err)
+		}
+		sum.Add(sum, new(big.Int).SetUint64(target))
+	}
+
+	expected := intervals * TargetSpacing
+	timespan := recent[len(recent)-1].Timestamp - recent[0].Timestamp
+	if timespan < expected/MaxAdjustment {
+		timespan = expected / MaxAdjustment
+	}
+	if timespan > expected*MaxAdjustment {
+		timespan = expected * MaxAdjustment
+	}
+
+	// average * timespan / expected, with the average's division folded in
+	next := sum.Mul(sum, big.NewInt(timespan))
+	next.Quo(next, big.NewInt(expected*intervals))
+	if !next.IsUint64() || next.Uint64() > PowLimit {
+		return PowLimitBits, nil
+	}
+	if next.Sign() == 0 {
+		next.SetUint64(1)
+	}
+	return TargetToCompact(next.Uint64()), nil
+}
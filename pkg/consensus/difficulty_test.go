@@ -0,0 +1,136 @@
+package consensus
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCompactBits(t *testing.T) {
+	if got := TargetToCompact(PowLimit); got != PowLimitBits {
+		t.Errorf("TargetToCompact(PowLimit) = %08x, want %08x", got, PowLimitBits)
+	}
+	tests := []struct {
+		target uint64
+		bits   uint32
+		want   uint64
+	}{
+		{PowLimit, PowLimitBits, PowLimit},
+		{0x0000000012345678, 0x04123456, 0x0000000012345600},
+		{0x0000000000800000, 0x04008000, 0x0000000000800000},
+		{0x7f, 0x017f0000, 0x7f},
+	}
+	for _, tt := range tests {
+		if got := TargetToCompact(tt.target); got != tt.bits {
+			t.Errorf("TargetToCompact(%016x) = %08x, want %08x", tt.target, got, tt.bits)
+		}
+		if got, err := CompactToTarget(tt.bits); err != nil || got != tt.want {
+			t.Errorf("CompactToTarget(%08x) = %016x, %v, want %016x", tt.bits, got, err, tt.want)
+		}
+	}
+
+	for _, bits := range []uint32{0x04923456, 0x09010000, 0x08010000, 0x00000000} {
+		if _, err := CompactToTarget(bits); !errors.Is(err, ErrInvalidBits) {
+			t.Errorf("CompactToTarget(%08x) error = %v, want ErrInvalidBits", bits, err)
+		}
+	}
+	if got := Difficulty(PowLimitBits); math.Abs(got-256) > 0.01 {
+		t.Errorf("Difficulty(PowLimitBits) = %f, want 256", got)
+	}
+}
+
+func TestCheckProofOfWork(t *testing.T) {
+	bits := TargetToCompact(0x0000001000000000)
+	hash := make([]byte, 32)
+	binary.LittleEndian.PutUint64(hash, 0x0000000fffffffff)
+	if err := CheckProofOfWork(hash, bits); err != nil {
+		t.Errorf("CheckProofOfWork() below target error = %v", err)
+	}
+	binary.LittleEndian.PutUint64(hash, 0x0000001000000000)
+	if err := CheckProofOfWork(hash, bits); !errors.Is(err, ErrHighHash) {
+		t.Errorf("CheckProofOfWork() at target error = %v, want ErrHighHash", err)
+	}
+}
+
+func TestNextBitsBounds(t *testing.T) {
+	if bits, _ := NextBits(nil); bits != PowLimitBits {
+		t.Errorf("NextBits(nil) = %08x, want the pow limit", bits)
+	}
+
+	target := uint64(0x0000100000000000)
+	window := make([]BlockTime, RetargetWindow+1)
+	for i := range window {
+		window[i] = BlockTime{Height: uint32(i), Timestamp: int64(i) * TargetSpacing, Bits: TargetToCompact(target)}
+	}
+	if bits, err := NextBits(window); err != nil || bits != TargetToCompact(target) {
+		t.Errorf("NextBits() on schedule = %08x, %v, want unchanged", bits, err)
+	}
+
+	// Blocks every second can only tighten the target by MaxAdjustment
+	for i := range window {
+		window[i].Timestamp = int64(i)
+	}
+	bits, _ := NextBits(window)
+	if got, _ := CompactToTarget(bits); got != target/MaxAdjustment {
+		t.Errorf("NextBits() after fast blocks = %016x, want %016x", got, target/MaxAdjustment)
+	}
+
+	// Slow blocks never ease the target past the pow limit
+	for i := range window {
+		window[i] = BlockTime{Height: uint32(i), Timestamp: int64(i) * 100 * TargetSpacing, Bits: PowLimitBits}
+	}
+	if bits, _ := NextBits(window); bits != PowLimitBits {
+		t.Errorf("NextBits() after slow blocks = %08x, want the pow limit", bits)
+	}
+
+	window[RetargetWindow].Bits = 0x09010000
+	if _, err := NextBits(window); !errors.Is(err, ErrInvalidBits) {
+		t.Errorf("NextBits() with invalid bits error = %v", err)
+	}
+}
+
+// TestRetargetHashRateSwings mines a simulated chain whose hash rate jumps
+// and collapses, checking that block times return to TargetSpacing
+func TestRetargetHashRateSwings(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var chain []BlockTime
+	now := int64(0)
+
+	// mine adds n blocks at hashRate hashes per second and returns their
+	// mean solve time
+	mine := func(n int, hashRate float64) float64 {
+		start := now
+		for i := 0; i < n; i++ {
+			bits, err := NextBits(chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target, _ := CompactToTarget(bits)
+			// Each hash meets the target with probability target/2^64
+			expected := math.Exp2(64) / float64(target) / hashRate
+			now += int64(math.Ceil(rng.ExpFloat64() * expected))
+			chain = append(chain, BlockTime{Height: uint32(len(chain)), Timestamp: now, Bits: bits})
+		}
+		return float64(now-start) / float64(n)
+	}
+
+	phases := []struct {
+		name     string
+		hashRate float64
+	}{
+		{"launch", 1e6},
+		{"hash rate x10", 1e7},
+		{"hash rate /100", 1e5},
+		{"recovery x4", 4e5},
+	}
+	for _, phase := range phases {
+		// Allow a few windows to adjust, then measure
+		mine(4*RetargetWindow, phase.hashRate)
+		mean := mine(10*RetargetWindow, phase.hashRate)
+		if mean < 0.9*TargetSpacing || mean > 1.1*TargetSpacing {
+			t.Errorf("%s: mean block time %.0fs, want within 10%% of %ds", phase.name, mean, TargetSpacing)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Package consensus implements the EXS block seed, difficulty and block
+// validation rules.
+//
+// Tetra-PoW block seeds start with the SHA-256 hash of the prophecy axiom.
+// The axiom rotates per epoch: an epoch schedule maps height ranges to axiom
+// hashes, and a seed is valid only with the hash of the epoch its height
+// falls in. Only hashes are ever scheduled, the raw axiom is never stored.
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// MinRotationNotice is the number of blocks a rotation must be scheduled
+// ahead of the chain tip, about two weeks, so miners can upgrade in time
+const MinRotationNotice = 2016
+
+// ErrInvalidSchedule indicates an epoch schedule or rotation that breaks the
+// scheduling rules
+var ErrInvalidSchedule = errors.New("invalid epoch schedule")
+
+// AxiomHash is the SHA-256 hash of a normalized prophecy axiom
+type AxiomHash [32]byte
+
+// HashAxiom hashes an axiom after lowercasing it and collapsing whitespace,
+// so the same words always give the same hash
+func HashAxiom(axiom string) AxiomHash {
+	normalized := strings.Join(strings.Fields(strings.ToLower(axiom)), " ")
+	return sha256.Sum256([]byte(normalized))
+}
+
+// ParseAxiomHash decodes a hex axiom hash
+func ParseAxiomHash(s string) (AxiomHash, error) {
+	var h AxiomHash
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(h) {
+		return h, fmt.Errorf("axiom hash must be %d hex bytes", len(h))
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// String returns the hash in hex
+func (h AxiomHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// MarshalText encodes the hash as hex
+func (h AxiomHash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes a hex hash
+func (h *AxiomHash) UnmarshalText(text []byte) error {
+	parsed, err := ParseAxiomHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// Epoch is the axiom hash in force from block height Start until the next
+// epoch begins
+type Epoch struct {
+	Start     uint32    `json:"start"`
+	AxiomHash AxiomHash `json:"axiom_hash"`
+}
+
+// Schedule lists the epochs in order of their start height. The first epoch
+// starts at genesis.
+type Schedule []Epoch
+
+// DefaultSchedule is a single epoch using the canonical prophecy axiom from
+// genesis onwards
+func DefaultSchedule() Schedule {
+	return Schedule{{Start: 0, AxiomHash: HashAxiom(strings.Join(crypto.Canonical13WordProphecy, " "))}}
+}
+
+// Validate checks that the schedule starts at genesis, that start heights
+// increase and that every rotation changes the axiom hash
+func (s Schedule) Validate() error {
+	if len(s) == 0 {
+		return fmt.Errorf("%w: no epochs", ErrInvalidSchedule)
+	}
+	if s[0].Start != 0 {
+		return fmt.Errorf("%w: first epoch starts at height %d, not genesis", ErrInvalidSchedule, s[0].Start)
+	}
+	for i, e := range s {
+		if e.AxiomHash == (AxiomHash{}) {
+			return fmt.Errorf("%w: epoch %d has no axiom hash", ErrInvalidSchedule, i)
+		}
+		if i == 0 {
+			continue
+		}
+		if e.Start <= s[i-1].Start {
+			return fmt.Errorf("%w: epoch %d starts at height %d, not after %d", ErrInvalidSchedule, i, e.Start, s[i-1].Start)
+		}
+		if e.AxiomHash == s[i-1].AxiomHash {
+			return fmt.Errorf("%w: epoch %d does not rotate the axiom", ErrInvalidSchedule, i)
+		}
+	}
+	return nil
+}
+
+// EpochAt returns the index and epoch in force at height
+func (s Schedule) EpochAt(height uint32) (int, Epoch) {
+	i := len(s) - 1
+	for i > 0 && s[i].Start > height {
+		i--
+	}
+	return i, s[i]
+}
+
+// AxiomHashAt returns the axiom hash block seeds must use at height
+func (s Schedule) AxiomHashAt(height uint32) AxiomHash {
+	_, e := s.EpochAt(height)
+	return e.AxiomHash
+}
+
+// End returns the last height of epoch i, or false for the open-ended last
+// epoch
+func (s Schedule) End(i int) (uint32, bool) {
+	if i+1 >= len(s) {
+		return 0, false
+	}
+	return s[i+1].Start - 1, true
+}
+
+// Rotate returns a copy of the schedule with a rotation to hash at start.
+// The rotation must come after every scheduled epoch and at least
+// MinRotationNotice blocks after tip, the current chain height.
+func (s Schedule) Rotate(start uint32, hash AxiomHash, tip uint32) (Schedule, error) {
+	if uint64(start) < uint64(tip)+MinRotationNotice {
+		return nil, fmt.Errorf("%w: rotation at height %d is less than %d blocks after the tip at %d",
+			ErrInvalidSchedule, start, MinRotationNotice, tip)
+	}
+	rotated := append(append(Schedule(nil), s...), Epoch{Start: start, AxiomHash: hash})
+	if err := rotated.Validate(); err != nil {
+		return nil, err
+	}
+	return rotated, nil
+}
+
+// LoadSchedule reads a schedule saved by Save. A missing file gives the
+// DefaultSchedule.
+func LoadSchedule(path string) (Schedule, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultSchedule(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read epoch schedule: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var s Schedule
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSchedule, path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes the schedule to path, replacing any previous file atomically
+func (s Schedule) Save(path string) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create schedule directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write epoch schedule: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,115 @@
+package consensus
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashAxiomNormalizes(t *testing.T) {
+	want := HashAxiom("sword legend pull magic kingdom artist stone destroy forget fire steel honey question")
+	if got := HashAxiom("  Sword legend  pull magic kingdom artist stone destroy forget fire steel honey QUESTION\n"); got != want {
+		t.Errorf("HashAxiom() = %s, want %s", got, want)
+	}
+	if DefaultSchedule().AxiomHashAt(0) != want {
+		t.Error("Expected the default schedule to use the canonical axiom")
+	}
+}
+
+func TestScheduleRotate(t *testing.T) {
+	next := HashAxiom("second prophecy")
+	s, err := DefaultSchedule().Rotate(10000, next, 5000)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if len(s) != 2 || len(DefaultSchedule()) != 1 {
+		t.Fatalf("Expected a two-epoch copy, got %d epochs", len(s))
+	}
+
+	tests := []struct {
+		height uint32
+		epoch  int
+	}{{0, 0}, {9999, 0}, {10000, 1}, {500000, 1}}
+	for _, tt := range tests {
+		if i, _ := s.EpochAt(tt.height); i != tt.epoch {
+			t.Errorf("EpochAt(%d) = %d, want %d", tt.height, i, tt.epoch)
+		}
+	}
+	if end, ok := s.End(0); !ok || end != 9999 {
+		t.Errorf("End(0) = %d, %v, want 9999", end, ok)
+	}
+	if _, ok := s.End(1); ok {
+		t.Error("Expected the last epoch to be open-ended")
+	}
+
+	invalid := []struct {
+		name  string
+		start uint32
+		hash  AxiomHash
+		tip   uint32
+	}{
+		{"too soon", 9000 + MinRotationNotice - 1, HashAxiom("third"), 9000},
+		{"before last epoch", 9999, HashAxiom("third"), 0},
+		{"same axiom", 20000, next, 0},
+		{"no axiom", 20000, AxiomHash{}, 0},
+	}
+	for _, tt := range invalid {
+		if _, err := s.Rotate(tt.start, tt.hash, tt.tip); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Rotate() %s error = %v, want ErrInvalidSchedule", tt.name, err)
+		}
+	}
+}
+
+func TestValidateSeed(t *testing.T) {
+	s, err := DefaultSchedule().Rotate(10000, HashAxiom("second prophecy"), 0)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if err := s.ValidateSeed(9999, s.BlockSeed(9999, 7, 1700000000)); err != nil {
+		t.Errorf("ValidateSeed() epoch 0 error = %v", err)
+	}
+	if err := s.ValidateSeed(10000, s.BlockSeed(10000, 7, 1700000000)); err != nil {
+		t.Errorf("ValidateSeed() epoch 1 error = %v", err)
+	}
+
+	// Seeds from the previous or the next epoch are rejected
+	if err := s.ValidateSeed(10000, s.BlockSeed(9999, 7, 1700000000)); !errors.Is(err, ErrWrongEpoch) {
+		t.Errorf("ValidateSeed() with expired axiom error = %v, want ErrWrongEpoch", err)
+	}
+	if err := s.ValidateSeed(9999, s.BlockSeed(10000, 7, 1700000000)); !errors.Is(err, ErrWrongEpoch) {
+		t.Errorf("ValidateSeed() with scheduled axiom error = %v, want ErrWrongEpoch", err)
+	}
+	unknown := Schedule{{AxiomHash: HashAxiom("forged prophecy")}}.BlockSeed(0, 7, 1700000000)
+	if err := s.ValidateSeed(0, unknown); !errors.Is(err, ErrWrongEpoch) {
+		t.Errorf("ValidateSeed() with unknown axiom error = %v, want ErrWrongEpoch", err)
+	}
+	if err := s.ValidateSeed(0, make([]byte, 32)); !errors.Is(err, ErrInvalidSeed) {
+		t.Errorf("ValidateSeed() with short seed error = %v, want ErrInvalidSeed", err)
+	}
+}
+
+func TestScheduleSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "epochs.json")
+
+	s, err := LoadSchedule(path)
+	if err != nil || len(s) != 1 {
+		t.Fatalf("LoadSchedule() missing file = %v, %v, want the default schedule", s, err)
+	}
+
+	s, _ = s.Rotate(50000, HashAxiom("second prophecy"), 0)
+	if err := s.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadSchedule(path)
+	if err != nil {
+		t.Fatalf("LoadSchedule() error = %v", err)
+	}
+	if len(loaded) != 2 || loaded[1] != s[1] {
+		t.Errorf("LoadSchedule() = %v, want %v", loaded, s)
+	}
+
+	if err := (Schedule{{Start: 5, AxiomHash: HashAxiom("late")}}).Save(path); !errors.Is(err, ErrInvalidSchedule) {
+		t.Errorf("Save() without genesis epoch error = %v, want ErrInvalidSchedule", err)
+	}
+}
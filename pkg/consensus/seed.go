@@ -0,0 +1,49 @@
+package consensus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// SeedSize is the length of a Tetra-PoW block seed: the axiom hash, the
+// nonce and the timestamp, both little-endian
+const SeedSize = 48
+
+// ErrWrongEpoch indicates a block seed built with another epoch's axiom hash
+var ErrWrongEpoch = errors.New("block seed uses wrong epoch axiom")
+
+// ErrInvalidSeed indicates a block seed that is not SeedSize bytes
+var ErrInvalidSeed = errors.New("invalid block seed")
+
+// BlockSeed builds the Tetra-PoW seed of a block at height
+func (s Schedule) BlockSeed(height uint32, nonce uint64, timestamp int64) []byte {
+	seed := make([]byte, SeedSize)
+	hash := s.AxiomHashAt(height)
+	copy(seed[0:32], hash[:])
+	binary.LittleEndian.PutUint64(seed[32:40], nonce)
+	binary.LittleEndian.PutUint64(seed[40:48], uint64(timestamp))
+	return seed
+}
+
+// ValidateSeed accepts a block seed at height only if it carries the axiom
+// hash of the epoch height falls in. Hashes of earlier or scheduled epochs
+// are rejected like any unknown hash.
+func (s Schedule) ValidateSeed(height uint32, seed []byte) error {
+	if len(seed) != SeedSize {
+		return fmt.Errorf("%w: %d bytes, want %d", ErrInvalidSeed, len(seed), SeedSize)
+	}
+	var got AxiomHash
+	copy(got[:], seed[:32])
+
+	i, want := s.EpochAt(height)
+	if got == want.AxiomHash {
+		return nil
+	}
+	for j, e := range s {
+		if e.AxiomHash == got {
+			return fmt.Errorf("%w: height %d is in epoch %d, seed uses epoch %d", ErrWrongEpoch, height, i, j)
+		}
+	}
+	return fmt.Errorf("%w: height %d is in epoch %d, seed uses unknown axiom hash %x", ErrWrongEpoch, height, i, got[:8])
+}
@@ -0,0 +1,339 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Block validation. The block at height h mints the subsidy of the h-th
+// forge in the economy's emission schedule until the supply cap: the
+// coinbase must open with the treasury's 15% in CLTV-locked mini-outputs
+// released every economy.BlockInterval blocks, and the miner may claim the
+// rest, 42.5 EXS before the first halving.
+
+const (
+	// BlockReward is the EXS minted by each block before the first halving
+	BlockReward = Amount(economy.ForgeReward)
+	// TreasuryReward is the treasury's share of BlockReward
+	TreasuryReward = Amount(economy.TreasuryAllocation)
+	// MaxSupply is the most EXS that will ever exist
+	MaxSupply = Amount(economy.TotalSupplyCap)
+	// MedianTimeBlocks is the number of recent blocks whose median
+	// timestamp a new block must exceed
+	MedianTimeBlocks = 11
+	// MaxFutureBlockTime is how many seconds past the local clock a block
+	// timestamp may be
+	MaxFutureBlockTime = 2 * 60 * 60
+)
+
+var (
+	// ErrInvalidHeader indicates a header at the wrong height, on the wrong
+	// parent or with the wrong target or proof-of-work algorithm
+	ErrInvalidHeader = errors.New("invalid block header")
+	// ErrTimestamp indicates a block timestamp not after the median of
+	// recent blocks or too far in the future
+	ErrTimestamp = errors.New("invalid block timestamp")
+	// ErrMerkleRoot indicates a header whose merkle root does not match
+	// the block's transactions
+	ErrMerkleRoot = errors.New("merkle root mismatch")
+	// ErrInvalidTransaction indicates a malformed or duplicate transaction
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	// ErrInvalidCoinbase indicates a block without a coinbase first or with
+	// more than one
+	ErrInvalidCoinbase = errors.New("invalid coinbase")
+	// ErrTreasuryAllocation indicates a coinbase that does not open with
+	// the treasury mini-outputs
+	ErrTreasuryAllocation = errors.New("invalid treasury allocation")
+	// ErrBlockReward indicates a coinbase paying the miner more than its
+	// share of the block reward
+	ErrBlockReward = errors.New("block reward too high")
+	// ErrMaxSupply indicates a block that would mint past MaxSupply
+	ErrMaxSupply = errors.New("block exceeds max supply")
+)
+
+// SubsidyAt returns the EXS the block at height may mint given the current
+// supply: the emission schedule's reward, or what is left below MaxSupply
+func SubsidyAt(height uint32, supply Amount) Amount {
+	return Amount(economy.ForgeEmission(uint64(height), economy.Amount(supply)))
+}
+
+// TreasuryOutputs returns the outputs a coinbase at height minting subsidy
+// must open with: the treasury's share split evenly across
+// economy.MiniOutputCount outputs, the last taking any remainder, locked
+// economy.BlockInterval blocks apart
+func TreasuryOutputs(address string, height uint32, subsidy Amount) []Output {
+	share := subsidy * TreasuryReward / BlockReward
+	part := share / economy.MiniOutputCount
+	var outputs []Output
+	for i := 0; i < economy.MiniOutputCount; i++ {
+		amount := part
+		if i == economy.MiniOutputCount-1 {
+			amount = share - part*(economy.MiniOutputCount-1)
+		}
+		if amount == 0 {
+			continue
+		}
+		outputs = append(outputs, Output{
+			Address:    address,
+			Amount:     amount,
+			LockHeight: height + uint32(i*economy.BlockInterval),
+		})
+	}
+	return outputs
+}
+
+// MedianTime returns the median timestamp of the last MedianTimeBlocks of
+// recent, or 0 for an empty chain
+func MedianTime(recent []BlockTime) int64 {
+	if len(recent) > MedianTimeBlocks {
+		recent = recent[len(recent)-MedianTimeBlocks:]
+	}
+	if len(recent) == 0 {
+		return 0
+	}
+	times := make([]int64, len(recent))
+	for i, b := range recent {
+		times[i] = b.Timestamp
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2]
+}
+
+// ChainState is what validating the next block needs to know of the chain
+type ChainState struct {
+	// Tip is the ID of the latest block, zero before genesis
+	Tip chainhash.Hash
+	// Recent holds the latest blocks in height order, at least
+	// RetargetWindow+1 of them once the chain is that long
+	Recent []BlockTime
+	// Supply is the EXS minted so far
+	Supply Amount
+}
+
+// NextHeight returns the height of the block after the tip
+func (s *ChainState) NextHeight() uint32 {
+	if len(s.Recent) == 0 {
+		return 0
+	}
+	return s.Recent[len(s.Recent)-1].Height + 1
+}
+
+// Params are the rules a Validator enforces beyond the fixed constants
+type Params struct {
+	Schedule Schedule
+	// TreasuryAddress receives the treasury mini-outputs
+	TreasuryAddress string
+	// PowHash computes the PowTetraPoW hash of work data and a nonce,
+	// crypto.TetraPoWHash if nil
+	PowHash func(data []byte, nonce uint64) []byte
+	// MemoryHardHeight is the height from which headers must use
+	// PowTetraArgon2. Zero never activates it.
+	MemoryHardHeight uint32
+	// MemoryHardHash computes the PowTetraArgon2 hash,
+	// crypto.TetraPoWArgon2Hash if nil
+	MemoryHardHash func(data []byte, nonce uint64) []byte
+	// PowCacheSize is the number of HPP-1 outputs kept when PowHash is
+	// nil, crypto.DefaultHPP1CacheSize if zero. Negative disables the
+	// cache.
+	PowCacheSize int
+	// Verification selects full or light proof-of-work checks
+	Verification VerifyMode
+	// LightSample makes a light validator check one header's proof of
+	// work in LightSample, DefaultLightSample if zero
+	LightSample int
+}
+
+// Validator checks blocks against the EXS consensus rules, so a node can
+// reject invalid blocks instead of trusting its peers
+type Validator struct {
+	params Params
+	cache  *crypto.HPP1Cache
+	now    func() time.Time
+	// sample reports whether a light validator checks the next header's
+	// proof of work
+	sample func() bool
+}
+
+// NewValidator creates a validator for params
+func NewValidator(params Params) (*Validator, error) {
+	if err := params.Schedule.Validate(); err != nil {
+		return nil, err
+	}
+	if params.TreasuryAddress == "" {
+		return nil, errors.New("treasury address is required")
+	}
+	if params.Verification != VerifyFull && params.Verification != VerifyLight {
+		return nil, fmt.Errorf("unknown verification mode %d", params.Verification)
+	}
+	if params.LightSample <= 0 {
+		params.LightSample = DefaultLightSample
+	}
+	v := &Validator{params: params, now: time.Now}
+	v.cache = v.params.defaultPowHashes()
+	v.sample = func() bool { return rand.IntN(v.params.LightSample) == 0 }
+	return v, nil
+}
+
+// CheckHeader validates a header as the next block after state: height,
+// parent, target, timestamp, algorithm and proof of work
+func (v *Validator) CheckHeader(h *Header, state *ChainState) error {
+	if want := state.NextHeight(); h.Height != want {
+		return fmt.Errorf("%w: height %d, want %d", ErrInvalidHeader, h.Height, want)
+	}
+	if h.PrevBlock != state.Tip {
+		return fmt.Errorf("%w: parent %s, want %s", ErrInvalidHeader, h.PrevBlock, state.Tip)
+	}
+	bits, err := NextBits(state.Recent)
+	if err != nil {
+		return err
+	}
+	if h.Bits != bits {
+		return fmt.Errorf("%w: bits %08x, want %08x", ErrInvalidHeader, h.Bits, bits)
+	}
+
+	if len(state.Recent) > 0 {
+		if median := MedianTime(state.Recent); h.Timestamp <= median {
+			return fmt.Errorf("%w: %d is not after median time %d", ErrTimestamp, h.Timestamp, median)
+		}
+	}
+	if limit := v.now().Unix() + MaxFutureBlockTime; h.Timestamp > limit {
+		return fmt.Errorf("%w: %d is more than %ds in the future", ErrTimestamp, h.Timestamp, MaxFutureBlockTime)
+	}
+
+	if want := v.AlgorithmAt(h.Height); h.Algorithm != want {
+		return fmt.Errorf("%w: algorithm %s, want %s", ErrInvalidHeader, h.Algorithm, want)
+	}
+	if v.params.Verification == VerifyLight && !v.sample() {
+		return nil
+	}
+	hash, err := v.PowHash(h)
+	if err != nil {
+		return err
+	}
+	if err := CheckProofOfWork(hash, h.Bits); err != nil {
+		return fmt.Errorf("block %d: %w", h.Height, err)
+	}
+	return nil
+}
+
+// CheckBlock validates a block as the next after state and returns the
+// EXS it mints
+func (v *Validator) CheckBlock(b *Block, state *ChainState) (Amount, error) {
+	if err := v.CheckHeader(&b.Header, state); err != nil {
+		return 0, err
+	}
+	if root := MerkleRoot(b.Transactions); b.Header.MerkleRoot != root {
+		return 0, fmt.Errorf("%w: header has %s, transactions give %s", ErrMerkleRoot, b.Header.MerkleRoot, root)
+	}
+	if len(b.Transactions) == 0 || !b.Transactions[0].IsCoinbase() {
+		return 0, fmt.Errorf("%w: first transaction must be the coinbase", ErrInvalidCoinbase)
+	}
+
+	// Duplicate transactions can leave the merkle root unchanged, and an
+	// output may only be spent once
+	seen := make(map[chainhash.Hash]bool, len(b.Transactions))
+	spent := make(map[OutPoint]bool)
+	for i, tx := range b.Transactions {
+		id := tx.Hash()
+		if seen[id] {
+			return 0, fmt.Errorf("%w: transaction %s appears twice", ErrInvalidTransaction, id)
+		}
+		seen[id] = true
+		if i > 0 && tx.IsCoinbase() {
+			return 0, fmt.Errorf("%w: transaction %d is a second coinbase", ErrInvalidCoinbase, i)
+		}
+		if err := checkTransaction(tx); err != nil {
+			return 0, fmt.Errorf("transaction %s: %w", id, err)
+		}
+		for _, in := range tx.Inputs {
+			if spent[in] {
+				return 0, fmt.Errorf("%w: %s:%d is spent twice", ErrInvalidTransaction, in.Hash, in.Index)
+			}
+			spent[in] = true
+		}
+	}
+
+	return v.checkCoinbase(b.Transactions[0], b.Header.Height, state.Supply)
+}
+
+// ConnectBlock validates a block as the next after state and returns the
+// state with it as the tip
+func (v *Validator) ConnectBlock(b *Block, state *ChainState) (*ChainState, error) {
+	minted, err := v.CheckBlock(b, state)
+	if err != nil {
+		return nil, err
+	}
+	recent := append(state.Recent[:len(state.Recent):len(state.Recent)], BlockTime{
+		Height:    b.Header.Height,
+		Timestamp: b.Header.Timestamp,
+		Bits:      b.Header.Bits,
+	})
+	if keep := max(RetargetWindow+1, MedianTimeBlocks); len(recent) > keep {
+		recent = recent[len(recent)-keep:]
+	}
+	return &ChainState{Tip: b.Header.Hash(), Recent: recent, Supply: state.Supply + minted}, nil
+}
+
+// checkCoinbase enforces the treasury allocation and the miner's share of
+// the subsidy, returning the EXS minted
+func (v *Validator) checkCoinbase(coinbase *Transaction, height uint32, supply Amount) (Amount, error) {
+	if supply < 0 || supply > MaxSupply {
+		return 0, fmt.Errorf("%w: supply %d before block %d", ErrMaxSupply, supply, height)
+	}
+	subsidy := SubsidyAt(height, supply)
+
+	treasury := TreasuryOutputs(v.params.TreasuryAddress, height, subsidy)
+	if len(coinbase.Outputs) < len(treasury) {
+		return 0, fmt.Errorf("%w: %d outputs, want at least %d treasury outputs", ErrTreasuryAllocation, len(coinbase.Outputs), len(treasury))
+	}
+	var minted Amount
+	for i, want := range treasury {
+		if got := coinbase.Outputs[i]; got != want {
+			return 0, fmt.Errorf("%w: output %d pays %d to %s locked until %d, want %d to %s locked until %d",
+				ErrTreasuryAllocation, i, got.Amount, got.Address, got.LockHeight, want.Amount, want.Address, want.LockHeight)
+		}
+		minted += want.Amount
+	}
+
+	allowed := subsidy - minted
+	var claimed Amount
+	for _, out := range coinbase.Outputs[len(treasury):] {
+		claimed += out.Amount
+	}
+	if claimed > allowed {
+		return 0, fmt.Errorf("%w: miner claims %d, allowed %d", ErrBlockReward, claimed, allowed)
+	}
+	minted += claimed
+	if supply+minted > MaxSupply {
+		return 0, fmt.Errorf("%w: %d + %d", ErrMaxSupply, supply, minted)
+	}
+	return minted, nil
+}
+
+// checkTransaction applies the rules that need no other transactions
+func checkTransaction(tx *Transaction) error {
+	if !tx.IsCoinbase() && len(tx.Outputs) == 0 {
+		return fmt.Errorf("%w: no outputs", ErrInvalidTransaction)
+	}
+	var total Amount
+	for i, out := range tx.Outputs {
+		if out.Address == "" {
+			return fmt.Errorf("%w: output %d has no address", ErrInvalidTransaction, i)
+		}
+		if out.Amount <= 0 || out.Amount > MaxSupply {
+			return fmt.Errorf("%w: output %d amount %d", ErrInvalidTransaction, i, out.Amount)
+		}
+		total += out.Amount
+		if total > MaxSupply {
+			return fmt.Errorf("%w: outputs total more than max supply", ErrInvalidTransaction)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	testTreasury = "bc1ptreasury"
+	testMiner    = "bc1pminer"
+	testGenesis  = 1_700_000_000
+)
+
+// quickHash stands in for Tetra-PoW so tests can mine at PowLimit quickly
+func quickHash(data []byte, nonce uint64) []byte {
+	h := sha256.New()
+	h.Write(data)
+	binary.Write(h, binary.LittleEndian, nonce)
+	return h.Sum(nil)
+}
+
+func newTestValidator(t *testing.T) *Validator {
+	t.Helper()
+	v, err := NewValidator(Params{Schedule: DefaultSchedule(), TreasuryAddress: testTreasury, PowHash: quickHash})
+	if err != nil {
+		t.Fatalf("NewValidator() error = %v", err)
+	}
+	v.now = func() time.Time { return time.Unix(testGenesis+1_000_000, 0) }
+	return v
+}
+
+func coinbase(height uint32, subsidy, miner Amount) *Transaction {
+	outputs := TreasuryOutputs(testTreasury, height, subsidy)
+	if miner > 0 {
+		outputs = append(outputs, Output{Address: testMiner, Amount: miner})
+	}
+	return &Transaction{Outputs: outputs}
+}
+
+// nextBlock builds an unsolved block after state paying the full subsidy
+func nextBlock(t *testing.T, state *ChainState, txs ...*Transaction) *Block {
+	t.Helper()
+	height := state.NextHeight()
+	subsidy := SubsidyAt(height, state.Supply)
+	cb := coinbase(height, subsidy, subsidy-subsidy*TreasuryReward/BlockReward)
+	bits, err := NextBits(state.Recent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := int64(testGenesis)
+	if n := len(state.Recent); n > 0 {
+		timestamp = state.Recent[n-1].Timestamp + TargetSpacing
+	}
+	b := &Block{
+		Header:       Header{Height: height, PrevBlock: state.Tip, Timestamp: timestamp, Bits: bits},
+		Transactions: append([]*Transaction{cb}, txs...),
+	}
+	b.Header.MerkleRoot = MerkleRoot(b.Transactions)
+	return b
+}
+
+// solve searches nonces until the header meets its target, if it has one
+func solve(v *Validator, h *Header) {
+	if _, err := CompactToTarget(h.Bits); err != nil {
+		return
+	}
+	for h.Nonce = 0; ; h.Nonce++ {
+		if hash, err := v.PowHash(h); err != nil || CheckProofOfWork(hash, h.Bits) == nil {
+			return
+		}
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	a := coinbase(0, BlockReward, 0)
+	b := &Transaction{Inputs: []OutPoint{{Index: 1}}, Outputs: []Output{{Address: "x", Amount: 1}}}
+	c := &Transaction{Inputs: []OutPoint{{Index: 2}}, Outputs: []Output{{Address: "y", Amount: 2}}}
+
+	if got := MerkleRoot([]*Transaction{a}); got != a.Hash() {
+		t.Errorf("MerkleRoot() of one transaction = %s, want its ID", got)
+	}
+	pair := func(l, r chainhash.Hash) chainhash.Hash {
+		return chainhash.DoubleHashH(append(l[:], r[:]...))
+	}
+	want := pair(pair(a.Hash(), b.Hash()), pair(c.Hash(), c.Hash()))
+	if got := MerkleRoot([]*Transaction{a, b, c}); got != want {
+		t.Errorf("MerkleRoot() = %s, want %s", got, want)
+	}
+	if MerkleRoot([]*Transaction{a, c, b}) == want {
+		t.Error("Expected the merkle root to commit to transaction order")
+	}
+}
+
+func TestConnectBlocks(t *testing.T) {
+	v := newTestValidator(t)
+	state := &ChainState{}
+	spend := &Transaction{Inputs: []OutPoint{{Hash: chainhash.Hash{1}}}, Outputs: []Output{{Address: testMiner, Amount: Coin}}}
+
+	for i := 0; i < 20; i++ {
+		b := nextBlock(t, state, spend)
+		solve(v, &b.Header)
+		next, err := v.ConnectBlock(b, state)
+		if err != nil {
+			t.Fatalf("ConnectBlock(%d) error = %v", i, err)
+		}
+		if next.Tip != b.Header.Hash() || next.NextHeight() != uint32(i+1) {
+			t.Fatalf("Block %d: tip %s at next height %d", i, next.Tip, next.NextHeight())
+		}
+		state = next
+		spend = &Transaction{Inputs: []OutPoint{{Hash: spend.Hash()}}, Outputs: spend.Outputs}
+	}
+	if want := 20 * BlockReward; state.Supply != want {
+		t.Errorf("Supply = %d, want %d", state.Supply, want)
+	}
+
+	// Reconnecting to the same parent is fine, but not to a stale one
+	b := nextBlock(t, state)
+	solve(v, &b.Header)
+	if _, err := v.ConnectBlock(b, state); err != nil {
+		t.Errorf("ConnectBlock() error = %v", err)
+	}
+	b.Header.PrevBlock = chainhash.Hash{}
+	solve(v, &b.Header)
+	if _, err := v.ConnectBlock(b, state); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("ConnectBlock() on the wrong parent error = %v, want ErrInvalidHeader", err)
+	}
+}
+
+func TestBlockRules(t *testing.T) {
+	v := newTestValidator(t)
+	state := &ChainState{}
+	for i := 0; i < MedianTimeBlocks; i++ {
+		b := nextBlock(t, state)
+		solve(v, &b.Header)
+		var err error
+		if state, err = v.ConnectBlock(b, state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	height := state.NextHeight()
+	treasury := TreasuryOutputs(testTreasury, height, BlockReward)
+	input := OutPoint{Hash: chainhash.Hash{7}}
+	transfer := func(amount Amount) *Transaction {
+		return &Transaction{Inputs: []OutPoint{input}, Outputs: []Output{{Address: testMiner, Amount: amount}}}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(b *Block)
+		// keepRoot skips recomputing the merkle root after mutate
+		keepRoot bool
+		want     error
+	}{
+		{"wrong height", func(b *Block) { b.Header.Height++ }, false, ErrInvalidHeader},
+		{"easier bits", func(b *Block) { b.Header.Bits = PowLimitBits + 1 }, false, ErrInvalidHeader},
+		{"at median time", func(b *Block) { b.Header.Timestamp = MedianTime(state.Recent) }, false, ErrTimestamp},
+		{"far future", func(b *Block) { b.Header.Timestamp = v.now().Unix() + MaxFutureBlockTime + 1 }, false, ErrTimestamp},
+		{"stale merkle root", func(b *Block) { b.Transactions = append(b.Transactions, transfer(Coin)) }, true, ErrMerkleRoot},
+		{"no coinbase", func(b *Block) { b.Transactions[0] = transfer(Coin) }, false, ErrInvalidCoinbase},
+		{"two coinbases", func(b *Block) { b.Transactions = append(b.Transactions, coinbase(height+1, BlockReward, Coin)) }, false, ErrInvalidCoinbase},
+		{"duplicate transaction", func(b *Block) { b.Transactions = append(b.Transactions, transfer(Coin), transfer(Coin)) }, false, ErrInvalidTransaction},
+		{"double spend", func(b *Block) { b.Transactions = append(b.Transactions, transfer(Coin), transfer(2*Coin)) }, false, ErrInvalidTransaction},
+		{"zero output", func(b *Block) { b.Transactions = append(b.Transactions, transfer(0)) }, false, ErrInvalidTransaction},
+		{"no treasury", func(b *Block) { b.Transactions[0] = coinbase(height, 0, BlockReward-TreasuryReward) }, false, ErrTreasuryAllocation},
+		{"short treasury", func(b *Block) { b.Transactions[0].Outputs[2].Amount-- }, false, ErrTreasuryAllocation},
+		{"unlocked treasury", func(b *Block) { b.Transactions[0].Outputs[2].LockHeight = height }, false, ErrTreasuryAllocation},
+		{"treasury elsewhere", func(b *Block) { b.Transactions[0].Outputs[0].Address = testMiner }, false, ErrTreasuryAllocation},
+		{"miner overpaid", func(b *Block) { b.Transactions[0].Outputs[3].Amount++ }, false, ErrBlockReward},
+		{"miner takes all", func(b *Block) { b.Transactions[0].Outputs = []Output{{Address: testMiner, Amount: BlockReward}} }, false, ErrTreasuryAllocation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := nextBlock(t, state)
+			tt.mutate(b)
+			if !tt.keepRoot {
+				b.Header.MerkleRoot = MerkleRoot(b.Transactions)
+			}
+			solve(v, &b.Header)
+			if _, err := v.CheckBlock(b, state); !errors.Is(err, tt.want) {
+				t.Errorf("CheckBlock() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// The miner may leave part of its share unclaimed
+	b := nextBlock(t, state)
+	b.Transactions[0].Outputs = append(treasury, Output{Address: testMiner, Amount: Coin})
+	b.Header.MerkleRoot = MerkleRoot(b.Transactions)
+	solve(v, &b.Header)
+	if minted, err := v.CheckBlock(b, state); err != nil || minted != TreasuryReward+Coin {
+		t.Errorf("CheckBlock() underpaid = %d, %v, want %d", minted, err, TreasuryReward+Coin)
+	}
+
+	// An unsolved header fails proof of work
+	b.Header.Nonce++
+	for CheckProofOfWork(quickHash(v.params.Schedule.WorkData(&b.Header), b.Header.Nonce), b.Header.Bits) == nil {
+		b.Header.Nonce++
+	}
+	if _, err := v.CheckBlock(b, state); !errors.Is(err, ErrHighHash) {
+		t.Errorf("CheckBlock() unsolved error = %v, want ErrHighHash", err)
+	}
+}
+
+func TestMaxSupply(t *testing.T) {
+	if got := TreasuryReward; got != 15*BlockReward/100 {
+		t.Errorf("TreasuryReward = %d, want 15%% of %d", got, BlockReward)
+	}
+	outputs := TreasuryOutputs(testTreasury, 100, BlockReward)
+	if len(outputs) != 3 {
+		t.Fatalf("Expected 3 treasury outputs, got %d", len(outputs))
+	}
+	for i, want := range []uint32{100, 4420, 8740} {
+		if outputs[i].Amount != 250_000_000 || outputs[i].LockHeight != want {
+			t.Errorf("Treasury output %d = %+v, want 2.5 EXS locked until %d", i, outputs[i], want)
+		}
+	}
+
+	tests := []struct {
+		height       uint32
+		supply, want Amount
+	}{
+		{0, 0, BlockReward},
+		{0, MaxSupply - BlockReward, BlockReward},
+		{0, MaxSupply - 10*Coin, 10 * Coin},
+		{0, MaxSupply, 0},
+		{209_999, 0, BlockReward},
+		{211_000, 0, BlockReward / 2},
+	}
+	for _, tt := range tests {
+		if got := SubsidyAt(tt.height, tt.supply); got != tt.want {
+			t.Errorf("SubsidyAt(%d, %d) = %d, want %d", tt.height, tt.supply, got, tt.want)
+		}
+	}
+
+	// The last partial block splits what is left, then blocks mint nothing
+	v := newTestValidator(t)
+	state := &ChainState{Supply: MaxSupply - 10*Coin}
+	b := nextBlock(t, state)
+	solve(v, &b.Header)
+	next, err := v.ConnectBlock(b, state)
+	if err != nil {
+		t.Fatalf("ConnectBlock() at the cap error = %v", err)
+	}
+	if next.Supply != MaxSupply {
+		t.Errorf("Supply = %d, want MaxSupply", next.Supply)
+	}
+	if treasury := TreasuryOutputs(testTreasury, 0, 10*Coin); treasury[0].Amount+treasury[1].Amount+treasury[2].Amount != 150_000_000 {
+		t.Errorf("Expected a 1.5 EXS treasury share of 10 EXS, got %+v", treasury)
+	}
+
+	b = nextBlock(t, next)
+	b.Transactions[0].Outputs = []Output{{Address: testMiner, Amount: 1}}
+	b.Header.MerkleRoot = MerkleRoot(b.Transactions)
+	solve(v, &b.Header)
+	if _, err := v.ConnectBlock(b, next); !errors.Is(err, ErrBlockReward) {
+		t.Errorf("ConnectBlock() past the cap error = %v, want ErrBlockReward", err)
+	}
+	b.Transactions[0].Outputs = nil
+	b.Header.MerkleRoot = MerkleRoot(b.Transactions)
+	solve(v, &b.Header)
+	if after, err := v.ConnectBlock(b, next); err != nil || after.Supply != MaxSupply {
+		t.Errorf("ConnectBlock() empty coinbase = %v, want supply held at the cap", err)
+	}
+
+	over := &ChainState{Supply: MaxSupply + 1}
+	b = nextBlock(t, over)
+	solve(v, &b.Header)
+	if _, err := v.CheckBlock(b, over); !errors.Is(err, ErrMaxSupply) {
+		t.Errorf("CheckBlock() on a supply past the cap error = %v, want ErrMaxSupply", err)
+	}
+}
+
+func TestTetraPoWHeader(t *testing.T) {
+	v, err := NewValidator(Params{Schedule: DefaultSchedule(), TreasuryAddress: testTreasury})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return time.Unix(testGenesis, 0) }
+
+	// Without a PowHash the validator checks real Tetra-PoW hashes, most of
+	// which miss even the pow limit
+	state := &ChainState{}
+	b := nextBlock(t, state)
+	for {
+		hash := crypto.TetraPoWHash(DefaultSchedule().WorkData(&b.Header), b.Header.Nonce)
+		if CheckProofOfWork(hash, b.Header.Bits) != nil {
+			break
+		}
+		b.Header.Nonce++
+	}
+	if err := v.CheckHeader(&b.Header, state); !errors.Is(err, ErrHighHash) {
+		t.Errorf("CheckHeader() error = %v, want ErrHighHash", err)
+	}
+}
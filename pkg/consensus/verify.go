@@ -0,0 +1,46 @@
+package consensus
+
+// Verification cost. A Tetra-PoW hash runs 600,000 PBKDF2 rounds, tens of
+// milliseconds on a server core, so checking proof of work dominates
+// validation. Full validators check every header once and keep HPP-1
+// outputs in a crypto.HPP1Cache, so revalidating a header, as block
+// validation after header-first sync or a reorg does, costs only the state
+// rounds. Light validators, meant for SPV clients following headers,
+// enforce every other header rule but check proof of work on a random
+// sample of headers: a forged chain of n headers passes with probability
+// (1-1/LightSample)^n.
+
+// DefaultLightSample checks one header in 16, so a forged chain of 100
+// headers goes unnoticed about once in 630 tries
+const DefaultLightSample = 16
+
+// VerifyMode selects how a Validator checks proof of work
+type VerifyMode int
+
+const (
+	// VerifyFull checks the proof of work of every header
+	VerifyFull VerifyMode = iota
+	// VerifyLight checks the proof of work of a random sample of headers
+	VerifyLight
+)
+
+// String names the mode
+func (m VerifyMode) String() string {
+	switch m {
+	case VerifyFull:
+		return "full"
+	case VerifyLight:
+		return "light"
+	default:
+		return "unknown"
+	}
+}
+
+// PowCacheStats returns the HPP-1 lookups the validator answered from its
+// cache and the ones it computed, zero without a cache
+func (v *Validator) PowCacheStats() (hits, misses uint64) {
+	if v.cache == nil {
+		return 0, 0
+	}
+	return v.cache.Stats()
+}
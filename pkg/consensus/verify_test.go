@@ -0,0 +1,83 @@
+package consensus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// unsolved sets the first nonce whose hash misses the header's target
+func unsolved(h *Header, hash func(data []byte, nonce uint64) []byte) {
+	for CheckProofOfWork(hash(DefaultSchedule().WorkData(h), h.Nonce), h.Bits) == nil {
+		h.Nonce++
+	}
+}
+
+func TestPowCache(t *testing.T) {
+	state := &ChainState{}
+	b := nextBlock(t, state)
+	unsolved(&b.Header, crypto.TetraPoWHash)
+
+	v, err := NewValidator(Params{Schedule: DefaultSchedule(), TreasuryAddress: testTreasury})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return time.Unix(testGenesis, 0) }
+	for i := 0; i < 2; i++ {
+		if err := v.CheckHeader(&b.Header, state); !errors.Is(err, ErrHighHash) {
+			t.Fatalf("CheckHeader() error = %v, want ErrHighHash", err)
+		}
+	}
+	if hits, misses := v.PowCacheStats(); hits != 1 || misses != 1 {
+		t.Errorf("PowCacheStats() = %d hits, %d misses, want 1, 1", hits, misses)
+	}
+
+	uncached, err := NewValidator(Params{Schedule: DefaultSchedule(), TreasuryAddress: testTreasury, PowCacheSize: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncached.now = v.now
+	if err := uncached.CheckHeader(&b.Header, state); !errors.Is(err, ErrHighHash) {
+		t.Errorf("CheckHeader() without a cache error = %v, want ErrHighHash", err)
+	}
+	if hits, misses := uncached.PowCacheStats(); hits != 0 || misses != 0 {
+		t.Errorf("PowCacheStats() without a cache = %d, %d, want 0, 0", hits, misses)
+	}
+}
+
+func TestLightVerification(t *testing.T) {
+	if _, err := NewValidator(Params{Schedule: DefaultSchedule(), TreasuryAddress: testTreasury, Verification: 2}); err == nil {
+		t.Error("Expected an unknown verification mode to be refused")
+	}
+	v, err := NewValidator(Params{Schedule: DefaultSchedule(), TreasuryAddress: testTreasury,
+		PowHash: quickHash, Verification: VerifyLight})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.params.LightSample != DefaultLightSample {
+		t.Errorf("LightSample = %d, want %d", v.params.LightSample, DefaultLightSample)
+	}
+	v.now = func() time.Time { return time.Unix(testGenesis, 0) }
+	checked := false
+	v.sample = func() bool { return checked }
+
+	state := &ChainState{}
+	b := nextBlock(t, state)
+	unsolved(&b.Header, quickHash)
+	if err := v.CheckHeader(&b.Header, state); err != nil {
+		t.Errorf("CheckHeader() of an unsampled header error = %v", err)
+	}
+	checked = true
+	if err := v.CheckHeader(&b.Header, state); !errors.Is(err, ErrHighHash) {
+		t.Errorf("CheckHeader() of a sampled header error = %v, want ErrHighHash", err)
+	}
+
+	// Every other rule still applies to unsampled headers
+	checked = false
+	b.Header.Bits = PowLimitBits + 1
+	if err := v.CheckHeader(&b.Header, state); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("CheckHeader() with easier bits error = %v, want ErrInvalidHeader", err)
+	}
+}
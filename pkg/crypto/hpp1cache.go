@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// DefaultHPP1CacheSize is the number of HPP-1 outputs an HPP1Cache keeps
+// when created with a size of zero, about a day of blocks
+const DefaultHPP1CacheSize = 4096
+
+// HPP1Cache remembers recent HPP-1 outputs, keyed by password, salt and key
+// length, so a node verifying the same header again, as header-first sync
+// followed by block validation or a reorg does, runs PBKDF2 only once. It
+// is safe for concurrent use and evicts the least recently used output.
+type HPP1Cache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+type hpp1Entry struct {
+	key   [sha256.Size]byte
+	value []byte
+}
+
+// NewHPP1Cache creates a cache holding up to size outputs,
+// DefaultHPP1CacheSize if size is zero or less
+func NewHPP1Cache(size int) *HPP1Cache {
+	if size <= 0 {
+		size = DefaultHPP1CacheSize
+	}
+	return &HPP1Cache{size: size, order: list.New(), entries: make(map[[sha256.Size]byte]*list.Element)}
+}
+
+// HPP1 returns HPP1(password, salt, keyLen), computing it only on a miss
+func (c *HPP1Cache) HPP1(password, salt []byte, keyLen int) []byte {
+	key := hpp1CacheKey(password, salt, keyLen)
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		c.hits++
+		value := append([]byte(nil), e.Value.(*hpp1Entry).value...)
+		c.mu.Unlock()
+		return value
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// Derive outside the lock so misses on other keys run in parallel
+	value := HPP1(password, salt, keyLen)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.order.PushFront(&hpp1Entry{key: key, value: append([]byte(nil), value...)})
+		if c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*hpp1Entry).key)
+		}
+	}
+	return value
+}
+
+// TetraPoWHash is TetraPoWHash with the HPP-1 step served from the cache
+func (c *HPP1Cache) TetraPoWHash(data []byte, nonce uint64) []byte {
+	seed := c.HPP1(tetraPoWInput(data, nonce), []byte(DefaultSalt), 32)
+	return NewTetraPoWState(seed).Compute()
+}
+
+// Stats returns the number of lookups answered from the cache and the
+// number that ran HPP-1
+func (c *HPP1Cache) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Len returns the number of outputs held
+func (c *HPP1Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// hpp1CacheKey hashes the inputs with their lengths, so no two distinct
+// (password, salt) pairs share a key
+func hpp1CacheKey(password, salt []byte, keyLen int) [sha256.Size]byte {
+	h := sha256.New()
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(password)))
+	h.Write(n[:])
+	h.Write(password)
+	binary.LittleEndian.PutUint64(n[:], uint64(len(salt)))
+	h.Write(n[:])
+	h.Write(salt)
+	binary.LittleEndian.PutUint64(n[:], uint64(keyLen))
+	h.Write(n[:])
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
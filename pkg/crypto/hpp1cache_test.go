@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestHPP1Cache(t *testing.T) {
+	c := NewHPP1Cache(2)
+	salt := []byte(DefaultSalt)
+
+	a := c.HPP1([]byte("a"), salt, 32)
+	if !bytes.Equal(a, HPP1([]byte("a"), salt, 32)) {
+		t.Fatal("cached HPP-1 output differs from HPP1")
+	}
+	a[0] ^= 0xff
+	if got := c.HPP1([]byte("a"), salt, 32); bytes.Equal(got, a) {
+		t.Error("modifying a returned output changed the cache")
+	}
+	if hits, misses := c.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("Stats() = %d hits, %d misses, want 1, 1", hits, misses)
+	}
+
+	// The salt and key length are part of the key
+	if bytes.Equal(c.HPP1([]byte("a"), []byte("other"), 32), c.HPP1([]byte("a"), salt, 32)) {
+		t.Error("different salts share a cached output")
+	}
+	if _, misses := c.Stats(); misses != 2 {
+		t.Errorf("misses = %d, want 2", misses)
+	}
+
+	// "a" was used last, so "a"+"other" is evicted by "b"
+	c.HPP1([]byte("b"), salt, 32)
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+	c.HPP1([]byte("a"), salt, 32)
+	c.HPP1([]byte("a"), []byte("other"), 32)
+	if hits, misses := c.Stats(); hits != 3 || misses != 4 {
+		t.Errorf("Stats() after eviction = %d hits, %d misses, want 3, 4", hits, misses)
+	}
+}
+
+func TestHPP1CacheTetraPoWHash(t *testing.T) {
+	c := NewHPP1Cache(0)
+	data := []byte("excalibur block")
+	want := TetraPoWHash(data, 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := c.TetraPoWHash(data, 3); !bytes.Equal(got, want) {
+				t.Errorf("cached TetraPoWHash = %x, want %x", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := c.TetraPoWHash(data, 3); !bytes.Equal(got, want) {
+		t.Errorf("cached TetraPoWHash = %x, want %x", got, want)
+	}
+	if hits, _ := c.Stats(); hits == 0 || c.Len() != 1 {
+		t.Errorf("Expected one cached output and a hit, got %d hits and %d outputs", hits, c.Len())
+	}
+}
@@ -0,0 +1,420 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultJobBatch is the number of nonces per worker a MiningJob searches
+// between progress reports, pauses and checkpoints
+const DefaultJobBatch = 16
+
+var (
+	// ErrJobStarted indicates Start was called on a job that already ran
+	ErrJobStarted = errors.New("mining job already started")
+	// ErrCheckpointMismatch indicates a checkpoint saved for other data or
+	// another difficulty
+	ErrCheckpointMismatch = errors.New("checkpoint is for a different job")
+)
+
+// SearchFunc searches the nonces first to last for one meeting difficulty.
+// It behaves like ParallelTetraPoWRange, returning the work done along with
+// ErrNonceSpaceExhausted or a context error.
+type SearchFunc func(ctx context.Context, data []byte, difficulty uint64, first, last uint64) (*ParallelResult, error)
+
+// JobState is the lifecycle state of a MiningJob
+type JobState int
+
+const (
+	// JobIdle is a job that has not been started
+	JobIdle JobState = iota
+	// JobRunning is a job searching for a nonce
+	JobRunning
+	// JobPaused is a started job waiting for Resume
+	JobPaused
+	// JobStopped is a job stopped by Stop or its context
+	JobStopped
+	// JobDone is a job that found a nonce or exhausted the nonce space
+	JobDone
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobIdle:
+		return "idle"
+	case JobRunning:
+		return "running"
+	case JobPaused:
+		return "paused"
+	case JobStopped:
+		return "stopped"
+	case JobDone:
+		return "done"
+	default:
+		return fmt.Sprintf("JobState(%d)", int(s))
+	}
+}
+
+// JobConfig configures a MiningJob
+type JobConfig struct {
+	Data       []byte
+	Difficulty uint64
+	// Workers is the number of CPU workers of the default search
+	Workers int
+	// Batch is the number of nonces searched at once; 0 means
+	// DefaultJobBatch per worker
+	Batch uint64
+	// Search replaces the CPU search, e.g. with an accelerator's
+	Search SearchFunc
+	// Checkpoint is a file the job resumes from and saves its next nonce
+	// to; empty disables checkpointing
+	Checkpoint string
+	// CheckpointInterval is the least time between checkpoint writes; the
+	// job always saves one when it stops
+	CheckpointInterval time.Duration
+	// OnProgress is called from the mining goroutine at most once per
+	// ProgressInterval, after a batch
+	OnProgress       func(JobProgress)
+	ProgressInterval time.Duration
+}
+
+// JobProgress reports the work of a MiningJob, including the work of the
+// runs it resumed from
+type JobProgress struct {
+	// Next is the first nonce not yet searched
+	Next      uint64
+	Hashes    uint64
+	BestNonce uint64
+	Best      []byte
+	// Elapsed and HashRate cover this run only
+	Elapsed  time.Duration
+	HashRate float64
+}
+
+// MiningJob searches the nonce space in batches from a starting nonce,
+// so it can be paused, stopped and resumed where it left off. A stopped
+// job saves its next nonce to the checkpoint file, and a new job for the
+// same data and difficulty continues from it.
+type MiningJob struct {
+	cfg JobConfig
+
+	mu       sync.Mutex
+	state    JobState
+	progress JobProgress
+	// runHashes counts the hashes of this run, for the hash rate
+	runHashes uint64
+	started   time.Time
+	// cancel stops the job, and cancelBatch interrupts the running batch
+	// when it is paused
+	cancel      context.CancelFunc
+	cancelBatch context.CancelFunc
+	resume      chan struct{}
+	done        chan struct{}
+	result      *ParallelResult
+	err         error
+}
+
+// NewMiningJob creates a job, resuming from its checkpoint file when one
+// exists
+func NewMiningJob(cfg JobConfig) (*MiningJob, error) {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.Batch == 0 {
+		cfg.Batch = uint64(cfg.Workers) * DefaultJobBatch
+	}
+	if cfg.Search == nil {
+		workers := cfg.Workers
+		cfg.Search = func(ctx context.Context, data []byte, difficulty uint64, first, last uint64) (*ParallelResult, error) {
+			return ParallelTetraPoWRange(ctx, data, difficulty, workers, first, last)
+		}
+	}
+
+	j := &MiningJob{cfg: cfg, done: make(chan struct{})}
+	if cfg.Checkpoint != "" {
+		progress, err := loadCheckpoint(cfg.Checkpoint, cfg.Data, cfg.Difficulty)
+		if err != nil {
+			return nil, err
+		}
+		j.progress = progress
+	}
+	return j, nil
+}
+
+// Start begins mining in a new goroutine. Cancelling ctx stops the job
+// like Stop.
+func (j *MiningJob) Start(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != JobIdle {
+		return ErrJobStarted
+	}
+	ctx, j.cancel = context.WithCancel(ctx)
+	j.state = JobRunning
+	j.started = time.Now()
+	go j.run(ctx)
+	return nil
+}
+
+// Pause interrupts the running batch and waits for Resume. The batch is
+// searched again from its first nonce.
+func (j *MiningJob) Pause() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != JobRunning {
+		return
+	}
+	j.state = JobPaused
+	j.resume = make(chan struct{})
+	if j.cancelBatch != nil {
+		j.cancelBatch()
+	}
+}
+
+// Resume continues a paused job
+func (j *MiningJob) Resume() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != JobPaused {
+		return
+	}
+	j.state = JobRunning
+	close(j.resume)
+}
+
+// Stop stops the job, saves its checkpoint and waits for it to finish
+func (j *MiningJob) Stop() {
+	j.mu.Lock()
+	cancel := j.cancel
+	j.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-j.done
+}
+
+// Wait blocks until the job finds a nonce, exhausts the nonce space or is
+// stopped, and returns the found nonce or why there is none
+func (j *MiningJob) Wait() (*ParallelResult, error) {
+	<-j.done
+	return j.result, j.err
+}
+
+// Done is closed when the job finishes
+func (j *MiningJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// State returns the job's lifecycle state
+func (j *MiningJob) State() JobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// Progress returns the work done so far
+func (j *MiningJob) Progress() JobProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.snapshot()
+}
+
+// snapshot copies the progress with the run's hash rate; j.mu is held
+func (j *MiningJob) snapshot() JobProgress {
+	p := j.progress
+	p.Best = append([]byte(nil), p.Best...)
+	if !j.started.IsZero() {
+		p.Elapsed = time.Since(j.started)
+		if p.Elapsed > 0 {
+			p.HashRate = float64(j.runHashes) / p.Elapsed.Seconds()
+		}
+	}
+	return p
+}
+
+func (j *MiningJob) run(ctx context.Context) {
+	var lastReport, lastSave time.Time
+	finish := func(state JobState, result *ParallelResult, err error) {
+		if j.cfg.Checkpoint != "" {
+			var saveErr error
+			if result != nil {
+				// The nonce is found; a later run starts a new search
+				if saveErr = os.Remove(j.cfg.Checkpoint); errors.Is(saveErr, os.ErrNotExist) {
+					saveErr = nil
+				}
+			} else {
+				saveErr = j.save()
+			}
+			if err == nil && saveErr != nil {
+				err = fmt.Errorf("checkpoint: %w", saveErr)
+			}
+		}
+		j.mu.Lock()
+		j.state, j.result, j.err = state, result, err
+		progress := j.snapshot()
+		j.mu.Unlock()
+		if j.cfg.OnProgress != nil {
+			j.cfg.OnProgress(progress)
+		}
+		j.cancel()
+		close(j.done)
+	}
+
+	for {
+		j.mu.Lock()
+		resume := j.resume
+		paused := j.state == JobPaused
+		j.mu.Unlock()
+		if paused {
+			select {
+			case <-ctx.Done():
+				finish(JobStopped, nil, ctx.Err())
+				return
+			case <-resume:
+			}
+		}
+		if ctx.Err() != nil {
+			finish(JobStopped, nil, ctx.Err())
+			return
+		}
+
+		j.mu.Lock()
+		first := j.progress.Next
+		last := first + j.cfg.Batch - 1
+		if last < first {
+			last = math.MaxUint64
+		}
+		batchCtx, cancelBatch := context.WithCancel(ctx)
+		j.cancelBatch = cancelBatch
+		j.mu.Unlock()
+
+		result, err := j.cfg.Search(batchCtx, j.cfg.Data, j.cfg.Difficulty, first, last)
+		cancelBatch()
+
+		j.mu.Lock()
+		j.cancelBatch = nil
+		if result != nil {
+			j.progress.Hashes += result.Hashes()
+			j.runHashes += result.Hashes()
+			if result.Best != nil && (j.progress.Best == nil || lowerHash(result.Best, j.progress.Best)) {
+				j.progress.Best, j.progress.BestNonce = result.Best, result.BestNonce
+			}
+		}
+		exhausted := errors.Is(err, ErrNonceSpaceExhausted)
+		if exhausted {
+			j.progress.Next = last + 1
+		}
+		j.mu.Unlock()
+
+		switch {
+		case err == nil:
+			j.mu.Lock()
+			j.progress.Next = result.Nonce + 1
+			j.mu.Unlock()
+			finish(JobDone, result, nil)
+			return
+		case exhausted && last == math.MaxUint64:
+			finish(JobDone, nil, ErrNonceSpaceExhausted)
+			return
+		case exhausted:
+		case ctx.Err() != nil:
+			finish(JobStopped, nil, ctx.Err())
+			return
+		case batchCtx.Err() != nil:
+			// Paused mid-batch
+			continue
+		default:
+			finish(JobStopped, nil, err)
+			return
+		}
+
+		now := time.Now()
+		if j.cfg.Checkpoint != "" && now.Sub(lastSave) >= j.cfg.CheckpointInterval {
+			if err := j.save(); err != nil {
+				finish(JobStopped, nil, fmt.Errorf("checkpoint: %w", err))
+				return
+			}
+			lastSave = now
+		}
+		if j.cfg.OnProgress != nil && now.Sub(lastReport) >= j.cfg.ProgressInterval {
+			j.cfg.OnProgress(j.Progress())
+			lastReport = now
+		}
+	}
+}
+
+// checkpoint is the JSON form of a job's checkpoint file
+type checkpoint struct {
+	Data       string    `json:"data"`
+	Difficulty uint64    `json:"difficulty"`
+	Next       uint64    `json:"next_nonce"`
+	Hashes     uint64    `json:"hashes"`
+	BestNonce  uint64    `json:"best_nonce"`
+	Best       string    `json:"best_hash,omitempty"`
+	Saved      time.Time `json:"saved_at"`
+}
+
+// save writes the job's progress to its checkpoint file atomically
+func (j *MiningJob) save() error {
+	j.mu.Lock()
+	c := checkpoint{
+		Data:       hex.EncodeToString(j.cfg.Data),
+		Difficulty: j.cfg.Difficulty,
+		Next:       j.progress.Next,
+		Hashes:     j.progress.Hashes,
+		BestNonce:  j.progress.BestNonce,
+		Best:       hex.EncodeToString(j.progress.Best),
+		Saved:      time.Now().UTC(),
+	}
+	j.mu.Unlock()
+
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.cfg.Checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, append(raw, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.cfg.Checkpoint)
+}
+
+// loadCheckpoint reads the progress saved at path, or none when the file
+// does not exist
+func loadCheckpoint(path string, data []byte, difficulty uint64) (JobProgress, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return JobProgress{}, nil
+	}
+	if err != nil {
+		return JobProgress{}, err
+	}
+	var c checkpoint
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return JobProgress{}, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	saved, err := hex.DecodeString(c.Data)
+	if err != nil {
+		return JobProgress{}, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	if !bytes.Equal(saved, data) || c.Difficulty != difficulty {
+		return JobProgress{}, fmt.Errorf("%w: %s", ErrCheckpointMismatch, path)
+	}
+	best, err := hex.DecodeString(c.Best)
+	if err != nil || (len(best) != 0 && len(best) < 8) {
+		return JobProgress{}, fmt.Errorf("checkpoint %s: invalid best_hash", path)
+	}
+	if len(best) == 0 {
+		best = nil
+	}
+	return JobProgress{Next: c.Next, Hashes: c.Hashes, BestNonce: c.BestNonce, Best: best}, nil
+}
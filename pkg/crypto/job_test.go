@@ -0,0 +1,130 @@
+package crypto
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSearch finds the nonce target after counting every nonce before it,
+// sleeping per batch so a job can be paused and stopped mid-search
+func fakeSearch(target uint64, searched *atomic.Uint64) SearchFunc {
+	return func(ctx context.Context, data []byte, difficulty uint64, first, last uint64) (*ParallelResult, error) {
+		select {
+		case <-ctx.Done():
+			return &ParallelResult{}, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+		hash := make([]byte, 32)
+		binary.LittleEndian.PutUint64(hash, ^first)
+		result := &ParallelResult{Best: hash, BestNonce: first}
+		if target >= first && target <= last {
+			searched.Add(target - first + 1)
+			result.Workers = []WorkerStats{{Hashes: target - first + 1}}
+			result.Nonce, result.Hash = target, hash
+			return result, nil
+		}
+		searched.Add(last - first + 1)
+		result.Workers = []WorkerStats{{Hashes: last - first + 1}}
+		return result, ErrNonceSpaceExhausted
+	}
+}
+
+func TestMiningJob(t *testing.T) {
+	data := []byte("test-block-data")
+	job, err := NewMiningJob(JobConfig{Data: data, Difficulty: 0xFFFFFFFFFFFFFF00, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := job.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := job.Start(context.Background()); !errors.Is(err, ErrJobStarted) {
+		t.Errorf("Expected ErrJobStarted, got %v", err)
+	}
+	result, err := job.Wait()
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if !meetsDifficulty(TetraPoWHash(data, result.Nonce), 0xFFFFFFFFFFFFFF00) {
+		t.Error("Found nonce does not meet difficulty")
+	}
+	if job.State() != JobDone || job.Progress().Hashes == 0 {
+		t.Errorf("Expected a done job with hashes, got %s with %d", job.State(), job.Progress().Hashes)
+	}
+}
+
+func TestMiningJobCheckpoint(t *testing.T) {
+	var searched atomic.Uint64
+	path := filepath.Join(t.TempDir(), "job.json")
+	var reports atomic.Int32
+	cfg := JobConfig{
+		Data:       []byte("data"),
+		Difficulty: 1,
+		Batch:      10,
+		Search:     fakeSearch(1000, &searched),
+		Checkpoint: path,
+		OnProgress: func(JobProgress) { reports.Add(1) },
+	}
+
+	job, err := NewMiningJob(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Start(context.Background())
+	for job.Progress().Next < 100 {
+		time.Sleep(time.Millisecond)
+	}
+	job.Pause()
+	// A batch finishing as the job pauses still counts
+	time.Sleep(5 * time.Millisecond)
+	paused := job.Progress().Next
+	time.Sleep(20 * time.Millisecond)
+	if job.State() != JobPaused || job.Progress().Next != paused {
+		t.Fatalf("Expected a paused job to stay at %d, got %s at %d", paused, job.State(), job.Progress().Next)
+	}
+	job.Resume()
+	for job.Progress().Next < paused+50 {
+		time.Sleep(time.Millisecond)
+	}
+	job.Stop()
+	if _, err := job.Wait(); !errors.Is(err, context.Canceled) || job.State() != JobStopped {
+		t.Fatalf("Expected a stopped job, got %s: %v", job.State(), err)
+	}
+	stopped := job.Progress()
+	if reports.Load() == 0 {
+		t.Error("Expected progress reports")
+	}
+
+	// A new job resumes at the checkpoint and removes it when done
+	resumed, err := NewMiningJob(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resumed.Progress(); got.Next != stopped.Next || got.Hashes != stopped.Hashes {
+		t.Fatalf("Resumed at %d with %d hashes, want %d with %d", got.Next, got.Hashes, stopped.Next, stopped.Hashes)
+	}
+	resumed.Start(context.Background())
+	result, err := resumed.Wait()
+	if err != nil || result.Nonce != 1000 {
+		t.Fatalf("Wait() = %+v, %v, want nonce 1000", result, err)
+	}
+	if got := resumed.Progress().Hashes; got != searched.Load() || got != 1001 {
+		t.Errorf("Expected 1001 hashes across both runs, got %d (searched %d)", got, searched.Load())
+	}
+	if fresh, _ := NewMiningJob(cfg); fresh.Progress().Next != 0 {
+		t.Error("Expected the checkpoint to be removed once the nonce was found")
+	}
+
+	cfg.Data = []byte("other")
+	job, _ = NewMiningJob(JobConfig{Data: []byte("data"), Difficulty: 1, Batch: 10, Search: fakeSearch(1<<40, &searched), Checkpoint: path})
+	job.Start(context.Background())
+	job.Stop()
+	if _, err := NewMiningJob(cfg); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Expected ErrCheckpointMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package crypto
+
+import (
+	"golang.org/x/crypto/argon2"
+)
+
+// Memory-hard Tetra-PoW. HPP-1 is PBKDF2, which needs almost no memory and
+// so pipelines cheaply on ASICs and FPGAs. The memory-hard variant derives
+// the Tetra-PoW seed with Argon2id instead, making every nonce fill and
+// read back Argon2Memory of RAM; the 128 state shifts that follow are
+// unchanged.
+
+const (
+	// Argon2Time is the number of Argon2id passes over memory per hash
+	Argon2Time = 1
+	// Argon2Memory is the memory one hash fills, in KiB (64 MiB)
+	Argon2Memory = 64 * 1024
+	// Argon2Threads is the Argon2id parallelism. One lane keeps a hash
+	// sequential, so more memory, not more cores, buys more hashes.
+	Argon2Threads = 1
+)
+
+// TetraPoWArgon2Hash computes the memory-hard Tetra-PoW hash of data with a
+// single nonce, a drop-in replacement for TetraPoWHash
+func TetraPoWArgon2Hash(data []byte, nonce uint64) []byte {
+	seed := argon2.IDKey(tetraPoWInput(data, nonce), []byte(DefaultSalt), Argon2Time, Argon2Memory, Argon2Threads, 32)
+	return NewTetraPoWState(seed).Compute()
+}
@@ -0,0 +1,38 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestTetraPoWArgon2Hash(t *testing.T) {
+	data := []byte("excalibur block")
+	hash := TetraPoWArgon2Hash(data, 7)
+	if len(hash) != 32 {
+		t.Fatalf("hash length = %d, want 32", len(hash))
+	}
+	if !bytes.Equal(hash, TetraPoWArgon2Hash(data, 7)) {
+		t.Error("memory-hard hash is not deterministic")
+	}
+	if bytes.Equal(hash, TetraPoWArgon2Hash(data, 8)) {
+		t.Error("different nonces give the same memory-hard hash")
+	}
+
+	// Only the seed derivation differs from the HPP-1 pipeline
+	seed := argon2.IDKey(tetraPoWInput(data, 7), []byte(DefaultSalt), Argon2Time, Argon2Memory, Argon2Threads, 32)
+	if want := NewTetraPoWState(seed).Compute(); !bytes.Equal(hash, want) {
+		t.Errorf("hash = %x, want Argon2id seed through Tetra-PoW %x", hash, want)
+	}
+	if bytes.Equal(hash, TetraPoWHash(data, 7)) {
+		t.Error("memory-hard hash equals the HPP-1 hash")
+	}
+}
+
+func BenchmarkTetraPoWArgon2Hash(b *testing.B) {
+	data := []byte("excalibur block")
+	for i := 0; i < b.N; i++ {
+		TetraPoWArgon2Hash(data, uint64(i))
+	}
+}
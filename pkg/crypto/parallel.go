@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrNonceSpaceExhausted indicates no nonce in the searched space met the target
+var ErrNonceSpaceExhausted = errors.New("nonce space exhausted")
+
+// WorkerStats reports the work done by one mining worker
+type WorkerStats struct {
+	Worker   int
+	Start    uint64
+	Hashes   uint64
+	Duration time.Duration
+	HashRate float64
+}
+
+// ParallelResult is the outcome of a parallel Tetra-PoW search. Best is the
+// lowest hash the CPU workers computed, the found one when the search
+// succeeds; device searches only report it when they find a nonce.
+type ParallelResult struct {
+	Nonce     uint64
+	Hash      []byte
+	Worker    int
+	Workers   []WorkerStats
+	BestNonce uint64
+	Best      []byte
+}
+
+// HashRate returns the combined hash rate of all workers in H/s
+func (r *ParallelResult) HashRate() float64 {
+	var total float64
+	for _, w := range r.Workers {
+		total += w.HashRate
+	}
+	return total
+}
+
+// Hashes returns the number of hashes all workers computed
+func (r *ParallelResult) Hashes() uint64 {
+	var total uint64
+	for _, w := range r.Workers {
+		total += w.Hashes
+	}
+	return total
+}
+
+// ParallelTetraPoW searches for a Tetra-PoW nonce with a pool of workers.
+// The nonce space is split into one contiguous range per worker; the first
+// worker to meet difficulty stops the others. Cancelling ctx stops the search
+// and returns ctx.Err().
+func ParallelTetraPoW(ctx context.Context, data []byte, difficulty uint64, workers int) (*ParallelResult, error) {
+	result, err := ParallelTetraPoWRange(ctx, data, difficulty, workers, 0, math.MaxUint64)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ParallelTetraPoWRange is ParallelTetraPoW over the nonces first to last.
+// When no nonce in the range meets difficulty it fails with
+// ErrNonceSpaceExhausted, and when ctx is cancelled with ctx.Err(), but in
+// both cases still returns the work done: the worker stats and the best
+// hash.
+func ParallelTetraPoWRange(ctx context.Context, data []byte, difficulty uint64, workers int, first, last uint64) (*ParallelResult, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if last < first {
+		return nil, fmt.Errorf("nonce range %d-%d is empty", first, last)
+	}
+	if span := last - first; span < uint64(workers)-1 {
+		workers = int(span) + 1
+	}
+
+	searchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// span is the range size divided by workers, without overflowing when
+	// the range is the whole nonce space
+	n, w := last-first, uint64(workers)
+	span := n/w + (n%w+1)/w
+	stats := make([]WorkerStats, workers)
+
+	var (
+		mu     sync.Mutex
+		result = &ParallelResult{}
+		found  bool
+		wg     sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		start := first + uint64(i)*span
+		end := start + span - 1
+		if i == workers-1 {
+			end = last
+		}
+
+		wg.Add(1)
+		go func(worker int, start, end uint64) {
+			defer wg.Done()
+
+			began := time.Now()
+			var (
+				hashes    uint64
+				best      []byte
+				bestNonce uint64
+			)
+			defer func() {
+				elapsed := time.Since(began)
+				stats[worker] = WorkerStats{Worker: worker, Start: start, Hashes: hashes, Duration: elapsed}
+				if elapsed > 0 {
+					stats[worker].HashRate = float64(hashes) / elapsed.Seconds()
+				}
+				mu.Lock()
+				if best != nil && (result.Best == nil || lowerHash(best, result.Best)) {
+					result.Best, result.BestNonce = best, bestNonce
+				}
+				mu.Unlock()
+			}()
+
+			for nonce := start; ; nonce++ {
+				if searchCtx.Err() != nil {
+					return
+				}
+
+				hash := TetraPoWHash(data, nonce)
+				hashes++
+				if best == nil || lowerHash(hash, best) {
+					best, bestNonce = hash, nonce
+				}
+
+				if meetsDifficulty(hash, difficulty) {
+					mu.Lock()
+					if !found {
+						found = true
+						result.Nonce, result.Hash, result.Worker = nonce, hash, worker
+					}
+					mu.Unlock()
+					cancel()
+					return
+				}
+
+				if nonce == end {
+					return
+				}
+			}
+		}(i, start, end)
+	}
+
+	wg.Wait()
+
+	result.Workers = stats
+	if found {
+		result.Best, result.BestNonce = result.Hash, result.Nonce
+		return result, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	return result, ErrNonceSpaceExhausted
+}
+
+// lowerHash reports whether a is closer to meeting a difficulty target
+// than b
+func lowerHash(a, b []byte) bool {
+	return binary.LittleEndian.Uint64(a[0:8]) < binary.LittleEndian.Uint64(b[0:8])
+}
@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParallelTetraPoW(t *testing.T) {
+	data := []byte("test-block-data")
+	difficulty := uint64(0xFFFFFFFFFFFFFF00)
+
+	result, err := ParallelTetraPoW(context.Background(), data, difficulty, 3)
+	if err != nil {
+		t.Fatalf("ParallelTetraPoW() error = %v", err)
+	}
+
+	if !bytes.Equal(result.Hash, TetraPoWHash(data, result.Nonce)) {
+		t.Error("Result hash does not match the nonce")
+	}
+	if !meetsDifficulty(result.Hash, difficulty) {
+		t.Error("Result hash does not meet difficulty")
+	}
+
+	if len(result.Workers) != 3 {
+		t.Fatalf("Expected 3 worker stats, got %d", len(result.Workers))
+	}
+	if result.Workers[1].Start <= result.Workers[0].Start || result.Workers[2].Start <= result.Workers[1].Start {
+		t.Error("Expected disjoint, increasing nonce ranges")
+	}
+	if result.Workers[result.Worker].Hashes == 0 {
+		t.Error("Expected the winning worker to report hashes")
+	}
+}
+
+func TestParallelTetraPoWCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// A zero target can never be met
+	_, err := ParallelTetraPoW(ctx, []byte("test"), 0, 2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestParallelTetraPoWRangeExhausted(t *testing.T) {
+	// More workers than nonces, and a target no hash meets
+	result, err := ParallelTetraPoWRange(context.Background(), []byte("test"), 0, 4, 10, 12)
+	if !errors.Is(err, ErrNonceSpaceExhausted) {
+		t.Fatalf("Expected ErrNonceSpaceExhausted, got %v", err)
+	}
+	if result.Hashes() != 3 || len(result.Workers) != 3 {
+		t.Fatalf("Expected 3 hashes by 3 workers, got %d by %d", result.Hashes(), len(result.Workers))
+	}
+	if result.BestNonce < 10 || result.BestNonce > 12 || !bytes.Equal(result.Best, TetraPoWHash([]byte("test"), result.BestNonce)) {
+		t.Errorf("Best nonce %d does not match its hash", result.BestNonce)
+	}
+}
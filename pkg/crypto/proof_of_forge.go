@@ -8,8 +8,10 @@ import (
 	"math"
 	
 	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/chaincfg"
 	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
 )
 
 // ProofOfForgeResult contains the result of a complete Proof-of-Forge derivation
@@ -67,12 +69,14 @@ func ProofOfForge(prophecyWords []string, salt []byte, network *chaincfg.Params)
 	return result, nil
 }
 
-// ProphecyBinding performs Step 1: SHA-512 of concatenated prophecy words
+// ProphecyBinding performs Step 1: SHA-512 of concatenated prophecy words.
+// Words are NFKD-normalized first, so a prophecy in any language binds to
+// the same hash whichever Unicode form it was entered in.
 func ProphecyBinding(prophecyWords []string) []byte {
 	// Concatenate all words
 	concatenated := ""
 	for _, word := range prophecyWords {
-		concatenated += word
+		concatenated += norm.NFKD.String(word)
 	}
 	
 	// Apply SHA-512
@@ -152,8 +156,13 @@ func DeriveTaprootAddress(finalSeed []byte, network *chaincfg.Params) (*bitcoin.
 		prophecyWords[i] = Canonical13WordProphecy[i]
 	}
 	
+	// The internal key comes from the seed too, so the same proof always
+	// derives the same address and VerifyProofOfForge can check it
+	seedKey := sha256.Sum256(append([]byte("Excalibur-EXS-Forge-Key"), finalSeed...))
+	internalKey, _ := btcec.PrivKeyFromBytes(seedKey[:])
+
 	// Generate Taproot vault using the prophecy
-	vault, err := bitcoin.GenerateTaprootVault(prophecyWords, network)
+	vault, err := bitcoin.NewTaprootVault(internalKey, prophecyWords, network)
 	if err != nil {
 		return nil, err
 	}
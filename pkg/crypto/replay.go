@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrHashMismatch indicates a claimed hash that differs from the
+	// recomputed Tetra-PoW hash
+	ErrHashMismatch = errors.New("hash does not match")
+	// ErrTargetNotMet indicates a hash that is not below its target
+	ErrTargetNotMet = errors.New("hash does not meet target")
+)
+
+// TetraPoWTrace records every stage of one Tetra-PoW hash, so that two
+// parties disagreeing about a result can find the first stage that differs
+type TetraPoWTrace struct {
+	// Input is the work data followed by the little-endian nonce
+	Input []byte
+	// Seed is the HPP-1 key derived from Input, the initial state
+	Seed []byte
+	// Rounds holds the state digest after each of the TetraPoWRounds shifts;
+	// the last is the hash
+	Rounds [][]byte
+}
+
+// Hash returns the traced Tetra-PoW hash
+func (t *TetraPoWTrace) Hash() []byte {
+	return t.Rounds[len(t.Rounds)-1]
+}
+
+// TraceTetraPoW computes the Tetra-PoW hash of data with nonce exactly as
+// TetraPoWHash does, keeping every intermediate digest
+func TraceTetraPoW(data []byte, nonce uint64) *TetraPoWTrace {
+	trace := &TetraPoWTrace{Input: tetraPoWInput(data, nonce)}
+	trace.Seed = HPP1(trace.Input, []byte(DefaultSalt), 32)
+	state := NewTetraPoWState(trace.Seed)
+	trace.Rounds = make([][]byte, TetraPoWRounds)
+	for i := range trace.Rounds {
+		state.Round()
+		trace.Rounds[i] = state.Digest()
+	}
+	return trace
+}
+
+// Replay is the verdict on a submitted mining result, re-executed from its
+// work data and nonce
+type Replay struct {
+	Data   []byte
+	Nonce  uint64
+	Target uint64
+	// Claimed is the submitted hash, nil when only the nonce was submitted
+	Claimed []byte
+	Trace   *TetraPoWTrace
+	// Value is the first eight hash bytes read little-endian, the number
+	// compared against the target
+	Value uint64
+}
+
+// ReplayResult recomputes the hash of data with nonce and checks it
+// against the claimed hash, if any, and target
+func ReplayResult(data []byte, nonce, target uint64, claimed []byte) *Replay {
+	trace := TraceTetraPoW(data, nonce)
+	return &Replay{
+		Data:    data,
+		Nonce:   nonce,
+		Target:  target,
+		Claimed: claimed,
+		Trace:   trace,
+		Value:   binary.LittleEndian.Uint64(trace.Hash()[:8]),
+	}
+}
+
+// HashMatches reports whether the claimed hash equals the recomputed one,
+// true when no hash was claimed
+func (r *Replay) HashMatches() bool {
+	return r.Claimed == nil || bytes.Equal(r.Claimed, r.Trace.Hash())
+}
+
+// MeetsTarget reports whether the recomputed hash is below the target
+func (r *Replay) MeetsTarget() bool {
+	return r.Value < r.Target
+}
+
+// Err returns nil for a valid result, otherwise why it is invalid
+func (r *Replay) Err() error {
+	var errs []error
+	if !r.HashMatches() {
+		errs = append(errs, fmt.Errorf("%w: claimed %x, computed %x", ErrHashMismatch, r.Claimed, r.Trace.Hash()))
+	}
+	if !r.MeetsTarget() {
+		errs = append(errs, fmt.Errorf("%w: %016x is not below %016x", ErrTargetNotMet, r.Value, r.Target))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestTraceTetraPoW(t *testing.T) {
+	data := []byte("Excalibur-EXS")
+	trace := TraceTetraPoW(data, 42)
+
+	if want := TetraPoWHash(data, 42); !bytes.Equal(trace.Hash(), want) {
+		t.Fatalf("Trace hash %x differs from TetraPoWHash %x", trace.Hash(), want)
+	}
+	if len(trace.Rounds) != TetraPoWRounds {
+		t.Fatalf("Expected %d round digests, got %d", TetraPoWRounds, len(trace.Rounds))
+	}
+
+	// Each digest follows from the one before it
+	state := NewTetraPoWState(trace.Seed)
+	for i, digest := range trace.Rounds {
+		state.Round()
+		if !bytes.Equal(state.Digest(), digest) {
+			t.Fatalf("Round %d digest does not follow from the seed", i+1)
+		}
+	}
+	if !bytes.Equal(trace.Input[:len(data)], data) || len(trace.Input) != len(data)+8 {
+		t.Errorf("Unexpected input %x", trace.Input)
+	}
+}
+
+func TestReplayResult(t *testing.T) {
+	data := []byte("Excalibur-EXS")
+	hash := TetraPoWHash(data, 7)
+
+	r := ReplayResult(data, 7, math.MaxUint64, hash)
+	if err := r.Err(); err != nil {
+		t.Fatalf("Expected a valid result, got %v", err)
+	}
+
+	forged := bytes.Clone(hash)
+	forged[0] ^= 1
+	r = ReplayResult(data, 7, r.Value, forged)
+	if r.HashMatches() || r.MeetsTarget() {
+		t.Fatalf("Expected a mismatched hash above its target, got %+v", r)
+	}
+	err := r.Err()
+	if !errors.Is(err, ErrHashMismatch) || !errors.Is(err, ErrTargetNotMet) {
+		t.Errorf("Expected both failures, got %v", err)
+	}
+
+	if r := ReplayResult(data, 7, r.Value+1, nil); r.Err() != nil {
+		t.Errorf("Expected a nonce without a claimed hash to verify, got %v", r.Err())
+	}
+}
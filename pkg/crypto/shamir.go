@@ -0,0 +1,425 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Shamir backups split the entropy of a prophecy or BIP-39 mnemonic into
+// shares the way SLIP-39 splits a master secret: Shamir's scheme over
+// GF(256), with a digest share that detects a recovery from wrong or
+// corrupted shares. Shares are written in the phrase's own wordlist.
+
+// MaxShares is the most shares a backup can be split into
+const MaxShares = 16
+
+const (
+	// shareDigestIndex and shareSecretIndex are the x coordinates of the
+	// digest and secret, as in SLIP-39
+	shareDigestIndex = 254
+	shareSecretIndex = 255
+	// shareDigestSize is the length of the digest checked on recovery
+	shareDigestSize = 4
+	// shareHeaderBits covers a 15-bit backup identifier, the threshold and
+	// member index less one in 4 bits each, and the phrase kind in 3 bits
+	shareHeaderBits = 26
+	// shareMinChecksumBits is the shortest share checksum
+	shareMinChecksumBits = 20
+)
+
+// shareKinds are the phrase lengths a share restores; the 3-bit kind in a
+// share header indexes it
+var shareKinds = []int{ProphecyWordCount, 12, 15, 18, 21, 24}
+
+var (
+	// ErrShareThreshold indicates an unusable threshold or share count
+	ErrShareThreshold = errors.New("invalid share threshold")
+	// ErrShareChecksum indicates a share whose checksum does not match its
+	// words, usually because of a typo
+	ErrShareChecksum = errors.New("share checksum mismatch")
+	// ErrShareMismatch indicates shares from different backups
+	ErrShareMismatch = errors.New("shares belong to different backups")
+	// ErrShareDuplicate indicates the same share given twice
+	ErrShareDuplicate = errors.New("duplicate share")
+	// ErrTooFewShares indicates fewer shares than the backup's threshold
+	ErrTooFewShares = errors.New("not enough shares")
+	// ErrShareDigest indicates shares that do not recover the secret they
+	// were split from
+	ErrShareDigest = errors.New("shares do not recover a valid secret")
+)
+
+// Share is one share of a Shamir backup
+type Share struct {
+	// ID identifies the backup; every share of it has the same ID
+	ID uint16
+	// Threshold is the number of shares that recover the phrase
+	Threshold int
+	// Index numbers the share from 1
+	Index int
+	// Language is the wordlist of the phrase and its shares
+	Language *Language
+	// WordCount is the length of the phrase the backup restores
+	WordCount int
+	// Value is the share of the phrase's entropy
+	Value []byte
+}
+
+// SplitPhrase splits a valid prophecy or BIP-39 mnemonic into n shares, any
+// threshold of which recover it
+func SplitPhrase(words []string, threshold, n int) ([]*Share, error) {
+	if n < 1 || n > MaxShares || threshold < 1 || threshold > n {
+		return nil, fmt.Errorf("%w: %d of %d shares", ErrShareThreshold, threshold, n)
+	}
+	lang, entropy, err := detect(words)
+	if err != nil {
+		return nil, err
+	}
+	values, err := splitSecret(threshold, n, entropy)
+	if err != nil {
+		return nil, err
+	}
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate backup identifier: %w", err)
+	}
+
+	shares := make([]*Share, n)
+	for i, value := range values {
+		shares[i] = &Share{
+			ID:        binary.BigEndian.Uint16(id[:]) & 0x7fff,
+			Threshold: threshold,
+			Index:     i + 1,
+			Language:  lang,
+			WordCount: len(words),
+			Value:     value,
+		}
+	}
+	return shares, nil
+}
+
+// CombineShares recovers a phrase from at least threshold shares of its
+// backup
+func CombineShares(shares []*Share) ([]string, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("%w: none given", ErrTooFewShares)
+	}
+	first := shares[0]
+	seen := make(map[int]bool, len(shares))
+	points := make([]sharePoint, 0, len(shares))
+	for _, share := range shares {
+		if share.ID != first.ID || share.Threshold != first.Threshold || share.Language != first.Language ||
+			share.WordCount != first.WordCount || len(share.Value) != len(first.Value) {
+			return nil, ErrShareMismatch
+		}
+		if seen[share.Index] {
+			return nil, fmt.Errorf("%w: share %d", ErrShareDuplicate, share.Index)
+		}
+		seen[share.Index] = true
+		points = append(points, sharePoint{x: byte(share.Index - 1), y: share.Value})
+	}
+	if len(points) < first.Threshold {
+		return nil, fmt.Errorf("%w: %d of %d", ErrTooFewShares, len(points), first.Threshold)
+	}
+
+	entropy, err := recoverSecret(first.Threshold, points[:first.Threshold])
+	if err != nil {
+		return nil, err
+	}
+	if first.WordCount == ProphecyWordCount {
+		return first.Language.EntropyToProphecy(entropy)
+	}
+	return first.Language.EntropyToMnemonic(entropy)
+}
+
+// Words encodes the share in its language: the header, the value and a
+// checksum of at least 20 bits from SHA-256 of the header and value
+func (s *Share) Words() []string {
+	header := s.header()
+	count := shareWordCount(len(s.Value))
+	checksumBits := count*11 - shareHeaderBits - len(s.Value)*8
+
+	var w bitWriter
+	w.write(uint64(header), shareHeaderBits)
+	for _, b := range s.Value {
+		w.write(uint64(b), 8)
+	}
+	checksum := shareChecksum(header, s.Value)
+	w.write(binary.BigEndian.Uint64(checksum[:8])>>(64-checksumBits), checksumBits)
+
+	words := make([]string, count)
+	for i := range words {
+		words[i] = s.Language.Words[w.index(i)]
+	}
+	return words
+}
+
+// Phrase returns the share's words joined for display
+func (s *Share) Phrase() string {
+	return s.Language.Phrase(s.Words())
+}
+
+// header packs the share's fields into its 26 leading bits
+func (s *Share) header() uint32 {
+	kind := 0
+	for i, count := range shareKinds {
+		if count == s.WordCount {
+			kind = i
+		}
+	}
+	return uint32(s.ID)<<11 | uint32(s.Threshold-1)<<7 | uint32(s.Index-1)<<3 | uint32(kind)
+}
+
+// ParseShare normalizes and decodes a share, verifying its checksum. The
+// wordlist is detected from the words.
+func ParseShare(phrase string) (*Share, error) {
+	words := strings.Fields(strings.ToLower(norm.NFKD.String(phrase)))
+	size := -1
+	for entropySize := 16; entropySize <= 32; entropySize += 4 {
+		if shareWordCount(entropySize) == len(words) {
+			size = entropySize
+		}
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("%w: %d words", ErrPhraseLength, len(words))
+	}
+
+	var share *Share
+	_, err := matchLanguage(words, func(lang *Language) (err error) {
+		share, err = decodeShare(lang, words, size)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// decodeShare decodes share words from one wordlist
+func decodeShare(lang *Language, words []string, size int) (*Share, error) {
+	var r bitReader
+	for i, word := range words {
+		index, ok := lang.index[word]
+		if !ok {
+			return nil, fmt.Errorf("%w: word %d %q", ErrUnknownWord, i+1, word)
+		}
+		r.append(index)
+	}
+
+	header := uint32(r.read(shareHeaderBits))
+	value := make([]byte, size)
+	for i := range value {
+		value[i] = byte(r.read(8))
+	}
+	checksumBits := len(words)*11 - shareHeaderBits - size*8
+	checksum := shareChecksum(header, value)
+	if r.read(checksumBits) != binary.BigEndian.Uint64(checksum[:8])>>(64-checksumBits) {
+		return nil, ErrShareChecksum
+	}
+
+	kind := int(header & 7)
+	if kind >= len(shareKinds) {
+		return nil, ErrShareChecksum
+	}
+	share := &Share{
+		ID:        uint16(header >> 11),
+		Threshold: int(header>>7&15) + 1,
+		Index:     int(header>>3&15) + 1,
+		Language:  lang,
+		WordCount: shareKinds[kind],
+		Value:     value,
+	}
+	entropyBits := share.WordCount * 32 / 3
+	if share.WordCount == ProphecyWordCount {
+		entropyBits = ProphecyEntropySize * 8
+	}
+	if entropyBits != size*8 {
+		return nil, fmt.Errorf("%w: %d-byte share of a %d-word phrase", ErrEntropySize, size, share.WordCount)
+	}
+	return share, nil
+}
+
+// shareWordCount returns the number of words in a share of size bytes
+func shareWordCount(size int) int {
+	return (shareHeaderBits + size*8 + shareMinChecksumBits + 10) / 11
+}
+
+// shareChecksum hashes a share's header and value
+func shareChecksum(header uint32, value []byte) [sha256.Size]byte {
+	var buf bytes.Buffer
+	buf.WriteString("exs-shamir")
+	binary.Write(&buf, binary.BigEndian, header)
+	buf.Write(value)
+	return sha256.Sum256(buf.Bytes())
+}
+
+// sharePoint is a share's value at x, the member index less one
+type sharePoint struct {
+	x byte
+	y []byte
+}
+
+// splitSecret returns n share values of secret as SLIP-39 does: threshold-2
+// random shares, a digest share at 254 and the secret at 255 fix the
+// polynomial, and the remaining shares are interpolated from them
+func splitSecret(threshold, n int, secret []byte) ([][]byte, error) {
+	values := make([][]byte, n)
+	if threshold == 1 {
+		for i := range values {
+			values[i] = append([]byte{}, secret...)
+		}
+		return values, nil
+	}
+
+	random := make([]byte, (threshold-2)*len(secret)+len(secret)-shareDigestSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate shares: %w", err)
+	}
+	points := make([]sharePoint, 0, threshold)
+	for i := 0; i < threshold-2; i++ {
+		points = append(points, sharePoint{x: byte(i), y: random[i*len(secret) : (i+1)*len(secret)]})
+	}
+	randomPart := random[(threshold-2)*len(secret):]
+	digest := shareDigest(randomPart, secret)
+	points = append(points,
+		sharePoint{x: shareDigestIndex, y: append(digest, randomPart...)},
+		sharePoint{x: shareSecretIndex, y: secret},
+	)
+
+	for i := range values {
+		if i < threshold-2 {
+			values[i] = append([]byte{}, points[i].y...)
+			continue
+		}
+		values[i] = interpolate(points, byte(i))
+	}
+	return values, nil
+}
+
+// recoverSecret interpolates the secret from threshold shares and checks it
+// against the digest share
+func recoverSecret(threshold int, points []sharePoint) ([]byte, error) {
+	if threshold == 1 {
+		return append([]byte{}, points[0].y...), nil
+	}
+	secret := interpolate(points, shareSecretIndex)
+	digestShare := interpolate(points, shareDigestIndex)
+	if !hmac.Equal(digestShare[:shareDigestSize], shareDigest(digestShare[shareDigestSize:], secret)) {
+		return nil, ErrShareDigest
+	}
+	return secret, nil
+}
+
+// shareDigest is the first 4 bytes of HMAC-SHA256(randomPart, secret)
+func shareDigest(randomPart, secret []byte) []byte {
+	mac := hmac.New(sha256.New, randomPart)
+	mac.Write(secret)
+	return mac.Sum(nil)[:shareDigestSize]
+}
+
+// gfExp and gfLog are the exponent and logarithm tables of GF(256) with the
+// Rijndael polynomial x^8 + x^4 + x^3 + x + 1 and generator 3
+var gfExp, gfLog = func() (exp [255]byte, log [256]byte) {
+	x := byte(1)
+	for i := range exp {
+		exp[i] = x
+		log[x] = byte(i)
+		// Multiply by 3: x*2 reduced by the polynomial, plus x
+		double := x << 1
+		if x&0x80 != 0 {
+			double ^= 0x1b
+		}
+		x ^= double
+	}
+	return exp, log
+}()
+
+// interpolate evaluates at x the polynomial through points, byte by byte,
+// with Lagrange interpolation over GF(256)
+func interpolate(points []sharePoint, x byte) []byte {
+	for _, p := range points {
+		if p.x == x {
+			return append([]byte{}, p.y...)
+		}
+	}
+
+	// log of the product of (x - xi) over all points
+	logProduct := 0
+	for _, p := range points {
+		logProduct += int(gfLog[p.x^x])
+	}
+	result := make([]byte, len(points[0].y))
+	for i, p := range points {
+		// log of the basis polynomial of point i at x
+		logBasis := logProduct - int(gfLog[p.x^x])
+		for j, q := range points {
+			if j != i {
+				logBasis -= int(gfLog[p.x^q.x])
+			}
+		}
+		logBasis = (logBasis%255 + 255) % 255
+		for k, y := range p.y {
+			if y != 0 {
+				result[k] ^= gfExp[(int(gfLog[y])+logBasis)%255]
+			}
+		}
+	}
+	return result
+}
+
+// bitWriter packs values into a bit string for word encoding
+type bitWriter struct {
+	bits []bool
+}
+
+// write appends the low n bits of v, most significant first
+func (w *bitWriter) write(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bits = append(w.bits, v>>i&1 == 1)
+	}
+}
+
+// index returns the i-th 11-bit word index
+func (w *bitWriter) index(i int) int {
+	index := 0
+	for _, bit := range w.bits[i*11 : i*11+11] {
+		index <<= 1
+		if bit {
+			index |= 1
+		}
+	}
+	return index
+}
+
+// bitReader unpacks a bit string built from word indexes
+type bitReader struct {
+	bits []bool
+	pos  int
+}
+
+// append adds an 11-bit word index
+func (r *bitReader) append(index int) {
+	for b := 10; b >= 0; b-- {
+		r.bits = append(r.bits, index>>b&1 == 1)
+	}
+}
+
+// read returns the next n bits
+func (r *bitReader) read(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.bits[r.pos] {
+			v |= 1
+		}
+		r.pos++
+	}
+	return v
+}
@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSplitAndCombine(t *testing.T) {
+	prophecy, _ := NewProphecy()
+	mnemonic, _ := Japanese.EntropyToMnemonic(make([]byte, 32))
+
+	for _, words := range [][]string{prophecy, mnemonic} {
+		shares, err := SplitPhrase(words, 3, 5)
+		if err != nil {
+			t.Fatalf("SplitPhrase() error = %v", err)
+		}
+		if len(shares) != 5 {
+			t.Fatalf("Expected 5 shares, got %d", len(shares))
+		}
+
+		// Every 3 of the 5 shares recover the phrase, parsed back from words
+		for a := 0; a < 5; a++ {
+			for b := a + 1; b < 5; b++ {
+				for c := b + 1; c < 5; c++ {
+					var subset []*Share
+					for _, i := range []int{c, a, b} {
+						share, err := ParseShare(shares[i].Phrase())
+						if err != nil {
+							t.Fatalf("ParseShare(share %d) error = %v", i+1, err)
+						}
+						subset = append(subset, share)
+					}
+					got, err := CombineShares(subset)
+					if err != nil {
+						t.Fatalf("CombineShares(%d, %d, %d) error = %v", a+1, b+1, c+1, err)
+					}
+					if strings.Join(got, " ") != strings.Join(words, " ") {
+						t.Errorf("CombineShares(%d, %d, %d) = %v, want %v", a+1, b+1, c+1, got, words)
+					}
+				}
+			}
+		}
+
+		if _, err := CombineShares(shares[:2]); !errors.Is(err, ErrTooFewShares) {
+			t.Errorf("Expected ErrTooFewShares, got %v", err)
+		}
+		if _, err := CombineShares([]*Share{shares[0], shares[1], shares[0]}); !errors.Is(err, ErrShareDuplicate) {
+			t.Errorf("Expected ErrShareDuplicate, got %v", err)
+		}
+	}
+
+	// A 1-of-n share is the entropy itself
+	shares, err := SplitPhrase(prophecy, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := CombineShares(shares[1:]); err != nil || strings.Join(got, " ") != strings.Join(prophecy, " ") {
+		t.Errorf("CombineShares(1 of 2) = %v, %v", got, err)
+	}
+}
+
+func TestShareErrors(t *testing.T) {
+	prophecy, _ := NewProphecy()
+	for _, tt := range []struct{ threshold, n int }{{0, 3}, {4, 3}, {2, 17}} {
+		if _, err := SplitPhrase(prophecy, tt.threshold, tt.n); !errors.Is(err, ErrShareThreshold) {
+			t.Errorf("SplitPhrase(%d of %d) error = %v, want ErrShareThreshold", tt.threshold, tt.n, err)
+		}
+	}
+	if _, err := SplitPhrase(append(prophecy[:12:12], "zoo"), 2, 3); err == nil {
+		t.Error("Expected an invalid phrase to be rejected")
+	}
+
+	shares, _ := SplitPhrase(prophecy, 2, 3)
+	other, _ := SplitPhrase(prophecy, 2, 3)
+	other[1].ID = shares[0].ID ^ 1
+	if _, err := CombineShares([]*Share{shares[0], other[1]}); !errors.Is(err, ErrShareMismatch) {
+		t.Errorf("Expected ErrShareMismatch, got %v", err)
+	}
+
+	// A corrupted value with a valid checksum fails the digest check
+	shares[1].Value[0] ^= 1
+	corrupted, err := ParseShare(shares[1].Phrase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CombineShares([]*Share{shares[0], corrupted}); !errors.Is(err, ErrShareDigest) {
+		t.Errorf("Expected ErrShareDigest, got %v", err)
+	}
+
+	words := shares[0].Words()
+	if len(words) != 16 {
+		t.Errorf("Expected a 16-word share, got %d", len(words))
+	}
+	typo := append([]string{}, words...)
+	if typo[5] == "abandon" {
+		typo[5] = "ability"
+	} else {
+		typo[5] = "abandon"
+	}
+	if _, err := ParseShare(strings.Join(typo, " ")); !errors.Is(err, ErrShareChecksum) {
+		t.Errorf("Expected ErrShareChecksum, got %v", err)
+	}
+	typo[5] = "excalibur"
+	if _, err := ParseShare(strings.Join(typo, " ")); !errors.Is(err, ErrUnknownWord) {
+		t.Errorf("Expected ErrUnknownWord, got %v", err)
+	}
+	if _, err := ParseShare(strings.Join(prophecy, " ")); !errors.Is(err, ErrPhraseLength) {
+		t.Errorf("Expected ErrPhraseLength for a prophecy, got %v", err)
+	}
+}
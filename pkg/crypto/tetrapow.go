@@ -12,6 +12,9 @@ const HPP1Rounds = 600000
 // DefaultSalt is the default salt used for HPP-1 key derivation in Tetra-PoW
 const DefaultSalt = "Excalibur-ESX-Ω′Δ18"
 
+// TetraPoWRounds is the number of state shifts in one Tetra-PoW hash
+const TetraPoWRounds = 128
+
 // SafetyCheckInterval defines the interval for safety checks during mining
 const SafetyCheckInterval = 1000000
 
@@ -55,37 +58,27 @@ func (t *TetraPoWState) Round() {
 
 // Compute performs 128 rounds of Tetra-PoW
 func (t *TetraPoWState) Compute() []byte {
-	for i := 0; i < 128; i++ {
-		t.Round()
-	}
-	
+	tetraPoWRounds(&t.state, TetraPoWRounds)
+	return t.Digest()
+}
+
+// Digest returns the current state as 32 little-endian bytes
+func (t *TetraPoWState) Digest() []byte {
 	result := make([]byte, 32)
 	binary.LittleEndian.PutUint64(result[0:8], t.state[0])
 	binary.LittleEndian.PutUint64(result[8:16], t.state[1])
 	binary.LittleEndian.PutUint64(result[16:24], t.state[2])
 	binary.LittleEndian.PutUint64(result[24:32], t.state[3])
-	
 	return result
 }
 
 // TetraPoW performs the Ω′ Δ18 Tetra-PoW algorithm
 func TetraPoW(data []byte, difficulty uint64) (nonce uint64, hash []byte) {
 	for nonce = 0; ; nonce++ {
-		// Combine data with nonce
-		input := make([]byte, len(data)+8)
-		copy(input, data)
-		binary.LittleEndian.PutUint64(input[len(data):], nonce)
-		
-		// Apply HPP-1 for quantum hardening
-		hpp1Result := HPP1(input, []byte(DefaultSalt), 32)
-		
-		// Apply Tetra-PoW state transformation
-		state := NewTetraPoWState(hpp1Result)
-		hash = state.Compute()
+		hash = TetraPoWHash(data, nonce)
 		
 		// Check if hash meets difficulty target
-		hashValue := binary.LittleEndian.Uint64(hash[0:8])
-		if hashValue < difficulty {
+		if meetsDifficulty(hash, difficulty) {
 			return nonce, hash
 		}
 		
@@ -98,3 +91,26 @@ func TetraPoW(data []byte, difficulty uint64) (nonce uint64, hash []byte) {
 		}
 	}
 }
+
+// TetraPoWHash computes the Tetra-PoW hash of data with a single nonce, the
+// unit of work both TetraPoW and ParallelTetraPoW repeat
+func TetraPoWHash(data []byte, nonce uint64) []byte {
+	// Apply HPP-1 for quantum hardening
+	hpp1Result := HPP1(tetraPoWInput(data, nonce), []byte(DefaultSalt), 32)
+
+	// Apply Tetra-PoW state transformation
+	return NewTetraPoWState(hpp1Result).Compute()
+}
+
+// tetraPoWInput combines data with the little-endian nonce
+func tetraPoWInput(data []byte, nonce uint64) []byte {
+	input := make([]byte, len(data)+8)
+	copy(input, data)
+	binary.LittleEndian.PutUint64(input[len(data):], nonce)
+	return input
+}
+
+// meetsDifficulty reports whether hash is below the difficulty target
+func meetsDifficulty(hash []byte, difficulty uint64) bool {
+	return binary.LittleEndian.Uint64(hash[0:8]) < difficulty
+}
@@ -0,0 +1,8 @@
+//go:build amd64 && !purego
+
+package crypto
+
+// tetraPoWRounds applies n rounds to s
+//
+//go:noescape
+func tetraPoWRounds(s *[4]uint64, n int)
@@ -0,0 +1,69 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func tetraPoWRounds(s *[4]uint64, n int)
+//
+// The state lives in R8-R11 and the round constants in R12-R14 and DX for
+// the whole loop; AX and BX hold the shifted words of each mixing step.
+// R15 is left alone, as dynamically linked builds keep the GOT in it.
+TEXT ·tetraPoWRounds(SB), NOSPLIT, $0-16
+	MOVQ s+0(FP), DI
+	MOVQ n+8(FP), CX
+	MOVQ 0(DI), R8
+	MOVQ 8(DI), R9
+	MOVQ 16(DI), R10
+	MOVQ 24(DI), R11
+	MOVQ $0x9E3779B97F4A7C15, R12
+	MOVQ $0x243F6A8885A308D3, R13
+	MOVQ $0x13198A2E03707344, R14
+	MOVQ $0xA4093822299F31D0, DX
+	TESTQ CX, CX
+	JLE done
+
+loop:
+	// s0 ^= s1<<13 ^ s3>>7
+	MOVQ R9, AX
+	SHLQ $13, AX
+	MOVQ R11, BX
+	SHRQ $7, BX
+	XORQ AX, R8
+	XORQ BX, R8
+
+	// s1 ^= s2<<17 ^ s0>>5
+	MOVQ R10, AX
+	SHLQ $17, AX
+	MOVQ R8, BX
+	SHRQ $5, BX
+	XORQ AX, R9
+	XORQ BX, R9
+
+	// s2 ^= s3<<23 ^ s1>>11
+	MOVQ R11, AX
+	SHLQ $23, AX
+	MOVQ R9, BX
+	SHRQ $11, BX
+	XORQ AX, R10
+	XORQ BX, R10
+
+	// s3 ^= s0<<29 ^ s2>>3
+	MOVQ R8, AX
+	SHLQ $29, AX
+	MOVQ R10, BX
+	SHRQ $3, BX
+	XORQ AX, R11
+	XORQ BX, R11
+
+	ADDQ R12, R8
+	ADDQ R13, R9
+	ADDQ R14, R10
+	ADDQ DX, R11
+	DECQ CX
+	JNZ  loop
+
+done:
+	MOVQ R8, 0(DI)
+	MOVQ R9, 8(DI)
+	MOVQ R10, 16(DI)
+	MOVQ R11, 24(DI)
+	RET
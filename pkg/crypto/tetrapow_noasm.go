@@ -0,0 +1,8 @@
+//go:build !amd64 || purego
+
+package crypto
+
+// tetraPoWRounds applies n rounds to s
+func tetraPoWRounds(s *[4]uint64, n int) {
+	tetraPoWRoundsGeneric(s, n)
+}
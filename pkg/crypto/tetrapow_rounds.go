@@ -0,0 +1,33 @@
+package crypto
+
+// Tetra-PoW rounds. Round is the reference state shift; tetraPoWRounds
+// runs many of them on the four state words held in registers rather than
+// loading and storing the array every round. tetraPoWRoundsGeneric is the
+// portable version, which arm64 and every other architecture run, and
+// amd64 has an assembly one. The purego build tag selects the portable
+// version everywhere. Both must stay byte-exact with Round.
+//
+// Each round depends on the last, so there is nothing for AVX2 or NEON
+// lanes to do within one state; the gains come from keeping the state in
+// registers.
+
+// Round constants added to the state words after mixing
+const (
+	tetraPoWK0 = 0x9E3779B97F4A7C15
+	tetraPoWK1 = 0x243F6A8885A308D3
+	tetraPoWK2 = 0x13198A2E03707344
+	tetraPoWK3 = 0xA4093822299F31D0
+)
+
+// tetraPoWRoundsGeneric applies n rounds to s
+func tetraPoWRoundsGeneric(s *[4]uint64, n int) {
+	s0, s1, s2, s3 := s[0], s[1], s[2], s[3]
+	for ; n > 0; n-- {
+		s0 ^= s1<<13 ^ s3>>7
+		s1 ^= s2<<17 ^ s0>>5
+		s2 ^= s3<<23 ^ s1>>11
+		s3 ^= s0<<29 ^ s2>>3
+		s0, s1, s2, s3 = s0+tetraPoWK0, s1+tetraPoWK1, s2+tetraPoWK2, s3+tetraPoWK3
+	}
+	s[0], s[1], s[2], s[3] = s0, s1, s2, s3
+}
@@ -0,0 +1,51 @@
+package crypto
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTetraPoWRoundsParity(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		seed := [4]uint64{rng.Uint64(), rng.Uint64(), rng.Uint64(), rng.Uint64()}
+		if i == 0 {
+			seed = [4]uint64{}
+		}
+		n := i % (TetraPoWRounds + 3)
+
+		reference := &TetraPoWState{state: seed}
+		for j := 0; j < n; j++ {
+			reference.Round()
+		}
+		generic, fast := seed, seed
+		tetraPoWRoundsGeneric(&generic, n)
+		tetraPoWRounds(&fast, n)
+		if generic != reference.state || fast != reference.state {
+			t.Fatalf("%d rounds of %x: generic %x, tetraPoWRounds %x, want %x", n, seed, generic, fast, reference.state)
+		}
+	}
+}
+
+func BenchmarkTetraPoWRounds(b *testing.B) {
+	b.Run("reference", func(b *testing.B) {
+		state := &TetraPoWState{}
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < TetraPoWRounds; j++ {
+				state.Round()
+			}
+		}
+	})
+	b.Run("generic", func(b *testing.B) {
+		var state [4]uint64
+		for i := 0; i < b.N; i++ {
+			tetraPoWRoundsGeneric(&state, TetraPoWRounds)
+		}
+	})
+	b.Run("optimized", func(b *testing.B) {
+		var state [4]uint64
+		for i := 0; i < b.N; i++ {
+			tetraPoWRounds(&state, TetraPoWRounds)
+		}
+	})
+}
@@ -0,0 +1,380 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ProphecyWordCount is the number of words in a prophecy seed phrase
+const ProphecyWordCount = 13
+
+// ProphecyEntropySize is the entropy in bytes encoded by a prophecy
+const ProphecyEntropySize = 16
+
+// prophecyChecksumBits completes 128 bits of entropy to 13 whole words
+const prophecyChecksumBits = ProphecyWordCount*11 - ProphecyEntropySize*8
+
+// seedRounds is the BIP-39 PBKDF2 iteration count for mnemonic-to-seed
+const seedRounds = 2048
+
+//go:embed english.txt
+var english string
+
+//go:embed japanese.txt
+var japanese string
+
+//go:embed korean.txt
+var korean string
+
+//go:embed spanish.txt
+var spanish string
+
+//go:embed chinese_simplified.txt
+var chineseSimplified string
+
+//go:embed chinese_traditional.txt
+var chineseTraditional string
+
+//go:embed french.txt
+var french string
+
+//go:embed italian.txt
+var italian string
+
+//go:embed czech.txt
+var czech string
+
+// Language is a BIP-39 wordlist. Its words are NFKD-normalized, the form
+// BIP-39 hashes, so a phrase decodes and binds the same whichever Unicode
+// composition it was typed or stored in.
+type Language struct {
+	// Name is the wordlist's name in BIP-39, such as "english" or
+	// "chinese_simplified"
+	Name string
+	// Words are the 2048 words in index order
+	Words []string
+	// Separator joins words for display: an ideographic space for
+	// Japanese, a space otherwise
+	Separator string
+
+	index map[string]int
+}
+
+func newLanguage(name, list, separator string) *Language {
+	words := strings.Fields(norm.NFKD.String(list))
+	index := make(map[string]int, len(words))
+	for i, word := range words {
+		index[word] = i
+	}
+	return &Language{Name: name, Words: words, Separator: separator, index: index}
+}
+
+// The BIP-39 wordlists
+var (
+	English            = newLanguage("english", english, " ")
+	Japanese           = newLanguage("japanese", japanese, "\u3000")
+	Korean             = newLanguage("korean", korean, " ")
+	Spanish            = newLanguage("spanish", spanish, " ")
+	ChineseSimplified  = newLanguage("chinese_simplified", chineseSimplified, " ")
+	ChineseTraditional = newLanguage("chinese_traditional", chineseTraditional, " ")
+	French             = newLanguage("french", french, " ")
+	Italian            = newLanguage("italian", italian, " ")
+	Czech              = newLanguage("czech", czech, " ")
+)
+
+// Languages lists the supported wordlists in the order phrases are matched
+// against them
+var Languages = []*Language{
+	English, Japanese, Korean, Spanish, ChineseSimplified, ChineseTraditional, French, Italian, Czech,
+}
+
+// Wordlist is the canonical EXS wordlist: the BIP-39 English list, so every
+// phrase is also readable by standard wallets
+var Wordlist = English.Words
+
+var (
+	// ErrUnknownWord indicates a phrase word is not in Wordlist
+	ErrUnknownWord = errors.New("word not in wordlist")
+	// ErrPhraseLength indicates a phrase has an unsupported number of words
+	ErrPhraseLength = errors.New("invalid phrase length")
+	// ErrChecksum indicates a phrase's checksum does not match its words,
+	// usually because of a typo
+	ErrChecksum = errors.New("phrase checksum mismatch")
+	// ErrEntropySize indicates entropy of an unsupported length
+	ErrEntropySize = errors.New("invalid entropy size")
+	// ErrUnknownLanguage indicates a wordlist name that is not supported
+	ErrUnknownLanguage = errors.New("unknown wordlist language")
+)
+
+// LanguageByName returns the wordlist with a BIP-39 name such as "japanese"
+// or "chinese-simplified"
+func LanguageByName(name string) (*Language, error) {
+	name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+	for _, lang := range Languages {
+		if lang.Name == name {
+			return lang, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownLanguage, name)
+}
+
+// NewProphecy returns an English prophecy for 128 bits of fresh random
+// entropy
+func NewProphecy() ([]string, error) {
+	return English.NewProphecy()
+}
+
+// EntropyToProphecy encodes 16 bytes of entropy as a 13-word English
+// prophecy
+func EntropyToProphecy(entropy []byte) ([]string, error) {
+	return English.EntropyToProphecy(entropy)
+}
+
+// EntropyToMnemonic encodes entropy as a standard English BIP-39 mnemonic
+func EntropyToMnemonic(entropy []byte) ([]string, error) {
+	return English.EntropyToMnemonic(entropy)
+}
+
+// MnemonicToEntropy decodes a prophecy or a standard BIP-39 mnemonic in any
+// supported language and verifies its checksum
+func MnemonicToEntropy(words []string) ([]byte, error) {
+	_, entropy, err := detect(words)
+	return entropy, err
+}
+
+// DetectLanguage returns the wordlist of a valid prophecy or BIP-39
+// mnemonic. A phrase whose words appear in more than one list, as many
+// Chinese characters do, belongs to the first whose checksum it passes.
+func DetectLanguage(words []string) (*Language, error) {
+	lang, _, err := detect(words)
+	return lang, err
+}
+
+// detect decodes words against every wordlist that contains them all
+func detect(words []string) (*Language, []byte, error) {
+	words = normalizeWords(words)
+	var entropy []byte
+	lang, err := matchLanguage(words, func(lang *Language) (err error) {
+		entropy, err = lang.MnemonicToEntropy(words)
+		return err
+	})
+	return lang, entropy, err
+}
+
+// matchLanguage returns the first wordlist containing every word that
+// decode accepts. When no list contains them all, the error names the
+// first unknown word in the closest list.
+func matchLanguage(words []string, decode func(*Language) error) (*Language, error) {
+	var closest *Language
+	var err error
+	best := -1
+	for _, lang := range Languages {
+		n := lang.known(words)
+		if n < len(words) {
+			if n > best {
+				closest, best = lang, n
+			}
+			continue
+		}
+		decodeErr := decode(lang)
+		if decodeErr == nil {
+			return lang, nil
+		}
+		if best < len(words) {
+			best, err = n, decodeErr
+		}
+	}
+	if best < len(words) {
+		for i, word := range words {
+			if _, ok := closest.index[word]; !ok {
+				return nil, fmt.Errorf("%w: word %d %q", ErrUnknownWord, i+1, word)
+			}
+		}
+	}
+	return nil, err
+}
+
+// known counts the words that are in the list
+func (l *Language) known(words []string) int {
+	n := 0
+	for _, word := range words {
+		if _, ok := l.index[word]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// NewProphecy returns a prophecy for 128 bits of fresh random entropy
+func (l *Language) NewProphecy() ([]string, error) {
+	entropy := make([]byte, ProphecyEntropySize)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, fmt.Errorf("failed to generate prophecy: %w", err)
+	}
+	return l.EntropyToProphecy(entropy)
+}
+
+// EntropyToProphecy encodes 16 bytes of entropy as a 13-word prophecy. The
+// prophecy extends the BIP-39 checksum from 4 to 15 bits of SHA-256(entropy),
+// so its first 12 words are the standard BIP-39 mnemonic of the same entropy
+// and the 13th word is a checksum word that catches almost every typo.
+func (l *Language) EntropyToProphecy(entropy []byte) ([]string, error) {
+	if len(entropy) != ProphecyEntropySize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrEntropySize, len(entropy))
+	}
+	return l.encodeWords(entropy, prophecyChecksumBits), nil
+}
+
+// EntropyToMnemonic encodes 16 to 32 bytes of entropy (a multiple of 4) as a
+// standard BIP-39 mnemonic of 12 to 24 words
+func (l *Language) EntropyToMnemonic(entropy []byte) ([]string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrEntropySize, len(entropy))
+	}
+	return l.encodeWords(entropy, len(entropy)*8/32), nil
+}
+
+// MnemonicToEntropy decodes a prophecy or a standard BIP-39 mnemonic from
+// this wordlist and verifies its checksum
+func (l *Language) MnemonicToEntropy(words []string) ([]byte, error) {
+	var checksumBits int
+	switch len(words) {
+	case ProphecyWordCount:
+		checksumBits = prophecyChecksumBits
+	case 12, 15, 18, 21, 24:
+		checksumBits = len(words) * 11 / 33
+	default:
+		return nil, fmt.Errorf("%w: %d words", ErrPhraseLength, len(words))
+	}
+
+	words = normalizeWords(words)
+	bits := make([]byte, (len(words)*11+7)/8)
+	for i, word := range words {
+		index, ok := l.index[word]
+		if !ok {
+			return nil, fmt.Errorf("%w: word %d %q", ErrUnknownWord, i+1, word)
+		}
+		for b := 0; b < 11; b++ {
+			if index&(1<<(10-b)) != 0 {
+				pos := i*11 + b
+				bits[pos/8] |= 0x80 >> (pos % 8)
+			}
+		}
+	}
+
+	entropy := bits[:(len(words)*11-checksumBits)/8]
+	expected := l.encodeWords(entropy, checksumBits)
+	for i := range words {
+		if words[i] != expected[i] {
+			return nil, ErrChecksum
+		}
+	}
+	return entropy, nil
+}
+
+// Phrase joins words with the wordlist's separator for display
+func (l *Language) Phrase(words []string) string {
+	return strings.Join(words, l.Separator)
+}
+
+// ParseMnemonic normalizes a phrase and validates it as a prophecy or a
+// standard BIP-39 mnemonic in any supported language. The words are
+// returned NFKD-normalized and lower case.
+func ParseMnemonic(phrase string) ([]string, error) {
+	words := strings.Fields(strings.ToLower(norm.NFKD.String(phrase)))
+	if _, err := MnemonicToEntropy(words); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// ParseProphecy normalizes a phrase and validates it as a 13-word prophecy
+func ParseProphecy(phrase string) ([]string, error) {
+	words, err := ParseMnemonic(phrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) != ProphecyWordCount {
+		return nil, fmt.Errorf("%w: %d words, a prophecy has %d", ErrPhraseLength, len(words), ProphecyWordCount)
+	}
+	return words, nil
+}
+
+// ParseLegacyProphecy normalizes a phrase from before prophecies carried a
+// checksum, such as the canonical axiom: 12, 13, 15, 18, 21 or 24 English
+// words, none of them checked against the others. New phrases should be
+// parsed with ParseMnemonic or ParseProphecy, which catch typos.
+func ParseLegacyProphecy(phrase string) ([]string, error) {
+	words := strings.Fields(strings.ToLower(norm.NFKD.String(phrase)))
+	switch len(words) {
+	case 12, ProphecyWordCount, 15, 18, 21, 24:
+	default:
+		return nil, fmt.Errorf("%w: %d words", ErrPhraseLength, len(words))
+	}
+	for i, word := range words {
+		if _, ok := English.index[word]; !ok {
+			return nil, fmt.Errorf("%w: word %d %q", ErrUnknownWord, i+1, word)
+		}
+	}
+	return words, nil
+}
+
+// StandardMnemonic returns the BIP-39 mnemonic within a phrase: the first
+// 12 words of a prophecy, or a standard mnemonic unchanged. Wallets seeded
+// from it can be restored by any BIP-39 wallet.
+func StandardMnemonic(words []string) []string {
+	if len(words) == ProphecyWordCount {
+		return words[:ProphecyWordCount-1]
+	}
+	return words
+}
+
+// MnemonicToSeed derives the 64-byte HD wallet seed for a phrase as BIP-39
+// does: PBKDF2-HMAC-SHA512 over the NFKD-normalized words with the salt
+// "mnemonic" followed by passphrase
+func MnemonicToSeed(words []string, passphrase string) []byte {
+	phrase := norm.NFKD.String(strings.Join(words, " "))
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(phrase), []byte(salt), seedRounds, 64, sha512.New)
+}
+
+// normalizeWords returns words in NFKD form, copying only if one changes
+func normalizeWords(words []string) []string {
+	for i, word := range words {
+		if !norm.NFKD.IsNormalString(word) {
+			normalized := make([]string, len(words))
+			copy(normalized, words[:i])
+			for j := i; j < len(words); j++ {
+				normalized[j] = norm.NFKD.String(words[j])
+			}
+			return normalized
+		}
+	}
+	return words
+}
+
+// encodeWords splits entropy followed by the first checksumBits bits of its
+// SHA-256 hash into 11-bit word indexes
+func (l *Language) encodeWords(entropy []byte, checksumBits int) []string {
+	hash := sha256.Sum256(entropy)
+	data := append(append([]byte{}, entropy...), hash[:]...)
+
+	words := make([]string, (len(entropy)*8+checksumBits)/11)
+	for i := range words {
+		index := 0
+		for b := 0; b < 11; b++ {
+			pos := i*11 + b
+			index = index<<1 | int(data[pos/8]>>(7-pos%8)&1)
+		}
+		words[i] = l.Words[index]
+	}
+	return words
+}
@@ -0,0 +1,255 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWordlist(t *testing.T) {
+	if len(Wordlist) != 2048 {
+		t.Fatalf("Expected 2048 words, got %d", len(Wordlist))
+	}
+	// SHA-256 of the BIP-39 english.txt
+	sum := sha256.Sum256([]byte(english))
+	if hex.EncodeToString(sum[:]) != "2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda" {
+		t.Error("Wordlist differs from the canonical BIP-39 English list")
+	}
+}
+
+func TestEntropyToMnemonic(t *testing.T) {
+	// BIP-39 test vectors
+	vectors := []struct {
+		entropy  string
+		mnemonic string
+	}{
+		{"00000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"},
+		{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f", "legal winner thank year wave sausage worth useful legal winner thank yellow"},
+		{"80808080808080808080808080808080", "letter advice cage absurd amount doctor acoustic avoid letter advice cage above"},
+		{"ffffffffffffffffffffffffffffffff", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong"},
+		{"0000000000000000000000000000000000000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art"},
+	}
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		words, err := EntropyToMnemonic(entropy)
+		if err != nil {
+			t.Fatalf("EntropyToMnemonic(%s) error = %v", v.entropy, err)
+		}
+		if got := strings.Join(words, " "); got != v.mnemonic {
+			t.Errorf("EntropyToMnemonic(%s) = %s, want %s", v.entropy, got, v.mnemonic)
+		}
+		decoded, err := MnemonicToEntropy(words)
+		if err != nil || hex.EncodeToString(decoded) != v.entropy {
+			t.Errorf("MnemonicToEntropy(%s) = %x, %v", v.mnemonic, decoded, err)
+		}
+	}
+
+	if _, err := EntropyToMnemonic(make([]byte, 15)); !errors.Is(err, ErrEntropySize) {
+		t.Errorf("Expected ErrEntropySize, got %v", err)
+	}
+}
+
+func TestEntropyToProphecy(t *testing.T) {
+	words, err := EntropyToProphecy(make([]byte, ProphecyEntropySize))
+	if err != nil {
+		t.Fatalf("EntropyToProphecy() error = %v", err)
+	}
+	// The first 12 words are the BIP-39 mnemonic, the 13th carries the
+	// remaining 11 checksum bits
+	expected := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about inner"
+	if got := strings.Join(words, " "); got != expected {
+		t.Errorf("EntropyToProphecy() = %s, want %s", got, expected)
+	}
+	if _, err := MnemonicToEntropy(words[:12]); err != nil {
+		t.Errorf("Expected the first 12 words to be a valid BIP-39 mnemonic, got %v", err)
+	}
+
+	if got := strings.Join(StandardMnemonic(words), " "); got != strings.Join(words[:12], " ") {
+		t.Errorf("StandardMnemonic() = %s", got)
+	}
+
+	if _, err := EntropyToProphecy(make([]byte, 32)); !errors.Is(err, ErrEntropySize) {
+		t.Errorf("Expected ErrEntropySize, got %v", err)
+	}
+}
+
+func TestNewProphecy(t *testing.T) {
+	words, err := NewProphecy()
+	if err != nil {
+		t.Fatalf("NewProphecy() error = %v", err)
+	}
+	if len(words) != ProphecyWordCount {
+		t.Fatalf("Expected %d words, got %d", ProphecyWordCount, len(words))
+	}
+	if _, err := MnemonicToEntropy(words); err != nil {
+		t.Errorf("NewProphecy() produced an invalid phrase: %v", err)
+	}
+
+	other, _ := NewProphecy()
+	if strings.Join(words, " ") == strings.Join(other, " ") {
+		t.Error("Expected two prophecies to differ")
+	}
+}
+
+func TestParseProphecy(t *testing.T) {
+	words, err := ParseProphecy("  Abandon abandon abandon abandon abandon abandon abandon\nabandon abandon abandon abandon about INNER ")
+	if err != nil {
+		t.Fatalf("ParseProphecy() error = %v", err)
+	}
+	if len(words) != ProphecyWordCount || words[0] != "abandon" || words[12] != "inner" {
+		t.Errorf("Unexpected words %v", words)
+	}
+
+	tests := []struct {
+		name   string
+		phrase string
+		err    error
+	}{
+		{"typo in checksum word", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about input", ErrChecksum},
+		{"swapped word", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about abandon inner", ErrChecksum},
+		{"unknown word", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about excalibur", ErrUnknownWord},
+		{"too short", "sword legend pull", ErrPhraseLength},
+		{"bip39 mnemonic", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", ErrPhraseLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseProphecy(tt.phrase); !errors.Is(err, tt.err) {
+				t.Errorf("ParseProphecy() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := ParseMnemonic("legal winner thank year wave sausage worth useful legal winner thank yellow"); err != nil {
+		t.Errorf("ParseMnemonic() rejected a BIP-39 mnemonic: %v", err)
+	}
+}
+
+func TestParseLegacyProphecy(t *testing.T) {
+	const axiom = "sword legend pull magic kingdom artist stone destroy forget fire steel honey question"
+	if _, err := ParseProphecy(axiom); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("ParseProphecy(axiom) error = %v, want ErrChecksum", err)
+	}
+	words, err := ParseLegacyProphecy(" Sword legend pull magic kingdom artist\nstone destroy forget fire steel honey QUESTION ")
+	if err != nil {
+		t.Fatalf("ParseLegacyProphecy(axiom) error = %v", err)
+	}
+	if strings.Join(words, " ") != axiom {
+		t.Errorf("ParseLegacyProphecy(axiom) = %v", words)
+	}
+
+	if _, err := ParseLegacyProphecy("sword legend pull"); !errors.Is(err, ErrPhraseLength) {
+		t.Errorf("Expected a 3-word phrase to fail, got %v", err)
+	}
+	if _, err := ParseLegacyProphecy("sword legend pull magic kingdom artist stone destroy forget fire steel honey excalibur"); !errors.Is(err, ErrUnknownWord) {
+		t.Errorf("Expected an unknown word to fail, got %v", err)
+	}
+}
+
+func TestMnemonicToSeed(t *testing.T) {
+	// BIP-39 test vector
+	words := strings.Fields("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about")
+	expected := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if got := hex.EncodeToString(MnemonicToSeed(words, "TREZOR")); got != expected {
+		t.Errorf("MnemonicToSeed() = %s, want %s", got, expected)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	for _, lang := range Languages {
+		if len(lang.Words) != 2048 || len(lang.index) != 2048 {
+			t.Errorf("%s: %d words, %d distinct", lang.Name, len(lang.Words), len(lang.index))
+		}
+		got, err := LanguageByName(lang.Name)
+		if err != nil || got != lang {
+			t.Errorf("LanguageByName(%q) = %v, %v", lang.Name, got, err)
+		}
+	}
+	if got, _ := LanguageByName("Chinese-Simplified"); got != ChineseSimplified {
+		t.Errorf("LanguageByName(Chinese-Simplified) = %v", got)
+	}
+	if _, err := LanguageByName("klingon"); !errors.Is(err, ErrUnknownLanguage) {
+		t.Errorf("Expected ErrUnknownLanguage, got %v", err)
+	}
+}
+
+func TestMultiLanguageProphecy(t *testing.T) {
+	entropy, _ := hex.DecodeString("7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f")
+	for _, lang := range Languages {
+		t.Run(lang.Name, func(t *testing.T) {
+			words, err := lang.EntropyToProphecy(entropy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := ParseProphecy(lang.Phrase(words))
+			if err != nil {
+				t.Fatalf("ParseProphecy() error = %v", err)
+			}
+			if strings.Join(parsed, " ") != strings.Join(words, " ") {
+				t.Errorf("ParseProphecy() = %v, want %v", parsed, words)
+			}
+			if got, err := DetectLanguage(words); err != nil || got != lang {
+				t.Errorf("DetectLanguage() = %v, %v", got, err)
+			}
+			decoded, err := MnemonicToEntropy(words)
+			if err != nil || hex.EncodeToString(decoded) != hex.EncodeToString(entropy) {
+				t.Errorf("MnemonicToEntropy() = %x, %v", decoded, err)
+			}
+		})
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	words, err := Spanish.EntropyToProphecy(make([]byte, ProphecyEntropySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Generated words are NFKD: ábaco has a combining acute accent
+	phrase := Spanish.Phrase(words)
+	if !strings.Contains(phrase, "a\u0301baco") {
+		t.Fatalf("Expected NFKD words, got %s", phrase)
+	}
+	composed := strings.ReplaceAll(phrase, "a\u0301", "\u00e1")
+
+	fromComposed, err := ParseProphecy(composed)
+	if err != nil {
+		t.Fatalf("ParseProphecy(NFC) error = %v", err)
+	}
+	if strings.Join(fromComposed, " ") != phrase {
+		t.Errorf("ParseProphecy(NFC) = %v, want %v", fromComposed, words)
+	}
+	if _, err := MnemonicToEntropy(strings.Fields(composed)); err != nil {
+		t.Errorf("MnemonicToEntropy(NFC) error = %v", err)
+	}
+	if hex.EncodeToString(ProphecyBinding(strings.Fields(composed))) != hex.EncodeToString(ProphecyBinding(words)) {
+		t.Error("ProphecyBinding() depends on Unicode composition")
+	}
+	// ASCII words are unchanged by normalization
+	sum := sha512.Sum512([]byte(strings.Join(Canonical13WordProphecy, "")))
+	if hex.EncodeToString(ProphecyBinding(Canonical13WordProphecy)) != hex.EncodeToString(sum[:]) {
+		t.Error("ProphecyBinding() changed for an English prophecy")
+	}
+
+	if _, err := ParseProphecy(strings.Replace(composed, "\u00e1baco", "abaco", 1)); !errors.Is(err, ErrUnknownWord) {
+		t.Errorf("Expected ErrUnknownWord for a word missing its accent, got %v", err)
+	}
+}
+
+func TestJapaneseMnemonic(t *testing.T) {
+	// BIP-39 Japanese test vector: ideographic spaces and a passphrase with
+	// compatibility characters that NFKD rewrites
+	phrase := "あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あおぞら"
+	words, err := ParseMnemonic(phrase)
+	if err != nil {
+		t.Fatalf("ParseMnemonic() error = %v", err)
+	}
+	if lang, _ := DetectLanguage(words); lang != Japanese {
+		t.Errorf("DetectLanguage() = %v, want japanese", lang)
+	}
+	expected := "a262d6fb6122ecf45be09c50492b31f92e9beb7d9a845987a02cefda57a15f9c467a17872029a9e92299b5cbdf306e3a0ee620245cbd508959b6cb7ca637bd55"
+	if got := hex.EncodeToString(MnemonicToSeed(words, "㍍ガバヴァぱばぐゞちぢ十人十色")); got != expected {
+		t.Errorf("MnemonicToSeed() = %s, want %s", got, expected)
+	}
+}
@@ -0,0 +1,104 @@
+package economy
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is a quantity of $EXS in exs-satoshis, 1e-8 EXS. Balances, rewards
+// and fees are kept as Amounts so they add up exactly; EXS as a float64 is
+// only for display and for APIs that carry it.
+type Amount int64
+
+// Coin is one $EXS
+const Coin Amount = 100_000_000
+
+// ParseAmount parses a decimal EXS amount (up to 8 decimals) into
+// exs-satoshis
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > 8 {
+		return 0, fmt.Errorf("amount %q has more than 8 decimals", s)
+	}
+	frac += strings.Repeat("0", 8-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	f, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if Amount(w) > TotalSupplyCap/Coin {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+	return Amount(w)*Coin + Amount(f), nil
+}
+
+// isDigits reports whether s holds only ASCII digits
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// EXS returns the amount in EXS, for display and float APIs
+func (a Amount) EXS() float64 {
+	return float64(a) / float64(Coin)
+}
+
+// String formats the amount in EXS without trailing zeros, e.g. 7.5
+func (a Amount) String() string {
+	sign := ""
+	n := uint64(a)
+	if a < 0 {
+		sign, n = "-", uint64(-a)
+	}
+	s := fmt.Sprintf("%s%d.%08d", sign, n/uint64(Coin), n%uint64(Coin))
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// MarshalJSON encodes the amount as a number of EXS, as treasury APIs and
+// ledgers have always carried amounts
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a number of EXS. Ledgers written while amounts were
+// float64 may hold values like 0.30000000000000004, which are rounded to the
+// nearest exs-satoshi.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	exs, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/\"") {
+		return fmt.Errorf("invalid amount %s", s)
+	}
+	sats := exs.Mul(exs, new(big.Rat).SetInt64(int64(Coin)))
+	// Round half away from zero
+	num, den := sats.Num(), sats.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	if !q.IsInt64() {
+		return fmt.Errorf("amount %s out of range", s)
+	}
+	*a = Amount(q.Int64())
+	return nil
+}
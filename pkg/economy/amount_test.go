@@ -0,0 +1,102 @@
+package economy
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Amount
+		wantErr  bool
+	}{
+		{"1", Coin, false},
+		{"42.5", 4250000000, false},
+		{".00000001", 1, false},
+		{"21000000", TotalSupplyCap, false},
+		{"0.123456789", 0, true},
+		{"-1", 0, true},
+		{"1.-5", 0, true},
+		{"1.+5", 0, true},
+		{"+1", 0, true},
+		{"1. 5", 0, true},
+		{"1.", Coin, false},
+		{"abc", 0, true},
+		{"", 0, true},
+		{"21000001", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseAmount(%q): expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("ParseAmount(%q): expected %d, got %d (%v)", tt.input, tt.expected, got, err)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := map[Amount]string{
+		0:                  "0",
+		1:                  "0.00000001",
+		ForgeReward:        "50",
+		TreasuryAllocation: "7.5",
+		MiniOutputAmount:   "2.5",
+		-Coin / 4:          "-0.25",
+	}
+	for amount, expected := range tests {
+		if got := amount.String(); got != expected {
+			t.Errorf("Amount(%d).String() = %q, expected %q", int64(amount), got, expected)
+		}
+		if parsed, err := ParseAmount(expected); amount >= 0 && (err != nil || parsed != amount) {
+			t.Errorf("ParseAmount(%q) = %d, %v, expected %d", expected, parsed, err, int64(amount))
+		}
+	}
+	if got := TreasuryAllocation.EXS(); got != 7.5 {
+		t.Errorf("TreasuryAllocation.EXS() = %v, expected 7.5", got)
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	data, err := json.Marshal(map[string]Amount{"balance": 4250000001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"balance":42.50000001}` {
+		t.Errorf("Marshal() = %s", data)
+	}
+
+	var decoded map[string]Amount
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded["balance"] != 4250000001 {
+		t.Errorf("Unmarshal(%s) = %v, %v", data, decoded, err)
+	}
+
+	// Ledgers written with float64 amounts round to the nearest exs-satoshi
+	legacy := map[string]Amount{
+		"0.30000000000000004": 30000000,
+		"42.074999999999996":  4207500000,
+		"0.425":               42500000,
+		"1e-9":                0,
+		"5e-9":                1,
+		"-0.000000015":        -2,
+	}
+	for input, expected := range legacy {
+		var amount Amount
+		if err := json.Unmarshal([]byte(input), &amount); err != nil || amount != expected {
+			t.Errorf("Unmarshal(%s) = %d, %v, expected %d", input, amount, err, expected)
+		}
+	}
+
+	for _, input := range []string{`"1.5"`, `true`, `1e30`} {
+		var amount Amount
+		if err := json.Unmarshal([]byte(input), &amount); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded with %d", input, amount)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package economy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+var (
+	// ErrInvalidClaim indicates a claim request that is malformed, badly
+	// signed or whose proof does not derive its proof address
+	ErrInvalidClaim = errors.New("invalid claim")
+	// ErrAlreadyClaimed indicates a proof that has already been paid
+	ErrAlreadyClaimed = errors.New("proof already claimed")
+	// ErrClaimCap indicates a claimant that has reached its claim cap
+	ErrClaimCap = errors.New("claim cap reached")
+)
+
+// ClaimPolicy sets what a claim pays and how much each address may claim
+type ClaimPolicy struct {
+	// Network is the network of claimant and proof addresses
+	Network *chaincfg.Params
+	// Reward is the EXS paid from the treasury for each claim
+	Reward Amount
+	// MaxClaims is the number of claims an address may make, 0 for no cap
+	MaxClaims int
+	// MaxAmount is the total EXS an address may claim, 0 for no cap
+	MaxAmount Amount
+}
+
+// DefaultClaimPolicy pays one mini-output per claim on mainnet, once per
+// address
+func DefaultClaimPolicy() ClaimPolicy {
+	return ClaimPolicy{
+		Network:   &chaincfg.MainNetParams,
+		Reward:    MiniOutputAmount,
+		MaxClaims: 1,
+	}
+}
+
+// ClaimRequest asks the treasury to pay Address for a proof of forge: the
+// prophecy words and salt that derive ProofAddress. It is signed by the
+// output key of Address, a Taproot address, so only its owner can claim.
+type ClaimRequest struct {
+	Address       string   `json:"address"`
+	ProphecyWords []string `json:"prophecy_words"`
+	// Salt is hex, empty for the default forge salt
+	Salt         string `json:"salt,omitempty"`
+	ProofAddress string `json:"proof_address"`
+	// Signature is a hex BIP-340 signature of Digest
+	Signature string `json:"signature"`
+}
+
+// Claim is a paid claim
+type Claim struct {
+	ID           int       `json:"id"`
+	Address      string    `json:"address"`
+	ProofAddress string    `json:"proof_address"`
+	Amount       Amount    `json:"amount"`
+	PaymentID    string    `json:"payment_id,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Digest returns the message signed for the claim on net. It commits to
+// everything but the signature, and to the network, so a claim cannot be
+// replayed elsewhere.
+func (r *ClaimRequest) Digest(net *chaincfg.Params) []byte {
+	digest := sha256.Sum256([]byte(strings.Join([]string{
+		"exs-claim",
+		net.Name,
+		r.Address,
+		r.ProofAddress,
+		r.Salt,
+		strings.Join(r.ProphecyWords, " "),
+	}, "\n")))
+	return digest[:]
+}
+
+// Sign signs the claim with key, the private key of Address's output key.
+// For a BIP-86 wallet key that is txscript.TweakTaprootPrivKey(key, nil).
+func (r *ClaimRequest) Sign(key *btcec.PrivateKey, net *chaincfg.Params) error {
+	sig, err := schnorr.Sign(key, r.Digest(net))
+	if err != nil {
+		return err
+	}
+	r.Signature = hex.EncodeToString(sig.Serialize())
+	return nil
+}
+
+// check validates the request and its signature, everything but the proof
+// itself, returning the decoded salt and the address in its canonical
+// encoding. DecodeAddress also accepts the upper-case form of a bech32m
+// address, and caps and balances must not see one owner as two addresses.
+func (r *ClaimRequest) check(net *chaincfg.Params) ([]byte, string, error) {
+	addr, err := btcutil.DecodeAddress(r.Address, net)
+	if err != nil || !addr.IsForNet(net) {
+		return nil, "", fmt.Errorf("%w: address %q is not a %s address", ErrInvalidClaim, r.Address, net.Name)
+	}
+	taproot, ok := addr.(*btcutil.AddressTaproot)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: address %s is not a Taproot address", ErrInvalidClaim, r.Address)
+	}
+	if len(r.ProphecyWords) != 13 {
+		return nil, "", fmt.Errorf("%w: prophecy must contain exactly 13 words", ErrInvalidClaim)
+	}
+	for _, word := range r.ProphecyWords {
+		if word == "" || strings.IndexFunc(word, unicode.IsSpace) >= 0 {
+			return nil, "", fmt.Errorf("%w: prophecy word %q", ErrInvalidClaim, word)
+		}
+	}
+	salt, err := hex.DecodeString(r.Salt)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: salt is not hex", ErrInvalidClaim)
+	}
+	if len(salt) == 0 {
+		salt = nil
+	}
+	if r.ProofAddress == "" {
+		return nil, "", fmt.Errorf("%w: no proof address", ErrInvalidClaim)
+	}
+
+	raw, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: signature is not hex", ErrInvalidClaim)
+	}
+	sig, err := schnorr.ParseSignature(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidClaim, err)
+	}
+	pub, err := schnorr.ParsePubKey(taproot.WitnessProgram())
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidClaim, err)
+	}
+	if !sig.Verify(r.Digest(net), pub) {
+		return nil, "", fmt.Errorf("%w: signature does not match %s", ErrInvalidClaim, r.Address)
+	}
+	return salt, addr.EncodeAddress(), nil
+}
+
+// SetClaimPolicy replaces the claim policy, DefaultClaimPolicy until set.
+// A nil network means mainnet.
+func (t *Treasury) SetClaimPolicy(policy ClaimPolicy) {
+	if policy.Network == nil {
+		policy.Network = &chaincfg.MainNetParams
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.claimPolicy = policy
+}
+
+// ClaimReward pays req.Address the policy's reward for a proof of forge.
+// The request must be signed by the claimant, the proof must derive its
+// proof address through crypto.VerifyProofOfForge, each proof is paid once
+// and each address, in its canonical encoding, only up to the policy's
+// caps. Proof verification takes
+// the full HPP-1 tempering, so it runs without holding the treasury lock.
+func (t *Treasury) ClaimReward(req *ClaimRequest) (*Claim, error) {
+	t.mu.RLock()
+	policy := t.claimPolicy
+	t.mu.RUnlock()
+	salt, address, err := req.check(policy.Network)
+	if err != nil {
+		return nil, err
+	}
+	canonical := *req
+	canonical.Address = address
+	req = &canonical
+	t.mu.RLock()
+	err = t.checkClaim(req, policy)
+	t.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := crypto.VerifyProofOfForge(req.ProphecyWords, salt, req.ProofAddress, policy.Network)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClaim, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: proof does not derive %s", ErrInvalidClaim, req.ProofAddress)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Another claim may have been paid while the proof was verified
+	if err := t.checkClaim(req, t.claimPolicy); err != nil {
+		return nil, err
+	}
+	paymentID, _ := t.requirePayment(req.Address)
+	entry := LedgerEntry{
+		Op:           OpClaim,
+		Amount:       t.claimPolicy.Reward,
+		Address:      req.Address,
+		ProofAddress: req.ProofAddress,
+		PaymentID:    paymentID,
+		Timestamp:    time.Now(),
+	}
+	if err := t.writeAhead(entry); err != nil {
+		return nil, err
+	}
+	claim := t.applyClaim(entry)
+	t.checkpoint()
+	if t.onEvent != nil {
+		t.onEvent(EventClaim, claim)
+	}
+	t.notifyBalance(req.Address)
+	return &claim, nil
+}
+
+// checkClaim refuses claims the treasury cannot pay under policy. The
+// caller must hold t.mu.
+func (t *Treasury) checkClaim(req *ClaimRequest, policy ClaimPolicy) error {
+	if err := t.halted(); err != nil {
+		return err
+	}
+	if _, ok := t.claimedProofs[req.ProofAddress]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyClaimed, req.ProofAddress)
+	}
+	total := t.claimTotals[req.Address]
+	if policy.MaxClaims > 0 && total.count >= policy.MaxClaims {
+		return fmt.Errorf("%w: %s has made %d claims", ErrClaimCap, req.Address, total.count)
+	}
+	if policy.MaxAmount > 0 && total.amount+policy.Reward > policy.MaxAmount {
+		return fmt.Errorf("%w: %s has claimed %s of %s EXS", ErrClaimCap, req.Address, total.amount, policy.MaxAmount)
+	}
+	if policy.Reward > t.balance {
+		return fmt.Errorf("%w: have %s EXS, need %s EXS", ErrInsufficientFunds, t.balance, policy.Reward)
+	}
+	_, err := t.requirePayment(req.Address)
+	return err
+}
+
+// claimTotal is what one address has claimed
+type claimTotal struct {
+	count  int
+	amount Amount
+}
+
+func (t *Treasury) applyClaim(entry LedgerEntry) Claim {
+	claim := Claim{
+		ID:           len(t.claims) + 1,
+		Address:      entry.Address,
+		ProofAddress: entry.ProofAddress,
+		Amount:       entry.Amount,
+		PaymentID:    entry.PaymentID,
+		Timestamp:    entry.Timestamp,
+	}
+	t.usePayment(entry.PaymentID, 0, claim.ID)
+	t.balance -= claim.Amount
+	t.addressBalances[claim.Address] += claim.Amount
+	t.claims = append(t.claims, claim)
+	t.indexClaim(claim)
+	return claim
+}
+
+// indexClaim records claim for double-claim and cap checks
+func (t *Treasury) indexClaim(claim Claim) {
+	t.claimedProofs[claim.ProofAddress] = claim.ID
+	total := t.claimTotals[claim.Address]
+	total.count++
+	total.amount += claim.Amount
+	t.claimTotals[claim.Address] = total
+}
+
+// GetClaims returns every paid claim, oldest first
+func (t *Treasury) GetClaims() []Claim {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	claims := make([]Claim, len(t.claims))
+	copy(claims, t.claims)
+	return claims
+}
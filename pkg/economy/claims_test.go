@@ -0,0 +1,171 @@
+package economy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// claimant returns a key and the Taproot address whose output key it is
+func claimant(t *testing.T) (*btcec.PrivateKey, string) {
+	t.Helper()
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(key.PubKey()), &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, addr.EncodeAddress()
+}
+
+func TestClaimReward(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	proof, err := crypto.ProofOfForge(crypto.Canonical13WordProphecy, nil, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	treasury.SetClaimPolicy(ClaimPolicy{Network: net, Reward: 2 * Coin, MaxClaims: 1})
+	treasury.ProcessForge("bcrt1pminer")
+
+	key, address := claimant(t)
+	req := &ClaimRequest{
+		Address:       address,
+		ProphecyWords: crypto.Canonical13WordProphecy,
+		ProofAddress:  proof.TaprootAddress,
+	}
+	if err := req.Sign(key, net); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tampering with any signed field breaks the signature
+	forged := *req
+	forged.ProofAddress = address
+	if _, err := treasury.ClaimReward(&forged); !errors.Is(err, ErrInvalidClaim) {
+		t.Errorf("Expected ErrInvalidClaim for a tampered claim, got %v", err)
+	}
+	// A signed claim whose proof derives another address is refused
+	wrong := *req
+	wrong.Salt = "00"
+	if err := wrong.Sign(key, net); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := treasury.ClaimReward(&wrong); !errors.Is(err, ErrInvalidClaim) {
+		t.Errorf("Expected ErrInvalidClaim for a wrong proof, got %v", err)
+	}
+
+	var types []string
+	treasury.OnEvent(func(typ string, data any) { types = append(types, typ) })
+	claim, err := treasury.ClaimReward(req)
+	if err != nil {
+		t.Fatalf("ClaimReward() error = %v", err)
+	}
+	if len(types) != 2 || types[0] != EventClaim || types[1] != EventBalance {
+		t.Errorf("Expected claim and balance events, got %v", types)
+	}
+	if claim.ID != 1 || claim.Amount != 2*Coin || claim.ProofAddress != proof.TaprootAddress {
+		t.Errorf("ClaimReward() = %+v", claim)
+	}
+	if balance := treasury.GetBalance(); balance != TreasuryAllocation-2*Coin {
+		t.Errorf("Treasury balance %s after the claim, expected %s", balance, TreasuryAllocation-2*Coin)
+	}
+
+	// The same proof cannot be paid twice, to anyone
+	otherKey, otherAddress := claimant(t)
+	again := *req
+	again.Address = otherAddress
+	if err := again.Sign(otherKey, net); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := treasury.ClaimReward(&again); !errors.Is(err, ErrAlreadyClaimed) {
+		t.Errorf("Expected ErrAlreadyClaimed, got %v", err)
+	}
+	// The claimant has used its one claim
+	second := *req
+	second.ProofAddress = "bcrt1pother"
+	if err := second.Sign(key, net); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := treasury.ClaimReward(&second); !errors.Is(err, ErrClaimCap) {
+		t.Errorf("Expected ErrClaimCap, got %v", err)
+	}
+
+	// Claims are replayed from the ledger, and still prevent double claims
+	treasury.Close()
+	reopened, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	reopened.SetClaimPolicy(ClaimPolicy{Network: net, Reward: 2 * Coin})
+	claims := reopened.GetClaims()
+	if len(claims) != 1 || claims[0].ProofAddress != claim.ProofAddress || claims[0].Address != claim.Address ||
+		claims[0].Amount != claim.Amount || !claims[0].Timestamp.Equal(claim.Timestamp) {
+		t.Errorf("Claims after reopening = %+v, expected %+v", claims, *claim)
+	}
+	if reopened.GetAddressBalance(address) != 2*Coin {
+		t.Errorf("Claimant balance %s after reopening, expected 2", reopened.GetAddressBalance(address))
+	}
+	if _, err := reopened.ClaimReward(&again); !errors.Is(err, ErrAlreadyClaimed) {
+		t.Errorf("Expected ErrAlreadyClaimed after reopening, got %v", err)
+	}
+}
+
+func TestClaimRewardAddressCase(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	proof, err := crypto.ProofOfForge(crypto.Canonical13WordProphecy, nil, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	treasury := NewTreasury()
+	treasury.SetClaimPolicy(ClaimPolicy{Network: net, Reward: 2 * Coin, MaxClaims: 1})
+	treasury.ProcessForge("bcrt1pminer")
+
+	// The upper-case form of the address is paid as the address itself
+	key, address := claimant(t)
+	upper := &ClaimRequest{
+		Address:       strings.ToUpper(address),
+		ProphecyWords: crypto.Canonical13WordProphecy,
+		ProofAddress:  proof.TaprootAddress,
+	}
+	if err := upper.Sign(key, net); err != nil {
+		t.Fatal(err)
+	}
+	claim, err := treasury.ClaimReward(upper)
+	if err != nil {
+		t.Fatalf("ClaimReward() error = %v", err)
+	}
+	if claim.Address != address {
+		t.Errorf("Claim address = %s, expected %s", claim.Address, address)
+	}
+	if balance := treasury.GetAddressBalance(address); balance != 2*Coin {
+		t.Errorf("Claimant balance %s, expected 2", balance)
+	}
+
+	// and counts against the same cap as the lower-case form
+	lower := &ClaimRequest{
+		Address:       address,
+		ProphecyWords: crypto.Canonical13WordProphecy,
+		ProofAddress:  "bcrt1pother",
+	}
+	if err := lower.Sign(key, net); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := treasury.ClaimReward(lower); !errors.Is(err, ErrClaimCap) {
+		t.Errorf("Expected ErrClaimCap for the lower-case form, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package economy
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// Emission schedule. The n-th forge, counting from 0, mints RewardAtForge(n):
+// ForgeReward halved every HalvingInterval forges, never less than
+// TailEmission. Rather than dropping at once, each halving phases in over
+// HalvingTransition forges, the reward decaying exponentially from the old
+// rate to the new one. Forges mint until the supply reaches TotalSupplyCap,
+// the last one minting what is left.
+const (
+	HalvingInterval          = 210_000   // Forges between halvings
+	HalvingTransition        = 1_000     // Forges over which a halving phases in
+	TailEmission      Amount = Coin / 10 // 0.1 $EXS minimum forge reward
+)
+
+// transitionDecay is 2^(-1/HalvingTransition) in Q63 fixed point, the
+// reward's decay per forge during a transition. Integer math keeps the
+// schedule identical on every platform.
+const transitionDecay = 0x7fe94b7987047c95
+
+// ErrSupplyCap indicates a forge after TotalSupplyCap has been minted
+var ErrSupplyCap = errors.New("supply cap reached")
+
+// RewardAtForge returns the reward of the n-th forge, counting from 0,
+// before the supply cap
+func RewardAtForge(n uint64) Amount {
+	halvings, into := n/HalvingInterval, n%HalvingInterval
+	reward := halvedReward(halvings)
+	if halvings > 0 && into < HalvingTransition {
+		prev := uint64(halvedReward(halvings - 1))
+		reward = max(reward, Amount(mulQ63(prev, powQ63(transitionDecay, into))))
+	}
+	return reward
+}
+
+// ForgeEmission returns what the n-th forge mints after supply has been
+// minted: RewardAtForge(n), or what is left below TotalSupplyCap
+func ForgeEmission(n uint64, supply Amount) Amount {
+	return max(min(RewardAtForge(n), TotalSupplyCap-supply), 0)
+}
+
+// TreasuryShare returns the treasury's TreasuryPercent of a forge reward
+func TreasuryShare(reward Amount) Amount {
+	return reward * TreasuryPercent / 100
+}
+
+// SupplyAtForge returns the supply minted by the first n forges
+func SupplyAtForge(n uint64) Amount {
+	var supply Amount
+	for i := uint64(0); i < n && supply < TotalSupplyCap; {
+		reward, run := rewardRun(i)
+		run = min(run, n-i)
+		supply = min(supply+reward*Amount(run), TotalSupplyCap)
+		i += run
+	}
+	return supply
+}
+
+// RemainingForges returns how many forges from the n-th on mint before
+// the supply, supply after the first n forges, reaches TotalSupplyCap
+func RemainingForges(n uint64, supply Amount) uint64 {
+	var count uint64
+	for supply < TotalSupplyCap {
+		reward, run := rewardRun(n)
+		left := TotalSupplyCap - supply
+		if full := uint64(left / reward); full < run {
+			if left%reward != 0 {
+				full++
+			}
+			return count + full
+		}
+		supply += reward * Amount(run)
+		count += run
+		n += run
+	}
+	return count
+}
+
+// rewardRun returns the reward of the n-th forge and the number of forges
+// from n on that mint the same reward
+func rewardRun(n uint64) (Amount, uint64) {
+	into := n % HalvingInterval
+	if n >= HalvingInterval && into < HalvingTransition {
+		return RewardAtForge(n), 1
+	}
+	return RewardAtForge(n), HalvingInterval - into
+}
+
+// halvedReward returns ForgeReward after halvings halvings, at least
+// TailEmission
+func halvedReward(halvings uint64) Amount {
+	if halvings >= 63 {
+		return TailEmission
+	}
+	return max(ForgeReward>>halvings, TailEmission)
+}
+
+// powQ63 raises the Q63 fraction x to the power n
+func powQ63(x, n uint64) uint64 {
+	result := uint64(1) << 63
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result = mulQ63(result, x)
+		}
+		x = mulQ63(x, x)
+	}
+	return result
+}
+
+// mulQ63 multiplies a by the Q63 fraction b, rounding down
+func mulQ63(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi<<1 | lo>>63
+}
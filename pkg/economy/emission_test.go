@@ -0,0 +1,90 @@
+package economy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRewardAtForge(t *testing.T) {
+	tests := []struct {
+		forge uint64
+		want  string
+	}{
+		{0, "50"},
+		{HalvingInterval - 1, "50"},
+		{HalvingInterval, "50"},
+		{HalvingInterval + HalvingTransition/2, "35.35533905"},
+		{HalvingInterval + HalvingTransition, "25"},
+		{2*HalvingInterval + HalvingTransition, "12.5"},
+		{9*HalvingInterval + HalvingTransition, "0.1"},
+		{1 << 40, "0.1"},
+	}
+	for _, tt := range tests {
+		if got := RewardAtForge(tt.forge).String(); got != tt.want {
+			t.Errorf("RewardAtForge(%d) = %s, want %s", tt.forge, got, tt.want)
+		}
+	}
+
+	// The reward never rises and never jumps by more than a forge's decay
+	prev := RewardAtForge(HalvingInterval - 1)
+	for n := uint64(HalvingInterval); n < HalvingInterval+HalvingTransition+1; n++ {
+		reward := RewardAtForge(n)
+		if reward > prev || prev-reward > prev/1000 {
+			t.Fatalf("RewardAtForge(%d) = %s after %s", n, reward, prev)
+		}
+		prev = reward
+	}
+}
+
+func TestSupplyCap(t *testing.T) {
+	if got := SupplyAtForge(HalvingInterval); got != HalvingInterval*ForgeReward {
+		t.Errorf("SupplyAtForge(%d) = %s", HalvingInterval, got)
+	}
+
+	remaining := RemainingForges(0, 0)
+	if SupplyAtForge(remaining) != TotalSupplyCap || SupplyAtForge(remaining-1) >= TotalSupplyCap {
+		t.Errorf("Expected the cap after %d forges, supply %s", remaining, SupplyAtForge(remaining))
+	}
+	if got := RemainingForges(HalvingInterval, SupplyAtForge(HalvingInterval)); got != remaining-HalvingInterval {
+		t.Errorf("RemainingForges() after the first era = %d, want %d", got, remaining-HalvingInterval)
+	}
+	if got := ForgeEmission(remaining, TotalSupplyCap); got != 0 {
+		t.Errorf("ForgeEmission() past the cap = %s", got)
+	}
+}
+
+func TestProcessForgeEmission(t *testing.T) {
+	treasury := NewTreasury()
+	treasury.totalForges = HalvingInterval + HalvingTransition
+	treasury.totalMinted = SupplyAtForge(HalvingInterval + HalvingTransition)
+
+	result := treasury.ProcessForge("bc1pminer")
+	if result.TotalReward != ForgeReward/2 || result.TreasuryAllocation != TreasuryAllocation/2 || result.MinerReward != MinerReward/2 {
+		t.Errorf("Forge after the first halving = %+v", result)
+	}
+	for _, output := range result.TreasuryMiniOutputs {
+		if output.Amount != MiniOutputAmount/2 {
+			t.Errorf("Mini-output amount = %s, want %s", output.Amount, MiniOutputAmount/2)
+		}
+	}
+
+	// The last forge mints what is left, then forges are refused
+	treasury.totalMinted = TotalSupplyCap - Coin
+	result = treasury.ProcessForge("bc1pminer")
+	if result.TotalReward != Coin || treasury.GetTotalMinted() != TotalSupplyCap {
+		t.Errorf("Last forge minted %s, supply %s", result.TotalReward, treasury.GetTotalMinted())
+	}
+	var sum Amount
+	for _, output := range result.TreasuryMiniOutputs {
+		sum += output.Amount
+	}
+	if sum != result.TreasuryAllocation {
+		t.Errorf("Mini-outputs add up to %s, want %s", sum, result.TreasuryAllocation)
+	}
+	if treasury.ProcessForge("bc1pminer") != nil {
+		t.Error("Expected no forge past the supply cap")
+	}
+	if _, err := treasury.ProcessForgeSplit(RewardSplit{{Address: "bc1pminer", Percent: 100}}); !errors.Is(err, ErrSupplyCap) {
+		t.Errorf("ProcessForgeSplit() past the cap error = %v, want ErrSupplyCap", err)
+	}
+}
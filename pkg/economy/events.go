@@ -0,0 +1,69 @@
+package economy
+
+// Treasury events passed to the OnEvent callback
+const (
+	// EventForge carries the ForgeResult of a forge
+	EventForge = "forge"
+	// EventDistribution carries a Distribution
+	EventDistribution = "distribution"
+	// EventBalance carries a BalanceChange
+	EventBalance = "balance"
+	// EventClaim carries a paid Claim
+	EventClaim = "claim"
+)
+
+// BalanceChange reports the treasury totals after an operation and the
+// address balances it changed
+type BalanceChange struct {
+	Balance            Amount `json:"balance"`
+	TotalFeesCollected Amount `json:"total_fees_collected"`
+	// ForgeFeePool is in BTC
+	ForgeFeePool float64           `json:"forge_fee_pool"`
+	BlockHeight  uint32            `json:"block_height"`
+	Addresses    map[string]Amount `json:"addresses,omitempty"`
+}
+
+// OnEvent registers fn to be called after every forge, distribution, claim
+// and balance change, typically to publish it on the event bus. fn runs with
+// the treasury locked, so events arrive in ledger order, and it must not call
+// back into the treasury. Replaying the ledger reports nothing.
+func (t *Treasury) OnEvent(fn func(typ string, data any)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onEvent = fn
+}
+
+// notifyForge reports a forge and the balances it credited. The caller must
+// hold t.mu.
+func (t *Treasury) notifyForge(result *ForgeResult) {
+	if t.onEvent == nil {
+		return
+	}
+	t.onEvent(EventForge, *result)
+	addresses := []string{result.MinerAddress}
+	for _, p := range result.Payouts {
+		addresses = append(addresses, p.Address)
+	}
+	t.notifyBalance(addresses...)
+}
+
+// notifyBalance reports the totals and the balances of addresses. The
+// caller must hold t.mu.
+func (t *Treasury) notifyBalance(addresses ...string) {
+	if t.onEvent == nil {
+		return
+	}
+	change := BalanceChange{
+		Balance:            t.balance,
+		TotalFeesCollected: t.totalFeesCollected,
+		ForgeFeePool:       t.forgeFeePool.ToBTC(),
+		BlockHeight:        t.currentBlockHeight,
+	}
+	if len(addresses) > 0 {
+		change.Addresses = make(map[string]Amount, len(addresses))
+		for _, address := range addresses {
+			change.Addresses[address] = t.addressBalances[address]
+		}
+	}
+	t.onEvent(EventBalance, change)
+}
@@ -0,0 +1,47 @@
+package economy
+
+import "testing"
+
+func TestOnEvent(t *testing.T) {
+	treasury := NewTreasury()
+	var types []string
+	var last BalanceChange
+	treasury.OnEvent(func(typ string, data any) {
+		types = append(types, typ)
+		if change, ok := data.(BalanceChange); ok {
+			last = change
+		}
+	})
+
+	result := treasury.ProcessForge("bc1pminer")
+	if len(types) != 2 || types[0] != EventForge || types[1] != EventBalance {
+		t.Fatalf("Expected forge and balance events, got %v", types)
+	}
+	if last.Balance != result.TreasuryAllocation || last.Addresses["bc1pminer"] != result.MinerReward {
+		t.Errorf("Unexpected balance change %+v", last)
+	}
+
+	types = nil
+	if _, err := treasury.Distribute(Coin, "bc1qgrant", "grant"); err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0] != EventDistribution || last.Addresses["bc1qgrant"] != Coin {
+		t.Errorf("Expected distribution and balance events, got %v %+v", types, last)
+	}
+
+	// Payouts of a split forge each report their balance
+	split := RewardSplit{{Address: "bc1pa", Percent: 50}, {Address: "bc1pb", Percent: 50}}
+	if _, err := treasury.ProcessForgeSplit(split); err != nil {
+		t.Fatal(err)
+	}
+	if last.Addresses["bc1pb"] == 0 || len(last.Addresses) != 2 {
+		t.Errorf("Expected both beneficiaries reported, got %+v", last.Addresses)
+	}
+
+	// Height changes are not events
+	types = nil
+	treasury.SetBlockHeight(5)
+	if len(types) != 0 {
+		t.Errorf("Expected no event for a height change, got %v", types)
+	}
+}
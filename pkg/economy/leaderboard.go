@@ -0,0 +1,118 @@
+package economy
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultLeaderboardSize is the number of miners ranked when no limit is given
+	DefaultLeaderboardSize = 10
+	// MaxLeaderboardSize bounds the number of miners in one leaderboard
+	MaxLeaderboardSize = 100
+)
+
+// Characters kept at each end of a truncated address
+const (
+	truncatePrefix = 8
+	truncateSuffix = 4
+)
+
+// LeaderboardEntry is one miner's rank on the forge leaderboard. Address is
+// truncated so the public leaderboard does not publish full payout addresses.
+type LeaderboardEntry struct {
+	Rank       int       `json:"rank"`
+	Address    string    `json:"address"`
+	Forges     int       `json:"forges"`
+	Rewards    Amount    `json:"rewards"`
+	Share      float64   `json:"share"`
+	FirstForge time.Time `json:"first_forge"`
+	LastForge  time.Time `json:"last_forge"`
+}
+
+// Leaderboard ranks miners by forges and reward, alongside the totals the
+// Round Table shows next to it
+type Leaderboard struct {
+	BlockHeight  uint32             `json:"block_height"`
+	TotalForges  int                `json:"total_forges"`
+	TotalRewards Amount             `json:"total_rewards"`
+	Miners       int                `json:"miners"`
+	Entries      []LeaderboardEntry `json:"entries"`
+}
+
+// TruncateAddress shortens an address to its first and last characters,
+// e.g. bc1p5d7r…x9k2, leaving enough for a miner to recognise their own
+func TruncateAddress(address string) string {
+	if len(address) <= truncatePrefix+truncateSuffix {
+		return address
+	}
+	return address[:truncatePrefix] + "…" + address[len(address)-truncateSuffix:]
+}
+
+// Leaderboard ranks the miners of every forge processed so far. A miner's
+// rewards are the miner rewards of the forges they found, including shares
+// split to other beneficiaries. Miners are ordered by forges, then rewards,
+// then who reached their count first; miners tied on forges and rewards share
+// a rank. limit defaults to DefaultLeaderboardSize and is capped at
+// MaxLeaderboardSize.
+func (t *Treasury) Leaderboard(limit int) Leaderboard {
+	if limit <= 0 {
+		limit = DefaultLeaderboardSize
+	}
+	if limit > MaxLeaderboardSize {
+		limit = MaxLeaderboardSize
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	board := Leaderboard{BlockHeight: t.currentBlockHeight, TotalForges: len(t.forges)}
+	miners := make(map[string]*LeaderboardEntry)
+	for _, forge := range t.forges {
+		entry, ok := miners[forge.MinerAddress]
+		if !ok {
+			entry = &LeaderboardEntry{Address: forge.MinerAddress, FirstForge: forge.Timestamp}
+			miners[forge.MinerAddress] = entry
+		}
+		entry.Forges++
+		entry.Rewards += forge.MinerReward
+		entry.LastForge = forge.Timestamp
+		board.TotalRewards += forge.MinerReward
+	}
+	board.Miners = len(miners)
+
+	ranked := make([]*LeaderboardEntry, 0, len(miners))
+	for _, entry := range miners {
+		ranked = append(ranked, entry)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Forges != b.Forges {
+			return a.Forges > b.Forges
+		}
+		if a.Rewards != b.Rewards {
+			return a.Rewards > b.Rewards
+		}
+		if !a.LastForge.Equal(b.LastForge) {
+			return a.LastForge.Before(b.LastForge)
+		}
+		return a.Address < b.Address
+	})
+
+	if len(ranked) < limit {
+		limit = len(ranked)
+	}
+	board.Entries = make([]LeaderboardEntry, limit)
+	for i, entry := range ranked[:limit] {
+		entry.Rank = i + 1
+		if i > 0 && entry.Forges == ranked[i-1].Forges && entry.Rewards == ranked[i-1].Rewards {
+			entry.Rank = ranked[i-1].Rank
+		}
+		if board.TotalForges > 0 {
+			entry.Share = float64(entry.Forges) / float64(board.TotalForges) * 100
+		}
+		board.Entries[i] = *entry
+		board.Entries[i].Address = TruncateAddress(entry.Address)
+	}
+	return board
+}
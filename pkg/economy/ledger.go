@@ -0,0 +1,589 @@
+package economy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// Ledger operations recorded in the write-ahead log
+const (
+	OpSetHeight      = "set_height"
+	OpForge          = "forge"
+	OpForgeFee       = "forge_fee"
+	OpTithe          = "tithe"
+	OpDistribute     = "distribute"
+	OpClaim          = "claim"
+	OpPropose        = "propose"
+	OpApprove        = "approve"
+	OpPaymentRequest = "payment_request"
+	OpPaymentVerify  = "payment_verify"
+	OpSettleTx       = "settle_tx"
+	OpSettleError    = "settle_error"
+	OpSettleConfirm  = "settle_confirm"
+	OpSettleRetry    = "settle_retry"
+	OpRevenue        = "revenue"
+)
+
+const (
+	// DefaultSnapshotInterval is the number of ledger entries between snapshots
+	DefaultSnapshotInterval = 1000
+
+	walFileName      = "treasury.wal"
+	snapshotFileName = "treasury.snapshot.json"
+
+	// Buckets of a treasury kept in a kv.Store
+	ledgerBucket   = "treasury_ledger"
+	snapshotBucket = "treasury"
+)
+
+var snapshotKey = []byte("snapshot")
+
+// ErrLedgerCorrupt indicates a ledger that cannot be recovered automatically
+var ErrLedgerCorrupt = errors.New("treasury ledger corrupt")
+
+// LedgerEntry is one treasury state change in the write-ahead log. Entries
+// carry everything needed to replay them deterministically, including
+// timestamps and transaction hashes.
+type LedgerEntry struct {
+	Seq            uint64      `json:"seq"`
+	Op             string      `json:"op"`
+	Height         uint32      `json:"height,omitempty"`
+	Address        string      `json:"address,omitempty"`
+	Amount         Amount      `json:"amount,omitempty"`
+	ForgeID        int         `json:"forge_id,omitempty"`
+	Purpose        string      `json:"purpose,omitempty"`
+	TxHash         string      `json:"tx_hash,omitempty"`
+	RequireDeposit bool        `json:"require_deposit,omitempty"`
+	Split          RewardSplit `json:"split,omitempty"`
+	ProofAddress   string      `json:"proof_address,omitempty"`
+	ProposalID     string      `json:"proposal_id,omitempty"`
+	By             string      `json:"by,omitempty"`
+	Signer         string      `json:"signer,omitempty"`
+	Signature      string      `json:"signature,omitempty"`
+	Threshold      int         `json:"threshold,omitempty"`
+	Approvals      int         `json:"approvals,omitempty"`
+	Timestamp      time.Time   `json:"timestamp,omitempty"`
+	ExpiresAt      time.Time   `json:"expires_at,omitempty"`
+	KeyHash        string      `json:"key_hash,omitempty"`
+	Network        string      `json:"network,omitempty"`
+	PaymentID      string      `json:"payment_id,omitempty"`
+	PayTo          string      `json:"pay_to,omitempty"`
+	Sats           int64       `json:"sats,omitempty"`
+	BlockHash      string      `json:"block_hash,omitempty"`
+	DistributionID int         `json:"distribution_id,omitempty"`
+	Settle         bool        `json:"settle,omitempty"`
+	RawTx          string      `json:"raw_tx,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	Final          bool        `json:"final,omitempty"`
+	Stream         string      `json:"stream,omitempty"`
+	Index          uint64      `json:"index,omitempty"`
+}
+
+// LedgerInfo describes the persistent ledger and the last recovery
+type LedgerInfo struct {
+	// Dir is the ledger directory, empty for a ledger in a kv.Store
+	Dir string
+	// Seq is the sequence number of the last ledger entry
+	Seq uint64
+	// SnapshotSeq is the sequence number covered by the last snapshot
+	SnapshotSeq uint64
+	// Replayed is the number of log entries replayed on startup
+	Replayed int
+	// TruncatedBytes is the size of a torn log tail discarded on startup
+	TruncatedBytes int64
+}
+
+// Ledger persists treasury state as periodic snapshots plus an append-only
+// write-ahead log of the entries since the last snapshot, either as files in
+// a directory or as records in a kv.Store
+type Ledger struct {
+	dir              string
+	wal              *os.File
+	store            kv.Store // replaces dir and wal when set
+	snapshotInterval int
+	sinceSnapshot    int
+	info             LedgerInfo
+}
+
+// treasuryState is the snapshot encoding of a Treasury
+type treasuryState struct {
+	Seq                uint64               `json:"seq"`
+	Balance            Amount               `json:"balance"`
+	TotalFeesCollected Amount               `json:"total_fees_collected"`
+	TotalForges        int                  `json:"total_forges"`
+	ForgeFeePoolBTC    float64              `json:"forge_fee_pool_btc"`
+	Distributions      []Distribution       `json:"distributions"`
+	MiniOutputs        []TreasuryMiniOutput `json:"mini_outputs"`
+	CurrentBlockHeight uint32               `json:"current_block_height"`
+	Forges             []*ForgeResult       `json:"forges"`
+	AddressBalances    map[string]Amount    `json:"address_balances"`
+	Claims             []Claim              `json:"claims,omitempty"`
+	Proposals          []*Proposal          `json:"proposals,omitempty"`
+	Payments           []*ForgePayment      `json:"payments,omitempty"`
+	Revenue            []*RevenueStream     `json:"revenue,omitempty"`
+	SavedAt            time.Time            `json:"saved_at"`
+}
+
+// OpenTreasury opens the persistent treasury in dir, recovering its state from
+// the latest snapshot and replaying the write-ahead log. A torn entry at the
+// end of the log, left by a crash mid-write, is discarded. snapshotInterval
+// defaults to DefaultSnapshotInterval when zero.
+func OpenTreasury(dir string, snapshotInterval int) (*Treasury, error) {
+	if snapshotInterval <= 0 {
+		snapshotInterval = DefaultSnapshotInterval
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+
+	t := NewTreasury()
+	l := &Ledger{dir: dir, snapshotInterval: snapshotInterval, info: LedgerInfo{Dir: dir}}
+
+	if err := t.loadSnapshot(filepath.Join(dir, snapshotFileName), &l.info); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger log: %w", err)
+	}
+	l.wal = wal
+	if err := t.replay(l); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	t.ledger = l
+	return t, nil
+}
+
+// OpenTreasuryStore opens the persistent treasury kept in store, which it
+// closes when closed. Each ledger entry is a record keyed by its sequence
+// number; a snapshot replaces the entries it covers in one transaction.
+// snapshotInterval defaults to DefaultSnapshotInterval when zero.
+func OpenTreasuryStore(store kv.Store, snapshotInterval int) (*Treasury, error) {
+	if snapshotInterval <= 0 {
+		snapshotInterval = DefaultSnapshotInterval
+	}
+	t := NewTreasury()
+	l := &Ledger{store: store, snapshotInterval: snapshotInterval}
+
+	err := store.View(func(tx kv.Tx) error {
+		data, err := tx.Get(snapshotBucket, snapshotKey)
+		if err == nil {
+			err = t.restore(data, &l.info)
+		} else if errors.Is(err, kv.ErrNotFound) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Iterate(ledgerBucket, nil, func(key, value []byte) error {
+			var entry LedgerEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return fmt.Errorf("%w: invalid entry %x: %v", ErrLedgerCorrupt, key, err)
+			}
+			return t.replayEntry(l, entry)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.ledger = l
+	return t, nil
+}
+
+// ledgerKey orders ledger records by sequence number
+func ledgerKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, seq)
+}
+
+// loadSnapshot restores the state saved at path, if any
+func (t *Treasury) loadSnapshot(path string, info *LedgerInfo) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ledger snapshot: %w", err)
+	}
+	return t.restore(data, info)
+}
+
+// restore loads an encoded snapshot
+func (t *Treasury) restore(data []byte, info *LedgerInfo) error {
+	var state treasuryState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%w: invalid snapshot: %v", ErrLedgerCorrupt, err)
+	}
+
+	t.balance = state.Balance
+	t.totalFeesCollected = state.TotalFeesCollected
+	t.totalForges = state.TotalForges
+	// The pool is whole satoshis, so its BTC value converts back exactly
+	pool, err := btcutil.NewAmount(state.ForgeFeePoolBTC)
+	if err != nil {
+		return fmt.Errorf("%w: invalid forge fee pool: %v", ErrLedgerCorrupt, err)
+	}
+	t.forgeFeePool = pool
+	t.currentBlockHeight = state.CurrentBlockHeight
+	if state.Distributions != nil {
+		t.distributions = state.Distributions
+	}
+	if state.MiniOutputs != nil {
+		t.miniOutputs = state.MiniOutputs
+	}
+	if state.Forges != nil {
+		t.forges = state.Forges
+	}
+	t.totalMinted = 0
+	for _, forge := range t.forges {
+		t.totalMinted += forge.TotalReward
+	}
+	if state.AddressBalances != nil {
+		t.addressBalances = state.AddressBalances
+	}
+	t.claims = state.Claims
+	for _, claim := range t.claims {
+		t.indexClaim(claim)
+	}
+	t.proposals = state.Proposals
+	for _, p := range t.proposals {
+		t.proposalIndex[p.ID] = p
+	}
+	t.payments = state.Payments
+	for _, p := range t.payments {
+		t.paymentIndex[p.ID] = p
+	}
+	t.revenue = state.Revenue
+	info.Seq = state.Seq
+	info.SnapshotSeq = state.Seq
+	return nil
+}
+
+// replay applies the log entries newer than the snapshot and positions the
+// log for appending
+func (t *Treasury) replay(l *Ledger) error {
+	reader := bufio.NewReader(l.wal)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// Partial final line from an interrupted write
+				return l.truncate(offset, int64(len(line)))
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read ledger log: %w", err)
+		}
+
+		entry, ok := decodeEntry(line)
+		if !ok {
+			rest, _ := io.Copy(io.Discard, reader)
+			if rest > 0 {
+				return fmt.Errorf("%w: invalid entry at offset %d", ErrLedgerCorrupt, offset)
+			}
+			return l.truncate(offset, int64(len(line)))
+		}
+		offset += int64(len(line))
+		if err := t.replayEntry(l, entry); err != nil {
+			return err
+		}
+	}
+	_, err := l.wal.Seek(0, io.SeekEnd)
+	return err
+}
+
+// replayEntry applies a logged entry newer than the snapshot
+func (t *Treasury) replayEntry(l *Ledger, entry LedgerEntry) error {
+	// Entries already covered by the snapshot remain if a crash happened
+	// between writing the snapshot and truncating the log
+	if entry.Seq <= l.info.Seq {
+		return nil
+	}
+	if entry.Seq != l.info.Seq+1 {
+		return fmt.Errorf("%w: expected entry %d, found %d", ErrLedgerCorrupt, l.info.Seq+1, entry.Seq)
+	}
+	if err := t.apply(entry); err != nil {
+		return err
+	}
+	l.info.Seq = entry.Seq
+	l.info.Replayed++
+	l.sinceSnapshot++
+	return nil
+}
+
+// apply replays one ledger entry
+func (t *Treasury) apply(entry LedgerEntry) error {
+	switch entry.Op {
+	case OpSetHeight:
+		t.applySetHeight(entry.Height)
+	case OpForge:
+		t.applyForge(entry)
+	case OpForgeFee:
+		t.applyForgeFee(entry.Amount, entry.RequireDeposit)
+	case OpTithe:
+		t.applyTithe(entry.ForgeID, entry.Address, entry.Amount)
+	case OpDistribute:
+		t.applyDistribution(entry)
+	case OpClaim:
+		t.applyClaim(entry)
+	case OpPropose:
+		t.applyPropose(entry)
+	case OpApprove:
+		t.applyApprove(entry)
+	case OpPaymentRequest:
+		t.applyPaymentRequest(entry)
+	case OpPaymentVerify:
+		t.applyPaymentVerify(entry)
+	case OpSettleTx, OpSettleError, OpSettleConfirm, OpSettleRetry:
+		t.applySettlement(entry)
+	case OpRevenue:
+		t.applyRevenue(entry)
+	default:
+		return fmt.Errorf("%w: unknown operation %q in entry %d", ErrLedgerCorrupt, entry.Op, entry.Seq)
+	}
+	return nil
+}
+
+// truncate discards a torn log tail
+func (l *Ledger) truncate(offset, size int64) error {
+	if err := l.wal.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate ledger log: %w", err)
+	}
+	l.info.TruncatedBytes = size
+	_, err := l.wal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// encodeEntry formats an entry as a checksummed log line
+func encodeEntry(entry LedgerEntry) ([]byte, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)), nil
+}
+
+// decodeEntry parses a log line, reporting false if it is damaged
+func decodeEntry(line []byte) (LedgerEntry, bool) {
+	var entry LedgerEntry
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	checksum, data, found := bytes.Cut(line, []byte(" "))
+	if !found || fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)) != string(checksum) {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// writeAhead durably appends entry to the ledger before it is applied. The
+// caller must hold t.mu. Without a ledger it does nothing.
+func (t *Treasury) writeAhead(entry LedgerEntry) error {
+	if t.ledger == nil {
+		return nil
+	}
+	l := t.ledger
+	entry.Seq = l.info.Seq + 1
+	var err error
+	if l.store != nil {
+		var data []byte
+		if data, err = json.Marshal(entry); err == nil {
+			err = kv.Put(l.store, ledgerBucket, ledgerKey(entry.Seq), data)
+		}
+	} else {
+		var line []byte
+		if line, err = encodeEntry(entry); err == nil {
+			if _, err = l.wal.Write(line); err == nil {
+				err = l.wal.Sync()
+			}
+		}
+	}
+	if err != nil {
+		t.ledgerErr = fmt.Errorf("failed to write treasury ledger: %w", err)
+		return t.ledgerErr
+	}
+	l.info.Seq = entry.Seq
+	l.sinceSnapshot++
+	return nil
+}
+
+// checkpoint writes a snapshot once enough entries have accumulated. The
+// caller must hold t.mu.
+func (t *Treasury) checkpoint() {
+	if t.ledger == nil || t.ledger.sinceSnapshot < t.ledger.snapshotInterval {
+		return
+	}
+	if err := t.snapshot(); err != nil {
+		t.ledgerErr = err
+	}
+}
+
+// snapshot saves the full state and truncates the log. The caller must hold
+// t.mu.
+func (t *Treasury) snapshot() error {
+	l := t.ledger
+	state := treasuryState{
+		Seq:                l.info.Seq,
+		Balance:            t.balance,
+		TotalFeesCollected: t.totalFeesCollected,
+		TotalForges:        t.totalForges,
+		ForgeFeePoolBTC:    t.forgeFeePool.ToBTC(),
+		Distributions:      t.distributions,
+		MiniOutputs:        t.miniOutputs,
+		CurrentBlockHeight: t.currentBlockHeight,
+		Forges:             t.forges,
+		AddressBalances:    t.addressBalances,
+		Claims:             t.claims,
+		Proposals:          t.proposals,
+		Payments:           t.payments,
+		Revenue:            t.revenue,
+		SavedAt:            time.Now(),
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger snapshot: %w", err)
+	}
+	if l.store != nil {
+		err = l.store.Update(func(tx kv.Tx) error {
+			if err := tx.Put(snapshotBucket, snapshotKey, data); err != nil {
+				return err
+			}
+			var covered [][]byte
+			err := tx.Iterate(ledgerBucket, nil, func(key, value []byte) error {
+				covered = append(covered, bytes.Clone(key))
+				return nil
+			})
+			for _, key := range covered {
+				if err == nil {
+					err = tx.Delete(ledgerBucket, key)
+				}
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write ledger snapshot: %w", err)
+		}
+		l.info.SnapshotSeq = l.info.Seq
+		l.sinceSnapshot = 0
+		return nil
+	}
+
+	path := filepath.Join(l.dir, snapshotFileName)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write ledger snapshot: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write ledger snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync ledger snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write ledger snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace ledger snapshot: %w", err)
+	}
+	// The rename must be durable before the log it replaces is emptied
+	if err := syncDir(l.dir); err != nil {
+		return fmt.Errorf("failed to sync ledger directory: %w", err)
+	}
+
+	// The snapshot now covers every logged entry
+	if err := l.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate ledger log: %w", err)
+	}
+	if _, err := l.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to truncate ledger log: %w", err)
+	}
+	l.info.SnapshotSeq = l.info.Seq
+	l.sinceSnapshot = 0
+	return nil
+}
+
+// syncDir flushes the entries of directory dir, such as a rename, to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// Snapshot saves the treasury state and compacts the write-ahead log
+func (t *Treasury) Snapshot() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ledger == nil {
+		return fmt.Errorf("treasury has no ledger")
+	}
+	return t.snapshot()
+}
+
+// LedgerInfo returns the state of the persistent ledger, or nil for an
+// in-memory treasury
+func (t *Treasury) LedgerInfo() *LedgerInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.ledger == nil {
+		return nil
+	}
+	info := t.ledger.info
+	return &info
+}
+
+// LedgerErr returns the last ledger write failure, if any. Operations that
+// could not be logged are not applied.
+func (t *Treasury) LedgerErr() error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.ledgerErr
+}
+
+// Close snapshots the treasury and closes its ledger
+func (t *Treasury) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ledger == nil {
+		return nil
+	}
+	err := t.snapshot()
+	var cerr error
+	if t.ledger.store != nil {
+		cerr = t.ledger.store.Close()
+	} else {
+		cerr = t.ledger.wal.Close()
+	}
+	if err == nil {
+		err = cerr
+	}
+	t.ledger = nil
+	return err
+}
@@ -0,0 +1,252 @@
+package economy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// populate runs a representative sequence of treasury operations
+func populate(t *testing.T, treasury *Treasury) {
+	t.Helper()
+	treasury.SetBlockHeight(100)
+	if treasury.ProcessForge("bc1pminer") == nil {
+		t.Fatalf("ProcessForge() failed: %v", treasury.LedgerErr())
+	}
+	treasury.SetBlockHeight(101)
+	if _, _, err := treasury.ProcessForgeWithFee("bc1pminer", true); err != nil {
+		t.Fatalf("ProcessForgeWithFee() error = %v", err)
+	}
+	if _, _, err := treasury.ProcessForgeFee(100*Coin, true); err != nil {
+		t.Fatalf("ProcessForgeFee() error = %v", err)
+	}
+	if _, err := treasury.Distribute(7*Coin/2, "bc1qgrant", "grant"); err != nil {
+		t.Fatalf("Distribute() error = %v", err)
+	}
+	treasury.SetBlockHeight(100 + MiniOutput2Delay)
+}
+
+func assertSameTreasury(t *testing.T, got, want *Treasury) {
+	t.Helper()
+	if got.GetBalance() != want.GetBalance() || got.GetTotalFeesCollected() != want.GetTotalFeesCollected() {
+		t.Errorf("Balance %s/%s, want %s/%s",
+			got.GetBalance(), got.GetTotalFeesCollected(), want.GetBalance(), want.GetTotalFeesCollected())
+	}
+	if got.GetTotalForges() != want.GetTotalForges() || got.GetForgeFeePool() != want.GetForgeFeePool() {
+		t.Errorf("Forges %d pool %d, want %d pool %d",
+			got.GetTotalForges(), got.GetForgeFeePool(), want.GetTotalForges(), want.GetForgeFeePool())
+	}
+	if got.GetSpendableBalance() != want.GetSpendableBalance() || got.GetLockedBalance() != want.GetLockedBalance() {
+		t.Errorf("Spendable/locked %s/%s, want %s/%s",
+			got.GetSpendableBalance(), got.GetLockedBalance(), want.GetSpendableBalance(), want.GetLockedBalance())
+	}
+	for _, addr := range []string{"bc1pminer", "bc1qgrant"} {
+		if got.GetAddressBalance(addr) != want.GetAddressBalance(addr) {
+			t.Errorf("%s balance %s, want %s", addr, got.GetAddressBalance(addr), want.GetAddressBalance(addr))
+		}
+	}
+	gotForges, wantForges := got.GetForgesAtHeight(101), want.GetForgesAtHeight(101)
+	if len(gotForges) != 1 || len(wantForges) != 1 || gotForges[0].MinerReward != wantForges[0].MinerReward ||
+		!gotForges[0].Timestamp.Equal(wantForges[0].Timestamp) {
+		t.Errorf("Forges at 101 = %+v, want %+v", gotForges, wantForges)
+	}
+	gotDists, wantDists := got.GetDistributions(), want.GetDistributions()
+	if len(gotDists) != len(wantDists) || gotDists[0].TxHash != wantDists[0].TxHash {
+		t.Errorf("Distributions = %+v, want %+v", gotDists, wantDists)
+	}
+}
+
+func TestLedgerReplay(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	populate(t, treasury)
+
+	// Simulate a crash: reopen without closing, so only the log is on disk
+	recovered, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	defer recovered.Close()
+
+	info := recovered.LedgerInfo()
+	if info.Replayed != 9 || info.Seq != 9 || info.SnapshotSeq != 0 {
+		t.Errorf("Unexpected ledger info %+v", info)
+	}
+	assertSameTreasury(t, recovered, treasury)
+}
+
+func TestLedgerSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 4)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	populate(t, treasury)
+
+	info := treasury.LedgerInfo()
+	if info.SnapshotSeq != 8 || info.Seq != 9 {
+		t.Errorf("Expected snapshot at 8 of 9 entries, got %+v", info)
+	}
+
+	recovered, err := OpenTreasury(dir, 4)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	if got := recovered.LedgerInfo(); got.Replayed != 1 || got.Seq != 9 {
+		t.Errorf("Expected 1 replayed entry, got %+v", got)
+	}
+	assertSameTreasury(t, recovered, treasury)
+
+	if err := recovered.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, walFileName)); len(data) != 0 {
+		t.Errorf("Expected empty log after Close, got %d bytes", len(data))
+	}
+
+	reopened, err := OpenTreasury(dir, 4)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	defer reopened.Close()
+	if got := reopened.LedgerInfo(); got.Replayed != 0 || got.SnapshotSeq != 9 {
+		t.Errorf("Expected state from snapshot only, got %+v", got)
+	}
+	assertSameTreasury(t, reopened, treasury)
+}
+
+func TestLedgerTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	populate(t, treasury)
+
+	// A crash mid-append leaves a partial final line
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.WriteString(`1234abcd {"seq":10,"op":"forge","addr`)
+	wal.Close()
+
+	recovered, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	info := recovered.LedgerInfo()
+	if info.Seq != 9 || info.TruncatedBytes == 0 {
+		t.Errorf("Expected torn tail discarded, got %+v", info)
+	}
+	assertSameTreasury(t, recovered, treasury)
+
+	// New entries append cleanly after the truncation
+	recovered.SetBlockHeight(5000)
+	recovered.Close()
+	reopened, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	defer reopened.Close()
+	if reopened.GetStats()["current_block_height"] != uint32(5000) {
+		t.Errorf("Expected height 5000, got %v", reopened.GetStats()["current_block_height"])
+	}
+}
+
+func TestLedgerCorruptEntry(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	populate(t, treasury)
+
+	path := filepath.Join(dir, walFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Damage the first entry; later entries cannot be trusted to replay
+	data[0] ^= 0xff
+	os.WriteFile(path, data, 0600)
+
+	if _, err := OpenTreasury(dir, 0); !errors.Is(err, ErrLedgerCorrupt) {
+		t.Errorf("Expected ErrLedgerCorrupt, got %v", err)
+	}
+}
+
+func TestLedgerReplaySplit(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	split := RewardSplit{{"bc1poperator", 90}, {"bc1phost", 10}}
+	if _, err := treasury.ProcessForgeSplit(split); err != nil {
+		t.Fatalf("ProcessForgeSplit() error = %v", err)
+	}
+	split[1].Address = "bc1pchanged"
+
+	recovered, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenTreasury() error = %v", err)
+	}
+	defer recovered.Close()
+	for _, addr := range []string{"bc1poperator", "bc1phost"} {
+		if got, want := recovered.GetAddressBalance(addr), treasury.GetAddressBalance(addr); got != want || got == 0 {
+			t.Errorf("%s balance %s, want %s", addr, got, want)
+		}
+	}
+	forges := recovered.GetForgesAtHeight(0)
+	if len(forges) != 1 || len(forges[0].Payouts) != 2 || forges[0].Payouts[1].Address != "bc1phost" {
+		t.Errorf("Recovered forges = %+v", forges)
+	}
+}
+
+func TestLedgerStore(t *testing.T) {
+	store := kv.NewMemory()
+	treasury, err := OpenTreasuryStore(store, 4)
+	if err != nil {
+		t.Fatalf("OpenTreasuryStore() error = %v", err)
+	}
+	populate(t, treasury)
+
+	// Reopen without closing: the snapshot after entry 8 plus entry 9
+	recovered, err := OpenTreasuryStore(store, 4)
+	if err != nil {
+		t.Fatalf("OpenTreasuryStore() error = %v", err)
+	}
+	info := recovered.LedgerInfo()
+	if info.Replayed != 1 || info.Seq != 9 || info.SnapshotSeq != 8 || info.Dir != "" {
+		t.Errorf("Unexpected ledger info %+v", info)
+	}
+	assertSameTreasury(t, recovered, treasury)
+
+	// Closing snapshots and removes the covered entries
+	if err := recovered.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	entries := 0
+	kv.Iterate(store, ledgerBucket, nil, func(key, value []byte) error {
+		entries++
+		return nil
+	})
+	if entries != 0 {
+		t.Errorf("Expected the snapshot to replace the entries, %d remain", entries)
+	}
+	reopened, err := OpenTreasuryStore(store, 4)
+	if err != nil {
+		t.Fatalf("OpenTreasuryStore() error = %v", err)
+	}
+	if info := reopened.LedgerInfo(); info.Replayed != 0 || info.Seq != 9 {
+		t.Errorf("Unexpected ledger info %+v", info)
+	}
+	assertSameTreasury(t, reopened, treasury)
+}
@@ -0,0 +1,328 @@
+package economy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// Forge fee payments. A miner or claimant requests a payment, which names a
+// fresh Taproot address of the treasury key, and pays the forge fee to it
+// in BTC. The payment is verified against an SPV header chain: the paying
+// transaction must be in a best-chain block with enough confirmations.
+// While payments are required, each forge and each claim uses up one
+// verified payment of its miner or claimant.
+
+// DefaultPaymentConfirmations is the confirmation depth a payment needs by
+// default
+const DefaultPaymentConfirmations = 6
+
+// MaxPendingPayments bounds the unverified payments one address may have
+const MaxPendingPayments = 4
+
+// Payment states
+const (
+	PaymentPending  = "pending"
+	PaymentVerified = "verified"
+	PaymentUsed     = "used"
+)
+
+var (
+	// ErrPaymentRequired indicates a forge or claim without a verified,
+	// unused forge fee payment
+	ErrPaymentRequired = errors.New("forge fee payment required")
+	// ErrUnknownPayment indicates a payment ID that does not exist
+	ErrUnknownPayment = errors.New("unknown forge payment")
+	// ErrInvalidPayment indicates a transaction that does not pay the fee
+	// to the payment address, or whose proof does not check out
+	ErrInvalidPayment = errors.New("invalid forge payment")
+	// ErrPaymentUnconfirmed indicates a payment with too few confirmations
+	ErrPaymentUnconfirmed = errors.New("forge payment not confirmed")
+	// ErrTooManyPayments indicates an address with MaxPendingPayments
+	// unverified payments
+	ErrTooManyPayments = errors.New("too many pending forge payments")
+	// ErrNoTreasuryKey indicates a payment requested before SetTreasuryKey
+	ErrNoTreasuryKey = errors.New("no treasury key configured")
+	// ErrNoPaymentChain indicates a payment verified without a header chain
+	ErrNoPaymentChain = errors.New("no chain to verify payments against")
+)
+
+// PaymentChain proves that transactions are in the best chain
+type PaymentChain interface {
+	// Confirmations checks proof against its block header and returns the
+	// block's confirmations
+	Confirmations(proof *bitcoin.TransactionProof) (int32, error)
+}
+
+// PaymentPolicy sets whether forges and claims need a forge fee payment
+// and how payments are verified
+type PaymentPolicy struct {
+	// Required makes every forge and claim use up a verified payment
+	Required bool
+	// Chain verifies payments, typically a *bitcoin.SPVClient
+	Chain PaymentChain
+	// MinConfirmations is the depth a payment needs,
+	// DefaultPaymentConfirmations when zero
+	MinConfirmations int32
+	// FeeSats overrides the fee in satoshis; when zero it is
+	// crypto.CalculateForgeFee of the forges so far
+	FeeSats int64
+}
+
+// ForgePayment is a forge fee payment and its verification
+type ForgePayment struct {
+	ID string `json:"id"`
+	// Address is the miner or claimant address the payment is for
+	Address string `json:"address"`
+	// PayTo is the Taproot address to pay, the treasury key tweaked by
+	// Tweak; the treasury spends it with its key tweaked the same way
+	PayTo     string    `json:"pay_to"`
+	PkScript  string    `json:"pk_script"`
+	Tweak     string    `json:"tweak"`
+	FeeSats   int64     `json:"fee_sats"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	// The paying transaction, once verified
+	TxHash      string    `json:"tx_hash,omitempty"`
+	PaidSats    int64     `json:"paid_sats,omitempty"`
+	BlockHash   string    `json:"block_hash,omitempty"`
+	BlockHeight uint32    `json:"block_height,omitempty"`
+	VerifiedAt  time.Time `json:"verified_at,omitempty"`
+	// The forge or claim that used the payment
+	ForgeID int `json:"forge_id,omitempty"`
+	ClaimID int `json:"claim_id,omitempty"`
+}
+
+// paymentTweak returns the Taproot tweak of payment id
+func paymentTweak(id string) []byte {
+	tweak := sha256.Sum256([]byte("exs-forge-payment:" + id))
+	return tweak[:]
+}
+
+// SetPaymentPolicy replaces the payment policy
+func (t *Treasury) SetPaymentPolicy(policy PaymentPolicy) {
+	if policy.MinConfirmations <= 0 {
+		policy.MinConfirmations = DefaultPaymentConfirmations
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.paymentPolicy = policy
+}
+
+// RequestForgePayment creates a payment of the forge fee for address, the
+// miner or claimant that will use it
+func (t *Treasury) RequestForgePayment(address string) (*ForgePayment, error) {
+	if address == "" {
+		return nil, fmt.Errorf("%w: an address is required", ErrInvalidPayment)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate payment ID: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.treasuryKey == nil {
+		return nil, ErrNoTreasuryKey
+	}
+	pending := 0
+	for _, p := range t.payments {
+		if p.Address == address && p.Status == PaymentPending {
+			pending++
+		}
+	}
+	if pending >= MaxPendingPayments {
+		return nil, fmt.Errorf("%w: %s has %d", ErrTooManyPayments, address, pending)
+	}
+
+	entry := LedgerEntry{
+		Op:        OpPaymentRequest,
+		PaymentID: hex.EncodeToString(id),
+		Address:   address,
+		Timestamp: time.Now(),
+	}
+	outputKey := txscript.ComputeTaprootOutputKey(t.treasuryKey, paymentTweak(entry.PaymentID))
+	payTo, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), t.scriptNet)
+	if err != nil {
+		return nil, err
+	}
+	entry.PayTo = payTo.EncodeAddress()
+	entry.Sats = t.paymentPolicy.FeeSats
+	if entry.Sats <= 0 {
+		entry.Sats = int64(crypto.CalculateForgeFee(uint64(t.totalForges)))
+	}
+	if err := t.writeAhead(entry); err != nil {
+		return nil, err
+	}
+	payment := *t.applyPaymentRequest(entry)
+	t.checkpoint()
+	return &payment, nil
+}
+
+// VerifyForgePayment checks that tx pays payment id its fee and that proof
+// places tx in a block with enough confirmations. Verifying a verified
+// payment again is a no-op.
+func (t *Treasury) VerifyForgePayment(id string, tx *wire.MsgTx, proof bitcoin.TransactionProof) (*ForgePayment, error) {
+	t.mu.RLock()
+	p, ok := t.paymentIndex[id]
+	var payment ForgePayment
+	if ok {
+		payment = *p
+	}
+	policy := t.paymentPolicy
+	t.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPayment, id)
+	}
+	if payment.Status != PaymentPending {
+		return &payment, nil
+	}
+	if policy.Chain == nil {
+		return nil, ErrNoPaymentChain
+	}
+
+	pkScript, _ := hex.DecodeString(payment.PkScript)
+	var paid int64
+	for _, out := range tx.TxOut {
+		if bytes.Equal(out.PkScript, pkScript) {
+			paid += out.Value
+		}
+	}
+	if paid < payment.FeeSats {
+		return nil, fmt.Errorf("%w: %s pays %d of %d sats to %s", ErrInvalidPayment, tx.TxHash(), paid, payment.FeeSats, payment.PayTo)
+	}
+	proof.TxHash = tx.TxHash()
+	confirmations, err := policy.Chain.Confirmations(&proof)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayment, err)
+	}
+	if confirmations < policy.MinConfirmations {
+		return nil, fmt.Errorf("%w: %d of %d confirmations", ErrPaymentUnconfirmed, confirmations, policy.MinConfirmations)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Another request may have verified it meanwhile
+	if p.Status != PaymentPending {
+		payment = *p
+		return &payment, nil
+	}
+	entry := LedgerEntry{
+		Op:        OpPaymentVerify,
+		PaymentID: id,
+		TxHash:    proof.TxHash.String(),
+		Sats:      paid,
+		BlockHash: proof.BlockHash.String(),
+		Height:    uint32(proof.BlockHeight),
+		Timestamp: time.Now(),
+	}
+	if err := t.writeAhead(entry); err != nil {
+		return nil, err
+	}
+	t.applyPaymentVerify(entry)
+	t.checkpoint()
+	payment = *p
+	return &payment, nil
+}
+
+func (t *Treasury) applyPaymentRequest(entry LedgerEntry) *ForgePayment {
+	payment := &ForgePayment{
+		ID:        entry.PaymentID,
+		Address:   entry.Address,
+		PayTo:     entry.PayTo,
+		Tweak:     hex.EncodeToString(paymentTweak(entry.PaymentID)),
+		FeeSats:   entry.Sats,
+		Status:    PaymentPending,
+		CreatedAt: entry.Timestamp,
+	}
+	if _, program, err := bitcoin.DecodeBech32m(entry.PayTo); err == nil {
+		if key, err := schnorr.ParsePubKey(program); err == nil {
+			script, _ := txscript.PayToTaprootScript(key)
+			payment.PkScript = hex.EncodeToString(script)
+		}
+	}
+	t.payments = append(t.payments, payment)
+	t.paymentIndex[payment.ID] = payment
+	return payment
+}
+
+func (t *Treasury) applyPaymentVerify(entry LedgerEntry) {
+	p, ok := t.paymentIndex[entry.PaymentID]
+	if !ok || p.Status != PaymentPending {
+		return
+	}
+	p.Status = PaymentVerified
+	p.TxHash = entry.TxHash
+	p.PaidSats = entry.Sats
+	p.BlockHash = entry.BlockHash
+	p.BlockHeight = entry.Height
+	p.VerifiedAt = entry.Timestamp
+}
+
+// usablePayment returns the oldest verified, unused payment of address;
+// callers must hold t.mu
+func (t *Treasury) usablePayment(address string) *ForgePayment {
+	for _, p := range t.payments {
+		if p.Address == address && p.Status == PaymentVerified {
+			return p
+		}
+	}
+	return nil
+}
+
+// requirePayment returns the payment a forge or claim by address uses, or
+// "" when payments are not required; callers must hold t.mu
+func (t *Treasury) requirePayment(address string) (string, error) {
+	if !t.paymentPolicy.Required {
+		return "", nil
+	}
+	p := t.usablePayment(address)
+	if p == nil {
+		return "", fmt.Errorf("%w: %s has no verified payment", ErrPaymentRequired, address)
+	}
+	return p.ID, nil
+}
+
+// usePayment marks the payment of a forge or claim entry used
+func (t *Treasury) usePayment(id string, forgeID, claimID int) {
+	if p, ok := t.paymentIndex[id]; ok && id != "" {
+		p.Status = PaymentUsed
+		p.ForgeID = forgeID
+		p.ClaimID = claimID
+	}
+}
+
+// GetForgePayment returns payment id
+func (t *Treasury) GetForgePayment(id string) (*ForgePayment, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	p, ok := t.paymentIndex[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPayment, id)
+	}
+	payment := *p
+	return &payment, nil
+}
+
+// GetForgePayments returns every payment, oldest first
+func (t *Treasury) GetForgePayments() []ForgePayment {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	payments := make([]ForgePayment, len(t.payments))
+	for i, p := range t.payments {
+		payments[i] = *p
+	}
+	return payments
+}
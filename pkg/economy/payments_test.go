@@ -0,0 +1,133 @@
+package economy
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// fakeChain confirms every proof to a fixed depth
+type fakeChain struct {
+	confirmations int32
+}
+
+func (c *fakeChain) Confirmations(proof *bitcoin.TransactionProof) (int32, error) {
+	proof.BlockHeight = 800000
+	return c.confirmations, nil
+}
+
+func payTx(t *testing.T, p *ForgePayment, sats int64) *wire.MsgTx {
+	t.Helper()
+	pkScript, err := hex.DecodeString(p.PkScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{})
+	tx.AddTxOut(wire.NewTxOut(sats, pkScript))
+	return tx
+}
+
+func TestForgePayments(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := treasury.RequestForgePayment("bcrt1pminer"); !errors.Is(err, ErrNoTreasuryKey) {
+		t.Errorf("Expected ErrNoTreasuryKey without a key, got %v", err)
+	}
+	key, _ := btcec.NewPrivateKey()
+	net := &chaincfg.RegressionNetParams
+	if err := treasury.SetTreasuryKey(key.PubKey().SerializeCompressed(), net); err != nil {
+		t.Fatal(err)
+	}
+	chain := &fakeChain{confirmations: 2}
+	treasury.SetPaymentPolicy(PaymentPolicy{Required: true, Chain: chain, FeeSats: 10000})
+
+	if result := treasury.ProcessForge("bcrt1pminer"); result != nil {
+		t.Fatal("Forged without a payment")
+	}
+	if _, _, err := treasury.ProcessForgeWithFee("bcrt1pminer", false); !errors.Is(err, ErrPaymentRequired) {
+		t.Errorf("Expected ErrPaymentRequired, got %v", err)
+	}
+
+	payment, err := treasury.RequestForgePayment("bcrt1pminer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The treasury key tweaked by the payment's tweak spends the address
+	tweak, _ := hex.DecodeString(payment.Tweak)
+	spender := txscript.TweakTaprootPrivKey(*key, tweak)
+	want, _ := btcutil.NewAddressTaproot(schnorr.SerializePubKey(spender.PubKey()), net)
+	if payment.PayTo != want.EncodeAddress() || payment.FeeSats != 10000 || payment.Status != PaymentPending {
+		t.Errorf("Payment = %+v, want 10000 sats to %s", payment, want)
+	}
+
+	if _, err := treasury.VerifyForgePayment(payment.ID, payTx(t, payment, 9999), bitcoin.TransactionProof{}); !errors.Is(err, ErrInvalidPayment) {
+		t.Errorf("Expected ErrInvalidPayment for an underpayment, got %v", err)
+	}
+	tx := payTx(t, payment, 10000)
+	if _, err := treasury.VerifyForgePayment(payment.ID, tx, bitcoin.TransactionProof{}); !errors.Is(err, ErrPaymentUnconfirmed) {
+		t.Errorf("Expected ErrPaymentUnconfirmed at 2 confirmations, got %v", err)
+	}
+	chain.confirmations = DefaultPaymentConfirmations
+	verified, err := treasury.VerifyForgePayment(payment.ID, tx, bitcoin.TransactionProof{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Status != PaymentVerified || verified.TxHash != tx.TxHash().String() || verified.BlockHeight != 800000 {
+		t.Errorf("Verified payment = %+v", verified)
+	}
+
+	// Another miner cannot use it, and it pays for one forge only
+	if result := treasury.ProcessForge("bcrt1pother"); result != nil {
+		t.Error("Forged with another miner's payment")
+	}
+	result := treasury.ProcessForge("bcrt1pminer")
+	if result == nil || result.PaymentID != payment.ID {
+		t.Fatalf("Forge with a verified payment = %+v", result)
+	}
+	if result := treasury.ProcessForge("bcrt1pminer"); result != nil {
+		t.Error("Forged twice with one payment")
+	}
+
+	treasury.Close()
+	reopened, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	got, err := reopened.GetForgePayment(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != PaymentUsed || got.ForgeID != result.ForgeID || got.PkScript != payment.PkScript {
+		t.Errorf("Payment after reopening = %+v", got)
+	}
+}
+
+func TestTooManyPendingPayments(t *testing.T) {
+	treasury := NewTreasury()
+	key, _ := btcec.NewPrivateKey()
+	treasury.SetTreasuryKey(key.PubKey().SerializeCompressed(), &chaincfg.RegressionNetParams)
+	for i := 0; i < MaxPendingPayments; i++ {
+		if _, err := treasury.RequestForgePayment("bcrt1pminer"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := treasury.RequestForgePayment("bcrt1pminer"); !errors.Is(err, ErrTooManyPayments) {
+		t.Errorf("Expected ErrTooManyPayments, got %v", err)
+	}
+	if _, err := treasury.RequestForgePayment("bcrt1pother"); err != nil {
+		t.Errorf("Another address was refused: %v", err)
+	}
+}
@@ -0,0 +1,364 @@
+package economy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/keystore"
+)
+
+// Multisig distributions. A distribution proposal names an amount, a
+// recipient and a purpose; it is paid once a threshold of the configured
+// signers has approved it with BIP-340 Schnorr signatures over its Digest,
+// and expires if that takes too long. Proposals and approvals are ledger
+// entries, and the approval that meets the threshold makes the
+// distribution in the same entry, so a restart keeps pending approvals and
+// can never pay a proposal twice.
+
+// DefaultProposalTTL is how long a proposal may collect approvals
+const DefaultProposalTTL = 72 * time.Hour
+
+// Proposal states
+const (
+	ProposalPending  = "pending"
+	ProposalExecuted = "executed"
+	ProposalExpired  = "expired"
+)
+
+var (
+	// ErrNoSigners indicates a proposal made without distribution signers
+	ErrNoSigners = errors.New("no distribution signers configured")
+	// ErrInvalidProposal indicates a proposal with a bad amount, recipient
+	// or purpose
+	ErrInvalidProposal = errors.New("invalid distribution proposal")
+	// ErrUnknownProposal indicates a proposal ID that does not exist
+	ErrUnknownProposal = errors.New("unknown distribution proposal")
+	// ErrProposalClosed indicates an approval of a proposal that has
+	// already been executed or has expired
+	ErrProposalClosed = errors.New("distribution proposal closed")
+	// ErrNotSigner indicates an approval from a key that is not a
+	// distribution signer
+	ErrNotSigner = errors.New("not a distribution signer")
+	// ErrInvalidSignature indicates an approval signature that does not
+	// verify for the proposal
+	ErrInvalidSignature = errors.New("invalid proposal signature")
+)
+
+// MultisigPolicy sets who approves distribution proposals
+type MultisigPolicy struct {
+	// Signers are 32-byte x-only public keys in hex
+	Signers []string
+	// Threshold is the number of approvals that execute a proposal
+	Threshold int
+	// TTL is how long a proposal may collect approvals,
+	// DefaultProposalTTL when zero
+	TTL time.Duration
+}
+
+// Proposal is a distribution awaiting or past its approvals
+type Proposal struct {
+	// ID is random, so signatures cannot be replayed against another
+	// proposal or treasury
+	ID         string     `json:"id"`
+	Amount     Amount     `json:"amount"`
+	Recipient  string     `json:"recipient"`
+	Purpose    string     `json:"purpose"`
+	ProposedBy string     `json:"proposed_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Threshold  int        `json:"threshold"`
+	Approvals  []Approval `json:"approvals,omitempty"`
+	Status     string     `json:"status"`
+	// DistributionID is the distribution that paid an executed proposal
+	DistributionID int       `json:"distribution_id,omitempty"`
+	ExecutedAt     time.Time `json:"executed_at,omitempty"`
+}
+
+// Approval is one signer's signature over a proposal
+type Approval struct {
+	// Signer is an x-only public key in hex
+	Signer string `json:"signer"`
+	// Signature is a hex BIP-340 signature of the proposal's Digest
+	Signature string    `json:"signature"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Digest returns the message signers sign to approve the proposal. It
+// commits to the ID, amount, recipient, purpose and expiry.
+func (p *Proposal) Digest() []byte {
+	digest := sha256.Sum256([]byte(strings.Join([]string{
+		"exs-distribution",
+		p.ID,
+		strconv.FormatInt(int64(p.Amount), 10),
+		p.Recipient,
+		p.Purpose,
+		strconv.FormatInt(p.ExpiresAt.Unix(), 10),
+	}, "\n")))
+	return digest[:]
+}
+
+// SignProposal signs the approval of p with a distribution signer's key
+func SignProposal(key keystore.Signer, p *Proposal) ([]byte, error) {
+	sig, err := key.SignSchnorr(p.Digest())
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+// SetMultisigPolicy replaces the distribution signers. Without signers no
+// proposal can be made.
+func (t *Treasury) SetMultisigPolicy(policy MultisigPolicy) error {
+	signers := make(map[string]bool)
+	for _, signer := range policy.Signers {
+		key, err := parseSignerKey(signer)
+		if err != nil {
+			return err
+		}
+		signers[key] = true
+	}
+	if len(signers) > 0 && (policy.Threshold < 1 || policy.Threshold > len(signers)) {
+		return fmt.Errorf("distribution threshold %d must be between 1 and %d signers", policy.Threshold, len(signers))
+	}
+	if policy.TTL <= 0 {
+		policy.TTL = DefaultProposalTTL
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.signers = signers
+	t.threshold = policy.Threshold
+	t.proposalTTL = policy.TTL
+	return nil
+}
+
+// parseSignerKey normalizes a signer key to lowercase hex
+func parseSignerKey(signer string) (string, error) {
+	raw, err := hex.DecodeString(signer)
+	if err == nil {
+		_, err = schnorr.ParsePubKey(raw)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid distribution signer %q: %v", signer, err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// Propose creates a distribution proposal, recording by as its proposer
+func (t *Treasury) Propose(amount Amount, recipient, purpose, by string) (*Proposal, error) {
+	if amount <= 0 || amount > TotalSupplyCap {
+		return nil, fmt.Errorf("%w: amount %s EXS", ErrInvalidProposal, amount)
+	}
+	if recipient == "" || strings.IndexFunc(recipient, unicode.IsSpace) >= 0 {
+		return nil, fmt.Errorf("%w: recipient %q", ErrInvalidProposal, recipient)
+	}
+	if strings.TrimSpace(purpose) == "" || strings.IndexFunc(purpose, unicode.IsControl) >= 0 {
+		return nil, fmt.Errorf("%w: a one-line purpose is required", ErrInvalidProposal)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate proposal ID: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.signers) == 0 {
+		return nil, ErrNoSigners
+	}
+	// Expiry is signed in whole seconds
+	now := time.Now().UTC().Truncate(time.Second)
+	entry := LedgerEntry{
+		Op:         OpPropose,
+		ProposalID: hex.EncodeToString(id),
+		Amount:     amount,
+		Address:    recipient,
+		Purpose:    purpose,
+		By:         by,
+		Threshold:  t.threshold,
+		Timestamp:  now,
+		ExpiresAt:  now.Add(t.proposalTTL),
+	}
+	if err := t.writeAhead(entry); err != nil {
+		return nil, err
+	}
+	p := t.applyPropose(entry)
+	t.checkpoint()
+	proposal := p.view(now)
+	return &proposal, nil
+}
+
+// Approve records signer's signature over proposal id. The approval that
+// meets the threshold distributes the amount, so it is refused while the
+// treasury is halted or short of funds and can be retried. Repeated
+// approvals from one signer count once, and approvals from signers since
+// removed from the policy do not count.
+func (t *Treasury) Approve(id, signer string, signature []byte) (*Proposal, error) {
+	key, err := parseSignerKey(signer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotSigner, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.proposalIndex[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProposal, id)
+	}
+	now := time.Now()
+	if status := p.view(now).Status; status != ProposalPending {
+		return nil, fmt.Errorf("%w: %s is %s", ErrProposalClosed, id, status)
+	}
+	if !t.signers[key] {
+		return nil, fmt.Errorf("%w: %s", ErrNotSigner, key)
+	}
+	sig, err := schnorr.ParseSignature(signature)
+	if err != nil || !sig.Verify(p.Digest(), mustParseKey(key)) {
+		return nil, ErrInvalidSignature
+	}
+	for _, approval := range p.Approvals {
+		if approval.Signer == key {
+			proposal := p.view(now)
+			return &proposal, nil
+		}
+	}
+	approvals := 1
+	for _, approval := range p.Approvals {
+		if t.signers[approval.Signer] {
+			approvals++
+		}
+	}
+	if approvals >= p.Threshold {
+		if err := t.halted(); err != nil {
+			return nil, err
+		}
+		if p.Amount > t.balance {
+			return nil, fmt.Errorf("%w: have %s EXS, need %s EXS", ErrInsufficientFunds, t.balance, p.Amount)
+		}
+	}
+
+	entry := LedgerEntry{
+		Op:         OpApprove,
+		ProposalID: id,
+		Signer:     key,
+		Signature:  hex.EncodeToString(signature),
+		Approvals:  approvals,
+		Timestamp:  now,
+	}
+	t.markSettlement(&entry)
+	if err := t.writeAhead(entry); err != nil {
+		return nil, err
+	}
+	dist := t.applyApprove(entry)
+	t.checkpoint()
+	if dist != nil {
+		if t.onEvent != nil {
+			t.onEvent(EventDistribution, *dist)
+		}
+		t.notifyBalance(dist.Recipient)
+	}
+	proposal := p.view(now)
+	return &proposal, nil
+}
+
+// mustParseKey parses a key parseSignerKey has validated
+func mustParseKey(key string) *btcec.PublicKey {
+	raw, _ := hex.DecodeString(key)
+	pub, _ := schnorr.ParsePubKey(raw)
+	return pub
+}
+
+func (t *Treasury) applyPropose(entry LedgerEntry) *Proposal {
+	p := &Proposal{
+		ID:         entry.ProposalID,
+		Amount:     entry.Amount,
+		Recipient:  entry.Address,
+		Purpose:    entry.Purpose,
+		ProposedBy: entry.By,
+		CreatedAt:  entry.Timestamp,
+		ExpiresAt:  entry.ExpiresAt,
+		Threshold:  entry.Threshold,
+		Status:     ProposalPending,
+	}
+	t.proposals = append(t.proposals, p)
+	t.proposalIndex[p.ID] = p
+	return p
+}
+
+// applyApprove records an approval, making the distribution when it meets
+// the threshold. The entry carries the approvals that counted when it was
+// written, as the signer policy is not in the ledger; older entries without
+// it count every approval.
+func (t *Treasury) applyApprove(entry LedgerEntry) *Distribution {
+	p, ok := t.proposalIndex[entry.ProposalID]
+	if !ok || p.Status != ProposalPending {
+		return nil
+	}
+	p.Approvals = append(p.Approvals, Approval{Signer: entry.Signer, Signature: entry.Signature, Timestamp: entry.Timestamp})
+	sort.Slice(p.Approvals, func(i, j int) bool { return p.Approvals[i].Signer < p.Approvals[j].Signer })
+	approvals := entry.Approvals
+	if approvals == 0 {
+		approvals = len(p.Approvals)
+	}
+	if approvals < p.Threshold {
+		return nil
+	}
+
+	dist := t.applyDistribution(LedgerEntry{
+		Amount:     p.Amount,
+		Address:    p.Recipient,
+		Purpose:    p.Purpose,
+		TxHash:     entry.TxHash,
+		ProposalID: p.ID,
+		Timestamp:  entry.Timestamp,
+		Settle:     entry.Settle,
+	})
+	p.Status = ProposalExecuted
+	p.DistributionID = dist.ID
+	p.ExecutedAt = entry.Timestamp
+	return &dist
+}
+
+// view returns a copy of p with its status at now
+func (p *Proposal) view(now time.Time) Proposal {
+	proposal := *p
+	proposal.Approvals = append([]Approval(nil), p.Approvals...)
+	if proposal.Status == ProposalPending && !now.Before(p.ExpiresAt) {
+		proposal.Status = ProposalExpired
+	}
+	return proposal
+}
+
+// GetProposal returns proposal id
+func (t *Treasury) GetProposal(id string) (*Proposal, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	p, ok := t.proposalIndex[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProposal, id)
+	}
+	proposal := p.view(time.Now())
+	return &proposal, nil
+}
+
+// GetProposals returns every proposal, oldest first
+func (t *Treasury) GetProposals() []Proposal {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	now := time.Now()
+	proposals := make([]Proposal, len(t.proposals))
+	for i, p := range t.proposals {
+		proposals[i] = p.view(now)
+	}
+	return proposals
+}
@@ -0,0 +1,231 @@
+package economy
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/keystore"
+)
+
+// distributionSigners returns n signer keys and their x-only keys in hex
+func distributionSigners(t *testing.T, n int) ([]*btcec.PrivateKey, []string) {
+	t.Helper()
+	keys := make([]*btcec.PrivateKey, n)
+	signers := make([]string, n)
+	for i := range keys {
+		key, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+		signers[i] = hexKey(key)
+	}
+	return keys, signers
+}
+
+func hexKey(key *btcec.PrivateKey) string {
+	return hex.EncodeToString(schnorr.SerializePubKey(key.PubKey()))
+}
+
+func approve(t *testing.T, treasury *Treasury, p *Proposal, key *btcec.PrivateKey) (*Proposal, error) {
+	t.Helper()
+	sig, err := SignProposal(keystore.PrivateKeySigner(key), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return treasury.Approve(p.ID, hexKey(key), sig)
+}
+
+func TestDistributionProposal(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := treasury.Propose(Coin, "bc1pgrant", "Grant", "arthur"); !errors.Is(err, ErrNoSigners) {
+		t.Errorf("Expected ErrNoSigners, got %v", err)
+	}
+	keys, signers := distributionSigners(t, 3)
+	if err := treasury.SetMultisigPolicy(MultisigPolicy{Signers: signers, Threshold: 4}); err == nil {
+		t.Error("Expected a threshold above the signers to be refused")
+	}
+	if err := treasury.SetMultisigPolicy(MultisigPolicy{Signers: signers, Threshold: 2}); err != nil {
+		t.Fatal(err)
+	}
+	treasury.ProcessForge("bc1pminer")
+
+	if _, err := treasury.Propose(0, "bc1pgrant", "Grant", "arthur"); !errors.Is(err, ErrInvalidProposal) {
+		t.Errorf("Expected ErrInvalidProposal for a zero amount, got %v", err)
+	}
+	if _, err := treasury.Propose(Coin, "bc1pgrant", "Grant\nPay twice", "arthur"); !errors.Is(err, ErrInvalidProposal) {
+		t.Errorf("Expected ErrInvalidProposal for a multi-line purpose, got %v", err)
+	}
+	p, err := treasury.Propose(2*Coin, "bc1pgrant", "Development grant", "arthur")
+	if err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+	if p.Status != ProposalPending || p.Threshold != 2 || p.ProposedBy != "arthur" {
+		t.Errorf("Propose() = %+v", p)
+	}
+
+	// A signature must be over this proposal, by a configured signer
+	tampered := *p
+	tampered.Amount = 10 * Coin
+	sig, _ := SignProposal(keystore.PrivateKeySigner(keys[0]), &tampered)
+	if _, err := treasury.Approve(p.ID, signers[0], sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a tampered proposal, got %v", err)
+	}
+	outsider, _ := distributionSigners(t, 1)
+	if _, err := approve(t, treasury, p, outsider[0]); !errors.Is(err, ErrNotSigner) {
+		t.Errorf("Expected ErrNotSigner, got %v", err)
+	}
+	if _, err := treasury.Approve("unknown", signers[0], sig); !errors.Is(err, ErrUnknownProposal) {
+		t.Errorf("Expected ErrUnknownProposal, got %v", err)
+	}
+
+	// One signer approving twice counts once
+	for i := 0; i < 2; i++ {
+		got, err := approve(t, treasury, p, keys[0])
+		if err != nil || got.Status != ProposalPending || len(got.Approvals) != 1 {
+			t.Fatalf("First approval = %+v, %v", got, err)
+		}
+	}
+	if balance := treasury.GetBalance(); balance != TreasuryAllocation {
+		t.Errorf("Treasury balance %s before the threshold, expected %s", balance, TreasuryAllocation)
+	}
+
+	// The final approval waits out a halt
+	treasury.SetHaltCheck(func() error { return errors.New("halted") })
+	if _, err := approve(t, treasury, p, keys[1]); err == nil {
+		t.Error("Expected the final approval to be refused while halted")
+	}
+	treasury.SetHaltCheck(nil)
+
+	got, err := approve(t, treasury, p, keys[1])
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+	if got.Status != ProposalExecuted || got.DistributionID != 1 {
+		t.Errorf("Approve() at the threshold = %+v", got)
+	}
+	if balance := treasury.GetBalance(); balance != TreasuryAllocation-2*Coin {
+		t.Errorf("Treasury balance %s after the distribution, expected %s", balance, TreasuryAllocation-2*Coin)
+	}
+	if dists := treasury.GetDistributions(); len(dists) != 1 || dists[0].ProposalID != p.ID || dists[0].Amount != 2*Coin {
+		t.Errorf("Distributions = %+v", dists)
+	}
+	if _, err := approve(t, treasury, p, keys[2]); !errors.Is(err, ErrProposalClosed) {
+		t.Errorf("Expected ErrProposalClosed after execution, got %v", err)
+	}
+
+	// A proposal larger than the balance cannot be executed
+	large, err := treasury.Propose(TreasuryAllocation, "bc1pgrant", "Too much", "arthur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := approve(t, treasury, large, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := approve(t, treasury, large, keys[1]); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+
+	// Proposals and approvals are replayed from the ledger, and an executed
+	// proposal is not paid again
+	treasury.Close()
+	reopened, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if err := reopened.SetMultisigPolicy(MultisigPolicy{Signers: signers, Threshold: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if balance := reopened.GetBalance(); balance != TreasuryAllocation-2*Coin {
+		t.Errorf("Treasury balance %s after reopening, expected %s", balance, TreasuryAllocation-2*Coin)
+	}
+	proposals := reopened.GetProposals()
+	if len(proposals) != 2 || proposals[0].Status != ProposalExecuted || len(proposals[1].Approvals) != 1 {
+		t.Errorf("Proposals after reopening = %+v", proposals)
+	}
+	if _, err := approve(t, reopened, p, keys[2]); !errors.Is(err, ErrProposalClosed) {
+		t.Errorf("Expected ErrProposalClosed after reopening, got %v", err)
+	}
+}
+
+func TestProposalRemovedSigner(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, signers := distributionSigners(t, 3)
+	if err := treasury.SetMultisigPolicy(MultisigPolicy{Signers: signers, Threshold: 2}); err != nil {
+		t.Fatal(err)
+	}
+	treasury.ProcessForge("bc1pminer")
+
+	p, err := treasury.Propose(Coin, "bc1pgrant", "Grant", "arthur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := approve(t, treasury, p, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once keys[0] is removed its approval no longer counts
+	if err := treasury.SetMultisigPolicy(MultisigPolicy{Signers: signers[1:], Threshold: 2}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := approve(t, treasury, p, keys[1])
+	if err != nil || got.Status != ProposalPending || len(got.Approvals) != 2 {
+		t.Fatalf("Approval after the removal = %+v, %v", got, err)
+	}
+	got, err = approve(t, treasury, p, keys[2])
+	if err != nil || got.Status != ProposalExecuted {
+		t.Fatalf("Approval at the threshold = %+v, %v", got, err)
+	}
+
+	// Replaying the ledger pays the proposal once, on the same approval
+	treasury.Close()
+	reopened, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if dists := reopened.GetDistributions(); len(dists) != 1 || dists[0].ProposalID != p.ID {
+		t.Errorf("Distributions after reopening = %+v", dists)
+	}
+	if balance := reopened.GetBalance(); balance != TreasuryAllocation-Coin {
+		t.Errorf("Treasury balance %s after reopening, expected %s", balance, TreasuryAllocation-Coin)
+	}
+}
+
+func TestProposalExpiry(t *testing.T) {
+	treasury := NewTreasury()
+	keys, signers := distributionSigners(t, 1)
+	if err := treasury.SetMultisigPolicy(MultisigPolicy{Signers: signers, Threshold: 1, TTL: time.Nanosecond}); err != nil {
+		t.Fatal(err)
+	}
+	treasury.ProcessForge("bc1pminer")
+
+	p, err := treasury.Propose(Coin, "bc1pgrant", "Grant", "arthur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := approve(t, treasury, p, keys[0]); !errors.Is(err, ErrProposalClosed) {
+		t.Errorf("Expected ErrProposalClosed for an expired proposal, got %v", err)
+	}
+	if got, _ := treasury.GetProposal(p.ID); got.Status != ProposalExpired {
+		t.Errorf("Expired proposal status = %s", got.Status)
+	}
+	if balance := treasury.GetBalance(); balance != TreasuryAllocation {
+		t.Errorf("Treasury balance %s after expiry, expected %s", balance, TreasuryAllocation)
+	}
+}
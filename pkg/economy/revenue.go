@@ -0,0 +1,157 @@
+package economy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Revenue streams. The treasury is credited with the revenue of a stream as
+// it is earned outside the protocol, converted to EXS; each credit carries
+// the stream's index of the earning, so a collector that restarts or
+// retries never credits an earning twice.
+
+// StreamLightning is the routing fees earned by the protocol's Lightning
+// node
+const StreamLightning = "lightning_routing"
+
+// StreamCrossChain prefixes the streams of coins mined on other chains
+// through external pools, e.g. cross_chain_mining_ltc
+const StreamCrossChain = "cross_chain_mining"
+
+// CrossChainStream returns the stream of coins mined on chain, e.g. "btc"
+func CrossChainStream(chain string) string {
+	return StreamCrossChain + "_" + chain
+}
+
+var (
+	// ErrDuplicateRevenue indicates an earning at or below the last index
+	// credited for its stream
+	ErrDuplicateRevenue = errors.New("revenue already credited")
+	// ErrInvalidRevenue indicates an earning without a stream or index, or
+	// with a negative amount
+	ErrInvalidRevenue = errors.New("invalid revenue")
+)
+
+// Revenue is one earning of a revenue stream
+type Revenue struct {
+	Stream string
+	// Index orders the stream's earnings; it must increase with each credit
+	Index uint64
+	// Units is the earning in the stream's base unit: millisatoshis for
+	// Lightning fees, the coin's smallest unit for mined coins
+	Units int64
+	// Amount is the EXS the treasury is credited with
+	Amount    Amount
+	Timestamp time.Time
+}
+
+// RevenueStream totals the revenue credited from a stream
+type RevenueStream struct {
+	Name      string    `json:"name"`
+	Earnings  int       `json:"earnings"`
+	Units     int64     `json:"units"`
+	Amount    Amount    `json:"amount"`
+	LastIndex uint64    `json:"last_index"`
+	LastAt    time.Time `json:"last_at,omitempty"`
+}
+
+// ProcessLightningRoutingFee credits the treasury with a routing fee of the
+// protocol's Lightning node, see CreditRevenue
+func (t *Treasury) ProcessLightningRoutingFee(index uint64, feeMsat int64, amount Amount, at time.Time) error {
+	return t.CreditRevenue(Revenue{Stream: StreamLightning, Index: index, Units: feeMsat, Amount: amount, Timestamp: at})
+}
+
+// ProcessCrossChainReward credits the treasury with a payout of coins mined
+// on chain through an external pool, in the coin's smallest unit; index
+// numbers the chain's payouts. See CreditRevenue.
+func (t *Treasury) ProcessCrossChainReward(chain string, index uint64, units int64, amount Amount, at time.Time) error {
+	if chain == "" {
+		return fmt.Errorf("%w: cross-chain reward without a chain", ErrInvalidRevenue)
+	}
+	return t.CreditRevenue(Revenue{Stream: CrossChainStream(chain), Index: index, Units: units, Amount: amount, Timestamp: at})
+}
+
+// CreditRevenue adds r.Amount to the treasury balance and the fees
+// collected. It returns ErrDuplicateRevenue when the stream has already been
+// credited up to r.Index.
+func (t *Treasury) CreditRevenue(r Revenue) error {
+	if r.Stream == "" || r.Index == 0 || r.Units < 0 || r.Amount < 0 {
+		return fmt.Errorf("%w: %+v", ErrInvalidRevenue, r)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.halted(); err != nil {
+		return err
+	}
+	if s := t.revenueStream(r.Stream); s != nil && r.Index <= s.LastIndex {
+		return fmt.Errorf("%w: %s %d (last %d)", ErrDuplicateRevenue, r.Stream, r.Index, s.LastIndex)
+	}
+	entry := LedgerEntry{
+		Op:        OpRevenue,
+		Stream:    r.Stream,
+		Index:     r.Index,
+		Sats:      r.Units,
+		Amount:    r.Amount,
+		Timestamp: r.Timestamp,
+	}
+	if err := t.writeAhead(entry); err != nil {
+		return err
+	}
+	t.applyRevenue(entry)
+	t.checkpoint()
+	t.notifyBalance()
+	return nil
+}
+
+func (t *Treasury) applyRevenue(entry LedgerEntry) {
+	s := t.revenueStream(entry.Stream)
+	if s == nil {
+		t.revenue = append(t.revenue, &RevenueStream{Name: entry.Stream})
+		s = t.revenue[len(t.revenue)-1]
+	}
+	s.Earnings++
+	s.Units += entry.Sats
+	s.Amount += entry.Amount
+	s.LastIndex = entry.Index
+	s.LastAt = entry.Timestamp
+
+	t.balance += entry.Amount
+	t.totalFeesCollected += entry.Amount
+}
+
+// revenueStream returns the totals of stream, nil before its first credit.
+// The caller must hold t.mu.
+func (t *Treasury) revenueStream(stream string) *RevenueStream {
+	for _, s := range t.revenue {
+		if s.Name == stream {
+			return s
+		}
+	}
+	return nil
+}
+
+// RevenueStreams returns the totals of every credited stream by name
+func (t *Treasury) RevenueStreams() []RevenueStream {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	streams := make([]RevenueStream, 0, len(t.revenue))
+	for _, s := range t.revenue {
+		streams = append(streams, *s)
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Name < streams[j].Name })
+	return streams
+}
+
+// RevenueStream returns the totals of stream, which are zero before its
+// first credit
+func (t *Treasury) RevenueStream(stream string) RevenueStream {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if s := t.revenueStream(stream); s != nil {
+		return *s
+	}
+	return RevenueStream{Name: stream}
+}
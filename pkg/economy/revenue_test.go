@@ -0,0 +1,86 @@
+package economy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCreditRevenue(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := treasury.ProcessLightningRoutingFee(1, 1500, 150, at); err != nil {
+		t.Fatal(err)
+	}
+	if err := treasury.ProcessLightningRoutingFee(3, 2500, 250, at.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := treasury.ProcessLightningRoutingFee(3, 2500, 250, at); !errors.Is(err, ErrDuplicateRevenue) {
+		t.Errorf("Expected ErrDuplicateRevenue for a credited index, got %v", err)
+	}
+	if err := treasury.CreditRevenue(Revenue{Stream: StreamLightning, Units: 1}); err == nil {
+		t.Error("Expected revenue without an index to be refused")
+	}
+	halted := errors.New("halted")
+	treasury.SetHaltCheck(func() error { return halted })
+	if err := treasury.ProcessLightningRoutingFee(4, 1000, 100, at); !errors.Is(err, halted) {
+		t.Errorf("Expected the halt to refuse revenue, got %v", err)
+	}
+	treasury.SetHaltCheck(nil)
+
+	want := RevenueStream{Name: StreamLightning, Earnings: 2, Units: 4000, Amount: 400, LastIndex: 3, LastAt: at.Add(time.Minute)}
+	if got := treasury.RevenueStream(StreamLightning); got != want {
+		t.Errorf("RevenueStream() = %+v, want %+v", got, want)
+	}
+	if treasury.GetBalance() != 400 || treasury.GetTotalFeesCollected() != 400 {
+		t.Errorf("Balance %s, fees %s, want 0.000004 each", treasury.GetBalance(), treasury.GetTotalFeesCollected())
+	}
+
+	// Replayed from the log, then restored from the snapshot
+	recovered, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recovered.RevenueStreams(); len(got) != 1 || got[0] != want {
+		t.Errorf("Replayed RevenueStreams() = %+v, want %+v", got, want)
+	}
+	if err := recovered.Close(); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if got := restored.RevenueStream(StreamLightning); got != want || restored.GetBalance() != 400 {
+		t.Errorf("Restored RevenueStream() = %+v, balance %s", got, restored.GetBalance())
+	}
+}
+
+func TestProcessCrossChainReward(t *testing.T) {
+	treasury, err := OpenTreasury(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer treasury.Close()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := treasury.ProcessCrossChainReward("ltc", 1, 150_000_000, Coin/4, at); err != nil {
+		t.Fatal(err)
+	}
+	if err := treasury.ProcessCrossChainReward("doge", 1, 100_000_000, Coin/400, at); err != nil {
+		t.Errorf("Expected chains to be numbered separately, got %v", err)
+	}
+	if err := treasury.ProcessCrossChainReward("", 2, 1, 1, at); !errors.Is(err, ErrInvalidRevenue) {
+		t.Errorf("Expected ErrInvalidRevenue without a chain, got %v", err)
+	}
+	if got := treasury.RevenueStream(CrossChainStream("ltc")); got.Amount != Coin/4 || got.Units != 150_000_000 {
+		t.Errorf("RevenueStream(ltc) = %+v", got)
+	}
+	if treasury.GetBalance() != Coin/4+Coin/400 {
+		t.Errorf("Balance %s, want 0.2525", treasury.GetBalance())
+	}
+}
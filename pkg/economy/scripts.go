@@ -0,0 +1,153 @@
+package economy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Mini-output scripts. Each mini-output pays the treasury key: an
+// immediately spendable one to its P2WPKH address, a locked one to the
+// P2WSH address of a bitcoin.BuildCLTVScript witness script that cannot be
+// spent before its unlock height. Forge ledger entries record the key hash
+// and network they were made with, so replay rebuilds the same scripts
+// after the key changes.
+
+// placeholderKeyHash is paid until SetTreasuryKey is called. It is the
+// hash of no known key, so its outputs are for accounting only.
+var placeholderKeyHash = []byte{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19,
+}
+
+// scriptNets are the networks mini-output addresses can be encoded for
+var scriptNets = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.SigNetParams,
+	&chaincfg.RegressionNetParams,
+}
+
+// UnlockableOutput is a spendable mini-output with what is needed to spend
+// it
+type UnlockableOutput struct {
+	OutputID     int    `json:"output_id"`
+	Amount       Amount `json:"amount"`
+	BlockHeight  uint32 `json:"block_height"`
+	UnlockHeight uint32 `json:"unlock_height"`
+	Address      string `json:"address"`
+	// PkScript is the output script, in hex
+	PkScript string `json:"pk_script"`
+	// WitnessScript is the CLTV script a locked output's P2WSH address
+	// commits to, in hex; the spending transaction's lock time must be at
+	// least UnlockHeight. It is empty for immediately spendable outputs.
+	WitnessScript string `json:"witness_script,omitempty"`
+}
+
+// SetTreasuryKey sets the compressed public key later mini-outputs and
+// forge payments pay and the network their addresses are encoded for
+func (t *Treasury) SetTreasuryKey(pubKey []byte, net *chaincfg.Params) error {
+	key, err := btcec.ParsePubKey(pubKey)
+	if err != nil {
+		return fmt.Errorf("invalid treasury key: %w", err)
+	}
+	if scriptNet(net.Name) == nil {
+		return fmt.Errorf("unsupported treasury network %q", net.Name)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.treasuryKey = key
+	t.keyHash = btcutil.Hash160(key.SerializeCompressed())
+	t.scriptNet = net
+	return nil
+}
+
+// scriptNet returns the network named name, or nil
+func scriptNet(name string) *chaincfg.Params {
+	for _, net := range scriptNets {
+		if net.Name == name {
+			return net
+		}
+	}
+	return nil
+}
+
+// treasuryKeyEntry records the treasury key hash and network in a forge
+// ledger entry; callers must hold t.mu
+func (t *Treasury) treasuryKeyEntry(entry *LedgerEntry) {
+	entry.KeyHash = hex.EncodeToString(t.keyHash)
+	entry.Network = t.scriptNet.Name
+}
+
+// entryTreasuryKey returns the key hash and network a forge entry was made
+// with. Entries written before they were recorded paid the placeholder on
+// mainnet.
+func entryTreasuryKey(entry LedgerEntry) ([]byte, *chaincfg.Params) {
+	keyHash, err := hex.DecodeString(entry.KeyHash)
+	if err != nil || len(keyHash) != 20 {
+		keyHash = placeholderKeyHash
+	}
+	net := scriptNet(entry.Network)
+	if net == nil {
+		net = &chaincfg.MainNetParams
+	}
+	return keyHash, net
+}
+
+// miniOutputScript returns the output script and address paying keyHash,
+// and for a locked output the CLTV witness script
+func miniOutputScript(keyHash []byte, unlockHeight uint32, locked bool, net *chaincfg.Params) (pkScript, witnessScript []byte, address string, err error) {
+	var addr btcutil.Address
+	if locked {
+		cltv, err := bitcoin.BuildCLTVScript(unlockHeight, keyHash)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		witnessScript = cltv.Script
+		scriptHash := sha256.Sum256(witnessScript)
+		addr, err = btcutil.NewAddressWitnessScriptHash(scriptHash[:], net)
+		if err != nil {
+			return nil, nil, "", err
+		}
+	} else {
+		addr, err = btcutil.NewAddressWitnessPubKeyHash(keyHash, net)
+		if err != nil {
+			return nil, nil, "", err
+		}
+	}
+	pkScript, err = txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return pkScript, witnessScript, addr.EncodeAddress(), nil
+}
+
+// GetUnlockableOutputs returns the unspent mini-outputs spendable at the
+// current block height with their scripts
+func (t *Treasury) GetUnlockableOutputs() []UnlockableOutput {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	outputs := make([]UnlockableOutput, 0)
+	for _, output := range t.miniOutputs {
+		if !output.IsSpendable || output.IsSpent {
+			continue
+		}
+		outputs = append(outputs, UnlockableOutput{
+			OutputID:      output.OutputID,
+			Amount:        output.Amount,
+			BlockHeight:   output.BlockHeight,
+			UnlockHeight:  output.UnlockHeight,
+			Address:       output.ScriptAddress,
+			PkScript:      hex.EncodeToString(output.PkScript),
+			WitnessScript: hex.EncodeToString(output.CLTVScript),
+		})
+	}
+	return outputs
+}
@@ -0,0 +1,74 @@
+package economy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestMiniOutputScripts(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	net := &chaincfg.RegressionNetParams
+	if err := treasury.SetTreasuryKey(key.PubKey().SerializeCompressed(), net); err != nil {
+		t.Fatal(err)
+	}
+	treasury.SetBlockHeight(1000)
+	result := treasury.ProcessForge("bcrt1pminer")
+
+	keyHash := btcutil.Hash160(key.PubKey().SerializeCompressed())
+	p2wpkh, _ := btcutil.NewAddressWitnessPubKeyHash(keyHash, net)
+	outputs := result.TreasuryMiniOutputs
+	if outputs[0].ScriptAddress != p2wpkh.EncodeAddress() || len(outputs[0].CLTVScript) != 0 {
+		t.Errorf("Immediate mini-output = %+v, want a P2WPKH to %s", outputs[0], p2wpkh)
+	}
+	for _, output := range outputs[1:] {
+		height, err := bitcoin.ValidateCLTVScript(output.CLTVScript)
+		if err != nil || height != output.UnlockHeight {
+			t.Errorf("Mini-output %d CLTV height = %d, %v, want %d", output.OutputID, height, err, output.UnlockHeight)
+		}
+		scriptHash := sha256.Sum256(output.CLTVScript)
+		p2wsh, _ := btcutil.NewAddressWitnessScriptHash(scriptHash[:], net)
+		if output.ScriptAddress != p2wsh.EncodeAddress() {
+			t.Errorf("Mini-output %d address = %s, want %s", output.OutputID, output.ScriptAddress, p2wsh)
+		}
+	}
+
+	if got := treasury.GetUnlockableOutputs(); len(got) != 1 || got[0].Address != p2wpkh.EncodeAddress() {
+		t.Errorf("Unlockable outputs at 1000 = %+v", got)
+	}
+	treasury.SetBlockHeight(1000 + MiniOutput2Delay)
+	got := treasury.GetUnlockableOutputs()
+	if len(got) != 2 || got[1].WitnessScript != hex.EncodeToString(outputs[1].CLTVScript) {
+		t.Errorf("Unlockable outputs after the first lock = %+v", got)
+	}
+
+	// Replay rebuilds the scripts the forge was made with, whatever key is
+	// configured by then
+	treasury.Close()
+	reopened, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	other, _ := btcec.NewPrivateKey()
+	reopened.SetTreasuryKey(other.PubKey().SerializeCompressed(), &chaincfg.MainNetParams)
+	for i, output := range reopened.GetMiniOutputs() {
+		if output.ScriptAddress != outputs[i].ScriptAddress || !bytes.Equal(output.PkScript, outputs[i].PkScript) {
+			t.Errorf("Mini-output %d after reopening = %s, want %s", output.OutputID, output.ScriptAddress, outputs[i].ScriptAddress)
+		}
+	}
+}
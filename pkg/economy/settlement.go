@@ -0,0 +1,179 @@
+package economy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Distribution settlement. Once EnableSettlement is called, every
+// distribution, direct or approved by proposal, is owed on Bitcoin: it starts
+// pending and a settlement engine (see pkg/settlement) pays it with a Taproot
+// transaction. The engine records the signed transaction before it
+// broadcasts it, so a restart rebroadcasts the same transaction instead of
+// paying twice, then records errors and finally the confirming block. A
+// distribution the engine gave up on stays failed until an operator retries
+// it. No new transactions are made during an emergency halt.
+
+// Settlement states
+const (
+	SettlementPending   = "pending"
+	SettlementBroadcast = "broadcast"
+	SettlementConfirmed = "confirmed"
+	SettlementFailed    = "failed"
+)
+
+var (
+	// ErrUnknownDistribution indicates a distribution ID that does not exist
+	ErrUnknownDistribution = errors.New("unknown distribution")
+	// ErrSettlementState indicates a settlement change the distribution's
+	// current state does not allow
+	ErrSettlementState = errors.New("invalid settlement state")
+)
+
+// EnableSettlement settles every later distribution on Bitcoin instead of
+// recording a mock transaction hash
+func (t *Treasury) EnableSettlement() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.settle = true
+}
+
+// markSettlement marks a distribution entry for settlement or, with
+// settlement off, gives it a mock transaction hash; callers must hold t.mu
+func (t *Treasury) markSettlement(entry *LedgerEntry) {
+	if t.settle {
+		entry.Settle = true
+		return
+	}
+	entry.TxHash = fmt.Sprintf("0x%x", entry.Timestamp.UnixNano()) // Mock tx hash
+}
+
+// PendingSettlements returns the distributions awaiting a transaction or its
+// confirmation, oldest first
+func (t *Treasury) PendingSettlements() []Distribution {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var pending []Distribution
+	for _, d := range t.distributions {
+		if d.Settlement == SettlementPending || d.Settlement == SettlementBroadcast {
+			pending = append(pending, d)
+		}
+	}
+	return pending
+}
+
+// GetDistribution returns distribution id
+func (t *Treasury) GetDistribution(id int) (*Distribution, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	d, err := t.distribution(id)
+	if err != nil {
+		return nil, err
+	}
+	dist := *d
+	return &dist, nil
+}
+
+// distribution returns distribution id; callers must hold t.mu
+func (t *Treasury) distribution(id int) (*Distribution, error) {
+	if id < 1 || id > len(t.distributions) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownDistribution, id)
+	}
+	return &t.distributions[id-1], nil
+}
+
+// RecordSettlementTx records the signed transaction paying pending
+// distribution id, before it is broadcast. It is refused during an emergency
+// halt.
+func (t *Treasury) RecordSettlementTx(id int, txHash, rawTx string) (*Distribution, error) {
+	return t.settlement(LedgerEntry{Op: OpSettleTx, TxHash: txHash, RawTx: rawTx}, id, SettlementPending)
+}
+
+// RecordSettlementError records a failed attempt to pay distribution id.
+// A final error fails the settlement; otherwise it stays where it was and is
+// attempted again.
+func (t *Treasury) RecordSettlementError(id int, err error, final bool) (*Distribution, error) {
+	return t.settlement(LedgerEntry{Op: OpSettleError, Error: err.Error(), Final: final}, id, SettlementPending, SettlementBroadcast)
+}
+
+// RecordSettlementConfirmed records the block that confirmed the
+// transaction paying distribution id
+func (t *Treasury) RecordSettlementConfirmed(id int, blockHash string, height uint32) (*Distribution, error) {
+	return t.settlement(LedgerEntry{Op: OpSettleConfirm, BlockHash: blockHash, Height: height}, id, SettlementBroadcast)
+}
+
+// RetrySettlement returns failed distribution id to pending, dropping its
+// transaction so the engine builds a new one. The old transaction is kept
+// as ReplacedTx: the new one spends its inputs again, so at most one of the
+// two can confirm.
+func (t *Treasury) RetrySettlement(id int) (*Distribution, error) {
+	return t.settlement(LedgerEntry{Op: OpSettleRetry}, id, SettlementFailed)
+}
+
+// settlement records entry for distribution id when it is in one of states
+func (t *Treasury) settlement(entry LedgerEntry, id int, states ...string) (*Distribution, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, err := t.distribution(id)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, s := range states {
+		allowed = allowed || d.Settlement == s
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: distribution %d is %q", ErrSettlementState, id, d.Settlement)
+	}
+	if entry.Op == OpSettleTx {
+		if err := t.halted(); err != nil {
+			return nil, err
+		}
+	}
+
+	entry.DistributionID = id
+	entry.Timestamp = time.Now()
+	if err := t.writeAhead(entry); err != nil {
+		return nil, err
+	}
+	t.applySettlement(entry)
+	t.checkpoint()
+	dist := *d
+	if t.onEvent != nil {
+		t.onEvent(EventDistribution, dist)
+	}
+	return &dist, nil
+}
+
+func (t *Treasury) applySettlement(entry LedgerEntry) {
+	d, err := t.distribution(entry.DistributionID)
+	if err != nil || d.Settlement == "" {
+		return
+	}
+	switch entry.Op {
+	case OpSettleTx:
+		d.Settlement = SettlementBroadcast
+		d.TxHash = entry.TxHash
+		d.RawTx = entry.RawTx
+	case OpSettleError:
+		d.Attempts++
+		d.LastError = entry.Error
+		if entry.Final {
+			d.Settlement = SettlementFailed
+		}
+	case OpSettleConfirm:
+		d.Settlement = SettlementConfirmed
+		d.BlockHash = entry.BlockHash
+		d.BlockHeight = entry.Height
+		d.SettledAt = entry.Timestamp
+	case OpSettleRetry:
+		d.Settlement = SettlementPending
+		d.Attempts = 0
+		if d.RawTx != "" {
+			d.ReplacedTx = d.RawTx
+		}
+		d.TxHash = ""
+		d.RawTx = ""
+	}
+}
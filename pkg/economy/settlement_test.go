@@ -0,0 +1,89 @@
+package economy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSettlement(t *testing.T) {
+	dir := t.TempDir()
+	treasury, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	treasury.ProcessForge("bcrt1pminer")
+
+	// Without settlement a distribution only has a mock hash
+	mock, err := treasury.Distribute(Coin, "bcrt1precipient", "grant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mock.Settlement != "" || !strings.HasPrefix(mock.TxHash, "0x") {
+		t.Errorf("Unsettled distribution = %+v", mock)
+	}
+	if _, err := treasury.RecordSettlementTx(mock.ID, "ab", "00"); !errors.Is(err, ErrSettlementState) {
+		t.Errorf("Expected ErrSettlementState for an unsettled distribution, got %v", err)
+	}
+
+	treasury.EnableSettlement()
+	dist, err := treasury.Distribute(2*Coin, "bcrt1precipient", "grant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dist.Settlement != SettlementPending || dist.TxHash != "" {
+		t.Errorf("Distribution = %+v, want pending without a hash", dist)
+	}
+	if pending := treasury.PendingSettlements(); len(pending) != 1 || pending[0].ID != dist.ID {
+		t.Errorf("PendingSettlements() = %+v", pending)
+	}
+	if _, err := treasury.RecordSettlementConfirmed(dist.ID, "00ff", 800000); !errors.Is(err, ErrSettlementState) {
+		t.Errorf("Expected ErrSettlementState confirming an unbroadcast settlement, got %v", err)
+	}
+	if _, err := treasury.RecordSettlementTx(99, "ab", "00"); !errors.Is(err, ErrUnknownDistribution) {
+		t.Errorf("Expected ErrUnknownDistribution, got %v", err)
+	}
+
+	// Broadcast, fail for good, retry, then confirm
+	if _, err := treasury.RecordSettlementTx(dist.ID, "aa", "0100"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := treasury.RecordSettlementError(dist.ID, errors.New("node down"), false); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := treasury.RecordSettlementError(dist.ID, errors.New("rejected"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Settlement != SettlementFailed || failed.Attempts != 2 || failed.LastError != "rejected" {
+		t.Errorf("Failed settlement = %+v", failed)
+	}
+	if len(treasury.PendingSettlements()) != 0 {
+		t.Error("A failed settlement is still pending")
+	}
+	retried, err := treasury.RetrySettlement(dist.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.Settlement != SettlementPending || retried.TxHash != "" || retried.RawTx != "" || retried.ReplacedTx != "0100" || retried.Attempts != 0 {
+		t.Errorf("Retried settlement = %+v", retried)
+	}
+	treasury.RecordSettlementTx(dist.ID, "bb", "0200")
+	if _, err := treasury.RecordSettlementConfirmed(dist.ID, "00ff", 800000); err != nil {
+		t.Fatal(err)
+	}
+
+	treasury.Close()
+	reopened, err := OpenTreasury(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	got, err := reopened.GetDistribution(dist.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Settlement != SettlementConfirmed || got.TxHash != "bb" || got.BlockHeight != 800000 || got.SettledAt.IsZero() {
+		t.Errorf("Distribution after reopening = %+v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package economy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxBeneficiaries bounds the number of payout addresses in a reward split
+const MaxBeneficiaries = 16
+
+// splitTolerance absorbs float rounding when percentages are summed
+const splitTolerance = 1e-9
+
+// ErrInvalidSplit indicates a reward split that cannot be applied
+var ErrInvalidSplit = errors.New("invalid reward split")
+
+// Beneficiary is one payout address of a reward split and its share of the
+// miner reward in percent
+type Beneficiary struct {
+	Address string  `json:"address"`
+	Percent float64 `json:"percent"`
+}
+
+// RewardSplit divides a miner reward between payout addresses, e.g. 90% to
+// the operator and 10% to the hosting provider. The first beneficiary is the
+// forge's miner address.
+type RewardSplit []Beneficiary
+
+// Payout is the amount of a forge's miner reward credited to one beneficiary
+type Payout struct {
+	Address string
+	Percent float64
+	Amount  Amount
+}
+
+// ParseRewardSplit parses a comma-separated list of address:percent pairs,
+// e.g. "bc1pop...:90,bc1phost...:10", and validates the result
+func ParseRewardSplit(s string) (RewardSplit, error) {
+	var split RewardSplit
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		i := strings.LastIndex(part, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q is not address:percent", ErrInvalidSplit, part)
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(part[i+1:], "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad percentage in %q", ErrInvalidSplit, part)
+		}
+		split = append(split, Beneficiary{Address: strings.TrimSpace(part[:i]), Percent: percent})
+	}
+	if err := split.Validate(); err != nil {
+		return nil, err
+	}
+	return split, nil
+}
+
+// Validate checks that the split names between 1 and MaxBeneficiaries
+// distinct addresses with positive percentages adding up to 100
+func (s RewardSplit) Validate() error {
+	if len(s) == 0 {
+		return fmt.Errorf("%w: no beneficiaries", ErrInvalidSplit)
+	}
+	if len(s) > MaxBeneficiaries {
+		return fmt.Errorf("%w: %d beneficiaries, at most %d allowed", ErrInvalidSplit, len(s), MaxBeneficiaries)
+	}
+	seen := make(map[string]bool, len(s))
+	total := 0.0
+	for _, b := range s {
+		if b.Address == "" {
+			return fmt.Errorf("%w: empty address", ErrInvalidSplit)
+		}
+		if seen[b.Address] {
+			return fmt.Errorf("%w: duplicate address %s", ErrInvalidSplit, b.Address)
+		}
+		seen[b.Address] = true
+		if !(b.Percent > 0 && b.Percent <= 100) {
+			return fmt.Errorf("%w: %s gets %v%%", ErrInvalidSplit, b.Address, b.Percent)
+		}
+		total += b.Percent
+	}
+	if math.Abs(total-100) > splitTolerance {
+		return fmt.Errorf("%w: percentages add up to %v%%, not 100%%", ErrInvalidSplit, total)
+	}
+	return nil
+}
+
+// Apply divides amount between the beneficiaries, rounding each share to
+// the nearest exs-satoshi. The last beneficiary gets the remainder so the
+// payouts always add up to amount exactly.
+func (s RewardSplit) Apply(amount Amount) []Payout {
+	payouts := make([]Payout, len(s))
+	remaining := amount
+	for i, b := range s {
+		share := min(Amount(math.Round(float64(amount)*b.Percent/100)), remaining)
+		if i == len(s)-1 {
+			share = remaining
+		}
+		remaining -= share
+		payouts[i] = Payout{Address: b.Address, Percent: b.Percent, Amount: share}
+	}
+	return payouts
+}
+
+// String formats the split as ParseRewardSplit accepts it
+func (s RewardSplit) String() string {
+	parts := make([]string, len(s))
+	for i, b := range s {
+		parts[i] = b.Address + ":" + strconv.FormatFloat(b.Percent, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
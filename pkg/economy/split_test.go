@@ -0,0 +1,55 @@
+package economy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRewardSplit(t *testing.T) {
+	split, err := ParseRewardSplit("bc1poperator:90, bc1phost:10%")
+	if err != nil {
+		t.Fatalf("ParseRewardSplit() error = %v", err)
+	}
+	want := RewardSplit{{Address: "bc1poperator", Percent: 90}, {Address: "bc1phost", Percent: 10}}
+	if len(split) != len(want) || split[0] != want[0] || split[1] != want[1] {
+		t.Errorf("ParseRewardSplit() = %+v, want %+v", split, want)
+	}
+	if split.String() != "bc1poperator:90,bc1phost:10" {
+		t.Errorf("String() = %q", split.String())
+	}
+
+	for _, s := range []string{
+		"",
+		"bc1poperator",
+		"bc1poperator:ninety",
+		"bc1poperator:90",
+		"bc1poperator:90,bc1phost:20",
+		"bc1poperator:50,bc1poperator:50",
+		"bc1poperator:110,bc1phost:-10",
+		":100",
+	} {
+		if _, err := ParseRewardSplit(s); !errors.Is(err, ErrInvalidSplit) {
+			t.Errorf("ParseRewardSplit(%q) error = %v, want ErrInvalidSplit", s, err)
+		}
+	}
+}
+
+func TestRewardSplitApply(t *testing.T) {
+	split := RewardSplit{{"bc1pa", 33.3}, {"bc1pb", 33.3}, {"bc1pc", 33.4}}
+	if err := split.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	amount := 85 * Coin / 2
+	payouts := split.Apply(amount)
+	var total Amount
+	for _, p := range payouts {
+		total += p.Amount
+	}
+	if total != amount {
+		t.Errorf("Payouts add up to %s, want 42.5", total)
+	}
+	if payouts[0].Amount != 1_415_250_000 || payouts[0].Address != "bc1pa" {
+		t.Errorf("First payout = %+v", payouts[0])
+	}
+}
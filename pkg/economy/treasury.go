@@ -1,6 +1,15 @@
 // Package economy implements the Excalibur $EXS treasury management and fee collection.
 //
+// Treasury is the single treasury engine and the constants below the single
+// source of tokenomics: the HTTP treasury, the gRPC API, Rosetta, the demo
+// and the miners all read them rather than keeping their own copies. Each
+// forge mints ForgeReward, of which TreasuryAllocation (15%) goes to the
+// treasury and MinerReward to the miner. The King's Tithe is a separate,
+// optional 1% TreasuryFee on minted EXS, not an alternative to the 15%
+// allocation.
+//
 // This module handles:
+// - Forge rewards following the emission schedule (see emission.go)
 // - 12-month rolling treasury release with CLTV time-locks
 // - Treasury allocation split into 3 mini-outputs (2.5 EXS each)
 // - Staggered vesting at 4,320-block intervals
@@ -13,66 +22,112 @@
 package economy
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
 )
 
+// ErrInsufficientFunds indicates a payout larger than the treasury balance
+var ErrInsufficientFunds = errors.New("insufficient treasury balance")
+
 // Constants for fee and reward calculations
 const (
-	ForgeReward         = 50.0   // 50 $EXS per forge (block reward)
-	TreasuryPercent     = 0.15   // 15% of block reward goes to treasury
-	TreasuryAllocation  = 7.5    // 7.5 $EXS per block (15% of 50 EXS)
-	TreasuryFeePercent  = 0.01   // 1% treasury fee (King's Tithe model)
-	ForgeFeesBTC        = 0.0001 // 0.0001 BTC per forge
-	ForgeFeeSats        = 10000  // 10,000 satoshis per forge (= ForgeFeesBTC * 1e8)
-	TotalSupplyCap      = 21000000
+	ForgeReward         Amount = 50 * Coin // 50 $EXS per forge (block reward)
+	TreasuryPercent            = 15        // 15% of block reward goes to treasury
+	TreasuryAllocation         = ForgeReward * TreasuryPercent / 100 // 7.5 $EXS per block (15% of 50 EXS)
+	MinerReward                = ForgeReward - TreasuryAllocation    // 42.5 $EXS per block to the miner
+	TreasuryFeePercent         = 1      // 1% treasury fee (King's Tithe model)
+	ForgeFeesBTC               = 0.0001 // 0.0001 BTC per forge
+	ForgeFeeSats               = 10000  // 10,000 satoshis per forge (= ForgeFeesBTC * 1e8)
+	TotalSupplyCap      Amount = 21000000 * Coin
 
 	// 12-month rolling treasury release constants
 	MiniOutputCount     = 3     // Split treasury into 3 mini-outputs
-	MiniOutputAmount    = 2.5   // 2.5 EXS per mini-output (7.5 / 3)
+	MiniOutputAmount    = TreasuryAllocation / MiniOutputCount // 2.5 EXS per mini-output (7.5 / 3)
 	BlockInterval       = 4320  // 4,320 blocks ≈ 30 days (at 10 min/block)
 	
 	// CLTV lock heights for mini-outputs (staggered release)
 	MiniOutput1Delay    = 0           // Immediately available
-	MiniOutput2Delay    = 4320        // Lock for ~1 month
-	MiniOutput3Delay    = 8640        // Lock for ~2 months
+	MiniOutput2Delay    = BlockInterval     // Lock for ~1 month
+	MiniOutput3Delay    = 2 * BlockInterval // Lock for ~2 months
 )
 
 // TreasuryMiniOutput represents a time-locked mini-output with CLTV script
 type TreasuryMiniOutput struct {
 	OutputID        int       // Unique output identifier
 	BlockHeight     uint32    // Block height when created
-	Amount          float64   // Amount in EXS (2.5 EXS)
+	Amount          Amount    // Amount in EXS (2.5 EXS)
 	LockHeight      uint32    // Block height when spendable (CLTV lock)
 	UnlockHeight    uint32    // Same as LockHeight (for clarity)
 	IsSpendable     bool      // Whether currently spendable
 	IsSpent         bool      // Whether already spent
-	CLTVScript      []byte    // Bitcoin CLTV script bytes
-	ScriptAddress   string    // Human-readable script address
+	CLTVScript      []byte    // CLTV witness script, empty for an immediately spendable output
+	PkScript        []byte    // Output script paying the treasury key
+	ScriptAddress   string    // Address of PkScript
 	CreatedAt       time.Time // Timestamp when created
 }
 
 // Treasury manages the protocol treasury and fee collection
 type Treasury struct {
 	mu                 sync.RWMutex
-	balance            float64
-	totalFeesCollected float64
+	balance            Amount
+	totalFeesCollected Amount
 	totalForges        int
-	forgeFeePoolBTC    float64
+	totalMinted        Amount
+	forgeFeePool       btcutil.Amount
 	distributions      []Distribution
 	miniOutputs        []TreasuryMiniOutput // All treasury mini-outputs
 	currentBlockHeight uint32               // Current blockchain height
+	forges             []*ForgeResult       // Forge history in processing order
+	addressBalances    map[string]Amount    // Ledger of EXS credited per address
+	ledger             *Ledger              // Write-ahead log, nil when in-memory only
+	ledgerErr          error                // Last ledger write failure
+	haltCheck          func() error         // Emergency halt, refusing forges and distributions
+	onEvent            func(typ string, data any) // Receives forge, distribution and balance events
+	claimPolicy        ClaimPolicy                // Reward and caps for ClaimReward
+	claims             []Claim                    // Paid claims in order
+	claimedProofs      map[string]int             // Claim ID by proof address, preventing double claims
+	claimTotals        map[string]claimTotal      // Claims made per claimant address
+	signers            map[string]bool            // Distribution signers' x-only keys
+	threshold          int                        // Approvals that execute a proposal
+	proposalTTL        time.Duration              // How long a proposal collects approvals
+	proposals          []*Proposal                // Distribution proposals in order
+	proposalIndex      map[string]*Proposal       // Proposals by ID
+	keyHash            []byte                     // Treasury key hash mini-outputs pay
+	scriptNet          *chaincfg.Params           // Network of mini-output addresses
+	treasuryKey        *btcec.PublicKey           // Treasury key forge payments are made to
+	paymentPolicy      PaymentPolicy              // Whether forges and claims need a payment
+	payments           []*ForgePayment            // Forge fee payments in order
+	paymentIndex       map[string]*ForgePayment   // Payments by ID
+	settle             bool                       // Whether distributions are settled on Bitcoin
+	revenue            []*RevenueStream           // Revenue credited per stream, see revenue.go
 }
 
 // Distribution represents a treasury distribution event
 type Distribution struct {
 	ID          int
 	Timestamp   time.Time
-	Amount      float64
+	Amount      Amount
 	Recipient   string
 	Purpose     string
 	TxHash      string
+	ProposalID  string `json:",omitempty"` // Proposal that approved the distribution, if any
+
+	// Settlement on Bitcoin, see settlement.go. Empty for distributions
+	// made while settlement was off, whose TxHash is a mock.
+	Settlement  string    `json:",omitempty"`
+	Attempts    int       `json:",omitempty"` // Failed attempts to build or broadcast
+	LastError   string    `json:",omitempty"`
+	RawTx       string    `json:",omitempty"` // Signed transaction, once built
+	ReplacedTx  string    `json:",omitempty"` // Transaction a retry dropped, whose inputs the next one spends
+	BlockHash   string    `json:",omitempty"`
+	BlockHeight uint32    `json:",omitempty"`
+	SettledAt   time.Time `json:",omitempty"`
 }
 
 // ForgeResult represents the outcome of a successful forge
@@ -80,11 +135,13 @@ type ForgeResult struct {
 	ForgeID           int
 	BlockHeight       uint32
 	MinerAddress      string
-	TotalReward       float64
-	MinerReward       float64
-	TreasuryAllocation float64
+	TotalReward       Amount
+	MinerReward       Amount
+	TreasuryAllocation Amount
 	TreasuryMiniOutputs []TreasuryMiniOutput // 3 mini-outputs with CLTV locks
-	ForgeFeeInBTC     float64
+	ForgeFeeInBTC     float64 // ForgeFeeSats in BTC, for display
+	Payouts           []Payout // Miner reward per beneficiary, nil for unsplit forges
+	PaymentID         string   `json:",omitempty"` // Forge fee payment used, if payments are required
 	Timestamp         time.Time
 }
 
@@ -94,10 +151,21 @@ func NewTreasury() *Treasury {
 		balance:            0,
 		totalFeesCollected: 0,
 		totalForges:        0,
-		forgeFeePoolBTC:    0,
+		forgeFeePool:       0,
 		distributions:      make([]Distribution, 0),
 		miniOutputs:        make([]TreasuryMiniOutput, 0),
 		currentBlockHeight: 0,
+		forges:             make([]*ForgeResult, 0),
+		addressBalances:    make(map[string]Amount),
+		claimPolicy:        DefaultClaimPolicy(),
+		claimedProofs:      make(map[string]int),
+		claimTotals:        make(map[string]claimTotal),
+		proposalTTL:        DefaultProposalTTL,
+		proposalIndex:      make(map[string]*Proposal),
+		keyHash:            placeholderKeyHash,
+		scriptNet:          &chaincfg.MainNetParams,
+		paymentPolicy:      PaymentPolicy{MinConfirmations: DefaultPaymentConfirmations},
+		paymentIndex:       make(map[string]*ForgePayment),
 	}
 }
 
@@ -105,6 +173,21 @@ func NewTreasury() *Treasury {
 func (t *Treasury) SetBlockHeight(height uint32) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	if t.writeAhead(LedgerEntry{Op: OpSetHeight, Height: height}) != nil {
+		return
+	}
+	t.applySetHeight(height)
+	t.checkpoint()
+}
+
+// GetBlockHeight returns the current blockchain height
+func (t *Treasury) GetBlockHeight() uint32 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.currentBlockHeight
+}
+
+func (t *Treasury) applySetHeight(height uint32) {
 	t.currentBlockHeight = height
 	
 	// Update spendable status of mini-outputs
@@ -115,48 +198,142 @@ func (t *Treasury) SetBlockHeight(height uint32) {
 	}
 }
 
-// ProcessForge processes a successful forge and creates treasury mini-outputs
+// SetHaltCheck installs check, consulted before every forge and
+// distribution; while it returns an error they are refused with it
+func (t *Treasury) SetHaltCheck(check func() error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.haltCheck = check
+}
+
+// halted returns the emergency halt error, if any; callers must hold t.mu
+func (t *Treasury) halted() error {
+	if t.haltCheck == nil {
+		return nil
+	}
+	return t.haltCheck()
+}
+
+// ProcessForge processes a successful forge and creates treasury mini-outputs.
+// It returns nil if the treasury is halted, the supply cap has been reached
+// or the forge could not be written to the ledger.
 func (t *Treasury) ProcessForge(minerAddress string) *ForgeResult {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	if t.halted() != nil || t.totalMinted >= TotalSupplyCap {
+		return nil
+	}
+	paymentID, err := t.requirePayment(minerAddress)
+	if err != nil {
+		return nil
+	}
+	entry := LedgerEntry{Op: OpForge, Address: minerAddress, PaymentID: paymentID, Timestamp: time.Now()}
+	t.treasuryKeyEntry(&entry)
+	if t.writeAhead(entry) != nil {
+		return nil
+	}
+	result := t.applyForge(entry)
+	t.checkpoint()
+	t.notifyForge(result)
+	return result
+}
+
+// ProcessForgeSplit processes a forge whose miner reward is divided between
+// the beneficiaries of split. The first beneficiary is recorded as the miner
+// and every beneficiary's address balance is credited with its share.
+func (t *Treasury) ProcessForgeSplit(split RewardSplit) (*ForgeResult, error) {
+	if err := split.Validate(); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.halted(); err != nil {
+		return nil, err
+	}
+	if t.totalMinted >= TotalSupplyCap {
+		return nil, ErrSupplyCap
+	}
+	paymentID, err := t.requirePayment(split[0].Address)
+	if err != nil {
+		return nil, err
+	}
+	entry := LedgerEntry{
+		Op:        OpForge,
+		Address:   split[0].Address,
+		Split:     append(RewardSplit(nil), split...),
+		PaymentID: paymentID,
+		Timestamp: time.Now(),
+	}
+	t.treasuryKeyEntry(&entry)
+	if err := t.writeAhead(entry); err != nil {
+		return nil, err
+	}
+	result := t.applyForge(entry)
+	t.checkpoint()
+	t.notifyForge(result)
+	return result, nil
+}
+
+func (t *Treasury) applyForge(entry LedgerEntry) *ForgeResult {
+	minerAddress, split, timestamp := entry.Address, entry.Split, entry.Timestamp
+	reward := ForgeEmission(uint64(t.totalForges), t.totalMinted)
 	t.totalForges++
+	t.totalMinted += reward
 	// Note: currentBlockHeight should be set externally via SetBlockHeight
 	// before calling ProcessForge to match the actual blockchain state
 
-	// Calculate distribution
-	// New model: Direct 15% treasury allocation (not 1% fee)
-	treasuryAllocation := ForgeReward * TreasuryPercent  // 7.5 EXS
-	minerReward := ForgeReward - treasuryAllocation      // 42.5 EXS
+	// Calculate distribution: the 15% allocation, not the 1% tithe
+	treasuryAllocation := TreasuryShare(reward) // 7.5 EXS before the first halving
+	minerReward := reward - treasuryAllocation  // 42.5 EXS before the first halving
 
 	// Create 3 mini-outputs with staggered CLTV locks
-	miniOutputs := t.createTreasuryMiniOutputs(t.currentBlockHeight)
+	keyHash, net := entryTreasuryKey(entry)
+	miniOutputs := t.createTreasuryMiniOutputs(t.currentBlockHeight, treasuryAllocation, keyHash, net, timestamp)
 
 	// Update treasury balance (total of all mini-outputs)
 	t.balance += treasuryAllocation
 	t.totalFeesCollected += treasuryAllocation
-	t.forgeFeePoolBTC += ForgeFeesBTC
+	t.forgeFeePool += ForgeFeeSats
 
 	// Store mini-outputs
 	t.miniOutputs = append(t.miniOutputs, miniOutputs...)
 
+	// Credit the miner reward, divided between beneficiaries if split
+	var payouts []Payout
+	if len(split) == 0 {
+		t.addressBalances[minerAddress] += minerReward
+	} else {
+		payouts = split.Apply(minerReward)
+		for _, p := range payouts {
+			t.addressBalances[p.Address] += p.Amount
+		}
+	}
+
 	result := &ForgeResult{
 		ForgeID:             t.totalForges,
 		BlockHeight:         t.currentBlockHeight,
 		MinerAddress:        minerAddress,
-		TotalReward:         ForgeReward,
+		TotalReward:         reward,
 		MinerReward:         minerReward,
 		TreasuryAllocation:  treasuryAllocation,
 		TreasuryMiniOutputs: miniOutputs,
 		ForgeFeeInBTC:       ForgeFeesBTC,
-		Timestamp:           time.Now(),
+		Payouts:             payouts,
+		PaymentID:           entry.PaymentID,
+		Timestamp:           timestamp,
 	}
+	t.forges = append(t.forges, result)
+	t.usePayment(entry.PaymentID, result.ForgeID, 0)
 
 	return result
 }
 
-// createTreasuryMiniOutputs creates 3 mini-outputs with CLTV time-locks
-func (t *Treasury) createTreasuryMiniOutputs(blockHeight uint32) []TreasuryMiniOutput {
+// createTreasuryMiniOutputs splits allocation into 3 mini-outputs paying
+// keyHash with CLTV time-locks, the last taking any remainder
+func (t *Treasury) createTreasuryMiniOutputs(blockHeight uint32, allocation Amount, keyHash []byte, net *chaincfg.Params, createdAt time.Time) []TreasuryMiniOutput {
 	// Define the lock delays for each mini-output
 	delays := []uint32{
 		MiniOutput1Delay, // 0 blocks (immediately available)
@@ -165,48 +342,30 @@ func (t *Treasury) createTreasuryMiniOutputs(blockHeight uint32) []TreasuryMiniO
 	}
 
 	miniOutputs := make([]TreasuryMiniOutput, MiniOutputCount)
-	
-	// Mock treasury public key hash (20 bytes) for CLTV script
-	// In production, this would be derived from the actual treasury multisig key
-	treasuryPubKeyHash := make([]byte, 20)
-	for i := range treasuryPubKeyHash {
-		treasuryPubKeyHash[i] = byte(i)
-	}
-	
+	part := allocation / MiniOutputCount
 	for i := 0; i < MiniOutputCount; i++ {
 		unlockHeight := blockHeight + delays[i]
-		
-		// Generate actual Bitcoin CLTV script
-		// Note: This creates a real Bitcoin script but doesn't execute on-chain
-		// Production implementation would integrate with actual Bitcoin node
-		var cltvScript []byte
-		var scriptAddr string
-		
-		// Only create actual script for locked outputs (delays > 0)
-		if delays[i] > 0 {
-			// Use the bitcoin package to build CLTV script (if available)
-			// For now, create a descriptive representation
-			scriptAddr = fmt.Sprintf("CLTV(height=%d, treasury_pubkey_hash=%x, amount=%.1f EXS)", 
-				unlockHeight, treasuryPubKeyHash[:4], MiniOutputAmount)
-			// In production: cltvScript = bitcoin.BuildCLTVScript(unlockHeight, treasuryPubKeyHash)
-			cltvScript = []byte(scriptAddr) // Placeholder for actual script bytes
-		} else {
-			scriptAddr = fmt.Sprintf("Immediate(treasury_pubkey_hash=%x, amount=%.1f EXS)", 
-				treasuryPubKeyHash[:4], MiniOutputAmount)
-			cltvScript = []byte{} // No CLTV for immediately spendable output
+		amount := part
+		if i == MiniOutputCount-1 {
+			amount = allocation - part*(MiniOutputCount-1)
 		}
-		
+
+		// Only locked outputs (delays > 0) get a CLTV script; keyHash is 20
+		// bytes and unlockHeight positive, so building cannot fail
+		pkScript, cltvScript, address, _ := miniOutputScript(keyHash, unlockHeight, delays[i] > 0, net)
+
 		miniOutputs[i] = TreasuryMiniOutput{
 			OutputID:      len(t.miniOutputs) + i + 1,
 			BlockHeight:   blockHeight,
-			Amount:        MiniOutputAmount,
+			Amount:        amount,
 			LockHeight:    unlockHeight,
 			UnlockHeight:  unlockHeight,
 			IsSpendable:   delays[i] == 0, // First output is immediately spendable
 			IsSpent:       false,
 			CLTVScript:    cltvScript,
-			ScriptAddress: scriptAddr,
-			CreatedAt:     time.Now(),
+			PkScript:      pkScript,
+			ScriptAddress: address,
+			CreatedAt:     createdAt,
 		}
 	}
 
@@ -214,18 +373,18 @@ func (t *Treasury) createTreasuryMiniOutputs(blockHeight uint32) []TreasuryMiniO
 }
 
 // GetBalance returns the current treasury balance
-func (t *Treasury) GetBalance() float64 {
+func (t *Treasury) GetBalance() Amount {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	return t.balance
 }
 
 // GetSpendableBalance returns the balance of spendable (unlocked) mini-outputs
-func (t *Treasury) GetSpendableBalance() float64 {
+func (t *Treasury) GetSpendableBalance() Amount {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	
-	spendable := 0.0
+	var spendable Amount
 	for _, output := range t.miniOutputs {
 		if output.IsSpendable && !output.IsSpent {
 			spendable += output.Amount
@@ -235,11 +394,11 @@ func (t *Treasury) GetSpendableBalance() float64 {
 }
 
 // GetLockedBalance returns the balance of locked (not yet spendable) mini-outputs
-func (t *Treasury) GetLockedBalance() float64 {
+func (t *Treasury) GetLockedBalance() Amount {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	
-	locked := 0.0
+	var locked Amount
 	for _, output := range t.miniOutputs {
 		if !output.IsSpendable && !output.IsSpent {
 			locked += output.Amount
@@ -287,13 +446,41 @@ func (t *Treasury) GetLockedMiniOutputs() []TreasuryMiniOutput {
 	return locked
 }
 
+// GetAddressBalance returns the EXS credited to an address by forges and distributions
+func (t *Treasury) GetAddressBalance(address string) Amount {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.addressBalances[address]
+}
+
+// GetForgesAtHeight returns the forges processed at a block height
+func (t *Treasury) GetForgesAtHeight(height uint32) []ForgeResult {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	forges := make([]ForgeResult, 0)
+	for _, forge := range t.forges {
+		if forge.BlockHeight == height {
+			forges = append(forges, *forge)
+		}
+	}
+	return forges
+}
+
 // GetTotalFeesCollected returns the total fees collected
-func (t *Treasury) GetTotalFeesCollected() float64 {
+func (t *Treasury) GetTotalFeesCollected() Amount {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	return t.totalFeesCollected
 }
 
+// GetTotalMinted returns the EXS minted by all forges
+func (t *Treasury) GetTotalMinted() Amount {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.totalMinted
+}
+
 // GetTotalForges returns the total number of forges processed
 func (t *Treasury) GetTotalForges() int {
 	t.mu.RLock()
@@ -302,35 +489,63 @@ func (t *Treasury) GetTotalForges() int {
 }
 
 // GetForgeFeePool returns the accumulated BTC forge fees
-func (t *Treasury) GetForgeFeePool() float64 {
+func (t *Treasury) GetForgeFeePool() btcutil.Amount {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.forgeFeePoolBTC
+	return t.forgeFeePool
 }
 
 // Distribute distributes funds from the treasury
-func (t *Treasury) Distribute(amount float64, recipient string, purpose string) (*Distribution, error) {
+func (t *Treasury) Distribute(amount Amount, recipient string, purpose string) (*Distribution, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	if err := t.halted(); err != nil {
+		return nil, err
+	}
 	if amount > t.balance {
-		return nil, fmt.Errorf("insufficient treasury balance: have %.2f, need %.2f", t.balance, amount)
+		return nil, fmt.Errorf("%w: have %s EXS, need %s EXS", ErrInsufficientFunds, t.balance, amount)
+	}
+
+	entry := LedgerEntry{
+		Op:        OpDistribute,
+		Amount:    amount,
+		Address:   recipient,
+		Purpose:   purpose,
+		Timestamp: time.Now(),
+	}
+	t.markSettlement(&entry)
+	if err := t.writeAhead(entry); err != nil {
+		return nil, err
+	}
+	dist := t.applyDistribution(entry)
+	t.checkpoint()
+	if t.onEvent != nil {
+		t.onEvent(EventDistribution, dist)
 	}
+	t.notifyBalance(recipient)
+	return &dist, nil
+}
 
-	t.balance -= amount
+func (t *Treasury) applyDistribution(entry LedgerEntry) Distribution {
+	t.balance -= entry.Amount
 
 	dist := Distribution{
 		ID:        len(t.distributions) + 1,
-		Timestamp: time.Now(),
-		Amount:    amount,
-		Recipient: recipient,
-		Purpose:   purpose,
-		TxHash:    fmt.Sprintf("0x%x", time.Now().UnixNano()), // Mock tx hash
+		Timestamp: entry.Timestamp,
+		Amount:    entry.Amount,
+		Recipient: entry.Address,
+		Purpose:    entry.Purpose,
+		TxHash:     entry.TxHash,
+		ProposalID: entry.ProposalID,
+	}
+	if entry.Settle {
+		dist.Settlement = SettlementPending
 	}
 
 	t.distributions = append(t.distributions, dist)
-
-	return &dist, nil
+	t.addressBalances[entry.Address] += entry.Amount
+	return dist
 }
 
 // GetDistributions returns all distribution history
@@ -349,13 +564,12 @@ func (t *Treasury) GetStats() map[string]interface{} {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	totalMinted := float64(t.totalForges) * ForgeReward
-	percentageMinted := (totalMinted / TotalSupplyCap) * 100
+	totalMinted := t.totalMinted
+	percentageMinted := float64(totalMinted) / float64(TotalSupplyCap) * 100
+	nextReward := ForgeEmission(uint64(t.totalForges), totalMinted)
 	
 	// Calculate mini-output statistics
-	spendableBalance := 0.0
-	lockedBalance := 0.0
-	spentBalance := 0.0
+	var spendableBalance, lockedBalance, spentBalance Amount
 	
 	for _, output := range t.miniOutputs {
 		if output.IsSpent {
@@ -375,34 +589,36 @@ func (t *Treasury) GetStats() map[string]interface{} {
 		"total_fees_collected":   t.totalFeesCollected,
 		"total_forges":           t.totalForges,
 		"current_block_height":   t.currentBlockHeight,
-		"forge_fee_pool_btc":     t.forgeFeePoolBTC,
+		"forge_fee_pool_btc":     t.forgeFeePool.ToBTC(),
 		"total_minted":           totalMinted,
 		"percentage_minted":      percentageMinted,
 		"supply_cap":             TotalSupplyCap,
-		"forge_reward":           ForgeReward,
-		"treasury_allocation":    TreasuryAllocation,
-		"treasury_percent":       TreasuryPercent * 100,
+		"forge_reward":           nextReward,
+		"treasury_allocation":    TreasuryShare(nextReward),
+		"treasury_percent":       TreasuryPercent,
 		"distributions_count":    len(t.distributions),
 		"mini_outputs_total":     len(t.miniOutputs),
-		"mini_output_amount":     MiniOutputAmount,
+		"mini_output_amount":     TreasuryShare(nextReward) / MiniOutputCount,
 		"mini_outputs_per_block": MiniOutputCount,
 		"block_interval":         BlockInterval,
+		"halving_interval":       HalvingInterval,
+		"tail_emission":          TailEmission,
 	}
 }
 
 // CalculateRuneDistribution calculates the $EXS Rune distribution
 // based on the tokenomics model (60% PoF, 15% Treasury, 20% Liquidity, 5% Airdrop)
-func (t *Treasury) CalculateRuneDistribution() map[string]float64 {
+func (t *Treasury) CalculateRuneDistribution() map[string]Amount {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	totalMinted := float64(t.totalForges) * ForgeReward
+	totalMinted := t.totalMinted
 
-	return map[string]float64{
-		"proof_of_forge":  totalMinted * 0.60,
-		"treasury":        totalMinted * 0.15,
-		"liquidity":       totalMinted * 0.20,
-		"airdrop":         totalMinted * 0.05,
+	return map[string]Amount{
+		"proof_of_forge":  totalMinted * 60 / 100,
+		"treasury":        totalMinted * 15 / 100,
+		"liquidity":       totalMinted * 20 / 100,
+		"airdrop":         totalMinted * 5 / 100,
 		"total_minted":    totalMinted,
 	}
 }
@@ -412,8 +628,7 @@ func (t *Treasury) EstimateTimeToSupplyCap(forgesPerDay float64) (days float64,
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	totalPossibleForges := int(TotalSupplyCap / ForgeReward)
-	forgesRemaining = totalPossibleForges - t.totalForges
+	forgesRemaining = int(RemainingForges(uint64(t.totalForges), t.totalMinted))
 
 	if forgesPerDay > 0 {
 		days = float64(forgesRemaining) / forgesPerDay
@@ -432,30 +647,35 @@ func (t *Treasury) PrintReport() {
 	fmt.Println("   12-Month Rolling Release with CLTV Time-Locks")
 	fmt.Println("═══════════════════════════════════════════════════")
 	fmt.Printf("Block Height:           %d\n", stats["current_block_height"])
-	fmt.Printf("Treasury Balance:       %.2f $EXS\n", stats["treasury_balance"])
-	fmt.Printf("  ├─ Spendable:         %.2f $EXS\n", stats["spendable_balance"])
-	fmt.Printf("  ├─ Locked (CLTV):     %.2f $EXS\n", stats["locked_balance"])
-	fmt.Printf("  └─ Spent:             %.2f $EXS\n", stats["spent_balance"])
-	fmt.Printf("Total Fees Collected:   %.2f $EXS\n", stats["total_fees_collected"])
+	fmt.Printf("Treasury Balance:       %.2f $EXS\n", stats["treasury_balance"].(Amount).EXS())
+	fmt.Printf("  ├─ Spendable:         %.2f $EXS\n", stats["spendable_balance"].(Amount).EXS())
+	fmt.Printf("  ├─ Locked (CLTV):     %.2f $EXS\n", stats["locked_balance"].(Amount).EXS())
+	fmt.Printf("  └─ Spent:             %.2f $EXS\n", stats["spent_balance"].(Amount).EXS())
+	fmt.Printf("Total Fees Collected:   %.2f $EXS\n", stats["total_fees_collected"].(Amount).EXS())
 	fmt.Printf("Total Forges:           %d\n", stats["total_forges"])
 	fmt.Printf("Forge Fee Pool:         %.8f BTC\n", stats["forge_fee_pool_btc"])
 	fmt.Printf("Total Minted:           %.2f $EXS (%.2f%%)\n", 
-		stats["total_minted"], stats["percentage_minted"])
+		stats["total_minted"].(Amount).EXS(), stats["percentage_minted"])
 	fmt.Println("───────────────────────────────────────────────────")
 	fmt.Println("Treasury Mini-Outputs (CLTV Time-Locked):")
 	fmt.Printf("  Total Mini-Outputs:   %d\n", stats["mini_outputs_total"])
-	fmt.Printf("  Amount per Output:    %.1f $EXS\n", stats["mini_output_amount"])
+	fmt.Printf("  Amount per Output:    %s $EXS\n", stats["mini_output_amount"])
 	fmt.Printf("  Outputs per Block:    %d\n", stats["mini_outputs_per_block"])
 	fmt.Printf("  Lock Intervals:       0, %d, %d blocks\n", BlockInterval, BlockInterval*2)
 	fmt.Println("───────────────────────────────────────────────────")
 	fmt.Println("Distribution Breakdown:")
-	fmt.Printf("  Proof-of-Forge:       %.2f $EXS (60%%)\n", distribution["proof_of_forge"])
-	fmt.Printf("  Treasury:             %.2f $EXS (15%%)\n", distribution["treasury"])
-	fmt.Printf("  Liquidity:            %.2f $EXS (20%%)\n", distribution["liquidity"])
-	fmt.Printf("  Airdrop:              %.2f $EXS (5%%)\n", distribution["airdrop"])
+	fmt.Printf("  Proof-of-Forge:       %.2f $EXS (60%%)\n", distribution["proof_of_forge"].EXS())
+	fmt.Printf("  Treasury:             %.2f $EXS (15%%)\n", distribution["treasury"].EXS())
+	fmt.Printf("  Liquidity:            %.2f $EXS (20%%)\n", distribution["liquidity"].EXS())
+	fmt.Printf("  Airdrop:              %.2f $EXS (5%%)\n", distribution["airdrop"].EXS())
 	fmt.Println("═══════════════════════════════════════════════════")
 }
 
+// TreasuryFee returns the 1% King's Tithe on amount
+func TreasuryFee(amount Amount) Amount {
+	return amount * TreasuryFeePercent / 100
+}
+
 // ProcessForgeFee implements the King's Tithe fee collection system.
 //
 // For every 100 $EXS minted (2 forges at 50 EXS each), this function routes
@@ -469,12 +689,25 @@ func (t *Treasury) PrintReport() {
 //   - treasuryFee: Amount routed to treasury (1% of minted amount)
 //   - forgeFeeInSats: Required BTC deposit in satoshis
 //   - error: Any error encountered during processing
-func (t *Treasury) ProcessForgeFee(mintedAmount float64, requireDeposit bool) (treasuryFee float64, forgeFeeInSats int64, err error) {
+func (t *Treasury) ProcessForgeFee(mintedAmount Amount, requireDeposit bool) (treasuryFee Amount, forgeFeeInSats int64, err error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// Calculate 1% treasury fee
-	treasuryFee = mintedAmount * TreasuryFeePercent
+	if err := t.halted(); err != nil {
+		return 0, 0, err
+	}
+	entry := LedgerEntry{Op: OpForgeFee, Amount: mintedAmount, RequireDeposit: requireDeposit}
+	if err := t.writeAhead(entry); err != nil {
+		return 0, 0, err
+	}
+	treasuryFee, forgeFeeInSats = t.applyForgeFee(mintedAmount, requireDeposit)
+	t.checkpoint()
+	t.notifyBalance()
+	return treasuryFee, forgeFeeInSats, nil
+}
+
+func (t *Treasury) applyForgeFee(mintedAmount Amount, requireDeposit bool) (treasuryFee Amount, forgeFeeInSats int64) {
+	treasuryFee = TreasuryFee(mintedAmount)
 
 	// Update treasury balance
 	t.balance += treasuryFee
@@ -483,12 +716,12 @@ func (t *Treasury) ProcessForgeFee(mintedAmount float64, requireDeposit bool) (t
 	// Handle forge fee deposit requirement
 	if requireDeposit {
 		forgeFeeInSats = ForgeFeeSats
-		t.forgeFeePoolBTC += ForgeFeesBTC
+		t.forgeFeePool += ForgeFeeSats
 	} else {
 		forgeFeeInSats = 0
 	}
 
-	return treasuryFee, forgeFeeInSats, nil
+	return treasuryFee, forgeFeeInSats
 }
 
 // ProcessForgeWithFee is a convenience function that combines ProcessForge and ProcessForgeFee.
@@ -499,9 +732,24 @@ func (t *Treasury) ProcessForgeFee(mintedAmount float64, requireDeposit bool) (t
 // The 1% fee is calculated from the miner's portion (42.5 EXS) and routed to treasury.
 //
 // Returns the standard ForgeResult plus the additional fee information.
-func (t *Treasury) ProcessForgeWithFee(minerAddress string, applyKingsTithe bool) (*ForgeResult, float64, error) {
+func (t *Treasury) ProcessForgeWithFee(minerAddress string, applyKingsTithe bool) (*ForgeResult, Amount, error) {
 	// Process the standard forge
 	result := t.ProcessForge(minerAddress)
+	if result == nil {
+		t.mu.RLock()
+		err := t.halted()
+		if err == nil && t.totalMinted >= TotalSupplyCap {
+			err = ErrSupplyCap
+		}
+		if err == nil {
+			_, err = t.requirePayment(minerAddress)
+		}
+		t.mu.RUnlock()
+		if err == nil {
+			err = t.LedgerErr()
+		}
+		return nil, 0, err
+	}
 
 	if !applyKingsTithe {
 		return result, 0, nil
@@ -515,7 +763,22 @@ func (t *Treasury) ProcessForgeWithFee(minerAddress string, applyKingsTithe bool
 
 	// The King's Tithe is deducted from the miner's reward after initial allocation
 	// This ensures the treasury gets both the 15% allocation AND the 1% tithe
-	result.MinerReward -= kingsTithe
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry := LedgerEntry{Op: OpTithe, ForgeID: result.ForgeID, Address: minerAddress, Amount: kingsTithe}
+	if err := t.writeAhead(entry); err != nil {
+		return result, 0, err
+	}
+	t.applyTithe(result.ForgeID, minerAddress, kingsTithe)
+	t.checkpoint()
+	t.notifyBalance(minerAddress)
 
 	return result, kingsTithe, nil
 }
+
+func (t *Treasury) applyTithe(forgeID int, minerAddress string, kingsTithe Amount) {
+	if forgeID >= 1 && forgeID <= len(t.forges) {
+		t.forges[forgeID-1].MinerReward -= kingsTithe
+	}
+	t.addressBalances[minerAddress] -= kingsTithe
+}
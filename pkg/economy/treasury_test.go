@@ -1,9 +1,25 @@
 package economy
 
 import (
+	"errors"
 	"testing"
 )
 
+func TestTokenomics(t *testing.T) {
+	if MinerReward+TreasuryAllocation != ForgeReward {
+		t.Errorf("Miner reward %s and treasury allocation %s do not add up to %s", MinerReward, TreasuryAllocation, ForgeReward)
+	}
+	if MiniOutputAmount*MiniOutputCount != TreasuryAllocation {
+		t.Errorf("%d mini-outputs of %s do not add up to %s", MiniOutputCount, MiniOutputAmount, TreasuryAllocation)
+	}
+	if MinerReward.String() != "42.5" || TreasuryFee(100*Coin) != Coin {
+		t.Errorf("MinerReward = %s, TreasuryFee(100) = %s", MinerReward, TreasuryFee(100*Coin))
+	}
+	if MiniOutput3Delay != 2*BlockInterval {
+		t.Errorf("Expected the last mini-output to unlock after %d blocks, got %d", 2*BlockInterval, MiniOutput3Delay)
+	}
+}
+
 func TestProcessForge(t *testing.T) {
 	treasury := NewTreasury()
 	treasury.SetBlockHeight(1000)
@@ -20,11 +36,11 @@ func TestProcessForge(t *testing.T) {
 	}
 
 	if result.TotalReward != ForgeReward {
-		t.Errorf("Expected TotalReward to be %.2f, got %.2f", ForgeReward, result.TotalReward)
+		t.Errorf("Expected TotalReward to be %s, got %s", ForgeReward, result.TotalReward)
 	}
 
 	if result.TreasuryAllocation != TreasuryAllocation {
-		t.Errorf("Expected TreasuryAllocation to be %.2f, got %.2f", TreasuryAllocation, result.TreasuryAllocation)
+		t.Errorf("Expected TreasuryAllocation to be %s, got %s", TreasuryAllocation, result.TreasuryAllocation)
 	}
 
 	if len(result.TreasuryMiniOutputs) != MiniOutputCount {
@@ -34,13 +50,13 @@ func TestProcessForge(t *testing.T) {
 	// Verify mini-output amounts
 	for i, output := range result.TreasuryMiniOutputs {
 		if output.Amount != MiniOutputAmount {
-			t.Errorf("Mini-output %d: expected amount %.2f, got %.2f", i, MiniOutputAmount, output.Amount)
+			t.Errorf("Mini-output %d: expected amount %s, got %s", i, MiniOutputAmount, output.Amount)
 		}
 	}
 
 	// Verify treasury balance
 	if treasury.GetBalance() != TreasuryAllocation {
-		t.Errorf("Expected treasury balance %.2f, got %.2f", TreasuryAllocation, treasury.GetBalance())
+		t.Errorf("Expected treasury balance %s, got %s", TreasuryAllocation, treasury.GetBalance())
 	}
 
 	// Verify total forges
@@ -94,7 +110,7 @@ func TestSetBlockHeight(t *testing.T) {
 	// Check locked balance (outputs 2 and 3 are locked)
 	lockedBalance := treasury.GetLockedBalance()
 	if lockedBalance != MiniOutputAmount*2 { // 2 locked outputs
-		t.Errorf("Expected locked balance %.2f, got %.2f", MiniOutputAmount*2, lockedBalance)
+		t.Errorf("Expected locked balance %s, got %s", MiniOutputAmount*2, lockedBalance)
 	}
 
 	// Advance to height where second output unlocks
@@ -105,12 +121,12 @@ func TestSetBlockHeight(t *testing.T) {
 	// Check that second output is now spendable
 	lockedBalance = treasury.GetLockedBalance()
 	if lockedBalance != MiniOutputAmount { // Only 1 locked output remains
-		t.Errorf("Expected locked balance %.2f after unlock, got %.2f", MiniOutputAmount, lockedBalance)
+		t.Errorf("Expected locked balance %s after unlock, got %s", MiniOutputAmount, lockedBalance)
 	}
 
 	spendableBalance := treasury.GetSpendableBalance()
 	if spendableBalance != MiniOutputAmount*2 { // 2 spendable outputs
-		t.Errorf("Expected spendable balance %.2f, got %.2f", MiniOutputAmount*2, spendableBalance)
+		t.Errorf("Expected spendable balance %s, got %s", MiniOutputAmount*2, spendableBalance)
 	}
 }
 
@@ -129,16 +145,16 @@ func TestGetStats(t *testing.T) {
 	}
 
 	expectedBalance := TreasuryAllocation * 2
-	if stats["treasury_balance"].(float64) != expectedBalance {
-		t.Errorf("Expected treasury_balance to be %.2f, got %v", expectedBalance, stats["treasury_balance"])
+	if stats["treasury_balance"].(Amount) != expectedBalance {
+		t.Errorf("Expected treasury_balance to be %s, got %v", expectedBalance, stats["treasury_balance"])
 	}
 
 	if stats["mini_outputs_total"].(int) != 6 { // 2 forges × 3 outputs
 		t.Errorf("Expected 6 mini-outputs, got %v", stats["mini_outputs_total"])
 	}
 
-	if stats["mini_output_amount"].(float64) != MiniOutputAmount {
-		t.Errorf("Expected mini_output_amount to be %.2f, got %v", MiniOutputAmount, stats["mini_output_amount"])
+	if stats["mini_output_amount"].(Amount) != MiniOutputAmount {
+		t.Errorf("Expected mini_output_amount to be %s, got %v", MiniOutputAmount, stats["mini_output_amount"])
 	}
 
 	if stats["block_interval"].(int) != BlockInterval {
@@ -183,13 +199,13 @@ func TestCalculateRuneDistribution(t *testing.T) {
 
 	distribution := treasury.CalculateRuneDistribution()
 
-	expectedTotal := float64(10) * ForgeReward
+	expectedTotal := 10 * ForgeReward
 	if distribution["total_minted"] != expectedTotal {
-		t.Errorf("Expected total_minted %.2f, got %.2f", expectedTotal, distribution["total_minted"])
+		t.Errorf("Expected total_minted %s, got %s", expectedTotal, distribution["total_minted"])
 	}
 
-	if distribution["treasury"] != expectedTotal*0.15 {
-		t.Errorf("Expected treasury allocation %.2f, got %.2f", expectedTotal*0.15, distribution["treasury"])
+	if distribution["treasury"] != expectedTotal*15/100 {
+		t.Errorf("Expected treasury allocation %s, got %s", expectedTotal*15/100, distribution["treasury"])
 	}
 }
 
@@ -197,7 +213,7 @@ func TestProcessForgeFee(t *testing.T) {
 	treasury := NewTreasury()
 	
 	// Test processing 100 EXS minted with deposit requirement
-	mintedAmount := 100.0
+	mintedAmount := 100 * Coin
 	treasuryFee, forgeFeeInSats, err := treasury.ProcessForgeFee(mintedAmount, true)
 	
 	if err != nil {
@@ -205,9 +221,9 @@ func TestProcessForgeFee(t *testing.T) {
 	}
 	
 	// Verify 1% fee
-	expectedFee := mintedAmount * TreasuryFeePercent // 1.0 EXS
+	expectedFee := mintedAmount * TreasuryFeePercent / 100 // 1 EXS
 	if treasuryFee != expectedFee {
-		t.Errorf("Expected treasury fee %.2f, got %.2f", expectedFee, treasuryFee)
+		t.Errorf("Expected treasury fee %s, got %s", expectedFee, treasuryFee)
 	}
 	
 	// Verify forge fee in satoshis
@@ -217,12 +233,12 @@ func TestProcessForgeFee(t *testing.T) {
 	
 	// Verify treasury balance updated
 	if treasury.GetBalance() != expectedFee {
-		t.Errorf("Expected treasury balance %.2f, got %.2f", expectedFee, treasury.GetBalance())
+		t.Errorf("Expected treasury balance %s, got %s", expectedFee, treasury.GetBalance())
 	}
 	
 	// Verify forge fee pool updated
-	if treasury.GetForgeFeePool() != ForgeFeesBTC {
-		t.Errorf("Expected forge fee pool %.8f BTC, got %.8f", ForgeFeesBTC, treasury.GetForgeFeePool())
+	if treasury.GetForgeFeePool() != ForgeFeeSats {
+		t.Errorf("Expected forge fee pool %d sats, got %d", ForgeFeeSats, treasury.GetForgeFeePool())
 	}
 }
 
@@ -230,7 +246,7 @@ func TestProcessForgeFeeWithoutDeposit(t *testing.T) {
 	treasury := NewTreasury()
 	
 	// Test processing without deposit requirement
-	mintedAmount := 50.0
+	mintedAmount := 50 * Coin
 	treasuryFee, forgeFeeInSats, err := treasury.ProcessForgeFee(mintedAmount, false)
 	
 	if err != nil {
@@ -238,9 +254,9 @@ func TestProcessForgeFeeWithoutDeposit(t *testing.T) {
 	}
 	
 	// Verify 1% fee
-	expectedFee := mintedAmount * TreasuryFeePercent // 0.5 EXS
+	expectedFee := mintedAmount * TreasuryFeePercent / 100 // 0.5 EXS
 	if treasuryFee != expectedFee {
-		t.Errorf("Expected treasury fee %.2f, got %.2f", expectedFee, treasuryFee)
+		t.Errorf("Expected treasury fee %s, got %s", expectedFee, treasuryFee)
 	}
 	
 	// Verify no forge fee when deposit not required
@@ -250,7 +266,7 @@ func TestProcessForgeFeeWithoutDeposit(t *testing.T) {
 	
 	// Verify forge fee pool not updated
 	if treasury.GetForgeFeePool() != 0 {
-		t.Errorf("Expected forge fee pool 0 BTC, got %.8f", treasury.GetForgeFeePool())
+		t.Errorf("Expected forge fee pool 0 sats, got %d", treasury.GetForgeFeePool())
 	}
 }
 
@@ -271,22 +287,22 @@ func TestProcessForgeWithFee(t *testing.T) {
 	
 	// Verify King's Tithe is 1% of the original miner reward (42.5 EXS)
 	originalMinerReward := ForgeReward - TreasuryAllocation // 42.5 EXS
-	expectedKingsTithe := originalMinerReward * TreasuryFeePercent // 0.425 EXS
+	expectedKingsTithe := originalMinerReward * TreasuryFeePercent / 100 // 0.425 EXS
 	
 	if kingsTithe != expectedKingsTithe {
-		t.Errorf("Expected King's Tithe %.3f, got %.3f", expectedKingsTithe, kingsTithe)
+		t.Errorf("Expected King's Tithe %s, got %s", expectedKingsTithe, kingsTithe)
 	}
 	
 	// Verify miner reward is reduced by King's Tithe
 	expectedFinalMinerReward := originalMinerReward - kingsTithe
 	if result.MinerReward != expectedFinalMinerReward {
-		t.Errorf("Expected final miner reward %.3f, got %.3f", expectedFinalMinerReward, result.MinerReward)
+		t.Errorf("Expected final miner reward %s, got %s", expectedFinalMinerReward, result.MinerReward)
 	}
 	
 	// Verify treasury balance includes both allocation and King's Tithe
 	expectedTreasuryBalance := TreasuryAllocation + kingsTithe
 	if treasury.GetBalance() != expectedTreasuryBalance {
-		t.Errorf("Expected treasury balance %.3f, got %.3f", expectedTreasuryBalance, treasury.GetBalance())
+		t.Errorf("Expected treasury balance %s, got %s", expectedTreasuryBalance, treasury.GetBalance())
 	}
 }
 
@@ -303,17 +319,144 @@ func TestProcessForgeWithFeeDisabled(t *testing.T) {
 	
 	// Verify no King's Tithe applied
 	if kingsTithe != 0 {
-		t.Errorf("Expected King's Tithe 0, got %.3f", kingsTithe)
+		t.Errorf("Expected King's Tithe 0, got %s", kingsTithe)
 	}
 	
 	// Verify miner reward is standard (not reduced by tithe)
 	expectedMinerReward := ForgeReward - TreasuryAllocation
 	if result.MinerReward != expectedMinerReward {
-		t.Errorf("Expected miner reward %.3f, got %.3f", expectedMinerReward, result.MinerReward)
+		t.Errorf("Expected miner reward %s, got %s", expectedMinerReward, result.MinerReward)
 	}
 	
 	// Verify treasury balance is standard allocation only
 	if treasury.GetBalance() != TreasuryAllocation {
-		t.Errorf("Expected treasury balance %.2f, got %.2f", TreasuryAllocation, treasury.GetBalance())
+		t.Errorf("Expected treasury balance %s, got %s", TreasuryAllocation, treasury.GetBalance())
+	}
+}
+
+func TestAddressLedger(t *testing.T) {
+	treasury := NewTreasury()
+	miner := "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"
+
+	treasury.SetBlockHeight(10)
+	treasury.ProcessForge(miner)
+	treasury.SetBlockHeight(11)
+	result, tithe, _ := treasury.ProcessForgeWithFee(miner, true)
+
+	expected := (ForgeReward - TreasuryAllocation) + result.MinerReward
+	if balance := treasury.GetAddressBalance(miner); balance != expected {
+		t.Errorf("Expected miner balance %s, got %s", expected, balance)
+	}
+
+	forges := treasury.GetForgesAtHeight(11)
+	if len(forges) != 1 || forges[0].MinerReward != ForgeReward-TreasuryAllocation-tithe {
+		t.Errorf("Expected one tithed forge at height 11, got %+v", forges)
+	}
+	if len(treasury.GetForgesAtHeight(12)) != 0 {
+		t.Error("Expected no forges at height 12")
+	}
+
+	treasury.Distribute(5*Coin, "bc1qrecipient", "grant")
+	if balance := treasury.GetAddressBalance("bc1qrecipient"); balance != 5*Coin {
+		t.Errorf("Expected recipient balance 5, got %s", balance)
+	}
+}
+
+func TestProcessForgeSplit(t *testing.T) {
+	treasury := NewTreasury()
+	treasury.SetBlockHeight(20)
+	split := RewardSplit{{"bc1poperator", 90}, {"bc1phost", 10}}
+
+	result, err := treasury.ProcessForgeSplit(split)
+	if err != nil {
+		t.Fatalf("ProcessForgeSplit() error = %v", err)
+	}
+	minerReward := ForgeReward - TreasuryAllocation
+	if result.MinerAddress != "bc1poperator" || result.MinerReward != minerReward || len(result.Payouts) != 2 {
+		t.Errorf("Unexpected forge result %+v", result)
+	}
+	if balance := treasury.GetAddressBalance("bc1poperator"); balance != minerReward*90/100 {
+		t.Errorf("Expected operator balance %s, got %s", minerReward*90/100, balance)
+	}
+	if balance := treasury.GetAddressBalance("bc1phost"); balance != minerReward-minerReward*90/100 {
+		t.Errorf("Expected host balance %s, got %s", minerReward-minerReward*90/100, balance)
+	}
+	if treasury.GetBalance() != TreasuryAllocation {
+		t.Errorf("Expected treasury balance %s, got %s", TreasuryAllocation, treasury.GetBalance())
+	}
+
+	if _, err := treasury.ProcessForgeSplit(RewardSplit{{"bc1poperator", 90}}); !errors.Is(err, ErrInvalidSplit) {
+		t.Errorf("ProcessForgeSplit() with 90%% error = %v, want ErrInvalidSplit", err)
+	}
+	if treasury.GetTotalForges() != 1 {
+		t.Errorf("Expected rejected split to record no forge, got %d forges", treasury.GetTotalForges())
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	treasury := NewTreasury()
+	treasury.SetBlockHeight(30)
+	top := "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"
+	for _, miner := range []string{"bc1qsecondminer0001", top, top, "bc1qthirdminer00002", top} {
+		treasury.ProcessForge(miner)
+	}
+
+	board := treasury.Leaderboard(0)
+	if board.TotalForges != 5 || board.Miners != 3 || board.BlockHeight != 30 {
+		t.Errorf("Unexpected leaderboard totals %+v", board)
+	}
+	if board.TotalRewards != 5*(ForgeReward-TreasuryAllocation) {
+		t.Errorf("Expected total rewards %s, got %s", 5*(ForgeReward-TreasuryAllocation), board.TotalRewards)
+	}
+	if len(board.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(board.Entries))
+	}
+
+	first := board.Entries[0]
+	if first.Rank != 1 || first.Forges != 3 || first.Share != 60 || first.Address != "bc1p5cyx…drcr" {
+		t.Errorf("Unexpected leader %+v", first)
+	}
+	if first.Rewards != 3*(ForgeReward-TreasuryAllocation) {
+		t.Errorf("Expected leader rewards %s, got %s", 3*(ForgeReward-TreasuryAllocation), first.Rewards)
+	}
+
+	// Tied miners share a rank, the earlier one listed first
+	if board.Entries[1].Rank != 2 || board.Entries[2].Rank != 2 || board.Entries[1].Address != "bc1qseco…0001" {
+		t.Errorf("Expected tied miners at rank 2, got %+v", board.Entries[1:])
+	}
+
+	if board := treasury.Leaderboard(1); len(board.Entries) != 1 || board.Miners != 3 {
+		t.Errorf("Expected one of 3 miners with limit 1, got %+v", board)
+	}
+}
+
+func TestHaltCheck(t *testing.T) {
+	treasury := NewTreasury()
+	treasury.SetBlockHeight(1000)
+	if treasury.ProcessForge("bc1ptest") == nil {
+		t.Fatal("Expected forge before the halt")
+	}
+
+	errHalted := errors.New("halted")
+	treasury.SetHaltCheck(func() error { return errHalted })
+	if treasury.ProcessForge("bc1ptest") != nil {
+		t.Error("Expected forge to be refused while halted")
+	}
+	if _, _, err := treasury.ProcessForgeWithFee("bc1ptest", true); !errors.Is(err, errHalted) {
+		t.Errorf("Expected halt error from ProcessForgeWithFee, got %v", err)
+	}
+	if _, err := treasury.ProcessForgeSplit(RewardSplit{{Address: "bc1ptest", Percent: 100}}); !errors.Is(err, errHalted) {
+		t.Errorf("Expected halt error from ProcessForgeSplit, got %v", err)
+	}
+	if _, err := treasury.Distribute(Coin, "bc1precipient", "grant"); !errors.Is(err, errHalted) {
+		t.Errorf("Expected halt error from Distribute, got %v", err)
+	}
+	if got := treasury.GetBalance(); got != TreasuryAllocation {
+		t.Errorf("Expected balance %s unchanged by the halt, got %s", TreasuryAllocation, got)
+	}
+
+	treasury.SetHaltCheck(func() error { return nil })
+	if _, err := treasury.Distribute(Coin, "bc1precipient", "grant"); err != nil {
+		t.Errorf("Expected distribution after the halt, got %v", err)
 	}
 }
@@ -0,0 +1,239 @@
+// Package events carries fleet-wide notices between EXS services. One
+// service, the treasury API, owns a Bus and serves it over HTTP; the others
+// follow that stream. A new subscriber first receives the latest event of
+// every type, so a service that starts or reconnects mid-incident learns the
+// current state without waiting for the next change.
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// subscriberBuffer is the number of events a subscriber may fall
+	// behind before it is dropped
+	subscriberBuffer = 16
+	// keepAlive is how often an idle stream sends a blank line so proxies
+	// keep the connection open
+	keepAlive = 15 * time.Second
+	// maxBackoff bounds the delay between a follower's reconnects
+	maxBackoff = 30 * time.Second
+)
+
+// Event is one notice on the bus
+type Event struct {
+	Seq  uint64          `json:"seq"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode unmarshals the event data into v
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Bus fans published events out to subscribers
+type Bus struct {
+	mu     sync.Mutex
+	seq    uint64
+	latest map[string]Event
+	subs   map[chan Event]struct{}
+}
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{latest: make(map[string]Event), subs: make(map[chan Event]struct{})}
+}
+
+// Publish sends data, encoded as JSON, to every subscriber as an event of
+// type typ. A subscriber too far behind to take it is dropped, closing its
+// channel, so a follower reconnects and resynchronizes from the latest
+// events instead of silently missing one.
+func (b *Bus) Publish(typ string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode %s event: %w", typ, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	event := Event{Seq: b.seq, Type: typ, Time: time.Now().UTC(), Data: raw}
+	b.latest[typ] = event
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return event, nil
+}
+
+// Latest returns the last event of type typ
+func (b *Bus) Latest(typ string) (Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	event, ok := b.latest[typ]
+	return event, ok
+}
+
+// Subscribe returns a channel receiving the latest event of every type,
+// oldest first, then each event as it is published. The channel is closed
+// by cancel or when the subscriber falls behind.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	replay := make([]Event, 0, len(b.latest))
+	for _, event := range b.latest {
+		replay = append(replay, event)
+	}
+	sort.Slice(replay, func(i, j int) bool { return replay[i].Seq < replay[j].Seq })
+
+	ch := make(chan Event, len(replay)+subscriberBuffer)
+	for _, event := range replay {
+		ch <- event
+	}
+	b.subs[ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// Handler streams the bus to an HTTP client as newline-delimited JSON
+// events, with blank keep-alive lines while idle
+func (b *Bus) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		events, cancel := b.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := enc.Encode(event); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// Follower reads a bus served by Handler, reconnecting whenever the stream
+// drops
+type Follower struct {
+	// URL is the event stream endpoint
+	URL string
+	// Token is sent as a bearer token when set
+	Token string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+	// OnError is told why each connection ended, if set
+	OnError func(error)
+}
+
+// Run passes every event to handle until ctx is cancelled. Each reconnect
+// replays the latest events, so handle must tolerate repeats.
+func (f *Follower) Run(ctx context.Context, handle func(Event)) error {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := f.stream(ctx, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if f.OnError != nil {
+			f.OnError(err)
+		}
+		if time.Since(start) > maxBackoff {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// stream reads one connection until it ends
+func (f *Follower) stream(ctx context.Context, handle func(Event)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return err
+	}
+	if f.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.Token)
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("event stream unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event stream refused: %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+		handle(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("event stream: %w", err)
+	}
+	return io.ErrUnexpectedEOF
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSubscribeReplaysLatest(t *testing.T) {
+	bus := NewBus()
+	bus.Publish("a", 1)
+	bus.Publish("b", 2)
+	bus.Publish("a", 3)
+
+	events, cancel := bus.Subscribe()
+	defer cancel()
+	bus.Publish("b", 4)
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		event := <-events
+		var v int
+		if err := event.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, event.Type+string(rune('0'+v)))
+	}
+	// Only the latest event of each type replays, in publish order
+	want := []string{"b2", "a3", "b4"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	if latest, ok := bus.Latest("b"); !ok || latest.Seq != 4 {
+		t.Errorf("Latest(b) = %+v, %v", latest, ok)
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	bus := NewBus()
+	events, cancel := bus.Subscribe()
+	defer cancel()
+	for i := 0; i <= subscriberBuffer; i++ {
+		bus.Publish("tick", i)
+	}
+
+	n := 0
+	for range events {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("received %d events before drop, want %d", n, subscriberBuffer)
+	}
+}
+
+func TestFollower(t *testing.T) {
+	bus := NewBus()
+	bus.Publish("emergency", map[string]bool{"halted": true})
+
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	srv := httptest.NewServer(auth(bus.Handler()))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan Event, 4)
+	follower := &Follower{URL: srv.URL, Token: "secret"}
+	done := make(chan error, 1)
+	go func() { done <- follower.Run(ctx, func(e Event) { received <- e }) }()
+
+	replayed := <-received
+	var state map[string]bool
+	if err := replayed.Decode(&state); err != nil || replayed.Type != "emergency" || !state["halted"] {
+		t.Fatalf("replayed %+v (%v)", replayed, err)
+	}
+
+	bus.Publish("emergency", map[string]bool{"halted": false})
+	select {
+	case live := <-received:
+		if live.Seq != 2 {
+			t.Errorf("live event seq %d, want 2", live.Seq)
+		}
+	case <-ctx.Done():
+		t.Fatal("live event not received")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+}
+
+func TestFollowerRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	follower := &Follower{URL: srv.URL, OnError: func(err error) {
+		errs <- err
+		cancel()
+	}}
+	follower.Run(ctx, func(Event) { t.Error("unexpected event") })
+	if err := <-errs; err == nil {
+		t.Error("expected a connection error")
+	}
+}
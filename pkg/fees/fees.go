@@ -0,0 +1,274 @@
+// Package fees estimates the fee rate a transaction needs to confirm within
+// a number of blocks. An Estimator combines what it observes itself, the
+// fee rates of transactions from the block they enter the mempool to the
+// block that confirms them, with a projection of the current mempool into
+// the next blocks, and falls back to an external estimator, such as an
+// Esplora backend's /fee-estimates, until it has seen enough blocks.
+// Estimates come in two modes, as bitcoind's estimatesmartfee gives them:
+// conservative, which pays more to be confident, and economical.
+package fees
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// ErrNoEstimates indicates no source that could estimate a fee rate
+var ErrNoEstimates = errors.New("no fee estimates")
+
+// Mode is how cautious an estimate is
+type Mode string
+
+const (
+	// Conservative needs 95% of observed transactions to have confirmed
+	// within the target, and at least the rate the current mempool needs
+	Conservative Mode = "conservative"
+	// Economical needs 85% and goes by the mempool only without observed
+	// blocks, so it may take longer
+	Economical Mode = "economical"
+)
+
+// Defaults for a zero Config field
+const (
+	DefaultMinRate  = 1.0
+	DefaultCacheTTL = 30 * time.Second
+)
+
+// MaxTarget is the longest confirmation target estimated; longer targets
+// get its estimate
+const MaxTarget = 144
+
+// Targets are the confirmation targets FeeEstimates reports
+var Targets = []int{1, 2, 3, 6, 12, 24, 144}
+
+// ParseMode returns the mode named s, case insensitively; empty means
+// economical
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(s)); mode {
+	case "":
+		return Economical, nil
+	case Conservative, Economical:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown fee estimate mode %q (use conservative or economical)", s)
+}
+
+// Estimates maps a confirmation target in blocks to the fee rate, in
+// sat/vB, expected to confirm within it
+type Estimates map[int]float64
+
+// Rate returns the fee rate to confirm within blocks: the estimate for the
+// largest target not above blocks, or the fastest one if all are above it
+func (f Estimates) Rate(blocks int) (float64, error) {
+	if len(f) == 0 {
+		return 0, ErrNoEstimates
+	}
+	best, fastest := -1, -1
+	for target := range f {
+		if target <= blocks && target > best {
+			best = target
+		}
+		if fastest < 0 || target < fastest {
+			fastest = target
+		}
+	}
+	if best < 0 {
+		best = fastest
+	}
+	return f[best], nil
+}
+
+// Source is an external estimator, such as an Esplora backend
+type Source interface {
+	FeeEstimates(ctx context.Context) (Estimates, error)
+}
+
+// MempoolSource reports the fee rates of the mempool
+type MempoolSource interface {
+	FeeHistogram(ctx context.Context) (Histogram, error)
+}
+
+// Config configures an Estimator
+type Config struct {
+	// External estimates for targets the estimator has not seen enough
+	// blocks for; nil for none
+	External Source
+	// Mempool supplies the mempool histogram when the estimator is not
+	// fed the mempool with ObserveMempool; nil for none
+	Mempool MempoolSource
+	// Mode is the mode of FeeEstimates, Economical when empty
+	Mode Mode
+	// MinRate floors every estimate, DefaultMinRate when zero
+	MinRate float64
+	// CacheTTL is how long fetched estimates and histograms are used,
+	// DefaultCacheTTL when zero
+	CacheTTL time.Duration
+}
+
+// Estimate is a fee rate and where it came from
+type Estimate struct {
+	// Rate is in sat/vB
+	Rate   float64
+	Target int
+	Mode   Mode
+	// Source is "blocks", "mempool", "external" or a combination
+	Source string
+}
+
+// SatsPerVByte returns the rate rounded up to whole sat/vB
+func (e Estimate) SatsPerVByte() int64 {
+	return int64(math.Ceil(e.Rate))
+}
+
+// Entry is an unconfirmed transaction observed in the mempool
+type Entry struct {
+	TxID  chainhash.Hash
+	Fee   int64
+	VSize int64
+}
+
+// Estimator estimates fee rates; it is safe for concurrent use
+type Estimator struct {
+	config  Config
+	mu      sync.Mutex
+	tracker *tracker
+
+	histogram   Histogram
+	histogramAt time.Time
+	observed    bool
+	external    Estimates
+	externalAt  time.Time
+}
+
+// New creates an estimator for config
+func New(config Config) *Estimator {
+	if config.Mode == "" {
+		config.Mode = Economical
+	}
+	if config.MinRate <= 0 {
+		config.MinRate = DefaultMinRate
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = DefaultCacheTTL
+	}
+	return &Estimator{config: config, tracker: newTracker()}
+}
+
+// ObserveMempool records the transactions in the mempool at height, the
+// current tip. Observe each new block with ObserveBlock first.
+func (e *Estimator) ObserveMempool(height int32, entries []Entry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.tracker.mempool(height, entries)
+	e.histogram = NewHistogram(entries)
+	e.histogramAt = time.Now()
+	e.observed = true
+}
+
+// ObserveBlock records the block at height confirming txids
+func (e *Estimator) ObserveBlock(height int32, txids []chainhash.Hash) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.tracker.block(height, txids)
+}
+
+// Height returns the last block observed, 0 before any
+func (e *Estimator) Height() int32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.tracker.height
+}
+
+// Estimate returns the fee rate to confirm within target blocks
+func (e *Estimator) Estimate(ctx context.Context, target int, mode Mode) (Estimate, error) {
+	target = min(max(target, 1), MaxTarget)
+	if mode == "" {
+		mode = e.config.Mode
+	}
+	threshold := 0.85
+	if mode == Conservative {
+		threshold = 0.95
+	}
+
+	histogram := e.mempoolHistogram(ctx)
+	e.mu.Lock()
+	blocks, blocksOK := e.tracker.estimate(target, threshold)
+	e.mu.Unlock()
+	blocksSource := "blocks"
+	if !blocksOK {
+		if external := e.externalEstimates(ctx); external != nil {
+			if rate, err := external.Rate(target); err == nil {
+				blocks, blocksOK, blocksSource = rate, true, "external"
+			}
+		}
+	}
+
+	estimate := Estimate{Target: target, Mode: mode}
+	projected, projectedOK := histogram.Rate(target)
+	switch {
+	case blocksOK && projectedOK && mode == Conservative:
+		estimate.Rate, estimate.Source = max(blocks, projected), blocksSource+"+mempool"
+	case blocksOK:
+		estimate.Rate, estimate.Source = blocks, blocksSource
+	case projectedOK:
+		estimate.Rate, estimate.Source = projected, "mempool"
+	default:
+		return Estimate{}, ErrNoEstimates
+	}
+	estimate.Rate = max(estimate.Rate, e.config.MinRate)
+	return estimate, nil
+}
+
+// FeeEstimates returns estimates in the configured mode for Targets, so an
+// Estimator can stand in for a backend's estimates
+func (e *Estimator) FeeEstimates(ctx context.Context) (Estimates, error) {
+	estimates := make(Estimates, len(Targets))
+	for _, target := range Targets {
+		estimate, err := e.Estimate(ctx, target, e.config.Mode)
+		if err != nil {
+			return nil, err
+		}
+		estimates[target] = estimate.Rate
+	}
+	return estimates, nil
+}
+
+// mempoolHistogram returns the observed mempool, or else the Mempool
+// source's, fetched at most once per CacheTTL; nil if there is none
+func (e *Estimator) mempoolHistogram(ctx context.Context) Histogram {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.observed || e.config.Mempool == nil || time.Since(e.histogramAt) < e.config.CacheTTL {
+		return e.histogram
+	}
+	if histogram, err := e.config.Mempool.FeeHistogram(ctx); err == nil {
+		e.histogram, e.histogramAt = histogram, time.Now()
+	}
+	return e.histogram
+}
+
+// externalEstimates returns the External source's estimates, fetched at
+// most once per CacheTTL; nil if there are none
+func (e *Estimator) externalEstimates(ctx context.Context) Estimates {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.config.External == nil || time.Since(e.externalAt) < e.config.CacheTTL {
+		return e.external
+	}
+	if estimates, err := e.config.External.FeeEstimates(ctx); err == nil {
+		e.external, e.externalAt = estimates, time.Now()
+	}
+	return e.external
+}
@@ -0,0 +1,181 @@
+package fees
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+type mockSource struct {
+	estimates Estimates
+	histogram Histogram
+	calls     int
+}
+
+func (m *mockSource) FeeEstimates(ctx context.Context) (Estimates, error) {
+	m.calls++
+	if m.estimates == nil {
+		return nil, ErrNoEstimates
+	}
+	return m.estimates, nil
+}
+
+func (m *mockSource) FeeHistogram(ctx context.Context) (Histogram, error) {
+	m.calls++
+	return m.histogram, nil
+}
+
+func txid(n int) chainhash.Hash {
+	var h chainhash.Hash
+	binary.LittleEndian.PutUint64(h[:], uint64(n))
+	return h
+}
+
+// simulate feeds e blocks in which transactions paying 50 sat/vB confirm
+// in the next block, 20 sat/vB in three and 5 sat/vB never
+func simulate(e *Estimator, blocks int) {
+	n := 0
+	var mempool []Entry
+	waiting := make(map[chainhash.Hash]int32)
+	for height := int32(1); height <= int32(blocks); height++ {
+		var confirmed []chainhash.Hash
+		var left []Entry
+		for _, entry := range mempool {
+			rate := entry.Fee / entry.VSize
+			age := height - waiting[entry.TxID]
+			if rate >= 50 || (rate >= 20 && age >= 3) {
+				confirmed = append(confirmed, entry.TxID)
+			} else {
+				left = append(left, entry)
+			}
+		}
+		e.ObserveBlock(height, confirmed)
+		mempool = left
+		for _, rate := range []int64{50, 50, 20, 20, 5} {
+			n++
+			entry := Entry{TxID: txid(n), Fee: rate * 200, VSize: 200}
+			waiting[entry.TxID] = height
+			mempool = append(mempool, entry)
+		}
+		e.ObserveMempool(height, mempool)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": Economical, "CONSERVATIVE": Conservative, "economical": Economical} {
+		if got, err := ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %s, %v, want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseMode("fast"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestEstimatorBlocks(t *testing.T) {
+	e := New(Config{})
+	if _, err := e.Estimate(context.Background(), 6, Economical); !errors.Is(err, ErrNoEstimates) {
+		t.Fatalf("Expected ErrNoEstimates before any observation, got %v", err)
+	}
+	simulate(e, 60)
+	if e.Height() != 60 {
+		t.Errorf("Height() = %d", e.Height())
+	}
+
+	for _, tc := range []struct {
+		target   int
+		min, max float64
+	}{
+		{1, 40, 50},
+		{2, 40, 50},
+		{3, 16, 20},
+		{12, 16, 20},
+	} {
+		estimate, err := e.Estimate(context.Background(), tc.target, Economical)
+		if err != nil {
+			t.Fatalf("Estimate(%d) error = %v", tc.target, err)
+		}
+		// The mempool is small, so the observed blocks decide
+		if estimate.Rate < tc.min || estimate.Rate > tc.max {
+			t.Errorf("Estimate(%d) = %.2f sat/vB (%s), want %v to %v", tc.target, estimate.Rate, estimate.Source, tc.min, tc.max)
+		}
+	}
+
+	estimates, err := e.FeeEstimates(context.Background())
+	if err != nil || len(estimates) != len(Targets) || estimates[1] < estimates[144] {
+		t.Errorf("FeeEstimates() = %v, %v", estimates, err)
+	}
+}
+
+func TestEstimatorModes(t *testing.T) {
+	e := New(Config{})
+	simulate(e, 60)
+
+	// A congested mempool: 3.5 MvB paying 30 sat/vB or more
+	e.ObserveMempool(60, []Entry{
+		{TxID: txid(-1), Fee: 60 * 1_500_000, VSize: 1_500_000},
+		{TxID: txid(-2), Fee: 30 * 2_000_000, VSize: 2_000_000},
+	})
+	conservative, err := e.Estimate(context.Background(), 3, Conservative)
+	if err != nil {
+		t.Fatalf("Estimate() error = %v", err)
+	}
+	economical, err := e.Estimate(context.Background(), 3, Economical)
+	if err != nil {
+		t.Fatalf("Estimate() error = %v", err)
+	}
+	if conservative.Rate != 30 || conservative.Source != "blocks+mempool" {
+		t.Errorf("Conservative = %+v, want the mempool's 30 sat/vB", conservative)
+	}
+	if economical.Rate >= conservative.Rate || economical.Source != "blocks" {
+		t.Errorf("Economical = %+v, want the observed blocks' rate below conservative", economical)
+	}
+}
+
+func TestEstimatorExternal(t *testing.T) {
+	source := &mockSource{
+		estimates: Estimates{1: 0.5, 6: 12},
+		histogram: Histogram{{Rate: 8, VSize: 1_200_000}, {Rate: 3, VSize: 400_000}},
+	}
+	e := New(Config{External: source, Mempool: source})
+
+	estimate, err := e.Estimate(context.Background(), 6, Conservative)
+	if err != nil {
+		t.Fatalf("Estimate() error = %v", err)
+	}
+	if estimate.Rate != 12 || estimate.Source != "external+mempool" {
+		t.Errorf("Estimate(6) = %+v, want the external 12 sat/vB", estimate)
+	}
+	estimate, _ = e.Estimate(context.Background(), 1, Economical)
+	if estimate.Rate != DefaultMinRate || estimate.Source != "external" {
+		t.Errorf("Expected the minimum rate to floor estimates, got %+v", estimate)
+	}
+	if estimate, _ = e.Estimate(context.Background(), 1, Conservative); estimate.Rate != 8 {
+		t.Errorf("Expected the mempool rate for the next block, got %+v", estimate)
+	}
+	if source.calls != 2 {
+		t.Errorf("Expected the sources to be cached, got %d calls", source.calls)
+	}
+}
+
+func TestHistogramRate(t *testing.T) {
+	if _, ok := Histogram(nil).Rate(1); ok {
+		t.Error("Expected no rate for an unknown mempool")
+	}
+	if rate, ok := (Histogram{}).Rate(1); !ok || rate != 0 {
+		t.Errorf("Expected an empty mempool to need no fee, got %v, %v", rate, ok)
+	}
+	h := NewHistogram([]Entry{
+		{Fee: 2 * 900_000, VSize: 900_000},
+		{Fee: 10 * 800_000, VSize: 800_000},
+		{Fee: 5 * 700_000, VSize: 700_000},
+	})
+	for target, want := range map[int]float64{1: 5, 2: 2, 3: 0} {
+		if rate, _ := h.Rate(target); rate != want {
+			t.Errorf("Rate(%d) = %v, want %v", target, rate, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package fees
+
+import "sort"
+
+// BlockVSize is the virtual size of a full block
+const BlockVSize = 1_000_000
+
+// Bucket is the virtual size of the mempool transactions paying at least
+// Rate, and less than the rate of the bucket before it
+type Bucket struct {
+	Rate  float64
+	VSize int64
+}
+
+// Histogram is the mempool by fee rate, highest first, as Esplora's
+// /mempool fee_histogram reports it. A nil histogram is an unknown
+// mempool, an empty one an empty mempool.
+type Histogram []Bucket
+
+// NewHistogram buckets entries by their fee rate
+func NewHistogram(entries []Entry) Histogram {
+	h := make(Histogram, 0, len(entries))
+	for _, entry := range entries {
+		if entry.VSize > 0 {
+			h = append(h, Bucket{Rate: float64(entry.Fee) / float64(entry.VSize), VSize: entry.VSize})
+		}
+	}
+	sort.Slice(h, func(i, j int) bool {
+		return h[i].Rate > h[j].Rate
+	})
+	return h
+}
+
+// Rate returns the fee rate that places a transaction within the first
+// target blocks mined from the mempool, 0 if the mempool fits in them, and
+// false for an unknown mempool. Transactions arriving meanwhile are not
+// foreseen.
+func (h Histogram) Rate(target int) (float64, bool) {
+	if h == nil {
+		return 0, false
+	}
+	space := int64(target) * BlockVSize
+	for _, bucket := range h {
+		if space -= bucket.VSize; space < 0 {
+			return bucket.Rate, true
+		}
+	}
+	return 0, true
+}
+
+// VSize returns the virtual size of the mempool
+func (h Histogram) VSize() int64 {
+	var vsize int64
+	for _, bucket := range h {
+		vsize += bucket.VSize
+	}
+	return vsize
+}
@@ -0,0 +1,149 @@
+package fees
+
+import (
+	"math"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Tracker parameters, after Bitcoin Core's block policy estimator
+const (
+	// bucketSpacing separates the fee rate buckets, from 1 to 10000 sat/vB
+	bucketSpacing = 1.1
+	maxBucketRate = 10_000
+	// decay ages every count per block, halving it in about 70 blocks
+	decay = 0.99
+	// minSamples is the decayed count a group of buckets needs to be
+	// judged
+	minSamples = 8
+)
+
+// pending is a transaction seen in the mempool and not yet confirmed
+type pending struct {
+	height int32
+	bucket int
+}
+
+// tracker learns how long transactions of each fee rate take to confirm
+type tracker struct {
+	// buckets holds the lower bound of each fee rate bucket
+	buckets []float64
+	// confirmed[t-1][b] counts transactions of bucket b that confirmed
+	// within t blocks of entering the mempool
+	confirmed [][]float64
+	// resolved[b] counts transactions of bucket b that confirmed or were
+	// given up on after MaxTarget blocks
+	resolved []float64
+	pending  map[chainhash.Hash]pending
+	height   int32
+}
+
+func newTracker() *tracker {
+	t := &tracker{pending: make(map[chainhash.Hash]pending)}
+	for rate := 1.0; rate <= maxBucketRate; rate *= bucketSpacing {
+		t.buckets = append(t.buckets, rate)
+	}
+	t.confirmed = make([][]float64, MaxTarget)
+	for i := range t.confirmed {
+		t.confirmed[i] = make([]float64, len(t.buckets))
+	}
+	t.resolved = make([]float64, len(t.buckets))
+	return t
+}
+
+// bucket returns the bucket of rate
+func (t *tracker) bucket(rate float64) int {
+	return max(sort.SearchFloat64s(t.buckets, math.Nextafter(rate, math.Inf(1)))-1, 0)
+}
+
+// mempool tracks the entries not tracked yet as entering at height and
+// forgets tracked transactions that left the mempool unconfirmed
+func (t *tracker) mempool(height int32, entries []Entry) {
+	seen := make(map[chainhash.Hash]bool, len(entries))
+	for _, entry := range entries {
+		seen[entry.TxID] = true
+		if _, ok := t.pending[entry.TxID]; ok || entry.VSize <= 0 {
+			continue
+		}
+		rate := float64(entry.Fee) / float64(entry.VSize)
+		t.pending[entry.TxID] = pending{height: height, bucket: t.bucket(rate)}
+	}
+	for txid := range t.pending {
+		if !seen[txid] {
+			delete(t.pending, txid)
+		}
+	}
+}
+
+// block records the tracked transactions confirmed at height
+func (t *tracker) block(height int32, txids []chainhash.Hash) {
+	if height <= t.height {
+		// A reorganization or a block seen before; its transactions
+		// are no new evidence
+		t.height = height
+		return
+	}
+	t.height = height
+
+	for i := range t.confirmed {
+		for b := range t.confirmed[i] {
+			t.confirmed[i][b] *= decay
+		}
+	}
+	for b := range t.resolved {
+		t.resolved[b] *= decay
+	}
+
+	for _, txid := range txids {
+		p, ok := t.pending[txid]
+		if !ok {
+			continue
+		}
+		delete(t.pending, txid)
+		blocks := max(int(height-p.height), 1)
+		for target := blocks; target <= MaxTarget; target++ {
+			t.confirmed[target-1][p.bucket]++
+		}
+		t.resolved[p.bucket]++
+	}
+	for txid, p := range t.pending {
+		if height-p.height > MaxTarget {
+			delete(t.pending, txid)
+			t.resolved[p.bucket]++
+		}
+	}
+}
+
+// estimate returns the lowest fee rate at which at least threshold of the
+// transactions confirmed within target blocks. Buckets are judged in
+// groups, from the highest rate down, each holding enough samples; the
+// first group that falls short ends the search. Transactions still waiting
+// after target blocks count against their bucket.
+func (t *tracker) estimate(target int, threshold float64) (float64, bool) {
+	waiting := make([]float64, len(t.buckets))
+	for _, p := range t.pending {
+		if int(t.height-p.height) >= target {
+			waiting[p.bucket]++
+		}
+	}
+
+	best := -1
+	var confirmed, total float64
+	for b := len(t.buckets) - 1; b >= 0; b-- {
+		confirmed += t.confirmed[target-1][b]
+		total += t.resolved[b] + waiting[b]
+		if total < minSamples {
+			continue
+		}
+		if confirmed/total < threshold {
+			break
+		}
+		best = b
+		confirmed, total = 0, 0
+	}
+	if best < 0 {
+		return 0, false
+	}
+	return t.buckets[best], true
+}
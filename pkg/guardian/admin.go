@@ -0,0 +1,434 @@
+package guardian
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// ErrClientCertificate indicates an admin request without a verified client
+// certificate
+var ErrClientCertificate = errors.New("admin API requires a verified client certificate")
+
+// UserInfo is a user as the admin API shows it, without credentials
+type UserInfo struct {
+	Username    string    `json:"username"`
+	Role        Role      `json:"role"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at,omitzero"`
+	// TwoFactor is "on", "pending" until the first code is verified, or
+	// "off"
+	TwoFactor    string    `json:"two_factor"`
+	FailedLogins int       `json:"failed_logins"`
+	LockedUntil  time.Time `json:"locked_until,omitzero"`
+}
+
+// Info returns the user without credentials
+func (u *User) Info(now time.Time) UserInfo {
+	info := UserInfo{
+		Username:     u.Username,
+		Role:         u.Role,
+		Enabled:      u.Enabled,
+		CreatedAt:    u.CreatedAt,
+		LastLoginAt:  u.LastLoginAt,
+		TwoFactor:    "off",
+		FailedLogins: u.FailedLogins,
+	}
+	if u.TOTPEnabled {
+		info.TwoFactor = "on"
+	} else if len(u.TOTPSecret) > 0 {
+		info.TwoFactor = "pending"
+	}
+	if now.Before(u.LockedUntil) {
+		info.LockedUntil = u.LockedUntil
+	}
+	return info
+}
+
+// SessionInfo is a session as the admin API shows it. ID stands in for the
+// token, which never leaves the Guardian.
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	Role       Role      `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastSeenAt time.Time `json:"last_seen_at,omitzero"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+}
+
+// SessionID returns the ID of the session with token: the first 16 bytes
+// of its SHA-256, in hex
+func SessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ListSessions returns the sessions that can still authenticate or be
+// refreshed, oldest first. JWT sessions are listed by the service that
+// issued them; revoking one ends it there at once, and at services that
+// check it by JWKSURL when its JWT expires.
+func (g *Guardian) ListSessions() []SessionInfo {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	now := time.Now()
+	sessions := make([]SessionInfo, 0, len(g.sessions))
+	for _, s := range g.sessions {
+		if g.ended(s, now) {
+			continue
+		}
+		sessions = append(sessions, SessionInfo{
+			ID:         SessionID(s.Token),
+			Username:   s.Username,
+			Role:       s.Role,
+			CreatedAt:  s.CreatedAt,
+			ExpiresAt:  s.ExpiresAt,
+			LastSeenAt: s.LastSeenAt,
+			IPAddress:  s.IPAddress,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions
+}
+
+// RevokeSessionID revokes the session with the given ID, as listed by
+// ListSessions
+func (g *Guardian) RevokeSessionID(id string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, session := range g.sessions {
+		if SessionID(session.Token) != id {
+			continue
+		}
+		if err := g.removeSession(session); err != nil {
+			return err
+		}
+		g.audit(AuditSessionRevoked, session.Username, session.IPAddress, map[string]string{"sessions": "1"})
+		return nil
+	}
+	return ErrInvalidToken
+}
+
+// AdminHandler serves user and security policy management for the Merlin's
+// Portal dashboard, rooted at "/" (mount it with http.StripPrefix):
+//
+//	GET    /users                          users without credentials
+//	POST   /users                          {"username", "password", "role"}
+//	GET    /users/{username}
+//	DELETE /users/{username}               delete and revoke sessions
+//	PUT    /users/{username}/role          {"role"}, revoking sessions on
+//	                                       a demotion
+//	PUT    /users/{username}/password      {"password"}, revoking sessions
+//	POST   /users/{username}/unlock        lift a lockout
+//	POST   /users/{username}/revoke        revoke every session
+//	GET    /sessions                       active sessions by ID
+//	DELETE /sessions/{id}                  force-revoke a session
+//	GET    /ip-policy                      global allow and deny rules
+//	POST   /ip-policy/{whitelist|denylist} {"rule"} to add
+//	DELETE /ip-policy/{whitelist|denylist}?rule=  to remove, both saved
+//	                                       to the policy file, if any, and
+//	                                       returning {"persisted"}
+//	GET    /api-keys                       API keys without their hashes
+//	POST   /api-keys                       {"name", "role", "scopes",
+//	                                       "allowed_ips", "rate_limit",
+//	                                       "expires_in"}, returning the key
+//	POST   /api-keys/{id}/rotate           replace the key, returning it
+//	DELETE /api-keys/{id}                  revoke the key
+//
+// Requests need a session whose role holds node.admin, King Arthur by
+// default, as for PermissionMiddleware, and with
+// requireClientCert a client certificate verified by the TLS server (mutual
+// TLS). Every change is recorded in the audit log as admin_request with the
+// administrator and status.
+func (g *Guardian) AdminHandler(requireClientCert bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		users := g.ListUsers()
+		infos := make([]UserInfo, len(users))
+		for i, u := range users {
+			infos[i] = u.Info(now)
+		}
+		writeJSON(w, http.StatusOK, infos)
+	})
+	mux.HandleFunc("POST /users", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Role     Role   `json:"role"`
+		}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.Username == "" || req.Password == "" || req.Role.rank() == 0 {
+			writeError(w, http.StatusBadRequest, errors.New("username, password and a known role are required"))
+			return
+		}
+		if err := g.CreateUser(req.Username, req.Password, req.Role); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		g.writeUser(w, http.StatusCreated, req.Username)
+	})
+	mux.HandleFunc("GET /users/{username}", func(w http.ResponseWriter, r *http.Request) {
+		g.writeUser(w, http.StatusOK, r.PathValue("username"))
+	})
+	mux.HandleFunc("DELETE /users/{username}", func(w http.ResponseWriter, r *http.Request) {
+		if err := g.DeleteUser(r.PathValue("username")); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("PUT /users/{username}/role", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Role Role `json:"role"`
+		}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.Role.rank() == 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown role: %s", req.Role))
+			return
+		}
+		if _, err := g.SetRole(r.PathValue("username"), req.Role); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		g.writeUser(w, http.StatusOK, r.PathValue("username"))
+	})
+	mux.HandleFunc("PUT /users/{username}/password", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Password string `json:"password"`
+		}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.Password == "" {
+			writeError(w, http.StatusBadRequest, errors.New("password is required"))
+			return
+		}
+		revoked, err := g.SetPassword(r.PathValue("username"), req.Password)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"sessions_revoked": revoked})
+	})
+	mux.HandleFunc("POST /users/{username}/unlock", func(w http.ResponseWriter, r *http.Request) {
+		if err := g.UnlockUser(r.PathValue("username")); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		g.writeUser(w, http.StatusOK, r.PathValue("username"))
+	})
+	mux.HandleFunc("POST /users/{username}/revoke", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := g.GetUserInfo(r.PathValue("username")); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		revoked, err := g.RevokeUserSessions(r.PathValue("username"))
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"sessions_revoked": revoked})
+	})
+	mux.HandleFunc("GET /sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, g.ListSessions())
+	})
+	mux.HandleFunc("DELETE /sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := g.RevokeSessionID(r.PathValue("id")); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /ip-policy", func(w http.ResponseWriter, r *http.Request) {
+		policy := g.IPPolicy()
+		writeJSON(w, http.StatusOK, struct {
+			Whitelist []netip.Prefix `json:"whitelist"`
+			Denylist  []netip.Prefix `json:"denylist"`
+			Require   bool           `json:"require_whitelist"`
+		}{policy.Allow, policy.Deny, policy.Require || g.config.RequireIPWhitelist})
+	})
+	editRules := func(add bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			rule := r.URL.Query().Get("rule")
+			if add {
+				var req struct {
+					Rule string `json:"rule"`
+				}
+				if !readJSON(w, r, &req) {
+					return
+				}
+				rule = req.Rule
+			}
+			var edit func(string) error
+			switch r.PathValue("list") {
+			case "whitelist":
+				edit = g.RemoveFromWhitelist
+				if add {
+					edit = g.AddToWhitelist
+				}
+			case "denylist":
+				edit = g.RemoveFromDenylist
+				if add {
+					edit = g.AddToDenylist
+				}
+			default:
+				http.NotFound(w, r)
+				return
+			}
+			if err := edit(rule); err != nil {
+				status := http.StatusBadRequest
+				if !errors.Is(err, ErrInvalidIPPolicy) {
+					status = http.StatusInternalServerError
+				}
+				writeError(w, status, err)
+				return
+			}
+			// Without a policy file the edit is lost when the service restarts
+			writeJSON(w, http.StatusOK, map[string]bool{"persisted": g.config.IPPolicyFile != ""})
+		}
+	}
+	mux.HandleFunc("POST /ip-policy/{list}", editRules(true))
+	mux.HandleFunc("DELETE /ip-policy/{list}", editRules(false))
+	mux.HandleFunc("GET /api-keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, g.ListAPIKeys())
+	})
+	mux.HandleFunc("POST /api-keys", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name       string       `json:"name"`
+			Role       Role         `json:"role"`
+			Scopes     []Permission `json:"scopes"`
+			AllowedIPs []string     `json:"allowed_ips"`
+			RateLimit  int          `json:"rate_limit"`
+			ExpiresIn  string       `json:"expires_in"`
+		}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		spec := APIKey{Name: req.Name, Role: req.Role, Scopes: req.Scopes, RateLimit: req.RateLimit}
+		for _, rule := range req.AllowedIPs {
+			prefix, err := ParseIPRule(rule)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			spec.AllowedIPs = append(spec.AllowedIPs, prefix)
+		}
+		if req.ExpiresIn != "" {
+			ttl, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || ttl <= 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid expires_in: %q", req.ExpiresIn))
+				return
+			}
+			spec.ExpiresAt = time.Now().Add(ttl)
+		}
+		key, record, err := g.CreateAPIKey(spec)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, struct {
+			Key    string  `json:"key"`
+			APIKey *APIKey `json:"api_key"`
+		}{key, record})
+	})
+	mux.HandleFunc("POST /api-keys/{id}/rotate", func(w http.ResponseWriter, r *http.Request) {
+		key, err := g.RotateAPIKey(r.PathValue("id"))
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"key": key})
+	})
+	mux.HandleFunc("DELETE /api-keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := g.RevokeAPIKey(r.PathValue("id")); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	audited := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requireClientCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			writeError(w, http.StatusForbidden, ErrClientCertificate)
+			return
+		}
+		if r.Method == http.MethodGet {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+		admin := ""
+		if session, ok := SessionFromContext(r.Context()); ok {
+			admin = session.Username
+		}
+		g.audit(AuditAdminRequest, admin, ClientIP(r), map[string]string{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"status": fmt.Sprint(rec.status),
+		})
+	})
+	return g.PermissionMiddleware(audited, PermissionNodeAdmin)
+}
+
+// writeUser writes the user's info, or 404 for an unknown user
+func (g *Guardian) writeUser(w http.ResponseWriter, status int, username string) {
+	user, err := g.GetUserInfo(username)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, status, user.Info(time.Now()))
+}
+
+// writeAdminError answers 404 for an unknown user or session, 409 for a
+// taken username and 500 otherwise
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrInvalidToken), errors.Is(err, ErrAPIKeyNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrInvalidAPIKey):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, ErrUserExists):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// statusRecorder remembers the status a handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
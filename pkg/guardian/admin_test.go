@@ -0,0 +1,136 @@
+package guardian
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// adminRequest calls h with token, over mutual TLS when tlsState has a
+// verified chain
+func adminRequest(h http.Handler, token, method, target, body string, tlsState *tls.ConnectionState) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.RemoteAddr = "10.0.0.1:4000"
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.TLS = tlsState
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminHandler(t *testing.T) {
+	g, sink := auditedGuardian(t)
+	g.CreateUser("arthur", "excalibur123", RoleKingArthur)
+	g.CreateUser("lancelot", "camelot456", RoleKnight)
+	arthur, _ := g.Authenticate("arthur", "excalibur123", "10.0.0.1")
+	lancelot, _ := g.Authenticate("lancelot", "camelot456", "10.0.0.1")
+
+	h := g.AdminHandler(true)
+	mtls := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	call := func(method, target, body string) *httptest.ResponseRecorder {
+		return adminRequest(h, arthur, method, target, body, mtls)
+	}
+
+	if rec := adminRequest(h, arthur, http.MethodGet, "/users", "", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Without a client certificate got %d, want 403", rec.Code)
+	}
+	if rec := adminRequest(h, lancelot, http.MethodGet, "/users", "", mtls); rec.Code != http.StatusForbidden {
+		t.Errorf("Knight got %d, want 403", rec.Code)
+	}
+
+	rec := call(http.MethodPost, "/users", `{"username": "pip", "password": "squire789", "role": "squire"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Create user got %d: %s", rec.Code, rec.Body)
+	}
+	var pip UserInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &pip); err != nil || pip.Role != RoleSquire || pip.TwoFactor != "off" {
+		t.Errorf("Created %+v (%v)", pip, err)
+	}
+	if strings.Contains(rec.Body.String(), "PasswordHash") {
+		t.Error("User info exposes the password hash")
+	}
+	if rec := call(http.MethodPost, "/users", `{"username": "pip", "password": "x", "role": "squire"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Duplicate user got %d, want 409", rec.Code)
+	}
+	if rec := call(http.MethodPost, "/users", `{"username": "bors", "password": "x", "role": "wizard"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Unknown role got %d, want 400", rec.Code)
+	}
+	if rec := call(http.MethodPut, "/users/pip/role", `{"role": "knight"}`); rec.Code != http.StatusOK {
+		t.Errorf("Role change got %d: %s", rec.Code, rec.Body)
+	}
+	if user, _ := g.GetUserInfo("pip"); user.Role != RoleKnight {
+		t.Errorf("pip is %s after the role change", user.Role)
+	}
+	// A demotion revokes the sessions issued with the higher role
+	knightToken, _ := g.Authenticate("pip", "squire789", "10.0.0.2")
+	if rec := call(http.MethodPut, "/users/pip/role", `{"role": "squire"}`); rec.Code != http.StatusOK {
+		t.Errorf("Demotion got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := g.ValidateSession(knightToken); err == nil {
+		t.Error("Expected the demotion to revoke pip's session")
+	}
+	if rec := call(http.MethodGet, "/users/nobody", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Unknown user got %d, want 404", rec.Code)
+	}
+
+	// Sessions are listed by ID and revoked without their tokens
+	pipToken, _ := g.Authenticate("pip", "squire789", "10.0.0.2")
+	rec = call(http.MethodGet, "/sessions", "")
+	var sessions []SessionInfo
+	json.Unmarshal(rec.Body.Bytes(), &sessions)
+	if len(sessions) != 3 || strings.Contains(rec.Body.String(), pipToken) {
+		t.Fatalf("Sessions = %s", rec.Body)
+	}
+	if rec := call(http.MethodDelete, "/sessions/"+SessionID(pipToken), ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Revoke session got %d", rec.Code)
+	}
+	if _, err := g.ValidateSession(pipToken); err == nil {
+		t.Error("Expected the revoked session to be invalid")
+	}
+	if rec := call(http.MethodDelete, "/sessions/"+SessionID(pipToken), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Revoking again got %d, want 404", rec.Code)
+	}
+
+	// Without a policy file the rules are not persisted
+	if rec := call(http.MethodPost, "/ip-policy/denylist", `{"rule": "192.0.2.0/24"}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"persisted":false`) {
+		t.Errorf("Deny rule got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := call(http.MethodPost, "/ip-policy/denylist", `{"rule": "not an ip"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Invalid rule got %d, want 400", rec.Code)
+	}
+	if rec := call(http.MethodGet, "/ip-policy", ""); !strings.Contains(rec.Body.String(), `"denylist":["192.0.2.0/24"]`) {
+		t.Errorf("IP policy = %s", rec.Body)
+	}
+	if rec := call(http.MethodDelete, "/ip-policy/denylist?rule=192.0.2.0/24", ""); rec.Code != http.StatusOK || len(g.IPPolicy().Deny) != 0 {
+		t.Errorf("Removing the deny rule got %d, rules %v", rec.Code, g.IPPolicy().Deny)
+	}
+
+	if rec := call(http.MethodDelete, "/users/pip", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Delete user got %d", rec.Code)
+	}
+	if _, err := g.GetUserInfo("pip"); err == nil {
+		t.Error("Expected pip to be deleted")
+	}
+
+	admin := 0
+	for _, event := range sink.events {
+		if event.Type == AuditAdminRequest {
+			admin++
+			if event.Username != "arthur" || event.Details["status"] == "" {
+				t.Errorf("Admin request audited as %+v", event)
+			}
+		}
+	}
+	// Every change, including the refused ones, but no reads
+	if admin != 11 {
+		t.Errorf("Audited %d admin requests, want 11: %v", admin, sink.types())
+	}
+	if !slices.Contains(sink.types(), AuditRoleEscalated) {
+		t.Error("Expected the promotion audited as a role escalation")
+	}
+}
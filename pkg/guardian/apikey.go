@@ -0,0 +1,295 @@
+package guardian
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// APIKeyPrefix starts every API key, so middleware can tell keys from
+	// session tokens
+	APIKeyPrefix = "exs_"
+	// APIKeyHeader carries an API key, as does "Authorization: Bearer"
+	APIKeyHeader = "X-API-Key"
+)
+
+var (
+	// ErrAPIKeyNotFound indicates an unknown API key ID
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrInvalidAPIKey indicates API key settings CreateAPIKey refuses
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// APIKey authenticates a machine caller, such as an exchange or the miner
+// daemon, without a password or session. Only the SHA-256 hash of the key
+// is kept; the key itself is shown once, when created or rotated.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+	// Scopes limits the key to these of its role's permissions, all of
+	// them when empty. Routes requiring a role check the role alone.
+	Scopes []Permission `json:"scopes,omitempty"`
+	// AllowedIPs binds the key to these networks, any when empty
+	AllowedIPs []netip.Prefix `json:"allowed_ips,omitempty"`
+	// RateLimit is the requests allowed per RateLimitWindow,
+	// Config.RateLimitRequests when zero
+	RateLimit  int       `json:"rate_limit,omitempty"`
+	Hash       []byte    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	RotatedAt  time.Time `json:"rotated_at,omitzero"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"` // zero for never
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+}
+
+// allowsIP reports whether the key may be used from ip
+func (k *APIKey) allowsIP(ip string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+	addr, ok := parseIP(ip)
+	return ok && containsIP(k.AllowedIPs, addr)
+}
+
+// matches reports whether key is the API key k stands for, unexpired at now
+func (k *APIKey) matches(key string, now time.Time) bool {
+	hash := sha256.Sum256([]byte(key))
+	if subtle.ConstantTimeCompare(hash[:], k.Hash) != 1 {
+		return false
+	}
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// newAPIKey returns a fresh key for id and its hash
+func newAPIKey(id string) (string, []byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := APIKeyPrefix + id + "_" + hex.EncodeToString(secret)
+	hash := sha256.Sum256([]byte(key))
+	return key, hash[:], nil
+}
+
+// apiKeyID returns the ID part of an API key
+func apiKeyID(key string) string {
+	id, _, _ := strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	return id
+}
+
+// CreateAPIKey stores a key with spec's name, role, scopes, allowed IPs,
+// rate limit and expiry, and returns the key, which cannot be recovered
+// later, with its record
+func (g *Guardian) CreateAPIKey(spec APIKey) (string, *APIKey, error) {
+	if spec.Name == "" {
+		return "", nil, fmt.Errorf("%w: a name is required", ErrInvalidAPIKey)
+	}
+	if !spec.Role.Valid() {
+		return "", nil, fmt.Errorf("%w: unknown role %s", ErrInvalidAPIKey, spec.Role)
+	}
+	for _, scope := range spec.Scopes {
+		if !g.HasPermission(spec.Role, scope) {
+			return "", nil, fmt.Errorf("%w: role %s does not hold %s", ErrInvalidAPIKey, spec.Role, scope)
+		}
+	}
+	if spec.RateLimit < 0 {
+		return "", nil, fmt.Errorf("%w: rate limit %d", ErrInvalidAPIKey, spec.RateLimit)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key ID: %w", err)
+	}
+	key, hash, err := newAPIKey(hex.EncodeToString(id))
+	if err != nil {
+		return "", nil, err
+	}
+	record := &APIKey{
+		ID:         hex.EncodeToString(id),
+		Name:       spec.Name,
+		Role:       spec.Role,
+		Scopes:     slices.Clone(spec.Scopes),
+		AllowedIPs: slices.Clone(spec.AllowedIPs),
+		RateLimit:  spec.RateLimit,
+		Hash:       hash,
+		CreatedAt:  time.Now(),
+		ExpiresAt:  spec.ExpiresAt,
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.store.PutAPIKey(record); err != nil {
+		return "", nil, fmt.Errorf("failed to persist API key: %w", err)
+	}
+	g.apiKeys[record.ID] = record
+	g.audit(AuditAPIKeyCreated, record.Name, "", map[string]string{"id": record.ID, "role": string(record.Role)})
+	info := *record
+	return key, &info, nil
+}
+
+// RotateAPIKey replaces the key with ID id by a new one with the same
+// settings, which it returns. The old key stops working at once.
+func (g *Guardian) RotateAPIKey(id string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	record, exists := g.apiKeys[id]
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	key, hash, err := newAPIKey(id)
+	if err != nil {
+		return "", err
+	}
+	updated := *record
+	updated.Hash = hash
+	updated.RotatedAt = time.Now()
+	if err := g.store.PutAPIKey(&updated); err != nil {
+		return "", fmt.Errorf("failed to persist API key: %w", err)
+	}
+	*record = updated
+	g.audit(AuditAPIKeyRotated, record.Name, "", map[string]string{"id": id})
+	return key, nil
+}
+
+// RevokeAPIKey deletes the key with ID id
+func (g *Guardian) RevokeAPIKey(id string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	record, exists := g.apiKeys[id]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	if err := g.store.DeleteAPIKey(id); err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	delete(g.apiKeys, id)
+	if limiter, ok := g.keyLimiters[id]; ok {
+		limiter.Stop()
+		delete(g.keyLimiters, id)
+	}
+	g.audit(AuditAPIKeyRevoked, record.Name, "", map[string]string{"id": id})
+	return nil
+}
+
+// ListAPIKeys returns the API keys, oldest first
+func (g *Guardian) ListAPIKeys() []APIKey {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	keys := make([]APIKey, 0, len(g.apiKeys))
+	for _, record := range g.apiKeys {
+		keys = append(keys, *record)
+	}
+	slices.SortFunc(keys, func(a, b APIKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return keys
+}
+
+// authorizeAPIKey is authorize for an API key: the key's own rate limit
+// replaces the per-IP one, and the key must be used from its allowed IPs.
+// Only a key that matches is charged to its own limit; failures count
+// against the client's IP, so guessing at a key cannot exhaust it.
+func (g *Guardian) authorizeAPIKey(key, ip string, allowed func(*Session) bool) (*Session, error) {
+	id := apiKeyID(key)
+	g.mu.Lock()
+	record, exists := g.apiKeys[id]
+	var limiter Limiter
+	var k APIKey
+	if exists {
+		limiter = g.keyLimiter(record)
+		k = *record
+	}
+	g.mu.Unlock()
+
+	if !g.ipAllowed(ip, "") {
+		authFailed("ip_denied")
+		return nil, ErrUnauthorized
+	}
+	now := time.Now()
+	if !exists || !k.matches(key, now) {
+		if !g.rateLimiter.Allow(ip) {
+			authFailed("rate_limited")
+			return nil, ErrRateLimitExceeded
+		}
+		authFailed("invalid_token")
+		return nil, ErrInvalidToken
+	}
+	if !limiter.Allow(id) {
+		authFailed("rate_limited")
+		return nil, ErrRateLimitExceeded
+	}
+	if !k.allowsIP(ip) || !g.ipAllowed(ip, k.Role) {
+		authFailed("ip_denied")
+		return nil, ErrUnauthorized
+	}
+	session := &Session{
+		Username:   k.Name,
+		Role:       k.Role,
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastSeenAt: now,
+		IPAddress:  ip,
+		APIKey:     k.ID,
+		Scopes:     k.Scopes,
+	}
+	if !allowed(session) {
+		authFailed("forbidden")
+		return nil, ErrUnauthorized
+	}
+	g.touchAPIKey(id, now)
+	return session, nil
+}
+
+// lookupAPIKey returns the record of key if it is valid at now
+func (g *Guardian) lookupAPIKey(key string, now time.Time) (APIKey, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	record, exists := g.apiKeys[apiKeyID(key)]
+	if !exists || !record.matches(key, now) {
+		return APIKey{}, false
+	}
+	return *record, true
+}
+
+// keyLimiter returns the rate limiter of record, creating it on first use.
+// g.mu must be held.
+func (g *Guardian) keyLimiter(record *APIKey) Limiter {
+	limiter, ok := g.keyLimiters[record.ID]
+	if !ok {
+		limit := record.RateLimit
+		if limit == 0 {
+			limit = g.config.RateLimitRequests
+		}
+		if g.buckets != nil {
+			limiter = NewBucketRateLimiter(g.buckets, "apikey|", limit, g.config.RateLimitWindow)
+		} else {
+			limiter = NewRateLimiter(limit, g.config.RateLimitWindow)
+		}
+		g.keyLimiters[record.ID] = limiter
+	}
+	return limiter
+}
+
+// touchAPIKey records use of the key with ID id, persisting it at most
+// every lastSeenPersistInterval
+func (g *Guardian) touchAPIKey(id string, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	record, exists := g.apiKeys[id]
+	if !exists {
+		return
+	}
+	persist := now.Sub(record.LastUsedAt) >= lastSeenPersistInterval
+	record.LastUsedAt = now
+	if persist {
+		// A failed write only loses the time of use
+		g.store.PutAPIKey(record)
+	}
+}
@@ -0,0 +1,178 @@
+package guardian
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	g, sink := auditedGuardian(t)
+	key, record, err := g.CreateAPIKey(APIKey{
+		Name:       "exchange",
+		Role:       RoleKnight,
+		Scopes:     []Permission{PermissionForgeSubmit},
+		AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, APIKeyPrefix+record.ID+"_") {
+		t.Errorf("Key %q does not carry ID %s", key, record.ID)
+	}
+
+	session, err := g.AuthorizePermission(key, "10.1.2.3", PermissionForgeSubmit)
+	if err != nil {
+		t.Fatalf("AuthorizePermission() = %v", err)
+	}
+	if session.Username != "exchange" || session.APIKey != record.ID || session.Token != "" {
+		t.Errorf("Session = %+v", session)
+	}
+	if _, err := g.Authorize(key, "10.1.2.3", RoleSquire); err != nil {
+		t.Errorf("Knight key refused a Squire route: %v", err)
+	}
+	if _, err := g.AuthorizePermission(key, "10.1.2.3", PermissionTreasuryRead); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected the scopes to refuse treasury.read, got %v", err)
+	}
+	if _, err := g.AuthorizePermission(key, "192.0.2.1", PermissionForgeSubmit); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a use outside the allowed IPs refused, got %v", err)
+	}
+	forged := APIKeyPrefix + record.ID + "_" + strings.Repeat("0", 64)
+	if _, err := g.Authorize(forged, "10.1.2.3", RoleKnight); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a wrong secret refused, got %v", err)
+	}
+
+	rotated, err := g.RotateAPIKey(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Authorize(key, "10.1.2.3", RoleKnight); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the old key refused after rotation, got %v", err)
+	}
+	if _, err := g.Authorize(rotated, "10.1.2.3", RoleKnight); err != nil {
+		t.Errorf("Rotated key refused: %v", err)
+	}
+
+	if err := g.RevokeAPIKey(record.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Authorize(rotated, "10.1.2.3", RoleKnight); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the revoked key refused, got %v", err)
+	}
+	if err := g.RevokeAPIKey(record.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Revoking again = %v", err)
+	}
+	want := []string{AuditAPIKeyCreated, AuditAPIKeyRotated, AuditAPIKeyRevoked}
+	if got := sink.types(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Audited %v, want %v", got, want)
+	}
+}
+
+func TestAPIKeyRefusedSettings(t *testing.T) {
+	g := NewGuardian(nil)
+	for _, spec := range []APIKey{
+		{Role: RoleKnight},
+		{Name: "x", Role: "wizard"},
+		{Name: "x", Role: RoleSquire, Scopes: []Permission{PermissionForgeSubmit}},
+		{Name: "x", Role: RoleKnight, RateLimit: -1},
+	} {
+		if _, _, err := g.CreateAPIKey(spec); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("CreateAPIKey(%+v) = %v, want ErrInvalidAPIKey", spec, err)
+		}
+	}
+}
+
+func TestAPIKeyRateLimit(t *testing.T) {
+	g := NewGuardian(nil)
+	key, _, _ := g.CreateAPIKey(APIKey{Name: "miner", Role: RoleKnight, RateLimit: 2})
+
+	h := g.Middleware(protectedHandler, RoleKnight)
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodPost, "/forge", nil)
+		req.RemoteAddr = "10.0.0.2:4000"
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		codes[i] = rec.Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Statuses = %v, want two accepted then 429", codes)
+	}
+}
+
+func TestAPIKeyFailuresLimitedByIP(t *testing.T) {
+	config := testConfig()
+	config.RateLimitRequests = 3
+	g := NewGuardian(config)
+	key, _, _ := g.CreateAPIKey(APIKey{Name: "miner", Role: RoleKnight, RateLimit: 2})
+	guess := key[:len(key)-1] + "0"
+	if guess == key {
+		guess = key[:len(key)-1] + "1"
+	}
+
+	h := g.Middleware(protectedHandler, RoleKnight)
+	serveKey := func(key, ip string) int {
+		req := httptest.NewRequest(http.MethodPost, "/forge", nil)
+		req.RemoteAddr = ip + ":4000"
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Wrong secrets for the key's ID are throttled by the guesser's IP
+	codes := make([]int, 4)
+	for i := range codes {
+		codes[i] = serveKey(guess, "10.0.0.66")
+	}
+	if codes[0] != http.StatusUnauthorized || codes[2] != http.StatusUnauthorized || codes[3] != http.StatusTooManyRequests {
+		t.Errorf("Statuses of wrong keys = %v, want three 401 then 429", codes)
+	}
+	// and leave the key's own limit to its holder
+	if code := serveKey(key, "10.0.0.2"); code != http.StatusOK {
+		t.Errorf("Status of the key after failed guesses = %d, want 200", code)
+	}
+	if code := serveKey(key, "10.0.0.2"); code != http.StatusOK {
+		t.Errorf("Status of the key's second request = %d, want 200", code)
+	}
+}
+
+func TestAPIKeyPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guardian.db")
+	store, err := NewBoltStore(path, testStoreKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGuardianWithStore(testConfig(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := g.CreateAPIKey(APIKey{Name: "exchange", Role: RoleKnight})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+
+	store, err = NewBoltStore(path, testStoreKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err = NewGuardianWithStore(testConfig(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if _, err := g.Authorize(key, "10.0.0.1", RoleKnight); err != nil {
+		t.Errorf("Key refused after reopening: %v", err)
+	}
+	keys, _ := store.ListAPIKeys()
+	if len(keys) != 1 || len(keys[0].Hash) != sha256.Size {
+		t.Errorf("Stored keys = %+v", keys)
+	}
+}
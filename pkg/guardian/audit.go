@@ -0,0 +1,447 @@
+package guardian
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/metrics"
+)
+
+// Audit event types
+const (
+	AuditLoginSuccess     = "login_success"
+	AuditLoginFailure     = "login_failure"
+	AuditUserCreated      = "user_created"
+	AuditUserDeleted      = "user_deleted"
+	AuditPasswordChanged  = "password_changed"
+	AuditRoleChanged      = "role_changed"
+	AuditRoleEscalated    = "role_escalated"
+	AuditSessionRevoked   = "session_revoked"
+	AuditWhitelistAdded   = "whitelist_added"
+	AuditWhitelistRemoved = "whitelist_removed"
+	AuditDenylistAdded    = "denylist_added"
+	AuditDenylistRemoved  = "denylist_removed"
+	AuditIPPolicyLoaded   = "ip_policy_loaded"
+	AuditIPPolicyRejected = "ip_policy_rejected"
+	AuditTOTPEnabled      = "totp_enabled"
+	AuditTOTPDisabled     = "totp_disabled"
+	AuditAccountLocked    = "account_locked"
+	AuditAccountUnlocked  = "account_unlocked"
+	AuditJWTKeyRotated    = "jwt_key_rotated"
+	AuditAdminRequest     = "admin_request"
+	AuditAPIKeyCreated    = "api_key_created"
+	AuditAPIKeyRotated    = "api_key_rotated"
+	AuditAPIKeyRevoked    = "api_key_revoked"
+)
+
+var (
+	// ErrAuditChainBroken indicates an audit log that was altered, reordered
+	// or has missing events
+	ErrAuditChainBroken = errors.New("audit log hash chain broken")
+	// ErrAuditQueueFull indicates an event dropped by a sink that is behind
+	ErrAuditQueueFull = errors.New("audit queue full")
+)
+
+// AuditEvent is one security event. Each event carries the hash of the one
+// before it, so altering, removing or reordering events breaks the chain.
+type AuditEvent struct {
+	Seq       uint64            `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Type      string            `json:"type"`
+	Username  string            `json:"username,omitempty"`
+	IPAddress string            `json:"ip,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prev_hash"`
+	Hash      string            `json:"hash"`
+}
+
+// computeHash hashes the event's JSON encoding without its own hash
+func (e *AuditEvent) computeHash() string {
+	unhashed := *e
+	unhashed.Hash = ""
+	data, _ := json.Marshal(&unhashed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditSink stores or forwards audit events
+type AuditSink interface {
+	Record(event *AuditEvent) error
+	Close() error
+}
+
+// auditTail is implemented by sinks that can read back their last event,
+// so the chain continues across restarts
+type auditTail interface {
+	last() (*AuditEvent, error)
+}
+
+// AuditLog numbers and hash-chains security events and hands them to a sink.
+// It is safe for concurrent use.
+type AuditLog struct {
+	mu   sync.Mutex
+	sink AuditSink
+	seq  uint64
+	prev string
+}
+
+// NewAuditLog writes events to sink, continuing the chain from the sink's
+// last event when it can be read back
+func NewAuditLog(sink AuditSink) (*AuditLog, error) {
+	a := &AuditLog{sink: sink}
+	if tail, ok := sink.(auditTail); ok {
+		last, err := tail.last()
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			a.seq, a.prev = last.Seq, last.Hash
+		}
+	}
+	return a, nil
+}
+
+// Record chains an event and writes it to the sink. The event keeps its
+// place in the chain even if the write fails, so VerifyAuditChain reports
+// the gap.
+func (a *AuditLog) Record(eventType, username, ipAddress string, details map[string]string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.seq++
+	event := &AuditEvent{
+		Seq:       a.seq,
+		Time:      time.Now().UTC(),
+		Type:      eventType,
+		Username:  username,
+		IPAddress: ipAddress,
+		Details:   details,
+		PrevHash:  a.prev,
+	}
+	event.Hash = event.computeHash()
+	a.prev = event.Hash
+	return a.sink.Record(event)
+}
+
+// Close closes the sink
+func (a *AuditLog) Close() error {
+	return a.sink.Close()
+}
+
+// VerifyAuditChain checks that events form an unbroken chain from the first
+// event ever recorded
+func VerifyAuditChain(events []*AuditEvent) error {
+	prev := ""
+	for i, event := range events {
+		if event.Seq != uint64(i)+1 {
+			return fmt.Errorf("%w: expected event %d, found %d", ErrAuditChainBroken, i+1, event.Seq)
+		}
+		if event.PrevHash != prev {
+			return fmt.Errorf("%w: event %d does not follow event %d", ErrAuditChainBroken, event.Seq, i)
+		}
+		if event.Hash != event.computeHash() {
+			return fmt.Errorf("%w: event %d was modified", ErrAuditChainBroken, event.Seq)
+		}
+		prev = event.Hash
+	}
+	return nil
+}
+
+// AuditQuery selects audit events; zero fields match everything
+type AuditQuery struct {
+	Type      string
+	Username  string
+	IPAddress string
+	Since     time.Time
+	Until     time.Time
+}
+
+// Matches reports whether event satisfies every set field of q
+func (q *AuditQuery) Matches(event *AuditEvent) bool {
+	switch {
+	case q.Type != "" && event.Type != q.Type:
+		return false
+	case q.Username != "" && event.Username != q.Username:
+		return false
+	case q.IPAddress != "" && event.IPAddress != q.IPAddress:
+		return false
+	case !q.Since.IsZero() && event.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && event.Time.After(q.Until):
+		return false
+	}
+	return true
+}
+
+// DefaultAuditPath returns the default audit log path,
+// $HOME/.excalibur-exs/guardian/audit.log
+func DefaultAuditPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	return filepath.Join(home, ".excalibur-exs", "guardian", "audit.log"), nil
+}
+
+// FileAuditSink appends events to a file as JSON lines, syncing each one
+type FileAuditSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileAuditSink opens path for appending, creating it and its directory.
+// Only one process may append to a file, or the chain forks.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileAuditSink{path: path, file: file}, nil
+}
+
+// Record appends event
+func (s *FileAuditSink) Record(event *AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return s.file.Sync()
+}
+
+func (s *FileAuditSink) last() (*AuditEvent, error) {
+	events, err := ReadAuditLog(s.path)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return events[len(events)-1], nil
+}
+
+// Close closes the file
+func (s *FileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+// ReadAuditLog reads the events written by a FileAuditSink
+func ReadAuditLog(path string) ([]*AuditEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []*AuditEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("audit log %s line %d: %w", path, line, err)
+		}
+		events = append(events, &event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return events, nil
+}
+
+// webhookQueueSize bounds the events waiting to be posted
+const webhookQueueSize = 1024
+
+// webhookAuditSink posts each event as JSON from a background goroutine, so
+// a slow endpoint never holds up authentication
+type webhookAuditSink struct {
+	url    string
+	client *http.Client
+	queue  chan []byte
+	done   chan struct{}
+}
+
+// NewWebhookAuditSink posts events to url. Events that cannot be delivered
+// after a retry, or that arrive while webhookQueueSize events are waiting,
+// are counted in the exs_guardian_audit_write_errors_total metric.
+func NewWebhookAuditSink(url string) (AuditSink, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("invalid audit webhook URL: %s", url)
+	}
+	s := &webhookAuditSink{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan []byte, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *webhookAuditSink) Record(event *AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	select {
+	case s.queue <- data:
+		return nil
+	default:
+		return ErrAuditQueueFull
+	}
+}
+
+func (s *webhookAuditSink) run() {
+	defer close(s.done)
+	for data := range s.queue {
+		if err := s.post(data); err != nil {
+			time.Sleep(time.Second)
+			if err := s.post(data); err != nil {
+				metrics.AuditWriteErrors.Inc()
+			}
+		}
+	}
+}
+
+func (s *webhookAuditSink) post(data []byte) error {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// Close delivers the queued events and stops the sink
+func (s *webhookAuditSink) Close() error {
+	close(s.queue)
+	<-s.done
+	return nil
+}
+
+// multiAuditSink records every event in each of its sinks
+type multiAuditSink []AuditSink
+
+func (m multiAuditSink) Record(event *AuditEvent) error {
+	var errs []error
+	for _, sink := range m {
+		errs = append(errs, sink.Record(event))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiAuditSink) last() (*AuditEvent, error) {
+	for _, sink := range m {
+		if tail, ok := sink.(auditTail); ok {
+			return tail.last()
+		}
+	}
+	return nil, nil
+}
+
+func (m multiAuditSink) Close() error {
+	var errs []error
+	for _, sink := range m {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// OpenAuditSink opens the comma-separated sinks in spec:
+//
+//	file[:PATH]      JSON lines, at DefaultAuditPath without a path
+//	syslog[:TAG]     the local syslog daemon, tagged "guardian" by default
+//	webhook:URL      HTTP POST of each event
+func OpenAuditSink(spec string) (AuditSink, error) {
+	var sinks multiAuditSink
+	for _, part := range strings.Split(spec, ",") {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(part), ":")
+		var (
+			sink AuditSink
+			err  error
+		)
+		switch kind {
+		case "file":
+			if arg == "" {
+				if arg, err = DefaultAuditPath(); err != nil {
+					break
+				}
+			}
+			sink, err = NewFileAuditSink(arg)
+		case "syslog":
+			if arg == "" {
+				arg = "guardian"
+			}
+			sink, err = NewSyslogAuditSink(arg)
+		case "webhook":
+			sink, err = NewWebhookAuditSink(arg)
+		default:
+			err = fmt.Errorf("unknown audit sink: %q (use file, syslog or webhook)", part)
+		}
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return sinks, nil
+}
+
+// OpenAuditLog opens the sinks in spec, as OpenAuditSink does, and continues
+// their chain
+func OpenAuditLog(spec string) (*AuditLog, error) {
+	sink, err := OpenAuditSink(spec)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := NewAuditLog(sink)
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	return audit, nil
+}
+
+// SetAuditLog records the Guardian's security events in audit. The Guardian
+// closes it on Close.
+func (g *Guardian) SetAuditLog(audit *AuditLog) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.auditLog = audit
+}
+
+// audit records a security event if an audit log is set; callers must hold
+// g.mu
+func (g *Guardian) audit(eventType, username, ipAddress string, details map[string]string) {
+	if g.auditLog == nil {
+		return
+	}
+	if err := g.auditLog.Record(eventType, username, ipAddress, details); err != nil {
+		metrics.AuditWriteErrors.Inc()
+	}
+}
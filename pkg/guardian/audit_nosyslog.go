@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package guardian
+
+import "errors"
+
+// NewSyslogAuditSink is unavailable on platforms without syslog
+func NewSyslogAuditSink(tag string) (AuditSink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,39 @@
+//go:build !windows && !plan9
+
+package guardian
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+)
+
+// syslogAuditSink sends each event as JSON to the authpriv facility
+type syslogAuditSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogAuditSink sends events to the local syslog daemon under tag.
+// Failed logins are logged as warnings, other events as notices.
+func NewSyslogAuditSink(tag string) (AuditSink, error) {
+	writer, err := syslog.New(syslog.LOG_AUTHPRIV|syslog.LOG_NOTICE, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &syslogAuditSink{writer: writer}, nil
+}
+
+func (s *syslogAuditSink) Record(event *AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.Type == AuditLoginFailure {
+		return s.writer.Warning(string(data))
+	}
+	return s.writer.Notice(string(data))
+}
+
+func (s *syslogAuditSink) Close() error {
+	return s.writer.Close()
+}
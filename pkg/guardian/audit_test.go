@@ -0,0 +1,286 @@
+package guardian
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryAuditSink keeps events for inspection
+type memoryAuditSink struct {
+	mu     sync.Mutex
+	events []*AuditEvent
+}
+
+func (m *memoryAuditSink) Record(event *AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memoryAuditSink) Close() error { return nil }
+
+func (m *memoryAuditSink) types() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	types := make([]string, len(m.events))
+	for i, event := range m.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func auditedGuardian(t *testing.T) (*Guardian, *memoryAuditSink) {
+	t.Helper()
+	sink := &memoryAuditSink{}
+	audit, err := NewAuditLog(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGuardian(nil)
+	g.SetAuditLog(audit)
+	return g, sink
+}
+
+func TestAuditEvents(t *testing.T) {
+	g, sink := auditedGuardian(t)
+
+	g.CreateUser("galahad", "grail1234", RoleSquire)
+	g.Authenticate("galahad", "wrong", "10.0.0.1")
+	g.Authenticate("mordred", "treason", "10.0.0.1")
+	if _, err := g.Authenticate("galahad", "grail1234", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	g.SetRole("galahad", RoleKnight)
+	g.SetRole("galahad", RoleSquire)
+	g.SetPassword("galahad", "newgrail5678")
+	g.AddToWhitelist("10.0.0.2")
+	g.RemoveFromWhitelist("10.0.0.2")
+	g.DeleteUser("galahad")
+
+	want := []string{
+		AuditUserCreated,
+		AuditLoginFailure,
+		AuditLoginFailure,
+		AuditLoginSuccess,
+		AuditRoleEscalated,
+		AuditRoleChanged,
+		AuditPasswordChanged,
+		AuditWhitelistAdded,
+		AuditWhitelistRemoved,
+		AuditUserDeleted,
+	}
+	if got := sink.types(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Audit events = %v, want %v", got, want)
+	}
+
+	events := sink.events
+	if reason := events[1].Details["reason"]; reason != "invalid_password" {
+		t.Errorf("Expected invalid_password, got %q", reason)
+	}
+	if reason := events[2].Details["reason"]; reason != "unknown_user" {
+		t.Errorf("Expected unknown_user, got %q", reason)
+	}
+	if events[3].IPAddress != "10.0.0.1" || events[3].Username != "galahad" {
+		t.Errorf("Unexpected login event: %+v", events[3])
+	}
+	if events[4].Details["from"] != "squire" || events[4].Details["to"] != "knight" {
+		t.Errorf("Unexpected escalation details: %v", events[4].Details)
+	}
+	if events[5].Details["sessions_revoked"] != "1" {
+		t.Errorf("Expected the demotion to revoke the session: %v", events[5].Details)
+	}
+	if err := VerifyAuditChain(events); err != nil {
+		t.Errorf("VerifyAuditChain failed: %v", err)
+	}
+}
+
+func TestAuditTOTPEvents(t *testing.T) {
+	g, sink := auditedGuardian(t)
+	g.CreateUser("tristan", "isolde123", RoleKnight)
+
+	enrollment, err := g.EnableTOTP("tristan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := totpEncoding.DecodeString(enrollment.Secret)
+	if err := g.VerifyTOTP("tristan", TOTPCode(secret, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.AuthenticateWithTOTP("tristan", "isolde123", "000000", "10.0.0.1"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("Expected ErrInvalidTOTP, got %v", err)
+	}
+	g.DisableTOTP("tristan")
+
+	want := []string{AuditUserCreated, AuditTOTPEnabled, AuditLoginFailure, AuditTOTPDisabled}
+	if got := sink.types(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Audit events = %v, want %v", got, want)
+	}
+}
+
+func TestSetRoleAndDeleteUser(t *testing.T) {
+	g := NewGuardian(nil)
+	g.CreateUser("percival", "grail1234", RoleSquire)
+	token, _ := g.Authenticate("percival", "grail1234", "10.0.0.1")
+
+	if _, err := g.SetRole("percival", Role("wizard")); err == nil {
+		t.Error("Expected an unknown role to be rejected")
+	}
+	if _, err := g.SetRole("percival", RoleKnight); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := g.GetUserInfo("percival"); user.Role != RoleKnight {
+		t.Errorf("Expected knight, got %s", user.Role)
+	}
+
+	if err := g.DeleteUser("percival"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.GetUserInfo("percival"); err == nil {
+		t.Error("Expected the user to be deleted")
+	}
+	if _, err := g.ValidateSession(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the user's sessions to be revoked, got %v", err)
+	}
+	if err := g.DeleteUser("percival"); err == nil {
+		t.Error("Expected deleting a missing user to fail")
+	}
+}
+
+func TestFileAuditSinkChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+
+	record := func(types ...string) {
+		sink, err := NewFileAuditSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		audit, err := NewAuditLog(sink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, eventType := range types {
+			if err := audit.Record(eventType, "arthur", "10.0.0.1", nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		audit.Close()
+	}
+	record(AuditLoginSuccess, AuditWhitelistAdded)
+	// A restarted process continues the chain
+	record(AuditLoginFailure)
+
+	events, err := ReadAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[2].Seq != 3 || events[2].PrevHash != events[1].Hash {
+		t.Fatalf("Expected a continued chain of 3 events, got %+v", events)
+	}
+	if err := VerifyAuditChain(events); err != nil {
+		t.Fatalf("VerifyAuditChain failed: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	tampered := []func([]*AuditEvent) []*AuditEvent{
+		func(e []*AuditEvent) []*AuditEvent { e[1].Type = AuditLoginFailure; return e },
+		func(e []*AuditEvent) []*AuditEvent { return append(e[:1], e[2:]...) },
+		func(e []*AuditEvent) []*AuditEvent { return e[1:] },
+		func(e []*AuditEvent) []*AuditEvent { e[0], e[1] = e[1], e[0]; return e },
+	}
+	for i, tamper := range tampered {
+		events, _ := ReadAuditLog(path)
+		if err := VerifyAuditChain(tamper(events)); !errors.Is(err, ErrAuditChainBroken) {
+			t.Errorf("Tampering %d: expected ErrAuditChainBroken, got %v", i, err)
+		}
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	now := time.Now()
+	event := &AuditEvent{Type: AuditLoginFailure, Username: "mordred", IPAddress: "10.0.0.9", Time: now}
+
+	matching := []AuditQuery{
+		{},
+		{Type: AuditLoginFailure, Username: "mordred"},
+		{IPAddress: "10.0.0.9", Since: now.Add(-time.Minute), Until: now.Add(time.Minute)},
+	}
+	for _, q := range matching {
+		if !q.Matches(event) {
+			t.Errorf("Expected %+v to match", q)
+		}
+	}
+	other := []AuditQuery{
+		{Type: AuditLoginSuccess},
+		{Username: "arthur"},
+		{Since: now.Add(time.Minute)},
+		{Until: now.Add(-time.Minute)},
+	}
+	for _, q := range other {
+		if q.Matches(event) {
+			t.Errorf("Expected %+v not to match", q)
+		}
+	}
+}
+
+func TestWebhookAuditSink(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []AuditEvent
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event AuditEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	sink, err := OpenAuditSink("webhook:" + srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, _ := NewAuditLog(sink)
+	audit.Record(AuditUserCreated, "bedivere", "", map[string]string{"role": "knight"})
+	audit.Record(AuditUserDeleted, "bedivere", "", nil)
+	// Close delivers the queued events
+	audit.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Details["role"] != "knight" || received[1].PrevHash != received[0].Hash {
+		t.Errorf("Unexpected webhook events: %+v", received)
+	}
+}
+
+func TestOpenAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := OpenAuditSink("file:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sink.(*FileAuditSink); !ok {
+		t.Errorf("Expected a file sink, got %T", sink)
+	}
+	sink.Close()
+
+	for _, spec := range []string{"kafka:topic", "webhook:ftp://example.com", "file:" + path + ",carrier-pigeon"} {
+		if _, err := OpenAuditSink(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package guardian
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBuckets holds named token buckets for rate limiters. Take refills
+// the bucket at key by rate tokens a second up to capacity, starting full,
+// then takes a token if one is left.
+type TokenBuckets interface {
+	Take(key string, capacity, rate float64, now time.Time) BucketTake
+}
+
+// BucketTake is the outcome of taking a token from a bucket
+type BucketTake struct {
+	Allowed bool
+	// Remaining is the tokens left in the bucket
+	Remaining float64
+	// RetryAfter is how long a refused caller must wait for a token
+	RetryAfter time.Duration
+}
+
+// MemoryBuckets keeps token buckets in process memory, so each replica of
+// a server limits its callers on its own
+type MemoryBuckets struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled
+}
+
+// bucketSweepInterval is how often refilled buckets are dropped
+const bucketSweepInterval = time.Minute
+
+// NewMemoryBuckets returns empty in-memory buckets
+func NewMemoryBuckets() *MemoryBuckets {
+	return &MemoryBuckets{buckets: make(map[string]*tokenBucket)}
+}
+
+// Take takes a token from the bucket at key
+func (m *MemoryBuckets) Take(key string, capacity, rate float64, now time.Time) BucketTake {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) >= bucketSweepInterval {
+		for k, b := range m.buckets {
+			if !now.Before(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	var take BucketTake
+	if b.tokens >= 1 {
+		b.tokens--
+		take.Allowed = true
+	} else {
+		take.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	take.Remaining = b.tokens
+	b.full = now.Add(time.Duration((capacity - b.tokens) / rate * float64(time.Second)))
+	return take
+}
+
+// Limiter decides whether an identifier, such as a client IP, may make
+// another request. RateLimiter and BucketRateLimiter implement it.
+type Limiter interface {
+	Allow(identifier string) bool
+	Stop()
+}
+
+// BucketRateLimiter allows maxRequests per window per identifier from
+// TokenBuckets, which may be shared by replicas
+type BucketRateLimiter struct {
+	buckets  TokenBuckets
+	prefix   string
+	capacity float64
+	rate     float64
+}
+
+// NewBucketRateLimiter limits identifiers in buckets whose keys start with
+// prefix
+func NewBucketRateLimiter(buckets TokenBuckets, prefix string, maxRequests int, window time.Duration) *BucketRateLimiter {
+	return &BucketRateLimiter{
+		buckets:  buckets,
+		prefix:   prefix,
+		capacity: float64(maxRequests),
+		rate:     float64(maxRequests) / window.Seconds(),
+	}
+}
+
+// Allow takes a token for identifier
+func (l *BucketRateLimiter) Allow(identifier string) bool {
+	return l.buckets.Take(l.prefix+identifier, l.capacity, l.rate, time.Now()).Allowed
+}
+
+// Stop does nothing; the buckets outlive the limiter
+func (l *BucketRateLimiter) Stop() {}
+
+// SetRateLimitBuckets moves the Guardian's per-IP and per-API-key rate
+// limits to buckets, such as RedisBuckets shared by replicas. Call it before
+// serving requests.
+func (g *Guardian) SetRateLimitBuckets(buckets TokenBuckets) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rateLimiter.Stop()
+	g.rateLimiter = NewBucketRateLimiter(buckets, "guardian|", g.config.RateLimitRequests, g.config.RateLimitWindow)
+	for _, limiter := range g.keyLimiters {
+		limiter.Stop()
+	}
+	g.keyLimiters = make(map[string]Limiter)
+	g.buckets = buckets
+}
@@ -0,0 +1,131 @@
+package guardian
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/metrics"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/redis"
+)
+
+// takeScript refills and takes from a token bucket atomically. The bucket
+// is a hash of its tokens and the time it was last updated, in
+// milliseconds by the Redis clock so that replicas agree, expiring once it
+// would be full again. It returns whether a token was taken, the tokens
+// left and the milliseconds to wait for one.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2]) / 1000
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1000)
+return {allowed, tostring(tokens), wait}
+`)
+
+// RedisBuckets keeps token buckets in Redis so that every replica of a
+// server draws from the same buckets. While Redis cannot be reached, takes
+// fall back to in-memory buckets, limiting each replica on its own rather
+// than refusing or waving through every request.
+type RedisBuckets struct {
+	client   *redis.Client
+	prefix   string
+	timeout  time.Duration
+	fallback *MemoryBuckets
+}
+
+// NewRedisBuckets keeps buckets in client under keys starting with prefix
+func NewRedisBuckets(client *redis.Client, prefix string) *RedisBuckets {
+	return &RedisBuckets{
+		client:   client,
+		prefix:   prefix,
+		timeout:  250 * time.Millisecond,
+		fallback: NewMemoryBuckets(),
+	}
+}
+
+// RateLimitBucketsFromEnv returns RedisBuckets in the RATE_LIMIT_REDIS_URL
+// Redis, keyed under exs:ratelimit:<service>:, or nil when unset
+func RateLimitBucketsFromEnv(service string) (*RedisBuckets, error) {
+	url := os.Getenv("RATE_LIMIT_REDIS_URL")
+	if url == "" {
+		return nil, nil
+	}
+	client, err := redis.Open(url)
+	if err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_REDIS_URL: %w", err)
+	}
+	return NewRedisBuckets(client, "exs:ratelimit:"+service+":"), nil
+}
+
+// Addr returns the address of the Redis server
+func (b *RedisBuckets) Addr() string {
+	return b.client.Addr()
+}
+
+// Ping checks that Redis answers
+func (b *RedisBuckets) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx)
+}
+
+// Close closes the connections to Redis
+func (b *RedisBuckets) Close() error {
+	return b.client.Close()
+}
+
+// Take takes a token from the bucket at key. Redis refills it by its own
+// clock, so now only applies to the fallback buckets.
+func (b *RedisBuckets) Take(key string, capacity, rate float64, now time.Time) BucketTake {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	reply, err := takeScript.Run(ctx, b.client, []string{b.prefix + key},
+		strconv.FormatFloat(capacity, 'f', -1, 64), strconv.FormatFloat(rate, 'f', -1, 64))
+	if err == nil {
+		var take BucketTake
+		if take, err = parseTake(reply); err == nil {
+			return take
+		}
+	}
+	metrics.RateLimitStoreErrors.Inc()
+	return b.fallback.Take(key, capacity, rate, now)
+}
+
+// parseTake reads the reply of takeScript
+func parseTake(reply any) (BucketTake, error) {
+	items, ok := reply.([]any)
+	if !ok || len(items) != 3 {
+		return BucketTake{}, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+	allowed, ok1 := items[0].(int64)
+	tokens, ok2 := items[1].(string)
+	wait, ok3 := items[2].(int64)
+	if !ok1 || !ok2 || !ok3 {
+		return BucketTake{}, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+	remaining, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return BucketTake{}, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+	return BucketTake{
+		Allowed:    allowed == 1,
+		Remaining:  remaining,
+		RetryAfter: time.Duration(wait) * time.Millisecond,
+	}, nil
+}
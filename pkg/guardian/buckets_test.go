@@ -0,0 +1,120 @@
+package guardian
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/redis"
+)
+
+func TestSharedBuckets(t *testing.T) {
+	// Two replicas sharing buckets share their callers' limits
+	buckets := NewMemoryBuckets()
+	config := DefaultConfig()
+	config.RateLimitRequests = 3
+	replicas := []*Guardian{NewGuardian(config), NewGuardian(config)}
+	for _, g := range replicas {
+		g.SetRateLimitBuckets(buckets)
+		defer g.Close()
+	}
+	allowed := 0
+	for i := range 6 {
+		if replicas[i%2].rateLimiter.Allow("10.0.0.1") {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("Allowed %d requests across replicas, want 3", allowed)
+	}
+
+	policy := &RateLimitPolicy{Default: Limit{Requests: 1, Per: time.Hour}}
+	limiters := []*PolicyLimiter{NewPolicyLimiter(policy, nil), NewPolicyLimiter(policy, nil)}
+	for _, l := range limiters {
+		l.SetBuckets(buckets)
+	}
+	now := time.Now()
+	if d := limiters[0].Allow("GET", "/balance", "ip:a", "", now); !d.Allowed {
+		t.Errorf("First request = %+v", d)
+	}
+	if d := limiters[1].Allow("GET", "/balance", "ip:a", "", now); d.Allowed {
+		t.Error("Expected the other replica to refuse the second request")
+	}
+}
+
+// serveRESP answers every command on a local listener with reply
+func serveRESP(t *testing.T, reply string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					// A command is an array of bulk strings
+					var n, size int
+					if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+						return
+					}
+					for range n {
+						if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+							return
+						}
+						if _, err := r.Discard(size + 2); err != nil {
+							return
+						}
+					}
+					c.Write([]byte(reply))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedisBuckets(t *testing.T) {
+	addr := serveRESP(t, "*3\r\n:0\r\n$4\r\n0.25\r\n:1500\r\n")
+	client, err := redis.Open("redis://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewRedisBuckets(client, "exs:ratelimit:test:")
+	defer b.Close()
+
+	take := b.Take("default|ip:a", 10, 1, time.Now())
+	if take.Allowed || take.Remaining != 0.25 || take.RetryAfter != 1500*time.Millisecond {
+		t.Errorf("Take() = %+v", take)
+	}
+}
+
+func TestRedisBucketsFallBack(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	client, _ := redis.Open("redis://" + addr)
+	b := NewRedisBuckets(client, "exs:ratelimit:test:")
+	defer b.Close()
+
+	// Without Redis each replica limits on its own
+	now := time.Now()
+	if take := b.Take("k", 1, 1, now); !take.Allowed {
+		t.Errorf("First take = %+v", take)
+	}
+	if take := b.Take("k", 1, 1, now); take.Allowed {
+		t.Error("Expected the fallback bucket to refuse the second take")
+	}
+}
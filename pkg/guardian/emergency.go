@@ -0,0 +1,290 @@
+package guardian
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/keystore"
+)
+
+// Emergency halt. Any King Arthur session can trip the breaker, freezing
+// forges and treasury distributions across every service that follows the
+// treasury's event bus. Resuming takes BIP-340 Schnorr signatures over the
+// halt ID from a threshold of the configured emergency signers, so one
+// compromised admin account can neither keep the fleet halted against the
+// signers nor bring it back early.
+
+// EventEmergency is the event bus type carrying HaltState changes
+const EventEmergency = "emergency"
+
+var (
+	// ErrHalted indicates an operation refused during an emergency halt
+	ErrHalted = errors.New("emergency halt in effect")
+	// ErrNotHalted indicates a resume approval with no halt in effect
+	ErrNotHalted = errors.New("no emergency halt in effect")
+	// ErrUnknownSigner indicates an approval from a key that is not an
+	// emergency signer
+	ErrUnknownSigner = errors.New("not an emergency signer")
+	// ErrInvalidApproval indicates a resume signature that does not verify
+	// for the current halt
+	ErrInvalidApproval = errors.New("invalid resume signature")
+)
+
+// HaltState is the state of the emergency breaker
+type HaltState struct {
+	Halted bool `json:"halted"`
+	// ID names the halt; resume signatures commit to it so they cannot be
+	// replayed against a later halt
+	ID       string    `json:"id,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	HaltedBy string    `json:"halted_by,omitempty"`
+	HaltedAt time.Time `json:"halted_at"`
+	// Approvals lists the signers, as x-only public key hex, who have
+	// signed the resume so far
+	Approvals []string `json:"approvals,omitempty"`
+	// Threshold is the number of approvals that resume; zero when no
+	// signers are configured
+	Threshold int       `json:"threshold,omitempty"`
+	ResumedAt time.Time `json:"resumed_at"`
+}
+
+// Breaker is the emergency circuit breaker of one service. The treasury's
+// breaker has signers and a state file; followers have neither and mirror
+// the treasury's state from the event bus with Apply.
+type Breaker struct {
+	mu        sync.RWMutex
+	state     HaltState
+	signers   map[string]bool
+	threshold int
+	path      string
+	onChange  func(HaltState)
+}
+
+// NewBreaker creates a breaker whose resume needs threshold of signers,
+// given as 32-byte x-only public keys in hex. State is kept at path, when
+// set, so a restart during an incident stays halted.
+func NewBreaker(path string, signers []string, threshold int) (*Breaker, error) {
+	b := &Breaker{signers: make(map[string]bool), threshold: threshold, path: path}
+	for _, signer := range signers {
+		key, err := parseSigner(signer)
+		if err != nil {
+			return nil, err
+		}
+		b.signers[key] = true
+	}
+	if len(b.signers) == 0 {
+		// Without signers a halt can never be resumed
+		b.threshold = 0
+	} else if threshold < 1 || threshold > len(b.signers) {
+		return nil, fmt.Errorf("resume threshold %d must be between 1 and %d signers", threshold, len(b.signers))
+	}
+	b.state.Threshold = b.threshold
+
+	if path == "" {
+		return b, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read halt state: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.state); err != nil {
+		return nil, fmt.Errorf("failed to parse halt state %s: %w", path, err)
+	}
+	b.state.Threshold = b.threshold
+	return b, nil
+}
+
+// parseSigner normalizes a signer key to lowercase hex
+func parseSigner(signer string) (string, error) {
+	raw, err := hex.DecodeString(signer)
+	if err == nil {
+		_, err = schnorr.ParsePubKey(raw)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid emergency signer %q: %v", signer, err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// OnChange registers fn to be called with every new state, typically to
+// publish it on the event bus
+func (b *Breaker) OnChange(fn func(HaltState)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = fn
+}
+
+// State returns the current state
+func (b *Breaker) State() HaltState {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	state := b.state
+	state.Approvals = append([]string(nil), b.state.Approvals...)
+	return state
+}
+
+// Check returns an ErrHalted error while a halt is in effect
+func (b *Breaker) Check() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.state.Halted {
+		return fmt.Errorf("%w: %s", ErrHalted, b.state.Reason)
+	}
+	return nil
+}
+
+// Halt trips the breaker. Halting while halted keeps the existing halt and
+// its approvals. The halt takes effect even if saving it fails.
+func (b *Breaker) Halt(reason, by string) (HaltState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state.Halted {
+		return b.state, nil
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return b.state, fmt.Errorf("failed to generate halt ID: %w", err)
+	}
+	b.state = HaltState{
+		Halted:    true,
+		ID:        hex.EncodeToString(id),
+		Reason:    reason,
+		HaltedBy:  by,
+		HaltedAt:  time.Now().UTC(),
+		Threshold: b.threshold,
+	}
+	b.changed()
+	return b.state, b.save()
+}
+
+// Approve records a signer's resume signature over the current halt,
+// resuming once the threshold is met. Repeated approvals from one signer
+// count once. A resume is only kept if it can be saved.
+func (b *Breaker) Approve(signer string, signature []byte) (HaltState, error) {
+	key, err := parseSigner(signer)
+	if err != nil {
+		return HaltState{}, fmt.Errorf("%w: %v", ErrUnknownSigner, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.state.Halted {
+		return b.state, ErrNotHalted
+	}
+	if !b.signers[key] {
+		return b.state, fmt.Errorf("%w: %s", ErrUnknownSigner, key)
+	}
+	if !verifyResume(key, b.state.ID, signature) {
+		return b.state, ErrInvalidApproval
+	}
+	for _, approved := range b.state.Approvals {
+		if approved == key {
+			return b.state, nil
+		}
+	}
+
+	next := b.state
+	next.Approvals = append(append([]string(nil), b.state.Approvals...), key)
+	sort.Strings(next.Approvals)
+	if len(next.Approvals) >= b.threshold {
+		next.Halted = false
+		next.ResumedAt = time.Now().UTC()
+	}
+	prev := b.state
+	b.state = next
+	if err := b.save(); err != nil && !next.Halted {
+		b.state = prev
+		return b.state, err
+	}
+	b.changed()
+	return b.state, nil
+}
+
+// Apply adopts a state received from the treasury's event bus
+func (b *Breaker) Apply(state HaltState) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = state
+	b.changed()
+	return b.save()
+}
+
+// changed notifies the OnChange callback; callers must hold b.mu
+func (b *Breaker) changed() {
+	if b.onChange != nil {
+		b.onChange(b.state)
+	}
+}
+
+// save writes the state file, if any; callers must hold b.mu
+func (b *Breaker) save() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(b.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return fmt.Errorf("failed to create halt state directory: %w", err)
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save halt state: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("failed to save halt state: %w", err)
+	}
+	return nil
+}
+
+// resumeDigest is the message a signer signs to resume halt id
+func resumeDigest(id string) []byte {
+	digest := sha256.Sum256([]byte("exs-emergency-resume:" + id))
+	return digest[:]
+}
+
+// verifyResume checks a resume signature by the signer with x-only key hex
+func verifyResume(key, id string, signature []byte) bool {
+	raw, _ := hex.DecodeString(key)
+	pub, err := schnorr.ParsePubKey(raw)
+	if err != nil {
+		return false
+	}
+	sig, err := schnorr.ParseSignature(signature)
+	if err != nil {
+		return false
+	}
+	return sig.Verify(resumeDigest(id), pub)
+}
+
+// SignResume signs the resume of halt id with an emergency signer's key
+func SignResume(key keystore.Signer, id string) ([]byte, error) {
+	sig, err := key.SignSchnorr(resumeDigest(id))
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+// SignerKey returns the x-only public key hex that identifies pub as an
+// emergency or distribution signer
+func SignerKey(pub *btcec.PublicKey) string {
+	return hex.EncodeToString(schnorr.SerializePubKey(pub))
+}
@@ -0,0 +1,155 @@
+package guardian
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/keystore"
+)
+
+// newSigners generates n emergency signer keys
+func newSigners(t *testing.T, n int) ([]*btcec.PrivateKey, []string) {
+	t.Helper()
+	keys := make([]*btcec.PrivateKey, n)
+	pubs := make([]string, n)
+	for i := range keys {
+		key, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i], pubs[i] = key, SignerKey(key.PubKey())
+	}
+	return keys, pubs
+}
+
+// approve signs the current halt with key and submits it
+func approve(t *testing.T, b *Breaker, key *btcec.PrivateKey) (HaltState, error) {
+	t.Helper()
+	sig, err := SignResume(keystore.PrivateKeySigner(key), b.State().ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Approve(SignerKey(key.PubKey()), sig)
+}
+
+func TestBreakerResumeThreshold(t *testing.T) {
+	keys, pubs := newSigners(t, 3)
+	b, err := NewBreaker("", pubs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []HaltState
+	b.OnChange(func(s HaltState) { changes = append(changes, s) })
+
+	if _, err := approve(t, b, keys[0]); !errors.Is(err, ErrNotHalted) {
+		t.Fatalf("approve without halt: got %v, want ErrNotHalted", err)
+	}
+	state, err := b.Halt("key compromise", "arthur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Halted || state.ID == "" || state.Threshold != 2 {
+		t.Fatalf("unexpected halt state %+v", state)
+	}
+	if err := b.Check(); !errors.Is(err, ErrHalted) {
+		t.Fatalf("Check() = %v, want ErrHalted", err)
+	}
+	if again, _ := b.Halt("second", "lancelot"); again.ID != state.ID || again.Reason != "key compromise" {
+		t.Errorf("halting again replaced the halt: %+v", again)
+	}
+
+	if state, err = approve(t, b, keys[0]); err != nil || !state.Halted {
+		t.Fatalf("first approval: halted %v, err %v", state.Halted, err)
+	}
+	if state, err = approve(t, b, keys[0]); err != nil || len(state.Approvals) != 1 {
+		t.Fatalf("repeated approval counted: %d approvals, err %v", len(state.Approvals), err)
+	}
+	if state, err = approve(t, b, keys[2]); err != nil || state.Halted {
+		t.Fatalf("second approval: halted %v, err %v", state.Halted, err)
+	}
+	if err := b.Check(); err != nil {
+		t.Errorf("Check() after resume = %v", err)
+	}
+	if len(changes) != 3 || changes[0].Halted != true || changes[2].Halted != false {
+		t.Errorf("unexpected change notifications %+v", changes)
+	}
+}
+
+func TestBreakerRejectsApprovals(t *testing.T) {
+	keys, pubs := newSigners(t, 2)
+	b, err := NewBreaker("", pubs[:1], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := b.Halt("incident", "arthur")
+
+	if _, err := approve(t, b, keys[1]); !errors.Is(err, ErrUnknownSigner) {
+		t.Errorf("outside signer: got %v, want ErrUnknownSigner", err)
+	}
+	if _, err := b.Approve("not-hex", []byte{1}); !errors.Is(err, ErrUnknownSigner) {
+		t.Errorf("malformed signer: got %v, want ErrUnknownSigner", err)
+	}
+	forged, _ := SignResume(keystore.PrivateKeySigner(keys[1]), first.ID)
+	if _, err := b.Approve(pubs[0], forged); !errors.Is(err, ErrInvalidApproval) {
+		t.Errorf("signature by another key: got %v, want ErrInvalidApproval", err)
+	}
+
+	// A signature over one halt cannot resume the next
+	old, _ := SignResume(keystore.PrivateKeySigner(keys[0]), first.ID)
+	if _, err := b.Approve(pubs[0], old); err != nil {
+		t.Fatal(err)
+	}
+	b.Halt("second incident", "arthur")
+	if _, err := b.Approve(pubs[0], old); !errors.Is(err, ErrInvalidApproval) {
+		t.Errorf("replayed signature: got %v, want ErrInvalidApproval", err)
+	}
+}
+
+func TestNewBreakerConfig(t *testing.T) {
+	_, pubs := newSigners(t, 2)
+	if _, err := NewBreaker("", pubs, 3); err == nil {
+		t.Error("expected error for threshold above signer count")
+	}
+	if _, err := NewBreaker("", pubs, 0); err == nil {
+		t.Error("expected error for zero threshold")
+	}
+	if _, err := NewBreaker("", []string{"abcd"}, 1); err == nil {
+		t.Error("expected error for invalid signer key")
+	}
+}
+
+func TestBreakerPersists(t *testing.T) {
+	keys, pubs := newSigners(t, 1)
+	path := filepath.Join(t.TempDir(), "emergency.json")
+	b, err := NewBreaker(path, pubs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	halted, err := b.Halt("incident", "arthur")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewBreaker(path, pubs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := reopened.State(); !state.Halted || state.ID != halted.ID {
+		t.Fatalf("halt not restored: %+v", state)
+	}
+	if _, err := approve(t, reopened, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err = NewBreaker(path, pubs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Check(); err != nil {
+		t.Errorf("resume not restored: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package guardian
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// StoreKeySize is the required length of a store encryption key
+const StoreKeySize = 32
+
+// StoreCipher encrypts sensitive store fields with AES-256-GCM and derives
+// opaque lookup keys for session tokens
+type StoreCipher struct {
+	aead     cipher.AEAD
+	indexKey []byte
+}
+
+// NewStoreCipher derives encryption and indexing subkeys from a 32-byte master key
+func NewStoreCipher(masterKey []byte) (*StoreCipher, error) {
+	if len(masterKey) != StoreKeySize {
+		return nil, fmt.Errorf("store key must be %d bytes, got %d", StoreKeySize, len(masterKey))
+	}
+
+	kdf := hkdf.New(sha256.New, masterKey, nil, []byte("lancelot-guardian-store"))
+	encKey := make([]byte, 32)
+	indexKey := make([]byte, 32)
+	if _, err := io.ReadFull(kdf, encKey); err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	if _, err := io.ReadFull(kdf, indexKey); err != nil {
+		return nil, fmt.Errorf("failed to derive index key: %w", err)
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StoreCipher{aead: aead, indexKey: indexKey}, nil
+}
+
+// Seal encrypts plaintext, binding it to associatedData (the record key)
+func (c *StoreCipher) Seal(plaintext, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+// Open decrypts a value produced by Seal
+func (c *StoreCipher) Open(sealed, associatedData []byte) ([]byte, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, ciphertext, associatedData)
+}
+
+// TokenIndex returns the HMAC-SHA256 lookup key for a session token, so raw
+// tokens never appear as store keys
+func (c *StoreCipher) TokenIndex(token string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadOrCreateStoreKey reads a hex-encoded store key from path, generating
+// and saving a new random key (mode 0600) if the file does not exist
+func LoadOrCreateStoreKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid store key file: %w", err)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read store key: %w", err)
+	}
+
+	key := make([]byte, StoreKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate store key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write store key: %w", err)
+	}
+	return key, nil
+}
@@ -4,10 +4,17 @@ package guardian
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -23,6 +30,10 @@ var (
 	ErrUnauthorized = errors.New("unauthorized access")
 	// ErrInvalidToken indicates token validation failure
 	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrUserNotFound indicates an unknown username
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists indicates a username already taken
+	ErrUserExists = errors.New("user already exists")
 )
 
 // Role defines access levels within the Excalibur protocol
@@ -37,14 +48,36 @@ const (
 	RoleSquire Role = "squire"
 )
 
+// rank orders roles by privilege; unknown roles rank lowest
+func (r Role) rank() int {
+	switch r {
+	case RoleKingArthur:
+		return 3
+	case RoleKnight:
+		return 2
+	case RoleSquire:
+		return 1
+	}
+	return 0
+}
+
 // Guardian implements the Lancelot Guardian Protocol
 type Guardian struct {
-	mu             sync.RWMutex
-	users          map[string]*User
-	sessions       map[string]*Session
-	rateLimiter    *RateLimiter
-	ipWhitelist    map[string]bool
-	config         *Config
+	mu          sync.RWMutex
+	users       map[string]*User
+	sessions    map[string]*Session
+	refresh     map[string]string // refresh token to session token
+	rateLimiter Limiter
+	ipPolicy    *IPPolicy
+	config      *Config
+	store       Store
+	auditLog    *AuditLog
+	stop        chan struct{}
+	jwtKeys     *JWTKeyring             // signing keys, in SessionModeJWT
+	jwtSources  []JWTKeySource          // keys JWTs are checked against
+	apiKeys     map[string]*APIKey      // by ID
+	keyLimiters map[string]Limiter      // per API key, by ID
+	buckets     TokenBuckets            // shared rate limit buckets, if any
 }
 
 // User represents an authenticated user in the system
@@ -56,16 +89,38 @@ type User struct {
 	CreatedAt    time.Time
 	LastLoginAt  time.Time
 	Enabled      bool
+
+	// Two-factor authentication. TOTPSecret is set by EnableTOTP, and
+	// TOTPEnabled once the first code is verified.
+	TOTPSecret  []byte
+	TOTPEnabled bool
+	TOTPCounter uint64   // last accepted time step, to prevent code reuse
+	BackupCodes [][]byte // SHA-256 hashes of unused backup codes
+
+	// Brute-force protection. FailedLogins counts consecutive failures
+	// since the last successful login or unlock.
+	FailedLogins      int
+	LastFailedLoginAt time.Time
+	LockedUntil       time.Time
 }
 
-// Session represents an active authenticated session
+// Session represents an active authenticated session. Token authenticates
+// requests until ExpiresAt; RefreshToken exchanges the session for a new one
+// through RefreshSession, until the session's maximum lifetime from login.
 type Session struct {
-	Token     string
-	Username  string
-	Role      Role
-	CreatedAt time.Time
-	ExpiresAt time.Time
-	IPAddress string
+	Token        string
+	RefreshToken string
+	Username     string
+	Role         Role
+	CreatedAt    time.Time // login time, kept across refreshes
+	ExpiresAt    time.Time
+	LastSeenAt   time.Time
+	IPAddress    string
+
+	// APIKey is the ID of the API key authenticating the request, and
+	// Scopes its scopes, for sessions standing in for an API key
+	APIKey string
+	Scopes []Permission
 }
 
 // Config holds Guardian configuration
@@ -76,16 +131,56 @@ type Config struct {
 	Argon2Threads uint8
 	Argon2KeyLen  uint32
 
-	// Session parameters
-	SessionDuration time.Duration
-	TokenLength     int
+	// Session parameters. SessionDuration is the lifetime of a session
+	// token. SessionIdleTimeout ends a session unused for that long, and
+	// SessionMaxLifetime is the absolute limit from login that refreshing
+	// cannot extend; zero disables the idle timeout or refreshing.
+	SessionDuration    time.Duration
+	SessionIdleTimeout time.Duration
+	SessionMaxLifetime time.Duration
+	TokenLength        int
 
 	// Rate limiting
 	RateLimitRequests int
 	RateLimitWindow   time.Duration
 
-	// Security
+	// Account lockout. After LockoutThreshold consecutive failed logins an
+	// account is locked for LockoutDuration, doubling with each further
+	// failure up to LockoutMaxDuration; a zero LockoutThreshold disables
+	// lockout and a zero LockoutMaxDuration keeps the duration fixed.
+	// From ChallengeThreshold failures on, logins must also pass Challenge,
+	// such as a CAPTCHA check; zero or a nil Challenge disables it.
+	LockoutThreshold   int
+	LockoutDuration    time.Duration
+	LockoutMaxDuration time.Duration
+	ChallengeThreshold int
+	Challenge          ChallengeFunc
+
+	// IP access. RequireIPWhitelist refuses addresses outside the allow
+	// rules; deny rules apply either way. IPPolicyFile, when set, is a YAML
+	// policy loaded at start and reloaded within IPPolicyReload of a change.
 	RequireIPWhitelist bool
+	IPPolicyFile       string
+	IPPolicyReload     time.Duration
+
+	// Stateless sessions. SessionModeJWT issues JWTs signed with the keys
+	// in JWTKeyFile, rotated every JWTKeyRotation; an empty JWTKeyFile
+	// keeps the keys in memory. A refreshable session's JWT expires after
+	// JWTLifetime, and its refresh token signs the next one. JWTs are
+	// checked against these keys and, when JWKSURL is set, those published
+	// by another Guardian, whatever the mode.
+	SessionMode    SessionMode
+	JWTIssuer      string
+	JWTKeyFile     string
+	JWTKeyRotation time.Duration
+	JWTLifetime    time.Duration
+	JWKSURL        string
+	// JWKSClient fetches JWKSURL, http.DefaultClient when nil
+	JWKSClient *http.Client
+
+	// Permissions grants each role permissions on top of those of the
+	// roles below it, DefaultPermissions when nil
+	Permissions map[Role][]Permission
 }
 
 // DefaultConfig returns secure default configuration
@@ -97,31 +192,199 @@ func DefaultConfig() *Config {
 		Argon2Threads: 4,
 		Argon2KeyLen:  32,
 
-		// 24 hour sessions
-		SessionDuration: 24 * time.Hour,
-		TokenLength:     32,
+		// 24 hour sessions, refreshable for a week after login
+		SessionDuration:    24 * time.Hour,
+		SessionMaxLifetime: 7 * 24 * time.Hour,
+		TokenLength:        32,
 
 		// Rate limiting: 100 requests per minute
 		RateLimitRequests: 100,
 		RateLimitWindow:   time.Minute,
 
+		// Lock accounts for a minute after 5 failed logins, doubling up
+		// to an hour; challenge from 3 failures once a Challenge is set
+		LockoutThreshold:   5,
+		LockoutDuration:    time.Minute,
+		LockoutMaxDuration: time.Hour,
+		ChallengeThreshold: 3,
+
 		RequireIPWhitelist: false,
+		IPPolicyReload:     DefaultIPPolicyReload,
+
+		SessionMode:    SessionModeOpaque,
+		JWTIssuer:      DefaultJWTIssuer,
+		JWTKeyRotation: DefaultJWTKeyRotation,
+		JWTLifetime:    DefaultJWTLifetime,
+	}
+}
+
+// ConfigFromEnv returns DefaultConfig with session timeouts overridden by
+// GUARDIAN_SESSION_DURATION, GUARDIAN_SESSION_IDLE_TIMEOUT and
+// GUARDIAN_SESSION_MAX_LIFETIME, and lockout by GUARDIAN_LOCKOUT_THRESHOLD,
+// GUARDIAN_LOCKOUT_DURATION and GUARDIAN_LOCKOUT_MAX_DURATION. The IP
+// policy file is GUARDIAN_IP_POLICY, checked every GUARDIAN_IP_POLICY_RELOAD.
+// GUARDIAN_SESSION_MODE=jwt issues JWTs signed with the keyring
+// GUARDIAN_JWT_KEYS, rotated every GUARDIAN_JWT_ROTATION and lasting
+// GUARDIAN_JWT_LIFETIME, and
+// GUARDIAN_JWKS_URL and GUARDIAN_JWT_ISSUER set where tokens are checked
+// and who they are from. Durations are given as "30m" and the like.
+func ConfigFromEnv() (*Config, error) {
+	config := DefaultConfig()
+	for name, field := range map[string]*time.Duration{
+		"GUARDIAN_SESSION_DURATION":     &config.SessionDuration,
+		"GUARDIAN_SESSION_IDLE_TIMEOUT": &config.SessionIdleTimeout,
+		"GUARDIAN_SESSION_MAX_LIFETIME": &config.SessionMaxLifetime,
+		"GUARDIAN_LOCKOUT_DURATION":     &config.LockoutDuration,
+		"GUARDIAN_LOCKOUT_MAX_DURATION": &config.LockoutMaxDuration,
+		"GUARDIAN_IP_POLICY_RELOAD":     &config.IPPolicyReload,
+		"GUARDIAN_JWT_ROTATION":         &config.JWTKeyRotation,
+		"GUARDIAN_JWT_LIFETIME":         &config.JWTLifetime,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s: %q", name, value)
+		}
+		*field = d
+	}
+	if config.SessionDuration <= 0 {
+		return nil, errors.New("invalid GUARDIAN_SESSION_DURATION: must be positive")
+	}
+	if value := os.Getenv("GUARDIAN_LOCKOUT_THRESHOLD"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GUARDIAN_LOCKOUT_THRESHOLD: %q", value)
+		}
+		config.LockoutThreshold = n
+	}
+	config.IPPolicyFile = os.Getenv("GUARDIAN_IP_POLICY")
+	if value := os.Getenv("GUARDIAN_SESSION_MODE"); value != "" {
+		config.SessionMode = SessionMode(value)
+		if config.SessionMode != SessionModeOpaque && config.SessionMode != SessionModeJWT {
+			return nil, fmt.Errorf("invalid GUARDIAN_SESSION_MODE: %q", value)
+		}
+	}
+	if value := os.Getenv("GUARDIAN_JWT_ISSUER"); value != "" {
+		config.JWTIssuer = value
 	}
+	config.JWTKeyFile = os.Getenv("GUARDIAN_JWT_KEYS")
+	config.JWKSURL = os.Getenv("GUARDIAN_JWKS_URL")
+	return config, nil
 }
 
-// NewGuardian creates a new Guardian instance
+// NewGuardian creates a new Guardian instance backed by an in-memory store
 func NewGuardian(config *Config) *Guardian {
+	g, _ := NewGuardianWithStore(config, NewMemoryStore())
+	return g
+}
+
+// NewGuardianWithStore creates a Guardian that persists users and sessions
+// to store, loading any existing records. Sessions that can no longer be
+// used or refreshed are discarded.
+func NewGuardianWithStore(config *Config, store Store) (*Guardian, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	return &Guardian{
+	g := &Guardian{
 		users:       make(map[string]*User),
 		sessions:    make(map[string]*Session),
+		refresh:     make(map[string]string),
 		rateLimiter: NewRateLimiter(config.RateLimitRequests, config.RateLimitWindow),
-		ipWhitelist: make(map[string]bool),
+		ipPolicy:    &IPPolicy{},
 		config:      config,
+		store:       store,
+		stop:        make(chan struct{}),
+		apiKeys:     make(map[string]*APIKey),
+		keyLimiters: make(map[string]Limiter),
+	}
+	if config.IPPolicyFile != "" {
+		data, err := os.ReadFile(config.IPPolicyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load IP policy: %w", err)
+		}
+		policy, err := ParseIPPolicy(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", config.IPPolicyFile, err)
+		}
+		g.ipPolicy = policy
+		if config.IPPolicyReload > 0 {
+			go g.watchIPPolicy(config.IPPolicyFile, config.IPPolicyReload, sha256.Sum256(data), g.stop)
+		}
+	}
+	if config.SessionMode == SessionModeJWT {
+		keys, err := OpenJWTKeyring(config.JWTKeyFile, config.JWTKeyRotation, config.SessionDuration+jwtLeeway)
+		if err != nil {
+			return nil, err
+		}
+		g.jwtKeys = keys
+		g.jwtSources = append(g.jwtSources, keys)
+	}
+	if config.JWKSURL != "" {
+		g.jwtSources = append(g.jwtSources, NewRemoteJWKS(config.JWKSURL, config.JWKSClient))
+	}
+
+	users, err := store.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
 	}
+	for _, user := range users {
+		g.users[user.Username] = user
+	}
+
+	keys, err := store.ListAPIKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API keys: %w", err)
+	}
+	for _, key := range keys {
+		g.apiKeys[key.ID] = key
+	}
+
+	sessions, err := store.ListSessions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	now := time.Now()
+	for _, session := range sessions {
+		if g.ended(session, now) {
+			if err := store.DeleteSession(session.Token); err != nil {
+				return nil, fmt.Errorf("failed to prune expired session: %w", err)
+			}
+			continue
+		}
+		g.addSession(session)
+	}
+
+	return g, nil
+}
+
+// Close releases the underlying store and audit log and stops background
+// tasks
+func (g *Guardian) Close() error {
+	g.rateLimiter.Stop()
+	g.mu.Lock()
+	for _, limiter := range g.keyLimiters {
+		limiter.Stop()
+	}
+	g.mu.Unlock()
+	close(g.stop)
+	err := g.store.Close()
+	if g.auditLog != nil {
+		err = errors.Join(err, g.auditLog.Close())
+	}
+	return err
+}
+
+// CheckStore reads from the store to report whether it is reachable, for
+// health checks
+func (g *Guardian) CheckStore() error {
+	if _, err := g.store.ListUsers(); err != nil {
+		return fmt.Errorf("guardian store unavailable: %w", err)
+	}
+	return nil
 }
 
 // CreateUser creates a new user with hashed password
@@ -130,25 +393,14 @@ func (g *Guardian) CreateUser(username, password string, role Role) error {
 	defer g.mu.Unlock()
 
 	if _, exists := g.users[username]; exists {
-		return fmt.Errorf("user already exists: %s", username)
+		return fmt.Errorf("%w: %s", ErrUserExists, username)
 	}
 
-	// Generate salt
-	salt := make([]byte, 16)
-	if _, err := rand.Read(salt); err != nil {
-		return fmt.Errorf("failed to generate salt: %w", err)
+	hash, salt, err := g.hashPassword(password)
+	if err != nil {
+		return err
 	}
 
-	// Hash password using Argon2id
-	hash := argon2.IDKey(
-		[]byte(password),
-		salt,
-		g.config.Argon2Time,
-		g.config.Argon2Memory,
-		g.config.Argon2Threads,
-		g.config.Argon2KeyLen,
-	)
-
 	user := &User{
 		Username:     username,
 		PasswordHash: hash,
@@ -158,29 +410,202 @@ func (g *Guardian) CreateUser(username, password string, role Role) error {
 		Enabled:      true,
 	}
 
+	if err := g.store.PutUser(user); err != nil {
+		return fmt.Errorf("failed to persist user: %w", err)
+	}
 	g.users[username] = user
+	g.audit(AuditUserCreated, username, "", map[string]string{"role": string(role)})
+	return nil
+}
+
+// DeleteUser removes a user and revokes their sessions
+func (g *Guardian) DeleteUser(username string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, exists := g.users[username]; !exists {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	revoked, err := g.revokeUserSessions(username)
+	if err != nil {
+		return err
+	}
+	if err := g.store.DeleteUser(username); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	delete(g.users, username)
+	g.audit(AuditUserDeleted, username, "", map[string]string{"sessions_revoked": strconv.Itoa(revoked)})
 	return nil
 }
 
-// Authenticate verifies credentials and returns a session token
+// SetRole changes a user's role. Sessions carry the role they were issued
+// with, so a demotion revokes the user's sessions, returning how many.
+func (g *Guardian) SetRole(username string, role Role) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user, exists := g.users[username]
+	if !exists {
+		return 0, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	if role.rank() == 0 {
+		return 0, fmt.Errorf("unknown role: %s", role)
+	}
+	if user.Role == role {
+		return 0, nil
+	}
+
+	updated := *user
+	updated.Role = role
+	if err := g.store.PutUser(&updated); err != nil {
+		return 0, fmt.Errorf("failed to persist user: %w", err)
+	}
+	promoted := role.rank() > user.Role.rank()
+	details := map[string]string{"from": string(user.Role), "to": string(role)}
+	*user = updated
+	revoked := 0
+	var err error
+	if !promoted {
+		revoked, err = g.revokeUserSessions(username)
+		details["sessions_revoked"] = strconv.Itoa(revoked)
+	}
+	eventType := AuditRoleChanged
+	if promoted {
+		eventType = AuditRoleEscalated
+	}
+	g.audit(eventType, username, "", details)
+	return revoked, err
+}
+
+// hashPassword hashes password with Argon2id under a fresh salt
+func (g *Guardian) hashPassword(password string) (hash, salt []byte, err error) {
+	salt = make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	hash = argon2.IDKey(
+		[]byte(password),
+		salt,
+		g.config.Argon2Time,
+		g.config.Argon2Memory,
+		g.config.Argon2Threads,
+		g.config.Argon2KeyLen,
+	)
+	return hash, salt, nil
+}
+
+// SetPassword replaces a user's password and revokes all of their
+// sessions, returning how many were revoked
+func (g *Guardian) SetPassword(username, password string) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user, exists := g.users[username]
+	if !exists {
+		return 0, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	hash, salt, err := g.hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := *user
+	updated.PasswordHash, updated.Salt = hash, salt
+	if err := g.store.PutUser(&updated); err != nil {
+		return 0, fmt.Errorf("failed to persist user: %w", err)
+	}
+	*user = updated
+	revoked, err := g.revokeUserSessions(username)
+	g.audit(AuditPasswordChanged, username, "", map[string]string{"sessions_revoked": strconv.Itoa(revoked)})
+	return revoked, err
+}
+
+// Credentials are what a login presents. TOTPCode is either the current
+// code from the user's authenticator or an unused backup code, and is
+// ignored for users without two-factor authentication. Challenge is the
+// response checked by Config.Challenge once an account has failed
+// ChallengeThreshold logins in a row.
+type Credentials struct {
+	Username  string
+	Password  string
+	TOTPCode  string
+	Challenge string
+}
+
+// Authenticate verifies credentials and returns a session token. Users with
+// two-factor authentication enabled get ErrTOTPRequired and must use
+// AuthenticateWithTOTP.
 func (g *Guardian) Authenticate(username, password, ipAddress string) (string, error) {
+	return g.Login(Credentials{Username: username, Password: password}, ipAddress)
+}
+
+// AuthenticateWithTOTP verifies credentials and a two-factor code and
+// returns a session token
+func (g *Guardian) AuthenticateWithTOTP(username, password, code, ipAddress string) (string, error) {
+	return g.Login(Credentials{Username: username, Password: password, TOTPCode: code}, ipAddress)
+}
+
+// Login verifies creds and returns a session token. Locked accounts get
+// ErrAccountLocked, and accounts past the challenge threshold
+// ErrChallengeRequired or ErrChallengeFailed.
+func (g *Guardian) Login(creds Credentials, ipAddress string) (string, error) {
+	session, err := g.LoginSession(creds, ipAddress)
+	if err != nil {
+		return "", err
+	}
+	return session.Token, nil
+}
+
+// LoginSession is Login returning a copy of the new session, with its
+// refresh token
+func (g *Guardian) LoginSession(creds Credentials, ipAddress string) (*Session, error) {
+	// The challenge may call out to a verification service, so it is
+	// checked before taking the lock
+	if g.challengeRequired(creds.Username) {
+		if creds.Challenge == "" {
+			return nil, ErrChallengeRequired
+		}
+		if err := g.config.Challenge(creds.Username, ipAddress, creds.Challenge); err != nil {
+			g.mu.Lock()
+			g.audit(AuditLoginFailure, creds.Username, ipAddress, map[string]string{"reason": "challenge_failed"})
+			g.mu.Unlock()
+			return nil, fmt.Errorf("%w: %v", ErrChallengeFailed, err)
+		}
+	}
+
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	username, password, code := creds.Username, creds.Password, creds.TOTPCode
+
 	// Check rate limit
 	if !g.rateLimiter.Allow(ipAddress) {
-		return "", ErrRateLimitExceeded
+		return nil, ErrRateLimitExceeded
 	}
 
-	// Check IP whitelist if enabled
-	if g.config.RequireIPWhitelist && !g.ipWhitelist[ipAddress] {
-		return "", ErrUnauthorized
+	// Check IP whitelist if enabled. Rate limited attempts are not audited,
+	// since nothing bounds how many there are.
+	if !g.ipAllowedLocked(ipAddress, "") {
+		g.audit(AuditLoginFailure, username, ipAddress, map[string]string{"reason": "ip_denied"})
+		return nil, ErrUnauthorized
 	}
 
 	// Get user
 	user, exists := g.users[username]
-	if !exists || !user.Enabled {
-		return "", ErrInvalidCredentials
+	if !exists {
+		g.audit(AuditLoginFailure, username, ipAddress, map[string]string{"reason": "unknown_user"})
+		return nil, ErrInvalidCredentials
+	}
+	if !user.Enabled {
+		g.audit(AuditLoginFailure, username, ipAddress, map[string]string{"reason": "user_disabled"})
+		return nil, ErrInvalidCredentials
+	}
+	// A locked account is refused before the password is checked, and the
+	// attempt does not extend the lockout
+	now := time.Now()
+	if now.Before(user.LockedUntil) {
+		g.audit(AuditLoginFailure, username, ipAddress, map[string]string{"reason": "account_locked"})
+		return nil, fmt.Errorf("%w until %s", ErrAccountLocked, user.LockedUntil.Format(time.RFC3339))
 	}
 
 	// Verify password
@@ -194,68 +619,243 @@ func (g *Guardian) Authenticate(username, password, ipAddress string) (string, e
 	)
 
 	if subtle.ConstantTimeCompare(hash, user.PasswordHash) != 1 {
-		return "", ErrInvalidCredentials
+		g.audit(AuditLoginFailure, username, ipAddress, map[string]string{"reason": "invalid_password"})
+		g.recordFailedLogin(user, ipAddress, now)
+		return nil, ErrInvalidCredentials
+	}
+
+	// Verify second factor and update last login
+	updated := *user
+	if user.TOTPEnabled {
+		if code == "" {
+			return nil, ErrTOTPRequired
+		}
+		if !updated.checkSecondFactor(code, now) {
+			g.audit(AuditLoginFailure, username, ipAddress, map[string]string{"reason": "invalid_totp"})
+			g.recordFailedLogin(user, ipAddress, now)
+			return nil, ErrInvalidTOTP
+		}
+	}
+	// Role networks are checked once the credentials are, so that they do
+	// not reveal a user's role
+	if !g.ipAllowedLocked(ipAddress, user.Role) {
+		g.audit(AuditLoginFailure, username, ipAddress, map[string]string{"reason": "ip_denied", "role": string(user.Role)})
+		return nil, ErrUnauthorized
+	}
+	updated.LastLoginAt = now
+	updated.FailedLogins, updated.LastFailedLoginAt, updated.LockedUntil = 0, time.Time{}, time.Time{}
+	if err := g.store.PutUser(&updated); err != nil {
+		return nil, fmt.Errorf("failed to persist user: %w", err)
+	}
+	*user = updated
+
+	session, err := g.newSession(user, ipAddress, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	g.audit(AuditLoginSuccess, username, ipAddress, map[string]string{"role": string(user.Role)})
+	sessionCopy := *session
+	return &sessionCopy, nil
+}
+
+// newSession issues and stores a session for user that logged in at
+// createdAt; callers must hold g.mu
+func (g *Guardian) newSession(user *User, ipAddress string, createdAt time.Time) (*Session, error) {
+	token, err := g.newToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &Session{
+		Token:      token,
+		Username:   user.Username,
+		Role:       user.Role,
+		CreatedAt:  createdAt,
+		ExpiresAt:  now.Add(g.config.SessionDuration),
+		LastSeenAt: now,
+		IPAddress:  ipAddress,
+	}
+	if g.config.SessionMaxLifetime > 0 {
+		if session.RefreshToken, err = g.newToken(); err != nil {
+			return nil, err
+		}
+		if limit := createdAt.Add(g.config.SessionMaxLifetime); session.ExpiresAt.After(limit) {
+			session.ExpiresAt = limit
+		}
+	}
+	if g.jwtKeys != nil {
+		if limit := now.Add(g.config.JWTLifetime); session.RefreshToken != "" && g.config.JWTLifetime > 0 && session.ExpiresAt.After(limit) {
+			session.ExpiresAt = limit
+		}
+		if session.Token, err = g.issueJWT(session); err != nil {
+			return nil, fmt.Errorf("failed to sign session token: %w", err)
+		}
 	}
 
-	// Update last login
-	user.LastLoginAt = time.Now()
+	if err := g.store.PutSession(session); err != nil {
+		return nil, fmt.Errorf("failed to persist session: %w", err)
+	}
+	g.addSession(session)
+	return session, nil
+}
 
-	// Generate session token
+// newToken returns a random hex token of the configured length
+func (g *Guardian) newToken() (string, error) {
 	tokenBytes := make([]byte, g.config.TokenLength)
 	if _, err := rand.Read(tokenBytes); err != nil {
 		return "", fmt.Errorf("failed to generate token: %w", err)
 	}
-	token := hex.EncodeToString(tokenBytes)
+	return hex.EncodeToString(tokenBytes), nil
+}
 
-	// Create session
-	session := &Session{
-		Token:     token,
-		Username:  username,
-		Role:      user.Role,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(g.config.SessionDuration),
-		IPAddress: ipAddress,
+// addSession indexes a session; callers must hold g.mu
+func (g *Guardian) addSession(session *Session) {
+	g.sessions[session.Token] = session
+	if session.RefreshToken != "" {
+		g.refresh[session.RefreshToken] = session.Token
+	}
+}
+
+// removeSession deletes a session from the store and index; callers must
+// hold g.mu
+func (g *Guardian) removeSession(session *Session) error {
+	if err := g.store.DeleteSession(session.Token); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	delete(g.sessions, session.Token)
+	delete(g.refresh, session.RefreshToken)
+	return nil
+}
+
+// lastSeen is when a session was last used; sessions stored before
+// LastSeenAt was recorded count from login
+func lastSeen(session *Session) time.Time {
+	if session.LastSeenAt.IsZero() {
+		return session.CreatedAt
 	}
+	return session.LastSeenAt
+}
 
-	g.sessions[token] = session
+// idle reports whether a session has gone unused past the idle timeout
+func (g *Guardian) idle(session *Session, now time.Time) bool {
+	timeout := g.config.SessionIdleTimeout
+	return timeout > 0 && now.Sub(lastSeen(session)) > timeout
+}
 
-	return token, nil
+// tokenExpired reports whether a session's token can no longer
+// authenticate requests
+func (g *Guardian) tokenExpired(session *Session, now time.Time) bool {
+	return now.After(session.ExpiresAt) || g.idle(session, now)
 }
 
-// ValidateSession checks if a session token is valid
+// RefreshExpiresAt returns when a session can no longer be refreshed, the
+// zero time if refreshing is disabled. An idle timeout can end it sooner.
+func (g *Guardian) RefreshExpiresAt(session *Session) time.Time {
+	if session.RefreshToken == "" || g.config.SessionMaxLifetime <= 0 {
+		return time.Time{}
+	}
+	return session.CreatedAt.Add(g.config.SessionMaxLifetime)
+}
+
+// ended reports whether a session can neither authenticate nor be refreshed
+func (g *Guardian) ended(session *Session, now time.Time) bool {
+	if !g.tokenExpired(session, now) {
+		return false
+	}
+	limit := g.RefreshExpiresAt(session)
+	return limit.IsZero() || now.After(limit) || g.idle(session, now)
+}
+
+// lastSeenPersistInterval limits how often use of a session is written to
+// the store when an idle timeout is enforced
+const lastSeenPersistInterval = time.Minute
+
+// ValidateSession checks if a session token is valid and records its use,
+// which keeps the session from idling out. It returns a copy of the session.
+// JWTs this Guardian signed must still be sessions it holds, so revocation
+// and the idle timeout apply to them at once; JWTs from other Guardians are
+// checked by signature and claims alone.
 func (g *Guardian) ValidateSession(token string) (*Session, error) {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
+	if isJWT(token) && len(g.jwtSources) > 0 && !g.signedHere(token) {
+		return g.validateJWT(token)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
 
 	session, exists := g.sessions[token]
 	if !exists {
 		return nil, ErrInvalidToken
 	}
 
-	if time.Now().After(session.ExpiresAt) {
+	now := time.Now()
+	if g.tokenExpired(session, now) {
 		return nil, ErrInvalidToken
 	}
 
-	return session, nil
+	persist := g.config.SessionIdleTimeout > 0 && now.Sub(lastSeen(session)) >= lastSeenPersistInterval
+	session.LastSeenAt = now
+	if persist {
+		// A failed write only risks an early idle timeout after a restart
+		g.store.PutSession(session)
+	}
+
+	sessionCopy := *session
+	return &sessionCopy, nil
 }
 
-// RequireRole checks if a session has the required role
+// RefreshSession exchanges a refresh token for a new session with a fresh
+// token and refresh token, revoking the old ones. The new session keeps the
+// login time, so refreshing never outlasts SessionMaxLifetime, and takes the
+// user's current role.
+func (g *Guardian) RefreshSession(refreshToken, ipAddress string) (*Session, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.rateLimiter.Allow(ipAddress) {
+		return nil, ErrRateLimitExceeded
+	}
+	if !g.ipAllowedLocked(ipAddress, "") {
+		return nil, ErrUnauthorized
+	}
+
+	token, exists := g.refresh[refreshToken]
+	if !exists {
+		return nil, ErrInvalidToken
+	}
+	old := g.sessions[token]
+	now := time.Now()
+	if limit := g.RefreshExpiresAt(old); limit.IsZero() || now.After(limit) || g.idle(old, now) {
+		return nil, ErrInvalidToken
+	}
+	user, exists := g.users[old.Username]
+	if !exists || !user.Enabled {
+		return nil, ErrInvalidToken
+	}
+	if !g.ipAllowedLocked(ipAddress, user.Role) {
+		return nil, ErrUnauthorized
+	}
+
+	session, err := g.newSession(user, ipAddress, old.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.removeSession(old); err != nil {
+		return nil, err
+	}
+	sessionCopy := *session
+	return &sessionCopy, nil
+}
+
+// RequireRole checks if a session has the required role or one above it
 func (g *Guardian) RequireRole(token string, requiredRole Role) error {
 	session, err := g.ValidateSession(token)
 	if err != nil {
 		return err
 	}
-
-	// King Arthur has access to everything
-	if session.Role == RoleKingArthur {
-		return nil
-	}
-
-	// Check role hierarchy
-	if session.Role != requiredRole {
+	if !session.Role.AtLeast(requiredRole) {
 		return ErrUnauthorized
 	}
-
 	return nil
 }
 
@@ -264,29 +864,96 @@ func (g *Guardian) RevokeSession(token string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	if _, exists := g.sessions[token]; !exists {
+	session, exists := g.sessions[token]
+	if !exists {
 		return ErrInvalidToken
 	}
-
-	delete(g.sessions, token)
+	if err := g.removeSession(session); err != nil {
+		return err
+	}
+	g.audit(AuditSessionRevoked, session.Username, session.IPAddress, map[string]string{"sessions": "1"})
 	return nil
 }
 
-// AddToWhitelist adds an IP address to the whitelist
-func (g *Guardian) AddToWhitelist(ip string) {
+// RevokeUserSessions removes every session of a user, for example after a
+// password change or a suspected compromise, returning how many were revoked
+func (g *Guardian) RevokeUserSessions(username string) (int, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	g.ipWhitelist[ip] = true
+	revoked, err := g.revokeUserSessions(username)
+	g.audit(AuditSessionRevoked, username, "", map[string]string{"sessions": strconv.Itoa(revoked)})
+	return revoked, err
+}
+
+// revokeUserSessions removes a user's sessions; callers must hold g.mu
+func (g *Guardian) revokeUserSessions(username string) (int, error) {
+	revoked := 0
+	for _, session := range g.sessions {
+		if session.Username != username {
+			continue
+		}
+		if err := g.removeSession(session); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// AddToWhitelist adds an IP address or CIDR network to the allow rules
+func (g *Guardian) AddToWhitelist(rule string) error {
+	return g.editIPRules(rule, false, true, AuditWhitelistAdded)
+}
+
+// RemoveFromWhitelist removes an IP address or CIDR network from the allow
+// rules
+func (g *Guardian) RemoveFromWhitelist(rule string) error {
+	return g.editIPRules(rule, false, false, AuditWhitelistRemoved)
+}
+
+// AddToDenylist adds an IP address or CIDR network to the deny rules, which
+// take precedence over allow rules
+func (g *Guardian) AddToDenylist(rule string) error {
+	return g.editIPRules(rule, true, true, AuditDenylistAdded)
+}
+
+// RemoveFromDenylist removes an IP address or CIDR network from the deny
+// rules
+func (g *Guardian) RemoveFromDenylist(rule string) error {
+	return g.editIPRules(rule, true, false, AuditDenylistRemoved)
 }
 
-// RemoveFromWhitelist removes an IP address from the whitelist
-func (g *Guardian) RemoveFromWhitelist(ip string) {
+// editIPRules adds rule to or removes it from the global allow or deny
+// rules, saving them to Config.IPPolicyFile when set. Without a policy
+// file the change lasts until the service restarts.
+func (g *Guardian) editIPRules(rule string, deny, add bool, eventType string) error {
+	prefix, err := ParseIPRule(rule)
+	if err != nil {
+		return err
+	}
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	delete(g.ipWhitelist, ip)
+	policy := g.ipPolicy.clone()
+	rules := &policy.Allow
+	if deny {
+		rules = &policy.Deny
+	}
+	*rules = slices.DeleteFunc(*rules, func(p netip.Prefix) bool { return p == prefix })
+	if add {
+		*rules = append(*rules, prefix)
+	}
+	if g.config.IPPolicyFile != "" {
+		if err := saveIPPolicy(g.config.IPPolicyFile, policy); err != nil {
+			return err
+		}
+	}
+	g.ipPolicy = policy
+	g.audit(eventType, "", prefix.String(), nil)
+	return nil
 }
 
-// CleanupExpiredSessions removes expired sessions
+// CleanupExpiredSessions removes sessions that can neither authenticate nor
+// be refreshed
 func (g *Guardian) CleanupExpiredSessions() int {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -294,9 +961,11 @@ func (g *Guardian) CleanupExpiredSessions() int {
 	removed := 0
 	now := time.Now()
 
-	for token, session := range g.sessions {
-		if now.After(session.ExpiresAt) {
-			delete(g.sessions, token)
+	for _, session := range g.sessions {
+		if g.ended(session, now) {
+			if err := g.removeSession(session); err != nil {
+				continue
+			}
 			removed++
 		}
 	}
@@ -311,7 +980,7 @@ func (g *Guardian) GetUserInfo(username string) (*User, error) {
 
 	user, exists := g.users[username]
 	if !exists {
-		return nil, fmt.Errorf("user not found: %s", username)
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, username)
 	}
 
 	// Return a copy to prevent external modification
@@ -319,6 +988,22 @@ func (g *Guardian) GetUserInfo(username string) (*User, error) {
 	return &userCopy, nil
 }
 
+// ListUsers returns copies of all users, sorted by username
+func (g *Guardian) ListUsers() []*User {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	users := make([]*User, 0, len(g.users))
+	for _, user := range g.users {
+		userCopy := *user
+		users = append(users, &userCopy)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users
+}
+
 // RateLimiter implements token bucket rate limiting
 type RateLimiter struct {
 	mu       sync.Mutex
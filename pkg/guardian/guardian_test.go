@@ -260,9 +260,10 @@ func TestIPWhitelist(t *testing.T) {
 }
 
 func TestRateLimiting(t *testing.T) {
-	config := DefaultConfig()
+	config := testConfig()
 	config.RateLimitRequests = 5
-	config.RateLimitWindow = 10 * time.Second // Longer window to avoid refills during test
+	config.RateLimitWindow = time.Hour // no refills during the test
+	config.LockoutThreshold = 0        // TestLockoutRateLimitOrder covers both
 	g := NewGuardian(config)
 
 	g.CreateUser("tristan", "isolde999", RoleKnight)
@@ -285,6 +286,7 @@ func TestRateLimiting(t *testing.T) {
 func TestCleanupExpiredSessions(t *testing.T) {
 	config := DefaultConfig()
 	config.SessionDuration = 50 * time.Millisecond
+	config.SessionMaxLifetime = 0 // not refreshable, so ended with the token
 	g := NewGuardian(config)
 
 	// Create multiple users and sessions
@@ -361,3 +363,202 @@ func BenchmarkValidateSession(b *testing.B) {
 		g.ValidateSession(token)
 	}
 }
+
+func TestRefreshSession(t *testing.T) {
+	g := NewGuardian(testConfig())
+	g.CreateUser("gawain", "greenknight1", RoleKnight)
+
+	token, err := g.Authenticate("gawain", "greenknight1", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Authentication failed: %v", err)
+	}
+	session, _ := g.ValidateSession(token)
+	if session.RefreshToken == "" {
+		t.Fatal("Expected a refresh token")
+	}
+
+	refreshed, err := g.RefreshSession(session.RefreshToken, "10.0.0.9")
+	if err != nil {
+		t.Fatalf("RefreshSession failed: %v", err)
+	}
+	if refreshed.Token == token || refreshed.RefreshToken == session.RefreshToken {
+		t.Error("Expected new tokens on refresh")
+	}
+	if !refreshed.CreatedAt.Equal(session.CreatedAt) || refreshed.IPAddress != "10.0.0.9" {
+		t.Errorf("Unexpected refreshed session: %+v", refreshed)
+	}
+	if _, err := g.ValidateSession(refreshed.Token); err != nil {
+		t.Errorf("Expected refreshed token to be valid: %v", err)
+	}
+	if _, err := g.ValidateSession(token); err != ErrInvalidToken {
+		t.Errorf("Expected old token to be revoked, got %v", err)
+	}
+	if _, err := g.RefreshSession(session.RefreshToken, "127.0.0.1"); err != ErrInvalidToken {
+		t.Errorf("Expected old refresh token to be rejected, got %v", err)
+	}
+	if _, err := g.RefreshSession(refreshed.Token, "127.0.0.1"); err != ErrInvalidToken {
+		t.Errorf("Expected a session token to be rejected as refresh token, got %v", err)
+	}
+}
+
+func TestRefreshSessionLimits(t *testing.T) {
+	config := testConfig()
+	config.SessionDuration = time.Hour
+	config.SessionMaxLifetime = 2 * time.Hour
+	g := NewGuardian(config)
+	g.CreateUser("bors", "steadfast22", RoleKnight)
+
+	token, _ := g.Authenticate("bors", "steadfast22", "127.0.0.1")
+	session, _ := g.ValidateSession(token)
+	if want := session.CreatedAt.Add(2 * time.Hour); !g.RefreshExpiresAt(session).Equal(want) {
+		t.Errorf("Expected refresh deadline %v, got %v", want, g.RefreshExpiresAt(session))
+	}
+
+	// Near the end of the lifetime, a refreshed token is cut short
+	g.mu.Lock()
+	g.sessions[token].CreatedAt = time.Now().Add(-90 * time.Minute)
+	g.mu.Unlock()
+	refreshed, err := g.RefreshSession(session.RefreshToken, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("RefreshSession failed: %v", err)
+	}
+	if limit := refreshed.CreatedAt.Add(2 * time.Hour); !refreshed.ExpiresAt.Equal(limit) {
+		t.Errorf("Expected token to expire at the lifetime limit %v, got %v", limit, refreshed.ExpiresAt)
+	}
+
+	// Past the lifetime, refreshing fails even with a valid token
+	g.mu.Lock()
+	g.sessions[refreshed.Token].CreatedAt = time.Now().Add(-3 * time.Hour)
+	g.mu.Unlock()
+	if _, err := g.RefreshSession(refreshed.RefreshToken, "127.0.0.1"); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken past the max lifetime, got %v", err)
+	}
+
+	// Disabled users cannot refresh
+	token, _ = g.Authenticate("bors", "steadfast22", "127.0.0.1")
+	session, _ = g.ValidateSession(token)
+	g.mu.Lock()
+	g.users["bors"].Enabled = false
+	g.mu.Unlock()
+	if _, err := g.RefreshSession(session.RefreshToken, "127.0.0.1"); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a disabled user, got %v", err)
+	}
+
+	config.SessionMaxLifetime = 0
+	g = NewGuardian(config)
+	g.CreateUser("bors", "steadfast22", RoleKnight)
+	token, _ = g.Authenticate("bors", "steadfast22", "127.0.0.1")
+	if session, _ := g.ValidateSession(token); session.RefreshToken != "" {
+		t.Error("Expected no refresh token when refreshing is disabled")
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	config := testConfig()
+	config.SessionIdleTimeout = 30 * time.Minute
+	g := NewGuardian(config)
+	g.CreateUser("kay", "seneschal33", RoleKnight)
+
+	token, _ := g.Authenticate("kay", "seneschal33", "127.0.0.1")
+	session, _ := g.ValidateSession(token)
+
+	// Use slides the idle deadline
+	g.mu.Lock()
+	g.sessions[token].LastSeenAt = time.Now().Add(-20 * time.Minute)
+	g.mu.Unlock()
+	if _, err := g.ValidateSession(token); err != nil {
+		t.Fatalf("Expected recently used session to be valid: %v", err)
+	}
+	g.mu.RLock()
+	lastSeen := g.sessions[token].LastSeenAt
+	g.mu.RUnlock()
+	if time.Since(lastSeen) > time.Minute {
+		t.Errorf("Expected use to be recorded, last seen %v", lastSeen)
+	}
+	if stored, _ := g.store.GetSession(token); !stored.LastSeenAt.Equal(lastSeen) {
+		t.Errorf("Expected use to be persisted, stored %v", stored.LastSeenAt)
+	}
+
+	// An idle session can neither authenticate nor refresh
+	g.mu.Lock()
+	g.sessions[token].LastSeenAt = time.Now().Add(-31 * time.Minute)
+	g.mu.Unlock()
+	if _, err := g.ValidateSession(token); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for idle session, got %v", err)
+	}
+	if _, err := g.RefreshSession(session.RefreshToken, "127.0.0.1"); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken refreshing idle session, got %v", err)
+	}
+	if removed := g.CleanupExpiredSessions(); removed != 1 {
+		t.Errorf("Expected idle session to be cleaned up, removed %d", removed)
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	g := NewGuardian(testConfig())
+	g.CreateUser("tristan", "isolde444", RoleKnight)
+	g.CreateUser("galahad", "grail555", RoleKnight)
+
+	first, _ := g.Authenticate("tristan", "isolde444", "127.0.0.1")
+	second, _ := g.Authenticate("tristan", "isolde444", "127.0.0.2")
+	other, _ := g.Authenticate("galahad", "grail555", "127.0.0.1")
+
+	revoked, err := g.RevokeUserSessions("tristan")
+	if err != nil || revoked != 2 {
+		t.Fatalf("Expected 2 sessions revoked, got %d (%v)", revoked, err)
+	}
+	for _, token := range []string{first, second} {
+		if _, err := g.ValidateSession(token); err != ErrInvalidToken {
+			t.Errorf("Expected revoked session, got %v", err)
+		}
+	}
+	if _, err := g.ValidateSession(other); err != nil {
+		t.Errorf("Expected other user's session to survive: %v", err)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	g := NewGuardian(testConfig())
+	g.CreateUser("lamorak", "oldpass666", RoleKnight)
+	token, _ := g.Authenticate("lamorak", "oldpass666", "127.0.0.1")
+
+	revoked, err := g.SetPassword("lamorak", "newpass777")
+	if err != nil || revoked != 1 {
+		t.Fatalf("Expected 1 session revoked, got %d (%v)", revoked, err)
+	}
+	if _, err := g.ValidateSession(token); err != ErrInvalidToken {
+		t.Errorf("Expected session revoked by password change, got %v", err)
+	}
+	if _, err := g.Authenticate("lamorak", "oldpass666", "127.0.0.1"); err != ErrInvalidCredentials {
+		t.Errorf("Expected old password to fail, got %v", err)
+	}
+	if _, err := g.Authenticate("lamorak", "newpass777", "127.0.0.1"); err != nil {
+		t.Errorf("Expected new password to work: %v", err)
+	}
+	if _, err := g.SetPassword("nobody", "x"); err == nil {
+		t.Error("Expected an error for an unknown user")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("GUARDIAN_SESSION_DURATION", "15m")
+	t.Setenv("GUARDIAN_SESSION_IDLE_TIMEOUT", "5m")
+	t.Setenv("GUARDIAN_SESSION_MAX_LIFETIME", "0")
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv failed: %v", err)
+	}
+	if config.SessionDuration != 15*time.Minute || config.SessionIdleTimeout != 5*time.Minute || config.SessionMaxLifetime != 0 {
+		t.Errorf("Unexpected session config: %v %v %v", config.SessionDuration, config.SessionIdleTimeout, config.SessionMaxLifetime)
+	}
+
+	t.Setenv("GUARDIAN_SESSION_IDLE_TIMEOUT", "soon")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("Expected an error for an invalid duration")
+	}
+	t.Setenv("GUARDIAN_SESSION_IDLE_TIMEOUT", "")
+	t.Setenv("GUARDIAN_SESSION_DURATION", "0s")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("Expected an error for a zero session duration")
+	}
+}
@@ -0,0 +1,306 @@
+package guardian
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IP access policy. Rules are networks in CIDR notation or single IPv4 or
+// IPv6 addresses. Deny rules take precedence over allow rules, and role
+// rules further restrict where each role may log in and use its sessions.
+// A policy file is reloaded when it changes, so operators can edit the
+// networks of a running service.
+
+// ErrInvalidIPPolicy indicates an IP rule or policy file that cannot be
+// parsed
+var ErrInvalidIPPolicy = errors.New("invalid IP policy")
+
+// DefaultIPPolicyReload is how often a policy file is checked for changes
+const DefaultIPPolicyReload = 10 * time.Second
+
+// IPRules are the networks allowed and denied
+type IPRules struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// IPPolicy decides which client addresses may authenticate. Addresses in a
+// deny rule are always refused. With Require set an address must also
+// match an allow rule; otherwise allow rules are not needed. A role with
+// allow rules is limited to them, and its deny rules add to the global
+// ones.
+type IPPolicy struct {
+	Require bool
+	IPRules
+	Roles map[Role]IPRules
+}
+
+// ParseIPRule parses a network such as "10.0.0.0/8" or "2001:db8::/32", or
+// a single address, which is a network of one
+func ParseIPRule(rule string) (netip.Prefix, error) {
+	rule = strings.TrimSpace(rule)
+	if strings.Contains(rule, "/") {
+		prefix, err := netip.ParsePrefix(rule)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: %q is not a network", ErrInvalidIPPolicy, rule)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, ok := parseIP(rule)
+	if !ok {
+		return netip.Prefix{}, fmt.Errorf("%w: %q is not an IP address or network", ErrInvalidIPPolicy, rule)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// parseIP parses a client address, with IPv4-mapped IPv6 addresses
+// unmapped so that IPv4 rules match them
+func parseIP(ip string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// containsIP reports whether any of prefixes contains addr
+func containsIP(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether ip may authenticate as role. An empty role checks
+// the global rules only. Unparseable addresses match no rule.
+func (p *IPPolicy) Allows(ip string, role Role) bool {
+	addr, _ := parseIP(ip)
+	if containsIP(p.Deny, addr) {
+		return false
+	}
+	if p.Require && !containsIP(p.Allow, addr) {
+		return false
+	}
+	if rules, ok := p.Roles[role]; ok && role != "" {
+		if containsIP(rules.Deny, addr) {
+			return false
+		}
+		if len(rules.Allow) > 0 && !containsIP(rules.Allow, addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// clone returns a copy of the policy that shares no slices with it
+func (p *IPPolicy) clone() *IPPolicy {
+	c := &IPPolicy{Require: p.Require, IPRules: IPRules{Allow: slices.Clone(p.Allow), Deny: slices.Clone(p.Deny)}}
+	if p.Roles != nil {
+		c.Roles = make(map[Role]IPRules, len(p.Roles))
+		for role, rules := range p.Roles {
+			c.Roles[role] = IPRules{Allow: slices.Clone(rules.Allow), Deny: slices.Clone(rules.Deny)}
+		}
+	}
+	return c
+}
+
+// ipPolicyFile is the YAML form of an IPPolicy
+type ipPolicyFile struct {
+	RequireWhitelist bool                     `yaml:"require_whitelist"`
+	Allow            []string                 `yaml:"allow"`
+	Deny             []string                 `yaml:"deny"`
+	Roles            map[Role]ipRuleListsFile `yaml:"roles"`
+}
+
+type ipRuleListsFile struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// parseIPRules parses allow and deny lists
+func parseIPRules(allow, deny []string) (IPRules, error) {
+	var rules IPRules
+	for _, list := range []struct {
+		rules []string
+		into  *[]netip.Prefix
+	}{{allow, &rules.Allow}, {deny, &rules.Deny}} {
+		for _, rule := range list.rules {
+			prefix, err := ParseIPRule(rule)
+			if err != nil {
+				return IPRules{}, err
+			}
+			*list.into = append(*list.into, prefix)
+		}
+	}
+	return rules, nil
+}
+
+// ParseIPPolicy parses a YAML policy:
+//
+//	require_whitelist: true
+//	allow: [10.20.0.0/16, "2001:db8:42::/48"]
+//	deny: [10.20.99.0/24]
+//	roles:
+//	  king_arthur:
+//	    allow: [10.20.1.0/24]
+func ParseIPPolicy(data []byte) (*IPPolicy, error) {
+	var file ipPolicyFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIPPolicy, err)
+	}
+
+	global, err := parseIPRules(file.Allow, file.Deny)
+	if err != nil {
+		return nil, err
+	}
+	policy := &IPPolicy{Require: file.RequireWhitelist, IPRules: global}
+	for role, lists := range file.Roles {
+		if role.rank() == 0 {
+			return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidIPPolicy, role)
+		}
+		rules, err := parseIPRules(lists.Allow, lists.Deny)
+		if err != nil {
+			return nil, fmt.Errorf("role %s: %w", role, err)
+		}
+		if policy.Roles == nil {
+			policy.Roles = make(map[Role]IPRules)
+		}
+		policy.Roles[role] = rules
+	}
+	return policy, nil
+}
+
+// formatIPRules returns rules in the form parseIPRules reads
+func formatIPRules(rules []netip.Prefix) []string {
+	out := make([]string, len(rules))
+	for i, rule := range rules {
+		out[i] = rule.String()
+	}
+	return out
+}
+
+// marshal returns the policy in the YAML form ParseIPPolicy reads
+func (p *IPPolicy) marshal() ([]byte, error) {
+	file := ipPolicyFile{RequireWhitelist: p.Require, Allow: formatIPRules(p.Allow), Deny: formatIPRules(p.Deny)}
+	for role, rules := range p.Roles {
+		if file.Roles == nil {
+			file.Roles = make(map[Role]ipRuleListsFile)
+		}
+		file.Roles[role] = ipRuleListsFile{Allow: formatIPRules(rules.Allow), Deny: formatIPRules(rules.Deny)}
+	}
+	return yaml.Marshal(&file)
+}
+
+// saveIPPolicy replaces the policy file at path with policy. Comments in
+// the file are not kept.
+func saveIPPolicy(path string, policy *IPPolicy) error {
+	data, err := policy.marshal()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save IP policy: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save IP policy: %w", err)
+	}
+	return nil
+}
+
+// LoadIPPolicy reads a YAML policy file
+func LoadIPPolicy(path string) (*IPPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParseIPPolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// ipAllowedLocked reports whether ip may authenticate as role under the
+// current policy and Config.RequireIPWhitelist; callers must hold g.mu
+func (g *Guardian) ipAllowedLocked(ip string, role Role) bool {
+	policy := *g.ipPolicy
+	policy.Require = policy.Require || g.config.RequireIPWhitelist
+	return policy.Allows(ip, role)
+}
+
+// IPPolicy returns a copy of the current IP policy
+func (g *Guardian) IPPolicy() *IPPolicy {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.ipPolicy.clone()
+}
+
+// SetIPPolicy replaces the IP policy, including rules added with
+// AddToWhitelist and AddToDenylist. It does not write the policy file.
+func (g *Guardian) SetIPPolicy(policy *IPPolicy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ipPolicy = policy.clone()
+	g.audit(AuditIPPolicyLoaded, "", "", policy.summary())
+}
+
+// summary counts the rules of a policy for the audit log
+func (p *IPPolicy) summary() map[string]string {
+	return map[string]string{
+		"require": strconv.FormatBool(p.Require),
+		"allow":   strconv.Itoa(len(p.Allow)),
+		"deny":    strconv.Itoa(len(p.Deny)),
+		"roles":   strconv.Itoa(len(p.Roles)),
+	}
+}
+
+// watchIPPolicy reloads the policy file at path whenever its contents
+// change, until stop is closed. A file that fails to load leaves the
+// current policy in place and is audited once per distinct error.
+func (g *Guardian) watchIPPolicy(path string, interval time.Duration, loaded [sha256.Size]byte, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failed := ""
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		data, err := os.ReadFile(path)
+		if err == nil && sha256.Sum256(data) == loaded {
+			continue
+		}
+		var policy *IPPolicy
+		if err == nil {
+			policy, err = ParseIPPolicy(data)
+		}
+		if err != nil {
+			if err.Error() != failed {
+				failed = err.Error()
+				g.mu.Lock()
+				g.audit(AuditIPPolicyRejected, "", "", map[string]string{"path": path, "error": failed})
+				g.mu.Unlock()
+			}
+			continue
+		}
+		failed, loaded = "", sha256.Sum256(data)
+		g.SetIPPolicy(policy)
+	}
+}
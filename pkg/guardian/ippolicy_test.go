@@ -0,0 +1,275 @@
+package guardian
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseIPRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"10.1.2.3", "10.1.2.3/32"},
+		{" 10.1.2.3 ", "10.1.2.3/32"},
+		{"10.1.2.3/16", "10.1.0.0/16"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"2001:db8::1/32", "2001:db8::/32"},
+		{"::ffff:10.1.2.3", "10.1.2.3/32"},
+	}
+	for _, tt := range tests {
+		got, err := ParseIPRule(tt.rule)
+		if err != nil {
+			t.Errorf("ParseIPRule(%q) failed: %v", tt.rule, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseIPRule(%q) = %s, want %s", tt.rule, got, tt.want)
+		}
+	}
+
+	for _, rule := range []string{"", "camelot", "10.1.2", "10.0.0.0/33", "2001:db8::/129"} {
+		if _, err := ParseIPRule(rule); !errors.Is(err, ErrInvalidIPPolicy) {
+			t.Errorf("ParseIPRule(%q): expected ErrInvalidIPPolicy, got %v", rule, err)
+		}
+	}
+}
+
+func TestIPPolicyAllows(t *testing.T) {
+	policy, err := ParseIPPolicy([]byte(`
+require_whitelist: true
+allow: [10.20.0.0/16, "2001:db8:42::/48"]
+deny: [10.20.99.0/24]
+roles:
+  king_arthur:
+    allow: [10.20.1.0/24]
+  squire:
+    deny: [10.20.2.0/24]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		role Role
+		want bool
+	}{
+		{"10.20.5.5", "", true},
+		{"10.21.5.5", "", false},
+		{"10.20.99.1", "", false},
+		{"2001:db8:42::9", "", true},
+		{"2001:db8:43::9", "", false},
+		{"::ffff:10.20.5.5", "", true},
+		{"not-an-ip", "", false},
+		{"10.20.1.1", RoleKingArthur, true},
+		{"10.20.5.5", RoleKingArthur, false},
+		{"10.20.5.5", RoleKnight, true},
+		{"10.20.2.2", RoleSquire, false},
+		{"10.20.2.2", RoleKnight, true},
+	}
+	for _, tt := range tests {
+		if got := policy.Allows(tt.ip, tt.role); got != tt.want {
+			t.Errorf("Allows(%s, %q) = %v, want %v", tt.ip, tt.role, got, tt.want)
+		}
+	}
+
+	// Without require_whitelist only deny rules restrict the global check
+	policy.Require = false
+	if !policy.Allows("192.168.1.1", "") || policy.Allows("10.20.99.1", "") {
+		t.Error("Expected deny rules alone to apply without require_whitelist")
+	}
+}
+
+func TestParseIPPolicyErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown field": "kings: [10.0.0.1]",
+		"unknown role":  "roles:\n  merlin:\n    allow: [10.0.0.1]",
+		"bad rule":      "deny: [10.0.0.0/40]",
+		"bad role rule": "roles:\n  knight:\n    allow: [avalon]",
+	} {
+		if _, err := ParseIPPolicy([]byte(data)); !errors.Is(err, ErrInvalidIPPolicy) {
+			t.Errorf("%s: expected ErrInvalidIPPolicy, got %v", name, err)
+		}
+	}
+
+	policy, err := ParseIPPolicy(nil)
+	if err != nil {
+		t.Fatalf("Expected an empty policy to parse, got %v", err)
+	}
+	if !policy.Allows("192.168.1.1", RoleKnight) {
+		t.Error("Expected an empty policy to allow every address")
+	}
+}
+
+func TestIPPolicyRoles(t *testing.T) {
+	g := NewGuardian(testConfig())
+	defer g.Close()
+	g.CreateUser("arthur", "excalibur123", RoleKingArthur)
+	g.CreateUser("kay", "seneschal99", RoleKnight)
+	policy, err := ParseIPPolicy([]byte("roles:\n  king_arthur:\n    allow: [10.0.1.0/24]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetIPPolicy(policy)
+
+	if _, err := g.Authenticate("arthur", "excalibur123", "10.0.2.1"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized outside the role's network, got %v", err)
+	}
+	token, err := g.Authenticate("arthur", "excalibur123", "10.0.1.1")
+	if err != nil {
+		t.Fatalf("Expected login from the role's network, got %v", err)
+	}
+	if _, err := g.Authenticate("kay", "seneschal99", "10.0.2.1"); err != nil {
+		t.Errorf("Expected other roles to be unrestricted, got %v", err)
+	}
+
+	// Sessions are only usable from the role's network
+	h := g.Middleware(protectedHandler, RoleKingArthur)
+	if rec := serve(h, token, "10.0.1.1:4242"); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 from the role's network, got %d", rec.Code)
+	}
+	if rec := serve(h, token, "10.0.2.1:4242"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 outside the role's network, got %d", rec.Code)
+	}
+
+	session, _ := g.ValidateSession(token)
+	if _, err := g.RefreshSession(session.RefreshToken, "10.0.2.1"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected refresh outside the role's network to fail, got %v", err)
+	}
+	if _, err := g.RefreshSession(session.RefreshToken, "10.0.1.2"); err != nil {
+		t.Errorf("Expected refresh from the role's network, got %v", err)
+	}
+}
+
+func TestIPRuleCIDR(t *testing.T) {
+	config := testConfig()
+	config.RequireIPWhitelist = true
+	g := NewGuardian(config)
+	defer g.Close()
+	sink := &memoryAuditSink{}
+	audit, _ := NewAuditLog(sink)
+	g.SetAuditLog(audit)
+	g.CreateUser("bedivere", "one-handed1", RoleKnight)
+
+	if err := g.AddToWhitelist("10.8.0.0/16"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddToWhitelist("10.8.3.7/16"); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.IPPolicy().Allow; len(got) != 1 {
+		t.Errorf("Expected equal networks to be added once, got %v", got)
+	}
+	if _, err := g.Authenticate("bedivere", "one-handed1", "10.8.200.1"); err != nil {
+		t.Fatalf("Expected login from the allowed network, got %v", err)
+	}
+
+	if err := g.AddToDenylist("10.8.200.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Authenticate("bedivere", "one-handed1", "10.8.200.1"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected the deny rule to win, got %v", err)
+	}
+	if err := g.RemoveFromDenylist("10.8.200.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Authenticate("bedivere", "one-handed1", "10.8.200.1"); err != nil {
+		t.Errorf("Expected login after removing the deny rule, got %v", err)
+	}
+
+	if err := g.AddToWhitelist("10.8.0.0/33"); !errors.Is(err, ErrInvalidIPPolicy) {
+		t.Errorf("Expected ErrInvalidIPPolicy, got %v", err)
+	}
+	want := []string{AuditWhitelistAdded, AuditWhitelistAdded, AuditDenylistAdded, AuditDenylistRemoved}
+	if got := slices.DeleteFunc(sink.types(), func(event string) bool {
+		return event != AuditWhitelistAdded && event != AuditDenylistAdded && event != AuditDenylistRemoved
+	}); !slices.Equal(got, want) {
+		t.Errorf("Audit events = %v, want %v", got, want)
+	}
+}
+
+func TestIPPolicyReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip-policy.yaml")
+	if err := os.WriteFile(path, []byte("deny: [10.0.0.0/8]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig()
+	config.IPPolicyFile = path
+	config.IPPolicyReload = 10 * time.Millisecond
+	g := NewGuardian(config)
+	defer g.Close()
+	sink := &memoryAuditSink{}
+	audit, _ := NewAuditLog(sink)
+	g.SetAuditLog(audit)
+
+	if g.IPPolicy().Allows("10.1.1.1", "") {
+		t.Fatal("Expected the policy file to be loaded")
+	}
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// A broken edit keeps the current policy
+	if err := os.WriteFile(path, []byte("deny: [10.0.0.0/80]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the rejected reload", func() bool { return slices.Contains(sink.types(), AuditIPPolicyRejected) })
+	if g.IPPolicy().Allows("10.1.1.1", "") {
+		t.Error("Expected a rejected file to keep the current policy")
+	}
+
+	if err := os.WriteFile(path, []byte("deny: [192.168.0.0/16]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the reload", func() bool { return g.IPPolicy().Allows("10.1.1.1", "") })
+	if g.IPPolicy().Allows("192.168.1.1", "") {
+		t.Error("Expected the reloaded deny rule to apply")
+	}
+	if !slices.Contains(sink.types(), AuditIPPolicyLoaded) {
+		t.Errorf("Expected an %s event, got %v", AuditIPPolicyLoaded, sink.types())
+	}
+}
+
+func TestIPRulesSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip-policy.yaml")
+	policy := "require_whitelist: true\ndeny: [10.0.0.0/8]\nroles:\n  king_arthur:\n    allow: [10.20.1.0/24]\n"
+	if err := os.WriteFile(path, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig()
+	config.IPPolicyFile = path
+	config.IPPolicyReload = 0
+	g := NewGuardian(config)
+	defer g.Close()
+
+	if err := g.AddToWhitelist("192.0.2.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RemoveFromDenylist("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart loads the edited rules from the file
+	restarted := NewGuardian(config)
+	defer restarted.Close()
+	saved := restarted.IPPolicy()
+	if !saved.Require || len(saved.Deny) != 0 || !saved.Allows("192.0.2.1", "") {
+		t.Errorf("Saved policy = %+v", saved)
+	}
+	if saved.Allows("192.0.2.1", RoleKingArthur) {
+		t.Error("Expected the saved policy to keep the role rules")
+	}
+}
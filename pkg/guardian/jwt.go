@@ -0,0 +1,512 @@
+package guardian
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stateless sessions. In SessionModeJWT a session token is a JWT signed
+// with Ed25519 that carries the username and role, so a service holding
+// the public keys can check it without the session map of the Guardian
+// that issued it. The keys are published as a JSON Web Key Set for other
+// services to fetch, and rotated with the retired keys still published
+// until the tokens they signed have expired. The issuer itself still
+// checks its session map, and keeps JWTs short-lived behind their refresh
+// tokens so a revocation reaches the other services within JWTLifetime.
+
+// SessionMode selects the kind of session token a Guardian issues
+type SessionMode string
+
+const (
+	// SessionModeOpaque issues random tokens looked up in the session map
+	SessionModeOpaque SessionMode = "opaque"
+	// SessionModeJWT issues signed JWTs that are checked without it
+	SessionModeJWT SessionMode = "jwt"
+)
+
+const (
+	// DefaultJWTIssuer is the "iss" claim of tokens a Guardian issues and
+	// expects
+	DefaultJWTIssuer = "excalibur-exs-guardian"
+	// DefaultJWTKeyRotation is how long a signing key is used
+	DefaultJWTKeyRotation = 30 * 24 * time.Hour
+	// DefaultJWTLifetime is how long a refreshable session's JWT is valid
+	DefaultJWTLifetime = 5 * time.Minute
+	// jwtLeeway tolerates clock differences between services
+	jwtLeeway = 30 * time.Second
+	// jwksRefreshInterval limits how often a remote key set is fetched
+	// for an unknown key ID, and how long a fetched set is trusted
+	jwksRefreshInterval = 30 * time.Second
+	jwksMaxAge          = 10 * time.Minute
+)
+
+// ErrUnknownJWTKey indicates a token signed with a key that is not, or no
+// longer, published
+var ErrUnknownJWTKey = errors.New("unknown JWT signing key")
+
+// JWTKeySource looks up the public key a token names in its "kid" header
+type JWTKeySource interface {
+	PublicKey(kid string) (ed25519.PublicKey, error)
+}
+
+// jwtHeader is the JOSE header of a Guardian token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims are the claims of a Guardian token. AuthTime is the login, kept
+// across refreshes like Session.CreatedAt.
+type jwtClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Role      Role   `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	AuthTime  int64  `json:"auth_time"`
+	ID        string `json:"jti"`
+}
+
+var jwtEncoding = base64.RawURLEncoding
+
+// signJWT encodes claims as a compact JWS signed with key
+func signJWT(key *jwtKey, claims *jwtClaims) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "EdDSA", Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := jwtEncoding.EncodeToString(header) + "." + jwtEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(key.Seed), []byte(signed))
+	return signed + "." + jwtEncoding.EncodeToString(sig), nil
+}
+
+// isJWT reports whether token has the three parts of a compact JWS rather
+// than the hex of an opaque token
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// verifyJWT checks the signature of token against sources and its issuer
+// and lifetime at now, returning the claims
+func verifyJWT(token, issuer string, sources []JWTKeySource, now time.Time) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Alg != "EdDSA" {
+		return nil, ErrInvalidToken
+	}
+	sig, err := jwtEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var pub ed25519.PublicKey
+	for _, source := range sources {
+		if pub, err = source.PublicKey(header.Kid); err == nil {
+			break
+		}
+	}
+	if pub == nil || !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Issuer != issuer || claims.Subject == "" || claims.Role.rank() == 0 {
+		return nil, ErrInvalidToken
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtLeeway)) || now.Before(time.Unix(claims.IssuedAt, 0).Add(-jwtLeeway)) {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	raw, err := jwtEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// jwtKey is an Ed25519 signing key. A retired key no longer signs but stays
+// published until Expires.
+type jwtKey struct {
+	ID        string     `json:"kid"`
+	Seed      []byte     `json:"seed"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+	Expires   *time.Time `json:"expires,omitempty"`
+}
+
+func newJWTKey(now time.Time) (*jwtKey, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, fmt.Errorf("failed to generate JWT key: %w", err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate JWT key ID: %w", err)
+	}
+	return &jwtKey{ID: hex.EncodeToString(id), Seed: seed, CreatedAt: now.UTC()}, nil
+}
+
+func (k *jwtKey) publicKey() ed25519.PublicKey {
+	return ed25519.NewKeyFromSeed(k.Seed).Public().(ed25519.PublicKey)
+}
+
+// JWTKeyring holds the signing keys of a Guardian, newest first, in a file
+// readable only by its owner
+type JWTKeyring struct {
+	mu       sync.RWMutex
+	path     string
+	rotation time.Duration
+	retain   time.Duration
+	keys     []*jwtKey
+}
+
+// OpenJWTKeyring loads the keyring at path, creating it with a fresh key if
+// it does not exist. An empty path keeps the keys in memory, so tokens stop
+// validating on restart. Keys sign for rotation before Rotate replaces
+// them, if rotation is positive, and retired keys are published for retain,
+// the longest a token they signed can live.
+func OpenJWTKeyring(path string, rotation, retain time.Duration) (*JWTKeyring, error) {
+	k := &JWTKeyring{path: path, rotation: rotation, retain: retain}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, &k.keys); err != nil {
+				return nil, fmt.Errorf("invalid JWT keyring %s: %w", path, err)
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("failed to read JWT keyring: %w", err)
+		}
+	}
+	for _, key := range k.keys {
+		if len(key.Seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid JWT keyring %s: key %s has a bad seed", path, key.ID)
+		}
+	}
+	if len(k.keys) == 0 || k.keys[0].RetiredAt != nil {
+		if _, err := k.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Rotate retires the current signing key in favour of a new one and drops
+// retired keys whose tokens have all expired, returning the new key ID
+func (k *JWTKeyring) Rotate() (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now().UTC()
+	key, err := newJWTKey(now)
+	if err != nil {
+		return "", err
+	}
+	keys := []*jwtKey{key}
+	for _, old := range k.keys {
+		if old.RetiredAt == nil {
+			expires := now.Add(k.retain)
+			old.RetiredAt, old.Expires = &now, &expires
+		}
+		if now.Before(*old.Expires) {
+			keys = append(keys, old)
+		}
+	}
+	if err := k.save(keys); err != nil {
+		return "", err
+	}
+	k.keys = keys
+	return key.ID, nil
+}
+
+// due reports whether the signing key has been used for its rotation period
+func (k *JWTKeyring) due(now time.Time) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.rotation > 0 && now.Sub(k.keys[0].CreatedAt) >= k.rotation
+}
+
+// save atomically writes keys to the keyring file
+func (k *JWTKeyring) save(keys []*jwtKey) error {
+	if k.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return fmt.Errorf("failed to create JWT keyring directory: %w", err)
+	}
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write JWT keyring: %w", err)
+	}
+	if err := os.Rename(tmp, k.path); err != nil {
+		return fmt.Errorf("failed to replace JWT keyring: %w", err)
+	}
+	return nil
+}
+
+// sign signs claims with the current key
+func (k *JWTKeyring) sign(claims *jwtClaims) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return signJWT(k.keys[0], claims)
+}
+
+// PublicKey returns the public key with ID kid, current or retired
+func (k *JWTKeyring) PublicKey(kid string) (ed25519.PublicKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := time.Now()
+	for _, key := range k.keys {
+		if key.ID == kid && (key.Expires == nil || now.Before(*key.Expires)) {
+			return key.publicKey(), nil
+		}
+	}
+	return nil, ErrUnknownJWTKey
+}
+
+// KeyID returns the ID of the current signing key
+func (k *JWTKeyring) KeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[0].ID
+}
+
+// JWK is an Ed25519 public key in JSON Web Key form (RFC 8037)
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the published public keys, current first
+func (k *JWTKeyring) JWKS() *JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	set := &JWKS{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range k.keys {
+		if key.Expires != nil && !now.Before(*key.Expires) {
+			continue
+		}
+		set.Keys = append(set.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   jwtEncoding.EncodeToString(key.publicKey()),
+			Kid: key.ID,
+			Use: "sig",
+			Alg: "EdDSA",
+		})
+	}
+	return set
+}
+
+// RemoteJWKS is a JWTKeySource backed by another service's JWKS endpoint.
+// The set is fetched when a token names a key it does not hold, at most
+// every 30 seconds, and refreshed after ten minutes so retired keys drop
+// out.
+type RemoteJWKS struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]ed25519.PublicKey
+	fetched time.Time
+}
+
+// NewRemoteJWKS returns a key source that fetches url with client, or
+// http.DefaultClient if nil
+func NewRemoteJWKS(url string, client *http.Client) *RemoteJWKS {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RemoteJWKS{url: url, client: client}
+}
+
+// PublicKey returns the key with ID kid, fetching the set if needed
+func (r *RemoteJWKS) PublicKey(kid string) (ed25519.PublicKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	age := time.Since(r.fetched)
+	if key, ok := r.keys[kid]; ok && age < jwksMaxAge {
+		return key, nil
+	}
+	if age >= jwksRefreshInterval {
+		r.fetched = time.Now()
+		keys, err := r.fetch()
+		if err != nil {
+			return nil, err
+		}
+		r.keys = keys
+	}
+	if key, ok := r.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownJWTKey
+}
+
+// fetch downloads and parses the key set
+func (r *RemoteJWKS) fetch() (map[string]ed25519.PublicKey, error) {
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS from %s: %w", r.url, err)
+	}
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" {
+			continue
+		}
+		x, err := jwtEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[jwk.Kid] = ed25519.PublicKey(x)
+	}
+	return keys, nil
+}
+
+// issueJWT signs a token for session, rotating the signing key when it is
+// due; callers must hold g.mu
+func (g *Guardian) issueJWT(session *Session) (string, error) {
+	now := time.Now()
+	if g.jwtKeys.due(now) {
+		if err := g.rotateJWTKeyLocked(); err != nil {
+			return "", err
+		}
+	}
+	id, err := g.newToken()
+	if err != nil {
+		return "", err
+	}
+	return g.jwtKeys.sign(&jwtClaims{
+		Issuer:    g.config.JWTIssuer,
+		Subject:   session.Username,
+		Role:      session.Role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: session.ExpiresAt.Unix(),
+		AuthTime:  session.CreatedAt.Unix(),
+		ID:        id,
+	})
+}
+
+// signedHere reports whether token names one of the Guardian's own signing
+// keys, so its session must be in the session map
+func (g *Guardian) signedHere(token string) bool {
+	if g.jwtKeys == nil {
+		return false
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(strings.SplitN(token, ".", 2)[0], &header); err != nil {
+		return false
+	}
+	_, err := g.jwtKeys.PublicKey(header.Kid)
+	return err == nil
+}
+
+// validateJWT checks a JWT from another Guardian against JWKSURL without
+// a session map, so a revocation at the issuer reaches it when the token
+// expires
+func (g *Guardian) validateJWT(token string) (*Session, error) {
+	now := time.Now()
+	claims, err := verifyJWT(token, g.config.JWTIssuer, g.jwtSources, now)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		Token:      token,
+		Username:   claims.Subject,
+		Role:       claims.Role,
+		CreatedAt:  time.Unix(claims.AuthTime, 0),
+		ExpiresAt:  time.Unix(claims.ExpiresAt, 0),
+		LastSeenAt: now,
+	}, nil
+}
+
+// RotateJWTKey replaces the signing key, keeping the old one published
+// until the tokens it signed expire, and returns the new key ID
+func (g *Guardian) RotateJWTKey() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.jwtKeys == nil {
+		return "", errors.New("JWT sessions are not enabled")
+	}
+	if err := g.rotateJWTKeyLocked(); err != nil {
+		return "", err
+	}
+	return g.jwtKeys.KeyID(), nil
+}
+
+// rotateJWTKeyLocked rotates the signing key; callers must hold g.mu
+func (g *Guardian) rotateJWTKeyLocked() error {
+	old := g.jwtKeys.KeyID()
+	kid, err := g.jwtKeys.Rotate()
+	if err != nil {
+		return err
+	}
+	g.audit(AuditJWTKeyRotated, "", "", map[string]string{"kid": kid, "retired": old})
+	return nil
+}
+
+// JWTKeys returns the Guardian's signing keyring, nil unless it issues JWTs
+func (g *Guardian) JWTKeys() *JWTKeyring {
+	return g.jwtKeys
+}
+
+// JWKSHandler serves the public signing keys as a JSON Web Key Set, for
+// services configured with JWKSURL. It answers 404 when the Guardian does
+// not issue JWTs.
+func (g *Guardian) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.jwtKeys == nil {
+			writeError(w, http.StatusNotFound, errors.New("JWT sessions are not enabled"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Header().Set("Cache-Control", "max-age=300")
+		json.NewEncoder(w).Encode(g.jwtKeys.JWKS())
+	})
+}
@@ -0,0 +1,361 @@
+package guardian
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func jwtConfig(t *testing.T) *Config {
+	config := testConfig()
+	config.SessionMode = SessionModeJWT
+	config.JWTKeyFile = filepath.Join(t.TempDir(), "jwt-keys.json")
+	return config
+}
+
+func TestJWTSession(t *testing.T) {
+	g, err := NewGuardianWithStore(jwtConfig(t), NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.CreateUser("percival", "grail-quest1", RoleKnight)
+
+	session, err := g.LoginSession(Credentials{Username: "percival", Password: "grail-quest1"}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if !isJWT(session.Token) || session.RefreshToken == "" {
+		t.Fatalf("Expected a JWT and a refresh token, got %q and %q", session.Token, session.RefreshToken)
+	}
+	validated, err := g.ValidateSession(session.Token)
+	if err != nil {
+		t.Fatalf("ValidateSession failed: %v", err)
+	}
+	if validated.Username != "percival" || validated.Role != RoleKnight {
+		t.Errorf("Unexpected session %+v", validated)
+	}
+	if !validated.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", validated.ExpiresAt, session.ExpiresAt)
+	}
+	if lifetime := time.Until(session.ExpiresAt); lifetime > DefaultJWTLifetime {
+		t.Errorf("JWT lasts %v, want at most %v behind its refresh token", lifetime, DefaultJWTLifetime)
+	}
+
+	// Refreshing signs a new JWT for the same login
+	refreshed, err := g.RefreshSession(session.RefreshToken, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("RefreshSession failed: %v", err)
+	}
+	if !isJWT(refreshed.Token) || refreshed.Token == session.Token {
+		t.Errorf("Expected a new JWT, got %q", refreshed.Token)
+	}
+	if _, err := g.RefreshSession(session.RefreshToken, "10.0.0.1"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the old refresh token to be revoked, got %v", err)
+	}
+	if _, err := g.ValidateSession(session.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the refreshed-away JWT to be refused, got %v", err)
+	}
+}
+
+func TestJWTRevocation(t *testing.T) {
+	config := jwtConfig(t)
+	config.SessionIdleTimeout = 30 * time.Minute
+	g, err := NewGuardianWithStore(config, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.CreateUser("lamorak", "round-table7", RoleKnight)
+	login := func() string {
+		token, err := g.Authenticate("lamorak", "round-table7", "10.0.0.1")
+		if err != nil || !isJWT(token) {
+			t.Fatalf("Authenticate() = %q, %v", token, err)
+		}
+		return token
+	}
+
+	token := login()
+	if err := g.RevokeSession(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.ValidateSession(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a revoked JWT to be refused, got %v", err)
+	}
+
+	token = login()
+	if err := g.RevokeSessionID(SessionID(token)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.ValidateSession(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a force-revoked JWT to be refused, got %v", err)
+	}
+
+	token = login()
+	g.mu.Lock()
+	g.sessions[token].LastSeenAt = time.Now().Add(-31 * time.Minute)
+	g.mu.Unlock()
+	if _, err := g.ValidateSession(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an idle JWT to be refused, got %v", err)
+	}
+
+	token = login()
+	if err := g.DeleteUser("lamorak"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.ValidateSession(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a deleted user's JWT to be refused, got %v", err)
+	}
+}
+
+func TestJWTRejected(t *testing.T) {
+	config := jwtConfig(t)
+	g, err := NewGuardianWithStore(config, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	// A verifier holding the issuer's keys checks claims alone
+	verifier := NewGuardian(testConfig())
+	defer verifier.Close()
+	verifier.jwtSources = []JWTKeySource{g.jwtKeys}
+	key := g.jwtKeys.keys[0]
+	now := time.Now()
+	claims := func() *jwtClaims {
+		return &jwtClaims{
+			Issuer:    DefaultJWTIssuer,
+			Subject:   "mordred",
+			Role:      RoleKnight,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			AuthTime:  now.Unix(),
+			ID:        "1",
+		}
+	}
+	sign := func(c *jwtClaims) string {
+		token, err := signJWT(key, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	if _, err := verifier.ValidateSession(sign(claims())); err != nil {
+		t.Fatalf("Expected a well-formed token to validate, got %v", err)
+	}
+	// The issuer only accepts tokens for sessions it holds
+	if _, err := g.ValidateSession(sign(claims())); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token for no session of the issuer to be refused, got %v", err)
+	}
+
+	expired := claims()
+	expired.ExpiresAt = now.Add(-time.Minute).Unix()
+	issuer := claims()
+	issuer.Issuer = "morgan-le-fay"
+	role := claims()
+	role.Role = "sorcerer"
+	future := claims()
+	future.IssuedAt = now.Add(time.Hour).Unix()
+
+	stranger, _ := newJWTKey(now)
+	forged, _ := signJWT(stranger, claims())
+
+	valid := sign(claims())
+	parts := strings.Split(valid, ".")
+	escalated := claims()
+	escalated.Role = RoleKingArthur
+	payload, _ := json.Marshal(escalated)
+	tampered := parts[0] + "." + jwtEncoding.EncodeToString(payload) + "." + parts[2]
+
+	header, _ := json.Marshal(jwtHeader{Alg: "none", Kid: key.ID})
+	unsigned := jwtEncoding.EncodeToString(header) + "." + parts[1] + "."
+
+	for name, token := range map[string]string{
+		"expired":       sign(expired),
+		"wrong issuer":  sign(issuer),
+		"unknown role":  sign(role),
+		"issued later":  sign(future),
+		"unknown key":   forged,
+		"tampered":      tampered,
+		"alg none":      unsigned,
+		"not base64":    "a.b.c",
+		"missing parts": parts[0] + "." + parts[1],
+	} {
+		if _, err := verifier.ValidateSession(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestJWTRemoteValidation(t *testing.T) {
+	issuer, err := NewGuardianWithStore(jwtConfig(t), NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer issuer.Close()
+	issuer.CreateUser("tristan", "iseult-forever", RoleKnight)
+	issuer.CreateUser("arthur", "excalibur123", RoleKingArthur)
+
+	jwks := httptest.NewServer(issuer.JWKSHandler())
+	defer jwks.Close()
+
+	// The verifier shares no store or session map with the issuer
+	config := testConfig()
+	config.JWKSURL = jwks.URL
+	verifier := NewGuardian(config)
+	defer verifier.Close()
+
+	token, err := issuer.Authenticate("tristan", "iseult-forever", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := verifier.Middleware(protectedHandler, RoleKnight)
+	if rec := serve(h, token, "10.0.0.1:4242"); rec.Code != http.StatusOK || rec.Body.String() != "tristan" {
+		t.Fatalf("Expected the verifier to accept the issuer's JWT, got %d (%s)", rec.Code, rec.Body)
+	}
+	if rec := serve(verifier.Middleware(protectedHandler, RoleKingArthur), token, "10.0.0.1:4242"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a Knight JWT on a King Arthur route, got %d", rec.Code)
+	}
+
+	// Tokens signed before a rotation stay valid, and the verifier fetches
+	// the new key when a token names it
+	oldKey := issuer.JWTKeys().KeyID()
+	if _, err := issuer.RotateJWTKey(); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := issuer.Authenticate("arthur", "excalibur123", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := verifier.jwtSources[0].(*RemoteJWKS)
+	remote.mu.Lock()
+	remote.fetched = time.Time{}
+	remote.mu.Unlock()
+	if rec := serve(h, admin, "10.0.0.1:4242"); rec.Code != http.StatusOK {
+		t.Errorf("Expected a JWT signed with the new key to validate, got %d (%s)", rec.Code, rec.Body)
+	}
+	if rec := serve(h, token, "10.0.0.1:4242"); rec.Code != http.StatusOK {
+		t.Errorf("Expected a JWT signed with the retired key %s to validate, got %d", oldKey, rec.Code)
+	}
+
+	// An opaque token from elsewhere is unknown to the verifier
+	if _, err := verifier.ValidateSession(strings.Repeat("ab", 32)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for an opaque token, got %v", err)
+	}
+}
+
+func TestJWTKeyringRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "jwt.json")
+	keys, err := OpenJWTKeyring(path, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := keys.KeyID()
+	second, err := keys.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("Expected a new key ID")
+	}
+	if got := len(keys.JWKS().Keys); got != 2 {
+		t.Errorf("Expected the retired key to stay published, got %d keys", got)
+	}
+
+	reopened, err := OpenJWTKeyring(path, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.KeyID() != second {
+		t.Errorf("Reopened key ID = %s, want %s", reopened.KeyID(), second)
+	}
+	if _, err := reopened.PublicKey(first); err != nil {
+		t.Errorf("Expected the retired key to be kept, got %v", err)
+	}
+
+	// Retired keys are dropped once their tokens have expired
+	short, err := OpenJWTKeyring("", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retired := short.KeyID()
+	short.Rotate()
+	if _, err := short.PublicKey(retired); !errors.Is(err, ErrUnknownJWTKey) {
+		t.Errorf("Expected ErrUnknownJWTKey for an expired key, got %v", err)
+	}
+	if short.due(time.Now().Add(1000 * time.Hour)) {
+		t.Error("Expected no rotation with a zero period")
+	}
+	if !keys.due(time.Now().Add(time.Hour)) {
+		t.Error("Expected rotation after the period")
+	}
+}
+
+func TestJWTAutoRotation(t *testing.T) {
+	config := jwtConfig(t)
+	config.JWTKeyRotation = time.Hour
+	g, err := NewGuardianWithStore(config, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	sink := &memoryAuditSink{}
+	audit, _ := NewAuditLog(sink)
+	g.SetAuditLog(audit)
+	g.CreateUser("gaheris", "brother-of-g", RoleSquire)
+
+	first := g.JWTKeys().KeyID()
+	g.jwtKeys.keys[0].CreatedAt = time.Now().Add(-2 * time.Hour)
+	if _, err := g.Authenticate("gaheris", "brother-of-g", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if g.JWTKeys().KeyID() == first {
+		t.Error("Expected the signing key to be rotated when due")
+	}
+	if !slices.Contains(sink.types(), AuditJWTKeyRotated) {
+		t.Errorf("Expected a %s event, got %v", AuditJWTKeyRotated, sink.types())
+	}
+}
+
+func TestLoginHandlerJWT(t *testing.T) {
+	g, err := NewGuardianWithStore(jwtConfig(t), NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.CreateUser("lamorak", "jousting-ace", RoleKnight)
+
+	rec := httptest.NewRecorder()
+	g.LoginHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login",
+		strings.NewReader(`{"username":"lamorak","password":"jousting-ace"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", rec.Code, rec.Body)
+	}
+	var resp sessionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !isJWT(resp.Token) || resp.RefreshToken == "" {
+		t.Errorf("Expected a JWT and a refresh token, got %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	g.JWKSHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/jwks", nil))
+	var set JWKS
+	if err := json.NewDecoder(rec.Body).Decode(&set); err != nil || len(set.Keys) != 1 || set.Keys[0].Kid != g.JWTKeys().KeyID() {
+		t.Errorf("Unexpected JWKS %+v (%v)", set, err)
+	}
+
+	opaque := NewGuardian(testConfig())
+	defer opaque.Close()
+	rec = httptest.NewRecorder()
+	opaque.JWKSHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/jwks", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without JWT sessions, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,101 @@
+package guardian
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrAccountLocked indicates an account locked after repeated failed
+	// logins
+	ErrAccountLocked = errors.New("account temporarily locked")
+	// ErrChallengeRequired indicates a login that must include a challenge
+	// response after repeated failures
+	ErrChallengeRequired = errors.New("challenge required")
+	// ErrChallengeFailed indicates a challenge response that did not verify
+	ErrChallengeFailed = errors.New("challenge failed")
+)
+
+// ChallengeFunc verifies the response to a secondary challenge, such as a
+// CAPTCHA token, presented by a login for username from ipAddress
+type ChallengeFunc func(username, ipAddress, response string) error
+
+// lockoutDuration returns how long an account is locked after failures
+// consecutive failed logins, zero below the threshold
+func (c *Config) lockoutDuration(failures int) time.Duration {
+	if c.LockoutThreshold <= 0 || failures < c.LockoutThreshold {
+		return 0
+	}
+	d := c.LockoutDuration
+	for i := c.LockoutThreshold; i < failures && d < c.LockoutMaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, max(c.LockoutMaxDuration, c.LockoutDuration))
+}
+
+// challengeRequired reports whether a login as username must pass the
+// challenge
+func (g *Guardian) challengeRequired(username string) bool {
+	if g.config.Challenge == nil || g.config.ChallengeThreshold <= 0 {
+		return false
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	user, exists := g.users[username]
+	return exists && user.FailedLogins >= g.config.ChallengeThreshold
+}
+
+// recordFailedLogin counts a failed login, locking the account once the
+// lockout threshold is reached; callers must hold g.mu. A failed write is
+// ignored so that it cannot turn a wrong password into a server error.
+func (g *Guardian) recordFailedLogin(user *User, ipAddress string, now time.Time) {
+	updated := *user
+	updated.FailedLogins++
+	updated.LastFailedLoginAt = now
+	if d := g.config.lockoutDuration(updated.FailedLogins); d > 0 {
+		updated.LockedUntil = now.Add(d)
+		g.audit(AuditAccountLocked, user.Username, ipAddress, map[string]string{
+			"failures": strconv.Itoa(updated.FailedLogins),
+			"until":    updated.LockedUntil.UTC().Format(time.RFC3339),
+		})
+	}
+	g.store.PutUser(&updated)
+	*user = updated
+}
+
+// LockedUntil returns when a user's lockout ends, the zero time if the user
+// is not locked
+func (g *Guardian) LockedUntil(username string) time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	user, exists := g.users[username]
+	if !exists || !time.Now().Before(user.LockedUntil) {
+		return time.Time{}
+	}
+	return user.LockedUntil
+}
+
+// UnlockUser lifts a lockout and clears the user's failed login count
+func (g *Guardian) UnlockUser(username string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user, exists := g.users[username]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	if user.FailedLogins == 0 && user.LockedUntil.IsZero() {
+		return nil
+	}
+
+	updated := *user
+	updated.FailedLogins, updated.LastFailedLoginAt, updated.LockedUntil = 0, time.Time{}, time.Time{}
+	if err := g.store.PutUser(&updated); err != nil {
+		return fmt.Errorf("failed to persist user: %w", err)
+	}
+	g.audit(AuditAccountUnlocked, username, "", map[string]string{"failures": strconv.Itoa(user.FailedLogins)})
+	*user = updated
+	return nil
+}
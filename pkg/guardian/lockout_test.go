@@ -0,0 +1,199 @@
+package guardian
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	config := &Config{LockoutThreshold: 3, LockoutDuration: time.Minute, LockoutMaxDuration: 10 * time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := config.lockoutDuration(tt.failures); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	config.LockoutMaxDuration = 0
+	if got := config.lockoutDuration(1000); got != time.Minute {
+		t.Errorf("Expected a fixed lockout without a maximum, got %v", got)
+	}
+	config.LockoutThreshold = 0
+	if got := config.lockoutDuration(1000); got != 0 {
+		t.Errorf("Expected no lockout with a zero threshold, got %v", got)
+	}
+}
+
+func TestAccountLockout(t *testing.T) {
+	config := testConfig()
+	config.LockoutThreshold = 3
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "guardian.db"), testStoreKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGuardianWithStore(config, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	sink := &memoryAuditSink{}
+	audit, _ := NewAuditLog(sink)
+	g.SetAuditLog(audit)
+	g.CreateUser("gareth", "kitchen-knave", RoleSquire)
+
+	for i := 0; i < config.LockoutThreshold; i++ {
+		if _, err := g.Authenticate("gareth", "wrong", "10.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+	if _, err := g.Authenticate("gareth", "kitchen-knave", "10.0.0.2"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Expected the correct password to be refused while locked, got %v", err)
+	}
+	until := g.LockedUntil("gareth")
+	if d := time.Until(until); d <= 0 || d > config.LockoutDuration {
+		t.Errorf("Expected a lockout of up to %v, got %v", config.LockoutDuration, d)
+	}
+
+	// The lockout survives a restart
+	stored, err := store.GetUser("gareth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.FailedLogins != 3 || !stored.LockedUntil.Equal(until) {
+		t.Errorf("Expected the lockout to be persisted, got %d failures until %v", stored.FailedLogins, stored.LockedUntil)
+	}
+
+	if err := g.UnlockUser("gareth"); err != nil {
+		t.Fatal(err)
+	}
+	if !g.LockedUntil("gareth").IsZero() {
+		t.Error("Expected the user to be unlocked")
+	}
+	if _, err := g.Authenticate("gareth", "kitchen-knave", "10.0.0.2"); err != nil {
+		t.Fatalf("Expected login after unlock, got %v", err)
+	}
+
+	want := []string{
+		AuditUserCreated, AuditLoginFailure, AuditLoginFailure, AuditLoginFailure, AuditAccountLocked,
+		AuditLoginFailure, AuditAccountUnlocked, AuditLoginSuccess,
+	}
+	if got := sink.types(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Audit events = %v, want %v", got, want)
+	}
+}
+
+func TestSuccessfulLoginResetsFailures(t *testing.T) {
+	config := testConfig()
+	config.LockoutThreshold = 3
+	g := NewGuardian(config)
+	g.CreateUser("gawain", "green-knight", RoleKnight)
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < config.LockoutThreshold-1; i++ {
+			g.Authenticate("gawain", "wrong", "10.0.0.1")
+		}
+		if _, err := g.Authenticate("gawain", "green-knight", "10.0.0.1"); err != nil {
+			t.Fatalf("Round %d: expected login below the threshold, got %v", round, err)
+		}
+	}
+	if user, _ := g.GetUserInfo("gawain"); user.FailedLogins != 0 {
+		t.Errorf("Expected failures to be reset, got %d", user.FailedLogins)
+	}
+}
+
+func TestLoginChallenge(t *testing.T) {
+	config := testConfig()
+	config.ChallengeThreshold = 2
+	config.Challenge = func(username, ipAddress, response string) error {
+		if response != "solved" {
+			return errors.New("wrong answer")
+		}
+		return nil
+	}
+	g := NewGuardian(config)
+	g.CreateUser("dagonet", "jester-fool", RoleSquire)
+
+	login := func(challenge string) error {
+		_, err := g.Login(Credentials{Username: "dagonet", Password: "jester-fool", Challenge: challenge}, "10.0.0.1")
+		return err
+	}
+	g.Authenticate("dagonet", "wrong", "10.0.0.1")
+	if err := login(""); err != nil {
+		t.Fatalf("Expected no challenge below the threshold, got %v", err)
+	}
+
+	g.Authenticate("dagonet", "wrong", "10.0.0.1")
+	g.Authenticate("dagonet", "wrong", "10.0.0.1")
+	if err := login(""); !errors.Is(err, ErrChallengeRequired) {
+		t.Errorf("Expected ErrChallengeRequired, got %v", err)
+	}
+	if err := login("guessed"); !errors.Is(err, ErrChallengeFailed) {
+		t.Errorf("Expected ErrChallengeFailed, got %v", err)
+	}
+	if err := login("solved"); err != nil {
+		t.Errorf("Expected login with the challenge solved, got %v", err)
+	}
+}
+
+func TestLockoutRateLimitOrder(t *testing.T) {
+	config := testConfig()
+	config.LockoutThreshold = 2
+	config.RateLimitRequests = 3
+	config.RateLimitWindow = time.Hour
+	g := NewGuardian(config)
+	g.CreateUser("kay", "seneschal33", RoleKnight)
+
+	for i := 0; i < config.LockoutThreshold; i++ {
+		if _, err := g.Authenticate("kay", "wrong", "10.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+	// Locked with requests to spare, the lockout is reported
+	if _, err := g.Authenticate("kay", "wrong", "10.0.0.1"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Expected ErrAccountLocked within the rate limit, got %v", err)
+	}
+	// Over the rate limit, the limit is reported first and the account
+	// is not consulted
+	if _, err := g.Authenticate("kay", "wrong", "10.0.0.1"); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("Expected ErrRateLimitExceeded over the limit of a locked account, got %v", err)
+	}
+	if _, err := g.Authenticate("kay", "seneschal33", "10.0.0.2"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Expected ErrAccountLocked from another address, got %v", err)
+	}
+}
+
+func TestLoginHandlerLockout(t *testing.T) {
+	config := testConfig()
+	config.LockoutThreshold = 1
+	g := NewGuardian(config)
+	g.CreateUser("bors", "grail-seeker", RoleKnight)
+	g.Authenticate("bors", "wrong", "10.0.0.1")
+
+	rec := httptest.NewRecorder()
+	g.LoginHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login",
+		strings.NewReader(`{"username":"bors","password":"grail-seeker"}`)))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 for a locked account, got %d (%s)", rec.Code, rec.Body)
+	}
+	retry, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retry < 1 || retry > int(config.LockoutDuration.Seconds())+1 {
+		t.Errorf("Unexpected Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+}
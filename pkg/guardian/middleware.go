@@ -0,0 +1,285 @@
+package guardian
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/metrics"
+)
+
+// sessionKey is the request context key for the authenticated session
+type sessionKey struct{}
+
+// ContextWithSession returns ctx carrying session for SessionFromContext
+func ContextWithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the session authenticated by Middleware
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+	return session, ok
+}
+
+// Middleware protects next with the Guardian: requests are rate limited per
+// client IP, checked against the IP policy, and must carry an
+// "Authorization: Bearer <token>" header for a session with requiredRole
+// or a role above it, used from a network its role allows. An API key may
+// stand in for the token, as the bearer or in an X-API-Key header. The
+// session is available to next via SessionFromContext.
+func (g *Guardian) Middleware(next http.Handler, requiredRole Role) http.Handler {
+	return g.middleware(next, func(token, ip string) (*Session, error) {
+		return g.Authorize(token, ip, requiredRole)
+	})
+}
+
+// middleware serves next to requests that authorize accepts
+func (g *Guardian) middleware(next http.Handler, authorize func(token, ip string) (*Session, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		session, err := authorize(token, ClientIP(r))
+		switch {
+		case errors.Is(err, ErrRateLimitExceeded):
+			writeError(w, http.StatusTooManyRequests, err)
+			return
+		case errors.Is(err, ErrUnauthorized):
+			writeError(w, http.StatusForbidden, err)
+			return
+		case err != nil:
+			challenge := `Bearer realm="guardian"`
+			if token != "" {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithSession(r.Context(), session)))
+	})
+}
+
+// Authorize applies the checks of Middleware to a request from ip carrying
+// token, for servers other than HTTP. It returns ErrRateLimitExceeded,
+// ErrInvalidToken for a missing or invalid token, or ErrUnauthorized for a
+// denied IP or role.
+func (g *Guardian) Authorize(token, ip string, requiredRole Role) (*Session, error) {
+	return g.authorize(token, ip, func(s *Session) bool { return s.Role.AtLeast(requiredRole) })
+}
+
+// authorize checks a request from ip carrying token, a session token or an
+// API key, for a session allowed accepts
+func (g *Guardian) authorize(token, ip string, allowed func(*Session) bool) (*Session, error) {
+	if strings.HasPrefix(token, APIKeyPrefix) {
+		return g.authorizeAPIKey(token, ip, allowed)
+	}
+	if !g.rateLimiter.Allow(ip) {
+		authFailed("rate_limited")
+		return nil, ErrRateLimitExceeded
+	}
+	if !g.ipAllowed(ip, "") {
+		authFailed("ip_denied")
+		return nil, ErrUnauthorized
+	}
+	if token == "" {
+		authFailed("missing_token")
+		return nil, ErrInvalidToken
+	}
+	session, err := g.ValidateSession(token)
+	if err != nil {
+		authFailed("invalid_token")
+		return nil, err
+	}
+	if !g.ipAllowed(ip, session.Role) {
+		authFailed("ip_denied")
+		return nil, ErrUnauthorized
+	}
+	if !allowed(session) {
+		authFailed("forbidden")
+		return nil, ErrUnauthorized
+	}
+	return session, nil
+}
+
+// LoginHandler exchanges a JSON {"username", "password"} body for a session
+// token, so servers using Middleware can issue their own tokens. Users with
+// two-factor authentication must also send "totp_code", and accounts past
+// the challenge threshold "challenge". Locked accounts get 429 with a
+// Retry-After header.
+func (g *Guardian) LoginHandler() http.Handler {
+	type loginRequest struct {
+		Username  string `json:"username"`
+		Password  string `json:"password"`
+		TOTPCode  string `json:"totp_code,omitempty"`
+		Challenge string `json:"challenge,omitempty"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		var req loginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid request format"))
+			return
+		}
+
+		session, err := g.LoginSession(Credentials{
+			Username:  req.Username,
+			Password:  req.Password,
+			TOTPCode:  req.TOTPCode,
+			Challenge: req.Challenge,
+		}, ClientIP(r))
+		switch {
+		case errors.Is(err, ErrRateLimitExceeded):
+			authFailed("rate_limited")
+			writeError(w, http.StatusTooManyRequests, err)
+			return
+		case errors.Is(err, ErrAccountLocked):
+			authFailed("account_locked")
+			if until := g.LockedUntil(req.Username); !until.IsZero() {
+				w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+			}
+			writeError(w, http.StatusTooManyRequests, err)
+			return
+		case errors.Is(err, ErrChallengeRequired), errors.Is(err, ErrChallengeFailed):
+			authFailed("challenge")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		case errors.Is(err, ErrInvalidCredentials):
+			authFailed("invalid_credentials")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		case errors.Is(err, ErrTOTPRequired), errors.Is(err, ErrInvalidTOTP):
+			authFailed("invalid_totp")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		case errors.Is(err, ErrUnauthorized):
+			authFailed("forbidden")
+			writeError(w, http.StatusForbidden, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, errors.New("authentication failed"))
+			return
+		}
+
+		g.writeSession(w, session)
+	})
+}
+
+// RefreshHandler exchanges a JSON {"refresh_token"} body for a new session,
+// answering like LoginHandler. The old token and refresh token stop working.
+func (g *Guardian) RefreshHandler() http.Handler {
+	type refreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		var req refreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+			writeError(w, http.StatusBadRequest, errors.New("invalid request format"))
+			return
+		}
+
+		session, err := g.RefreshSession(req.RefreshToken, ClientIP(r))
+		switch {
+		case errors.Is(err, ErrRateLimitExceeded):
+			authFailed("rate_limited")
+			writeError(w, http.StatusTooManyRequests, err)
+			return
+		case errors.Is(err, ErrInvalidToken):
+			authFailed("invalid_refresh_token")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		case errors.Is(err, ErrUnauthorized):
+			authFailed("forbidden")
+			writeError(w, http.StatusForbidden, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, errors.New("refresh failed"))
+			return
+		}
+		g.writeSession(w, session)
+	})
+}
+
+// sessionResponse is the body LoginHandler and RefreshHandler answer with
+type sessionResponse struct {
+	Token            string     `json:"token"`
+	Role             Role       `json:"role"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RefreshToken     string     `json:"refresh_token,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
+}
+
+func (g *Guardian) writeSession(w http.ResponseWriter, session *Session) {
+	resp := sessionResponse{
+		Token:        session.Token,
+		Role:         session.Role,
+		ExpiresAt:    session.ExpiresAt,
+		RefreshToken: session.RefreshToken,
+	}
+	if limit := g.RefreshExpiresAt(session); !limit.IsZero() {
+		resp.RefreshExpiresAt = &limit
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ClientIP returns the IP address of the client that sent r. Forwarding
+// headers are ignored since they can be forged by the client.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ipAllowed reports whether ip passes the IP policy for role, or for every
+// role when role is empty
+func (g *Guardian) ipAllowed(ip string, role Role) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.ipAllowedLocked(ip, role)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// requestToken returns the bearer token of r, or else its API key header
+func requestToken(r *http.Request) string {
+	if token, ok := bearerToken(r); ok {
+		return token
+	}
+	return strings.TrimSpace(r.Header.Get(APIKeyHeader))
+}
+
+// authFailed counts a rejected authentication in the exs_guardian metrics
+func authFailed(reason string) {
+	metrics.AuthFailures.WithLabelValues(reason).Inc()
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
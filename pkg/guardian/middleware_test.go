@@ -0,0 +1,217 @@
+package guardian
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/metrics"
+)
+
+// protectedHandler reports the authenticated username
+var protectedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	session, ok := SessionFromContext(r.Context())
+	if !ok {
+		http.Error(w, "no session", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(session.Username))
+})
+
+func serve(h http.Handler, token, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/forge", nil)
+	req.RemoteAddr = remoteAddr
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	g := NewGuardian(nil)
+	g.CreateUser("arthur", "excalibur123", RoleKingArthur)
+	g.CreateUser("lancelot", "camelot456", RoleKnight)
+	g.CreateUser("pip", "squire789", RoleSquire)
+
+	arthur, _ := g.Authenticate("arthur", "excalibur123", "10.0.0.1")
+	lancelot, _ := g.Authenticate("lancelot", "camelot456", "10.0.0.1")
+	pip, _ := g.Authenticate("pip", "squire789", "10.0.0.1")
+
+	h := g.Middleware(protectedHandler, RoleKnight)
+	forbidden := testutil.ToFloat64(metrics.AuthFailures.WithLabelValues("forbidden"))
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"invalid token", "deadbeef", http.StatusUnauthorized},
+		{"wrong role", pip, http.StatusForbidden},
+		{"required role", lancelot, http.StatusOK},
+		{"king arthur", arthur, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := serve(h, tt.token, "10.0.0.2:4000")
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.status, rec.Code, rec.Body)
+		}
+	}
+
+	if got := testutil.ToFloat64(metrics.AuthFailures.WithLabelValues("forbidden")) - forbidden; got != 1 {
+		t.Errorf("Expected 1 forbidden auth failure counted, got %v", got)
+	}
+
+	if rec := serve(h, lancelot, "10.0.0.2:4000"); rec.Body.String() != "lancelot" {
+		t.Errorf("Expected session in context, got %q", rec.Body)
+	}
+	if rec := serve(h, "", "10.0.0.2:4000"); rec.Header().Get("WWW-Authenticate") == "" {
+		t.Error("Expected WWW-Authenticate challenge")
+	}
+
+	g.RevokeSession(lancelot)
+	if rec := serve(h, lancelot, "10.0.0.2:4000"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked token to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.RateLimitRequests = 3
+	g := NewGuardian(config)
+	h := g.Middleware(protectedHandler, RoleKnight)
+
+	for i := 0; i < 3; i++ {
+		if rec := serve(h, "", "10.0.0.3:1234"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Request %d: expected 401, got %d", i, rec.Code)
+		}
+	}
+	if rec := serve(h, "", "10.0.0.3:5678"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 for the same IP on another port, got %d", rec.Code)
+	}
+	if rec := serve(h, "", "10.0.0.4:1234"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected other IPs unaffected, got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareWhitelist(t *testing.T) {
+	config := DefaultConfig()
+	config.RequireIPWhitelist = true
+	g := NewGuardian(config)
+	g.AddToWhitelist("10.0.0.5")
+	g.CreateUser("gawain", "greenknight1", RoleKnight)
+	token, err := g.Authenticate("gawain", "greenknight1", "10.0.0.5")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	h := g.Middleware(protectedHandler, RoleKnight)
+	if rec := serve(h, token, "10.0.0.5:80"); rec.Code != http.StatusOK {
+		t.Errorf("Expected whitelisted IP to pass, got %d", rec.Code)
+	}
+	if rec := serve(h, token, "10.0.0.6:80"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected other IP to be forbidden, got %d", rec.Code)
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	g := NewGuardian(nil)
+	g.CreateUser("percival", "grail2024", RoleKnight)
+	login := g.LoginHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login",
+		strings.NewReader(`{"username":"percival","password":"grail2024"}`))
+	rec := httptest.NewRecorder()
+	login.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Token string `json:"token"`
+		Role  Role   `json:"role"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if resp.Role != RoleKnight {
+		t.Errorf("Expected knight role, got %s", resp.Role)
+	}
+	if rec := serve(g.Middleware(protectedHandler, RoleKnight), resp.Token, "10.0.0.7:80"); rec.Code != http.StatusOK {
+		t.Errorf("Expected issued token to be accepted, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/auth/login",
+		strings.NewReader(`{"username":"percival","password":"wrong"}`))
+	rec = httptest.NewRecorder()
+	login.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for bad password, got %d", rec.Code)
+	}
+}
+
+func TestLoginHandlerTOTP(t *testing.T) {
+	g := NewGuardian(testConfig())
+	g.CreateUser("kay", "seneschal1", RoleKnight)
+	_, secret := enrollTOTP(t, g, "kay")
+	login := g.LoginHandler()
+
+	post := func(body string) int {
+		rec := httptest.NewRecorder()
+		login.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body)))
+		return rec.Code
+	}
+	if code := post(`{"username":"kay","password":"seneschal1"}`); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a code, got %d", code)
+	}
+	if code := post(`{"username":"kay","password":"seneschal1","totp_code":"` + TOTPCode(secret, time.Now()) + `"}`); code != http.StatusOK {
+		t.Errorf("Expected 200 with a code, got %d", code)
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	g := NewGuardian(testConfig())
+	g.CreateUser("dagonet", "jester888", RoleKnight)
+
+	type sessionBody struct {
+		Token            string     `json:"token"`
+		RefreshToken     string     `json:"refresh_token"`
+		RefreshExpiresAt *time.Time `json:"refresh_expires_at"`
+	}
+	post := func(h http.Handler, body string) (int, sessionBody) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth", strings.NewReader(body)))
+		var resp sessionBody
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+
+	code, login := post(g.LoginHandler(), `{"username":"dagonet","password":"jester888"}`)
+	if code != http.StatusOK || login.RefreshToken == "" || login.RefreshExpiresAt == nil {
+		t.Fatalf("Expected login with a refresh token, got %d %+v", code, login)
+	}
+
+	refresh := g.RefreshHandler()
+	code, refreshed := post(refresh, `{"refresh_token":"`+login.RefreshToken+`"}`)
+	if code != http.StatusOK || refreshed.Token == "" || refreshed.Token == login.Token {
+		t.Fatalf("Expected a new token, got %d %+v", code, refreshed)
+	}
+	if rec := serve(g.Middleware(protectedHandler, RoleKnight), refreshed.Token, "10.0.0.7:80"); rec.Code != http.StatusOK {
+		t.Errorf("Expected refreshed token to be accepted, got %d", rec.Code)
+	}
+	if rec := serve(g.Middleware(protectedHandler, RoleKnight), login.Token, "10.0.0.7:80"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected old token to be rejected, got %d", rec.Code)
+	}
+
+	if code, _ := post(refresh, `{"refresh_token":"`+login.RefreshToken+`"}`); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 reusing a refresh token, got %d", code)
+	}
+	if code, _ := post(refresh, `{}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a refresh token, got %d", code)
+	}
+}
@@ -0,0 +1,102 @@
+package guardian
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Permission names an action a role may be granted
+type Permission string
+
+const (
+	// PermissionTreasuryRead - View balances, distributions and payments
+	PermissionTreasuryRead Permission = "treasury.read"
+	// PermissionForgeSubmit - Submit forges and cross-chain rewards
+	PermissionForgeSubmit Permission = "forge.submit"
+	// PermissionTreasuryDistribute - Propose, approve and settle distributions
+	PermissionTreasuryDistribute Permission = "treasury.distribute"
+	// PermissionNodeAdmin - Administer nodes and the Guardian itself
+	PermissionNodeAdmin Permission = "node.admin"
+)
+
+// Roles lists the roles from least to most privileged. Each role holds the
+// access of the roles before it.
+var Roles = []Role{RoleSquire, RoleKnight, RoleKingArthur}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// AtLeast reports whether r holds the access of required, as r is required
+// or above it in Roles
+func (r Role) AtLeast(required Role) bool {
+	return r.Valid() && r.rank() >= required.rank()
+}
+
+// DefaultPermissions returns the permissions each role is granted on top of
+// those it inherits from the roles below it
+func DefaultPermissions() map[Role][]Permission {
+	return map[Role][]Permission{
+		RoleSquire:     {PermissionTreasuryRead},
+		RoleKnight:     {PermissionForgeSubmit},
+		RoleKingArthur: {PermissionTreasuryDistribute, PermissionNodeAdmin},
+	}
+}
+
+// Permissions returns the permissions role holds, its own and those of the
+// roles below it, sorted
+func (g *Guardian) Permissions(role Role) []Permission {
+	grants := g.config.Permissions
+	if grants == nil {
+		grants = DefaultPermissions()
+	}
+	var permissions []Permission
+	for _, r := range Roles {
+		if role.AtLeast(r) {
+			permissions = append(permissions, grants[r]...)
+		}
+	}
+	slices.Sort(permissions)
+	return slices.Compact(permissions)
+}
+
+// HasPermission reports whether role holds permission
+func (g *Guardian) HasPermission(role Role, permission Permission) bool {
+	return slices.Contains(g.Permissions(role), permission)
+}
+
+// sessionHolds reports whether session holds permission, through its role
+// and within its scopes
+func (g *Guardian) sessionHolds(session *Session, permission Permission) bool {
+	if len(session.Scopes) > 0 && !slices.Contains(session.Scopes, permission) {
+		return false
+	}
+	return g.HasPermission(session.Role, permission)
+}
+
+// RequirePermission checks if a session's role holds permission
+func (g *Guardian) RequirePermission(token string, permission Permission) error {
+	session, err := g.ValidateSession(token)
+	if err != nil {
+		return err
+	}
+	if !g.HasPermission(session.Role, permission) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// AuthorizePermission is Authorize for a session whose role holds
+// permission
+func (g *Guardian) AuthorizePermission(token, ip string, permission Permission) (*Session, error) {
+	return g.authorize(token, ip, func(s *Session) bool { return g.sessionHolds(s, permission) })
+}
+
+// PermissionMiddleware is Middleware for sessions whose role holds
+// permission
+func (g *Guardian) PermissionMiddleware(next http.Handler, permission Permission) http.Handler {
+	return g.middleware(next, func(token, ip string) (*Session, error) {
+		return g.AuthorizePermission(token, ip, permission)
+	})
+}
@@ -0,0 +1,67 @@
+package guardian
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestRoleHierarchy(t *testing.T) {
+	g := NewGuardian(nil)
+	g.CreateUser("lancelot", "camelot456", RoleKnight)
+	lancelot, _ := g.Authenticate("lancelot", "camelot456", "10.0.0.1")
+
+	if err := g.RequireRole(lancelot, RoleSquire); err != nil {
+		t.Errorf("Knight should have Squire access: %v", err)
+	}
+	if RoleSquire.AtLeast(RoleKnight) || Role("wizard").AtLeast("") {
+		t.Error("Expected only known roles at or above the required one")
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	g := NewGuardian(nil)
+	g.CreateUser("arthur", "excalibur123", RoleKingArthur)
+	g.CreateUser("lancelot", "camelot456", RoleKnight)
+	g.CreateUser("pip", "squire789", RoleSquire)
+	arthur, _ := g.Authenticate("arthur", "excalibur123", "10.0.0.1")
+	lancelot, _ := g.Authenticate("lancelot", "camelot456", "10.0.0.1")
+	pip, _ := g.Authenticate("pip", "squire789", "10.0.0.1")
+
+	want := []Permission{PermissionForgeSubmit, PermissionTreasuryRead}
+	if got := g.Permissions(RoleKnight); !slices.Equal(got, want) {
+		t.Errorf("Knight permissions = %v, want %v", got, want)
+	}
+	if err := g.RequirePermission(lancelot, PermissionForgeSubmit); err != nil {
+		t.Errorf("Knight should submit forges: %v", err)
+	}
+	if err := g.RequirePermission(lancelot, PermissionTreasuryDistribute); err != ErrUnauthorized {
+		t.Errorf("Knight should not distribute, got: %v", err)
+	}
+	if err := g.RequirePermission(arthur, PermissionForgeSubmit); err != nil {
+		t.Errorf("King Arthur should inherit forge.submit: %v", err)
+	}
+
+	h := g.PermissionMiddleware(protectedHandler, PermissionForgeSubmit)
+	if rec := serve(h, pip, "10.0.0.2:4000"); rec.Code != http.StatusForbidden {
+		t.Errorf("Squire got %d, want 403", rec.Code)
+	}
+	if rec := serve(h, lancelot, "10.0.0.2:4000"); rec.Code != http.StatusOK || rec.Body.String() != "lancelot" {
+		t.Errorf("Knight got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestConfiguredPermissions(t *testing.T) {
+	config := DefaultConfig()
+	config.Permissions = map[Role][]Permission{
+		RoleSquire: {PermissionTreasuryRead, PermissionForgeSubmit},
+	}
+	g := NewGuardian(config)
+
+	if !g.HasPermission(RoleSquire, PermissionForgeSubmit) {
+		t.Error("Expected the Squire granted forge.submit")
+	}
+	if g.HasPermission(RoleKingArthur, PermissionNodeAdmin) {
+		t.Error("Expected only the configured permissions")
+	}
+}
@@ -0,0 +1,290 @@
+package guardian
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/metrics"
+)
+
+// ErrInvalidRateLimitPolicy indicates a rate limit policy that cannot be
+// applied
+var ErrInvalidRateLimitPolicy = errors.New("invalid rate limit policy")
+
+// Limit is a token bucket refilled with Requests tokens every Per (a minute
+// when zero) and holding at most Burst, Requests when zero. A zero Requests
+// leaves requests unlimited.
+type Limit struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst"`
+}
+
+// EndpointLimit applies its Limit to requests for Path, or below it when
+// Path ends in "/", made with Method, any when empty
+type EndpointLimit struct {
+	Method string `yaml:"method"`
+	Path   string `yaml:"path"`
+	Limit  `yaml:",inline"`
+}
+
+// name identifies the endpoint in metrics and bucket keys
+func (e EndpointLimit) name() string {
+	if e.Method == "" {
+		return e.Path
+	}
+	return e.Method + " " + e.Path
+}
+
+// matches reports whether a request for path with method falls under e
+func (e EndpointLimit) matches(method, path string) bool {
+	if e.Method != "" && !strings.EqualFold(e.Method, method) {
+		return false
+	}
+	if strings.HasSuffix(e.Path, "/") {
+		return strings.HasPrefix(path, e.Path)
+	}
+	return path == e.Path
+}
+
+// RateLimitPolicy sets request limits per caller: Default for endpoints no
+// rule in Endpoints matches, the first match otherwise. RoleMultipliers
+// scales both rate and burst for callers authenticated with a role;
+// anonymous callers and unlisted roles get a multiplier of 1.
+type RateLimitPolicy struct {
+	Default         Limit            `yaml:"default"`
+	Endpoints       []EndpointLimit  `yaml:"endpoints"`
+	RoleMultipliers map[Role]float64 `yaml:"roles"`
+}
+
+// DefaultRateLimitPolicy allows 600 requests a minute per caller in bursts
+// of 100, forges 20 a minute in bursts of 5 and leaves health checks and
+// metrics unlimited. Knights get twice the limits and King Arthur five
+// times.
+func DefaultRateLimitPolicy() *RateLimitPolicy {
+	return &RateLimitPolicy{
+		Default: Limit{Requests: 600, Per: time.Minute, Burst: 100},
+		Endpoints: []EndpointLimit{
+			{Method: http.MethodPost, Path: "/forge", Limit: Limit{Requests: 20, Per: time.Minute, Burst: 5}},
+			{Path: "/healthz"},
+			{Path: "/readyz"},
+			{Path: "/metrics"},
+		},
+		RoleMultipliers: map[Role]float64{RoleKnight: 2, RoleKingArthur: 5},
+	}
+}
+
+// ParseRateLimitPolicy parses a YAML rate limit policy:
+//
+//	default: {requests: 600, per: 1m, burst: 100}
+//	endpoints:
+//	  - {method: POST, path: /forge, requests: 20, per: 1m, burst: 5}
+//	  - {path: /balance, requests: 1200, per: 1m}
+//	  - {path: /payments/, requests: 60, per: 1m}
+//	roles:
+//	  knight: 2
+//	  king_arthur: 5
+func ParseRateLimitPolicy(data []byte) (*RateLimitPolicy, error) {
+	var policy RateLimitPolicy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRateLimitPolicy, err)
+	}
+	if err := policy.Default.validate("default"); err != nil {
+		return nil, err
+	}
+	for _, e := range policy.Endpoints {
+		if !strings.HasPrefix(e.Path, "/") {
+			return nil, fmt.Errorf("%w: endpoint path %q must start with /", ErrInvalidRateLimitPolicy, e.Path)
+		}
+		if err := e.Limit.validate(e.name()); err != nil {
+			return nil, err
+		}
+	}
+	for role, m := range policy.RoleMultipliers {
+		if !role.Valid() {
+			return nil, fmt.Errorf("%w: unknown role %s", ErrInvalidRateLimitPolicy, role)
+		}
+		if m <= 0 {
+			return nil, fmt.Errorf("%w: multiplier %v for %s must be positive", ErrInvalidRateLimitPolicy, m, role)
+		}
+	}
+	return &policy, nil
+}
+
+func (l Limit) validate(name string) error {
+	if l.Requests < 0 || l.Per < 0 || l.Burst < 0 {
+		return fmt.Errorf("%w: negative limit for %s", ErrInvalidRateLimitPolicy, name)
+	}
+	return nil
+}
+
+// LoadRateLimitPolicy reads a YAML rate limit policy file
+func LoadRateLimitPolicy(path string) (*RateLimitPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rate limit policy: %w", err)
+	}
+	policy, err := ParseRateLimitPolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// RateLimitPolicyFromEnv returns the policy in the RATE_LIMIT_POLICY file,
+// DefaultRateLimitPolicy when unset, or nil when it is "off"
+func RateLimitPolicyFromEnv() (*RateLimitPolicy, error) {
+	switch path := os.Getenv("RATE_LIMIT_POLICY"); path {
+	case "":
+		return DefaultRateLimitPolicy(), nil
+	case "off":
+		return nil, nil
+	default:
+		return LoadRateLimitPolicy(path)
+	}
+}
+
+// match returns the rule name and limit applying to a request
+func (p *RateLimitPolicy) match(method, path string) (string, Limit) {
+	for _, e := range p.Endpoints {
+		if e.matches(method, path) {
+			return e.name(), e.Limit
+		}
+	}
+	return "default", p.Default
+}
+
+// multiplier returns the scale of role's limits
+func (p *RateLimitPolicy) multiplier(role Role) float64 {
+	if m, ok := p.RoleMultipliers[role]; ok {
+		return m
+	}
+	return 1
+}
+
+// CallerFunc identifies the caller of a request, whose requests share a
+// bucket, and the role it is authenticated with, if any
+type CallerFunc func(r *http.Request) (caller string, role Role)
+
+// ClientCaller identifies callers by client IP alone
+func ClientCaller(r *http.Request) (string, Role) {
+	return "ip:" + ClientIP(r), ""
+}
+
+// Caller identifies the caller of r by the user or API key its token
+// authenticates, with their role, or else by client IP. It is the
+// CallerFunc for servers with a Guardian.
+func (g *Guardian) Caller(r *http.Request) (string, Role) {
+	token := requestToken(r)
+	switch {
+	case token == "":
+	case strings.HasPrefix(token, APIKeyPrefix):
+		if key, ok := g.lookupAPIKey(token, time.Now()); ok {
+			return "key:" + key.ID, key.Role
+		}
+	default:
+		if session, err := g.ValidateSession(token); err == nil {
+			return "user:" + session.Username, session.Role
+		}
+	}
+	return ClientCaller(r)
+}
+
+// RateDecision is a PolicyLimiter's answer to a request
+type RateDecision struct {
+	Allowed bool
+	// Rule names the endpoint rule applied, "default" for none
+	Rule string
+	// Limit is the caller's burst and Remaining the requests left in it;
+	// both are zero for unlimited endpoints
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a refused caller must wait
+	RetryAfter time.Duration
+}
+
+// PolicyLimiter applies a RateLimitPolicy to requests, one token bucket per
+// endpoint rule and caller. One limiter is shared by all of a server's
+// routes.
+type PolicyLimiter struct {
+	policy  *RateLimitPolicy
+	caller  CallerFunc
+	buckets TokenBuckets
+}
+
+// NewPolicyLimiter applies policy to callers identified by caller,
+// ClientCaller when nil, keeping its buckets in memory
+func NewPolicyLimiter(policy *RateLimitPolicy, caller CallerFunc) *PolicyLimiter {
+	if caller == nil {
+		caller = ClientCaller
+	}
+	return &PolicyLimiter{policy: policy, caller: caller, buckets: NewMemoryBuckets()}
+}
+
+// SetBuckets moves the limiter's buckets to buckets, such as RedisBuckets
+// shared by replicas
+func (l *PolicyLimiter) SetBuckets(buckets TokenBuckets) {
+	l.buckets = buckets
+}
+
+// Allow takes a token for a request by caller, with role, for path with
+// method at now
+func (l *PolicyLimiter) Allow(method, path, caller string, role Role, now time.Time) RateDecision {
+	rule, limit := l.policy.match(method, path)
+	if limit.Requests == 0 {
+		return RateDecision{Allowed: true, Rule: rule}
+	}
+	per := limit.Per
+	if per == 0 {
+		per = time.Minute
+	}
+	burst := limit.Burst
+	if burst == 0 {
+		burst = limit.Requests
+	}
+	m := l.policy.multiplier(role)
+	capacity := math.Max(1, math.Round(float64(burst)*m))
+	rate := float64(limit.Requests) * m / per.Seconds()
+
+	take := l.buckets.Take(rule+"|"+caller, capacity, rate, now)
+	return RateDecision{
+		Allowed:    take.Allowed,
+		Rule:       rule,
+		Limit:      int(capacity),
+		Remaining:  int(take.Remaining),
+		RetryAfter: take.RetryAfter,
+	}
+}
+
+// Middleware refuses requests over their limit with 429 and a Retry-After
+// header, counted in the exs_guardian metrics. Limited responses carry
+// X-RateLimit-Limit and X-RateLimit-Remaining headers.
+func (l *PolicyLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, role := l.caller(r)
+		d := l.Allow(r.Method, r.URL.Path, caller, role, time.Now())
+		if d.Limit > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		}
+		if !d.Allowed {
+			metrics.RateLimited.WithLabelValues(d.Rule).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, ErrRateLimitExceeded)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package guardian
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPolicyLimiter(t *testing.T) {
+	policy, err := ParseRateLimitPolicy([]byte(`
+default: {requests: 60, per: 1m, burst: 2}
+endpoints:
+  - {method: POST, path: /forge, requests: 6, per: 1m, burst: 1}
+  - {path: /payments/, requests: 0}
+roles:
+  knight: 3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewPolicyLimiter(policy, nil)
+	now := time.Unix(1_700_000_000, 0)
+
+	if d := l.Allow("POST", "/forge", "ip:a", "", now); !d.Allowed || d.Rule != "POST /forge" {
+		t.Errorf("First forge = %+v", d)
+	}
+	d := l.Allow("POST", "/forge", "ip:a", "", now)
+	if d.Allowed || d.RetryAfter != 10*time.Second {
+		t.Errorf("Second forge = %+v, want refused for 10s", d)
+	}
+	if d := l.Allow("POST", "/forge", "ip:a", "", now.Add(10*time.Second)); !d.Allowed {
+		t.Errorf("Forge after the wait = %+v", d)
+	}
+
+	// Endpoints and callers have their own buckets
+	if d := l.Allow("GET", "/balance", "ip:a", "", now); !d.Allowed || d.Rule != "default" || d.Remaining != 1 {
+		t.Errorf("Balance = %+v", d)
+	}
+	if d := l.Allow("POST", "/forge", "ip:b", "", now); !d.Allowed {
+		t.Errorf("Another caller's forge = %+v", d)
+	}
+
+	// A Knight's burst is three times as large
+	for i := range 3 {
+		if d := l.Allow("POST", "/forge", "user:lancelot", RoleKnight, now); !d.Allowed {
+			t.Fatalf("Knight forge %d = %+v", i+1, d)
+		}
+	}
+	if d := l.Allow("POST", "/forge", "user:lancelot", RoleKnight, now); d.Allowed {
+		t.Error("Expected the Knight's fourth forge refused")
+	}
+
+	for range 10 {
+		if d := l.Allow("GET", "/payments/abc", "ip:a", "", now); !d.Allowed || d.Limit != 0 {
+			t.Fatalf("Unlimited endpoint = %+v", d)
+		}
+	}
+}
+
+func TestPolicyLimiterMiddleware(t *testing.T) {
+	g := NewGuardian(nil)
+	g.CreateUser("lancelot", "camelot456", RoleKnight)
+	lancelot, _ := g.Authenticate("lancelot", "camelot456", "10.0.0.1")
+
+	policy := &RateLimitPolicy{
+		Default:         Limit{Requests: 1, Per: time.Hour},
+		RoleMultipliers: map[Role]float64{RoleKnight: 2},
+	}
+	h := NewPolicyLimiter(policy, g.Caller).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/balance", nil)
+		req.RemoteAddr = "10.0.0.2:4000"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(""); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("First request got %d, headers %v", rec.Code, rec.Header())
+	}
+	rec := get("")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "3600" {
+		t.Errorf("Second request got %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// The Knight is limited apart from the address, with twice the burst
+	for i := range 2 {
+		if rec := get(lancelot); rec.Code != http.StatusOK {
+			t.Errorf("Knight request %d got %d", i+1, rec.Code)
+		}
+	}
+	if rec := get(lancelot); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Knight's third request got %d, want 429", rec.Code)
+	}
+}
+
+func TestParseRateLimitPolicyRefuses(t *testing.T) {
+	for _, policy := range []string{
+		`default: {requests: -1}`,
+		`endpoints: [{path: forge, requests: 1}]`,
+		`roles: {wizard: 2}`,
+		`roles: {knight: 0}`,
+		`limit: 5`,
+	} {
+		if _, err := ParseRateLimitPolicy([]byte(policy)); !errors.Is(err, ErrInvalidRateLimitPolicy) {
+			t.Errorf("ParseRateLimitPolicy(%q) = %v", policy, err)
+		}
+	}
+}
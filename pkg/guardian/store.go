@@ -0,0 +1,511 @@
+package guardian
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// ErrNotFound indicates a record does not exist in the store
+var ErrNotFound = errors.New("record not found")
+
+// Store persists Guardian users and sessions
+type Store interface {
+	PutUser(user *User) error
+	GetUser(username string) (*User, error)
+	DeleteUser(username string) error
+	ListUsers() ([]*User, error)
+
+	PutSession(session *Session) error
+	GetSession(token string) (*Session, error)
+	DeleteSession(token string) error
+	ListSessions() ([]*Session, error)
+
+	PutAPIKey(key *APIKey) error
+	DeleteAPIKey(id string) error
+	ListAPIKeys() ([]*APIKey, error)
+
+	Close() error
+}
+
+// MemoryStore is a non-persistent Store, used when no backend is configured
+type MemoryStore struct {
+	mu       sync.RWMutex
+	users    map[string]User
+	sessions map[string]Session
+	apiKeys  map[string]APIKey
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:    make(map[string]User),
+		sessions: make(map[string]Session),
+		apiKeys:  make(map[string]APIKey),
+	}
+}
+
+// PutUser stores a copy of the user
+func (m *MemoryStore) PutUser(user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[user.Username] = *user
+	return nil
+}
+
+// GetUser returns a copy of the user
+func (m *MemoryStore) GetUser(username string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user, ok := m.users[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// DeleteUser removes a user
+func (m *MemoryStore) DeleteUser(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.users, username)
+	return nil
+}
+
+// ListUsers returns copies of all users
+func (m *MemoryStore) ListUsers() ([]*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	users := make([]*User, 0, len(m.users))
+	for _, user := range m.users {
+		u := user
+		users = append(users, &u)
+	}
+	return users, nil
+}
+
+// PutSession stores a copy of the session
+func (m *MemoryStore) PutSession(session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.Token] = *session
+	return nil
+}
+
+// GetSession returns a copy of the session
+func (m *MemoryStore) GetSession(token string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	session, ok := m.sessions[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// DeleteSession removes a session
+func (m *MemoryStore) DeleteSession(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, token)
+	return nil
+}
+
+// ListSessions returns copies of all sessions
+func (m *MemoryStore) ListSessions() ([]*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		s := session
+		sessions = append(sessions, &s)
+	}
+	return sessions, nil
+}
+
+// PutAPIKey stores a copy of the API key
+func (m *MemoryStore) PutAPIKey(key *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiKeys[key.ID] = *key
+	return nil
+}
+
+// DeleteAPIKey removes an API key
+func (m *MemoryStore) DeleteAPIKey(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.apiKeys, id)
+	return nil
+}
+
+// ListAPIKeys returns copies of all API keys
+func (m *MemoryStore) ListAPIKeys() ([]*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]*APIKey, 0, len(m.apiKeys))
+	for _, key := range m.apiKeys {
+		k := key
+		keys = append(keys, &k)
+	}
+	return keys, nil
+}
+
+// Close is a no-op for the memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+const (
+	usersBucket    = "users"
+	sessionsBucket = "sessions"
+	apiKeysBucket  = "api_keys"
+)
+
+// userRecord is the on-disk form of a User. Credentials are sealed.
+type userRecord struct {
+	Username    string    `json:"username"`
+	Role        Role      `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	Enabled     bool      `json:"enabled"`
+	TOTPEnabled bool      `json:"totp_enabled,omitempty"`
+	Credentials []byte    `json:"credentials"`
+
+	FailedLogins      int       `json:"failed_logins,omitempty"`
+	LastFailedLoginAt time.Time `json:"last_failed_login_at"`
+	LockedUntil       time.Time `json:"locked_until"`
+}
+
+type credentials struct {
+	PasswordHash []byte   `json:"password_hash"`
+	Salt         []byte   `json:"salt"`
+	TOTPSecret   []byte   `json:"totp_secret,omitempty"`
+	TOTPCounter  uint64   `json:"totp_counter,omitempty"`
+	BackupCodes  [][]byte `json:"backup_codes,omitempty"`
+}
+
+// sessionRecord is the on-disk form of a Session. It is keyed by an HMAC of
+// the token, and the token and refresh token are sealed.
+type sessionRecord struct {
+	Username     string    `json:"username"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	IPAddress    string    `json:"ip_address"`
+	Token        []byte    `json:"token"`
+	RefreshToken []byte    `json:"refresh_token,omitempty"`
+}
+
+// apiKeyRecord is the on-disk form of an APIKey, with its hash
+type apiKeyRecord struct {
+	APIKey
+	Hash []byte `json:"hash"`
+}
+
+// encryptedStore implements Store over a kv.Store, encrypting password
+// hashes and session tokens at rest
+type encryptedStore struct {
+	backend kv.Store
+	cipher  *StoreCipher
+}
+
+// NewKVStore creates a store over db, which it closes when closed. Password
+// hashes and session tokens are encrypted with key.
+func NewKVStore(db kv.Store, key []byte) (Store, error) {
+	c, err := NewStoreCipher(key)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &encryptedStore{backend: db, cipher: c}, nil
+}
+
+func (s *encryptedStore) get(bucket, key string) ([]byte, error) {
+	value, err := kv.Get(s.backend, bucket, []byte(key))
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *encryptedStore) forEach(bucket string, fn func(key string, value []byte) error) error {
+	return kv.Iterate(s.backend, bucket, nil, func(key, value []byte) error {
+		return fn(string(key), value)
+	})
+}
+
+func (s *encryptedStore) PutUser(user *User) error {
+	creds, err := json.Marshal(credentials{
+		PasswordHash: user.PasswordHash,
+		Salt:         user.Salt,
+		TOTPSecret:   user.TOTPSecret,
+		TOTPCounter:  user.TOTPCounter,
+		BackupCodes:  user.BackupCodes,
+	})
+	if err != nil {
+		return err
+	}
+	sealed, err := s.cipher.Seal(creds, []byte(usersBucket+"/"+user.Username))
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(userRecord{
+		Username:    user.Username,
+		Role:        user.Role,
+		CreatedAt:   user.CreatedAt,
+		LastLoginAt: user.LastLoginAt,
+		Enabled:     user.Enabled,
+		TOTPEnabled: user.TOTPEnabled,
+		Credentials: sealed,
+
+		FailedLogins:      user.FailedLogins,
+		LastFailedLoginAt: user.LastFailedLoginAt,
+		LockedUntil:       user.LockedUntil,
+	})
+	if err != nil {
+		return err
+	}
+	return kv.Put(s.backend, usersBucket, []byte(user.Username), data)
+}
+
+func (s *encryptedStore) GetUser(username string) (*User, error) {
+	data, err := s.get(usersBucket, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeUser(username, data)
+}
+
+func (s *encryptedStore) decodeUser(key string, data []byte) (*User, error) {
+	var rec userRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("corrupt user record %s: %w", key, err)
+	}
+
+	plain, err := s.cipher.Open(rec.Credentials, []byte(usersBucket+"/"+key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt user %s: %w", key, err)
+	}
+	var creds credentials
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, fmt.Errorf("corrupt credentials for %s: %w", key, err)
+	}
+
+	return &User{
+		Username:     rec.Username,
+		PasswordHash: creds.PasswordHash,
+		Salt:         creds.Salt,
+		Role:         rec.Role,
+		CreatedAt:    rec.CreatedAt,
+		LastLoginAt:  rec.LastLoginAt,
+		Enabled:      rec.Enabled,
+		TOTPSecret:   creds.TOTPSecret,
+		TOTPEnabled:  rec.TOTPEnabled,
+		TOTPCounter:  creds.TOTPCounter,
+		BackupCodes:  creds.BackupCodes,
+
+		FailedLogins:      rec.FailedLogins,
+		LastFailedLoginAt: rec.LastFailedLoginAt,
+		LockedUntil:       rec.LockedUntil,
+	}, nil
+}
+
+func (s *encryptedStore) DeleteUser(username string) error {
+	return kv.Delete(s.backend, usersBucket, []byte(username))
+}
+
+func (s *encryptedStore) ListUsers() ([]*User, error) {
+	var users []*User
+	err := s.forEach(usersBucket, func(key string, value []byte) error {
+		user, err := s.decodeUser(key, value)
+		if err != nil {
+			return err
+		}
+		users = append(users, user)
+		return nil
+	})
+	return users, err
+}
+
+func (s *encryptedStore) PutSession(session *Session) error {
+	key := s.cipher.TokenIndex(session.Token)
+	sealed, err := s.cipher.Seal([]byte(session.Token), []byte(sessionsBucket+"/"+key))
+	if err != nil {
+		return err
+	}
+	var sealedRefresh []byte
+	if session.RefreshToken != "" {
+		sealedRefresh, err = s.cipher.Seal([]byte(session.RefreshToken), []byte(sessionsBucket+"/"+key+"/refresh"))
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(sessionRecord{
+		Username:     session.Username,
+		Role:         session.Role,
+		CreatedAt:    session.CreatedAt,
+		ExpiresAt:    session.ExpiresAt,
+		LastSeenAt:   session.LastSeenAt,
+		IPAddress:    session.IPAddress,
+		Token:        sealed,
+		RefreshToken: sealedRefresh,
+	})
+	if err != nil {
+		return err
+	}
+	return kv.Put(s.backend, sessionsBucket, []byte(key), data)
+}
+
+func (s *encryptedStore) GetSession(token string) (*Session, error) {
+	key := s.cipher.TokenIndex(token)
+	data, err := s.get(sessionsBucket, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeSession(key, data)
+}
+
+func (s *encryptedStore) decodeSession(key string, data []byte) (*Session, error) {
+	var rec sessionRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("corrupt session record: %w", err)
+	}
+
+	token, err := s.cipher.Open(rec.Token, []byte(sessionsBucket+"/"+key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt session: %w", err)
+	}
+	var refresh []byte
+	if rec.RefreshToken != nil {
+		refresh, err = s.cipher.Open(rec.RefreshToken, []byte(sessionsBucket+"/"+key+"/refresh"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt session: %w", err)
+		}
+	}
+
+	return &Session{
+		Token:        string(token),
+		RefreshToken: string(refresh),
+		Username:     rec.Username,
+		Role:         rec.Role,
+		CreatedAt:    rec.CreatedAt,
+		ExpiresAt:    rec.ExpiresAt,
+		LastSeenAt:   rec.LastSeenAt,
+		IPAddress:    rec.IPAddress,
+	}, nil
+}
+
+func (s *encryptedStore) DeleteSession(token string) error {
+	return kv.Delete(s.backend, sessionsBucket, []byte(s.cipher.TokenIndex(token)))
+}
+
+func (s *encryptedStore) ListSessions() ([]*Session, error) {
+	var sessions []*Session
+	err := s.forEach(sessionsBucket, func(key string, value []byte) error {
+		session, err := s.decodeSession(key, value)
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, session)
+		return nil
+	})
+	return sessions, err
+}
+
+func (s *encryptedStore) PutAPIKey(key *APIKey) error {
+	data, err := json.Marshal(apiKeyRecord{APIKey: *key, Hash: key.Hash})
+	if err != nil {
+		return err
+	}
+	return kv.Put(s.backend, apiKeysBucket, []byte(key.ID), data)
+}
+
+func (s *encryptedStore) DeleteAPIKey(id string) error {
+	return kv.Delete(s.backend, apiKeysBucket, []byte(id))
+}
+
+func (s *encryptedStore) ListAPIKeys() ([]*APIKey, error) {
+	var keys []*APIKey
+	err := s.forEach(apiKeysBucket, func(key string, value []byte) error {
+		var rec apiKeyRecord
+		if err := json.Unmarshal(value, &rec); err != nil {
+			return fmt.Errorf("corrupt API key record %s: %w", key, err)
+		}
+		rec.APIKey.Hash = rec.Hash
+		keys = append(keys, &rec.APIKey)
+		return nil
+	})
+	return keys, err
+}
+
+func (s *encryptedStore) Close() error {
+	return s.backend.Close()
+}
+
+// DefaultStorePath returns the default store database path,
+// $HOME/.excalibur-exs/guardian/guardian.db
+func DefaultStorePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	return filepath.Join(home, ".excalibur-exs", "guardian", "guardian.db"), nil
+}
+
+// OpenStore opens the store backend ("bolt", "badger", "sqlite" or "memory")
+// at path, or DefaultStorePath when path is empty. A badger store is a
+// directory. The encryption key is read from
+// GUARDIAN_STORE_KEY (hex) or from guardian.key next to the database, which
+// is created on first use.
+func OpenStore(backend, path string) (Store, error) {
+	if backend == "memory" {
+		return NewMemoryStore(), nil
+	}
+	if backend != kv.BackendBolt && backend != kv.BackendBadger && backend != "sqlite" {
+		return nil, fmt.Errorf("unknown store backend: %s (use bolt, badger, sqlite or memory)", backend)
+	}
+
+	if path == "" {
+		var err error
+		if path, err = DefaultStorePath(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	var key []byte
+	if envKey := os.Getenv("GUARDIAN_STORE_KEY"); envKey != "" {
+		decoded, err := hex.DecodeString(envKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GUARDIAN_STORE_KEY: %w", err)
+		}
+		key = decoded
+	} else {
+		loaded, err := LoadOrCreateStoreKey(filepath.Join(filepath.Dir(path), "guardian.key"))
+		if err != nil {
+			return nil, err
+		}
+		key = loaded
+	}
+
+	switch backend {
+	case "sqlite":
+		return NewSQLiteStore(path, key)
+	case kv.BackendBadger:
+		return NewBadgerStore(path, key)
+	}
+	return NewBoltStore(path, key)
+}
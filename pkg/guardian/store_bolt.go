@@ -0,0 +1,25 @@
+package guardian
+
+import (
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// NewBoltStore opens (or creates) a BoltDB-backed store at path. Password
+// hashes and session tokens are encrypted with key.
+func NewBoltStore(path string, key []byte) (Store, error) {
+	db, err := kv.OpenBolt(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewKVStore(db, key)
+}
+
+// NewBadgerStore opens (or creates) a Badger-backed store in the directory
+// path. Password hashes and session tokens are encrypted with key.
+func NewBadgerStore(path string, key []byte) (Store, error) {
+	db, err := kv.OpenBadger(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewKVStore(db, key)
+}
@@ -0,0 +1,125 @@
+package guardian
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, works with CGO_ENABLED=0
+)
+
+// sqliteKV is a kv.Store over a single SQLite table, each transaction an
+// SQL transaction
+type sqliteKV struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) a SQLite-backed store at path. Password
+// hashes and session tokens are encrypted with key.
+func NewSQLiteStore(path string, key []byte) (Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite store: %w", err)
+	}
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	stmts := []string{
+		`PRAGMA journal_mode=WAL`,
+		`PRAGMA synchronous=FULL`,
+		`CREATE TABLE IF NOT EXISTS guardian_kv (
+			bucket TEXT NOT NULL,
+			key    TEXT NOT NULL,
+			value  BLOB NOT NULL,
+			PRIMARY KEY (bucket, key)
+		)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize sqlite store: %w", err)
+		}
+	}
+
+	return NewKVStore(&sqliteKV{db: db}, key)
+}
+
+func (s *sqliteKV) run(writable bool, fn func(tx kv.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(sqliteTx{tx: tx, writable: writable}); err != nil || !writable {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteKV) View(fn func(tx kv.Tx) error) error {
+	return s.run(false, fn)
+}
+
+func (s *sqliteKV) Update(fn func(tx kv.Tx) error) error {
+	return s.run(true, fn)
+}
+
+func (s *sqliteKV) Close() error {
+	return s.db.Close()
+}
+
+type sqliteTx struct {
+	tx       *sql.Tx
+	writable bool
+}
+
+func (t sqliteTx) Get(bucket string, key []byte) ([]byte, error) {
+	var value []byte
+	err := t.tx.QueryRow(`SELECT value FROM guardian_kv WHERE bucket = ? AND key = ?`, bucket, string(key)).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, kv.ErrNotFound
+	}
+	return value, err
+}
+
+func (t sqliteTx) Put(bucket string, key, value []byte) error {
+	if !t.writable {
+		return kv.ErrReadOnly
+	}
+	_, err := t.tx.Exec(
+		`INSERT INTO guardian_kv (bucket, key, value) VALUES (?, ?, ?)
+		 ON CONFLICT(bucket, key) DO UPDATE SET value = excluded.value`,
+		bucket, string(key), value,
+	)
+	return err
+}
+
+func (t sqliteTx) Delete(bucket string, key []byte) error {
+	if !t.writable {
+		return kv.ErrReadOnly
+	}
+	_, err := t.tx.Exec(`DELETE FROM guardian_kv WHERE bucket = ? AND key = ?`, bucket, string(key))
+	return err
+}
+
+func (t sqliteTx) Iterate(bucket string, prefix []byte, fn func(key, value []byte) error) error {
+	rows, err := t.tx.Query(`SELECT key, value FROM guardian_kv WHERE bucket = ? AND substr(key, 1, ?) = ? ORDER BY key`,
+		bucket, len(prefix), string(prefix))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		if err := fn([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,264 @@
+package guardian
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testConfig uses cheap Argon2 parameters to keep store tests fast
+func testConfig() *Config {
+	config := DefaultConfig()
+	config.Argon2Time = 1
+	config.Argon2Memory = 8 * 1024
+	return config
+}
+
+func testStoreKey() []byte {
+	return bytes.Repeat([]byte{0x42}, StoreKeySize)
+}
+
+func openTestStores(t *testing.T) map[string]func(path string, key []byte) (Store, error) {
+	return map[string]func(path string, key []byte) (Store, error){
+		"bolt":   NewBoltStore,
+		"badger": NewBadgerStore,
+		"sqlite": NewSQLiteStore,
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	for name, open := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store, err := open(filepath.Join(t.TempDir(), "guardian.db"), testStoreKey())
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer store.Close()
+
+			user := &User{
+				Username:     "arthur",
+				PasswordHash: []byte("hash"),
+				Salt:         []byte("salt"),
+				Role:         RoleKingArthur,
+				CreatedAt:    time.Now().UTC().Truncate(time.Second),
+				Enabled:      true,
+			}
+			if err := store.PutUser(user); err != nil {
+				t.Fatalf("PutUser failed: %v", err)
+			}
+
+			got, err := store.GetUser("arthur")
+			if err != nil {
+				t.Fatalf("GetUser failed: %v", err)
+			}
+			if !bytes.Equal(got.PasswordHash, user.PasswordHash) || !bytes.Equal(got.Salt, user.Salt) {
+				t.Error("Credentials did not round trip")
+			}
+			if got.Role != RoleKingArthur || !got.CreatedAt.Equal(user.CreatedAt) {
+				t.Errorf("Unexpected user: %+v", got)
+			}
+
+			session := &Session{
+				Token:        "deadbeef",
+				RefreshToken: "cafebabe",
+				Username:     "arthur",
+				Role:         RoleKingArthur,
+				ExpiresAt:    time.Now().Add(time.Hour),
+				LastSeenAt:   time.Now().UTC(),
+			}
+			if err := store.PutSession(session); err != nil {
+				t.Fatalf("PutSession failed: %v", err)
+			}
+			gotSession, err := store.GetSession("deadbeef")
+			if err != nil {
+				t.Fatalf("GetSession failed: %v", err)
+			}
+			if gotSession.Token != "deadbeef" || gotSession.RefreshToken != "cafebabe" || gotSession.Username != "arthur" ||
+				!gotSession.LastSeenAt.Equal(session.LastSeenAt) {
+				t.Errorf("Unexpected session: %+v", gotSession)
+			}
+
+			if err := store.DeleteSession("deadbeef"); err != nil {
+				t.Fatalf("DeleteSession failed: %v", err)
+			}
+			if _, err := store.GetSession("deadbeef"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreEncryptionAtRest(t *testing.T) {
+	for name, open := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "guardian.db")
+			store, err := open(path, testStoreKey())
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+
+			secretHash := []byte("SECRET-PASSWORD-HASH")
+			token := "SECRET-SESSION-TOKEN"
+			store.PutUser(&User{Username: "merlin", PasswordHash: secretHash, Salt: []byte("salt")})
+			store.PutSession(&Session{Token: token, Username: "merlin", ExpiresAt: time.Now().Add(time.Hour)})
+			store.Close()
+
+			// A badger store is a directory of files
+			matches, _ := filepath.Glob(path + "*")
+			nested, _ := filepath.Glob(filepath.Join(path, "*"))
+			for _, file := range append(matches, nested...) {
+				if info, err := os.Stat(file); err != nil || info.IsDir() {
+					continue
+				}
+				raw, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatalf("Failed to read store file: %v", err)
+				}
+				if bytes.Contains(raw, secretHash) || bytes.Contains(raw, []byte(token)) {
+					t.Errorf("%s contains plaintext secrets", filepath.Base(file))
+				}
+			}
+
+			// A different key must not be able to decrypt the records
+			wrongKey := bytes.Repeat([]byte{0x01}, StoreKeySize)
+			reopened, err := open(path, wrongKey)
+			if err != nil {
+				t.Fatalf("Failed to reopen store: %v", err)
+			}
+			defer reopened.Close()
+			if _, err := reopened.GetUser("merlin"); err == nil {
+				t.Error("Expected decryption failure with wrong key")
+			}
+		})
+	}
+}
+
+func TestGuardianPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guardian.db")
+
+	store, err := NewBoltStore(path, testStoreKey())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	g, err := NewGuardianWithStore(testConfig(), store)
+	if err != nil {
+		t.Fatalf("NewGuardianWithStore failed: %v", err)
+	}
+
+	if err := g.CreateUser("galahad", "grail", RoleKnight); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	token, err := g.Authenticate("galahad", "grail", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	g.Close()
+
+	// Simulate a restart
+	store, err = NewBoltStore(path, testStoreKey())
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	g, err = NewGuardianWithStore(testConfig(), store)
+	if err != nil {
+		t.Fatalf("NewGuardianWithStore failed: %v", err)
+	}
+	defer g.Close()
+
+	users := g.ListUsers()
+	if len(users) != 1 || users[0].Username != "galahad" {
+		t.Fatalf("Expected persisted user galahad, got %v", users)
+	}
+	if users[0].LastLoginAt.IsZero() {
+		t.Error("Expected last login to be persisted")
+	}
+
+	session, err := g.ValidateSession(token)
+	if err != nil {
+		t.Fatalf("Persisted session should be valid: %v", err)
+	}
+	if session.Username != "galahad" {
+		t.Errorf("Expected session for galahad, got %s", session.Username)
+	}
+
+	if _, err := g.Authenticate("galahad", "grail", "127.0.0.1"); err != nil {
+		t.Errorf("Authentication after restart failed: %v", err)
+	}
+
+	if err := g.RevokeSession(token); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if _, err := store.GetSession(token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoked session should be removed from store, got %v", err)
+	}
+}
+
+func TestGuardianPrunesExpiredSessionsOnLoad(t *testing.T) {
+	store := NewMemoryStore()
+	store.PutSession(&Session{Token: "old", Username: "kay", ExpiresAt: time.Now().Add(-time.Minute)})
+	store.PutSession(&Session{Token: "new", Username: "kay", ExpiresAt: time.Now().Add(time.Minute)})
+
+	g, err := NewGuardianWithStore(testConfig(), store)
+	if err != nil {
+		t.Fatalf("NewGuardianWithStore failed: %v", err)
+	}
+	defer g.Close()
+
+	if _, err := g.ValidateSession("new"); err != nil {
+		t.Errorf("Expected live session to load: %v", err)
+	}
+	if _, err := store.GetSession("old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected expired session to be pruned, got %v", err)
+	}
+}
+
+func TestLoadOrCreateStoreKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "guardian.key")
+
+	key, err := LoadOrCreateStoreKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateStoreKey failed: %v", err)
+	}
+	if len(key) != StoreKeySize {
+		t.Errorf("Expected %d byte key, got %d", StoreKeySize, len(key))
+	}
+
+	again, err := LoadOrCreateStoreKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateStoreKey failed: %v", err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Error("Expected the same key on second load")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key file mode 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestGuardianCheckStore(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "guardian.db"), testStoreKey())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	g, err := NewGuardianWithStore(testConfig(), store)
+	if err != nil {
+		t.Fatalf("NewGuardianWithStore failed: %v", err)
+	}
+	defer g.Close()
+
+	if err := g.CheckStore(); err != nil {
+		t.Errorf("CheckStore failed on an open store: %v", err)
+	}
+	store.Close()
+	if err := g.CheckStore(); err == nil {
+		t.Error("Expected CheckStore to fail once the store is closed")
+	}
+}
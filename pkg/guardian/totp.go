@@ -0,0 +1,240 @@
+package guardian
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238 defaults understood by all authenticator apps)
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+	TOTPIssuer = "Excalibur-EXS"
+
+	// totpSkew is the number of periods either side of now accepted to
+	// tolerate clock drift
+	totpSkew       = 1
+	totpSecretSize = 20
+
+	// BackupCodeCount is the number of one-time backup codes issued on enrollment
+	BackupCodeCount = 10
+)
+
+var (
+	// ErrTOTPRequired indicates the user must supply a two-factor code
+	ErrTOTPRequired = errors.New("two-factor code required")
+	// ErrInvalidTOTP indicates a wrong, reused or expired two-factor code
+	ErrInvalidTOTP = errors.New("invalid two-factor code")
+	// ErrTOTPEnabled indicates two-factor authentication is already active
+	ErrTOTPEnabled = errors.New("two-factor authentication already enabled")
+	// ErrTOTPNotEnrolled indicates EnableTOTP has not been called for the user
+	ErrTOTPNotEnrolled = errors.New("two-factor authentication not enrolled")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment is returned by EnableTOTP for the user to set up their
+// authenticator. It is shown once and never stored in the clear.
+type TOTPEnrollment struct {
+	Secret      string   // base32 secret for manual entry
+	URI         string   // otpauth:// provisioning URI, usually shown as a QR code
+	BackupCodes []string // one-time codes for when the authenticator is lost
+}
+
+// TOTPCode returns the code for secret at time t
+func TOTPCode(secret []byte, t time.Time) string {
+	return hotp(secret, uint64(t.Unix())/uint64(TOTPPeriod/time.Second))
+}
+
+// hotp computes an RFC 4226 one-time password
+func hotp(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1000000)
+}
+
+// EnableTOTP starts two-factor enrollment for a user, generating a new secret
+// and backup codes. Authentication is unaffected until the user proves their
+// authenticator works with VerifyTOTP.
+func (g *Guardian) EnableTOTP(username string) (*TOTPEnrollment, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user, exists := g.users[username]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	codes, hashes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *user
+	updated.TOTPSecret = secret
+	updated.TOTPCounter = 0
+	updated.BackupCodes = hashes
+	if err := g.store.PutUser(&updated); err != nil {
+		return nil, fmt.Errorf("failed to persist user: %w", err)
+	}
+	*user = updated
+
+	encoded := totpEncoding.EncodeToString(secret)
+	return &TOTPEnrollment{
+		Secret:      encoded,
+		URI:         provisioningURI(username, encoded),
+		BackupCodes: codes,
+	}, nil
+}
+
+// VerifyTOTP checks a code from the user's authenticator. The first valid
+// code after EnableTOTP completes enrollment, after which Authenticate
+// requires a code.
+func (g *Guardian) VerifyTOTP(username, code string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user, exists := g.users[username]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	if len(user.TOTPSecret) == 0 {
+		return ErrTOTPNotEnrolled
+	}
+
+	updated := *user
+	if !updated.checkTOTP(code, time.Now()) {
+		return ErrInvalidTOTP
+	}
+	updated.TOTPEnabled = true
+	if err := g.store.PutUser(&updated); err != nil {
+		return fmt.Errorf("failed to persist user: %w", err)
+	}
+	if !user.TOTPEnabled {
+		g.audit(AuditTOTPEnabled, username, "", nil)
+	}
+	*user = updated
+	return nil
+}
+
+// DisableTOTP removes a user's secret and backup codes
+func (g *Guardian) DisableTOTP(username string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user, exists := g.users[username]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+
+	updated := *user
+	updated.TOTPSecret = nil
+	updated.TOTPEnabled = false
+	updated.TOTPCounter = 0
+	updated.BackupCodes = nil
+	if err := g.store.PutUser(&updated); err != nil {
+		return fmt.Errorf("failed to persist user: %w", err)
+	}
+	if user.TOTPEnabled {
+		g.audit(AuditTOTPDisabled, username, "", nil)
+	}
+	*user = updated
+	return nil
+}
+
+// checkSecondFactor accepts a current TOTP code or consumes a backup code
+func (u *User) checkSecondFactor(code string, now time.Time) bool {
+	return u.checkTOTP(code, now) || u.useBackupCode(code)
+}
+
+// checkTOTP accepts a code within totpSkew periods of now. Each period's
+// code is accepted once, so an observed code cannot be replayed.
+func (u *User) checkTOTP(code string, now time.Time) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return false
+	}
+	current := uint64(now.Unix()) / uint64(TOTPPeriod/time.Second)
+	for counter := current - totpSkew; counter <= current+totpSkew; counter++ {
+		if counter <= u.TOTPCounter {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(u.TOTPSecret, counter)), []byte(code)) == 1 {
+			u.TOTPCounter = counter
+			return true
+		}
+	}
+	return false
+}
+
+// useBackupCode removes code from the user's unused backup codes
+func (u *User) useBackupCode(code string) bool {
+	hash := hashBackupCode(code)
+	for i, stored := range u.BackupCodes {
+		if subtle.ConstantTimeCompare(stored, hash) == 1 {
+			remaining := make([][]byte, 0, len(u.BackupCodes)-1)
+			remaining = append(remaining, u.BackupCodes[:i]...)
+			u.BackupCodes = append(remaining, u.BackupCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// newBackupCodes generates backup codes formatted as "xxxx-xxxx" and their
+// hashes for storage
+func newBackupCodes() ([]string, [][]byte, error) {
+	codes := make([]string, BackupCodeCount)
+	hashes := make([][]byte, BackupCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate backup code: %w", err)
+		}
+		encoded := strings.ToLower(totpEncoding.EncodeToString(raw))
+		codes[i] = encoded[:4] + "-" + encoded[4:]
+		hashes[i] = hashBackupCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashBackupCode hashes a backup code, ignoring case, spaces and dashes
+func hashBackupCode(code string) []byte {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return sum[:]
+}
+
+// provisioningURI builds the otpauth:// URI understood by authenticator apps
+func provisioningURI(username, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	return "otpauth://totp/" + url.PathEscape(TOTPIssuer+":"+username) + "?" + params.Encode()
+}
@@ -0,0 +1,166 @@
+package guardian
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors for SHA-1, truncated to 6 digits
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := TOTPCode(secret, time.Unix(tt.unix, 0)); got != tt.code {
+			t.Errorf("TOTPCode(%d) = %s, expected %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+// enrollTOTP enables two-factor authentication for a user and returns the
+// enrollment and decoded secret
+func enrollTOTP(t *testing.T, g *Guardian, username string) (*TOTPEnrollment, []byte) {
+	t.Helper()
+	enrollment, err := g.EnableTOTP(username)
+	if err != nil {
+		t.Fatalf("EnableTOTP() error = %v", err)
+	}
+	secret, err := totpEncoding.DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("Invalid secret %q: %v", enrollment.Secret, err)
+	}
+	// Verify with the previous period's code so tests can still log in with
+	// the current one
+	if err := g.VerifyTOTP(username, TOTPCode(secret, time.Now().Add(-TOTPPeriod))); err != nil {
+		t.Fatalf("VerifyTOTP() error = %v", err)
+	}
+	return enrollment, secret
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	g := NewGuardian(testConfig())
+	g.CreateUser("galahad", "holygrail1", RoleKnight)
+
+	enrollment, err := g.EnableTOTP("galahad")
+	if err != nil {
+		t.Fatalf("EnableTOTP() error = %v", err)
+	}
+	uri, err := url.Parse(enrollment.URI)
+	if err != nil || uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Fatalf("Invalid provisioning URI %q", enrollment.URI)
+	}
+	if uri.Query().Get("secret") != enrollment.Secret || uri.Query().Get("issuer") != TOTPIssuer {
+		t.Errorf("Provisioning URI %q does not match enrollment", enrollment.URI)
+	}
+	if len(enrollment.BackupCodes) != BackupCodeCount {
+		t.Errorf("Expected %d backup codes, got %d", BackupCodeCount, len(enrollment.BackupCodes))
+	}
+
+	// Login is unaffected until the first code is verified
+	if _, err := g.Authenticate("galahad", "holygrail1", "127.0.0.1"); err != nil {
+		t.Fatalf("Expected pending enrollment not to require a code, got %v", err)
+	}
+	if err := g.VerifyTOTP("galahad", "000000"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected ErrInvalidTOTP, got %v", err)
+	}
+	secret, _ := totpEncoding.DecodeString(enrollment.Secret)
+	if err := g.VerifyTOTP("galahad", TOTPCode(secret, time.Now())); err != nil {
+		t.Fatalf("VerifyTOTP() error = %v", err)
+	}
+	if _, err := g.EnableTOTP("galahad"); !errors.Is(err, ErrTOTPEnabled) {
+		t.Errorf("Expected ErrTOTPEnabled, got %v", err)
+	}
+	if _, err := g.Authenticate("galahad", "holygrail1", "127.0.0.1"); !errors.Is(err, ErrTOTPRequired) {
+		t.Errorf("Expected ErrTOTPRequired, got %v", err)
+	}
+
+	if err := g.DisableTOTP("galahad"); err != nil {
+		t.Fatalf("DisableTOTP() error = %v", err)
+	}
+	if _, err := g.Authenticate("galahad", "holygrail1", "127.0.0.1"); err != nil {
+		t.Errorf("Expected login without a code after DisableTOTP, got %v", err)
+	}
+	if err := g.VerifyTOTP("galahad", "123456"); !errors.Is(err, ErrTOTPNotEnrolled) {
+		t.Errorf("Expected ErrTOTPNotEnrolled, got %v", err)
+	}
+}
+
+func TestAuthenticateWithTOTP(t *testing.T) {
+	g := NewGuardian(testConfig())
+	g.CreateUser("bedivere", "lakeside99", RoleKnight)
+	enrollment, secret := enrollTOTP(t, g, "bedivere")
+
+	code := TOTPCode(secret, time.Now())
+	if _, err := g.AuthenticateWithTOTP("bedivere", "wrongpassword", code, "127.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := g.AuthenticateWithTOTP("bedivere", "lakeside99", code, "127.0.0.1"); err != nil {
+		t.Fatalf("AuthenticateWithTOTP() error = %v", err)
+	}
+	if _, err := g.AuthenticateWithTOTP("bedivere", "lakeside99", code, "127.0.0.1"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected a reused code to be rejected, got %v", err)
+	}
+
+	// Backup codes work once each, in any case and without the dash
+	backup := enrollment.BackupCodes[0]
+	if _, err := g.AuthenticateWithTOTP("bedivere", "lakeside99", "  "+strings.ToUpper(backup[:4]+backup[5:])+" ", "127.0.0.1"); err != nil {
+		t.Fatalf("Expected backup code to be accepted, got %v", err)
+	}
+	if _, err := g.AuthenticateWithTOTP("bedivere", "lakeside99", backup, "127.0.0.1"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected a used backup code to be rejected, got %v", err)
+	}
+	user, _ := g.GetUserInfo("bedivere")
+	if len(user.BackupCodes) != BackupCodeCount-1 {
+		t.Errorf("Expected %d backup codes left, got %d", BackupCodeCount-1, len(user.BackupCodes))
+	}
+}
+
+func TestTOTPPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guardian.db")
+	store, err := NewBoltStore(path, testStoreKey())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	g, err := NewGuardianWithStore(testConfig(), store)
+	if err != nil {
+		t.Fatalf("NewGuardianWithStore() error = %v", err)
+	}
+	g.CreateUser("tristan", "isolde2024", RoleKnight)
+	enrollment, secret := enrollTOTP(t, g, "tristan")
+	if _, err := g.AuthenticateWithTOTP("tristan", "isolde2024", enrollment.BackupCodes[0], "127.0.0.1"); err != nil {
+		t.Fatalf("AuthenticateWithTOTP() error = %v", err)
+	}
+	g.Close()
+
+	store, err = NewBoltStore(path, testStoreKey())
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	g, err = NewGuardianWithStore(testConfig(), store)
+	if err != nil {
+		t.Fatalf("NewGuardianWithStore() error = %v", err)
+	}
+	defer g.Close()
+
+	if _, err := g.Authenticate("tristan", "isolde2024", "127.0.0.1"); !errors.Is(err, ErrTOTPRequired) {
+		t.Errorf("Expected two-factor to survive a restart, got %v", err)
+	}
+	if _, err := g.AuthenticateWithTOTP("tristan", "isolde2024", enrollment.BackupCodes[0], "127.0.0.1"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected used backup code to stay used, got %v", err)
+	}
+	if _, err := g.AuthenticateWithTOTP("tristan", "isolde2024", TOTPCode(secret, time.Now()), "127.0.0.1"); err != nil {
+		t.Errorf("AuthenticateWithTOTP() error = %v", err)
+	}
+}
@@ -1,9 +1,15 @@
 package hardware
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"runtime"
 	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
 )
 
 // HardwareType represents the type of mining hardware
@@ -43,7 +49,7 @@ type HardwareInfo struct {
 	Cores            int
 	Memory           uint64 // In bytes
 	ComputeUnits     int
-	MaxHashRate      float64 // Estimated H/s
+	MaxHashRate      float64 // H/s, measured once calibrated
 	PowerConsumption float64 // Estimated watts
 	Supported        bool
 }
@@ -55,6 +61,14 @@ type Accelerator struct {
 	workerCount   int
 	enabled       bool
 	optimization  string
+	workerRates   []float64
+	backend       Backend // nil when mining on the CPU
+	fallback      error   // Why a requested backend is not in use
+	calibration   *Calibration
+	throttled     int             // CPU workers while too hot, 0 when not throttled
+	thermal       *ThermalReading // Last sensor reading
+	thermalSensor string
+	thermalLimit  float64
 }
 
 // NewAccelerator creates a new hardware accelerator
@@ -77,8 +91,7 @@ func DetectHardware() HardwareInfo {
 		Supported:   true,
 	}
 
-	// Estimate hash rate based on CPU cores
-	// Ω′ Δ18 is compute-intensive: ~100-500 H/s per core typical
+	// Rough per-core guess until SetCalibration supplies a measured rate
 	info.MaxHashRate = float64(info.Cores) * 250.0
 	
 	// Estimate power: ~50W per core at full load
@@ -187,7 +200,11 @@ func (a *Accelerator) GetOptimization() string {
 func (a *Accelerator) EstimateHashRate() float64 {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	
+	return a.estimateHashRate()
+}
+
+// estimateHashRate estimates the hash rate; callers must hold a.mu
+func (a *Accelerator) estimateHashRate() float64 {
 	if !a.enabled {
 		return 0
 	}
@@ -195,9 +212,12 @@ func (a *Accelerator) EstimateHashRate() float64 {
 	baseRate := a.hardwareInfo.MaxHashRate
 	workerRatio := float64(a.workerCount) / float64(a.hardwareInfo.Cores)
 	
-	// Apply diminishing returns for oversubscription
+	// Apply diminishing returns for oversubscription. A calibration was
+	// measured with every core busy, so extra workers add nothing to it.
 	var efficiency float64
-	if workerRatio <= 1.0 {
+	if a.calibration != nil {
+		efficiency = min(workerRatio, 1.0)
+	} else if workerRatio <= 1.0 {
 		efficiency = workerRatio
 	} else if workerRatio <= 2.0 {
 		efficiency = 1.0 + (workerRatio-1.0)*0.7
@@ -212,7 +232,11 @@ func (a *Accelerator) EstimateHashRate() float64 {
 func (a *Accelerator) EstimatePowerConsumption() float64 {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	
+	return a.estimatePowerConsumption()
+}
+
+// estimatePowerConsumption estimates power draw; callers must hold a.mu
+func (a *Accelerator) estimatePowerConsumption() float64 {
 	if !a.enabled {
 		return 0
 	}
@@ -255,6 +279,13 @@ func (a *Accelerator) GetStats() map[string]interface{} {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 	
+	hashRate := a.estimateHashRate()
+	power := a.estimatePowerConsumption()
+	efficiency := 0.0
+	if power != 0 {
+		efficiency = hashRate / power
+	}
+	
 	return map[string]interface{}{
 		"hardware_type":       a.hardwareInfo.Type.String(),
 		"hardware_name":       a.hardwareInfo.Name,
@@ -262,8 +293,243 @@ func (a *Accelerator) GetStats() map[string]interface{} {
 		"worker_count":        a.workerCount,
 		"enabled":             a.enabled,
 		"optimization":        a.optimization,
-		"estimated_hashrate":  a.EstimateHashRate(),
-		"estimated_power_w":   a.EstimatePowerConsumption(),
-		"efficiency_h_per_w":  a.GetEfficiency(),
+		"estimated_hashrate":  hashRate,
+		"estimated_power_w":   power,
+		"efficiency_h_per_w":  efficiency,
+		"measured_hashrate":   a.measuredHashRate(),
+		"calibrated":          a.calibration != nil,
+		"backend":             a.backendName(),
+		"devices":             len(a.devices()),
+		"worker_hashrates":    append([]float64(nil), a.workerRates...),
+		"active_workers":      a.activeWorkers(),
+		"throttled":           a.throttled > 0,
+		"temperature_c":       a.temperature(),
+	}
+}
+
+// temperature returns the hottest temperature last read, 0 without a
+// reading; callers must hold a.mu
+func (a *Accelerator) temperature() float64 {
+	if a.thermal == nil {
+		return 0
+	}
+	return a.thermal.Hottest()
+}
+
+// RecordWorkerHashRates stores the hash rates measured by each mining worker
+func (a *Accelerator) RecordWorkerHashRates(rates []float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.workerRates = append([]float64(nil), rates...)
+}
+
+// GetWorkerHashRates returns the last recorded per-worker hash rates
+func (a *Accelerator) GetWorkerHashRates() []float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]float64(nil), a.workerRates...)
+}
+
+// MeasuredHashRate returns the combined hash rate of the last mining run
+func (a *Accelerator) MeasuredHashRate() float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.measuredHashRate()
+}
+
+// measuredHashRate sums the recorded worker rates; callers must hold a.mu
+func (a *Accelerator) measuredHashRate() float64 {
+	var total float64
+	for _, rate := range a.workerRates {
+		total += rate
+	}
+	return total
+}
+
+// SetCalibration replaces the per-core hash rate guess with a measured one,
+// rejecting a calibration from other hardware
+func (a *Accelerator) SetCalibration(c *Calibration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !c.Matches(a.hardwareInfo) {
+		return fmt.Errorf("%w: %d %s cores, not %d %s cores", ErrCalibrationMismatch,
+			c.Cores, c.Arch, a.hardwareInfo.Cores, a.hardwareInfo.Name)
+	}
+	a.calibration = c
+	a.hardwareInfo.MaxHashRate = c.HashRate()
+	return nil
+}
+
+// Calibration returns the calibration in use, nil while hash rates are
+// estimated
+func (a *Accelerator) Calibration() *Calibration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.calibration
+}
+
+// EstimateBlockTime is the mean time to mine a block at difficulty with the
+// current configuration, negative when it cannot be estimated
+func (a *Accelerator) EstimateBlockTime(difficulty uint64) time.Duration {
+	return ExpectedBlockTime(difficulty, a.EstimateHashRate())
+}
+
+// SetBackend selects where Mine hashes: BackendCPU, BackendAuto or a name
+// from Backends. Auto settles for the CPU when no backend has a device; a
+// named backend that cannot be opened is an error, leaving the CPU in use.
+func (a *Accelerator) SetBackend(name string) error {
+	var (
+		backend Backend
+		err     error
+	)
+	switch name {
+	case BackendCPU:
+	case BackendAuto:
+		var errs []error
+		for _, candidate := range Backends() {
+			if backend, err = OpenBackend(candidate); err == nil {
+				break
+			}
+			errs = append(errs, err)
+		}
+		if backend == nil {
+			err = errors.Join(errs...)
+		}
+	default:
+		backend, err = OpenBackend(name)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.backend != nil {
+		a.backend.Close()
+	}
+	a.backend, a.fallback = backend, err
+	if name == BackendAuto {
+		return nil
+	}
+	return err
+}
+
+// Backend returns the name of the backend Mine uses, BackendCPU if none
+func (a *Accelerator) Backend() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.backendName()
+}
+
+// backendName names the active backend; callers must hold a.mu
+func (a *Accelerator) backendName() string {
+	if a.backend == nil {
+		return BackendCPU
+	}
+	return a.backend.Name()
+}
+
+// Devices returns the devices of the active backend, none on the CPU
+func (a *Accelerator) Devices() []Device {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.devices()
+}
+
+// devices lists the active backend's devices; callers must hold a.mu
+func (a *Accelerator) devices() []Device {
+	if a.backend == nil {
+		return nil
+	}
+	return a.backend.Devices()
+}
+
+// FallbackReason returns why mining is on the CPU although SetBackend
+// asked for a device backend, or nil
+func (a *Accelerator) FallbackReason() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.fallback
+}
+
+// Close releases the backend's devices
+func (a *Accelerator) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.backend == nil {
+		return nil
+	}
+	err := a.backend.Close()
+	a.backend = nil
+	return err
+}
+
+// Mine runs a Tetra-PoW search and records the per-worker hash rates. With a
+// device backend the workers are its devices; if the backend fails, it is
+// closed and the search continues on the CPU with the configured worker
+// count, leaving the failure in FallbackReason.
+func (a *Accelerator) Mine(ctx context.Context, data []byte, difficulty uint64) (*crypto.ParallelResult, error) {
+	result, err := a.MineRange(ctx, data, difficulty, 0, math.MaxUint64)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MineRange is Mine over the nonces first to last, a crypto.SearchFunc for
+// mining jobs. Like crypto.ParallelTetraPoWRange, it returns the work done
+// along with ErrNonceSpaceExhausted or a context error.
+func (a *Accelerator) MineRange(ctx context.Context, data []byte, difficulty uint64, first, last uint64) (*crypto.ParallelResult, error) {
+	if !a.IsEnabled() {
+		return nil, fmt.Errorf("hardware acceleration is disabled")
+	}
+
+	a.mu.RLock()
+	backend, optimization := a.backend, a.optimization
+	a.mu.RUnlock()
+
+	var (
+		result *crypto.ParallelResult
+		err    error
+	)
+	if backend != nil {
+		result, err = mineDevices(ctx, backend, data, difficulty, optimization, first, last)
+		if err != nil && ctx.Err() == nil && !errors.Is(err, crypto.ErrNonceSpaceExhausted) {
+			a.mu.Lock()
+			if a.backend == backend {
+				a.backend.Close()
+				a.backend, a.fallback = nil, err
+			}
+			a.mu.Unlock()
+			result, err = nil, nil
+		} else if err != nil && result == nil {
+			return nil, err
+		}
+	}
+	if result == nil {
+		result, err = crypto.ParallelTetraPoWRange(ctx, data, difficulty, a.ActiveWorkers(), first, last)
+	}
+	if result == nil {
+		return nil, err
+	}
+
+	rates := make([]float64, len(result.Workers))
+	for i, w := range result.Workers {
+		rates[i] = w.HashRate
+	}
+	a.RecordWorkerHashRates(rates)
+	return result, err
+}
+
+// BatchSize returns the number of nonces a mining job should search at
+// once to keep every device, or every CPU worker, busy
+func (a *Accelerator) BatchSize() uint64 {
+	a.mu.RLock()
+	backend, optimization, workers := a.backend, a.optimization, a.workerCount
+	a.mu.RUnlock()
+	if backend == nil {
+		return uint64(max(workers, 1)) * crypto.DefaultJobBatch
+	}
+	var total uint64
+	for _, device := range backend.Devices() {
+		total += batchSize(device, optimization)
 	}
+	return total
 }
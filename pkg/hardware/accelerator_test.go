@@ -1,8 +1,17 @@
 package hardware
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
 )
 
 func TestNewAccelerator(t *testing.T) {
@@ -71,6 +80,8 @@ func TestHardwareTypeString(t *testing.T) {
 
 func TestSetWorkerCount(t *testing.T) {
 	acc := NewAccelerator()
+	// Pin the core count so the cap does not depend on the host
+	acc.hardwareInfo.Cores = 4
 	
 	// Test setting valid worker count
 	err := acc.SetWorkerCount(4)
@@ -308,6 +319,241 @@ func TestGetStats(t *testing.T) {
 	}
 }
 
+func TestRecordWorkerHashRates(t *testing.T) {
+	acc := NewAccelerator()
+	
+	acc.RecordWorkerHashRates([]float64{1.5, 2.5})
+	
+	if rate := acc.MeasuredHashRate(); rate != 4.0 {
+		t.Errorf("Expected measured hash rate 4.0, got %f", rate)
+	}
+	
+	stats := acc.GetStats()
+	rates, ok := stats["worker_hashrates"].([]float64)
+	if !ok || len(rates) != 2 {
+		t.Fatalf("Expected 2 worker hash rates, got %v", stats["worker_hashrates"])
+	}
+	if stats["measured_hashrate"] != 4.0 {
+		t.Errorf("Expected measured_hashrate 4.0, got %v", stats["measured_hashrate"])
+	}
+}
+
+func TestMine(t *testing.T) {
+	acc := NewAccelerator()
+	acc.hardwareInfo.Cores = 2
+	if err := acc.SetWorkerCount(2); err != nil {
+		t.Fatalf("SetWorkerCount() error = %v", err)
+	}
+	
+	result, err := acc.Mine(context.Background(), []byte("test"), 0xFFFFFFFFFFFFFF00)
+	if err != nil {
+		t.Fatalf("Mine() error = %v", err)
+	}
+	if len(result.Hash) != 32 {
+		t.Errorf("Expected 32-byte hash, got %d", len(result.Hash))
+	}
+	if len(acc.GetWorkerHashRates()) != 2 {
+		t.Errorf("Expected 2 recorded worker rates, got %d", len(acc.GetWorkerHashRates()))
+	}
+	
+	acc.Disable()
+	if _, err := acc.Mine(context.Background(), []byte("test"), 0xFFFFFFFFFFFFFF00); err == nil {
+		t.Error("Expected error when acceleration is disabled")
+	}
+}
+
+// fakeBackend hashes on the CPU behind the Backend interface
+type fakeBackend struct {
+	mu      sync.Mutex
+	devices []Device
+	fail    error
+	batches int
+	closed  bool
+}
+
+func (f *fakeBackend) Name() string      { return "fake" }
+func (f *fakeBackend) Devices() []Device { return f.devices }
+
+func (f *fakeBackend) Search(ctx context.Context, device int, data []byte, difficulty uint64, start, count uint64) (Batch, error) {
+	f.mu.Lock()
+	f.batches++
+	f.mu.Unlock()
+	if f.fail != nil {
+		return Batch{}, f.fail
+	}
+	var batch Batch
+	for nonce := start; nonce < start+count; nonce++ {
+		batch.Hashes++
+		hash := crypto.TetraPoWHash(data, nonce)
+		if binary.LittleEndian.Uint64(hash[0:8]) < difficulty {
+			batch.Found, batch.Nonce = true, nonce
+			break
+		}
+	}
+	return batch, nil
+}
+
+func (f *fakeBackend) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// useFakeBackend registers backend as the only backend for one test
+func useFakeBackend(t *testing.T, backend *fakeBackend) {
+	t.Helper()
+	backendsMu.Lock()
+	saved := backends
+	backends = map[string]func() (Backend, error){
+		"fake": func() (Backend, error) { return backend, nil },
+	}
+	backendsMu.Unlock()
+	t.Cleanup(func() {
+		backendsMu.Lock()
+		backends = saved
+		backendsMu.Unlock()
+	})
+}
+
+func TestSetBackend(t *testing.T) {
+	acc := NewAccelerator()
+	if acc.Backend() != BackendCPU {
+		t.Errorf("Expected CPU backend by default, got %s", acc.Backend())
+	}
+	if err := acc.SetBackend("cuda-9000"); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("Expected ErrUnknownBackend, got %v", err)
+	}
+
+	empty := &fakeBackend{}
+	useFakeBackend(t, empty)
+	if err := acc.SetBackend(BackendAuto); err != nil {
+		t.Fatalf("SetBackend(auto) error = %v", err)
+	}
+	if acc.Backend() != BackendCPU || !errors.Is(acc.FallbackReason(), ErrNoDevices) {
+		t.Errorf("Expected CPU fallback for a backend without devices, got %s (%v)", acc.Backend(), acc.FallbackReason())
+	}
+	if err := acc.SetBackend("fake"); !errors.Is(err, ErrNoDevices) {
+		t.Errorf("Expected ErrNoDevices for a named backend without devices, got %v", err)
+	}
+
+	gpu := &fakeBackend{devices: []Device{{Index: 0, Type: GPU, Name: "Fake GPU", ComputeUnits: 4}}}
+	useFakeBackend(t, gpu)
+	if err := acc.SetBackend(BackendAuto); err != nil {
+		t.Fatalf("SetBackend(auto) error = %v", err)
+	}
+	if acc.Backend() != "fake" || len(acc.Devices()) != 1 || acc.FallbackReason() != nil {
+		t.Errorf("Expected fake backend with 1 device, got %s with %d", acc.Backend(), len(acc.Devices()))
+	}
+	if stats := acc.GetStats(); stats["backend"] != "fake" || stats["devices"] != 1 {
+		t.Errorf("Unexpected backend stats %v/%v", stats["backend"], stats["devices"])
+	}
+
+	if err := acc.SetBackend(BackendCPU); err != nil {
+		t.Fatalf("SetBackend(cpu) error = %v", err)
+	}
+	if !gpu.closed || acc.Backend() != BackendCPU {
+		t.Error("Expected switching to the CPU to close the device backend")
+	}
+}
+
+func TestMineDevices(t *testing.T) {
+	backend := &fakeBackend{devices: []Device{
+		{Index: 0, Type: GPU, Name: "Fake GPU 0", ComputeUnits: 1},
+		{Index: 1, Type: GPU, Name: "Fake GPU 1", ComputeUnits: 1},
+	}}
+	useFakeBackend(t, backend)
+	acc := NewAccelerator()
+	if err := acc.SetBackend("fake"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := acc.Mine(context.Background(), []byte("test"), 0xFFFFFFFFFFFFFF00)
+	if err != nil {
+		t.Fatalf("Mine() error = %v", err)
+	}
+	if len(result.Workers) != 2 || len(acc.GetWorkerHashRates()) != 2 {
+		t.Errorf("Expected stats for 2 devices, got %d", len(result.Workers))
+	}
+	want := crypto.TetraPoWHash([]byte("test"), result.Nonce)
+	if string(result.Hash) != string(want) {
+		t.Error("Expected the CPU-verified hash of the winning nonce")
+	}
+	if acc.Backend() != "fake" {
+		t.Errorf("Expected the backend to stay in use, got %s", acc.Backend())
+	}
+}
+
+func TestMineRangeDevices(t *testing.T) {
+	backend := &fakeBackend{devices: []Device{{Index: 0, Type: GPU, Name: "Fake GPU", ComputeUnits: 2}}}
+	useFakeBackend(t, backend)
+	acc := NewAccelerator()
+	if err := acc.SetBackend("fake"); err != nil {
+		t.Fatal(err)
+	}
+	if got := acc.BatchSize(); got != 512 {
+		t.Errorf("Expected a 512-nonce batch for 2 balanced compute units, got %d", got)
+	}
+
+	// A zero target is never met, so the range is searched to its end
+	result, err := acc.MineRange(context.Background(), []byte("test"), 0, 5, 7)
+	if !errors.Is(err, crypto.ErrNonceSpaceExhausted) {
+		t.Fatalf("Expected ErrNonceSpaceExhausted, got %v", err)
+	}
+	if result.Hashes() != 3 || acc.Backend() != "fake" {
+		t.Errorf("Expected 3 hashes on the device, got %d on %s", result.Hashes(), acc.Backend())
+	}
+}
+
+func TestMineFallsBackToCPU(t *testing.T) {
+	backend := &fakeBackend{
+		devices: []Device{{Index: 0, Type: GPU, Name: "Fake GPU", ComputeUnits: 1}},
+		fail:    errors.New("device lost"),
+	}
+	useFakeBackend(t, backend)
+	acc := NewAccelerator()
+	acc.hardwareInfo.Cores = 1
+	acc.SetWorkerCount(1)
+	if err := acc.SetBackend("fake"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := acc.Mine(context.Background(), []byte("test"), 0xFFFFFFFFFFFFFF00)
+	if err != nil {
+		t.Fatalf("Mine() error = %v", err)
+	}
+	if len(result.Hash) != 32 {
+		t.Errorf("Expected 32-byte hash, got %d", len(result.Hash))
+	}
+	if acc.Backend() != BackendCPU || !backend.closed {
+		t.Errorf("Expected the failed backend to be closed, got %s", acc.Backend())
+	}
+	if reason := acc.FallbackReason(); reason == nil || !errors.Is(reason, backend.fail) {
+		t.Errorf("Expected the device failure as fallback reason, got %v", reason)
+	}
+}
+
+func TestVerifyNonce(t *testing.T) {
+	if _, err := verifyNonce([]byte("test"), 0, 7); !errors.Is(err, ErrInvalidNonce) {
+		t.Errorf("Expected ErrInvalidNonce, got %v", err)
+	}
+	hash, err := verifyNonce([]byte("test"), ^uint64(0), 7)
+	if err != nil || len(hash) != 32 {
+		t.Errorf("Expected a verified 32-byte hash, got %d bytes (%v)", len(hash), err)
+	}
+}
+
+func TestBatchSize(t *testing.T) {
+	gpu := Device{ComputeUnits: 10}
+	if batchSize(gpu, "power_save") >= batchSize(gpu, "balanced") ||
+		batchSize(gpu, "balanced") >= batchSize(gpu, "extreme") {
+		t.Error("Expected batch size to grow with the optimization mode")
+	}
+	if batchSize(Device{}, "balanced") == 0 {
+		t.Error("Expected a nonzero batch for a device without compute unit info")
+	}
+}
+
 func BenchmarkEstimateHashRate(b *testing.B) {
 	acc := NewAccelerator()
 	
@@ -325,3 +571,112 @@ func BenchmarkGetStats(b *testing.B) {
 		acc.GetStats()
 	}
 }
+
+func TestCalibrate(t *testing.T) {
+	c, err := Calibrate(context.Background(), time.Millisecond)
+	if err != nil {
+		t.Fatalf("Calibrate failed: %v", err)
+	}
+	if c.Cores != runtime.NumCPU() || c.Hashes < uint64(c.Cores) {
+		t.Errorf("Expected at least one hash on each of %d cores, got %d on %d", runtime.NumCPU(), c.Hashes, c.Cores)
+	}
+	if c.PerCoreHashRate <= 0 {
+		t.Errorf("Expected a positive per-core hash rate, got %f", c.PerCoreHashRate)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Calibrate(ctx, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSetCalibration(t *testing.T) {
+	acc := NewAccelerator()
+	info := acc.GetHardwareInfo()
+
+	other := &Calibration{Arch: info.Name, Cores: info.Cores + 1, PerCoreHashRate: 6}
+	if err := acc.SetCalibration(other); !errors.Is(err, ErrCalibrationMismatch) {
+		t.Errorf("Expected ErrCalibrationMismatch, got %v", err)
+	}
+	if acc.Calibration() != nil {
+		t.Error("A rejected calibration should not be kept")
+	}
+
+	c := &Calibration{Arch: info.Name, Cores: info.Cores, PerCoreHashRate: 6}
+	if err := acc.SetCalibration(c); err != nil {
+		t.Fatalf("SetCalibration failed: %v", err)
+	}
+	if got := acc.GetHardwareInfo().MaxHashRate; got != c.HashRate() {
+		t.Errorf("Expected MaxHashRate %f, got %f", c.HashRate(), got)
+	}
+	if acc.SetOptimization("balanced"); acc.EstimateHashRate() != c.HashRate() {
+		t.Errorf("Expected balanced hash rate %f, got %f", c.HashRate(), acc.EstimateHashRate())
+	}
+	if acc.SetOptimization("extreme"); acc.EstimateHashRate() != c.HashRate() {
+		t.Errorf("Expected extra workers to add nothing to %f, got %f", c.HashRate(), acc.EstimateHashRate())
+	}
+	if acc.GetStats()["calibrated"] != true {
+		t.Error("Expected calibrated stat to be true")
+	}
+
+	want := ExpectedBlockTime(1<<60, c.HashRate())
+	if got := acc.EstimateBlockTime(1 << 60); got != want {
+		t.Errorf("Expected block time %v, got %v", want, got)
+	}
+}
+
+func TestCalibrationSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "calibration.json")
+	if _, err := LoadCalibration(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", err)
+	}
+
+	c := &Calibration{Arch: "amd64", Cores: 4, Hashes: 120, Duration: 5 * time.Second, PerCoreHashRate: 6, MeasuredAt: time.Now().UTC()}
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadCalibration(path)
+	if err != nil {
+		t.Fatalf("LoadCalibration failed: %v", err)
+	}
+	if loaded.PerCoreHashRate != 6 || loaded.Cores != 4 || !loaded.MeasuredAt.Equal(c.MeasuredAt) {
+		t.Errorf("Loaded %+v, saved %+v", loaded, c)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"cores": 4}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCalibration(path); err == nil {
+		t.Error("Expected an error for a calibration without a hash rate")
+	}
+}
+
+func TestExpectedBlockTime(t *testing.T) {
+	if got := ExpectedHashes(1 << 60); got != 16 {
+		t.Errorf("Expected 16 hashes, got %f", got)
+	}
+	if got := ExpectedBlockTime(1<<60, 8); got != 2*time.Second {
+		t.Errorf("Expected 2s, got %v", got)
+	}
+	if got := ExpectedBlockTime(1<<60, 0); got >= 0 {
+		t.Errorf("Expected a negative time without a hash rate, got %v", got)
+	}
+	if got := ExpectedBlockTime(1, 1); got >= 0 {
+		t.Errorf("Expected a negative time past the duration range, got %v", got)
+	}
+}
+
+func TestFormatBlockTime(t *testing.T) {
+	tests := map[time.Duration]string{
+		-1:                     "never",
+		time.Millisecond:       "< 1s",
+		90*time.Minute + 400e6: "1h30m0s",
+		72 * time.Hour:         "3.0 days",
+	}
+	for d, want := range tests {
+		if got := FormatBlockTime(d); got != want {
+			t.Errorf("FormatBlockTime(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
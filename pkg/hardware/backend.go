@@ -0,0 +1,239 @@
+package hardware
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// Backend names accepted by SetBackend besides the registered backends
+const (
+	// BackendAuto uses the first registered backend with a device, or the CPU
+	BackendAuto = "auto"
+	// BackendCPU mines with crypto.ParallelTetraPoW on the CPU cores
+	BackendCPU = "cpu"
+)
+
+var (
+	// ErrUnknownBackend indicates a backend that was not compiled in
+	ErrUnknownBackend = errors.New("unknown accelerator backend")
+	// ErrNoDevices indicates a backend found no device to mine on
+	ErrNoDevices = errors.New("no accelerator devices found")
+	// ErrInvalidNonce indicates a device reported a nonce that does not
+	// meet the target when rehashed on the CPU
+	ErrInvalidNonce = errors.New("device returned an invalid nonce")
+)
+
+// Device is one compute device of a backend
+type Device struct {
+	Index        int
+	Type         HardwareType
+	Name         string
+	Vendor       string
+	ComputeUnits int
+	Memory       uint64 // In bytes
+	ClockMHz     int
+}
+
+// Batch is the outcome of searching one nonce range on a device
+type Batch struct {
+	Found  bool
+	Nonce  uint64
+	Hashes uint64
+}
+
+// Backend hashes Tetra-PoW nonces on accelerator devices. The CPU is not a
+// backend; the Accelerator falls back to it whenever a backend fails.
+type Backend interface {
+	// Name identifies the backend, e.g. "opencl"
+	Name() string
+	// Devices lists the devices Search can use
+	Devices() []Device
+	// Search hashes data with nonces start to start+count-1 on device and
+	// reports the lowest nonce meeting difficulty, if any. A running batch
+	// is not interrupted by ctx.
+	Search(ctx context.Context, device int, data []byte, difficulty uint64, start, count uint64) (Batch, error)
+	// Close releases the devices
+	Close() error
+}
+
+var (
+	backendsMu sync.Mutex
+	backends   = make(map[string]func() (Backend, error))
+)
+
+// registerBackend makes a backend available to SetBackend; backends built
+// under a tag register themselves from init
+func registerBackend(name string, open func() (Backend, error)) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = open
+}
+
+// Backends returns the names of the backends compiled into this binary
+func Backends() []string {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenBackend opens a registered backend, failing with ErrNoDevices when it
+// has nothing to mine on
+func OpenBackend(name string) (Backend, error) {
+	backendsMu.Lock()
+	open, ok := backends[name]
+	backendsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
+	}
+
+	backend, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(backend.Devices()) == 0 {
+		backend.Close()
+		return nil, fmt.Errorf("%s: %w", name, ErrNoDevices)
+	}
+	return backend, nil
+}
+
+// batchSize is the number of nonces dispatched to a device at once,
+// scaled by the optimization mode so power_save returns to the host often
+// and extreme keeps the device saturated
+func batchSize(device Device, optimization string) uint64 {
+	perUnit := map[string]uint64{
+		"power_save":  64,
+		"balanced":    256,
+		"performance": 1024,
+		"extreme":     4096,
+	}[optimization]
+	if perUnit == 0 {
+		perUnit = 256
+	}
+	return uint64(max(device.ComputeUnits, 1)) * perUnit
+}
+
+// mineDevices searches the nonces first to last with every device of
+// backend, each taking the next batch of nonces as it finishes one. Nonces
+// a device reports are rehashed on the CPU before they are returned. When
+// no nonce is found for want of nonces or time, the worker stats are
+// returned with the error.
+func mineDevices(ctx context.Context, backend Backend, data []byte, difficulty uint64, optimization string, first, last uint64) (*crypto.ParallelResult, error) {
+	devices := backend.Devices()
+	searchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		next      = first
+		exhausted bool
+		result    *crypto.ParallelResult
+		firstErr  error
+		wg        sync.WaitGroup
+	)
+	stats := make([]crypto.WorkerStats, len(devices))
+
+	// claim reserves the next count nonces, fewer at the end of the space
+	claim := func(count uint64) (uint64, uint64, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if exhausted {
+			return 0, 0, false
+		}
+		start := next
+		if count > last-start {
+			count = last - start + 1
+			exhausted = true
+		}
+		next = start + count
+		return start, count, true
+	}
+
+	for i, device := range devices {
+		wg.Add(1)
+		go func(worker int, device Device) {
+			defer wg.Done()
+
+			began := time.Now()
+			stats[worker] = crypto.WorkerStats{Worker: worker}
+			defer func() {
+				elapsed := time.Since(began)
+				stats[worker].Duration = elapsed
+				if elapsed > 0 {
+					stats[worker].HashRate = float64(stats[worker].Hashes) / elapsed.Seconds()
+				}
+			}()
+
+			size := batchSize(device, optimization)
+			for first := true; searchCtx.Err() == nil; first = false {
+				start, count, ok := claim(size)
+				if !ok {
+					return
+				}
+				if first {
+					stats[worker].Start = start
+				}
+
+				batch, err := backend.Search(searchCtx, device.Index, data, difficulty, start, count)
+				stats[worker].Hashes += batch.Hashes
+				if err == nil && batch.Found {
+					var hash []byte
+					hash, err = verifyNonce(data, difficulty, batch.Nonce)
+					if err == nil {
+						mu.Lock()
+						if result == nil {
+							result = &crypto.ParallelResult{Nonce: batch.Nonce, Hash: hash, Worker: worker}
+						}
+						mu.Unlock()
+						cancel()
+						return
+					}
+				}
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil && searchCtx.Err() == nil {
+						firstErr = fmt.Errorf("%s device %d (%s): %w", backend.Name(), device.Index, device.Name, err)
+					}
+					mu.Unlock()
+					cancel()
+					return
+				}
+			}
+		}(i, device)
+	}
+	wg.Wait()
+
+	if result != nil {
+		result.Workers = stats
+		return result, nil
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return &crypto.ParallelResult{Workers: stats}, err
+	}
+	return &crypto.ParallelResult{Workers: stats}, crypto.ErrNonceSpaceExhausted
+}
+
+// verifyNonce rehashes a device's nonce on the CPU, guarding against a
+// miscompiled kernel or faulty device
+func verifyNonce(data []byte, difficulty uint64, nonce uint64) ([]byte, error) {
+	hash := crypto.TetraPoWHash(data, nonce)
+	if binary.LittleEndian.Uint64(hash[0:8]) >= difficulty {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidNonce, nonce)
+	}
+	return hash, nil
+}
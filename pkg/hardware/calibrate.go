@@ -0,0 +1,167 @@
+package hardware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// DefaultCalibrationTime is how long Calibrate hashes unless told otherwise;
+// a Tetra-PoW hash takes a sizeable fraction of a second on one core, so
+// shorter runs measure too few hashes
+const DefaultCalibrationTime = 5 * time.Second
+
+// ErrCalibrationMismatch indicates a calibration measured on other hardware
+var ErrCalibrationMismatch = errors.New("calibration was measured on different hardware")
+
+// Calibration is a measured CPU Tetra-PoW throughput
+type Calibration struct {
+	Arch            string        `json:"arch"`
+	Cores           int           `json:"cores"`
+	Hashes          uint64        `json:"hashes"`
+	Duration        time.Duration `json:"duration"`
+	PerCoreHashRate float64       `json:"per_core_hashrate"` // H/s
+	MeasuredAt      time.Time     `json:"measured_at"`
+}
+
+// Calibrate runs full Tetra-PoW hashes on every core for about duration and
+// measures the per-core throughput. Every core finishes at least one hash,
+// so a cancelled ctx still yields a measurement unless it was cancelled
+// before any hash started.
+func Calibrate(ctx context.Context, duration time.Duration) (*Calibration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cores := runtime.NumCPU()
+	deadline := time.Now().Add(duration)
+	data := []byte("Excalibur-EXS-Calibration")
+
+	var (
+		hashes atomic.Uint64
+		wg     sync.WaitGroup
+	)
+	began := time.Now()
+	for i := 0; i < cores; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for nonce := uint64(worker) << 32; ; nonce++ {
+				crypto.TetraPoWHash(data, nonce)
+				hashes.Add(1)
+				if ctx.Err() != nil || time.Now().After(deadline) {
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(began)
+
+	c := &Calibration{
+		Arch:       runtime.GOARCH,
+		Cores:      cores,
+		Hashes:     hashes.Load(),
+		Duration:   elapsed,
+		MeasuredAt: time.Now().UTC(),
+	}
+	c.PerCoreHashRate = float64(c.Hashes) / elapsed.Seconds() / float64(cores)
+	return c, nil
+}
+
+// HashRate is the calibrated rate of all cores in H/s
+func (c *Calibration) HashRate() float64 {
+	return c.PerCoreHashRate * float64(c.Cores)
+}
+
+// Matches reports whether the calibration was measured on info's hardware
+func (c *Calibration) Matches(info HardwareInfo) bool {
+	return c.Arch == info.Name && c.Cores == info.Cores
+}
+
+// DefaultCalibrationPath returns where the miner keeps its calibration,
+// $HOME/.excalibur-exs/calibration.json
+func DefaultCalibrationPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	return filepath.Join(home, ".excalibur-exs", "calibration.json"), nil
+}
+
+// LoadCalibration reads a calibration saved by Save; a missing file is
+// reported as os.ErrNotExist
+func LoadCalibration(path string) (*Calibration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Calibration
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse calibration %s: %w", path, err)
+	}
+	if c.Cores < 1 || c.PerCoreHashRate <= 0 {
+		return nil, fmt.Errorf("calibration %s holds no measurement", path)
+	}
+	return &c, nil
+}
+
+// Save writes the calibration to path, creating its directory
+func (c *Calibration) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create calibration directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write calibration: %w", err)
+	}
+	return nil
+}
+
+// ExpectedHashes is the mean number of hashes to find a nonce below
+// difficulty: each hash meets it with probability difficulty/2^64
+func ExpectedHashes(difficulty uint64) float64 {
+	if difficulty == 0 {
+		return math.Inf(1)
+	}
+	return math.Exp2(64) / float64(difficulty)
+}
+
+// ExpectedBlockTime is the mean time to find a nonce below difficulty at
+// hashRate H/s, or a negative duration if it cannot be represented
+func ExpectedBlockTime(difficulty uint64, hashRate float64) time.Duration {
+	if hashRate <= 0 {
+		return -1
+	}
+	seconds := ExpectedHashes(difficulty) / hashRate
+	if seconds >= math.MaxInt64/float64(time.Second) {
+		return -1
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// FormatBlockTime renders an ExpectedBlockTime, "never" when it is negative
+func FormatBlockTime(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "never"
+	case d < time.Second:
+		return "< 1s"
+	case d < 48*time.Hour:
+		return d.Round(time.Second).String()
+	default:
+		return fmt.Sprintf("%.1f days", d.Hours()/24)
+	}
+}
@@ -0,0 +1,326 @@
+//go:build opencl
+
+package hardware
+
+/*
+#cgo linux LDFLAGS: -lOpenCL
+#cgo windows LDFLAGS: -lOpenCL
+#cgo darwin LDFLAGS: -framework OpenCL
+#define CL_TARGET_OPENCL_VERSION 120
+#include <stdlib.h>
+#ifdef __APPLE__
+#include <OpenCL/opencl.h>
+#else
+#include <CL/cl.h>
+#endif
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// BackendOpenCL is the name of the OpenCL backend, compiled in with the
+// opencl build tag
+const BackendOpenCL = "opencl"
+
+// maxPlatforms and maxDevices bound OpenCL enumeration
+const (
+	maxPlatforms = 16
+	maxDevices   = 64
+)
+
+func init() {
+	registerBackend(BackendOpenCL, openOpenCL)
+}
+
+// clError describes an OpenCL status code
+type clError struct {
+	call   string
+	status C.cl_int
+}
+
+func (e clError) Error() string {
+	return fmt.Sprintf("%s failed with OpenCL error %d", e.call, int(e.status))
+}
+
+// check turns a non-success status into an error
+func check(call string, status C.cl_int) error {
+	if status != C.CL_SUCCESS {
+		return clError{call, status}
+	}
+	return nil
+}
+
+// openclDevice is one GPU or accelerator with its compiled kernel, built
+// on first use
+type openclDevice struct {
+	mu      sync.Mutex
+	id      C.cl_device_id
+	info    Device
+	ctx     C.cl_context
+	queue   C.cl_command_queue
+	program C.cl_program
+	kernel  C.cl_kernel
+	salt    C.cl_mem
+	found   C.cl_mem
+}
+
+// openclBackend mines on every OpenCL GPU and accelerator device
+type openclBackend struct {
+	devices []*openclDevice
+}
+
+// openOpenCL enumerates the GPU and accelerator devices of every platform
+func openOpenCL() (Backend, error) {
+	var platforms [maxPlatforms]C.cl_platform_id
+	var numPlatforms C.cl_uint
+	// Without an installed driver the ICD loader reports an error rather
+	// than zero platforms
+	if err := check("clGetPlatformIDs", C.clGetPlatformIDs(maxPlatforms, &platforms[0], &numPlatforms)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoDevices, err)
+	}
+
+	b := &openclBackend{}
+	for _, platform := range platforms[:min(int(numPlatforms), maxPlatforms)] {
+		var ids [maxDevices]C.cl_device_id
+		var n C.cl_uint
+		status := C.clGetDeviceIDs(platform, C.CL_DEVICE_TYPE_GPU|C.CL_DEVICE_TYPE_ACCELERATOR, maxDevices, &ids[0], &n)
+		if status == C.CL_DEVICE_NOT_FOUND {
+			continue
+		}
+		if err := check("clGetDeviceIDs", status); err != nil {
+			return nil, err
+		}
+		for _, id := range ids[:min(int(n), maxDevices)] {
+			info := deviceInfo(id)
+			info.Index = len(b.devices)
+			b.devices = append(b.devices, &openclDevice{id: id, info: info})
+		}
+	}
+	return b, nil
+}
+
+// deviceInfo reads the properties shown by miner hwinfo
+func deviceInfo(id C.cl_device_id) Device {
+	info := Device{
+		Type:         GPU,
+		Name:         deviceString(id, C.CL_DEVICE_NAME),
+		Vendor:       deviceString(id, C.CL_DEVICE_VENDOR),
+		ComputeUnits: int(deviceUint(id, C.CL_DEVICE_MAX_COMPUTE_UNITS)),
+		ClockMHz:     int(deviceUint(id, C.CL_DEVICE_MAX_CLOCK_FREQUENCY)),
+	}
+	var deviceType C.cl_device_type
+	if C.clGetDeviceInfo(id, C.CL_DEVICE_TYPE, C.size_t(unsafe.Sizeof(deviceType)), unsafe.Pointer(&deviceType), nil) == C.CL_SUCCESS &&
+		deviceType&C.CL_DEVICE_TYPE_ACCELERATOR != 0 {
+		info.Type = FPGA
+	}
+	var memory C.cl_ulong
+	if C.clGetDeviceInfo(id, C.CL_DEVICE_GLOBAL_MEM_SIZE, C.size_t(unsafe.Sizeof(memory)), unsafe.Pointer(&memory), nil) == C.CL_SUCCESS {
+		info.Memory = uint64(memory)
+	}
+	return info
+}
+
+func deviceString(id C.cl_device_id, param C.cl_device_info) string {
+	var buf [256]C.char
+	if C.clGetDeviceInfo(id, param, C.size_t(len(buf)), unsafe.Pointer(&buf[0]), nil) != C.CL_SUCCESS {
+		return ""
+	}
+	return strings.TrimSpace(C.GoString(&buf[0]))
+}
+
+func deviceUint(id C.cl_device_id, param C.cl_device_info) C.cl_uint {
+	var v C.cl_uint
+	C.clGetDeviceInfo(id, param, C.size_t(unsafe.Sizeof(v)), unsafe.Pointer(&v), nil)
+	return v
+}
+
+func (b *openclBackend) Name() string {
+	return BackendOpenCL
+}
+
+func (b *openclBackend) Devices() []Device {
+	devices := make([]Device, len(b.devices))
+	for i, d := range b.devices {
+		devices[i] = d.info
+	}
+	return devices
+}
+
+func (b *openclBackend) Search(ctx context.Context, device int, data []byte, difficulty uint64, start, count uint64) (Batch, error) {
+	if device < 0 || device >= len(b.devices) {
+		return Batch{}, fmt.Errorf("no OpenCL device %d", device)
+	}
+	if err := ctx.Err(); err != nil {
+		return Batch{}, err
+	}
+	return b.devices[device].search(data, difficulty, start, count)
+}
+
+func (b *openclBackend) Close() error {
+	for _, d := range b.devices {
+		d.mu.Lock()
+		d.release()
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+// build creates the device's context, queue and kernel; callers must hold
+// d.mu
+func (d *openclDevice) build() error {
+	if d.kernel != nil {
+		return nil
+	}
+	var status C.cl_int
+	d.ctx = C.clCreateContext(nil, 1, &d.id, nil, nil, &status)
+	if err := check("clCreateContext", status); err != nil {
+		return err
+	}
+	d.queue = C.clCreateCommandQueue(d.ctx, d.id, 0, &status)
+	if err := check("clCreateCommandQueue", status); err != nil {
+		d.release()
+		return err
+	}
+
+	source := C.CString(tetraPoWKernel)
+	defer C.free(unsafe.Pointer(source))
+	d.program = C.clCreateProgramWithSource(d.ctx, 1, &source, nil, &status)
+	if err := check("clCreateProgramWithSource", status); err != nil {
+		d.release()
+		return err
+	}
+	if status = C.clBuildProgram(d.program, 1, &d.id, nil, nil, nil); status != C.CL_SUCCESS {
+		var buf [4096]C.char
+		C.clGetProgramBuildInfo(d.program, d.id, C.CL_PROGRAM_BUILD_LOG, C.size_t(len(buf)), unsafe.Pointer(&buf[0]), nil)
+		d.release()
+		return fmt.Errorf("kernel build failed: %s", strings.TrimSpace(C.GoString(&buf[0])))
+	}
+	name := C.CString("tetrapow_search")
+	defer C.free(unsafe.Pointer(name))
+	d.kernel = C.clCreateKernel(d.program, name, &status)
+	if err := check("clCreateKernel", status); err != nil {
+		d.release()
+		return err
+	}
+
+	// The kernel hashes salt||INT(1) in a single padded block
+	salt := []byte(crypto.DefaultSalt)
+	if len(salt)+4 > 55 {
+		d.release()
+		return fmt.Errorf("HPP-1 salt of %d bytes is too long for the kernel", len(salt))
+	}
+	d.salt = C.clCreateBuffer(d.ctx, C.CL_MEM_READ_ONLY|C.CL_MEM_COPY_HOST_PTR, C.size_t(len(salt)), unsafe.Pointer(&salt[0]), &status)
+	if err := check("clCreateBuffer", status); err != nil {
+		d.release()
+		return err
+	}
+	d.found = C.clCreateBuffer(d.ctx, C.CL_MEM_READ_WRITE, C.size_t(unsafe.Sizeof(C.cl_uint(0))), nil, &status)
+	if err := check("clCreateBuffer", status); err != nil {
+		d.release()
+		return err
+	}
+	return nil
+}
+
+// search runs one batch of count nonces from start
+func (d *openclDevice) search(data []byte, difficulty uint64, start, count uint64) (Batch, error) {
+	if count > 1<<32-1 {
+		count = 1<<32 - 1
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.build(); err != nil {
+		return Batch{}, err
+	}
+
+	// OpenCL buffers cannot be empty, so data carries a spare byte
+	padded := append(data[:len(data):len(data)], 0)
+	var status C.cl_int
+	input := C.clCreateBuffer(d.ctx, C.CL_MEM_READ_ONLY|C.CL_MEM_COPY_HOST_PTR, C.size_t(len(padded)), unsafe.Pointer(&padded[0]), &status)
+	if err := check("clCreateBuffer", status); err != nil {
+		return Batch{}, err
+	}
+	defer C.clReleaseMemObject(input)
+
+	found := C.cl_uint(^uint32(0))
+	if err := check("clEnqueueWriteBuffer", C.clEnqueueWriteBuffer(d.queue, d.found, C.CL_TRUE, 0,
+		C.size_t(unsafe.Sizeof(found)), unsafe.Pointer(&found), 0, nil, nil)); err != nil {
+		return Batch{}, err
+	}
+
+	dataLen := C.cl_uint(len(data))
+	saltLen := C.cl_uint(len(crypto.DefaultSalt))
+	iterations := C.cl_uint(crypto.HPP1Rounds)
+	first := C.cl_ulong(start)
+	target := C.cl_ulong(difficulty)
+	args := []struct {
+		size  uintptr
+		value unsafe.Pointer
+	}{
+		{unsafe.Sizeof(input), unsafe.Pointer(&input)},
+		{unsafe.Sizeof(dataLen), unsafe.Pointer(&dataLen)},
+		{unsafe.Sizeof(d.salt), unsafe.Pointer(&d.salt)},
+		{unsafe.Sizeof(saltLen), unsafe.Pointer(&saltLen)},
+		{unsafe.Sizeof(iterations), unsafe.Pointer(&iterations)},
+		{unsafe.Sizeof(first), unsafe.Pointer(&first)},
+		{unsafe.Sizeof(target), unsafe.Pointer(&target)},
+		{unsafe.Sizeof(d.found), unsafe.Pointer(&d.found)},
+	}
+	for i, arg := range args {
+		if err := check("clSetKernelArg", C.clSetKernelArg(d.kernel, C.cl_uint(i), C.size_t(arg.size), arg.value)); err != nil {
+			return Batch{}, err
+		}
+	}
+
+	global := C.size_t(count)
+	if err := check("clEnqueueNDRangeKernel", C.clEnqueueNDRangeKernel(d.queue, d.kernel, 1, nil, &global, nil, 0, nil, nil)); err != nil {
+		return Batch{}, err
+	}
+	if err := check("clEnqueueReadBuffer", C.clEnqueueReadBuffer(d.queue, d.found, C.CL_TRUE, 0,
+		C.size_t(unsafe.Sizeof(found)), unsafe.Pointer(&found), 0, nil, nil)); err != nil {
+		return Batch{}, err
+	}
+
+	batch := Batch{Hashes: count}
+	if uint32(found) != ^uint32(0) {
+		batch.Found, batch.Nonce = true, start+uint64(found)
+	}
+	return batch, nil
+}
+
+// release frees whatever build created; callers must hold d.mu
+func (d *openclDevice) release() {
+	if d.found != nil {
+		C.clReleaseMemObject(d.found)
+		d.found = nil
+	}
+	if d.salt != nil {
+		C.clReleaseMemObject(d.salt)
+		d.salt = nil
+	}
+	if d.kernel != nil {
+		C.clReleaseKernel(d.kernel)
+		d.kernel = nil
+	}
+	if d.program != nil {
+		C.clReleaseProgram(d.program)
+		d.program = nil
+	}
+	if d.queue != nil {
+		C.clReleaseCommandQueue(d.queue)
+		d.queue = nil
+	}
+	if d.ctx != nil {
+		C.clReleaseContext(d.ctx)
+		d.ctx = nil
+	}
+}
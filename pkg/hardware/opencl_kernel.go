@@ -0,0 +1,230 @@
+//go:build opencl
+
+package hardware
+
+// tetraPoWKernel computes crypto.TetraPoWHash for one nonce per work-item:
+// HPP-1 (PBKDF2-HMAC-SHA256, one 32-byte block) over data||nonce, then the
+// 128 Tetra-PoW rounds. Work-items whose hash meets the difficulty
+// atomically lower *found to their offset from start, so the host reads the
+// lowest winning nonce of the batch.
+const tetraPoWKernel = `
+#define ROTR(x, n) (((x) >> (n)) | ((x) << (32 - (n))))
+#define CH(x, y, z) (((x) & (y)) ^ (~(x) & (z)))
+#define MAJ(x, y, z) (((x) & (y)) ^ ((x) & (z)) ^ ((y) & (z)))
+#define EP0(x) (ROTR(x, 2) ^ ROTR(x, 13) ^ ROTR(x, 22))
+#define EP1(x) (ROTR(x, 6) ^ ROTR(x, 11) ^ ROTR(x, 25))
+#define SIG0(x) (ROTR(x, 7) ^ ROTR(x, 18) ^ ((x) >> 3))
+#define SIG1(x) (ROTR(x, 17) ^ ROTR(x, 19) ^ ((x) >> 10))
+
+__constant uint K[64] = {
+	0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+	0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+	0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+	0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+	0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+	0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+	0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+	0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2
+};
+
+__constant uint IV[8] = {
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19
+};
+
+// sha256_block compresses one block of 16 big-endian words into state
+void sha256_block(uint *state, const uint *block) {
+	uint w[64];
+	for (int i = 0; i < 16; i++) {
+		w[i] = block[i];
+	}
+	for (int i = 16; i < 64; i++) {
+		w[i] = SIG1(w[i - 2]) + w[i - 7] + SIG0(w[i - 15]) + w[i - 16];
+	}
+
+	uint a = state[0], b = state[1], c = state[2], d = state[3];
+	uint e = state[4], f = state[5], g = state[6], h = state[7];
+	for (int i = 0; i < 64; i++) {
+		uint t1 = h + EP1(e) + CH(e, f, g) + K[i] + w[i];
+		uint t2 = EP0(a) + MAJ(a, b, c);
+		h = g; g = f; f = e; e = d + t1;
+		d = c; c = b; b = a; a = t1 + t2;
+	}
+	state[0] += a; state[1] += b; state[2] += c; state[3] += d;
+	state[4] += e; state[5] += f; state[6] += g; state[7] += h;
+}
+
+// message_byte returns byte i of data||nonce, the nonce little-endian
+uchar message_byte(__global const uchar *data, uint len, ulong nonce, uint i) {
+	if (i < len) {
+		return data[i];
+	}
+	return (uchar)(nonce >> (8 * (i - len)));
+}
+
+// hmac_key fills key with the HMAC-SHA256 key block for data||nonce:
+// the message itself when it fits a block, else its SHA-256
+void hmac_key(__global const uchar *data, uint len, ulong nonce, uint *key) {
+	uint msg_len = len + 8;
+	if (msg_len <= 64) {
+		for (int i = 0; i < 16; i++) {
+			uint word = 0;
+			for (int j = 0; j < 4; j++) {
+				uint p = 4 * i + j;
+				word = (word << 8) | (p < msg_len ? message_byte(data, len, nonce, p) : 0);
+			}
+			key[i] = word;
+		}
+		return;
+	}
+
+	uint state[8];
+	for (int i = 0; i < 8; i++) {
+		state[i] = IV[i];
+	}
+	ulong bits = (ulong)msg_len * 8;
+	uint padded = ((msg_len + 9 + 63) / 64) * 64;
+	uint block[16];
+	for (uint offset = 0; offset < padded; offset += 64) {
+		for (int i = 0; i < 16; i++) {
+			uint word = 0;
+			for (int j = 0; j < 4; j++) {
+				uint p = offset + 4 * i + j;
+				uchar b = 0;
+				if (p < msg_len) {
+					b = message_byte(data, len, nonce, p);
+				} else if (p == msg_len) {
+					b = 0x80;
+				} else if (p >= padded - 8) {
+					b = (uchar)(bits >> (8 * (padded - 1 - p)));
+				}
+				word = (word << 8) | b;
+			}
+			block[i] = word;
+		}
+		sha256_block(state, block);
+	}
+	for (int i = 0; i < 8; i++) {
+		key[i] = state[i];
+	}
+	for (int i = 8; i < 16; i++) {
+		key[i] = 0;
+	}
+}
+
+// hmac_digest finishes HMAC of a 32-byte message from precomputed inner
+// and outer pad states
+void hmac_digest(const uint *inner, const uint *outer, const uint *msg, uint *out) {
+	uint state[8];
+	uint block[16];
+	for (int i = 0; i < 8; i++) {
+		state[i] = inner[i];
+		block[i] = msg[i];
+	}
+	block[8] = 0x80000000;
+	for (int i = 9; i < 15; i++) {
+		block[i] = 0;
+	}
+	block[15] = (64 + 32) * 8;
+	sha256_block(state, block);
+
+	for (int i = 0; i < 8; i++) {
+		block[i] = state[i];
+		out[i] = outer[i];
+	}
+	sha256_block(out, block);
+}
+
+__kernel void tetrapow_search(__global const uchar *data, uint data_len,
+		__global const uchar *salt, uint salt_len, uint iterations,
+		ulong start, ulong difficulty, __global uint *found) {
+	uint gid = get_global_id(0);
+	ulong nonce = start + gid;
+
+	uint key[16];
+	hmac_key(data, data_len, nonce, key);
+
+	uint inner[8], outer[8], block[16];
+	for (int i = 0; i < 8; i++) {
+		inner[i] = IV[i];
+		outer[i] = IV[i];
+	}
+	for (int i = 0; i < 16; i++) {
+		block[i] = key[i] ^ 0x36363636;
+	}
+	sha256_block(inner, block);
+	for (int i = 0; i < 16; i++) {
+		block[i] = key[i] ^ 0x5c5c5c5c;
+	}
+	sha256_block(outer, block);
+
+	// U1 = HMAC(salt || INT(1)); the host keeps salt_len + 4 below 56
+	uint state[8];
+	for (int i = 0; i < 8; i++) {
+		state[i] = inner[i];
+	}
+	uint msg_len = salt_len + 4;
+	for (int i = 0; i < 16; i++) {
+		uint word = 0;
+		for (int j = 0; j < 4; j++) {
+			uint p = 4 * i + j;
+			uchar b = 0;
+			if (p < salt_len) {
+				b = salt[p];
+			} else if (p == salt_len + 3) {
+				b = 1;
+			} else if (p == msg_len) {
+				b = 0x80;
+			}
+			word = (word << 8) | b;
+		}
+		block[i] = word;
+	}
+	block[15] = (64 + msg_len) * 8;
+	sha256_block(state, block);
+	for (int i = 0; i < 8; i++) {
+		block[i] = state[i];
+		state[i] = outer[i];
+	}
+	block[8] = 0x80000000;
+	for (int i = 9; i < 15; i++) {
+		block[i] = 0;
+	}
+	block[15] = (64 + 32) * 8;
+	sha256_block(state, block);
+
+	uint u[8], t[8];
+	for (int i = 0; i < 8; i++) {
+		u[i] = state[i];
+		t[i] = state[i];
+	}
+	for (uint n = 1; n < iterations; n++) {
+		hmac_digest(inner, outer, u, u);
+		for (int i = 0; i < 8; i++) {
+			t[i] ^= u[i];
+		}
+	}
+
+	// Tetra-PoW state words are the derived key read little-endian
+	ulong s[4];
+	for (int i = 0; i < 4; i++) {
+		uint lo = t[2 * i], hi = t[2 * i + 1];
+		lo = (lo >> 24) | ((lo >> 8) & 0xff00) | ((lo << 8) & 0xff0000) | (lo << 24);
+		hi = (hi >> 24) | ((hi >> 8) & 0xff00) | ((hi << 8) & 0xff0000) | (hi << 24);
+		s[i] = (ulong)lo | ((ulong)hi << 32);
+	}
+	for (int r = 0; r < 128; r++) {
+		s[0] = s[0] ^ (s[1] << 13) ^ (s[3] >> 7);
+		s[1] = s[1] ^ (s[2] << 17) ^ (s[0] >> 5);
+		s[2] = s[2] ^ (s[3] << 23) ^ (s[1] >> 11);
+		s[3] = s[3] ^ (s[0] << 29) ^ (s[2] >> 3);
+		s[0] += 0x9E3779B97F4A7C15UL;
+		s[1] += 0x243F6A8885A308D3UL;
+		s[2] += 0x13198A2E03707344UL;
+		s[3] += 0xA4093822299F31D0UL;
+	}
+
+	if (s[0] < difficulty) {
+		atomic_min(found, gid);
+	}
+}
+`
@@ -0,0 +1,448 @@
+package hardware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Thermal monitoring. A Sensor reads CPU temperatures and clock speeds from
+// sysfs on Linux, from lm-sensors where sysfs has none, or from powermetrics
+// on macOS. An accelerator given a thermal limit halves its CPU workers at
+// every reading at or above the limit and doubles them back once the
+// temperature falls Hysteresis below it. Device backends manage their own
+// clocks and are not throttled.
+
+const (
+	// DefaultThermalLimit is the temperature in °C at which mining backs
+	// off, below the 95-105 °C at which most CPUs throttle themselves
+	DefaultThermalLimit = 85.0
+	// DefaultThermalHysteresis is how far below the limit, in °C, the
+	// temperature must fall before throttled workers are restored
+	DefaultThermalHysteresis = 5.0
+	// DefaultThermalInterval is the time between sensor readings
+	DefaultThermalInterval = 5 * time.Second
+)
+
+// ErrNoSensor indicates that no temperature sensor could be read
+var ErrNoSensor = errors.New("no temperature sensor")
+
+// Temperature is one sensor's reading
+type Temperature struct {
+	Label   string
+	Celsius float64
+}
+
+// ThermalReading is a snapshot of CPU temperatures and clock speed
+type ThermalReading struct {
+	Temperatures []Temperature
+	// FrequencyMHz is the mean current clock across cores, 0 if unknown
+	FrequencyMHz float64
+	// MaxFrequencyMHz is the highest clock any core can run at, 0 if
+	// unknown
+	MaxFrequencyMHz float64
+	Time            time.Time
+}
+
+// Hottest returns the highest temperature in the reading
+func (r ThermalReading) Hottest() float64 {
+	hottest := 0.0
+	for i, t := range r.Temperatures {
+		if i == 0 || t.Celsius > hottest {
+			hottest = t.Celsius
+		}
+	}
+	return hottest
+}
+
+// String summarizes the reading on one line
+func (r ThermalReading) String() string {
+	s := fmt.Sprintf("%.1f °C", r.Hottest())
+	if r.FrequencyMHz > 0 {
+		s += fmt.Sprintf(", %.0f MHz", r.FrequencyMHz)
+		if r.MaxFrequencyMHz > 0 {
+			s += fmt.Sprintf(" of %.0f", r.MaxFrequencyMHz)
+		}
+	}
+	return s
+}
+
+// Sensor reads CPU temperatures
+type Sensor interface {
+	// Name identifies the sensor source
+	Name() string
+	// Read takes a reading, failing with ErrNoSensor when the source has
+	// no temperatures
+	Read(ctx context.Context) (ThermalReading, error)
+}
+
+// DetectSensor returns the first sensor source that gives a reading on this
+// machine, or ErrNoSensor
+func DetectSensor(ctx context.Context) (Sensor, error) {
+	var candidates []Sensor
+	switch runtime.GOOS {
+	case "linux":
+		candidates = append(candidates, SysfsSensor{Root: "/sys"}, LMSensors{})
+	case "darwin":
+		candidates = append(candidates, PowerMetrics{})
+	default:
+		candidates = append(candidates, LMSensors{})
+	}
+	var failures []string
+	for _, s := range candidates {
+		if _, err := s.Read(ctx); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", s.Name(), err))
+			continue
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("%w (%s)", ErrNoSensor, strings.Join(failures, "; "))
+}
+
+// SysfsSensor reads the Linux hwmon and thermal zone interfaces and the
+// cpufreq clocks under Root, normally /sys
+type SysfsSensor struct {
+	Root string
+}
+
+// Name returns "sysfs"
+func (s SysfsSensor) Name() string { return "sysfs" }
+
+// Read takes a reading from hwmon, falling back to thermal zones
+func (s SysfsSensor) Read(ctx context.Context) (ThermalReading, error) {
+	r := ThermalReading{Temperatures: s.hwmon(), Time: time.Now()}
+	if len(r.Temperatures) == 0 {
+		r.Temperatures = s.thermalZones()
+	}
+	if len(r.Temperatures) == 0 {
+		return r, ErrNoSensor
+	}
+	r.FrequencyMHz, r.MaxFrequencyMHz = s.frequency()
+	return r, nil
+}
+
+// hwmon reads temp*_input in millidegrees, labelled by the chip name and
+// temp*_label
+func (s SysfsSensor) hwmon() []Temperature {
+	inputs, _ := filepath.Glob(filepath.Join(s.Root, "class", "hwmon", "hwmon*", "temp*_input"))
+	var temps []Temperature
+	for _, input := range inputs {
+		milli, ok := readSysfsInt(input)
+		if !ok {
+			continue
+		}
+		dir := filepath.Dir(input)
+		label := strings.TrimSuffix(filepath.Base(input), "_input")
+		if l, err := os.ReadFile(filepath.Join(dir, label+"_label")); err == nil {
+			label = strings.TrimSpace(string(l))
+		}
+		if name, err := os.ReadFile(filepath.Join(dir, "name")); err == nil {
+			label = strings.TrimSpace(string(name)) + " " + label
+		}
+		temps = append(temps, Temperature{Label: label, Celsius: float64(milli) / 1000})
+	}
+	return temps
+}
+
+// thermalZones reads thermal_zone*/temp in millidegrees, labelled by type
+func (s SysfsSensor) thermalZones() []Temperature {
+	zones, _ := filepath.Glob(filepath.Join(s.Root, "class", "thermal", "thermal_zone*"))
+	var temps []Temperature
+	for _, zone := range zones {
+		milli, ok := readSysfsInt(filepath.Join(zone, "temp"))
+		if !ok {
+			continue
+		}
+		label := filepath.Base(zone)
+		if t, err := os.ReadFile(filepath.Join(zone, "type")); err == nil {
+			label = strings.TrimSpace(string(t))
+		}
+		temps = append(temps, Temperature{Label: label, Celsius: float64(milli) / 1000})
+	}
+	return temps
+}
+
+// frequency returns the mean current and the highest maximum cpufreq
+// clock in MHz
+func (s SysfsSensor) frequency() (current, maximum float64) {
+	dirs, _ := filepath.Glob(filepath.Join(s.Root, "devices", "system", "cpu", "cpu[0-9]*", "cpufreq"))
+	var total float64
+	var n int
+	for _, dir := range dirs {
+		if khz, ok := readSysfsInt(filepath.Join(dir, "scaling_cur_freq")); ok {
+			total += float64(khz) / 1000
+			n++
+		}
+		if khz, ok := readSysfsInt(filepath.Join(dir, "cpuinfo_max_freq")); ok {
+			maximum = max(maximum, float64(khz)/1000)
+		}
+	}
+	if n > 0 {
+		current = total / float64(n)
+	}
+	return current, maximum
+}
+
+func readSysfsInt(path string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return v, err == nil
+}
+
+// LMSensors reads `sensors -j` from lm-sensors
+type LMSensors struct {
+	// Command is the sensors binary, "sensors" from PATH if empty
+	Command string
+}
+
+// Name returns "lm-sensors"
+func (s LMSensors) Name() string { return "lm-sensors" }
+
+// Read runs sensors and parses its JSON output
+func (s LMSensors) Read(ctx context.Context) (ThermalReading, error) {
+	command := s.Command
+	if command == "" {
+		command = "sensors"
+	}
+	out, err := exec.CommandContext(ctx, command, "-j").Output()
+	if err != nil {
+		return ThermalReading{}, err
+	}
+	return parseSensorsJSON(out)
+}
+
+// parseSensorsJSON reads every temp*_input of every chip feature
+func parseSensorsJSON(data []byte) (ThermalReading, error) {
+	var chips map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &chips); err != nil {
+		return ThermalReading{}, fmt.Errorf("invalid sensors output: %w", err)
+	}
+	r := ThermalReading{Time: time.Now()}
+	for chip, features := range chips {
+		for feature, raw := range features {
+			var values map[string]float64
+			if json.Unmarshal(raw, &values) != nil {
+				continue // the "Adapter" string
+			}
+			for key, v := range values {
+				if strings.HasPrefix(key, "temp") && strings.HasSuffix(key, "_input") {
+					r.Temperatures = append(r.Temperatures, Temperature{Label: chip + " " + feature, Celsius: v})
+				}
+			}
+		}
+	}
+	if len(r.Temperatures) == 0 {
+		return r, ErrNoSensor
+	}
+	sort.Slice(r.Temperatures, func(i, j int) bool { return r.Temperatures[i].Label < r.Temperatures[j].Label })
+	return r, nil
+}
+
+// PowerMetrics reads the SMC temperatures and CPU clocks macOS reports
+// through powermetrics, which needs root
+type PowerMetrics struct {
+	// Command is the powermetrics binary, "powermetrics" from PATH if
+	// empty
+	Command string
+}
+
+// Name returns "powermetrics"
+func (s PowerMetrics) Name() string { return "powermetrics" }
+
+// Read takes one 100 ms sample
+func (s PowerMetrics) Read(ctx context.Context) (ThermalReading, error) {
+	command := s.Command
+	if command == "" {
+		command = "powermetrics"
+	}
+	out, err := exec.CommandContext(ctx, command, "--samplers", "smc,cpu_power", "-i", "100", "-n", "1").Output()
+	if err != nil {
+		return ThermalReading{}, err
+	}
+	return parsePowerMetrics(out)
+}
+
+// parsePowerMetrics reads "... die temperature: 52.13 C" and
+// "... frequency: 2400 MHz" lines
+func parsePowerMetrics(data []byte) (ThermalReading, error) {
+	r := ThermalReading{Time: time.Now()}
+	var total float64
+	var n int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		label, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		label = strings.TrimSpace(label)
+		fields := strings.Fields(value)
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(label, "temperature") && fields[1] == "C":
+			r.Temperatures = append(r.Temperatures, Temperature{Label: label, Celsius: v})
+		case strings.HasSuffix(label, "frequency") && strings.EqualFold(fields[1], "MHz"):
+			total += v
+			n++
+			r.MaxFrequencyMHz = max(r.MaxFrequencyMHz, v)
+		}
+	}
+	if len(r.Temperatures) == 0 {
+		return r, ErrNoSensor
+	}
+	if n > 0 {
+		r.FrequencyMHz = total / float64(n)
+	}
+	// powermetrics reports no rated maximum, only the clocks it saw
+	r.MaxFrequencyMHz = 0
+	return r, nil
+}
+
+// ThermalConfig configures StartThermalMonitor
+type ThermalConfig struct {
+	Sensor Sensor
+	// Limit is the temperature in °C at or above which CPU workers are
+	// halved, DefaultThermalLimit if zero
+	Limit float64
+	// Hysteresis is how far below Limit the temperature must fall before
+	// workers are restored, DefaultThermalHysteresis if zero
+	Hysteresis float64
+	// Interval is the time between readings, DefaultThermalInterval if
+	// zero
+	Interval time.Duration
+	// OnReading, if set, is called after every reading with the workers
+	// now in use
+	OnReading func(r ThermalReading, workers int)
+}
+
+// ThermalStatus is the accelerator's last reading and throttling state
+type ThermalStatus struct {
+	// Reading is nil until the monitor has read the sensor
+	Reading   *ThermalReading
+	Sensor    string
+	Limit     float64
+	Throttled bool
+	// Workers is the number of CPU workers mining now
+	Workers int
+}
+
+// StartThermalMonitor reads cfg.Sensor every cfg.Interval until ctx is
+// done, throttling CPU workers while the hottest temperature is at or above
+// the limit. It fails if the first reading does.
+func (a *Accelerator) StartThermalMonitor(ctx context.Context, cfg ThermalConfig) error {
+	if cfg.Sensor == nil {
+		return ErrNoSensor
+	}
+	if cfg.Limit == 0 {
+		cfg.Limit = DefaultThermalLimit
+	}
+	if cfg.Hysteresis == 0 {
+		cfg.Hysteresis = DefaultThermalHysteresis
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultThermalInterval
+	}
+	r, err := cfg.Sensor.Read(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", cfg.Sensor.Name(), err)
+	}
+
+	a.mu.Lock()
+	a.thermalSensor, a.thermalLimit = cfg.Sensor.Name(), cfg.Limit
+	a.mu.Unlock()
+	a.applyThermal(r, cfg)
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			// A failed reading keeps the last throttling decision
+			if r, err := cfg.Sensor.Read(ctx); err == nil {
+				a.applyThermal(r, cfg)
+			}
+		}
+	}()
+	return nil
+}
+
+// applyThermal records a reading and halves or doubles the throttled
+// worker count
+func (a *Accelerator) applyThermal(r ThermalReading, cfg ThermalConfig) {
+	a.mu.Lock()
+	hot := r.Hottest()
+	active := a.activeWorkers()
+	switch {
+	case hot >= cfg.Limit:
+		a.throttled = max(active/2, 1)
+	case a.throttled > 0 && hot <= cfg.Limit-cfg.Hysteresis:
+		a.throttled *= 2
+		if a.throttled >= a.workerCount {
+			a.throttled = 0
+		}
+	}
+	a.thermal = &r
+	workers := a.activeWorkers()
+	a.mu.Unlock()
+
+	if cfg.OnReading != nil {
+		cfg.OnReading(r, workers)
+	}
+}
+
+// Thermal returns the last reading and whether workers are throttled
+func (a *Accelerator) Thermal() ThermalStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	status := ThermalStatus{
+		Sensor:    a.thermalSensor,
+		Limit:     a.thermalLimit,
+		Throttled: a.throttled > 0,
+		Workers:   a.activeWorkers(),
+	}
+	if a.thermal != nil {
+		r := *a.thermal
+		status.Reading = &r
+	}
+	return status
+}
+
+// ActiveWorkers returns the number of CPU workers Mine uses: the worker
+// count, or fewer while throttled
+func (a *Accelerator) ActiveWorkers() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.activeWorkers()
+}
+
+// activeWorkers applies throttling to the worker count; callers must hold
+// a.mu
+func (a *Accelerator) activeWorkers() int {
+	if a.throttled > 0 && a.throttled < a.workerCount {
+		return a.throttled
+	}
+	return a.workerCount
+}
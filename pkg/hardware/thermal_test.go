@@ -0,0 +1,232 @@
+package hardware
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+func writeSysfs(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSysfsSensor(t *testing.T) {
+	root := t.TempDir()
+	if _, err := (SysfsSensor{Root: root}).Read(context.Background()); !errors.Is(err, ErrNoSensor) {
+		t.Errorf("Read() of an empty tree error = %v, want ErrNoSensor", err)
+	}
+
+	writeSysfs(t, root, map[string]string{
+		"class/thermal/thermal_zone0/type":                    "x86_pkg_temp",
+		"class/thermal/thermal_zone0/temp":                    "61000",
+		"devices/system/cpu/cpu0/cpufreq/scaling_cur_freq":    "2000000",
+		"devices/system/cpu/cpu0/cpufreq/cpuinfo_max_freq":    "3500000",
+		"devices/system/cpu/cpu1/cpufreq/scaling_cur_freq":    "3000000",
+		"devices/system/cpu/cpu1/cpufreq/cpuinfo_max_freq":    "4000000",
+		"devices/system/cpu/cpufreq/policy0/scaling_cur_freq": "1",
+	})
+	r, err := (SysfsSensor{Root: root}).Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Temperatures) != 1 || r.Temperatures[0] != (Temperature{Label: "x86_pkg_temp", Celsius: 61}) {
+		t.Errorf("thermal zone temperatures = %+v", r.Temperatures)
+	}
+	if r.FrequencyMHz != 2500 || r.MaxFrequencyMHz != 4000 {
+		t.Errorf("frequency = %.0f of %.0f MHz, want 2500 of 4000", r.FrequencyMHz, r.MaxFrequencyMHz)
+	}
+
+	// hwmon takes precedence over thermal zones
+	writeSysfs(t, root, map[string]string{
+		"class/hwmon/hwmon0/name":        "coretemp",
+		"class/hwmon/hwmon0/temp1_input": "48000",
+		"class/hwmon/hwmon0/temp1_label": "Package id 0",
+		"class/hwmon/hwmon0/temp2_input": "73500",
+		"class/hwmon/hwmon1/temp1_input": "garbage",
+	})
+	r, err = (SysfsSensor{Root: root}).Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Temperatures) != 2 || r.Temperatures[0].Label != "coretemp Package id 0" || r.Hottest() != 73.5 {
+		t.Errorf("hwmon temperatures = %+v", r.Temperatures)
+	}
+	if got := r.String(); got != "73.5 °C, 2500 MHz of 4000" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseSensorsJSON(t *testing.T) {
+	out := []byte(`{
+		"coretemp-isa-0000": {
+			"Adapter": "ISA adapter",
+			"Package id 0": {"temp1_input": 55.000, "temp1_max": 100.000, "temp1_crit": 100.000},
+			"Core 0": {"temp2_input": 57.000, "temp2_max": 100.000}
+		},
+		"nvme-pci-0100": {
+			"Adapter": "PCI adapter",
+			"Composite": {"temp1_input": 38.850, "temp1_alarm": 0.000}
+		},
+		"acpi_fan-acpi-0": {"Adapter": "ACPI interface", "fan1": {"fan1_input": 1200.000}}
+	}`)
+	r, err := parseSensorsJSON(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Temperatures) != 3 || r.Temperatures[0].Label != "coretemp-isa-0000 Core 0" || r.Hottest() != 57 {
+		t.Errorf("temperatures = %+v", r.Temperatures)
+	}
+	if _, err := parseSensorsJSON([]byte(`{"acpi_fan-acpi-0": {"fan1": {"fan1_input": 1200}}}`)); !errors.Is(err, ErrNoSensor) {
+		t.Errorf("parseSensorsJSON() without temperatures error = %v, want ErrNoSensor", err)
+	}
+}
+
+func TestParsePowerMetrics(t *testing.T) {
+	out := []byte(`*** Sampled system activity (Mon Oct  5 10:00:00 2026 +0000) (101.23ms elapsed) ***
+
+**** SMC sensors ****
+
+CPU die temperature: 64.52 C
+GPU die temperature: 51.00 C
+Fan: 1800.12 rpm
+
+**** Processor usage ****
+
+CPU 0 frequency: 2400 MHz
+CPU 1 frequency: 1800 MHz
+`)
+	r, err := parsePowerMetrics(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Temperatures) != 2 || r.Temperatures[0] != (Temperature{Label: "CPU die temperature", Celsius: 64.52}) {
+		t.Errorf("temperatures = %+v", r.Temperatures)
+	}
+	if r.FrequencyMHz != 2100 || r.MaxFrequencyMHz != 0 {
+		t.Errorf("frequency = %.0f of %.0f MHz, want 2100 of unknown", r.FrequencyMHz, r.MaxFrequencyMHz)
+	}
+}
+
+// fakeSensor reports a temperature the test sets
+type fakeSensor struct {
+	mu      sync.Mutex
+	celsius float64
+	err     error
+}
+
+func (s *fakeSensor) Name() string { return "fake" }
+
+func (s *fakeSensor) Read(ctx context.Context) (ThermalReading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ThermalReading{Temperatures: []Temperature{{Label: "cpu", Celsius: s.celsius}}}, s.err
+}
+
+func (s *fakeSensor) set(celsius float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.celsius = celsius
+}
+
+func TestThermalThrottling(t *testing.T) {
+	acc := NewAccelerator()
+	acc.workerCount = 8
+	cfg := ThermalConfig{Limit: 85, Hysteresis: 5}
+
+	steps := []struct {
+		celsius float64
+		workers int
+	}{
+		{70, 8},
+		{85, 4},
+		{90, 2},
+		{95, 1},
+		{96, 1},
+		{82, 1}, // inside the hysteresis band
+		{80, 2},
+		{79, 4},
+		{70, 8},
+		{70, 8},
+	}
+	for i, step := range steps {
+		acc.applyThermal(ThermalReading{Temperatures: []Temperature{{Celsius: step.celsius}}}, cfg)
+		status := acc.Thermal()
+		if status.Workers != step.workers || status.Throttled != (step.workers < 8) || status.Reading.Hottest() != step.celsius {
+			t.Fatalf("Step %d at %.0f °C: %+v, want %d workers", i, step.celsius, status, step.workers)
+		}
+	}
+
+	acc.applyThermal(ThermalReading{Temperatures: []Temperature{{Celsius: 88}}}, cfg)
+	stats := acc.GetStats()
+	if stats["active_workers"] != 4 || stats["throttled"] != true || stats["temperature_c"] != 88.0 || stats["worker_count"] != 8 {
+		t.Errorf("GetStats() = %v", stats)
+	}
+
+	// Throttled CPU searches run on fewer workers
+	result, err := acc.MineRange(context.Background(), []byte("hot"), 0, 0, 3)
+	if !errors.Is(err, crypto.ErrNonceSpaceExhausted) || len(result.Workers) != 4 {
+		t.Errorf("MineRange() while throttled = %d workers, %v", len(result.Workers), err)
+	}
+}
+
+func TestStartThermalMonitor(t *testing.T) {
+	acc := NewAccelerator()
+	acc.workerCount = 4
+	if err := acc.StartThermalMonitor(context.Background(), ThermalConfig{}); !errors.Is(err, ErrNoSensor) {
+		t.Errorf("StartThermalMonitor() without a sensor error = %v, want ErrNoSensor", err)
+	}
+	if err := acc.StartThermalMonitor(context.Background(), ThermalConfig{Sensor: &fakeSensor{err: ErrNoSensor}}); !errors.Is(err, ErrNoSensor) {
+		t.Errorf("StartThermalMonitor() with a failing sensor error = %v, want ErrNoSensor", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sensor := &fakeSensor{celsius: 60}
+	readings := make(chan int, 100)
+	err := acc.StartThermalMonitor(ctx, ThermalConfig{Sensor: sensor, Interval: time.Millisecond,
+		OnReading: func(r ThermalReading, workers int) {
+			select {
+			case readings <- workers:
+			default:
+			}
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := acc.Thermal(); status.Sensor != "fake" || status.Limit != DefaultThermalLimit || status.Workers != 4 {
+		t.Errorf("Thermal() = %+v", status)
+	}
+
+	sensor.set(DefaultThermalLimit + 1)
+	deadline := time.After(5 * time.Second)
+	for acc.ActiveWorkers() != 1 {
+		select {
+		case <-readings:
+		case <-deadline:
+			t.Fatalf("workers = %d after overheating, want 1", acc.ActiveWorkers())
+		}
+	}
+	sensor.set(DefaultThermalLimit - DefaultThermalHysteresis)
+	for acc.ActiveWorkers() != 4 {
+		select {
+		case <-readings:
+		case <-deadline:
+			t.Fatalf("workers = %d after cooling, want 4", acc.ActiveWorkers())
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Package health serves the liveness and readiness probes of the HTTP
+// servers and shuts them down gracefully. /healthz answers while the process
+// serves requests at all; /readyz answers 200 only while every readiness
+// check passes and the server is not draining, so load balancers and
+// orchestrators stop routing to it before it exits.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// CheckTimeout bounds each readiness check
+	CheckTimeout = 2 * time.Second
+	// ShutdownTimeout bounds how long in-flight requests are drained
+	ShutdownTimeout = 10 * time.Second
+	// StartupTimeout is how long servers wait for required dependencies
+	StartupTimeout = 30 * time.Second
+	// startupInterval is how often Startup retries failing checks
+	startupInterval = 500 * time.Millisecond
+)
+
+// ErrDraining is the readiness of a server that is shutting down
+var ErrDraining = errors.New("shutting down")
+
+// Check reports whether one dependency is ready, nil when it is
+type Check func(ctx context.Context) error
+
+// Probe holds a server's readiness checks
+type Probe struct {
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]Check
+	required map[string]bool
+	draining atomic.Bool
+}
+
+// NewProbe creates a probe without checks, ready until it drains
+func NewProbe() *Probe {
+	return &Probe{checks: make(map[string]Check), required: make(map[string]bool)}
+}
+
+// Add registers a readiness check under name, replacing any check of that
+// name
+func (p *Probe) Add(name string, check Check) {
+	p.add(name, check, false)
+}
+
+// Require registers a readiness check that must also pass before the
+// server starts, see Startup
+func (p *Probe) Require(name string, check Check) {
+	p.add(name, check, true)
+}
+
+func (p *Probe) add(name string, check Check, required bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.checks[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.checks[name] = check
+	p.required[name] = required
+}
+
+// Drain marks the server as shutting down, failing readiness from now on
+func (p *Probe) Drain() {
+	p.draining.Store(true)
+}
+
+// Check runs every check concurrently, each bounded by CheckTimeout, and
+// returns the failures by name, empty when the server is ready
+func (p *Probe) Check(ctx context.Context) map[string]error {
+	return p.check(ctx, false)
+}
+
+// check runs the checks, only the required ones if requiredOnly is set
+func (p *Probe) check(ctx context.Context, requiredOnly bool) map[string]error {
+	p.mu.RLock()
+	var names []string
+	var checks []Check
+	for _, name := range p.names {
+		if !requiredOnly || p.required[name] {
+			names = append(names, name)
+			checks = append(checks, p.checks[name])
+		}
+	}
+	p.mu.RUnlock()
+
+	failures := make(map[string]error)
+	if p.draining.Load() {
+		failures["server"] = ErrDraining
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := run(ctx, check); err != nil {
+				mu.Lock()
+				failures[names[i]] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return failures
+}
+
+// run runs check with CheckTimeout. A check still running at the deadline
+// fails and is left to finish in the background.
+func run(ctx context.Context, check Check) error {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Startup waits up to timeout for the required checks to pass, for
+// dependencies that need a moment after start, and returns the failures
+// left otherwise. Checks registered with Add, such as syncing with peers,
+// may keep the server unready after it starts.
+func (p *Probe) Startup(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		failures := p.check(ctx, true)
+		if len(failures) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("dependencies not ready after %s: %s", timeout, describe(failures))
+		case <-time.After(startupInterval):
+		}
+	}
+}
+
+// describe lists failures as "name: error" in name order
+func describe(failures map[string]error) string {
+	parts := make([]string, 0, len(failures))
+	for name, err := range failures {
+		parts = append(parts, name+": "+err.Error())
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, "; ")
+}
+
+// Liveness answers /healthz: 200 for as long as the server answers at all
+func (p *Probe) Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+}
+
+// Readiness answers /readyz: 200 with every check "ok" when ready, and 503
+// naming the failed checks otherwise
+func (p *Probe) Readiness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failures := p.Check(r.Context())
+		p.mu.RLock()
+		checks := make(map[string]string, len(p.names)+len(failures))
+		for _, name := range p.names {
+			checks[name] = "ok"
+		}
+		p.mu.RUnlock()
+		for name, err := range failures {
+			checks[name] = err.Error()
+		}
+
+		status := "ready"
+		w.Header().Set("Content-Type", "application/json")
+		if len(failures) > 0 {
+			status = "not ready"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "checks": checks})
+	})
+}
+
+// Serve runs listen, which serves server, until ctx is cancelled. It then
+// drains the probe and shuts server down, waiting up to ShutdownTimeout
+// for in-flight requests. It returns nil after a shutdown and listen's
+// error if the server fails first.
+func Serve(ctx context.Context, server *http.Server, probe *Probe, listen func() error) error {
+	errc := make(chan error, 1)
+	go func() { errc <- listen() }()
+
+	select {
+	case err := <-errc:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	if probe != nil {
+		probe.Drain()
+	}
+	slog.Info("Shutting down: draining connections", "timeout", ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("failed to drain connections: %w", err)
+	}
+	if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	probe := NewProbe()
+	var synced atomic.Bool
+	probe.Add("database", func(ctx context.Context) error { return nil })
+	probe.Add("spv", func(ctx context.Context) error {
+		if !synced.Load() {
+			return errors.New("headers not synced")
+		}
+		return nil
+	})
+
+	get := func(h http.Handler) (int, map[string]interface{}) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+		var body map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, body
+	}
+
+	code, body := get(probe.Readiness())
+	checks := body["checks"].(map[string]interface{})
+	if code != http.StatusServiceUnavailable || body["status"] != "not ready" || checks["database"] != "ok" || checks["spv"] != "headers not synced" {
+		t.Errorf("/readyz while syncing = %d %v", code, body)
+	}
+
+	synced.Store(true)
+	if code, body := get(probe.Readiness()); code != http.StatusOK || body["status"] != "ready" {
+		t.Errorf("/readyz when synced = %d %v", code, body)
+	}
+
+	probe.Drain()
+	code, body = get(probe.Readiness())
+	if code != http.StatusServiceUnavailable || body["checks"].(map[string]interface{})["server"] != ErrDraining.Error() {
+		t.Errorf("/readyz while draining = %d %v", code, body)
+	}
+	if code, body := get(probe.Liveness()); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("/healthz while draining = %d %v", code, body)
+	}
+}
+
+func TestStartup(t *testing.T) {
+	probe := NewProbe()
+	probe.Add("spv", func(ctx context.Context) error { return errors.New("syncing") })
+	var calls atomic.Int32
+	probe.Require("ledger", func(ctx context.Context) error {
+		if calls.Add(1) < 3 {
+			return errors.New("opening")
+		}
+		return nil
+	})
+	if err := probe.Startup(context.Background(), 5*time.Second); err != nil {
+		t.Errorf("Startup() = %v, want ready on the third check", err)
+	}
+
+	probe.Require("peers", func(ctx context.Context) error { return errors.New("no peers") })
+	err := probe.Startup(context.Background(), 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "peers: no peers") {
+		t.Errorf("Startup() with a failing dependency = %v", err)
+	}
+
+	// A hung check times out rather than blocking readiness
+	probe = NewProbe()
+	probe.Add("hung", func(ctx context.Context) error { select {} })
+	start := time.Now()
+	if failures := probe.Check(context.Background()); !errors.Is(failures["hung"], context.DeadlineExceeded) {
+		t.Errorf("Check() of a hung dependency = %v", failures)
+	}
+	if elapsed := time.Since(start); elapsed > CheckTimeout+time.Second {
+		t.Errorf("Check() took %s", elapsed)
+	}
+}
+
+func TestServe(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})}
+	probe := NewProbe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, server, probe, func() error { return server.Serve(lis) }) }()
+
+	// A request in flight at shutdown is drained, not cut off
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + lis.Addr().String())
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-started
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if failures := probe.Check(context.Background()); failures["server"] != ErrDraining {
+		t.Errorf("probe after shutdown began = %v, want draining", failures)
+	}
+	close(release)
+	if got := <-response; got != "done" {
+		t.Errorf("in-flight response = %q, want done", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve() = %v", err)
+	}
+
+	// A server failing to listen returns its error
+	busy := &http.Server{Addr: lis.Addr().String()}
+	err = Serve(context.Background(), busy, nil, func() error { return errors.New("address in use") })
+	if err == nil || err.Error() != "address in use" {
+		t.Errorf("Serve() of a failing listener = %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Package inscription inscribes forge certificates on Bitcoin as
+// Ordinals-compatible inscriptions and indexes the certificates inscribed
+package inscription
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+)
+
+const (
+	// ContentType is the content type certificates are inscribed with
+	ContentType = "application/json"
+	// Protocol tells forge certificates apart from other JSON inscriptions
+	Protocol = "exs-forge"
+	// maxProofSize bounds the proof hash, in bytes
+	maxProofSize = 64
+)
+
+// ErrInvalidCertificate indicates an inscription that is not a forge
+// certificate
+var ErrInvalidCertificate = errors.New("invalid forge certificate")
+
+// Certificate attests a completed forge: its number, the block height the
+// treasury recorded it at and the hash of its Tetra-PoW proof
+type Certificate struct {
+	Protocol string `json:"p"`
+	Forge    int    `json:"forge"`
+	Height   uint32 `json:"height"`
+	Proof    string `json:"proof"`
+}
+
+// NewCertificate returns the certificate of forge number forge, recorded at
+// height with the Tetra-PoW hash proof
+func NewCertificate(forge int, height uint32, proof []byte) (*Certificate, error) {
+	c := &Certificate{Protocol: Protocol, Forge: forge, Height: height, Proof: hex.EncodeToString(proof)}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validate checks the fields of a certificate
+func (c *Certificate) validate() error {
+	if c.Protocol != Protocol {
+		return fmt.Errorf("%w: protocol %q", ErrInvalidCertificate, c.Protocol)
+	}
+	if c.Forge <= 0 {
+		return fmt.Errorf("%w: forge %d", ErrInvalidCertificate, c.Forge)
+	}
+	proof, err := hex.DecodeString(c.Proof)
+	if err != nil || len(proof) == 0 || len(proof) > maxProofSize {
+		return fmt.Errorf("%w: proof %q", ErrInvalidCertificate, c.Proof)
+	}
+	return nil
+}
+
+// Inscription returns the inscription of the certificate
+func (c *Certificate) Inscription() (bitcoin.Inscription, error) {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return bitcoin.Inscription{}, fmt.Errorf("failed to encode certificate: %w", err)
+	}
+	return bitcoin.Inscription{ContentType: ContentType, Body: body}, nil
+}
+
+// ParseCertificate returns the forge certificate ins inscribes
+func ParseCertificate(ins bitcoin.Inscription) (*Certificate, error) {
+	if mediaType, _, err := mime.ParseMediaType(ins.ContentType); err != nil || mediaType != ContentType {
+		return nil, fmt.Errorf("%w: content type %q", ErrInvalidCertificate, ins.ContentType)
+	}
+	var c Certificate
+	if err := json.Unmarshal(ins.Body, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
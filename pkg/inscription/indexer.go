@@ -0,0 +1,188 @@
+package inscription
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ErrReorg indicates the last indexed block left the best chain; the index
+// is rebuilt from a height before the fork
+var ErrReorg = errors.New("indexed block no longer on the best chain")
+
+// BlockSource serves the blocks of the best chain, as
+// wallet.EsploraSource does
+type BlockSource interface {
+	TipHeight(ctx context.Context) (int32, error)
+	BlockHash(ctx context.Context, height int32) (*chainhash.Hash, error)
+	Block(ctx context.Context, hash *chainhash.Hash) (*wire.MsgBlock, error)
+}
+
+// Record is an inscribed certificate found on chain
+type Record struct {
+	Certificate
+	InscriptionID string `json:"inscription_id"`
+	BlockHeight   int32  `json:"block_height"`
+	// Owner is the address the reveal transaction sent the inscription to
+	Owner string `json:"owner,omitempty"`
+}
+
+// Index holds the certificates inscribed up to block Height. The first
+// certificate inscribed for a forge is the forge's certificate; later ones
+// are ignored.
+type Index struct {
+	Height       int32     `json:"height"`
+	BlockHash    string    `json:"block_hash,omitempty"`
+	Certificates []Record  `json:"certificates"`
+	SyncedAt     time.Time `json:"synced_at,omitempty"`
+}
+
+// NewIndex returns an empty index that starts indexing at height from
+func NewIndex(from int32) *Index {
+	return &Index{Height: from - 1, Certificates: []Record{}}
+}
+
+// IndexBlock adds the certificates revealed in block, the block at height
+// of net's best chain
+func (x *Index) IndexBlock(block *wire.MsgBlock, height int32, net *chaincfg.Params) {
+	forges := make(map[int]bool, len(x.Certificates))
+	for _, r := range x.Certificates {
+		forges[r.Forge] = true
+	}
+	for _, tx := range block.Transactions {
+		// Inscriptions are numbered across the inputs of a transaction
+		n := 0
+		for _, in := range tx.TxIn {
+			for _, ins := range bitcoin.ParseInscriptions(in.Witness) {
+				id := fmt.Sprintf("%si%d", tx.TxHash(), n)
+				n++
+				c, err := ParseCertificate(ins)
+				if err != nil || forges[c.Forge] {
+					continue
+				}
+				forges[c.Forge] = true
+				x.Certificates = append(x.Certificates, Record{
+					Certificate:   *c,
+					InscriptionID: id,
+					BlockHeight:   height,
+					Owner:         outputAddress(tx, net),
+				})
+			}
+		}
+	}
+	hash := block.BlockHash()
+	x.Height, x.BlockHash = height, hash.String()
+}
+
+// outputAddress returns the address of tx's first output, which an
+// inscription on the first satoshi of the first input goes to
+func outputAddress(tx *wire.MsgTx, net *chaincfg.Params) string {
+	if len(tx.TxOut) == 0 {
+		return ""
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(tx.TxOut[0].PkScript, net)
+	if err != nil || len(addrs) != 1 {
+		return ""
+	}
+	return addrs[0].EncodeAddress()
+}
+
+// Sync indexes the blocks of source from the block after Height to the tip.
+// It returns ErrReorg when the last indexed block is no longer on the best
+// chain. The index holds every block indexed until an error, so it can be
+// saved and synced again.
+func (x *Index) Sync(ctx context.Context, source BlockSource, net *chaincfg.Params) error {
+	tip, err := source.TipHeight(ctx)
+	if err != nil {
+		return err
+	}
+	if x.BlockHash != "" && x.Height <= tip {
+		hash, err := source.BlockHash(ctx, x.Height)
+		if err != nil {
+			return err
+		}
+		if hash.String() != x.BlockHash {
+			return fmt.Errorf("%w: block %d was %s, now %s", ErrReorg, x.Height, x.BlockHash, hash)
+		}
+	}
+	for height := x.Height + 1; height <= tip; height++ {
+		hash, err := source.BlockHash(ctx, height)
+		if err != nil {
+			return err
+		}
+		block, err := source.Block(ctx, hash)
+		if err != nil {
+			return err
+		}
+		x.IndexBlock(block, height, net)
+	}
+	x.SyncedAt = time.Now().UTC()
+	return nil
+}
+
+// Certificate returns the certificate of forge, if one was inscribed
+func (x *Index) Certificate(forge int) (*Record, bool) {
+	for i := range x.Certificates {
+		if x.Certificates[i].Forge == forge {
+			r := x.Certificates[i]
+			return &r, true
+		}
+	}
+	return nil, false
+}
+
+// List returns the certificates by forge number, those owned by owner
+// when it is not empty
+func (x *Index) List(owner string) []Record {
+	records := make([]Record, 0, len(x.Certificates))
+	for _, r := range x.Certificates {
+		if owner == "" || r.Owner == owner {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Forge < records[j].Forge })
+	return records
+}
+
+// Save writes the index to path
+func (x *Index) Save(path string) error {
+	data, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode certificate index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write certificate index: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadIndex reads the index at path, or returns nil when there is none
+func LoadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate index: %w", err)
+	}
+	var x Index
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate index: %w", err)
+	}
+	return &x, nil
+}
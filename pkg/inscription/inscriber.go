@@ -0,0 +1,168 @@
+package inscription
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DefaultFeeTarget is the confirmation target, in blocks, inscriptions pay
+// the fee rate of by default
+const DefaultFeeTarget = 6
+
+// ErrFundingAddress indicates a certificate sent to the address paying for
+// it, whose outputs would later be spent as fees, inscription and all
+var ErrFundingAddress = errors.New("certificate sent to the funding address")
+
+// Chain lists the inscriber's outputs and relays its transactions, as
+// wallet.EsploraSource does
+type Chain interface {
+	Unspent(ctx context.Context, address string) ([]wallet.UTXO, error)
+	Broadcast(ctx context.Context, rawTx string) (string, error)
+	FeeEstimates(ctx context.Context) (wallet.FeeEstimates, error)
+}
+
+// Inscriber inscribes certificates with a commit transaction, paid from
+// the P2TR address of a key, and a reveal transaction sending the
+// inscription to the certified miner
+type Inscriber struct {
+	key       *bitcoin.TaprootKey
+	net       *chaincfg.Params
+	chain     Chain
+	feeTarget int
+	address   string
+	pkScript  []byte
+}
+
+// Receipt describes an inscribed certificate. The inscription ID is the
+// reveal txid followed by "i0", as Ordinals explorers show it.
+type Receipt struct {
+	InscriptionID string `json:"inscription_id"`
+	CommitTxID    string `json:"commit_txid"`
+	RevealTxID    string `json:"reveal_txid"`
+	RevealTx      string `json:"reveal_tx"`
+	Owner         string `json:"owner"`
+	Fee           int64  `json:"fee"`
+}
+
+// NewInscriber creates an inscriber paying from key's address on net at the
+// fee rate for confirmation within feeTarget blocks, DefaultFeeTarget when
+// zero
+func NewInscriber(key *bitcoin.TaprootKey, net *chaincfg.Params, chain Chain, feeTarget int) (*Inscriber, error) {
+	pkScript, err := key.PkScript()
+	if err != nil {
+		return nil, err
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, net)
+	if err != nil || len(addrs) != 1 {
+		return nil, fmt.Errorf("inscriber key has no address: %v", err)
+	}
+	if feeTarget <= 0 {
+		feeTarget = DefaultFeeTarget
+	}
+	return &Inscriber{key: key, net: net, chain: chain, feeTarget: feeTarget, address: addrs[0].EncodeAddress(), pkScript: pkScript}, nil
+}
+
+// Address returns the address inscriptions are paid from
+func (i *Inscriber) Address() string {
+	return i.address
+}
+
+// Inscribe inscribes c and sends the inscription to the address owner. The
+// commit is broadcast before the reveal; when the reveal is not relayed the
+// receipt is returned with the error, and its RevealTx can be broadcast
+// again.
+func (i *Inscriber) Inscribe(ctx context.Context, c *Certificate, owner string) (*Receipt, error) {
+	addr, err := btcutil.DecodeAddress(owner, i.net)
+	if err != nil || !addr.IsForNet(i.net) {
+		return nil, fmt.Errorf("invalid owner address %q for %s", owner, i.net.Name)
+	}
+	ownerScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(ownerScript, i.pkScript) {
+		return nil, fmt.Errorf("%w: %s", ErrFundingAddress, owner)
+	}
+	ins, err := c.Inscription()
+	if err != nil {
+		return nil, err
+	}
+	commit, err := bitcoin.NewInscriptionCommit(i.key.PrivKey, ins, i.net)
+	if err != nil {
+		return nil, err
+	}
+
+	estimates, err := i.chain.FeeEstimates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fee estimates: %w", err)
+	}
+	rate, err := estimates.Rate(i.feeTarget)
+	if err != nil {
+		return nil, fmt.Errorf("fee estimates: %w", err)
+	}
+	feeRate := max(int64(math.Ceil(rate)), 1)
+	utxos, err := i.chain.Unspent(ctx, i.address)
+	if err != nil {
+		return nil, fmt.Errorf("inscriber outputs: %w", err)
+	}
+	var inputs []bitcoin.UTXO
+	for _, u := range utxos {
+		hash, err := chainhash.NewHashFromStr(u.TxID)
+		if err != nil {
+			continue
+		}
+		inputs = append(inputs, bitcoin.UTXO{OutPoint: wire.OutPoint{Hash: *hash, Index: u.Vout}, Value: u.Value, PkScript: i.pkScript})
+	}
+
+	commitValue := bitcoin.InscriptionPostage + commit.RevealFee(ownerScript, feeRate)
+	commitSpend, err := bitcoin.BuildTaprootSpend(inputs, []*wire.TxOut{wire.NewTxOut(commitValue, commit.PkScript)}, i.pkScript, feeRate)
+	if err != nil {
+		return nil, fmt.Errorf("commit for %s: %w", i.address, err)
+	}
+	if err := commitSpend.Sign([]*bitcoin.TaprootKey{i.key}); err != nil {
+		return nil, err
+	}
+	reveal, err := commit.Reveal(wire.OutPoint{Hash: commitSpend.Tx.TxHash(), Index: 0}, commitValue, ownerScript, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	if err := reveal.Verify(); err != nil {
+		return nil, fmt.Errorf("reveal does not verify: %w", err)
+	}
+	commitTx, err := commitSpend.Hex()
+	if err != nil {
+		return nil, err
+	}
+	revealTx, err := reveal.Hex()
+	if err != nil {
+		return nil, err
+	}
+
+	revealID := reveal.Tx.TxHash().String()
+	receipt := &Receipt{
+		InscriptionID: revealID + "i0",
+		CommitTxID:    commitSpend.Tx.TxHash().String(),
+		RevealTxID:    revealID,
+		RevealTx:      revealTx,
+		Owner:         owner,
+		Fee:           commitSpend.Fee + reveal.Fee,
+	}
+	if _, err := i.chain.Broadcast(ctx, commitTx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	if _, err := i.chain.Broadcast(ctx, revealTx); err != nil {
+		return receipt, fmt.Errorf("reveal of commit %s: %w", receipt.CommitTxID, err)
+	}
+	return receipt, nil
+}
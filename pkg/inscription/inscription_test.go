@@ -0,0 +1,182 @@
+package inscription
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// fakeChain funds one address and mines every broadcast transaction into
+// the next block
+type fakeChain struct {
+	utxos   []wallet.UTXO
+	mempool []*wire.MsgTx
+	blocks  []*wire.MsgBlock
+}
+
+func (c *fakeChain) Unspent(ctx context.Context, address string) ([]wallet.UTXO, error) {
+	return c.utxos, nil
+}
+
+func (c *fakeChain) Broadcast(ctx context.Context, rawTx string) (string, error) {
+	raw, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return "", err
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return "", err
+	}
+	c.mempool = append(c.mempool, &tx)
+	return tx.TxHash().String(), nil
+}
+
+func (c *fakeChain) FeeEstimates(ctx context.Context) (wallet.FeeEstimates, error) {
+	return wallet.FeeEstimates{1: 5, 6: 2}, nil
+}
+
+// mine puts the mempool in a new block
+func (c *fakeChain) mine() {
+	block := &wire.MsgBlock{Header: wire.BlockHeader{Nonce: uint32(len(c.blocks))}, Transactions: c.mempool}
+	c.blocks, c.mempool = append(c.blocks, block), nil
+}
+
+func (c *fakeChain) TipHeight(ctx context.Context) (int32, error) {
+	return int32(len(c.blocks)) - 1, nil
+}
+
+func (c *fakeChain) BlockHash(ctx context.Context, height int32) (*chainhash.Hash, error) {
+	if int(height) >= len(c.blocks) {
+		return nil, errors.New("no block")
+	}
+	hash := c.blocks[height].BlockHash()
+	return &hash, nil
+}
+
+func (c *fakeChain) Block(ctx context.Context, hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	for _, block := range c.blocks {
+		if block.BlockHash() == *hash {
+			return block, nil
+		}
+	}
+	return nil, errors.New("no block")
+}
+
+func TestCertificate(t *testing.T) {
+	proof := bytes.Repeat([]byte{0xab}, 32)
+	c, err := NewCertificate(7, 1234, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins, err := c.Inscription()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseCertificate(ins)
+	if err != nil || *got != *c {
+		t.Errorf("ParseCertificate() = %+v, %v, want %+v", got, err, c)
+	}
+
+	if _, err := NewCertificate(0, 1, proof); !errors.Is(err, ErrInvalidCertificate) {
+		t.Errorf("Expected ErrInvalidCertificate for forge 0, got %v", err)
+	}
+	for _, ins := range []bitcoin.Inscription{
+		{ContentType: "text/plain", Body: ins.Body},
+		{ContentType: ContentType, Body: []byte(`{"p":"brc-20","op":"mint"}`)},
+		{ContentType: ContentType, Body: []byte(`{"p":"exs-forge","forge":1,"proof":"zz"}`)},
+	} {
+		if _, err := ParseCertificate(ins); !errors.Is(err, ErrInvalidCertificate) {
+			t.Errorf("ParseCertificate(%s) = %v, want ErrInvalidCertificate", ins.Body, err)
+		}
+	}
+	if _, err := ParseCertificate(bitcoin.Inscription{ContentType: "application/json; charset=utf-8", Body: ins.Body}); err != nil {
+		t.Errorf("Expected content type parameters to be accepted, got %v", err)
+	}
+}
+
+func TestInscribeAndIndex(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	priv, _ := btcec.NewPrivateKey()
+	key := &bitcoin.TaprootKey{PrivKey: priv}
+	minerPriv, _ := btcec.NewPrivateKey()
+	miner, _ := bitcoin.DeriveTaprootAddress(minerPriv.PubKey().SerializeCompressed(), net)
+
+	chain := &fakeChain{}
+	chain.mine()
+	inscriber, err := NewInscriber(key, net, chain, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.utxos = []wallet.UTXO{{TxID: chainhash.HashH([]byte("funding")).String(), Value: 100_000, Address: inscriber.Address()}}
+
+	c, _ := NewCertificate(3, 42, bytes.Repeat([]byte{1}, 32))
+	receipt, err := inscriber.Inscribe(context.Background(), c, miner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.mempool) != 2 || chain.mempool[0].TxHash().String() != receipt.CommitTxID || chain.mempool[1].TxHash().String() != receipt.RevealTxID {
+		t.Fatalf("Expected the commit then the reveal to be broadcast, got %d transactions", len(chain.mempool))
+	}
+	if out := chain.mempool[1].TxOut[0]; out.Value != bitcoin.InscriptionPostage {
+		t.Errorf("Expected the inscription on a %d sat output, got %d", bitcoin.InscriptionPostage, out.Value)
+	}
+	if _, err := inscriber.Inscribe(context.Background(), c, inscriber.Address()); !errors.Is(err, ErrFundingAddress) {
+		t.Errorf("Expected ErrFundingAddress, got %v", err)
+	}
+	chain.mine()
+
+	// A second certificate for the same forge does not replace the first
+	chain.mempool = nil
+	forged, _ := NewCertificate(3, 43, bytes.Repeat([]byte{2}, 32))
+	if _, err := inscriber.Inscribe(context.Background(), forged, miner); err != nil {
+		t.Fatal(err)
+	}
+	chain.mine()
+
+	index := NewIndex(1)
+	if err := index.Sync(context.Background(), chain, net); err != nil {
+		t.Fatal(err)
+	}
+	records := index.List("")
+	if len(records) != 1 {
+		t.Fatalf("Expected one certificate, got %+v", records)
+	}
+	want := Record{Certificate: *c, InscriptionID: receipt.InscriptionID, BlockHeight: 1, Owner: miner}
+	if records[0] != want {
+		t.Errorf("Record = %+v, want %+v", records[0], want)
+	}
+	if r, ok := index.Certificate(3); !ok || r.Owner != miner {
+		t.Errorf("Certificate(3) = %+v, %v", r, ok)
+	}
+	if got := index.List("bcrt1pother"); len(got) != 0 {
+		t.Errorf("Expected no certificates for another owner, got %+v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "certificates.json")
+	if err := index.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadIndex(path)
+	if err != nil || loaded.Height != 2 || len(loaded.Certificates) != 1 {
+		t.Fatalf("LoadIndex() = %+v, %v", loaded, err)
+	}
+	if missing, err := LoadIndex(filepath.Join(t.TempDir(), "none.json")); missing != nil || err != nil {
+		t.Errorf("Expected no index for a missing file, got %+v, %v", missing, err)
+	}
+
+	// Replacing the last block is a reorg
+	chain.blocks[2] = &wire.MsgBlock{Header: wire.BlockHeader{Nonce: 99}}
+	if err := loaded.Sync(context.Background(), chain, net); !errors.Is(err, ErrReorg) {
+		t.Errorf("Expected ErrReorg, got %v", err)
+	}
+}
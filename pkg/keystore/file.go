@@ -0,0 +1,210 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// keyFileVersion is the current key file format version
+const keyFileVersion = 1
+
+// keyFile is a sealed key at rest. The public key is kept in the clear so
+// signers can be opened without unsealing; it is bound into the seal with
+// the name, so neither can be swapped.
+type keyFile struct {
+	Version    int    `json:"version"`
+	Name       string `json:"name"`
+	PubKey     string `json:"pubkey"`
+	Seal       string `json:"seal"`
+	KDFRounds  int    `json:"kdf_rounds,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce,omitempty"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// additionalData binds a seal to the key's name and public key
+func (f *keyFile) additionalData() []byte {
+	return []byte("exs-keystore\n" + f.Name + "\n" + f.PubKey)
+}
+
+// sealer seals the private key in a keyFile
+type sealer interface {
+	seal(f *keyFile, key []byte) error
+	open(f *keyFile) ([]byte, error)
+}
+
+// dirBackend keeps one sealed keyFile per key in a directory
+type dirBackend struct {
+	dir    string
+	sealer sealer
+}
+
+func (d *dirBackend) path(name string) string {
+	return filepath.Join(d.dir, name+".key")
+}
+
+func (d *dirBackend) read(name string) (*keyFile, error) {
+	data, err := os.ReadFile(d.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", d.path(name), err)
+	}
+	if f.Version != keyFileVersion {
+		return nil, fmt.Errorf("unsupported key file version %d", f.Version)
+	}
+	if f.Name != name {
+		return nil, fmt.Errorf("key file %s holds key %q", d.path(name), f.Name)
+	}
+	return &f, nil
+}
+
+func (d *dirBackend) load(name string) ([]byte, error) {
+	f, err := d.read(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.sealer.open(f)
+}
+
+func (d *dirBackend) publicKey(name string) (*btcec.PublicKey, error) {
+	f, err := d.read(name)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(f.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key in %s: %w", d.path(name), err)
+	}
+	return btcec.ParsePubKey(raw)
+}
+
+func (d *dirBackend) save(name string, key []byte, pub *btcec.PublicKey) error {
+	f := &keyFile{Version: keyFileVersion, Name: name, PubKey: hex.EncodeToString(pub.SerializeCompressed())}
+	if err := d.sealer.seal(f, key); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create keystore: %w", err)
+	}
+	// O_EXCL refuses to replace a key, even one written concurrently
+	out, err := os.OpenFile(d.path(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrKeyExists, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		os.Remove(d.path(name))
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return out.Close()
+}
+
+func (d *dirBackend) remove(name string) error {
+	err := os.Remove(d.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	return err
+}
+
+func (d *dirBackend) names() ([]string, error) {
+	entries, err := os.ReadDir(d.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".key"); ok && !e.IsDir() && ValidKeyName(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// passphraseSeal names the passphrase seal: AES-256-GCM under a key
+// stretched from the passphrase with HPP-1, as wallet files are
+const passphraseSeal = "hpp1-aes-256-gcm"
+
+// passphraseSealer seals keys with a passphrase. Stretched keys are cached,
+// so only the first signature with each key pays for HPP-1.
+type passphraseSealer struct {
+	passphrase string
+	kdf        *crypto.HPP1Cache
+}
+
+func newPassphraseSealer(passphrase string) *passphraseSealer {
+	return &passphraseSealer{passphrase: passphrase, kdf: crypto.NewHPP1Cache(64)}
+}
+
+func (p *passphraseSealer) seal(f *keyFile, key []byte) error {
+	f.Seal = passphraseSeal
+	f.KDFRounds = crypto.HPP1Rounds
+	f.Salt = make([]byte, 16)
+	f.Nonce = make([]byte, 12)
+	if _, err := rand.Read(f.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	aead, err := p.cipher(f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, key, f.additionalData())
+	return nil
+}
+
+func (p *passphraseSealer) open(f *keyFile) ([]byte, error) {
+	if f.Seal != passphraseSeal {
+		return nil, fmt.Errorf("key %s is sealed with %q, not a passphrase", f.Name, f.Seal)
+	}
+	if f.KDFRounds != crypto.HPP1Rounds {
+		return nil, fmt.Errorf("unsupported kdf rounds %d", f.KDFRounds)
+	}
+	aead, err := p.cipher(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := aead.Open(nil, f.Nonce, f.Ciphertext, f.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("%w for key %s", ErrWrongPassphrase, f.Name)
+	}
+	return key, nil
+}
+
+func (p *passphraseSealer) cipher(salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(p.kdf.HPP1([]byte(p.passphrase), salt, 32))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,171 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// keychainBackend keeps keys as hex secrets in the OS keychain: the login
+// keychain through security(1) on macOS, the Secret Service (GNOME Keyring,
+// KWallet) through secret-tool(1) on Linux. Keys are items of service,
+// with the key name as account.
+type keychainBackend struct {
+	service string
+	goos    string
+	// run runs a keychain command with stdin and returns its output
+	run func(stdin string, name string, args ...string) (string, error)
+}
+
+// errNoItem is returned by run when the keychain has no such item
+var errNoItem = errors.New("no keychain item")
+
+func newKeychainBackend(service string) (*keychainBackend, error) {
+	k := &keychainBackend{service: service, goos: runtime.GOOS, run: runKeychainCommand}
+	if k.goos != "darwin" && k.goos != "linux" {
+		return nil, fmt.Errorf("%w: no keychain on %s", ErrUnsupported, k.goos)
+	}
+	return k, nil
+}
+
+// runKeychainCommand runs a keychain tool. Both tools exit with status 44
+// (security) or 1 (secret-tool) and print nothing when an item is missing.
+func runKeychainCommand(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && (exit.ExitCode() == 44 || exit.ExitCode() == 1 && stderr.Len() == 0) {
+		return "", errNoItem
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (k *keychainBackend) load(name string) ([]byte, error) {
+	var out string
+	var err error
+	if k.goos == "darwin" {
+		out, err = k.run("", "security", "find-generic-password", "-s", k.service, "-a", name, "-w")
+	} else {
+		out, err = k.run("", "secret-tool", "lookup", "service", k.service, "account", name)
+	}
+	if errors.Is(err, errNoItem) || err == nil && strings.TrimSpace(out) == "" {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keychain: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(out))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("keychain item %s/%s is not a key", k.service, name)
+	}
+	return key, nil
+}
+
+func (k *keychainBackend) publicKey(name string) (*btcec.PublicKey, error) {
+	raw, err := k.load(name)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(raw)
+	key, pub := btcec.PrivKeyFromBytes(raw)
+	key.Zero()
+	return pub, nil
+}
+
+func (k *keychainBackend) save(name string, key []byte, pub *btcec.PublicKey) error {
+	if _, err := k.load(name); err == nil {
+		return fmt.Errorf("%w: %s", ErrKeyExists, name)
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	secret := hex.EncodeToString(key)
+	var err error
+	if k.goos == "darwin" {
+		// security(1) only takes the secret as an argument
+		_, err = k.run("", "security", "add-generic-password", "-s", k.service, "-a", name,
+			"-l", "EXS key "+name, "-w", secret)
+	} else {
+		_, err = k.run(secret, "secret-tool", "store", "--label=EXS key "+name, "service", k.service, "account", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write keychain: %w", err)
+	}
+	return nil
+}
+
+func (k *keychainBackend) remove(name string) error {
+	var err error
+	if k.goos == "darwin" {
+		_, err = k.run("", "security", "delete-generic-password", "-s", k.service, "-a", name)
+	} else {
+		if _, err = k.load(name); err != nil {
+			return err
+		}
+		_, err = k.run("", "secret-tool", "clear", "service", k.service, "account", name)
+	}
+	if errors.Is(err, errNoItem) {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	return err
+}
+
+func (k *keychainBackend) names() ([]string, error) {
+	if k.goos == "darwin" {
+		out, err := k.run("", "security", "dump-keychain")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list keychain: %w", err)
+		}
+		return parseDumpKeychain(out, k.service), nil
+	}
+	out, err := k.run("", "secret-tool", "search", "--all", "service", k.service)
+	if errors.Is(err, errNoItem) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keychain: %w", err)
+	}
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), "attribute.account = "); ok && ValidKeyName(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// parseDumpKeychain returns the accounts of service's items in the output
+// of security dump-keychain, which lists each item's attributes as lines
+// such as
+//
+//	"acct"<blob>="treasury-vault"
+//	"svce"<blob>="exs"
+func parseDumpKeychain(out, service string) []string {
+	var names []string
+	for _, item := range strings.Split(out, "keychain: ") {
+		var account, svc string
+		for _, line := range strings.Split(item, "\n") {
+			line = strings.TrimSpace(line)
+			if v, ok := strings.CutPrefix(line, `"acct"<blob>=`); ok {
+				account = strings.Trim(v, `"`)
+			} else if v, ok := strings.CutPrefix(line, `"svce"<blob>=`); ok {
+				svc = strings.Trim(v, `"`)
+			}
+		}
+		if svc == service && ValidKeyName(account) {
+			names = append(names, account)
+		}
+	}
+	return names
+}
@@ -0,0 +1,298 @@
+// Package keystore keeps the secp256k1 keys that sign for the treasury, such
+// as vault spend keys and distribution and emergency signer keys, and signs
+// with them on request. Callers hold a Signer rather than a private key;
+// backends unseal a key only for the signature it makes.
+//
+// Keys live in a directory sealed with a passphrase (file), in the OS
+// keychain (keychain), or in a directory sealed by a HashiCorp Vault Transit
+// key (transit).
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+var (
+	// ErrKeyNotFound indicates no key has the name asked for
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyExists indicates a key of that name is already stored
+	ErrKeyExists = errors.New("key already exists")
+	// ErrInvalidKeyName indicates a name a key cannot be stored under
+	ErrInvalidKeyName = errors.New("invalid key name")
+	// ErrWrongPassphrase indicates a key that could not be unsealed
+	ErrWrongPassphrase = errors.New("wrong keystore passphrase")
+	// ErrUnsupported indicates a backend this build cannot use
+	ErrUnsupported = errors.New("unsupported keystore")
+)
+
+// Signer signs with one secp256k1 key
+type Signer interface {
+	// PubKey returns the key's public key
+	PubKey() *btcec.PublicKey
+	// SignSchnorr signs a 32-byte digest with BIP-340
+	SignSchnorr(digest []byte) (*schnorr.Signature, error)
+	// SignTaproot signs a 32-byte sighash with the key tweaked by BIP-341
+	// to commit to scriptRoot, nil for a BIP-86 key
+	SignTaproot(digest, scriptRoot []byte) (*schnorr.Signature, error)
+}
+
+// Keystore holds named keys
+type Keystore interface {
+	// Generate creates a random key named name
+	Generate(name string) (*btcec.PublicKey, error)
+	// Import stores key as name
+	Import(name string, key *btcec.PrivateKey) error
+	// PublicKey returns the public key of name
+	PublicKey(name string) (*btcec.PublicKey, error)
+	// List returns the names of the stored keys, sorted
+	List() ([]string, error)
+	// Delete removes name
+	Delete(name string) error
+	// Signer returns a Signer for name
+	Signer(name string) (Signer, error)
+}
+
+// keyNamePattern restricts key names to what every backend can store
+var keyNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidKeyName reports whether name can name a key
+func ValidKeyName(name string) bool {
+	return keyNamePattern.MatchString(name)
+}
+
+// Open opens a keystore from spec:
+//
+//	file:DIR         keys in DIR sealed with passphrase
+//	keychain[:NAME]  the OS keychain, under service NAME ("exs" by default)
+//	transit:DIR      keys in DIR sealed by the Vault Transit key
+//	                 EXS_KEYSTORE_TRANSIT_KEY ("exs" by default) at
+//	                 VAULT_ADDR, authenticated with VAULT_TOKEN
+func Open(spec, passphrase string) (Keystore, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "file":
+		if arg == "" {
+			return nil, errors.New("file keystore needs a directory")
+		}
+		if passphrase == "" {
+			return nil, errors.New("file keystore needs a passphrase")
+		}
+		return newStore(&dirBackend{dir: arg, sealer: newPassphraseSealer(passphrase)}), nil
+	case "keychain":
+		if arg == "" {
+			arg = "exs"
+		}
+		b, err := newKeychainBackend(arg)
+		if err != nil {
+			return nil, err
+		}
+		return newStore(b), nil
+	case "transit":
+		if arg == "" {
+			return nil, errors.New("transit keystore needs a directory")
+		}
+		sealer, err := transitSealerFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return newStore(&dirBackend{dir: arg, sealer: sealer}), nil
+	case "pkcs11":
+		// secp256k1 BIP-340 signatures, which every key here makes, are
+		// not a PKCS#11 mechanism, and cloud KMS keys sign ECDSA at best
+		return nil, fmt.Errorf("%w: pkcs11 tokens cannot make BIP-340 signatures; use transit to keep keys sealed by a KMS", ErrUnsupported)
+	}
+	return nil, fmt.Errorf("unknown keystore: %q (use file, keychain or transit)", spec)
+}
+
+// FromEnv opens the EXS_KEYSTORE keystore, with EXS_KEYSTORE_PASSPHRASE for
+// a file keystore, or returns nil when it is unset
+func FromEnv() (Keystore, error) {
+	spec := os.Getenv("EXS_KEYSTORE")
+	if spec == "" {
+		return nil, nil
+	}
+	ks, err := Open(spec, os.Getenv("EXS_KEYSTORE_PASSPHRASE"))
+	if err != nil {
+		return nil, fmt.Errorf("EXS_KEYSTORE: %w", err)
+	}
+	return ks, nil
+}
+
+// backend keeps raw private keys by name, sealed however it stores them
+type backend interface {
+	// load returns the 32-byte private key of name
+	load(name string) ([]byte, error)
+	// publicKey returns the public key of name, unsealing only if needed
+	publicKey(name string) (*btcec.PublicKey, error)
+	// save stores a new key, refusing to replace one
+	save(name string, key []byte, pub *btcec.PublicKey) error
+	remove(name string) error
+	names() ([]string, error)
+}
+
+// store implements Keystore over a backend, caching public keys
+type store struct {
+	b    backend
+	mu   sync.Mutex
+	pubs map[string]*btcec.PublicKey
+}
+
+func newStore(b backend) *store {
+	return &store{b: b, pubs: make(map[string]*btcec.PublicKey)}
+}
+
+func (s *store) Generate(name string) (*btcec.PublicKey, error) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	defer key.Zero()
+	if err := s.Import(name, key); err != nil {
+		return nil, err
+	}
+	return key.PubKey(), nil
+}
+
+func (s *store) Import(name string, key *btcec.PrivateKey) error {
+	if !ValidKeyName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidKeyName, name)
+	}
+	raw := key.Serialize()
+	defer clear(raw)
+	if err := s.b.save(name, raw, key.PubKey()); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.pubs[name] = key.PubKey()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *store) PublicKey(name string) (*btcec.PublicKey, error) {
+	if !ValidKeyName(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKeyName, name)
+	}
+	s.mu.Lock()
+	pub, ok := s.pubs[name]
+	s.mu.Unlock()
+	if ok {
+		return pub, nil
+	}
+	pub, err := s.b.publicKey(name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.pubs[name] = pub
+	s.mu.Unlock()
+	return pub, nil
+}
+
+func (s *store) List() ([]string, error) {
+	names, err := s.b.names()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *store) Delete(name string) error {
+	if !ValidKeyName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidKeyName, name)
+	}
+	if err := s.b.remove(name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.pubs, name)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *store) Signer(name string) (Signer, error) {
+	pub, err := s.PublicKey(name)
+	if err != nil {
+		return nil, err
+	}
+	return &storeSigner{s: s, name: name, pub: pub}, nil
+}
+
+// storeSigner unseals its key for each signature and wipes it after
+type storeSigner struct {
+	s    *store
+	name string
+	pub  *btcec.PublicKey
+}
+
+func (k *storeSigner) PubKey() *btcec.PublicKey {
+	return k.pub
+}
+
+func (k *storeSigner) SignSchnorr(digest []byte) (*schnorr.Signature, error) {
+	return k.sign(func(key *btcec.PrivateKey) (*schnorr.Signature, error) {
+		return schnorr.Sign(key, digest)
+	})
+}
+
+func (k *storeSigner) SignTaproot(digest, scriptRoot []byte) (*schnorr.Signature, error) {
+	return k.sign(func(key *btcec.PrivateKey) (*schnorr.Signature, error) {
+		return signTaproot(key, digest, scriptRoot)
+	})
+}
+
+func (k *storeSigner) sign(sign func(*btcec.PrivateKey) (*schnorr.Signature, error)) (*schnorr.Signature, error) {
+	raw, err := k.s.b.load(k.name)
+	if err != nil {
+		return nil, err
+	}
+	key, _ := btcec.PrivKeyFromBytes(raw)
+	clear(raw)
+	defer key.Zero()
+	if !key.PubKey().IsEqual(k.pub) {
+		return nil, fmt.Errorf("key %s changed since it was opened", k.name)
+	}
+	return sign(key)
+}
+
+// signTaproot signs digest with key tweaked to commit to scriptRoot
+func signTaproot(key *btcec.PrivateKey, digest, scriptRoot []byte) (*schnorr.Signature, error) {
+	if scriptRoot == nil {
+		scriptRoot = []byte{}
+	}
+	tweaked := txscript.TweakTaprootPrivKey(*key, scriptRoot)
+	defer tweaked.Zero()
+	return schnorr.Sign(tweaked, digest)
+}
+
+// PrivateKeySigner signs with a key held in memory, such as one read from
+// the terminal or derived from a wallet
+func PrivateKeySigner(key *btcec.PrivateKey) Signer {
+	return memorySigner{key}
+}
+
+type memorySigner struct {
+	key *btcec.PrivateKey
+}
+
+func (m memorySigner) PubKey() *btcec.PublicKey {
+	return m.key.PubKey()
+}
+
+func (m memorySigner) SignSchnorr(digest []byte) (*schnorr.Signature, error) {
+	return schnorr.Sign(m.key, digest)
+}
+
+func (m memorySigner) SignTaproot(digest, scriptRoot []byte) (*schnorr.Signature, error) {
+	return signTaproot(m.key, digest, scriptRoot)
+}
@@ -0,0 +1,277 @@
+package keystore
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+)
+
+// checkKeystore runs a keystore through its lifecycle
+func checkKeystore(t *testing.T, ks Keystore) {
+	t.Helper()
+	pub, err := ks.Generate("distribution-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Generate("distribution-1"); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("Generating over a key = %v, want ErrKeyExists", err)
+	}
+	if _, err := ks.Generate("../escape"); !errors.Is(err, ErrInvalidKeyName) {
+		t.Errorf("Generating ../escape = %v, want ErrInvalidKeyName", err)
+	}
+	imported, _ := btcec.NewPrivateKey()
+	if err := ks.Import("emergency", imported); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := ks.List(); err != nil || strings.Join(names, ",") != "distribution-1,emergency" {
+		t.Errorf("List() = %v, %v", names, err)
+	}
+
+	signer, err := ks.Signer("distribution-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signer.PubKey().IsEqual(pub) {
+		t.Error("Signer has a different public key")
+	}
+	digest := sha256.Sum256([]byte("proposal"))
+	sig, err := signer.SignSchnorr(digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Verify(digest[:], pub) {
+		t.Error("Signature does not verify")
+	}
+
+	if err := ks.Delete("emergency"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Signer("emergency"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Signer of a deleted key = %v, want ErrKeyNotFound", err)
+	}
+	if err := ks.Delete("emergency"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Deleting again = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestFileKeystore(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open("file:"+dir, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKeystore(t, ks)
+
+	data, err := os.ReadFile(filepath.Join(dir, "distribution-1.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "distribution-1.key")); info.Mode().Perm() != 0600 {
+		t.Errorf("Key file mode = %v", info.Mode())
+	}
+
+	// The public key is readable without the passphrase, the key is not
+	wrong, _ := Open("file:"+dir, "wrong")
+	signer, err := wrong.Signer("distribution-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("proposal"))
+	if _, err := signer.SignSchnorr(digest[:]); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Signing with the wrong passphrase = %v", err)
+	}
+
+	// A seal moved to another name does not open
+	var f keyFile
+	json.Unmarshal(data, &f)
+	f.Name = "moved"
+	moved, _ := json.Marshal(f)
+	os.WriteFile(filepath.Join(dir, "moved.key"), moved, 0600)
+	if signer, err := ks.Signer("moved"); err != nil {
+		t.Fatal(err)
+	} else if _, err := signer.SignSchnorr(digest[:]); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Signing with a renamed key = %v", err)
+	}
+}
+
+// fakeTransit serves Vault Transit encrypt and decrypt, reversing the
+// plaintext as its "encryption"
+func fakeTransit(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string][]string{"errors": {"permission denied"}})
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		reverse := func(b []byte) []byte {
+			out := make([]byte, len(b))
+			for i := range b {
+				out[len(b)-1-i] = b[i]
+			}
+			return out
+		}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/exs":
+			plaintext, _ := base64.StdEncoding.DecodeString(body["plaintext"])
+			ciphertext := "vault:v1:" + base64.StdEncoding.EncodeToString(reverse(plaintext))
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"ciphertext": ciphertext}})
+		case "/v1/transit/decrypt/exs":
+			sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(body["ciphertext"], "vault:v1:"))
+			plaintext := base64.StdEncoding.EncodeToString(reverse(sealed))
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": plaintext}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTransitKeystore(t *testing.T) {
+	srv := fakeTransit(t)
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "s.token")
+	dir := t.TempDir()
+	ks, err := Open("transit:"+dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKeystore(t, ks)
+
+	// Without a valid token Vault refuses to unseal
+	t.Setenv("VAULT_TOKEN", "s.revoked")
+	revoked, _ := Open("transit:"+dir, "")
+	signer, _ := revoked.Signer("distribution-1")
+	digest := sha256.Sum256([]byte("proposal"))
+	if _, err := signer.SignSchnorr(digest[:]); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Signing with a revoked token = %v", err)
+	}
+}
+
+func TestKeychainKeystore(t *testing.T) {
+	items := map[string]string{}
+	b := &keychainBackend{service: "exs", goos: "linux"}
+	b.run = func(stdin string, name string, args ...string) (string, error) {
+		if name != "secret-tool" || !strings.Contains(strings.Join(args, " "), "service exs") {
+			t.Fatalf("Unexpected command %s %v", name, args)
+		}
+		switch args[0] {
+		case "store":
+			items[args[len(args)-1]] = stdin
+			return "", nil
+		case "lookup":
+			if secret, ok := items[args[4]]; ok {
+				return secret + "\n", nil
+			}
+			return "", errNoItem
+		case "clear":
+			delete(items, args[4])
+			return "", nil
+		case "search":
+			var out string
+			for account := range items {
+				out += "[/1]\nlabel = EXS key\nattribute.service = exs\nattribute.account = " + account + "\n"
+			}
+			return out, nil
+		}
+		return "", errors.New("unexpected command")
+	}
+	checkKeystore(t, newStore(b))
+}
+
+func TestParseDumpKeychain(t *testing.T) {
+	out := `keychain: "/Users/arthur/Library/Keychains/login.keychain-db"
+class: "genp"
+attributes:
+    "acct"<blob>="treasury-vault"
+    "svce"<blob>="exs"
+keychain: "/Users/arthur/Library/Keychains/login.keychain-db"
+class: "genp"
+attributes:
+    "acct"<blob>="arthur"
+    "svce"<blob>="mail"
+`
+	if names := parseDumpKeychain(out, "exs"); len(names) != 1 || names[0] != "treasury-vault" {
+		t.Errorf("parseDumpKeychain() = %v", names)
+	}
+}
+
+func TestParseTaprootKeySpendsVault(t *testing.T) {
+	ks, err := Open("file:"+t.TempDir(), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	internal, _ := btcec.NewPrivateKey()
+	words := strings.Fields("sword legend pull magic kingdom artist stone destroy forget fire steel honey question")
+	vault, err := bitcoin.NewTaprootVault(internal, words, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Import("treasury-vault", internal); err != nil {
+		t.Fatal(err)
+	}
+
+	ref := TaprootKeyPrefix + "treasury-vault:" + hex.EncodeToString(vault.TweakHash)
+	key, err := ParseTaprootKey(ref, ks, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.PrivKey != nil || !key.OutputKey().IsEqual(vault.OutputKey) {
+		t.Fatalf("Keystore key does not spend the vault")
+	}
+
+	pkScript, _ := key.PkScript()
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(90_000, pkScript))
+	spend := &bitcoin.Spend{Tx: tx, PrevOuts: []*wire.TxOut{wire.NewTxOut(100_000, pkScript)}}
+	if err := spend.Sign([]*bitcoin.TaprootKey{key}); err != nil {
+		t.Fatal(err)
+	}
+	if err := spend.Verify(); err != nil {
+		t.Errorf("Keystore-signed spend does not verify: %v", err)
+	}
+
+	if _, err := ParseTaprootKey(ref, nil, &chaincfg.RegressionNetParams); err == nil {
+		t.Error("Expected a keystore reference without a keystore refused")
+	}
+}
+
+func TestPrivateKeySigner(t *testing.T) {
+	key, _ := btcec.NewPrivateKey()
+	signer := PrivateKeySigner(key)
+	digest := sha256.Sum256([]byte("resume"))
+	sig, err := signer.SignSchnorr(digest[:])
+	if err != nil || !sig.Verify(digest[:], key.PubKey()) {
+		t.Errorf("SignSchnorr() = %v, %v", sig, err)
+	}
+}
+
+func TestOpenRefuses(t *testing.T) {
+	if _, err := Open("pkcs11:/usr/lib/softhsm/libsofthsm2.so", ""); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Open(pkcs11) = %v, want ErrUnsupported", err)
+	}
+	for _, spec := range []string{"file:/tmp/keys", "file:", "transit:", "vault"} {
+		if _, err := Open(spec, ""); err == nil {
+			t.Errorf("Open(%q) succeeded", spec)
+		}
+	}
+}
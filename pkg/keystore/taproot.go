@@ -0,0 +1,40 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+)
+
+// TaprootKeyPrefix marks a Taproot key held in a keystore
+const TaprootKeyPrefix = "keystore:"
+
+// ParseTaprootKey parses a Taproot spend key as bitcoin.ParseTaprootKey
+// does, or a reference "keystore:NAME[:ROOT]" to key NAME in ks, optionally
+// committing to the hex script root ROOT, e.g. the TweakHash of a vault
+func ParseTaprootKey(s string, ks Keystore, net *chaincfg.Params) (*bitcoin.TaprootKey, error) {
+	ref, ok := strings.CutPrefix(strings.TrimSpace(s), TaprootKeyPrefix)
+	if !ok {
+		return bitcoin.ParseTaprootKey(s, net)
+	}
+	if ks == nil {
+		return nil, errors.New("a keystore key needs EXS_KEYSTORE")
+	}
+	name, rootHex, hasRoot := strings.Cut(ref, ":")
+	signer, err := ks.Signer(name)
+	if err != nil {
+		return nil, err
+	}
+	key := &bitcoin.TaprootKey{Signer: signer}
+	if hasRoot {
+		if key.ScriptRoot, err = hex.DecodeString(rootHex); err != nil || len(key.ScriptRoot) != 32 {
+			return nil, fmt.Errorf("invalid script root %q", rootHex)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,121 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// transitSeal names the Vault Transit seal
+const transitSeal = "vault-transit"
+
+// transitSealer seals keys with a HashiCorp Vault Transit key, so the key
+// files are useless without Vault, which authorizes and audits every
+// unseal. The plaintext carries the file's additional data, binding the
+// seal to the key's name and public key.
+type transitSealer struct {
+	addr   string // e.g. https://vault:8200
+	mount  string
+	key    string
+	token  string
+	client *http.Client
+}
+
+// transitSealerFromEnv reads VAULT_ADDR, VAULT_TOKEN,
+// EXS_KEYSTORE_TRANSIT_MOUNT ("transit" by default) and
+// EXS_KEYSTORE_TRANSIT_KEY ("exs" by default)
+func transitSealerFromEnv() (*transitSealer, error) {
+	t := &transitSealer{
+		addr:   strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"),
+		mount:  os.Getenv("EXS_KEYSTORE_TRANSIT_MOUNT"),
+		key:    os.Getenv("EXS_KEYSTORE_TRANSIT_KEY"),
+		token:  os.Getenv("VAULT_TOKEN"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	if t.addr == "" || t.token == "" {
+		return nil, errors.New("transit keystore needs VAULT_ADDR and VAULT_TOKEN")
+	}
+	if t.mount == "" {
+		t.mount = "transit"
+	}
+	if t.key == "" {
+		t.key = "exs"
+	}
+	return t, nil
+}
+
+func (t *transitSealer) seal(f *keyFile, key []byte) error {
+	plaintext := append(f.additionalData(), key...)
+	defer clear(plaintext)
+	var reply struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := t.call("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}, &reply); err != nil {
+		return err
+	}
+	f.Seal = transitSeal + ":" + t.key
+	f.Ciphertext = []byte(reply.Ciphertext)
+	return nil
+}
+
+func (t *transitSealer) open(f *keyFile) ([]byte, error) {
+	if f.Seal != transitSeal+":"+t.key {
+		return nil, fmt.Errorf("key %s is sealed with %q, not Transit key %s", f.Name, f.Seal, t.key)
+	}
+	var reply struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := t.call("decrypt", map[string]string{"ciphertext": string(f.Ciphertext)}, &reply); err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(reply.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Transit plaintext: %w", err)
+	}
+	ad := f.additionalData()
+	if len(plaintext) != len(ad)+32 || subtle.ConstantTimeCompare(plaintext[:len(ad)], ad) != 1 {
+		clear(plaintext)
+		return nil, fmt.Errorf("key file %s does not match its seal", f.Name)
+	}
+	return plaintext[len(ad):], nil
+}
+
+// call posts body to the Transit operation op and decodes its data
+func (t *transitSealer) call(op string, body any, data any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.addr+"/v1/"+t.mount+"/"+op+"/"+t.key, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", t.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Transit %s failed: %w", op, err)
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("Transit %s: invalid response: %w", op, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Transit %s: %s: %s", op, resp.Status, strings.Join(reply.Errors, "; "))
+	}
+	if err := json.Unmarshal(reply.Data, data); err != nil {
+		return fmt.Errorf("Transit %s: invalid response: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Badger is a Store in a Badger directory. Buckets share one keyspace, each
+// key prefixed with its bucket name and a zero byte.
+type Badger struct {
+	db *badger.DB
+}
+
+// OpenBadger opens (or creates) a Badger database in dir
+func OpenBadger(dir string) (*Badger, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open badger store: %w", err)
+	}
+	return &Badger{db: db}, nil
+}
+
+// View runs fn in a read-only Badger transaction
+func (b *Badger) View(fn func(tx Tx) error) error {
+	return b.db.View(func(txn *badger.Txn) error {
+		return fn(badgerTx{txn})
+	})
+}
+
+// Update runs fn in a read-write Badger transaction. Badger bounds the size
+// of a transaction, failing larger ones with badger.ErrTxnTooBig.
+func (b *Badger) Update(fn func(tx Tx) error) error {
+	return b.db.Update(func(txn *badger.Txn) error {
+		return fn(badgerTx{txn})
+	})
+}
+
+// Close closes the database
+func (b *Badger) Close() error {
+	return b.db.Close()
+}
+
+// flatKey places key in bucket within a single keyspace
+func flatKey(bucket string, key []byte) []byte {
+	flat := make([]byte, 0, len(bucket)+1+len(key))
+	flat = append(flat, bucket...)
+	flat = append(flat, 0)
+	return append(flat, key...)
+}
+
+type badgerTx struct {
+	txn *badger.Txn
+}
+
+func (t badgerTx) Get(bucket string, key []byte) ([]byte, error) {
+	item, err := t.txn.Get(flatKey(bucket, key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t badgerTx) Put(bucket string, key, value []byte) error {
+	err := t.txn.Set(flatKey(bucket, key), append([]byte{}, value...))
+	if errors.Is(err, badger.ErrReadOnlyTxn) {
+		return ErrReadOnly
+	}
+	return err
+}
+
+func (t badgerTx) Delete(bucket string, key []byte) error {
+	err := t.txn.Delete(flatKey(bucket, key))
+	if errors.Is(err, badger.ErrReadOnlyTxn) {
+		return ErrReadOnly
+	}
+	return err
+}
+
+func (t badgerTx) Iterate(bucket string, prefix []byte, fn func(key, value []byte) error) error {
+	start := flatKey(bucket, prefix)
+	it := t.txn.NewIterator(badger.IteratorOptions{Prefix: start, PrefetchValues: true, PrefetchSize: 100})
+	defer it.Close()
+	for it.Seek(start); it.ValidForPrefix(start); it.Next() {
+		item := it.Item()
+		err := item.Value(func(value []byte) error {
+			return fn(item.Key()[len(bucket)+1:], value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
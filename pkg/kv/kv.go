@@ -0,0 +1,153 @@
+// Package kv is the key/value storage shared by the EXS services. A Store
+// holds named buckets of byte keys, read and written in transactions, and
+// has BoltDB, Badger, LevelDB and in-memory implementations, so a service
+// can swap its backend through configuration.
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Storage backends accepted by Open
+const (
+	BackendBolt    = "bolt"
+	BackendBadger  = "badger"
+	BackendLevelDB = "leveldb"
+	BackendMemory  = "memory"
+)
+
+var (
+	// ErrNotFound indicates a key that is not in its bucket
+	ErrNotFound = errors.New("key not found")
+	// ErrReadOnly indicates a write in a View transaction
+	ErrReadOnly = errors.New("write in read-only transaction")
+	// ErrUnknownBackend indicates a backend name Open does not know
+	ErrUnknownBackend = errors.New("unknown storage backend")
+)
+
+// Store is a transactional key/value store of named buckets. Buckets exist
+// once written to; reading a bucket never written to finds nothing.
+type Store interface {
+	// View runs fn in a read-only transaction
+	View(fn func(tx Tx) error) error
+	// Update runs fn in a read-write transaction, committed when fn returns
+	// nil and discarded otherwise
+	Update(fn func(tx Tx) error) error
+	// Close releases the store
+	Close() error
+}
+
+// Tx reads and writes a store within a transaction. It is only valid
+// during the function it was passed to.
+type Tx interface {
+	// Get returns a copy of the value of key, or ErrNotFound
+	Get(bucket string, key []byte) ([]byte, error)
+	// Put sets the value of key
+	Put(bucket string, key, value []byte) error
+	// Delete removes key, if present
+	Delete(bucket string, key []byte) error
+	// Iterate calls fn for every key starting with prefix, in key order,
+	// stopping at the first error. key and value are only valid during fn.
+	Iterate(bucket string, prefix []byte, fn func(key, value []byte) error) error
+}
+
+// Open opens a store of backend at path, a file for bolt and a directory
+// for badger and leveldb, creating it and its parent directories if
+// needed. path is ignored for memory.
+func Open(backend, path string) (Store, error) {
+	if backend == BackendMemory {
+		return NewMemory(), nil
+	}
+	if backend != BackendBolt && backend != BackendBadger && backend != BackendLevelDB {
+		return nil, fmt.Errorf("%w: %q (use bolt, badger, leveldb or memory)", ErrUnknownBackend, backend)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	switch backend {
+	case BackendBolt:
+		return OpenBolt(path)
+	case BackendBadger:
+		return OpenBadger(path)
+	}
+	return OpenLevelDB(path, 0)
+}
+
+// Get reads one key in its own transaction
+func Get(s Store, bucket string, key []byte) ([]byte, error) {
+	var value []byte
+	err := s.View(func(tx Tx) error {
+		var err error
+		value, err = tx.Get(bucket, key)
+		return err
+	})
+	return value, err
+}
+
+// Put writes one key in its own transaction
+func Put(s Store, bucket string, key, value []byte) error {
+	return s.Update(func(tx Tx) error {
+		return tx.Put(bucket, key, value)
+	})
+}
+
+// Delete removes one key in its own transaction
+func Delete(s Store, bucket string, key []byte) error {
+	return s.Update(func(tx Tx) error {
+		return tx.Delete(bucket, key)
+	})
+}
+
+// Iterate walks the keys starting with prefix in a read-only transaction
+func Iterate(s Store, bucket string, prefix []byte, fn func(key, value []byte) error) error {
+	return s.View(func(tx Tx) error {
+		return tx.Iterate(bucket, prefix, fn)
+	})
+}
+
+// Batch collects writes to apply together in one transaction
+type Batch struct {
+	ops []batchOp
+}
+
+type batchOp struct {
+	bucket string
+	key    []byte
+	value  []byte // nil for a delete
+}
+
+// Put records setting key to value
+func (b *Batch) Put(bucket string, key, value []byte) {
+	b.ops = append(b.ops, batchOp{bucket: bucket, key: key, value: append([]byte{}, value...)})
+}
+
+// Delete records removing key
+func (b *Batch) Delete(bucket string, key []byte) {
+	b.ops = append(b.ops, batchOp{bucket: bucket, key: key})
+}
+
+// Len returns the number of writes recorded
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Write applies the batch to s atomically
+func (b *Batch) Write(s Store) error {
+	return s.Update(func(tx Tx) error {
+		for _, op := range b.ops {
+			var err error
+			if op.value == nil {
+				err = tx.Delete(op.bucket, op.key)
+			} else {
+				err = tx.Put(op.bucket, op.key, op.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
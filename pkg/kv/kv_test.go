@@ -0,0 +1,169 @@
+package kv
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// forEachBackend runs test against a fresh store of every backend
+func forEachBackend(t *testing.T, test func(t *testing.T, s Store)) {
+	for _, backend := range []string{BackendMemory, BackendBolt, BackendBadger, BackendLevelDB} {
+		t.Run(backend, func(t *testing.T) {
+			s, err := Open(backend, filepath.Join(t.TempDir(), "store", "db"))
+			if err != nil {
+				t.Fatalf("Open(%s) error = %v", backend, err)
+			}
+			defer s.Close()
+			test(t, s)
+		})
+	}
+}
+
+func TestGetPutDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		if _, err := Get(s, "a", []byte("k")); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected ErrNotFound, got %v", err)
+		}
+		if err := Put(s, "a", []byte("k"), []byte("v1")); err != nil {
+			t.Fatal(err)
+		}
+		if err := Put(s, "b", []byte("k"), []byte("v2")); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := Get(s, "a", []byte("k")); err != nil || string(value) != "v1" {
+			t.Errorf("Get(a) = %q, %v", value, err)
+		}
+		if value, err := Get(s, "b", []byte("k")); err != nil || string(value) != "v2" {
+			t.Errorf("Get(b) = %q, %v", value, err)
+		}
+		if err := Delete(s, "a", []byte("k")); err != nil {
+			t.Fatal(err)
+		}
+		if err := Delete(s, "missing", []byte("k")); err != nil {
+			t.Errorf("Delete() of a missing key error = %v", err)
+		}
+		if _, err := Get(s, "a", []byte("k")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+		}
+	})
+}
+
+func TestIterate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		err := s.Update(func(tx Tx) error {
+			for _, key := range []string{"p/3", "p/1", "q/1", "p/2", "o"} {
+				if err := tx.Put("bucket", []byte(key), []byte(key)); err != nil {
+					return err
+				}
+			}
+			return tx.Put("other", []byte("p/0"), nil)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		err = Iterate(s, "bucket", []byte("p/"), func(key, value []byte) error {
+			if string(key) != string(value) {
+				t.Errorf("Key %q has value %q", key, value)
+			}
+			keys = append(keys, string(key))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 3 || keys[0] != "p/1" || keys[1] != "p/2" || keys[2] != "p/3" {
+			t.Errorf("Iterate() = %v, expected [p/1 p/2 p/3]", keys)
+		}
+
+		stop := errors.New("stop")
+		count := 0
+		err = Iterate(s, "bucket", nil, func(key, value []byte) error {
+			count++
+			return stop
+		})
+		if !errors.Is(err, stop) || count != 1 {
+			t.Errorf("Expected Iterate to stop at the first error, got %v after %d", err, count)
+		}
+	})
+}
+
+func TestUpdateRollback(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		if err := Put(s, "a", []byte("kept"), []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+		failed := errors.New("failed")
+		err := s.Update(func(tx Tx) error {
+			if err := tx.Put("a", []byte("new"), []byte("2")); err != nil {
+				return err
+			}
+			if err := tx.Delete("a", []byte("kept")); err != nil {
+				return err
+			}
+			// Writes are visible within the transaction
+			if _, err := tx.Get("a", []byte("kept")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected the delete visible in its transaction, got %v", err)
+			}
+			if value, err := tx.Get("a", []byte("new")); err != nil || string(value) != "2" {
+				t.Errorf("Expected the put visible in its transaction, got %q, %v", value, err)
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Fatalf("Expected the transaction error, got %v", err)
+		}
+		if _, err := Get(s, "a", []byte("kept")); err != nil {
+			t.Errorf("Expected the delete rolled back, got %v", err)
+		}
+		if _, err := Get(s, "a", []byte("new")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the put rolled back, got %v", err)
+		}
+	})
+}
+
+func TestViewIsReadOnly(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		err := s.View(func(tx Tx) error {
+			return tx.Put("a", []byte("k"), []byte("v"))
+		})
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, got %v", err)
+		}
+	})
+}
+
+func TestBatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		if err := Put(s, "a", []byte("gone"), []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+		var batch Batch
+		batch.Put("a", []byte("k1"), []byte("v1"))
+		batch.Put("b", []byte("k2"), []byte{})
+		batch.Delete("a", []byte("gone"))
+		if batch.Len() != 3 {
+			t.Errorf("Len() = %d, expected 3", batch.Len())
+		}
+		if err := batch.Write(s); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := Get(s, "a", []byte("k1")); err != nil || string(value) != "v1" {
+			t.Errorf("Get(k1) = %q, %v", value, err)
+		}
+		if value, err := Get(s, "b", []byte("k2")); err != nil || len(value) != 0 {
+			t.Errorf("Expected an empty value stored, got %q, %v", value, err)
+		}
+		if _, err := Get(s, "a", []byte("gone")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the batch delete applied, got %v", err)
+		}
+	})
+}
+
+func TestOpenUnknownBackend(t *testing.T) {
+	if _, err := Open("rocksdb", t.TempDir()); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("Expected ErrUnknownBackend, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDB is a Store in a LevelDB directory, with buckets laid out as for
+// Badger. Update transactions are serialized; View transactions read a
+// snapshot.
+type LevelDB struct {
+	db *leveldb.DB
+}
+
+// OpenLevelDB opens (or creates) a LevelDB database in dir. cacheSize is the
+// block cache in bytes, or the LevelDB default when zero.
+func OpenLevelDB(dir string, cacheSize int) (*LevelDB, error) {
+	db, err := leveldb.OpenFile(dir, &opt.Options{BlockCacheCapacity: cacheSize})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open leveldb store: %w", err)
+	}
+	return &LevelDB{db: db}, nil
+}
+
+// DB returns the underlying database, for migrating data written before
+// buckets
+func (l *LevelDB) DB() *leveldb.DB {
+	return l.db
+}
+
+// View runs fn against a snapshot of the database
+func (l *LevelDB) View(fn func(tx Tx) error) error {
+	snap, err := l.db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+	return fn(levelTx{r: snap})
+}
+
+// Update runs fn in a LevelDB transaction
+func (l *LevelDB) Update(fn func(tx Tx) error) error {
+	txn, err := l.db.OpenTransaction()
+	if err != nil {
+		return err
+	}
+	if err := fn(levelTx{r: txn, w: txn}); err != nil {
+		txn.Discard()
+		return err
+	}
+	return txn.Commit()
+}
+
+// Close closes the database
+func (l *LevelDB) Close() error {
+	return l.db.Close()
+}
+
+// levelReader is the read side shared by snapshots and transactions
+type levelReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+type levelTx struct {
+	r levelReader
+	w *leveldb.Transaction // nil in a View
+}
+
+func (t levelTx) Get(bucket string, key []byte) ([]byte, error) {
+	value, err := t.r.Get(flatKey(bucket, key), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (t levelTx) Put(bucket string, key, value []byte) error {
+	if t.w == nil {
+		return ErrReadOnly
+	}
+	return t.w.Put(flatKey(bucket, key), value, nil)
+}
+
+func (t levelTx) Delete(bucket string, key []byte) error {
+	if t.w == nil {
+		return ErrReadOnly
+	}
+	return t.w.Delete(flatKey(bucket, key), nil)
+}
+
+func (t levelTx) Iterate(bucket string, prefix []byte, fn func(key, value []byte) error) error {
+	it := t.r.NewIterator(util.BytesPrefix(flatKey(bucket, prefix)), nil)
+	defer it.Release()
+	for it.Next() {
+		if err := fn(it.Key()[len(bucket)+1:], it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
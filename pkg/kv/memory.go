@@ -0,0 +1,120 @@
+package kv
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+)
+
+// Memory is a non-persistent Store. Update transactions are serialized and
+// see their own writes; View transactions run concurrently.
+type Memory struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]map[string][]byte)}
+}
+
+// View runs fn with read access to the store
+func (m *Memory) View(fn func(tx Tx) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return fn(&memoryTx{m: m})
+}
+
+// Update runs fn, applying its writes only when it succeeds
+func (m *Memory) Update(fn func(tx Tx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := &memoryTx{m: m, writes: make(map[string]map[string][]byte)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for bucket, writes := range tx.writes {
+		b := m.buckets[bucket]
+		if b == nil {
+			b = make(map[string][]byte)
+			m.buckets[bucket] = b
+		}
+		for key, value := range writes {
+			if value == nil {
+				delete(b, key)
+			} else {
+				b[key] = value
+			}
+		}
+	}
+	return nil
+}
+
+// Close is a no-op for the memory store
+func (m *Memory) Close() error {
+	return nil
+}
+
+// memoryTx overlays the writes of an Update on the store; a nil value is a
+// delete. writes is nil in a View.
+type memoryTx struct {
+	m      *Memory
+	writes map[string]map[string][]byte
+}
+
+func (tx *memoryTx) lookup(bucket, key string) ([]byte, bool) {
+	if value, ok := tx.writes[bucket][key]; ok {
+		return value, value != nil
+	}
+	value, ok := tx.m.buckets[bucket][key]
+	return value, ok
+}
+
+func (tx *memoryTx) Get(bucket string, key []byte) ([]byte, error) {
+	value, ok := tx.lookup(bucket, string(key))
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(value), nil
+}
+
+func (tx *memoryTx) write(bucket string, key, value []byte) error {
+	if tx.writes == nil {
+		return ErrReadOnly
+	}
+	if tx.writes[bucket] == nil {
+		tx.writes[bucket] = make(map[string][]byte)
+	}
+	tx.writes[bucket][string(key)] = value
+	return nil
+}
+
+func (tx *memoryTx) Put(bucket string, key, value []byte) error {
+	return tx.write(bucket, key, append([]byte{}, value...))
+}
+
+func (tx *memoryTx) Delete(bucket string, key []byte) error {
+	return tx.write(bucket, key, nil)
+}
+
+func (tx *memoryTx) Iterate(bucket string, prefix []byte, fn func(key, value []byte) error) error {
+	var keys []string
+	for key := range tx.m.buckets[bucket] {
+		if _, written := tx.writes[bucket][key]; !written && bytes.HasPrefix([]byte(key), prefix) {
+			keys = append(keys, key)
+		}
+	}
+	for key, value := range tx.writes[bucket] {
+		if value != nil && bytes.HasPrefix([]byte(key), prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		value, _ := tx.lookup(bucket, key)
+		if err := fn([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
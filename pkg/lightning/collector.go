@@ -0,0 +1,150 @@
+package lightning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+)
+
+// DefaultBatch is how many forwards a collector reads per call
+const DefaultBatch = 1000
+
+// Collector credits the treasury with the fee of each forward of a node.
+// The treasury records the index of the last forward credited, so the
+// collector resumes after it following a restart and never credits a
+// forward twice.
+type Collector struct {
+	treasury    *economy.Treasury
+	node        Node
+	satsPerCoin int64
+	batch       int
+}
+
+// NewCollector creates a collector crediting the fees of node to treasury,
+// converted at satsPerCoin satoshis per EXS; zero credits one exs-satoshi
+// per satoshi
+func NewCollector(treasury *economy.Treasury, node Node, satsPerCoin int64) *Collector {
+	if satsPerCoin <= 0 {
+		satsPerCoin = int64(economy.Coin)
+	}
+	return &Collector{treasury: treasury, node: node, satsPerCoin: satsPerCoin, batch: DefaultBatch}
+}
+
+// Convert returns the EXS a fee of feeMsat millisatoshis is credited as,
+// rounded down
+func (c *Collector) Convert(feeMsat int64) economy.Amount {
+	n := new(big.Int).Mul(big.NewInt(feeMsat), big.NewInt(int64(economy.Coin)))
+	n.Quo(n, new(big.Int).Mul(big.NewInt(c.satsPerCoin), big.NewInt(1000)))
+	return economy.Amount(n.Int64())
+}
+
+// Run credits new forwards every interval until ctx is done
+func (c *Collector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := c.Step(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Lightning fee collection", "credited", n, "err", err)
+		} else if n > 0 {
+			slog.Info("⚡ Lightning routing fees credited", "forwards", n, "last_index", c.treasury.RevenueStream(economy.StreamLightning).LastIndex)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step credits every forward after the last one credited and returns how
+// many it credited. It stops at the first error, which leaves the remaining
+// forwards for the next step.
+func (c *Collector) Step(ctx context.Context) (int, error) {
+	credited := 0
+	for {
+		after := c.treasury.RevenueStream(economy.StreamLightning).LastIndex
+		forwards, err := c.node.Forwards(ctx, after, c.batch)
+		if err != nil {
+			return credited, fmt.Errorf("forwards after %d: %w", after, err)
+		}
+		for _, f := range forwards {
+			err := c.treasury.ProcessLightningRoutingFee(f.Index, f.FeeMsat, c.Convert(f.FeeMsat), f.Time)
+			if errors.Is(err, economy.ErrDuplicateRevenue) {
+				continue
+			}
+			if err != nil {
+				return credited, fmt.Errorf("forward %d: %w", f.Index, err)
+			}
+			credited++
+		}
+		if len(forwards) < c.batch {
+			return credited, nil
+		}
+	}
+}
+
+// Stats summarizes a node's channels and routing
+type Stats struct {
+	Channels       int   `json:"channels"`
+	ActiveChannels int   `json:"active_channels"`
+	Capacity       int64 `json:"capacity"`
+	LocalBalance   int64 `json:"local_balance"`
+	RemoteBalance  int64 `json:"remote_balance"`
+	Forwards       int   `json:"forwards"`
+	VolumeMsat     int64 `json:"volume_msat"`
+	FeesMsat       int64 `json:"fees_msat"`
+	// Fees earned over the last day, week and 30 days
+	Fees24hMsat int64 `json:"fees_24h_msat"`
+	Fees7dMsat  int64 `json:"fees_7d_msat"`
+	Fees30dMsat int64 `json:"fees_30d_msat"`
+}
+
+// ReadStats reads the channels and the whole forwarding history of node and
+// summarizes them as of now
+func ReadStats(ctx context.Context, node Node, now time.Time) (*Stats, error) {
+	channels, err := node.Channels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("channels: %w", err)
+	}
+	var stats Stats
+	for _, ch := range channels {
+		stats.Channels++
+		if ch.Active {
+			stats.ActiveChannels++
+		}
+		stats.Capacity += ch.Capacity
+		stats.LocalBalance += ch.LocalBalance
+		stats.RemoteBalance += ch.RemoteBalance
+	}
+	var after uint64
+	for {
+		forwards, err := node.Forwards(ctx, after, DefaultBatch)
+		if err != nil {
+			return nil, fmt.Errorf("forwards after %d: %w", after, err)
+		}
+		for _, f := range forwards {
+			stats.Forwards++
+			stats.VolumeMsat += f.AmtOutMsat
+			stats.FeesMsat += f.FeeMsat
+			age := now.Sub(f.Time)
+			if age <= 24*time.Hour {
+				stats.Fees24hMsat += f.FeeMsat
+			}
+			if age <= 7*24*time.Hour {
+				stats.Fees7dMsat += f.FeeMsat
+			}
+			if age <= 30*24*time.Hour {
+				stats.Fees30dMsat += f.FeeMsat
+			}
+			after = f.Index
+		}
+		if len(forwards) < DefaultBatch {
+			return &stats, nil
+		}
+	}
+}
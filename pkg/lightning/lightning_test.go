@@ -0,0 +1,177 @@
+package lightning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+)
+
+// forwardTime is when the fake nodes routed their forwards
+var forwardTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeLND serves n forwards paying 1000*i msat fees and one channel
+func fakeLND(n int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Grpc-Metadata-macaroon") != "0201" {
+			http.Error(w, `{"code":2,"message":"verification failed"}`, http.StatusInternalServerError)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/switch":
+			var req struct {
+				IndexOffset  int `json:"index_offset"`
+				NumMaxEvents int `json:"num_max_events"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			events := []map[string]string{}
+			for i := req.IndexOffset + 1; i <= n && len(events) < req.NumMaxEvents; i++ {
+				events = append(events, map[string]string{
+					"timestamp_ns": fmt.Sprint(forwardTime.UnixNano()),
+					"chan_id_in":   "1",
+					"chan_id_out":  "2",
+					"amt_in_msat":  fmt.Sprint(1_000_000 + 1000*i),
+					"amt_out_msat": "1000000",
+					"fee_msat":     fmt.Sprint(1000 * i),
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"forwarding_events": events, "last_offset_index": req.IndexOffset + len(events)})
+		case "/v1/channels":
+			w.Write([]byte(`{"channels":[{"active":true,"remote_pubkey":"02aa","chan_id":"123","capacity":"500000","local_balance":"300000","remote_balance":"199000"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestLND(t *testing.T) {
+	server := fakeLND(3)
+	defer server.Close()
+	macaroon := filepath.Join(t.TempDir(), "readonly.macaroon")
+	os.WriteFile(macaroon, []byte{2, 1}, 0600)
+	node, err := Open(Config{Backend: BackendLND, URL: server.URL, MacaroonFile: macaroon})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forwards, err := node.Forwards(context.Background(), 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Forward{Index: 2, Time: forwardTime, ChanIn: "1", ChanOut: "2", AmtInMsat: 1_002_000, AmtOutMsat: 1_000_000, FeeMsat: 2000}
+	if len(forwards) != 2 || forwards[0] != want || forwards[1].Index != 3 {
+		t.Errorf("Forwards() = %+v, want %+v first", forwards, want)
+	}
+	channels, err := node.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 1 || channels[0] != (Channel{ID: "123", Peer: "02aa", Active: true, Capacity: 500000, LocalBalance: 300000, RemoteBalance: 199000}) {
+		t.Errorf("Channels() = %+v", channels)
+	}
+
+	if _, err := NewLND(server.URL, "00", http.DefaultClient).Channels(context.Background()); !errors.Is(err, ErrNode) {
+		t.Errorf("Expected ErrNode for a bad macaroon, got %v", err)
+	}
+	if _, err := Open(Config{Backend: "eclair", URL: server.URL}); err == nil {
+		t.Error("Expected an unknown backend to be refused")
+	}
+}
+
+func TestCLN(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Rune") != "rune" || r.Method != http.MethodPost {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/listforwards":
+			var req struct {
+				Status string `json:"status"`
+				Index  string `json:"index"`
+				Start  uint64 `json:"start"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Status != "settled" || req.Index != "created" || req.Start != 5 {
+				t.Errorf("Unexpected listforwards request %+v", req)
+			}
+			w.Write([]byte(`{"forwards":[
+				{"created_index":5,"in_channel":"1x1x0","out_channel":"2x1x0","in_msat":101000,"out_msat":100000,"fee_msat":1000,"status":"settled","received_time":1772366399.5,"resolved_time":1772366400.25},
+				{"created_index":7,"in_channel":"1x1x0","out_channel":"2x1x0","in_msat":2001,"out_msat":2000,"fee_msat":1,"status":"settled","resolved_time":1772366401}]}`))
+		case "/v1/listpeerchannels":
+			w.Write([]byte(`{"channels":[
+				{"peer_id":"03bb","peer_connected":true,"state":"CHANNELD_NORMAL","short_channel_id":"1x1x0","total_msat":1000000000,"to_us_msat":400000000},
+				{"peer_id":"03cc","peer_connected":false,"state":"CHANNELD_NORMAL","short_channel_id":"2x1x0","total_msat":2000000,"to_us_msat":0}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	node, err := Open(Config{Backend: BackendCLN, URL: server.URL, Rune: "rune"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forwards, err := node.Forwards(context.Background(), 4, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Forward{Index: 5, Time: time.Unix(1772366400, 250_000_000).UTC(), ChanIn: "1x1x0", ChanOut: "2x1x0", AmtInMsat: 101000, AmtOutMsat: 100000, FeeMsat: 1000}
+	if len(forwards) != 2 || forwards[0] != want || forwards[1].Index != 7 {
+		t.Errorf("Forwards() = %+v, want %+v first", forwards, want)
+	}
+	channels, err := node.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 2 || channels[0] != (Channel{ID: "1x1x0", Peer: "03bb", Active: true, Capacity: 1_000_000, LocalBalance: 400_000, RemoteBalance: 600_000}) || channels[1].Active {
+		t.Errorf("Channels() = %+v", channels)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	server := fakeLND(5)
+	defer server.Close()
+	node := NewLND(server.URL, "0201", server.Client())
+	treasury := economy.NewTreasury()
+
+	// 1000 sats per EXS: a 1.5 sat fee is 0.0015 EXS
+	collector := NewCollector(treasury, node, 1000)
+	collector.batch = 2
+	if got := collector.Convert(1500); got != 15*economy.Coin/10000 {
+		t.Errorf("Convert(1500 msat) = %s, want 0.0015", got)
+	}
+	n, err := collector.Step(context.Background())
+	if err != nil || n != 5 {
+		t.Fatalf("Step() = %d, %v, want 5 forwards", n, err)
+	}
+	// Fees of 1 to 5 sats
+	want := economy.RevenueStream{Name: economy.StreamLightning, Earnings: 5, Units: 15000, Amount: 15 * economy.Coin / 1000, LastIndex: 5, LastAt: forwardTime}
+	if got := treasury.RevenueStream(economy.StreamLightning); got != want {
+		t.Errorf("RevenueStream() = %+v, want %+v", got, want)
+	}
+	if treasury.GetBalance() != want.Amount {
+		t.Errorf("Balance = %s, want %s", treasury.GetBalance(), want.Amount)
+	}
+	// Nothing new to credit
+	if n, err := collector.Step(context.Background()); err != nil || n != 0 {
+		t.Errorf("Second Step() = %d, %v, want nothing credited", n, err)
+	}
+
+	stats, err := ReadStats(context.Background(), node, forwardTime.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Forwards != 5 || stats.FeesMsat != 15000 || stats.Fees24hMsat != 0 || stats.Fees7dMsat != 15000 ||
+		stats.VolumeMsat != 5_000_000 || stats.ActiveChannels != 1 || stats.Capacity != 500000 {
+		t.Errorf("ReadStats() = %+v", stats)
+	}
+}
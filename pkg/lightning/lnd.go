@@ -0,0 +1,106 @@
+package lightning
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LND reads an LND node through its REST proxy
+type LND struct {
+	rest
+}
+
+// NewLND returns the LND node at baseURL authenticated with the hex of
+// macaroon
+func NewLND(baseURL, macaroon string, httpClient *http.Client) *LND {
+	return &LND{rest{baseURL: baseURL, authHeader: "Grpc-Metadata-macaroon", authValue: macaroon, httpClient: httpClient}}
+}
+
+// lndInt is a 64-bit integer, which the REST proxy encodes as a string
+type lndInt int64
+
+func (n *lndInt) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(s) >= 2 && s[0] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", data)
+	}
+	*n = lndInt(v)
+	return nil
+}
+
+// Forwards returns the settled forwards of the node's forwarding log,
+// whose indexes are positions in the log
+func (l *LND) Forwards(ctx context.Context, after uint64, limit int) ([]Forward, error) {
+	req := map[string]interface{}{
+		"start_time":     "0",
+		"end_time":       strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+		"index_offset":   after,
+		"num_max_events": limit,
+	}
+	var resp struct {
+		ForwardingEvents []struct {
+			TimestampNs lndInt `json:"timestamp_ns"`
+			ChanIDIn    string `json:"chan_id_in"`
+			ChanIDOut   string `json:"chan_id_out"`
+			AmtInMsat   lndInt `json:"amt_in_msat"`
+			AmtOutMsat  lndInt `json:"amt_out_msat"`
+			FeeMsat     lndInt `json:"fee_msat"`
+		} `json:"forwarding_events"`
+	}
+	if err := l.call(ctx, http.MethodPost, "/v1/switch", req, &resp); err != nil {
+		return nil, err
+	}
+	forwards := make([]Forward, len(resp.ForwardingEvents))
+	for i, e := range resp.ForwardingEvents {
+		forwards[i] = Forward{
+			Index:      after + uint64(i) + 1,
+			Time:       time.Unix(0, int64(e.TimestampNs)).UTC(),
+			ChanIn:     e.ChanIDIn,
+			ChanOut:    e.ChanIDOut,
+			AmtInMsat:  int64(e.AmtInMsat),
+			AmtOutMsat: int64(e.AmtOutMsat),
+			FeeMsat:    int64(e.FeeMsat),
+		}
+	}
+	return forwards, nil
+}
+
+// Channels returns the node's open channels
+func (l *LND) Channels(ctx context.Context) ([]Channel, error) {
+	var resp struct {
+		Channels []struct {
+			Active        bool   `json:"active"`
+			RemotePubkey  string `json:"remote_pubkey"`
+			ChanID        string `json:"chan_id"`
+			Capacity      lndInt `json:"capacity"`
+			LocalBalance  lndInt `json:"local_balance"`
+			RemoteBalance lndInt `json:"remote_balance"`
+		} `json:"channels"`
+	}
+	if err := l.call(ctx, http.MethodGet, "/v1/channels", nil, &resp); err != nil {
+		return nil, err
+	}
+	channels := make([]Channel, len(resp.Channels))
+	for i, c := range resp.Channels {
+		channels[i] = Channel{
+			ID:            c.ChanID,
+			Peer:          c.RemotePubkey,
+			Active:        c.Active,
+			Capacity:      int64(c.Capacity),
+			LocalBalance:  int64(c.LocalBalance),
+			RemoteBalance: int64(c.RemoteBalance),
+		}
+	}
+	return channels, nil
+}
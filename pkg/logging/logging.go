@@ -0,0 +1,210 @@
+// Package logging sets up the structured logger the Excalibur-EXS binaries
+// write their diagnostics to. Every binary takes --log-level and
+// --log-format (or LOG_LEVEL and LOG_FORMAT), writing text for people or
+// one JSON object per line for log aggregation to stderr. HTTP servers wrap
+// their handlers in Middleware so each request is logged, and logged with
+// its request ID, through FromContext.
+package logging
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Environment variables read by FromEnv
+const (
+	EnvLevel  = "LOG_LEVEL"
+	EnvFormat = "LOG_FORMAT"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// RequestIDHeader carries a request's ID in from proxies and back out to
+// clients
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// ErrInvalidConfig indicates an unknown level or format
+var ErrInvalidConfig = errors.New("invalid logging configuration")
+
+// Options are a binary's logging settings
+type Options struct {
+	// Level is debug, info, warn or error
+	Level string
+	// Format is FormatText or FormatJSON
+	Format string
+}
+
+// FromEnv returns the Options set by LOG_LEVEL and LOG_FORMAT, info and
+// text by default
+func FromEnv() Options {
+	o := Options{Level: os.Getenv(EnvLevel), Format: os.Getenv(EnvFormat)}
+	if o.Level == "" {
+		o.Level = "info"
+	}
+	if o.Format == "" {
+		o.Format = FormatText
+	}
+	return o
+}
+
+// flagSet is satisfied by both flag.FlagSet and pflag.FlagSet
+type flagSet interface {
+	StringVar(p *string, name, value, usage string)
+}
+
+// Register adds --log-level and --log-format to fs, defaulting to the
+// values from FromEnv
+func (o *Options) Register(fs flagSet) {
+	env := FromEnv()
+	fs.StringVar(&o.Level, "log-level", env.Level, "log level: debug, info, warn or error")
+	fs.StringVar(&o.Format, "log-format", env.Format, "log format: text or json")
+}
+
+// Handler returns the slog handler writing to w in o's level and format
+func (o Options) Handler(w io.Writer) (slog.Handler, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+		return nil, fmt.Errorf("%w: level %q", ErrInvalidConfig, o.Level)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(o.Format) {
+	case FormatText, "":
+		return slog.NewTextHandler(w, opts), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("%w: format %q", ErrInvalidConfig, o.Format)
+	}
+}
+
+// Setup makes o the default logger, writing to stderr. Output of the
+// standard log package goes through it at info level.
+func (o Options) Setup() error {
+	handler, err := o.Handler(os.Stderr)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Fatal logs msg at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type contextKey struct{}
+
+// WithLogger returns ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of ctx, carrying the request ID inside
+// handlers wrapped by Middleware, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Middleware gives every request an ID, kept from the X-Request-ID header
+// when a proxy set one, and returns it in the response header; the entries
+// of a traced request carry its trace ID too. It logs the request when it
+// completes; probes and metrics scrapes are logged at debug level only.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		logger := FromContext(r.Context()).With("request_id", id)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(WithLogger(r.Context(), logger)))
+
+		level := slog.LevelInfo
+		switch r.URL.Path {
+		case "/healthz", "/readyz", "/metrics":
+			level = slog.LevelDebug
+		}
+		logger.Log(r.Context(), level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote", r.RemoteAddr)
+	})
+}
+
+// validRequestID reports whether id is short printable ASCII, safe to log
+// and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack hands the connection to a WebSocket upgrade, recording it as
+// switching protocols
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
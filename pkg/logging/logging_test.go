@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := Options{Level: "warn", Format: "json"}.Handler(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(handler)
+	logger.Info("dropped")
+	logger.Warn("kept", "height", 42)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output %q is not one JSON object: %v", buf.String(), err)
+	}
+	if entry["msg"] != "kept" || entry["level"] != "WARN" || entry["height"] != float64(42) {
+		t.Errorf("entry = %v", entry)
+	}
+
+	for _, o := range []Options{{Level: "loud", Format: "text"}, {Level: "info", Format: "xml"}} {
+		if _, err := o.Handler(&buf); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Handler() of %+v = %v, want ErrInvalidConfig", o, err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Setenv(EnvLevel, "debug")
+	t.Setenv(EnvFormat, "")
+	var o Options
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o.Register(fs)
+	if o.Level != "debug" || o.Format != FormatText {
+		t.Errorf("defaults = %+v, want debug from the environment and text", o)
+	}
+	if err := fs.Parse([]string{"--log-format", "json", "--log-level", "error"}); err != nil {
+		t.Fatal(err)
+	}
+	if o.Level != "error" || o.Format != FormatJSON {
+		t.Errorf("after flags = %+v", o)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	handler, _ := Options{Level: "debug", Format: "json"}.Handler(&buf)
+	logged := func() []map[string]interface{} {
+		var entries []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var entry map[string]interface{}
+			json.Unmarshal([]byte(line), &entry)
+			entries = append(entries, entry)
+		}
+		buf.Reset()
+		return entries
+	}
+
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Warn("forge rejected")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	serve := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/forge", nil)
+		req = req.WithContext(WithLogger(req.Context(), slog.New(handler)))
+		if header != "" {
+			req.Header.Set(RequestIDHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A proxy's request ID is kept and tags every entry of the request
+	rec := serve("edge-1234")
+	entries := logged()
+	if rec.Header().Get(RequestIDHeader) != "edge-1234" || len(entries) != 2 {
+		t.Fatalf("response ID %q, entries %v", rec.Header().Get(RequestIDHeader), entries)
+	}
+	for _, entry := range entries {
+		if entry["request_id"] != "edge-1234" {
+			t.Errorf("entry %v lacks the request ID", entry)
+		}
+	}
+	if access := entries[1]; access["status"] != float64(http.StatusTeapot) || access["path"] != "/forge" || access["method"] != "POST" {
+		t.Errorf("access entry = %v", access)
+	}
+
+	// Without one, or with one unsafe to log, an ID is generated
+	for _, header := range []string{"", "bad id\n", strings.Repeat("x", maxRequestIDLength+1)} {
+		id := serve(header).Header().Get(RequestIDHeader)
+		logged()
+		if len(id) != 16 {
+			t.Errorf("generated ID for %q = %q", header, id)
+		}
+	}
+}
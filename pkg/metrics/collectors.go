@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+)
+
+var (
+	treasuryBalanceDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "treasury", "balance_exs"),
+		"Treasury balance in EXS by state: total, spendable or locked.",
+		[]string{"state"}, nil)
+	treasuryFeesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "treasury", "fees_collected_exs_total"),
+		"EXS collected by the treasury.", nil, nil)
+	treasuryForgesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "treasury", "forges_total"),
+		"Forges processed by the treasury.", nil, nil)
+	treasuryForgeFeeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "treasury", "forge_fee_pool_btc"),
+		"BTC accumulated in the forge fee pool.", nil, nil)
+	treasuryHeightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "treasury", "block_height"),
+		"Block height last reported to the treasury.", nil, nil)
+
+	spvPeersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "spv", "peers"),
+		"SPV peers by state: connected or known.",
+		[]string{"state"}, nil)
+	spvHeightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "spv", "best_height"),
+		"Height of the SPV client's best header.", nil, nil)
+	spvHeadersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "spv", "headers"),
+		"Block headers stored by the SPV client.", nil, nil)
+)
+
+// treasuryCollector reads treasury state at scrape time, so the metrics
+// always match the ledger
+type treasuryCollector struct {
+	treasury *economy.Treasury
+}
+
+// WatchTreasury exports the balances and forge counts of t
+func WatchTreasury(t *economy.Treasury) error {
+	return Registry.Register(treasuryCollector{treasury: t})
+}
+
+func (c treasuryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- treasuryBalanceDesc
+	ch <- treasuryFeesDesc
+	ch <- treasuryForgesDesc
+	ch <- treasuryForgeFeeDesc
+	ch <- treasuryHeightDesc
+}
+
+func (c treasuryCollector) Collect(ch chan<- prometheus.Metric) {
+	t := c.treasury
+	ch <- prometheus.MustNewConstMetric(treasuryBalanceDesc, prometheus.GaugeValue, t.GetBalance().EXS(), "total")
+	ch <- prometheus.MustNewConstMetric(treasuryBalanceDesc, prometheus.GaugeValue, t.GetSpendableBalance().EXS(), "spendable")
+	ch <- prometheus.MustNewConstMetric(treasuryBalanceDesc, prometheus.GaugeValue, t.GetLockedBalance().EXS(), "locked")
+	ch <- prometheus.MustNewConstMetric(treasuryFeesDesc, prometheus.CounterValue, t.GetTotalFeesCollected().EXS())
+	ch <- prometheus.MustNewConstMetric(treasuryForgesDesc, prometheus.CounterValue, float64(t.GetTotalForges()))
+	ch <- prometheus.MustNewConstMetric(treasuryForgeFeeDesc, prometheus.GaugeValue, t.GetForgeFeePool().ToBTC())
+	ch <- prometheus.MustNewConstMetric(treasuryHeightDesc, prometheus.GaugeValue, float64(t.GetBlockHeight()))
+}
+
+// spvCollector reads the SPV client's peers and headers at scrape time
+type spvCollector struct {
+	spv *bitcoin.SPVClient
+}
+
+// WatchSPV exports the peer counts and header height of an SPV client
+func WatchSPV(spv *bitcoin.SPVClient) error {
+	return Registry.Register(spvCollector{spv: spv})
+}
+
+func (c spvCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- spvPeersDesc
+	ch <- spvHeightDesc
+	ch <- spvHeadersDesc
+}
+
+func (c spvCollector) Collect(ch chan<- prometheus.Metric) {
+	peers := c.spv.GetPeers()
+	connected := 0
+	for _, peer := range peers {
+		if peer.Connected {
+			connected++
+		}
+	}
+	_, height := c.spv.GetBestBlock()
+
+	ch <- prometheus.MustNewConstMetric(spvPeersDesc, prometheus.GaugeValue, float64(connected), "connected")
+	ch <- prometheus.MustNewConstMetric(spvPeersDesc, prometheus.GaugeValue, float64(len(peers)), "known")
+	ch <- prometheus.MustNewConstMetric(spvHeightDesc, prometheus.GaugeValue, float64(height))
+	ch <- prometheus.MustNewConstMetric(spvHeadersDesc, prometheus.GaugeValue, float64(c.spv.GetHeaderCount()))
+}
@@ -0,0 +1,174 @@
+// Package metrics exposes Prometheus metrics for the Excalibur-EXS daemons.
+//
+// Every server registers what it owns on the shared Registry and serves it
+// on /metrics with Handler:
+// - HTTP request latencies per route, via Instrument
+// - Treasury balances and forge counts, via WatchTreasury
+// - SPV peer counts and header height, via WatchSPV
+// - Miner hash rate, attempts and blocks found, via WatchMiner
+// - Guardian auth failures, audit log write errors and rate-limited requests
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every EXS metric name
+const Namespace = "exs"
+
+// Registry holds the metrics served by Handler, including the Go runtime
+// and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration observes request latency by route, method and
+	// status code
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	// AuthFailures counts rejected Guardian authentications by reason
+	AuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "guardian",
+		Name:      "auth_failures_total",
+		Help:      "Rejected Guardian authentications by reason.",
+	}, []string{"reason"})
+
+	// AuditWriteErrors counts Guardian audit events a sink failed to record
+	AuditWriteErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "guardian",
+		Name:      "audit_write_errors_total",
+		Help:      "Guardian audit events that could not be recorded.",
+	})
+
+	// RateLimited counts requests refused by a rate limit policy, by the
+	// endpoint rule that refused them
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "guardian",
+		Name:      "rate_limited_total",
+		Help:      "Requests refused by the rate limit policy, by endpoint rule.",
+	}, []string{"rule"})
+
+	// RateLimitStoreErrors counts rate limit checks that fell back to
+	// in-memory buckets because the shared store failed
+	RateLimitStoreErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "guardian",
+		Name:      "rate_limit_store_errors_total",
+		Help:      "Rate limit checks that fell back to in-memory buckets.",
+	})
+
+	// MiningAttempts counts hash attempts made by the miner
+	MiningAttempts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "miner",
+		Name:      "attempts_total",
+		Help:      "Tetra-PoW hash attempts.",
+	})
+
+	// BlocksFound counts attempts that met the difficulty target
+	BlocksFound = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "miner",
+		Name:      "blocks_found_total",
+		Help:      "Tetra-PoW attempts that met the difficulty target.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		AuthFailures,
+		AuditWriteErrors,
+		RateLimited,
+		RateLimitStoreErrors,
+	)
+}
+
+// Handler serves Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Instrument records the latency of every request h serves under route. The
+// route should be the pattern h is mounted on, not the request path, so the
+// number of label values stays bounded.
+func Instrument(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		h.ServeHTTP(rec, r)
+		HTTPRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// MuxMiddleware instruments every route of a gorilla/mux router, labelling
+// requests with the matched route's path template
+func MuxMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, _ := mux.CurrentRoute(r).GetPathTemplate()
+		Instrument(route, next).ServeHTTP(w, r)
+	})
+}
+
+// WatchMiner exports MiningAttempts, BlocksFound and rate, in hashes per
+// second, as the miner hash rate. Only mining servers call it, so other
+// daemons do not report idle miner metrics.
+func WatchMiner(rate func() float64) error {
+	hashRate := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "miner",
+		Name:      "hash_rate",
+		Help:      "Tetra-PoW hashes per second.",
+	}, rate)
+	for _, c := range []prometheus.Collector{MiningAttempts, BlocksFound, hashRate} {
+		if err := Registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack hands the connection to a WebSocket upgrade, recording it as
+// switching protocols
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+)
+
+// scrape returns the exposition served by Handler
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", rec.Code)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestInstrument(t *testing.T) {
+	h := Instrument("/teapot", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/teapot?x=1", nil))
+
+	want := `exs_http_request_duration_seconds_count{code="418",method="POST",route="/teapot"} 1`
+	if body := scrape(t); !strings.Contains(body, want) {
+		t.Errorf("Scrape missing %q", want)
+	}
+}
+
+func TestWatchTreasury(t *testing.T) {
+	treasury := economy.NewTreasury()
+	if err := WatchTreasury(treasury); err != nil {
+		t.Fatalf("WatchTreasury() error = %v", err)
+	}
+	treasury.SetBlockHeight(50)
+	treasury.ProcessForge("bc1pminer")
+
+	body := scrape(t)
+	for _, want := range []string{
+		"exs_treasury_forges_total 1",
+		`exs_treasury_balance_exs{state="total"} 7.5`,
+		`exs_treasury_balance_exs{state="spendable"} 2.5`,
+		`exs_treasury_balance_exs{state="locked"} 5`,
+		"exs_treasury_block_height 50",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Scrape missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+)
+
+var (
+	// ErrDuplicatePayout indicates a payout transaction already recorded
+	ErrDuplicatePayout = errors.New("payout already recorded")
+	// ErrAlreadyCredited is returned by a Crediter for a payout the
+	// treasury has already credited
+	ErrAlreadyCredited = errors.New("payout already credited")
+)
+
+// Payout is a pool payout of mined coins
+type Payout struct {
+	Chain string `json:"chain"`
+	// Index numbers the chain's payouts from 1, in the order recorded
+	Index uint64 `json:"index"`
+	TxID  string `json:"txid"`
+	// Units is the payout in the coin's smallest unit
+	Units int64 `json:"units"`
+	// Amount is the EXS the treasury is credited with, at the chain's
+	// multiplier when recorded
+	Amount   economy.Amount `json:"amount"`
+	Time     time.Time      `json:"time"`
+	Credited bool           `json:"credited"`
+}
+
+// Crediter credits payouts to the treasury, typically through its
+// /revenue/cross-chain endpoint
+type Crediter interface {
+	CreditPayout(ctx context.Context, payout Payout) error
+}
+
+// PayoutTotals sums the payouts of a chain
+type PayoutTotals struct {
+	Payouts  int            `json:"payouts"`
+	Units    int64          `json:"units"`
+	Amount   economy.Amount `json:"amount"`
+	Credited economy.Amount `json:"credited"`
+	// Pending counts payouts not credited yet
+	Pending int `json:"pending"`
+}
+
+// Payouts records pool payouts in a file and credits them to the treasury
+type Payouts struct {
+	path string
+
+	mu      sync.Mutex
+	payouts []*Payout
+}
+
+// OpenPayouts opens the payouts recorded at path, creating it with the
+// first payout
+func OpenPayouts(path string) (*Payouts, error) {
+	b := &Payouts{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payouts: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.payouts); err != nil {
+		return nil, fmt.Errorf("failed to parse payouts: %w", err)
+	}
+	return b, nil
+}
+
+// Record records a payout of units to the pool account in transaction
+// txid, converted to EXS at config's multiplier
+func (b *Payouts) Record(config ChainConfig, txid string, units int64, at time.Time) (*Payout, error) {
+	if txid == "" || units <= 0 {
+		return nil, fmt.Errorf("payout needs a transaction and a positive amount")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var index uint64
+	for _, p := range b.payouts {
+		if p.Chain != config.Chain {
+			continue
+		}
+		if p.TxID == txid {
+			return nil, fmt.Errorf("%w: %s %s", ErrDuplicatePayout, config.Chain, txid)
+		}
+		index = p.Index
+	}
+	p := &Payout{Chain: config.Chain, Index: index + 1, TxID: txid, Units: units, Amount: config.Convert(units), Time: at.UTC()}
+	b.payouts = append(b.payouts, p)
+	if err := b.save(); err != nil {
+		b.payouts = b.payouts[:len(b.payouts)-1]
+		return nil, err
+	}
+	return p, nil
+}
+
+// List returns the payouts of chain, or every payout when chain is empty,
+// in the order recorded
+func (b *Payouts) List(chain string) []Payout {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	payouts := make([]Payout, 0, len(b.payouts))
+	for _, p := range b.payouts {
+		if chain == "" || p.Chain == chain {
+			payouts = append(payouts, *p)
+		}
+	}
+	return payouts
+}
+
+// Totals sums the payouts of chain
+func (b *Payouts) Totals(chain string) PayoutTotals {
+	var totals PayoutTotals
+	for _, p := range b.List(chain) {
+		totals.Payouts++
+		totals.Units += p.Units
+		totals.Amount += p.Amount
+		if p.Credited {
+			totals.Credited += p.Amount
+		} else {
+			totals.Pending++
+		}
+	}
+	return totals
+}
+
+// Credit sends the payouts not credited yet to crediter in order and
+// returns how many it credited. A chain's payouts stay in order: the first
+// failure of a chain holds back its later payouts until the next call.
+func (b *Payouts) Credit(ctx context.Context, crediter Crediter) (int, error) {
+	credited := 0
+	failed := make(map[string]bool)
+	var errs []error
+	for _, p := range b.List("") {
+		if p.Credited || failed[p.Chain] {
+			continue
+		}
+		err := crediter.CreditPayout(ctx, p)
+		if err != nil && !errors.Is(err, ErrAlreadyCredited) {
+			failed[p.Chain] = true
+			errs = append(errs, fmt.Errorf("%s payout %d: %w", p.Chain, p.Index, err))
+			continue
+		}
+		if err := b.markCredited(p.Chain, p.Index); err != nil {
+			return credited, err
+		}
+		credited++
+	}
+	return credited, errors.Join(errs...)
+}
+
+func (b *Payouts) markCredited(chain string, index uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, p := range b.payouts {
+		if p.Chain == chain && p.Index == index {
+			p.Credited = true
+		}
+	}
+	return b.save()
+}
+
+// save writes the payouts to the file. The caller must hold b.mu.
+func (b *Payouts) save() error {
+	data, err := json.MarshalIndent(b.payouts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode payouts: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return fmt.Errorf("failed to create payouts directory: %w", err)
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write payouts: %w", err)
+	}
+	return os.Rename(tmp, b.path)
+}
@@ -0,0 +1,339 @@
+// Package proxy relays miners of other chains to external pools for the
+// cross-chain mining revenue stream. Miners of Bitcoin, Litecoin or
+// Dogecoin connect to the proxy with Stratum V1 as they would to the pool;
+// each connection is relayed to its own pool connection, with worker names
+// moved under the protocol's pool account. The proxy counts the shares the
+// pool accepts and rejects per worker, and records the pool's payouts,
+// which are credited to the treasury converted to EXS.
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/mining/stratum"
+)
+
+// Chains are the coins the proxy relays, by ticker. Each has eight
+// decimals.
+var Chains = map[string]string{
+	"btc":  "Bitcoin",
+	"ltc":  "Litecoin",
+	"doge": "Dogecoin",
+}
+
+// maxLine bounds a Stratum message
+const maxLine = 64 << 10
+
+// ChainConfig relays the miners of one chain to one pool
+type ChainConfig struct {
+	// Chain is a key of Chains
+	Chain string `json:"chain"`
+	// Listen is the address miners connect to
+	Listen string `json:"listen"`
+	// Pool is the pool's Stratum address, e.g. stratum+tcp://host:3333
+	Pool string `json:"pool"`
+	// User is the pool account; a miner's worker w is authorized as
+	// User.w. Empty passes worker names through.
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Multiplier is the EXS credited per coin the pool pays out
+	Multiplier economy.Amount `json:"multiplier"`
+}
+
+// Validate checks the chain is supported and the relay complete
+func (c ChainConfig) Validate() error {
+	if _, ok := Chains[c.Chain]; !ok {
+		return fmt.Errorf("chain %q must be btc, ltc or doge", c.Chain)
+	}
+	if c.Listen == "" || c.Pool == "" {
+		return fmt.Errorf("%s needs a listen address and a pool", c.Chain)
+	}
+	if c.Multiplier <= 0 {
+		return fmt.Errorf("%s needs a positive multiplier", c.Chain)
+	}
+	return nil
+}
+
+// Convert returns the EXS credited for units of the chain's coin, rounded
+// down
+func (c ChainConfig) Convert(units int64) economy.Amount {
+	n := new(big.Int).Mul(big.NewInt(units), big.NewInt(int64(c.Multiplier)))
+	return economy.Amount(n.Quo(n, big.NewInt(int64(economy.Coin))).Int64())
+}
+
+// WorkerStats counts the shares of one worker
+type WorkerStats struct {
+	Worker   string `json:"worker"`
+	Accepted uint64 `json:"accepted"`
+	Rejected uint64 `json:"rejected"`
+	// Work sums the difficulty of accepted shares
+	Work      float64   `json:"work"`
+	LastShare time.Time `json:"last_share,omitempty"`
+}
+
+// ChainStats counts the shares relayed for one chain
+type ChainStats struct {
+	Chain    string        `json:"chain"`
+	Pool     string        `json:"pool"`
+	Miners   int           `json:"miners"`
+	Accepted uint64        `json:"accepted"`
+	Rejected uint64        `json:"rejected"`
+	Work     float64       `json:"work"`
+	Workers  []WorkerStats `json:"workers"`
+}
+
+// Proxy relays the miners of one chain
+type Proxy struct {
+	config ChainConfig
+
+	mu      sync.Mutex
+	miners  int
+	workers map[string]*WorkerStats
+}
+
+// New creates the relay config describes
+func New(config ChainConfig) (*Proxy, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Proxy{config: config, workers: make(map[string]*WorkerStats)}, nil
+}
+
+// Config returns the relay's configuration
+func (p *Proxy) Config() ChainConfig {
+	return p.config
+}
+
+// ListenAndServe accepts miners on the configured address until ctx is done
+func (p *Proxy) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", p.config.Listen)
+	if err != nil {
+		return err
+	}
+	return p.Serve(ctx, ln)
+}
+
+// Serve relays the miners accepted on ln until ctx is done
+func (p *Proxy) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go p.relay(ctx, conn)
+	}
+}
+
+// Stats returns the shares relayed so far, workers by name
+func (p *Proxy) Stats() ChainStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := ChainStats{Chain: p.config.Chain, Pool: p.config.Pool, Miners: p.miners, Workers: make([]WorkerStats, 0, len(p.workers))}
+	for _, w := range p.workers {
+		stats.Accepted += w.Accepted
+		stats.Rejected += w.Rejected
+		stats.Work += w.Work
+		stats.Workers = append(stats.Workers, *w)
+	}
+	sort.Slice(stats.Workers, func(i, j int) bool { return stats.Workers[i].Worker < stats.Workers[j].Worker })
+	return stats
+}
+
+// submitted is a share sent to the pool awaiting its answer
+type submitted struct {
+	worker     string
+	difficulty float64
+}
+
+// connState follows one miner's session
+type connState struct {
+	mu         sync.Mutex
+	difficulty float64
+	pending    map[string]submitted
+}
+
+// relay pipes miner to a new pool connection until either side closes
+func (p *Proxy) relay(ctx context.Context, miner net.Conn) {
+	defer miner.Close()
+	var d net.Dialer
+	pool, err := d.DialContext(ctx, "tcp", strings.TrimPrefix(p.config.Pool, "stratum+tcp://"))
+	if err != nil {
+		slog.Warn("Pool unreachable", "chain", p.config.Chain, "pool", p.config.Pool, "err", err)
+		return
+	}
+	defer pool.Close()
+
+	p.mu.Lock()
+	p.miners++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.miners--
+		p.mu.Unlock()
+	}()
+
+	state := &connState{difficulty: 1, pending: make(map[string]submitted)}
+	done := make(chan struct{}, 2)
+	go func() {
+		p.pipe(miner, pool, func(line []byte) []byte { return p.fromMiner(state, line) })
+		done <- struct{}{}
+	}()
+	go func() {
+		p.pipe(pool, miner, func(line []byte) []byte { return p.fromPool(state, line) })
+		done <- struct{}{}
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// pipe copies lines from src to dst through inspect
+func (p *Proxy) pipe(src, dst net.Conn, inspect func([]byte) []byte) {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+	for scanner.Scan() {
+		line := inspect(scanner.Bytes())
+		// line may share the scanner's buffer, so it is not appended to
+		out := make([]byte, 0, len(line)+1)
+		if _, err := dst.Write(append(append(out, line...), '\n')); err != nil {
+			return
+		}
+	}
+}
+
+// message is a Stratum message, keeping the fields the proxy does not
+// touch as they were
+type message map[string]json.RawMessage
+
+func (m message) method() string {
+	var method string
+	json.Unmarshal(m["method"], &method)
+	return method
+}
+
+func (m message) params() []json.RawMessage {
+	var params []json.RawMessage
+	json.Unmarshal(m["params"], &params)
+	return params
+}
+
+// fromMiner moves authorized and submitting workers under the pool account
+// and remembers submitted shares
+func (p *Proxy) fromMiner(state *connState, line []byte) []byte {
+	var msg message
+	if json.Unmarshal(line, &msg) != nil {
+		return line
+	}
+	method := msg.method()
+	if method != stratum.MethodAuthorize && method != stratum.MethodSubmit {
+		return line
+	}
+	params := msg.params()
+	var worker string
+	if len(params) == 0 || json.Unmarshal(params[0], &worker) != nil {
+		return line
+	}
+	if method == stratum.MethodSubmit {
+		state.mu.Lock()
+		state.pending[string(msg["id"])] = submitted{worker: worker, difficulty: state.difficulty}
+		state.mu.Unlock()
+	}
+	if p.config.User == "" {
+		return line
+	}
+	params[0], _ = json.Marshal(p.poolWorker(worker))
+	if method == stratum.MethodAuthorize && len(params) > 1 && p.config.Password != "" {
+		params[1], _ = json.Marshal(p.config.Password)
+	}
+	msg["params"], _ = json.Marshal(params)
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return line
+	}
+	return out
+}
+
+// poolWorker returns the pool account's name for worker
+func (p *Proxy) poolWorker(worker string) string {
+	// A miner configured with the pool account already, or with an
+	// account.worker of its own, keeps only its worker part
+	if _, name, ok := strings.Cut(worker, "."); ok {
+		worker = name
+	}
+	if worker == "" {
+		return p.config.User
+	}
+	return p.config.User + "." + worker
+}
+
+// fromPool follows the share difficulty and counts answered shares
+func (p *Proxy) fromPool(state *connState, line []byte) []byte {
+	var msg message
+	if json.Unmarshal(line, &msg) != nil {
+		return line
+	}
+	if msg.method() == stratum.MethodSetDifficulty {
+		var difficulty float64
+		if params := msg.params(); len(params) > 0 && json.Unmarshal(params[0], &difficulty) == nil && difficulty > 0 {
+			state.mu.Lock()
+			state.difficulty = difficulty
+			state.mu.Unlock()
+		}
+		return line
+	}
+	id, ok := msg["id"]
+	if !ok || string(id) == "null" {
+		return line
+	}
+	state.mu.Lock()
+	share, ok := state.pending[string(id)]
+	delete(state.pending, string(id))
+	state.mu.Unlock()
+	if !ok {
+		return line
+	}
+	var accepted bool
+	json.Unmarshal(msg["result"], &accepted)
+	if e, ok := msg["error"]; ok && string(e) != "null" {
+		accepted = false
+	}
+	p.countShare(share, accepted)
+	return line
+}
+
+// countShare records the pool's answer to a share
+func (p *Proxy) countShare(share submitted, accepted bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := p.workers[share.worker]
+	if w == nil {
+		w = &WorkerStats{Worker: share.worker}
+		p.workers[share.worker] = w
+	}
+	if accepted {
+		w.Accepted++
+		w.Work += share.difficulty
+	} else {
+		w.Rejected++
+	}
+	w.LastShare = time.Now().UTC()
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+)
+
+// fakePool answers authorizations and accepts the shares of rig1 only,
+// sending the lines it receives to received
+func fakePool(t *testing.T, received chan<- string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte(`{"id":null,"method":"mining.set_difficulty","params":[8]}` + "\n"))
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received <- scanner.Text()
+					var msg struct {
+						ID     json.RawMessage `json:"id"`
+						Params []string        `json:"params"`
+					}
+					json.Unmarshal(scanner.Bytes(), &msg)
+					answer := `{"id":` + string(msg.ID) + `,"result":true,"error":null}`
+					if len(msg.Params) > 0 && msg.Params[0] != "acct.rig1" {
+						answer = `{"id":` + string(msg.ID) + `,"result":null,"error":[23,"Low difficulty share",null]}`
+					}
+					conn.Write([]byte(answer + "\n"))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProxyRelaysShares(t *testing.T) {
+	received := make(chan string, 16)
+	pool := fakePool(t, received)
+	p, err := New(ChainConfig{Chain: "ltc", Listen: "127.0.0.1:0", Pool: "stratum+tcp://" + pool, User: "acct", Password: "secret", Multiplier: economy.Coin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Serve(ctx, ln)
+
+	miner, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer miner.Close()
+	miner.SetDeadline(time.Now().Add(5 * time.Second))
+	replies := bufio.NewScanner(miner)
+	read := func() string {
+		t.Helper()
+		if !replies.Scan() {
+			t.Fatalf("Miner connection closed: %v", replies.Err())
+		}
+		return replies.Text()
+	}
+	if got := read(); !strings.Contains(got, "mining.set_difficulty") {
+		t.Fatalf("Expected the pool's difficulty first, got %s", got)
+	}
+
+	lines := []string{
+		`{"id":1,"method":"mining.authorize","params":["me.rig1","x"]}`,
+		`{"id":2,"method":"mining.submit","params":["me.rig1","job","00","5f","0a"]}`,
+		`{"id":3,"method":"mining.submit","params":["rig2","job","00","5f","0b"]}`,
+	}
+	for _, line := range lines {
+		miner.Write([]byte(line + "\n"))
+		read()
+	}
+
+	var auth struct {
+		Params []string `json:"params"`
+	}
+	json.Unmarshal([]byte(<-received), &auth)
+	if len(auth.Params) != 2 || auth.Params[0] != "acct.rig1" || auth.Params[1] != "secret" {
+		t.Errorf("Pool authorized %v, want [acct.rig1 secret]", auth.Params)
+	}
+
+	stats := p.Stats()
+	if stats.Miners != 1 || stats.Accepted != 1 || stats.Rejected != 1 || stats.Work != 8 {
+		t.Errorf("Stats() = %+v, want 1 miner, 1 accepted share of difficulty 8 and 1 rejected", stats)
+	}
+	if len(stats.Workers) != 2 || stats.Workers[0].Worker != "me.rig1" || stats.Workers[1].Rejected != 1 {
+		t.Errorf("Workers = %+v", stats.Workers)
+	}
+}
+
+func TestPoolWorker(t *testing.T) {
+	p := &Proxy{config: ChainConfig{User: "acct"}}
+	for worker, want := range map[string]string{"rig1": "acct.rig1", "acct.rig1": "acct.rig1", "other.rig1": "acct.rig1", "": "acct"} {
+		if got := p.poolWorker(worker); got != want {
+			t.Errorf("poolWorker(%q) = %q, want %q", worker, got, want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	// 1 DOGE at 0.0025 EXS per DOGE
+	c := ChainConfig{Chain: "doge", Multiplier: economy.Coin / 400}
+	if got := c.Convert(int64(economy.Coin)); got != economy.Coin/400 {
+		t.Errorf("Convert(1 DOGE) = %s, want 0.0025", got)
+	}
+	if got := c.Convert(399); got != 0 {
+		t.Errorf("Convert(399) = %s, want 0 (rounded down)", got)
+	}
+}
+
+type fakeCrediter struct {
+	credited []Payout
+	fail     string
+}
+
+func (f *fakeCrediter) CreditPayout(ctx context.Context, p Payout) error {
+	if p.Chain == f.fail {
+		return errors.New("treasury unavailable")
+	}
+	f.credited = append(f.credited, p)
+	return nil
+}
+
+func TestPayouts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payouts.json")
+	payouts, err := OpenPayouts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	btc := ChainConfig{Chain: "btc", Multiplier: 1000 * economy.Coin}
+	doge := ChainConfig{Chain: "doge", Multiplier: economy.Coin / 400}
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := payouts.Record(btc, "aa", 50000, at); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payouts.Record(doge, "aa", int64(economy.Coin), at); err != nil {
+		t.Fatal(err)
+	}
+	p, err := payouts.Record(btc, "bb", 25000, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Index != 2 || p.Amount != 250*economy.Coin/1000 {
+		t.Errorf("Record() = %+v, want index 2 and 0.25 EXS", p)
+	}
+	if _, err := payouts.Record(btc, "aa", 50000, at); !errors.Is(err, ErrDuplicatePayout) {
+		t.Errorf("Expected ErrDuplicatePayout, got %v", err)
+	}
+
+	crediter := &fakeCrediter{fail: "doge"}
+	n, err := payouts.Credit(context.Background(), crediter)
+	if n != 2 || err == nil {
+		t.Errorf("Credit() = %d, %v; want the btc payouts credited and the doge failure", n, err)
+	}
+	crediter.fail = ""
+	if n, err := payouts.Credit(context.Background(), crediter); n != 1 || err != nil {
+		t.Errorf("Credit() retry = %d, %v; want the doge payout", n, err)
+	}
+
+	reopened, err := OpenPayouts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	totals := reopened.Totals("btc")
+	if totals.Payouts != 2 || totals.Units != 75000 || totals.Pending != 0 || totals.Credited != 750*economy.Coin/1000 {
+		t.Errorf("Totals(btc) = %+v", totals)
+	}
+	if got := reopened.List(""); len(got) != 3 || got[1].Chain != "doge" || !got[1].Credited {
+		t.Errorf("List() = %+v", got)
+	}
+}
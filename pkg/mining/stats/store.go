@@ -0,0 +1,294 @@
+// Package stats persists miner statistics across restarts: a session per
+// mining run with its hash rate and power samples, submitted shares and the
+// blocks it found, reported over a trailing window.
+package stats
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, works with CGO_ENABLED=0
+)
+
+// ErrInvalidHistory indicates a history window that does not parse
+var ErrInvalidHistory = errors.New("invalid history window")
+
+// Store records miner statistics in a SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (or creates) the stats database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stats database: %w", err)
+	}
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	stmts := []string{
+		`PRAGMA journal_mode=WAL`,
+		`PRAGMA synchronous=NORMAL`,
+		// The node and a miner may record into the same database
+		`PRAGMA busy_timeout=5000`,
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id      INTEGER PRIMARY KEY AUTOINCREMENT,
+			source  TEXT NOT NULL,
+			address TEXT NOT NULL,
+			threads INTEGER NOT NULL,
+			started INTEGER NOT NULL,
+			ended   INTEGER,
+			hashes  INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS samples (
+			session   INTEGER NOT NULL REFERENCES sessions(id),
+			time      INTEGER NOT NULL,
+			hash_rate REAL NOT NULL,
+			watts     REAL NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS samples_time ON samples(time)`,
+		`CREATE TABLE IF NOT EXISTS shares (
+			session    INTEGER NOT NULL REFERENCES sessions(id),
+			time       INTEGER NOT NULL,
+			accepted   INTEGER NOT NULL,
+			difficulty REAL NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS shares_time ON shares(time)`,
+		`CREATE TABLE IF NOT EXISTS blocks (
+			session INTEGER REFERENCES sessions(id),
+			time    INTEGER NOT NULL,
+			height  INTEGER NOT NULL,
+			hash    TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS blocks_time ON blocks(time)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize stats database: %w", err)
+		}
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Session is one mining run. Its methods may be called concurrently.
+type Session struct {
+	store *Store
+	id    int64
+}
+
+// StartSession begins a session of threads workers mining for address,
+// source naming where the work comes from, such as a pool URL
+func (s *Store) StartSession(source, address string, threads int, started time.Time) (*Session, error) {
+	res, err := s.db.Exec(`INSERT INTO sessions (source, address, threads, started) VALUES (?, ?, ?, ?)`,
+		source, address, threads, started.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+	return &Session{store: s, id: id}, nil
+}
+
+// ID returns the session's row id
+func (s *Session) ID() int64 {
+	return s.id
+}
+
+// Sample records the hash rate in H/s and estimated power draw in watts at t
+func (s *Session) Sample(t time.Time, hashRate, watts float64) error {
+	_, err := s.store.db.Exec(`INSERT INTO samples (session, time, hash_rate, watts) VALUES (?, ?, ?, ?)`,
+		s.id, t.UnixMilli(), hashRate, watts)
+	if err != nil {
+		return fmt.Errorf("failed to record sample: %w", err)
+	}
+	return nil
+}
+
+// Share records a share submitted at difficulty and the pool's verdict
+func (s *Session) Share(t time.Time, accepted bool, difficulty float64) error {
+	_, err := s.store.db.Exec(`INSERT INTO shares (session, time, accepted, difficulty) VALUES (?, ?, ?, ?)`,
+		s.id, t.UnixMilli(), accepted, difficulty)
+	if err != nil {
+		return fmt.Errorf("failed to record share: %w", err)
+	}
+	return nil
+}
+
+// Block records a block found at t
+func (s *Session) Block(t time.Time, height int32, hash string) error {
+	return s.store.block(s.id, t, height, hash)
+}
+
+// Block records a block found at t outside a session, such as one an
+// external miner submitted to the node
+func (s *Store) Block(t time.Time, height int32, hash string) error {
+	return s.block(nil, t, height, hash)
+}
+
+func (s *Store) block(session any, t time.Time, height int32, hash string) error {
+	_, err := s.db.Exec(`INSERT INTO blocks (session, time, height, hash) VALUES (?, ?, ?, ?)`,
+		session, t.UnixMilli(), height, hash)
+	if err != nil {
+		return fmt.Errorf("failed to record block: %w", err)
+	}
+	return nil
+}
+
+// End closes the session at t after hashes hashes
+func (s *Session) End(t time.Time, hashes uint64) error {
+	_, err := s.store.db.Exec(`UPDATE sessions SET ended = ?, hashes = ? WHERE id = ?`,
+		t.UnixMilli(), int64(hashes), s.id)
+	if err != nil {
+		return fmt.Errorf("failed to end session: %w", err)
+	}
+	return nil
+}
+
+// Block is a found block
+type Block struct {
+	Time   time.Time
+	Height int32
+	Hash   string
+}
+
+// Report summarizes the statistics recorded since a time
+type Report struct {
+	Since time.Time
+	// Sessions counts the sessions that ran since Since, Running those
+	// without an end
+	Sessions int
+	Running  int
+	// Uptime is the time sessions spent mining since Since. A session that
+	// did not end counts until its last sample.
+	Uptime time.Duration
+	// Hashes counts the hashes of the sessions that ended since Since
+	Hashes uint64
+	// AvgHashRate and PeakHashRate are over the samples, AvgWatts their
+	// average power estimate
+	Samples      int
+	AvgHashRate  float64
+	PeakHashRate float64
+	AvgWatts     float64
+	Accepted     uint64
+	Rejected     uint64
+	Blocks       []Block
+}
+
+// Efficiency returns the average hash rate per watt, or 0 without a power
+// estimate
+func (r *Report) Efficiency() float64 {
+	if r.AvgWatts == 0 {
+		return 0
+	}
+	return r.AvgHashRate / r.AvgWatts
+}
+
+// Report summarizes the statistics recorded since a time
+func (s *Store) Report(since time.Time) (*Report, error) {
+	from := since.UnixMilli()
+	report := &Report{Since: since}
+
+	rows, err := s.db.Query(`SELECT started, ended, hashes,
+			(SELECT MAX(time) FROM samples WHERE session = sessions.id)
+		FROM sessions
+		WHERE ended IS NULL OR ended >= ?`, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	for rows.Next() {
+		var started, hashes int64
+		var ended, lastSample sql.NullInt64
+		if err := rows.Scan(&started, &ended, &hashes, &lastSample); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to query sessions: %w", err)
+		}
+		end := started
+		switch {
+		case ended.Valid:
+			end = ended.Int64
+			report.Hashes += uint64(hashes)
+		case lastSample.Valid:
+			end = lastSample.Int64
+		}
+		if !ended.Valid {
+			report.Running++
+		}
+		report.Sessions++
+		report.Uptime += time.Duration(max(end-max(started, from), 0)) * time.Millisecond
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+
+	var avgRate, peakRate, avgWatts sql.NullFloat64
+	err = s.db.QueryRow(`SELECT COUNT(*), AVG(hash_rate), MAX(hash_rate), AVG(watts)
+		FROM samples WHERE time >= ?`, from).Scan(&report.Samples, &avgRate, &peakRate, &avgWatts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query samples: %w", err)
+	}
+	report.AvgHashRate, report.PeakHashRate, report.AvgWatts = avgRate.Float64, peakRate.Float64, avgWatts.Float64
+
+	err = s.db.QueryRow(`SELECT COALESCE(SUM(accepted), 0), COALESCE(SUM(1 - accepted), 0)
+		FROM shares WHERE time >= ?`, from).Scan(&report.Accepted, &report.Rejected)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shares: %w", err)
+	}
+
+	rows, err = s.db.Query(`SELECT time, height, hash FROM blocks WHERE time >= ? ORDER BY time`, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t int64
+		var b Block
+		if err := rows.Scan(&t, &b.Height, &b.Hash); err != nil {
+			return nil, fmt.Errorf("failed to query blocks: %w", err)
+		}
+		b.Time = time.UnixMilli(t)
+		report.Blocks = append(report.Blocks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %w", err)
+	}
+	return report, nil
+}
+
+// ParseHistory parses a history window: a time.ParseDuration string or a
+// whole number of days ("7d") or weeks ("2w")
+func ParseHistory(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidHistory, s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidHistory, s)
+	}
+	return d, nil
+}
@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mining.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Millisecond)
+
+	// A session that ended before the window is left out
+	old, err := store.StartSession("stratum+tcp://pool:3333", "bc1qold", 2, now.Add(-10*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.Sample(now.Add(-10*24*time.Hour+time.Minute), 1000, 100)
+	old.Share(now.Add(-10*24*time.Hour+time.Minute), true, 1)
+	old.Block(now.Add(-10*24*time.Hour+time.Minute), 5, "old")
+	if err := old.End(now.Add(-9*24*time.Hour), 1e6); err != nil {
+		t.Fatal(err)
+	}
+
+	// A session spanning the start of the window counts from it
+	since := now.Add(-7 * 24 * time.Hour)
+	spanning, _ := store.StartSession("stratum+tcp://pool:3333", "bc1qaddr", 4, since.Add(-time.Hour))
+	spanning.Sample(since.Add(time.Minute), 100, 50)
+	spanning.Share(since.Add(time.Minute), true, 2)
+	spanning.Share(since.Add(2*time.Minute), false, 2)
+	spanning.End(since.Add(time.Hour), 5000)
+
+	// A session still running counts until its last sample
+	store.Close()
+	if store, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	running, _ := store.StartSession("solo", "bc1qaddr", 1, now.Add(-2*time.Hour))
+	running.Sample(now.Add(-time.Hour), 300, 150)
+	running.Share(now.Add(-time.Hour), true, 4)
+	running.Block(now.Add(-time.Hour), 101, "aa")
+	if err := store.Block(now.Add(-90*time.Minute), 100, "bb"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.Report(since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sessions != 2 || report.Running != 1 || report.Uptime != 2*time.Hour || report.Hashes != 5000 {
+		t.Errorf("sessions = %d (%d running), uptime %v, %d hashes, want 2 (1), 2h, 5000",
+			report.Sessions, report.Running, report.Uptime, report.Hashes)
+	}
+	if report.Samples != 2 || report.AvgHashRate != 200 || report.PeakHashRate != 300 || report.AvgWatts != 100 || report.Efficiency() != 2 {
+		t.Errorf("samples = %+v", report)
+	}
+	if report.Accepted != 2 || report.Rejected != 1 {
+		t.Errorf("shares = %d accepted, %d rejected, want 2, 1", report.Accepted, report.Rejected)
+	}
+	if len(report.Blocks) != 2 || report.Blocks[0] != (Block{Time: now.Add(-90 * time.Minute), Height: 100, Hash: "bb"}) {
+		t.Errorf("blocks = %+v", report.Blocks)
+	}
+
+	empty, err := store.Report(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Samples != 0 || empty.Efficiency() != 0 || len(empty.Blocks) != 0 || empty.Uptime != 0 {
+		t.Errorf("Report() of an empty window = %+v", empty)
+	}
+}
+
+func TestParseHistory(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"7d", 7 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"36h", 36 * time.Hour},
+		{"90m", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got, err := ParseHistory(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseHistory(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "d", "-1d", "0d", "1.5d", "week", "-3h"} {
+		if _, err := ParseHistory(in); !errors.Is(err, ErrInvalidHistory) {
+			t.Errorf("ParseHistory(%q) error = %v, want ErrInvalidHistory", in, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package stratum
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ErrClosed indicates the pool connection is closed
+var ErrClosed = errors.New("stratum connection closed")
+
+// Client is a miner's connection to a pool
+type Client struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	nextID      uint64
+	pending     map[uint64]chan *Message
+	extranonce1 []byte
+	en2Size     int
+	difficulty  float64
+	job         *Job
+	generation  uint64
+	done        chan struct{}
+	err         error
+}
+
+// Dial connects to a pool at addr. A stratum+tcp:// scheme is accepted.
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	addr = strings.TrimPrefix(addr, "stratum+tcp://")
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to pool %s: %w", addr, err)
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient wraps an established pool connection
+func NewClient(conn net.Conn) *Client {
+	c := &Client{
+		conn:       conn,
+		pending:    make(map[uint64]chan *Message),
+		difficulty: 1,
+		done:       make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Subscribe subscribes to jobs and receives the extranonce assignment
+func (c *Client) Subscribe(ctx context.Context, agent string) error {
+	resp, err := c.call(ctx, MethodSubscribe, agent)
+	if err != nil {
+		return err
+	}
+	var result []json.RawMessage
+	if err := json.Unmarshal(resp, &result); err != nil || len(result) < 3 {
+		return fmt.Errorf("invalid subscribe result: %s", resp)
+	}
+	var en1Hex string
+	var en2Size int
+	if err := json.Unmarshal(result[1], &en1Hex); err != nil {
+		return fmt.Errorf("invalid extranonce1: %w", err)
+	}
+	if err := json.Unmarshal(result[2], &en2Size); err != nil || en2Size < 1 || en2Size > 8 {
+		return fmt.Errorf("invalid extranonce2 size: %s", result[2])
+	}
+	en1, err := hex.DecodeString(en1Hex)
+	if err != nil {
+		return fmt.Errorf("invalid extranonce1: %w", err)
+	}
+
+	c.mu.Lock()
+	c.extranonce1, c.en2Size = en1, en2Size
+	c.mu.Unlock()
+	return nil
+}
+
+// Authorize authorizes a worker on this connection
+func (c *Client) Authorize(ctx context.Context, worker, password string) error {
+	_, err := c.call(ctx, MethodAuthorize, worker, password)
+	return err
+}
+
+// Submit submits a share. A rejected share returns an *Error.
+func (c *Client) Submit(ctx context.Context, worker, jobID string, extranonce2 []byte, nonce uint64) error {
+	_, err := c.call(ctx, MethodSubmit, worker, jobID, hex.EncodeToString(extranonce2), fmt.Sprintf("%016x", nonce))
+	return err
+}
+
+// Extranonce returns the server-assigned extranonce1 and the extranonce2 size
+func (c *Client) Extranonce() ([]byte, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.extranonce1, c.en2Size
+}
+
+// Difficulty returns the current share difficulty
+func (c *Client) Difficulty() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.difficulty
+}
+
+// Job returns the current job, or nil before the first notify, and a
+// generation number that changes with every new job or difficulty
+func (c *Client) Job() (*Job, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.job, c.generation
+}
+
+// Done is closed when the connection ends
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that ended the connection
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the pool connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) call(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
+	ch := make(chan *Message, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(struct {
+		ID     uint64        `json:"id"`
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}{ID: id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	c.writeMu.Lock()
+	_, err = c.conn.Write(append(data, '\n'))
+	c.writeMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, c.Err()
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	}
+}
+
+func (c *Client) readLoop() {
+	reader := bufio.NewReaderSize(c.conn, 4096)
+	var err error
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		var msg Message
+		if err = json.Unmarshal(line, &msg); err != nil {
+			err = fmt.Errorf("invalid message from pool: %w", err)
+			break
+		}
+		if msg.ID != nil && msg.Method == "" {
+			c.mu.Lock()
+			ch := c.pending[*msg.ID]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- &msg
+			}
+			continue
+		}
+		c.handleNotification(&msg)
+	}
+
+	c.mu.Lock()
+	c.err = fmt.Errorf("%w: %v", ErrClosed, err)
+	c.mu.Unlock()
+	c.conn.Close()
+	close(c.done)
+}
+
+func (c *Client) handleNotification(msg *Message) {
+	switch msg.Method {
+	case MethodNotify:
+		job, err := parseNotify(msg.Params)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.job = job
+		c.generation++
+		c.mu.Unlock()
+	case MethodSetDifficulty:
+		var difficulty float64
+		if len(msg.Params) == 0 || json.Unmarshal(msg.Params[0], &difficulty) != nil || difficulty <= 0 {
+			return
+		}
+		c.mu.Lock()
+		c.difficulty = difficulty
+		c.generation++
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,150 @@
+package stratum
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// noncesPerExtranonce is how many nonces a worker tries before moving to
+// its next extranonce2
+const noncesPerExtranonce = 1 << 32
+
+// pollInterval is how often an idle worker checks for a job
+const pollInterval = 250 * time.Millisecond
+
+// MinerStats are the counters of a pool miner
+type MinerStats struct {
+	Hashes   uint64
+	Accepted uint64
+	Rejected uint64
+	HashRate float64
+}
+
+// Miner mines pool jobs with a pool of worker goroutines. Workers share the
+// connection's extranonce1 and partition extranonce2 by worker index, so no
+// two workers hash the same data.
+type Miner struct {
+	client  *Client
+	worker  string
+	workers int
+
+	// OnShare is called after each submitted share with the pool's verdict
+	OnShare func(jobID string, nonce uint64, err error)
+	// Hash is the proof-of-work hash, crypto.TetraPoWHash unless replaced
+	// before Run; it must match the pool's
+	Hash func(data []byte, nonce uint64) []byte
+
+	started  time.Time
+	hashes   atomic.Uint64
+	accepted atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// NewMiner creates a miner submitting shares as worker
+func NewMiner(client *Client, worker string, workers int) *Miner {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Miner{client: client, worker: worker, workers: workers, Hash: crypto.TetraPoWHash}
+}
+
+// Run mines until ctx is cancelled or the pool connection ends. It returns
+// nil when ctx is cancelled and the connection error otherwise.
+func (m *Miner) Run(ctx context.Context) error {
+	m.started = time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-m.client.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < m.workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			m.work(ctx, id)
+		}(i)
+	}
+	wg.Wait()
+
+	select {
+	case <-m.client.Done():
+		return m.client.Err()
+	default:
+		return nil
+	}
+}
+
+// Stats returns the miner's counters
+func (m *Miner) Stats() MinerStats {
+	stats := MinerStats{
+		Hashes:   m.hashes.Load(),
+		Accepted: m.accepted.Load(),
+		Rejected: m.rejected.Load(),
+	}
+	if elapsed := time.Since(m.started); !m.started.IsZero() && elapsed > 0 {
+		stats.HashRate = float64(stats.Hashes) / elapsed.Seconds()
+	}
+	return stats
+}
+
+func (m *Miner) work(ctx context.Context, id int) {
+	extranonce2 := uint64(id)
+	for {
+		job, generation := m.client.Job()
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		extranonce1, size := m.client.Extranonce()
+		en2 := EncodeExtranonce2(extranonce2, size)
+		extranonce2 += uint64(m.workers)
+		data := WorkData(job, extranonce1, en2)
+		target := TargetForDifficulty(m.client.Difficulty())
+
+		for nonce := uint64(0); nonce < noncesPerExtranonce; nonce++ {
+			if ctx.Err() != nil {
+				return
+			}
+			if _, current := m.client.Job(); current != generation {
+				break
+			}
+
+			hash := m.Hash(data, nonce)
+			m.hashes.Add(1)
+			if HashValue(hash) >= target {
+				continue
+			}
+
+			err := m.client.Submit(ctx, m.worker, job.ID, en2, nonce)
+			var serr *Error
+			switch {
+			case err == nil:
+				m.accepted.Add(1)
+			case errors.As(err, &serr):
+				m.rejected.Add(1)
+			default:
+				return
+			}
+			if m.OnShare != nil {
+				m.OnShare(job.ID, nonce, err)
+			}
+		}
+	}
+}
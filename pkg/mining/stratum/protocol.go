@@ -0,0 +1,157 @@
+// Package stratum implements a Stratum-style pool protocol for Tetra-PoW.
+//
+// Messages are newline-delimited JSON-RPC objects over TCP. A miner
+// subscribes to receive an extranonce1 and jobs, authorizes a worker and
+// submits shares. The work hashed for a share is
+//
+//	job data || extranonce1 || extranonce2
+//
+// with the nonce appended by crypto.TetraPoWHash. A share is valid when the
+// first eight hash bytes, read little-endian, fall below the share target.
+package stratum
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Protocol methods
+const (
+	MethodSubscribe     = "mining.subscribe"
+	MethodAuthorize     = "mining.authorize"
+	MethodSubmit        = "mining.submit"
+	MethodNotify        = "mining.notify"
+	MethodSetDifficulty = "mining.set_difficulty"
+)
+
+// Extranonce1Size is the size of the server-assigned extranonce in bytes
+const Extranonce1Size = 4
+
+// Extranonce2Size is the size of the miner-chosen extranonce in bytes
+const Extranonce2Size = 4
+
+// Stratum error codes
+const (
+	ErrCodeOther         = 20
+	ErrCodeJobNotFound   = 21
+	ErrCodeDuplicate     = 22
+	ErrCodeLowDifficulty = 23
+	ErrCodeUnauthorized  = 24
+	ErrCodeNotSubscribed = 25
+)
+
+// Error is a Stratum error, encoded on the wire as [code, message, null]
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("stratum error %d: %s", e.Code, e.Message)
+}
+
+// MarshalJSON encodes the error as a Stratum error triple
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Code, e.Message, nil})
+}
+
+// UnmarshalJSON decodes a Stratum error triple
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var triple []interface{}
+	if err := json.Unmarshal(data, &triple); err != nil || len(triple) < 2 {
+		return fmt.Errorf("invalid stratum error: %s", data)
+	}
+	code, ok := triple[0].(float64)
+	if !ok {
+		return fmt.Errorf("invalid stratum error code: %v", triple[0])
+	}
+	e.Code = int(code)
+	e.Message = fmt.Sprint(triple[1])
+	return nil
+}
+
+// Message is a request, response or notification. Notifications have a nil ID.
+type Message struct {
+	ID     *uint64           `json:"id"`
+	Method string            `json:"method,omitempty"`
+	Params []json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage   `json:"result,omitempty"`
+	Error  *Error            `json:"error,omitempty"`
+}
+
+// Job is a unit of work announced by the pool
+type Job struct {
+	ID       string
+	PrevHash string
+	Data     []byte
+	// Target is the block target; shares below it solve the job
+	Target uint64
+	// Difficulty, when set, overrides every connection's variable share
+	// difficulty for this job
+	Difficulty float64
+	// Clean tells miners to abandon earlier jobs
+	Clean bool
+}
+
+// notifyParams encodes a job as mining.notify parameters
+func (j *Job) notifyParams() []interface{} {
+	return []interface{}{j.ID, j.PrevHash, hex.EncodeToString(j.Data), fmt.Sprintf("%016x", j.Target), j.Clean}
+}
+
+// parseNotify decodes mining.notify parameters
+func parseNotify(params []json.RawMessage) (*Job, error) {
+	if len(params) < 5 {
+		return nil, fmt.Errorf("mining.notify: expected 5 params, got %d", len(params))
+	}
+	var job Job
+	var data, target string
+	for i, dst := range []interface{}{&job.ID, &job.PrevHash, &data, &target, &job.Clean} {
+		if err := json.Unmarshal(params[i], dst); err != nil {
+			return nil, fmt.Errorf("mining.notify param %d: %w", i, err)
+		}
+	}
+	var err error
+	if job.Data, err = hex.DecodeString(data); err != nil {
+		return nil, fmt.Errorf("mining.notify data: %w", err)
+	}
+	if job.Target, err = strconv.ParseUint(target, 16, 64); err != nil {
+		return nil, fmt.Errorf("mining.notify target: %w", err)
+	}
+	return &job, nil
+}
+
+// WorkData assembles the bytes hashed for a share
+func WorkData(job *Job, extranonce1, extranonce2 []byte) []byte {
+	data := make([]byte, 0, len(job.Data)+len(extranonce1)+len(extranonce2))
+	data = append(data, job.Data...)
+	data = append(data, extranonce1...)
+	return append(data, extranonce2...)
+}
+
+// EncodeExtranonce2 encodes n as a little-endian extranonce2 of size bytes
+func EncodeExtranonce2(n uint64, size int) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+	if size > len(buf) {
+		size = len(buf)
+	}
+	return append([]byte(nil), buf[:size]...)
+}
+
+// TargetForDifficulty converts a share difficulty to a hash target.
+// Difficulty 1 accepts every hash.
+func TargetForDifficulty(difficulty float64) uint64 {
+	if difficulty <= 1 {
+		return math.MaxUint64
+	}
+	return uint64(float64(math.MaxUint64) / difficulty)
+}
+
+// HashValue returns the value compared against targets
+func HashValue(hash []byte) uint64 {
+	return binary.LittleEndian.Uint64(hash[:8])
+}
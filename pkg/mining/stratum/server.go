@@ -0,0 +1,553 @@
+package stratum
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// maxJobs is the number of recent jobs that still accept shares
+const maxJobs = 8
+
+// maxMessageSize bounds a single protocol line
+const maxMessageSize = 64 * 1024
+
+// Config configures a pool server
+type Config struct {
+	// Difficulty is the initial share difficulty of every connection
+	Difficulty    float64
+	MinDifficulty float64
+	MaxDifficulty float64
+	// TargetShareTime is the desired interval between shares per connection
+	TargetShareTime time.Duration
+	// RetargetInterval is how often each connection's share difficulty is
+	// adjusted; zero disables retargeting
+	RetargetInterval time.Duration
+	// Authorize checks worker credentials; nil accepts any non-empty worker
+	Authorize func(worker, password string) bool
+	// OnShare is called for every accepted share
+	OnShare func(Share)
+	// OnBlock is called for accepted shares that also meet the job target
+	OnBlock func(Share)
+	// Hash is the proof-of-work hash shares are checked with,
+	// crypto.TetraPoWHash when nil
+	Hash func(data []byte, nonce uint64) []byte
+}
+
+// DefaultConfig returns the default pool configuration
+func DefaultConfig() Config {
+	return Config{
+		Difficulty:       8,
+		MinDifficulty:    1,
+		MaxDifficulty:    1 << 32,
+		TargetShareTime:  10 * time.Second,
+		RetargetInterval: 2 * time.Minute,
+	}
+}
+
+// Share is an accepted share
+type Share struct {
+	Worker      string
+	JobID       string
+	Extranonce1 string
+	Extranonce2 string
+	Nonce       uint64
+	Hash        []byte
+	Difficulty  float64
+	Block       bool
+	Time        time.Time
+}
+
+// WorkerStats are the share counters of one worker
+type WorkerStats struct {
+	Worker    string
+	Accepted  uint64
+	Rejected  uint64
+	Stale     uint64
+	Work      float64
+	LastShare time.Time
+	// Difficulty is the share difficulty of the worker's last accepted share
+	Difficulty float64
+}
+
+// PoolStats summarizes the pool
+type PoolStats struct {
+	Connections int
+	// Difficulty is the initial share difficulty of new connections
+	Difficulty float64
+	Blocks     uint64
+	Workers    []WorkerStats
+}
+
+// Server is a Stratum pool server
+type Server struct {
+	cfg Config
+
+	mu         sync.Mutex
+	sessions   map[*session]struct{}
+	jobs       map[string]*Job
+	jobOrder   []string
+	current    *Job
+	submitted  map[string]map[string]struct{}
+	difficulty float64
+	extranonce uint32
+	workers    map[string]*WorkerStats
+	blocks     uint64
+	// round is the work credited per worker since the last EndRound
+	round map[string]float64
+}
+
+// session is one miner connection. difficulty is its variable share
+// difficulty and announced the difficulty last sent to the miner, which
+// differs while a job overrides it.
+type session struct {
+	conn        net.Conn
+	writeMu     sync.Mutex
+	extranonce1 []byte
+	subscribed  bool
+	authorized  map[string]bool
+	difficulty  float64
+	prevDiff    float64
+	announced   float64
+	windowStart time.Time
+	windowWork  float64
+}
+
+// NewServer creates a pool server
+func NewServer(cfg Config) *Server {
+	if cfg.Difficulty <= 0 {
+		cfg.Difficulty = DefaultConfig().Difficulty
+	}
+	if cfg.Hash == nil {
+		cfg.Hash = crypto.TetraPoWHash
+	}
+	return &Server{
+		cfg:        cfg,
+		sessions:   make(map[*session]struct{}),
+		jobs:       make(map[string]*Job),
+		submitted:  make(map[string]map[string]struct{}),
+		difficulty: cfg.Difficulty,
+		workers:    make(map[string]*WorkerStats),
+		round:      make(map[string]float64),
+	}
+}
+
+// ListenAndServe listens on addr and serves miners until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts miners on ln until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		s.mu.Lock()
+		for sess := range s.sessions {
+			sess.conn.Close()
+		}
+		s.mu.Unlock()
+	}()
+
+	if s.cfg.RetargetInterval > 0 {
+		go s.retargetLoop(ctx)
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept failed: %w", err)
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// SetJob makes job the current job and announces it to every subscribed
+// miner, preceded by a difficulty change when the job overrides it or ends
+// an override
+func (s *Server) SetJob(job *Job) {
+	s.mu.Lock()
+	if job.Clean {
+		s.jobs = make(map[string]*Job)
+		s.jobOrder = nil
+		s.submitted = make(map[string]map[string]struct{})
+	}
+	s.jobs[job.ID] = job
+	s.jobOrder = append(s.jobOrder, job.ID)
+	s.submitted[job.ID] = make(map[string]struct{})
+	for len(s.jobOrder) > maxJobs {
+		delete(s.jobs, s.jobOrder[0])
+		delete(s.submitted, s.jobOrder[0])
+		s.jobOrder = s.jobOrder[1:]
+	}
+	s.current = job
+	sessions := s.subscribedSessions()
+	changes := make([]float64, len(sessions))
+	for i, sess := range sessions {
+		changes[i] = sess.announce(job)
+	}
+	s.mu.Unlock()
+
+	for i, sess := range sessions {
+		if changes[i] > 0 {
+			sess.notify(MethodSetDifficulty, changes[i])
+		}
+		sess.notify(MethodNotify, job.notifyParams()...)
+	}
+}
+
+// Difficulty returns the initial share difficulty of new connections
+func (s *Server) Difficulty() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.difficulty
+}
+
+// Stats returns pool statistics with workers sorted by name
+func (s *Server) Stats() PoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := PoolStats{Connections: len(s.sessions), Difficulty: s.difficulty, Blocks: s.blocks}
+	for _, w := range s.workers {
+		stats.Workers = append(stats.Workers, *w)
+	}
+	sort.Slice(stats.Workers, func(i, j int) bool {
+		return stats.Workers[i].Worker < stats.Workers[j].Worker
+	})
+	return stats
+}
+
+// EndRound returns the work credited per worker since the last call, the
+// round a found block's reward is split over, and starts a new round
+func (s *Server) EndRound() map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	round := s.round
+	s.round = make(map[string]float64)
+	return round
+}
+
+// subscribedSessions returns subscribed sessions; callers must hold s.mu
+func (s *Server) subscribedSessions() []*session {
+	var sessions []*session
+	for sess := range s.sessions {
+		if sess.subscribed {
+			sessions = append(sessions, sess)
+		}
+	}
+	return sessions
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	s.mu.Lock()
+	s.extranonce++
+	sess := &session{
+		conn:        conn,
+		extranonce1: binary.BigEndian.AppendUint32(nil, s.extranonce),
+		authorized:  make(map[string]bool),
+		difficulty:  s.difficulty,
+		prevDiff:    s.difficulty,
+		windowStart: time.Now(),
+	}
+	s.sessions[sess] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxMessageSize)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return
+		}
+		if msg.ID == nil {
+			continue
+		}
+
+		result, serr := s.dispatch(sess, &msg)
+		sess.respond(*msg.ID, result, serr)
+
+		if msg.Method == MethodSubscribe && serr == nil {
+			s.mu.Lock()
+			job := s.current
+			sess.announce(job)
+			diff := sess.announced
+			s.mu.Unlock()
+			sess.notify(MethodSetDifficulty, diff)
+			if job != nil {
+				sess.notify(MethodNotify, job.notifyParams()...)
+			}
+		}
+	}
+}
+
+func (s *Server) dispatch(sess *session, msg *Message) (interface{}, *Error) {
+	switch msg.Method {
+	case MethodSubscribe:
+		s.mu.Lock()
+		sess.subscribed = true
+		s.mu.Unlock()
+		id := hex.EncodeToString(sess.extranonce1)
+		subscriptions := [][]string{{MethodSetDifficulty, id}, {MethodNotify, id}}
+		return []interface{}{subscriptions, id, Extranonce2Size}, nil
+
+	case MethodAuthorize:
+		var worker, password string
+		if len(msg.Params) > 0 {
+			json.Unmarshal(msg.Params[0], &worker)
+		}
+		if len(msg.Params) > 1 {
+			json.Unmarshal(msg.Params[1], &password)
+		}
+		if worker == "" || (s.cfg.Authorize != nil && !s.cfg.Authorize(worker, password)) {
+			return nil, &Error{Code: ErrCodeUnauthorized, Message: "Unauthorized worker"}
+		}
+		s.mu.Lock()
+		sess.authorized[worker] = true
+		if _, ok := s.workers[worker]; !ok {
+			s.workers[worker] = &WorkerStats{Worker: worker}
+		}
+		s.mu.Unlock()
+		return true, nil
+
+	case MethodSubmit:
+		if err := s.submit(sess, msg.Params); err != nil {
+			return nil, err
+		}
+		return true, nil
+
+	default:
+		return nil, &Error{Code: ErrCodeOther, Message: "Unknown method " + msg.Method}
+	}
+}
+
+// submit validates and records a share
+func (s *Server) submit(sess *session, params []json.RawMessage) *Error {
+	if len(params) < 4 {
+		return &Error{Code: ErrCodeOther, Message: "Expected 4 params"}
+	}
+	var worker, jobID, en2Hex, nonceHex string
+	for i, dst := range []*string{&worker, &jobID, &en2Hex, &nonceHex} {
+		if err := json.Unmarshal(params[i], dst); err != nil {
+			return &Error{Code: ErrCodeOther, Message: "Malformed params"}
+		}
+	}
+	extranonce2, err := hex.DecodeString(en2Hex)
+	if err != nil || len(extranonce2) != Extranonce2Size {
+		return &Error{Code: ErrCodeOther, Message: "Invalid extranonce2"}
+	}
+	nonce, err := strconv.ParseUint(nonceHex, 16, 64)
+	if err != nil {
+		return &Error{Code: ErrCodeOther, Message: "Invalid nonce"}
+	}
+
+	s.mu.Lock()
+	if !sess.subscribed {
+		s.mu.Unlock()
+		return &Error{Code: ErrCodeNotSubscribed, Message: "Not subscribed"}
+	}
+	if !sess.authorized[worker] {
+		s.mu.Unlock()
+		return &Error{Code: ErrCodeUnauthorized, Message: "Unauthorized worker"}
+	}
+	stats := s.workers[worker]
+	job, ok := s.jobs[jobID]
+	if !ok {
+		stats.Stale++
+		s.mu.Unlock()
+		return &Error{Code: ErrCodeJobNotFound, Message: "Job not found"}
+	}
+	key := fmt.Sprintf("%x/%s/%016x", sess.extranonce1, en2Hex, nonce)
+	if _, dup := s.submitted[jobID][key]; dup {
+		stats.Rejected++
+		s.mu.Unlock()
+		return &Error{Code: ErrCodeDuplicate, Message: "Duplicate share"}
+	}
+	s.submitted[jobID][key] = struct{}{}
+	difficulty, prevDiff := sess.difficulty, sess.prevDiff
+	if job.Difficulty > 0 {
+		difficulty, prevDiff = job.Difficulty, job.Difficulty
+	}
+	s.mu.Unlock()
+
+	hash := s.cfg.Hash(WorkData(job, sess.extranonce1, extranonce2), nonce)
+	value := HashValue(hash)
+
+	// Shares mined just before a retarget are credited at the old difficulty
+	credited := difficulty
+	if value >= TargetForDifficulty(difficulty) {
+		credited = prevDiff
+	}
+
+	s.mu.Lock()
+	if value >= TargetForDifficulty(credited) {
+		stats.Rejected++
+		s.mu.Unlock()
+		return &Error{Code: ErrCodeLowDifficulty, Message: "Low difficulty share"}
+	}
+	now := time.Now()
+	stats.Accepted++
+	stats.Work += credited
+	s.round[worker] += credited
+	stats.LastShare = now
+	stats.Difficulty = credited
+	sess.windowWork += credited
+	share := Share{
+		Worker:      worker,
+		JobID:       jobID,
+		Extranonce1: hex.EncodeToString(sess.extranonce1),
+		Extranonce2: en2Hex,
+		Nonce:       nonce,
+		Hash:        hash,
+		Difficulty:  credited,
+		Block:       value < job.Target,
+		Time:        now,
+	}
+	if share.Block {
+		s.blocks++
+	}
+	s.mu.Unlock()
+
+	if s.cfg.OnShare != nil {
+		s.cfg.OnShare(share)
+	}
+	if share.Block && s.cfg.OnBlock != nil {
+		s.cfg.OnBlock(share)
+	}
+	return nil
+}
+
+func (s *Server) retargetLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.RetargetInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.retarget(now)
+		}
+	}
+}
+
+// retarget scales each connection's share difficulty so it submits about
+// one share per TargetShareTime, changing by at most 4x per step. The rate is
+// measured as credited work, so shares at an overridden job difficulty count
+// in proportion. Connections younger than half an interval keep their window.
+func (s *Server) retarget(now time.Time) {
+	s.mu.Lock()
+	if s.cfg.TargetShareTime <= 0 {
+		s.mu.Unlock()
+		return
+	}
+
+	var changed []*session
+	var diffs []float64
+	for sess := range s.sessions {
+		elapsed := now.Sub(sess.windowStart)
+		if len(sess.authorized) == 0 || elapsed <= 0 || elapsed < s.cfg.RetargetInterval/2 {
+			continue
+		}
+		shares := sess.windowWork / sess.difficulty
+		sess.windowStart, sess.windowWork = now, 0
+
+		factor := float64(s.cfg.TargetShareTime) * shares / float64(elapsed)
+		if factor < 0.25 {
+			factor = 0.25
+		} else if factor > 4 {
+			factor = 4
+		}
+		next := sess.difficulty * factor
+		if s.cfg.MinDifficulty > 0 && next < s.cfg.MinDifficulty {
+			next = s.cfg.MinDifficulty
+		}
+		if s.cfg.MaxDifficulty > 0 && next > s.cfg.MaxDifficulty {
+			next = s.cfg.MaxDifficulty
+		}
+		if next == sess.difficulty {
+			continue
+		}
+		sess.prevDiff, sess.difficulty = sess.difficulty, next
+		if sess.subscribed {
+			if diff := sess.announce(s.current); diff > 0 {
+				changed = append(changed, sess)
+				diffs = append(diffs, diff)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for i, sess := range changed {
+		sess.notify(MethodSetDifficulty, diffs[i])
+	}
+}
+
+// announce records the difficulty the miner should use for job and returns
+// it if it differs from the last one sent, or 0; callers must hold s.mu
+func (sess *session) announce(job *Job) float64 {
+	diff := sess.difficulty
+	if job != nil && job.Difficulty > 0 {
+		diff = job.Difficulty
+	}
+	if diff == sess.announced {
+		return 0
+	}
+	sess.announced = diff
+	return diff
+}
+
+func (sess *session) respond(id uint64, result interface{}, serr *Error) {
+	msg := struct {
+		ID     uint64      `json:"id"`
+		Result interface{} `json:"result"`
+		Error  *Error      `json:"error"`
+	}{ID: id, Result: result, Error: serr}
+	sess.write(msg)
+}
+
+func (sess *session) notify(method string, params ...interface{}) {
+	msg := struct {
+		ID     *uint64       `json:"id"`
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}{Method: method, Params: params}
+	sess.write(msg)
+}
+
+func (sess *session) write(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	sess.writeMu.Lock()
+	defer sess.writeMu.Unlock()
+	sess.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := sess.conn.Write(append(data, '\n')); err != nil {
+		sess.conn.Close()
+	}
+}
@@ -0,0 +1,274 @@
+package stratum
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// quickHash stands in for crypto.TetraPoWHash, whose cost would make mining
+// tests slow, especially under the race detector
+func quickHash(data []byte, nonce uint64) []byte {
+	hash := sha256.Sum256(binary.LittleEndian.AppendUint64(append([]byte(nil), data...), nonce))
+	return hash[:]
+}
+
+func startServer(t *testing.T, cfg Config) (*Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	server := NewServer(cfg)
+	go server.Serve(ctx, ln)
+	return server, ln.Addr().String()
+}
+
+func dialPool(t *testing.T, addr string) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := Dial(ctx, "stratum+tcp://"+addr)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if err := client.Subscribe(ctx, "test"); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := client.Authorize(ctx, "worker1", "x"); err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	return client
+}
+
+func waitForJob(t *testing.T, client *Client) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := client.Job(); job != nil {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for a job")
+	return nil
+}
+
+func TestSubscribeAssignsUniqueExtranonce(t *testing.T) {
+	_, addr := startServer(t, Config{Difficulty: 1})
+
+	a := dialPool(t, addr)
+	b := dialPool(t, addr)
+
+	en1a, size := a.Extranonce()
+	en1b, _ := b.Extranonce()
+	if len(en1a) != Extranonce1Size || size != Extranonce2Size {
+		t.Fatalf("Unexpected extranonce sizes %d/%d", len(en1a), size)
+	}
+	if string(en1a) == string(en1b) {
+		t.Error("Expected distinct extranonce1 per connection")
+	}
+}
+
+func TestSubmitShare(t *testing.T) {
+	var mu sync.Mutex
+	var shares []Share
+	server, addr := startServer(t, Config{
+		Difficulty: 1,
+		OnShare: func(s Share) {
+			mu.Lock()
+			shares = append(shares, s)
+			mu.Unlock()
+		},
+	})
+	client := dialPool(t, addr)
+	server.SetJob(&Job{ID: "1", PrevHash: "00", Data: []byte("block"), Target: 0, Clean: true})
+	job := waitForJob(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	en2 := EncodeExtranonce2(0, Extranonce2Size)
+	if err := client.Submit(ctx, "worker1", job.ID, en2, 7); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	var serr *Error
+	err := client.Submit(ctx, "worker1", job.ID, en2, 7)
+	if !errors.As(err, &serr) || serr.Code != ErrCodeDuplicate {
+		t.Errorf("Expected duplicate share error, got %v", err)
+	}
+	err = client.Submit(ctx, "worker1", "missing", en2, 8)
+	if !errors.As(err, &serr) || serr.Code != ErrCodeJobNotFound {
+		t.Errorf("Expected job not found error, got %v", err)
+	}
+	err = client.Submit(ctx, "other", job.ID, en2, 9)
+	if !errors.As(err, &serr) || serr.Code != ErrCodeUnauthorized {
+		t.Errorf("Expected unauthorized error, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(shares) != 1 || shares[0].Worker != "worker1" || shares[0].Block {
+		t.Errorf("Unexpected shares %+v", shares)
+	}
+	stats := server.Stats()
+	if len(stats.Workers) != 1 || stats.Workers[0].Accepted != 1 || stats.Workers[0].Stale != 1 {
+		t.Errorf("Unexpected worker stats %+v", stats.Workers)
+	}
+	if round := server.EndRound(); len(round) != 1 || round["worker1"] != 1 {
+		t.Errorf("EndRound() = %v, want worker1's one share", round)
+	}
+	if round := server.EndRound(); len(round) != 0 {
+		t.Errorf("Expected a new round to start empty, got %v", round)
+	}
+}
+
+func waitForDifficulty(t *testing.T, client *Client, want float64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Difficulty() != want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := client.Difficulty(); got != want {
+		t.Fatalf("Client difficulty = %v, want %v", got, want)
+	}
+}
+
+// sessionFor returns the server session of a client by its extranonce1
+func sessionFor(server *Server, client *Client) *session {
+	en1, _ := client.Extranonce()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for sess := range server.sessions {
+		if string(sess.extranonce1) == string(en1) {
+			return sess
+		}
+	}
+	return nil
+}
+
+func TestRetarget(t *testing.T) {
+	server, addr := startServer(t, Config{
+		Difficulty:      16,
+		MinDifficulty:   1,
+		MaxDifficulty:   1024,
+		TargetShareTime: 10 * time.Second,
+	})
+	slow := dialPool(t, addr)
+	fast := dialPool(t, addr)
+	waitForDifficulty(t, slow, 16)
+
+	// A minute at 16 is six shares on target; the fast miner did 1000
+	sess := sessionFor(server, fast)
+	server.mu.Lock()
+	sess.windowWork = 1000 * 16
+	server.mu.Unlock()
+
+	// No shares lowers difficulty by the maximum step, many raise it by it
+	server.retarget(time.Now().Add(time.Minute))
+	waitForDifficulty(t, slow, 4)
+	waitForDifficulty(t, fast, 64)
+	if got := server.Difficulty(); got != 16 {
+		t.Errorf("Difficulty() = %v, want the initial 16", got)
+	}
+
+	// On target: six shares in a minute keeps the difficulty
+	server.mu.Lock()
+	sess.windowWork = 6 * 64
+	start := sess.windowStart
+	server.mu.Unlock()
+	server.retarget(start.Add(time.Minute))
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if sess.difficulty != 64 {
+		t.Errorf("Session difficulty = %v, want 64", sess.difficulty)
+	}
+}
+
+func TestJobDifficultyOverride(t *testing.T) {
+	server, addr := startServer(t, Config{Difficulty: 4})
+	client := dialPool(t, addr)
+	waitForDifficulty(t, client, 4)
+
+	server.SetJob(&Job{ID: "1", PrevHash: "00", Data: []byte("block"), Difficulty: 1, Clean: true})
+	waitForDifficulty(t, client, 1)
+
+	// Difficulty 1 accepts any hash, so this share must be judged at the override
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Submit(ctx, "worker1", "1", EncodeExtranonce2(0, Extranonce2Size), 1); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if w := server.Stats().Workers[0]; w.Difficulty != 1 || w.Work != 1 {
+		t.Errorf("Expected a share credited at difficulty 1, got %+v", w)
+	}
+
+	// The next job without an override restores the connection's difficulty
+	server.SetJob(&Job{ID: "2", PrevHash: "00", Data: []byte("block")})
+	waitForDifficulty(t, client, 4)
+}
+
+func TestMinerFindsBlock(t *testing.T) {
+	found := make(chan Share, 1)
+	server, addr := startServer(t, Config{
+		Difficulty: 1,
+		OnBlock: func(s Share) {
+			select {
+			case found <- s:
+			default:
+			}
+		},
+		Hash: quickHash,
+	})
+	client := dialPool(t, addr)
+	server.SetJob(&Job{ID: "1", PrevHash: "00", Data: []byte("block"), Target: 0xFFFFFFFFFFFFFF00, Clean: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	miner := NewMiner(client, "worker1", 2)
+	miner.Hash = quickHash
+	errc := make(chan error, 1)
+	go func() { errc <- miner.Run(ctx) }()
+
+	select {
+	case share := <-found:
+		if !share.Block || share.Worker != "worker1" {
+			t.Errorf("Unexpected block share %+v", share)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for a block")
+	}
+
+	// The pool records the share before the miner reads its verdict
+	deadline := time.Now().Add(5 * time.Second)
+	for miner.Stats().Accepted == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if miner.Stats().Accepted == 0 {
+		t.Error("Expected accepted shares")
+	}
+
+	cancel()
+	if err := <-errc; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestTargetForDifficulty(t *testing.T) {
+	if TargetForDifficulty(1) != ^uint64(0) {
+		t.Error("Difficulty 1 should accept every hash")
+	}
+	if TargetForDifficulty(4) >= TargetForDifficulty(2) {
+		t.Error("Higher difficulty should lower the target")
+	}
+}
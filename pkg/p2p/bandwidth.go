@@ -0,0 +1,108 @@
+package p2p
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BandwidthStats counts bytes received and sent on the wire, including
+// message headers and transport encryption overhead
+type BandwidthStats struct {
+	BytesIn  uint64 `json:"bytes_in"`
+	BytesOut uint64 `json:"bytes_out"`
+}
+
+// Sub returns the traffic since an earlier sample
+func (s BandwidthStats) Sub(earlier BandwidthStats) BandwidthStats {
+	return BandwidthStats{BytesIn: s.BytesIn - earlier.BytesIn, BytesOut: s.BytesOut - earlier.BytesOut}
+}
+
+// bandwidthCounter accumulates traffic with atomic counters
+type bandwidthCounter struct {
+	in  atomic.Uint64
+	out atomic.Uint64
+}
+
+func (c *bandwidthCounter) stats() BandwidthStats {
+	return BandwidthStats{BytesIn: c.in.Load(), BytesOut: c.out.Load()}
+}
+
+// RateLimiter is a token bucket limiting throughput to a number of bytes per
+// second. A transfer larger than the bucket is allowed through and repaid by
+// waiting, so callers never need to split their reads and writes.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter for bytesPerSecond, allowing bursts of up
+// to one second of traffic. It returns nil, which never limits, for a rate of
+// zero or less.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	rate := float64(bytesPerSecond)
+	return &RateLimiter{rate: rate, burst: rate, tokens: rate, last: time.Now()}
+}
+
+// Wait takes n bytes from the bucket, sleeping while it is in debt
+func (l *RateLimiter) Wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// meteredConn counts traffic into a peer and a node total and applies the
+// node's rate limits
+type meteredConn struct {
+	net.Conn
+	peer     *bandwidthCounter
+	total    *bandwidthCounter
+	download *RateLimiter
+	upload   *RateLimiter
+}
+
+func (c *meteredConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.peer.in.Add(uint64(n))
+		c.total.in.Add(uint64(n))
+		// Throttle after the fact: the sleep delays the next read, which in
+		// turn backs TCP flow control up to the sender
+		c.download.Wait(n)
+	}
+	return n, err
+}
+
+func (c *meteredConn) Write(p []byte) (int, error) {
+	c.upload.Wait(len(p))
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.peer.out.Add(uint64(n))
+		c.total.out.Add(uint64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(10000)
+
+	// The first second of traffic passes as a burst, the next 5000 bytes
+	// take about half a second
+	start := time.Now()
+	limiter.Wait(10000)
+	limiter.Wait(5000)
+	elapsed := time.Since(start)
+	if elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Expected about 500ms of throttling, got %v", elapsed)
+	}
+
+	unlimited := NewRateLimiter(0)
+	start = time.Now()
+	unlimited.Wait(1 << 30)
+	if time.Since(start) > 50*time.Millisecond {
+		t.Error("Expected no throttling without a rate")
+	}
+}
+
+func TestNodePeerTraffic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewNode(testConfig())
+	if err != nil {
+		t.Fatalf("NewNode() error = %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	go server.Serve(ctx, ln)
+	defer server.Close()
+
+	client, err := NewNode(testConfig())
+	if err != nil {
+		t.Fatalf("NewNode() error = %v", err)
+	}
+	defer client.Close()
+	if _, err := client.Connect(ctx, ln.Addr().String()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	peers := client.Peers()
+	if len(peers) != 1 {
+		t.Fatalf("Expected 1 peer, got %d", len(peers))
+	}
+	traffic := peers[0].Traffic
+	// Transport keys, version, verack and exsfeatures in each direction
+	if traffic.BytesIn < 200 || traffic.BytesOut < 200 {
+		t.Errorf("Expected handshake traffic, got %+v", traffic)
+	}
+	if total := client.Bandwidth(); total != traffic {
+		t.Errorf("Expected node total %+v to match the only peer %+v", total, traffic)
+	}
+
+	// The server sees the same bytes in the other direction
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Bandwidth().BytesIn != traffic.BytesOut && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := server.Bandwidth(); got.BytesIn != traffic.BytesOut || got.BytesOut != traffic.BytesIn {
+		t.Errorf("Server traffic %+v does not mirror client %+v", got, traffic)
+	}
+}
@@ -0,0 +1,185 @@
+// Package p2p implements the EXS peer-to-peer handshake.
+//
+// EXS nodes speak the Bitcoin wire protocol and extend the version/verack
+// handshake with EXS service bits and an exsfeatures message carrying the
+// Tetra-PoW version, optional features and node uptime, so incompatible
+// peers are rejected before any sync traffic is exchanged.
+package p2p
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// EXS service bits, taken from the range BIP-0159 leaves for experiments
+const (
+	// SFNodeEXS marks a node that speaks the EXS protocol extensions
+	SFNodeEXS wire.ServiceFlag = 1 << 24
+	// SFNodeTetraPoW marks a node that validates Tetra-PoW forges
+	SFNodeTetraPoW wire.ServiceFlag = 1 << 25
+	// SFNodeRunes marks a node that indexes runes
+	SFNodeRunes wire.ServiceFlag = 1 << 26
+)
+
+// TetraPoWVersion is the Tetra-PoW consensus version this node validates
+const TetraPoWVersion uint32 = 1
+
+// CmdFeatures is the command of the feature negotiation message
+const CmdFeatures = "exsfeatures"
+
+// featuresMessageVersion is the encoding version of MsgFeatures
+const featuresMessageVersion uint32 = 1
+
+// maxNodeVersionLen bounds the node version string in MsgFeatures
+const maxNodeVersionLen = 64
+
+// FeatureFlag is a bitfield of optional EXS features
+type FeatureFlag uint64
+
+// Optional EXS features
+const (
+	// FeatureRunes indicates rune indexing and relay support
+	FeatureRunes FeatureFlag = 1 << iota
+	// FeatureCompactFilters indicates BIP-157/158 compact filter serving
+	FeatureCompactFilters
+	// FeatureForgeCommitments indicates OP_RETURN forge commitment indexing
+	FeatureForgeCommitments
+)
+
+var featureNames = []struct {
+	flag FeatureFlag
+	name string
+}{
+	{FeatureRunes, "runes"},
+	{FeatureCompactFilters, "compact-filters"},
+	{FeatureForgeCommitments, "forge-commitments"},
+}
+
+// Has reports whether f includes every feature in want
+func (f FeatureFlag) Has(want FeatureFlag) bool {
+	return f&want == want
+}
+
+// String lists the feature names, or "none"
+func (f FeatureFlag) String() string {
+	var names []string
+	for _, fn := range featureNames {
+		if f.Has(fn.flag) {
+			names = append(names, fn.name)
+			f &^= fn.flag
+		}
+	}
+	if f != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint64(f)))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseFeatures parses a comma-separated list of feature names
+func ParseFeatures(s string) (FeatureFlag, error) {
+	var flags FeatureFlag
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+		found := false
+		for _, fn := range featureNames {
+			if fn.name == name {
+				flags |= fn.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown feature %q", name)
+		}
+	}
+	return flags, nil
+}
+
+// MsgFeatures is the exsfeatures message sent after a peer's version is
+// accepted
+type MsgFeatures struct {
+	TetraPoWVersion uint32
+	Features        FeatureFlag
+	NodeVersion     string
+	StartTime       time.Time
+}
+
+// Uptime returns how long the sending node had been running at time now
+func (m *MsgFeatures) Uptime(now time.Time) time.Duration {
+	if m.StartTime.IsZero() || now.Before(m.StartTime) {
+		return 0
+	}
+	return now.Sub(m.StartTime)
+}
+
+// BtcDecode decodes the message payload
+func (m *MsgFeatures) BtcDecode(r io.Reader, pver uint32, enc wire.MessageEncoding) error {
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version < featuresMessageVersion {
+		return fmt.Errorf("unsupported %s version %d", CmdFeatures, version)
+	}
+	var features uint64
+	var startTime int64
+	if err := binary.Read(r, binary.LittleEndian, &m.TetraPoWVersion); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &features); err != nil {
+		return err
+	}
+	nodeVersion, err := wire.ReadVarString(r, pver)
+	if err != nil {
+		return err
+	}
+	if len(nodeVersion) > maxNodeVersionLen {
+		return fmt.Errorf("%s node version too long: %d bytes", CmdFeatures, len(nodeVersion))
+	}
+	if err := binary.Read(r, binary.LittleEndian, &startTime); err != nil {
+		return err
+	}
+
+	m.Features = FeatureFlag(features)
+	m.NodeVersion = nodeVersion
+	m.StartTime = time.Unix(startTime, 0)
+	return nil
+}
+
+// BtcEncode encodes the message payload
+func (m *MsgFeatures) BtcEncode(w io.Writer, pver uint32, enc wire.MessageEncoding) error {
+	if len(m.NodeVersion) > maxNodeVersionLen {
+		return fmt.Errorf("%s node version too long: %d bytes", CmdFeatures, len(m.NodeVersion))
+	}
+	for _, v := range []interface{}{featuresMessageVersion, m.TetraPoWVersion, uint64(m.Features)} {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	if err := wire.WriteVarString(w, pver, m.NodeVersion); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, m.StartTime.Unix())
+}
+
+// Command returns the message command
+func (m *MsgFeatures) Command() string {
+	return CmdFeatures
+}
+
+// MaxPayloadLength returns the largest payload the message can have
+func (m *MsgFeatures) MaxPayloadLength(pver uint32) uint32 {
+	// versions, features, var string and start time
+	return 4 + 4 + 8 + uint32(wire.MaxVarIntPayload) + maxNodeVersionLen + 8
+}
@@ -0,0 +1,253 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chaos"
+)
+
+// DefaultHandshakeTimeout bounds the version/verack/exsfeatures exchange
+const DefaultHandshakeTimeout = 30 * time.Second
+
+// MinProtocolVersion is the oldest wire protocol version accepted from peers
+const MinProtocolVersion = wire.SendHeadersVersion
+
+var (
+	// ErrIncompatiblePeer indicates a peer without a required service bit,
+	// Tetra-PoW version or feature
+	ErrIncompatiblePeer = errors.New("incompatible peer")
+	// ErrSelfConnection indicates a connection to this node itself
+	ErrSelfConnection = errors.New("connected to self")
+)
+
+// Config describes the local node to peers
+type Config struct {
+	Network *chaincfg.Params
+	// UserAgent is the node name and version, e.g. "exs-node:1.0.0"
+	UserAgent string
+	// Services are the advertised service bits; SFNodeEXS and
+	// SFNodeTetraPoW are always added
+	Services wire.ServiceFlag
+	// Features are the optional EXS features this node offers
+	Features FeatureFlag
+	// RequiredFeatures are the features a peer must offer
+	RequiredFeatures FeatureFlag
+	// StartHeight returns the current best height; nil reports 0
+	StartHeight func() int32
+	// HandshakeTimeout defaults to DefaultHandshakeTimeout
+	HandshakeTimeout time.Duration
+	// StartTime is when the node started, reported to peers as uptime
+	StartTime time.Time
+	// Nonce identifies this node in version messages to detect connections
+	// to itself; zero uses a fresh nonce per handshake
+	Nonce uint64
+	// DisableEncryption connects and accepts peers in plaintext only
+	DisableEncryption bool
+	// RequireEncryption rejects peers that do not negotiate the encrypted
+	// transport instead of falling back to plaintext
+	RequireEncryption bool
+	// MaxUploadRate and MaxDownloadRate limit the node's total traffic in
+	// bytes per second; zero is unlimited
+	MaxUploadRate   int64
+	MaxDownloadRate int64
+	// Chaos injects faults into every peer connection for resilience
+	// testing; nil injects nothing
+	Chaos *chaos.Injector
+}
+
+// PeerInfo is what a peer announced during the handshake
+type PeerInfo struct {
+	Addr            string
+	Inbound         bool
+	ProtocolVersion uint32
+	Services        wire.ServiceFlag
+	UserAgent       string
+	StartHeight     int32
+	TetraPoWVersion uint32
+	Features        FeatureFlag
+	NodeVersion     string
+	// StartTime is when the peer reports it started
+	StartTime   time.Time
+	ConnectedAt time.Time
+	// TimeOffset is the peer's clock minus the local clock, from the
+	// timestamp in its version message
+	TimeOffset time.Duration
+	// Encrypted reports whether the connection uses the encrypted transport
+	Encrypted bool
+	// SessionID is the encrypted transport session ID, the same on both ends
+	SessionID []byte
+	// Traffic is the peer's bandwidth use, filled in by Node.Peers
+	Traffic BandwidthStats
+}
+
+// Uptime returns how long the peer has been running
+func (p *PeerInfo) Uptime() time.Duration {
+	if p.StartTime.IsZero() {
+		return 0
+	}
+	return time.Since(p.StartTime)
+}
+
+// services returns the advertised service bits
+func (c *Config) services() wire.ServiceFlag {
+	services := c.Services | SFNodeEXS | SFNodeTetraPoW
+	if c.Features.Has(FeatureRunes) {
+		services |= SFNodeRunes
+	}
+	if c.Features.Has(FeatureCompactFilters) {
+		services |= wire.SFNodeCF
+	}
+	return services
+}
+
+// handshakeTimeout returns the handshake timeout
+func (c *Config) handshakeTimeout() time.Duration {
+	if c.HandshakeTimeout <= 0 {
+		return DefaultHandshakeTimeout
+	}
+	return c.HandshakeTimeout
+}
+
+// Handshake performs the version, verack and exsfeatures exchange on conn
+// and returns what the peer announced. Peers missing the EXS service bits,
+// running another Tetra-PoW version or lacking required features are
+// rejected with ErrIncompatiblePeer.
+func Handshake(conn net.Conn, cfg *Config, inbound bool) (*PeerInfo, error) {
+	conn.SetDeadline(time.Now().Add(cfg.handshakeTimeout()))
+	defer conn.SetDeadline(time.Time{})
+
+	btcnet := cfg.Network.Net
+	pver := wire.ProtocolVersion
+
+	nonce := cfg.Nonce
+	if nonce == 0 {
+		var err error
+		if nonce, err = RandomNonce(); err != nil {
+			return nil, err
+		}
+	}
+	var height int32
+	if cfg.StartHeight != nil {
+		height = cfg.StartHeight()
+	}
+	local := localVersion(conn, cfg, nonce, height)
+	if err := WriteMessage(conn, local, pver, btcnet); err != nil {
+		return nil, fmt.Errorf("failed to send version: %w", err)
+	}
+
+	msg, err := ReadMessage(conn, pver, btcnet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version: %w", err)
+	}
+	remote, ok := msg.(*wire.MsgVersion)
+	if !ok {
+		return nil, fmt.Errorf("expected version, got %s", msg.Command())
+	}
+	if remote.Nonce == nonce {
+		return nil, ErrSelfConnection
+	}
+
+	info := &PeerInfo{
+		Addr:            conn.RemoteAddr().String(),
+		Inbound:         inbound,
+		ProtocolVersion: uint32(remote.ProtocolVersion),
+		Services:        remote.Services,
+		UserAgent:       remote.UserAgent,
+		StartHeight:     remote.LastBlock,
+		TimeOffset:      remote.Timestamp.Sub(time.Now().Truncate(time.Second)),
+	}
+	if ec, ok := conn.(*EncryptedConn); ok {
+		info.Encrypted = true
+		info.SessionID = ec.SessionID()
+	}
+	if info.ProtocolVersion < MinProtocolVersion {
+		return info, fmt.Errorf("%w: protocol version %d is older than %d", ErrIncompatiblePeer, info.ProtocolVersion, MinProtocolVersion)
+	}
+	if !info.Services.HasFlag(SFNodeEXS) || !info.Services.HasFlag(SFNodeTetraPoW) {
+		return info, fmt.Errorf("%w: %s does not advertise EXS services (%s)", ErrIncompatiblePeer, info.UserAgent, info.Services)
+	}
+	if uint32(remote.ProtocolVersion) < pver {
+		pver = uint32(remote.ProtocolVersion)
+	}
+
+	features := &MsgFeatures{
+		TetraPoWVersion: TetraPoWVersion,
+		Features:        cfg.Features,
+		NodeVersion:     cfg.UserAgent,
+		StartTime:       cfg.StartTime,
+	}
+	if err := WriteMessage(conn, wire.NewMsgVerAck(), pver, btcnet); err != nil {
+		return info, fmt.Errorf("failed to send verack: %w", err)
+	}
+	if err := WriteMessage(conn, features, pver, btcnet); err != nil {
+		return info, fmt.Errorf("failed to send %s: %w", CmdFeatures, err)
+	}
+
+	var gotVerAck, gotFeatures bool
+	for !gotVerAck || !gotFeatures {
+		msg, err := ReadMessage(conn, pver, btcnet)
+		if err != nil {
+			return info, fmt.Errorf("handshake with %s failed: %w", info.Addr, err)
+		}
+		switch m := msg.(type) {
+		case *wire.MsgVerAck:
+			gotVerAck = true
+		case *MsgFeatures:
+			info.TetraPoWVersion = m.TetraPoWVersion
+			info.Features = m.Features
+			info.NodeVersion = m.NodeVersion
+			info.StartTime = m.StartTime
+			gotFeatures = true
+		default:
+			return info, fmt.Errorf("unexpected %s during handshake", msg.Command())
+		}
+	}
+
+	if info.TetraPoWVersion != TetraPoWVersion {
+		return info, fmt.Errorf("%w: Tetra-PoW version %d, want %d", ErrIncompatiblePeer, info.TetraPoWVersion, TetraPoWVersion)
+	}
+	if !info.Features.Has(cfg.RequiredFeatures) {
+		missing := cfg.RequiredFeatures &^ info.Features
+		return info, fmt.Errorf("%w: missing features %s", ErrIncompatiblePeer, missing)
+	}
+	info.ConnectedAt = time.Now()
+	return info, nil
+}
+
+// localVersion builds the version message announcing this node
+func localVersion(conn net.Conn, cfg *Config, nonce uint64, height int32) *wire.MsgVersion {
+	services := cfg.services()
+	me := netAddress(conn.LocalAddr(), services)
+	you := netAddress(conn.RemoteAddr(), 0)
+	msg := wire.NewMsgVersion(me, you, nonce, height)
+	msg.Services = services
+	msg.ProtocolVersion = int32(wire.ProtocolVersion)
+	if cfg.UserAgent != "" {
+		msg.UserAgent = "/" + cfg.UserAgent + "/"
+	}
+	return msg
+}
+
+func netAddress(addr net.Addr, services wire.ServiceFlag) *wire.NetAddress {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return wire.NewNetAddress(tcp, services)
+	}
+	return wire.NewNetAddressIPPort(net.IPv4zero, 0, services)
+}
+
+// RandomNonce returns a random version message nonce
+func RandomNonce() (uint64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
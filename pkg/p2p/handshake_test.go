@@ -0,0 +1,220 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func testConfig() Config {
+	return Config{
+		Network:          &chaincfg.RegressionNetParams,
+		UserAgent:        "exs-node:test",
+		HandshakeTimeout: 5 * time.Second,
+		StartTime:        time.Now().Add(-time.Hour),
+	}
+}
+
+// handshakePair runs both sides of a handshake over a TCP loopback pair
+func handshakePair(t *testing.T, a, b Config) (*PeerInfo, error, *PeerInfo, error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	type result struct {
+		info *PeerInfo
+		err  error
+	}
+	inbound := make(chan result, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			inbound <- result{nil, err}
+			return
+		}
+		defer conn.Close()
+		info, err := Handshake(conn, &b, true)
+		inbound <- result{info, err}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	info, err := Handshake(conn, &a, false)
+	if err != nil {
+		// Unblock the other side
+		conn.Close()
+	}
+	r := <-inbound
+	return info, err, r.info, r.err
+}
+
+func TestHandshake(t *testing.T) {
+	a := testConfig()
+	a.Features = FeatureCompactFilters
+	b := testConfig()
+	b.Features = FeatureRunes | FeatureCompactFilters
+	b.StartHeight = func() int32 { return 42 }
+
+	outInfo, outErr, inInfo, inErr := handshakePair(t, a, b)
+	if outErr != nil || inErr != nil {
+		t.Fatalf("Handshake() errors = %v, %v", outErr, inErr)
+	}
+
+	if !outInfo.Services.HasFlag(SFNodeRunes) || !outInfo.Services.HasFlag(wire.SFNodeCF) {
+		t.Errorf("Expected runes and compact filter services, got %s", outInfo.Services)
+	}
+	if outInfo.StartHeight != 42 || outInfo.UserAgent != "/exs-node:test/" {
+		t.Errorf("Unexpected version info %+v", outInfo)
+	}
+	if !outInfo.Features.Has(FeatureRunes|FeatureCompactFilters) || outInfo.TetraPoWVersion != TetraPoWVersion {
+		t.Errorf("Unexpected features %s, Tetra-PoW %d", outInfo.Features, outInfo.TetraPoWVersion)
+	}
+	if uptime := outInfo.Uptime(); uptime < 59*time.Minute {
+		t.Errorf("Expected about an hour of uptime, got %v", uptime)
+	}
+	if !inInfo.Inbound || inInfo.Features != FeatureCompactFilters {
+		t.Errorf("Unexpected inbound info %+v", inInfo)
+	}
+	// Version timestamps have whole seconds
+	if offset := outInfo.TimeOffset; offset.Abs() > time.Second {
+		t.Errorf("Expected a clock offset within a second, got %v", offset)
+	}
+}
+
+func TestHandshakeRequiredFeatures(t *testing.T) {
+	a := testConfig()
+	a.RequiredFeatures = FeatureRunes
+	b := testConfig()
+
+	_, err, _, _ := handshakePair(t, a, b)
+	if !errors.Is(err, ErrIncompatiblePeer) {
+		t.Errorf("Expected ErrIncompatiblePeer, got %v", err)
+	}
+}
+
+func TestHandshakeSelfConnection(t *testing.T) {
+	a := testConfig()
+	a.Nonce = 7
+	b := testConfig()
+	b.Nonce = 7
+
+	_, err, _, _ := handshakePair(t, a, b)
+	if !errors.Is(err, ErrSelfConnection) {
+		t.Errorf("Expected ErrSelfConnection, got %v", err)
+	}
+}
+
+func TestHandshakeRejectsNonEXSPeer(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// A plain Bitcoin node announces no EXS service bits
+	go func() {
+		me := wire.NewNetAddressIPPort(net.IPv4zero, 0, wire.SFNodeNetwork)
+		version := wire.NewMsgVersion(me, me, 1, 0)
+		version.Services = wire.SFNodeNetwork
+		ReadMessage(server, wire.ProtocolVersion, chaincfg.RegressionNetParams.Net)
+		WriteMessage(server, version, wire.ProtocolVersion, chaincfg.RegressionNetParams.Net)
+	}()
+
+	cfg := testConfig()
+	_, err := Handshake(client, &cfg, false)
+	if !errors.Is(err, ErrIncompatiblePeer) {
+		t.Errorf("Expected ErrIncompatiblePeer, got %v", err)
+	}
+}
+
+func TestMsgFeaturesRoundTrip(t *testing.T) {
+	msg := &MsgFeatures{
+		TetraPoWVersion: 3,
+		Features:        FeatureRunes | FeatureForgeCommitments,
+		NodeVersion:     "exs-node:1.0.0",
+		StartTime:       time.Unix(1700000000, 0),
+	}
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, msg, wire.ProtocolVersion, wire.TestNet); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	decoded, err := ReadMessage(&buf, wire.ProtocolVersion, wire.TestNet)
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	got, ok := decoded.(*MsgFeatures)
+	if !ok {
+		t.Fatalf("Expected *MsgFeatures, got %T", decoded)
+	}
+	if got.TetraPoWVersion != 3 || got.Features != msg.Features || got.NodeVersion != msg.NodeVersion || !got.StartTime.Equal(msg.StartTime) {
+		t.Errorf("Round trip mismatch: %+v", got)
+	}
+}
+
+func TestParseFeatures(t *testing.T) {
+	flags, err := ParseFeatures("runes, compact-filters")
+	if err != nil {
+		t.Fatalf("ParseFeatures() error = %v", err)
+	}
+	if flags != FeatureRunes|FeatureCompactFilters || flags.String() != "runes,compact-filters" {
+		t.Errorf("Unexpected features %s", flags)
+	}
+	if _, err := ParseFeatures("teleport"); err == nil {
+		t.Error("Expected error for unknown feature")
+	}
+}
+
+func TestNodePeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewNode(testConfig())
+	if err != nil {
+		t.Fatalf("NewNode() error = %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	addr := ln.Addr().String()
+	go server.Serve(ctx, ln)
+	defer server.Close()
+
+	client, err := NewNode(testConfig())
+	if err != nil {
+		t.Fatalf("NewNode() error = %v", err)
+	}
+	defer client.Close()
+
+	info, err := client.Connect(ctx, addr)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if info.Inbound {
+		t.Error("Expected outbound peer")
+	}
+	if len(client.Peers()) != 1 {
+		t.Errorf("Expected 1 client peer, got %d", len(client.Peers()))
+	}
+	if offset, samples := client.TimeOffset(); samples != 1 || offset.Abs() > time.Second {
+		t.Errorf("Expected 1 peer time sample near zero, got %v from %d", offset, samples)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.Peers()) != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if peers := server.Peers(); len(peers) != 1 || !peers[0].Inbound {
+		t.Errorf("Expected 1 inbound server peer, got %+v", peers)
+	}
+}
@@ -0,0 +1,63 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ReadMessage reads one message from r. EXS messages are decoded here and
+// everything else is handed to the btcd wire decoder.
+func ReadMessage(r io.Reader, pver uint32, net wire.BitcoinNet) (wire.Message, error) {
+	var header [wire.MessageHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	magic := wire.BitcoinNet(binary.LittleEndian.Uint32(header[0:4]))
+	command := string(bytes.TrimRight(header[4:16], "\x00"))
+	length := binary.LittleEndian.Uint32(header[16:20])
+
+	if magic != net {
+		return nil, fmt.Errorf("message from other network [%v]", magic)
+	}
+	if length > wire.MaxMessagePayload {
+		return nil, fmt.Errorf("message payload too large: %d bytes", length)
+	}
+
+	var exs wire.Message
+	switch command {
+	case CmdFeatures:
+		exs = &MsgFeatures{}
+	}
+	if exs != nil && length > exs.MaxPayloadLength(pver) {
+		return nil, fmt.Errorf("%s payload too large: %d bytes", command, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	if exs == nil {
+		frame := io.MultiReader(bytes.NewReader(header[:]), bytes.NewReader(payload))
+		msg, _, err := wire.ReadMessage(frame, pver, net)
+		return msg, err
+	}
+
+	if !bytes.Equal(chainhash.DoubleHashB(payload)[:4], header[20:24]) {
+		return nil, fmt.Errorf("%s payload checksum failed", command)
+	}
+	if err := exs.BtcDecode(bytes.NewReader(payload), pver, wire.BaseEncoding); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", command, err)
+	}
+	return exs, nil
+}
+
+// WriteMessage writes one message to w
+func WriteMessage(w io.Writer, msg wire.Message, pver uint32, net wire.BitcoinNet) error {
+	return wire.WriteMessage(w, msg, pver, net)
+}
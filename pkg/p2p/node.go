@@ -0,0 +1,265 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/clock"
+)
+
+// Node accepts and dials EXS peers, keeping those that complete the handshake
+type Node struct {
+	cfg Config
+
+	mu    sync.Mutex
+	peers map[*peer]struct{}
+	lns   []net.Listener
+
+	traffic  bandwidthCounter
+	upload   *RateLimiter
+	download *RateLimiter
+}
+
+// peer is a connected peer
+type peer struct {
+	conn    net.Conn
+	info    *PeerInfo
+	traffic *bandwidthCounter
+	writeMu sync.Mutex
+}
+
+// NewNode creates a node. StartTime defaults to now and Nonce to a random
+// value shared by all of the node's handshakes.
+func NewNode(cfg Config) (*Node, error) {
+	if cfg.Network == nil {
+		return nil, fmt.Errorf("network is required")
+	}
+	if cfg.DisableEncryption && cfg.RequireEncryption {
+		return nil, fmt.Errorf("encryption cannot be both disabled and required")
+	}
+	if cfg.StartTime.IsZero() {
+		cfg.StartTime = time.Now()
+	}
+	if cfg.Nonce == 0 {
+		nonce, err := RandomNonce()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Nonce = nonce
+	}
+	return &Node{
+		cfg:      cfg,
+		peers:    make(map[*peer]struct{}),
+		upload:   NewRateLimiter(cfg.MaxUploadRate),
+		download: NewRateLimiter(cfg.MaxDownloadRate),
+	}, nil
+}
+
+// Bandwidth returns the node's total traffic, including disconnected peers
+func (n *Node) Bandwidth() BandwidthStats {
+	return n.traffic.stats()
+}
+
+// meter wraps conn to count its traffic and apply the rate limits, and to
+// inject the configured faults
+func (n *Node) meter(conn net.Conn) (*meteredConn, *bandwidthCounter) {
+	traffic := &bandwidthCounter{}
+	return &meteredConn{
+		Conn:     n.cfg.Chaos.Conn(conn),
+		peer:     traffic,
+		total:    &n.traffic,
+		download: n.download,
+		upload:   n.upload,
+	}, traffic
+}
+
+// Uptime returns how long the node has been running
+func (n *Node) Uptime() time.Duration {
+	return time.Since(n.cfg.StartTime)
+}
+
+// Listen accepts inbound peers on addr until ctx is cancelled
+func (n *Node) Listen(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return n.Serve(ctx, ln)
+}
+
+// Serve accepts inbound peers on ln until ctx is cancelled
+func (n *Node) Serve(ctx context.Context, ln net.Listener) error {
+	n.mu.Lock()
+	n.lns = append(n.lns, ln)
+	n.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept failed: %w", err)
+		}
+		go n.accept(conn)
+	}
+}
+
+// Connect dials addr and performs the handshake. The encrypted transport is
+// tried first; unless encryption is required, a peer that does not support it
+// is redialled in plaintext.
+func (n *Node) Connect(ctx context.Context, addr string) (*PeerInfo, error) {
+	info, err := n.connect(ctx, addr, !n.cfg.DisableEncryption)
+	if errors.Is(err, errTransportFailed) && !n.cfg.RequireEncryption {
+		info, err = n.connect(ctx, addr, false)
+	}
+	return info, err
+}
+
+// errTransportFailed indicates an outbound encrypted transport negotiation
+// failure, after which Connect may fall back to plaintext
+var errTransportFailed = errors.New("encrypted transport negotiation failed")
+
+func (n *Node) connect(ctx context.Context, addr string, encrypt bool) (*PeerInfo, error) {
+	var d net.Dialer
+	raw, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	conn, traffic := n.meter(raw)
+	if encrypt {
+		ec, err := InitiateTransport(conn, n.cfg.Network.Net, n.cfg.HandshakeTimeout)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w with %s: %v", errTransportFailed, addr, err)
+		}
+		return n.addPeer(ec, traffic, false)
+	}
+	if n.cfg.RequireEncryption {
+		conn.Close()
+		return nil, ErrEncryptionRequired
+	}
+	return n.addPeer(conn, traffic, false)
+}
+
+// Peers returns the connected peers ordered by address
+func (n *Node) Peers() []PeerInfo {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	peers := make([]PeerInfo, 0, len(n.peers))
+	for p := range n.peers {
+		info := *p.info
+		info.Traffic = p.traffic.stats()
+		peers = append(peers, info)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Addr < peers[j].Addr
+	})
+	return peers
+}
+
+// TimeOffset returns the median clock offset of the connected peers, the
+// peer time minus the local time, and how many peers there are
+func (n *Node) TimeOffset() (time.Duration, int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	offsets := make([]time.Duration, 0, len(n.peers))
+	for p := range n.peers {
+		offsets = append(offsets, p.info.TimeOffset)
+	}
+	return clock.MedianOffset(offsets), len(offsets)
+}
+
+// Close disconnects every peer and stops listening
+func (n *Node) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, ln := range n.lns {
+		ln.Close()
+	}
+	for p := range n.peers {
+		p.conn.Close()
+	}
+	return nil
+}
+
+// accept negotiates the transport with an inbound peer and performs the
+// handshake
+func (n *Node) accept(raw net.Conn) {
+	metered, traffic := n.meter(raw)
+	var conn net.Conn = metered
+	if !n.cfg.DisableEncryption {
+		negotiated, err := AcceptTransport(conn, n.cfg.Network.Net, n.cfg.HandshakeTimeout)
+		if err != nil {
+			raw.Close()
+			return
+		}
+		if _, ok := negotiated.(*EncryptedConn); !ok && n.cfg.RequireEncryption {
+			raw.Close()
+			return
+		}
+		conn = negotiated
+	}
+	n.addPeer(conn, traffic, true)
+}
+
+// addPeer performs the handshake and tracks the peer, closing conn on failure
+func (n *Node) addPeer(conn net.Conn, traffic *bandwidthCounter, inbound bool) (*PeerInfo, error) {
+	info, err := Handshake(conn, &n.cfg, inbound)
+	if err != nil {
+		conn.Close()
+		return info, err
+	}
+	p := &peer{conn: conn, info: info, traffic: traffic}
+	n.mu.Lock()
+	n.peers[p] = struct{}{}
+	n.mu.Unlock()
+
+	go n.readLoop(p)
+	return info, nil
+}
+
+// readLoop answers pings until the peer disconnects
+func (n *Node) readLoop(p *peer) {
+	defer func() {
+		n.mu.Lock()
+		delete(n.peers, p)
+		n.mu.Unlock()
+		p.conn.Close()
+	}()
+
+	pver := p.info.ProtocolVersion
+	if pver > wire.ProtocolVersion {
+		pver = wire.ProtocolVersion
+	}
+	for {
+		msg, err := ReadMessage(p.conn, pver, n.cfg.Network.Net)
+		if errors.Is(err, wire.ErrUnknownMessage) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if ping, ok := msg.(*wire.MsgPing); ok {
+			p.writeMu.Lock()
+			err = WriteMessage(p.conn, wire.NewMsgPong(ping.Nonce), pver, n.cfg.Network.Net)
+			p.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
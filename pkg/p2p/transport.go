@@ -0,0 +1,328 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encrypted transport, modelled on BIP-324. The initiator opens with an
+// ephemeral X25519 public key and the responder answers with its own. Both
+// sides derive per-direction keys with HKDF-SHA256 over the shared secret
+// and exchange an encrypted confirmation packet. Each packet is a 3-byte
+// length encrypted with a ChaCha20 stream, followed by the ChaCha20-Poly1305
+// sealed payload. A responder that sees a plaintext version message instead
+// of a key serves the connection unencrypted.
+
+const (
+	// pubKeySize is the size of an X25519 public key
+	pubKeySize = 32
+	// lengthSize is the size of the encrypted packet length
+	lengthSize = 3
+	// maxPacketSize is the largest payload of one encrypted packet
+	maxPacketSize = 1<<24 - 1
+	// maxHandshakePacketSize is the largest payload accepted before the
+	// confirmation packets have authenticated the peer
+	maxHandshakePacketSize = 64
+	// v1PrefixSize is how much of a plaintext version message the responder
+	// inspects to detect an unencrypted peer
+	v1PrefixSize = 16
+)
+
+var (
+	// ErrEncryptionRequired indicates a peer that did not negotiate an
+	// encrypted transport when one is required
+	ErrEncryptionRequired = errors.New("encrypted transport required")
+	// ErrTransportAuth indicates a packet that failed authentication
+	ErrTransportAuth = errors.New("transport authentication failed")
+	// ErrPacketTooLarge indicates a packet longer than the transport allows
+	ErrPacketTooLarge = errors.New("transport packet too large")
+)
+
+// transportConfirm is the payload of the confirmation packet both sides send
+// after deriving keys
+var transportConfirm = []byte("exs-v2-transport")
+
+// EncryptedConn is a connection whose traffic is encrypted and authenticated
+type EncryptedConn struct {
+	net.Conn
+	reader    io.Reader
+	sessionID []byte
+
+	readMu     sync.Mutex
+	recvLength *chacha20.Cipher
+	recvAEAD   cipher.AEAD
+	recvNonce  uint64
+	maxPacket  int
+	pending    []byte
+
+	writeMu    sync.Mutex
+	sendLength *chacha20.Cipher
+	sendAEAD   cipher.AEAD
+	sendNonce  uint64
+}
+
+// SessionID identifies the session; both ends see the same value, so
+// operators can compare it out of band to rule out a man in the middle
+func (c *EncryptedConn) SessionID() []byte {
+	return c.sessionID
+}
+
+// Read decrypts data from the connection
+func (c *EncryptedConn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	for len(c.pending) == 0 {
+		packet, err := c.readPacket()
+		if err != nil {
+			return 0, err
+		}
+		c.pending = packet
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write encrypts p onto the connection
+func (c *EncryptedConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxPacketSize {
+			chunk = chunk[:maxPacketSize]
+		}
+		if err := c.writePacket(chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (c *EncryptedConn) readPacket() ([]byte, error) {
+	var encLength, length [lengthSize]byte
+	if _, err := io.ReadFull(c.reader, encLength[:]); err != nil {
+		return nil, err
+	}
+	c.recvLength.XORKeyStream(length[:], encLength[:])
+	size := int(length[0]) | int(length[1])<<8 | int(length[2])<<16
+	if size > c.maxPacket {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPacketTooLarge, size)
+	}
+
+	sealed := make([]byte, size+c.recvAEAD.Overhead())
+	if _, err := io.ReadFull(c.reader, sealed); err != nil {
+		return nil, err
+	}
+	plain, err := c.recvAEAD.Open(sealed[:0], packetNonce(c.recvNonce), sealed, encLength[:])
+	if err != nil {
+		return nil, ErrTransportAuth
+	}
+	c.recvNonce++
+	return plain, nil
+}
+
+func (c *EncryptedConn) writePacket(p []byte) error {
+	length := [lengthSize]byte{byte(len(p)), byte(len(p) >> 8), byte(len(p) >> 16)}
+	var encLength [lengthSize]byte
+	c.sendLength.XORKeyStream(encLength[:], length[:])
+
+	packet := make([]byte, lengthSize, lengthSize+len(p)+c.sendAEAD.Overhead())
+	copy(packet, encLength[:])
+	packet = c.sendAEAD.Seal(packet, packetNonce(c.sendNonce), p, encLength[:])
+	c.sendNonce++
+
+	_, err := c.Conn.Write(packet)
+	return err
+}
+
+func packetNonce(counter uint64) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], counter)
+	return nonce
+}
+
+// prefixedConn replays bytes already read from a connection
+type prefixedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *prefixedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// InitiateTransport opens an encrypted transport on an outbound connection.
+// The negotiation must finish within timeout; zero uses
+// DefaultHandshakeTimeout.
+func InitiateTransport(conn net.Conn, btcnet wire.BitcoinNet, timeout time.Duration) (*EncryptedConn, error) {
+	conn.SetDeadline(time.Now().Add(transportTimeout(timeout)))
+	defer conn.SetDeadline(time.Time{})
+
+	key, err := transportKey(btcnet)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(key.PublicKey().Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to send transport key: %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	theirs := make([]byte, pubKeySize)
+	if _, err := io.ReadFull(reader, theirs); err != nil {
+		return nil, fmt.Errorf("failed to read transport key: %w", err)
+	}
+	return finishTransport(conn, reader, key, theirs, true, btcnet)
+}
+
+// AcceptTransport negotiates the transport on an inbound connection. It
+// returns an *EncryptedConn for an encrypting peer, or a connection replaying
+// the peer's plaintext version message otherwise. The negotiation must finish
+// within timeout; zero uses DefaultHandshakeTimeout.
+func AcceptTransport(conn net.Conn, btcnet wire.BitcoinNet, timeout time.Duration) (net.Conn, error) {
+	conn.SetDeadline(time.Now().Add(transportTimeout(timeout)))
+	defer conn.SetDeadline(time.Time{})
+
+	reader := bufio.NewReader(conn)
+	prefix, err := reader.Peek(v1PrefixSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from peer: %w", err)
+	}
+	if bytes.Equal(prefix, v1Prefix(btcnet)) {
+		return &prefixedConn{Conn: conn, reader: reader}, nil
+	}
+
+	theirs := make([]byte, pubKeySize)
+	if _, err := io.ReadFull(reader, theirs); err != nil {
+		return nil, fmt.Errorf("failed to read transport key: %w", err)
+	}
+	key, err := transportKey(btcnet)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(key.PublicKey().Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to send transport key: %w", err)
+	}
+	return finishTransport(conn, reader, key, theirs, false, btcnet)
+}
+
+// transportTimeout returns the negotiation timeout
+func transportTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultHandshakeTimeout
+	}
+	return timeout
+}
+
+// finishTransport derives the session keys and exchanges confirmations
+func finishTransport(conn net.Conn, reader io.Reader, key *ecdh.PrivateKey, theirs []byte, initiator bool, btcnet wire.BitcoinNet) (*EncryptedConn, error) {
+	peerKey, err := ecdh.X25519().NewPublicKey(theirs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport key: %w", err)
+	}
+	secret, err := key.ECDH(peerKey)
+	if err != nil {
+		return nil, fmt.Errorf("key exchange failed: %w", err)
+	}
+
+	// Bind the secret to both public keys in initiator, responder order
+	ours := key.PublicKey().Bytes()
+	ikm := append([]byte(nil), secret...)
+	if initiator {
+		ikm = append(append(ikm, ours...), theirs...)
+	} else {
+		ikm = append(append(ikm, theirs...), ours...)
+	}
+	var magic [4]byte
+	binary.LittleEndian.PutUint32(magic[:], uint32(btcnet))
+	salt := append([]byte("exs_v2_shared_secret"), magic[:]...)
+	derive := func(label string, size int) ([]byte, error) {
+		out := make([]byte, size)
+		_, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte(label)), out)
+		return out, err
+	}
+
+	keys := make(map[string][]byte)
+	for _, label := range []string{"initiator_L", "initiator_P", "responder_L", "responder_P", "session_id"} {
+		if keys[label], err = derive(label, 32); err != nil {
+			return nil, err
+		}
+	}
+	sendSide, recvSide := "initiator", "responder"
+	if !initiator {
+		sendSide, recvSide = recvSide, sendSide
+	}
+
+	// Until the confirmation authenticates the peer, only accept packets
+	// the size of a confirmation
+	c := &EncryptedConn{Conn: conn, reader: reader, sessionID: keys["session_id"], maxPacket: maxHandshakePacketSize}
+	if c.sendLength, err = chacha20.NewUnauthenticatedCipher(keys[sendSide+"_L"], make([]byte, chacha20.NonceSize)); err != nil {
+		return nil, err
+	}
+	if c.recvLength, err = chacha20.NewUnauthenticatedCipher(keys[recvSide+"_L"], make([]byte, chacha20.NonceSize)); err != nil {
+		return nil, err
+	}
+	if c.sendAEAD, err = chacha20poly1305.New(keys[sendSide+"_P"]); err != nil {
+		return nil, err
+	}
+	if c.recvAEAD, err = chacha20poly1305.New(keys[recvSide+"_P"]); err != nil {
+		return nil, err
+	}
+
+	if err := c.writePacket(transportConfirm); err != nil {
+		return nil, fmt.Errorf("failed to send transport confirmation: %w", err)
+	}
+	confirm, err := c.readPacket()
+	if err != nil {
+		return nil, fmt.Errorf("transport confirmation failed: %w", err)
+	}
+	if !bytes.Equal(confirm, transportConfirm) {
+		return nil, ErrTransportAuth
+	}
+	c.maxPacket = maxPacketSize
+	return c, nil
+}
+
+// transportKey generates an ephemeral key whose public key cannot be
+// mistaken for the start of a plaintext version message
+func transportKey(btcnet wire.BitcoinNet) (*ecdh.PrivateKey, error) {
+	prefix := v1Prefix(btcnet)
+	for {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate transport key: %w", err)
+		}
+		if !bytes.HasPrefix(key.PublicKey().Bytes(), prefix) {
+			return key, nil
+		}
+	}
+}
+
+// v1Prefix is the start of a plaintext version message: the network magic
+// followed by the padded "version" command
+func v1Prefix(btcnet wire.BitcoinNet) []byte {
+	prefix := make([]byte, v1PrefixSize)
+	binary.LittleEndian.PutUint32(prefix, uint32(btcnet))
+	copy(prefix[4:], wire.CmdVersion)
+	return prefix
+}
@@ -0,0 +1,246 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chaos"
+)
+
+// transportPair negotiates the encrypted transport over a TCP loopback pair
+func transportPair(t *testing.T) (*EncryptedConn, *EncryptedConn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	btcnet := chaincfg.RegressionNetParams.Net
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			accepted <- result{nil, err}
+			return
+		}
+		negotiated, err := AcceptTransport(conn, btcnet, 0)
+		accepted <- result{negotiated, err}
+	}()
+
+	raw, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { raw.Close() })
+	out, err := InitiateTransport(raw, btcnet, 0)
+	if err != nil {
+		t.Fatalf("InitiateTransport() error = %v", err)
+	}
+	r := <-accepted
+	if r.err != nil {
+		t.Fatalf("AcceptTransport() error = %v", r.err)
+	}
+	t.Cleanup(func() { r.conn.Close() })
+	in, ok := r.conn.(*EncryptedConn)
+	if !ok {
+		t.Fatalf("Expected *EncryptedConn, got %T", r.conn)
+	}
+	return out, in, raw
+}
+
+func TestEncryptedTransport(t *testing.T) {
+	out, in, _ := transportPair(t)
+	if !bytes.Equal(out.SessionID(), in.SessionID()) {
+		t.Error("Expected matching session IDs")
+	}
+
+	msg := bytes.Repeat([]byte("exs block relay "), 1000)
+	go out.Write(msg)
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(in, got); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Error("Decrypted data does not match")
+	}
+
+	go in.Write([]byte("pong"))
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(out, reply); err != nil || string(reply) != "pong" {
+		t.Errorf("Read() = %q, %v", reply, err)
+	}
+}
+
+// tamperConn flips the last bit of everything written
+type tamperConn struct {
+	net.Conn
+}
+
+func (c tamperConn) Write(p []byte) (int, error) {
+	tampered := append([]byte(nil), p...)
+	tampered[len(tampered)-1] ^= 1
+	return c.Conn.Write(tampered)
+}
+
+func TestEncryptedTransportTamper(t *testing.T) {
+	out, in, raw := transportPair(t)
+	out.Conn = tamperConn{raw}
+
+	go out.Write([]byte("inv"))
+	if _, err := in.Read(make([]byte, 16)); !errors.Is(err, ErrTransportAuth) {
+		t.Errorf("Expected ErrTransportAuth, got %v", err)
+	}
+}
+
+func TestEncryptedTransportHandshakePacketLimit(t *testing.T) {
+	out, in, _ := transportPair(t)
+	in.maxPacket = maxHandshakePacketSize
+
+	go out.Write(make([]byte, maxHandshakePacketSize+1))
+	if _, err := in.Read(make([]byte, 16)); !errors.Is(err, ErrPacketTooLarge) {
+		t.Errorf("Expected ErrPacketTooLarge, got %v", err)
+	}
+}
+
+func TestAcceptTransportTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// The client never sends anything
+	start := time.Now()
+	if _, err := AcceptTransport(server, chaincfg.RegressionNetParams.Net, 50*time.Millisecond); err == nil {
+		t.Fatal("Expected a silent peer to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("AcceptTransport() took %v", elapsed)
+	}
+}
+
+func TestAcceptTransportPlaintext(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	btcnet := chaincfg.RegressionNetParams.Net
+	go func() {
+		me := wire.NewNetAddressIPPort(net.IPv4zero, 0, 0)
+		WriteMessage(client, wire.NewMsgVersion(me, me, 1, 0), wire.ProtocolVersion, btcnet)
+	}()
+
+	conn, err := AcceptTransport(server, btcnet, 0)
+	if err != nil {
+		t.Fatalf("AcceptTransport() error = %v", err)
+	}
+	if _, ok := conn.(*EncryptedConn); ok {
+		t.Fatal("Expected plaintext connection")
+	}
+	msg, err := ReadMessage(conn, wire.ProtocolVersion, btcnet)
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if _, ok := msg.(*wire.MsgVersion); !ok {
+		t.Errorf("Expected version replayed, got %s", msg.Command())
+	}
+}
+
+// nodePair starts a listening node and connects a client node to it
+func nodePair(t *testing.T, serverCfg, clientCfg Config) (*PeerInfo, error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	server, err := NewNode(serverCfg)
+	if err != nil {
+		t.Fatalf("NewNode() error = %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	go server.Serve(ctx, ln)
+	t.Cleanup(func() { server.Close() })
+
+	client, err := NewNode(clientCfg)
+	if err != nil {
+		t.Fatalf("NewNode() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client.Connect(ctx, ln.Addr().String())
+}
+
+func TestNodeEncryptedPeers(t *testing.T) {
+	info, err := nodePair(t, testConfig(), testConfig())
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if !info.Encrypted || len(info.SessionID) != 32 {
+		t.Errorf("Expected encrypted peer, got %+v", info)
+	}
+}
+
+func TestNodePlaintextFallback(t *testing.T) {
+	server := testConfig()
+	server.DisableEncryption = true
+	server.HandshakeTimeout = 2 * time.Second
+
+	info, err := nodePair(t, server, testConfig())
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if info.Encrypted {
+		t.Error("Expected plaintext fallback")
+	}
+
+	client := testConfig()
+	client.RequireEncryption = true
+	if _, err := nodePair(t, server, client); err == nil {
+		t.Error("Expected error when encryption is required")
+	}
+}
+
+func TestNodeRequireEncryptionInbound(t *testing.T) {
+	server := testConfig()
+	server.RequireEncryption = true
+	client := testConfig()
+	client.DisableEncryption = true
+
+	if _, err := nodePair(t, server, client); err == nil {
+		t.Error("Expected plaintext peer to be rejected")
+	}
+}
+
+func TestNodeChaos(t *testing.T) {
+	// Delays slow the handshake down but do not break it
+	server := testConfig()
+	server.Chaos = chaos.New(chaos.Config{Delay: 5 * time.Millisecond, DelayRate: 1})
+	if _, err := nodePair(t, server, testConfig()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if server.Chaos.Stats().Delayed == 0 {
+		t.Error("Expected delays to be injected")
+	}
+
+	// A peer that sends malformed frames is refused
+	server = testConfig()
+	server.HandshakeTimeout = 2 * time.Second
+	server.Chaos = chaos.New(chaos.Config{CorruptRate: 1})
+	client := testConfig()
+	client.HandshakeTimeout = 2 * time.Second
+	if _, err := nodePair(t, server, client); err == nil {
+		t.Error("Expected the handshake to fail on corrupted frames")
+	}
+}
@@ -0,0 +1,168 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer answers RESP commands with reply, recording them
+type fakeServer struct {
+	addr  string
+	reply func(args []string) string
+
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func newFakeServer(t *testing.T, reply func(args []string) string) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeServer{addr: ln.Addr().String(), reply: reply}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range reply.([]any) {
+			args = append(args, arg.(string))
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		s.mu.Unlock()
+		c.Write([]byte(s.reply(args)))
+	}
+}
+
+func (s *fakeServer) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, cmd := range s.commands {
+		names = append(names, cmd[0])
+	}
+	return names
+}
+
+func TestOpen(t *testing.T) {
+	c, err := Open("redis://:secret@cache/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.addr != "cache:6379" || c.password != "secret" || c.db != 3 || c.tls != nil {
+		t.Errorf("Open() = %+v", c)
+	}
+	if c, _ := Open("rediss://default:pw@cache:6380"); c.tls == nil || c.username != "default" || c.addr != "cache:6380" {
+		t.Errorf("Open(rediss) = %+v", c)
+	}
+	for _, url := range []string{"http://cache", "redis://cache/x"} {
+		if _, err := Open(url); err == nil {
+			t.Errorf("Open(%q) succeeded", url)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH", "SELECT":
+			return "+OK\r\n"
+		case "GET":
+			return "$5\r\nhello\r\n"
+		case "MGET":
+			return "*3\r\n$1\r\na\r\n$-1\r\n:7\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	c, err := Open("redis://:secret@" + s.addr + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	if reply, err := c.Do(ctx, "GET", "k"); err != nil || reply != "hello" {
+		t.Errorf("GET = %v, %v", reply, err)
+	}
+	reply, err := c.Do(ctx, "MGET", "a", "b", "c")
+	if items, ok := reply.([]any); err != nil || !ok || len(items) != 3 || items[0] != "a" || items[1] != nil || items[2] != int64(7) {
+		t.Errorf("MGET = %#v, %v", reply, err)
+	}
+	var replyErr Error
+	if _, err := c.Do(ctx, "FLUSHALL"); !errors.As(err, &replyErr) {
+		t.Errorf("FLUSHALL = %v, want an error reply", err)
+	}
+	// The connection survives an error reply and is set up only once
+	if _, err := c.Do(ctx, "GET", "k"); err != nil {
+		t.Fatal(err)
+	}
+	want := "AUTH,SELECT,GET,MGET,FLUSHALL,GET"
+	if got := strings.Join(s.names(), ","); got != want {
+		t.Errorf("Commands = %s, want %s", got, want)
+	}
+
+	c.Close()
+	if _, err := c.Do(ctx, "GET", "k"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Do after Close = %v", err)
+	}
+}
+
+func TestScriptLoadsOnNoScript(t *testing.T) {
+	loaded := false
+	s := newFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] == "EVALSHA" && !loaded:
+			return "-NOSCRIPT No matching script\r\n"
+		case args[0] == "EVAL":
+			loaded = true
+			fallthrough
+		case args[0] == "EVALSHA":
+			return ":" + strconv.Itoa(len(args)) + "\r\n"
+		}
+		return "-ERR unexpected\r\n"
+	})
+	c, _ := Open("redis://" + s.addr)
+	defer c.Close()
+	script := NewScript("return 1")
+
+	for i := range 2 {
+		reply, err := script.Run(context.Background(), c, []string{"key"}, "arg")
+		if err != nil || reply != int64(5) {
+			t.Errorf("Run %d = %v, %v", i+1, reply, err)
+		}
+	}
+	if got := strings.Join(s.names(), ","); got != "EVALSHA,EVAL,EVALSHA" {
+		t.Errorf("Commands = %s", got)
+	}
+	s.mu.Lock()
+	eval := s.commands[1]
+	s.mu.Unlock()
+	if eval[1] != "return 1" || eval[2] != "1" || eval[3] != "key" || eval[4] != "arg" {
+		t.Errorf("EVAL = %q", eval)
+	}
+}
@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+)
+
+var (
+	// ErrBlockNotFound indicates a block hash or height the chain does not have
+	ErrBlockNotFound = chain.ErrBlockNotFound
+	// ErrTxNotFound indicates a transaction neither confirmed nor in the mempool
+	ErrTxNotFound = chain.ErrTxNotFound
+	// ErrTxRejected indicates a transaction that failed validation
+	ErrTxRejected = errors.New("transaction rejected")
+	// ErrTxInChain indicates a transaction that is already confirmed
+	ErrTxInChain = errors.New("transaction already in block chain")
+	// ErrNotRegtest indicates block generation on a network other than regtest
+	ErrNotRegtest = errors.New("block generation is only available on regtest")
+	// ErrNoWallet indicates a wallet method called without a wallet loaded
+	ErrNoWallet = errors.New("no wallet is loaded")
+)
+
+// Chain is the chain state served by the RPC server
+type Chain interface {
+	// Params returns the network the chain belongs to
+	Params() *chaincfg.Params
+	// Tip returns the best block
+	Tip() chain.Tip
+	// BlockHash returns the hash of the block at height, or ErrBlockNotFound
+	BlockHash(height int32) (chainhash.Hash, error)
+	// Block returns a block and its height, or ErrBlockNotFound
+	Block(hash chainhash.Hash) (*wire.MsgBlock, int32, error)
+	// Transaction returns a transaction and the hash of the block that
+	// confirmed it, nil while it is in the mempool, or ErrTxNotFound
+	Transaction(txid chainhash.Hash) (*wire.MsgTx, *chainhash.Hash, error)
+	// SendTransaction validates tx and relays it or adds it to the mempool
+	SendTransaction(ctx context.Context, tx *wire.MsgTx) error
+	// Mempool returns the unconfirmed transactions in arrival order
+	Mempool() []MempoolEntry
+	// Coins returns the confirmed unspent outputs paying pkScript,
+	// including those spent by mempool transactions
+	Coins(pkScript []byte) ([]chain.Coin, error)
+	// Generate mines n blocks paying their coinbase to pkScript, returning
+	// their hashes, or ErrNotRegtest
+	Generate(n int, pkScript []byte) ([]chainhash.Hash, error)
+	// BlockTemplate assembles an unsolved block on the tip from the
+	// mempool, paying the subsidy and fees to pkScript
+	BlockTemplate(pkScript []byte) (*BlockTemplate, error)
+	// SubmitBlock validates a solved block and stores it, returning
+	// whether it joined the main chain
+	SubmitBlock(block *wire.MsgBlock) (bool, error)
+}
+
+// BlockTemplate is an unsolved block for external miners
+type BlockTemplate struct {
+	Block  *wire.MsgBlock
+	Height int32
+	// Fees holds the fee of each transaction after the coinbase, in block
+	// order
+	Fees []int64
+}
+
+// MempoolEntry is an unconfirmed transaction
+type MempoolEntry struct {
+	Tx *wire.MsgTx
+	// Fee is in satoshis
+	Fee int64
+	// Time is when the transaction entered the mempool
+	Time time.Time
+}
+
+// Wallet is the node wallet served by the wallet methods
+type Wallet interface {
+	// NewAddress returns an unused receiving address of addressType,
+	// "bech32" or "bech32m", or the wallet's default type when empty
+	NewAddress(ctx context.Context, addressType string) (string, error)
+	// Balance returns the spendable balance confirmed at least minConf times
+	Balance(ctx context.Context, minConf int) (btcutil.Amount, error)
+}
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+)
+
+// TrackFees feeds est the blocks and mempool of c every interval until ctx
+// is done
+func TrackFees(ctx context.Context, c Chain, est *fees.Estimator, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ObserveFees(c, est); err != nil {
+			slog.Warn("Fee estimator update failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ObserveFees feeds est the blocks of c it has not seen, starting at the
+// tip the first time, and then the mempool
+func ObserveFees(c Chain, est *fees.Estimator) error {
+	tip := c.Tip().Height
+	from := est.Height() + 1
+	if est.Height() == 0 || tip < from-1 || tip-from > fees.MaxTarget {
+		from = tip
+	}
+	for height := from; height <= tip; height++ {
+		hash, err := c.BlockHash(height)
+		if err != nil {
+			return err
+		}
+		block, _, err := c.Block(hash)
+		if err != nil {
+			return err
+		}
+		txids := make([]chainhash.Hash, len(block.Transactions))
+		for i, tx := range block.Transactions {
+			txids[i] = tx.TxHash()
+		}
+		est.ObserveBlock(height, txids)
+	}
+
+	mempool := c.Mempool()
+	entries := make([]fees.Entry, len(mempool))
+	for i, entry := range mempool {
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(entry.Tx))
+		entries[i] = fees.Entry{
+			TxID:  entry.Tx.TxHash(),
+			Fee:   entry.Fee,
+			VSize: (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor,
+		}
+	}
+	est.ObserveMempool(tip, entries)
+	return nil
+}
+
+func (s *Server) estimateSmartFee(ctx context.Context, p params) (interface{}, error) {
+	var target int64
+	if err := p.require(0, "conf_target", &target); err != nil {
+		return nil, err
+	}
+	if target < 1 || target > 1008 {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Invalid conf_target, must be between 1 and 1008")
+	}
+	modeName := "economical"
+	if _, err := p.get(1, &modeName); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(modeName, "unset") {
+		modeName = ""
+	}
+	mode, err := fees.ParseMode(modeName)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Invalid estimate_mode parameter, must be one of: \"unset\", \"economical\", \"conservative\"")
+	}
+
+	result := &btcjson.EstimateSmartFeeResult{Blocks: target}
+	if s.cfg.Fees == nil {
+		result.Errors = []string{"Fee estimation disabled"}
+		return result, nil
+	}
+	estimate, err := s.cfg.Fees.Estimate(ctx, int(target), mode)
+	if err != nil {
+		result.Errors = []string{"Insufficient data or no feerate found"}
+		return result, nil
+	}
+	// bitcoind reports BTC/kvB
+	feeRate := estimate.Rate * 1000 / btcutil.SatoshiPerBitcoin
+	result.FeeRate = &feeRate
+	result.Blocks = int64(estimate.Target)
+	return result, nil
+}
@@ -0,0 +1,309 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+)
+
+// mempoolTx is an unconfirmed transaction, its fee and when it arrived
+type mempoolTx struct {
+	tx    *wire.MsgTx
+	fee   int64
+	added time.Time
+}
+
+// LocalChain serves a stored chain with a mempool. Transactions are
+// validated against the chain's UTXO set, including their scripts, and on
+// regtest blocks are mined from the mempool.
+type LocalChain struct {
+	chain *chain.Chain
+
+	mu      sync.Mutex
+	mempool map[chainhash.Hash]*mempoolTx
+	// pending lists mempool transactions in arrival order, so parents are
+	// mined before their children
+	pending []chainhash.Hash
+	// spent maps outpoints spent by mempool transactions to the spender
+	spent map[wire.OutPoint]chainhash.Hash
+}
+
+// NewLocalChain serves c with an empty mempool
+func NewLocalChain(c *chain.Chain) *LocalChain {
+	return &LocalChain{
+		chain:   c,
+		mempool: make(map[chainhash.Hash]*mempoolTx),
+		spent:   make(map[wire.OutPoint]chainhash.Hash),
+	}
+}
+
+// Params returns the chain's network
+func (c *LocalChain) Params() *chaincfg.Params {
+	return c.chain.Params()
+}
+
+// Tip returns the best block
+func (c *LocalChain) Tip() chain.Tip {
+	return c.chain.Tip()
+}
+
+// BlockHash returns the hash of the block at height
+func (c *LocalChain) BlockHash(height int32) (chainhash.Hash, error) {
+	return c.chain.BlockHash(height)
+}
+
+// Block returns the block with hash and its height
+func (c *LocalChain) Block(hash chainhash.Hash) (*wire.MsgBlock, int32, error) {
+	return c.chain.Block(hash)
+}
+
+// Transaction returns a mempool transaction, or a confirmed one from the
+// chain's transaction index
+func (c *LocalChain) Transaction(txid chainhash.Hash) (*wire.MsgTx, *chainhash.Hash, error) {
+	c.mu.Lock()
+	entry, ok := c.mempool[txid]
+	c.mu.Unlock()
+	if ok {
+		return entry.tx, nil, nil
+	}
+	tx, hash, err := c.chain.Transaction(txid)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, &hash, nil
+}
+
+// Unspent returns the confirmed unspent outputs whose script matches.
+// Outputs spent by mempool transactions are left out.
+func (c *LocalChain) Unspent(match func(pkScript []byte) bool) ([]chain.Coin, error) {
+	coins, err := c.chain.Unspent(match)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	unspent := coins[:0]
+	for _, coin := range coins {
+		if _, spent := c.spent[coin.OutPoint]; !spent {
+			unspent = append(unspent, coin)
+		}
+	}
+	return unspent, nil
+}
+
+// Coins returns the confirmed unspent outputs paying pkScript, from the
+// address index when the chain has one
+func (c *LocalChain) Coins(pkScript []byte) ([]chain.Coin, error) {
+	return c.chain.Coins(pkScript)
+}
+
+// SendTransaction validates tx against the UTXO set and adds it to the
+// mempool. Inputs may spend confirmed outputs or outputs of earlier mempool
+// transactions. A transaction already in the mempool is accepted again.
+func (c *LocalChain) SendTransaction(ctx context.Context, tx *wire.MsgTx) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	txid := tx.TxHash()
+	if _, ok := c.mempool[txid]; ok {
+		return nil
+	}
+	if c.chain.Confirmed(txid) {
+		return fmt.Errorf("%w: %s", ErrTxInChain, txid)
+	}
+	for index := range tx.TxOut {
+		if coin, _ := c.chain.FetchCoin(wire.OutPoint{Hash: txid, Index: uint32(index)}); coin != nil {
+			return fmt.Errorf("%w: %s", ErrTxInChain, txid)
+		}
+	}
+
+	fee, err := c.checkTransaction(tx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTxRejected, err)
+	}
+	c.add(tx, fee, time.Now())
+	return nil
+}
+
+// Mempool returns the mempool transactions in arrival order
+func (c *LocalChain) Mempool() []MempoolEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]MempoolEntry, 0, len(c.pending))
+	for _, txid := range c.pending {
+		entry := c.mempool[txid]
+		entries = append(entries, MempoolEntry{Tx: entry.tx, Fee: entry.fee, Time: entry.added})
+	}
+	return entries
+}
+
+// add puts a validated transaction in the mempool
+func (c *LocalChain) add(tx *wire.MsgTx, fee int64, added time.Time) {
+	txid := tx.TxHash()
+	c.mempool[txid] = &mempoolTx{tx: tx, fee: fee, added: added}
+	c.pending = append(c.pending, txid)
+	for _, in := range tx.TxIn {
+		c.spent[in.PreviousOutPoint] = txid
+	}
+}
+
+// checkTransaction validates tx for the next block and returns its fee
+func (c *LocalChain) checkTransaction(tx *wire.MsgTx) (int64, error) {
+	if blockchain.IsCoinBaseTx(tx) {
+		return 0, fmt.Errorf("coinbase")
+	}
+	utx := btcutil.NewTx(tx)
+	if err := blockchain.CheckTransactionSanity(utx); err != nil {
+		return 0, err
+	}
+	tip := c.chain.Tip()
+	nextHeight := tip.Height + 1
+	if !blockchain.IsFinalizedTransaction(utx, nextHeight, tip.MedianTime) {
+		return 0, fmt.Errorf("non-final")
+	}
+
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	var in int64
+	for _, txIn := range tx.TxIn {
+		if spender, ok := c.spent[txIn.PreviousOutPoint]; ok {
+			return 0, fmt.Errorf("txn-mempool-conflict with %s", spender)
+		}
+		prevOut, err := c.prevOut(txIn.PreviousOutPoint, nextHeight)
+		if err != nil {
+			return 0, err
+		}
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, prevOut)
+		in += prevOut.Value
+	}
+	var out int64
+	for _, txOut := range tx.TxOut {
+		out += txOut.Value
+	}
+	if in < out {
+		return 0, fmt.Errorf("bad-txns-in-belowout, value in (%s) < value out (%s)",
+			btcutil.Amount(in), btcutil.Amount(out))
+	}
+	if err := chain.VerifyScripts(tx, fetcher); err != nil {
+		return 0, fmt.Errorf("mandatory-script-verify-flag-failed (%v)", err)
+	}
+	return in - out, nil
+}
+
+// prevOut returns the output spent by op from the UTXO set or the mempool
+func (c *LocalChain) prevOut(op wire.OutPoint, nextHeight int32) (*wire.TxOut, error) {
+	coin, err := c.chain.FetchCoin(op)
+	if err != nil {
+		return nil, err
+	}
+	if coin != nil {
+		if coin.Coinbase && nextHeight-coin.Height < int32(c.chain.Params().CoinbaseMaturity) {
+			return nil, fmt.Errorf("bad-txns-premature-spend-of-coinbase, tried to spend coinbase at depth %d",
+				nextHeight-coin.Height)
+		}
+		return coin.TxOut, nil
+	}
+	if parent, ok := c.mempool[op.Hash]; ok && int(op.Index) < len(parent.tx.TxOut) {
+		return parent.tx.TxOut[op.Index], nil
+	}
+	return nil, fmt.Errorf("bad-txns-inputs-missingorspent")
+}
+
+// Generate mines n blocks on regtest, each including the whole mempool and
+// paying the subsidy and fees to pkScript
+func (c *LocalChain) Generate(n int, pkScript []byte) ([]chainhash.Hash, error) {
+	if c.chain.Params().Net != chaincfg.RegressionNetParams.Net {
+		return nil, ErrNotRegtest
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hashes := make([]chainhash.Hash, 0, n)
+	for i := 0; i < n; i++ {
+		txs, fees := c.candidates()
+		var total int64
+		for _, fee := range fees {
+			total += fee
+		}
+		block, err := c.chain.NewBlock(pkScript, txs, total)
+		if err != nil {
+			return hashes, err
+		}
+		if _, err := c.chain.ProcessBlock(block); err != nil {
+			return hashes, err
+		}
+		hashes = append(hashes, block.BlockHash())
+		c.revalidate()
+	}
+	return hashes, nil
+}
+
+// BlockTemplate assembles an unsolved block on the tip from the whole
+// mempool, paying the subsidy and fees to pkScript. Unlike Generate it
+// works on every network, leaving the proof of work to the caller.
+func (c *LocalChain) BlockTemplate(pkScript []byte) (*BlockTemplate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	txs, fees := c.candidates()
+	var total int64
+	for _, fee := range fees {
+		total += fee
+	}
+	block, height, err := c.chain.BlockTemplate(pkScript, txs, total)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockTemplate{Block: block, Height: height, Fees: fees}, nil
+}
+
+// SubmitBlock validates a solved block and stores it, dropping the
+// transactions it confirms from the mempool when it joins the main chain
+func (c *LocalChain) SubmitBlock(block *wire.MsgBlock) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	main, err := c.chain.ProcessBlock(block)
+	if err != nil {
+		return false, err
+	}
+	if main {
+		c.revalidate()
+	}
+	return main, nil
+}
+
+// candidates returns the mempool transactions in arrival order and their
+// fees; c.mu is held
+func (c *LocalChain) candidates() ([]*wire.MsgTx, []int64) {
+	txs := make([]*wire.MsgTx, 0, len(c.pending))
+	fees := make([]int64, 0, len(c.pending))
+	for _, txid := range c.pending {
+		entry := c.mempool[txid]
+		txs = append(txs, entry.tx)
+		fees = append(fees, entry.fee)
+	}
+	return txs, fees
+}
+
+// revalidate checks the mempool against the new tip, dropping confirmed
+// and conflicting transactions
+func (c *LocalChain) revalidate() {
+	pending, mempool := c.pending, c.mempool
+	c.pending = nil
+	c.mempool = make(map[chainhash.Hash]*mempoolTx)
+	c.spent = make(map[wire.OutPoint]chainhash.Hash)
+	for _, txid := range pending {
+		entry := mempool[txid]
+		if fee, err := c.checkTransaction(entry.tx); err == nil {
+			c.add(entry.tx, fee, entry.added)
+		}
+	}
+}
@@ -0,0 +1,582 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// method is an RPC method and the names of its parameters, in order
+type method struct {
+	params []string
+	call   func(s *Server, ctx context.Context, p params) (interface{}, error)
+}
+
+var methods = map[string]method{
+	"getblockchaininfo":  {nil, (*Server).getBlockchainInfo},
+	"getblockcount":      {nil, (*Server).getBlockCount},
+	"getbestblockhash":   {nil, (*Server).getBestBlockHash},
+	"getblockhash":       {[]string{"height"}, (*Server).getBlockHash},
+	"getblock":           {[]string{"blockhash", "verbosity"}, (*Server).getBlock},
+	"getrawtransaction":  {[]string{"txid", "verbose", "blockhash"}, (*Server).getRawTransaction},
+	"sendrawtransaction": {[]string{"hexstring", "maxfeerate"}, (*Server).sendRawTransaction},
+	"getrawmempool":      {[]string{"verbose"}, (*Server).getRawMempool},
+	"scantxoutset":       {[]string{"action", "scanobjects"}, (*Server).scanTxOutSet},
+	"getbalance":         {[]string{"dummy", "minconf", "include_watchonly", "avoid_reuse"}, (*Server).getBalance},
+	"getnewaddress":      {[]string{"label", "address_type"}, (*Server).getNewAddress},
+	"generatetoaddress":  {[]string{"nblocks", "address", "maxtries"}, (*Server).generateToAddress},
+	"getblocktemplate":   {[]string{"template_request"}, (*Server).getBlockTemplate},
+	"submitblock":        {[]string{"hexdata", "dummy"}, (*Server).submitBlock},
+	"getmininginfo":      {nil, (*Server).getMiningInfo},
+	"estimatesmartfee":   {[]string{"conf_target", "estimate_mode"}, (*Server).estimateSmartFee},
+}
+
+// chainNames maps networks to the chain names bitcoind reports
+var chainNames = map[wire.BitcoinNet]string{
+	chaincfg.MainNetParams.Net:       "main",
+	chaincfg.TestNet3Params.Net:      "test",
+	chaincfg.RegressionNetParams.Net: "regtest",
+	chaincfg.SigNetParams.Net:        "signet",
+}
+
+// difficulty1Bits is the target of difficulty 1, which bitcoind uses on
+// every network
+const difficulty1Bits = 0x1d00ffff
+
+func (s *Server) getBlockchainInfo(ctx context.Context, p params) (interface{}, error) {
+	tip := s.cfg.Chain.Tip()
+	return &btcjson.GetBlockChainInfoResult{
+		Chain:                chainNames[s.cfg.Chain.Params().Net],
+		Blocks:               tip.Height,
+		Headers:              tip.Height,
+		BestBlockHash:        tip.Hash.String(),
+		Difficulty:           difficulty(tip.Header.Bits),
+		MedianTime:           tip.MedianTime.Unix(),
+		VerificationProgress: 1,
+		ChainWork:            fmt.Sprintf("%064x", tip.ChainWork),
+	}, nil
+}
+
+func (s *Server) getBlockCount(ctx context.Context, p params) (interface{}, error) {
+	return s.cfg.Chain.Tip().Height, nil
+}
+
+func (s *Server) getBestBlockHash(ctx context.Context, p params) (interface{}, error) {
+	return s.cfg.Chain.Tip().Hash.String(), nil
+}
+
+func (s *Server) getBlockHash(ctx context.Context, p params) (interface{}, error) {
+	var height int32
+	if err := p.require(0, "height", &height); err != nil {
+		return nil, err
+	}
+	hash, err := s.cfg.Chain.BlockHash(height)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Block height out of range")
+	}
+	return hash.String(), nil
+}
+
+func (s *Server) getBlock(ctx context.Context, p params) (interface{}, error) {
+	hash, err := hashParam(p, 0, "blockhash")
+	if err != nil {
+		return nil, err
+	}
+	verbosity, err := verbosityParam(p, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	block, height, err := s.cfg.Chain.Block(hash)
+	if err != nil {
+		return nil, err
+	}
+	if verbosity == 0 {
+		var buf bytes.Buffer
+		if err := block.Serialize(&buf); err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(buf.Bytes()), nil
+	}
+
+	tip := s.cfg.Chain.Tip()
+	header := block.Header
+	result := btcjson.GetBlockVerboseTxResult{
+		Hash:          hash.String(),
+		Confirmations: int64(tip.Height - height + 1),
+		StrippedSize:  int32(block.SerializeSizeStripped()),
+		Size:          int32(block.SerializeSize()),
+		Weight:        int32(blockchain.GetBlockWeight(btcutil.NewBlock(block))),
+		Height:        int64(height),
+		Version:       header.Version,
+		VersionHex:    fmt.Sprintf("%08x", uint32(header.Version)),
+		MerkleRoot:    header.MerkleRoot.String(),
+		Time:          header.Timestamp.Unix(),
+		Nonce:         header.Nonce,
+		Bits:          fmt.Sprintf("%08x", header.Bits),
+		Difficulty:    difficulty(header.Bits),
+	}
+	if height > 0 {
+		result.PreviousHash = header.PrevBlock.String()
+	}
+	if height < tip.Height {
+		next, err := s.cfg.Chain.BlockHash(height + 1)
+		if err != nil {
+			return nil, err
+		}
+		result.NextHash = next.String()
+	}
+
+	if verbosity == 1 {
+		txids := make([]string, len(block.Transactions))
+		for i, tx := range block.Transactions {
+			txids[i] = tx.TxHash().String()
+		}
+		return &btcjson.GetBlockVerboseResult{
+			Hash: result.Hash, Confirmations: result.Confirmations, StrippedSize: result.StrippedSize,
+			Size: result.Size, Weight: result.Weight, Height: result.Height, Version: result.Version,
+			VersionHex: result.VersionHex, MerkleRoot: result.MerkleRoot, Tx: txids, Time: result.Time,
+			Nonce: result.Nonce, Bits: result.Bits, Difficulty: result.Difficulty,
+			PreviousHash: result.PreviousHash, NextHash: result.NextHash,
+		}, nil
+	}
+	for _, tx := range block.Transactions {
+		txResult, err := s.txResult(tx, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		result.Tx = append(result.Tx, *txResult)
+	}
+	return &result, nil
+}
+
+func (s *Server) getRawTransaction(ctx context.Context, p params) (interface{}, error) {
+	txid, err := hashParam(p, 0, "txid")
+	if err != nil {
+		return nil, err
+	}
+	verbosity, err := verbosityParam(p, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *wire.MsgTx
+	var blockHash *chainhash.Hash
+	if ok, err := p.get(2, new(string)); err != nil {
+		return nil, err
+	} else if ok {
+		hash, err := hashParam(p, 2, "blockhash")
+		if err != nil {
+			return nil, err
+		}
+		block, _, err := s.cfg.Chain.Block(hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range block.Transactions {
+			if candidate.TxHash() == txid {
+				tx, blockHash = candidate, &hash
+				break
+			}
+		}
+		if tx == nil {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo, "No such transaction found in the provided block")
+		}
+	} else if tx, blockHash, err = s.cfg.Chain.Transaction(txid); err != nil {
+		return nil, err
+	}
+
+	if verbosity == 0 {
+		return txHex(tx)
+	}
+	var confirmations int64
+	if blockHash != nil {
+		_, height, err := s.cfg.Chain.Block(*blockHash)
+		if err != nil {
+			return nil, err
+		}
+		confirmations = int64(s.cfg.Chain.Tip().Height - height + 1)
+	}
+	return s.txResult(tx, blockHash, confirmations)
+}
+
+func (s *Server) sendRawTransaction(ctx context.Context, p params) (interface{}, error) {
+	var rawTx string
+	if err := p.require(0, "hexstring", &rawTx); err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(rawTx)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err == nil {
+		err = tx.Deserialize(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCDeserialization, "TX decode failed")
+	}
+	if err := s.cfg.Chain.SendTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	return tx.TxHash().String(), nil
+}
+
+func (s *Server) getRawMempool(ctx context.Context, p params) (interface{}, error) {
+	var verbose bool
+	if _, err := p.get(0, &verbose); err != nil {
+		return nil, err
+	}
+	entries := s.cfg.Chain.Mempool()
+	if !verbose {
+		txids := make([]string, len(entries))
+		for i, entry := range entries {
+			txids[i] = entry.Tx.TxHash().String()
+		}
+		return txids, nil
+	}
+
+	inMempool := make(map[chainhash.Hash]bool, len(entries))
+	for _, entry := range entries {
+		inMempool[entry.Tx.TxHash()] = true
+	}
+	height := int64(s.cfg.Chain.Tip().Height)
+	result := make(map[string]btcjson.GetRawMempoolVerboseResult, len(entries))
+	for _, entry := range entries {
+		depends := make([]string, 0)
+		for _, in := range entry.Tx.TxIn {
+			if inMempool[in.PreviousOutPoint.Hash] {
+				depends = append(depends, in.PreviousOutPoint.Hash.String())
+			}
+		}
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(entry.Tx))
+		vsize := int32((weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor)
+		result[entry.Tx.TxHash().String()] = btcjson.GetRawMempoolVerboseResult{
+			Size:    vsize,
+			Vsize:   vsize,
+			Weight:  int32(weight),
+			Fee:     btcutil.Amount(entry.Fee).ToBTC(),
+			Time:    entry.Time.Unix(),
+			Height:  height,
+			Depends: depends,
+		}
+	}
+	return result, nil
+}
+
+// scanTxOutSetResult is the result of scantxoutset
+type scanTxOutSetResult struct {
+	Success     bool          `json:"success"`
+	TxOuts      int           `json:"txouts"`
+	Height      int32         `json:"height"`
+	BestBlock   string        `json:"bestblock"`
+	Unspents    []scanUnspent `json:"unspents"`
+	TotalAmount float64       `json:"total_amount"`
+}
+
+// scanUnspent is an unspent output found by scantxoutset
+type scanUnspent struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Desc         string  `json:"desc"`
+	Amount       float64 `json:"amount"`
+	Coinbase     bool    `json:"coinbase"`
+	Height       int32   `json:"height"`
+}
+
+// scanTxOutSet lists the unspent outputs paying addr(<address>) and
+// raw(<hex script>) descriptors. Scans finish before returning, so the
+// abort and status actions find none in progress.
+func (s *Server) scanTxOutSet(ctx context.Context, p params) (interface{}, error) {
+	var action string
+	if err := p.require(0, "action", &action); err != nil {
+		return nil, err
+	}
+	switch action {
+	case "start":
+	case "abort":
+		return false, nil
+	case "status":
+		return nil, nil
+	default:
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, fmt.Sprintf("Invalid action '%s'", action))
+	}
+	var objects []json.RawMessage
+	if err := p.require(1, "scanobjects", &objects); err != nil {
+		return nil, err
+	}
+
+	net := s.cfg.Chain.Params()
+	tip := s.cfg.Chain.Tip()
+	result := scanTxOutSetResult{Success: true, Height: tip.Height, BestBlock: tip.Hash.String(), Unspents: []scanUnspent{}}
+	var total btcutil.Amount
+	for _, object := range objects {
+		var desc string
+		if err := json.Unmarshal(object, &desc); err != nil {
+			var ranged struct {
+				Desc string `json:"desc"`
+			}
+			if err := json.Unmarshal(object, &ranged); err != nil {
+				return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Scan object needs to be either a string or an object")
+			}
+			desc = ranged.Desc
+		}
+		pkScript, err := descriptorScript(desc, net)
+		if err != nil {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, err.Error())
+		}
+		coins, err := s.cfg.Chain.Coins(pkScript)
+		if err != nil {
+			return nil, err
+		}
+		for _, coin := range coins {
+			result.Unspents = append(result.Unspents, scanUnspent{
+				TxID:         coin.OutPoint.Hash.String(),
+				Vout:         coin.OutPoint.Index,
+				ScriptPubKey: hex.EncodeToString(coin.TxOut.PkScript),
+				Desc:         desc,
+				Amount:       btcutil.Amount(coin.TxOut.Value).ToBTC(),
+				Coinbase:     coin.Coinbase,
+				Height:       coin.Height,
+			})
+			total += btcutil.Amount(coin.TxOut.Value)
+		}
+	}
+	result.TxOuts = len(result.Unspents)
+	result.TotalAmount = total.ToBTC()
+	return result, nil
+}
+
+// descriptorScript returns the output script of an addr() or raw()
+// descriptor. A checksum suffix is ignored.
+func descriptorScript(desc string, net *chaincfg.Params) ([]byte, error) {
+	if i := strings.IndexByte(desc, '#'); i >= 0 {
+		desc = desc[:i]
+	}
+	switch {
+	case strings.HasPrefix(desc, "addr(") && strings.HasSuffix(desc, ")"):
+		addr, err := btcutil.DecodeAddress(desc[len("addr("):len(desc)-1], net)
+		if err != nil || !addr.IsForNet(net) {
+			return nil, fmt.Errorf("Invalid address in descriptor '%s'", desc)
+		}
+		return txscript.PayToAddrScript(addr)
+	case strings.HasPrefix(desc, "raw(") && strings.HasSuffix(desc, ")"):
+		pkScript, err := hex.DecodeString(desc[len("raw(") : len(desc)-1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid script in descriptor '%s'", desc)
+		}
+		return pkScript, nil
+	}
+	return nil, fmt.Errorf("Unsupported descriptor '%s', use addr() or raw()", desc)
+}
+
+func (s *Server) getBalance(ctx context.Context, p params) (interface{}, error) {
+	if s.cfg.Wallet == nil {
+		return nil, ErrNoWallet
+	}
+	var dummy string
+	if ok, err := p.get(0, &dummy); err != nil {
+		return nil, err
+	} else if ok && dummy != "*" {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMethodDeprecated, `dummy first argument must be excluded or set to "*".`)
+	}
+	minConf := 0
+	if _, err := p.get(1, &minConf); err != nil {
+		return nil, err
+	}
+	balance, err := s.cfg.Wallet.Balance(ctx, minConf)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCWallet, err.Error())
+	}
+	return balance.ToBTC(), nil
+}
+
+func (s *Server) getNewAddress(ctx context.Context, p params) (interface{}, error) {
+	if s.cfg.Wallet == nil {
+		return nil, ErrNoWallet
+	}
+	var addressType string
+	if _, err := p.get(1, &addressType); err != nil {
+		return nil, err
+	}
+	switch addressType {
+	case "", "bech32", "bech32m":
+	default:
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, fmt.Sprintf("Unknown address type '%s'", addressType))
+	}
+	address, err := s.cfg.Wallet.NewAddress(ctx, addressType)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCWallet, err.Error())
+	}
+	return address, nil
+}
+
+func (s *Server) generateToAddress(ctx context.Context, p params) (interface{}, error) {
+	var n int
+	var address string
+	if err := p.require(0, "nblocks", &n); err != nil {
+		return nil, err
+	}
+	if err := p.require(1, "address", &address); err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "nblocks must not be negative")
+	}
+	net := s.cfg.Chain.Params()
+	addr, err := btcutil.DecodeAddress(address, net)
+	if err != nil || !addr.IsForNet(net) {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, "Error: Invalid address")
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, "Error: Invalid address")
+	}
+
+	if s.cfg.ClockCheck != nil {
+		if err := s.cfg.ClockCheck(); err != nil {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, err.Error())
+		}
+	}
+
+	hashes, err := s.cfg.Chain.Generate(n, pkScript)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(hashes))
+	for i, hash := range hashes {
+		result[i] = hash.String()
+	}
+	return result, nil
+}
+
+// txResult describes tx the way getrawtransaction does with verbose set
+func (s *Server) txResult(tx *wire.MsgTx, blockHash *chainhash.Hash, confirmations int64) (*btcjson.TxRawResult, error) {
+	rawHex, err := txHex(tx)
+	if err != nil {
+		return nil, err
+	}
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	result := &btcjson.TxRawResult{
+		Hex:      rawHex,
+		Txid:     tx.TxHash().String(),
+		Hash:     tx.WitnessHash().String(),
+		Size:     int32(tx.SerializeSize()),
+		Vsize:    int32((weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor),
+		Weight:   int32(weight),
+		Version:  uint32(tx.Version),
+		LockTime: tx.LockTime,
+		Vin:      make([]btcjson.Vin, len(tx.TxIn)),
+		Vout:     make([]btcjson.Vout, len(tx.TxOut)),
+	}
+
+	coinbase := blockchain.IsCoinBaseTx(tx)
+	for i, in := range tx.TxIn {
+		vin := btcjson.Vin{Sequence: in.Sequence}
+		for _, item := range in.Witness {
+			vin.Witness = append(vin.Witness, hex.EncodeToString(item))
+		}
+		if coinbase {
+			vin.Coinbase = hex.EncodeToString(in.SignatureScript)
+		} else {
+			disasm, _ := txscript.DisasmString(in.SignatureScript)
+			vin.Txid = in.PreviousOutPoint.Hash.String()
+			vin.Vout = in.PreviousOutPoint.Index
+			vin.ScriptSig = &btcjson.ScriptSig{Asm: disasm, Hex: hex.EncodeToString(in.SignatureScript)}
+		}
+		result.Vin[i] = vin
+	}
+
+	net := s.cfg.Chain.Params()
+	for i, out := range tx.TxOut {
+		disasm, _ := txscript.DisasmString(out.PkScript)
+		class, addrs, _, _ := txscript.ExtractPkScriptAddrs(out.PkScript, net)
+		vout := btcjson.Vout{
+			Value: btcutil.Amount(out.Value).ToBTC(),
+			N:     uint32(i),
+			ScriptPubKey: btcjson.ScriptPubKeyResult{
+				Asm:  disasm,
+				Hex:  hex.EncodeToString(out.PkScript),
+				Type: class.String(),
+			},
+		}
+		if len(addrs) == 1 {
+			vout.ScriptPubKey.Address = addrs[0].EncodeAddress()
+		}
+		result.Vout[i] = vout
+	}
+
+	if blockHash != nil {
+		block, _, err := s.cfg.Chain.Block(*blockHash)
+		if err != nil {
+			return nil, err
+		}
+		result.BlockHash = blockHash.String()
+		result.Confirmations = uint64(confirmations)
+		result.Time = block.Header.Timestamp.Unix()
+		result.Blocktime = result.Time
+	}
+	return result, nil
+}
+
+// txHex serializes tx with its witness
+func txHex(tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// hashParam decodes a hex block or transaction hash parameter
+func hashParam(p params, i int, name string) (chainhash.Hash, error) {
+	var s string
+	if err := p.require(i, name, &s); err != nil {
+		return chainhash.Hash{}, err
+	}
+	hash, err := chainhash.NewHashFromStr(s)
+	if err != nil || len(s) != 2*chainhash.HashSize {
+		return chainhash.Hash{}, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+			fmt.Sprintf("%s must be of length 64 (not %d, for '%s')", name, len(s), s))
+	}
+	return *hash, nil
+}
+
+// verbosityParam decodes a verbosity given as a number or, as older clients
+// send it, a boolean
+func verbosityParam(p params, i int, def int) (int, error) {
+	var verbose bool
+	if ok, _ := p.get(i, &verbose); ok {
+		if verbose {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	verbosity := def
+	if _, err := p.get(i, &verbosity); err != nil {
+		return 0, err
+	}
+	if verbosity < 0 || verbosity > 2 {
+		return 0, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, fmt.Sprintf("Invalid verbosity %d", verbosity))
+	}
+	return verbosity, nil
+}
+
+// difficulty returns how many times harder bits is than difficulty 1
+func difficulty(bits uint32) float64 {
+	ratio := new(big.Float).Quo(
+		new(big.Float).SetInt(blockchain.CompactToBig(difficulty1Bits)),
+		new(big.Float).SetInt(blockchain.CompactToBig(bits)),
+	)
+	d, _ := ratio.Float64()
+	return d
+}
@@ -0,0 +1,268 @@
+// Package rpc serves a bitcoind-compatible subset of the JSON-RPC API, so
+// existing Bitcoin tooling and block explorers can talk to an EXS node.
+//
+// Requests are authenticated with HTTP basic auth and may use JSON-RPC 1.0
+// or 2.0, be batched, and pass parameters by position or by name. The
+// supported methods are getblockchaininfo, getblockcount, getbestblockhash,
+// getblockhash, getblock, getrawtransaction, sendrawtransaction, getbalance,
+// getnewaddress, getblocktemplate, submitblock, getmininginfo,
+// estimatesmartfee and, on regtest, generatetoaddress. External miners can also fetch header work
+// from /work with the same credentials.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+)
+
+// maxRequestSize bounds the body of one HTTP request
+const maxRequestSize = 8 << 20
+
+// Config configures a Server
+type Config struct {
+	Chain Chain
+	// Wallet serves getbalance and getnewaddress; nil when no wallet is loaded
+	Wallet Wallet
+	// User and Password are required from every client
+	User     string
+	Password string
+	// ClockCheck reports whether the local clock can timestamp blocks;
+	// generatetoaddress fails while it returns an error. nil skips the check.
+	ClockCheck func() error
+	// MiningAddress is paid by /work requests without an address
+	MiningAddress string
+	// Fees serves estimatesmartfee; nil disables fee estimation
+	Fees *fees.Estimator
+}
+
+// Server answers JSON-RPC requests over HTTP
+type Server struct {
+	cfg  Config
+	work *workProvider
+}
+
+// NewServer creates a server for cfg
+func NewServer(cfg Config) *Server {
+	return &Server{cfg: cfg, work: newWorkProvider(cfg.Chain)}
+}
+
+// request is one JSON-RPC call
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// ServeHTTP answers a single or batched JSON-RPC request. Like bitcoind,
+// failed JSON-RPC 1.0 calls get HTTP status 404 for unknown methods and 500
+// otherwise, while JSON-RPC 2.0 calls and batches always get 200.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/work" {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.serveWork(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "JSONRPC server handles only POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var calls []json.RawMessage
+		if err := json.Unmarshal(body, &calls); err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse(nil, "", btcjson.ErrRPCParse))
+			return
+		}
+		replies := make([]map[string]interface{}, len(calls))
+		for i, call := range calls {
+			replies[i], _ = s.call(r.Context(), call)
+		}
+		writeJSON(w, http.StatusOK, replies)
+		return
+	}
+	reply, status := s.call(r.Context(), body)
+	writeJSON(w, status, reply)
+}
+
+// authorized checks the request's basic auth credentials in constant time
+func (s *Server) authorized(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.cfg.User)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.Password)) == 1
+	return userOK && passwordOK
+}
+
+// call runs one JSON-RPC call and returns its response and HTTP status
+func (s *Server) call(ctx context.Context, raw json.RawMessage) (map[string]interface{}, int) {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, "", btcjson.ErrRPCParse), http.StatusInternalServerError
+	}
+	if req.Method == "" {
+		return errorResponse(req.ID, req.JSONRPC, btcjson.ErrRPCInvalidRequest), statusFor(req.JSONRPC, http.StatusBadRequest)
+	}
+
+	m, ok := methods[req.Method]
+	if !ok {
+		return errorResponse(req.ID, req.JSONRPC, btcjson.ErrRPCMethodNotFound), statusFor(req.JSONRPC, http.StatusNotFound)
+	}
+	params, err := parseParams(req.Params, m.params)
+	var result interface{}
+	if err == nil {
+		result, err = m.call(s, ctx, params)
+	}
+	if err != nil {
+		return errorResponse(req.ID, req.JSONRPC, rpcError(err)), statusFor(req.JSONRPC, http.StatusInternalServerError)
+	}
+
+	reply := map[string]interface{}{"result": result, "id": req.ID}
+	if req.JSONRPC == "2.0" {
+		reply["jsonrpc"] = "2.0"
+	} else {
+		reply["error"] = nil
+	}
+	return reply, http.StatusOK
+}
+
+// statusFor returns status for a failed JSON-RPC 1.0 call and 200 for 2.0
+func statusFor(version string, status int) int {
+	if version == "2.0" {
+		return http.StatusOK
+	}
+	return status
+}
+
+// errorResponse builds the response to a failed call
+func errorResponse(id json.RawMessage, version string, rpcErr *btcjson.RPCError) map[string]interface{} {
+	reply := map[string]interface{}{"error": rpcErr, "id": id}
+	if version == "2.0" {
+		reply["jsonrpc"] = "2.0"
+	} else {
+		reply["result"] = nil
+	}
+	return reply
+}
+
+// rpcError converts a method error to the error bitcoind would return
+func rpcError(err error) *btcjson.RPCError {
+	var rpcErr *btcjson.RPCError
+	switch {
+	case errors.As(err, &rpcErr):
+		return rpcErr
+	case errors.Is(err, ErrBlockNotFound):
+		return btcjson.NewRPCError(btcjson.ErrRPCBlockNotFound, "Block not found")
+	case errors.Is(err, chain.ErrBlockPruned):
+		return btcjson.NewRPCError(btcjson.ErrRPCMisc, "Block not available (pruned data)")
+	case errors.Is(err, ErrTxNotFound):
+		return btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo, "No such mempool or blockchain transaction")
+	case errors.Is(err, ErrTxRejected):
+		return btcjson.NewRPCError(btcjson.ErrRPCTxRejected, strings.TrimPrefix(err.Error(), ErrTxRejected.Error()+": "))
+	case errors.Is(err, ErrTxInChain):
+		return btcjson.NewRPCError(btcjson.ErrRPCTxAlreadyInChain, "Transaction already in block chain")
+	case errors.Is(err, ErrNoWallet):
+		return btcjson.NewRPCError(btcjson.ErrRPCWalletNotFound, "No wallet is loaded")
+	}
+	return btcjson.NewRPCError(btcjson.ErrRPCMisc, err.Error())
+}
+
+// params holds a call's parameters by position
+type params []json.RawMessage
+
+// parseParams reads positional parameters from an array, or named ones from
+// an object using the method's parameter names
+func parseParams(raw json.RawMessage, names []string) (params, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	if raw[0] == '{' {
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &named); err != nil {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, "Params must be an array or object")
+		}
+		p := make(params, len(names))
+		n := 0
+		for i, name := range names {
+			if v, ok := named[name]; ok {
+				p[i] = v
+				n = i + 1
+				delete(named, name)
+			}
+		}
+		for name := range named {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Unknown named parameter "+name)
+		}
+		return p[:n], nil
+	}
+	var p params
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, "Params must be an array or object")
+	}
+	if len(p) > len(names) {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code,
+			fmt.Sprintf("Too many parameters: expected at most %d", len(names)))
+	}
+	return p, nil
+}
+
+// get decodes parameter i into dst, reporting false when it is absent or null
+func (p params) get(i int, dst interface{}) (bool, error) {
+	if i >= len(p) || len(p[i]) == 0 || bytes.Equal(p[i], []byte("null")) {
+		return false, nil
+	}
+	if err := json.Unmarshal(p[i], dst); err != nil {
+		return false, btcjson.NewRPCError(btcjson.ErrRPCType, fmt.Sprintf("Invalid type for parameter %d", i+1))
+	}
+	return true, nil
+}
+
+// require decodes a mandatory parameter
+func (p params) require(i int, name string, dst interface{}) error {
+	ok, err := p.get(i, dst)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, "Missing required parameter "+name)
+	}
+	return nil
+}
+
+// writeJSON writes v with status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
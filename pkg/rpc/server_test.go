@@ -0,0 +1,482 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+)
+
+type stubWallet struct {
+	address string
+	balance btcutil.Amount
+	minConf int
+}
+
+func (w *stubWallet) NewAddress(ctx context.Context, addressType string) (string, error) {
+	return w.address, nil
+}
+
+func (w *stubWallet) Balance(ctx context.Context, minConf int) (btcutil.Amount, error) {
+	w.minConf = minConf
+	return w.balance, nil
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	ID json.RawMessage `json:"id"`
+}
+
+type testNode struct {
+	t      *testing.T
+	server *httptest.Server
+	chain  *LocalChain
+	wallet *stubWallet
+	fees   *fees.Estimator
+	// clockErr is returned by the server's ClockCheck
+	clockErr error
+}
+
+func newTestNode(t *testing.T) *testNode {
+	store, err := chain.OpenMemory(&chaincfg.RegressionNetParams, chain.Options{TxIndex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	local := NewLocalChain(store)
+	wallet := &stubWallet{address: "bcrt1qtest", balance: 150000000}
+	node := &testNode{t: t, chain: local, wallet: wallet, fees: fees.New(fees.Config{})}
+	node.server = httptest.NewServer(NewServer(Config{
+		Chain: local, Wallet: wallet, User: "exs", Password: "secret", Fees: node.fees,
+		ClockCheck: func() error { return node.clockErr },
+	}))
+	t.Cleanup(node.server.Close)
+	return node
+}
+
+// post sends body and returns the HTTP status and raw response
+func (n *testNode) post(body string) (int, []byte) {
+	n.t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, n.server.URL, strings.NewReader(body))
+	req.SetBasicAuth("exs", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return resp.StatusCode, buf.Bytes()
+}
+
+// call makes a JSON-RPC 1.0 call and decodes its result into result
+func (n *testNode) call(method string, result interface{}, params ...interface{}) {
+	n.t.Helper()
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"id": 1, "method": method, "params": params})
+	status, raw := n.post(string(body))
+	var resp response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		n.t.Fatalf("%s: %v: %s", method, err, raw)
+	}
+	if status != http.StatusOK || resp.Error != nil {
+		n.t.Fatalf("%s: status %d: %s", method, status, raw)
+	}
+	if result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			n.t.Fatalf("%s: %v", method, err)
+		}
+	}
+}
+
+// callError makes a call expected to fail and returns its error code
+func (n *testNode) callError(method string, params ...interface{}) int {
+	n.t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"id": 1, "method": method, "params": params})
+	_, raw := n.post(string(body))
+	var resp response
+	json.Unmarshal(raw, &resp)
+	if resp.Error == nil {
+		n.t.Fatalf("%s succeeded: %s", method, raw)
+	}
+	return resp.Error.Code
+}
+
+func TestGenerateAndQuery(t *testing.T) {
+	node := newTestNode(t)
+	key, _ := btcec.NewPrivateKey()
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+
+	var hashes []string
+	node.call("generatetoaddress", &hashes, 101, addr.EncodeAddress())
+	if len(hashes) != 101 {
+		t.Fatalf("generated %d blocks, want 101", len(hashes))
+	}
+
+	var info struct {
+		Chain         string `json:"chain"`
+		Blocks        int32  `json:"blocks"`
+		BestBlockHash string `json:"bestblockhash"`
+		ChainWork     string `json:"chainwork"`
+	}
+	node.call("getblockchaininfo", &info)
+	if info.Chain != "regtest" || info.Blocks != 101 || info.BestBlockHash != hashes[100] || len(info.ChainWork) != 64 {
+		t.Errorf("getblockchaininfo = %+v", info)
+	}
+
+	var hash string
+	node.call("getblockhash", &hash, 1)
+	if hash != hashes[0] {
+		t.Errorf("getblockhash(1) = %s, want %s", hash, hashes[0])
+	}
+	if code := node.callError("getblockhash", 500); code != -8 {
+		t.Errorf("getblockhash out of range code = %d, want -8", code)
+	}
+
+	var block struct {
+		Height        int64    `json:"height"`
+		Confirmations int64    `json:"confirmations"`
+		Tx            []string `json:"tx"`
+		PreviousHash  string   `json:"previousblockhash"`
+		NextHash      string   `json:"nextblockhash"`
+	}
+	node.call("getblock", &block, hashes[0])
+	if block.Height != 1 || block.Confirmations != 101 || len(block.Tx) != 1 || block.NextHash != hashes[1] {
+		t.Errorf("getblock = %+v", block)
+	}
+
+	var rawBlock string
+	node.call("getblock", &rawBlock, hashes[0], 0)
+	var decoded wire.MsgBlock
+	data, _ := hex.DecodeString(rawBlock)
+	if err := decoded.Deserialize(bytes.NewReader(data)); err != nil || decoded.BlockHash().String() != hashes[0] {
+		t.Errorf("getblock verbosity 0 does not decode to the block: %v", err)
+	}
+
+	var txBlock struct {
+		Tx []struct {
+			Txid string `json:"txid"`
+			Vin  []struct {
+				Coinbase string `json:"coinbase"`
+			} `json:"vin"`
+			Vout []struct {
+				ScriptPubKey struct {
+					Address string `json:"address"`
+					Type    string `json:"type"`
+				} `json:"scriptPubKey"`
+			} `json:"vout"`
+		} `json:"tx"`
+	}
+	node.call("getblock", &txBlock, hashes[0], 2)
+	coinbase := txBlock.Tx[0]
+	if coinbase.Txid != block.Tx[0] || coinbase.Vin[0].Coinbase == "" ||
+		coinbase.Vout[0].ScriptPubKey.Address != addr.EncodeAddress() ||
+		coinbase.Vout[0].ScriptPubKey.Type != "witness_v0_keyhash" {
+		t.Errorf("getblock verbosity 2 coinbase = %+v", coinbase)
+	}
+
+	var rawTx struct {
+		Txid          string `json:"txid"`
+		BlockHash     string `json:"blockhash"`
+		Confirmations int64  `json:"confirmations"`
+	}
+	node.call("getrawtransaction", &rawTx, block.Tx[0], true)
+	if rawTx.Txid != block.Tx[0] || rawTx.BlockHash != hashes[0] || rawTx.Confirmations != 101 {
+		t.Errorf("getrawtransaction = %+v", rawTx)
+	}
+	if code := node.callError("getrawtransaction", strings.Repeat("00", 32)); code != -5 {
+		t.Errorf("getrawtransaction unknown code = %d, want -5", code)
+	}
+}
+
+func TestScanTxOutSet(t *testing.T) {
+	node := newTestNode(t)
+	key, _ := btcec.NewPrivateKey()
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+
+	var hashes []string
+	node.call("generatetoaddress", &hashes, 3, addr.EncodeAddress())
+	var result struct {
+		Success   bool   `json:"success"`
+		Height    int32  `json:"height"`
+		BestBlock string `json:"bestblock"`
+		Unspents  []struct {
+			Txid         string  `json:"txid"`
+			ScriptPubKey string  `json:"scriptPubKey"`
+			Amount       float64 `json:"amount"`
+			Coinbase     bool    `json:"coinbase"`
+			Height       int32   `json:"height"`
+		} `json:"unspents"`
+		TotalAmount float64 `json:"total_amount"`
+	}
+	node.call("scantxoutset", &result, "start", []string{"addr(" + addr.EncodeAddress() + ")"})
+	if !result.Success || result.Height != 3 || result.BestBlock != hashes[2] || len(result.Unspents) != 3 || result.TotalAmount != 150 {
+		t.Fatalf("scantxoutset = %+v", result)
+	}
+	if u := result.Unspents[0]; u.ScriptPubKey != hex.EncodeToString(pkScript) || !u.Coinbase || u.Amount != 50 || u.Height == 0 {
+		t.Errorf("scantxoutset unspent = %+v", u)
+	}
+
+	node.call("scantxoutset", &result, "start", []interface{}{map[string]string{"desc": "raw(0014" + strings.Repeat("00", 20) + ")"}})
+	if len(result.Unspents) != 0 || result.TotalAmount != 0 {
+		t.Errorf("scantxoutset of an unused script = %+v", result)
+	}
+	if code := node.callError("scantxoutset", "start", []string{"pkh(02aa)"}); code != -5 {
+		t.Errorf("unsupported descriptor code = %d, want -5", code)
+	}
+	if code := node.callError("scantxoutset", "start", []string{"addr(tb1qinvalid)"}); code != -5 {
+		t.Errorf("invalid address code = %d, want -5", code)
+	}
+}
+
+func TestSendRawTransaction(t *testing.T) {
+	node := newTestNode(t)
+	key, _ := btcec.NewPrivateKey()
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+
+	var hashes []string
+	node.call("generatetoaddress", &hashes, 101, addr.EncodeAddress())
+	var block struct {
+		Tx []string `json:"tx"`
+	}
+	node.call("getblock", &block, hashes[0])
+	coinbaseID, _ := chainhash.NewHashFromStr(block.Tx[0])
+	prevOut := wire.NewTxOut(50*btcutil.SatoshiPerBitcoin, pkScript)
+
+	spend := func(value int64) *wire.MsgTx {
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(coinbaseID, 0), nil, nil))
+		tx.AddTxOut(wire.NewTxOut(value, pkScript))
+		fetcher := txscript.NewCannedPrevOutputFetcher(prevOut.PkScript, prevOut.Value)
+		witness, err := txscript.WitnessSignature(tx, txscript.NewTxSigHashes(tx, fetcher), 0,
+			prevOut.Value, prevOut.PkScript, txscript.SigHashAll, key, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.TxIn[0].Witness = witness
+		return tx
+	}
+	encode := func(tx *wire.MsgTx) string {
+		var buf bytes.Buffer
+		tx.Serialize(&buf)
+		return hex.EncodeToString(buf.Bytes())
+	}
+
+	tx := spend(prevOut.Value - 10000)
+	var txid string
+	node.call("sendrawtransaction", &txid, encode(tx))
+	if txid != tx.TxHash().String() {
+		t.Fatalf("sendrawtransaction = %s, want %s", txid, tx.TxHash())
+	}
+	if code := node.callError("sendrawtransaction", encode(spend(prevOut.Value-20000))); code != -26 {
+		t.Errorf("double spend code = %d, want -26", code)
+	}
+	if code := node.callError("sendrawtransaction", "zz"); code != -22 {
+		t.Errorf("undecodable transaction code = %d, want -22", code)
+	}
+
+	var mempoolTx struct {
+		Confirmations int64 `json:"confirmations"`
+	}
+	node.call("getrawtransaction", &mempoolTx, txid, 1)
+	if mempoolTx.Confirmations != 0 {
+		t.Errorf("mempool transaction has %d confirmations", mempoolTx.Confirmations)
+	}
+	var mempool []string
+	node.call("getrawmempool", &mempool)
+	if len(mempool) != 1 || mempool[0] != txid {
+		t.Errorf("getrawmempool = %v, want [%s]", mempool, txid)
+	}
+	var verbose map[string]struct {
+		Fee   float64 `json:"fee"`
+		Vsize int32   `json:"vsize"`
+	}
+	node.call("getrawmempool", &verbose, true)
+	if entry, ok := verbose[txid]; !ok || entry.Fee != 0.0001 || entry.Vsize == 0 {
+		t.Errorf("verbose getrawmempool = %+v, want %s paying 0.0001", verbose, txid)
+	}
+
+	node.call("generatetoaddress", &hashes, 1, addr.EncodeAddress())
+	var mined struct {
+		Tx []string `json:"tx"`
+	}
+	node.call("getblock", &mined, hashes[0])
+	if len(mined.Tx) != 2 || mined.Tx[1] != txid {
+		t.Fatalf("mined block txs = %v, want coinbase and %s", mined.Tx, txid)
+	}
+	node.call("getrawmempool", &mempool)
+	if len(mempool) != 0 {
+		t.Errorf("getrawmempool after mining = %v, want empty", mempool)
+	}
+	if code := node.callError("sendrawtransaction", encode(tx)); code != -27 {
+		t.Errorf("confirmed transaction code = %d, want -27", code)
+	}
+
+	var coinbase struct {
+		Vout []struct {
+			Value float64 `json:"value"`
+		} `json:"vout"`
+	}
+	node.call("getrawtransaction", &coinbase, mined.Tx[0], 1, hashes[0])
+	if coinbase.Vout[0].Value != 50.0001 {
+		t.Errorf("coinbase value = %v, want subsidy plus fee 50.0001", coinbase.Vout[0].Value)
+	}
+}
+
+func TestWalletMethods(t *testing.T) {
+	node := newTestNode(t)
+
+	var balance float64
+	node.call("getbalance", &balance, "*", 6)
+	if balance != 1.5 || node.wallet.minConf != 6 {
+		t.Errorf("getbalance = %v with minconf %d", balance, node.wallet.minConf)
+	}
+	var address string
+	node.call("getnewaddress", &address, "", "bech32m")
+	if address != node.wallet.address {
+		t.Errorf("getnewaddress = %s", address)
+	}
+	if code := node.callError("getnewaddress", "", "legacy"); code != -5 {
+		t.Errorf("legacy address type code = %d, want -5", code)
+	}
+
+	server := NewServer(Config{Chain: node.chain})
+	if code := rpcError(mustFail(t, server, "getbalance")).Code; code != -18 {
+		t.Errorf("getbalance without wallet code = %d, want -18", code)
+	}
+}
+
+func mustFail(t *testing.T, s *Server, name string) error {
+	t.Helper()
+	_, err := methods[name].call(s, context.Background(), nil)
+	if err == nil {
+		t.Fatalf("%s succeeded", name)
+	}
+	return err
+}
+
+func TestProtocol(t *testing.T) {
+	node := newTestNode(t)
+
+	req, _ := http.NewRequest(http.MethodPost, node.server.URL, strings.NewReader(`{"method":"getblockcount"}`))
+	req.SetBasicAuth("exs", "wrong")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("bad password status = %d, want 401", resp.StatusCode)
+	}
+
+	status, raw := node.post(`{"id":1,"method":"getnetworkhashps","params":[]}`)
+	var resp1 response
+	json.Unmarshal(raw, &resp1)
+	if status != http.StatusNotFound || resp1.Error == nil || resp1.Error.Code != -32601 {
+		t.Errorf("unknown 1.0 method = %d %s", status, raw)
+	}
+	status, raw = node.post(`{"jsonrpc":"2.0","id":1,"method":"getnetworkhashps"}`)
+	if status != http.StatusOK || !strings.Contains(string(raw), "-32601") || strings.Contains(string(raw), `"result"`) {
+		t.Errorf("unknown 2.0 method = %d %s", status, raw)
+	}
+
+	status, raw = node.post(`[{"id":1,"method":"getblockcount"},{"id":2,"method":"getblockhash","params":{"height":0}},{"id":3,"method":"nope"}]`)
+	var batch []response
+	if err := json.Unmarshal(raw, &batch); err != nil || status != http.StatusOK || len(batch) != 3 {
+		t.Fatalf("batch = %d %s", status, raw)
+	}
+	if string(batch[0].Result) != "0" ||
+		string(batch[1].Result) != `"`+chaincfg.RegressionNetParams.GenesisHash.String()+`"` ||
+		batch[2].Error == nil {
+		t.Errorf("batch replies = %s", raw)
+	}
+
+	if code := node.callError("getblockhash"); code != -32602 {
+		t.Errorf("missing parameter code = %d, want -32602", code)
+	}
+	status, raw = node.post(`{"id":1,"method":"getblockhash","params":{"index":0}}`)
+	if status != http.StatusInternalServerError || !strings.Contains(string(raw), "-8") {
+		t.Errorf("unknown named parameter = %d %s", status, raw)
+	}
+}
+
+func TestGenerateRefusedOnClockSkew(t *testing.T) {
+	node := newTestNode(t)
+	key, _ := btcec.NewPrivateKey()
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+
+	node.clockErr = errors.New("local clock is 3h0m0s ahead of NTP time")
+	if code := node.callError("generatetoaddress", 1, addr.EncodeAddress()); code != -1 {
+		t.Errorf("generatetoaddress with a skewed clock code = %d, want -1", code)
+	}
+	if height := node.chain.Tip().Height; height != 0 {
+		t.Errorf("Expected no blocks mined, got height %d", height)
+	}
+
+	node.clockErr = nil
+	var hashes []string
+	node.call("generatetoaddress", &hashes, 1, addr.EncodeAddress())
+	if len(hashes) != 1 {
+		t.Errorf("generated %d blocks, want 1", len(hashes))
+	}
+}
+
+func TestEstimateSmartFee(t *testing.T) {
+	node := newTestNode(t)
+	var result struct {
+		FeeRate *float64 `json:"feerate"`
+		Errors  []string `json:"errors"`
+		Blocks  int64    `json:"blocks"`
+	}
+	node.call("estimatesmartfee", &result, 6)
+	if result.FeeRate != nil || len(result.Errors) == 0 {
+		t.Errorf("Expected no estimate before observing the node, got %+v", result)
+	}
+
+	key, _ := btcec.NewPrivateKey()
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+	var hashes []string
+	node.call("generatetoaddress", &hashes, 3, addr.EncodeAddress())
+	if err := ObserveFees(node.chain, node.fees); err != nil {
+		t.Fatalf("ObserveFees() error = %v", err)
+	}
+	if node.fees.Height() != 3 {
+		t.Errorf("Estimator height = %d, want 3", node.fees.Height())
+	}
+
+	// An empty mempool needs only the minimum rate, 1 sat/vB
+	result.FeeRate = nil
+	node.call("estimatesmartfee", &result, 6, "CONSERVATIVE")
+	if result.FeeRate == nil || *result.FeeRate != 0.00001 || result.Blocks != 6 {
+		t.Errorf("estimatesmartfee = %+v, want 0.00001 BTC/kvB", result)
+	}
+	if code := node.callError("estimatesmartfee", 0); code != -8 {
+		t.Errorf("conf_target 0 code = %d, want -8", code)
+	}
+	if code := node.callError("estimatesmartfee", 6, "fast"); code != -8 {
+		t.Errorf("unknown mode code = %d, want -8", code)
+	}
+}
@@ -0,0 +1,438 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/chain"
+)
+
+// Work for external miners. getblocktemplate hands out BIP 22 templates
+// whose coinbase the miner builds, and submitblock takes the solved block.
+// The /work endpoint hands out header work instead: the node builds the
+// coinbase, paying the mining address, and the miner only returns a nonce
+// and a time. Solutions are checked against the chain's target before the
+// chain validates them, and work whose parent is no longer the tip is
+// refused as stale.
+//
+// The proof of work is the one pkg/chain validates: Bitcoin's double
+// SHA-256 of the 80-byte header, not Tetra-PoW. Tetra-PoW (pkg/consensus)
+// is what cmd/tetra_pow mines for the treasury's forges, and checking it
+// here would refuse blocks the chain accepts.
+
+// maxHeaderWork is the number of /work units remembered, so solutions to
+// work handed out shortly before newer work are still accepted
+const maxHeaderWork = 64
+
+var (
+	// longPollTimeout bounds how long a long poll waits for new work
+	longPollTimeout = time.Minute
+	// workPollInterval is how often a long poll checks for new work
+	workPollInterval = 250 * time.Millisecond
+)
+
+// ErrNoMiningAddress indicates header work requested without an address
+// and without a configured mining address
+var ErrNoMiningAddress = errors.New("no mining address configured")
+
+// Submission results, as BIP 22 names them; an accepted block has none
+const (
+	resultDuplicate    = "duplicate"
+	resultInconclusive = "inconclusive"
+	resultStale        = "stale-prevblk"
+	resultBadPrevBlock = "bad-prevblk"
+	resultHighHash     = "high-hash"
+	resultUnknownWork  = "unknown-work"
+	resultRejected     = "rejected"
+)
+
+// templateScript pays the coinbase of getblocktemplate templates, whose
+// coinbase the miner replaces
+var templateScript = []byte{txscript.OP_TRUE}
+
+// WorkStats counts the solutions submitted to a server
+type WorkStats struct {
+	// Accepted counts blocks stored, on the main chain or not
+	Accepted uint64 `json:"accepted"`
+	// Stale counts blocks whose parent was no longer the tip
+	Stale     uint64 `json:"stale"`
+	Duplicate uint64 `json:"duplicate"`
+	Rejected  uint64 `json:"rejected"`
+}
+
+// Work is a block header for a miner to solve: it grinds the nonce, and
+// may raise the time, until the header's double SHA-256 (SHA-256d, as for
+// Bitcoin, not Tetra-PoW) is at most Target
+type Work struct {
+	ID            string `json:"id"`
+	Height        int32  `json:"height"`
+	PreviousHash  string `json:"previousblockhash"`
+	Header        string `json:"header"`
+	Bits          string `json:"bits"`
+	Target        string `json:"target"`
+	CurTime       int64  `json:"curtime"`
+	MinTime       int64  `json:"mintime"`
+	CoinbaseValue int64  `json:"coinbasevalue"`
+	Transactions  int    `json:"transactions"`
+	LongPollID    string `json:"longpollid"`
+}
+
+// WorkSubmission is a solution to header work. A zero Time keeps the
+// work's time.
+type WorkSubmission struct {
+	ID    string `json:"id"`
+	Nonce uint32 `json:"nonce"`
+	Time  int64  `json:"time,omitempty"`
+}
+
+// WorkResult reports a submitted solution, with the BIP 22 reason when it
+// was not accepted
+type WorkResult struct {
+	Accepted bool   `json:"accepted"`
+	Hash     string `json:"hash"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// workProvider builds templates and tracks the header work handed out and
+// the solutions submitted
+type workProvider struct {
+	chain Chain
+
+	mu    sync.Mutex
+	next  uint64
+	work  map[string]*BlockTemplate
+	order []string
+	stats WorkStats
+}
+
+func newWorkProvider(c Chain) *workProvider {
+	return &workProvider{chain: c, work: make(map[string]*BlockTemplate)}
+}
+
+// state identifies the work the chain would hand out now: the tip and the
+// number of mempool transactions, which long polls wait to change
+func (w *workProvider) state() string {
+	return w.chain.Tip().Hash.String() + strconv.Itoa(len(w.chain.Mempool()))
+}
+
+// wait blocks while the state is longPollID, up to longPollTimeout
+func (w *workProvider) wait(ctx context.Context, longPollID string) error {
+	if longPollID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, longPollTimeout)
+	defer cancel()
+	ticker := time.NewTicker(workPollInterval)
+	defer ticker.Stop()
+	for w.state() == longPollID {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// headerWork builds a template paying pkScript and remembers it as work
+func (w *workProvider) headerWork(pkScript []byte) (*Work, error) {
+	state := w.state()
+	tmpl, err := w.chain.BlockTemplate(pkScript)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	w.next++
+	id := strconv.FormatUint(w.next, 16)
+	w.work[id] = tmpl
+	w.order = append(w.order, id)
+	if len(w.order) > maxHeaderWork {
+		delete(w.work, w.order[0])
+		w.order = w.order[1:]
+	}
+	w.mu.Unlock()
+
+	var header bytes.Buffer
+	if err := tmpl.Block.Header.Serialize(&header); err != nil {
+		return nil, err
+	}
+	h := tmpl.Block.Header
+	return &Work{
+		ID:            id,
+		Height:        tmpl.Height,
+		PreviousHash:  h.PrevBlock.String(),
+		Header:        hex.EncodeToString(header.Bytes()),
+		Bits:          fmt.Sprintf("%08x", h.Bits),
+		Target:        target(h.Bits),
+		CurTime:       h.Timestamp.Unix(),
+		MinTime:       w.chain.Tip().MedianTime.Unix() + 1,
+		CoinbaseValue: tmpl.Block.Transactions[0].TxOut[0].Value,
+		Transactions:  len(tmpl.Block.Transactions),
+		LongPollID:    state,
+	}, nil
+}
+
+// solve applies a submission to its work and submits the block
+func (w *workProvider) solve(sub WorkSubmission) WorkResult {
+	w.mu.Lock()
+	tmpl, ok := w.work[sub.ID]
+	w.mu.Unlock()
+	if !ok {
+		w.count(resultUnknownWork)
+		return WorkResult{Reason: resultUnknownWork}
+	}
+
+	block := &wire.MsgBlock{Header: tmpl.Block.Header, Transactions: tmpl.Block.Transactions}
+	block.Header.Nonce = sub.Nonce
+	if sub.Time != 0 {
+		block.Header.Timestamp = time.Unix(sub.Time, 0)
+	}
+	reason := w.submit(block)
+	return WorkResult{Accepted: reason == "" || reason == resultInconclusive, Hash: block.BlockHash().String(), Reason: reason}
+}
+
+// submit validates a solved block and stores it, returning its BIP 22
+// result
+func (w *workProvider) submit(block *wire.MsgBlock) string {
+	reason := w.check(block)
+	w.count(reason)
+	return reason
+}
+
+// count records a submission's result
+func (w *workProvider) count(reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch reason {
+	case "", resultInconclusive:
+		w.stats.Accepted++
+	case resultStale:
+		w.stats.Stale++
+	case resultDuplicate:
+		w.stats.Duplicate++
+	default:
+		w.stats.Rejected++
+	}
+}
+
+func (w *workProvider) check(block *wire.MsgBlock) string {
+	if block.Header.PrevBlock != w.chain.Tip().Hash {
+		switch {
+		case w.known(block.BlockHash()):
+			return resultDuplicate
+		case !w.known(block.Header.PrevBlock):
+			return resultBadPrevBlock
+		}
+		return resultStale
+	}
+	if err := blockchain.CheckProofOfWork(btcutil.NewBlock(block), w.chain.Params().PowLimit); err != nil {
+		return resultHighHash
+	}
+	main, err := w.chain.SubmitBlock(block)
+	switch {
+	case errors.Is(err, chain.ErrDuplicateBlock):
+		return resultDuplicate
+	case errors.Is(err, chain.ErrOrphanBlock):
+		return resultBadPrevBlock
+	case err != nil:
+		return resultRejected + ": " + err.Error()
+	case !main:
+		return resultInconclusive
+	}
+	return ""
+}
+
+// known reports whether the chain has the block with hash
+func (w *workProvider) known(hash chainhash.Hash) bool {
+	_, _, err := w.chain.Block(hash)
+	return err == nil
+}
+
+// Stats returns the solutions submitted so far
+func (w *workProvider) Stats() WorkStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+// target returns the target of bits as 64 hex digits
+func target(bits uint32) string {
+	return fmt.Sprintf("%064x", blockchain.CompactToBig(bits))
+}
+
+func (s *Server) getBlockTemplate(ctx context.Context, p params) (interface{}, error) {
+	var req struct {
+		Mode       string `json:"mode"`
+		LongPollID string `json:"longpollid"`
+	}
+	if _, err := p.get(0, &req); err != nil {
+		return nil, err
+	}
+	if req.Mode != "" && req.Mode != "template" {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Invalid mode")
+	}
+	if err := s.work.wait(ctx, req.LongPollID); err != nil {
+		return nil, err
+	}
+
+	state := s.work.state()
+	tmpl, err := s.cfg.Chain.BlockTemplate(templateScript)
+	if err != nil {
+		return nil, err
+	}
+	h := tmpl.Block.Header
+	coinbase := tmpl.Block.Transactions[0]
+	result := &btcjson.GetBlockTemplateResult{
+		Bits:          fmt.Sprintf("%08x", h.Bits),
+		CurTime:       h.Timestamp.Unix(),
+		Height:        int64(tmpl.Height),
+		PreviousHash:  h.PrevBlock.String(),
+		SigOpLimit:    blockchain.MaxBlockSigOpsCost,
+		SizeLimit:     blockchain.MaxBlockBaseSize,
+		WeightLimit:   blockchain.MaxBlockWeight,
+		Transactions:  make([]btcjson.GetBlockTemplateResultTx, 0, len(tmpl.Fees)),
+		Version:       h.Version,
+		CoinbaseValue: &coinbase.TxOut[0].Value,
+		LongPollID:    state,
+		Target:        target(h.Bits),
+		MinTime:       s.cfg.Chain.Tip().MedianTime.Unix() + 1,
+		Mutable:       []string{"time", "transactions", "prevblock"},
+		NonceRange:    "00000000ffffffff",
+	}
+	if _, ok := blockchain.ExtractWitnessCommitment(btcutil.NewTx(coinbase)); ok {
+		// The template adds the commitment as the last coinbase output
+		result.DefaultWitnessCommitment = hex.EncodeToString(coinbase.TxOut[len(coinbase.TxOut)-1].PkScript)
+	}
+
+	index := make(map[chainhash.Hash]int64, len(tmpl.Fees))
+	for i, tx := range tmpl.Block.Transactions[1:] {
+		data, err := txHex(tx)
+		if err != nil {
+			return nil, err
+		}
+		txid := tx.TxHash()
+		index[txid] = int64(i + 1)
+		depends := make([]int64, 0)
+		for _, in := range tx.TxIn {
+			if n, ok := index[in.PreviousOutPoint.Hash]; ok {
+				depends = append(depends, n)
+			}
+		}
+		utx := btcutil.NewTx(tx)
+		result.Transactions = append(result.Transactions, btcjson.GetBlockTemplateResultTx{
+			Data:    data,
+			TxID:    txid.String(),
+			Hash:    tx.WitnessHash().String(),
+			Depends: depends,
+			Fee:     tmpl.Fees[i],
+			SigOps:  int64(blockchain.CountSigOps(utx) * blockchain.WitnessScaleFactor),
+			Weight:  blockchain.GetTransactionWeight(utx),
+		})
+	}
+	return result, nil
+}
+
+func (s *Server) submitBlock(ctx context.Context, p params) (interface{}, error) {
+	var data string
+	if err := p.require(0, "hexdata", &data); err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCDeserialization, "Block decode failed")
+	}
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(raw)); err != nil || len(block.Transactions) == 0 {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCDeserialization, "Block decode failed")
+	}
+	if reason := s.work.submit(&block); reason != "" {
+		return reason, nil
+	}
+	return nil, nil
+}
+
+// miningInfo is the getmininginfo result, with the submission counts
+type miningInfo struct {
+	Blocks     int64     `json:"blocks"`
+	Difficulty float64   `json:"difficulty"`
+	PooledTx   int       `json:"pooledtx"`
+	Chain      string    `json:"chain"`
+	Submitted  WorkStats `json:"submitted"`
+	Warnings   string    `json:"warnings"`
+}
+
+func (s *Server) getMiningInfo(ctx context.Context, p params) (interface{}, error) {
+	tip := s.cfg.Chain.Tip()
+	return &miningInfo{
+		Blocks:     int64(tip.Height),
+		Difficulty: difficulty(tip.Header.Bits),
+		PooledTx:   len(s.cfg.Chain.Mempool()),
+		Chain:      chainNames[s.cfg.Chain.Params().Net],
+		Submitted:  s.work.Stats(),
+	}, nil
+}
+
+// serveWork answers /work: GET hands out header work paying the address
+// parameter or the configured mining address, first waiting for new work
+// when longpollid is the current one, and POST takes a WorkSubmission
+func (s *Server) serveWork(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		address := r.URL.Query().Get("address")
+		if address == "" {
+			address = s.cfg.MiningAddress
+		}
+		if address == "" {
+			http.Error(w, ErrNoMiningAddress.Error(), http.StatusBadRequest)
+			return
+		}
+		net := s.cfg.Chain.Params()
+		addr, err := btcutil.DecodeAddress(address, net)
+		var pkScript []byte
+		if err == nil && addr.IsForNet(net) {
+			pkScript, err = txscript.PayToAddrScript(addr)
+		}
+		if err != nil || pkScript == nil {
+			http.Error(w, "Invalid address", http.StatusBadRequest)
+			return
+		}
+		if err := s.work.wait(r.Context(), r.URL.Query().Get("longpollid")); err != nil {
+			return
+		}
+		work, err := s.work.headerWork(pkScript)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, work)
+	case http.MethodPost:
+		var sub WorkSubmission
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&sub); err != nil {
+			http.Error(w, "Invalid submission", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.work.solve(sub))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
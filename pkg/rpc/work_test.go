@@ -0,0 +1,272 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// grind sets the first nonce whose header hash meets, or with meets false
+// misses, the header's target
+func grind(t *testing.T, header *wire.BlockHeader, meets bool) {
+	t.Helper()
+	target := blockchain.CompactToBig(header.Bits)
+	for nonce := uint32(0); nonce < 1<<20; nonce++ {
+		header.Nonce = nonce
+		hash := header.BlockHash()
+		if (blockchain.HashToBig(&hash).Cmp(target) <= 0) == meets {
+			return
+		}
+	}
+	t.Fatal("no nonce found")
+}
+
+func TestBlockTemplate(t *testing.T) {
+	node := newTestNode(t)
+	key, _ := btcec.NewPrivateKey()
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+
+	var hashes []string
+	node.call("generatetoaddress", &hashes, 101, addr.EncodeAddress())
+	coinbase, _, _ := node.chain.Transaction(coinbaseID(t, hashes[0], node))
+	prevOut := coinbase.TxOut[0]
+	spend := wire.NewMsgTx(2)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&[]chainhash.Hash{coinbase.TxHash()}[0], 0), nil, nil))
+	spend.AddTxOut(wire.NewTxOut(prevOut.Value-10000, pkScript))
+	fetcher := txscript.NewCannedPrevOutputFetcher(prevOut.PkScript, prevOut.Value)
+	witness, err := txscript.WitnessSignature(spend, txscript.NewTxSigHashes(spend, fetcher), 0,
+		prevOut.Value, prevOut.PkScript, txscript.SigHashAll, key, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend.TxIn[0].Witness = witness
+	var buf bytes.Buffer
+	spend.Serialize(&buf)
+	node.call("sendrawtransaction", nil, hex.EncodeToString(buf.Bytes()))
+
+	var tmpl struct {
+		Bits          string `json:"bits"`
+		CurTime       int64  `json:"curtime"`
+		Height        int32  `json:"height"`
+		PreviousHash  string `json:"previousblockhash"`
+		Version       int32  `json:"version"`
+		CoinbaseValue int64  `json:"coinbasevalue"`
+		Commitment    string `json:"default_witness_commitment"`
+		Target        string `json:"target"`
+		LongPollID    string `json:"longpollid"`
+		Transactions  []struct {
+			Data string `json:"data"`
+			TxID string `json:"txid"`
+			Fee  int64  `json:"fee"`
+		} `json:"transactions"`
+	}
+	node.call("getblocktemplate", &tmpl, map[string]interface{}{"rules": []string{"segwit"}})
+	subsidy := blockchain.CalcBlockSubsidy(102, &chaincfg.RegressionNetParams)
+	if tmpl.Height != 102 || tmpl.PreviousHash != hashes[100] || tmpl.CoinbaseValue != subsidy+10000 ||
+		len(tmpl.Transactions) != 1 || tmpl.Transactions[0].TxID != spend.TxHash().String() ||
+		tmpl.Transactions[0].Fee != 10000 || tmpl.Commitment == "" || len(tmpl.Target) != 64 {
+		t.Fatalf("getblocktemplate = %+v", tmpl)
+	}
+	if code := node.callError("getblocktemplate", map[string]string{"mode": "proposal"}); code != -8 {
+		t.Errorf("proposal mode code = %d, want -8", code)
+	}
+
+	// Build the block the way a miner would, with its own coinbase
+	sigScript, _ := txscript.NewScriptBuilder().AddInt64(102).AddInt64(7).Script()
+	cb := wire.NewMsgTx(1)
+	cb.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+		SignatureScript:  sigScript,
+		Sequence:         wire.MaxTxInSequenceNum,
+		Witness:          wire.TxWitness{make([]byte, 32)},
+	})
+	cb.AddTxOut(wire.NewTxOut(tmpl.CoinbaseValue, pkScript))
+	commitment, _ := hex.DecodeString(tmpl.Commitment)
+	cb.AddTxOut(wire.NewTxOut(0, commitment))
+	txData, _ := hex.DecodeString(tmpl.Transactions[0].Data)
+	var tx wire.MsgTx
+	tx.Deserialize(bytes.NewReader(txData))
+
+	bits, _ := strconv.ParseUint(tmpl.Bits, 16, 32)
+	prev, _ := chainhash.NewHashFromStr(tmpl.PreviousHash)
+	block := wire.MsgBlock{Header: wire.BlockHeader{
+		Version:    tmpl.Version,
+		PrevBlock:  *prev,
+		MerkleRoot: blockchain.CalcMerkleRoot([]*btcutil.Tx{btcutil.NewTx(cb), btcutil.NewTx(&tx)}, false),
+		Timestamp:  time.Unix(tmpl.CurTime, 0),
+		Bits:       uint32(bits),
+	}, Transactions: []*wire.MsgTx{cb, &tx}}
+	grind(t, &block.Header, true)
+	buf.Reset()
+	block.Serialize(&buf)
+
+	var result *string
+	node.call("submitblock", &result, hex.EncodeToString(buf.Bytes()))
+	if result != nil {
+		t.Fatalf("submitblock = %q, want null", *result)
+	}
+	if tip := node.chain.Tip(); tip.Hash != block.BlockHash() || len(node.chain.Mempool()) != 0 {
+		t.Fatalf("tip %s with %d mempool transactions after submitblock", tip.Hash, len(node.chain.Mempool()))
+	}
+	node.call("submitblock", &result, hex.EncodeToString(buf.Bytes()))
+	if result == nil || *result != "duplicate" {
+		t.Errorf("resubmitted block = %v, want duplicate", result)
+	}
+	if code := node.callError("submitblock", "zz"); code != -22 {
+		t.Errorf("undecodable block code = %d, want -22", code)
+	}
+
+	var info miningInfo
+	node.call("getmininginfo", &info)
+	if info.Blocks != 102 || info.Chain != "regtest" || info.Submitted != (WorkStats{Accepted: 1, Duplicate: 1}) {
+		t.Errorf("getmininginfo = %+v", info)
+	}
+}
+
+// coinbaseID returns the coinbase txid of the block with hash
+func coinbaseID(t *testing.T, hash string, node *testNode) chainhash.Hash {
+	t.Helper()
+	h, _ := chainhash.NewHashFromStr(hash)
+	block, _, err := node.chain.Block(*h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block.Transactions[0].TxHash()
+}
+
+func TestBlockTemplateLongPoll(t *testing.T) {
+	saved := workPollInterval
+	workPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { workPollInterval = saved })
+
+	node := newTestNode(t)
+	key, _ := btcec.NewPrivateKey()
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+
+	var tmpl struct {
+		PreviousHash string `json:"previousblockhash"`
+		LongPollID   string `json:"longpollid"`
+	}
+	node.call("getblocktemplate", &tmpl)
+
+	done := make(chan string, 1)
+	go func() {
+		var next struct {
+			PreviousHash string `json:"previousblockhash"`
+		}
+		body, _ := json.Marshal(map[string]interface{}{"id": 1, "method": "getblocktemplate",
+			"params": []interface{}{map[string]string{"longpollid": tmpl.LongPollID}}})
+		_, raw := node.post(string(body))
+		var resp response
+		json.Unmarshal(raw, &resp)
+		json.Unmarshal(resp.Result, &next)
+		done <- next.PreviousHash
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("long poll returned before the tip changed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	var hashes []string
+	node.call("generatetoaddress", &hashes, 1, addr.EncodeAddress())
+	select {
+	case prev := <-done:
+		if prev != hashes[0] {
+			t.Errorf("long poll template builds on %s, want %s", prev, hashes[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("long poll did not return after a new block")
+	}
+}
+
+func TestHeaderWork(t *testing.T) {
+	node := newTestNode(t)
+	key, _ := btcec.NewPrivateKey()
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+
+	request := func(method, query, body string) (int, []byte) {
+		req, _ := http.NewRequest(method, node.server.URL+"/work"+query, strings.NewReader(body))
+		req.SetBasicAuth("exs", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+	getWork := func(addr btcutil.Address) (Work, wire.BlockHeader) {
+		t.Helper()
+		status, raw := request(http.MethodGet, "?address="+addr.EncodeAddress(), "")
+		var work Work
+		if err := json.Unmarshal(raw, &work); status != http.StatusOK || err != nil {
+			t.Fatalf("GET /work = %d: %s", status, raw)
+		}
+		data, _ := hex.DecodeString(work.Header)
+		var header wire.BlockHeader
+		header.Deserialize(bytes.NewReader(data))
+		return work, header
+	}
+	submit := func(id string, nonce uint32) WorkResult {
+		t.Helper()
+		body, _ := json.Marshal(WorkSubmission{ID: id, Nonce: nonce})
+		_, raw := request(http.MethodPost, "", string(body))
+		var result WorkResult
+		json.Unmarshal(raw, &result)
+		return result
+	}
+
+	if status, _ := request(http.MethodGet, "", ""); status != http.StatusBadRequest {
+		t.Errorf("GET /work without an address = %d, want 400", status)
+	}
+	req, _ := http.NewRequest(http.MethodGet, node.server.URL+"/work", nil)
+	if resp, _ := http.DefaultClient.Do(req); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /work without credentials = %d, want 401", resp.StatusCode)
+	}
+
+	other, _ := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), &chaincfg.RegressionNetParams)
+	work, header := getWork(addr)
+	older, olderHeader := getWork(other)
+	if work.Height != 1 || work.ID == older.ID || work.CoinbaseValue != 50*btcutil.SatoshiPerBitcoin {
+		t.Fatalf("work = %+v", work)
+	}
+
+	grind(t, &header, false)
+	if result := submit(work.ID, header.Nonce); result.Accepted || result.Reason != "high-hash" {
+		t.Errorf("unsolved work = %+v, want high-hash", result)
+	}
+	grind(t, &header, true)
+	result := submit(work.ID, header.Nonce)
+	if !result.Accepted || result.Hash != node.chain.Tip().Hash.String() {
+		t.Fatalf("solved work = %+v, tip %s", result, node.chain.Tip().Hash)
+	}
+	grind(t, &olderHeader, true)
+	if result := submit(older.ID, olderHeader.Nonce); result.Accepted || result.Reason != "stale-prevblk" {
+		t.Errorf("work on the old tip = %+v, want stale-prevblk", result)
+	}
+	if result := submit("ffff", 0); result.Reason != "unknown-work" {
+		t.Errorf("unknown work = %+v, want unknown-work", result)
+	}
+
+	var info miningInfo
+	node.call("getmininginfo", &info)
+	if info.Blocks != 1 || info.Submitted != (WorkStats{Accepted: 1, Stale: 1, Rejected: 2}) {
+		t.Errorf("getmininginfo = %+v", info)
+	}
+}
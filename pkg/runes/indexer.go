@@ -0,0 +1,442 @@
+package runes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	// ErrUnknownRune indicates a rune ID or name that was never etched
+	ErrUnknownRune = errors.New("unknown rune")
+	// ErrBlockOrder indicates a block indexed out of height order
+	ErrBlockOrder = errors.New("block out of order")
+)
+
+// RuneEntry is an etched rune
+type RuneEntry struct {
+	ID           RuneID         `json:"id"`
+	Name         string         `json:"name"`
+	Rune         Rune           `json:"-"`
+	Spacers      uint32         `json:"spacers,omitempty"`
+	Divisibility uint8          `json:"divisibility"`
+	Symbol       string         `json:"symbol,omitempty"`
+	Premine      U128           `json:"premine"`
+	Terms        *Terms         `json:"terms,omitempty"`
+	Turbo        bool           `json:"turbo,omitempty"`
+	Mints        U128           `json:"mints"`
+	Burned       U128           `json:"burned"`
+	Etching      chainhash.Hash `json:"etching"`
+	Timestamp    time.Time      `json:"timestamp"`
+}
+
+// Supply returns the amount of the rune in existence, burned runes
+// included: the premine plus every mint so far
+func (e *RuneEntry) Supply() U128 {
+	var amount U128
+	if e.Terms != nil && e.Terms.Amount != nil {
+		amount = *e.Terms.Amount
+	}
+	minted, _ := e.Mints.Mul(amount)
+	supply, _ := e.Premine.Add(minted)
+	return supply
+}
+
+// mintable returns the amount a mint at height makes, or false when the
+// rune's terms do not allow one
+func (e *RuneEntry) mintable(height uint64) (U128, bool) {
+	t := e.Terms
+	if t == nil {
+		return U128{}, false
+	}
+	var cap U128
+	if t.Cap != nil {
+		cap = *t.Cap
+	}
+	if e.Mints.Cmp(cap) >= 0 ||
+		t.HeightStart != nil && height < *t.HeightStart ||
+		t.HeightEnd != nil && height >= *t.HeightEnd ||
+		t.OffsetStart != nil && height < e.ID.Block+*t.OffsetStart ||
+		t.OffsetEnd != nil && height >= e.ID.Block+*t.OffsetEnd {
+		return U128{}, false
+	}
+	if t.Amount == nil {
+		return U128{}, true
+	}
+	return *t.Amount, true
+}
+
+// Balance is an amount of one rune
+type Balance struct {
+	ID     RuneID `json:"id"`
+	Name   string `json:"name"`
+	Amount U128   `json:"amount"`
+}
+
+// output is an unspent output holding runes
+type output struct {
+	address  string
+	balances map[RuneID]U128
+}
+
+// Indexer follows the rune protocol through the blocks of one chain,
+// tracking etched runes and the runes held by every unspent output and by
+// every address. Blocks are indexed in height order from the first block
+// given; the index lives in memory, so after a restart or a reorganization
+// it is rebuilt by indexing the chain again. Etchings of named runes are not
+// checked for a commitment to the name in their inputs.
+type Indexer struct {
+	net *chaincfg.Params
+	// first is the height rune names start unlocking at
+	first uint64
+
+	mu        sync.RWMutex
+	height    uint64
+	started   bool
+	runes     map[RuneID]*RuneEntry
+	names     map[Rune]RuneID
+	outputs   map[wire.OutPoint]*output
+	addresses map[string]map[RuneID]U128
+}
+
+// NewIndexer creates an indexer for net whose rune names start unlocking at
+// height first, see MinimumAtHeight
+func NewIndexer(net *chaincfg.Params, first uint64) *Indexer {
+	return &Indexer{
+		net:       net,
+		first:     first,
+		runes:     make(map[RuneID]*RuneEntry),
+		names:     make(map[Rune]RuneID),
+		outputs:   make(map[wire.OutPoint]*output),
+		addresses: make(map[string]map[RuneID]U128),
+	}
+}
+
+// Height returns the height of the last block indexed, and false before
+// the first
+func (ix *Indexer) Height() (uint64, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.height, ix.started
+}
+
+// IndexBlock applies the runestones of block at height. Blocks after the
+// first must follow each other.
+func (ix *Indexer) IndexBlock(block *wire.MsgBlock, height uint64) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.started && height != ix.height+1 {
+		return fmt.Errorf("%w: have %d, got %d", ErrBlockOrder, ix.height, height)
+	}
+	for i, tx := range block.Transactions {
+		ix.indexTx(tx, height, uint32(i), block.Header.Timestamp)
+	}
+	ix.height, ix.started = height, true
+	return nil
+}
+
+// indexTx moves the runes of tx's inputs to its outputs as its runestone
+// directs, minting and etching on the way; callers must hold ix.mu
+func (ix *Indexer) indexTx(tx *wire.MsgTx, height uint64, index uint32, timestamp time.Time) {
+	unallocated := make(map[RuneID]U128)
+	for _, in := range tx.TxIn {
+		for id, amount := range ix.spend(in.PreviousOutPoint) {
+			unallocated[id], _ = unallocated[id].Add(amount)
+		}
+	}
+
+	allocated := make([]map[RuneID]U128, len(tx.TxOut))
+	allocate := func(vout int, id RuneID, amount U128) {
+		if amount.IsZero() {
+			return
+		}
+		if allocated[vout] == nil {
+			allocated[vout] = make(map[RuneID]U128)
+		}
+		allocated[vout][id], _ = allocated[vout][id].Add(amount)
+		unallocated[id] = unallocated[id].Sub(amount)
+	}
+	burned := make(map[RuneID]U128)
+
+	runestone := Decipher(tx)
+	if runestone != nil {
+		if runestone.Mint != nil {
+			if entry, ok := ix.runes[*runestone.Mint]; ok {
+				if amount, ok := entry.mintable(height); ok {
+					entry.Mints, _ = entry.Mints.Add(NewU128(1))
+					unallocated[entry.ID], _ = unallocated[entry.ID].Add(amount)
+				}
+			}
+		}
+		etched := ix.etch(tx, runestone, height, index, timestamp)
+
+		if runestone.Cenotaph != "" {
+			for id, amount := range unallocated {
+				burned[id], _ = burned[id].Add(amount)
+			}
+			unallocated = nil
+		} else {
+			if etched != nil {
+				unallocated[etched.ID], _ = unallocated[etched.ID].Add(etched.Premine)
+			}
+			for _, edict := range runestone.Edicts {
+				id := edict.ID
+				if id == (RuneID{}) {
+					if etched == nil {
+						continue
+					}
+					id = etched.ID
+				}
+				balance, ok := unallocated[id]
+				if !ok {
+					continue
+				}
+				if int(edict.Output) < len(tx.TxOut) {
+					amount := balance
+					if !edict.Amount.IsZero() {
+						amount = edict.Amount.Min(balance)
+					}
+					allocate(int(edict.Output), id, amount)
+					continue
+				}
+
+				// Split between every output but OP_RETURNs
+				destinations := spendableOutputs(tx)
+				if len(destinations) == 0 {
+					continue
+				}
+				if edict.Amount.IsZero() {
+					share, remainder := balance.DivMod(uint64(len(destinations)))
+					for i, vout := range destinations {
+						amount := share
+						if uint64(i) < remainder {
+							amount, _ = amount.Add(NewU128(1))
+						}
+						allocate(vout, id, amount)
+					}
+				} else {
+					for _, vout := range destinations {
+						allocate(vout, id, edict.Amount.Min(unallocated[id]))
+					}
+				}
+			}
+		}
+	}
+
+	// What is left goes to the pointer, by default the first output that
+	// is not an OP_RETURN, and is burned without one
+	if len(unallocated) > 0 {
+		vout := -1
+		if runestone != nil && runestone.Pointer != nil {
+			vout = int(*runestone.Pointer)
+		} else if destinations := spendableOutputs(tx); len(destinations) > 0 {
+			vout = destinations[0]
+		}
+		for id, amount := range unallocated {
+			if vout < 0 {
+				burned[id], _ = burned[id].Add(amount)
+			} else {
+				allocate(vout, id, amount)
+			}
+		}
+	}
+
+	txHash := tx.TxHash()
+	for vout, balances := range allocated {
+		if balances == nil {
+			continue
+		}
+		if isOpReturn(tx.TxOut[vout].PkScript) {
+			for id, amount := range balances {
+				burned[id], _ = burned[id].Add(amount)
+			}
+			continue
+		}
+		ix.store(wire.OutPoint{Hash: txHash, Index: uint32(vout)}, tx.TxOut[vout].PkScript, balances)
+	}
+	for id, amount := range burned {
+		if entry, ok := ix.runes[id]; ok {
+			entry.Burned, _ = entry.Burned.Add(amount)
+		}
+	}
+}
+
+// etch creates the rune tx's runestone etches, if it may: a named rune must
+// be unlocked at height, not reserved and not etched before. A cenotaph
+// etches a named rune without supply. Callers must hold ix.mu.
+func (ix *Indexer) etch(tx *wire.MsgTx, runestone *Runestone, height uint64, index uint32, timestamp time.Time) *RuneEntry {
+	e := runestone.Etching
+	if e == nil {
+		return nil
+	}
+	name := reservedRune(height, index)
+	if e.Rune != nil {
+		name = *e.Rune
+		if U128(name).Cmp(U128(MinimumAtHeight(ix.first, height))) < 0 || name.IsReserved() {
+			return nil
+		}
+	}
+	if _, taken := ix.names[name]; taken {
+		return nil
+	}
+
+	entry := &RuneEntry{
+		ID:        RuneID{Block: height, Tx: index},
+		Rune:      name,
+		Etching:   tx.TxHash(),
+		Timestamp: timestamp,
+	}
+	if runestone.Cenotaph == "" {
+		if e.Divisibility != nil {
+			entry.Divisibility = *e.Divisibility
+		}
+		if e.Premine != nil {
+			entry.Premine = *e.Premine
+		}
+		if e.Spacers != nil {
+			entry.Spacers = *e.Spacers
+		}
+		if e.Symbol != nil {
+			entry.Symbol = string(*e.Symbol)
+		}
+		entry.Terms = e.Terms
+		entry.Turbo = e.Turbo
+	}
+	entry.Name = SpacedRune{Rune: name, Spacers: entry.Spacers}.String()
+	ix.runes[entry.ID] = entry
+	ix.names[name] = entry.ID
+	return entry
+}
+
+// spend removes the runes of outPoint from the index and returns them;
+// callers must hold ix.mu
+func (ix *Indexer) spend(outPoint wire.OutPoint) map[RuneID]U128 {
+	out, ok := ix.outputs[outPoint]
+	if !ok {
+		return nil
+	}
+	delete(ix.outputs, outPoint)
+	if held := ix.addresses[out.address]; out.address != "" {
+		for id, amount := range out.balances {
+			if held[id] = held[id].Sub(amount); held[id].IsZero() {
+				delete(held, id)
+			}
+		}
+		if len(held) == 0 {
+			delete(ix.addresses, out.address)
+		}
+	}
+	return out.balances
+}
+
+// store records the runes of a new output; callers must hold ix.mu
+func (ix *Indexer) store(outPoint wire.OutPoint, pkScript []byte, balances map[RuneID]U128) {
+	out := &output{balances: balances}
+	if _, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, ix.net); err == nil && len(addrs) == 1 {
+		out.address = addrs[0].EncodeAddress()
+	}
+	ix.outputs[outPoint] = out
+	if out.address == "" {
+		return
+	}
+	held := ix.addresses[out.address]
+	if held == nil {
+		held = make(map[RuneID]U128)
+		ix.addresses[out.address] = held
+	}
+	for id, amount := range balances {
+		held[id], _ = held[id].Add(amount)
+	}
+}
+
+// Rune returns the rune etched as id
+func (ix *Indexer) Rune(id RuneID) (*RuneEntry, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	entry, ok := ix.runes[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRune, id)
+	}
+	r := *entry
+	return &r, nil
+}
+
+// RuneByName returns the rune named name, with or without spacers
+func (ix *Indexer) RuneByName(name string) (*RuneEntry, error) {
+	spaced, err := ParseSpacedRune(name)
+	if err != nil {
+		return nil, err
+	}
+	ix.mu.RLock()
+	id, ok := ix.names[spaced.Rune]
+	ix.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRune, name)
+	}
+	return ix.Rune(id)
+}
+
+// Runes returns every etched rune in etching order
+func (ix *Indexer) Runes() []RuneEntry {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	runes := make([]RuneEntry, 0, len(ix.runes))
+	for _, entry := range ix.runes {
+		runes = append(runes, *entry)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i].ID.less(runes[j].ID) })
+	return runes
+}
+
+// Balances returns the runes held by address, such as a Taproot address,
+// in etching order
+func (ix *Indexer) Balances(address string) []Balance {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.balances(ix.addresses[address])
+}
+
+// OutputBalances returns the runes held by an unspent output
+func (ix *Indexer) OutputBalances(outPoint wire.OutPoint) []Balance {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	out, ok := ix.outputs[outPoint]
+	if !ok {
+		return nil
+	}
+	return ix.balances(out.balances)
+}
+
+// balances lists held with rune names; callers must hold ix.mu
+func (ix *Indexer) balances(held map[RuneID]U128) []Balance {
+	balances := make([]Balance, 0, len(held))
+	for id, amount := range held {
+		balances = append(balances, Balance{ID: id, Name: ix.runes[id].Name, Amount: amount})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].ID.less(balances[j].ID) })
+	return balances
+}
+
+// spendableOutputs returns the indexes of tx's outputs that are not
+// OP_RETURNs
+func spendableOutputs(tx *wire.MsgTx) []int {
+	var outputs []int
+	for vout, out := range tx.TxOut {
+		if !isOpReturn(out.PkScript) {
+			outputs = append(outputs, vout)
+		}
+	}
+	return outputs
+}
+
+// isOpReturn reports whether pkScript is an unspendable OP_RETURN output
+func isOpReturn(pkScript []byte) bool {
+	return len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN
+}
@@ -0,0 +1,167 @@
+package runes
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// taprootAddress returns a regtest Taproot address and its output script
+func taprootAddress(t *testing.T, seed byte) (string, []byte) {
+	key := make([]byte, 32)
+	key[0] = seed
+	addr, err := btcutil.NewAddressTaproot(key, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr.EncodeAddress(), script
+}
+
+// runeTx builds a transaction spending ins with a runestone as its first
+// output followed by outputs paying scripts
+func runeTx(t *testing.T, runestone *Runestone, ins []wire.OutPoint, scripts ...[]byte) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	for _, in := range ins {
+		tx.AddTxIn(wire.NewTxIn(&in, nil, nil))
+	}
+	if len(ins) == 0 {
+		// A unique coinbase-like input keeps transaction hashes apart
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: uint32(len(scripts))}, []byte{byte(len(scripts))}, nil))
+	}
+	script, err := runestone.Encipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.AddTxOut(wire.NewTxOut(0, script))
+	for _, s := range scripts {
+		tx.AddTxOut(wire.NewTxOut(546, s))
+	}
+	return tx
+}
+
+func outPoint(tx *wire.MsgTx, vout uint32) wire.OutPoint {
+	return wire.OutPoint{Hash: tx.TxHash(), Index: vout}
+}
+
+func balanceOf(ix *Indexer, address string, id RuneID) U128 {
+	for _, b := range ix.Balances(address) {
+		if b.ID == id {
+			return b.Amount
+		}
+	}
+	return U128{}
+}
+
+func TestIndexer(t *testing.T) {
+	alice, aliceScript := taprootAddress(t, 1)
+	bob, bobScript := taprootAddress(t, 2)
+	ix := NewIndexer(&chaincfg.RegressionNetParams, 0)
+
+	name, _ := ParseRune("EXSCALIBURRUNE")
+	divisibility, spacers, symbol := uint8(8), uint32(1<<2), '⚔'
+	premine, amount, cap := NewU128(1000), NewU128(100), NewU128(2)
+	etch := runeTx(t, &Runestone{Etching: &Etching{
+		Divisibility: &divisibility,
+		Premine:      &premine,
+		Rune:         &name,
+		Spacers:      &spacers,
+		Symbol:       &symbol,
+		Terms:        &Terms{Amount: &amount, Cap: &cap},
+	}}, nil, aliceScript)
+	if err := ix.IndexBlock(&wire.MsgBlock{Transactions: []*wire.MsgTx{etch}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	id := RuneID{Block: 1}
+	entry, err := ix.RuneByName("EXS•CALIBURRUNE")
+	if err != nil || entry.ID != id || entry.Name != "EXS•CALIBURRUNE" || entry.Symbol != "⚔" {
+		t.Fatalf("RuneByName() = %+v, %v", entry, err)
+	}
+	if got := balanceOf(ix, alice, id); got != premine {
+		t.Errorf("Expected alice to hold the premine, got %s", got)
+	}
+
+	// A mint, then a transfer of 300 to bob with the rest pointed back
+	pointer := uint32(2)
+	mint := runeTx(t, &Runestone{Mint: &id}, nil, bobScript, aliceScript)
+	transfer := runeTx(t, &Runestone{
+		Edicts:  []Edict{{ID: id, Amount: NewU128(300), Output: 1}},
+		Pointer: &pointer,
+	}, []wire.OutPoint{outPoint(etch, 1)}, bobScript, aliceScript)
+	if err := ix.IndexBlock(&wire.MsgBlock{Transactions: []*wire.MsgTx{mint, transfer}}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(ix, bob, id); got != NewU128(400) {
+		t.Errorf("Expected bob to hold 400, got %s", got)
+	}
+	if got := balanceOf(ix, alice, id); got != NewU128(700) {
+		t.Errorf("Expected alice to hold 700, got %s", got)
+	}
+	if b := ix.OutputBalances(outPoint(etch, 1)); len(b) != 0 {
+		t.Errorf("Expected the spent etching output to be empty, got %v", b)
+	}
+
+	// Splitting alice's output evenly, a mint past the cap and a cenotaph
+	// burning bob's transfer output
+	split := runeTx(t, &Runestone{
+		Edicts: []Edict{{ID: id, Output: 3}},
+	}, []wire.OutPoint{outPoint(transfer, 2)}, aliceScript, bobScript)
+	second := runeTx(t, &Runestone{Mint: &id}, nil, bobScript, bobScript, aliceScript)
+	third := runeTx(t, &Runestone{Mint: &id}, nil, bobScript, bobScript, bobScript, aliceScript)
+	cenotaph := runeTx(t, &Runestone{}, []wire.OutPoint{outPoint(transfer, 1)}, bobScript)
+	cenotaph.TxOut[0].PkScript = []byte{txscript.OP_RETURN, txscript.OP_13, txscript.OP_1}
+	block := &wire.MsgBlock{Transactions: []*wire.MsgTx{split, second, third, cenotaph}}
+	if err := ix.IndexBlock(block, 2); !errors.Is(err, ErrBlockOrder) {
+		t.Errorf("Expected ErrBlockOrder, got %v", err)
+	}
+	if err := ix.IndexBlock(block, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(ix, alice, id); got != NewU128(350) {
+		t.Errorf("Expected alice to hold 350, got %s", got)
+	}
+	if b := ix.OutputBalances(outPoint(split, 2)); len(b) != 1 || b[0].Amount != NewU128(350) {
+		t.Errorf("Expected half of the split on output 2, got %v", b)
+	}
+	// Both mints and half of the split; the transfer output was burned
+	if got := balanceOf(ix, bob, id); got != NewU128(550) {
+		t.Errorf("Expected bob to hold 550, got %s", got)
+	}
+
+	entry, _ = ix.Rune(id)
+	if entry.Mints != NewU128(2) || entry.Burned != NewU128(300) || entry.Supply() != NewU128(1200) {
+		t.Errorf("Expected 2 mints, 300 burned and a supply of 1200, got %+v", entry)
+	}
+	if _, err := ix.Rune(RuneID{Block: 9}); !errors.Is(err, ErrUnknownRune) {
+		t.Errorf("Expected ErrUnknownRune, got %v", err)
+	}
+}
+
+func TestIndexerEtchingRules(t *testing.T) {
+	_, script := taprootAddress(t, 1)
+	ix := NewIndexer(&chaincfg.RegressionNetParams, 0)
+
+	short, _ := ParseRune("EXS")
+	long, _ := ParseRune("EXSCALIBURRUNE")
+	premine := NewU128(1)
+	block := &wire.MsgBlock{Transactions: []*wire.MsgTx{
+		runeTx(t, &Runestone{Etching: &Etching{Rune: &short, Premine: &premine}}, nil, script),
+		runeTx(t, &Runestone{Etching: &Etching{Rune: &long, Premine: &premine}}, nil, script, script),
+		runeTx(t, &Runestone{Etching: &Etching{Rune: &long}}, nil, script, script, script),
+		runeTx(t, &Runestone{Etching: &Etching{Premine: &premine}}, nil, script, script, script, script),
+	}}
+	if err := ix.IndexBlock(block, 5); err != nil {
+		t.Fatal(err)
+	}
+	runes := ix.Runes()
+	if len(runes) != 2 || runes[0].Rune != long || runes[1].Name != reservedRune(5, 3).String() {
+		t.Errorf("Expected a named and a reserved rune, got %+v", runes)
+	}
+}
@@ -0,0 +1,161 @@
+package runes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Spacer separates the letters of a spaced rune name
+const Spacer = '•'
+
+// UnlockInterval is the number of blocks between each one-letter shortening
+// of the shortest name that can be etched, a twelfth of a halving interval
+const UnlockInterval = 17_500
+
+// unlockSteps is 12 steps of UnlockInterval
+const unlockSteps = 12
+
+// maxSpacers masks the spacers between the 28 letters of the longest name
+const maxSpacers = 1<<27 - 1
+
+// ErrInvalidName indicates a rune name that is not A to Z letters,
+// optionally with spacers between them
+var ErrInvalidName = errors.New("invalid rune name")
+
+// Rune is a rune's name as a number in bijective base 26: A is 0, Z is 25,
+// AA is 26 and so on
+type Rune U128
+
+// reserved is the first reserved rune, AAAAAAAAAAAAAAAAAAAAAAAAAAA. Etchings
+// without a name are given one from here on.
+var reserved = Rune{Hi: 0x04d1_0cef_280d_a966, Lo: 0xfa07_0460_2570_a3d6}
+
+// reservedRune returns the name given to the unnamed rune etched by
+// transaction tx of block
+func reservedRune(block uint64, tx uint32) Rune {
+	n, _ := U128(reserved).Add(U128{Hi: block >> 32, Lo: block<<32 | uint64(tx)})
+	return Rune(n)
+}
+
+// IsReserved reports whether r is reserved for unnamed etchings
+func (r Rune) IsReserved() bool {
+	return U128(r).Cmp(U128(reserved)) >= 0
+}
+
+// ParseRune parses a rune name such as UNCOMMONGOODS
+func ParseRune(s string) (Rune, error) {
+	if s == "" {
+		return Rune{}, fmt.Errorf("%w: empty name", ErrInvalidName)
+	}
+	n := U128{}
+	for i, c := range s {
+		if c < 'A' || c > 'Z' {
+			return Rune{}, fmt.Errorf("%w: %q", ErrInvalidName, s)
+		}
+		var overflow bool
+		if i > 0 {
+			if n, overflow = n.Add(NewU128(1)); overflow {
+				return Rune{}, fmt.Errorf("%w: %q is too long", ErrInvalidName, s)
+			}
+		}
+		if n, overflow = n.Mul(NewU128(26)); overflow {
+			return Rune{}, fmt.Errorf("%w: %q is too long", ErrInvalidName, s)
+		}
+		if n, overflow = n.Add(NewU128(uint64(c - 'A'))); overflow {
+			return Rune{}, fmt.Errorf("%w: %q is too long", ErrInvalidName, s)
+		}
+	}
+	return Rune(n), nil
+}
+
+// String returns the name's letters
+func (r Rune) String() string {
+	n := U128(r)
+	if n == MaxU128 {
+		return "BCGDENLQRQWDSLRUGSNLBTMFIJAV"
+	}
+	n, _ = n.Add(NewU128(1))
+	var letters []byte
+	for !n.IsZero() {
+		m, rem := n.Sub(NewU128(1)).DivMod(26)
+		letters = append(letters, 'A'+byte(rem))
+		n = m
+	}
+	for i, j := 0, len(letters)-1; i < j; i, j = i+1, j-1 {
+		letters[i], letters[j] = letters[j], letters[i]
+	}
+	return string(letters)
+}
+
+// SpacedRune is a rune name with the spacers it is displayed with: bit i of
+// Spacers puts a spacer after letter i
+type SpacedRune struct {
+	Rune    Rune
+	Spacers uint32
+}
+
+// ParseSpacedRune parses a name with optional spacers, • or ., between its
+// letters, such as UNCOMMON•GOODS
+func ParseSpacedRune(s string) (SpacedRune, error) {
+	var letters strings.Builder
+	var spacers uint32
+	for _, c := range s {
+		if c == Spacer || c == '.' {
+			if letters.Len() == 0 || spacers&(1<<(letters.Len()-1)) != 0 {
+				return SpacedRune{}, fmt.Errorf("%w: misplaced spacer in %q", ErrInvalidName, s)
+			}
+			spacers |= 1 << (letters.Len() - 1)
+			continue
+		}
+		letters.WriteRune(c)
+	}
+	r, err := ParseRune(letters.String())
+	if err != nil {
+		return SpacedRune{}, err
+	}
+	if spacers>>(letters.Len()-1) != 0 {
+		return SpacedRune{}, fmt.Errorf("%w: trailing spacer in %q", ErrInvalidName, s)
+	}
+	return SpacedRune{Rune: r, Spacers: spacers}, nil
+}
+
+// String returns the name with its spacers
+func (s SpacedRune) String() string {
+	var b strings.Builder
+	for i, c := range s.Rune.String() {
+		b.WriteRune(c)
+		if s.Spacers&(1<<i) != 0 {
+			b.WriteRune(Spacer)
+		}
+	}
+	return b.String()
+}
+
+// MinimumAtHeight returns the lowest rune that can be etched at height on a
+// chain whose rune names start unlocking at first: until then only names of
+// 13 letters or more, then one letter shorter every UnlockInterval blocks,
+// gradually within each interval, until every name is open
+func MinimumAtHeight(first, height uint64) Rune {
+	// steps[i] is the first name of i+1 letters
+	var steps [unlockSteps + 1]U128
+	for i := 1; i <= unlockSteps; i++ {
+		steps[i], _ = steps[i-1].Add(NewU128(1))
+		steps[i], _ = steps[i].Mul(NewU128(26))
+	}
+
+	offset := height + 1
+	if offset < first {
+		return Rune(steps[unlockSteps])
+	}
+	progress := offset - first
+	if progress >= unlockSteps*UnlockInterval {
+		return Rune{}
+	}
+	length := unlockSteps - progress/UnlockInterval
+	start, end := steps[length], steps[length-1]
+	span := start.Sub(end)
+	elapsed, _ := span.Mul(NewU128(progress % UnlockInterval))
+	unlocked, _ := elapsed.DivMod(UnlockInterval)
+	return Rune(start.Sub(unlocked))
+}
@@ -0,0 +1,505 @@
+package runes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MaxDivisibility is the most decimal places a rune can have
+const MaxDivisibility = 38
+
+// maxPushSize bounds each data push of a runestone's payload
+const maxPushSize = txscript.MaxScriptElementSize
+
+// Runestone message tags. Unknown even tags, such as 126, make a cenotaph;
+// unknown odd tags, such as the no-op 127, are ignored.
+const (
+	tagBody         = 0
+	tagDivisibility = 1
+	tagFlags        = 2
+	tagSpacers      = 3
+	tagRune         = 4
+	tagSymbol       = 5
+	tagPremine      = 6
+	tagCap          = 8
+	tagAmount       = 10
+	tagHeightStart  = 12
+	tagHeightEnd    = 14
+	tagOffsetStart  = 16
+	tagOffsetEnd    = 18
+	tagMint         = 20
+	tagPointer      = 22
+)
+
+// Flags bits
+const (
+	flagEtching  = 0
+	flagTerms    = 1
+	flagTurbo    = 2
+	flagCenotaph = 127
+)
+
+// Cenotaph flaws
+const (
+	FlawCenotaph            = "cenotaph flag"
+	FlawEdictOutput         = "edict output out of range"
+	FlawEdictRuneID         = "invalid edict rune ID"
+	FlawInvalidScript       = "invalid script"
+	FlawOpcode              = "non-push opcode"
+	FlawSupplyOverflow      = "supply overflows 128 bits"
+	FlawTrailingIntegers    = "trailing integers"
+	FlawTruncatedField      = "truncated field"
+	FlawUnrecognizedEvenTag = "unrecognized even tag"
+	FlawUnrecognizedFlag    = "unrecognized flag"
+	FlawVarint              = "invalid varint"
+)
+
+// ErrInvalidRuneID indicates a rune ID that is not BLOCK:TX
+var ErrInvalidRuneID = errors.New("invalid rune ID")
+
+// RuneID identifies a rune by the block height and transaction index of its
+// etching
+type RuneID struct {
+	Block uint64
+	Tx    uint32
+}
+
+// ParseRuneID parses BLOCK:TX
+func ParseRuneID(s string) (RuneID, error) {
+	blockStr, txStr, ok := strings.Cut(s, ":")
+	block, err1 := strconv.ParseUint(blockStr, 10, 64)
+	tx, err2 := strconv.ParseUint(txStr, 10, 32)
+	if !ok || err1 != nil || err2 != nil || block == 0 && tx > 0 {
+		return RuneID{}, fmt.Errorf("%w: %q", ErrInvalidRuneID, s)
+	}
+	return RuneID{Block: block, Tx: uint32(tx)}, nil
+}
+
+// String formats the ID as BLOCK:TX
+func (id RuneID) String() string {
+	return fmt.Sprintf("%d:%d", id.Block, id.Tx)
+}
+
+// MarshalText encodes the ID as BLOCK:TX, so IDs key JSON objects
+func (id RuneID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText decodes BLOCK:TX
+func (id *RuneID) UnmarshalText(text []byte) error {
+	parsed, err := ParseRuneID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// less orders IDs by block, then transaction
+func (id RuneID) less(other RuneID) bool {
+	return id.Block < other.Block || id.Block == other.Block && id.Tx < other.Tx
+}
+
+// Edict transfers Amount of rune ID to output Output. An amount of zero
+// transfers everything left; an output equal to the number of outputs
+// splits the amount between every output but OP_RETURNs.
+type Edict struct {
+	ID     RuneID `json:"id"`
+	Amount U128   `json:"amount"`
+	Output uint32 `json:"output"`
+}
+
+// Terms are the conditions under which anyone can mint a rune
+type Terms struct {
+	// Amount is minted by each mint
+	Amount *U128 `json:"amount,omitempty"`
+	// Cap is the number of mints allowed
+	Cap *U128 `json:"cap,omitempty"`
+	// Minting is open from HeightStart and closes at HeightEnd...
+	HeightStart *uint64 `json:"height_start,omitempty"`
+	HeightEnd   *uint64 `json:"height_end,omitempty"`
+	// ...and from and to these offsets from the etching's height
+	OffsetStart *uint64 `json:"offset_start,omitempty"`
+	OffsetEnd   *uint64 `json:"offset_end,omitempty"`
+}
+
+// Etching creates a rune. Fields left nil take their defaults: no
+// decimals, no premine, a reserved name, no spacers and no symbol.
+type Etching struct {
+	Divisibility *uint8  `json:"divisibility,omitempty"`
+	Premine      *U128   `json:"premine,omitempty"`
+	Rune         *Rune   `json:"rune,omitempty"`
+	Spacers      *uint32 `json:"spacers,omitempty"`
+	Symbol       *rune   `json:"symbol,omitempty"`
+	Terms        *Terms  `json:"terms,omitempty"`
+	Turbo        bool    `json:"turbo,omitempty"`
+}
+
+// Supply returns the most of the rune that can exist, the premine plus
+// every mint, and false when it overflows 128 bits
+func (e *Etching) Supply() (U128, bool) {
+	var premine, cap, amount U128
+	if e.Premine != nil {
+		premine = *e.Premine
+	}
+	if e.Terms != nil && e.Terms.Cap != nil {
+		cap = *e.Terms.Cap
+	}
+	if e.Terms != nil && e.Terms.Amount != nil {
+		amount = *e.Terms.Amount
+	}
+	minted, overflow := cap.Mul(amount)
+	if overflow {
+		return U128{}, false
+	}
+	supply, overflow := premine.Add(minted)
+	return supply, !overflow
+}
+
+// Runestone is a rune protocol message in a transaction's first output
+// whose script starts OP_RETURN OP_13
+type Runestone struct {
+	Edicts  []Edict  `json:"edicts,omitempty"`
+	Etching *Etching `json:"etching,omitempty"`
+	Mint    *RuneID  `json:"mint,omitempty"`
+	// Pointer is the output runes not transferred by edicts go to, by
+	// default the first output that is not an OP_RETURN
+	Pointer *uint32 `json:"pointer,omitempty"`
+	// Cenotaph is the flaw of a malformed runestone, which burns the runes
+	// of its inputs. Of a cenotaph only the etching's name, when it has one,
+	// and the mint are kept: the rune is etched without supply and the mint
+	// is burned.
+	Cenotaph string `json:"cenotaph,omitempty"`
+}
+
+// Encipher returns the OP_RETURN output script carrying r
+func (r *Runestone) Encipher() ([]byte, error) {
+	var payload []byte
+	field := func(tag uint64, value U128) {
+		payload = appendVarint(payload, NewU128(tag))
+		payload = appendVarint(payload, value)
+	}
+	optional := func(tag uint64, value *uint64) {
+		if value != nil {
+			field(tag, NewU128(*value))
+		}
+	}
+
+	if e := r.Etching; e != nil {
+		flags := uint64(1) << flagEtching
+		if e.Terms != nil {
+			flags |= 1 << flagTerms
+		}
+		if e.Turbo {
+			flags |= 1 << flagTurbo
+		}
+		field(tagFlags, NewU128(flags))
+		if e.Rune != nil {
+			field(tagRune, U128(*e.Rune))
+		}
+		if e.Divisibility != nil {
+			if *e.Divisibility > MaxDivisibility {
+				return nil, fmt.Errorf("divisibility %d is above %d", *e.Divisibility, MaxDivisibility)
+			}
+			field(tagDivisibility, NewU128(uint64(*e.Divisibility)))
+		}
+		if e.Spacers != nil {
+			if *e.Spacers > maxSpacers {
+				return nil, fmt.Errorf("invalid spacers %#x", *e.Spacers)
+			}
+			field(tagSpacers, NewU128(uint64(*e.Spacers)))
+		}
+		if e.Symbol != nil {
+			if !utf8.ValidRune(*e.Symbol) {
+				return nil, fmt.Errorf("invalid symbol %U", *e.Symbol)
+			}
+			field(tagSymbol, NewU128(uint64(*e.Symbol)))
+		}
+		if e.Premine != nil {
+			field(tagPremine, *e.Premine)
+		}
+		if t := e.Terms; t != nil {
+			if t.Amount != nil {
+				field(tagAmount, *t.Amount)
+			}
+			if t.Cap != nil {
+				field(tagCap, *t.Cap)
+			}
+			optional(tagHeightStart, t.HeightStart)
+			optional(tagHeightEnd, t.HeightEnd)
+			optional(tagOffsetStart, t.OffsetStart)
+			optional(tagOffsetEnd, t.OffsetEnd)
+		}
+		if _, ok := e.Supply(); !ok {
+			return nil, errors.New(FlawSupplyOverflow)
+		}
+	}
+	if r.Mint != nil {
+		field(tagMint, NewU128(r.Mint.Block))
+		field(tagMint, NewU128(uint64(r.Mint.Tx)))
+	}
+	if r.Pointer != nil {
+		field(tagPointer, NewU128(uint64(*r.Pointer)))
+	}
+	if len(r.Edicts) > 0 {
+		payload = appendVarint(payload, NewU128(tagBody))
+		edicts := append([]Edict(nil), r.Edicts...)
+		sort.SliceStable(edicts, func(i, j int) bool { return edicts[i].ID.less(edicts[j].ID) })
+		var previous RuneID
+		for _, edict := range edicts {
+			block, tx := edict.ID.Block-previous.Block, uint64(edict.ID.Tx)
+			if block == 0 {
+				tx -= uint64(previous.Tx)
+			}
+			payload = appendVarint(payload, NewU128(block))
+			payload = appendVarint(payload, NewU128(tx))
+			payload = appendVarint(payload, edict.Amount)
+			payload = appendVarint(payload, NewU128(uint64(edict.Output)))
+			previous = edict.ID
+		}
+	}
+
+	// Pushed as is: the script builder would turn one-byte pushes into
+	// small-integer opcodes, which make a cenotaph
+	script := []byte{txscript.OP_RETURN, txscript.OP_13}
+	for len(payload) > 0 {
+		chunk := payload[:min(len(payload), maxPushSize)]
+		payload = payload[len(chunk):]
+		switch {
+		case len(chunk) < txscript.OP_PUSHDATA1:
+			script = append(script, byte(len(chunk)))
+		case len(chunk) <= 0xff:
+			script = append(script, txscript.OP_PUSHDATA1, byte(len(chunk)))
+		default:
+			script = append(script, txscript.OP_PUSHDATA2, byte(len(chunk)), byte(len(chunk)>>8))
+		}
+		script = append(script, chunk...)
+	}
+	return script, nil
+}
+
+// Decipher returns the runestone of tx, or nil when it has none
+func Decipher(tx *wire.MsgTx) *Runestone {
+	payload, flaw, found := runestonePayload(tx)
+	if !found {
+		return nil
+	}
+	if flaw != "" {
+		return &Runestone{Cenotaph: flaw}
+	}
+
+	var integers []U128
+	for len(payload) > 0 {
+		n, size, err := readVarint(payload)
+		if err != nil {
+			return &Runestone{Cenotaph: FlawVarint}
+		}
+		integers = append(integers, n)
+		payload = payload[size:]
+	}
+
+	r := &Runestone{}
+	fields := make(map[U128][]U128)
+	var order []U128
+	for i := 0; i < len(integers); i += 2 {
+		tag := integers[i]
+		if tag == NewU128(tagBody) {
+			r.Edicts, flaw = decodeEdicts(integers[i+1:], len(tx.TxOut))
+			break
+		}
+		if i+1 == len(integers) {
+			flaw = FlawTruncatedField
+			break
+		}
+		if _, ok := fields[tag]; !ok {
+			order = append(order, tag)
+		}
+		fields[tag] = append(fields[tag], integers[i+1])
+	}
+
+	var flags U128
+	take(fields, tagFlags, 1, func(v []U128) bool { flags = v[0]; return true })
+	hasFlag := func(bit uint) bool {
+		mask := U128{Lo: 1 << bit}
+		if bit >= 64 {
+			mask = U128{Hi: 1 << (bit - 64)}
+		}
+		set := flags.Hi&mask.Hi != 0 || flags.Lo&mask.Lo != 0
+		flags = U128{Hi: flags.Hi &^ mask.Hi, Lo: flags.Lo &^ mask.Lo}
+		return set
+	}
+
+	if hasFlag(flagEtching) {
+		e := &Etching{}
+		take(fields, tagDivisibility, 1, func(v []U128) bool {
+			if v[0].Hi != 0 || v[0].Lo > MaxDivisibility {
+				return false
+			}
+			d := uint8(v[0].Lo)
+			e.Divisibility = &d
+			return true
+		})
+		take(fields, tagPremine, 1, func(v []U128) bool { e.Premine = &v[0]; return true })
+		take(fields, tagRune, 1, func(v []U128) bool { name := Rune(v[0]); e.Rune = &name; return true })
+		take(fields, tagSpacers, 1, func(v []U128) bool {
+			if v[0].Hi != 0 || v[0].Lo > maxSpacers {
+				return false
+			}
+			s := uint32(v[0].Lo)
+			e.Spacers = &s
+			return true
+		})
+		take(fields, tagSymbol, 1, func(v []U128) bool {
+			if v[0].Hi != 0 || v[0].Lo > utf8.MaxRune || !utf8.ValidRune(rune(v[0].Lo)) {
+				return false
+			}
+			c := rune(v[0].Lo)
+			e.Symbol = &c
+			return true
+		})
+		if hasFlag(flagTerms) {
+			t := &Terms{}
+			take(fields, tagCap, 1, func(v []U128) bool { t.Cap = &v[0]; return true })
+			take(fields, tagAmount, 1, func(v []U128) bool { t.Amount = &v[0]; return true })
+			for tag, dst := range map[uint64]**uint64{
+				tagHeightStart: &t.HeightStart, tagHeightEnd: &t.HeightEnd,
+				tagOffsetStart: &t.OffsetStart, tagOffsetEnd: &t.OffsetEnd,
+			} {
+				take(fields, tag, 1, func(v []U128) bool {
+					if v[0].Hi != 0 {
+						return false
+					}
+					n := v[0].Lo
+					*dst = &n
+					return true
+				})
+			}
+			e.Terms = t
+		}
+		e.Turbo = hasFlag(flagTurbo)
+		r.Etching = e
+	}
+
+	take(fields, tagMint, 2, func(v []U128) bool {
+		if v[0].Hi != 0 || v[1].Hi != 0 || v[1].Lo > 0xffffffff || v[0].Lo == 0 && v[1].Lo > 0 {
+			return false
+		}
+		r.Mint = &RuneID{Block: v[0].Lo, Tx: uint32(v[1].Lo)}
+		return true
+	})
+	take(fields, tagPointer, 1, func(v []U128) bool {
+		if v[0].Hi != 0 || v[0].Lo >= uint64(len(tx.TxOut)) {
+			return false
+		}
+		p := uint32(v[0].Lo)
+		r.Pointer = &p
+		return true
+	})
+
+	if flaw == "" && r.Etching != nil {
+		if _, ok := r.Etching.Supply(); !ok {
+			flaw = FlawSupplyOverflow
+		}
+	}
+	if flaw == "" && hasFlag(flagCenotaph) {
+		flaw = FlawCenotaph
+	}
+	if flaw == "" && !flags.IsZero() {
+		flaw = FlawUnrecognizedFlag
+	}
+	for _, tag := range order {
+		if _, left := fields[tag]; flaw == "" && left && tag.Lo%2 == 0 {
+			flaw = FlawUnrecognizedEvenTag
+		}
+	}
+
+	if flaw != "" {
+		cenotaph := &Runestone{Mint: r.Mint, Cenotaph: flaw}
+		if r.Etching != nil && r.Etching.Rune != nil {
+			cenotaph.Etching = &Etching{Rune: r.Etching.Rune}
+		}
+		return cenotaph
+	}
+	return r
+}
+
+// take passes the first n values of tag to parse and removes them when it
+// accepts them. Values left over, of an even tag, make a cenotaph.
+func take(fields map[U128][]U128, tag uint64, n int, parse func([]U128) bool) {
+	key := NewU128(tag)
+	values := fields[key]
+	if len(values) < n || !parse(values[:n]) {
+		return
+	}
+	if values = values[n:]; len(values) == 0 {
+		delete(fields, key)
+	} else {
+		fields[key] = values
+	}
+}
+
+// decodeEdicts decodes delta-encoded edicts of a transaction with outputs
+// outputs
+func decodeEdicts(integers []U128, outputs int) ([]Edict, string) {
+	var edicts []Edict
+	var id RuneID
+	for ; len(integers) >= 4; integers = integers[4:] {
+		block, tx, amount, output := integers[0], integers[1], integers[2], integers[3]
+		if block.Hi != 0 || tx.Hi != 0 {
+			return edicts, FlawEdictRuneID
+		}
+		next := RuneID{Block: id.Block + block.Lo}
+		if next.Block < id.Block {
+			return edicts, FlawEdictRuneID
+		}
+		t := tx.Lo
+		if block.Lo == 0 {
+			t += uint64(id.Tx)
+		}
+		if t > 0xffffffff || t < tx.Lo || next.Block == 0 && t > 0 {
+			return edicts, FlawEdictRuneID
+		}
+		next.Tx = uint32(t)
+		if output.Hi != 0 || output.Lo > uint64(outputs) {
+			return edicts, FlawEdictOutput
+		}
+		edicts = append(edicts, Edict{ID: next, Amount: amount, Output: uint32(output.Lo)})
+		id = next
+	}
+	if len(integers) > 0 {
+		return edicts, FlawTrailingIntegers
+	}
+	return edicts, ""
+}
+
+// runestonePayload returns the concatenated data pushes after OP_RETURN
+// OP_13 in the first output that starts with them, and the flaw that makes
+// the runestone a cenotaph, if any
+func runestonePayload(tx *wire.MsgTx) ([]byte, string, bool) {
+	for _, out := range tx.TxOut {
+		script := out.PkScript
+		if len(script) < 2 || script[0] != txscript.OP_RETURN || script[1] != txscript.OP_13 {
+			continue
+		}
+		var payload []byte
+		tokens := txscript.MakeScriptTokenizer(0, script[2:])
+		for tokens.Next() {
+			if tokens.Opcode() > txscript.OP_PUSHDATA4 {
+				return nil, FlawOpcode, true
+			}
+			payload = append(payload, tokens.Data()...)
+		}
+		if tokens.Err() != nil {
+			return nil, FlawInvalidScript, true
+		}
+		return payload, "", true
+	}
+	return nil, "", false
+}
@@ -0,0 +1,183 @@
+package runes
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestVarint(t *testing.T) {
+	for _, n := range []U128{{}, NewU128(127), NewU128(128), NewU128(1 << 63), {Hi: 1}, MaxU128} {
+		encoded := appendVarint(nil, n)
+		decoded, size, err := readVarint(encoded)
+		if err != nil || decoded != n || size != len(encoded) {
+			t.Errorf("readVarint(%x) = %s, %d, %v, want %s", encoded, decoded, size, err, n)
+		}
+	}
+	if encoded := appendVarint(nil, MaxU128); len(encoded) != maxVarintLen {
+		t.Errorf("max U128 encodes to %d bytes, want %d", len(encoded), maxVarintLen)
+	}
+	if _, _, err := readVarint([]byte{0x80, 0x80}); err != errVarintTruncated {
+		t.Errorf("Expected a truncated varint, got %v", err)
+	}
+	overlong := append(bytes.Repeat([]byte{0xff}, maxVarintLen-1), 0x04)
+	if _, _, err := readVarint(overlong); err != errVarintOverlong {
+		t.Errorf("Expected an overlong varint, got %v", err)
+	}
+}
+
+func TestRuneNames(t *testing.T) {
+	for name, want := range map[string]U128{
+		"A":                            {},
+		"Z":                            NewU128(25),
+		"AA":                           NewU128(26),
+		"AAA":                          NewU128(702),
+		"AAAAAAAAAAAAAAAAAAAAAAAAAAA":  U128(reserved),
+		"BCGDENLQRQWDSLRUGSNLBTMFIJAV": MaxU128,
+	} {
+		r, err := ParseRune(name)
+		if err != nil || U128(r) != want {
+			t.Errorf("ParseRune(%s) = %s, %v, want %s", name, U128(r), err, want)
+		}
+		if r.String() != name {
+			t.Errorf("Rune(%s).String() = %s", want, r)
+		}
+	}
+	for _, name := range []string{"", "abc", "BCGDENLQRQWDSLRUGSNLBTMFIJAW"} {
+		if _, err := ParseRune(name); err == nil {
+			t.Errorf("ParseRune(%q) succeeded", name)
+		}
+	}
+
+	spaced, err := ParseSpacedRune("UNCOMMON.GOODS")
+	if err != nil || spaced.Spacers != 1<<7 || spaced.String() != "UNCOMMON•GOODS" {
+		t.Errorf("ParseSpacedRune() = %+v %s, %v", spaced, spaced, err)
+	}
+	for _, name := range []string{"•A", "A•", "A••B", "•"} {
+		if _, err := ParseSpacedRune(name); err == nil {
+			t.Errorf("ParseSpacedRune(%q) succeeded", name)
+		}
+	}
+
+	thirteen, _ := ParseRune("AAAAAAAAAAAAA")
+	if MinimumAtHeight(1000, 0) != thirteen || MinimumAtHeight(1000, 998) != thirteen {
+		t.Error("Names shorter than 13 letters unlocked early")
+	}
+	twelve, _ := ParseRune("AAAAAAAAAAAA")
+	if m := MinimumAtHeight(1000, 999+UnlockInterval); m != twelve {
+		t.Errorf("MinimumAtHeight() after one interval = %s, want %s", m, twelve)
+	}
+	if m := MinimumAtHeight(1000, 999+UnlockInterval/2); U128(m).Cmp(U128(twelve)) <= 0 || U128(m).Cmp(U128(thirteen)) >= 0 {
+		t.Errorf("MinimumAtHeight() within an interval = %s", m)
+	}
+	if m := MinimumAtHeight(1000, 999+12*UnlockInterval); m != (Rune{}) {
+		t.Errorf("MinimumAtHeight() at the end = %s, want every name open", m)
+	}
+}
+
+// runestoneTx returns a transaction with the given output scripts
+func runestoneTx(scripts ...[]byte) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{})
+	for _, script := range scripts {
+		tx.AddTxOut(wire.NewTxOut(0, script))
+	}
+	return tx
+}
+
+func TestRunestoneRoundTrip(t *testing.T) {
+	name, _ := ParseRune("EXSCALIBURRUNE")
+	divisibility, spacers, symbol := uint8(8), uint32(1<<2), '⚔'
+	premine, amount, cap := NewU128(1000), NewU128(50), NewU128(21_000)
+	start, offset := uint64(100), uint64(5000)
+	pointer := uint32(1)
+	stone := &Runestone{
+		Etching: &Etching{
+			Divisibility: &divisibility,
+			Premine:      &premine,
+			Rune:         &name,
+			Spacers:      &spacers,
+			Symbol:       &symbol,
+			Terms:        &Terms{Amount: &amount, Cap: &cap, HeightStart: &start, OffsetEnd: &offset},
+			Turbo:        true,
+		},
+		Mint:    &RuneID{Block: 840000, Tx: 3},
+		Pointer: &pointer,
+		Edicts: []Edict{
+			{ID: RuneID{Block: 840000, Tx: 7}, Amount: NewU128(5), Output: 2},
+			{ID: RuneID{}, Amount: NewU128(10), Output: 1},
+			{ID: RuneID{Block: 840000, Tx: 3}, Amount: MaxU128, Output: 3},
+			{ID: RuneID{Block: 840001, Tx: 1}, Output: 1},
+		},
+	}
+	script, err := stone.Encipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Decipher(runestoneTx(script, []byte{txscript.OP_TRUE}, []byte{txscript.OP_TRUE}))
+	if got == nil || got.Cenotaph != "" {
+		t.Fatalf("Decipher() = %+v", got)
+	}
+	// Edicts come back sorted by rune ID
+	want := *stone
+	want.Edicts = []Edict{stone.Edicts[1], stone.Edicts[2], stone.Edicts[0], stone.Edicts[3]}
+	if !reflect.DeepEqual(got, &want) {
+		a, _ := json.Marshal(got)
+		b, _ := json.Marshal(want)
+		t.Errorf("Decipher() =\n%s\nwant\n%s", a, b)
+	}
+
+	// Only the first OP_RETURN OP_13 output counts, wherever it is
+	plain, _ := (&Runestone{Pointer: &pointer}).Encipher()
+	if got := Decipher(runestoneTx([]byte{txscript.OP_TRUE}, plain, script)); got == nil || got.Pointer == nil || got.Etching != nil {
+		t.Errorf("Decipher() of a later runestone = %+v", got)
+	}
+	if got := Decipher(runestoneTx([]byte{txscript.OP_RETURN, txscript.OP_12})); got != nil {
+		t.Errorf("Decipher() without a runestone = %+v", got)
+	}
+}
+
+func TestCenotaphs(t *testing.T) {
+	message := func(integers ...uint64) []byte {
+		var payload []byte
+		for _, n := range integers {
+			payload = appendVarint(payload, NewU128(n))
+		}
+		return append([]byte{txscript.OP_RETURN, txscript.OP_13, byte(len(payload))}, payload...)
+	}
+	name, _ := ParseRune("EXSCALIBURRUNE")
+	payload := appendVarint(appendVarint(nil, NewU128(tagFlags)), U128{Hi: 1 << (flagCenotaph - 64), Lo: 1})
+	payload = appendVarint(appendVarint(payload, NewU128(tagRune)), NewU128(name.Lo))
+	cenotaphFlag := append([]byte{txscript.OP_RETURN, txscript.OP_13, byte(len(payload))}, payload...)
+
+	for flaw, script := range map[string][]byte{
+		FlawOpcode:              {txscript.OP_RETURN, txscript.OP_13, txscript.OP_1},
+		FlawInvalidScript:       {txscript.OP_RETURN, txscript.OP_13, 0x05, 0x00},
+		FlawVarint:              {txscript.OP_RETURN, txscript.OP_13, 0x01, 0x80},
+		FlawTruncatedField:      message(tagPointer),
+		FlawTrailingIntegers:    message(tagBody, 1, 1, 1),
+		FlawEdictOutput:         message(tagBody, 1, 1, 1, 3),
+		FlawEdictRuneID:         message(tagBody, 0, 1, 1, 0),
+		FlawUnrecognizedEvenTag: message(126, 1),
+		FlawUnrecognizedFlag:    message(tagFlags, 1<<flagTerms),
+		FlawCenotaph:            cenotaphFlag,
+	} {
+		got := Decipher(runestoneTx(script, []byte{txscript.OP_TRUE}))
+		if got == nil || got.Cenotaph != flaw {
+			t.Errorf("Decipher(%x) = %+v, want cenotaph %q", script, got, flaw)
+		}
+	}
+
+	// An unknown odd tag is ignored, and a cenotaph keeps the etched name
+	if got := Decipher(runestoneTx(message(127, 1, tagPointer, 0), []byte{txscript.OP_TRUE})); got == nil || got.Cenotaph != "" {
+		t.Errorf("Decipher() with an odd tag = %+v", got)
+	}
+	got := Decipher(runestoneTx(message(tagFlags, 1, tagRune, name.Lo, tagPremine, 5, 126, 0), []byte{txscript.OP_TRUE}))
+	if got.Cenotaph != FlawUnrecognizedEvenTag || got.Etching == nil || *got.Etching.Rune != name || got.Etching.Premine != nil {
+		t.Errorf("Cenotaph etching = %+v", got)
+	}
+}
@@ -0,0 +1,124 @@
+package runes
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// U128 is an unsigned 128-bit integer, the width of rune amounts and names
+type U128 struct {
+	Hi, Lo uint64
+}
+
+// MaxU128 is the largest U128
+var MaxU128 = U128{Hi: ^uint64(0), Lo: ^uint64(0)}
+
+// NewU128 returns n as a U128
+func NewU128(n uint64) U128 {
+	return U128{Lo: n}
+}
+
+// ParseU128 parses a decimal U128
+func ParseU128(s string) (U128, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return U128{}, fmt.Errorf("invalid 128-bit amount %q", s)
+	}
+	var u U128
+	u.Lo = new(big.Int).And(n, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	u.Hi = new(big.Int).Rsh(n, 64).Uint64()
+	return u, nil
+}
+
+// IsZero reports whether u is zero
+func (u U128) IsZero() bool {
+	return u.Hi == 0 && u.Lo == 0
+}
+
+// Cmp returns -1, 0 or 1 as u is less than, equal to or greater than v
+func (u U128) Cmp(v U128) int {
+	switch {
+	case u.Hi < v.Hi || u.Hi == v.Hi && u.Lo < v.Lo:
+		return -1
+	case u == v:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Add returns u+v and whether it overflowed
+func (u U128) Add(v U128) (U128, bool) {
+	lo, carry := bits.Add64(u.Lo, v.Lo, 0)
+	hi, overflow := bits.Add64(u.Hi, v.Hi, carry)
+	return U128{Hi: hi, Lo: lo}, overflow != 0
+}
+
+// Sub returns u-v; callers ensure v is not greater than u
+func (u U128) Sub(v U128) U128 {
+	lo, borrow := bits.Sub64(u.Lo, v.Lo, 0)
+	hi, _ := bits.Sub64(u.Hi, v.Hi, borrow)
+	return U128{Hi: hi, Lo: lo}
+}
+
+// Mul returns u*v and whether it overflowed
+func (u U128) Mul(v U128) (U128, bool) {
+	if u.Hi != 0 && v.Hi != 0 {
+		return U128{}, true
+	}
+	hi, lo := bits.Mul64(u.Lo, v.Lo)
+	c1, o1 := bits.Mul64(u.Hi, v.Lo)
+	c2, o2 := bits.Mul64(u.Lo, v.Hi)
+	hi, carry := bits.Add64(hi, o1, 0)
+	hi, carry2 := bits.Add64(hi, o2, 0)
+	overflow := c1 != 0 || c2 != 0 || carry != 0 || carry2 != 0
+	return U128{Hi: hi, Lo: lo}, overflow
+}
+
+// DivMod returns u/n and u%n; n must not be zero
+func (u U128) DivMod(n uint64) (U128, uint64) {
+	qHi, r := bits.Div64(0, u.Hi, n)
+	qLo, r := bits.Div64(r, u.Lo, n)
+	return U128{Hi: qHi, Lo: qLo}, r
+}
+
+// Min returns the smaller of u and v
+func (u U128) Min(v U128) U128 {
+	if u.Cmp(v) <= 0 {
+		return u
+	}
+	return v
+}
+
+// Big returns u as a big.Int
+func (u U128) Big() *big.Int {
+	n := new(big.Int).SetUint64(u.Hi)
+	n.Lsh(n, 64)
+	return n.Or(n, new(big.Int).SetUint64(u.Lo))
+}
+
+// String formats u in decimal
+func (u U128) String() string {
+	return u.Big().String()
+}
+
+// MarshalJSON encodes u as a decimal string, since JSON numbers lose
+// precision past 2^53
+func (u U128) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + u.String() + `"`), nil
+}
+
+// UnmarshalJSON decodes a decimal string or number
+func (u *U128) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	v, err := ParseU128(s)
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
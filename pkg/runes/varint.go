@@ -0,0 +1,49 @@
+package runes
+
+import "errors"
+
+// Runestone integers are LEB128 varints of at most 19 bytes, 7 bits each
+const maxVarintLen = 19
+
+var (
+	errVarintTruncated = errors.New("truncated varint")
+	errVarintOverlong  = errors.New("varint longer than 128 bits")
+)
+
+// appendVarint appends the LEB128 encoding of n to b
+func appendVarint(b []byte, n U128) []byte {
+	for n.Hi != 0 || n.Lo > 0x7f {
+		b = append(b, byte(n.Lo)&0x7f|0x80)
+		n = U128{Hi: n.Hi >> 7, Lo: n.Lo>>7 | n.Hi<<57}
+	}
+	return append(b, byte(n.Lo))
+}
+
+// readVarint decodes the LEB128 integer at the start of b, returning it and
+// its length
+func readVarint(b []byte) (U128, int, error) {
+	var n U128
+	for i := 0; i < len(b); i++ {
+		if i == maxVarintLen {
+			return U128{}, 0, errVarintOverlong
+		}
+		value := uint64(b[i] & 0x7f)
+		shift := uint(7 * i)
+		// The last byte holds the top two bits of 128
+		if i == maxVarintLen-1 && value>>2 != 0 {
+			return U128{}, 0, errVarintOverlong
+		}
+		if shift < 64 {
+			n.Lo |= value << shift
+			if shift > 57 {
+				n.Hi |= value >> (64 - shift)
+			}
+		} else {
+			n.Hi |= value << (shift - 64)
+		}
+		if b[i]&0x80 == 0 {
+			return n, i + 1, nil
+		}
+	}
+	return U128{}, 0, errVarintTruncated
+}
@@ -0,0 +1,370 @@
+// Package settlement pays treasury distributions on Bitcoin. The engine pays
+// each pending distribution with a Taproot transaction from the settlement
+// key's address, records the signed transaction in the treasury ledger before
+// broadcasting it, rebroadcasts it while no node knows it and records the
+// block once it is buried deep enough. Attempts that fail are recorded on the
+// distribution; after MaxAttempts, or at once for a distribution that can
+// never be paid, it fails until an operator retries it. The transaction of a
+// retried distribution spends the inputs of the one it replaces, so the
+// distribution is paid once even if the old one was relayed after all.
+package settlement
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Defaults for a zero Config field
+const (
+	DefaultConfirmations = 6
+	DefaultMaxAttempts   = 5
+	DefaultFeeTarget     = 6
+	DefaultFeeMode       = fees.Conservative
+)
+
+// ErrUnpayable indicates a distribution no transaction can pay, such as one
+// to an invalid address or below the dust limit
+var ErrUnpayable = errors.New("distribution cannot be paid")
+
+// Chain is the Bitcoin backend settlements are paid through, typically a
+// *wallet.EsploraSource. Broadcast fails with wallet.ErrTxRejected for a
+// transaction the node refused; any other error is an outage.
+type Chain interface {
+	Unspent(ctx context.Context, address string) ([]wallet.UTXO, error)
+	Broadcast(ctx context.Context, rawTx string) (string, error)
+	TxStatus(ctx context.Context, txid string) (*wallet.TxStatus, error)
+	TipHeight(ctx context.Context) (int32, error)
+	FeeEstimates(ctx context.Context) (wallet.FeeEstimates, error)
+}
+
+// FeeEstimator estimates fee rates by confirmation target and mode, like a
+// *fees.Estimator
+type FeeEstimator interface {
+	Estimate(ctx context.Context, target int, mode fees.Mode) (fees.Estimate, error)
+}
+
+// Config configures an Engine
+type Config struct {
+	// Key spends the settlement address, the key's P2TR address
+	Key *bitcoin.TaprootKey
+	Net *chaincfg.Params
+	// Chain lists the key's outputs and relays transactions
+	Chain Chain
+	// Confirmations is the depth that settles a distribution,
+	// DefaultConfirmations when zero
+	Confirmations int32
+	// MaxAttempts is how many failed attempts fail a distribution,
+	// DefaultMaxAttempts when zero
+	MaxAttempts int
+	// FeeTarget is the number of blocks to confirm within,
+	// DefaultFeeTarget when zero
+	FeeTarget int
+	// Fees estimates the fee rate for FeeTarget; nil uses the Chain's
+	// estimates
+	Fees FeeEstimator
+	// FeeMode is the mode of the Fees estimate, DefaultFeeMode when empty
+	FeeMode fees.Mode
+	// SatsPerCoin is how many satoshis one EXS is paid with; zero pays one
+	// satoshi per exs-satoshi
+	SatsPerCoin int64
+}
+
+// Engine settles the distributions of a treasury
+type Engine struct {
+	treasury *economy.Treasury
+	config   Config
+	address  string
+	pkScript []byte
+}
+
+// New creates an engine paying the distributions of treasury with config
+func New(treasury *economy.Treasury, config Config) (*Engine, error) {
+	if config.Key == nil || config.Net == nil || config.Chain == nil {
+		return nil, errors.New("settlement needs a key, a network and a chain")
+	}
+	if config.Confirmations <= 0 {
+		config.Confirmations = DefaultConfirmations
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.FeeTarget <= 0 {
+		config.FeeTarget = DefaultFeeTarget
+	}
+	if config.FeeMode == "" {
+		config.FeeMode = DefaultFeeMode
+	}
+	if config.SatsPerCoin <= 0 {
+		config.SatsPerCoin = int64(economy.Coin)
+	}
+	pkScript, err := config.Key.PkScript()
+	if err != nil {
+		return nil, err
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, config.Net)
+	if err != nil || len(addrs) != 1 {
+		return nil, fmt.Errorf("settlement key has no address: %v", err)
+	}
+	return &Engine{treasury: treasury, config: config, address: addrs[0].EncodeAddress(), pkScript: pkScript}, nil
+}
+
+// Address returns the address settlements are paid from
+func (e *Engine) Address() string {
+	return e.address
+}
+
+// Run settles pending distributions every interval until ctx is done
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Step(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Settlement", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step makes one pass over the pending distributions: it pays those without
+// a transaction and follows those with one. It returns the first error
+// reaching the chain; such outages are not counted as attempts.
+func (e *Engine) Step(ctx context.Context) error {
+	pending := e.treasury.PendingSettlements()
+	if len(pending) == 0 {
+		return nil
+	}
+	tip, err := e.config.Chain.TipHeight(ctx)
+	if err != nil {
+		return fmt.Errorf("chain tip: %w", err)
+	}
+
+	// Outputs spent by transactions not yet confirmed are not spent again,
+	// nor those a retried distribution must spend again
+	spent := make(map[wire.OutPoint]bool)
+	for _, d := range pending {
+		for _, raw := range []string{d.RawTx, d.ReplacedTx} {
+			if tx, err := decodeTx(raw); err == nil {
+				for _, in := range tx.TxIn {
+					spent[in.PreviousOutPoint] = true
+				}
+			}
+		}
+	}
+
+	for _, d := range pending {
+		switch d.Settlement {
+		case economy.SettlementPending:
+			err = e.pay(ctx, d, spent)
+		case economy.SettlementBroadcast:
+			err = e.follow(ctx, d, tip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// feeRate returns the fee rate to confirm within FeeTarget blocks, from
+// Fees or else the Chain
+func (e *Engine) feeRate(ctx context.Context) (float64, error) {
+	if e.config.Fees != nil {
+		estimate, err := e.config.Fees.Estimate(ctx, e.config.FeeTarget, e.config.FeeMode)
+		return estimate.Rate, err
+	}
+	estimates, err := e.config.Chain.FeeEstimates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return estimates.Rate(e.config.FeeTarget)
+}
+
+// pay builds, records and broadcasts the transaction paying d
+func (e *Engine) pay(ctx context.Context, d economy.Distribution, spent map[wire.OutPoint]bool) error {
+	out, err := e.output(d)
+	if err != nil {
+		return e.fail(d, err, true)
+	}
+	utxos, err := e.config.Chain.Unspent(ctx, e.address)
+	if err != nil {
+		return fmt.Errorf("settlement outputs: %w", err)
+	}
+	rate, err := e.feeRate(ctx)
+	if err != nil {
+		return fmt.Errorf("fee estimates: %w", err)
+	}
+
+	// A retry spends the replaced transaction's inputs again, so the two
+	// conflict
+	replaced := make(map[wire.OutPoint]bool)
+	if tx, err := decodeTx(d.ReplacedTx); err == nil {
+		for _, in := range tx.TxIn {
+			replaced[in.PreviousOutPoint] = true
+		}
+	}
+	var required, inputs []bitcoin.UTXO
+	for _, u := range utxos {
+		hash, err := chainhash.NewHashFromStr(u.TxID)
+		if err != nil {
+			continue
+		}
+		outPoint := wire.OutPoint{Hash: *hash, Index: u.Vout}
+		utxo := bitcoin.UTXO{OutPoint: outPoint, Value: u.Value, PkScript: e.pkScript}
+		if replaced[outPoint] {
+			required = append(required, utxo)
+		} else if !spent[outPoint] {
+			inputs = append(inputs, utxo)
+		}
+	}
+	if len(required) < len(replaced) {
+		// An input is gone: the replaced transaction may have been relayed
+		if resumed, err := e.resume(ctx, d); resumed || err != nil {
+			return err
+		}
+	}
+	spend, err := bitcoin.BuildTaprootSpendWith(required, inputs, []*wire.TxOut{out}, e.pkScript, max(int64(math.Ceil(rate)), 1))
+	if err != nil {
+		return e.fail(d, err, false)
+	}
+	if err := spend.Sign([]*bitcoin.TaprootKey{e.config.Key}); err != nil {
+		return e.fail(d, err, true)
+	}
+	raw, err := spend.Hex()
+	if err != nil {
+		return e.fail(d, err, true)
+	}
+
+	// Recorded first, so a crash before the broadcast resends this
+	// transaction rather than paying d again
+	recorded, err := e.treasury.RecordSettlementTx(d.ID, spend.Tx.TxHash().String(), raw)
+	if err != nil {
+		return err
+	}
+	for _, in := range spend.Tx.TxIn {
+		spent[in.PreviousOutPoint] = true
+	}
+	slog.Info("Settling distribution", "distribution", d.ID, "tx", recorded.TxHash, "sats", out.Value, "fee", spend.Fee)
+	return e.broadcast(ctx, *recorded)
+}
+
+// resume records d's replaced transaction as its settlement again when the
+// chain knows it, reporting whether it did
+func (e *Engine) resume(ctx context.Context, d economy.Distribution) (bool, error) {
+	tx, err := decodeTx(d.ReplacedTx)
+	if err != nil {
+		return false, nil
+	}
+	txHash := tx.TxHash().String()
+	if _, err := e.config.Chain.TxStatus(ctx, txHash); errors.Is(err, wallet.ErrTxNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("settlement %s: %w", txHash, err)
+	}
+	if _, err := e.treasury.RecordSettlementTx(d.ID, txHash, d.ReplacedTx); err != nil {
+		return false, err
+	}
+	slog.Info("Replaced settlement was relayed", "distribution", d.ID, "tx", txHash)
+	return true, nil
+}
+
+// follow rebroadcasts d's transaction while no node knows it and records it
+// settled once it has enough confirmations at tip
+func (e *Engine) follow(ctx context.Context, d economy.Distribution, tip int32) error {
+	status, err := e.config.Chain.TxStatus(ctx, d.TxHash)
+	if errors.Is(err, wallet.ErrTxNotFound) {
+		return e.broadcast(ctx, d)
+	}
+	if err != nil {
+		return fmt.Errorf("settlement %s: %w", d.TxHash, err)
+	}
+	if !status.Confirmed || tip-status.BlockHeight+1 < e.config.Confirmations {
+		return nil
+	}
+	if _, err := e.treasury.RecordSettlementConfirmed(d.ID, status.BlockHash, uint32(status.BlockHeight)); err != nil {
+		return err
+	}
+	slog.Info("Distribution settled", "distribution", d.ID, "tx", d.TxHash, "height", status.BlockHeight)
+	return nil
+}
+
+// broadcast relays d's recorded transaction. Only a rejection is an
+// attempt: after a timeout the node may have the transaction, and follow
+// finds or rebroadcasts it.
+func (e *Engine) broadcast(ctx context.Context, d economy.Distribution) error {
+	_, err := e.config.Chain.Broadcast(ctx, d.RawTx)
+	if errors.Is(err, wallet.ErrTxRejected) {
+		return e.fail(d, err, false)
+	}
+	if err != nil {
+		return fmt.Errorf("broadcast %s: %w", d.TxHash, err)
+	}
+	return nil
+}
+
+// fail records a failed attempt to pay d, failing it when final or out of
+// attempts
+func (e *Engine) fail(d economy.Distribution, err error, final bool) error {
+	final = final || d.Attempts+1 >= e.config.MaxAttempts
+	if _, recordErr := e.treasury.RecordSettlementError(d.ID, err, final); recordErr != nil {
+		return recordErr
+	}
+	slog.Warn("Settlement attempt failed", "distribution", d.ID, "attempt", d.Attempts+1, "final", final, "err", err)
+	return nil
+}
+
+// output returns the output paying d
+func (e *Engine) output(d economy.Distribution) (*wire.TxOut, error) {
+	addr, err := btcutil.DecodeAddress(d.Recipient, e.config.Net)
+	if err != nil || !addr.IsForNet(e.config.Net) {
+		return nil, fmt.Errorf("%w: invalid %s address %q", ErrUnpayable, e.config.Net.Name, d.Recipient)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnpayable, err)
+	}
+	sats := e.sats(d.Amount)
+	if sats < bitcoin.DustLimit {
+		return nil, fmt.Errorf("%w: %d sats is below the dust limit", ErrUnpayable, sats)
+	}
+	return wire.NewTxOut(sats, pkScript), nil
+}
+
+// sats converts amount to satoshis at SatsPerCoin, rounding down
+func (e *Engine) sats(amount economy.Amount) int64 {
+	n := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(e.config.SatsPerCoin))
+	return n.Quo(n, big.NewInt(int64(economy.Coin))).Int64()
+}
+
+// decodeTx decodes a hex raw transaction
+func decodeTx(raw string) (*wire.MsgTx, error) {
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	tx := new(wire.MsgTx)
+	if err := tx.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
@@ -0,0 +1,329 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/wallet"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// fakeChain holds one funding output and mines broadcast transactions when
+// told to
+type fakeChain struct {
+	utxos     []wallet.UTXO
+	tip       int32
+	down      bool
+	reject    bool
+	timeout   bool // the node takes broadcasts but the reply is lost
+	mempool   map[string]string
+	mined     map[string]int32
+	broadcast int
+}
+
+func (c *fakeChain) Unspent(ctx context.Context, address string) ([]wallet.UTXO, error) {
+	if c.down {
+		return nil, errors.New("connection refused")
+	}
+	return c.utxos, nil
+}
+
+func (c *fakeChain) Broadcast(ctx context.Context, rawTx string) (string, error) {
+	c.broadcast++
+	if c.reject {
+		return "", fmt.Errorf("%w: 400 Bad Request: bad-txns-inputs-missingorspent", wallet.ErrTxRejected)
+	}
+	tx, err := decodeTx(rawTx)
+	if err != nil {
+		return "", err
+	}
+	txid := tx.TxHash().String()
+	c.mempool[txid] = rawTx
+	if c.timeout {
+		return "", context.DeadlineExceeded
+	}
+	return txid, nil
+}
+
+func (c *fakeChain) TxStatus(ctx context.Context, txid string) (*wallet.TxStatus, error) {
+	if c.down {
+		return nil, errors.New("connection refused")
+	}
+	if height, ok := c.mined[txid]; ok {
+		return &wallet.TxStatus{Confirmed: true, BlockHeight: height, BlockHash: "00ff"}, nil
+	}
+	if _, ok := c.mempool[txid]; ok {
+		return &wallet.TxStatus{}, nil
+	}
+	return nil, wallet.ErrTxNotFound
+}
+
+func (c *fakeChain) TipHeight(ctx context.Context) (int32, error) {
+	if c.down {
+		return 0, errors.New("connection refused")
+	}
+	return c.tip, nil
+}
+
+func (c *fakeChain) FeeEstimates(ctx context.Context) (wallet.FeeEstimates, error) {
+	return wallet.FeeEstimates{1: 20, 6: 5}, nil
+}
+
+func TestSettlement(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	priv, _ := btcec.NewPrivateKey()
+	key := &bitcoin.TaprootKey{PrivKey: priv}
+	recipientKey, _ := btcec.NewPrivateKey()
+	recipient, _ := btcutil.NewAddressTaproot(schnorr.SerializePubKey(recipientKey.PubKey()), net)
+
+	treasury, err := economy.OpenTreasury(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer treasury.Close()
+	treasury.ProcessForge("bcrt1pminer")
+	treasury.EnableSettlement()
+
+	chain := &fakeChain{
+		tip:     800000,
+		mempool: make(map[string]string),
+		mined:   make(map[string]int32),
+		utxos: []wallet.UTXO{{
+			TxID:  "1111111111111111111111111111111111111111111111111111111111111111",
+			Value: 10_000_000,
+		}},
+	}
+	engine, err := New(treasury, Config{Key: key, Net: net, Chain: chain, MaxAttempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	paid, _ := treasury.Distribute(economy.Coin/100, recipient.EncodeAddress(), "grant")
+	dust, _ := treasury.Distribute(100, recipient.EncodeAddress(), "tip")
+	invalid, _ := treasury.Distribute(economy.Coin/100, "bc1qnotregtest", "grant")
+
+	// An outage is not an attempt
+	chain.down = true
+	if err := engine.Step(ctx); err == nil {
+		t.Error("Expected the outage to be reported")
+	}
+	if d, _ := treasury.GetDistribution(paid.ID); d.Attempts != 0 || d.Settlement != economy.SettlementPending {
+		t.Errorf("Distribution after an outage = %+v", d)
+	}
+	chain.down = false
+
+	if err := engine.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := treasury.GetDistribution(paid.ID)
+	if d.Settlement != economy.SettlementBroadcast || chain.mempool[d.TxHash] != d.RawTx {
+		t.Fatalf("Paid distribution = %+v, want its transaction broadcast", d)
+	}
+	tx, _ := decodeTx(d.RawTx)
+	if tx.TxOut[0].Value != 1_000_000 || len(tx.TxIn) != 1 {
+		t.Errorf("Settlement transaction pays %d sats from %d inputs", tx.TxOut[0].Value, len(tx.TxIn))
+	}
+	for _, id := range []int{dust.ID, invalid.ID} {
+		if d, _ := treasury.GetDistribution(id); d.Settlement != economy.SettlementFailed || d.LastError == "" {
+			t.Errorf("Unpayable distribution = %+v, want failed", d)
+		}
+	}
+
+	// Dropped from the mempool, it is rebroadcast unchanged
+	delete(chain.mempool, d.TxHash)
+	if err := engine.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if chain.mempool[d.TxHash] != d.RawTx || chain.broadcast != 2 {
+		t.Errorf("Transaction not rebroadcast, %d broadcasts", chain.broadcast)
+	}
+
+	// Settled once buried deep enough
+	chain.mined[d.TxHash] = 800001
+	chain.tip = 800005
+	engine.Step(ctx)
+	if d, _ := treasury.GetDistribution(paid.ID); d.Settlement != economy.SettlementBroadcast {
+		t.Errorf("Settled at 5 confirmations: %+v", d)
+	}
+	chain.tip = 800006
+	engine.Step(ctx)
+	if d, _ := treasury.GetDistribution(paid.ID); d.Settlement != economy.SettlementConfirmed || d.BlockHeight != 800001 {
+		t.Errorf("Distribution at 6 confirmations = %+v", d)
+	}
+
+	// Rejected broadcasts fail a distribution after MaxAttempts, and a
+	// retry builds a new transaction
+	chain.utxos[0].TxID = tx.TxHash().String()
+	chain.utxos[0].Vout = 1
+	retry, _ := treasury.Distribute(economy.Coin/100, recipient.EncodeAddress(), "grant")
+	chain.reject = true
+	engine.Step(ctx)
+	engine.Step(ctx)
+	failed, _ := treasury.GetDistribution(retry.ID)
+	if failed.Settlement != economy.SettlementFailed || failed.Attempts != 2 {
+		t.Fatalf("Rejected distribution = %+v", failed)
+	}
+	chain.reject = false
+	chain.utxos[0].TxID = "2222222222222222222222222222222222222222222222222222222222222222"
+	chain.utxos[0].Vout = 0
+	if _, err := treasury.RetrySettlement(retry.ID); err != nil {
+		t.Fatal(err)
+	}
+	engine.Step(ctx)
+	if d, _ := treasury.GetDistribution(retry.ID); d.Settlement != economy.SettlementBroadcast || d.TxHash == failed.TxHash {
+		t.Errorf("Retried distribution = %+v, want a new transaction", d)
+	}
+}
+
+func TestSettlementRetryAfterTimeout(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	priv, _ := btcec.NewPrivateKey()
+	recipientKey, _ := btcec.NewPrivateKey()
+	recipient, _ := btcutil.NewAddressTaproot(schnorr.SerializePubKey(recipientKey.PubKey()), net)
+
+	treasury, err := economy.OpenTreasury(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer treasury.Close()
+	treasury.ProcessForge("bcrt1pminer")
+	treasury.EnableSettlement()
+
+	funding := wallet.UTXO{TxID: "1111111111111111111111111111111111111111111111111111111111111111", Value: 2_000_000}
+	chain := &fakeChain{
+		tip:     800000,
+		mempool: make(map[string]string),
+		mined:   make(map[string]int32),
+		utxos:   []wallet.UTXO{funding},
+	}
+	engine, err := New(treasury, Config{Key: &bitcoin.TaprootKey{PrivKey: priv}, Net: net, Chain: chain, MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	dist, _ := treasury.Distribute(economy.Coin/100, recipient.EncodeAddress(), "grant")
+
+	// A timed-out broadcast is an outage, not an attempt, and the node that
+	// took the transaction is not sent it again
+	chain.timeout = true
+	if err := engine.Step(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the timeout to be reported, got %v", err)
+	}
+	first, _ := treasury.GetDistribution(dist.ID)
+	if first.Settlement != economy.SettlementBroadcast || first.Attempts != 0 {
+		t.Fatalf("Distribution after a timeout = %+v", first)
+	}
+	chain.timeout = false
+	if err := engine.Step(ctx); err != nil || chain.broadcast != 1 {
+		t.Fatalf("Step() = %v after %d broadcasts, want the relayed transaction followed", err, chain.broadcast)
+	}
+
+	// Failed and retried by an operator while the old transaction is still
+	// out there: the retry does not pay it again
+	chain.reject = true
+	delete(chain.mempool, first.TxHash)
+	engine.Step(ctx)
+	if d, _ := treasury.GetDistribution(dist.ID); d.Settlement != economy.SettlementFailed {
+		t.Fatalf("Rejected distribution = %+v", d)
+	}
+	chain.reject = false
+	chain.mempool[first.TxHash] = first.RawTx
+	chain.utxos = []wallet.UTXO{{TxID: "2222222222222222222222222222222222222222222222222222222222222222", Value: 5_000_000}}
+	if _, err := treasury.RetrySettlement(dist.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := treasury.GetDistribution(dist.ID); d.Settlement != economy.SettlementBroadcast || d.TxHash != first.TxHash {
+		t.Errorf("Retried distribution = %+v, want the relayed transaction %s", d, first.TxHash)
+	}
+
+	// When the old transaction is unknown, the new one spends its input
+	// again ahead of a larger output, so at most one of them confirms
+	chain.reject = true
+	delete(chain.mempool, first.TxHash)
+	engine.Step(ctx)
+	chain.reject = false
+	chain.utxos = append(chain.utxos, funding)
+	if _, err := treasury.RetrySettlement(dist.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	retried, _ := treasury.GetDistribution(dist.ID)
+	if retried.Settlement != economy.SettlementBroadcast {
+		t.Fatalf("Retried distribution = %+v, want a transaction", retried)
+	}
+	old, _ := decodeTx(first.RawTx)
+	tx, _ := decodeTx(retried.RawTx)
+	if tx.TxIn[0].PreviousOutPoint != old.TxIn[0].PreviousOutPoint {
+		t.Errorf("Retry spends %v, not the replaced transaction's input %v", tx.TxIn[0].PreviousOutPoint, old.TxIn[0].PreviousOutPoint)
+	}
+}
+
+// stubFees estimates a fixed rate and records what it was asked
+type stubFees struct {
+	target int
+	mode   fees.Mode
+}
+
+func (f *stubFees) Estimate(ctx context.Context, target int, mode fees.Mode) (fees.Estimate, error) {
+	f.target, f.mode = target, mode
+	return fees.Estimate{Rate: 40.2, Target: target, Mode: mode}, nil
+}
+
+func TestSettlementFeeEstimator(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	priv, _ := btcec.NewPrivateKey()
+	recipientKey, _ := btcec.NewPrivateKey()
+	recipient, _ := btcutil.NewAddressTaproot(schnorr.SerializePubKey(recipientKey.PubKey()), net)
+
+	treasury, err := economy.OpenTreasury(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer treasury.Close()
+	treasury.ProcessForge("bcrt1pminer")
+	treasury.EnableSettlement()
+
+	chain := &fakeChain{
+		tip:     800000,
+		mempool: make(map[string]string),
+		mined:   make(map[string]int32),
+		utxos:   []wallet.UTXO{{TxID: "1111111111111111111111111111111111111111111111111111111111111111", Value: 10_000_000}},
+	}
+	estimator := &stubFees{}
+	engine, err := New(treasury, Config{Key: &bitcoin.TaprootKey{PrivKey: priv}, Net: net, Chain: chain, FeeTarget: 2, Fees: estimator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid, _ := treasury.Distribute(economy.Coin/100, recipient.EncodeAddress(), "grant")
+	if err := engine.Step(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if estimator.target != 2 || estimator.mode != fees.Conservative {
+		t.Errorf("Estimated for %d blocks, %s; want 2, conservative", estimator.target, estimator.mode)
+	}
+
+	d, _ := treasury.GetDistribution(paid.ID)
+	tx, _ := decodeTx(d.RawTx)
+	fee := int64(10_000_000)
+	for _, out := range tx.TxOut {
+		fee -= out.Value
+	}
+	// One key-path input and two Taproot outputs are 154 vB, at 41 sat/vB
+	if fee < 154*41 || fee > 160*41 {
+		t.Errorf("Settlement fee = %d sats, want the estimator's rate rounded up", fee)
+	}
+}
@@ -0,0 +1,352 @@
+// Package tlsconfig serves the HTTP APIs over TLS and makes the calls
+// between services over it. A service with a certificate serves HTTPS,
+// picking up a rotated certificate without a restart; with a client CA it
+// also requires callers to present a certificate that CA signed (mutual
+// TLS), and it presents its own certificate on outgoing calls. In
+// production plaintext HTTP is refused both ways.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for
+// rotation
+const DefaultReloadInterval = 30 * time.Second
+
+// Environment variables read by FromEnv
+const (
+	EnvCertFile       = "TLS_CERT_FILE"
+	EnvKeyFile        = "TLS_KEY_FILE"
+	EnvClientCAFile   = "TLS_CLIENT_CA_FILE"
+	EnvCAFile         = "TLS_CA_FILE"
+	EnvReloadInterval = "TLS_RELOAD_INTERVAL"
+	EnvHTTPSOnly      = "TLS_HTTPS_ONLY"
+)
+
+var (
+	// ErrPlaintext indicates plaintext HTTP served or called in HTTPS-only
+	// mode
+	ErrPlaintext = errors.New("plaintext HTTP is not allowed in HTTPS-only mode")
+	// ErrInvalidConfig indicates an incomplete or unreadable TLS setup
+	ErrInvalidConfig = errors.New("invalid TLS configuration")
+)
+
+// Config is a service's TLS setup
+type Config struct {
+	// CertFile and KeyFile hold the PEM certificate chain and private key
+	// served, and presented on outgoing calls. Both or neither are set.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the PEM CAs whose certificates clients must
+	// present; empty accepts any client
+	ClientCAFile string
+	// CAFile holds PEM CAs trusted on outgoing calls besides the system
+	// roots, for services with certificates from a private CA
+	CAFile string
+	// ReloadInterval is how often CertFile and KeyFile are checked for a
+	// rotated certificate, DefaultReloadInterval when 0 and never when
+	// negative
+	ReloadInterval time.Duration
+	// HTTPSOnly refuses to serve or call plaintext HTTP
+	HTTPSOnly bool
+}
+
+// FromEnv returns the Config set by TLS_CERT_FILE, TLS_KEY_FILE,
+// TLS_CLIENT_CA_FILE, TLS_CA_FILE and TLS_RELOAD_INTERVAL. HTTPS-only mode
+// is on when TLS_HTTPS_ONLY is true, or unset with ENV=production.
+func FromEnv() (Config, error) {
+	c := Config{
+		CertFile:     os.Getenv(EnvCertFile),
+		KeyFile:      os.Getenv(EnvKeyFile),
+		ClientCAFile: os.Getenv(EnvClientCAFile),
+		CAFile:       os.Getenv(EnvCAFile),
+		HTTPSOnly:    os.Getenv("ENV") == "production",
+	}
+	if value := os.Getenv(EnvReloadInterval); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %q", EnvReloadInterval, value)
+		}
+		c.ReloadInterval = d
+	}
+	if value := os.Getenv(EnvHTTPSOnly); value != "" {
+		httpsOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %q", EnvHTTPSOnly, value)
+		}
+		c.HTTPSOnly = httpsOnly
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// ServerFromEnv is FromEnv for a service, which needs a certificate in
+// HTTPS-only mode
+func ServerFromEnv() (Config, error) {
+	c, err := FromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+	if err := c.validateServer(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// Validate checks that the files are set consistently
+func (c Config) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("%w: %s and %s must be set together", ErrInvalidConfig, EnvCertFile, EnvKeyFile)
+	}
+	if c.ClientCAFile != "" && c.CertFile == "" {
+		return fmt.Errorf("%w: a client CA needs a server certificate", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// validateServer checks that HTTPS-only mode has a certificate to serve
+func (c Config) validateServer() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if c.HTTPSOnly && !c.Enabled() {
+		return fmt.Errorf("%w: set %s and %s", ErrPlaintext, EnvCertFile, EnvKeyFile)
+	}
+	return nil
+}
+
+// Enabled reports whether the service serves HTTPS
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// Mutual reports whether clients must present a certificate
+func (c Config) Mutual() bool {
+	return c.ClientCAFile != ""
+}
+
+// Server returns the TLS configuration to serve with, or nil when TLS is
+// not enabled, which is ErrPlaintext in HTTPS-only mode
+func (c Config) Server() (*tls.Config, error) {
+	if err := c.validateServer(); err != nil {
+		return nil, err
+	}
+	if !c.Enabled() {
+		return nil, nil
+	}
+	cert, err := c.certificate()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.get()
+		},
+	}
+	if c.Mutual() {
+		pool, err := loadPool(c.ClientCAFile, false)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// Client returns the TLS configuration for outgoing calls, trusting CAFile
+// and presenting the service's certificate when it has one
+func (c Config) Client() (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pool, err := loadPool(c.CAFile, true)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if c.Enabled() {
+		cert, err := c.certificate()
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.get()
+		}
+	}
+	return config, nil
+}
+
+// HTTPClient returns a client for calls to other services with timeout,
+// refusing http:// URLs in HTTPS-only mode
+func (c Config) HTTPClient(timeout time.Duration) (*http.Client, error) {
+	config, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	if c.HTTPSOnly {
+		client.Transport = httpsOnly{transport}
+	}
+	return client, nil
+}
+
+// httpsOnly refuses requests that are not made over HTTPS
+type httpsOnly struct {
+	next http.RoundTripper
+}
+
+func (t httpsOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrPlaintext, req.URL.Redacted())
+	}
+	return t.next.RoundTrip(req)
+}
+
+// ListenAndServe serves server over HTTPS when TLS is enabled, adding a
+// Strict-Transport-Security header to every response, and over plaintext
+// HTTP otherwise. It returns http.ErrServerClosed after Shutdown.
+func ListenAndServe(server *http.Server, c Config) error {
+	config, err := c.Server()
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = config
+	server.Handler = HSTS(server.Handler)
+	return server.ListenAndServeTLS("", "")
+}
+
+// HSTS tells browsers to reach the server over HTTPS only for a year
+func HSTS(next http.Handler) http.Handler {
+	if next == nil {
+		next = http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Describe summarizes the setup for startup logs
+func (c Config) Describe() string {
+	switch {
+	case c.Mutual():
+		return fmt.Sprintf("HTTPS with client certificates from %s (%s)", c.ClientCAFile, c.CertFile)
+	case c.Enabled():
+		return fmt.Sprintf("HTTPS (%s)", c.CertFile)
+	default:
+		return fmt.Sprintf("plaintext HTTP: set %s and %s to serve HTTPS", EnvCertFile, EnvKeyFile)
+	}
+}
+
+// certificate is a key pair reloaded when its files change. The files are
+// checked during handshakes at most every interval, and a rotation that
+// fails to load keeps the previous pair.
+type certificate struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func (c Config) certificate() (*certificate, error) {
+	interval := c.ReloadInterval
+	if interval == 0 {
+		interval = DefaultReloadInterval
+	}
+	cert := &certificate{certFile: c.CertFile, keyFile: c.KeyFile, interval: interval}
+	modTime, err := cert.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := cert.load(modTime); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// get returns the current pair, reloading it if the files changed
+func (c *certificate) get() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.interval < 0 || time.Since(c.checked) < c.interval {
+		return c.cert, nil
+	}
+	c.checked = time.Now()
+	if modTime, err := c.latestModTime(); err == nil && !modTime.Equal(c.modTime) {
+		// Keep serving the old pair if the new one is half written
+		c.load(modTime)
+	}
+	return c.cert, nil
+}
+
+// latestModTime returns when either file last changed
+func (c *certificate) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// load reads the pair written at modTime
+func (c *certificate) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	c.cert, c.modTime, c.checked = &cert, modTime, time.Now()
+	return nil
+}
+
+// loadPool reads the PEM certificates in name, added to the system roots
+// when system is set
+func loadPool(name string, system bool) (*x509.CertPool, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	pool := x509.NewCertPool()
+	if system {
+		if roots, err := x509.SystemCertPool(); err == nil {
+			pool = roots
+		}
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: no certificates in %s", ErrInvalidConfig, name)
+	}
+	return pool, nil
+}
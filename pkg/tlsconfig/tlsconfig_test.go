@@ -0,0 +1,297 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for localhost usable by servers and clients,
+// returning the certificate and key files
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve starts an HTTPS server for c answering with the client
+// certificate's common name, returning its URL
+func serve(t *testing.T, c Config) string {
+	t.Helper()
+	config, err := c.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		TLSConfig: config,
+		Handler: HSTS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.TLS.PeerCertificates) > 0 {
+				io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+			}
+		})),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	go server.ServeTLS(lis, "", "")
+	t.Cleanup(func() { server.Close() })
+	return "https://" + lis.Addr().String()
+}
+
+func get(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if resp.Header.Get("Strict-Transport-Security") == "" {
+		return "", errors.New("no Strict-Transport-Security header")
+	}
+	return string(body), err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "exs services")
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, ca.pem)
+
+	certFile, keyFile := ca.issue(t, dir, "treasury", 2)
+	url := serve(t, Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+
+	minerCert, minerKey := ca.issue(t, dir, "miner", 3)
+	miner, err := Config{CertFile: minerCert, KeyFile: minerKey, CAFile: caFile}.HTTPClient(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := get(miner, url); err != nil || name != "miner" {
+		t.Errorf("GET with a client certificate = %q, %v, want miner", name, err)
+	}
+
+	// Callers without a certificate, or with one from another CA, are refused
+	anonymous, _ := Config{CAFile: caFile}.HTTPClient(5 * time.Second)
+	if _, err := get(anonymous, url); err == nil {
+		t.Error("GET without a client certificate succeeded")
+	}
+	otherCert, otherKey := newTestCA(t, "other").issue(t, dir, "intruder", 4)
+	intruder, _ := Config{CertFile: otherCert, KeyFile: otherKey, CAFile: caFile}.HTTPClient(5 * time.Second)
+	if _, err := get(intruder, url); err == nil {
+		t.Error("GET with a certificate from another CA succeeded")
+	}
+
+	// Without CAFile the private CA is not trusted
+	untrusting, _ := Config{CertFile: minerCert, KeyFile: minerKey}.HTTPClient(5 * time.Second)
+	if _, err := get(untrusting, url); err == nil {
+		t.Error("GET trusting only the system roots succeeded")
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "exs services")
+	certFile, keyFile := ca.issue(t, dir, "server", 2)
+	c := Config{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Millisecond}
+	cert, err := c.certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := func() int64 {
+		t.Helper()
+		pair, err := cert.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.SerialNumber.Int64()
+	}
+	if got := serial(); got != 2 {
+		t.Fatalf("serial = %d, want 2", got)
+	}
+
+	// A rotation is picked up on a later handshake
+	rotated, rotatedKey := ca.issue(t, t.TempDir(), "server", 5)
+	later := time.Now().Add(time.Minute)
+	for _, f := range [][2]string{{rotated, certFile}, {rotatedKey, keyFile}} {
+		data, _ := os.ReadFile(f[0])
+		writeFile(t, f[1], data)
+		os.Chtimes(f[1], later, later)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if got := serial(); got != 5 {
+		t.Errorf("serial after rotation = %d, want 5", got)
+	}
+
+	// A half-written rotation keeps the previous pair
+	writeFile(t, keyFile, []byte("garbage"))
+	later = later.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	time.Sleep(2 * time.Millisecond)
+	if got := serial(); got != 5 {
+		t.Errorf("serial after a broken rotation = %d, want 5", got)
+	}
+}
+
+func TestHTTPSOnly(t *testing.T) {
+	if _, err := (Config{HTTPSOnly: true}).Server(); !errors.Is(err, ErrPlaintext) {
+		t.Errorf("Server() of HTTPS-only without a certificate = %v, want ErrPlaintext", err)
+	}
+	if err := ListenAndServe(&http.Server{Addr: "127.0.0.1:0"}, Config{HTTPSOnly: true}); !errors.Is(err, ErrPlaintext) {
+		t.Errorf("ListenAndServe() of HTTPS-only without a certificate = %v, want ErrPlaintext", err)
+	}
+
+	dir := t.TempDir()
+	ca := newTestCA(t, "exs services")
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, ca.pem)
+	certFile, keyFile := ca.issue(t, dir, "server", 2)
+	c := Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, HTTPSOnly: true}
+
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+	client, err := c.HTTPClient(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(plain.URL); !errors.Is(err, ErrPlaintext) {
+		t.Errorf("GET %s in HTTPS-only mode = %v, want ErrPlaintext", plain.URL, err)
+	}
+	if _, err := get(client, serve(t, c)); err != nil {
+		t.Errorf("GET over HTTPS in HTTPS-only mode = %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, name := range []string{EnvCertFile, EnvKeyFile, EnvClientCAFile, EnvCAFile, EnvReloadInterval, EnvHTTPSOnly, "ENV"} {
+		t.Setenv(name, "")
+	}
+	c, err := FromEnv()
+	if err != nil || c.Enabled() || c.HTTPSOnly {
+		t.Errorf("FromEnv() without variables = %+v, %v, want plaintext", c, err)
+	}
+
+	// Clients need no certificate in production, services do
+	t.Setenv("ENV", "production")
+	if c, err := FromEnv(); err != nil || !c.HTTPSOnly {
+		t.Errorf("FromEnv() in production = %+v, %v, want HTTPS-only", c, err)
+	}
+	if _, err := ServerFromEnv(); !errors.Is(err, ErrPlaintext) {
+		t.Errorf("ServerFromEnv() in production without a certificate = %v, want ErrPlaintext", err)
+	}
+	t.Setenv(EnvHTTPSOnly, "false")
+	if c, err := ServerFromEnv(); err != nil || c.HTTPSOnly {
+		t.Errorf("ServerFromEnv() with TLS_HTTPS_ONLY=false = %+v, %v", c, err)
+	}
+
+	t.Setenv(EnvCertFile, "server.crt")
+	if _, err := FromEnv(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("FromEnv() without a key = %v, want ErrInvalidConfig", err)
+	}
+	t.Setenv(EnvKeyFile, "server.key")
+	t.Setenv(EnvClientCAFile, "ca.pem")
+	t.Setenv(EnvReloadInterval, "1m")
+	c, err = FromEnv()
+	if err != nil || !c.Mutual() || c.ReloadInterval != time.Minute {
+		t.Errorf("FromEnv() = %+v, %v", c, err)
+	}
+	if _, err := c.Server(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Server() with missing files = %v, want ErrInvalidConfig", err)
+	}
+
+	t.Setenv(EnvReloadInterval, "often")
+	if _, err := FromEnv(); err == nil {
+		t.Error("FromEnv() accepted an invalid reload interval")
+	}
+}
+
+func TestServerDisabled(t *testing.T) {
+	config, err := Config{}.Server()
+	if config != nil || err != nil {
+		t.Errorf("Server() without a certificate = %v, %v, want nil", config, err)
+	}
+	config2, err := Config{}.Client()
+	if err != nil || config2.GetClientCertificate != nil || config2.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Client() without a certificate = %+v, %v", config2, err)
+	}
+}
@@ -0,0 +1,178 @@
+// Package tracing traces requests across the Excalibur-EXS services with
+// OpenTelemetry, so a forge can be followed from the miner that found the
+// block through the treasury that records it. Servers wrap their handlers in
+// Middleware and name spans after their routes with Instrument or
+// MuxMiddleware; clients send the trace along with Transport; work worth
+// timing on its own, such as a mining round, gets a span from Start.
+//
+// Spans are exported over OTLP/HTTP once OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set. The exporter and sampler read
+// the rest of the standard OTEL_* variables, e.g. OTEL_EXPORTER_OTLP_HEADERS
+// and OTEL_TRACES_SAMPLER. Without an endpoint spans are not recorded, but
+// incoming trace context is still passed on to the next service.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Environment variables read by FromEnv
+const (
+	EnvEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	EnvServiceName    = "OTEL_SERVICE_NAME"
+	EnvSDKDisabled    = "OTEL_SDK_DISABLED"
+)
+
+// instrumentationName names the tracer of the spans started here
+const instrumentationName = "github.com/Holedozer1229/Excalibur-EXS"
+
+// Config is a binary's tracing setup
+type Config struct {
+	// Endpoint is the OTLP/HTTP collector spans are exported to; empty
+	// disables export
+	Endpoint string
+	// ServiceName overrides the name the binary reports its spans under
+	ServiceName string
+}
+
+// FromEnv returns the Config set by the OTEL_* variables. OTEL_SDK_DISABLED
+// turns export off even with an endpoint.
+func FromEnv() Config {
+	c := Config{
+		Endpoint:    os.Getenv(EnvTracesEndpoint),
+		ServiceName: os.Getenv(EnvServiceName),
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = os.Getenv(EnvEndpoint)
+	}
+	if disabled, _ := strconv.ParseBool(os.Getenv(EnvSDKDisabled)); disabled {
+		c.Endpoint = ""
+	}
+	return c
+}
+
+// Enabled reports whether spans are exported
+func (c Config) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// Describe summarizes the setup for startup logs
+func (c Config) Describe() string {
+	if !c.Enabled() {
+		return "disabled: set " + EnvEndpoint + " to export traces"
+	}
+	return "OTLP/HTTP to " + c.Endpoint
+}
+
+// Setup installs the W3C trace context propagator and, when c is enabled,
+// a tracer provider exporting the spans of service in batches. The returned
+// function flushes the spans still buffered; call it before exiting.
+func (c Config) Setup(ctx context.Context, service string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !c.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the endpoint, headers and TLS settings itself
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.ServiceName != "" {
+		service = c.ServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", service),
+		attribute.String("service.version", buildinfo.Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware starts a server span for every request, continuing the trace
+// of the caller. Probes and metrics scrapes are not traced.
+func Middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/healthz", "/readyz", "/metrics":
+				return false
+			}
+			return true
+		}),
+	)
+}
+
+// Instrument names the server span of every request h serves after route,
+// the pattern h is mounted on, so requests for the same endpoint group
+// together
+func Instrument(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(attribute.String("http.route", route))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// MuxMiddleware instruments every route of a gorilla/mux router, naming
+// spans after the matched route's path template
+func MuxMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, _ := mux.CurrentRoute(r).GetPathTemplate()
+		Instrument(route, next).ServeHTTP(w, r)
+	})
+}
+
+// Transport starts a client span for every request sent through next,
+// http.DefaultTransport when nil, and passes the trace on to the server
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(next,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
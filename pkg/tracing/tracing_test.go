@@ -0,0 +1,127 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/logging"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record installs a provider recording every span for the test
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	if _, err := (Config{}).Setup(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	return recorder
+}
+
+func TestForgeTrace(t *testing.T) {
+	recorder := record(t)
+
+	// A treasury recording the forge in a span of its own
+	var logs bytes.Buffer
+	handler, _ := logging.Options{Level: "info", Format: "json"}.Handler(&logs)
+	router := mux.NewRouter()
+	router.HandleFunc("/forge/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "forge", attribute.String("miner_address", "bc1p..."))
+		End(span, errors.New("payment required"))
+		w.WriteHeader(http.StatusPaymentRequired)
+	}).Methods("POST")
+	router.Handle("/healthz", http.NotFoundHandler())
+	router.Use(MuxMiddleware)
+	treasury := httptest.NewServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.Middleware(router).ServeHTTP(w, r.WithContext(logging.WithLogger(r.Context(), slog.New(handler))))
+	})))
+	defer treasury.Close()
+
+	// A miner submitting it from its mining span
+	ctx, mine := Start(context.Background(), "mine")
+	client := &http.Client{Transport: Transport(nil)}
+	req, _ := http.NewRequestWithContext(ctx, "POST", treasury.URL+"/forge/1", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get(treasury.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	mine.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server, forge := spans["POST /forge/{id}"], spans["forge"]
+	if server == nil || forge == nil || spans["mine"] == nil || len(spans) != 5 {
+		t.Fatalf("spans = %v, want mine, two client spans, the server span and forge", spans)
+	}
+	if spans["GET /healthz"] != nil {
+		t.Error("a probe was traced")
+	}
+
+	// One trace from the mining span down to the forge
+	traceID := spans["mine"].SpanContext().TraceID()
+	for name, span := range spans {
+		if name != "GET" && span.SpanContext().TraceID() != traceID {
+			t.Errorf("span %s is in trace %s, want %s", name, span.SpanContext().TraceID(), traceID)
+		}
+	}
+	if server.Parent().SpanID() != spans["POST"].SpanContext().SpanID() || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("server span parent = %s, want the client span", server.Parent().SpanID())
+	}
+	if forge.Parent().SpanID() != server.SpanContext().SpanID() || forge.Status().Code != codes.Error {
+		t.Errorf("forge span parent %s, status %v", forge.Parent().SpanID(), forge.Status())
+	}
+
+	// The request log carries the trace ID
+	var entry map[string]interface{}
+	json.Unmarshal(logs.Bytes(), &entry)
+	if entry["trace_id"] != traceID.String() {
+		t.Errorf("request log %v lacks trace ID %s", entry, traceID)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, name := range []string{EnvEndpoint, EnvTracesEndpoint, EnvServiceName, EnvSDKDisabled} {
+		t.Setenv(name, "")
+	}
+	if c := FromEnv(); c.Enabled() {
+		t.Errorf("FromEnv() without variables = %+v, want disabled", c)
+	}
+
+	t.Setenv(EnvEndpoint, "http://collector:4318")
+	t.Setenv(EnvServiceName, "treasury-eu")
+	if c := FromEnv(); !c.Enabled() || c.Endpoint != "http://collector:4318" || c.ServiceName != "treasury-eu" {
+		t.Errorf("FromEnv() = %+v", c)
+	}
+	t.Setenv(EnvTracesEndpoint, "http://traces:4318/v1/traces")
+	if c := FromEnv(); c.Endpoint != "http://traces:4318/v1/traces" {
+		t.Errorf("FromEnv() with a traces endpoint = %+v", c)
+	}
+	t.Setenv(EnvSDKDisabled, "true")
+	if c := FromEnv(); c.Enabled() {
+		t.Errorf("FromEnv() with %s = %+v, want disabled", EnvSDKDisabled, c)
+	}
+}
@@ -0,0 +1,212 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+)
+
+// DefaultManifestURL is where each release publishes its signed manifest
+const DefaultManifestURL = "https://github.com/Holedozer1229/Excalibur-EXS/releases/latest/download/manifest.json"
+
+// maxManifestSize bounds how much of a manifest response is read
+const maxManifestSize = 1 << 20
+
+// Config configures a Checker
+type Config struct {
+	URL      string
+	Interval time.Duration // between checks by Watch; 0 disables checking
+	Timeout  time.Duration // per request
+
+	// Keys verify manifests; nil means the embedded MaintainerKeys
+	Keys []ed25519.PublicKey
+}
+
+// DefaultConfig checks DefaultManifestURL once a day
+func DefaultConfig() *Config {
+	return &Config{
+		URL:      DefaultManifestURL,
+		Interval: 24 * time.Hour,
+		Timeout:  30 * time.Second,
+	}
+}
+
+// ConfigFromEnv returns DefaultConfig with the manifest URL overridden by
+// EXS_UPDATE_URL and the interval by EXS_UPDATE_INTERVAL, a duration such as
+// "6h"; an interval of 0 disables checking
+func ConfigFromEnv() (*Config, error) {
+	config := DefaultConfig()
+	if url := os.Getenv("EXS_UPDATE_URL"); url != "" {
+		config.URL = url
+	}
+	if value := os.Getenv("EXS_UPDATE_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid EXS_UPDATE_INTERVAL: %q", value)
+		}
+		config.Interval = d
+	}
+	return config, nil
+}
+
+// Status is the outcome of the latest check, as shown by /health
+type Status struct {
+	Current   string     `json:"current"`
+	Latest    string     `json:"latest,omitempty"`
+	Available bool       `json:"available"`
+	Critical  bool       `json:"critical,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Checker fetches and verifies release manifests, remembering the newest
+// verified release. It is safe for concurrent use.
+type Checker struct {
+	config  Config
+	current string
+	client  *http.Client
+
+	mu     sync.RWMutex
+	status Status
+}
+
+// NewChecker creates a checker for the running binary's version. It fails
+// with ErrNoMaintainerKeys when there is nothing to verify manifests with.
+func NewChecker(config *Config) (*Checker, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	c := &Checker{
+		config:  *config,
+		current: buildinfo.Version,
+		client:  &http.Client{Timeout: config.Timeout},
+	}
+	if c.config.Keys == nil {
+		keys, err := MaintainerKeys()
+		if err != nil {
+			return nil, err
+		}
+		c.config.Keys = keys
+	}
+	if len(c.config.Keys) == 0 {
+		return nil, ErrNoMaintainerKeys
+	}
+	if _, err := parseVersion(c.current); err != nil {
+		return nil, fmt.Errorf("running version: %w", err)
+	}
+	c.status.Current = c.current
+	return c, nil
+}
+
+// Status returns the outcome of the latest check. After a failed check it
+// still reports the last verified release, along with the error.
+func (c *Checker) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Check fetches the manifest and returns the verified release
+func (c *Checker) Check(ctx context.Context) (*Release, error) {
+	release, err := c.fetch(ctx)
+	now := time.Now().UTC()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status.CheckedAt = &now
+	if err != nil {
+		c.status.Error = err.Error()
+		return nil, err
+	}
+
+	newer, _ := CompareVersions(release.Version, c.current)
+	c.status = Status{
+		Current:   c.current,
+		Latest:    release.Version,
+		Available: newer > 0,
+		Critical:  newer > 0 && release.Critical,
+		URL:       release.URL,
+		CheckedAt: &now,
+	}
+	return release, nil
+}
+
+func (c *Checker) fetch(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Excalibur-EXS/"+c.current)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch release manifest: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release manifest: %w", err)
+	}
+	if len(data) > maxManifestSize {
+		return nil, fmt.Errorf("release manifest exceeds %d bytes", maxManifestSize)
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	return m.Verify(c.config.Keys)
+}
+
+// Watch checks now and then every Interval until ctx is done. notify is
+// called once for each newer release found.
+func (c *Checker) Watch(ctx context.Context, notify func(*Release)) {
+	if c.config.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	notified := c.current
+	for {
+		if release, err := c.Check(ctx); err == nil {
+			if newer, _ := CompareVersions(release.Version, notified); newer > 0 {
+				notified = release.Version
+				if notify != nil {
+					notify(release)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// StartFromEnv watches for releases in the background until ctx is done,
+// configured by ConfigFromEnv. It returns a nil Checker when
+// EXS_UPDATE_INTERVAL disables checking.
+func StartFromEnv(ctx context.Context, notify func(*Release)) (*Checker, error) {
+	config, err := ConfigFromEnv()
+	if err != nil || config.Interval == 0 {
+		return nil, err
+	}
+	c, err := NewChecker(config)
+	if err != nil {
+		return nil, err
+	}
+	go c.Watch(ctx, notify)
+	return c, nil
+}
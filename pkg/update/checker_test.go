@@ -0,0 +1,135 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// manifestServer serves whatever manifest is stored in *m
+func manifestServer(t *testing.T, m **Manifest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *m == nil {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(*m)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testChecker(t *testing.T, url string, key ed25519.PrivateKey) *Checker {
+	t.Helper()
+	c, err := NewChecker(&Config{
+		URL:      url,
+		Interval: time.Hour,
+		Timeout:  5 * time.Second,
+		Keys:     []ed25519.PublicKey{key.Public().(ed25519.PublicKey)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.current = "1.0.0"
+	c.status.Current = "1.0.0"
+	return c
+}
+
+func TestCheckerCheck(t *testing.T) {
+	key := newKey(t)
+	var m *Manifest
+	srv := manifestServer(t, &m)
+	c := testChecker(t, srv.URL, key)
+
+	if status := c.Status(); status.Current != "1.0.0" || status.CheckedAt != nil {
+		t.Errorf("Unexpected status before checking: %+v", status)
+	}
+
+	m = signedManifest(t, &Release{Version: "1.0.0"}, key)
+	if _, err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if status := c.Status(); status.Available || status.Latest != "1.0.0" || status.CheckedAt == nil {
+		t.Errorf("Expected no update, got %+v", status)
+	}
+
+	m = signedManifest(t, &Release{Version: "1.1.0", URL: "https://example.com/v1.1.0", Critical: true}, key)
+	release, err := c.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	status := c.Status()
+	if release.Version != "1.1.0" || !status.Available || !status.Critical || status.URL != "https://example.com/v1.1.0" {
+		t.Errorf("Expected a critical update, got %+v", status)
+	}
+
+	// A forged manifest is reported but does not replace the verified release
+	m = signedManifest(t, &Release{Version: "6.6.6"}, newKey(t))
+	if _, err := c.Check(context.Background()); !errors.Is(err, ErrUntrustedManifest) {
+		t.Errorf("Expected ErrUntrustedManifest, got %v", err)
+	}
+	status = c.Status()
+	if status.Latest != "1.1.0" || !status.Available || status.Error == "" {
+		t.Errorf("Expected last verified release with an error, got %+v", status)
+	}
+
+	m = nil
+	if _, err := c.Check(context.Background()); err == nil {
+		t.Error("Expected an error when the manifest is missing")
+	}
+}
+
+func TestCheckerWatch(t *testing.T) {
+	key := newKey(t)
+	m := signedManifest(t, &Release{Version: "1.2.0"}, key)
+	srv := manifestServer(t, &m)
+	c := testChecker(t, srv.URL, key)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notified := make(chan *Release, 1)
+	done := make(chan struct{})
+	go func() {
+		c.Watch(ctx, func(r *Release) { notified <- r })
+		close(done)
+	}()
+
+	select {
+	case r := <-notified:
+		if r.Version != "1.2.0" {
+			t.Errorf("Expected 1.2.0, got %s", r.Version)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a notification for the newer release")
+	}
+	cancel()
+	<-done
+}
+
+func TestNewCheckerRequiresKeys(t *testing.T) {
+	if _, err := NewChecker(&Config{URL: "http://localhost", Keys: []ed25519.PublicKey{}}); !errors.Is(err, ErrNoMaintainerKeys) {
+		t.Errorf("Expected ErrNoMaintainerKeys, got %v", err)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("EXS_UPDATE_URL", "https://mirror.example.com/manifest.json")
+	t.Setenv("EXS_UPDATE_INTERVAL", "6h")
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.URL != "https://mirror.example.com/manifest.json" || config.Interval != 6*time.Hour {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	t.Setenv("EXS_UPDATE_INTERVAL", "daily")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("Expected an invalid interval to be rejected")
+	}
+}
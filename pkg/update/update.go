@@ -0,0 +1,250 @@
+// Package update tells operators about new Excalibur-EXS releases. Each
+// release is described by a manifest signed with Ed25519 by one or more
+// maintainers; a manifest is trusted only when it carries a valid signature
+// from a key embedded in maintainers.keys. Nothing is downloaded or
+// installed: the operator decides whether and when to upgrade.
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signingContext is prepended to the release before signing, so manifest
+// signatures cannot be replayed as signatures over anything else
+const signingContext = "Excalibur-EXS release manifest\n"
+
+var (
+	// ErrNoMaintainerKeys indicates there are no keys to verify manifests with
+	ErrNoMaintainerKeys = errors.New("no maintainer keys")
+
+	// ErrUntrustedManifest indicates a manifest without a valid signature
+	// from a maintainer key
+	ErrUntrustedManifest = errors.New("manifest has no valid maintainer signature")
+
+	// ErrInvalidVersion indicates a version that is not of the form 1.2.3[-pre]
+	ErrInvalidVersion = errors.New("invalid version")
+)
+
+//go:embed maintainers.keys
+var maintainersFile []byte
+
+// Release describes a published release
+type Release struct {
+	Version    string    `json:"version"`
+	ReleasedAt time.Time `json:"released_at"`
+	URL        string    `json:"url"`
+	Notes      string    `json:"notes,omitempty"`
+	Critical   bool      `json:"critical,omitempty"` // fixes a security issue
+	Assets     []Asset   `json:"assets,omitempty"`
+}
+
+// Asset is a release download, listed so operators can check what they fetch
+type Asset struct {
+	Name     string `json:"name"`
+	Platform string `json:"platform,omitempty"`
+	SHA256   string `json:"sha256"`
+}
+
+// Manifest is a release and the maintainer signatures over it
+type Manifest struct {
+	Release    json.RawMessage `json:"release"`
+	Signatures []Signature     `json:"signatures"`
+}
+
+// Signature is one maintainer's Ed25519 signature, both fields in hex
+type Signature struct {
+	Key       string `json:"key"`
+	Signature string `json:"signature"`
+}
+
+// NewManifest returns an unsigned manifest for r
+func NewManifest(r *Release) (*Manifest, error) {
+	if _, err := parseVersion(r.Version); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{Release: data}, nil
+}
+
+// ParseManifest decodes a manifest without verifying it
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if len(m.Release) == 0 {
+		return nil, errors.New("manifest has no release")
+	}
+	return &m, nil
+}
+
+// signedMessage is what maintainers sign. The release is compacted first so
+// re-indenting the manifest does not invalidate its signatures.
+func (m *Manifest) signedMessage() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(signingContext)
+	if err := json.Compact(&buf, m.Release); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest release: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Sign adds key's signature, replacing an earlier one by the same key
+func (m *Manifest) Sign(key ed25519.PrivateKey) error {
+	msg, err := m.signedMessage()
+	if err != nil {
+		return err
+	}
+	pub := hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	sig := Signature{Key: pub, Signature: hex.EncodeToString(ed25519.Sign(key, msg))}
+	for i := range m.Signatures {
+		if m.Signatures[i].Key == pub {
+			m.Signatures[i] = sig
+			return nil
+		}
+	}
+	m.Signatures = append(m.Signatures, sig)
+	return nil
+}
+
+// Verify returns the release if at least one signature by a key in keys is
+// valid. Signatures by other keys are ignored.
+func (m *Manifest) Verify(keys []ed25519.PublicKey) (*Release, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoMaintainerKeys
+	}
+	msg, err := m.signedMessage()
+	if err != nil {
+		return nil, err
+	}
+	if !m.signedBy(keys, msg) {
+		return nil, ErrUntrustedManifest
+	}
+
+	var r Release
+	if err := json.Unmarshal(m.Release, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest release: %w", err)
+	}
+	if _, err := parseVersion(r.Version); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (m *Manifest) signedBy(keys []ed25519.PublicKey, msg []byte) bool {
+	for _, sig := range m.Signatures {
+		pub, err := hex.DecodeString(sig.Key)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			continue
+		}
+		signature, err := hex.DecodeString(sig.Signature)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if key.Equal(ed25519.PublicKey(pub)) && ed25519.Verify(key, msg, signature) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MaintainerKeys returns the keys embedded from maintainers.keys
+func MaintainerKeys() ([]ed25519.PublicKey, error) {
+	return ParseKeys(maintainersFile)
+}
+
+// ParseKeys reads one hex Ed25519 public key per line, optionally followed by
+// the maintainer's name. Blank lines and lines starting with # are skipped.
+func ParseKeys(data []byte) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		field, _, _ := strings.Cut(line, " ")
+		key, err := hex.DecodeString(field)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("line %d: invalid maintainer key %q", n, field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
+
+// CompareVersions returns -1, 0 or 1 as version a is older than, the same as
+// or newer than b. A leading "v" is ignored and pre-releases such as
+// 1.2.0-rc1 sort before their release.
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va.parts {
+		if va.parts[i] != vb.parts[i] {
+			if va.parts[i] < vb.parts[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	switch {
+	case va.pre == vb.pre:
+		return 0, nil
+	case va.pre == "":
+		return 1, nil
+	case vb.pre == "":
+		return -1, nil
+	case va.pre < vb.pre:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+type version struct {
+	parts [3]uint64
+	pre   string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	core, pre, hasPre := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	if hasPre && pre == "" {
+		return v, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+	}
+	v.pre = pre
+
+	fields := strings.Split(core, ".")
+	if len(fields) > len(v.parts) {
+		return v, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+	}
+	for i, field := range fields {
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return v, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+		}
+		v.parts[i] = n
+	}
+	return v, nil
+}
@@ -0,0 +1,140 @@
+package update
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signedManifest(t *testing.T, r *Release, keys ...ed25519.PrivateKey) *Manifest {
+	t.Helper()
+	m, err := NewManifest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := m.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestManifestVerify(t *testing.T) {
+	maintainer, other := newKey(t), newKey(t)
+	trusted := []ed25519.PublicKey{maintainer.Public().(ed25519.PublicKey)}
+	release := &Release{
+		Version:    "1.2.0",
+		ReleasedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		URL:        "https://github.com/Holedozer1229/Excalibur-EXS/releases/tag/v1.2.0",
+		Critical:   true,
+	}
+
+	m := signedManifest(t, release, other, maintainer)
+	got, err := m.Verify(trusted)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got.Version != "1.2.0" || !got.Critical || got.URL != release.URL {
+		t.Errorf("Unexpected release: %+v", got)
+	}
+
+	// Re-indenting the manifest keeps its signatures valid
+	data, _ := json.Marshal(m)
+	var indented bytes.Buffer
+	json.Indent(&indented, data, "", "  ")
+	parsed, err := ParseManifest(indented.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parsed.Verify(trusted); err != nil {
+		t.Errorf("Expected indented manifest to verify, got %v", err)
+	}
+
+	if _, err := signedManifest(t, release, other).Verify(trusted); !errors.Is(err, ErrUntrustedManifest) {
+		t.Errorf("Expected ErrUntrustedManifest for an unknown signer, got %v", err)
+	}
+	if _, err := signedManifest(t, release).Verify(trusted); !errors.Is(err, ErrUntrustedManifest) {
+		t.Errorf("Expected ErrUntrustedManifest for an unsigned manifest, got %v", err)
+	}
+	if _, err := m.Verify(nil); !errors.Is(err, ErrNoMaintainerKeys) {
+		t.Errorf("Expected ErrNoMaintainerKeys, got %v", err)
+	}
+
+	tampered := signedManifest(t, release, maintainer)
+	tampered.Release = bytes.Replace(tampered.Release, []byte("1.2.0"), []byte("9.9.9"), 1)
+	if _, err := tampered.Verify(trusted); !errors.Is(err, ErrUntrustedManifest) {
+		t.Errorf("Expected ErrUntrustedManifest for a tampered release, got %v", err)
+	}
+}
+
+func TestManifestSignReplaces(t *testing.T) {
+	key := newKey(t)
+	m := signedManifest(t, &Release{Version: "1.0.1"}, key, key)
+	if len(m.Signatures) != 1 {
+		t.Errorf("Expected re-signing to replace the signature, got %d", len(m.Signatures))
+	}
+	if _, err := NewManifest(&Release{Version: "latest"}); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("Expected ErrInvalidVersion, got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	pub := newKey(t).Public().(ed25519.PublicKey)
+	data := "# maintainers\n\n" + hex.EncodeToString(pub) + " Arthur Pendragon\n"
+	keys, err := ParseKeys([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys[0].Equal(pub) {
+		t.Errorf("Unexpected keys: %x", keys)
+	}
+
+	if _, err := ParseKeys([]byte("abcd\n")); err == nil {
+		t.Error("Expected a short key to be rejected")
+	}
+	if _, err := MaintainerKeys(); err != nil {
+		t.Errorf("Embedded maintainers.keys does not parse: %v", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0.0", "1.0", 0},
+		{"1.0.1", "1.0.0", 1},
+		{"1.10.0", "1.9.9", 1},
+		{"2.0.0", "10.0.0", -1},
+		{"1.2.0-rc1", "1.2.0", -1},
+		{"1.2.0-rc2", "1.2.0-rc1", 1},
+		{"1.2.0-rc1", "1.1.9", 1},
+	}
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil || got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, %v; want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "1.2.3.4", "1.x", "1.0-"} {
+		if _, err := CompareVersions(bad, "1.0.0"); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("Expected ErrInvalidVersion for %q, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+var (
+	// ErrContactNotFound indicates no contact has the given label
+	ErrContactNotFound = errors.New("contact not found")
+	// ErrUnknownRecipient indicates a recipient that is not a contact, a
+	// payment URI or an address
+	ErrUnknownRecipient = errors.New("not a contact, payment URI or address")
+)
+
+// Contact is a labelled address in the address book
+type Contact struct {
+	Label   string    `json:"label"`
+	Address string    `json:"address"`
+	Amount  int64     `json:"amount,omitempty"` // default amount in satoshis
+	Message string    `json:"message,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// PaymentRequest returns a request paying the contact its default amount
+func (c *Contact) PaymentRequest() *PaymentRequest {
+	return &PaymentRequest{Address: c.Address, Amount: c.Amount, Label: c.Label, Message: c.Message}
+}
+
+// AddressBook persists contacts as a JSON file. Labels are matched without
+// regard to case.
+type AddressBook struct {
+	mu       sync.Mutex
+	path     string
+	contacts []Contact
+}
+
+// OpenAddressBook loads the address book at path, creating an empty one if
+// the file does not exist
+func OpenAddressBook(path string) (*AddressBook, error) {
+	b := &AddressBook{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read address book: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.contacts); err != nil {
+		return nil, fmt.Errorf("failed to parse address book: %w", err)
+	}
+	return b, nil
+}
+
+// Add saves a contact, replacing any existing contact with the same label
+func (b *AddressBook) Add(c Contact, net *chaincfg.Params) (*Contact, error) {
+	c.Label = strings.TrimSpace(c.Label)
+	if c.Label == "" {
+		return nil, errors.New("contact label is empty")
+	}
+	if strings.Contains(c.Label, ":") {
+		return nil, fmt.Errorf("contact label %q must not contain ':'", c.Label)
+	}
+	if c.Amount < 0 {
+		return nil, fmt.Errorf("invalid default amount %d", c.Amount)
+	}
+	if _, err := addressScript(c.Address, net); err != nil {
+		return nil, err
+	}
+	c.AddedAt = time.Now().UTC()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if i := b.index(c.Label); i >= 0 {
+		b.contacts[i] = c
+	} else {
+		b.contacts = append(b.contacts, c)
+	}
+	if err := b.save(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Remove deletes the contact with label
+func (b *AddressBook) Remove(label string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(label)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrContactNotFound, label)
+	}
+	b.contacts = append(b.contacts[:i], b.contacts[i+1:]...)
+	return b.save()
+}
+
+// Get returns the contact with label
+func (b *AddressBook) Get(label string) (*Contact, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(label)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrContactNotFound, label)
+	}
+	c := b.contacts[i]
+	return &c, nil
+}
+
+// List returns all contacts sorted by label
+func (b *AddressBook) List() []Contact {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	list := make([]Contact, len(b.contacts))
+	copy(list, b.contacts)
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Label) < strings.ToLower(list[j].Label)
+	})
+	return list
+}
+
+// Resolve turns a recipient given on the command line into a payment request.
+// The recipient may be an exs: URI, a contact label or a plain address.
+func (b *AddressBook) Resolve(recipient string, net *chaincfg.Params) (*PaymentRequest, error) {
+	if IsPaymentURI(recipient) {
+		return ParsePaymentURI(recipient, net)
+	}
+	if c, err := b.Get(recipient); err == nil {
+		return c.PaymentRequest(), nil
+	}
+	if _, err := addressScript(recipient, net); err != nil {
+		return nil, fmt.Errorf("%q is %w", recipient, ErrUnknownRecipient)
+	}
+	return &PaymentRequest{Address: recipient}, nil
+}
+
+func (b *AddressBook) index(label string) int {
+	for i := range b.contacts {
+		if strings.EqualFold(b.contacts[i].Label, label) {
+			return i
+		}
+	}
+	return -1
+}
+
+// save atomically writes the address book to disk (write to temp file, then
+// rename)
+func (b *AddressBook) save() error {
+	data, err := json.MarshalIndent(b.contacts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode address book: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write address book: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("failed to replace address book: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package wallet
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestAddressBook(t *testing.T) {
+	net := &chaincfg.MainNetParams
+	path := filepath.Join(t.TempDir(), "addressbook.json")
+	book, err := OpenAddressBook(path)
+	if err != nil {
+		t.Fatalf("OpenAddressBook() error = %v", err)
+	}
+
+	if _, err := book.Add(Contact{Label: "alice", Address: testTaprootAddr, Amount: 500000000}, net); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, err := book.Add(Contact{Label: "Bob", Address: testWPKHAddr}, net); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	for _, c := range []Contact{
+		{Label: "", Address: testTaprootAddr},
+		{Label: "exs:alice", Address: testTaprootAddr},
+		{Label: "carol", Address: "not-an-address"},
+	} {
+		if _, err := book.Add(c, net); err == nil {
+			t.Errorf("Expected Add(%+v) to fail", c)
+		}
+	}
+
+	// Contacts survive a reload and labels match without regard to case
+	book, err = OpenAddressBook(path)
+	if err != nil {
+		t.Fatalf("OpenAddressBook() error = %v", err)
+	}
+	if list := book.List(); len(list) != 2 || list[0].Label != "alice" || list[1].Label != "Bob" {
+		t.Fatalf("Unexpected contacts: %+v", list)
+	}
+	alice, err := book.Get("ALICE")
+	if err != nil || alice.Amount != 500000000 {
+		t.Fatalf("Get() = %+v, %v", alice, err)
+	}
+
+	// Adding an existing label replaces the contact
+	if _, err := book.Add(Contact{Label: "Alice", Address: testWPKHAddr}, net); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if list := book.List(); len(list) != 2 || list[0].Address != testWPKHAddr {
+		t.Errorf("Expected alice to be replaced, got %+v", list)
+	}
+
+	if err := book.Remove("bob"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := book.Remove("bob"); !errors.Is(err, ErrContactNotFound) {
+		t.Errorf("Expected ErrContactNotFound, got %v", err)
+	}
+}
+
+func TestAddressBookResolve(t *testing.T) {
+	net := &chaincfg.MainNetParams
+	book, err := OpenAddressBook(filepath.Join(t.TempDir(), "addressbook.json"))
+	if err != nil {
+		t.Fatalf("OpenAddressBook() error = %v", err)
+	}
+	if _, err := book.Add(Contact{Label: "alice", Address: testTaprootAddr, Amount: 500000000, Message: "rent"}, net); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	tests := []struct {
+		recipient string
+		address   string
+		amount    int64
+	}{
+		{"alice", testTaprootAddr, 500000000},
+		{testWPKHAddr, testWPKHAddr, 0},
+		{"exs:" + testWPKHAddr + "?amount=0.1", testWPKHAddr, 10000000},
+	}
+	for _, tt := range tests {
+		req, err := book.Resolve(tt.recipient, net)
+		if err != nil {
+			t.Errorf("Resolve(%s) error = %v", tt.recipient, err)
+			continue
+		}
+		if req.Address != tt.address || req.Amount != tt.amount {
+			t.Errorf("Resolve(%s) = %+v", tt.recipient, req)
+		}
+	}
+
+	if _, err := book.Resolve("mallory", net); !errors.Is(err, ErrUnknownRecipient) {
+		t.Errorf("Expected ErrUnknownRecipient, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// ChainSource answers the chain queries a wallet needs: which scripts have
+// history, which of their outputs are unspent, and relaying transactions
+type ChainSource interface {
+	UsageChecker
+	Unspent(ctx context.Context, address string) ([]UTXO, error)
+	Broadcast(ctx context.Context, rawTx string) (string, error)
+}
+
+// Snapshot is a wallet's view of its funds as of the last sync
+type Snapshot struct {
+	SyncedAt time.Time `json:"synced_at"`
+	UTXOs    []UTXO    `json:"utxos"`
+}
+
+// Confirmed returns the total of confirmed outputs in satoshis
+func (s *Snapshot) Confirmed() int64 {
+	var total int64
+	for _, utxo := range s.UTXOs {
+		if utxo.Height > 0 {
+			total += utxo.Value
+		}
+	}
+	return total
+}
+
+// Unconfirmed returns the total of unconfirmed outputs in satoshis
+func (s *Snapshot) Unconfirmed() int64 {
+	var total int64
+	for _, utxo := range s.UTXOs {
+		if utxo.Height == 0 {
+			total += utxo.Value
+		}
+	}
+	return total
+}
+
+// Sync rescans every descriptor in store over its range with a gap limit,
+// records the next unused indexes and collects the unspent outputs of all
+// used addresses
+func Sync(ctx context.Context, source ChainSource, store *DescriptorStore, net *chaincfg.Params, gapLimit uint32) (*Snapshot, error) {
+	scanner := NewScanner(source, net, gapLimit)
+	snapshot := &Snapshot{UTXOs: []UTXO{}}
+	seen := make(map[string]bool)
+
+	for _, entry := range store.List() {
+		desc, err := ParseDescriptor(entry.Descriptor)
+		if err != nil {
+			return nil, err
+		}
+		result, err := scanner.Scan(ctx, desc, entry.RangeStart, entry.RangeEnd)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.RecordScan(result); err != nil {
+			return nil, err
+		}
+
+		for _, addr := range result.Used {
+			utxos, err := source.Unspent(ctx, addr.Address)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list outputs of %s: %w", addr.Address, err)
+			}
+			for _, utxo := range utxos {
+				key := fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)
+				if !seen[key] {
+					seen[key] = true
+					snapshot.UTXOs = append(snapshot.UTXOs, utxo)
+				}
+			}
+		}
+	}
+
+	sort.Slice(snapshot.UTXOs, func(i, j int) bool {
+		return snapshot.UTXOs[i].Value > snapshot.UTXOs[j].Value
+	})
+	snapshot.SyncedAt = time.Now().UTC()
+	return snapshot, nil
+}
+
+// SaveSnapshot writes a snapshot to path
+func SaveSnapshot(path string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create wallet directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadSnapshot reads the snapshot at path. A wallet that was never synced
+// has an empty snapshot.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Snapshot{UTXOs: []UTXO{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,162 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// newEsploraServer serves an Esplora API where each address in utxos has
+// history and the given unspent outputs
+func newEsploraServer(t *testing.T, utxos map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/tx":
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != "0200" {
+				http.Error(w, "bad-txns", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, "feedface")
+		case r.URL.Path == "/tx/feedface/status":
+			fmt.Fprint(w, `{"confirmed":true,"block_height":800000,"block_hash":"00ff"}`)
+		case r.URL.Path == "/blocks/tip/height":
+			fmt.Fprint(w, "800005")
+		case strings.HasSuffix(r.URL.Path, "/utxo"):
+			addr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/address/"), "/utxo")
+			fmt.Fprintf(w, "[%s]", utxos[addr])
+		case strings.HasPrefix(r.URL.Path, "/address/"):
+			count := 0
+			if _, ok := utxos[strings.TrimPrefix(r.URL.Path, "/address/")]; ok {
+				count = 1
+			}
+			fmt.Fprintf(w, `{"chain_stats":{"tx_count":%d},"mempool_stats":{"tx_count":0}}`, count)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestSyncWithEsplora(t *testing.T) {
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/<0;1>/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+	receive, _ := d.Derive(ReceiveBranch, 2, &chaincfg.MainNetParams)
+	change, _ := d.Derive(ChangeBranch, 0, &chaincfg.MainNetParams)
+
+	server := newEsploraServer(t, map[string]string{
+		receive.Address: `{"txid":"aa","vout":0,"value":5000,"status":{"confirmed":true,"block_height":100}}`,
+		change.Address:  `{"txid":"bb","vout":1,"value":9000,"status":{"confirmed":false}}`,
+	})
+	defer server.Close()
+
+	dir := t.TempDir()
+	store, err := OpenDescriptorStore(filepath.Join(dir, "descriptors.json"))
+	if err != nil {
+		t.Fatalf("OpenDescriptorStore() error = %v", err)
+	}
+	if _, err := store.Import(d, "", 0, 1000, true); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	source := NewEsploraSource(server.URL+"/", &chaincfg.MainNetParams)
+	snapshot, err := Sync(context.Background(), source, store, &chaincfg.MainNetParams, 5)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(snapshot.UTXOs) != 2 || snapshot.UTXOs[0].TxID != "bb" {
+		t.Fatalf("Unexpected outputs %+v", snapshot.UTXOs)
+	}
+	if snapshot.Confirmed() != 5000 || snapshot.Unconfirmed() != 9000 {
+		t.Errorf("Balance = %d confirmed, %d unconfirmed", snapshot.Confirmed(), snapshot.Unconfirmed())
+	}
+	if next := store.List()[0].NextIndex; next[0] != 3 || next[1] != 1 {
+		t.Errorf("Expected next indexes [3 1], got %v", next)
+	}
+
+	path := filepath.Join(dir, "utxos.json")
+	if err := SaveSnapshot(path, snapshot); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil || loaded.Confirmed() != 5000 || len(loaded.UTXOs) != 2 {
+		t.Errorf("LoadSnapshot() = %+v, %v", loaded, err)
+	}
+	if empty, err := LoadSnapshot(filepath.Join(dir, "missing.json")); err != nil || len(empty.UTXOs) != 0 {
+		t.Errorf("Expected an empty snapshot for a missing file, got %+v, %v", empty, err)
+	}
+}
+
+func TestEsploraBroadcast(t *testing.T) {
+	server := newEsploraServer(t, nil)
+	defer server.Close()
+	source := NewEsploraSource(server.URL, &chaincfg.MainNetParams)
+
+	txid, err := source.Broadcast(context.Background(), "0200")
+	if err != nil || txid != "feedface" {
+		t.Errorf("Broadcast() = %q, %v", txid, err)
+	}
+	if _, err := source.Broadcast(context.Background(), "ff"); err == nil || !strings.Contains(err.Error(), "bad-txns") {
+		t.Errorf("Expected the node's rejection to be reported, got %v", err)
+	}
+}
+
+func TestEsploraTxStatus(t *testing.T) {
+	server := newEsploraServer(t, nil)
+	defer server.Close()
+	source := NewEsploraSource(server.URL, &chaincfg.MainNetParams)
+
+	status, err := source.TxStatus(context.Background(), "feedface")
+	if err != nil || !status.Confirmed || status.BlockHeight != 800000 || status.BlockHash != "00ff" {
+		t.Errorf("TxStatus() = %+v, %v", status, err)
+	}
+	if _, err := source.TxStatus(context.Background(), "beef"); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("Expected ErrTxNotFound for an unknown transaction, got %v", err)
+	}
+	if height, err := source.TipHeight(context.Background()); err != nil || height != 800005 {
+		t.Errorf("TipHeight() = %d, %v", height, err)
+	}
+}
+
+func TestEsploraBlock(t *testing.T) {
+	genesis := chaincfg.MainNetParams.GenesisBlock
+	hash := genesis.BlockHash()
+	var raw bytes.Buffer
+	if err := genesis.Serialize(&raw); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/block-height/0":
+			fmt.Fprint(w, hash.String())
+		case "/block/" + hash.String() + "/raw":
+			w.Write(raw.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	source := NewEsploraSource(server.URL, &chaincfg.MainNetParams)
+
+	got, err := source.BlockHash(context.Background(), 0)
+	if err != nil || *got != hash {
+		t.Fatalf("BlockHash() = %v, %v", got, err)
+	}
+	block, err := source.Block(context.Background(), got)
+	if err != nil || block.BlockHash() != hash || len(block.Transactions) != 1 {
+		t.Errorf("Block() = %v, %v", block, err)
+	}
+	if _, err := source.BlockHash(context.Background(), 1); err == nil {
+		t.Error("Expected an error for a height past the tip")
+	}
+}
@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QR chunk frames use only the QR alphanumeric character set (upper-case
+// letters, digits and ':') so each code can be rendered in the dense
+// alphanumeric mode:
+//
+//	EXS:<index>:<total>:<digest>:<base32 data>
+//
+// digest is the first 8 hex characters of SHA-256 over the whole payload and
+// ties the frames of one payload together.
+const (
+	chunkPrefix = "EXS"
+
+	// DefaultChunkSize keeps each frame comfortably inside a version 10 QR code
+	DefaultChunkSize = 300
+)
+
+var chunkEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ErrIncompleteChunks indicates frames are missing from a chunked payload
+var ErrIncompleteChunks = errors.New("missing qr chunks")
+
+// SplitChunks encodes payload into QR-friendly frames whose data part is at
+// most chunkSize characters
+func SplitChunks(payload []byte, chunkSize int) []string {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	sum := sha256.Sum256(payload)
+	digest := strings.ToUpper(hex.EncodeToString(sum[:4]))
+	encoded := chunkEncoding.EncodeToString(payload)
+
+	total := (len(encoded) + chunkSize - 1) / chunkSize
+	if total == 0 {
+		total = 1
+	}
+
+	frames := make([]string, 0, total)
+	for i := 0; i < total; i++ {
+		start := i * chunkSize
+		end := start + chunkSize
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		frames = append(frames, fmt.Sprintf("%s:%d:%d:%s:%s", chunkPrefix, i+1, total, digest, encoded[start:end]))
+	}
+	return frames
+}
+
+// JoinChunks reassembles frames produced by SplitChunks, in any order and
+// tolerating duplicates, and verifies the payload digest
+func JoinChunks(frames []string) ([]byte, error) {
+	var digest string
+	var total int
+	parts := make(map[int]string)
+
+	for _, frame := range frames {
+		frame = strings.TrimSpace(frame)
+		if frame == "" {
+			continue
+		}
+		fields := strings.SplitN(frame, ":", 5)
+		if len(fields) != 5 || fields[0] != chunkPrefix {
+			return nil, fmt.Errorf("invalid qr chunk: %.20q", frame)
+		}
+
+		index, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk index: %w", err)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid chunk count: %s", fields[2])
+		}
+		if index < 1 || index > count {
+			return nil, fmt.Errorf("chunk index %d out of range 1-%d", index, count)
+		}
+
+		if digest == "" {
+			digest, total = fields[3], count
+		} else if fields[3] != digest || count != total {
+			return nil, errors.New("qr chunks belong to different payloads")
+		}
+		parts[index] = fields[4]
+	}
+
+	if total == 0 {
+		return nil, ErrIncompleteChunks
+	}
+	var missing []string
+	var encoded strings.Builder
+	for i := 1; i <= total; i++ {
+		part, ok := parts[i]
+		if !ok {
+			missing = append(missing, strconv.Itoa(i))
+			continue
+		}
+		encoded.WriteString(part)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s of %d", ErrIncompleteChunks, strings.Join(missing, ","), total)
+	}
+
+	payload, err := chunkEncoding.DecodeString(encoded.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid chunk data: %w", err)
+	}
+	sum := sha256.Sum256(payload)
+	if strings.ToUpper(hex.EncodeToString(sum[:4])) != digest {
+		return nil, errors.New("qr chunk digest mismatch")
+	}
+	return payload, nil
+}
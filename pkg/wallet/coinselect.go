@@ -0,0 +1,266 @@
+package wallet
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// SelectionStrategy names a coin selection algorithm
+type SelectionStrategy string
+
+const (
+	// SelectLargestFirst spends the largest outputs until the payment is
+	// covered
+	SelectLargestFirst SelectionStrategy = "largest-first"
+	// SelectBranchAndBound searches for inputs matching the payment closely
+	// enough to need no change output, as Bitcoin Core does, and falls back
+	// to knapsack when there are none
+	SelectBranchAndBound SelectionStrategy = "bnb"
+	// SelectKnapsack approximates the smallest set of inputs that covers the
+	// payment and a change output, by random subset sums
+	SelectKnapsack SelectionStrategy = "knapsack"
+)
+
+// bnbMaxTries bounds the branch-and-bound search
+const bnbMaxTries = 100_000
+
+// knapsackIterations is the number of random subsets knapsack tries
+const knapsackIterations = 1000
+
+// ParseSelectionStrategy returns the strategy named s; empty means
+// largest-first
+func ParseSelectionStrategy(s string) (SelectionStrategy, error) {
+	switch strategy := SelectionStrategy(s); strategy {
+	case "":
+		return SelectLargestFirst, nil
+	case SelectLargestFirst, SelectBranchAndBound, SelectKnapsack:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown coin selection strategy %q (use bnb, knapsack or largest-first)", s)
+}
+
+// coin is a UTXO with what it costs to spend
+type coin struct {
+	utxo     UTXO
+	pkScript []byte
+	// effective is the value left after paying for the input at the fee rate
+	effective int64
+}
+
+// newCoins prices utxos as inputs at feeRate
+func newCoins(utxos []UTXO, feeRate int64, net *chaincfg.Params) ([]coin, error) {
+	coins := make([]coin, len(utxos))
+	for i, utxo := range utxos {
+		pkScript, err := addressScript(utxo.Address, net)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.TxID, utxo.Vout, err)
+		}
+		vsize, err := inputVSize(pkScript)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.TxID, utxo.Vout, err)
+		}
+		coins[i] = coin{utxo: utxo, pkScript: pkScript, effective: utxo.Value - vsize*feeRate}
+	}
+	return coins, nil
+}
+
+// selectCoins picks coins whose effective values cover target, the
+// payouts and the fee of everything but the inputs. changeCost is what a
+// change output costs to create and later spend; minChange is the least
+// excess worth a change output.
+func selectCoins(coins []coin, target, changeCost, minChange int64, strategy SelectionStrategy) []coin {
+	switch strategy {
+	case SelectBranchAndBound:
+		if selected := selectBranchAndBound(coins, target, changeCost); selected != nil {
+			return selected
+		}
+		return selectKnapsack(coins, target, minChange)
+	case SelectKnapsack:
+		return selectKnapsack(coins, target, minChange)
+	}
+	return selectLargestFirst(coins, target)
+}
+
+// selectLargestFirst takes coins by value until they cover target
+func selectLargestFirst(coins []coin, target int64) []coin {
+	sorted := make([]coin, len(coins))
+	copy(sorted, coins)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].utxo.Value > sorted[j].utxo.Value
+	})
+	var total int64
+	for i, c := range sorted {
+		total += c.effective
+		if total >= target {
+			return sorted[:i+1]
+		}
+	}
+	return nil
+}
+
+// selectBranchAndBound searches depth first for the coins whose effective
+// values sum to within changeCost above target, keeping the set with the
+// least excess. It returns nil if there is none.
+func selectBranchAndBound(coins []coin, target, changeCost int64) []coin {
+	var pool []coin
+	var available int64
+	for _, c := range coins {
+		if c.effective > 0 {
+			pool = append(pool, c)
+			available += c.effective
+		}
+	}
+	if available < target {
+		return nil
+	}
+	sort.SliceStable(pool, func(i, j int) bool {
+		return pool[i].effective > pool[j].effective
+	})
+
+	included := make([]bool, len(pool))
+	var best []bool
+	bestExcess := int64(-1)
+	var total int64
+	depth := 0
+	for tries := 0; tries < bnbMaxTries; tries++ {
+		backtrack := false
+		switch {
+		case total+available < target || total > target+changeCost:
+			backtrack = true
+		case total >= target:
+			if excess := total - target; bestExcess < 0 || excess < bestExcess {
+				best = append([]bool(nil), included[:depth]...)
+				bestExcess = excess
+				if excess == 0 {
+					tries = bnbMaxTries
+				}
+			}
+			backtrack = true
+		case depth == len(pool):
+			backtrack = true
+		}
+
+		if backtrack {
+			// Walk back to the last included coin and exclude it instead
+			for depth > 0 && !included[depth-1] {
+				depth--
+				available += pool[depth].effective
+			}
+			if depth == 0 {
+				break
+			}
+			included[depth-1] = false
+			total -= pool[depth-1].effective
+			continue
+		}
+		// Include the next coin first
+		available -= pool[depth].effective
+		included[depth] = true
+		total += pool[depth].effective
+		depth++
+	}
+
+	if best == nil {
+		return nil
+	}
+	var selected []coin
+	for i, in := range best {
+		if in {
+			selected = append(selected, pool[i])
+		}
+	}
+	return selected
+}
+
+// selectKnapsack follows Bitcoin Core's knapsack solver: an exact match or
+// the smallest coin covering target and change if nothing smaller does,
+// else the best of many random subsets of the smaller coins.
+func selectKnapsack(coins []coin, target, minChange int64) []coin {
+	var smaller []coin
+	var smallerTotal int64
+	var lowestLarger *coin
+	for i, c := range coins {
+		switch {
+		case c.effective <= 0:
+			continue
+		case c.effective == target:
+			return []coin{c}
+		case c.effective < target+minChange:
+			smaller = append(smaller, c)
+			smallerTotal += c.effective
+		case lowestLarger == nil || c.effective < lowestLarger.effective:
+			lowestLarger = &coins[i]
+		}
+	}
+
+	if smallerTotal == target {
+		return smaller
+	}
+	if smallerTotal < target {
+		if lowestLarger == nil {
+			return nil
+		}
+		return []coin{*lowestLarger}
+	}
+
+	sort.SliceStable(smaller, func(i, j int) bool {
+		return smaller[i].effective > smaller[j].effective
+	})
+	best, bestTotal := approximateBestSubset(smaller, smallerTotal, target)
+	if bestTotal != target && smallerTotal >= target+minChange {
+		best, bestTotal = approximateBestSubset(smaller, smallerTotal, target+minChange)
+	}
+	// A single larger coin beats a subset that needs change anyway and
+	// spends more
+	if lowestLarger != nil && ((bestTotal != target && bestTotal < target+minChange) || lowestLarger.effective <= bestTotal) {
+		return []coin{*lowestLarger}
+	}
+
+	var selected []coin
+	for i, in := range best {
+		if in {
+			selected = append(selected, smaller[i])
+		}
+	}
+	return selected
+}
+
+// approximateBestSubset draws random subsets of coins, each pass adding
+// the coins the first skipped, and keeps the one closest above target
+func approximateBestSubset(coins []coin, total, target int64) ([]bool, int64) {
+	best := make([]bool, len(coins))
+	for i := range best {
+		best[i] = true
+	}
+	bestTotal := total
+
+	included := make([]bool, len(coins))
+	for rep := 0; rep < knapsackIterations && bestTotal != target; rep++ {
+		clear(included)
+		var sum int64
+		reached := false
+		for pass := 0; pass < 2 && !reached; pass++ {
+			for i := range coins {
+				// The first pass picks at random, the second adds the rest
+				if included[i] || (pass == 0 && rand.IntN(2) == 0) {
+					continue
+				}
+				sum += coins[i].effective
+				included[i] = true
+				if sum >= target {
+					reached = true
+					if sum < bestTotal {
+						bestTotal = sum
+						copy(best, included)
+					}
+					sum -= coins[i].effective
+					included[i] = false
+				}
+			}
+		}
+	}
+	return best, bestTotal
+}
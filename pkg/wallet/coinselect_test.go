@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestParseSelectionStrategy(t *testing.T) {
+	for in, want := range map[string]SelectionStrategy{"": SelectLargestFirst, "bnb": SelectBranchAndBound, "knapsack": SelectKnapsack} {
+		if got, err := ParseSelectionStrategy(in); err != nil || got != want {
+			t.Errorf("ParseSelectionStrategy(%q) = %s, %v, want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseSelectionStrategy("smallest-first"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
+
+// coinTestUTXOs returns taproot outputs of the given values
+func coinTestUTXOs(values ...int64) []UTXO {
+	utxos := make([]UTXO, len(values))
+	for i, v := range values {
+		utxos[i] = UTXO{TxID: testTxID, Vout: uint32(i), Value: v, Address: testTaprootAddr}
+	}
+	return utxos
+}
+
+func TestBuildBatchBranchAndBound(t *testing.T) {
+	// At 1 sat/vB the payout needs 100000 + 42 for the transaction, and each
+	// taproot input 58 more: the two small coins match it exactly
+	utxos := coinTestUTXOs(200000, 50116, 50100)
+	payouts := []Payout{{Address: testWPKHAddr, Amount: 100000}}
+	net := &chaincfg.MainNetParams
+
+	largest, err := BuildBatch(utxos, payouts, testTaprootAddr, 1, net)
+	if err != nil {
+		t.Fatalf("BuildBatch() error = %v", err)
+	}
+	if len(largest.Inputs) != 1 || largest.Change == 0 {
+		t.Errorf("Expected largest first to spend one coin with change, got %d inputs, change %d", len(largest.Inputs), largest.Change)
+	}
+
+	result, err := BuildBatchWithOptions(utxos, payouts, testTaprootAddr, 1, BatchOptions{Strategy: SelectBranchAndBound}, net)
+	if err != nil {
+		t.Fatalf("BuildBatchWithOptions() error = %v", err)
+	}
+	if len(result.Inputs) != 2 || result.Change != 0 || len(result.Packet.UnsignedTx.TxOut) != 1 {
+		t.Errorf("Expected a changeless match of two coins, got %d inputs, change %d", len(result.Inputs), result.Change)
+	}
+	if result.Fee != 216 {
+		t.Errorf("Expected fee 216, got %d", result.Fee)
+	}
+}
+
+func TestBuildBatchStrategies(t *testing.T) {
+	utxos := coinTestUTXOs(5000, 12000, 30000, 75000, 160000, 320000, 640000)
+	payouts := []Payout{{Address: testWPKHAddr, Amount: 250000}}
+	net := &chaincfg.MainNetParams
+
+	for _, strategy := range []SelectionStrategy{SelectLargestFirst, SelectBranchAndBound, SelectKnapsack} {
+		t.Run(string(strategy), func(t *testing.T) {
+			result, err := BuildBatchWithOptions(utxos, payouts, testTaprootAddr, 5, BatchOptions{Strategy: strategy}, net)
+			if err != nil {
+				t.Fatalf("BuildBatchWithOptions() error = %v", err)
+			}
+			var in int64
+			for _, u := range result.Inputs {
+				in += u.Value
+			}
+			if in != 250000+result.Change+result.Fee {
+				t.Errorf("Inputs %d do not balance payout, change %d and fee %d", in, result.Change, result.Fee)
+			}
+			vsize := int64(txOverheadVSize + outputBaseVSize + 22 + len(result.Inputs)*taprootInputVSize)
+			if result.Change > 0 {
+				vsize += outputBaseVSize + 34
+			}
+			if result.Fee < vsize*5 {
+				t.Errorf("Fee %d is below %d sat/vB for %d vB", result.Fee, 5, vsize)
+			}
+		})
+	}
+
+	_, err := BuildBatchWithOptions(utxos, []Payout{{Address: testWPKHAddr, Amount: 2_000_000}}, testTaprootAddr, 5, BatchOptions{Strategy: SelectBranchAndBound}, net)
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+}
+
+func TestBuildBatchAvoidsUneconomicChange(t *testing.T) {
+	// At 20 sat/vB change of 1000 is above dust but costs 1160 to spend
+	utxos := coinTestUTXOs(103860)
+	payouts := []Payout{{Address: testWPKHAddr, Amount: 100000}}
+
+	result, err := BuildBatch(utxos, payouts, testTaprootAddr, 20, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildBatch() error = %v", err)
+	}
+	if result.Change != 0 || result.Fee != 3860 {
+		t.Errorf("Expected the change to go to the fee, got change %d, fee %d", result.Change, result.Fee)
+	}
+}
+
+func TestBuildBatchSpendAll(t *testing.T) {
+	utxos := coinTestUTXOs(500000, 20000)
+	payouts := []Payout{{Address: testWPKHAddr, Amount: 100000}}
+	net := &chaincfg.MainNetParams
+
+	result, err := BuildBatchWithOptions(utxos, payouts, testTaprootAddr, 2, BatchOptions{SpendAll: true}, net)
+	if err != nil {
+		t.Fatalf("BuildBatchWithOptions() error = %v", err)
+	}
+	if len(result.Inputs) != 2 {
+		t.Errorf("Expected both chosen coins to be spent, got %d", len(result.Inputs))
+	}
+
+	_, err = BuildBatchWithOptions(utxos[1:], payouts, testTaprootAddr, 2, BatchOptions{SpendAll: true}, net)
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds for too few chosen coins, got %v", err)
+	}
+}
+
+func TestSelectBranchAndBound(t *testing.T) {
+	var coins []coin
+	for _, v := range []int64{1, 2, 3, 4, 5, 8, 13, 21, 34} {
+		coins = append(coins, coin{utxo: UTXO{TxID: fmt.Sprint(v)}, effective: v * 1000})
+	}
+	selected := selectBranchAndBound(coins, 30000, 0)
+	var total int64
+	for _, c := range selected {
+		total += c.effective
+	}
+	if total != 30000 {
+		t.Errorf("Expected an exact match of 30000, got %d from %d coins", total, len(selected))
+	}
+	if selected := selectBranchAndBound(coins, 30500, 100); selected != nil {
+		t.Errorf("Expected no match within the change window, got %d coins", len(selected))
+	}
+}
@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ForgeCommitmentTag prefixes the OP_RETURN data of a forge commitment so
+// commitments can be told apart from other null-data outputs
+var ForgeCommitmentTag = []byte("EXSF")
+
+// MaxCommitmentSize is the largest commitment that fits a standard OP_RETURN
+// output after the tag
+var MaxCommitmentSize = txscript.MaxDataCarrierSize - len(ForgeCommitmentTag)
+
+// ErrInvalidCommitment indicates an empty or oversized commitment
+var ErrInvalidCommitment = errors.New("invalid commitment")
+
+// CommitmentScript returns the OP_RETURN script committing to data, usually
+// a forge proof hash
+func CommitmentScript(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data) > MaxCommitmentSize {
+		return nil, fmt.Errorf("%w: %d bytes, want 1-%d", ErrInvalidCommitment, len(data), MaxCommitmentSize)
+	}
+	payload := append(append([]byte(nil), ForgeCommitmentTag...), data...)
+	return txscript.NullDataScript(payload)
+}
+
+// ExtractCommitment returns the data of the first forge commitment output in
+// tx, or false if tx carries none
+func ExtractCommitment(tx *wire.MsgTx) ([]byte, bool) {
+	for _, out := range tx.TxOut {
+		if txscript.GetScriptClass(out.PkScript) != txscript.NullDataTy {
+			continue
+		}
+		pushes, err := txscript.PushedData(out.PkScript)
+		if err != nil || len(pushes) != 1 {
+			continue
+		}
+		if data, ok := bytes.CutPrefix(pushes[0], ForgeCommitmentTag); ok && len(data) > 0 {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// VerifyCommitment reports whether tx commits to data
+func VerifyCommitment(tx *wire.MsgTx, data []byte) bool {
+	committed, ok := ExtractCommitment(tx)
+	return ok && bytes.Equal(committed, data)
+}
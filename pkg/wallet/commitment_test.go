@@ -0,0 +1,66 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func TestBuildBatchWithCommitment(t *testing.T) {
+	proof := sha256.Sum256([]byte("forge-proof"))
+	utxos := []UTXO{{TxID: testTxID, Vout: 0, Value: 500000, Address: testTaprootAddr}}
+	payouts := []Payout{{Address: testWPKHAddr, Amount: 100000}}
+
+	plain, err := BuildBatch(utxos, payouts, testTaprootAddr, 2, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildBatch() error = %v", err)
+	}
+	result, err := BuildBatchWithCommitment(utxos, payouts, testTaprootAddr, 2, proof[:], &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildBatchWithCommitment() error = %v", err)
+	}
+
+	tx := result.Packet.UnsignedTx
+	if len(tx.TxOut) != 3 {
+		t.Fatalf("Expected payout, commitment and change, got %d outputs", len(tx.TxOut))
+	}
+	if txscript.GetScriptClass(tx.TxOut[1].PkScript) != txscript.NullDataTy || tx.TxOut[1].Value != 0 {
+		t.Error("Expected a zero-value OP_RETURN output before change")
+	}
+	if result.Change != tx.TxOut[2].Value {
+		t.Errorf("Expected change last, got %d", tx.TxOut[2].Value)
+	}
+	if result.Fee <= plain.Fee {
+		t.Errorf("Expected the commitment to raise the fee, got %d <= %d", result.Fee, plain.Fee)
+	}
+
+	committed, ok := ExtractCommitment(tx)
+	if !ok || !bytes.Equal(committed, proof[:]) {
+		t.Errorf("ExtractCommitment() = %x, %v", committed, ok)
+	}
+	if !VerifyCommitment(tx, proof[:]) {
+		t.Error("Expected commitment to verify")
+	}
+	if VerifyCommitment(tx, []byte("other")) {
+		t.Error("Expected a different commitment to fail")
+	}
+	if _, ok := ExtractCommitment(plain.Packet.UnsignedTx); ok {
+		t.Error("Expected no commitment in a plain batch")
+	}
+}
+
+func TestCommitmentScriptSize(t *testing.T) {
+	if _, err := CommitmentScript(nil); !errors.Is(err, ErrInvalidCommitment) {
+		t.Errorf("Expected ErrInvalidCommitment for empty data, got %v", err)
+	}
+	if _, err := CommitmentScript(make([]byte, MaxCommitmentSize+1)); !errors.Is(err, ErrInvalidCommitment) {
+		t.Errorf("Expected ErrInvalidCommitment for oversized data, got %v", err)
+	}
+	if _, err := CommitmentScript(make([]byte, MaxCommitmentSize)); err != nil {
+		t.Errorf("CommitmentScript() error = %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/fees"
+)
+
+// consolidationOutputVSize is the size of the single Taproot output a
+// consolidation pays to
+const consolidationOutputVSize = outputBaseVSize + 34
+
+// ErrNoFeeEstimates indicates a backend that returned no fee estimates
+var ErrNoFeeEstimates = fees.ErrNoEstimates
+
+// FeeEstimates maps a confirmation target in blocks to the fee rate, in
+// sat/vB, expected to confirm within it
+type FeeEstimates = fees.Estimates
+
+// FeeEstimator reports the fee rates the network currently needs; a
+// *fees.Estimator is one
+type FeeEstimator interface {
+	FeeEstimates(ctx context.Context) (FeeEstimates, error)
+}
+
+// FeeEstimates returns the backend's fee estimates per confirmation target
+func (e *EsploraSource) FeeEstimates(ctx context.Context) (FeeEstimates, error) {
+	var raw map[string]float64
+	if err := e.get(ctx, "/fee-estimates", &raw); err != nil {
+		return nil, err
+	}
+	estimates := make(FeeEstimates, len(raw))
+	for target, rate := range raw {
+		blocks, err := strconv.Atoi(target)
+		if err != nil || blocks <= 0 {
+			continue
+		}
+		estimates[blocks] = rate
+	}
+	if len(estimates) == 0 {
+		return nil, ErrNoFeeEstimates
+	}
+	return estimates, nil
+}
+
+// FeeHistogram returns the backend's mempool by fee rate
+func (e *EsploraSource) FeeHistogram(ctx context.Context) (fees.Histogram, error) {
+	var mempool struct {
+		FeeHistogram [][2]float64 `json:"fee_histogram"`
+	}
+	if err := e.get(ctx, "/mempool", &mempool); err != nil {
+		return nil, err
+	}
+	histogram := make(fees.Histogram, 0, len(mempool.FeeHistogram))
+	for _, bucket := range mempool.FeeHistogram {
+		histogram = append(histogram, fees.Bucket{Rate: bucket[0], VSize: int64(bucket[1])})
+	}
+	return histogram, nil
+}
+
+// ConsolidationPolicy decides which outputs are fragments and when merging
+// them is worth it
+type ConsolidationPolicy struct {
+	// SmallValue is the value in satoshis below which a confirmed output is
+	// a fragment
+	SmallValue int64
+	// MinInputs is the number of fragments worth spending before
+	// consolidation is advised, and MaxInputs the most one transaction
+	// spends
+	MinInputs int
+	MaxInputs int
+	// MaxFeeRate is the highest fee rate, in sat/vB, at which consolidation
+	// is advised
+	MaxFeeRate int64
+}
+
+// DefaultConsolidationPolicy merges 10 to 200 outputs under 0.001 EXS at up
+// to 5 sat/vB
+func DefaultConsolidationPolicy() ConsolidationPolicy {
+	return ConsolidationPolicy{SmallValue: 100_000, MinInputs: 10, MaxInputs: 200, MaxFeeRate: 5}
+}
+
+// ConsolidationAdvice weighs merging a wallet's fragments now against
+// spending them one by one later
+type ConsolidationAdvice struct {
+	// Fragments are the confirmed outputs below the policy's SmallValue,
+	// smallest first
+	Fragments []UTXO
+	// Uneconomic are fragments worth less than the fee to spend them at
+	// SpendRate, which a later payment would have to leave behind
+	Uneconomic []UTXO
+	// Inputs are the fragments worth spending at FeeRate, largest first
+	// and at most MaxInputs, that a consolidation merges
+	Inputs []UTXO
+	Value  int64
+	VSize  int64
+	// Fee is the cost of the consolidation at FeeRate
+	Fee     int64
+	FeeRate int64
+	// SpendRate is the fee rate assumed for later payments, and LaterCost
+	// what spending the inputs in them would cost
+	SpendRate int64
+	LaterCost int64
+	// Savings is LaterCost less the consolidation fee and the cost of
+	// spending the merged output
+	Savings     int64
+	Recommended bool
+	Reason      string
+}
+
+// AdviseConsolidation finds the fragments among utxos and estimates the cost
+// of merging them at feeRate against spending them at spendRate later
+func AdviseConsolidation(utxos []UTXO, feeRate, spendRate int64, policy ConsolidationPolicy, net *chaincfg.Params) (*ConsolidationAdvice, error) {
+	if feeRate <= 0 || spendRate <= 0 {
+		return nil, fmt.Errorf("invalid fee rate %d or spend rate %d", feeRate, spendRate)
+	}
+	advice := &ConsolidationAdvice{FeeRate: feeRate, SpendRate: spendRate}
+	sizes := make(map[UTXO]int64)
+	for _, utxo := range utxos {
+		if utxo.Height <= 0 || utxo.Value >= policy.SmallValue {
+			continue
+		}
+		size, err := utxoVSize(utxo, net)
+		if err != nil {
+			return nil, err
+		}
+		sizes[utxo] = size
+		advice.Fragments = append(advice.Fragments, utxo)
+	}
+	sort.SliceStable(advice.Fragments, func(i, j int) bool {
+		return advice.Fragments[i].Value < advice.Fragments[j].Value
+	})
+
+	for _, utxo := range advice.Fragments {
+		if utxo.Value <= sizes[utxo]*spendRate {
+			advice.Uneconomic = append(advice.Uneconomic, utxo)
+		}
+		if utxo.Value > sizes[utxo]*feeRate {
+			advice.Inputs = append(advice.Inputs, utxo)
+		}
+	}
+	// Keep the largest when capped; the smallest gain the least from merging
+	if policy.MaxInputs > 0 && len(advice.Inputs) > policy.MaxInputs {
+		advice.Inputs = advice.Inputs[len(advice.Inputs)-policy.MaxInputs:]
+	}
+	sort.SliceStable(advice.Inputs, func(i, j int) bool {
+		return advice.Inputs[i].Value > advice.Inputs[j].Value
+	})
+
+	advice.VSize = txOverheadVSize + consolidationOutputVSize
+	var inputVSizes int64
+	for _, utxo := range advice.Inputs {
+		advice.Value += utxo.Value
+		inputVSizes += sizes[utxo]
+	}
+	advice.VSize += inputVSizes
+	advice.Fee = advice.VSize * feeRate
+	advice.LaterCost = inputVSizes * spendRate
+	advice.Savings = advice.LaterCost - advice.Fee - taprootInputVSize*spendRate
+
+	switch {
+	case len(advice.Inputs) < max(policy.MinInputs, 2):
+		advice.Reason = fmt.Sprintf("%d fragment(s) worth spending, below the %d worth merging", len(advice.Inputs), max(policy.MinInputs, 2))
+	case policy.MaxFeeRate > 0 && feeRate > policy.MaxFeeRate:
+		advice.Reason = fmt.Sprintf("fee rate %d sat/vB is above %d sat/vB; schedule it for a low-fee window", feeRate, policy.MaxFeeRate)
+	case advice.Savings <= 0:
+		advice.Reason = fmt.Sprintf("merging costs %d sats, more than it saves at %d sat/vB", advice.Fee, spendRate)
+	default:
+		advice.Recommended = true
+		advice.Reason = fmt.Sprintf("merging %d fragments saves about %d sats at %d sat/vB", len(advice.Inputs), advice.Savings, spendRate)
+	}
+	return advice, nil
+}
+
+// utxoVSize returns the virtual size of spending utxo
+func utxoVSize(utxo UTXO, net *chaincfg.Params) (int64, error) {
+	pkScript, err := addressScript(utxo.Address, net)
+	if err != nil {
+		return 0, fmt.Errorf("utxo %s:%d: %w", utxo.TxID, utxo.Vout, err)
+	}
+	size, err := inputVSize(pkScript)
+	if err != nil {
+		return 0, fmt.Errorf("utxo %s:%d: %w", utxo.TxID, utxo.Vout, err)
+	}
+	return size, nil
+}
+
+// BuildConsolidation builds one unsigned transaction spending every input to
+// a single output at dest, less the fee at feeRate. The transaction signals
+// replace-by-fee.
+func BuildConsolidation(inputs []UTXO, dest string, feeRate int64, net *chaincfg.Params) (*BatchResult, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("no outputs to consolidate")
+	}
+	if feeRate <= 0 {
+		return nil, fmt.Errorf("invalid fee rate %d", feeRate)
+	}
+	destScript, err := addressScript(dest, net)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination: %w", err)
+	}
+
+	tx := wire.NewMsgTx(2)
+	vsize := int64(txOverheadVSize + outputBaseVSize + len(destScript))
+	var prevOuts []*wire.TxOut
+	var total int64
+	for _, utxo := range inputs {
+		pkScript, err := addressScript(utxo.Address, net)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.TxID, utxo.Vout, err)
+		}
+		size, err := inputVSize(pkScript)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.TxID, utxo.Vout, err)
+		}
+		hash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.TxID, utxo.Vout, err)
+		}
+		in := wire.NewTxIn(wire.NewOutPoint(hash, utxo.Vout), nil, nil)
+		in.Sequence = RBFSequence
+		tx.AddTxIn(in)
+		prevOuts = append(prevOuts, wire.NewTxOut(utxo.Value, pkScript))
+		total += utxo.Value
+		vsize += size
+	}
+
+	fee := vsize * feeRate
+	value := total - fee
+	if value < DustLimit {
+		return nil, fmt.Errorf("%w: %d sats of inputs cannot pay a %d sat fee", ErrInsufficientFunds, total, fee)
+	}
+	tx.AddTxOut(wire.NewTxOut(value, destScript))
+
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create psbt: %w", err)
+	}
+	for i, prevOut := range prevOuts {
+		packet.Inputs[i].WitnessUtxo = prevOut
+	}
+	return &BatchResult{
+		Packet:  packet,
+		Inputs:  inputs,
+		Fee:     fee,
+		Payouts: []Payout{{Address: dest, Amount: value}},
+	}, nil
+}
+
+// ScheduledTx is a signed transaction held back until the fee rate the
+// network needs falls to the rate it pays
+type ScheduledTx struct {
+	TxRecord
+	FeeRate float64   `json:"fee_rate"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewScheduledTx schedules a raw transaction in hex paying fee, to be
+// dropped if not broadcast by expires
+func NewScheduledTx(rawHex string, fee int64, expires time.Time) (*ScheduledTx, error) {
+	rec, err := NewTxRecord(rawHex, fee, nil)
+	if err != nil {
+		return nil, err
+	}
+	tx, _ := rec.Tx()
+	return &ScheduledTx{
+		TxRecord: *rec,
+		FeeRate:  float64(fee) / float64(VirtualSize(tx)),
+		Expires:  expires.UTC(),
+	}, nil
+}
+
+// Due reports whether the transaction pays at least rate
+func (s *ScheduledTx) Due(rate float64) bool {
+	return s.FeeRate >= rate
+}
+
+// SaveScheduledTx writes s to dir/<txid>.json
+func SaveScheduledTx(dir string, s *ScheduledTx) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create schedule directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, s.TxID+".json"), data, 0600)
+}
+
+// ListScheduledTxs returns the scheduled transactions in dir, soonest to
+// expire first. A missing directory holds none.
+func ListScheduledTxs(dir string) ([]*ScheduledTx, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var scheduled []*ScheduledTx
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var s ScheduledTx
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("invalid scheduled transaction %s: %w", entry.Name(), err)
+		}
+		scheduled = append(scheduled, &s)
+	}
+	sort.Slice(scheduled, func(i, j int) bool {
+		return scheduled[i].Expires.Before(scheduled[j].Expires)
+	})
+	return scheduled, nil
+}
+
+// RemoveScheduledTx deletes the scheduled transaction txid from dir
+func RemoveScheduledTx(dir, txid string) error {
+	return os.Remove(filepath.Join(dir, txid+".json"))
+}
+
+// ScheduleRun is the outcome of RunSchedule
+type ScheduleRun struct {
+	Broadcast []*ScheduledTx
+	Waiting   []*ScheduledTx
+	Expired   []*ScheduledTx
+	Failed    map[string]error
+}
+
+// RunSchedule broadcasts the transactions scheduled in dir that pay at least
+// rate, and drops those past their expiry. Broadcast and expired
+// transactions leave the schedule; failed ones stay for the next run.
+func RunSchedule(ctx context.Context, dir string, rate float64, broadcast func(ctx context.Context, rawTx string) (string, error), now time.Time) (*ScheduleRun, error) {
+	scheduled, err := ListScheduledTxs(dir)
+	if err != nil {
+		return nil, err
+	}
+	run := &ScheduleRun{Failed: make(map[string]error)}
+	for _, s := range scheduled {
+		switch {
+		case now.After(s.Expires):
+			if err := RemoveScheduledTx(dir, s.TxID); err != nil {
+				return run, err
+			}
+			run.Expired = append(run.Expired, s)
+		case !s.Due(rate):
+			run.Waiting = append(run.Waiting, s)
+		default:
+			if _, err := broadcast(ctx, s.Raw); err != nil {
+				run.Failed[s.TxID] = err
+				continue
+			}
+			if err := RemoveScheduledTx(dir, s.TxID); err != nil {
+				return run, err
+			}
+			run.Broadcast = append(run.Broadcast, s)
+		}
+	}
+	return run, nil
+}
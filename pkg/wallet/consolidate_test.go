@@ -0,0 +1,208 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func fragments(n int, value int64) []UTXO {
+	utxos := make([]UTXO, n)
+	for i := range utxos {
+		utxos[i] = UTXO{TxID: testTxID, Vout: uint32(i), Value: value + int64(i), Address: testTaprootAddr, Height: 100}
+	}
+	return utxos
+}
+
+func TestFeeEstimates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"1":25.5,"6":12,"144":1.5,"504":1.01,"x":3}`)
+	}))
+	defer server.Close()
+
+	estimates, err := NewEsploraSource(server.URL, &chaincfg.MainNetParams).FeeEstimates(context.Background())
+	if err != nil {
+		t.Fatalf("FeeEstimates() error = %v", err)
+	}
+	for blocks, want := range map[int]float64{1: 25.5, 3: 25.5, 6: 12, 100: 12, 144: 1.5, 1000: 1.01} {
+		if got, _ := estimates.Rate(blocks); got != want {
+			t.Errorf("Rate(%d) = %v, want %v", blocks, got, want)
+		}
+	}
+	if got, _ := (FeeEstimates{6: 4}).Rate(1); got != 4 {
+		t.Errorf("Expected the fastest estimate below every target, got %v", got)
+	}
+	if _, err := (FeeEstimates{}).Rate(6); !errors.Is(err, ErrNoFeeEstimates) {
+		t.Errorf("Expected ErrNoFeeEstimates, got %v", err)
+	}
+}
+
+func TestFeeHistogram(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mempool" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"count":3100,"vsize":1800000,"total_fee":9000000,"fee_histogram":[[40.2,600000],[15,700000],[2,500000]]}`)
+	}))
+	defer server.Close()
+
+	histogram, err := NewEsploraSource(server.URL, &chaincfg.MainNetParams).FeeHistogram(context.Background())
+	if err != nil {
+		t.Fatalf("FeeHistogram() error = %v", err)
+	}
+	if len(histogram) != 3 || histogram[1].Rate != 15 || histogram.VSize() != 1800000 {
+		t.Errorf("Unexpected histogram: %+v", histogram)
+	}
+	if rate, ok := histogram.Rate(1); !ok || rate != 15 {
+		t.Errorf("Rate(1) = %v, %v, want 15", rate, ok)
+	}
+}
+
+func TestAdviseConsolidation(t *testing.T) {
+	utxos := append(fragments(12, 5000),
+		UTXO{TxID: testTxID, Vout: 20, Value: 1000, Address: testTaprootAddr, Height: 100},
+		UTXO{TxID: testTxID, Vout: 21, Value: 40, Address: testTaprootAddr, Height: 100},
+		UTXO{TxID: testTxID, Vout: 22, Value: 3000, Address: testTaprootAddr},
+		UTXO{TxID: testTxID, Vout: 23, Value: 5_000_000, Address: testTaprootAddr, Height: 100},
+	)
+	policy := DefaultConsolidationPolicy()
+
+	advice, err := AdviseConsolidation(utxos, 1, 20, policy, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("AdviseConsolidation() error = %v", err)
+	}
+	// Unconfirmed and large outputs are not fragments
+	if len(advice.Fragments) != 14 || advice.Fragments[0].Value != 40 {
+		t.Fatalf("Expected 14 fragments smallest first, got %+v", advice.Fragments)
+	}
+	// 1000 and 40 sats cost more than 58 vB at 20 sat/vB; 40 is not even worth
+	// spending at 1 sat/vB
+	if len(advice.Uneconomic) != 2 || len(advice.Inputs) != 13 {
+		t.Fatalf("Expected 2 uneconomic fragments and 13 inputs, got %d and %d", len(advice.Uneconomic), len(advice.Inputs))
+	}
+	if advice.Inputs[0].Value < advice.Inputs[len(advice.Inputs)-1].Value {
+		t.Error("Expected inputs largest first")
+	}
+	wantVSize := int64(txOverheadVSize + consolidationOutputVSize + 13*taprootInputVSize)
+	if advice.VSize != wantVSize || advice.Fee != wantVSize {
+		t.Errorf("Expected vsize and fee %d, got %d and %d", wantVSize, advice.VSize, advice.Fee)
+	}
+	if advice.LaterCost != 13*taprootInputVSize*20 || !advice.Recommended {
+		t.Errorf("Expected a recommended consolidation, got %+v", advice)
+	}
+
+	expensive, _ := AdviseConsolidation(utxos, 8, 20, policy, &chaincfg.MainNetParams)
+	if expensive.Recommended {
+		t.Error("Expected no recommendation above the policy's fee rate")
+	}
+	pointless, _ := AdviseConsolidation(utxos, 5, 5, policy, &chaincfg.MainNetParams)
+	if pointless.Recommended || pointless.Savings > 0 {
+		t.Errorf("Expected no savings at equal rates, got %d", pointless.Savings)
+	}
+	few, _ := AdviseConsolidation(fragments(3, 5000), 1, 20, policy, &chaincfg.MainNetParams)
+	if few.Recommended {
+		t.Error("Expected no recommendation below MinInputs")
+	}
+
+	policy.MaxInputs = 5
+	capped, _ := AdviseConsolidation(utxos, 1, 20, policy, &chaincfg.MainNetParams)
+	if len(capped.Inputs) != 5 || capped.Inputs[4].Value != 5007 {
+		t.Errorf("Expected the 5 largest inputs, got %+v", capped.Inputs)
+	}
+}
+
+func TestBuildConsolidation(t *testing.T) {
+	inputs := fragments(3, 10000)
+	result, err := BuildConsolidation(inputs, testWPKHAddr, 2, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildConsolidation() error = %v", err)
+	}
+	tx := result.Packet.UnsignedTx
+	if len(tx.TxIn) != 3 || len(tx.TxOut) != 1 {
+		t.Fatalf("Expected 3 inputs and 1 output, got %d and %d", len(tx.TxIn), len(tx.TxOut))
+	}
+	if tx.TxOut[0].Value+result.Fee != 30003 {
+		t.Errorf("Expected inputs = output + fee, got %d + %d", tx.TxOut[0].Value, result.Fee)
+	}
+	if tx.TxIn[0].Sequence != RBFSequence || result.Packet.Inputs[2].WitnessUtxo == nil {
+		t.Error("Expected RBF signalling and witness UTXOs")
+	}
+
+	if _, err := BuildConsolidation(fragments(2, 600), testWPKHAddr, 10, &chaincfg.MainNetParams); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+	if _, err := BuildConsolidation(nil, testWPKHAddr, 1, &chaincfg.MainNetParams); err == nil {
+		t.Error("Expected an error without inputs")
+	}
+}
+
+func scheduledTx(t *testing.T, vout uint32, fee int64, expires time.Time) *ScheduledTx {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, vout), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(10000, []byte{0x51}))
+	var buf bytes.Buffer
+	tx.Serialize(&buf)
+	s, err := NewScheduledTx(hex.EncodeToString(buf.Bytes()), fee, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRunSchedule(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	cheap := scheduledTx(t, 0, 60, now.Add(time.Hour))
+	rich := scheduledTx(t, 1, 6000, now.Add(time.Hour))
+	stale := scheduledTx(t, 2, 6000, now.Add(-time.Hour))
+	failing := scheduledTx(t, 3, 6000, now.Add(2*time.Hour))
+	for _, s := range []*ScheduledTx{cheap, rich, stale, failing} {
+		if err := SaveScheduledTx(dir, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cheap.Due(2) || !cheap.Due(cheap.FeeRate) {
+		t.Errorf("Unexpected Due for a %v sat/vB transaction", cheap.FeeRate)
+	}
+
+	var sent []string
+	broadcast := func(ctx context.Context, raw string) (string, error) {
+		if raw == failing.Raw {
+			return "", errors.New("mempool full")
+		}
+		sent = append(sent, raw)
+		return "", nil
+	}
+	run, err := RunSchedule(context.Background(), dir, 2, broadcast, now)
+	if err != nil {
+		t.Fatalf("RunSchedule() error = %v", err)
+	}
+	if len(run.Broadcast) != 1 || run.Broadcast[0].TxID != rich.TxID || len(sent) != 1 {
+		t.Errorf("Expected only the due transaction broadcast, got %+v", run.Broadcast)
+	}
+	if len(run.Waiting) != 1 || len(run.Expired) != 1 || run.Failed[failing.TxID] == nil {
+		t.Errorf("Unexpected run %+v", run)
+	}
+
+	left, err := ListScheduledTxs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0].TxID != cheap.TxID || left[1].TxID != failing.TxID {
+		t.Errorf("Expected the waiting and failed transactions to stay, got %d", len(left))
+	}
+	if none, err := ListScheduledTxs(dir + "/missing"); none != nil || err != nil {
+		t.Errorf("Expected nothing scheduled in a missing directory, got %v, %v", none, err)
+	}
+}
@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ErrFeeRateMet indicates a parent already pays the requested fee rate
+var ErrFeeRateMet = errors.New("parent already meets target fee rate")
+
+// CPFPResult is an unsigned child transaction that bumps a stuck parent
+type CPFPResult struct {
+	Packet          *psbt.Packet
+	ParentVSize     int64
+	ChildVSize      int64
+	ChildFee        int64
+	PackageFeeRate  float64
+	ParentFeeRate   float64
+	SpentOutput     uint32
+	DestinationPaid int64
+}
+
+// VirtualSize returns the BIP-141 virtual size of a transaction
+func VirtualSize(tx *wire.MsgTx) int64 {
+	weight := int64(tx.SerializeSizeStripped()*3 + tx.SerializeSize())
+	return (weight + 3) / 4
+}
+
+// BuildCPFP builds a child spending output vout of parent to dest with a fee
+// that lifts the parent+child package to targetRate sat/vB. parentFee is the
+// fee the parent already pays. The child signals replace-by-fee.
+func BuildCPFP(parent *wire.MsgTx, vout uint32, parentFee, targetRate int64, dest string, net *chaincfg.Params) (*CPFPResult, error) {
+	if int(vout) >= len(parent.TxOut) {
+		return nil, fmt.Errorf("parent has no output %d", vout)
+	}
+	if targetRate <= 0 || parentFee < 0 {
+		return nil, fmt.Errorf("invalid fee parameters")
+	}
+
+	spent := parent.TxOut[vout]
+	inVSize, err := inputVSize(spent.PkScript)
+	if err != nil {
+		return nil, fmt.Errorf("output %d: %w", vout, err)
+	}
+	destScript, err := addressScript(dest, net)
+	if err != nil {
+		return nil, err
+	}
+
+	parentVSize := VirtualSize(parent)
+	if parentFee >= parentVSize*targetRate {
+		return nil, fmt.Errorf("%w: %.1f sat/vB", ErrFeeRateMet, float64(parentFee)/float64(parentVSize))
+	}
+
+	childVSize := int64(txOverheadVSize) + inVSize + int64(outputBaseVSize+len(destScript))
+	childFee := (parentVSize+childVSize)*targetRate - parentFee
+	value := spent.Value - childFee
+	if value < DustLimit {
+		return nil, fmt.Errorf("%w: output %d holds %d, child fee is %d", ErrInsufficientFunds, vout, spent.Value, childFee)
+	}
+
+	parentHash := parent.TxHash()
+	tx := wire.NewMsgTx(2)
+	in := wire.NewTxIn(wire.NewOutPoint(&parentHash, vout), nil, nil)
+	in.Sequence = RBFSequence
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(value, destScript))
+
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create psbt: %w", err)
+	}
+	packet.Inputs[0].WitnessUtxo = wire.NewTxOut(spent.Value, spent.PkScript)
+
+	return &CPFPResult{
+		Packet:          packet,
+		ParentVSize:     parentVSize,
+		ChildVSize:      childVSize,
+		ChildFee:        childFee,
+		PackageFeeRate:  float64(parentFee+childFee) / float64(parentVSize+childVSize),
+		ParentFeeRate:   float64(parentFee) / float64(parentVSize),
+		SpentOutput:     vout,
+		DestinationPaid: value,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testParent builds a one-input parent paying a payout and a change output
+func testParent(t *testing.T) *wire.MsgTx {
+	payout, _ := addressScript(testWPKHAddr, &chaincfg.MainNetParams)
+	change, _ := addressScript(testTaprootAddr, &chaincfg.MainNetParams)
+
+	prevHash := chainhash.DoubleHashH([]byte("parent-prev"))
+	tx := wire.NewMsgTx(2)
+	in := wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), nil, nil)
+	in.Witness = wire.TxWitness{bytes.Repeat([]byte{1}, 64)}
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(100000, payout))
+	tx.AddTxOut(wire.NewTxOut(50000, change))
+	return tx
+}
+
+func TestVirtualSize(t *testing.T) {
+	tx := testParent(t)
+	// 1 key-path input, P2WPKH and P2TR outputs
+	if vsize := VirtualSize(tx); vsize != 142 {
+		t.Errorf("Expected vsize 142, got %d", vsize)
+	}
+}
+
+func TestBuildCPFP(t *testing.T) {
+	parent := testParent(t)
+	parentVSize := VirtualSize(parent)
+
+	result, err := BuildCPFP(parent, 1, parentVSize, 20, testTaprootAddr, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildCPFP() error = %v", err)
+	}
+
+	if result.PackageFeeRate < 20 {
+		t.Errorf("Expected package rate >= 20, got %.2f", result.PackageFeeRate)
+	}
+	if result.ParentFeeRate != 1 {
+		t.Errorf("Expected parent rate 1, got %.2f", result.ParentFeeRate)
+	}
+
+	tx := result.Packet.UnsignedTx
+	if tx.TxIn[0].PreviousOutPoint.Hash != parent.TxHash() || tx.TxIn[0].PreviousOutPoint.Index != 1 {
+		t.Error("Child does not spend the parent's change output")
+	}
+	if tx.TxOut[0].Value+result.ChildFee != 50000 {
+		t.Errorf("Expected output + fee = 50000, got %d + %d", tx.TxOut[0].Value, result.ChildFee)
+	}
+	if result.Packet.Inputs[0].WitnessUtxo == nil {
+		t.Error("Expected witness UTXO on child input")
+	}
+}
+
+func TestBuildCPFPErrors(t *testing.T) {
+	parent := testParent(t)
+
+	if _, err := BuildCPFP(parent, 1, 10000, 20, testTaprootAddr, &chaincfg.MainNetParams); !errors.Is(err, ErrFeeRateMet) {
+		t.Errorf("Expected ErrFeeRateMet, got %v", err)
+	}
+	if _, err := BuildCPFP(parent, 1, 0, 1000, testTaprootAddr, &chaincfg.MainNetParams); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+	if _, err := BuildCPFP(parent, 5, 0, 20, testTaprootAddr, &chaincfg.MainNetParams); err == nil {
+		t.Error("Expected error for missing output")
+	}
+}
+
+func TestTxRecord(t *testing.T) {
+	parent := testParent(t)
+	var buf bytes.Buffer
+	parent.Serialize(&buf)
+
+	change := uint32(1)
+	rec, err := NewTxRecord(hex.EncodeToString(buf.Bytes()), 1500, &change)
+	if err != nil {
+		t.Fatalf("NewTxRecord() error = %v", err)
+	}
+	if rec.TxID != parent.TxHash().String() {
+		t.Errorf("Expected txid %s, got %s", parent.TxHash(), rec.TxID)
+	}
+
+	dir := t.TempDir()
+	if err := SaveTxRecord(dir, rec); err != nil {
+		t.Fatalf("SaveTxRecord() error = %v", err)
+	}
+	loaded, err := LoadTxRecord(dir, rec.TxID)
+	if err != nil {
+		t.Fatalf("LoadTxRecord() error = %v", err)
+	}
+	if loaded.Fee != 1500 || loaded.ChangeIndex == nil || *loaded.ChangeIndex != 1 {
+		t.Errorf("Unexpected record: %+v", loaded)
+	}
+}
+
+func TestListTxRecords(t *testing.T) {
+	dir := t.TempDir()
+	if records, err := ListTxRecords(dir + "/missing"); err != nil || len(records) != 0 {
+		t.Fatalf("Expected no records for missing dir, got %v, %v", records, err)
+	}
+
+	for i, fee := range []int64{1000, 2000} {
+		tx := testParent(t)
+		tx.TxOut[0].Value += int64(i)
+		var buf bytes.Buffer
+		tx.Serialize(&buf)
+		rec, err := NewTxRecord(hex.EncodeToString(buf.Bytes()), fee, nil)
+		if err != nil {
+			t.Fatalf("NewTxRecord() error = %v", err)
+		}
+		rec.CreatedAt = rec.CreatedAt.Add(time.Duration(i) * time.Hour)
+		if err := SaveTxRecord(dir, rec); err != nil {
+			t.Fatalf("SaveTxRecord() error = %v", err)
+		}
+	}
+
+	records, err := ListTxRecords(dir)
+	if err != nil {
+		t.Fatalf("ListTxRecords() error = %v", err)
+	}
+	if len(records) != 2 || records[0].Fee != 2000 {
+		t.Errorf("Expected 2 records newest first, got %+v", records)
+	}
+}
@@ -0,0 +1,380 @@
+// Package wallet implements Excalibur-EXS wallet primitives: output
+// descriptors, address derivation and chain rescanning.
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+var (
+	// ErrInvalidDescriptor indicates a malformed output descriptor
+	ErrInvalidDescriptor = errors.New("invalid descriptor")
+	// ErrChecksumMismatch indicates the descriptor checksum does not match
+	ErrChecksumMismatch = errors.New("descriptor checksum mismatch")
+	// ErrNotRanged indicates a ranged operation on a fixed descriptor
+	ErrNotRanged = errors.New("descriptor is not ranged")
+)
+
+// DescriptorType identifies the output script template of a descriptor
+type DescriptorType string
+
+const (
+	// DescriptorTR is a BIP-86 key-path-only Taproot output: tr(KEY)
+	DescriptorTR DescriptorType = "tr"
+	// DescriptorWPKH is a native SegWit v0 pay-to-witness-pubkey-hash output: wpkh(KEY)
+	DescriptorWPKH DescriptorType = "wpkh"
+)
+
+// Descriptor is a parsed output descriptor (BIP-380/381/382/386 subset)
+type Descriptor struct {
+	Type DescriptorType
+	// Origin is the optional key origin, e.g. "d34db33f/86'/0'/0'"
+	Origin string
+	// Ranged reports whether the key path ends in a wildcard (*)
+	Ranged bool
+	// Private reports whether the descriptor was given with a private
+	// extended key. Only its public key is kept, and String never includes
+	// private material.
+	Private bool
+
+	pubKey   *btcec.PublicKey        // fixed key, when no extended key is used
+	extKey   *hdkeychain.ExtendedKey // extended public key
+	prefix   []uint32                // derivation steps before the multi-path element
+	branches []uint32                // multi-path alternatives (<0;1>), or a single step
+	suffix   []uint32                // derivation steps after the multi-path element
+	hasMulti bool
+	keyExpr  string
+}
+
+// DerivedAddress is a single address produced by a descriptor
+type DerivedAddress struct {
+	Branch   int
+	Index    uint32
+	Address  string
+	PkScript []byte
+}
+
+// ParseDescriptor parses a tr() or wpkh() descriptor with an optional
+// trailing "#checksum". Extended keys may use ranged (/*) and multi-path
+// (/<0;1>/*) derivation.
+func ParseDescriptor(desc string) (*Descriptor, error) {
+	desc = strings.TrimSpace(desc)
+
+	if i := strings.IndexByte(desc, '#'); i >= 0 {
+		body, sum := desc[:i], desc[i+1:]
+		expected, err := DescriptorChecksum(body)
+		if err != nil {
+			return nil, err
+		}
+		if sum != expected {
+			return nil, fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, sum, expected)
+		}
+		desc = body
+	}
+
+	open := strings.IndexByte(desc, '(')
+	if open < 0 || !strings.HasSuffix(desc, ")") {
+		return nil, fmt.Errorf("%w: expected func(KEY)", ErrInvalidDescriptor)
+	}
+
+	d := &Descriptor{Type: DescriptorType(desc[:open])}
+	switch d.Type {
+	case DescriptorTR, DescriptorWPKH:
+	default:
+		return nil, fmt.Errorf("%w: unsupported script type %q", ErrInvalidDescriptor, d.Type)
+	}
+
+	keyExpr := desc[open+1 : len(desc)-1]
+	if strings.ContainsAny(keyExpr, ",{}()") {
+		return nil, fmt.Errorf("%w: script trees and nested expressions are not supported", ErrInvalidDescriptor)
+	}
+	if err := d.parseKey(keyExpr); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// parseKey parses a KEY expression: [origin]key/path
+func (d *Descriptor) parseKey(expr string) error {
+	d.keyExpr = expr
+	if strings.HasPrefix(expr, "[") {
+		end := strings.IndexByte(expr, ']')
+		if end < 0 {
+			return fmt.Errorf("%w: unterminated key origin", ErrInvalidDescriptor)
+		}
+		d.Origin = expr[1:end]
+		fp := strings.SplitN(d.Origin, "/", 2)[0]
+		if len(fp) != 8 {
+			return fmt.Errorf("%w: key origin fingerprint must be 8 hex characters", ErrInvalidDescriptor)
+		}
+		if _, err := hex.DecodeString(fp); err != nil {
+			return fmt.Errorf("%w: invalid key origin fingerprint", ErrInvalidDescriptor)
+		}
+		expr = expr[end+1:]
+	}
+
+	parts := strings.Split(expr, "/")
+	key := parts[0]
+
+	// Plain hex public key
+	if raw, err := hex.DecodeString(key); err == nil {
+		if len(parts) > 1 {
+			return fmt.Errorf("%w: derivation steps require an extended key", ErrInvalidDescriptor)
+		}
+		switch {
+		case len(raw) == 32 && d.Type == DescriptorTR:
+			d.pubKey, err = schnorr.ParsePubKey(raw)
+		case len(raw) == 33:
+			d.pubKey, err = btcec.ParsePubKey(raw)
+		default:
+			return fmt.Errorf("%w: invalid public key length %d", ErrInvalidDescriptor, len(raw))
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+		}
+		return nil
+	}
+
+	ext, err := hdkeychain.NewKeyFromString(key)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+	if ext.IsPrivate() {
+		if ext, err = ext.Neuter(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+		}
+		d.Private = true
+		d.keyExpr = strings.Replace(d.keyExpr, key, ext.String(), 1)
+	}
+	d.extKey = ext
+
+	for i, step := range parts[1:] {
+		last := i == len(parts)-2
+		switch {
+		case step == "*":
+			if !last {
+				return fmt.Errorf("%w: wildcard must be the final path element", ErrInvalidDescriptor)
+			}
+			d.Ranged = true
+		case strings.HasPrefix(step, "<") && strings.HasSuffix(step, ">"):
+			if d.hasMulti {
+				return fmt.Errorf("%w: only one multi-path element is allowed", ErrInvalidDescriptor)
+			}
+			alts := strings.Split(step[1:len(step)-1], ";")
+			if len(alts) < 2 {
+				return fmt.Errorf("%w: multi-path element needs at least two alternatives", ErrInvalidDescriptor)
+			}
+			for _, alt := range alts {
+				n, err := parsePathStep(alt)
+				if err != nil {
+					return err
+				}
+				d.branches = append(d.branches, n)
+			}
+			d.hasMulti = true
+		default:
+			n, err := parsePathStep(step)
+			if err != nil {
+				return err
+			}
+			if d.hasMulti {
+				d.suffix = append(d.suffix, n)
+			} else {
+				d.prefix = append(d.prefix, n)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parsePathStep parses a single unhardened BIP-32 path element
+func parsePathStep(step string) (uint32, error) {
+	if strings.HasSuffix(step, "'") || strings.HasSuffix(step, "h") {
+		return 0, fmt.Errorf("%w: hardened step %q cannot be derived from a public key", ErrInvalidDescriptor, step)
+	}
+	n, err := strconv.ParseUint(step, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid path element %q", ErrInvalidDescriptor, step)
+	}
+	return uint32(n), nil
+}
+
+// Branches returns the number of derivation branches (2 for /<0;1>/*)
+func (d *Descriptor) Branches() int {
+	if len(d.branches) == 0 {
+		return 1
+	}
+	return len(d.branches)
+}
+
+// IsMultiPath reports whether the descriptor expands into several branches
+func (d *Descriptor) IsMultiPath() bool {
+	return d.hasMulti
+}
+
+// String returns the canonical descriptor with its checksum appended
+func (d *Descriptor) String() string {
+	body := fmt.Sprintf("%s(%s)", d.Type, d.keyExpr)
+	sum, _ := DescriptorChecksum(body)
+	return body + "#" + sum
+}
+
+// Derive returns the address at index on the given branch. Non-ranged
+// descriptors ignore index.
+func (d *Descriptor) Derive(branch int, index uint32, net *chaincfg.Params) (*DerivedAddress, error) {
+	if branch < 0 || branch >= d.Branches() {
+		return nil, fmt.Errorf("branch %d out of range (descriptor has %d)", branch, d.Branches())
+	}
+
+	pubKey := d.pubKey
+	if d.extKey != nil {
+		key := d.extKey
+		path := append([]uint32{}, d.prefix...)
+		if d.hasMulti {
+			path = append(path, d.branches[branch])
+			path = append(path, d.suffix...)
+		}
+		if d.Ranged {
+			if index >= hdkeychain.HardenedKeyStart {
+				return nil, fmt.Errorf("index %d exceeds unhardened range", index)
+			}
+			path = append(path, index)
+		}
+		for _, step := range path {
+			var err error
+			if key, err = key.Derive(step); err != nil {
+				return nil, fmt.Errorf("failed to derive child %d: %w", step, err)
+			}
+		}
+		var err error
+		if pubKey, err = key.ECPubKey(); err != nil {
+			return nil, fmt.Errorf("failed to extract public key: %w", err)
+		}
+	}
+	if !d.Ranged {
+		index = 0
+	}
+
+	addr, err := d.address(pubKey, net)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build output script: %w", err)
+	}
+
+	return &DerivedAddress{
+		Branch:   branch,
+		Index:    index,
+		Address:  addr.EncodeAddress(),
+		PkScript: pkScript,
+	}, nil
+}
+
+// DeriveRange derives addresses [start, end) on a branch
+func (d *Descriptor) DeriveRange(branch int, start, end uint32, net *chaincfg.Params) ([]*DerivedAddress, error) {
+	if !d.Ranged {
+		return nil, ErrNotRanged
+	}
+	if end < start {
+		return nil, fmt.Errorf("invalid range [%d, %d)", start, end)
+	}
+
+	addrs := make([]*DerivedAddress, 0, end-start)
+	for i := start; i < end; i++ {
+		addr, err := d.Derive(branch, i, net)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// address builds the output address for a derived public key
+func (d *Descriptor) address(pubKey *btcec.PublicKey, net *chaincfg.Params) (btcutil.Address, error) {
+	switch d.Type {
+	case DescriptorTR:
+		// BIP-86: key-path only, tweaked with an empty script tree
+		outputKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+		return btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), net)
+	case DescriptorWPKH:
+		return btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), net)
+	default:
+		return nil, fmt.Errorf("unsupported descriptor type: %s", d.Type)
+	}
+}
+
+const (
+	descInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// descPolyMod is the BCH code generator used by descriptor checksums (BIP-380)
+func descPolyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// DescriptorChecksum computes the 8-character BIP-380 checksum of a descriptor
+func DescriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+
+	for _, ch := range desc {
+		pos := strings.IndexRune(descInputCharset, ch)
+		if pos < 0 {
+			return "", fmt.Errorf("%w: invalid character %q", ErrInvalidDescriptor, ch)
+		}
+		c = descPolyMod(c, pos&31)
+		cls = cls*3 + (pos >> 5)
+		clsCount++
+		if clsCount == 3 {
+			c = descPolyMod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = descPolyMod(c, cls)
+	}
+	for i := 0; i < 8; i++ {
+		c = descPolyMod(c, 0)
+	}
+	c ^= 1
+
+	sum := make([]byte, 8)
+	for i := 0; i < 8; i++ {
+		sum[i] = descChecksumCharset[(c>>(5*(7-i)))&31]
+	}
+	return string(sum), nil
+}
@@ -0,0 +1,195 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// BIP-86 test vector account xpub (m/86'/0'/0') for the
+// "abandon abandon ... about" mnemonic
+const bip86AccountXpub = "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ"
+
+func TestDescriptorChecksum(t *testing.T) {
+	// BIP-380 test vector
+	sum, err := DescriptorChecksum("raw(deadbeef)")
+	if err != nil {
+		t.Fatalf("DescriptorChecksum() error = %v", err)
+	}
+	if sum != "89f8spxm" {
+		t.Errorf("DescriptorChecksum() = %s, want 89f8spxm", sum)
+	}
+
+	if _, err := DescriptorChecksum("tr(é)"); err == nil {
+		t.Error("Expected error for character outside the descriptor charset")
+	}
+}
+
+func TestParseDescriptorBIP86(t *testing.T) {
+	d, err := ParseDescriptor("tr([73c5da0a/86'/0'/0']" + bip86AccountXpub + "/<0;1>/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+
+	if !d.Ranged || !d.IsMultiPath() || d.Branches() != 2 {
+		t.Fatalf("Expected ranged multi-path descriptor with 2 branches, got ranged=%v branches=%d", d.Ranged, d.Branches())
+	}
+	if d.Origin != "73c5da0a/86'/0'/0'" {
+		t.Errorf("Origin = %s", d.Origin)
+	}
+
+	tests := []struct {
+		branch int
+		index  uint32
+		want   string
+	}{
+		{0, 0, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+		{0, 1, "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh"},
+		{1, 0, "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7"},
+	}
+	for _, tt := range tests {
+		addr, err := d.Derive(tt.branch, tt.index, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("Derive(%d, %d) error = %v", tt.branch, tt.index, err)
+		}
+		if addr.Address != tt.want {
+			t.Errorf("Derive(%d, %d) = %s, want %s", tt.branch, tt.index, addr.Address, tt.want)
+		}
+	}
+}
+
+func TestParseDescriptorWPKH(t *testing.T) {
+	seed := []byte{
+		0x5e, 0xb0, 0x0b, 0xbd, 0xdc, 0xf0, 0x69, 0x08, 0x48, 0x89, 0xa8, 0xab, 0x91, 0x55, 0x56, 0x81,
+		0x65, 0xf5, 0xc4, 0x53, 0xcc, 0xb8, 0x5e, 0x70, 0x81, 0x1a, 0xae, 0xd6, 0xf6, 0xda, 0x5f, 0xc1,
+		0x9a, 0x5a, 0xc4, 0x0b, 0x38, 0x9c, 0xd3, 0x70, 0xd0, 0x86, 0x20, 0x6d, 0xec, 0x8a, 0xa6, 0xc4,
+		0x3d, 0xae, 0xa6, 0x69, 0x0f, 0x20, 0xad, 0x3d, 0x8d, 0x48, 0xb2, 0xd2, 0xce, 0x9e, 0x38, 0xe4,
+	}
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewMaster() error = %v", err)
+	}
+	account := master
+	for _, step := range []uint32{84, 0, 0} {
+		if account, err = account.Derive(hdkeychain.HardenedKeyStart + step); err != nil {
+			t.Fatalf("Derive() error = %v", err)
+		}
+	}
+	xpub, err := account.Neuter()
+	if err != nil {
+		t.Fatalf("Neuter() error = %v", err)
+	}
+
+	d, err := ParseDescriptor("wpkh(" + xpub.String() + "/0/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+
+	// BIP-84 test vector: m/84'/0'/0'/0/0
+	addr, err := d.Derive(0, 0, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Derive() error = %v", err)
+	}
+	if addr.Address != "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu" {
+		t.Errorf("Derive() = %s, want bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", addr.Address)
+	}
+}
+
+func TestParseDescriptorRoundTrip(t *testing.T) {
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/0/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+
+	again, err := ParseDescriptor(d.String())
+	if err != nil {
+		t.Fatalf("ParseDescriptor(String()) error = %v", err)
+	}
+	if again.String() != d.String() {
+		t.Errorf("Round trip mismatch: %s != %s", again.String(), d.String())
+	}
+
+	addrs, err := d.DeriveRange(0, 0, 5, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("DeriveRange() error = %v", err)
+	}
+	if len(addrs) != 5 {
+		t.Errorf("Expected 5 addresses, got %d", len(addrs))
+	}
+}
+
+func TestParseDescriptorFixedKey(t *testing.T) {
+	d, err := ParseDescriptor("wpkh(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+	if d.Ranged {
+		t.Error("Fixed key descriptor should not be ranged")
+	}
+	if _, err := d.DeriveRange(0, 0, 10, &chaincfg.MainNetParams); !errors.Is(err, ErrNotRanged) {
+		t.Errorf("Expected ErrNotRanged, got %v", err)
+	}
+
+	addr, err := d.Derive(0, 7, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Derive() error = %v", err)
+	}
+	if addr.Address != "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4" {
+		t.Errorf("Derive() = %s", addr.Address)
+	}
+}
+
+func TestParseDescriptorErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		desc string
+		want error
+	}{
+		{"bad checksum", "tr(" + bip86AccountXpub + "/0/*)#aaaaaaaa", ErrChecksumMismatch},
+		{"unsupported type", "pkh(" + bip86AccountXpub + ")", ErrInvalidDescriptor},
+		{"script tree", "tr(" + bip86AccountXpub + ",{pk(00)})", ErrInvalidDescriptor},
+		{"hardened step", "tr(" + bip86AccountXpub + "/0'/*)", ErrInvalidDescriptor},
+		{"wildcard not last", "tr(" + bip86AccountXpub + "/*/0)", ErrInvalidDescriptor},
+		{"two multi-paths", "tr(" + bip86AccountXpub + "/<0;1>/<2;3>/*)", ErrInvalidDescriptor},
+		{"bad origin", "tr([zz/86']" + bip86AccountXpub + "/*)", ErrInvalidDescriptor},
+		{"path on hex key", "wpkh(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798/0)", ErrInvalidDescriptor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDescriptor(tt.desc)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ParseDescriptor() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDescriptorPrivateKey(t *testing.T) {
+	master, err := hdkeychain.NewMaster(make([]byte, 32), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewMaster() error = %v", err)
+	}
+	xpub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("Neuter() error = %v", err)
+	}
+
+	d, err := ParseDescriptor("tr(" + master.String() + "/0/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+	if !d.Private {
+		t.Error("Expected Private to be set for an xprv")
+	}
+	// The private key must never be written back out
+	public, err := ParseDescriptor("tr(" + xpub.String() + "/0/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+	if d.String() != public.String() || public.Private {
+		t.Errorf("String() = %s, want %s", d.String(), public.String())
+	}
+}
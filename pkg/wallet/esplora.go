@@ -0,0 +1,352 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+)
+
+// EsploraSource is a ChainSource backed by an Esplora HTTP API, as served by
+// electrs, mempool.space and blockstream.info
+type EsploraSource struct {
+	baseURL string
+	net     *chaincfg.Params
+	client  *http.Client
+}
+
+var (
+	// ErrTxNotFound indicates a transaction neither in the mempool nor in a
+	// block
+	ErrTxNotFound = errors.New("transaction not found")
+	// ErrTxRejected indicates a broadcast the node refused, as opposed to
+	// one that may not have reached it
+	ErrTxRejected = errors.New("transaction rejected")
+)
+
+// TxStatus is where a transaction is: in the mempool when not Confirmed,
+// otherwise in block BlockHash at BlockHeight
+type TxStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int32  `json:"block_height,omitempty"`
+	BlockHash   string `json:"block_hash,omitempty"`
+}
+
+// NewEsploraSource creates a source for the API at baseURL, e.g.
+// "https://blockstream.info/api"
+func NewEsploraSource(baseURL string, net *chaincfg.Params) *EsploraSource {
+	return &EsploraSource{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		net:     net,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// DefaultEsploraURL returns the public Esplora API for net, or "" for
+// networks without one
+func DefaultEsploraURL(net *chaincfg.Params) string {
+	switch net.Net {
+	case chaincfg.MainNetParams.Net:
+		return "https://blockstream.info/api"
+	case chaincfg.TestNet3Params.Net:
+		return "https://blockstream.info/testnet/api"
+	default:
+		return ""
+	}
+}
+
+// ScriptUsed reports whether the script's address has any confirmed or
+// mempool transactions
+func (e *EsploraSource) ScriptUsed(ctx context.Context, pkScript []byte) (bool, error) {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, e.net)
+	if err != nil || len(addrs) != 1 {
+		return false, fmt.Errorf("script has no address")
+	}
+
+	var stats struct {
+		ChainStats struct {
+			TxCount int `json:"tx_count"`
+		} `json:"chain_stats"`
+		MempoolStats struct {
+			TxCount int `json:"tx_count"`
+		} `json:"mempool_stats"`
+	}
+	if err := e.get(ctx, "/address/"+addrs[0].EncodeAddress(), &stats); err != nil {
+		return false, err
+	}
+	return stats.ChainStats.TxCount+stats.MempoolStats.TxCount > 0, nil
+}
+
+// Unspent lists the unspent outputs paying address
+func (e *EsploraSource) Unspent(ctx context.Context, address string) ([]UTXO, error) {
+	var outputs []struct {
+		TxID   string `json:"txid"`
+		Vout   uint32 `json:"vout"`
+		Value  int64  `json:"value"`
+		Status struct {
+			Confirmed   bool  `json:"confirmed"`
+			BlockHeight int32 `json:"block_height"`
+		} `json:"status"`
+	}
+	if err := e.get(ctx, "/address/"+address+"/utxo", &outputs); err != nil {
+		return nil, err
+	}
+
+	utxos := make([]UTXO, 0, len(outputs))
+	for _, out := range outputs {
+		utxo := UTXO{TxID: out.TxID, Vout: out.Vout, Value: out.Value, Address: address}
+		if out.Status.Confirmed {
+			utxo.Height = out.Status.BlockHeight
+		}
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
+// Broadcast relays a raw transaction and returns its txid. A transaction
+// the node refuses fails with ErrTxRejected.
+func (e *EsploraSource) Broadcast(ctx context.Context, rawTx string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/tx", strings.NewReader(rawTx))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	body, err := e.do(req)
+	if errors.Is(err, errBadRequest) {
+		return "", fmt.Errorf("%w: %v", ErrTxRejected, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("broadcast failed: %w", err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// TxStatus returns the status of transaction txid, or ErrTxNotFound when the
+// API does not know it
+func (e *EsploraSource) TxStatus(ctx context.Context, txid string) (*TxStatus, error) {
+	var status TxStatus
+	if err := e.get(ctx, "/tx/"+txid+"/status", &status); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrTxNotFound, txid)
+		}
+		return nil, err
+	}
+	return &status, nil
+}
+
+// TipHeight returns the height of the best block
+func (e *EsploraSource) TipHeight(ctx context.Context) (int32, error) {
+	var height int32
+	if err := e.get(ctx, "/blocks/tip/height", &height); err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// BlockHash returns the hash of the block at height on the best chain
+func (e *EsploraSource) BlockHash(ctx context.Context, height int32) (*chainhash.Hash, error) {
+	body, err := e.fetch(ctx, fmt.Sprintf("/block-height/%d", height))
+	if err != nil {
+		return nil, err
+	}
+	hash, err := chainhash.NewHashFromStr(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid block hash at height %d: %w", height, err)
+	}
+	return hash, nil
+}
+
+// Block returns the block with the given hash
+func (e *EsploraSource) Block(ctx context.Context, hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	body, err := e.fetch(ctx, "/block/"+hash.String()+"/raw")
+	if err != nil {
+		return nil, err
+	}
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(body)); err != nil {
+		return nil, fmt.Errorf("invalid block %s: %w", hash, err)
+	}
+	return &block, nil
+}
+
+// esploraPageSize is how many confirmed transactions Esplora returns per
+// page of address history
+const esploraPageSize = 25
+
+// esploraTx is a transaction as Esplora describes it
+type esploraTx struct {
+	TxID string `json:"txid"`
+	Vin  []struct {
+		TxID    string `json:"txid"`
+		Vout    uint32 `json:"vout"`
+		Prevout *struct {
+			Address string `json:"scriptpubkey_address"`
+			Value   int64  `json:"value"`
+		} `json:"prevout"`
+	} `json:"vin"`
+	Vout []struct {
+		Address string `json:"scriptpubkey_address"`
+		Value   int64  `json:"value"`
+	} `json:"vout"`
+	Fee    int64 `json:"fee"`
+	Status struct {
+		Confirmed   bool   `json:"confirmed"`
+		BlockHeight int32  `json:"block_height"`
+		BlockHash   string `json:"block_hash"`
+		BlockTime   int64  `json:"block_time"`
+	} `json:"status"`
+}
+
+func (t *esploraTx) chainTx() ChainTx {
+	tx := ChainTx{TxID: t.TxID, Fee: t.Fee}
+	if t.Status.Confirmed {
+		tx.Height = t.Status.BlockHeight
+		tx.BlockHash = t.Status.BlockHash
+		tx.BlockTime = time.Unix(t.Status.BlockTime, 0).UTC()
+	}
+	for _, in := range t.Vin {
+		input := TxIO{TxID: in.TxID, Vout: in.Vout}
+		if in.Prevout != nil {
+			input.Address, input.Value = in.Prevout.Address, in.Prevout.Value
+		}
+		tx.Inputs = append(tx.Inputs, input)
+	}
+	for i, out := range t.Vout {
+		tx.Outputs = append(tx.Outputs, TxIO{TxID: t.TxID, Vout: uint32(i), Address: out.Address, Value: out.Value})
+	}
+	return tx
+}
+
+// AddressTxs returns the mempool and confirmed transactions of address,
+// following Esplora's pages of confirmed history
+func (e *EsploraSource) AddressTxs(ctx context.Context, address string) ([]ChainTx, error) {
+	var page []esploraTx
+	if err := e.get(ctx, "/address/"+address+"/txs", &page); err != nil {
+		return nil, err
+	}
+	var txs []ChainTx
+	for {
+		confirmed := 0
+		lastSeen := ""
+		for _, t := range page {
+			txs = append(txs, t.chainTx())
+			if t.Status.Confirmed {
+				confirmed++
+				lastSeen = t.TxID
+			}
+		}
+		if confirmed < esploraPageSize {
+			return txs, nil
+		}
+		page = nil
+		if err := e.get(ctx, "/address/"+address+"/txs/chain/"+lastSeen, &page); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// MerkleProof returns the merkle branch of a confirmed transaction
+func (e *EsploraSource) MerkleProof(ctx context.Context, txid string) (*bitcoin.TransactionProof, error) {
+	var resp struct {
+		BlockHeight int32    `json:"block_height"`
+		Merkle      []string `json:"merkle"`
+		Pos         int      `json:"pos"`
+	}
+	if err := e.get(ctx, "/tx/"+txid+"/merkle-proof", &resp); err != nil {
+		return nil, err
+	}
+	txHash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, err
+	}
+	proof := &bitcoin.TransactionProof{BlockHeight: resp.BlockHeight, TxHash: *txHash, Position: resp.Pos}
+	for _, h := range resp.Merkle {
+		hash, err := chainhash.NewHashFromStr(h)
+		if err != nil {
+			return nil, fmt.Errorf("invalid merkle proof of %s: %w", txid, err)
+		}
+		proof.MerkleProof = append(proof.MerkleProof, *hash)
+	}
+	return proof, nil
+}
+
+// BlockHeader returns the header of the block with the given hash
+func (e *EsploraSource) BlockHeader(ctx context.Context, hash *chainhash.Hash) (*wire.BlockHeader, error) {
+	body, err := e.fetch(ctx, "/block/"+hash.String()+"/header")
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid header of block %s: %w", hash, err)
+	}
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("invalid header of block %s: %w", hash, err)
+	}
+	return &header, nil
+}
+
+// fetch returns the body of path
+func (e *EsploraSource) fetch(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return e.do(req)
+}
+
+// get fetches path and decodes the JSON response into v
+func (e *EsploraSource) get(ctx context.Context, path string, v interface{}) error {
+	body, err := e.fetch(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// errNotFound and errBadRequest mark 404 and 400 responses
+var (
+	errNotFound   = errors.New("404 Not Found")
+	errBadRequest = errors.New("400 Bad Request")
+)
+
+// do sends req and returns the body of a successful response
+func (e *EsploraSource) do(req *http.Request) ([]byte, error) {
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", errNotFound, strings.TrimSpace(string(body)))
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		return nil, fmt.Errorf("%w: %s", errBadRequest, strings.TrimSpace(string(body)))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// Wallet address branches of the BIP-86 account
+const (
+	ReceiveBranch = 0
+	ChangeBranch  = 1
+)
+
+// SeedScheme names how a wallet's phrase becomes its BIP-32 seed
+type SeedScheme string
+
+const (
+	// SeedBIP39 is the BIP-39 seed of the phrase, taken from a prophecy's
+	// 12-word mnemonic, so the wallet can be restored in other wallets
+	SeedBIP39 SeedScheme = "bip39"
+	// SeedForge is the tempered Proof-of-Forge output of a prophecy: its
+	// binding hash after 128 Tetra-PoW rounds and HPP-1 tempering. Only
+	// Excalibur wallets can restore it.
+	SeedForge SeedScheme = "forge"
+	// SeedLegacy is the BIP-39 seed of every word of a phrase without a
+	// checksum, as wallets derived them before prophecies carried one
+	SeedLegacy SeedScheme = "legacy"
+)
+
+// ErrSeedScheme indicates an unknown seed scheme or a phrase it cannot seed
+var ErrSeedScheme = errors.New("unsupported seed scheme")
+
+// ParseSeedScheme returns the scheme named s; empty means BIP-39
+func ParseSeedScheme(s string) (SeedScheme, error) {
+	switch SeedScheme(s) {
+	case "", SeedBIP39:
+		return SeedBIP39, nil
+	case SeedForge:
+		return SeedForge, nil
+	case SeedLegacy:
+		return SeedLegacy, nil
+	}
+	return "", fmt.Errorf("%w: %q (use bip39, forge or legacy)", ErrSeedScheme, s)
+}
+
+// Seed derives the BIP-32 seed of a phrase and optional passphrase. Under
+// SeedForge the passphrase, if any, salts the tempering in place of the
+// default forge salt. SeedBIP39 refuses a 13-word phrase that fails its
+// checksum rather than seed it from 12 words it was never seeded from.
+func Seed(words []string, passphrase string, scheme SeedScheme) ([]byte, error) {
+	switch scheme {
+	case "", SeedBIP39:
+		if len(words) == crypto.ProphecyWordCount {
+			if _, err := crypto.MnemonicToEntropy(words); err != nil {
+				return nil, fmt.Errorf("%w: %v (seed a phrase from before prophecy checksums with the legacy scheme)", ErrSeedScheme, err)
+			}
+		}
+		return crypto.MnemonicToSeed(crypto.StandardMnemonic(words), passphrase), nil
+	case SeedLegacy:
+		return crypto.MnemonicToSeed(words, passphrase), nil
+	case SeedForge:
+		if len(words) != 13 {
+			return nil, fmt.Errorf("%w: forge seeds need a 13-word prophecy, got %d words", ErrSeedScheme, len(words))
+		}
+		var salt []byte
+		if passphrase != "" {
+			salt = []byte(passphrase)
+		}
+		return crypto.PBKDF2Tempering(crypto.TetraPOW128Rounds(crypto.ProphecyBinding(words)), salt), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrSeedScheme, scheme)
+}
+
+// HDWallet is an unlocked BIP-32 wallet derived from a prophecy phrase. Each
+// account follows BIP-86: m/86'/coin'/account' with receive and change
+// branches of key-path-only Taproot addresses.
+type HDWallet struct {
+	net         *chaincfg.Params
+	fingerprint uint32
+	master      *hdkeychain.ExtendedKey
+	index       uint32
+	account     *hdkeychain.ExtendedKey
+}
+
+// NewHDWallet derives account 0 of a phrase and optional BIP-39 passphrase.
+// A prophecy is seeded from its standard 12-word mnemonic.
+func NewHDWallet(words []string, passphrase string, net *chaincfg.Params) (*HDWallet, error) {
+	seed, err := Seed(words, passphrase, SeedBIP39)
+	if err != nil {
+		return nil, err
+	}
+	return NewHDWalletFromSeed(seed, net)
+}
+
+// NewHDWalletFromSeed derives account 0 of a BIP-32 seed
+func NewHDWalletFromSeed(seed []byte, net *chaincfg.Params) (*HDWallet, error) {
+	master, err := hdkeychain.NewMaster(seed, net)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+	masterPub, err := master.ECPubKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+	w := &HDWallet{
+		net:         net,
+		fingerprint: binary.BigEndian.Uint32(btcutil.Hash160(masterPub.SerializeCompressed())[:4]),
+		master:      master,
+	}
+	return w.Account(0)
+}
+
+// Account returns the wallet's account at index
+func (w *HDWallet) Account(index uint32) (*HDWallet, error) {
+	if index >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("account %d out of range", index)
+	}
+	account := w.master
+	for _, step := range accountPath(w.net, index) {
+		var err error
+		if account, err = account.Derive(step); err != nil {
+			return nil, fmt.Errorf("failed to derive account key: %w", err)
+		}
+	}
+	return &HDWallet{net: w.net, fingerprint: w.fingerprint, master: w.master, index: index, account: account}, nil
+}
+
+// AccountIndex returns the index of the wallet's account
+func (w *HDWallet) AccountIndex() uint32 {
+	return w.index
+}
+
+// AccountOf returns the index of the account a descriptor tracks, if it is
+// one of this wallet's
+func (w *HDWallet) AccountOf(d *Descriptor) (uint32, bool) {
+	if !strings.HasPrefix(d.Origin, fmt.Sprintf("%08x/", w.fingerprint)) {
+		return 0, false
+	}
+	return AccountIndex(d, w.net)
+}
+
+// AccountIndex returns the account of a descriptor whose key origin is a
+// BIP-86 account path on net, fingerprint/86'/coin'/account'
+func AccountIndex(d *Descriptor, net *chaincfg.Params) (uint32, bool) {
+	_, path, ok := strings.Cut(d.Origin, "/")
+	if !ok {
+		return 0, false
+	}
+	var purpose, coin, index uint32
+	if _, err := fmt.Sscanf(path, "%d'/%d'/%d'", &purpose, &coin, &index); err != nil || index >= hdkeychain.HardenedKeyStart {
+		return 0, false
+	}
+	if fmt.Sprintf("%d'/%d'/%d'", purpose, coin, index) != path {
+		return 0, false
+	}
+	want := accountPath(net, index)
+	if purpose+hdkeychain.HardenedKeyStart != want[0] || coin+hdkeychain.HardenedKeyStart != want[1] {
+		return 0, false
+	}
+	return index, true
+}
+
+// accountPath returns the BIP-86 path of an account: 86'/coin'/account',
+// where coin is 0 on mainnet and 1 on test networks
+func accountPath(net *chaincfg.Params, account uint32) []uint32 {
+	coin := uint32(1)
+	if net.Net == chaincfg.MainNetParams.Net {
+		coin = 0
+	}
+	return []uint32{
+		hdkeychain.HardenedKeyStart + 86,
+		hdkeychain.HardenedKeyStart + coin,
+		hdkeychain.HardenedKeyStart + account,
+	}
+}
+
+// Descriptor returns the account's public multi-path descriptor,
+// tr([fingerprint/86'/coin'/account']xpub/<0;1>/*), for tracking and
+// scanning
+func (w *HDWallet) Descriptor() (*Descriptor, error) {
+	xpub, err := w.account.Neuter()
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive account public key: %w", err)
+	}
+	path := accountPath(w.net, w.index)
+	origin := fmt.Sprintf("%08x/%d'/%d'/%d'", w.fingerprint,
+		path[0]-hdkeychain.HardenedKeyStart, path[1]-hdkeychain.HardenedKeyStart, path[2]-hdkeychain.HardenedKeyStart)
+	return ParseDescriptor(fmt.Sprintf("tr([%s]%s/<%d;%d>/*)", origin, xpub, ReceiveBranch, ChangeBranch))
+}
+
+// TaprootKey returns the signing key for the address at index on branch
+func (w *HDWallet) TaprootKey(branch int, index uint32) (*bitcoin.TaprootKey, error) {
+	if branch != ReceiveBranch && branch != ChangeBranch {
+		return nil, fmt.Errorf("branch %d out of range", branch)
+	}
+	if index >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	key, err := w.account.Derive(uint32(branch))
+	if err == nil {
+		key, err = key.Derive(index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key %d/%d: %w", branch, index, err)
+	}
+	priv, err := key.ECPrivKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key %d/%d: %w", branch, index, err)
+	}
+	return &bitcoin.TaprootKey{PrivKey: priv}, nil
+}
+
+// TaprootKeys returns the signing keys for indexes [0, end) on both branches
+func (w *HDWallet) TaprootKeys(end uint32) ([]*bitcoin.TaprootKey, error) {
+	keys := make([]*bitcoin.TaprootKey, 0, 2*end)
+	for _, branch := range []int{ReceiveBranch, ChangeBranch} {
+		for index := uint32(0); index < end; index++ {
+			key, err := w.TaprootKey(branch, index)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,205 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// bip86Words is the BIP-86 test vector mnemonic
+var bip86Words = strings.Fields("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about")
+
+func TestHDWalletBIP86(t *testing.T) {
+	hd, err := NewHDWallet(bip86Words, "", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewHDWallet() error = %v", err)
+	}
+	desc, err := hd.Descriptor()
+	if err != nil {
+		t.Fatalf("Descriptor() error = %v", err)
+	}
+	if !strings.HasPrefix(desc.String(), "tr([73c5da0a/86'/0'/0']xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ/<0;1>/*)") {
+		t.Errorf("Unexpected descriptor %s", desc)
+	}
+
+	vectors := []struct {
+		branch  int
+		index   uint32
+		address string
+	}{
+		{ReceiveBranch, 0, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+		{ReceiveBranch, 1, "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh"},
+		{ChangeBranch, 0, "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7"},
+	}
+	for _, v := range vectors {
+		addr, err := desc.Derive(v.branch, v.index, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("Derive() error = %v", err)
+		}
+		if addr.Address != v.address {
+			t.Errorf("Address %d/%d = %s, want %s", v.branch, v.index, addr.Address, v.address)
+		}
+
+		key, err := hd.TaprootKey(v.branch, v.index)
+		if err != nil {
+			t.Fatalf("TaprootKey() error = %v", err)
+		}
+		if script, _ := key.PkScript(); !bytes.Equal(script, addr.PkScript) {
+			t.Errorf("Key %d/%d does not spend %s", v.branch, v.index, v.address)
+		}
+	}
+
+	keys, err := hd.TaprootKeys(3)
+	if err != nil || len(keys) != 6 {
+		t.Fatalf("TaprootKeys() = %d keys, %v", len(keys), err)
+	}
+	if _, err := hd.TaprootKey(2, 0); err == nil {
+		t.Error("Expected an unknown branch to be rejected")
+	}
+}
+
+func TestHDWalletProphecyMatchesMnemonic(t *testing.T) {
+	// The prophecy of all-zero entropy extends the BIP-86 vector mnemonic
+	prophecy := append(append([]string{}, bip86Words...), "inner")
+	hd, err := NewHDWallet(prophecy, "", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewHDWallet() error = %v", err)
+	}
+	desc, _ := hd.Descriptor()
+	addr, err := desc.Derive(ReceiveBranch, 0, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Derive() error = %v", err)
+	}
+	if addr.Address != "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr" {
+		t.Errorf("Prophecy wallet address %s differs from its BIP-39 mnemonic", addr.Address)
+	}
+}
+
+func TestHDWalletTestnetCoinType(t *testing.T) {
+	hd, err := NewHDWallet(bip86Words, "", &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("NewHDWallet() error = %v", err)
+	}
+	desc, err := hd.Descriptor()
+	if err != nil {
+		t.Fatalf("Descriptor() error = %v", err)
+	}
+	if !strings.HasPrefix(desc.String(), "tr([73c5da0a/86'/1'/0']tpub") {
+		t.Errorf("Expected a coin type 1 tpub descriptor, got %s", desc)
+	}
+}
+
+func TestHDWalletAccounts(t *testing.T) {
+	hd, err := NewHDWallet(bip86Words, "", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewHDWallet() error = %v", err)
+	}
+	second, err := hd.Account(1)
+	if err != nil {
+		t.Fatalf("Account() error = %v", err)
+	}
+	desc, err := second.Descriptor()
+	if err != nil {
+		t.Fatalf("Descriptor() error = %v", err)
+	}
+	if second.AccountIndex() != 1 || desc.Origin != "73c5da0a/86'/0'/1'" {
+		t.Errorf("Account 1 has origin %s", desc.Origin)
+	}
+	first, _ := hd.Descriptor()
+	if strings.Contains(desc.String(), bip86AccountXpub) {
+		t.Error("Account 1 shares account 0's xpub")
+	}
+
+	for _, d := range []*Descriptor{first, desc} {
+		index, ok := hd.AccountOf(d)
+		account, _ := hd.Account(index)
+		own, _ := account.Descriptor()
+		if !ok || own.String() != d.String() {
+			t.Errorf("AccountOf(%s) = %d, %v", d.Origin, index, ok)
+		}
+	}
+	other, _ := NewHDWallet(strings.Fields("legal winner thank year wave sausage worth useful legal winner thank yellow"), "", &chaincfg.MainNetParams)
+	if _, ok := other.AccountOf(desc); ok {
+		t.Error("Expected another wallet's descriptor not to be its own")
+	}
+	testnet, _ := ParseDescriptor("tr([73c5da0a/86'/1'/0']" + bip86AccountXpub + "/<0;1>/*)")
+	if _, ok := hd.AccountOf(testnet); ok {
+		t.Error("Expected another coin type not to match")
+	}
+
+	key, err := second.TaprootKey(ReceiveBranch, 4)
+	if err != nil {
+		t.Fatalf("TaprootKey() error = %v", err)
+	}
+	addr, _ := desc.Derive(ReceiveBranch, 4, &chaincfg.MainNetParams)
+	if script, _ := key.PkScript(); !bytes.Equal(script, addr.PkScript) {
+		t.Error("Account 1 key does not spend its address")
+	}
+	if _, err := hd.Account(hdkeychain.HardenedKeyStart); err == nil {
+		t.Error("Expected a hardened account index to be rejected")
+	}
+}
+
+func TestSeedForge(t *testing.T) {
+	prophecy := append(append([]string{}, bip86Words...), "inner")
+	forge, err := Seed(prophecy, "", SeedForge)
+	if err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	tempered := crypto.PBKDF2Tempering(crypto.TetraPOW128Rounds(crypto.ProphecyBinding(prophecy)), nil)
+	if !bytes.Equal(forge, tempered) {
+		t.Error("Forge seed is not the tempered Proof-of-Forge output")
+	}
+	bip39, _ := Seed(prophecy, "", SeedBIP39)
+	if bytes.Equal(forge, bip39) {
+		t.Error("Forge and BIP-39 seeds match")
+	}
+	salted, _ := Seed(prophecy, "excalibur", SeedForge)
+	if bytes.Equal(forge, salted) {
+		t.Error("Passphrase does not change the forge seed")
+	}
+
+	if _, err := Seed(bip86Words, "", SeedForge); !errors.Is(err, ErrSeedScheme) {
+		t.Errorf("Expected a 12-word forge seed to fail, got %v", err)
+	}
+	if scheme, _ := ParseSeedScheme("legacy"); scheme != SeedLegacy {
+		t.Errorf("ParseSeedScheme(\"legacy\") = %s, want legacy", scheme)
+	}
+	if _, err := ParseSeedScheme("electrum"); !errors.Is(err, ErrSeedScheme) {
+		t.Errorf("Expected an unknown scheme to fail, got %v", err)
+	}
+	if scheme, _ := ParseSeedScheme(""); scheme != SeedBIP39 {
+		t.Errorf("ParseSeedScheme(\"\") = %s, want bip39", scheme)
+	}
+}
+
+func TestSeedLegacy(t *testing.T) {
+	axiom, err := crypto.ParseLegacyProphecy("sword legend pull magic kingdom artist stone destroy forget fire steel honey question")
+	if err != nil {
+		t.Fatalf("ParseLegacyProphecy() error = %v", err)
+	}
+	// The axiom has no checksum, so BIP-39 must not seed it from 12 words
+	if _, err := Seed(axiom, "", SeedBIP39); !errors.Is(err, ErrSeedScheme) {
+		t.Errorf("Expected a BIP-39 seed of the axiom to fail, got %v", err)
+	}
+	seed, err := Seed(axiom, "", SeedLegacy)
+	if err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	if !bytes.Equal(seed, crypto.MnemonicToSeed(axiom, "")) {
+		t.Error("Legacy seed is not the BIP-39 seed of all 13 words")
+	}
+
+	prophecy := append(append([]string{}, bip86Words...), "inner")
+	legacy, _ := Seed(prophecy, "", SeedLegacy)
+	bip39, _ := Seed(prophecy, "", SeedBIP39)
+	if bytes.Equal(legacy, bip39) || !bytes.Equal(bip39, crypto.MnemonicToSeed(bip86Words, "")) {
+		t.Error("A checksummed prophecy does not keep its 12-word BIP-39 seed")
+	}
+}
@@ -0,0 +1,271 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+)
+
+// ErrUnverified indicates a confirmed transaction whose inclusion in its
+// block could not be proven
+var ErrUnverified = errors.New("transaction inclusion not proven")
+
+// HistorySource lists the transactions touching an address and proves
+// their inclusion in blocks, so history can be checked the SPV way
+type HistorySource interface {
+	UsageChecker
+	// AddressTxs returns every transaction paying or spending from address
+	AddressTxs(ctx context.Context, address string) ([]ChainTx, error)
+	// MerkleProof returns the merkle branch of a confirmed transaction
+	MerkleProof(ctx context.Context, txid string) (*bitcoin.TransactionProof, error)
+	// BlockHeader returns the header of the block with the given hash
+	BlockHeader(ctx context.Context, hash *chainhash.Hash) (*wire.BlockHeader, error)
+}
+
+// TxIO is a transaction input, described by the output it spends, or output
+type TxIO struct {
+	TxID    string `json:"txid"`
+	Vout    uint32 `json:"vout"`
+	Address string `json:"address,omitempty"`
+	Value   int64  `json:"value"`
+}
+
+// ChainTx is a transaction as a HistorySource reports it
+type ChainTx struct {
+	TxID      string    `json:"txid"`
+	Height    int32     `json:"height,omitempty"`
+	BlockHash string    `json:"block_hash,omitempty"`
+	BlockTime time.Time `json:"block_time,omitempty"`
+	Fee       int64     `json:"fee"`
+	Inputs    []TxIO    `json:"inputs"`
+	Outputs   []TxIO    `json:"outputs"`
+}
+
+// HistoryEntry is a transaction touching a wallet. Inputs and Outputs hold
+// only the wallet's own coins.
+type HistoryEntry struct {
+	TxID      string    `json:"txid"`
+	Height    int32     `json:"height,omitempty"`
+	BlockHash string    `json:"block_hash,omitempty"`
+	BlockTime time.Time `json:"block_time,omitempty"`
+	Fee       int64     `json:"fee"`
+	Inputs    []TxIO    `json:"inputs,omitempty"`
+	Outputs   []TxIO    `json:"outputs,omitempty"`
+	// Verified is set once a merkle proof ties a confirmed transaction to
+	// a block header with valid proof of work
+	Verified bool `json:"verified"`
+}
+
+// Sent returns the total of the wallet's coins the transaction spends
+func (e *HistoryEntry) Sent() int64 {
+	var total int64
+	for _, in := range e.Inputs {
+		total += in.Value
+	}
+	return total
+}
+
+// Received returns the total the transaction pays to the wallet
+func (e *HistoryEntry) Received() int64 {
+	var total int64
+	for _, out := range e.Outputs {
+		total += out.Value
+	}
+	return total
+}
+
+// Net returns the change in the wallet's balance
+func (e *HistoryEntry) Net() int64 {
+	return e.Received() - e.Sent()
+}
+
+// History is a wallet's transactions as of the last sync, newest first
+type History struct {
+	SyncedAt time.Time      `json:"synced_at"`
+	Entries  []HistoryEntry `json:"entries"`
+}
+
+// Unverified returns the confirmed entries whose inclusion was not proven
+func (h *History) Unverified() []HistoryEntry {
+	var entries []HistoryEntry
+	for _, e := range h.Entries {
+		if e.Height > 0 && !e.Verified {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// SyncHistory scans every descriptor in store and collects the transactions
+// of the used addresses. Each confirmed transaction is checked with a
+// merkle proof against its block header, whose proof of work is checked
+// against net; one that fails is an error, while one the source cannot
+// prove is kept unverified.
+func SyncHistory(ctx context.Context, source HistorySource, store *DescriptorStore, net *chaincfg.Params, gapLimit uint32) (*History, error) {
+	scanner := NewScanner(source, net, gapLimit)
+	owned := make(map[string]bool)
+	var addresses []string
+	for _, entry := range store.List() {
+		desc, err := ParseDescriptor(entry.Descriptor)
+		if err != nil {
+			return nil, err
+		}
+		result, err := scanner.Scan(ctx, desc, entry.RangeStart, entry.RangeEnd)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.RecordScan(result); err != nil {
+			return nil, err
+		}
+		for _, addr := range result.Used {
+			if !owned[addr.Address] {
+				owned[addr.Address] = true
+				addresses = append(addresses, addr.Address)
+			}
+		}
+	}
+
+	history := &History{Entries: []HistoryEntry{}}
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		txs, err := source.AddressTxs(ctx, address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list transactions of %s: %w", address, err)
+		}
+		for _, tx := range txs {
+			if seen[tx.TxID] {
+				continue
+			}
+			seen[tx.TxID] = true
+			entry := historyEntry(tx, owned)
+			if entry.Height > 0 {
+				err := VerifyInclusion(ctx, source, &entry, net)
+				switch {
+				case err == nil:
+					entry.Verified = true
+				case !errors.Is(err, ErrUnverified):
+					return nil, err
+				}
+			}
+			history.Entries = append(history.Entries, entry)
+		}
+	}
+
+	// Unconfirmed first, then by height, newest first
+	sort.SliceStable(history.Entries, func(i, j int) bool {
+		a, b := history.Entries[i].Height, history.Entries[j].Height
+		if a == 0 || b == 0 {
+			return a == 0 && b != 0
+		}
+		return a > b
+	})
+	history.SyncedAt = time.Now().UTC()
+	return history, nil
+}
+
+// historyEntry keeps the inputs and outputs of tx that belong to owned
+// addresses
+func historyEntry(tx ChainTx, owned map[string]bool) HistoryEntry {
+	entry := HistoryEntry{
+		TxID:      tx.TxID,
+		Height:    tx.Height,
+		BlockHash: tx.BlockHash,
+		BlockTime: tx.BlockTime,
+		Fee:       tx.Fee,
+	}
+	for _, in := range tx.Inputs {
+		if owned[in.Address] {
+			entry.Inputs = append(entry.Inputs, in)
+		}
+	}
+	for _, out := range tx.Outputs {
+		if owned[out.Address] {
+			entry.Outputs = append(entry.Outputs, out)
+		}
+	}
+	return entry
+}
+
+// VerifyInclusion checks that a confirmed entry is in its block: the
+// header must hash to the entry's block, meet its own difficulty target
+// within net's proof-of-work limit, and commit by its merkle root to the
+// transaction. Headers are not checked to form a chain; a source that mines
+// valid blocks for fake transactions defeats this, at the cost of the work.
+func VerifyInclusion(ctx context.Context, source HistorySource, entry *HistoryEntry, net *chaincfg.Params) error {
+	blockHash, err := chainhash.NewHashFromStr(entry.BlockHash)
+	if err != nil {
+		return fmt.Errorf("%w: %s has no block hash", ErrUnverified, entry.TxID)
+	}
+	txHash, err := chainhash.NewHashFromStr(entry.TxID)
+	if err != nil {
+		return fmt.Errorf("invalid txid %q", entry.TxID)
+	}
+	proof, err := source.MerkleProof(ctx, entry.TxID)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUnverified, entry.TxID, err)
+	}
+	header, err := source.BlockHeader(ctx, blockHash)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUnverified, entry.TxID, err)
+	}
+
+	if header.BlockHash() != *blockHash {
+		return fmt.Errorf("%w: header does not hash to block %s", bitcoin.ErrInvalidMerkleProof, blockHash)
+	}
+	target := blockchain.CompactToBig(header.Bits)
+	if target.Sign() <= 0 || target.Cmp(net.PowLimit) > 0 || blockchain.HashToBig(blockHash).Cmp(target) > 0 {
+		return fmt.Errorf("%w: block %s lacks its proof of work", bitcoin.ErrInvalidMerkleProof, blockHash)
+	}
+	if proof.BlockHeight != 0 && proof.BlockHeight != entry.Height {
+		return fmt.Errorf("%w: %s proven at height %d, reported at %d", bitcoin.ErrInvalidMerkleProof, entry.TxID, proof.BlockHeight, entry.Height)
+	}
+	if bitcoin.MerkleRootFromProof(*txHash, proof.MerkleProof, proof.Position) != header.MerkleRoot {
+		return fmt.Errorf("%w: %s in block %s", bitcoin.ErrInvalidMerkleProof, entry.TxID, blockHash)
+	}
+	return nil
+}
+
+// SaveHistory writes a history to path
+func SaveHistory(path string, history *History) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create wallet directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadHistory reads the history at path. A wallet that was never synced
+// has an empty history.
+func LoadHistory(path string) (*History, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &History{Entries: []HistoryEntry{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	var history History
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse history: %w", err)
+	}
+	return &history, nil
+}
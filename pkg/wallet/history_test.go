@@ -0,0 +1,251 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+)
+
+// mockHistory is a chain with one confirmed transaction per block, each
+// next to a sibling so its merkle proof has one step
+type mockHistory struct {
+	mockChecker
+	txs     map[string][]ChainTx
+	proofs  map[string]*bitcoin.TransactionProof
+	headers map[chainhash.Hash]*wire.BlockHeader
+}
+
+func newMockHistory() *mockHistory {
+	return &mockHistory{
+		mockChecker: mockChecker{used: make(map[string]bool)},
+		txs:         make(map[string][]ChainTx),
+		proofs:      make(map[string]*bitcoin.TransactionProof),
+		headers:     make(map[chainhash.Hash]*wire.BlockHeader),
+	}
+}
+
+// add records tx for every address it touches, mining a regtest block for
+// it when it is confirmed
+func (m *mockHistory) add(t *testing.T, tx ChainTx) ChainTx {
+	t.Helper()
+	txHash := chainhash.DoubleHashH([]byte(fmt.Sprintf("tx %d %d", tx.Height, len(m.proofs))))
+	tx.TxID = txHash.String()
+	if tx.Height > 0 {
+		sibling := chainhash.DoubleHashH([]byte("sibling"))
+		header := &wire.BlockHeader{
+			Version:    1,
+			MerkleRoot: bitcoin.MerkleRootFromProof(txHash, []chainhash.Hash{sibling}, 0),
+			Timestamp:  time.Unix(1700000000+int64(tx.Height)*600, 0),
+			Bits:       chaincfg.RegressionNetParams.PowLimitBits,
+		}
+		target := blockchain.CompactToBig(header.Bits)
+		for {
+			hash := header.BlockHash()
+			if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+				break
+			}
+			header.Nonce++
+		}
+		blockHash := header.BlockHash()
+		m.headers[blockHash] = header
+		m.proofs[tx.TxID] = &bitcoin.TransactionProof{
+			BlockHash:   blockHash,
+			BlockHeight: tx.Height,
+			TxHash:      txHash,
+			MerkleProof: []chainhash.Hash{sibling},
+		}
+		tx.BlockHash = blockHash.String()
+		tx.BlockTime = header.Timestamp
+	}
+	for i := range tx.Inputs {
+		m.txs[tx.Inputs[i].Address] = append(m.txs[tx.Inputs[i].Address], tx)
+	}
+	for i := range tx.Outputs {
+		tx.Outputs[i].TxID, tx.Outputs[i].Vout = tx.TxID, uint32(i)
+		m.txs[tx.Outputs[i].Address] = append(m.txs[tx.Outputs[i].Address], tx)
+	}
+	return tx
+}
+
+func (m *mockHistory) AddressTxs(ctx context.Context, address string) ([]ChainTx, error) {
+	return m.txs[address], nil
+}
+
+func (m *mockHistory) MerkleProof(ctx context.Context, txid string) (*bitcoin.TransactionProof, error) {
+	proof, ok := m.proofs[txid]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return proof, nil
+}
+
+func (m *mockHistory) BlockHeader(ctx context.Context, hash *chainhash.Hash) (*wire.BlockHeader, error) {
+	header, ok := m.headers[*hash]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return header, nil
+}
+
+// historyFixture funds the wallet's first address, spends it with change to
+// the second, and leaves a payment to the second in the mempool
+func historyFixture(t *testing.T) (*mockHistory, *DescriptorStore, []ChainTx) {
+	t.Helper()
+	net := &chaincfg.RegressionNetParams
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/0/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+	store, err := OpenDescriptorStore(filepath.Join(t.TempDir(), "descriptors.json"))
+	if err != nil {
+		t.Fatalf("OpenDescriptorStore() error = %v", err)
+	}
+	if _, err := store.Import(d, "audit", 0, 100, true); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	addrs, err := d.DeriveRange(0, 0, 2, net)
+	if err != nil {
+		t.Fatalf("DeriveRange() error = %v", err)
+	}
+
+	m := newMockHistory()
+	for _, addr := range addrs {
+		m.used[string(addr.PkScript)] = true
+	}
+	const outsider = "bcrt1qoutsider"
+	fund := m.add(t, ChainTx{Height: 10, Fee: 200, Outputs: []TxIO{
+		{Address: addrs[0].Address, Value: 50_000},
+		{Address: outsider, Value: 1_000},
+	}})
+	spend := m.add(t, ChainTx{Height: 12, Fee: 1_000, Inputs: []TxIO{
+		{TxID: fund.TxID, Vout: 0, Address: addrs[0].Address, Value: 50_000},
+	}, Outputs: []TxIO{
+		{Address: outsider, Value: 30_000},
+		{Address: addrs[1].Address, Value: 19_000},
+	}})
+	pending := m.add(t, ChainTx{Fee: 300, Outputs: []TxIO{{Address: addrs[1].Address, Value: 5_000}}})
+	return m, store, []ChainTx{fund, spend, pending}
+}
+
+func TestSyncHistory(t *testing.T) {
+	m, store, txs := historyFixture(t)
+
+	history, err := SyncHistory(context.Background(), m, store, &chaincfg.RegressionNetParams, 5)
+	if err != nil {
+		t.Fatalf("SyncHistory() error = %v", err)
+	}
+	if len(history.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(history.Entries))
+	}
+	// Mempool first, then newest first
+	for i, want := range []string{txs[2].TxID, txs[1].TxID, txs[0].TxID} {
+		if history.Entries[i].TxID != want {
+			t.Errorf("Entries[%d] = %s, want %s", i, history.Entries[i].TxID, want)
+		}
+	}
+	spend := history.Entries[1]
+	if spend.Sent() != 50_000 || spend.Received() != 19_000 || spend.Net() != -31_000 {
+		t.Errorf("Spend: sent %d, received %d, net %d", spend.Sent(), spend.Received(), spend.Net())
+	}
+	if len(history.Entries[2].Outputs) != 1 {
+		t.Errorf("Expected only the wallet's output to be kept, got %+v", history.Entries[2].Outputs)
+	}
+	if !spend.Verified || !history.Entries[2].Verified || history.Entries[0].Verified {
+		t.Error("Expected confirmed entries to be verified and the mempool entry not")
+	}
+	if len(history.Unverified()) != 0 {
+		t.Errorf("Unverified() = %+v", history.Unverified())
+	}
+
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := SaveHistory(path, history); err != nil {
+		t.Fatalf("SaveHistory() error = %v", err)
+	}
+	loaded, err := LoadHistory(path)
+	if err != nil || len(loaded.Entries) != 3 || loaded.Entries[1].Net() != -31_000 {
+		t.Errorf("LoadHistory() = %+v, %v", loaded, err)
+	}
+}
+
+func TestSyncHistoryProofs(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+
+	// A proof the source cannot give leaves the entry unverified
+	m, store, txs := historyFixture(t)
+	delete(m.proofs, txs[0].TxID)
+	history, err := SyncHistory(context.Background(), m, store, net, 5)
+	if err != nil {
+		t.Fatalf("SyncHistory() error = %v", err)
+	}
+	if unverified := history.Unverified(); len(unverified) != 1 || unverified[0].TxID != txs[0].TxID {
+		t.Errorf("Unverified() = %+v", unverified)
+	}
+
+	// A proof that leads elsewhere fails the sync
+	m, store, txs = historyFixture(t)
+	m.proofs[txs[1].TxID].MerkleProof = []chainhash.Hash{{0x01}}
+	if _, err := SyncHistory(context.Background(), m, store, net, 5); !errors.Is(err, bitcoin.ErrInvalidMerkleProof) {
+		t.Errorf("SyncHistory() error = %v, want ErrInvalidMerkleProof", err)
+	}
+
+	// So does a header without the work its target asks for
+	m, store, txs = historyFixture(t)
+	entry := HistoryEntry{TxID: txs[0].TxID, Height: txs[0].Height, BlockHash: txs[0].BlockHash}
+	if err := VerifyInclusion(context.Background(), m, &entry, &chaincfg.MainNetParams); !errors.Is(err, bitcoin.ErrInvalidMerkleProof) {
+		t.Errorf("VerifyInclusion() on mainnet error = %v, want ErrInvalidMerkleProof", err)
+	}
+}
+
+func TestNewStatement(t *testing.T) {
+	m, store, txs := historyFixture(t)
+	net := &chaincfg.RegressionNetParams
+	history, err := SyncHistory(context.Background(), m, store, net, 5)
+	if err != nil {
+		t.Fatalf("SyncHistory() error = %v", err)
+	}
+	snapshot := &Snapshot{UTXOs: []UTXO{
+		{TxID: txs[1].TxID, Vout: 1, Value: 19_000, Address: txs[1].Outputs[1].Address, Height: 12},
+		{TxID: txs[2].TxID, Vout: 0, Value: 5_000, Address: txs[2].Outputs[0].Address},
+	}}
+
+	s := NewStatement(store, history, snapshot, client.BlockIdentifier{Index: 12, Hash: "tip"}, net)
+	if s.NetworkIdentifier.Network != "regtest" || len(s.Descriptors) != 1 {
+		t.Errorf("Unexpected header: %+v, %v", s.NetworkIdentifier, s.Descriptors)
+	}
+	if s.TotalCount != 2 || len(s.Transactions) != 2 {
+		t.Fatalf("Expected 2 confirmed transactions, got %d", s.TotalCount)
+	}
+	if len(s.Balances) != 1 || s.Balances[0].Value != "19000" {
+		t.Errorf("Balances = %+v, want 19000", s.Balances)
+	}
+	if len(s.Coins) != 1 || s.Coins[0].CoinIdentifier.Identifier != txs[1].TxID+":1" {
+		t.Errorf("Coins = %+v", s.Coins)
+	}
+
+	ops := s.Transactions[0].Transaction.Operations
+	if len(ops) != 2 {
+		t.Fatalf("Expected 2 operations, got %d", len(ops))
+	}
+	if ops[0].Type != client.OpTypeInput || ops[0].Amount.Value != "-50000" || ops[0].CoinChange.CoinAction != client.CoinSpent {
+		t.Errorf("Unexpected input operation: %+v", ops[0])
+	}
+	if ops[1].Type != client.OpTypeOutput || ops[1].Amount.Value != "19000" || ops[1].OperationIdentifier.Index != 1 {
+		t.Errorf("Unexpected output operation: %+v", ops[1])
+	}
+
+	// An earlier tip leaves out later blocks
+	if s := NewStatement(store, history, snapshot, client.BlockIdentifier{Index: 11}, net); s.TotalCount != 1 || s.Balances[0].Value != "50000" {
+		t.Errorf("Statement at 11: %d transactions, balance %s", s.TotalCount, s.Balances[0].Value)
+	}
+}
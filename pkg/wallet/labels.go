@@ -0,0 +1,314 @@
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// BIP-329 label types the wallet sets itself
+const (
+	LabelAddress = "addr"
+	LabelTx      = "tx"
+	LabelOutput  = "output"
+)
+
+// ErrInvalidLabel indicates a malformed label record
+var ErrInvalidLabel = errors.New("invalid label")
+
+// Label is a BIP-329 label record naming an address, transaction or other
+// wallet item
+type Label struct {
+	Type   string `json:"type"`
+	Ref    string `json:"ref"`
+	Label  string `json:"label"`
+	Origin string `json:"origin,omitempty"`
+	// Spendable is false for a frozen output; nil leaves it spendable
+	Spendable *bool `json:"spendable,omitempty"`
+}
+
+// LabelStore persists a wallet's labels as a BIP-329 JSON Lines file, so it
+// can be exchanged with other wallets as is. Records of types the wallet
+// does not use are kept.
+type LabelStore struct {
+	mu     sync.Mutex
+	path   string
+	labels []Label
+}
+
+// OpenLabelStore loads the labels at path, creating an empty store if the
+// file does not exist
+func OpenLabelStore(path string) (*LabelStore, error) {
+	s := &LabelStore{path: path}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labels: %w", err)
+	}
+	defer f.Close()
+	if s.labels, err = readLabels(f); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LabelRef returns the label type of ref on net: a transaction ID, an
+// outpoint (txid:vout) or an address
+func LabelRef(ref string, net *chaincfg.Params) (string, error) {
+	if isTxID(ref) {
+		return LabelTx, nil
+	}
+	if _, err := ParseOutpoint(ref); err == nil {
+		return LabelOutput, nil
+	}
+	if _, err := addressScript(ref, net); err == nil {
+		return LabelAddress, nil
+	}
+	return "", fmt.Errorf("%w: %q is not a transaction ID, outpoint or address", ErrInvalidLabel, ref)
+}
+
+// ParseOutpoint checks that ref is an outpoint, txid:vout, and returns it
+// in canonical form
+func ParseOutpoint(ref string) (string, error) {
+	txid, vout, ok := strings.Cut(ref, ":")
+	if !ok || !isTxID(txid) {
+		return "", fmt.Errorf("%w: %q is not an outpoint (txid:vout)", ErrInvalidLabel, ref)
+	}
+	n, err := strconv.ParseUint(vout, 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is not an outpoint (txid:vout)", ErrInvalidLabel, ref)
+	}
+	return fmt.Sprintf("%s:%d", strings.ToLower(txid), n), nil
+}
+
+func isTxID(ref string) bool {
+	_, err := chainhash.NewHashFromStr(ref)
+	return err == nil && len(ref) == 2*chainhash.HashSize
+}
+
+// Set labels a wallet item, replacing its label; an empty label removes it.
+// An output keeps its frozen state unless l sets Spendable.
+func (s *LabelStore) Set(l Label) error {
+	if err := l.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.index(l.Type, l.Ref); i >= 0 && l.Spendable == nil {
+		l.Spendable = s.labels[i].Spendable
+	}
+	return s.put(l)
+}
+
+// Freeze marks the output at outpoint unspendable, or spendable again, for
+// coin selection, keeping its label
+func (s *LabelStore) Freeze(outpoint string, frozen bool) error {
+	ref, err := ParseOutpoint(outpoint)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := Label{Type: LabelOutput, Ref: ref}
+	if i := s.index(LabelOutput, ref); i >= 0 {
+		l = s.labels[i]
+	}
+	l.Spendable = nil
+	if frozen {
+		spendable := false
+		l.Spendable = &spendable
+	}
+	return s.put(l)
+}
+
+// Frozen reports whether the output at outpoint is frozen
+func (s *LabelStore) Frozen(outpoint string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(LabelOutput, outpoint)
+	return i >= 0 && s.labels[i].Spendable != nil && !*s.labels[i].Spendable
+}
+
+// Spendable returns the utxos that are not frozen
+func (s *LabelStore) Spendable(utxos []UTXO) []UTXO {
+	var spendable []UTXO
+	for _, u := range utxos {
+		if !s.Frozen(u.Outpoint()) {
+			spendable = append(spendable, u)
+		}
+	}
+	return spendable
+}
+
+// Get returns the label of a wallet item, or "" if it has none
+func (s *LabelStore) Get(labelType, ref string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.index(labelType, ref); i >= 0 {
+		return s.labels[i].Label
+	}
+	return ""
+}
+
+// List returns all labels sorted by type and reference
+func (s *LabelStore) List() []Label {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Label, len(s.labels))
+	copy(list, s.labels)
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Type != list[j].Type {
+			return list[i].Type < list[j].Type
+		}
+		return list[i].Ref < list[j].Ref
+	})
+	return list
+}
+
+// Export writes the labels to w as BIP-329 JSON Lines
+func (s *LabelStore) Export(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, l := range s.List() {
+		if err := enc.Encode(l); err != nil {
+			return fmt.Errorf("failed to export labels: %w", err)
+		}
+	}
+	return nil
+}
+
+// Import merges BIP-329 JSON Lines from r, replacing the labels of items
+// already labelled, and returns the number of records read
+func (s *LabelStore) Import(r io.Reader) (int, error) {
+	labels, err := readLabels(r)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, l := range labels {
+		if i := s.index(l.Type, l.Ref); i >= 0 {
+			s.labels[i] = l
+		} else {
+			s.labels = append(s.labels, l)
+		}
+	}
+	return len(labels), s.save()
+}
+
+// readLabels parses BIP-329 JSON Lines, skipping blank lines and records
+// that neither label nor freeze anything
+func readLabels(r io.Reader) ([]Label, error) {
+	var labels []Label
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var l Label
+		if err := json.Unmarshal(text, &l); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidLabel, line, err)
+		}
+		if err := l.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !l.empty() {
+			labels = append(labels, l)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read labels: %w", err)
+	}
+	return labels, nil
+}
+
+// empty reports whether l neither labels nor freezes its item
+func (l *Label) empty() bool {
+	return l.Label == "" && l.Spendable == nil
+}
+
+func (l *Label) validate() error {
+	switch l.Type {
+	case LabelTx, LabelAddress, LabelOutput, "pubkey", "input", "xpub":
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidLabel, l.Type)
+	}
+	if strings.TrimSpace(l.Ref) == "" {
+		return fmt.Errorf("%w: empty reference", ErrInvalidLabel)
+	}
+	return nil
+}
+
+func (s *LabelStore) index(labelType, ref string) int {
+	for i := range s.labels {
+		if s.labels[i].Type == labelType && s.labels[i].Ref == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// put replaces the record of l's item with l, or removes it if l is
+// empty, and saves; the caller holds mu
+func (s *LabelStore) put(l Label) error {
+	i := s.index(l.Type, l.Ref)
+	switch {
+	case l.empty() && i < 0:
+		return nil
+	case l.empty():
+		s.labels = append(s.labels[:i], s.labels[i+1:]...)
+	case i >= 0:
+		s.labels[i] = l
+	default:
+		s.labels = append(s.labels, l)
+	}
+	return s.save()
+}
+
+// save atomically writes the labels to disk
+func (s *LabelStore) save() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, l := range s.labels {
+		if err := enc.Encode(l); err != nil {
+			return fmt.Errorf("failed to encode labels: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create wallet directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write labels: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace labels: %w", err)
+	}
+	return nil
+}
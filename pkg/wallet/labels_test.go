@@ -0,0 +1,151 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestLabelRef(t *testing.T) {
+	net := &chaincfg.MainNetParams
+	for ref, want := range map[string]string{testTxID: LabelTx, testTxID + ":1": LabelOutput, testTaprootAddr: LabelAddress} {
+		if got, err := LabelRef(ref, net); err != nil || got != want {
+			t.Errorf("LabelRef(%s) = %s, %v, want %s", ref, got, err, want)
+		}
+	}
+	if _, err := LabelRef("not-a-ref", net); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel, got %v", err)
+	}
+}
+
+func TestLabelStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.jsonl")
+	store, err := OpenLabelStore(path)
+	if err != nil {
+		t.Fatalf("OpenLabelStore() error = %v", err)
+	}
+
+	if err := store.Set(Label{Type: LabelAddress, Ref: testTaprootAddr, Label: "Invoice #42"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := store.Set(Label{Type: LabelTx, Ref: testTxID, Label: "Rent"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := store.Set(Label{Type: "utxo", Ref: testTxID, Label: "x"}); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for an unknown type, got %v", err)
+	}
+
+	// Labels survive a reload; replacing and clearing work by type and ref
+	store, err = OpenLabelStore(path)
+	if err != nil {
+		t.Fatalf("OpenLabelStore() error = %v", err)
+	}
+	if got := store.Get(LabelAddress, testTaprootAddr); got != "Invoice #42" {
+		t.Errorf("Get() = %q", got)
+	}
+	if err := store.Set(Label{Type: LabelTx, Ref: testTxID, Label: "March rent"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if list := store.List(); len(list) != 2 || list[0].Type != LabelAddress || list[1].Label != "March rent" {
+		t.Errorf("Unexpected labels: %+v", list)
+	}
+	if err := store.Set(Label{Type: LabelAddress, Ref: testTaprootAddr}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := store.Get(LabelAddress, testTaprootAddr); got != "" {
+		t.Errorf("Expected the label to be cleared, got %q", got)
+	}
+}
+
+func TestLabelStoreBIP329(t *testing.T) {
+	store, err := OpenLabelStore(filepath.Join(t.TempDir(), "labels.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenLabelStore() error = %v", err)
+	}
+	store.Set(Label{Type: LabelTx, Ref: testTxID, Label: "old"})
+
+	// Records from another wallet, including types this one does not set
+	records := `{"type":"tx","ref":"` + testTxID + `","label":"Transaction","origin":"wpkh([d34db33f/84'/0'/0'])"}
+
+{"type":"xpub","ref":"` + bip86AccountXpub + `","label":"Cold storage"}
+{"type":"addr","ref":"` + testTaprootAddr + `","label":""}
+`
+	n, err := store.Import(strings.NewReader(records))
+	if err != nil || n != 2 {
+		t.Fatalf("Import() = %d, %v, want 2", n, err)
+	}
+	if got := store.Get(LabelTx, testTxID); got != "Transaction" {
+		t.Errorf("Expected the import to replace the label, got %q", got)
+	}
+
+	var out bytes.Buffer
+	if err := store.Export(&out); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"origin":"wpkh([d34db33f/84'/0'/0'])"`) || !strings.HasPrefix(lines[1], `{"type":"xpub"`) {
+		t.Errorf("Unexpected export:\n%s", out.String())
+	}
+
+	if _, err := store.Import(strings.NewReader("{\"type\":\"tx\"}\n")); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for a record without ref, got %v", err)
+	}
+	if _, err := store.Import(strings.NewReader("not json\n")); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for malformed JSON, got %v", err)
+	}
+}
+
+func TestLabelStoreFreeze(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.jsonl")
+	store, err := OpenLabelStore(path)
+	if err != nil {
+		t.Fatalf("OpenLabelStore() error = %v", err)
+	}
+	utxos := []UTXO{{TxID: testTxID, Vout: 0}, {TxID: testTxID, Vout: 1}}
+	outpoint := utxos[1].Outpoint()
+
+	if err := store.Set(Label{Type: LabelOutput, Ref: outpoint, Label: "Tainted"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := store.Freeze(outpoint, true); err != nil {
+		t.Fatalf("Freeze() error = %v", err)
+	}
+	if err := store.Freeze("not-an-outpoint", true); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel, got %v", err)
+	}
+
+	// Frozen state survives a reload and relabelling, and is BIP-329 spendable
+	store, err = OpenLabelStore(path)
+	if err != nil {
+		t.Fatalf("OpenLabelStore() error = %v", err)
+	}
+	if err := store.Set(Label{Type: LabelOutput, Ref: outpoint, Label: "Tainted coin"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if !store.Frozen(outpoint) || store.Get(LabelOutput, outpoint) != "Tainted coin" {
+		t.Errorf("Expected a labelled frozen output, got %+v", store.List())
+	}
+	if spendable := store.Spendable(utxos); len(spendable) != 1 || spendable[0].Vout != 0 {
+		t.Errorf("Spendable() = %+v", spendable)
+	}
+	var out bytes.Buffer
+	store.Export(&out)
+	if !strings.Contains(out.String(), `"spendable":false`) {
+		t.Errorf("Unexpected export:\n%s", out.String())
+	}
+
+	// Unfreezing an unlabelled output removes its record
+	if err := store.Set(Label{Type: LabelOutput, Ref: outpoint}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := store.Freeze(outpoint, false); err != nil {
+		t.Fatalf("Freeze() error = %v", err)
+	}
+	if store.Frozen(outpoint) || len(store.List()) != 0 {
+		t.Errorf("Expected no labels left, got %+v", store.List())
+	}
+}
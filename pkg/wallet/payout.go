@@ -0,0 +1,361 @@
+package wallet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/economy"
+)
+
+// DustLimit is the smallest output value, in satoshis, a batch will create
+const DustLimit = 546
+
+// Size estimates in virtual bytes used for batch fee calculation
+const (
+	txOverheadVSize   = 11 // version, locktime, counts and segwit marker
+	taprootInputVSize = 58 // key-path spend
+	wpkhInputVSize    = 68 // P2WPKH spend
+	outputBaseVSize   = 9  // value and script length
+)
+
+var (
+	// ErrNoPayouts indicates an empty payout list
+	ErrNoPayouts = errors.New("no payouts")
+	// ErrDustOutput indicates a payout below DustLimit
+	ErrDustOutput = errors.New("payout below dust limit")
+	// ErrInsufficientFunds indicates the UTXOs cannot cover payouts and fee
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// Payout is a single address→amount pair of a batch payout
+type Payout struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount"`
+}
+
+// UTXO is a spendable output offered to a batch payout. Height is the
+// confirming block, or 0 while the output is unconfirmed.
+type UTXO struct {
+	TxID    string `json:"txid"`
+	Vout    uint32 `json:"vout"`
+	Value   int64  `json:"value"`
+	Address string `json:"address"`
+	Height  int32  `json:"height,omitempty"`
+}
+
+// Outpoint returns the output's txid:vout
+func (u UTXO) Outpoint() string {
+	return fmt.Sprintf("%s:%d", u.TxID, u.Vout)
+}
+
+// BatchResult is an unsigned consolidated payout transaction
+type BatchResult struct {
+	Packet  *psbt.Packet
+	Inputs  []UTXO
+	Fee     int64
+	Change  int64
+	Payouts []Payout
+	// Commitment is the forge commitment carried in an OP_RETURN output
+	Commitment []byte
+}
+
+// ParseAmount parses a decimal EXS amount (up to 8 decimals) into satoshis
+func ParseAmount(s string) (int64, error) {
+	amount, err := economy.ParseAmount(s)
+	return int64(amount), err
+}
+
+// ParsePayouts reads address→amount pairs with amounts in EXS. format is
+// "csv" (address,amount per line, optional header) or "json" (an array of
+// {"address","amount"} objects). Repeated addresses are merged into one
+// output, keeping the order of first appearance.
+func ParsePayouts(r io.Reader, format string) ([]Payout, error) {
+	type entry struct{ address, amount string }
+	var entries []entry
+
+	switch format {
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = 2
+		reader.TrimLeadingSpace = true
+		reader.Comment = '#'
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid payout csv: %w", err)
+		}
+		for i, rec := range records {
+			if i == 0 && strings.EqualFold(rec[0], "address") {
+				continue
+			}
+			entries = append(entries, entry{rec[0], rec[1]})
+		}
+
+	case "json":
+		var raw []struct {
+			Address string      `json:"address"`
+			Amount  json.Number `json:"amount"`
+		}
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid payout json: %w", err)
+		}
+		for _, p := range raw {
+			entries = append(entries, entry{p.Address, p.Amount.String()})
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported payout format %q", format)
+	}
+
+	var payouts []Payout
+	index := make(map[string]int)
+	for i, e := range entries {
+		amount, err := ParseAmount(e.amount)
+		if err != nil {
+			return nil, fmt.Errorf("payout %d: %w", i+1, err)
+		}
+		address := strings.TrimSpace(e.address)
+		if j, ok := index[address]; ok {
+			payouts[j].Amount += amount
+			continue
+		}
+		index[address] = len(payouts)
+		payouts = append(payouts, Payout{Address: address, Amount: amount})
+	}
+	if len(payouts) == 0 {
+		return nil, ErrNoPayouts
+	}
+	return payouts, nil
+}
+
+// SplitReward divides reward, in satoshis, between addresses in proportion
+// to their work, as a pool pays the miners of a round. The satoshis lost to
+// rounding go to the largest share, and shares below DustLimit are left
+// out, staying with the pool. Payouts are ordered by address.
+func SplitReward(reward int64, work map[string]float64) []Payout {
+	var total float64
+	for _, w := range work {
+		if w > 0 {
+			total += w
+		}
+	}
+	if reward <= 0 || total == 0 {
+		return nil
+	}
+
+	payouts := make([]Payout, 0, len(work))
+	var paid int64
+	largest := -1
+	for address, w := range work {
+		if w <= 0 {
+			continue
+		}
+		amount := int64(float64(reward) * (w / total))
+		paid += amount
+		payouts = append(payouts, Payout{Address: address, Amount: amount})
+		if largest < 0 || amount > payouts[largest].Amount {
+			largest = len(payouts) - 1
+		}
+	}
+	payouts[largest].Amount += reward - paid
+
+	kept := payouts[:0]
+	for _, p := range payouts {
+		if p.Amount >= DustLimit {
+			kept = append(kept, p)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Address < kept[j].Address
+	})
+	return kept
+}
+
+// WritePayouts writes payouts as the CSV ParsePayouts reads
+func WritePayouts(w io.Writer, payouts []Payout) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"address", "amount"})
+	for _, p := range payouts {
+		amount := fmt.Sprintf("%d.%08d", p.Amount/btcutil.SatoshiPerBitcoin, p.Amount%btcutil.SatoshiPerBitcoin)
+		writer.Write([]string{p.Address, amount})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// BuildBatch builds one unsigned transaction paying every payout from utxos.
+// Inputs are selected largest first; change worth less than DustLimit or
+// than the fee to spend it later is left to the fee. feeRate is in
+// satoshis per virtual byte. The transaction signals replace-by-fee; clear
+// it with SetRBF before signing to opt out.
+func BuildBatch(utxos []UTXO, payouts []Payout, change string, feeRate int64, net *chaincfg.Params) (*BatchResult, error) {
+	return BuildBatchWithOptions(utxos, payouts, change, feeRate, BatchOptions{}, net)
+}
+
+// BuildBatchWithCommitment is BuildBatch with an OP_RETURN output committing
+// to commitment placed after the payouts and before change. A nil
+// commitment adds no output.
+func BuildBatchWithCommitment(utxos []UTXO, payouts []Payout, change string, feeRate int64, commitment []byte, net *chaincfg.Params) (*BatchResult, error) {
+	return BuildBatchWithOptions(utxos, payouts, change, feeRate, BatchOptions{Commitment: commitment}, net)
+}
+
+// BatchOptions controls how BuildBatchWithOptions builds a batch
+type BatchOptions struct {
+	// Commitment adds an OP_RETURN output committing to it after the
+	// payouts and before change
+	Commitment []byte
+	// Strategy selects the inputs; empty means largest first
+	Strategy SelectionStrategy
+	// SpendAll spends every offered UTXO instead of selecting, for coins
+	// chosen by hand
+	SpendAll bool
+}
+
+// BuildBatchWithOptions is BuildBatch with the inputs selected, and an
+// optional commitment added, as opts asks
+func BuildBatchWithOptions(utxos []UTXO, payouts []Payout, change string, feeRate int64, opts BatchOptions, net *chaincfg.Params) (*BatchResult, error) {
+	if len(payouts) == 0 {
+		return nil, ErrNoPayouts
+	}
+	if feeRate <= 0 {
+		return nil, fmt.Errorf("invalid fee rate %d", feeRate)
+	}
+
+	tx := wire.NewMsgTx(2)
+	var payoutTotal int64
+	for _, p := range payouts {
+		if p.Amount < DustLimit {
+			return nil, fmt.Errorf("%w: %s %d", ErrDustOutput, p.Address, p.Amount)
+		}
+		pkScript, err := addressScript(p.Address, net)
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(p.Amount, pkScript))
+		payoutTotal += p.Amount
+	}
+	if opts.Commitment != nil {
+		script, err := CommitmentScript(opts.Commitment)
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(0, script))
+	}
+	changeScript, err := addressScript(change, net)
+	if err != nil {
+		return nil, fmt.Errorf("invalid change address: %w", err)
+	}
+
+	coins, err := newCoins(utxos, feeRate, net)
+	if err != nil {
+		return nil, err
+	}
+	vsize := int64(txOverheadVSize)
+	for _, out := range tx.TxOut {
+		vsize += int64(outputBaseVSize + len(out.PkScript))
+	}
+	changeVSize := int64(outputBaseVSize + len(changeScript))
+	changeSpendVSize, err := inputVSize(changeScript)
+	if err != nil {
+		changeSpendVSize = taprootInputVSize
+	}
+	// Change must be worth spending: above dust and the fee of its input
+	minChange := max(DustLimit, changeSpendVSize*feeRate)
+
+	// Coins cover the outputs and the fee of all but their own inputs
+	target := payoutTotal + vsize*feeRate
+	selected := coins
+	if !opts.SpendAll {
+		selected = selectCoins(coins, target, (changeVSize+changeSpendVSize)*feeRate, changeVSize*feeRate+minChange, opts.Strategy)
+	}
+	var inputTotal, effectiveTotal int64
+	for _, c := range selected {
+		inputTotal += c.utxo.Value
+		effectiveTotal += c.effective
+	}
+	if selected == nil || effectiveTotal < target {
+		var have, need int64 = 0, target
+		for _, c := range coins {
+			have += c.utxo.Value
+			need += c.utxo.Value - c.effective
+		}
+		if opts.SpendAll {
+			have, need = inputTotal, target+inputTotal-effectiveTotal
+		}
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientFunds, have, need)
+	}
+
+	var inputs []UTXO
+	var prevOuts []*wire.TxOut
+	for _, c := range selected {
+		hash, err := chainhash.NewHashFromStr(c.utxo.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", c.utxo.TxID, c.utxo.Vout, err)
+		}
+		in := wire.NewTxIn(wire.NewOutPoint(hash, c.utxo.Vout), nil, nil)
+		in.Sequence = RBFSequence
+		tx.AddTxIn(in)
+		inputs = append(inputs, c.utxo)
+		prevOuts = append(prevOuts, wire.NewTxOut(c.utxo.Value, c.pkScript))
+	}
+
+	changeValue := effectiveTotal - target - changeVSize*feeRate
+	if changeValue >= minChange {
+		tx.AddTxOut(wire.NewTxOut(changeValue, changeScript))
+	} else {
+		changeValue = 0
+	}
+	fee := inputTotal - payoutTotal - changeValue
+
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create psbt: %w", err)
+	}
+	for i, prevOut := range prevOuts {
+		packet.Inputs[i].WitnessUtxo = prevOut
+	}
+
+	return &BatchResult{
+		Packet:     packet,
+		Inputs:     inputs,
+		Fee:        fee,
+		Change:     changeValue,
+		Payouts:    payouts,
+		Commitment: opts.Commitment,
+	}, nil
+}
+
+func addressScript(address string, net *chaincfg.Params) ([]byte, error) {
+	addr, err := btcutil.DecodeAddress(address, net)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", address, err)
+	}
+	if !addr.IsForNet(net) {
+		return nil, fmt.Errorf("address %s is not for %s", address, net.Name)
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+func inputVSize(pkScript []byte) (int64, error) {
+	switch {
+	case txscript.IsPayToTaproot(pkScript):
+		return taprootInputVSize, nil
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		return wpkhInputVSize, nil
+	default:
+		return 0, errors.New("only P2TR and P2WPKH inputs are supported")
+	}
+}
@@ -0,0 +1,200 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	testTaprootAddr = "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"
+	testWPKHAddr    = "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"
+	testTxID        = "0000000000000000000000000000000000000000000000000000000000000001"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"1", 100000000, false},
+		{"0.5", 50000000, false},
+		{".00000001", 1, false},
+		{"21000000", 2100000000000000, false},
+		{"0.123456789", 0, true},
+		{"-1", 0, true},
+		{"1.-5", 0, true},
+		{"1.+5", 0, true},
+		{"0.-0000001", 0, true},
+		{"abc", 0, true},
+		{"", 0, true},
+		{"21000001", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseAmount(%q): expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("ParseAmount(%q): expected %d, got %d (%v)", tt.input, tt.expected, got, err)
+		}
+	}
+}
+
+func TestParsePayouts(t *testing.T) {
+	csvInput := "address,amount\n" +
+		testTaprootAddr + ",0.5\n" +
+		"# comment\n" +
+		testWPKHAddr + ", 0.25\n" +
+		testTaprootAddr + ",0.1\n"
+	jsonInput := `[{"address":"` + testTaprootAddr + `","amount":0.5},` +
+		`{"address":"` + testWPKHAddr + `","amount":"0.25"},` +
+		`{"address":"` + testTaprootAddr + `","amount":0.1}]`
+
+	for format, input := range map[string]string{"csv": csvInput, "json": jsonInput} {
+		payouts, err := ParsePayouts(strings.NewReader(input), format)
+		if err != nil {
+			t.Fatalf("%s: ParsePayouts() error = %v", format, err)
+		}
+		if len(payouts) != 2 {
+			t.Fatalf("%s: expected 2 merged payouts, got %d", format, len(payouts))
+		}
+		if payouts[0].Address != testTaprootAddr || payouts[0].Amount != 60000000 {
+			t.Errorf("%s: unexpected first payout %+v", format, payouts[0])
+		}
+		if payouts[1].Amount != 25000000 {
+			t.Errorf("%s: unexpected second payout %+v", format, payouts[1])
+		}
+	}
+
+	if _, err := ParsePayouts(strings.NewReader("address,amount\n"), "csv"); !errors.Is(err, ErrNoPayouts) {
+		t.Errorf("Expected ErrNoPayouts, got %v", err)
+	}
+	if _, err := ParsePayouts(strings.NewReader(""), "xml"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestSplitReward(t *testing.T) {
+	payouts := SplitReward(5000000000, map[string]float64{
+		testWPKHAddr:    1,
+		testTaprootAddr: 2,
+		"idle":          0,
+	})
+	if len(payouts) != 2 {
+		t.Fatalf("SplitReward() = %+v, want the idle worker left out", payouts)
+	}
+	// The taproot address gets its two thirds plus the rounding remainder
+	if payouts[0].Address != testTaprootAddr || payouts[0].Amount != 3333333334 {
+		t.Errorf("Unexpected first payout %+v", payouts[0])
+	}
+	if payouts[1].Address != testWPKHAddr || payouts[1].Amount != 1666666666 {
+		t.Errorf("Unexpected second payout %+v", payouts[1])
+	}
+	if dust := SplitReward(1000, map[string]float64{testTaprootAddr: 1, testWPKHAddr: 1}); len(dust) != 0 {
+		t.Errorf("Expected dust shares to stay with the pool, got %+v", dust)
+	}
+	if SplitReward(5000000000, nil) != nil {
+		t.Error("Expected no payouts for a round without work")
+	}
+
+	var buf strings.Builder
+	if err := WritePayouts(&buf, payouts); err != nil {
+		t.Fatalf("WritePayouts() error = %v", err)
+	}
+	parsed, err := ParsePayouts(strings.NewReader(buf.String()), "csv")
+	if err != nil || len(parsed) != 2 || parsed[0] != payouts[0] || parsed[1] != payouts[1] {
+		t.Errorf("ParsePayouts(WritePayouts()) = %+v, %v, want %+v", parsed, err, payouts)
+	}
+}
+
+func TestBuildBatch(t *testing.T) {
+	utxos := []UTXO{
+		{TxID: testTxID, Vout: 0, Value: 30000, Address: testTaprootAddr},
+		{TxID: testTxID, Vout: 1, Value: 500000, Address: testWPKHAddr},
+		{TxID: testTxID, Vout: 2, Value: 200000, Address: testTaprootAddr},
+	}
+	payouts := []Payout{
+		{Address: testTaprootAddr, Amount: 300000},
+		{Address: testWPKHAddr, Amount: 100000},
+	}
+
+	result, err := BuildBatch(utxos, payouts, testWPKHAddr, 2, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildBatch() error = %v", err)
+	}
+
+	// Largest UTXO alone covers the payouts
+	if len(result.Inputs) != 1 || result.Inputs[0].Vout != 1 {
+		t.Errorf("Expected largest UTXO selected, got %+v", result.Inputs)
+	}
+	tx := result.Packet.UnsignedTx
+	if len(tx.TxOut) != 3 {
+		t.Fatalf("Expected 2 payouts and change, got %d outputs", len(tx.TxOut))
+	}
+
+	var outputTotal int64
+	for _, out := range tx.TxOut {
+		outputTotal += out.Value
+	}
+	if outputTotal+result.Fee != 500000 {
+		t.Errorf("Expected inputs = outputs + fee, got %d + %d", outputTotal, result.Fee)
+	}
+	if result.Change != tx.TxOut[2].Value {
+		t.Errorf("Expected change %d, got %d", tx.TxOut[2].Value, result.Change)
+	}
+	if result.Packet.Inputs[0].WitnessUtxo == nil {
+		t.Error("Expected witness UTXO on input")
+	}
+}
+
+func TestBuildBatchDropsDustChange(t *testing.T) {
+	utxos := []UTXO{{TxID: testTxID, Vout: 0, Value: 100300, Address: testTaprootAddr}}
+	payouts := []Payout{{Address: testWPKHAddr, Amount: 100000}}
+
+	result, err := BuildBatch(utxos, payouts, testTaprootAddr, 1, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildBatch() error = %v", err)
+	}
+	if len(result.Packet.UnsignedTx.TxOut) != 1 || result.Change != 0 {
+		t.Errorf("Expected dust change to be dropped, got %d outputs", len(result.Packet.UnsignedTx.TxOut))
+	}
+	if result.Fee != 300 {
+		t.Errorf("Expected fee 300, got %d", result.Fee)
+	}
+}
+
+func TestBuildBatchErrors(t *testing.T) {
+	utxos := []UTXO{{TxID: testTxID, Vout: 0, Value: 10000, Address: testTaprootAddr}}
+
+	tests := []struct {
+		name    string
+		payouts []Payout
+		change  string
+		wantErr error
+	}{
+		{"no payouts", nil, testTaprootAddr, ErrNoPayouts},
+		{"dust", []Payout{{Address: testWPKHAddr, Amount: 100}}, testTaprootAddr, ErrDustOutput},
+		{"insufficient", []Payout{{Address: testWPKHAddr, Amount: 20000}}, testTaprootAddr, ErrInsufficientFunds},
+		{"bad change", []Payout{{Address: testWPKHAddr, Amount: 1000}}, "notanaddress", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildBatch(utxos, tt.payouts, tt.change, 1, &chaincfg.MainNetParams)
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
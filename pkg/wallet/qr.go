@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// DefaultQRSize is the default width and height of PNG QR codes in pixels
+	DefaultQRSize = 256
+	// MaxQRSize bounds the PNG QR codes QRHandler renders
+	MaxQRSize = 1024
+)
+
+// QRContent returns what a QR code for the request should encode: the bare
+// address when there is nothing else to say, which every wallet can scan,
+// and the exs: URI otherwise
+func (r *PaymentRequest) QRContent() string {
+	if r.Amount == 0 && r.Label == "" && r.Message == "" {
+		return r.Address
+	}
+	return r.URI()
+}
+
+// QRTerminal renders content as a QR code drawn with Unicode half blocks,
+// two modules per character row, for printing to a terminal with a dark
+// background
+func QRTerminal(content string) (string, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode qr code: %w", err)
+	}
+	return code.ToSmallString(false), nil
+}
+
+// QRPNG renders content as a size×size pixel PNG QR code
+func QRPNG(content string, size int) ([]byte, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode qr code: %w", err)
+	}
+	png, err := code.PNG(size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render qr code: %w", err)
+	}
+	return png, nil
+}
+
+// QRHandler serves QR codes for handing addresses and payment requests to
+// mobile wallets. It answers GET requests with either
+//
+//	?address=<address>[&amount=<EXS>][&label=<label>][&message=<message>]
+//	?uri=<exs: URI>
+//
+// plus format=png (default) or format=text, and size=<pixels> for PNGs.
+// Addresses must belong to net.
+func QRHandler(net *chaincfg.Params) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req, err := qrRequest(r, net)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "png":
+			size := DefaultQRSize
+			if s := r.URL.Query().Get("size"); s != "" {
+				if size, err = strconv.Atoi(s); err != nil || size < 1 || size > MaxQRSize {
+					http.Error(w, fmt.Sprintf("size must be between 1 and %d", MaxQRSize), http.StatusBadRequest)
+					return
+				}
+			}
+			png, err := QRPNG(req.QRContent(), size)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+		case "text":
+			text, err := QRTerminal(req.QRContent())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(text))
+		default:
+			http.Error(w, fmt.Sprintf("unknown format %q (use png or text)", format), http.StatusBadRequest)
+		}
+	})
+}
+
+// qrRequest builds the payment request described by a QRHandler query
+func qrRequest(r *http.Request, net *chaincfg.Params) (*PaymentRequest, error) {
+	query := r.URL.Query()
+	if uri := query.Get("uri"); uri != "" {
+		return ParsePaymentURI(uri, net)
+	}
+
+	address := query.Get("address")
+	if address == "" {
+		return nil, fmt.Errorf("address or uri is required")
+	}
+	if _, err := addressScript(address, net); err != nil {
+		return nil, err
+	}
+	req := &PaymentRequest{Address: address, Label: query.Get("label"), Message: query.Get("message")}
+	if amount := query.Get("amount"); amount != "" {
+		var err error
+		if req.Amount, err = ParseAmount(amount); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
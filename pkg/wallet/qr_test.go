@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestQRContent(t *testing.T) {
+	bare := &PaymentRequest{Address: testTaprootAddr}
+	if bare.QRContent() != testTaprootAddr {
+		t.Errorf("Expected the bare address, got %s", bare.QRContent())
+	}
+	req := &PaymentRequest{Address: testTaprootAddr, Amount: 100000000}
+	if req.QRContent() != req.URI() {
+		t.Errorf("Expected the payment URI, got %s", req.QRContent())
+	}
+}
+
+func TestQRPNG(t *testing.T) {
+	data, err := QRPNG("exs:"+testTaprootAddr+"?amount=1", 200)
+	if err != nil {
+		t.Fatalf("QRPNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Invalid PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 200 {
+		t.Errorf("Expected 200x200 image, got %v", b)
+	}
+}
+
+func TestQRHandler(t *testing.T) {
+	h := QRHandler(&chaincfg.MainNetParams)
+	uri := url.QueryEscape("exs:" + testTaprootAddr + "?amount=2")
+	tests := []struct {
+		name        string
+		query       string
+		status      int
+		contentType string
+	}{
+		{"address", "address=" + testTaprootAddr, http.StatusOK, "image/png"},
+		{"payment request", "address=" + testWPKHAddr + "&amount=0.5&label=Merlin", http.StatusOK, "image/png"},
+		{"uri as text", "uri=" + uri + "&format=text", http.StatusOK, "text/plain; charset=utf-8"},
+		{"missing address", "", http.StatusBadRequest, ""},
+		{"invalid address", "address=bc1qnotanaddress", http.StatusBadRequest, ""},
+		{"invalid amount", "address=" + testTaprootAddr + "&amount=-1", http.StatusBadRequest, ""},
+		{"invalid uri", "uri=bitcoin:" + testTaprootAddr, http.StatusBadRequest, ""},
+		{"oversized", "address=" + testTaprootAddr + "&size=4096", http.StatusBadRequest, ""},
+		{"unknown format", "address=" + testTaprootAddr + "&format=svg", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/qr?"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.status, rec.Code, rec.Body)
+			continue
+		}
+		if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.contentType, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/qr?format=text&address="+testTaprootAddr, nil))
+	if !strings.Contains(rec.Body.String(), "▄") {
+		t.Errorf("Expected a half-block QR code, got %q", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/qr?address="+testTaprootAddr, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
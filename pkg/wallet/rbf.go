@@ -0,0 +1,34 @@
+package wallet
+
+import (
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// RBFSequence is the input sequence used to signal BIP-125 replaceability
+	RBFSequence = wire.MaxTxInSequenceNum - 2
+	// FinalSequence opts out of replacement while keeping nLockTime enforced
+	FinalSequence = wire.MaxTxInSequenceNum - 1
+)
+
+// SetRBF sets or clears BIP-125 replace-by-fee signaling on every input of tx
+func SetRBF(tx *wire.MsgTx, enabled bool) {
+	sequence := uint32(FinalSequence)
+	if enabled {
+		sequence = RBFSequence
+	}
+	for _, in := range tx.TxIn {
+		in.Sequence = sequence
+	}
+}
+
+// SignalsRBF reports whether tx explicitly signals BIP-125 replaceability,
+// which any input with a sequence below FinalSequence does
+func SignalsRBF(tx *wire.MsgTx) bool {
+	for _, in := range tx.TxIn {
+		if in.Sequence < FinalSequence {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestSetRBF(t *testing.T) {
+	tx := testParent(t)
+	if SignalsRBF(tx) {
+		t.Error("Expected max sequence to not signal RBF")
+	}
+
+	SetRBF(tx, true)
+	if !SignalsRBF(tx) || tx.TxIn[0].Sequence != RBFSequence {
+		t.Errorf("Expected RBF sequence, got %x", tx.TxIn[0].Sequence)
+	}
+
+	SetRBF(tx, false)
+	if SignalsRBF(tx) || tx.TxIn[0].Sequence != FinalSequence {
+		t.Errorf("Expected final sequence, got %x", tx.TxIn[0].Sequence)
+	}
+
+	tx.TxIn[0].Sequence = wire.MaxTxInSequenceNum
+	if SignalsRBF(tx) {
+		t.Error("Expected max sequence to not signal RBF")
+	}
+}
+
+func TestBuildersSignalRBF(t *testing.T) {
+	utxos := []UTXO{{TxID: testParent(t).TxHash().String(), Vout: 0, Value: 100000, Address: testTaprootAddr}}
+	batch, err := BuildBatch(utxos, []Payout{{Address: testWPKHAddr, Amount: 50000}}, testTaprootAddr, 10, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildBatch() error = %v", err)
+	}
+	if !SignalsRBF(batch.Packet.UnsignedTx) {
+		t.Error("Expected batch to signal RBF by default")
+	}
+
+	child, err := BuildCPFP(testParent(t), 1, 0, 20, testTaprootAddr, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildCPFP() error = %v", err)
+	}
+	if !SignalsRBF(child.Packet.UnsignedTx) {
+		t.Error("Expected CPFP child to signal RBF by default")
+	}
+}
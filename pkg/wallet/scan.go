@@ -0,0 +1,207 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// DefaultGapLimit is the number of consecutive unused addresses after which
+// a ranged scan stops (BIP-44 recommendation)
+const DefaultGapLimit = 20
+
+// UsageChecker reports whether an output script has ever received funds
+type UsageChecker interface {
+	ScriptUsed(ctx context.Context, pkScript []byte) (bool, error)
+}
+
+// ScanResult is the outcome of scanning one descriptor
+type ScanResult struct {
+	Descriptor string
+	Used       []*DerivedAddress
+	// NextIndex is the first unused index on each branch
+	NextIndex []uint32
+	Scanned   int
+}
+
+// ScanProgress reports the state of a background rescan
+type ScanProgress struct {
+	Descriptors int
+	Completed   int
+	Scanned     int
+	Found       int
+	Done        bool
+	Err         error
+}
+
+// Scanner walks ranged descriptors with a gap limit, asking a UsageChecker
+// which derived scripts have activity on chain
+type Scanner struct {
+	checker  UsageChecker
+	network  *chaincfg.Params
+	gapLimit uint32
+}
+
+// NewScanner creates a scanner. A gapLimit of 0 selects DefaultGapLimit.
+func NewScanner(checker UsageChecker, network *chaincfg.Params, gapLimit uint32) *Scanner {
+	if gapLimit == 0 {
+		gapLimit = DefaultGapLimit
+	}
+	return &Scanner{
+		checker:  checker,
+		network:  network,
+		gapLimit: gapLimit,
+	}
+}
+
+// Scan scans a descriptor starting at index start and never deriving past
+// end (0 means unbounded). Each branch stops after gapLimit unused addresses.
+func (s *Scanner) Scan(ctx context.Context, d *Descriptor, start, end uint32) (*ScanResult, error) {
+	return s.scan(ctx, d, start, end, nil)
+}
+
+func (s *Scanner) scan(ctx context.Context, d *Descriptor, start, end uint32, onAddr func(used bool)) (*ScanResult, error) {
+	if s.checker == nil {
+		return nil, errors.New("scanner has no usage checker")
+	}
+
+	result := &ScanResult{
+		Descriptor: d.String(),
+		NextIndex:  make([]uint32, d.Branches()),
+	}
+
+	for branch := 0; branch < d.Branches(); branch++ {
+		next := start
+		gap := uint32(0)
+
+		for idx := start; ; idx++ {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			if end > 0 && idx >= end {
+				break
+			}
+
+			addr, err := d.Derive(branch, idx, s.network)
+			if err != nil {
+				return result, err
+			}
+			used, err := s.checker.ScriptUsed(ctx, addr.PkScript)
+			if err != nil {
+				return result, fmt.Errorf("failed to check %s: %w", addr.Address, err)
+			}
+			result.Scanned++
+			if onAddr != nil {
+				onAddr(used)
+			}
+
+			if used {
+				result.Used = append(result.Used, addr)
+				next = idx + 1
+				gap = 0
+			} else {
+				gap++
+			}
+
+			if !d.Ranged || gap >= s.gapLimit {
+				break
+			}
+		}
+
+		result.NextIndex[branch] = next
+	}
+
+	return result, nil
+}
+
+// RescanJob is a rescan running in the background
+type RescanJob struct {
+	mu       sync.RWMutex
+	progress ScanProgress
+	results  []*ScanResult
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// StartRescan scans imported descriptors over their ranges in a background
+// goroutine. Results are only kept for descriptors scanned to the end, so
+// each can be passed to DescriptorStore.RecordScan.
+func (s *Scanner) StartRescan(ctx context.Context, entries []ImportedDescriptor) *RescanJob {
+	ctx, cancel := context.WithCancel(ctx)
+	job := &RescanJob{
+		progress: ScanProgress{Descriptors: len(entries)},
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(job.done)
+		defer cancel()
+
+		for _, entry := range entries {
+			d, err := ParseDescriptor(entry.Descriptor)
+			if err != nil {
+				job.fail(err)
+				return
+			}
+			result, err := s.scan(ctx, d, entry.RangeStart, entry.RangeEnd, func(used bool) {
+				job.mu.Lock()
+				job.progress.Scanned++
+				if used {
+					job.progress.Found++
+				}
+				job.mu.Unlock()
+			})
+
+			if err != nil {
+				job.fail(err)
+				return
+			}
+			job.mu.Lock()
+			job.results = append(job.results, result)
+			job.progress.Completed++
+			job.mu.Unlock()
+		}
+
+		job.mu.Lock()
+		job.progress.Done = true
+		job.mu.Unlock()
+	}()
+
+	return job
+}
+
+// fail ends the job with err
+func (j *RescanJob) fail(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.progress.Err = err
+	j.progress.Done = true
+}
+
+// Progress returns a snapshot of the job's progress
+func (j *RescanJob) Progress() ScanProgress {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.progress
+}
+
+// Cancel stops the rescan
+func (j *RescanJob) Cancel() {
+	j.cancel()
+}
+
+// Wait blocks until the rescan finishes and returns the results of the
+// descriptors it completed
+func (j *RescanJob) Wait() ([]*ScanResult, error) {
+	<-j.done
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	results := make([]*ScanResult, len(j.results))
+	copy(results, j.results)
+	return results, j.progress.Err
+}
@@ -0,0 +1,238 @@
+package wallet
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// mockChecker reports a fixed set of scripts as used
+type mockChecker struct {
+	used map[string]bool
+}
+
+func (m *mockChecker) ScriptUsed(ctx context.Context, pkScript []byte) (bool, error) {
+	return m.used[string(pkScript)], nil
+}
+
+func newMockChecker(t *testing.T, d *Descriptor, branch int, indexes ...uint32) *mockChecker {
+	m := &mockChecker{used: make(map[string]bool)}
+	for _, idx := range indexes {
+		addr, err := d.Derive(branch, idx, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("Derive() error = %v", err)
+		}
+		m.used[string(addr.PkScript)] = true
+	}
+	return m
+}
+
+func TestScannerGapLimit(t *testing.T) {
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/<0;1>/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+
+	// Index 25 is beyond a gap of 20 after index 3, so it must not be found
+	checker := newMockChecker(t, d, 0, 0, 3, 25)
+	scanner := NewScanner(checker, &chaincfg.MainNetParams, 0)
+
+	result, err := scanner.Scan(context.Background(), d, 0, 0)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(result.Used) != 2 {
+		t.Errorf("Expected 2 used addresses, got %d", len(result.Used))
+	}
+	if result.NextIndex[0] != 4 {
+		t.Errorf("Expected next receive index 4, got %d", result.NextIndex[0])
+	}
+	if result.NextIndex[1] != 0 {
+		t.Errorf("Expected next change index 0, got %d", result.NextIndex[1])
+	}
+	// Receive branch: 4 + 20 gap, change branch: 20 gap
+	if result.Scanned != 44 {
+		t.Errorf("Expected 44 scanned addresses, got %d", result.Scanned)
+	}
+}
+
+func TestScannerRangeEnd(t *testing.T) {
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/0/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+
+	checker := newMockChecker(t, d, 0, 2)
+	scanner := NewScanner(checker, &chaincfg.MainNetParams, 5)
+
+	result, err := scanner.Scan(context.Background(), d, 0, 3)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if result.Scanned != 3 {
+		t.Errorf("Expected scan to stop at range end (3), scanned %d", result.Scanned)
+	}
+}
+
+func TestStartRescan(t *testing.T) {
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/0/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+
+	store, err := OpenDescriptorStore(filepath.Join(t.TempDir(), "descriptors.json"))
+	if err != nil {
+		t.Fatalf("OpenDescriptorStore() error = %v", err)
+	}
+	if _, err := store.Import(d, "", 0, 1000, true); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	checker := newMockChecker(t, d, 0, 0, 1)
+	scanner := NewScanner(checker, &chaincfg.MainNetParams, 5)
+
+	job := scanner.StartRescan(context.Background(), store.List())
+	results, err := job.Wait()
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	progress := job.Progress()
+	if !progress.Done || progress.Completed != 1 || progress.Found != 2 {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+	if len(results) != 1 || results[0].NextIndex[0] != 2 {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+	if err := store.RecordScan(results[0]); err != nil {
+		t.Fatalf("RecordScan() error = %v", err)
+	}
+	if list := store.List(); list[0].NeedsScan || list[0].NextIndex[0] != 2 {
+		t.Errorf("Unexpected entry after the rescan: %+v", list[0])
+	}
+}
+
+func TestStartRescanCancel(t *testing.T) {
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/0/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	scanner := NewScanner(&mockChecker{}, &chaincfg.MainNetParams, 0)
+	job := scanner.StartRescan(ctx, []ImportedDescriptor{{Descriptor: d.String(), RangeEnd: 1000}})
+	results, err := job.Wait()
+	if err == nil {
+		t.Error("Expected error from cancelled rescan")
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results from a cancelled rescan, got %d", len(results))
+	}
+}
+
+func TestDescriptorStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "descriptors.json")
+
+	store, err := OpenDescriptorStore(path)
+	if err != nil {
+		t.Fatalf("OpenDescriptorStore() error = %v", err)
+	}
+
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/<0;1>/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+
+	if _, err := store.Import(d, "migrated", 0, 1000, true); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if err := store.RecordScan(&ScanResult{Descriptor: d.String(), NextIndex: []uint32{7, 2}}); err != nil {
+		t.Fatalf("RecordScan() error = %v", err)
+	}
+
+	reopened, err := OpenDescriptorStore(path)
+	if err != nil {
+		t.Fatalf("OpenDescriptorStore() error = %v", err)
+	}
+	list := reopened.List()
+	if len(list) != 1 {
+		t.Fatalf("Expected 1 descriptor, got %d", len(list))
+	}
+	if list[0].Label != "migrated" || list[0].NeedsScan || list[0].NextIndex[0] != 7 {
+		t.Errorf("Unexpected stored descriptor: %+v", list[0])
+	}
+
+	index, err := reopened.Reserve(d.String(), 1)
+	if err != nil || index != 2 {
+		t.Fatalf("Reserve() = %d, %v, want 2", index, err)
+	}
+	if index, _ := reopened.Reserve(d.String(), 1); index != 3 {
+		t.Errorf("second Reserve() = %d, want 3", index)
+	}
+	if _, err := reopened.Reserve(d.String(), 2); err == nil {
+		t.Error("Expected error for missing branch")
+	}
+
+	if _, err := store.Import(d, "", 10, 5, false); err == nil {
+		t.Error("Expected error for empty range")
+	}
+
+	if removed, err := reopened.Remove(d.String()); err != nil || !removed {
+		t.Errorf("Remove() = %v, %v", removed, err)
+	}
+	if removed, _ := reopened.Remove(d.String()); removed {
+		t.Error("Expected a second Remove() to find nothing")
+	}
+	if emptied, _ := OpenDescriptorStore(reopened.path); len(emptied.List()) != 0 {
+		t.Errorf("Expected no descriptors after Remove(), got %d", len(emptied.List()))
+	}
+}
+
+func TestDescriptorStoreKV(t *testing.T) {
+	legacyPath := filepath.Join(t.TempDir(), "descriptors.json")
+	legacy, err := OpenDescriptorStore(legacyPath)
+	if err != nil {
+		t.Fatalf("OpenDescriptorStore() error = %v", err)
+	}
+	d, err := ParseDescriptor("tr(" + bip86AccountXpub + "/<0;1>/*)")
+	if err != nil {
+		t.Fatalf("ParseDescriptor() error = %v", err)
+	}
+	if _, err := legacy.Import(d, "legacy", 0, 1000, false); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	// The first open copies the JSON store into the kv store
+	db := kv.NewMemory()
+	store, err := OpenDescriptorStoreKV(db, legacyPath)
+	if err != nil {
+		t.Fatalf("OpenDescriptorStoreKV() error = %v", err)
+	}
+	if list := store.List(); len(list) != 1 || list[0].Label != "legacy" {
+		t.Fatalf("Expected the legacy descriptor imported, got %+v", list)
+	}
+	if index, err := store.Reserve(d.String(), 0); err != nil || index != 0 {
+		t.Fatalf("Reserve() = %d, %v, want 0", index, err)
+	}
+
+	// Later opens read the kv store and ignore the JSON file
+	if _, err := legacy.Remove(d.String()); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenDescriptorStoreKV(db, legacyPath)
+	if err != nil {
+		t.Fatalf("OpenDescriptorStoreKV() error = %v", err)
+	}
+	defer reopened.Close()
+	list := reopened.List()
+	if len(list) != 1 || list[0].NextIndex[0] != 1 {
+		t.Errorf("Unexpected stored descriptors %+v", list)
+	}
+}
@@ -0,0 +1,211 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+	exspsbt "github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin/psbt"
+)
+
+// SigningRequestVersion is the current signing-request file format version
+const SigningRequestVersion = 1
+
+var (
+	// ErrRequestMismatch indicates a signed file does not answer the request
+	ErrRequestMismatch = errors.New("signed transaction does not match signing request")
+	// ErrIncompleteSignatures indicates a signed PSBT could not be finalized
+	ErrIncompleteSignatures = errors.New("psbt is missing signatures")
+)
+
+// SigningOutput summarizes a transaction output for offline review
+type SigningOutput struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount"`
+}
+
+// SigningRequest is a portable file carrying an unsigned PSBT plus the
+// metadata an air-gapped signer needs to review it. The signer returns the
+// same structure with PSBT replaced by the signed PSBT.
+type SigningRequest struct {
+	Version     int             `json:"version"`
+	ID          string          `json:"id"`
+	Wallet      string          `json:"wallet"`
+	Network     string          `json:"network"`
+	Description string          `json:"description,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Outputs     []SigningOutput `json:"outputs"`
+	InputTotal  int64           `json:"input_total"`
+	Fee         int64           `json:"fee"`
+	ChangeIndex *uint32         `json:"change_index,omitempty"`
+	RBF         bool            `json:"rbf"`
+	PSBT        string          `json:"psbt"`
+}
+
+// NewSigningRequest wraps an unsigned PSBT in a signing request. Input
+// amounts come from the PSBT's witness UTXO fields when present.
+func NewSigningRequest(packet *psbt.Packet, walletName, description string, net *chaincfg.Params) (*SigningRequest, error) {
+	var buf bytes.Buffer
+	if err := packet.Serialize(&buf); err != nil {
+		return nil, fmt.Errorf("failed to serialize psbt: %w", err)
+	}
+
+	req := &SigningRequest{
+		Version:     SigningRequestVersion,
+		ID:          packet.UnsignedTx.TxHash().String(),
+		Wallet:      walletName,
+		Network:     net.Name,
+		Description: description,
+		CreatedAt:   time.Now().UTC(),
+		RBF:         SignalsRBF(packet.UnsignedTx),
+		PSBT:        base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}
+
+	var outputTotal int64
+	for _, out := range packet.UnsignedTx.TxOut {
+		outputTotal += out.Value
+		address := "nonstandard"
+		if _, addrs, _, err := txscript.ExtractPkScriptAddrs(out.PkScript, net); err == nil && len(addrs) == 1 {
+			address = addrs[0].EncodeAddress()
+		} else if txscript.GetScriptClass(out.PkScript) == txscript.NullDataTy {
+			address = "OP_RETURN"
+		}
+		req.Outputs = append(req.Outputs, SigningOutput{Address: address, Amount: out.Value})
+	}
+
+	complete := true
+	for _, in := range packet.Inputs {
+		if in.WitnessUtxo == nil {
+			complete = false
+			break
+		}
+		req.InputTotal += in.WitnessUtxo.Value
+	}
+	if complete {
+		req.Fee = req.InputTotal - outputTotal
+	} else {
+		req.InputTotal = 0
+	}
+
+	return req, nil
+}
+
+// Packet decodes the request's PSBT
+func (r *SigningRequest) Packet() (*psbt.Packet, error) {
+	raw, err := base64.StdEncoding.DecodeString(r.PSBT)
+	if err != nil {
+		return nil, fmt.Errorf("invalid psbt encoding: %w", err)
+	}
+	return psbt.NewFromRawBytes(bytes.NewReader(raw), false)
+}
+
+// Validate checks the format version and that the PSBT matches the request ID
+func (r *SigningRequest) Validate() error {
+	if r.Version != SigningRequestVersion {
+		return fmt.Errorf("unsupported signing request version %d", r.Version)
+	}
+	packet, err := r.Packet()
+	if err != nil {
+		return err
+	}
+	if packet.UnsignedTx.TxHash().String() != r.ID {
+		return ErrRequestMismatch
+	}
+	return nil
+}
+
+// Finalize checks that signed answers request, finalizes all inputs and
+// returns the raw network transaction as hex
+func (r *SigningRequest) Finalize(signed *SigningRequest) (string, error) {
+	if err := signed.Validate(); err != nil {
+		return "", err
+	}
+	if signed.ID != r.ID {
+		return "", ErrRequestMismatch
+	}
+
+	packet, err := signed.Packet()
+	if err != nil {
+		return "", err
+	}
+	if err := psbt.MaybeFinalizeAll(packet); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrIncompleteSignatures, err)
+	}
+	tx, err := psbt.Extract(packet)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract transaction: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", fmt.Errorf("failed to serialize transaction: %w", err)
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// Sign signs every P2TR input of the request that keys control, by key path,
+// and returns the signed answer for Finalize along with the number of inputs
+// signed. The request itself is not modified.
+func (r *SigningRequest) Sign(keys []*bitcoin.TaprootKey) (*SigningRequest, int, error) {
+	packet, err := r.Packet()
+	if err != nil {
+		return nil, 0, err
+	}
+	signed, err := exspsbt.Sign(packet, keys)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var buf bytes.Buffer
+	if err := packet.Serialize(&buf); err != nil {
+		return nil, 0, fmt.Errorf("failed to serialize psbt: %w", err)
+	}
+	answer := *r
+	answer.PSBT = base64.StdEncoding.EncodeToString(buf.Bytes())
+	return &answer, signed, nil
+}
+
+// Encode returns the JSON encoding of the request
+func (r *SigningRequest) Encode() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// DecodeSigningRequest parses and validates a signing request
+func DecodeSigningRequest(data []byte) (*SigningRequest, error) {
+	var req SigningRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid signing request: %w", err)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// WriteSigningRequest writes a request file with owner-only permissions
+func WriteSigningRequest(path string, req *SigningRequest) error {
+	data, err := req.Encode()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ReadSigningRequest reads and validates a request file
+func ReadSigningRequest(path string) (*SigningRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeSigningRequest(data)
+}
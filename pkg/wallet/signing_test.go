@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/bitcoin"
+)
+
+// testPacket builds a one-input P2WPKH spend and returns it with its key
+func testPacket(t *testing.T) (*psbt.Packet, *btcec.PrivateKey) {
+	priv, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{0x11}, 32))
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(priv.PubKey().SerializeCompressed()), &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressWitnessPubKeyHash() error = %v", err)
+	}
+	pkScript, _ := txscript.PayToAddrScript(addr)
+
+	prevHash := chainhash.DoubleHashH([]byte("prev"))
+	packet, err := psbt.New(
+		[]*wire.OutPoint{wire.NewOutPoint(&prevHash, 0)},
+		[]*wire.TxOut{wire.NewTxOut(90000, pkScript)},
+		2, 0, []uint32{wire.MaxTxInSequenceNum},
+	)
+	if err != nil {
+		t.Fatalf("psbt.New() error = %v", err)
+	}
+	packet.Inputs[0].WitnessUtxo = wire.NewTxOut(100000, pkScript)
+	return packet, priv
+}
+
+func signTestPacket(t *testing.T, packet *psbt.Packet, priv *btcec.PrivateKey) {
+	in := packet.Inputs[0]
+	fetcher := txscript.NewCannedPrevOutputFetcher(in.WitnessUtxo.PkScript, in.WitnessUtxo.Value)
+	hashes := txscript.NewTxSigHashes(packet.UnsignedTx, fetcher)
+	sig, err := txscript.RawTxInWitnessSignature(packet.UnsignedTx, hashes, 0,
+		in.WitnessUtxo.Value, in.WitnessUtxo.PkScript, txscript.SigHashAll, priv)
+	if err != nil {
+		t.Fatalf("RawTxInWitnessSignature() error = %v", err)
+	}
+
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		t.Fatalf("NewUpdater() error = %v", err)
+	}
+	if _, err := updater.Sign(0, sig, priv.PubKey().SerializeCompressed(), nil, nil); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+}
+
+func TestSigningRequestRoundTrip(t *testing.T) {
+	packet, priv := testPacket(t)
+
+	req, err := NewSigningRequest(packet, "cold", "pay merlin", &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewSigningRequest() error = %v", err)
+	}
+	if req.Fee != 10000 {
+		t.Errorf("Expected fee 10000, got %d", req.Fee)
+	}
+	if len(req.Outputs) != 1 || !strings.HasPrefix(req.Outputs[0].Address, "bcrt1q") {
+		t.Errorf("Unexpected outputs: %+v", req.Outputs)
+	}
+
+	path := filepath.Join(t.TempDir(), "request.json")
+	if err := WriteSigningRequest(path, req); err != nil {
+		t.Fatalf("WriteSigningRequest() error = %v", err)
+	}
+	loaded, err := ReadSigningRequest(path)
+	if err != nil {
+		t.Fatalf("ReadSigningRequest() error = %v", err)
+	}
+
+	// Unsigned request must not finalize
+	if _, err := req.Finalize(loaded); !errors.Is(err, ErrIncompleteSignatures) {
+		t.Errorf("Expected ErrIncompleteSignatures, got %v", err)
+	}
+
+	// Offline signer answers with the signed PSBT
+	signedPacket, err := loaded.Packet()
+	if err != nil {
+		t.Fatalf("Packet() error = %v", err)
+	}
+	signTestPacket(t, signedPacket, priv)
+	signed, err := NewSigningRequest(signedPacket, "cold", "pay merlin", &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewSigningRequest() error = %v", err)
+	}
+
+	rawTx, err := req.Finalize(signed)
+	if err != nil {
+		t.Fatalf("Finalize() error = %v", err)
+	}
+	if rawTx == "" {
+		t.Error("Expected raw transaction hex")
+	}
+}
+
+func TestSigningRequestMismatch(t *testing.T) {
+	packet, _ := testPacket(t)
+	req, _ := NewSigningRequest(packet, "cold", "", &chaincfg.RegressionNetParams)
+
+	other, _ := testPacket(t)
+	other.UnsignedTx.TxOut[0].Value = 80000
+	otherReq, _ := NewSigningRequest(other, "cold", "", &chaincfg.RegressionNetParams)
+	if _, err := req.Finalize(otherReq); !errors.Is(err, ErrRequestMismatch) {
+		t.Errorf("Expected ErrRequestMismatch, got %v", err)
+	}
+
+	tampered := *req
+	tampered.ID = otherReq.ID
+	if err := tampered.Validate(); !errors.Is(err, ErrRequestMismatch) {
+		t.Errorf("Expected ErrRequestMismatch for tampered ID, got %v", err)
+	}
+}
+
+func TestChunksRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte("excalibur"), 100)
+
+	frames := SplitChunks(payload, 64)
+	if len(frames) < 2 {
+		t.Fatalf("Expected multiple frames, got %d", len(frames))
+	}
+	for _, frame := range frames {
+		if strings.ToUpper(frame) != frame {
+			t.Errorf("Frame is not QR alphanumeric: %s", frame)
+		}
+	}
+
+	// Reverse order with a duplicate frame
+	shuffled := []string{frames[0]}
+	for i := len(frames) - 1; i >= 0; i-- {
+		shuffled = append(shuffled, frames[i])
+	}
+	got, err := JoinChunks(shuffled)
+	if err != nil {
+		t.Fatalf("JoinChunks() error = %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Error("Payload did not round trip")
+	}
+}
+
+func TestJoinChunksErrors(t *testing.T) {
+	frames := SplitChunks([]byte(strings.Repeat("a", 200)), 32)
+	other := SplitChunks([]byte(strings.Repeat("b", 200)), 32)
+
+	tests := []struct {
+		name   string
+		frames []string
+	}{
+		{"empty", nil},
+		{"missing", frames[1:]},
+		{"mixed payloads", append([]string{other[0]}, frames[1:]...)},
+		{"bad prefix", []string{"BTC:1:1:00000000:AA"}},
+		{"index out of range", []string{"EXS:3:2:00000000:AA"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := JoinChunks(tt.frames); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestSigningRequestSign(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	priv, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{0x22}, 32))
+	key := &bitcoin.TaprootKey{PrivKey: priv}
+	addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(key.OutputKey()), net)
+	if err != nil {
+		t.Fatalf("NewAddressTaproot() error = %v", err)
+	}
+	utxos := []UTXO{
+		{TxID: chainhash.DoubleHashH([]byte("a")).String(), Vout: 0, Value: 60000, Address: addr.EncodeAddress()},
+		{TxID: chainhash.DoubleHashH([]byte("b")).String(), Vout: 1, Value: 50000, Address: addr.EncodeAddress()},
+	}
+	batch, err := BuildBatch(utxos, []Payout{{Address: addr.EncodeAddress(), Amount: 100000}}, addr.EncodeAddress(), 2, net)
+	if err != nil {
+		t.Fatalf("BuildBatch() error = %v", err)
+	}
+	req, err := NewSigningRequest(batch.Packet, "hot", "", net)
+	if err != nil {
+		t.Fatalf("NewSigningRequest() error = %v", err)
+	}
+
+	other, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{0x33}, 32))
+	if _, n, err := req.Sign([]*bitcoin.TaprootKey{{PrivKey: other}}); err != nil || n != 0 {
+		t.Errorf("Sign() with foreign key = %d, %v, expected 0 inputs", n, err)
+	}
+
+	signed, n, err := req.Sign([]*bitcoin.TaprootKey{key})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("Expected 2 signed inputs, got %d", n)
+	}
+	if signed.PSBT == req.PSBT {
+		t.Error("Expected Sign to leave the request unmodified")
+	}
+
+	rawTx, err := req.Finalize(signed)
+	if err != nil {
+		t.Fatalf("Finalize() error = %v", err)
+	}
+	raw, _ := hex.DecodeString(rawTx)
+	tx := wire.NewMsgTx(2)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+	spend := &bitcoin.Spend{Tx: tx}
+	for _, in := range batch.Packet.Inputs {
+		spend.PrevOuts = append(spend.PrevOuts, in.WitnessUtxo)
+	}
+	if err := spend.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/client"
+)
+
+// Statement is an account statement of a wallet, shaped after the Rosetta
+// Data API: Balances and Coins as /account/balance and /account/coins
+// return them at BlockIdentifier, and Transactions as /search/transactions
+// returns them, newest first, with an INPUT operation for every coin the
+// wallet spent and an OUTPUT operation for every coin it received
+type Statement struct {
+	NetworkIdentifier client.NetworkIdentifier  `json:"network_identifier"`
+	BlockIdentifier   client.BlockIdentifier    `json:"block_identifier"`
+	Descriptors       []string                  `json:"descriptors"`
+	GeneratedAt       time.Time                 `json:"generated_at"`
+	Balances          []client.Amount           `json:"balances"`
+	Coins             []client.Coin             `json:"coins"`
+	Transactions      []client.BlockTransaction `json:"transactions"`
+	TotalCount        int64                     `json:"total_count"`
+	// Unverified lists confirmed transactions whose inclusion in their
+	// block was not proven
+	Unverified []string `json:"unverified,omitempty"`
+}
+
+// RosettaNetwork returns the network name Rosetta servers use for net
+func RosettaNetwork(net *chaincfg.Params) string {
+	if net.Net == chaincfg.TestNet3Params.Net {
+		return "testnet"
+	}
+	return net.Name
+}
+
+// NewStatement builds the statement of the descriptors in store from their
+// history and unspent outputs, as of tip. Only confirmed transactions at or
+// below tip are included; the balance is the sum of their effects.
+func NewStatement(store *DescriptorStore, history *History, snapshot *Snapshot, tip client.BlockIdentifier, net *chaincfg.Params) *Statement {
+	s := &Statement{
+		NetworkIdentifier: client.NetworkIdentifier{Blockchain: client.Blockchain, Network: RosettaNetwork(net)},
+		BlockIdentifier:   tip,
+		Descriptors:       []string{},
+		GeneratedAt:       time.Now().UTC(),
+		Coins:             []client.Coin{},
+		Transactions:      []client.BlockTransaction{},
+	}
+	for _, entry := range store.List() {
+		s.Descriptors = append(s.Descriptors, entry.Descriptor)
+	}
+
+	var balance int64
+	for _, entry := range history.Entries {
+		if entry.Height == 0 || int64(entry.Height) > tip.Index {
+			continue
+		}
+		if !entry.Verified {
+			s.Unverified = append(s.Unverified, entry.TxID)
+		}
+		balance += entry.Net()
+
+		var ops []client.Operation
+		for _, in := range entry.Inputs {
+			ops = append(ops, coinOperation(len(ops), client.OpTypeInput, client.CoinSpent, in, -in.Value))
+		}
+		for _, out := range entry.Outputs {
+			ops = append(ops, coinOperation(len(ops), client.OpTypeOutput, client.CoinCreated, out, out.Value))
+		}
+		s.Transactions = append(s.Transactions, client.BlockTransaction{
+			BlockIdentifier: client.BlockIdentifier{Index: int64(entry.Height), Hash: entry.BlockHash},
+			Transaction: client.Transaction{
+				TransactionIdentifier: client.TransactionIdentifier{Hash: entry.TxID},
+				Operations:            ops,
+			},
+		})
+	}
+	s.TotalCount = int64(len(s.Transactions))
+	s.Balances = []client.Amount{*client.NewAmount(balance)}
+
+	for _, utxo := range snapshot.UTXOs {
+		if utxo.Height == 0 || int64(utxo.Height) > tip.Index {
+			continue
+		}
+		s.Coins = append(s.Coins, client.Coin{
+			CoinIdentifier: client.CoinIdentifier{Identifier: fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)},
+			Amount:         *client.NewAmount(utxo.Value),
+			Metadata:       map[string]interface{}{"height": utxo.Height, "address": utxo.Address},
+		})
+	}
+	return s
+}
+
+// coinOperation describes one of the wallet's coins moving in a transaction
+func coinOperation(index int, opType, action string, coin TxIO, value int64) client.Operation {
+	return client.Operation{
+		OperationIdentifier: client.OperationIdentifier{Index: int64(index)},
+		Type:                opType,
+		Status:              "SUCCESS",
+		Account:             &client.AccountIdentifier{Address: coin.Address},
+		Amount:              client.NewAmount(value),
+		CoinChange: &client.CoinChange{
+			CoinIdentifier: client.CoinIdentifier{Identifier: fmt.Sprintf("%s:%d", coin.TxID, coin.Vout)},
+			CoinAction:     action,
+		},
+	}
+}
@@ -0,0 +1,234 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/kv"
+)
+
+// ImportedDescriptor is a descriptor tracked by a wallet
+type ImportedDescriptor struct {
+	Descriptor string    `json:"descriptor"`
+	Label      string    `json:"label,omitempty"`
+	RangeStart uint32    `json:"range_start"`
+	RangeEnd   uint32    `json:"range_end"`
+	NextIndex  []uint32  `json:"next_index"`
+	NeedsScan  bool      `json:"needs_scan"`
+	ImportedAt time.Time `json:"imported_at"`
+	LastScanAt time.Time `json:"last_scan_at,omitempty"`
+}
+
+// descriptorsBucket and descriptorsKey locate the descriptor list in a
+// kv.Store
+const descriptorsBucket = "wallet"
+
+var descriptorsKey = []byte("descriptors")
+
+// DescriptorStore persists imported descriptors as a JSON file, or as a JSON
+// record in a kv.Store
+type DescriptorStore struct {
+	mu          sync.Mutex
+	path        string
+	db          kv.Store // replaces path when set
+	descriptors []ImportedDescriptor
+}
+
+// OpenDescriptorStore loads the store at path, creating an empty one if the
+// file does not exist
+func OpenDescriptorStore(path string) (*DescriptorStore, error) {
+	s := &DescriptorStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.descriptors); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor store: %w", err)
+	}
+	return s, nil
+}
+
+// OpenDescriptorStoreKV loads the store kept in db, which it closes when
+// closed. If db holds no descriptors yet and legacyPath names a JSON store,
+// its descriptors are copied into db.
+func OpenDescriptorStoreKV(db kv.Store, legacyPath string) (*DescriptorStore, error) {
+	s := &DescriptorStore{db: db}
+	data, err := kv.Get(db, descriptorsBucket, descriptorsKey)
+	if errors.Is(err, kv.ErrNotFound) && legacyPath != "" {
+		legacy, err := OpenDescriptorStore(legacyPath)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		if s.descriptors = legacy.descriptors; len(s.descriptors) > 0 {
+			if err := s.save(); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+	if errors.Is(err, kv.ErrNotFound) {
+		return s, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &s.descriptors)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read descriptor store: %w", err)
+	}
+	return s, nil
+}
+
+// Close releases the store's kv.Store, if any
+func (s *DescriptorStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Import adds a descriptor, replacing any existing entry for the same
+// descriptor. With rescan it is marked NeedsScan, which the next Sync or
+// background rescan clears once it has scanned the descriptor's history.
+func (s *DescriptorStore) Import(d *Descriptor, label string, rangeStart, rangeEnd uint32, rescan bool) (*ImportedDescriptor, error) {
+	if d.Ranged && rangeEnd <= rangeStart {
+		return nil, fmt.Errorf("invalid range [%d, %d)", rangeStart, rangeEnd)
+	}
+
+	entry := ImportedDescriptor{
+		Descriptor: d.String(),
+		Label:      label,
+		RangeStart: rangeStart,
+		RangeEnd:   rangeEnd,
+		NextIndex:  make([]uint32, d.Branches()),
+		NeedsScan:  rescan,
+		ImportedAt: time.Now().UTC(),
+	}
+	for i := range entry.NextIndex {
+		entry.NextIndex[i] = rangeStart
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	replaced := false
+	for i := range s.descriptors {
+		if s.descriptors[i].Descriptor == entry.Descriptor {
+			s.descriptors[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		s.descriptors = append(s.descriptors, entry)
+	}
+
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// List returns all imported descriptors
+func (s *DescriptorStore) List() []ImportedDescriptor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]ImportedDescriptor, len(s.descriptors))
+	copy(list, s.descriptors)
+	return list
+}
+
+// RecordScan stores the outcome of a rescan and clears the pending flag
+func (s *DescriptorStore) RecordScan(result *ScanResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.descriptors {
+		if s.descriptors[i].Descriptor == result.Descriptor {
+			s.descriptors[i].NextIndex = result.NextIndex
+			s.descriptors[i].NeedsScan = false
+			s.descriptors[i].LastScanAt = time.Now().UTC()
+			return s.save()
+		}
+	}
+	return fmt.Errorf("descriptor not imported: %s", result.Descriptor)
+}
+
+// Reserve hands out the next unused index of a descriptor branch and
+// advances past it, so the same address is not given out twice
+func (s *DescriptorStore) Reserve(descriptor string, branch int) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.descriptors {
+		entry := &s.descriptors[i]
+		if entry.Descriptor != descriptor {
+			continue
+		}
+		if branch < 0 || branch >= len(entry.NextIndex) {
+			return 0, fmt.Errorf("branch %d out of range (descriptor has %d)", branch, len(entry.NextIndex))
+		}
+		index := entry.NextIndex[branch]
+		entry.NextIndex[branch]++
+		if err := s.save(); err != nil {
+			entry.NextIndex[branch] = index
+			return 0, err
+		}
+		return index, nil
+	}
+	return 0, fmt.Errorf("descriptor not imported: %s", descriptor)
+}
+
+// Remove stops tracking a descriptor and reports whether it was imported
+func (s *DescriptorStore) Remove(descriptor string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.descriptors {
+		if s.descriptors[i].Descriptor == descriptor {
+			s.descriptors = append(s.descriptors[:i], s.descriptors[i+1:]...)
+			return true, s.save()
+		}
+	}
+	return false, nil
+}
+
+// save atomically writes the store to disk (write to temp file, then
+// rename), or to its kv.Store in one transaction
+func (s *DescriptorStore) save() error {
+	data, err := json.MarshalIndent(s.descriptors, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode descriptor store: %w", err)
+	}
+	if s.db != nil {
+		if err := kv.Put(s.db, descriptorsBucket, descriptorsKey, data); err != nil {
+			return fmt.Errorf("failed to write descriptor store: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create wallet directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write descriptor store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace descriptor store: %w", err)
+	}
+	return nil
+}
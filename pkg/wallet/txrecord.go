@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TxRecord is a finalized wallet transaction kept for later fee bumping
+type TxRecord struct {
+	TxID        string    `json:"txid"`
+	Raw         string    `json:"raw"`
+	Fee         int64     `json:"fee"`
+	ChangeIndex *uint32   `json:"change_index,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewTxRecord creates a record from a raw transaction in hex
+func NewTxRecord(rawHex string, fee int64, changeIndex *uint32) (*TxRecord, error) {
+	rec := &TxRecord{Raw: rawHex, Fee: fee, ChangeIndex: changeIndex, CreatedAt: time.Now().UTC()}
+	tx, err := rec.Tx()
+	if err != nil {
+		return nil, err
+	}
+	rec.TxID = tx.TxHash().String()
+	return rec, nil
+}
+
+// Tx decodes the recorded transaction
+func (r *TxRecord) Tx() (*wire.MsgTx, error) {
+	raw, err := hex.DecodeString(r.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction hex: %w", err)
+	}
+	tx := wire.NewMsgTx(2)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// SaveTxRecord writes rec to dir/<txid>.json
+func SaveTxRecord(dir string, rec *TxRecord) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create transaction directory: %w", err)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, rec.TxID+".json"), data, 0600)
+}
+
+// LoadTxRecord reads the record of txid from dir
+func LoadTxRecord(dir, txid string) (*TxRecord, error) {
+	data, err := os.ReadFile(filepath.Join(dir, txid+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var rec TxRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("invalid transaction record: %w", err)
+	}
+	return &rec, nil
+}
+
+// ListTxRecords returns every record in dir, newest first. A missing
+// directory holds no records.
+func ListTxRecords(dir string) ([]*TxRecord, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []*TxRecord
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		rec, err := LoadTxRecord(dir, strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		records = append(records, rec)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	return records, nil
+}
@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// URIScheme is the scheme of EXS payment request URIs
+const URIScheme = "exs"
+
+// ErrInvalidURI indicates a malformed payment request URI
+var ErrInvalidURI = errors.New("invalid payment URI")
+
+// PaymentRequest is a BIP-21 style request to pay an address, encoded as
+// exs:<address>?amount=<EXS>&label=<label>&message=<message>
+type PaymentRequest struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount,omitempty"` // satoshis, zero if unspecified
+	Label   string `json:"label,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// IsPaymentURI reports whether s uses the exs: scheme
+func IsPaymentURI(s string) bool {
+	return len(s) > len(URIScheme) && strings.EqualFold(s[:len(URIScheme)+1], URIScheme+":")
+}
+
+// ParsePaymentURI decodes an exs: URI and checks its address belongs to net.
+// As in BIP-21, unknown parameters are ignored unless prefixed with "req-".
+func ParsePaymentURI(uri string, net *chaincfg.Params) (*PaymentRequest, error) {
+	if !IsPaymentURI(uri) {
+		return nil, fmt.Errorf("%w: expected %s: scheme", ErrInvalidURI, URIScheme)
+	}
+	address, query, _ := strings.Cut(uri[len(URIScheme)+1:], "?")
+	if address == "" {
+		return nil, fmt.Errorf("%w: missing address", ErrInvalidURI)
+	}
+	if _, err := addressScript(address, net); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURI, err)
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURI, err)
+	}
+
+	req := &PaymentRequest{Address: address}
+	for key, values := range params {
+		if len(values) != 1 {
+			return nil, fmt.Errorf("%w: parameter %s given %d times", ErrInvalidURI, key, len(values))
+		}
+		switch value := values[0]; key {
+		case "amount":
+			if req.Amount, err = ParseAmount(value); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidURI, err)
+			}
+		case "label":
+			req.Label = value
+		case "message":
+			req.Message = value
+		default:
+			if strings.HasPrefix(key, "req-") {
+				return nil, fmt.Errorf("%w: unsupported required parameter %s", ErrInvalidURI, key)
+			}
+		}
+	}
+	return req, nil
+}
+
+// URI encodes the request as an exs: URI
+func (r *PaymentRequest) URI() string {
+	var params []string
+	if r.Amount > 0 {
+		params = append(params, "amount="+FormatAmount(r.Amount))
+	}
+	if r.Label != "" {
+		params = append(params, "label="+escapeURIValue(r.Label))
+	}
+	if r.Message != "" {
+		params = append(params, "message="+escapeURIValue(r.Message))
+	}
+
+	uri := URIScheme + ":" + r.Address
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+// FormatAmount formats satoshis as EXS without trailing zeros
+func FormatAmount(sats int64) string {
+	s := fmt.Sprintf("%d.%08d", sats/btcutil.SatoshiPerBitcoin, sats%btcutil.SatoshiPerBitcoin)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// escapeURIValue percent-encodes a parameter value, using %20 rather than +
+// for spaces since BIP-21 wallets disagree on the latter
+func escapeURIValue(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
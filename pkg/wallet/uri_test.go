@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestPaymentURIRoundTrip(t *testing.T) {
+	req := &PaymentRequest{
+		Address: testTaprootAddr,
+		Amount:  150000000,
+		Label:   "Sir Lancelot",
+		Message: "Forge fee & tithe",
+	}
+	uri := req.URI()
+	expected := "exs:" + testTaprootAddr + "?amount=1.5&label=Sir%20Lancelot&message=Forge%20fee%20%26%20tithe"
+	if uri != expected {
+		t.Fatalf("URI() = %s, expected %s", uri, expected)
+	}
+
+	parsed, err := ParsePaymentURI(uri, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("ParsePaymentURI() error = %v", err)
+	}
+	if *parsed != *req {
+		t.Errorf("Round trip mismatch: got %+v, expected %+v", parsed, req)
+	}
+
+	bare := &PaymentRequest{Address: testWPKHAddr}
+	if bare.URI() != "exs:"+testWPKHAddr {
+		t.Errorf("Expected no query for a bare address, got %s", bare.URI())
+	}
+}
+
+func TestParsePaymentURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		amount  int64
+		wantErr bool
+	}{
+		{"upper case scheme", "EXS:" + testTaprootAddr, 0, false},
+		{"plus as space", "exs:" + testTaprootAddr + "?amount=0.00001&label=a+b", 1000, false},
+		{"unknown parameter", "exs:" + testTaprootAddr + "?amount=2&foo=bar", 200000000, false},
+		{"required parameter", "exs:" + testTaprootAddr + "?req-somethingyoudontunderstand=50", 0, true},
+		{"bad amount", "exs:" + testTaprootAddr + "?amount=1.123456789", 0, true},
+		{"repeated amount", "exs:" + testTaprootAddr + "?amount=1&amount=2", 0, true},
+		{"wrong scheme", "bitcoin:" + testTaprootAddr, 0, true},
+		{"missing address", "exs:?amount=1", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParsePaymentURI(tt.uri, &chaincfg.MainNetParams)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidURI) {
+					t.Errorf("Expected ErrInvalidURI, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePaymentURI() error = %v", err)
+			}
+			if req.Amount != tt.amount {
+				t.Errorf("Amount = %d, expected %d", req.Amount, tt.amount)
+			}
+		})
+	}
+
+	if _, err := ParsePaymentURI("exs:"+testTaprootAddr, &chaincfg.RegressionNetParams); !errors.Is(err, ErrInvalidURI) {
+		t.Errorf("Expected a mainnet address to be rejected on regtest, got %v", err)
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := map[int64]string{
+		0:                "0",
+		1:                "0.00000001",
+		50000000:         "0.5",
+		100000000:        "1",
+		2100000000000000: "21000000",
+	}
+	for sats, expected := range tests {
+		if got := FormatAmount(sats); got != expected {
+			t.Errorf("FormatAmount(%d) = %s, expected %s", sats, got, expected)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+var (
+	// ErrVanityExhausted indicates a vanity search that tried every
+	// unhardened index without a match
+	ErrVanityExhausted = errors.New("no match in the unhardened index range")
+	// ErrCheckpointMismatch indicates a checkpoint written by a search for
+	// another wallet, network, branch or prefix
+	ErrCheckpointMismatch = errors.New("checkpoint belongs to a different search")
+)
+
+// vanityChunk is the number of consecutive indexes a worker takes at a time
+const vanityChunk = 256
+
+// VanityMatch is a wallet address with the requested prefix
+type VanityMatch struct {
+	Branch  int
+	Index   uint32
+	Address string
+}
+
+// VanityProgress reports a running vanity search
+type VanityProgress struct {
+	// Next is the index below which every index has been tried, where an
+	// interrupted search resumes
+	Next uint32
+	// Tried counts the indexes tried by this run
+	Tried   uint64
+	Elapsed time.Duration
+}
+
+// VanitySearch grinds the indexes of an HD wallet branch for a Taproot
+// address starting with a prefix. The keys are derived, not random: the
+// seed recovers the address from its branch and index, and the search
+// returns the lowest matching index whatever the number of workers.
+type VanitySearch struct {
+	Wallet *HDWallet
+	Branch int
+	// Prefix is the wanted start of the address, with or without its
+	// "bc1p" (or test network) lead
+	Prefix string
+	// Start is the first index tried
+	Start uint32
+	// Workers is the number of goroutines deriving addresses, GOMAXPROCS
+	// if zero
+	Workers int
+	// Progress, if set, is called every ProgressInterval and once more when
+	// the search stops
+	Progress         func(VanityProgress)
+	ProgressInterval time.Duration
+}
+
+// VanityPrefix normalizes a vanity prefix to the full address prefix on
+// net, rejecting characters Bech32m addresses cannot contain
+func VanityPrefix(prefix string, net *chaincfg.Params) (string, error) {
+	lead := net.Bech32HRPSegwit + "1p"
+	chars := strings.TrimPrefix(strings.ToLower(prefix), lead)
+	if chars == "" {
+		return "", errors.New("vanity prefix is empty")
+	}
+	for _, r := range chars {
+		if !strings.ContainsRune(descChecksumCharset, r) {
+			return "", fmt.Errorf("vanity prefix %q: %q is not a Bech32 character (%s)", prefix, r, descChecksumCharset)
+		}
+	}
+	return lead + chars, nil
+}
+
+// VanityTries returns the expected number of indexes tried to find an
+// address with prefix, as normalized by VanityPrefix
+func VanityTries(prefix string, net *chaincfg.Params) float64 {
+	n := len(prefix) - len(net.Bech32HRPSegwit+"1p")
+	tries := 1.0
+	for i := 0; i < n; i++ {
+		tries *= float64(len(descChecksumCharset))
+	}
+	return tries
+}
+
+// Fingerprint returns the first four bytes of the master public key hash,
+// identifying the wallet without revealing it
+func (w *HDWallet) Fingerprint() uint32 {
+	return w.fingerprint
+}
+
+// Run searches until it finds a match, ctx is cancelled or the unhardened
+// index range is exhausted. A match found before cancellation is returned.
+func (s *VanitySearch) Run(ctx context.Context) (*VanityMatch, error) {
+	if s.Branch != ReceiveBranch && s.Branch != ChangeBranch {
+		return nil, fmt.Errorf("branch %d out of range", s.Branch)
+	}
+	if s.Start >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("index %d exceeds unhardened range", s.Start)
+	}
+	prefix, err := VanityPrefix(s.Prefix, s.Wallet.net)
+	if err != nil {
+		return nil, err
+	}
+	branchKey, err := s.Wallet.account.Derive(uint32(s.Branch))
+	if err == nil {
+		branchKey, err = branchKey.Neuter()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive branch %d: %w", s.Branch, err)
+	}
+
+	type chunkResult struct {
+		start uint64
+		match *VanityMatch
+		err   error
+	}
+	chunks := make(chan uint64)
+	results := make(chan chunkResult)
+	var tried atomic.Uint64
+	var wg sync.WaitGroup
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				end := min(start+vanityChunk, hdkeychain.HardenedKeyStart)
+				match, err := s.searchChunk(branchKey, prefix, uint32(start), uint32(end))
+				tried.Add(end - start)
+				results <- chunkResult{start: start, match: match, err: err}
+			}
+		}()
+	}
+
+	interval := s.ProgressInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	began := time.Now()
+	next, watermark := uint64(s.Start), uint64(s.Start)
+	completed := make(map[uint64]bool)
+	inFlight := 0
+	stopping := false
+	var best *VanityMatch
+	var searchErr error
+	cancelled := ctx.Done()
+	progress := func() {
+		if s.Progress != nil {
+			s.Progress(VanityProgress{Next: uint32(watermark), Tried: tried.Load(), Elapsed: time.Since(began)})
+		}
+	}
+
+	for {
+		send := chunks
+		if stopping || next >= hdkeychain.HardenedKeyStart {
+			send = nil
+		}
+		if send == nil && inFlight == 0 {
+			break
+		}
+		select {
+		case send <- next:
+			next += vanityChunk
+			inFlight++
+		case r := <-results:
+			inFlight--
+			completed[r.start] = true
+			for completed[watermark] {
+				delete(completed, watermark)
+				watermark = min(watermark+vanityChunk, hdkeychain.HardenedKeyStart)
+			}
+			if r.err != nil && searchErr == nil {
+				searchErr, stopping = r.err, true
+			}
+			// Chunks below a match may still hold a lower one
+			if r.match != nil && (best == nil || r.match.Index < best.Index) {
+				best, stopping = r.match, true
+			}
+		case <-ticker.C:
+			progress()
+		case <-cancelled:
+			cancelled, stopping = nil, true
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	progress()
+
+	switch {
+	case best != nil:
+		return best, nil
+	case searchErr != nil:
+		return nil, searchErr
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	default:
+		return nil, ErrVanityExhausted
+	}
+}
+
+// searchChunk returns the first address in [start, end) of the branch that
+// starts with prefix
+func (s *VanitySearch) searchChunk(branchKey *hdkeychain.ExtendedKey, prefix string, start, end uint32) (*VanityMatch, error) {
+	for index := start; index < end; index++ {
+		child, err := branchKey.Derive(index)
+		if errors.Is(err, hdkeychain.ErrInvalidChild) {
+			// BIP-32 skips the rare index without a valid key
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive child %d: %w", index, err)
+		}
+		pubKey, err := child.ECPubKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract public key: %w", err)
+		}
+		outputKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+		addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), s.Wallet.net)
+		if err != nil {
+			return nil, err
+		}
+		if address := addr.EncodeAddress(); strings.HasPrefix(address, prefix) {
+			return &VanityMatch{Branch: s.Branch, Index: index, Address: address}, nil
+		}
+	}
+	return nil, nil
+}
+
+// VanityCheckpoint records how far a vanity search got so that an
+// interrupted search resumes where it stopped. It holds no key material.
+type VanityCheckpoint struct {
+	Fingerprint string    `json:"fingerprint"`
+	Network     string    `json:"network"`
+	Branch      int       `json:"branch"`
+	Prefix      string    `json:"prefix"`
+	Next        uint32    `json:"next"`
+	Tried       uint64    `json:"tried"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Checkpoint returns the checkpoint of the search at p. tried counts the
+// indexes of earlier runs.
+func (s *VanitySearch) Checkpoint(p VanityProgress, tried uint64) *VanityCheckpoint {
+	prefix, _ := VanityPrefix(s.Prefix, s.Wallet.net)
+	return &VanityCheckpoint{
+		Fingerprint: fmt.Sprintf("%08x", s.Wallet.fingerprint),
+		Network:     s.Wallet.net.Name,
+		Branch:      s.Branch,
+		Prefix:      prefix,
+		Next:        p.Next,
+		Tried:       tried + p.Tried,
+		UpdatedAt:   time.Now().UTC(),
+	}
+}
+
+// Resume sets the search to start from the checkpoint c, which must have
+// been written by the same search
+func (s *VanitySearch) Resume(c *VanityCheckpoint) error {
+	want := s.Checkpoint(VanityProgress{}, 0)
+	if c.Fingerprint != want.Fingerprint || c.Network != want.Network || c.Branch != want.Branch || c.Prefix != want.Prefix {
+		return fmt.Errorf("%w: %s %s branch %d prefix %s", ErrCheckpointMismatch, c.Fingerprint, c.Network, c.Branch, c.Prefix)
+	}
+	s.Start = c.Next
+	return nil
+}
+
+// LoadVanityCheckpoint reads a checkpoint written by Save
+func LoadVanityCheckpoint(path string) (*VanityCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c VanityCheckpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid vanity checkpoint %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save atomically writes the checkpoint to path
+func (c *VanityCheckpoint) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode vanity checkpoint: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vanity checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace vanity checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestVanityPrefix(t *testing.T) {
+	tests := []struct {
+		prefix, want string
+	}{
+		{"Q7", "bc1pq7"},
+		{"bc1pexs", "bc1pexs"},
+	}
+	for _, tt := range tests {
+		if got, err := VanityPrefix(tt.prefix, &chaincfg.MainNetParams); err != nil || got != tt.want {
+			t.Errorf("VanityPrefix(%q) = %q, %v, want %q", tt.prefix, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "bc1p", "io", "b1"} {
+		if _, err := VanityPrefix(bad, &chaincfg.MainNetParams); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+	if got := VanityTries("bc1pq7", &chaincfg.MainNetParams); got != 1024 {
+		t.Errorf("VanityTries() = %v, want 1024", got)
+	}
+}
+
+func TestVanitySearch(t *testing.T) {
+	hd, err := NewHDWallet(bip86Words, "", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := hd.Descriptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var matches []*VanityMatch
+	for _, workers := range []int{1, 4} {
+		search := &VanitySearch{Wallet: hd, Branch: ReceiveBranch, Prefix: "q", Workers: workers}
+		match, err := search.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run() with %d workers error = %v", workers, err)
+		}
+		matches = append(matches, match)
+	}
+	if *matches[0] != *matches[1] {
+		t.Fatalf("Expected the lowest match whatever the workers, got %+v and %+v", matches[0], matches[1])
+	}
+
+	// The match is the wallet's own address at that index
+	match := matches[0]
+	addr, err := desc.Derive(ReceiveBranch, match.Index, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Address != match.Address || !strings.HasPrefix(match.Address, "bc1pq") {
+		t.Errorf("Match %+v does not match derived address %s", match, addr.Address)
+	}
+	for i := uint32(0); i < match.Index; i++ {
+		if addr, _ := desc.Derive(ReceiveBranch, i, &chaincfg.MainNetParams); strings.HasPrefix(addr.Address, "bc1pq") {
+			t.Fatalf("Index %d matches before %d", i, match.Index)
+		}
+	}
+}
+
+func TestVanityCheckpointResume(t *testing.T) {
+	hd, err := NewHDWallet(bip86Words, "", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var last VanityProgress
+	search := &VanitySearch{
+		Wallet:  hd,
+		Branch:  ChangeBranch,
+		Prefix:  "qqqqqqqq",
+		Workers: 2,
+		Progress: func(p VanityProgress) {
+			last = p
+			if p.Tried >= 2*vanityChunk {
+				cancel()
+			}
+		},
+		ProgressInterval: 10 * time.Millisecond,
+	}
+	if _, err := search.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the search to be cancelled, got %v", err)
+	}
+	if last.Next == 0 || uint64(last.Next) > last.Tried {
+		t.Fatalf("Unexpected final progress %+v", last)
+	}
+
+	path := filepath.Join(t.TempDir(), "vanity", "checkpoint.json")
+	if err := search.Checkpoint(last, 100).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadVanityCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Tried != last.Tried+100 || loaded.Prefix != "bc1pqqqqqqqq" {
+		t.Errorf("Unexpected checkpoint %+v", loaded)
+	}
+
+	resumed := &VanitySearch{Wallet: hd, Branch: ChangeBranch, Prefix: "bc1pqqqqqqqq"}
+	if err := resumed.Resume(loaded); err != nil || resumed.Start != last.Next {
+		t.Errorf("Resume() = %v, start %d, want %d", err, resumed.Start, last.Next)
+	}
+	other := &VanitySearch{Wallet: hd, Branch: ChangeBranch, Prefix: "qqqqqqqp"}
+	if err := other.Resume(loaded); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Expected ErrCheckpointMismatch, got %v", err)
+	}
+}
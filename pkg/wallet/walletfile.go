@@ -0,0 +1,371 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+// WalletFileVersion is the current wallet file format version
+const WalletFileVersion = 2
+
+// walletKDF names the key derivation used to encrypt wallet files
+const walletKDF = "hpp1-pbkdf2-sha256"
+
+const (
+	// walletSlots is the number of sealed slots in every wallet file: the
+	// visible wallet and a hidden one, or random bytes of the same size
+	walletSlots = 2
+	// walletSecretSize is the padded plaintext size of every slot, so
+	// slots reveal nothing about what they hold
+	walletSecretSize = 1024
+)
+
+var (
+	// ErrWalletExists indicates a wallet file is already present
+	ErrWalletExists = errors.New("wallet already exists")
+	// ErrWrongPassphrase indicates a wallet file could not be decrypted
+	ErrWrongPassphrase = errors.New("wrong wallet passphrase")
+	// ErrHiddenWallet indicates a change the hidden wallet cannot make,
+	// such as hiding itself behind another decoy
+	ErrHiddenWallet = errors.New("passphrase opens the hidden wallet")
+)
+
+// WalletFile is an HD wallet at rest. The prophecy phrase is sealed with
+// AES-256-GCM under a key stretched from the passphrase with HPP-1; the
+// account descriptor is kept in the clear so addresses and balances can be
+// tracked without unlocking. Seed names how the phrase seeds the keys of
+// both slots; files without one use BIP-39.
+//
+// Every file has two equally sized slots. The first holds the visible
+// wallet, whose descriptor is in the clear. The second holds random bytes,
+// or a hidden wallet behind a decoy: its own passphrase opens it and its
+// descriptor is sealed with its phrase, so the file looks the same either
+// way.
+type WalletFile struct {
+	Version    int          `json:"version"`
+	Name       string       `json:"name"`
+	Network    string       `json:"network"`
+	Descriptor string       `json:"descriptor"`
+	Seed       SeedScheme   `json:"seed,omitempty"`
+	KDF        string       `json:"kdf"`
+	KDFRounds  int          `json:"kdf_rounds"`
+	Slots      []walletSlot `json:"slots"`
+	CreatedAt  time.Time    `json:"created_at"`
+
+	// Version 1 files have a single slot in these fields
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+
+	path string
+}
+
+// walletSlot is one sealed wallet secret or its random stand-in
+type walletSlot struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// walletSecret is the sealed content of a wallet slot
+type walletSecret struct {
+	Words      []string `json:"words"`
+	Descriptor string   `json:"descriptor,omitempty"`
+}
+
+// Account is a wallet opened from its file with one of its passphrases
+type Account struct {
+	Words      []string
+	Descriptor string
+	// Hidden is true for the wallet behind a decoy
+	Hidden bool
+}
+
+// CreateWalletFile encrypts words with passphrase and writes a new wallet
+// file at path, refusing to overwrite an existing one. The keys are seeded
+// from words by scheme.
+func CreateWalletFile(path, name string, words []string, scheme SeedScheme, passphrase string, net *chaincfg.Params) (*WalletFile, *HDWallet, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrWalletExists, name)
+	}
+
+	hd, desc, err := walletDescriptor(words, scheme, net)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &WalletFile{
+		Version:    WalletFileVersion,
+		Name:       name,
+		Network:    net.Name,
+		Descriptor: desc,
+		Seed:       scheme,
+		KDF:        walletKDF,
+		KDFRounds:  crypto.HPP1Rounds,
+		CreatedAt:  time.Now().UTC(),
+		path:       path,
+	}
+	visible, err := f.seal(walletSecret{Words: words}, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	hidden, err := randomSlot()
+	if err != nil {
+		return nil, nil, err
+	}
+	f.Slots = []walletSlot{*visible, *hidden}
+
+	if err := f.save(); err != nil {
+		return nil, nil, err
+	}
+	return f, hd, nil
+}
+
+// OpenWalletFile reads a wallet file without decrypting it
+func OpenWalletFile(path string) (*WalletFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f WalletFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid wallet file: %w", err)
+	}
+	switch f.Version {
+	case 1:
+		f.Slots = []walletSlot{{Salt: f.Salt, Nonce: f.Nonce, Ciphertext: f.Ciphertext}}
+		f.Salt, f.Nonce, f.Ciphertext = nil, nil, nil
+	case WalletFileVersion:
+		if len(f.Slots) != walletSlots {
+			return nil, fmt.Errorf("invalid wallet file: %d slots", len(f.Slots))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported wallet file version %d", f.Version)
+	}
+	if f.KDF != walletKDF {
+		return nil, fmt.Errorf("unsupported wallet key derivation %q", f.KDF)
+	}
+	f.path = path
+	return &f, nil
+}
+
+// Open decrypts the slot passphrase opens. Every slot is tried, so a
+// wrong passphrase takes as long whether or not a wallet is hidden.
+func (f *WalletFile) Open(passphrase string) (*Account, error) {
+	var account *Account
+	for i, slot := range f.Slots {
+		secret, err := f.open(slot, passphrase)
+		if err != nil {
+			if errors.Is(err, ErrWrongPassphrase) {
+				continue
+			}
+			return nil, err
+		}
+		if account == nil {
+			account = &Account{Words: secret.Words, Descriptor: f.Descriptor, Hidden: i > 0}
+			if account.Hidden {
+				account.Descriptor = secret.Descriptor
+			}
+		}
+	}
+	if account == nil {
+		return nil, ErrWrongPassphrase
+	}
+	return account, nil
+}
+
+// Words decrypts the prophecy phrase of the wallet passphrase opens
+func (f *WalletFile) Words(passphrase string) ([]string, error) {
+	account, err := f.Open(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return account.Words, nil
+}
+
+// Unlock decrypts the wallet passphrase opens and derives its keys
+func (f *WalletFile) Unlock(passphrase string, net *chaincfg.Params) (*HDWallet, error) {
+	if net.Name != f.Network {
+		return nil, fmt.Errorf("wallet %s is for %s, not %s", f.Name, f.Network, net.Name)
+	}
+	words, err := f.Words(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := Seed(words, "", f.Seed)
+	if err != nil {
+		return nil, err
+	}
+	return NewHDWalletFromSeed(seed, net)
+}
+
+// HideBehindDecoy moves the visible wallet passphrase opens into the hidden
+// slot and makes decoyWords, opened by decoyPassphrase, the visible wallet.
+// Any wallet already in the hidden slot is overwritten: the file cannot
+// tell whether one is there. The decoy's HD wallet is returned so its
+// descriptor can replace the hidden wallet's wherever it was tracked.
+func (f *WalletFile) HideBehindDecoy(passphrase string, decoyWords []string, decoyPassphrase string, net *chaincfg.Params) (*HDWallet, error) {
+	if net.Name != f.Network {
+		return nil, fmt.Errorf("wallet %s is for %s, not %s", f.Name, f.Network, net.Name)
+	}
+	if passphrase == decoyPassphrase {
+		return nil, fmt.Errorf("the decoy passphrase must differ from the wallet passphrase")
+	}
+	account, err := f.Open(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if account.Hidden {
+		return nil, ErrHiddenWallet
+	}
+	decoy, decoyDesc, err := walletDescriptor(decoyWords, f.Seed, net)
+	if err != nil {
+		return nil, err
+	}
+
+	// The descriptor is bound into every slot, so both are sealed anew
+	f.Version = WalletFileVersion
+	f.Descriptor = decoyDesc
+	visible, err := f.seal(walletSecret{Words: decoyWords}, decoyPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := f.seal(walletSecret{Words: account.Words, Descriptor: account.Descriptor}, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	f.Slots = []walletSlot{*visible, *hidden}
+	if err := f.save(); err != nil {
+		return nil, err
+	}
+	return decoy, nil
+}
+
+// walletDescriptor derives the HD wallet of words and its account
+// descriptor
+func walletDescriptor(words []string, scheme SeedScheme, net *chaincfg.Params) (*HDWallet, string, error) {
+	seed, err := Seed(words, "", scheme)
+	if err != nil {
+		return nil, "", err
+	}
+	hd, err := NewHDWalletFromSeed(seed, net)
+	if err != nil {
+		return nil, "", err
+	}
+	desc, err := hd.Descriptor()
+	if err != nil {
+		return nil, "", err
+	}
+	return hd, desc.String(), nil
+}
+
+// seal encrypts secret, padded to walletSecretSize, under passphrase
+func (f *WalletFile) seal(secret walletSecret, passphrase string) (*walletSlot, error) {
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode wallet secret: %w", err)
+	}
+	if len(plaintext) > walletSecretSize {
+		return nil, fmt.Errorf("wallet secret of %d bytes exceeds %d", len(plaintext), walletSecretSize)
+	}
+	// JSON ignores the trailing whitespace
+	plaintext = append(plaintext, bytes.Repeat([]byte(" "), walletSecretSize-len(plaintext))...)
+
+	slot := &walletSlot{Salt: make([]byte, 16), Nonce: make([]byte, 12)}
+	if _, err := rand.Read(slot.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(slot.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	aead, err := f.cipher(passphrase, slot.Salt)
+	if err != nil {
+		return nil, err
+	}
+	slot.Ciphertext = aead.Seal(nil, slot.Nonce, plaintext, f.additionalData())
+	return slot, nil
+}
+
+// randomSlot returns random bytes shaped like a sealed slot
+func randomSlot() (*walletSlot, error) {
+	slot := &walletSlot{
+		Salt:       make([]byte, 16),
+		Nonce:      make([]byte, 12),
+		Ciphertext: make([]byte, walletSecretSize+16),
+	}
+	for _, b := range [][]byte{slot.Salt, slot.Nonce, slot.Ciphertext} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate slot: %w", err)
+		}
+	}
+	return slot, nil
+}
+
+// open decrypts one slot
+func (f *WalletFile) open(slot walletSlot, passphrase string) (*walletSecret, error) {
+	aead, err := f.cipher(passphrase, slot.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, slot.Nonce, slot.Ciphertext, f.additionalData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	var secret walletSecret
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		return nil, fmt.Errorf("invalid wallet secret: %w", err)
+	}
+	return &secret, nil
+}
+
+// cipher derives the AES-256-GCM cipher for passphrase and a slot's salt
+func (f *WalletFile) cipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	if f.KDFRounds != crypto.HPP1Rounds {
+		return nil, fmt.Errorf("unsupported kdf rounds %d", f.KDFRounds)
+	}
+	block, err := aes.NewCipher(crypto.HPP1([]byte(passphrase), salt, 32))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the ciphertext to the wallet's public fields
+func (f *WalletFile) additionalData() []byte {
+	data := f.Name + "\x00" + f.Network + "\x00" + f.Descriptor
+	// Bound only when set, so files from before seed schemes still open
+	if f.Seed != "" {
+		data += "\x00" + string(f.Seed)
+	}
+	return []byte(data)
+}
+
+// save writes the wallet file with owner-only permissions
+func (f *WalletFile) save() error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode wallet file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("failed to create wallet directory: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write wallet file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to replace wallet file: %w", err)
+	}
+	return nil
+}
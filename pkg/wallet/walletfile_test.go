@@ -0,0 +1,207 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/crypto"
+)
+
+func TestWalletFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets", "cold", "wallet.json")
+	net := &chaincfg.RegressionNetParams
+
+	f, hd, err := CreateWalletFile(path, "cold", bip86Words, SeedBIP39, "excalibur", net)
+	if err != nil {
+		t.Fatalf("CreateWalletFile() error = %v", err)
+	}
+	desc, _ := hd.Descriptor()
+	if f.Descriptor != desc.String() {
+		t.Errorf("Stored descriptor %s does not match wallet", f.Descriptor)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected an owner-only wallet file, got %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "abandon") {
+		t.Error("Wallet file contains the phrase in the clear")
+	}
+	if _, _, err := CreateWalletFile(path, "cold", bip86Words, SeedBIP39, "", net); !errors.Is(err, ErrWalletExists) {
+		t.Errorf("Expected ErrWalletExists, got %v", err)
+	}
+
+	loaded, err := OpenWalletFile(path)
+	if err != nil {
+		t.Fatalf("OpenWalletFile() error = %v", err)
+	}
+	words, err := loaded.Words("excalibur")
+	if err != nil {
+		t.Fatalf("Words() error = %v", err)
+	}
+	if strings.Join(words, " ") != strings.Join(bip86Words, " ") {
+		t.Errorf("Decrypted phrase %v does not match", words)
+	}
+	if _, err := loaded.Words("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := loaded.Unlock("excalibur", &chaincfg.MainNetParams); err == nil {
+		t.Error("Expected unlocking on another network to fail")
+	}
+
+	// The ciphertext is bound to the public fields
+	loaded.Descriptor = f.Descriptor + "x"
+	if _, err := loaded.Words("excalibur"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected a tampered descriptor to fail decryption, got %v", err)
+	}
+}
+
+func TestWalletFileForgeSeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets", "forged", "wallet.json")
+	net := &chaincfg.RegressionNetParams
+	prophecy := append(append([]string{}, bip86Words...), "inner")
+
+	if _, _, err := CreateWalletFile(path, "forged", bip86Words, SeedForge, "excalibur", net); !errors.Is(err, ErrSeedScheme) {
+		t.Errorf("Expected a 12-word forge wallet to fail, got %v", err)
+	}
+	f, _, err := CreateWalletFile(path, "forged", prophecy, SeedForge, "excalibur", net)
+	if err != nil {
+		t.Fatalf("CreateWalletFile() error = %v", err)
+	}
+	loaded, err := OpenWalletFile(path)
+	if err != nil || loaded.Seed != SeedForge {
+		t.Fatalf("OpenWalletFile() = %+v, %v", loaded, err)
+	}
+	hd, err := loaded.Unlock("excalibur", net)
+	if err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	desc, _ := hd.Descriptor()
+	if desc.String() != f.Descriptor {
+		t.Errorf("Unlocked descriptor %s does not match %s", desc, f.Descriptor)
+	}
+	bip39, _ := NewHDWallet(prophecy, "", net)
+	if other, _ := bip39.Descriptor(); other.String() == f.Descriptor {
+		t.Error("Forge wallet derives the BIP-39 keys")
+	}
+
+	// The scheme is bound to the ciphertext
+	loaded.Seed = SeedBIP39
+	if _, err := loaded.Words("excalibur"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected a tampered seed scheme to fail decryption, got %v", err)
+	}
+}
+
+func TestWalletFileLegacySeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets", "axiom", "wallet.json")
+	net := &chaincfg.RegressionNetParams
+	axiom, _ := crypto.ParseLegacyProphecy("sword legend pull magic kingdom artist stone destroy forget fire steel honey question")
+
+	if _, _, err := CreateWalletFile(path, "axiom", axiom, SeedBIP39, "excalibur", net); !errors.Is(err, ErrSeedScheme) {
+		t.Errorf("Expected the axiom to need the legacy scheme, got %v", err)
+	}
+	f, _, err := CreateWalletFile(path, "axiom", axiom, SeedLegacy, "excalibur", net)
+	if err != nil {
+		t.Fatalf("CreateWalletFile() error = %v", err)
+	}
+	loaded, err := OpenWalletFile(path)
+	if err != nil || loaded.Seed != SeedLegacy {
+		t.Fatalf("OpenWalletFile() = %+v, %v", loaded, err)
+	}
+	hd, err := loaded.Unlock("excalibur", net)
+	if err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	legacy, _ := NewHDWalletFromSeed(crypto.MnemonicToSeed(axiom, ""), net)
+	desc, _ := hd.Descriptor()
+	if want, _ := legacy.Descriptor(); desc.String() != f.Descriptor || desc.String() != want.String() {
+		t.Errorf("Unlocked descriptor %s, want the all-words seed's %s", desc, want)
+	}
+}
+
+func TestWalletFileDecoy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets", "vault", "wallet.json")
+	net := &chaincfg.RegressionNetParams
+	decoyWords := strings.Fields("legal winner thank year wave sausage worth useful legal winner thank yellow")
+
+	f, _, err := CreateWalletFile(path, "vault", bip86Words, SeedBIP39, "real", net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realDesc := f.Descriptor
+	plain, _ := os.ReadFile(path)
+
+	if _, err := f.HideBehindDecoy("real", decoyWords, "real", net); err == nil {
+		t.Error("Expected the decoy passphrase to differ")
+	}
+	decoy, err := f.HideBehindDecoy("real", decoyWords, "duress", net)
+	if err != nil {
+		t.Fatalf("HideBehindDecoy() error = %v", err)
+	}
+	decoyDesc, _ := decoy.Descriptor()
+
+	loaded, err := OpenWalletFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Descriptor != decoyDesc.String() {
+		t.Errorf("Visible descriptor = %s, want the decoy's", loaded.Descriptor)
+	}
+	visible, err := loaded.Open("duress")
+	if err != nil || visible.Hidden || strings.Join(visible.Words, " ") != strings.Join(decoyWords, " ") {
+		t.Errorf("Open(decoy) = %+v, %v", visible, err)
+	}
+	hidden, err := loaded.Open("real")
+	if err != nil || !hidden.Hidden || hidden.Descriptor != realDesc || strings.Join(hidden.Words, " ") != strings.Join(bip86Words, " ") {
+		t.Errorf("Open(real) = %+v, %v", hidden, err)
+	}
+	if _, err := loaded.Open("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := loaded.HideBehindDecoy("real", decoyWords, "other", net); !errors.Is(err, ErrHiddenWallet) {
+		t.Errorf("Expected ErrHiddenWallet, got %v", err)
+	}
+
+	// The file does not reveal the hidden wallet: its descriptor is sealed
+	// and the slots are shaped as in a file without one
+	hiddenFile, _ := os.ReadFile(path)
+	if strings.Contains(string(hiddenFile), realDesc) {
+		t.Error("Wallet file contains the hidden descriptor in the clear")
+	}
+	var before, after WalletFile
+	json.Unmarshal(plain, &before)
+	json.Unmarshal(hiddenFile, &after)
+	for i := range before.Slots {
+		if len(before.Slots[i].Ciphertext) != len(after.Slots[i].Ciphertext) {
+			t.Errorf("Slot %d is %d bytes with a hidden wallet, %d without", i,
+				len(after.Slots[i].Ciphertext), len(before.Slots[i].Ciphertext))
+		}
+	}
+}
+
+func TestWalletFileVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	f := &WalletFile{Version: 1, Name: "old", Network: "regtest", Descriptor: "tr(old)", KDF: walletKDF, KDFRounds: crypto.HPP1Rounds, path: path}
+	slot, err := f.seal(walletSecret{Words: bip86Words}, "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Salt, f.Nonce, f.Ciphertext = slot.Salt, slot.Nonce, slot.Ciphertext
+	if err := f.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := OpenWalletFile(path)
+	if err != nil {
+		t.Fatalf("OpenWalletFile(v1) error = %v", err)
+	}
+	account, err := loaded.Open("pw")
+	if err != nil || account.Hidden || account.Descriptor != "tr(old)" {
+		t.Errorf("Open(v1) = %+v, %v", account, err)
+	}
+}
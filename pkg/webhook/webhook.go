@@ -0,0 +1,348 @@
+// Package webhook posts treasury events to external endpoints, such as
+// accounting systems, so they need not poll the API. Each delivery is the
+// event as JSON, signed with HMAC-SHA256 under the endpoint's secret, and is
+// retried with exponential backoff until the endpoint answers 2xx or the
+// attempts run out. Every attempt is appended to a delivery log.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/events"
+)
+
+const (
+	// Headers sent with each delivery
+	EventHeader     = "X-EXS-Event"
+	DeliveryHeader  = "X-EXS-Delivery"
+	TimestampHeader = "X-EXS-Timestamp"
+	SignatureHeader = "X-EXS-Signature"
+
+	// DefaultAttempts is how often a delivery is tried before it is given up
+	DefaultAttempts = 8
+	// DefaultBackoff is the wait before the first retry; it doubles with
+	// each retry up to maxBackoff
+	DefaultBackoff = time.Second
+	// MaxSignatureAge is how old a delivery Verify accepts
+	MaxSignatureAge = 5 * time.Minute
+
+	maxBackoff = 5 * time.Minute
+	// queueSize bounds the deliveries waiting per endpoint
+	queueSize = 1024
+	// recentSize is how many attempts Recent keeps
+	recentSize = 200
+)
+
+var (
+	// ErrQueueFull indicates an endpoint too far behind to take an event
+	ErrQueueFull = errors.New("webhook queue full")
+	// ErrSignature indicates a delivery whose signature does not verify
+	ErrSignature = errors.New("invalid webhook signature")
+)
+
+// Endpoint receives the events of the listed types
+type Endpoint struct {
+	URL    string
+	Secret string
+	// Events lists the event types delivered, empty for all
+	Events []string
+}
+
+// Config configures a Dispatcher
+type Config struct {
+	Endpoints []Endpoint
+	// LogPath is the delivery log, none when empty
+	LogPath string
+	// Attempts defaults to DefaultAttempts and Backoff to DefaultBackoff
+	Attempts int
+	Backoff  time.Duration
+	// Client defaults to a client with a 10 second timeout
+	Client *http.Client
+}
+
+// Attempt records one try to deliver an event
+type Attempt struct {
+	// Delivery identifies the event's delivery to the endpoint across its
+	// attempts
+	Delivery string    `json:"delivery"`
+	URL      string    `json:"url"`
+	Event    string    `json:"event"`
+	Seq      uint64    `json:"seq"`
+	Attempt  int       `json:"attempt"`
+	Time     time.Time `json:"time"`
+	Status   int       `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Delivered is set on the attempt the endpoint accepted; GaveUp on the
+	// last attempt of a delivery that failed
+	Delivered bool `json:"delivered"`
+	GaveUp    bool `json:"gave_up,omitempty"`
+}
+
+// delivery is an event on its way to an endpoint
+type delivery struct {
+	id    string
+	event events.Event
+	body  []byte
+}
+
+// Dispatcher delivers events to its endpoints, each from its own queue in
+// the order sent
+type Dispatcher struct {
+	config Config
+	log    *os.File
+	queues []chan delivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// sendMu keeps Send from queueing to a closed dispatcher
+	sendMu sync.RWMutex
+	closed bool
+
+	mu     sync.Mutex
+	recent []Attempt
+}
+
+// New starts delivering to config's endpoints
+func New(config Config) (*Dispatcher, error) {
+	for _, e := range config.Endpoints {
+		if !strings.HasPrefix(e.URL, "https://") && !strings.HasPrefix(e.URL, "http://") {
+			return nil, fmt.Errorf("invalid webhook URL: %s", e.URL)
+		}
+		if e.Secret == "" {
+			return nil, fmt.Errorf("webhook %s needs a secret", e.URL)
+		}
+	}
+	if config.Attempts <= 0 {
+		config.Attempts = DefaultAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultBackoff
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	d := &Dispatcher{config: config}
+	if config.LogPath != "" {
+		if err := os.MkdirAll(filepath.Dir(config.LogPath), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create webhook log directory: %w", err)
+		}
+		log, err := os.OpenFile(config.LogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open webhook log: %w", err)
+		}
+		d.log = log
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for _, e := range config.Endpoints {
+		queue := make(chan delivery, queueSize)
+		d.queues = append(d.queues, queue)
+		d.wg.Add(1)
+		go d.run(e, queue)
+	}
+	return d, nil
+}
+
+// Send queues event for every endpoint taking its type. It does not block,
+// so it may be called with the treasury locked; an endpoint whose queue is
+// full misses the event, reported as ErrQueueFull.
+func (d *Dispatcher) Send(event events.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+	}
+	d.sendMu.RLock()
+	defer d.sendMu.RUnlock()
+	if d.closed {
+		return nil
+	}
+	var errs []error
+	for i, e := range d.config.Endpoints {
+		if len(e.Events) > 0 && !slices.Contains(e.Events, event.Type) {
+			continue
+		}
+		select {
+		case d.queues[i] <- delivery{id: newDeliveryID(), event: event, body: body}:
+		default:
+			errs = append(errs, fmt.Errorf("%w: %s", ErrQueueFull, e.URL))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Recent returns the latest attempts, oldest first
+func (d *Dispatcher) Recent() []Attempt {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.recent)
+}
+
+// Close stops retrying, abandons the queued deliveries and closes the log
+func (d *Dispatcher) Close() error {
+	d.sendMu.Lock()
+	if d.closed {
+		d.sendMu.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.cancel()
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.sendMu.Unlock()
+	d.wg.Wait()
+	if d.log != nil {
+		return d.log.Close()
+	}
+	return nil
+}
+
+// run delivers an endpoint's queue in order
+func (d *Dispatcher) run(e Endpoint, queue <-chan delivery) {
+	defer d.wg.Done()
+	for job := range queue {
+		if d.ctx.Err() != nil {
+			continue
+		}
+		d.deliver(e, job)
+	}
+}
+
+// deliver tries job until the endpoint accepts it, the attempts run out or
+// the dispatcher closes
+func (d *Dispatcher) deliver(e Endpoint, job delivery) {
+	backoff := d.config.Backoff
+	for attempt := 1; ; attempt++ {
+		status, err := d.post(e, job)
+		a := Attempt{
+			Delivery:  job.id,
+			URL:       e.URL,
+			Event:     job.event.Type,
+			Seq:       job.event.Seq,
+			Attempt:   attempt,
+			Time:      time.Now().UTC(),
+			Status:    status,
+			Delivered: err == nil,
+		}
+		if err != nil {
+			a.Error = err.Error()
+			a.GaveUp = attempt >= d.config.Attempts
+		}
+		d.record(a)
+		if a.Delivered || a.GaveUp {
+			return
+		}
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// post sends job to e once
+func (d *Dispatcher) post(e Endpoint, job delivery) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, e.URL, bytes.NewReader(job.body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, job.event.Type)
+	req.Header.Set(DeliveryHeader, job.id)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign([]byte(e.Secret), timestamp, job.body))
+	resp, err := d.config.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// record appends a to the log and the recent attempts
+func (d *Dispatcher) record(a Attempt) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recent = append(d.recent, a)
+	if len(d.recent) > recentSize {
+		d.recent = slices.Delete(d.recent, 0, len(d.recent)-recentSize)
+	}
+	if d.log != nil {
+		data, _ := json.Marshal(a)
+		d.log.Write(append(data, '\n'))
+	}
+}
+
+func newDeliveryID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Sign returns the signature header of body sent at timestamp (Unix
+// seconds): sha256= and the hex HMAC-SHA256 of "<timestamp>.<body>"
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's signature and that it was sent within
+// MaxSignatureAge of now, as a receiving endpoint should
+func Verify(secret []byte, header http.Header, body []byte, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing timestamp", ErrSignature)
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return fmt.Errorf("%w: sent %s ago", ErrSignature, age.Round(time.Second))
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(secret, timestamp, body))) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// ReadLog reads the attempts written to a delivery log
+func ReadLog(path string) ([]Attempt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var attempts []Attempt
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var a Attempt
+		if err := json.Unmarshal(line, &a); err != nil {
+			return nil, fmt.Errorf("webhook log %s line %d: %w", path, i+1, err)
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, nil
+}
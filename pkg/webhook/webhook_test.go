@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Holedozer1229/Excalibur-EXS/pkg/events"
+)
+
+func TestDispatcherRetries(t *testing.T) {
+	secret := []byte("s3cret")
+	received := make(chan events.Event, 4)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header, body, time.Now()); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+		var event events.Event
+		json.Unmarshal(body, &event)
+		if r.Header.Get(EventHeader) != event.Type {
+			t.Errorf("%s = %q for a %s event", EventHeader, r.Header.Get(EventHeader), event.Type)
+		}
+		received <- event
+	}))
+	defer srv.Close()
+
+	logPath := filepath.Join(t.TempDir(), "webhooks.log")
+	d, err := New(Config{
+		Endpoints: []Endpoint{{URL: srv.URL, Secret: string(secret), Events: []string{"forge"}}},
+		LogPath:   logPath,
+		Backoff:   time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := events.NewBus()
+	balance, _ := bus.Publish("balance", 1)
+	forge, _ := bus.Publish("forge", map[string]string{"miner_address": "bc1p"})
+	if err := d.Send(balance); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Send(forge); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-received:
+		if event.Seq != forge.Seq || event.Type != "forge" {
+			t.Errorf("Delivered %+v, want the forge event", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Forge event not delivered")
+	}
+	waitAttempts(t, d, 3)
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err := ReadLog(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 3 || attempts[0].Status != http.StatusServiceUnavailable || !attempts[2].Delivered || attempts[2].Attempt != 3 {
+		t.Errorf("Logged attempts = %+v, want two refusals then the delivery", attempts)
+	}
+	if attempts[0].Delivery != attempts[2].Delivery {
+		t.Error("Expected the retries to share a delivery ID")
+	}
+	if recent := d.Recent(); len(recent) != 3 {
+		t.Errorf("Recent() has %d attempts, want 3", len(recent))
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	d, err := New(Config{Endpoints: []Endpoint{{URL: srv.URL, Secret: "x"}}, Attempts: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.Send(events.Event{Seq: 1, Type: "claim"})
+
+	if recent := waitAttempts(t, d, 2); !recent[1].GaveUp || recent[0].GaveUp {
+		t.Errorf("Recent() = %+v, want the second attempt given up", recent)
+	}
+}
+
+// waitAttempts waits for d to record n attempts
+func waitAttempts(t *testing.T, d *Dispatcher, n int) []Attempt {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if recent := d.Recent(); len(recent) >= n {
+			return recent
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Recent() = %+v, want %d attempts", d.Recent(), n)
+	return nil
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"seq":1}`)
+	now := time.Unix(1_700_000_000, 0)
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	header.Set(SignatureHeader, Sign(secret, now.Unix(), body))
+
+	if err := Verify(secret, header, body, now.Add(time.Minute)); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+	if err := Verify(secret, header, []byte(`{"seq":2}`), now); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected a changed body to fail, got %v", err)
+	}
+	if err := Verify([]byte("other"), header, body, now); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected another secret to fail, got %v", err)
+	}
+	if err := Verify(secret, header, body, now.Add(MaxSignatureAge+time.Second)); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected an old delivery to fail, got %v", err)
+	}
+}
+
+func TestNewRefusesEndpoints(t *testing.T) {
+	if _, err := New(Config{Endpoints: []Endpoint{{URL: "ftp://x", Secret: "x"}}}); err == nil {
+		t.Error("Expected a non-HTTP URL to be refused")
+	}
+	if _, err := New(Config{Endpoints: []Endpoint{{URL: "https://x"}}}); err == nil {
+		t.Error("Expected an endpoint without a secret to be refused")
+	}
+}
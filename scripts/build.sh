@@ -0,0 +1,40 @@
+#!/bin/bash
+
+# Excalibur $EXS - Reproducible Go builds
+# Builds the Go binaries into ./bin with version metadata embedded via ldflags.
+# The build date is the commit time (or SOURCE_DATE_EPOCH), so rebuilding a
+# commit yields the same binary and the same build hash.
+#
+# Usage: scripts/build.sh [command...]   (default: all commands below)
+# Environment: VERSION, SOURCE_DATE_EPOCH, GOOS, GOARCH, OUT_DIR
+
+set -e  # Exit on error
+
+cd "$(dirname "$0")/.."
+
+PKG="github.com/Holedozer1229/Excalibur-EXS/pkg/buildinfo"
+OUT_DIR="${OUT_DIR:-bin}"
+COMMANDS=("$@")
+if [ ${#COMMANDS[@]} -eq 0 ]; then
+    COMMANDS=(exs-node rosetta treasury tetra_pow guardian)
+fi
+
+COMMIT="$(git rev-parse HEAD 2>/dev/null || echo unknown)"
+if [ -n "$SOURCE_DATE_EPOCH" ]; then
+    BUILD_DATE="$(date -u -d "@$SOURCE_DATE_EPOCH" +%Y-%m-%dT%H:%M:%SZ)"
+else
+    BUILD_DATE="$(TZ=UTC git log -1 --date=format-local:%Y-%m-%dT%H:%M:%SZ --format=%cd 2>/dev/null || echo unknown)"
+fi
+
+LDFLAGS="-s -w -buildid= -X $PKG.Commit=$COMMIT -X $PKG.BuildDate=$BUILD_DATE"
+if [ -n "$VERSION" ]; then
+    LDFLAGS="$LDFLAGS -X $PKG.Version=$VERSION"
+fi
+
+mkdir -p "$OUT_DIR"
+for name in "${COMMANDS[@]}"; do
+    echo "Building $name ($COMMIT, $BUILD_DATE)"
+    CGO_ENABLED=0 go build -trimpath -ldflags "$LDFLAGS" -o "$OUT_DIR/$name" "./cmd/$name"
+done
+
+echo "✓ Binaries written to $OUT_DIR/"